@@ -0,0 +1,423 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/baseimages"
+	"github.com/duaraghav8/dockershrink/internal/buildcontext"
+	"github.com/duaraghav8/dockershrink/internal/config"
+	"github.com/duaraghav8/dockershrink/internal/daemon"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/fileindex"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/output"
+	"github.com/duaraghav8/dockershrink/internal/project"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+	"github.com/duaraghav8/dockershrink/internal/sinks"
+	"github.com/duaraghav8/dockershrink/internal/targets"
+	"github.com/duaraghav8/dockershrink/internal/units"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze",
+	Short: "Analyzes the Docker image definition for a project without modifying it",
+	Long: `Scores the Dockerfile and .dockerignore files of a NodeJS project and reports every detected inefficiency along with its severity and estimated size impact.
+This command never modifies the project and does not require an OpenAI API key. To speed up repeated runs, it keeps an index of the project's files in the .dockershrink directory.
+With --recursive, or --target given several times, up to --jobs Dockerfiles are analyzed at once and the results are printed in order. A Dockerfile that fails to be analyzed doesn't stop the others. The errors are listed at the end and the command exits with status 2 if only some of the Dockerfiles failed.
+With --fail-on or --min-score, the command exits with status 3 if a Dockerfile doesn't meet them, eg- to fail a CI pipeline. Errors still exit with status 1.
+Dockerfiles are also checked against the policies of your organization given with --policy, and the Rego policies of the OPA bundles given with --policy-bundle. Violations make the command exit with status 3 too.
+To adopt this on existing Dockerfiles, record their current findings with --write-baseline and pass the file to --baseline afterwards, so that only new findings are reported.
+With --watch, the project is analyzed again every time its Dockerfile, .dockerignore, package manifests or configuration are saved, until interrupted. Only the new and resolved findings are printed after the first analysis.`,
+	Run: runAnalyze,
+}
+
+// maxPrintedInstructionSizes is the number of instructions printed with their estimated size
+const maxPrintedInstructionSizes = 10
+
+var severityColors = map[models.Severity]color.Attribute{
+	models.SeverityHigh:   color.FgRed,
+	models.SeverityMedium: color.FgYellow,
+	models.SeverityLow:    color.FgCyan,
+	models.SeverityInfo:   color.FgWhite,
+}
+
+func init() {
+	analyzeCmd.Flags().StringVar(&dockerfilePath, "dockerfile", "Dockerfile", "Path to Dockerfile")
+	analyzeCmd.Flags().StringVar(&dockerignorePath, "dockerignore", ".dockerignore", "Path to .dockerignore")
+	analyzeCmd.Flags().StringVar(&goal, "goal", string(models.GoalAll), "What to analyze the image for: size, build-speed, security or all")
+	addProfileFlag(analyzeCmd)
+	addOrgPolicyFlag(analyzeCmd)
+	addManifestFlag(analyzeCmd)
+	addPolicyBundleFlag(analyzeCmd)
+	analyzeCmd.Flags().StringVar(&platforms, "platforms", "", "Comma-separated platforms the image is built for, eg- linux/amd64,linux/arm64. Base images are checked against all of them")
+	analyzeCmd.Flags().StringArrayVar(&buildContextFlags, "build-context", nil, buildContextFlagUsage)
+	analyzeCmd.Flags().BoolVar(&useDaemon, "daemon", false, daemonFlagUsage)
+	analyzeCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Analyze every Dockerfile under the current directory, using the directory of each Dockerfile as its project")
+	addTargetFlags(analyzeCmd)
+	addReportFlags(analyzeCmd)
+	addCIFormatFlags(analyzeCmd)
+	addPolicyFlags(analyzeCmd)
+	addBaselineFlags(analyzeCmd)
+	analyzeCmd.Flags().BoolVarP(&watchMode, "watch", "w", false, "Analyze again whenever the Dockerfile, .dockerignore, package manifests or configuration change, printing the new and resolved findings")
+
+	rootCmd.AddCommand(analyzeCmd)
+}
+
+func runAnalyze(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+
+	policy := policyFromFlags(logger)
+	loadBaseline(logger)
+
+	if watchMode {
+		runWatch(cmd.Context(), logger)
+		return
+	}
+
+	if multipleTargets() {
+		violated := false
+		partial := runRecursive(logger, func(t *targets.Target, cfg *config.Config, root string) (func(), error) {
+			analysis, err := analyzeTarget(cmd.Context(), logger, cfg, t, &coldLoader{ctx: cmd.Context(), logger: logger})
+			if err != nil {
+				return nil, err
+			}
+			return func() {
+				applyBaseline(logger, analysis)
+				printAnalysis(analysis)
+				sendAnalysisReport(cmd.Context(), logger, cfg, root, t, analysis)
+				violated = checkPolicy(policy, t.Dockerfile, analysis) || violated
+			}, nil
+		})
+		writeBaseline(logger)
+		if partial {
+			exit(exitPartialFailure)
+		}
+		if violated {
+			exit(exitViolations)
+		}
+		return
+	}
+
+	analysis, cfg, cwd := analyzeProject(cmd.Context(), logger)
+	applyBaseline(logger, analysis)
+	printAnalysis(analysis)
+	sendAnalysisReport(cmd.Context(), logger, cfg, cwd, projectTarget(cwd), analysis)
+	writeBaseline(logger)
+	if checkPolicy(policy, dockerfilePath, analysis) {
+		exit(exitViolations)
+	}
+}
+
+func sendAnalysisReport(ctx context.Context, logger *log.Logger, cfg *config.Config, projectDir string, t *targets.Target, analysis *project.AnalysisResponse) {
+	report := &sinks.Report{
+		Command:          "analyze",
+		Timestamp:        time.Now(),
+		DockerfilePath:   t.Dockerfile,
+		Owners:           dockerfileOwners(logger, projectDir, cfg, t.Dockerfile),
+		Score:            &analysis.Score,
+		ScoreBreakdown:   rules.Breakdown(analysis.Findings),
+		Findings:         analysis.Findings,
+		InstructionSizes: analysis.Sizes,
+		Variants:         analysis.Variants,
+
+		EstimatedImageSize: analysis.EstimatedImageSize,
+	}
+	addFixes(ctx, logger, cfg, t, addResult(output.FromReport(report)))
+	sendReport(logger, cfg, report)
+}
+
+// analyzeProject runs the static rules on the project in the current directory.
+// It returns the analysis along with the project's configuration and directory.
+func analyzeProject(ctx context.Context, logger *log.Logger) (*project.AnalysisResponse, *config.Config, string) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		logger.Fatalf("Error getting current working directory: %v", err)
+	}
+	cfg, err := loadConfig(cwd)
+	if err != nil {
+		logger.Fatalf("Error loading configuration: %v", err)
+	}
+
+	if useDaemon {
+		analysis, err := analyzeWithDaemon(ctx, cwd)
+		if err == nil {
+			return analysis, cfg, cwd
+		}
+		logger.Warnf("Failed to analyze with the daemon, analyzing in this process instead: %v", err)
+	}
+
+	analysis, err := analyzeTarget(ctx, logger, cfg, projectTarget(cwd), &coldLoader{ctx: ctx, logger: logger, index: true})
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	return analysis, cfg, cwd
+}
+
+// projectTarget returns the Dockerfile given by the flags, built from the project in dir
+func projectTarget(dir string) *targets.Target {
+	return &targets.Target{Dir: dir, Dockerfile: dockerfilePath, Dockerignore: dockerignorePath}
+}
+
+// analyzeTarget runs the static rules on a single Dockerfile and the project it builds,
+// with the state of the project loaded by loader
+func analyzeTarget(ctx context.Context, logger *log.Logger, cfg *config.Config, t *targets.Target, loader daemon.Loader) (*project.AnalysisResponse, error) {
+	analysisGoal, err := models.ParseGoal(goal)
+	if err != nil {
+		return nil, err
+	}
+	proj, dockerfileObject, err := loadTarget(logger, t, loader)
+	if err != nil {
+		return nil, err
+	}
+	proj.SetEvents(logEvents(logger))
+
+	platformTargets, err := targetPlatforms(logger, cfg)
+	if err != nil {
+		return nil, err
+	}
+	severities, err := ruleSeverities(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid configuration in %s: %w", config.Filename, err)
+	}
+	policies, err := loadPolicies(cfg)
+	if err != nil {
+		return nil, err
+	}
+	proj.SetPolicies(policies)
+	proj.SetManifests(loadManifests(logger, cfg, t))
+	analysis := proj.AnalyzeDockerImage(&project.AnalyzeOptions{
+		Goal:       analysisGoal,
+		Platforms:  platformTargets,
+		Severities: severities,
+		BuildArgs:  findBuildArgs(logger, dockerfileObject, t.Dockerfile),
+	})
+
+	external, err := externalFindings(ctx, cfg, proj, severities)
+	if err != nil {
+		return nil, err
+	}
+	if len(external) > 0 {
+		analysis.Findings = append(analysis.Findings, external...)
+		rules.SortFindings(analysis.Findings)
+		analysis.Score = rules.Score(analysis.Findings)
+	}
+	return analysis, nil
+}
+
+// externalFindings returns the findings of the rule plugins of the configuration,
+// and the violations of the Rego policies of the OPA bundles given with --policy-bundle
+func externalFindings(ctx context.Context, cfg *config.Config, proj *project.Project, severities map[string]models.Severity) ([]*models.Finding, error) {
+	findings := []*models.Finding{}
+	if len(cfg.Lint.Plugins) > 0 {
+		plugins := make([]*rules.RulePlugin, 0, len(cfg.Lint.Plugins))
+		for _, pc := range cfg.Lint.Plugins {
+			p, err := rules.LoadRulePlugin(ctx, pc.Command)
+			if err != nil {
+				return nil, err
+			}
+			plugins = append(plugins, p)
+		}
+		found, err := proj.CheckRulePlugins(ctx, plugins, severities)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, found...)
+	}
+
+	if len(policyBundles) > 0 {
+		violations, err := proj.CheckPolicyBundles(ctx, policyBundles)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, violations...)
+	}
+	return findings, nil
+}
+
+// loadTarget loads the project a single Dockerfile builds, with its state loaded by loader.
+// The parsed Dockerfile is returned along with the project.
+func loadTarget(logger *log.Logger, t *targets.Target, loader daemon.Loader) (*project.Project, *dockerfile.Dockerfile, error) {
+	dockerfileObject, err := loader.Dockerfile(t.Dockerfile)
+	if err != nil {
+		return nil, nil, err
+	}
+	proj, _, err := newTargetProject(logger, t, dockerfileObject, loader, nil, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return proj, dockerfileObject, nil
+}
+
+// newTargetProject returns the project the parsed Dockerfile of the target builds, with its state loaded by loader.
+// The LLM can't read the files for which ignored returns true, it receives paths relative to the directory of the target.
+// The directory trees given to the LLM are only built if withTrees is set, since only optimizing needs them.
+// The named build contexts of the Dockerfile are returned along with the project.
+func newTargetProject(logger *log.Logger, t *targets.Target, d *dockerfile.Dockerfile, loader daemon.Loader, ignored func(path string) bool, withTrees bool) (*project.Project, []*buildcontext.Context, error) {
+	dir, err := filepath.Abs(t.Dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error resolving project directory: %w", err)
+	}
+
+	// the path is kept even if the file doesn't exist, so that findings point to where it's expected
+	dockerignoreObject, err := readDockerignore(t.Dockerignore)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	packageJson, err := getPackageJson(t.Dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("Failed to read package.json: %w", err)
+	}
+	ws, err := getWorkspace(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dirTree := ""
+	if withTrees {
+		if dirTree, err = getDirTree(dir, ignored); err != nil {
+			return nil, nil, err
+		}
+	}
+	if ignored, err = withExcludedPaths(ignored); err != nil {
+		return nil, nil, err
+	}
+	projectDirFS := restrictedfilesystem.NewRestrictedFilesystem(dir, dirTree, t.Dockerfile, t.Dockerignore)
+	projectDirFS.SetIgnored(ignored)
+	buildContexts, err := addBuildContexts(logger, projectDirFS, t.Dockerfile, withTrees)
+	if err != nil {
+		return nil, nil, err
+	}
+	proj := project.NewProject(d, dockerignoreObject, packageJson, projectDirFS, ws, workspacePackage)
+	proj.SetBaseImages(loader.BaseImages(d))
+	if x := loader.FileIndex(dir); x != nil {
+		proj.SetFileIndex(x)
+	}
+	return proj, buildContexts, nil
+}
+
+func printAnalysis(analysis *project.AnalysisResponse) {
+	printScore(analysis.Score, rules.Breakdown(analysis.Findings))
+	printInstructionSizes(analysis.Sizes)
+	printFindings(analysis.Findings)
+	printVariants(analysis.Findings, analysis.Variants)
+}
+
+// printScore prints the score along with its breakdown, if it's known
+func printScore(score int, breakdown *models.ScoreBreakdown) {
+	if breakdown == nil {
+		fmt.Printf("\nScore: %s\n", scoreColor(score).Add(color.Bold).Sprintf("%d/100", score))
+		return
+	}
+	fmt.Printf("\nScore: %s\n", scoreColor(score).Add(color.Bold).Sprintf("%d/100 (%s)", score, breakdown.Grade))
+	for _, aspect := range []struct {
+		name  string
+		score models.AspectScore
+	}{
+		{"Size efficiency", breakdown.Size},
+		{"Cache friendliness", breakdown.Cache},
+		{"Security posture", breakdown.Security},
+	} {
+		fmt.Printf("  %-20s %s  %d finding(s)\n", aspect.name, scoreColor(aspect.score.Score).Sprintf("%3d %s", aspect.score.Score, aspect.score.Grade), aspect.score.Findings)
+	}
+}
+
+// scoreColor returns the color scores are printed in: red below 50, yellow below 80 and green otherwise
+func scoreColor(score int) *color.Color {
+	if score < 50 {
+		return color.New(color.FgRed)
+	} else if score < 80 {
+		return color.New(color.FgYellow)
+	}
+	return color.New(color.FgGreen)
+}
+
+func printFindings(findings []*models.Finding) {
+	if len(findings) == 0 {
+		color.Green("No inefficiencies found in the Docker image definition.")
+		return
+	}
+
+	var totalImpact int64
+	fmt.Printf("\n============ %d Finding(s) ============\n", len(findings))
+	for _, f := range findings {
+		location := f.Filepath
+		if f.Line > 0 {
+			location = fmt.Sprintf("%s:%d", f.Filepath, f.Line)
+		}
+		color.Cyan("Severity: " + color.New(severityColors[f.Severity]).Sprint(f.Severity))
+		color.Cyan("Rule: " + color.WhiteString("%s (%s)", f.Code, f.Rule))
+		color.Cyan("File: " + color.BlueString(location))
+		color.Cyan("Title: " + color.GreenString(f.Title))
+		color.Cyan("Description: " + color.WhiteString(f.Description))
+		if f.Fix != nil {
+			color.Cyan("Fix: " + color.WhiteString("replace lines %d-%d with:\n%s", f.Fix.Line, f.Fix.EndLine, f.Fix.Replacement))
+		}
+		if f.EstimatedSizeImpact > 0 {
+			color.Cyan("Estimated Size Impact: " + color.WhiteString("~%s", units.FormatBytes(f.EstimatedSizeImpact)))
+			totalImpact += f.EstimatedSizeImpact
+		}
+		fmt.Println("---------------------------------")
+	}
+	if totalImpact > 0 {
+		fmt.Printf("\nEstimated total savings: ~%s\n", units.FormatBytes(totalImpact))
+	}
+}
+
+// coldLoader loads the state of a project from scratch for a single analysis
+type coldLoader struct {
+	// ctx cancels the requests made to load base image data
+	ctx    context.Context
+	logger *log.Logger
+	// index keeps an index of the project's files in its directory to speed up later runs
+	index bool
+}
+
+func (l *coldLoader) Dockerfile(path string) (*dockerfile.Dockerfile, error) {
+	return readDockerfile(l.logger, path)
+}
+
+func (l *coldLoader) BaseImages(d *dockerfile.Dockerfile) *baseimages.Matrix {
+	return loadBaseImages(l.ctx, l.logger, d)
+}
+
+func (l *coldLoader) FileIndex(dir string) *fileindex.Index {
+	if !l.index {
+		return nil
+	}
+	return indexProject(l.logger, dir)
+}
+
+// printInstructionSizes prints the instructions that add the most to the size of the image
+func printInstructionSizes(sizes []*models.InstructionSize) {
+	if len(sizes) == 0 {
+		return
+	}
+	fmt.Printf("\n============ Estimated Size by Instruction ============\n")
+	for i, s := range sizes {
+		if i == maxPrintedInstructionSizes {
+			fmt.Printf("... and %d more\n", len(sizes)-i)
+			break
+		}
+		instruction, _, _ := strings.Cut(s.Instruction, "\n")
+		if len(instruction) > 60 {
+			instruction = instruction[:57] + "..."
+		}
+		fmt.Printf("%s  %s  %s %s\n",
+			color.WhiteString("%10s", "~"+units.FormatBytes(s.EstimatedSize)),
+			color.BlueString("%s:%d", s.Filepath, s.Line),
+			instruction,
+			color.New(color.Faint).Sprintf("(%s)", s.Basis),
+		)
+	}
+}
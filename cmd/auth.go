@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/credentials"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// authProfileEnv selects the profile whose API key is used, like --auth-profile
+const authProfileEnv = "DOCKERSHRINK_AUTH_PROFILE"
+
+// authProfile is the profile selected with --auth-profile for the run
+var authProfile string
+
+// loginProfile is the profile of "auth login" and "auth logout"
+var loginProfile string
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manages the API keys of named profiles",
+	Long: `Stores the OpenAI API keys of named profiles, eg- "work" and "personal", so that they don't have to be exported in every shell.
+Keys are stored in the macOS keychain, the Windows Credential Manager, or the Secret Service keyring (GNOME Keyring, KWallet) on Linux.
+Where there is no keychain, eg- on a headless server, or it fails, they are stored in a file encrypted with a passphrase, read from $` + credentials.EnvPassphrase + ` or prompted for.
+
+Commands use the key of the profile selected with --auth-profile or $` + authProfileEnv + `.
+Otherwise, --openai-api-key and $OPENAI_API_KEY take precedence over the key of the "default" profile.`,
+}
+
+var authLoginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Stores the API key of a profile",
+	Long: `Stores the API key of a profile, replacing the one stored before. The key is prompted for without being shown,
+or read from stdin if it isn't a terminal, eg- "echo $KEY | dockershrink auth login --profile ci".`,
+	Args: cobra.NoArgs,
+	Run:  runAuthLogin,
+}
+
+var authLogoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Deletes the API key of a profile",
+	Args:  cobra.NoArgs,
+	Run:   runAuthLogout,
+}
+
+var authListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the profiles and where their keys are stored",
+	Long:  "Lists the profiles logged in and where their keys are stored. The keys themselves are never shown.",
+	Args:  cobra.NoArgs,
+	Run:   runAuthList,
+}
+
+func init() {
+	for _, c := range []*cobra.Command{authLoginCmd, authLogoutCmd} {
+		c.Flags().StringVar(&loginProfile, "profile", "", "Name of the profile (default: --auth-profile, $"+authProfileEnv+" or \""+credentials.DefaultProfile+"\")")
+	}
+	authCmd.AddCommand(authLoginCmd)
+	authCmd.AddCommand(authLogoutCmd)
+	authCmd.AddCommand(authListCmd)
+	rootCmd.AddCommand(authCmd)
+}
+
+func runAuthLogin(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+	store := credentialStore(logger)
+	profile := authCommandProfile()
+	if err := credentials.ValidateProfileName(profile); err != nil {
+		logger.Fatalf("Error: %v", err)
+	}
+
+	key, err := readSecret(fmt.Sprintf("OpenAI API key for profile %s: ", profile))
+	if err != nil {
+		logger.Fatalf("Error reading the API key: %v", err)
+	}
+	if key == "" {
+		logger.Fatalf("Error: the API key is empty")
+	}
+	log.AddSecret(key)
+	backend, err := store.Login(profile, key)
+	if err != nil {
+		logger.Fatalf("Error storing the API key: %v", err)
+	}
+	if store.Keychain != nil && backend != store.Keychain {
+		logger.Warnf("* The %s couldn't be used, the key was stored in %s instead", store.Keychain.Name(), store.File.Path)
+	}
+	setOutputData(map[string]any{"profile": profile, "backend": backend.Name()})
+	color.Green("Logged in profile %s, the key is stored in the %s", profile, backend.Name())
+	if profile != credentials.DefaultProfile {
+		logger.Infof("Use it with --auth-profile %s or %s=%s", profile, authProfileEnv, profile)
+	}
+}
+
+func runAuthLogout(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+	store := credentialStore(logger)
+	profile := authCommandProfile()
+	if err := store.Logout(profile); err != nil {
+		if errors.Is(err, credentials.ErrNotFound) {
+			logger.Fatalf("Error: profile %s isn't logged in", profile)
+		}
+		logger.Fatalf("Error deleting the API key: %v", err)
+	}
+	setOutputData(map[string]any{"profile": profile})
+	color.Green("Logged out profile %s", profile)
+}
+
+func runAuthList(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+	store := credentialStore(logger)
+	setOutputData(store.Profiles)
+	if len(store.Profiles) == 0 {
+		logger.Infof("No profiles yet. Run \"dockershrink auth login\" to store an API key.")
+		return
+	}
+
+	selected := selectedAuthProfile()
+	if selected == "" {
+		selected = credentials.DefaultProfile
+	}
+	fmt.Printf("  %-20s  %-24s  %s\n", "PROFILE", "STORED IN", "CREATED")
+	for _, name := range store.Names() {
+		p := store.Profiles[name]
+		marker := " "
+		if name == selected {
+			marker = "*"
+		}
+		fmt.Printf("%s %-20s  %-24s  %s\n", marker, name, p.Backend, p.CreatedAt.Local().Format("2006-01-02 15:04"))
+	}
+}
+
+// authCommandProfile returns the profile "auth login" and "auth logout" act on
+func authCommandProfile() string {
+	if loginProfile != "" {
+		return loginProfile
+	}
+	if p := selectedAuthProfile(); p != "" {
+		return p
+	}
+	return credentials.DefaultProfile
+}
+
+// selectedAuthProfile returns the profile selected with --auth-profile or $DOCKERSHRINK_AUTH_PROFILE, empty if none is
+func selectedAuthProfile() string {
+	if authProfile != "" {
+		return authProfile
+	}
+	return os.Getenv(authProfileEnv)
+}
+
+// credentialStore opens the store of the profiles, the passphrase of the encrypted file is asked for only if it's used
+func credentialStore(logger *log.Logger) *credentials.Store {
+	store, err := openCredentialStore()
+	if err != nil {
+		logger.Fatalf("Error reading the credentials: %v", err)
+	}
+	return store
+}
+
+func openCredentialStore() (*credentials.Store, error) {
+	profilesPath, filePath, err := credentials.DefaultPaths()
+	if err != nil {
+		return nil, err
+	}
+	file := &credentials.EncryptedFile{Path: filePath, Passphrase: func() (string, error) {
+		return credentialsPassphrase(filePath)
+	}}
+	return credentials.Open(profilesPath, credentials.Keychain(), file)
+}
+
+// credentialsPassphrase returns the passphrase of the encrypted file from the environment, or prompts for it.
+// A new passphrase is asked for twice.
+func credentialsPassphrase(path string) (string, error) {
+	if p := os.Getenv(credentials.EnvPassphrase); p != "" {
+		log.AddSecret(p)
+		return p, nil
+	}
+	if !stdinIsTerminal() {
+		return "", fmt.Errorf("a passphrase is required to use the encrypted credentials file, set %s", credentials.EnvPassphrase)
+	}
+	if _, err := os.Stat(path); err == nil {
+		return readSecret("Passphrase of " + path + ": ")
+	}
+	p, err := readSecret("New passphrase to encrypt " + path + " with: ")
+	if err != nil {
+		return "", err
+	}
+	confirmation, err := readSecret("Repeat the passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if p != confirmation {
+		return "", errors.New("the passphrases don't match")
+	}
+	return p, nil
+}
+
+// readSecret prompts for a secret on stderr without echoing it, or reads it from stdin if it isn't a terminal
+func readSecret(prompt string) (string, error) {
+	if !stdinIsTerminal() {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(content)), nil
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	if echo(false) == nil {
+		defer func() {
+			echo(true)
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// echo turns the echo of the terminal on or off with stty. It fails where there is no stty, eg- on Windows,
+// and the secret is then shown as it's typed.
+func echo(on bool) error {
+	arg := "-echo"
+	if on {
+		arg = "echo"
+	}
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
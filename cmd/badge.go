@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/duaraghav8/dockershrink/internal/badge"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	badgeMetric string
+	badgeFormat string
+	badgeFile   string
+)
+
+var badgeCmd = &cobra.Command{
+	Use:   "badge",
+	Short: "Renders the score or the estimated image size of the Dockerfile as a badge for the README",
+	Long: `Analyzes the project like "analyze" and renders its score, or the estimated size of its image with --metric size, as a badge.
+The badge is an SVG by default, or the JSON of a shields.io endpoint with --format shields, which shields.io renders in any of its styles:
+  https://img.shields.io/endpoint?url=<URL of the JSON file>
+It's written to stdout, or to --file, eg- to commit it from CI along with the changes to the Dockerfile.
+"dockershrink serve" also serves the badges of the projects analyzed through it, see "dockershrink serve --help".`,
+	Example: `  dockershrink badge --file .github/dockershrink.svg
+  dockershrink badge --metric size --format shields > badge.json`,
+	Args: cobra.NoArgs,
+	Run:  runBadge,
+}
+
+func init() {
+	badgeCmd.Flags().StringVar(&badgeMetric, "metric", string(badge.MetricScore), "What the badge shows: score or size")
+	badgeCmd.Flags().StringVar(&badgeFormat, "format", string(badge.FormatSVG), "How the badge is rendered: svg or shields")
+	badgeCmd.Flags().StringVar(&badgeFile, "file", "", "Write the badge to this file instead of stdout")
+	badgeCmd.Flags().StringVar(&dockerfilePath, "dockerfile", "Dockerfile", "Path to Dockerfile")
+	badgeCmd.Flags().StringVar(&dockerignorePath, "dockerignore", ".dockerignore", "Path to .dockerignore")
+	badgeCmd.Flags().StringVar(&goal, "goal", string(models.GoalAll), "What to analyze the image for: size, build-speed, security or all")
+	addProfileFlag(badgeCmd)
+
+	rootCmd.AddCommand(badgeCmd)
+}
+
+func runBadge(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+	metric, err := badge.ParseMetric(badgeMetric)
+	if err != nil {
+		logger.Fatalf("Error: %v", err)
+	}
+	format, err := badge.ParseFormat(badgeFormat)
+	if err != nil {
+		logger.Fatalf("Error: %v", err)
+	}
+
+	analysis, _, _ := analyzeProject(cmd.Context(), logger)
+	b := badge.New(metric, &badge.Status{
+		Score:              analysis.Score,
+		Grade:              rules.Grade(analysis.Score),
+		EstimatedImageSize: analysis.EstimatedImageSize,
+	})
+	if metric == badge.MetricSize && analysis.EstimatedImageSize == 0 {
+		logger.Warnf("* The size of the image couldn't be estimated, the badge shows it as unknown")
+	}
+	content, _ := b.Render(format)
+	setOutputData(map[string]any{"label": b.Label, "message": b.Message, "color": b.Color, "file": badgeFile})
+
+	if badgeFile == "" {
+		if document == nil {
+			os.Stdout.Write(content)
+		}
+		return
+	}
+	if err := os.WriteFile(badgeFile, content, 0o644); err != nil {
+		logger.Fatalf("Error writing badge: %v", err)
+	}
+	color.Green("Badge written to %s: %s %s", badgeFile, b.Label, b.Message)
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/baseimages"
+	"github.com/duaraghav8/dockershrink/internal/docker"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/units"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// max time allowed for scanning all tags of an image for vulnerabilities
+const cveScanTimeout = 30 * time.Minute
+
+var (
+	refreshBaseImages bool
+	scanCVEs          bool
+)
+
+var baseImagesCmd = &cobra.Command{
+	Use:   "base-images [image...]",
+	Short: "Lists the supported releases and variants of official base images",
+	Long: `Shows the release cycles, end of life dates, variant sizes and known vulnerabilities of official images like node, python, golang and eclipse-temurin.
+This data is fetched from Docker Hub and endoflife.date, cached for a day, and used by analyze and optimize to recommend smaller and supported base images.
+With --cves, every supported tag is scanned with Docker Scout, which requires Docker and can take a while.`,
+	Run: runBaseImages,
+}
+
+func init() {
+	baseImagesCmd.Flags().BoolVar(&refreshBaseImages, "refresh", false, "Fetch live data even if the cached data is still fresh")
+	baseImagesCmd.Flags().BoolVar(&scanCVEs, "cves", false, "Count the known vulnerabilities of every supported tag using Docker Scout")
+
+	rootCmd.AddCommand(baseImagesCmd)
+}
+
+func runBaseImages(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+
+	names := args
+	if len(names) == 0 {
+		names = baseimages.Builtin().Names()
+	}
+	m := loadBaseImageMatrix(cmd.Context(), logger, names, refreshBaseImages)
+
+	if scanCVEs {
+		scanBaseImageCVEs(cmd.Context(), logger, m, names)
+	}
+
+	if m.FetchedAt.IsZero() {
+		logger.Infof("* Showing built-in data, which can be outdated")
+	} else {
+		logger.Infof("* Showing data fetched at %s", m.FetchedAt.Local().Format(time.DateTime))
+	}
+
+	for _, name := range names {
+		img := m.Image(name)
+		if img == nil {
+			logger.Warnf("No data available for %s, only official images are supported", name)
+			continue
+		}
+		fmt.Printf("\n============ %s ============\n", img.Name)
+		if img.Replacement != "" {
+			color.Yellow("Deprecated, use %s instead", img.Replacement)
+			continue
+		}
+
+		now := time.Now()
+		for _, c := range img.Cycles {
+			if !c.Supported(now) {
+				continue
+			}
+			release := c.Version
+			if c.LTS {
+				release += " (LTS)"
+			}
+			eol := "not announced"
+			if !c.EOL.IsZero() {
+				eol = c.EOL.Format(time.DateOnly)
+			}
+			color.Cyan("Release %s: "+color.WhiteString("supported until %s", eol), release)
+		}
+
+		tags := make([]string, 0, len(img.Tags))
+		for tag := range img.Tags {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		fmt.Println("---------------------------------")
+		for _, tag := range tags {
+			t := img.Tags[tag]
+			line := fmt.Sprintf("%-20s %10s", tag, units.FormatBytes(t.Size))
+			if t.CVEs != nil {
+				line += fmt.Sprintf("  (%s)", t.CVEs)
+			}
+			fmt.Println(line)
+		}
+	}
+}
+
+// scanBaseImageCVEs counts the vulnerabilities of every tag of the given images and caches the results
+func scanBaseImageCVEs(ctx context.Context, logger *log.Logger, m *baseimages.Matrix, names []string) {
+	client, err := docker.NewClient()
+	if err != nil {
+		logger.Fatalf("Cannot scan for vulnerabilities: %v", err)
+	}
+	cache, err := baseimages.DefaultCache()
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, cveScanTimeout)
+	defer cancel()
+
+	for _, name := range names {
+		img := m.Image(name)
+		if img == nil {
+			continue
+		}
+		for tag := range img.Tags {
+			ref := img.Name + ":" + tag
+			logger.Infof("* Scanning %s for vulnerabilities", ref)
+			cves, err := baseimages.ScanCVEs(ctx, client, ref)
+			if err != nil {
+				logger.Warnf("%v", err)
+				continue
+			}
+			img.SetCVEs(tag, cves)
+		}
+	}
+	if err := cache.Write(m); err != nil {
+		logger.Warnf("Failed to cache vulnerability counts: %v", err)
+	}
+}
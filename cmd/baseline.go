@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/duaraghav8/dockershrink/internal/baseline"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/project"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+	"github.com/spf13/cobra"
+)
+
+var (
+	baselinePath      string
+	writeBaselinePath string
+)
+
+var (
+	// activeBaseline is the baseline given by --baseline, nil without one
+	activeBaseline *baseline.Baseline
+	// baselineFindings are the findings of all the Dockerfiles analyzed, written with --write-baseline
+	baselineFindings []*models.Finding
+)
+
+func addBaselineFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&baselinePath, "baseline", "", "Leave out the findings recorded in this baseline file, so that only new findings are reported and fail the command")
+	cmd.Flags().StringVar(&writeBaselinePath, "write-baseline", "", "Record all the current findings in this baseline file, to be passed to --baseline by later runs")
+}
+
+// loadBaseline loads the baseline given by --baseline, if any
+func loadBaseline(logger *log.Logger) {
+	if baselinePath == "" {
+		return
+	}
+	b, err := baseline.Load(baselinePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			logger.Fatalf("Baseline %s doesn't exist, write it with --write-baseline %s", baselinePath, baselinePath)
+		}
+		logger.Fatalf("%v", err)
+	}
+	activeBaseline = b
+}
+
+// applyBaseline leaves the findings of the baseline out of the analysis of a Dockerfile and updates its score.
+// With --write-baseline, all its findings are recorded and left out, since they're accepted from now on.
+func applyBaseline(logger *log.Logger, analysis *project.AnalysisResponse) {
+	b := activeBaseline
+	if writeBaselinePath != "" {
+		baselineFindings = append(baselineFindings, analysis.Findings...)
+		b = baseline.New(analysis.Findings)
+	}
+	if b == nil {
+		return
+	}
+	kept, suppressed := b.Filter(analysis.Findings)
+	if suppressed == 0 {
+		return
+	}
+	analysis.Findings = kept
+	analysis.Score = rules.Score(kept)
+	logger.Infof("* %d finding(s) in the baseline are left out", suppressed)
+}
+
+// writeBaseline writes the findings recorded with --write-baseline, if any
+func writeBaseline(logger *log.Logger) {
+	if writeBaselinePath == "" {
+		return
+	}
+	if err := baseline.New(baselineFindings).Write(writeBaselinePath); err != nil {
+		logger.Fatalf("Error writing baseline: %v", err)
+	}
+	logger.Infof("\nBaseline with %d finding(s) written to %s. Pass it to later runs with --baseline %s", len(baselineFindings), writeBaselinePath, writeBaselinePath)
+}
@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/batch"
+	"github.com/duaraghav8/dockershrink/internal/config"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/project"
+	"github.com/duaraghav8/dockershrink/internal/targets"
+	"github.com/duaraghav8/dockershrink/internal/units"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	batchRoot          string
+	batchSummary       string
+	batchSummaryFormat string
+	batchCloneDir      string
+	batchScoreboard    string
+)
+
+var batchCmd = &cobra.Command{
+	Use:   "batch [git URL...]",
+	Short: "Analyzes the Dockerfiles of many repositories and summarizes the results",
+	Long: `Analyzes every Dockerfile of every git repository under --root, and of the repositories given as git URLs, which are cloned first.
+Each repository is analyzed with its own configuration, and up to --jobs Dockerfiles are analyzed at once.
+The score, findings and estimated savings of every Dockerfile are printed, and written with --summary to a CSV or JSON file, eg- to track image bloat across an organization.
+With --scoreboard, the repositories are also ranked from the worst Dockerfiles to the best in an HTML dashboard and a CSV file, like the scoreboard command does.
+This command never modifies the repositories. It exits with status 2 if some of the repositories or Dockerfiles failed, or 1 if all of them did.`,
+	Run: runBatch,
+}
+
+func init() {
+	batchCmd.Flags().StringVar(&batchRoot, "root", "", "Directory containing the git repositories to analyze")
+	batchCmd.Flags().StringVar(&batchSummary, "summary", "", "Write the summary of all the Dockerfiles to this file")
+	batchCmd.Flags().StringVar(&batchSummaryFormat, "summary-format", "", "Format of the summary: csv or json (default: from the extension of --summary, json otherwise)")
+	batchCmd.Flags().StringVar(&batchScoreboard, "scoreboard", "", "Write an HTML dashboard and a CSV file ranking the repositories to this directory")
+	batchCmd.Flags().StringVar(&batchCloneDir, "clone-dir", "", "Directory the git URLs are cloned into and left in (default: a temporary directory removed afterwards)")
+	batchCmd.Flags().StringVar(&goal, "goal", string(models.GoalAll), "Which rules to run: size, build-speed, security or all")
+	addProfileFlag(batchCmd)
+	addOrgPolicyFlag(batchCmd)
+	addPolicyBundleFlag(batchCmd)
+	batchCmd.Flags().IntVarP(&jobs, "jobs", "j", defaultJobs, "Number of Dockerfiles analyzed at once")
+
+	rootCmd.AddCommand(batchCmd)
+}
+
+// batchRepository is a repository of a batch along with its summary.
+// Repository is nil if the repository couldn't be cloned.
+type batchRepository struct {
+	*batch.Repository
+	summary *batch.RepositorySummary
+}
+
+// batchTarget is a Dockerfile of one of the repositories of a batch
+type batchTarget struct {
+	repo *batch.RepositorySummary
+	cfg  *config.Config
+	// path is relative to the root of the repository
+	path string
+}
+
+func runBatch(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+	ctx := cmd.Context()
+
+	if batchRoot == "" && len(args) == 0 {
+		logger.Fatalf("Pass the directory containing the repositories with --root, or the git URLs of the repositories")
+	}
+	if jobs < 1 {
+		logger.Fatalf("--jobs must be at least 1")
+	}
+	format := summaryFormat()
+	if format != batch.FormatCSV && format != batch.FormatJSON {
+		logger.Fatalf("Invalid --summary-format %q, must be %s or %s", format, batch.FormatCSV, batch.FormatJSON)
+	}
+
+	skipDirs := append(defaultDirsExcludedFromTreeStructure[:], outputDir)
+	repos := []*batchRepository{}
+	if batchRoot != "" {
+		found, err := batch.Find(batchRoot, skipDirs)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		for _, r := range found {
+			repos = append(repos, &batchRepository{Repository: r})
+		}
+	}
+	// exit skips deferred calls, so the temporary clones are removed explicitly before exiting
+	cleanup := func() {}
+	if len(args) > 0 {
+		cloneDir := batchCloneDir
+		if cloneDir == "" {
+			dir, err := os.MkdirTemp("", "dockershrink-batch-")
+			if err != nil {
+				logger.Fatalf("Error creating a directory for the clones: %v", err)
+			}
+			cloneDir = dir
+			cleanup = func() { os.RemoveAll(dir) }
+		}
+		repos = append(repos, cloneRepositories(ctx, logger, cloneDir, args)...)
+	}
+	defer cleanup()
+	if len(repos) == 0 {
+		logger.Fatalf("No git repositories found under %s", batchRoot)
+	}
+
+	// the Dockerfiles of all the repositories are analyzed together, so that --jobs applies across repositories
+	all := []*targets.Target{}
+	dockerfiles := map[*targets.Target]*batchTarget{}
+	for _, repo := range repos {
+		if repo.summary == nil {
+			repo.summary = &batch.RepositorySummary{Name: repo.Name, URL: repo.URL, Dockerfiles: []*batch.DockerfileSummary{}}
+		}
+		if repo.Repository == nil {
+			continue
+		}
+		cfg, err := loadConfig(repo.Dir)
+		if err != nil {
+			repo.summary.Error = fmt.Sprintf("Error loading configuration: %v", err)
+			continue
+		}
+		found, err := targets.Find(repo.Dir, skipDirs)
+		if err != nil {
+			repo.summary.Error = err.Error()
+			continue
+		}
+		for _, t := range found {
+			rel, err := filepath.Rel(repo.Dir, t.Dockerfile)
+			if err != nil {
+				rel = t.Dockerfile
+			}
+			rel = filepath.ToSlash(rel)
+			if cfg.Ignored(rel) {
+				logger.Debug("Skipping ignored Dockerfile", map[string]string{"dockerfile": t.Dockerfile})
+				continue
+			}
+			all = append(all, t)
+			dockerfiles[t] = &batchTarget{repo: repo.summary, cfg: cfg, path: rel}
+		}
+	}
+	logger.Infof("* Found %d Dockerfile(s) in %d repositories", len(all), len(repos))
+
+	targets.Each(all, jobs, func(t *targets.Target) (*project.AnalysisResponse, error) {
+		return analyzeTarget(ctx, logger, dockerfiles[t].cfg, t, &coldLoader{ctx: ctx, logger: logger})
+	}, func(t *targets.Target, analysis *project.AnalysisResponse, err error) {
+		bt := dockerfiles[t]
+		name := color.BlueString(bt.repo.Name + "/" + bt.path)
+		if err != nil {
+			bt.repo.Dockerfiles = append(bt.repo.Dockerfiles, &batch.DockerfileSummary{Path: bt.path, Error: err.Error()})
+			fmt.Printf("%s  %s\n", name, color.RedString("%v", err))
+			return
+		}
+		d := batch.NewDockerfileSummary(bt.path, analysis.Score, analysis.Findings, analysis.EstimatedImageSize)
+		bt.repo.Dockerfiles = append(bt.repo.Dockerfiles, d)
+		fmt.Printf("%s  %s  %d finding(s)", name, scoreColor(d.Score).Sprintf("%d/100", d.Score), d.Findings)
+		if d.EstimatedSavings > 0 {
+			fmt.Printf("  ~%s to save", units.FormatBytes(d.EstimatedSavings))
+		}
+		fmt.Println()
+	})
+
+	summary := &batch.Summary{}
+	for _, repo := range repos {
+		summary.Add(repo.summary)
+	}
+	printBatchSummary(summary)
+	setOutputData(summary)
+
+	if batchSummary != "" {
+		if err := writeBatchSummary(summary, format); err != nil {
+			cleanup()
+			logger.Fatalf("Error writing the summary: %v", err)
+		}
+		logger.Infof("\nSummary saved to %s", batchSummary)
+	}
+	if batchScoreboard != "" {
+		if err := writeScoreboard(batch.NewScoreboard(summary, time.Now()), batchScoreboard); err != nil {
+			cleanup()
+			logger.Fatalf("Error writing the scoreboard: %v", err)
+		}
+		logger.Infof("Scoreboard saved to %s", filepath.Join(batchScoreboard, scoreboardHTMLFile))
+	}
+
+	failed, total := summary.Totals.Failed, summary.Totals.Dockerfiles
+	for _, r := range summary.Repositories {
+		if r.Error != "" {
+			failed++
+			total++
+		}
+	}
+	if failed > 0 {
+		cleanup()
+		if failed == total {
+			exit(1)
+		}
+		exit(exitPartialFailure)
+	}
+}
+
+// cloneRepositories clones the repositories at the given URLs into dir. Repositories that can't be cloned
+// are returned with the error in their summary, so that they show up in it instead of failing the whole batch.
+func cloneRepositories(ctx context.Context, logger *log.Logger, dir string, urls []string) []*batchRepository {
+	repos := []*batchRepository{}
+	names := map[string]int{}
+	for _, url := range urls {
+		// repositories with the same name, eg- forks, are cloned into different directories
+		name := batch.RepositoryName(url)
+		names[name]++
+		if n := names[name]; n > 1 {
+			name = fmt.Sprintf("%s-%d", name, n)
+		}
+
+		dest := filepath.Join(dir, name)
+		if _, err := os.Stat(filepath.Join(dest, ".git")); err == nil {
+			// left by an earlier run with --clone-dir
+			logger.Infof("* Using the existing clone of %s in %s", url, dest)
+			repos = append(repos, &batchRepository{Repository: &batch.Repository{Name: name, URL: url, Dir: dest}})
+			continue
+		}
+		logger.Infof("* Cloning %s", url)
+		repo, err := batch.Clone(ctx, url, dest)
+		if err != nil {
+			logger.Warnf("%v", err)
+			repos = append(repos, &batchRepository{summary: &batch.RepositorySummary{Name: name, URL: url, Error: err.Error()}})
+			continue
+		}
+		repo.Name = name
+		repos = append(repos, &batchRepository{Repository: repo})
+	}
+	return repos
+}
+
+// summaryFormat returns the format of the summary: --summary-format, or the extension of the --summary file
+func summaryFormat() string {
+	if batchSummaryFormat != "" {
+		return strings.ToLower(batchSummaryFormat)
+	}
+	if strings.EqualFold(filepath.Ext(batchSummary), ".csv") {
+		return batch.FormatCSV
+	}
+	return batch.FormatJSON
+}
+
+func writeBatchSummary(summary *batch.Summary, format string) error {
+	f, err := os.Create(batchSummary)
+	if err != nil {
+		return err
+	}
+	if err := summary.Write(f, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// printBatchSummary prints the totals of all the repositories
+func printBatchSummary(summary *batch.Summary) {
+	t := summary.Totals
+	fmt.Printf("\n============ Summary ============\n")
+	color.Cyan("Repositories: " + color.WhiteString("%d", t.Repositories))
+	color.Cyan("Dockerfiles: " + color.WhiteString("%d", t.Dockerfiles))
+	if analyzed := t.Dockerfiles - t.Failed; analyzed > 0 {
+		color.Cyan("Average score: " + scoreColor(int(t.AverageScore)).Sprintf("%.0f/100", t.AverageScore))
+		color.Cyan("Findings: " + color.WhiteString("%d", t.Findings))
+		if t.EstimatedSavings > 0 {
+			color.Cyan("Estimated total savings: " + color.WhiteString("~%s", units.FormatBytes(t.EstimatedSavings)))
+		}
+	}
+	for _, r := range summary.Repositories {
+		if r.Error != "" {
+			color.Red("%s: %s", r.Name, r.Error)
+		}
+	}
+	if t.Failed > 0 {
+		color.Red("%d of %d Dockerfile(s) failed", t.Failed, t.Dockerfiles)
+	}
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/duaraghav8/dockershrink/internal/buildargs"
+	"github.com/duaraghav8/dockershrink/internal/buildcontext"
+	"github.com/duaraghav8/dockershrink/internal/compose"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/fatih/color"
+)
+
+// findBuildArgs returns the combinations of build arguments the Dockerfile is built with, as declared in the
+// compose service that builds it, its targets in JSON bake files and the builds of GitHub Actions workflows.
+// Only the ARGs the Dockerfile declares are kept. Files that can't be read are skipped with a warning.
+func findBuildArgs(logger *log.Logger, d *dockerfile.Dockerfile, dockerfilePath string) []*buildargs.Combination {
+	declared := d.DeclaredArgs()
+	if len(declared) == 0 {
+		return nil
+	}
+	combinations := []*buildargs.Combination{}
+
+	path := composeFile
+	if path == "" {
+		path = compose.Find(".")
+	}
+	if path != "" {
+		f, err := compose.Load(path)
+		if err != nil {
+			logger.Warnf("* Ignoring the build arguments in %s: %v", path, err)
+		} else {
+			combinations = append(combinations, buildargs.FromCompose(f, dockerfilePath)...)
+		}
+	}
+	for _, bake := range buildcontext.FindBake(".") {
+		found, err := buildargs.FromBake(bake, dockerfilePath)
+		if err != nil {
+			logger.Warnf("* Ignoring the build arguments in %s: %v", bake, err)
+		}
+		combinations = append(combinations, found...)
+	}
+	for _, workflow := range buildargs.FindWorkflows(".") {
+		found, err := buildargs.FromWorkflow(workflow, dockerfilePath)
+		if err != nil {
+			logger.Warnf("* Ignoring the build arguments in %s: %v", workflow, err)
+		}
+		combinations = append(combinations, found...)
+	}
+
+	relevant := buildargs.Relevant(combinations, declared)
+	if len(relevant) > 0 {
+		logger.Infof("* Analyzing %d combination(s) of build arguments", len(relevant))
+	}
+	return relevant
+}
+
+// printVariants prints the score of every combination of build arguments along with the findings
+// that differ from the analysis with the Dockerfile's defaults, so that a fix can be checked against every variant
+func printVariants(findings []*models.Finding, variants []*models.Variant) {
+	if len(variants) == 0 {
+		return
+	}
+	key := func(f *models.Finding) string {
+		return fmt.Sprintf("%s:%d:%s", f.Code, f.Line, f.Title)
+	}
+	defaults := map[string]bool{}
+	for _, f := range findings {
+		defaults[key(f)] = true
+	}
+
+	fmt.Printf("\n============ %d Build Argument Variant(s) ============\n", len(variants))
+	for _, v := range variants {
+		color.Cyan("Variant: " + color.WhiteString(v.Name))
+		color.Cyan("Build Arguments: " + color.WhiteString((&buildargs.Combination{Args: v.Args}).String()))
+		color.Cyan("Score: " + color.WhiteString("%d/100", v.Score))
+
+		found := map[string]bool{}
+		for _, f := range v.Findings {
+			found[key(f)] = true
+			if !defaults[key(f)] {
+				fmt.Printf("  %s %s (%s, line %d)\n", color.RedString("+"), f.Title, f.Code, f.Line)
+			}
+		}
+		for _, f := range findings {
+			if !found[key(f)] {
+				fmt.Printf("  %s %s (%s, line %d)\n", color.GreenString("-"), f.Title, f.Code, f.Line)
+			}
+		}
+		fmt.Println("---------------------------------")
+	}
+	fmt.Println("Findings marked + only occur in the variant, those marked - only occur with the Dockerfile's defaults.")
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/duaraghav8/dockershrink/internal/buildcontext"
+	"github.com/duaraghav8/dockershrink/internal/compose"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+)
+
+var buildContextFlags []string
+
+const buildContextFlagUsage = "Additional named build context as NAME=VALUE, like docker build's --build-context, eg- lib=../lib. Can be repeated"
+
+// findBuildContexts returns the named build contexts the Dockerfile is built with. They are declared in the
+// additional_contexts of the compose service that builds it, the contexts of its target in a JSON bake file,
+// and the --build-context flags, which take precedence in that order.
+// Compose and bake files that can't be read are skipped with a warning, unless the compose file was given explicitly.
+func findBuildContexts(logger *log.Logger, dockerfilePath string) ([]*buildcontext.Context, error) {
+	lists := [][]*buildcontext.Context{}
+
+	path := composeFile
+	if path == "" {
+		path = compose.Find(".")
+	}
+	if path != "" {
+		contexts, err := composeBuildContexts(path, dockerfilePath)
+		if err != nil && composeFile != "" {
+			return nil, err
+		}
+		if err != nil {
+			logger.Warnf("* Ignoring the build contexts in %s: %v", path, err)
+		}
+		lists = append(lists, contexts)
+	}
+	for _, bake := range buildcontext.FindBake(".") {
+		contexts, err := buildcontext.FromBake(bake, dockerfilePath)
+		if err != nil {
+			logger.Warnf("* Ignoring the build contexts in %s: %v", bake, err)
+		}
+		lists = append(lists, contexts)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	flags := []*buildcontext.Context{}
+	for _, declaration := range buildContextFlags {
+		c, err := buildcontext.Parse(declaration, cwd)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid --build-context: %w", err)
+		}
+		flags = append(flags, c)
+	}
+	return buildcontext.Merge(append(lists, flags)...), nil
+}
+
+func composeBuildContexts(path, dockerfilePath string) ([]*buildcontext.Context, error) {
+	f, err := compose.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load compose file: %w", err)
+	}
+	return buildcontext.FromCompose(f, dockerfilePath)
+}
+
+// addBuildContexts makes the named build contexts of the Dockerfile available to the rules and the LLM.
+// If withTrees is true, the directory trees of local contexts are built for the LLM.
+// Local contexts whose directory doesn't exist are left out, their files can't be inspected.
+func addBuildContexts(logger *log.Logger, rfs *restrictedfilesystem.RestrictedFilesystem, dockerfilePath string, withTrees bool) ([]*buildcontext.Context, error) {
+	contexts, err := findBuildContexts(logger, dockerfilePath)
+	if err != nil {
+		return nil, err
+	}
+	added := []*buildcontext.Context{}
+	for _, c := range contexts {
+		tree := ""
+		if c.IsLocal() {
+			if info, err := os.Stat(c.Dir); err != nil || !info.IsDir() {
+				logger.Warnf("* Build context %s is not a directory, its files won't be analyzed", c)
+				continue
+			}
+			if withTrees {
+				if tree, err = getDirTree(c.Dir, nil); err != nil {
+					return nil, err
+				}
+			}
+		}
+		rfs.AddBuildContext(c, tree)
+		added = append(added, c)
+	}
+	if len(added) > 0 {
+		logger.Infof("* Using %d named build context(s)", len(added))
+	}
+	return added, nil
+}
+
+// buildContextArgs returns the contexts as NAME=VALUE for docker build's --build-context, with local paths made absolute
+func buildContextArgs(contexts []*buildcontext.Context) []string {
+	args := make([]string, 0, len(contexts))
+	for _, c := range contexts {
+		value := c.Source
+		if c.IsLocal() {
+			value = c.Dir
+		}
+		args = append(args, c.Name+"="+value)
+	}
+	return args
+}
@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/bundle"
+	"github.com/duaraghav8/dockershrink/internal/history"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/output"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+	"github.com/duaraghav8/dockershrink/internal/sinks"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	bundlePath    string
+	bundleRunID   string
+	signKeyPath   string
+	verifyKeyPath string
+	applyBundle   bool
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Packs the analysis of the project and the changes of an optimization run into a portable bundle",
+	Long: `Analyzes the project and writes the findings, the report and the changes made by an optimization run into a single archive, along with a manifest of their checksums.
+The bundle can be reviewed and applied on another machine with "dockershrink import", eg- when dockershrink runs in a restricted CI environment.
+The changes are taken from the latest run recorded in the project's history, or the one given with --run. If no run was recorded, the bundle only contains the analysis.
+
+To sign the bundle, pass an ed25519 private key in PEM format, created with:
+  openssl genpkey -algorithm ed25519 -out dockershrink.pem
+  openssl pkey -in dockershrink.pem -pubout -out dockershrink.pub.pem`,
+	Args: cobra.NoArgs,
+	Run:  runExport,
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <bundle>",
+	Short: "Shows the findings and changes of a bundle created by \"dockershrink export\" and optionally applies them",
+	Long: `Verifies the bundle and prints its findings and the diff of its changes.
+With --apply, the changes are written into the project in the current directory. They are only applied if every file they modify is still identical to the one the optimization ran on.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runImport,
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&bundlePath, "bundle", "dockershrink-bundle.tar.gz", "Path to write the bundle to")
+	exportCmd.Flags().StringVar(&bundleRunID, "run", "latest", "ID of the optimization run whose changes are included")
+	exportCmd.Flags().StringVar(&signKeyPath, "sign-key", "", "Path to an ed25519 private key in PEM format to sign the bundle with")
+	exportCmd.Flags().StringVar(&dockerfilePath, "dockerfile", "Dockerfile", "Path to Dockerfile")
+	exportCmd.Flags().StringVar(&dockerignorePath, "dockerignore", ".dockerignore", "Path to .dockerignore")
+	exportCmd.Flags().StringVar(&goal, "goal", string(models.GoalAll), "What to analyze the image for: size, build-speed, security or all")
+	addProfileFlag(exportCmd)
+
+	importCmd.Flags().StringVar(&verifyKeyPath, "verify-key", "", "Path to the ed25519 public key in PEM format the bundle must be signed with")
+	importCmd.Flags().BoolVar(&applyBundle, "apply", false, "Apply the changes of the bundle to the project in the current directory")
+
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+}
+
+func runExport(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+
+	var key ed25519.PrivateKey
+	if signKeyPath != "" {
+		var err error
+		if key, err = bundle.LoadPrivateKey(signKeyPath); err != nil {
+			logger.Fatalf("Error loading signing key: %v", err)
+		}
+	}
+
+	analysis, cfg, cwd := analyzeProject(cmd.Context(), logger)
+	now := time.Now()
+	b := &bundle.Bundle{
+		Manifest: &bundle.Manifest{DockershrinkVersion: Version, CreatedAt: now.UTC()},
+		Report: &sinks.Report{
+			Command:          "export",
+			Timestamp:        now,
+			DockerfilePath:   dockerfilePath,
+			Owners:           dockerfileOwners(logger, cwd, cfg, dockerfilePath),
+			Score:            &analysis.Score,
+			ScoreBreakdown:   rules.Breakdown(analysis.Findings),
+			Findings:         analysis.Findings,
+			InstructionSizes: analysis.Sizes,
+			Variants:         analysis.Variants,
+		},
+	}
+
+	run, err := history.NewStore(cwd).Get(bundleRunID)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && !cmd.Flags().Changed("run"):
+		logger.Infof("* No optimization run was recorded, the bundle only contains the analysis")
+	case err != nil:
+		logger.Fatalf("Error reading run history: %v", err)
+	default:
+		changes, err := runChanges(cwd, run)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		b.Manifest.RunID = run.ID
+		b.Report.RunID = run.ID
+		b.Report.ActionsTaken = run.ActionsTaken
+		b.Report.Recommendations = run.Recommendations
+		b.Changes = changes
+		logger.Infof("* Including %d change(s) from run %s", len(changes), run.ID)
+	}
+
+	f, err := os.Create(bundlePath)
+	if err != nil {
+		logger.Fatalf("Error creating bundle: %v", err)
+	}
+	defer f.Close()
+	if err := bundle.Write(f, b, key); err != nil {
+		logger.Fatalf("Error writing bundle: %v", err)
+	}
+
+	signature := "unsigned"
+	if key != nil {
+		signature = "signed"
+	}
+	addResult(output.FromReport(b.Report))
+	setOutputData(map[string]any{"bundle": bundlePath, "signed": key != nil})
+	logger.Infof("Bundle (%s) saved to %s", signature, bundlePath)
+}
+
+// runChanges returns the files modified by an optimization run, relative to the project
+func runChanges(projectDir string, run *history.Run) ([]*bundle.Change, error) {
+	changes := []*bundle.Change{}
+	add := func(path, original, optimized string) error {
+		if original == optimized {
+			return nil
+		}
+		if filepath.IsAbs(path) {
+			rel, err := filepath.Rel(projectDir, path)
+			if err != nil {
+				return err
+			}
+			path = rel
+		}
+		if !filepath.IsLocal(path) {
+			return fmt.Errorf("Run %s modified %s, which is outside of the project", run.ID, path)
+		}
+		changes = append(changes, &bundle.Change{Path: filepath.ToSlash(filepath.Clean(path)), Original: original, Optimized: optimized})
+		return nil
+	}
+
+	path := run.DockerfilePath
+	if path == "" {
+		path = dockerfilePath
+	}
+	if err := add(path, run.InputDockerfile, run.OutputDockerfile); err != nil {
+		return nil, err
+	}
+	// runs don't record where the .dockerignore is, it's the one analyzed now
+	if run.OutputDockerignore != "" {
+		if err := add(dockerignorePath, run.InputDockerignore, run.OutputDockerignore); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+func runImport(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+
+	var key ed25519.PublicKey
+	if verifyKeyPath != "" {
+		var err error
+		if key, err = bundle.LoadPublicKey(verifyKeyPath); err != nil {
+			logger.Fatalf("Error loading verification key: %v", err)
+		}
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		logger.Fatalf("Error opening bundle: %v", err)
+	}
+	defer f.Close()
+	b, err := bundle.Read(f, key)
+	if err != nil {
+		logger.Fatalf("Error reading bundle %s: %v", args[0], err)
+	}
+
+	signature := color.YellowString("not verified")
+	if b.Signed {
+		signature = color.GreenString("verified")
+	}
+	color.Cyan("Bundle: " + color.BlueString(args[0]) + color.WhiteString(" (created %s by dockershrink %s)", b.Manifest.CreatedAt.Local().Format("2006-01-02 15:04:05"), b.Manifest.DockershrinkVersion))
+	color.Cyan("Signature: " + signature)
+	color.Cyan("Dockerfile: " + color.BlueString(b.Report.DockerfilePath))
+	if b.Manifest.RunID != "" {
+		color.Cyan("Run: " + color.BlueString(b.Manifest.RunID))
+	}
+
+	if b.Report.Score != nil {
+		printScore(*b.Report.Score, b.Report.ScoreBreakdown)
+	}
+	printFindings(b.Findings())
+	result := addResult(output.FromReport(b.Report))
+	setOutputData(map[string]any{"bundle": args[0], "signed": b.Signed, "applied": false})
+
+	if len(b.Changes) == 0 {
+		fmt.Println("\nThe bundle contains no changes.")
+		if applyBundle {
+			logger.Warnf("* Nothing to apply")
+		}
+		return
+	}
+	fmt.Printf("\n============ %d Change(s) ============\n", len(b.Changes))
+	printDiff(b.Patch())
+	for _, c := range b.Changes {
+		result.AddModifiedFile(c.Path, "", c.Original, c.Optimized)
+	}
+	if len(b.Report.ActionsTaken) > 0 {
+		fmt.Println("\nActions taken:")
+		for _, a := range b.Report.ActionsTaken {
+			fmt.Printf("- %s\n", a.Title)
+		}
+	}
+
+	if !applyBundle {
+		fmt.Println("\nRun with --apply to apply the changes to the project in the current directory.")
+		return
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		logger.Fatalf("Error getting current working directory: %v", err)
+	}
+	if key == nil {
+		logger.Warnf("* Applying changes from a bundle whose signature wasn't verified, pass --verify-key to verify it")
+	}
+	conflicts, err := b.Conflicts(cwd)
+	if err != nil {
+		logger.Fatalf("Error comparing the project with the bundle: %v", err)
+	}
+	if len(conflicts) > 0 {
+		logger.Fatalf("The changes were not applied, these files were modified since the bundle was created: %v", conflicts)
+	}
+	for _, c := range b.Changes {
+		if err := backupProjectFile("import", filepath.Join(cwd, filepath.FromSlash(c.Path)), []byte(c.Optimized)); err != nil {
+			logger.Fatalf("The changes were not applied. %v", err)
+		}
+	}
+	if err := b.Apply(cwd); err != nil {
+		logger.Fatalf("Error applying changes: %v", err)
+	}
+	for _, m := range result.ModifiedFiles {
+		m.OutputPath = filepath.Join(cwd, filepath.FromSlash(m.Path))
+	}
+	setOutputData(map[string]any{"bundle": args[0], "signed": b.Signed, "applied": true})
+	color.Green("\nApplied %d change(s).", len(b.Changes))
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/duaraghav8/dockershrink/internal/diff"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/output"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+const chatHelp = `/diff - show the changes applied to the Dockerfile since the chat started
+/help - print help
+/quit - end the chat, like Ctrl+D
+Anything else is sent to the AI.`
+
+var chatCmd = &cobra.Command{
+	Use:   "chat",
+	Short: "Optimizes the Dockerfile in a conversation with the AI",
+	Long: `Starts a conversation with the AI about the project's Dockerfile, eg- "keep the debian base but shrink node_modules".
+The AI knows as much about the project as when it optimizes it, can read its files and remembers the whole conversation, including which of its changes were applied.
+Every change it proposes is reviewed hunk by hunk like with "optimize --interactive", and the accepted hunks are written to the Dockerfile right away, so that later requests build on them.
+The Dockerfile is backed up before it's first written, "dockershrink revert" restores it as it was before the chat.
+This command requires an OpenAI API key.`,
+	Args: cobra.NoArgs,
+	Run:  runChat,
+}
+
+func init() {
+	chatCmd.Flags().StringVar(&dockerfilePath, "dockerfile", "Dockerfile", "Path to Dockerfile")
+	chatCmd.Flags().StringVar(&dockerignorePath, "dockerignore", ".dockerignore", "Path to .dockerignore")
+	chatCmd.Flags().StringVar(&goal, "goal", string(models.GoalAll), "What to optimize the image for: size, build-speed, security or all")
+	addProfileFlag(chatCmd)
+	addOrgPolicyFlag(chatCmd)
+
+	rootCmd.AddCommand(chatCmd)
+}
+
+func runChat(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+	ctx := cmd.Context()
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		logger.Fatalf("Error getting current working directory: %v", err)
+	}
+	cfg, err := loadConfig(cwd)
+	if err != nil {
+		logger.Fatalf("Error loading configuration: %v", err)
+	}
+	aiService, ok := getAIService(logger, cfg)
+	if !ok {
+		logger.Fatalf("chat requires an OpenAI API key")
+	}
+	chatGoal, err := models.ParseGoal(goal)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	attachDocs(logger, aiService, cfg)
+
+	dockerfileObject, err := readDockerfile(logger, dockerfilePath)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	proj, _, err := newTargetProject(logger, projectTarget(cwd), dockerfileObject, &coldLoader{ctx: ctx, logger: logger}, cfg.Ignored, true)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	policies, err := loadPolicies(cfg)
+	if err != nil {
+		logger.Fatalf("Error loading the policies of your organization: %v", err)
+	}
+	proj.SetPolicies(policies)
+	conversation, err := proj.StartChat(aiService, chatGoal)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	path := projectRelativePath(cwd, dockerfilePath)
+	original := conversation.Dockerfile()
+	// actions are the changes that were applied, at least in part
+	actions := []*models.OptimizationAction{}
+	r := newReviewer(ctx, os.Stdin, aiService)
+	color.Cyan("Chatting about %s. Type /help for help, /quit to end the chat.", path)
+
+chat:
+	for {
+		fmt.Print(color.BlueString("\n> "))
+		message, err := r.readLine()
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		switch message {
+		case "":
+			continue
+		// the reviewer reads "q" once the input is closed
+		case "q", "/quit", "/exit":
+			break chat
+		case "/help":
+			fmt.Println(chatHelp)
+			continue
+		case "/diff":
+			printDiff(diff.Unified("a/"+path, "b/"+path, original, conversation.Dockerfile()))
+			continue
+		}
+
+		resp, err := conversation.Send(ctx, message)
+		if err != nil {
+			if ctx.Err() != nil {
+				logger.Fatalf("%v", ctx.Err())
+			}
+			color.Red("Failed to get a reply, send your message again: %v", err)
+			continue
+		}
+		color.Cyan("AI: " + color.WhiteString(resp.Reply))
+		if resp.Dockerfile == "" {
+			continue
+		}
+
+		current := conversation.Dockerfile()
+		applied, err := r.review(path, current, resp.Dockerfile, resp.ActionsTaken)
+		if err != nil {
+			logger.Fatalf("Error reviewing changes: %v", err)
+		}
+		// quitting the review only rejects the rest of this proposal
+		r.quit = false
+		if _, err := dockerfile.NewDockerfile(applied); err != nil {
+			color.Red("The reviewed Dockerfile is invalid, none of the changes were applied: %v", err)
+			applied = current
+		}
+		conversation.Apply(applied)
+		if applied == current {
+			logger.Infof("No changes were applied.")
+			continue
+		}
+		if err := writeProjectFile("chat", dockerfilePath, []byte(applied), 0o644); err != nil {
+			logger.Fatalf("Error writing %s: %v", dockerfilePath, err)
+		}
+		actions = append(actions, resp.ActionsTaken...)
+		logger.Infof("Changes written to %s", path)
+	}
+
+	result := addResult(&output.Result{Dockerfile: dockerfilePath, ActionsTaken: actions})
+	result.AddModifiedFile(path, dockerfilePath, original, conversation.Dockerfile())
+	if conversation.Dockerfile() != original {
+		logger.Infof("Run \"dockershrink revert\" to restore %s as it was before the chat.", path)
+	}
+}
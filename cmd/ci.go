@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/ci"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/sinks"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// max time allowed for commenting on a pull request or uploading a report
+const ciCommentTimeout = 30 * time.Second
+
+var (
+	commentReportFile string
+	commentPR         int
+	commentRepository string
+	commentTokenEnv   string
+	commentTag        string
+)
+
+// formats of the reports CI systems show in pull requests, written with --ci-format
+const (
+	ciFormatGitLab    = "gitlab"
+	ciFormatBitbucket = "bitbucket"
+)
+
+var ciFormats = []string{ciFormatGitLab, ciFormatBitbucket}
+
+// files the CI reports are written to by default
+var ciFiles = map[string]string{
+	ciFormatGitLab:    "gl-code-quality-report.json",
+	ciFormatBitbucket: "dockershrink-insights.json",
+}
+
+var (
+	ciFormat string
+	ciFile   string
+)
+
+var (
+	insightsFile       string
+	insightsRepository string
+	insightsCommit     string
+	insightsTokenEnv   string
+)
+
+var ciCmd = &cobra.Command{
+	Use:   "ci",
+	Short: "Integrates dockershrink with CI systems",
+}
+
+var ciCommentCmd = &cobra.Command{
+	Use:   "comment",
+	Short: "Posts the markdown report as a comment on the GitHub pull request being built",
+	Long: `Posts the markdown report written by "analyze" or "optimize" with --report markdown as a comment on a GitHub pull request.
+The comment is sticky: it's posted once and updated by later runs, so the pull request always has a single, current report.
+
+In GitHub Actions, the repository and pull request are read from the environment. The token needs permission to write pull requests.`,
+	Example: `  dockershrink optimize --report markdown
+  dockershrink ci comment
+
+  # one comment per service of a monorepo
+  cd services/api
+  dockershrink analyze --report markdown --report-file api.md
+  dockershrink ci comment --report-file api.md --tag api`,
+	Args: cobra.NoArgs,
+	Run:  runCIComment,
+}
+
+var ciInsightsCmd = &cobra.Command{
+	Use:   "insights",
+	Short: "Uploads the findings as a Bitbucket Code Insights report on the commit being built",
+	Long: `Uploads the report written by "analyze" or "lint" with --ci-format bitbucket to Bitbucket Cloud as a Code Insights report, with an annotation for every finding.
+Uploading it again for the same commit replaces the previous report.
+
+In Bitbucket Pipelines, the repository and commit are read from the environment, and no token is needed since requests go through the authenticating proxy of Pipelines.`,
+	Example: `  dockershrink lint --ci-format bitbucket
+  dockershrink ci insights`,
+	Args: cobra.NoArgs,
+	Run:  runCIInsights,
+}
+
+func init() {
+	ciInsightsCmd.Flags().StringVar(&insightsFile, "report-file", ciFiles[ciFormatBitbucket], "Code Insights report to upload")
+	ciInsightsCmd.Flags().StringVar(&insightsRepository, "repo", "", "Repository as workspace/slug (default: $BITBUCKET_REPO_FULL_NAME)")
+	ciInsightsCmd.Flags().StringVar(&insightsCommit, "commit", "", "Commit the report is for (default: $BITBUCKET_COMMIT)")
+	ciInsightsCmd.Flags().StringVar(&insightsTokenEnv, "token-env", "BITBUCKET_TOKEN", "Environment variable holding a Bitbucket access token, not needed in Bitbucket Pipelines")
+	ciCmd.AddCommand(ciInsightsCmd)
+
+	ciCommentCmd.Flags().StringVar(&commentReportFile, "report-file", "dockershrink-report.md", "Markdown report to post, - to read it from stdin")
+	ciCommentCmd.Flags().IntVar(&commentPR, "pr", 0, "Number of the pull request (default: the pull request the GitHub Actions workflow runs for)")
+	ciCommentCmd.Flags().StringVar(&commentRepository, "repo", "", "Repository of the pull request as owner/name (default: $GITHUB_REPOSITORY)")
+	ciCommentCmd.Flags().StringVar(&commentTokenEnv, "token-env", "GITHUB_TOKEN", "Environment variable holding the GitHub token")
+	ciCommentCmd.Flags().StringVar(&commentTag, "tag", "", "Keeps a separate sticky comment for every tag, eg- for every Dockerfile of a monorepo")
+	ciCmd.AddCommand(ciCommentCmd)
+	rootCmd.AddCommand(ciCmd)
+}
+
+// commentOutput is the output of "ci comment" in JSON
+type commentOutput struct {
+	URL     string `json:"url"`
+	Created bool   `json:"created"`
+}
+
+func runCIComment(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+
+	var report []byte
+	var err error
+	if commentReportFile == "-" {
+		report, err = io.ReadAll(os.Stdin)
+	} else {
+		report, err = os.ReadFile(commentReportFile)
+	}
+	if err != nil {
+		logger.Fatalf("Error reading the report: %v", err)
+	}
+	if strings.TrimSpace(string(report)) == "" {
+		logger.Fatalf("The report is empty, write it with: dockershrink optimize --report markdown")
+	}
+
+	token := os.Getenv(commentTokenEnv)
+	if token == "" {
+		logger.Fatalf("Environment variable %s is not set", commentTokenEnv)
+	}
+	log.AddSecret(token)
+	repository := commentRepository
+	if repository == "" {
+		repository = os.Getenv("GITHUB_REPOSITORY")
+	}
+	if repository == "" {
+		logger.Fatalf("The repository is unknown, pass it with --repo")
+	}
+	pr := commentPR
+	if pr == 0 {
+		if pr, err = ci.PullRequestFromEnv(); err != nil {
+			logger.Fatalf("%v. Pass the pull request with --pr", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), ciCommentTimeout)
+	defer cancel()
+	comment, created, err := ci.NewGitHub("", repository, token).UpsertComment(ctx, pr, commentTag, string(report))
+	if err != nil {
+		logger.Fatalf("Error commenting on pull request #%d of %s: %v", pr, repository, err)
+	}
+	setOutputData(&commentOutput{URL: comment.URL, Created: created})
+
+	action := "Updated"
+	if created {
+		action = "Posted"
+	}
+	color.Cyan("%s the report on pull request #%d: %s", action, pr, color.BlueString(comment.URL))
+}
+
+func addCIFormatFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&ciFormat, "ci-format", "", "Also write the findings as a report for a CI system: gitlab (Code Quality) or bitbucket (Code Insights, upload it with \"ci insights\")")
+	cmd.Flags().StringVar(&ciFile, "ci-file", "", "File the --ci-format report is written to (default: gl-code-quality-report.json for gitlab, dockershrink-insights.json for bitbucket)")
+}
+
+func validateCIFormat() error {
+	if ciFormat == "" || slices.Contains(ciFormats, ciFormat) {
+		return nil
+	}
+	return fmt.Errorf("unknown CI format %q, must be one of: %s", ciFormat, strings.Join(ciFormats, ", "))
+}
+
+// writeCIReport writes the findings of the reports in the --ci-format format, if any
+func writeCIReport(reports []*sinks.Report) {
+	if ciFormat == "" {
+		return
+	}
+	path := ciFile
+	if path == "" {
+		path = ciFiles[ciFormat]
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		color.Red("Error writing CI report: %v", err)
+		return
+	}
+	defer f.Close()
+
+	write := ci.WriteCodeQuality
+	if ciFormat == ciFormatBitbucket {
+		write = ci.WriteInsights
+	}
+	if err := write(f, reports); err != nil {
+		color.Red("Error writing CI report: %v", err)
+		return
+	}
+	color.Cyan("%s report saved to %s", ciFormat, path)
+}
+
+func runCIInsights(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+
+	content, err := os.ReadFile(insightsFile)
+	if err != nil {
+		logger.Fatalf("Error reading the report: %v", err)
+	}
+	insights := &ci.Insights{}
+	if err := json.Unmarshal(content, insights); err != nil || insights.Report == nil {
+		logger.Fatalf("%s isn't a Code Insights report, write it with: dockershrink lint --ci-format bitbucket", insightsFile)
+	}
+
+	repository := insightsRepository
+	if repository == "" {
+		repository = os.Getenv("BITBUCKET_REPO_FULL_NAME")
+	}
+	if repository == "" {
+		logger.Fatalf("The repository is unknown, pass it with --repo")
+	}
+	commit := insightsCommit
+	if commit == "" {
+		commit = os.Getenv("BITBUCKET_COMMIT")
+	}
+	if commit == "" {
+		logger.Fatalf("The commit is unknown, pass it with --commit")
+	}
+	token := os.Getenv(insightsTokenEnv)
+	if token == "" && os.Getenv("BITBUCKET_BUILD_NUMBER") == "" {
+		logger.Fatalf("Environment variable %s is not set, a token is needed outside of Bitbucket Pipelines", insightsTokenEnv)
+	}
+	log.AddSecret(token)
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), ciCommentTimeout)
+	defer cancel()
+	if err := ci.NewBitbucket("", repository, token).UploadInsights(ctx, commit, insights); err != nil {
+		logger.Fatalf("Error uploading the report to %s: %v", repository, err)
+	}
+	setOutputData(map[string]any{"repository": repository, "commit": commit, "annotations": len(insights.Annotations)})
+	color.Cyan("Uploaded the report with %d annotation(s) to commit %s", len(insights.Annotations), commit)
+}
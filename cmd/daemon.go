@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/config"
+	"github.com/duaraghav8/dockershrink/internal/daemon"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/project"
+	"github.com/duaraghav8/dockershrink/internal/targets"
+	"github.com/spf13/cobra"
+)
+
+// max time a client waits for the daemon to respond
+const daemonCallTimeout = time.Minute
+
+const daemonFlagUsage = "Analyze with the running daemon, which keeps the project's state warm across runs. Falls back to analyzing in this process if the daemon isn't running"
+
+// socketEnv overrides the socket clients connect to, eg- for a daemon started with --socket
+const socketEnv = "DOCKERSHRINK_SOCKET"
+
+var (
+	useDaemon   bool
+	socketPath  string
+	idleTimeout time.Duration
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Runs dockershrink in the background to answer analyses from editors and the CLI",
+	Long: `Runs a local daemon in the foreground that listens on a unix socket, which only the current user can connect to.
+The daemon keeps parsed Dockerfiles, indexes of project files and base image data in memory, so analyses requested with "analyze --daemon", "lint --daemon" or by an editor return in milliseconds instead of loading everything again.
+Dockerfiles are parsed again only when they change and base image data is refreshed every hour.
+The daemon exits after --idle-timeout without requests. Clients connect to the default socket, or to the one in the ` + socketEnv + ` environment variable.`,
+	Run: runDaemon,
+}
+
+var daemonStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Prints the status of the running daemon",
+	Run:   runDaemonStatus,
+}
+
+var daemonStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stops the running daemon",
+	Run:   runDaemonStop,
+}
+
+func init() {
+	daemonCmd.PersistentFlags().StringVar(&socketPath, "socket", "", "Path of the unix socket (default: $"+socketEnv+", $XDG_RUNTIME_DIR/dockershrink.sock or ~/.cache/dockershrink/daemon.sock)")
+	daemonCmd.Flags().DurationVar(&idleTimeout, "idle-timeout", daemon.DefaultIdleTimeout, "Exit after this long without requests, 0 to run until stopped")
+
+	daemonCmd.AddCommand(daemonStatusCmd, daemonStopCmd)
+	rootCmd.AddCommand(daemonCmd)
+}
+
+// daemonSocket returns the path of the socket the daemon listens on
+func daemonSocket() (string, error) {
+	if socketPath != "" {
+		return socketPath, nil
+	}
+	if s := os.Getenv(socketEnv); s != "" {
+		return s, nil
+	}
+	return daemon.DefaultSocket()
+}
+
+func runDaemon(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+
+	socket, err := daemonSocket()
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	l, err := daemon.Listen(socket)
+	if err != nil {
+		logger.Fatalf("Error starting daemon: %v", err)
+	}
+	defer os.Remove(socket)
+
+	warm := daemon.NewWarm(&coldLoader{ctx: cmd.Context(), logger: logger, index: true})
+	server := daemon.NewServer(daemonAnalyzer(logger, warm), warm)
+	server.IdleTimeout = idleTimeout
+
+	go func() {
+		<-cmd.Context().Done()
+		server.Stop()
+	}()
+
+	logger.Infof("* Daemon listening on %s", socket)
+	if err := server.Serve(l); err != nil {
+		logger.Fatalf("Daemon stopped: %v", err)
+	}
+	logger.Infof("* Daemon stopped")
+}
+
+// daemonAnalyzer returns the function the daemon analyzes projects with.
+// The analysis reads the flags of the analyze command and resolves paths against the working directory,
+// so analyses run one at a time, each with the flags and working directory of its request. They take
+// milliseconds with warm state, so this doesn't hold clients up.
+func daemonAnalyzer(logger *log.Logger, warm *daemon.Warm) daemon.AnalyzeFunc {
+	var mu sync.Mutex
+	return func(ctx context.Context, req *daemon.AnalyzeRequest) (*daemon.Analysis, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		previous, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chdir(req.Cwd); err != nil {
+			return nil, fmt.Errorf("Error changing to directory %s: %w", req.Cwd, err)
+		}
+		defer os.Chdir(previous)
+
+		cfg, err := config.Load(req.Cwd)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading configuration: %w", err)
+		}
+		goal, platforms, buildContextFlags = req.Goal, req.Platforms, req.BuildContexts
+		policyPaths, policyBundles = req.Policies, req.PolicyBundles
+		if goal == "" && cfg.Profile != "" {
+			// validated when the configuration was loaded
+			p, _ := models.ParseProfile(cfg.Profile)
+			goal = string(p.Goal())
+		}
+		if goal == "" {
+			goal = string(models.GoalAll)
+		}
+		t := &targets.Target{Dir: req.Cwd, Dockerfile: req.Dockerfile, Dockerignore: req.Dockerignore}
+		if t.Dockerfile == "" {
+			t.Dockerfile = "Dockerfile"
+		}
+		if t.Dockerignore == "" {
+			t.Dockerignore = ".dockerignore"
+		}
+		analysis, err := analyzeTarget(ctx, logger, cfg, t, warm)
+		if err != nil {
+			return nil, err
+		}
+		return &daemon.Analysis{
+			Score:            analysis.Score,
+			Findings:         analysis.Findings,
+			InstructionSizes: analysis.Sizes,
+			Variants:         analysis.Variants,
+		}, nil
+	}
+}
+
+// analyzeWithDaemon has the running daemon analyze the project in the current directory with the flags of this run
+func analyzeWithDaemon(ctx context.Context, cwd string) (*project.AnalysisResponse, error) {
+	socket, err := daemonSocket()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, daemonCallTimeout)
+	defer cancel()
+	analysis, err := daemon.Analyze(ctx, socket, &daemon.AnalyzeRequest{
+		Cwd:           cwd,
+		Dockerfile:    dockerfilePath,
+		Dockerignore:  dockerignorePath,
+		Goal:          goal,
+		Platforms:     platforms,
+		BuildContexts: buildContextFlags,
+		Policies:      policyPaths,
+		PolicyBundles: policyBundles,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &project.AnalysisResponse{
+		Score:    analysis.Score,
+		Findings: analysis.Findings,
+		Sizes:    analysis.InstructionSizes,
+		Variants: analysis.Variants,
+	}, nil
+}
+
+func runDaemonStatus(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+	socket, err := daemonSocket()
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	ctx, cancel := context.WithTimeout(cmd.Context(), daemonCallTimeout)
+	defer cancel()
+	status, err := daemon.Ping(ctx, socket)
+	if err != nil {
+		logger.Fatalf("No daemon is running on %s: %v", socket, err)
+	}
+	printDaemonStatus(socket, status)
+}
+
+func runDaemonStop(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+	socket, err := daemonSocket()
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	ctx, cancel := context.WithTimeout(cmd.Context(), daemonCallTimeout)
+	defer cancel()
+	status, err := daemon.Stop(ctx, socket)
+	if err != nil {
+		logger.Fatalf("No daemon is running on %s: %v", socket, err)
+	}
+	logger.Infof("* Stopped daemon %d after %d request(s)", status.PID, status.Requests)
+}
+
+func printDaemonStatus(socket string, status *daemon.Status) {
+	lines := []string{
+		fmt.Sprintf("Socket: %s", socket),
+		fmt.Sprintf("PID: %d", status.PID),
+		fmt.Sprintf("Uptime: %s", time.Since(status.StartedAt).Round(time.Second)),
+		fmt.Sprintf("Requests: %d", status.Requests),
+		fmt.Sprintf("Cache: %d hit(s), %d miss(es), %d project(s) indexed", status.Cache.Hits, status.Cache.Misses, status.Cache.Projects),
+	}
+	fmt.Println(strings.Join(lines, "\n"))
+}
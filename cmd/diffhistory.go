@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/duaraghav8/dockershrink/internal/diff"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/dockerignore"
+	"github.com/duaraghav8/dockershrink/internal/history"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/output"
+	"github.com/duaraghav8/dockershrink/internal/project"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var diffHistoryCmd = &cobra.Command{
+	Use:   "diff-history <run-id>",
+	Short: "Compares the current Dockerfile against the output of a previous optimization run",
+	Long: `Shows how the current Dockerfile differs from the one produced by a previous "dockershrink optimize" run and reports optimizations from that run which have since regressed.
+The run ID can be "latest" or any unique prefix of a run ID. Runs are recorded in the .dockershrink/history directory of the project.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runDiffHistory,
+}
+
+func init() {
+	diffHistoryCmd.Flags().StringVar(&dockerfilePath, "dockerfile", "Dockerfile", "Path to Dockerfile")
+	diffHistoryCmd.Flags().StringVar(&dockerignorePath, "dockerignore", ".dockerignore", "Path to .dockerignore")
+
+	rootCmd.AddCommand(diffHistoryCmd)
+}
+
+func runDiffHistory(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		logger.Fatalf("Error getting current working directory: %v", err)
+	}
+
+	run, err := history.NewStore(cwd).Get(args[0])
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			logger.Fatalf("%v. Runs are recorded every time \"dockershrink optimize\" is run.", err)
+		}
+		logger.Fatalf("Error reading run history: %v", err)
+	}
+
+	currentDockerfile, err := readDockerfile(logger, dockerfilePath)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	currentDockerignore, err := readDockerignore(dockerignorePath)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	if currentDockerignore == nil {
+		dockerignorePath = ""
+	}
+
+	color.Cyan("Run: " + color.BlueString(run.ID) + color.WhiteString(" (%s)", run.Timestamp.Local().Format("2006-01-02 15:04:05")))
+	if run.DockerfilePath != "" && run.DockerfilePath != dockerfilePath {
+		logger.Warnf("* Run %s optimized %s, comparing it against %s", run.ID, run.DockerfilePath, dockerfilePath)
+	}
+
+	d := diff.Unified("run/"+run.ID+"/Dockerfile", dockerfilePath, run.OutputDockerfile, currentDockerfile.Raw())
+	if d == "" {
+		color.Green("\nThe Dockerfile is identical to the output of run %s.", run.ID)
+	} else {
+		fmt.Println()
+		printDiff(d)
+	}
+
+	pastDockerfile, err := dockerfile.NewDockerfile(run.OutputDockerfile)
+	if err != nil {
+		logger.Fatalf("Failed to parse the Dockerfile recorded in run %s: %v", run.ID, err)
+	}
+	var pastDockerignore *dockerignore.Dockerignore
+	if run.OutputDockerignore != "" {
+		pastDockerignore = dockerignore.NewDockerignore(run.OutputDockerignore)
+	}
+
+	packageJson, err := getPackageJson(".")
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Fatalf("Failed to read package.json: %v", err)
+	}
+	ws, err := getWorkspace(cwd)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	opts := &project.AnalyzeOptions{Goal: models.GoalAll}
+	projectDirFS := restrictedfilesystem.NewRestrictedFilesystem(cwd, "", dockerfilePath, dockerignorePath)
+	past := project.NewProject(pastDockerfile, pastDockerignore, packageJson, projectDirFS, ws, workspacePackage).AnalyzeDockerImage(opts)
+	current := project.NewProject(currentDockerfile, currentDockerignore, packageJson, projectDirFS, ws, workspacePackage).AnalyzeDockerImage(opts)
+
+	fmt.Printf("\nScore: %d/100 at run %s, %d/100 now\n", past.Score, run.ID, current.Score)
+
+	regressions := history.Regressions(past.Findings, current.Findings)
+	addResult(&output.Result{
+		Dockerfile:   dockerfilePath,
+		RunID:        run.ID,
+		Score:        &current.Score,
+		Findings:     regressions,
+		ActionsTaken: run.ActionsTaken,
+	})
+	if len(regressions) == 0 {
+		color.Green("No optimizations have regressed since run %s.", run.ID)
+		return
+	}
+
+	fmt.Printf("\n============ %d Regression(s) ============\n", len(regressions))
+	for _, f := range regressions {
+		location := f.Filepath
+		if f.Line > 0 {
+			location = fmt.Sprintf("%s:%d", f.Filepath, f.Line)
+		}
+		color.Cyan("Severity: " + color.New(severityColors[f.Severity]).Sprint(f.Severity))
+		color.Cyan("Rule: " + color.WhiteString(f.Rule))
+		color.Cyan("File: " + color.BlueString(location))
+		color.Cyan("Title: " + color.GreenString(f.Title))
+		color.Cyan("Description: " + color.WhiteString(f.Description))
+		fmt.Println("---------------------------------")
+	}
+
+	if len(run.ActionsTaken) > 0 {
+		fmt.Printf("\nActions taken in run %s:\n", run.ID)
+		for _, a := range run.ActionsTaken {
+			fmt.Printf("- %s\n", a.Title)
+		}
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"path/filepath"
 
 	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/output"
 	"github.com/duaraghav8/dockershrink/internal/project"
+	"github.com/duaraghav8/dockershrink/internal/projectinfo"
 	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
 	"github.com/spf13/cobra"
 )
@@ -15,23 +17,33 @@ var generateCmd = &cobra.Command{
 	Use:   "generate",
 	Short: "Generates the Docker image definition for a project",
 	Long: `Generates the Dockerfile and .dockerignore files for a NodeJS project.
+The project is inspected to detect its framework, entrypoint, port and nodejs version, which are used to produce an optimized multistage Dockerfile.
 OpenAI API key is required for this command.`,
 	Run: runGenerate,
 }
 
 func init() {
+	addOrgPolicyFlag(generateCmd)
 	rootCmd.AddCommand(generateCmd)
 }
 
 func runGenerate(cmd *cobra.Command, args []string) {
 	logger := log.NewLogger(debug)
 
-	aiService, ok := getAIService(logger)
+	cwd, err := os.Getwd()
+	if err != nil {
+		logger.Fatalf("Error getting current working directory: %v", err)
+	}
+	cfg, err := loadConfig(cwd)
+	if err != nil {
+		logger.Fatalf("Error loading configuration: %v", err)
+	}
+	aiService, ok := getAIService(logger, cfg)
 	if !ok {
 		logger.Fatalf("OpenAI API key is required for this command")
 	}
 
-	packageJson, err := getPackageJson()
+	packageJson, err := getPackageJson(".")
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			logger.Warnf("* No package.json file found")
@@ -40,11 +52,13 @@ func runGenerate(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	cwd, err := os.Getwd()
+	attachDocs(logger, aiService, cfg)
+
+	ignored, err := withExcludedPaths(cfg.Ignored)
 	if err != nil {
-		logger.Fatalf("Error getting current working directory: %v", err)
+		logger.Fatalf("%v", err)
 	}
-	cwdTree, err := getDirTree(cwd)
+	cwdTree, err := getDirTree(cwd, cfg.Ignored)
 	if err != nil {
 		logger.Fatalf("%v", err)
 	}
@@ -52,10 +66,31 @@ func runGenerate(cmd *cobra.Command, args []string) {
 	projectDirFS := restrictedfilesystem.NewRestrictedFilesystem(
 		cwd, cwdTree, "", "",
 	)
+	projectDirFS.SetIgnored(ignored)
 
-	proj := project.NewProject(nil, nil, packageJson, projectDirFS)
+	ws, err := getWorkspace(cwd)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	if ws != nil {
+		logger.Infof("* Detected a %s monorepo workspace with %d package(s)", ws.Manager, len(ws.Packages))
+	}
+
+	proj := project.NewProject(nil, nil, packageJson, projectDirFS, ws, workspacePackage)
+	proj.SetEvents(logEvents(logger))
+	policies, err := loadPolicies(cfg)
+	if err != nil {
+		logger.Fatalf("Error loading the policies of your organization: %v", err)
+	}
+	proj.SetPolicies(policies)
+
+	info := projectinfo.Inspect(os.DirFS(cwd), packageJson)
+	if info.Language != projectinfo.LanguageNodeJS {
+		logger.Warnf("* Detected a %s project, but dockershrink currently only supports NodeJS. Results may be inaccurate.", info.Language)
+	}
+	logger.Debug("Detected project facts", map[string]string{"facts": info.Summary()})
 
-	response, err := proj.GenerateDockerImage(aiService)
+	response, err := proj.GenerateDockerImage(cmd.Context(), aiService, info)
 	if err != nil {
 		logger.Fatalf("Error generating Docker image (use --debug to get more info): %s", err)
 	}
@@ -65,13 +100,18 @@ func runGenerate(cmd *cobra.Command, args []string) {
 		logger.Fatalf("Error creating output directory: %v", err)
 	}
 	dockerfileOutputPath := filepath.Join(outputDir, "Dockerfile")
-	if err := os.WriteFile(dockerfileOutputPath, []byte(response.Dockerfile), os.ModePerm); err != nil {
+	if err := writeProjectFile("generate", dockerfileOutputPath, []byte(response.Dockerfile), os.ModePerm); err != nil {
 		logger.Fatalf("Error writing optimized Dockerfile: %v", err)
 	}
 	dockerignoreOutputPath := filepath.Join(outputDir, ".dockerignore")
-	if err := os.WriteFile(dockerignoreOutputPath, []byte(response.Dockerignore), os.ModePerm); err != nil {
-		logger.Fatalf("Error writing optimized Dockerfile: %v", err)
+	if err := writeProjectFile("generate", dockerignoreOutputPath, []byte(response.Dockerignore), os.ModePerm); err != nil {
+		logger.Fatalf("Error writing generated .dockerignore: %v", err)
 	}
 
+	result := addResult(&output.Result{Dockerfile: "Dockerfile"})
+	result.AddModifiedFile("Dockerfile", dockerfileOutputPath, "", response.Dockerfile)
+	result.AddModifiedFile(".dockerignore", dockerignoreOutputPath, "", response.Dockerignore)
+
 	logger.Infof("Generated Docker files saved to %s/", outputDir)
+	printPolicyViolations(logger, response.PolicyViolations)
 }
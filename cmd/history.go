@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/duaraghav8/dockershrink/internal/diff"
+	"github.com/duaraghav8/dockershrink/internal/history"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/units"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// length of the input hash shown by history, like an abbreviated git commit
+const shortHashLength = 8
+
+var historyDockerfile string
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Lists the optimization runs recorded in the project",
+	Long: `Lists every "dockershrink optimize" run recorded in the .dockershrink/history directory of the project, oldest first, with the number of actions taken and the bytes saved.
+Savings are the sizes measured by --verify-build when the images were built, and estimates otherwise. Runs over the same Dockerfile and .dockerignore have the same input hash.
+Use "dockershrink show <run-id>" to see the details of a run.`,
+	Args: cobra.NoArgs,
+	Run:  runHistory,
+}
+
+var showCmd = &cobra.Command{
+	Use:   "show <run-id>",
+	Short: "Shows the details of a recorded optimization run",
+	Long: `Shows the changes, actions taken, recommendations and image sizes of a run recorded by "dockershrink optimize".
+The run ID can be "latest" or any unique prefix of a run ID.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runShow,
+}
+
+func init() {
+	historyCmd.Flags().StringVar(&historyDockerfile, "dockerfile", "", "Only list the runs over this Dockerfile")
+
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(showCmd)
+}
+
+func runHistory(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+	store := historyStore(logger)
+
+	runs, err := store.List()
+	if err != nil {
+		logger.Fatalf("Error reading run history: %v", err)
+	}
+	listed := []*history.Run{}
+	for _, run := range runs {
+		if historyDockerfile == "" || projectRelativePath(".", run.DockerfilePath) == projectRelativePath(".", historyDockerfile) {
+			listed = append(listed, run)
+		}
+	}
+	setOutputData(listed)
+	if len(listed) == 0 {
+		logger.Infof("No runs recorded yet. Runs are recorded every time \"dockershrink optimize\" is run.")
+		return
+	}
+
+	fmt.Printf("%-20s  %-16s  %-8s  %-24s  %7s  %s\n", "RUN", "DATE", "INPUT", "DOCKERFILE", "ACTIONS", "SAVED")
+	for _, run := range listed {
+		fmt.Printf("%-20s  %-16s  %-8s  %-24s  %7d  %s\n",
+			run.ID,
+			run.Timestamp.Local().Format("2006-01-02 15:04"),
+			shortHash(run.InputHash),
+			run.DockerfilePath,
+			len(run.ActionsTaken),
+			formatSavings(run),
+		)
+	}
+}
+
+func runShow(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+
+	run, err := historyStore(logger).Get(args[0])
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			logger.Fatalf("%v. Runs are recorded every time \"dockershrink optimize\" is run.", err)
+		}
+		logger.Fatalf("Error reading run history: %v", err)
+	}
+	setOutputData(run)
+
+	color.Cyan("Run: " + color.BlueString(run.ID) + color.WhiteString(" (%s)", run.Timestamp.Local().Format("2006-01-02 15:04:05")))
+	color.Cyan("Dockerfile: " + color.WhiteString(run.DockerfilePath))
+	color.Cyan("Input hash: " + color.WhiteString(run.InputHash))
+	if run.EstimatedSizeBefore > 0 {
+		color.Cyan("Estimated image size: " + color.WhiteString("%s -> %s", units.FormatBytes(run.EstimatedSizeBefore), units.FormatBytes(run.EstimatedSizeAfter)))
+	}
+	if run.VerifiedSizeAfter > 0 {
+		before := "unknown"
+		if run.VerifiedSizeBefore > 0 {
+			before = units.FormatBytes(run.VerifiedSizeBefore)
+		}
+		color.Cyan("Built image size: " + color.WhiteString("%s -> %s", before, units.FormatBytes(run.VerifiedSizeAfter)))
+	}
+	color.Cyan("Saved: " + color.WhiteString(formatSavings(run)))
+
+	// the path of the .dockerignore isn't recorded, it's usually next to the Dockerfile
+	dockerignorePath := path.Join(path.Dir(filepath.ToSlash(run.DockerfilePath)), ".dockerignore")
+	if d := diff.Unified("a/"+run.DockerfilePath, "b/"+run.DockerfilePath, run.InputDockerfile, run.OutputDockerfile) +
+		diff.Unified("a/"+dockerignorePath, "b/"+dockerignorePath, run.InputDockerignore, run.OutputDockerignore); d != "" {
+		fmt.Printf("\n============ Changes ============\n")
+		printDiff(d)
+	}
+
+	if len(run.ActionsTaken) > 0 {
+		fmt.Printf("\n============ %d Action(s) Taken ============\n", len(run.ActionsTaken))
+		for _, a := range run.ActionsTaken {
+			fmt.Printf("- %s (%s)\n", a.Title, a.Risk)
+		}
+	}
+	if len(run.Recommendations) > 0 {
+		fmt.Printf("\n============ %d Recommendation(s) ============\n", len(run.Recommendations))
+		for _, r := range run.Recommendations {
+			fmt.Printf("- %s\n", r.Title)
+		}
+	}
+}
+
+// historyStore returns the run history of the project in the current directory
+func historyStore(logger *log.Logger) *history.Store {
+	cwd, err := os.Getwd()
+	if err != nil {
+		logger.Fatalf("Error getting current working directory: %v", err)
+	}
+	return history.NewStore(cwd)
+}
+
+// formatSavings returns the bytes a run saved, eg- "120.5 MB (built)" when measured by --verify-build
+func formatSavings(run *history.Run) string {
+	savings, verified := run.Savings()
+	if savings == 0 && !verified {
+		return "-"
+	}
+	s := units.FormatBytes(savings)
+	if savings < 0 {
+		s = "-" + units.FormatBytes(-savings)
+	}
+	if verified {
+		return s + " (built)"
+	}
+	return s + " (estimated)"
+}
+
+// shortHash abbreviates a hash, runs recorded before hashes were kept have none
+func shortHash(hash string) string {
+	if len(hash) < shortHashLength {
+		return "-"
+	}
+	return hash[:shortHashLength]
+}
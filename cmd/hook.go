@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/githook"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/targets"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	staged         bool
+	forceHook      bool
+	stagedFlagHelp = "Only lint the Dockerfiles and .dockerignore files staged in git, as they're staged. Base image data isn't fetched, so that it's fast enough for a pre-commit hook"
+)
+
+var hookCmd = &cobra.Command{
+	Use:   "hook",
+	Short: "Manages the git hook that lints Dockerfiles before every commit",
+}
+
+var hookInstallCmd = &cobra.Command{
+	Use:   "install [-- lint flags]",
+	Short: "Installs a pre-commit hook that lints the staged Dockerfiles",
+	Long: `Installs a git pre-commit hook in the current repository that runs "dockershrink lint --staged".
+Only the Dockerfiles and .dockerignore files being committed are checked, with the static rules only, so the hook takes well under a second.
+Flags after -- are passed to lint, eg- to only block commits with high severity findings. Commits can skip the hook with git commit --no-verify.`,
+	Example: `  dockershrink hook install
+  dockershrink hook install -- --fail-on high --baseline .dockershrink-baseline.json`,
+	Run: runHookInstall,
+}
+
+var hookUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Removes the pre-commit hook installed by dockershrink",
+	Args:  cobra.NoArgs,
+	Run:   runHookUninstall,
+}
+
+func init() {
+	hookInstallCmd.Flags().BoolVar(&forceHook, "force", false, "Replace a pre-commit hook that wasn't installed by dockershrink")
+	hookCmd.AddCommand(hookInstallCmd, hookUninstallCmd)
+	rootCmd.AddCommand(hookCmd)
+}
+
+func runHookInstall(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+	path, err := githook.Install(".", args, forceHook)
+	if errors.Is(err, githook.ErrForeignHook) {
+		logger.Fatalf("%v. Add \"dockershrink lint --staged\" to it, or replace it with --force", err)
+	}
+	if err != nil {
+		logger.Fatalf("Error installing the hook: %v", err)
+	}
+	setOutputData(map[string]string{"hook": path})
+	color.Cyan("Installed the pre-commit hook: %s", color.BlueString(path))
+}
+
+func runHookUninstall(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+	path, err := githook.Uninstall(".")
+	if err != nil {
+		logger.Fatalf("Error removing the hook: %v", err)
+	}
+	setOutputData(map[string]string{"hook": path})
+	if path == "" {
+		color.Cyan("No pre-commit hook installed by dockershrink was found.")
+		return
+	}
+	color.Cyan("Removed the pre-commit hook: %s", color.BlueString(path))
+}
+
+// runStaged runs fn on every Dockerfile affected by the changes staged in git, like runRecursive does.
+// Nothing is run if no Dockerfile or .dockerignore file is staged.
+func runStaged(logger *log.Logger, fn targetFunc) bool {
+	cwd, err := os.Getwd()
+	if err != nil {
+		logger.Fatalf("Error getting current working directory: %v", err)
+	}
+	cfg, err := loadConfig(cwd)
+	if err != nil {
+		logger.Fatalf("Error loading configuration: %v", err)
+	}
+
+	files, err := githook.StagedFiles(cwd)
+	if err != nil {
+		logger.Fatalf("Error listing the staged files: %v", err)
+	}
+	changed, err := targets.FromChangedFiles(files)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	found := []*targets.Target{}
+	for _, t := range changed {
+		if !cfg.Ignored(filepath.ToSlash(t.Dockerfile)) {
+			found = append(found, t)
+		}
+	}
+	if len(found) == 0 {
+		logger.Infof("No staged Dockerfiles to lint.")
+		return false
+	}
+	return runTargets(logger, cfg, cwd, found, fn)
+}
+
+// stagedLoader loads Dockerfiles as they're staged in git, so that the changes left out of the commit aren't checked
+type stagedLoader struct {
+	coldLoader
+	dir string
+}
+
+func (l *stagedLoader) Dockerfile(path string) (*dockerfile.Dockerfile, error) {
+	content, err := githook.StagedContent(l.dir, path)
+	if err != nil {
+		return nil, err
+	}
+	return parseDockerfileAt(l.logger, path, content)
+}
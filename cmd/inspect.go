@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/docker"
+	"github.com/duaraghav8/dockershrink/internal/inspect"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/project"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+	"github.com/duaraghav8/dockershrink/internal/sandbox"
+	"github.com/duaraghav8/dockershrink/internal/units"
+	"github.com/duaraghav8/dockershrink/internal/verify"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// max time allowed for pulling, exporting and analyzing an image
+const inspectTimeout = 30 * time.Minute
+
+// how long the application runs before its workload when profiling, unless --profile-duration is given
+const defaultProfileDuration = 10 * time.Second
+
+var (
+	pullImage       bool
+	profileRuntime  bool
+	workload        string
+	profileDuration time.Duration
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <image>",
+	Short: "Analyzes an already built image",
+	Long: `Exports an image with the local Docker daemon and analyzes its layers to find large layers, duplicate files, leftover caches and secrets.
+Recommendations are made for the Dockerfile that built the image, even if the Dockerfile itself isn't available.
+The image is pulled from its registry if it doesn't exist locally. This command never writes any files and does not require an OpenAI API key.
+With --profile, the image is also run in a sandbox, optionally with a workload, and the files it never reads are reported as candidates for removal.
+Profiling needs /bin/sh, find, stat and touch inside the image.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runInspect,
+}
+
+func init() {
+	inspectCmd.Flags().BoolVar(&pullImage, "pull", false, "Always pull the image, even if it exists locally")
+	inspectCmd.Flags().BoolVar(&profileRuntime, "profile", false, "Run the image and report the files that are never read at runtime")
+	inspectCmd.Flags().StringVar(&workload, "workload", "", "Command run inside the container while profiling to exercise the application, eg- \"npm run smoke\". Implies --profile")
+	inspectCmd.Flags().DurationVar(&profileDuration, "profile-duration", defaultProfileDuration, "How long the application runs before the workload when profiling")
+
+	rootCmd.AddCommand(inspectCmd)
+}
+
+func runInspect(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+	ref := args[0]
+
+	var workloadCommand []string
+	if workload != "" {
+		var err error
+		if workloadCommand, err = verify.SplitCommand(workload); err != nil {
+			logger.Fatalf("Invalid workload: %v", err)
+		}
+		profileRuntime = true
+	}
+
+	client, err := docker.NewClient()
+	if err != nil {
+		logger.Fatalf("Cannot inspect the image: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(cmd.Context(), inspectTimeout)
+	defer cancel()
+	if err := client.Ping(ctx); err != nil {
+		logger.Fatalf("Cannot inspect the image: %v", err)
+	}
+
+	logger.Infof("* Exporting %s to analyze its layers, this may take a while", ref)
+	img, err := inspect.Load(ctx, client, ref, pullImage)
+	if err != nil {
+		logger.Fatalf("Error inspecting image: %v", err)
+	}
+	report := inspect.Analyze(img)
+	if profileRuntime {
+		report.Findings = append(report.Findings, profileImage(ctx, logger, client, img, workloadCommand)...)
+	}
+
+	fmt.Printf("\n============ Image ============\n")
+	color.Cyan("Image: " + color.WhiteString(ref))
+	color.Cyan("Size: " + color.WhiteString("%s in %d layers", units.FormatBytes(img.Size), len(img.Layers)))
+
+	fmt.Printf("\n============ Largest Layers ============\n")
+	for _, l := range report.LargestLayers {
+		instruction := inspect.LayerInstruction(l)
+		if len(instruction) > layerCodeMaxLength {
+			instruction = instruction[:layerCodeMaxLength-3] + "..."
+		}
+		fmt.Printf("%10s  layer %d: %s\n", units.FormatBytes(l.Size), l.Index, instruction)
+	}
+
+	printAnalysis(&project.AnalysisResponse{
+		Findings: report.Findings,
+		Score:    rules.Score(report.Findings),
+	})
+}
+
+// profileImage runs the image, along with the workload if one is given, and reports the files it never reads
+func profileImage(ctx context.Context, logger *log.Logger, client *docker.Client, img *inspect.Image, workload []string) []*models.Finding {
+	logger.Infof("* Running %s for %s to record the files it reads", img.Ref, profileDuration)
+	profile, err := inspect.Profile(ctx, sandbox.New(client), img, &inspect.ProfileOptions{
+		Workload: workload,
+		Duration: profileDuration,
+		Limits:   sandbox.DefaultLimits(),
+	})
+	if err != nil {
+		logger.Fatalf("Error profiling image: %v", err)
+	}
+
+	if res := profile.WorkloadResult; res != nil && !res.Succeeded() {
+		logger.Warnf("The workload exited with status %d, files it didn't get to read are reported as never read", res.ExitCode)
+		fmt.Println(tail(res.Stdout+res.Stderr, buildOutputTailLines))
+	}
+	read, total := profile.Counts()
+	logger.Infof("* %d of %d files in the image were read at runtime", read, total)
+	return inspect.CheckRuntimeProfile(img, profile, workload)
+}
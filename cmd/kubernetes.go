@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/duaraghav8/dockershrink/internal/config"
+	"github.com/duaraghav8/dockershrink/internal/kubernetes"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/targets"
+	"github.com/spf13/cobra"
+)
+
+// manifestPatterns are the Kubernetes manifests given with --k8s-manifest, on top of those of the configuration
+var manifestPatterns []string
+
+func addManifestFlag(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&manifestPatterns, "k8s-manifest", nil, "Kubernetes manifest or Helm values file deploying the image, eg- 'deploy/*.yaml', which the Dockerfile is cross-checked against. Can be repeated, and adds to the manifests of the configuration")
+}
+
+// loadManifests returns the containers of the Kubernetes manifests of the configuration and of --k8s-manifest
+// that run the image of the target. Manifests that can't be read are reported and skipped, since they only
+// add findings to the analysis.
+func loadManifests(logger *log.Logger, cfg *config.Config, t *targets.Target) []*kubernetes.Container {
+	patterns := append(append([]string{}, cfg.Kubernetes.Manifests...), manifestPatterns...)
+	if len(patterns) == 0 {
+		return nil
+	}
+	files, err := kubernetes.Find(patterns)
+	if err != nil {
+		logger.Warnf("Failed to find the Kubernetes manifests: %v", err)
+		return nil
+	}
+
+	containers := []*kubernetes.Container{}
+	for _, f := range files {
+		found, err := kubernetes.Load(f)
+		if err != nil {
+			logger.Warnf("Skipping Kubernetes manifest: %v", err)
+			continue
+		}
+		containers = append(containers, found...)
+	}
+	project := t.Dir
+	if abs, err := filepath.Abs(t.Dir); err == nil {
+		project = abs
+	}
+	matched := kubernetes.Match(containers, cfg.Kubernetes.Image, filepath.Base(project))
+	// findings point to the manifests relative to the working directory, like they do to the Dockerfile
+	if cwd, err := os.Getwd(); err == nil {
+		for _, c := range matched {
+			if abs, err := filepath.Abs(c.Path); err == nil {
+				if rel, err := filepath.Rel(cwd, abs); err == nil {
+					c.Path = filepath.ToSlash(rel)
+				}
+			}
+		}
+	}
+	logger.Debug("Matched the containers of the Kubernetes manifests", map[string]string{"containers": strconv.Itoa(len(matched))})
+	return matched
+}
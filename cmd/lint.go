@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/config"
+	"github.com/duaraghav8/dockershrink/internal/daemon"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/output"
+	"github.com/duaraghav8/dockershrink/internal/project"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+	"github.com/duaraghav8/dockershrink/internal/sinks"
+	"github.com/duaraghav8/dockershrink/internal/targets"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var listRules bool
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Checks the Docker image definition for a project against dockershrink's rules",
+	Long: `Runs the same static rules as analyze and prints one line per finding, like a linter.
+Every rule has a code, eg- DS014. Severities can be changed or rules turned off under "lint.severity" in .dockershrink.yaml,
+and single findings can be suppressed with a "# dockershrink:ignore DS014" comment above the instruction.
+Use "# dockershrink:ignore-file DS001" anywhere in the Dockerfile to suppress a rule everywhere.
+The command exits with status 3 if there are findings with a severity other than info, so that they can be told apart from errors, which exit with status 1.
+With --fail-on or --min-score, it exits with status 3 only if a Dockerfile doesn't meet them instead.
+Findings recorded with --write-baseline are left out by later runs given the file with --baseline.
+With --staged, only the Dockerfiles affected by the changes staged in git are linted, as they're staged. This is what the hook installed by "dockershrink hook install" runs.
+With --recursive, or --target given several times, up to --jobs Dockerfiles are linted at once. A Dockerfile that fails to be linted doesn't stop the others, and the command exits with status 2 if only some of them failed.`,
+	Run: runLint,
+}
+
+func init() {
+	lintCmd.Flags().StringVar(&dockerfilePath, "dockerfile", "Dockerfile", "Path to Dockerfile")
+	lintCmd.Flags().StringVar(&dockerignorePath, "dockerignore", ".dockerignore", "Path to .dockerignore")
+	lintCmd.Flags().StringVar(&goal, "goal", string(models.GoalAll), "Which rules to run: size, build-speed, security or all")
+	addProfileFlag(lintCmd)
+	addOrgPolicyFlag(lintCmd)
+	addManifestFlag(lintCmd)
+	addPolicyBundleFlag(lintCmd)
+	lintCmd.Flags().StringVar(&platforms, "platforms", "", "Comma-separated platforms the image is built for, eg- linux/amd64,linux/arm64. Base images are checked against all of them")
+	lintCmd.Flags().StringArrayVar(&buildContextFlags, "build-context", nil, buildContextFlagUsage)
+	lintCmd.Flags().BoolVar(&useDaemon, "daemon", false, daemonFlagUsage)
+	lintCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Lint every Dockerfile under the current directory, using the directory of each Dockerfile as its project")
+	addTargetFlags(lintCmd)
+	lintCmd.Flags().BoolVar(&listRules, "rules", false, "List all the rules along with their codes and default severities")
+	addCIFormatFlags(lintCmd)
+	addPolicyFlags(lintCmd)
+	addBaselineFlags(lintCmd)
+	lintCmd.Flags().BoolVar(&staged, "staged", false, stagedFlagHelp)
+
+	rootCmd.AddCommand(lintCmd)
+}
+
+func runLint(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+
+	if listRules {
+		printRules()
+		return
+	}
+
+	policy := policyFromFlags(logger)
+	loadBaseline(logger)
+	// failed tells whether the findings of a Dockerfile fail the command, by default if any of them isn't info
+	failed := func(dockerfile string, analysis *project.AnalysisResponse) bool {
+		hasProblems := printLintFindings(analysis.Findings)
+		if policy.IsSet() {
+			return checkPolicy(policy, dockerfile, analysis)
+		}
+		return hasProblems
+	}
+	if multipleTargets() || staged {
+		if staged && len(targetPaths) > 0 {
+			logger.Fatalf("--target can't be used with --staged")
+		}
+		run := runRecursive
+		var loader daemon.Loader = &coldLoader{ctx: cmd.Context(), logger: logger}
+		if staged {
+			run = runStaged
+			cwd, err := os.Getwd()
+			if err != nil {
+				logger.Fatalf("Error getting current working directory: %v", err)
+			}
+			loader = &stagedLoader{coldLoader: coldLoader{ctx: cmd.Context(), logger: logger, index: true}, dir: cwd}
+			// base image data is only read from the cache, the hook must not wait for the network
+			offline = true
+		}
+
+		anyFailed := false
+		partial := run(logger, func(t *targets.Target, cfg *config.Config, root string) (func(), error) {
+			analysis, err := analyzeTarget(cmd.Context(), logger, cfg, t, loader)
+			if err != nil {
+				return nil, err
+			}
+			return func() {
+				applyBaseline(logger, analysis)
+				addLintResult(cmd.Context(), logger, cfg, t, analysis)
+				anyFailed = failed(t.Dockerfile, analysis) || anyFailed
+			}, nil
+		})
+		writeBaseline(logger)
+		if partial {
+			exit(exitPartialFailure)
+		}
+		if anyFailed {
+			exit(exitViolations)
+		}
+		return
+	}
+
+	analysis, cfg, cwd := analyzeProject(cmd.Context(), logger)
+	applyBaseline(logger, analysis)
+	addLintResult(cmd.Context(), logger, cfg, projectTarget(cwd), analysis)
+	hasFailed := failed(dockerfilePath, analysis)
+	writeBaseline(logger)
+	if hasFailed {
+		exit(exitViolations)
+	}
+}
+
+// addLintResult adds the findings for a Dockerfile to the output document
+func addLintResult(ctx context.Context, logger *log.Logger, cfg *config.Config, t *targets.Target, analysis *project.AnalysisResponse) {
+	r := addResult(&output.Result{
+		Dockerfile:       t.Dockerfile,
+		Score:            &analysis.Score,
+		ScoreBreakdown:   rules.Breakdown(analysis.Findings),
+		Findings:         analysis.Findings,
+		InstructionSizes: analysis.Sizes,
+		Variants:         analysis.Variants,
+	})
+	addFixes(ctx, logger, cfg, t, r)
+	collectReport(&sinks.Report{
+		Command:        "lint",
+		Timestamp:      time.Now(),
+		DockerfilePath: t.Dockerfile,
+		Score:          &analysis.Score,
+		ScoreBreakdown: rules.Breakdown(analysis.Findings),
+		Findings:       analysis.Findings,
+	})
+}
+
+// printLintFindings prints one line per finding and returns true if any of them has a severity other than info
+func printLintFindings(findings []*models.Finding) bool {
+	failed := false
+	for _, f := range findings {
+		location := f.Filepath
+		if f.Line > 0 {
+			location = fmt.Sprintf("%s:%d", f.Filepath, f.Line)
+		}
+		fmt.Printf("%s: %s %s %s\n",
+			color.BlueString(location),
+			color.New(severityColors[f.Severity]).Sprintf("%s[%s]", f.Code, f.Severity),
+			f.Title,
+			color.WhiteString("(%s)", f.Rule),
+		)
+		if f.Severity != models.SeverityInfo {
+			failed = true
+		}
+	}
+
+	if len(findings) == 0 {
+		color.Green("No problems found.")
+		return false
+	}
+	fmt.Printf("\n%d problem(s) found\n", len(findings))
+	return failed
+}
+
+// ruleInfo describes a rule in the JSON output
+type ruleInfo struct {
+	ID       string          `json:"id"`
+	Name     string          `json:"name"`
+	Severity models.Severity `json:"severity"`
+	Goals    []models.Goal   `json:"goals"`
+}
+
+func printRules() {
+	infos := make([]*ruleInfo, 0, len(rules.All))
+	for _, rule := range rules.All {
+		infos = append(infos, &ruleInfo{ID: rule.ID, Name: rule.Name, Severity: rule.Severity, Goals: rule.Goals})
+		goals := make([]string, 0, len(rule.Goals))
+		for _, g := range rule.Goals {
+			goals = append(goals, string(g))
+		}
+		fmt.Printf("%s  %-36s %s  (%s)\n",
+			rule.ID,
+			rule.Name,
+			color.New(severityColors[rule.Severity]).Sprintf("%-6s", rule.Severity),
+			strings.Join(goals, ", "),
+		)
+	}
+	setOutputData(infos)
+}
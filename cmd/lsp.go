@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/duaraghav8/dockershrink/internal/config"
+	"github.com/duaraghav8/dockershrink/internal/daemon"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/lsp"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/project"
+	"github.com/duaraghav8/dockershrink/internal/targets"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Runs a language server that editors use to analyze and optimize Dockerfiles as they're edited",
+	Long: `Runs a Language Server Protocol server on stdin and stdout, for editors to start when a Dockerfile is opened.
+Dockerfiles are analyzed with the static rules as you type, and the findings show up as diagnostics. The project of a Dockerfile is its directory, and the configuration is read from the workspace folder it's in.
+Code actions fix a finding with the changes "optimize" makes to its lines, fix all of them at once, or optimize the whole Dockerfile with the AI, which requires an OpenAI API key.
+Logs are written to stderr, which editors usually show in the output of the language server.`,
+	Args: cobra.NoArgs,
+	Run:  runLSP,
+}
+
+func init() {
+	lspCmd.Flags().StringVar(&goal, "goal", string(models.GoalAll), "What to analyze and optimize the images for: size, build-speed, security or all")
+	addProfileFlag(lspCmd)
+	addOrgPolicyFlag(lspCmd)
+
+	rootCmd.AddCommand(lspCmd)
+}
+
+func runLSP(cmd *cobra.Command, args []string) {
+	// stdout carries the protocol, anything else printed to it would break the connection
+	protocolOut := os.Stdout
+	os.Stdout = os.Stderr
+	color.Output = os.Stderr
+	logger := log.NewLogger(debug)
+
+	e := &lspEngine{
+		logger:   logger,
+		warm:     daemon.NewWarm(&coldLoader{ctx: cmd.Context(), logger: logger, index: true}),
+		flagGoal: goal,
+	}
+	server := lsp.NewServer(e.analyze, e.optimize)
+	if err := server.Serve(cmd.Context(), os.Stdin, protocolOut); err != nil {
+		logger.Fatalf("Language server stopped: %v", err)
+	}
+}
+
+// lspEngine analyzes and optimizes the Dockerfiles opened in the editor, keeping the state of their projects warm
+// like the daemon does. Like daemonAnalyzer, it runs one analysis at a time in the directory of the project.
+type lspEngine struct {
+	logger *log.Logger
+	warm   *daemon.Warm
+	// flagGoal is the goal given on the command line, which the profile of each project's configuration may override
+	flagGoal string
+
+	mu sync.Mutex
+}
+
+// enter changes to the workspace folder of the file and returns its configuration, along with the
+// function to change back. The Dockerfile is loaded from its content in the editor.
+func (e *lspEngine) enter(f *lsp.File) (*config.Config, *targets.Target, daemon.Loader, func(), error) {
+	e.mu.Lock()
+	dir := f.Root
+	if dir == "" {
+		dir = filepath.Dir(f.Path)
+	}
+	previous, err := os.Getwd()
+	if err != nil {
+		e.mu.Unlock()
+		return nil, nil, nil, nil, err
+	}
+	if err := os.Chdir(dir); err != nil {
+		e.mu.Unlock()
+		return nil, nil, nil, nil, fmt.Errorf("Error changing to directory %s: %w", dir, err)
+	}
+	leave := func() {
+		os.Chdir(previous)
+		e.mu.Unlock()
+	}
+
+	goal = e.flagGoal
+	cfg, err := loadConfig(dir)
+	if err != nil {
+		leave()
+		return nil, nil, nil, nil, fmt.Errorf("Error loading configuration: %w", err)
+	}
+	ts, err := targets.FromPaths([]string{f.Path})
+	if err != nil {
+		leave()
+		return nil, nil, nil, nil, err
+	}
+	return cfg, ts[0], &editorLoader{Loader: e.warm, logger: e.logger, file: f}, leave, nil
+}
+
+func (e *lspEngine) analyze(ctx context.Context, f *lsp.File) ([]*models.Finding, error) {
+	cfg, t, loader, leave, err := e.enter(f)
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+
+	analysis, err := analyzeTarget(ctx, e.logger, cfg, t, loader)
+	if err != nil {
+		return nil, err
+	}
+	return analysis.Findings, nil
+}
+
+func (e *lspEngine) optimize(ctx context.Context, f *lsp.File, withAI bool) (string, error) {
+	cfg, t, loader, leave, err := e.enter(f)
+	if err != nil {
+		return "", err
+	}
+	defer leave()
+
+	optimizationGoal, err := models.ParseGoal(goal)
+	if err != nil {
+		return "", err
+	}
+	platformTargets, err := targetPlatforms(e.logger, cfg)
+	if err != nil {
+		return "", err
+	}
+	opts := &project.OptimizeOptions{Goal: optimizationGoal, Platforms: platformTargets}
+	dockerfileObject, err := loader.Dockerfile(t.Dockerfile)
+	if err != nil {
+		return "", err
+	}
+	if !withAI {
+		// the project is modified while optimizing, so it's loaded again instead of reusing the analyzed one
+		proj, _, err := newTargetProject(e.logger, t, dockerfileObject, loader, nil, false)
+		if err != nil {
+			return "", err
+		}
+		response, err := proj.OptimizeDockerImage(ctx, nil, opts)
+		if err != nil {
+			return "", err
+		}
+		return response.Dockerfile, nil
+	}
+
+	aiService, ok := getAIService(e.logger, cfg)
+	if !ok {
+		return "", errors.New("optimizing with AI requires an OpenAI API key")
+	}
+	attachDocs(e.logger, aiService, cfg)
+	proj, _, err := newTargetProject(e.logger, t, dockerfileObject, loader, cfg.Ignored, true)
+	if err != nil {
+		return "", err
+	}
+	policies, err := loadPolicies(cfg)
+	if err != nil {
+		return "", err
+	}
+	proj.SetPolicies(policies)
+	response, err := proj.OptimizeDockerImage(ctx, aiService, opts)
+	if err != nil {
+		return "", err
+	}
+	return response.Dockerfile, nil
+}
+
+// editorLoader loads the Dockerfile opened in the editor from its content, which may not be saved yet
+type editorLoader struct {
+	daemon.Loader
+	logger *log.Logger
+	file   *lsp.File
+}
+
+func (l *editorLoader) Dockerfile(path string) (*dockerfile.Dockerfile, error) {
+	if filepath.Clean(path) != filepath.Clean(l.file.Path) {
+		return l.Loader.Dockerfile(path)
+	}
+	// syntax errors are returned as they are, so that the editor shows them where they are
+	d, _, err := parseDockerfile(l.logger, l.file.Content)
+	return d, err
+}
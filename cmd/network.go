@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+
+	"github.com/duaraghav8/dockershrink/internal/config"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/network"
+	"github.com/spf13/cobra"
+)
+
+var (
+	proxyURL string
+	noProxy  []string
+	caCerts  []string
+)
+
+// appliedNetwork are the options http.DefaultTransport was set up with, nil until it is
+var appliedNetwork *network.Options
+
+// setupNetwork sets up the transport every HTTP client of dockershrink uses, including the LLM's,
+// according to the network section of the configuration and the --proxy, --no-proxy and --ca-cert flags.
+// It's run before every command with the configuration of the current directory, and again once a command loads
+// the configuration of its project.
+func setupNetwork(root *cobra.Command, cfg *config.Config) error {
+	opts := network.Options{Proxy: cfg.Network.Proxy, NoProxy: cfg.Network.NoProxy, CACerts: cfg.Network.CACerts}
+	flags := root.PersistentFlags()
+	if flags.Changed("proxy") {
+		opts.Proxy = proxyURL
+	}
+	if flags.Changed("no-proxy") {
+		opts.NoProxy = noProxy
+	}
+	// like --policy, --ca-cert adds to the configuration
+	opts.CACerts = append(append([]string{}, opts.CACerts...), caCerts...)
+	if appliedNetwork != nil && reflect.DeepEqual(*appliedNetwork, opts) {
+		return nil
+	}
+
+	transport, err := network.Transport(opts)
+	if err != nil {
+		return err
+	}
+	if u, err := url.Parse(opts.Proxy); err == nil {
+		if password, ok := u.User.Password(); ok {
+			log.AddSecret(password)
+		}
+	}
+	http.DefaultTransport = transport
+	appliedNetwork = &opts
+	return nil
+}
+
+// setupDefaultNetwork sets up the transport before the command runs. An invalid configuration is left for the
+// commands that load it to report, the flags and environment variables are used in the meantime.
+func setupDefaultNetwork(cmd *cobra.Command) error {
+	cfg, err := config.Load(".")
+	if err != nil {
+		cfg = config.Default()
+	}
+	return setupNetwork(cmd.Root(), cfg)
+}
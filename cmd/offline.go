@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/airgap"
+	"github.com/duaraghav8/dockershrink/internal/baseimages"
+	"github.com/duaraghav8/dockershrink/internal/docs"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	offlineBundlePath string
+	offlineBundleCVEs bool
+)
+
+var offlineBundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Downloads the data dockershrink fetches from the network, to run on machines without internet access",
+	Long: `Static analysis, optimization and the documentation search of the LLM use data fetched from the network: the release cycles and sizes of official base images,
+their known vulnerabilities and the embeddings of dockershrink's documentation. "bundle download" caches all of it, so that dockershrink works with --offline,
+and optionally packs it into an archive that "bundle install" unpacks on an air-gapped machine.
+The rules and the documentation are built into dockershrink and don't need to be downloaded.`,
+}
+
+var offlineBundleDownloadCmd = &cobra.Command{
+	Use:   "download [image...]",
+	Short: "Caches the base image data and the embeddings of the documentation, and optionally writes them to an archive",
+	Long: `Fetches the release cycles and tag sizes of the given official images, or of all of them, and computes the embeddings of the documentation
+with the embeddings provider of the configuration, if there is one. Vulnerability counts already cached are included, and --cves scans every supported tag with Docker Scout first.
+Everything is cached in the user's cache directory. With --archive, it's also written to an archive to copy to another machine and install with "dockershrink bundle install".`,
+	Run: runOfflineBundleDownload,
+}
+
+var offlineBundleInstallCmd = &cobra.Command{
+	Use:   "install <archive>",
+	Short: "Adds the data of an archive written by \"bundle download\" to the cache",
+	Long: `Verifies the checksums of the archive and adds its data to the user's cache directory. Cached images fetched more recently than those of the archive are kept.
+Run dockershrink with --offline afterwards, so that it doesn't try to reach the network for fresher data.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runOfflineBundleInstall,
+}
+
+func init() {
+	offlineBundleDownloadCmd.Flags().StringVar(&offlineBundlePath, "archive", "", "Also write the data to this archive, eg- dockershrink-offline.tar.gz")
+	offlineBundleDownloadCmd.Flags().BoolVar(&offlineBundleCVEs, "cves", false, "Count the known vulnerabilities of every supported tag using Docker Scout, which requires Docker and can take a while")
+
+	offlineBundleCmd.AddCommand(offlineBundleDownloadCmd)
+	offlineBundleCmd.AddCommand(offlineBundleInstallCmd)
+	rootCmd.AddCommand(offlineBundleCmd)
+}
+
+func runOfflineBundleDownload(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+	if offline {
+		logger.Fatalf("bundle download requires network access and cannot be used with --offline")
+	}
+	cache, err := baseimages.DefaultCache()
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	names := args
+	if len(names) == 0 {
+		names = baseimages.Builtin().Names()
+	}
+	logger.Infof("* Fetching the release cycles and tag sizes of %s", strings.Join(names, ", "))
+	ctx, cancel := context.WithTimeout(cmd.Context(), baseImagesTimeout*time.Duration(len(names)))
+	m, err := baseimages.Load(ctx, names, &baseimages.LoadOptions{Cache: cache, Fetcher: baseimages.NewFetcher(), Refresh: true})
+	cancel()
+	if err != nil {
+		logger.Fatalf("Error fetching base image data: %v", err)
+	}
+	if offlineBundleCVEs {
+		scanBaseImageCVEs(cmd.Context(), logger, m, names)
+	}
+	cached, err := cache.Read()
+	if err != nil {
+		logger.Fatalf("Error reading base image cache: %v", err)
+	}
+	b := airgap.New(Version, cached)
+
+	cfg, err := loadConfig(".")
+	if err != nil {
+		logger.Fatalf("Error loading configuration: %v", err)
+	}
+	embedder, err := getEmbedder(cfg)
+	switch {
+	case err != nil:
+		logger.Warnf("* The embeddings of the documentation aren't included, it will be searched by keywords: %v", err)
+	case embedder == nil:
+		logger.Infof("* No embeddings provider is configured, the documentation will be searched by keywords")
+	default:
+		cacheDir, err := docs.DefaultCacheDir()
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		logger.Infof("* Computing the embeddings of the documentation with %s", embedder.Name())
+		path, err := docs.NewIndex(docs.Passages(), embedder, cacheDir).Precompute(cmd.Context())
+		if err == nil {
+			err = b.AddEmbeddings(embedder.Name(), path)
+		}
+		if err != nil {
+			logger.Warnf("* The embeddings of the documentation aren't included, it will be searched by keywords: %v", err)
+		}
+	}
+
+	if offlineBundlePath != "" {
+		f, err := os.Create(offlineBundlePath)
+		if err != nil {
+			logger.Fatalf("Error creating bundle: %v", err)
+		}
+		if err := airgap.Write(f, b); err != nil {
+			f.Close()
+			logger.Fatalf("Error writing bundle: %v", err)
+		}
+		if err := f.Close(); err != nil {
+			logger.Fatalf("Error writing bundle: %v", err)
+		}
+	}
+
+	setOutputData(b.Manifest)
+	printOfflineManifest(b.Manifest)
+	if offlineBundlePath != "" {
+		color.Green("\nBundle written to %s, install it with \"dockershrink bundle install %s\"", offlineBundlePath, offlineBundlePath)
+	} else {
+		color.Green("\nThe data is cached, dockershrink can now run with --offline")
+	}
+}
+
+func runOfflineBundleInstall(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+	f, err := os.Open(args[0])
+	if err != nil {
+		logger.Fatalf("Error opening bundle: %v", err)
+	}
+	b, err := airgap.Read(f)
+	f.Close()
+	if err != nil {
+		logger.Fatalf("Error reading bundle: %v", err)
+	}
+
+	cache, err := baseimages.DefaultCache()
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	embeddingsDir, err := docs.DefaultCacheDir()
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	if err := b.Install(cache, embeddingsDir); err != nil {
+		logger.Fatalf("Error installing bundle: %v", err)
+	}
+
+	setOutputData(b.Manifest)
+	printOfflineManifest(b.Manifest)
+	color.Green("\nInstalled, run dockershrink with --offline to use the data of the bundle")
+	if len(b.Manifest.EmbeddingsProviders) > 0 {
+		logger.Infof("The documentation is searched with the embeddings of the bundle if the same provider is configured here, and it must still be able to embed the LLM's queries.")
+	}
+}
+
+func printOfflineManifest(m *airgap.Manifest) {
+	color.Cyan("Created: " + color.WhiteString("%s by dockershrink %s", m.CreatedAt.Local().Format("2006-01-02 15:04"), m.DockershrinkVersion))
+	color.Cyan("Base images: " + color.WhiteString(strings.Join(m.Images, ", ")))
+	color.Cyan("Tags scanned for vulnerabilities: " + color.WhiteString(fmt.Sprint(m.ScannedTags)))
+	embeddings := "none, the documentation is searched by keywords"
+	if len(m.EmbeddingsProviders) > 0 {
+		embeddings = strings.Join(m.EmbeddingsProviders, ", ")
+	}
+	color.Cyan("Documentation embeddings: " + color.WhiteString(embeddings))
+}
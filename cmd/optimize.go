@@ -1,16 +1,37 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/duaraghav8/dockershrink/internal/ai"
+	"github.com/duaraghav8/dockershrink/internal/buildcontext"
+	"github.com/duaraghav8/dockershrink/internal/classification"
+	"github.com/duaraghav8/dockershrink/internal/config"
+	"github.com/duaraghav8/dockershrink/internal/diff"
+	"github.com/duaraghav8/dockershrink/internal/distroless"
+	"github.com/duaraghav8/dockershrink/internal/docker"
 	"github.com/duaraghav8/dockershrink/internal/dockerfile"
-	"github.com/duaraghav8/dockershrink/internal/dockerignore"
+	"github.com/duaraghav8/dockershrink/internal/docs"
+	"github.com/duaraghav8/dockershrink/internal/explain"
+	"github.com/duaraghav8/dockershrink/internal/history"
 	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/output"
 	"github.com/duaraghav8/dockershrink/internal/project"
+	"github.com/duaraghav8/dockershrink/internal/registry"
+	"github.com/duaraghav8/dockershrink/internal/remote"
 	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+	"github.com/duaraghav8/dockershrink/internal/sandbox"
+	"github.com/duaraghav8/dockershrink/internal/sinks"
+	"github.com/duaraghav8/dockershrink/internal/targets"
+	"github.com/duaraghav8/dockershrink/internal/units"
+	"github.com/duaraghav8/dockershrink/internal/verify"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
@@ -18,52 +39,224 @@ import (
 var (
 	dockerfilePath   string
 	dockerignorePath string
+	goal             string
+	profile          string
+	patchFile        string
+	interactive      bool
+	verifyBuild      bool
+	verifyBoot       bool
+	verifyRun        string
+	verifyWithDeps   bool
+	composeFile      string
+	composeService   string
+	pinImages        bool
+	platforms        string
+	repairSyntax     bool
+	applyRisk        string
+	hardening        bool
+	explainChanges   bool
+	migrateTo        string
+	contextArchive   string
 )
 
+// remotePatchFile is where the changes to a remote project are written if --patch-file isn't set
+const remotePatchFile = "dockershrink.patch"
+
 var optimizeCmd = &cobra.Command{
-	Use:   "optimize",
+	Use:   "optimize [git URL[#ref] | -]",
 	Short: "Optimizes the Docker image definition for a project",
 	Long: `Optimizes the Dockerfile and .dockerignore files for a NodeJS project and provides recommendations where applicable.
+The changes are printed as a unified diff and the optimized files are written to the output directory.
+Use --patch-file to write the changes as a patch instead, which can be applied to the project with "git apply".
+Use --interactive to review the changes hunk by hunk and choose which ones to apply, like "git add -p".
+Use --apply-risk to only apply changes up to a risk level, eg- in CI, and get the riskier ones as recommendations.
+With --recursive, or --target given several times, up to --jobs Dockerfiles are optimized at once and the optimized files are written to the output directory at the same paths as the originals.
+Pass the URL of a git repository, optionally followed by "#" and a branch, tag or commit, or a tarball of the project with --context, to optimize a project that isn't checked out.
+It's fetched into a temporary directory, and the changes are written to --patch-file (default: dockershrink.patch) in the current directory.
+Use --create-pr to commit the optimized files to a new branch of the project's repository on GitHub or GitLab and open a pull request with the report as its description, instead of writing them.
+Pass "-" to read the Dockerfile from stdin and write the optimized Dockerfile to stdout, eg- "cat Dockerfile | dockershrink optimize - > Dockerfile.optimized".
+Everything else is written to stderr. The current directory is the build context of the Dockerfile, unless --no-context is set.
+The requests to the LLM can be paced with "llm.requests_per_minute" in .dockershrink.yaml to stay within the rate limits of the API key.
 OpenAI API key is optional for this command, but it is recommended to provide one for better results.`,
-	Run: runOptimize,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runOptimize,
 }
 
 func init() {
 	optimizeCmd.Flags().StringVar(&dockerfilePath, "dockerfile", "Dockerfile", "Path to Dockerfile")
 	optimizeCmd.Flags().StringVar(&dockerignorePath, "dockerignore", ".dockerignore", "Path to .dockerignore")
+	optimizeCmd.Flags().StringVar(&goal, "goal", string(models.GoalAll), "What to optimize the image for: size, build-speed, security or all")
+	addProfileFlag(optimizeCmd)
+	addOrgPolicyFlag(optimizeCmd)
+	optimizeCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Review every change and choose which ones to apply")
+	optimizeCmd.Flags().BoolVar(&verifyBuild, "verify-build", false, "Build the original and optimized images with the local Docker daemon, fail if the optimized one doesn't build and report the real image sizes")
+	optimizeCmd.Flags().BoolVar(&verifyBoot, "verify-boot", false, "Start the optimized image and check that the application boots and passes its HEALTHCHECK (implies --verify-build)")
+	optimizeCmd.Flags().StringVar(&verifyRun, "verify-run", "", "Command to run inside the optimized container once it has booted, it must exit with status 0 (implies --verify-boot)")
+	optimizeCmd.Flags().BoolVar(&verifyWithDeps, "verify-with-deps", false, "Start the services the image's compose service depends on before smoke testing it, and tear them down afterwards")
+	optimizeCmd.Flags().StringVar(&composeFile, "compose-file", "", "Compose file used by --verify-with-deps and to find named build contexts (default: compose.yaml or docker-compose.yml in the current directory)")
+	optimizeCmd.Flags().StringVar(&composeService, "compose-service", "", "Compose service built from the Dockerfile (default: the service whose build points to the Dockerfile)")
+	optimizeCmd.Flags().StringVar(&platforms, "platforms", "", "Comma-separated platforms the image is built for, eg- linux/amd64,linux/arm64. Only base images published for all of them are recommended")
+	optimizeCmd.Flags().BoolVar(&pinImages, "pin-base-images", false, "Pin every base image to the digest its tag currently points to, eg- FROM node:20-alpine@sha256:...")
+	optimizeCmd.Flags().BoolVar(&repairSyntax, "repair-syntax", false, "If the Dockerfile has syntax errors that can't be recovered automatically, ask the LLM to correct them before optimizing")
+	optimizeCmd.Flags().StringArrayVar(&buildContextFlags, "build-context", nil, buildContextFlagUsage)
+	optimizeCmd.Flags().StringVar(&applyRisk, "apply-risk", "", "Only apply changes up to this risk level: cosmetic, cache-impacting, size-impacting or behavior-changing (default: apply all). Riskier changes are turned into recommendations")
+	optimizeCmd.Flags().BoolVar(&explainChanges, "explain", false, "Explain every change and recommendation: the practice behind it, why it helps, what it saves on its own and where to read more")
+	optimizeCmd.Flags().BoolVar(&hardening, "include-security-recommendations", false, "Also harden the container: run it as a non-root user, add a HEALTHCHECK, drop setuid binaries and set NODE_ENV=production (needs the security goal)")
+	optimizeCmd.Flags().StringVar(&migrateTo, "migrate-to", "", "Move the final stage to a distroless or chiseled image, copying the application, its runtime and the shared libraries it loads into it (needs the size or security goal)")
+	optimizeCmd.Flags().StringVar(&contextArchive, "context", "", "Optimize the project in this tarball (.tar or .tar.gz) instead of the current directory, and write the changes to --patch-file")
+	optimizeCmd.Flags().StringVar(&patchFile, "patch-file", "", "Write the changes to this file as a git-applyable patch instead of writing the optimized files")
+	optimizeCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Optimize every Dockerfile under the current directory, using the directory of each Dockerfile as its project")
+	addPullRequestFlags(optimizeCmd)
+	addPipelineFlags(optimizeCmd)
+	addTargetFlags(optimizeCmd)
+	addReportFlags(optimizeCmd)
 
 	rootCmd.AddCommand(optimizeCmd)
 }
 
 func runOptimize(cmd *cobra.Command, args []string) {
+	// the Dockerfile is read from stdin, and stdout is reserved for the optimized one before anything is logged
+	fromStdin := len(args) > 0 && args[0] == stdinArg
+	var pipelineErr error
+	if fromStdin {
+		pipelineErr = setupPipeline(cmd)
+		args = nil
+	}
 	logger := log.NewLogger(debug)
-	aiService, _ := getAIService(logger)
+	ctx := cmd.Context()
+	if pipelineErr != nil {
+		logger.Fatalf("%v", pipelineErr)
+	}
+	if noContext && !fromStdin {
+		logger.Fatalf("--no-context can only be used with \"-\", when the Dockerfile is read from stdin")
+	}
 
-	// Read Dockerfile
-	dockerfileContents, err := os.ReadFile(dockerfilePath)
+	cwd, err := os.Getwd()
 	if err != nil {
-		logger.Fatalf("Error reading %s: %v", dockerfilePath, err)
+		logger.Fatalf("Error getting current working directory: %v", err)
+	}
+	remoteProject := len(args) > 0 || contextArchive != ""
+	if remoteProject {
+		workspace, err := fetchRemoteProject(ctx, logger, cmd, cwd, args)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		// the reports are written once the command returns, relative to the directory it was run in.
+		// exit skips deferred calls, so the workspace is left behind if the optimization fails.
+		defer func(dir string) {
+			os.Chdir(dir)
+			workspace.Remove()
+		}(cwd)
+		cwd = workspace.Dir
+	}
+	if createPR {
+		remoteURL, ref := "", ""
+		if len(args) > 0 {
+			remoteURL, ref = remote.SplitRef(args[0])
+		}
+		if contextArchive != "" {
+			logger.Fatalf("--create-pr needs a git repository, pass its URL instead of --context")
+		}
+		if err := preparePullRequest(cwd, remoteURL, ref); err != nil {
+			logger.Fatalf("%v", err)
+		}
+	}
+	cfg, err := loadConfig(cwd)
+	if err != nil {
+		logger.Fatalf("Error loading configuration: %v", err)
+	}
+	if !cmd.Flags().Changed("patch-file") && cfg.Output.PatchFile != "" && !remoteProject && !fromStdin {
+		patchFile = cfg.Output.PatchFile
+	}
+	// the configuration still comes from the current directory, only the files of the project are left out
+	if noContext {
+		empty, cleanup, err := enterEmptyContext(cwd)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		defer cleanup()
+		cwd = empty
+	}
+	aiService, _ := getAIService(logger, cfg)
+
+	optimizationGoal, err := models.ParseGoal(goal)
+	if err != nil {
+		logger.Fatalf("%v", err)
 	}
 
-	dockerfileObject, err := dockerfile.NewDockerfile(string(dockerfileContents))
+	var maxRisk models.Risk
+	if applyRisk != "" {
+		if maxRisk, err = models.ParseRisk(applyRisk); err != nil {
+			logger.Fatalf("Invalid --apply-risk: %v", err)
+		}
+	}
 
-	// Read .dockerignore if it exists
-	var dockerignoreObject *dockerignore.Dockerignore
+	var migrationTarget distroless.Target
+	if migrateTo != "" {
+		if migrationTarget, err = distroless.ParseTarget(migrateTo); err != nil {
+			logger.Fatalf("Invalid --migrate-to: %v", err)
+		}
+	}
 
-	if _, err := os.Stat(dockerignorePath); err == nil {
-		content, err := os.ReadFile(dockerignorePath)
+	if pinImages && offline {
+		logger.Fatalf("--pin-base-images requires access to the registries and cannot be used with --offline")
+	}
+	if repairSyntax && aiService == nil {
+		logger.Fatalf("--repair-syntax requires an OpenAI API key")
+	}
+	if multipleTargets() {
+		if err := validateOptimizeTargets(cmd); err != nil {
+			logger.Fatalf("%v", err)
+		}
+		runOptimizeTargets(ctx, logger, cfg, aiService, &project.OptimizeOptions{Goal: optimizationGoal, MaxRisk: maxRisk, Hardening: hardening, MigrateTo: migrationTarget, Explain: explainChanges})
+		return
+	}
+
+	verifyOpts := &verifyOptions{smokeTest: verifyBoot || verifyRun != ""}
+	if verifyRun != "" {
+		verifyOpts.smokeTestCommand, err = verify.SplitCommand(verifyRun)
+		if err != nil {
+			logger.Fatalf("Invalid --verify-run command: %v", err)
+		}
+	}
+	if verifyWithDeps {
+		if !verifyOpts.smokeTest {
+			logger.Fatalf("--verify-with-deps requires --verify-boot or --verify-run")
+		}
+		verifyOpts.compose, verifyOpts.composeService, err = loadComposeService(composeFile, composeService, dockerfilePath)
 		if err != nil {
-			logger.Fatalf("Error reading %s: %v", dockerignorePath, err)
+			logger.Fatalf("%v", err)
 		}
-		dockerignoreObject = dockerignore.NewDockerignore(string(content))
+	}
+
+	var dockerfileContent []byte
+	if fromStdin {
+		dockerfileContent, err = io.ReadAll(os.Stdin)
 	} else {
+		dockerfileContent, err = os.ReadFile(dockerfilePath)
+	}
+	if err != nil {
+		logger.Fatalf("Error reading %s: %v", dockerfilePath, err)
+	}
+	dockerfileObject, syntaxActions, err := loadDockerfileForOptimization(ctx, logger, aiService, dockerfilePath, string(dockerfileContent))
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	dockerignoreObject, err := readDockerignore(dockerignorePath)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	// the patch creates the .dockerignore at the given path if it doesn't exist yet
+	dockerignorePatchPath := dockerignorePath
+	if dockerignoreObject == nil {
 		logger.Warnf("* No dockerignore file found at %s", dockerignorePath)
 		// set path to empty string to signify to the rest of the application
 		// that .dockerignore does not exist for this project
 		dockerignorePath = ""
 	}
 
-	packageJson, err := getPackageJson()
+	packageJson, err := getPackageJson(".")
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			logger.Warnf("* No package.json file found")
@@ -72,11 +265,29 @@ func runOptimize(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	cwd, err := os.Getwd()
+	if aiService != nil {
+		attachDocs(logger, aiService, cfg)
+	}
+
+	class := classifyDockerfile(cwd, dockerfilePath, cfg)
+	logger.Infof("* Dockerfile classified as %s (%s: %s)", class.Kind, class.Source, class.Reason)
+	if class.Kind == classification.KindCI {
+		switch cfg.CIDockerfiles {
+		case config.CIDockerfilesSkip:
+			logger.Infof("Skipping CI-only Dockerfile. Set \"ci_dockerfiles\" in %s to change this.", config.Filename)
+			writePipelineOutput(string(dockerfileContent))
+			return
+		case config.CIDockerfilesRelaxed:
+			logger.Infof("* Applying the relaxed rule set for CI-only Dockerfiles")
+			optimizationGoal = models.GoalBuildSpeed
+		}
+	}
+
+	ignored, err := withExcludedPaths(cfg.Ignored)
 	if err != nil {
-		logger.Fatalf("Error getting current working directory: %v", err)
+		logger.Fatalf("%v", err)
 	}
-	cwdTree, err := getDirTree(cwd)
+	cwdTree, err := getDirTree(cwd, cfg.Ignored)
 	if err != nil {
 		logger.Fatalf("%v", err)
 	}
@@ -87,56 +298,610 @@ func runOptimize(cmd *cobra.Command, args []string) {
 		dockerfilePath,
 		dockerignorePath,
 	)
+	projectDirFS.SetIgnored(ignored)
+
+	buildContexts, err := addBuildContexts(logger, projectDirFS, dockerfilePath, true)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	ws, err := getWorkspace(cwd)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	if ws != nil {
+		logger.Infof("* Detected a %s monorepo workspace with %d package(s)", ws.Manager, len(ws.Packages))
+	}
 
-	proj := project.NewProject(dockerfileObject, dockerignoreObject, packageJson, projectDirFS)
+	proj := project.NewProject(dockerfileObject, dockerignoreObject, packageJson, projectDirFS, ws, workspacePackage)
+	proj.SetEvents(logEvents(logger))
+	proj.SetBaseImages(loadBaseImages(ctx, logger, dockerfileObject))
+	policies, err := loadPolicies(cfg)
+	if err != nil {
+		logger.Fatalf("Error loading the policies of your organization: %v", err)
+	}
+	proj.SetPolicies(policies)
+
+	run := &history.Run{
+		Command:         "optimize",
+		DockerfilePath:  dockerfilePath,
+		InputDockerfile: string(dockerfileContent),
+	}
+	// the original Dockerfile as parsed, after fixing its syntax errors if it had any
+	parsedDockerfile := dockerfileObject.Raw()
+	if dockerignoreObject != nil {
+		run.InputDockerignore = dockerignoreObject.Raw()
+	}
 
-	response, err := proj.OptimizeDockerImage(aiService)
+	platformTargets, err := targetPlatforms(logger, cfg)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	optimizeOpts := &project.OptimizeOptions{Goal: optimizationGoal, Platforms: platformTargets, MaxRisk: maxRisk, Hardening: hardening, MigrateTo: migrationTarget, Explain: explainChanges}
+	// removes the image built to find the shared libraries, it's called as soon as the optimization is done
+	// rather than deferred, since the fatal errors below exit without running deferred functions
+	cleanup := func() {}
+	if migrationTarget != "" {
+		optimizeOpts.Libraries, cleanup = originalImageLibraries(ctx, logger, cwd, buildContexts)
+	}
+	if pinImages {
+		optimizeOpts.PinResolver = registry.NewClient()
+	}
+	response, err := proj.OptimizeDockerImage(ctx, aiService, optimizeOpts)
+	cleanup()
 	if err != nil {
 		logger.Fatalf("Error optimizing Docker image (use --debug to get more info): %s", err)
 	}
+	response.ActionsTaken = append(syntaxActions, response.ActionsTaken...)
+	explain.Annotate(syntaxActions)
 
-	if len(response.ActionsTaken) > 0 {
-		// Save optimized files
-		if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
-			logger.Fatalf("Error creating output directory: %v", err)
+	// set if the user rejected every change made by dockershrink
+	changesRejected := false
+	if interactive && len(response.ActionsTaken) > 0 {
+		r := newReviewer(ctx, os.Stdin, aiService)
+		response.Dockerfile, err = r.review(projectRelativePath(cwd, dockerfilePath), run.InputDockerfile, response.Dockerfile, response.ActionsTaken)
+		if err != nil {
+			logger.Fatalf("Error reviewing changes: %v", err)
+		}
+		if _, err := dockerfile.NewDockerfile(response.Dockerfile); err != nil {
+			logger.Fatalf("The reviewed Dockerfile is invalid: %v", err)
+		}
+		response.Dockerignore, err = r.review(projectRelativePath(cwd, dockerignorePatchPath), run.InputDockerignore, response.Dockerignore, response.ActionsTaken)
+		if err != nil {
+			logger.Fatalf("Error reviewing changes: %v", err)
+		}
+		if response.Dockerfile == run.InputDockerfile && response.Dockerignore == run.InputDockerignore {
+			logger.Infof("\nNo changes were accepted.")
+			response.ActionsTaken = nil
+			changesRejected = true
+		}
+	}
+
+	if (verifyBuild || verifyOpts.smokeTest) && len(response.ActionsTaken) > 0 {
+		contextArgs := buildContextArgs(buildContexts)
+		original := &verify.Definition{Dockerfile: parsedDockerfile, Dockerignore: run.InputDockerignore, BuildContexts: contextArgs}
+		optimized := &verify.Definition{Dockerfile: response.Dockerfile, Dockerignore: response.Dockerignore, BuildContexts: contextArgs, Secrets: buildSecretArgs(response.BuildSecrets)}
+		var ok bool
+		run.VerifiedSizeBefore, run.VerifiedSizeAfter, ok = verifyChanges(ctx, logger, cwd, original, optimized, verifyOpts)
+		if !ok {
+			logger.Fatalf("\nThe optimized files were discarded and the original files were left untouched.")
 		}
+	}
 
-		// write Dockerfile to file
-		dockerfileOutputPath := filepath.Join(outputDir, "Dockerfile")
-		if err := os.WriteFile(dockerfileOutputPath, []byte(response.Dockerfile), os.ModePerm); err != nil {
-			logger.Fatalf("Error writing optimized Dockerfile: %v", err)
+	run.OutputDockerfile = response.Dockerfile
+	run.OutputDockerignore = response.Dockerignore
+	run.ActionsTaken = response.ActionsTaken
+	run.Recommendations = response.Recommendations
+	run.EstimatedSizeBefore = response.EstimatedSizeBefore
+	run.EstimatedSizeAfter = response.EstimatedSizeAfter
+	run.ScoreBefore, run.ScoreAfter = &response.ScoreBefore, &response.ScoreAfter
+	if changesRejected {
+		run.EstimatedSizeAfter = run.EstimatedSizeBefore
+		run.ScoreAfter = run.ScoreBefore
+	}
+	run.Commit = currentCommit(cwd)
+	savings, _ := run.Savings()
+	usageReport.AddSavings(savings)
+	// the history of a remote project would be removed along with its workspace, and the history of a Dockerfile
+	// read from stdin would lack the file it was about
+	if !remoteProject && !fromStdin {
+		if err := history.NewStore(cwd).Save(run); err != nil {
+			// history is a convenience, failing to record it must not fail the optimization
+			logger.Warnf("* Failed to record this run in history: %v", err)
+		} else {
+			logger.Infof("* Run recorded as %s", run.ID)
 		}
+	}
+	report := &sinks.Report{
+		RunID:           run.ID,
+		Command:         run.Command,
+		Timestamp:       run.Timestamp,
+		DockerfilePath:  dockerfilePath,
+		Owners:          dockerfileOwners(logger, cwd, cfg, dockerfilePath),
+		ActionsTaken:    response.ActionsTaken,
+		Recommendations: response.Recommendations,
+
+		EstimatedImageSize:          response.EstimatedSizeBefore,
+		EstimatedOptimizedImageSize: response.EstimatedSizeAfter,
+	}
+	if changesRejected {
+		report.EstimatedOptimizedImageSize = report.EstimatedImageSize
+	}
+	defer sendReport(logger, cfg, report)
+	result := addResult(output.FromReport(report))
+
+	if len(response.ActionsTaken) > 0 {
+		dockerfileRelPath := projectRelativePath(cwd, dockerfilePath)
+		dockerignoreRelPath := projectRelativePath(cwd, dockerignorePatchPath)
+
+		fmt.Printf("\n============ Changes ============\n")
+		printDiff(diff.Unified("a/"+dockerfileRelPath, "b/"+dockerfileRelPath, run.InputDockerfile, response.Dockerfile))
+		printDiff(diff.Unified("a/"+dockerignoreRelPath, "b/"+dockerignoreRelPath, run.InputDockerignore, response.Dockerignore))
 
-		// if Dockerignore exists, write it to file
-		if response.Dockerignore != "" {
-			dockerignoreOutputPath := filepath.Join(outputDir, ".dockerignore")
-			if err := os.WriteFile(dockerignoreOutputPath, []byte(response.Dockerignore), os.ModePerm); err != nil {
-				logger.Fatalf("Error writing optimized Dockerfile: %v", err)
+		report.Diff = diff.GitPatch(dockerfileRelPath, run.InputDockerfile, response.Dockerfile) +
+			diff.GitPatch(dockerignoreRelPath, run.InputDockerignore, response.Dockerignore)
+		if pullRequest != nil {
+			pullRequest.addFile(dockerfileRelPath, run.InputDockerfile, response.Dockerfile)
+			pullRequest.addFile(dockerignoreRelPath, run.InputDockerignore, response.Dockerignore)
+			pullRequest.reports = append(pullRequest.reports, report)
+		}
+		if fromStdin {
+			if response.Dockerignore != run.InputDockerignore {
+				logger.Warnf("\n* Only the Dockerfile is written to stdout, apply the changes to %s shown above yourself", dockerignoreRelPath)
+			}
+			result.AddModifiedFile(dockerfileRelPath, "", run.InputDockerfile, response.Dockerfile)
+			result.AddModifiedFile(dockerignoreRelPath, "", run.InputDockerignore, response.Dockerignore)
+		} else if patchFile != "" || pullRequest != nil {
+			if patchFile != "" {
+				if err := os.WriteFile(patchFile, []byte(report.Diff), 0o644); err != nil {
+					logger.Fatalf("Error writing patch file: %v", err)
+				}
+				logger.Infof("\nPatch saved to %s. Apply it with: git apply %s", patchFile, patchFile)
+			}
+			result.AddModifiedFile(dockerfileRelPath, "", run.InputDockerfile, response.Dockerfile)
+			result.AddModifiedFile(dockerignoreRelPath, "", run.InputDockerignore, response.Dockerignore)
+		} else {
+			if err := writeOptimizedFiles(response, "Dockerfile", ".dockerignore"); err != nil {
+				logger.Fatalf("%v", err)
 			}
+			result.AddModifiedFile(dockerfileRelPath, filepath.Join(outputDir, "Dockerfile"), run.InputDockerfile, response.Dockerfile)
+			result.AddModifiedFile(dockerignoreRelPath, filepath.Join(outputDir, ".dockerignore"), run.InputDockerignore, response.Dockerignore)
+			logger.Infof("\nOptimized file(s) saved to %s/", outputDir)
+		}
+
+	}
+	printOptimizationActions(logger, response, changesRejected)
+	if pullRequest != nil {
+		pullRequest.open(ctx, logger)
+	}
+	writePipelineOutput(response.Dockerfile)
+}
+
+// fetchRemoteProject fetches the project given as a git URL or with --context into a temporary workspace and makes
+// it the current directory, so that the paths of the project's flags, eg- --dockerfile, are relative to it.
+// --patch-file, --policy and --prompt-dir stay relative to the directory the command was run in.
+func fetchRemoteProject(ctx context.Context, logger *log.Logger, cmd *cobra.Command, cwd string, args []string) (*remote.Workspace, error) {
+	if len(args) > 0 && contextArchive != "" {
+		return nil, fmt.Errorf("pass either a git URL or --context, not both")
+	}
+	if interactive {
+		return nil, fmt.Errorf("--interactive can't be used with a remote project, review the patch instead")
+	}
+	if !cmd.Flags().Changed("patch-file") && !createPR {
+		patchFile = remotePatchFile
+	}
+	paths := []*string{&patchFile, &promptDir}
+	for i := range policyPaths {
+		paths = append(paths, &policyPaths[i])
+	}
+	for _, p := range paths {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(cwd, *p)
+		}
+	}
+
+	var workspace *remote.Workspace
+	var err error
+	if contextArchive != "" {
+		logger.Infof("* Extracting %s", contextArchive)
+		workspace, err = remote.ExtractArchive(contextArchive)
+	} else {
+		if !remote.IsGitURL(args[0]) {
+			return nil, fmt.Errorf("%s isn't the URL of a git repository, run dockershrink in the project's directory to optimize a local project", args[0])
 		}
+		logger.Infof("* Fetching %s", args[0])
+		workspace, err = remote.FetchGit(ctx, args[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chdir(workspace.Dir); err != nil {
+		workspace.Remove()
+		return nil, fmt.Errorf("failed to enter the workspace: %w", err)
+	}
+	return workspace, nil
+}
 
-		logger.Infof("Optimized file(s) saved to %s/", outputDir)
+// originalImageLibraries builds the original Dockerfile so that the shared libraries the application loads can be
+// listed with ldd when migrating its final stage. nil is returned if Docker isn't available or the build fails,
+// copying the libraries is left to the user then. The returned function deletes the image.
+func originalImageLibraries(ctx context.Context, logger *log.Logger, contextDir string, buildContexts []*buildcontext.Context) (distroless.LibraryResolver, func()) {
+	client, err := docker.NewClient()
+	if err == nil {
+		err = client.Ping(ctx)
+	}
+	if err != nil {
+		logger.Warnf("* The shared libraries of the application won't be checked: %v", err)
+		return nil, func() {}
+	}
+
+	logger.Infof("* Building the original image to find the shared libraries the application loads, this may take a while")
+	buildCtx, cancel := context.WithTimeout(ctx, verifyBuildTimeout)
+	defer cancel()
+	result, err := client.Build(buildCtx, &docker.BuildOptions{
+		ContextDir:    contextDir,
+		Dockerfile:    dockerfilePath,
+		BuildContexts: buildContextArgs(buildContexts),
+	})
+	if err == nil && result.Err != nil {
+		err = result.Err
+	}
+	if err != nil {
+		logger.Warnf("* The shared libraries of the application won't be checked, the original image failed to build: %v", err)
+		return nil, func() {}
+	}
+	return &distroless.ImageLibraries{Sandbox: sandbox.New(client), Image: result.ImageID}, func() {
+		client.RemoveImage(context.Background(), result.ImageID)
+	}
+}
 
+// printOptimizationActions prints the actions taken, the build secrets they need and the recommendations.
+// changesRejected is set if the user rejected every change, so that the image isn't reported as already optimized.
+func printOptimizationActions(logger *log.Logger, response *project.OptimizationResponse, changesRejected bool) {
+	if len(response.ActionsTaken) > 0 {
 		fmt.Printf("\n============ %d Action(s) Taken ============\n", len(response.ActionsTaken))
 		for _, action := range response.ActionsTaken {
 			color.Cyan("File: " + color.BlueString(action.Filepath))
 			color.Cyan("Title: " + color.GreenString(action.Title))
+			color.Cyan("Risk: " + color.YellowString(string(action.Risk)))
 			color.Cyan("Description: " + color.WhiteString(action.Description))
+			if explainChanges {
+				printExplanation(action.Explanation, false)
+			}
 			fmt.Println("---------------------------------")
 		}
 	}
 
+	if len(response.ActionsTaken) > 0 && len(response.BuildSecrets) > 0 {
+		printBuildSecrets(response.BuildSecrets)
+	}
+
 	if len(response.Recommendations) > 0 {
 		fmt.Printf("\n\n============ %d Recommendation(s) ============\n", len(response.Recommendations))
 		for _, rec := range response.Recommendations {
 			color.Cyan("File: " + color.BlueString(rec.Filepath))
 			color.Cyan("Title: " + color.GreenString(rec.Title))
+			color.Cyan("Risk: " + color.YellowString(string(rec.Risk)))
 			color.Cyan("Description: " + color.WhiteString(rec.Description))
+			if explainChanges {
+				printExplanation(rec.Explanation, true)
+			}
 			fmt.Println("---------------------------------")
 		}
 	}
 
-	if len(response.ActionsTaken) == 0 && len(response.Recommendations) == 0 {
+	if len(response.ActionsTaken) == 0 && len(response.Recommendations) == 0 && !changesRejected {
 		logger.Infof("Docker image is already optimized, no further actions were taken.")
 	}
+	printPolicyViolations(logger, response.PolicyViolations)
+}
+
+// printExplanation prints why an action was taken, what it saves and where to read more about it.
+// The sizes of recommendations are what they would save if they were applied.
+func printExplanation(e *models.Explanation, recommended bool) {
+	if e == nil {
+		return
+	}
+	if e.Principle != "" {
+		color.Cyan("Principle: " + color.WhiteString(e.Principle))
+	}
+	if e.Rationale != "" {
+		color.Cyan("Why: " + color.WhiteString(e.Rationale))
+	}
+	if e.EstimatedSizeImpact != 0 {
+		verb, size := "adds", e.EstimatedSizeImpact
+		if size < 0 {
+			verb, size = "saves", -size
+		}
+		if recommended {
+			verb = "would " + strings.TrimSuffix(verb, "s")
+		}
+		color.Cyan("Estimated size impact: " + color.WhiteString("%s %s", verb, units.FormatBytes(size)))
+	}
+	if e.BuildTimeImpact != "" {
+		color.Cyan("Build time: " + color.WhiteString(e.BuildTimeImpact))
+	}
+	for _, title := range e.Docs {
+		if p := docs.Lookup(title); p != nil {
+			color.Cyan("Read more: " + color.GreenString(p.Title))
+			fmt.Println("  " + strings.ReplaceAll(p.Text, "\n", "\n  "))
+		}
+	}
+	for _, link := range e.Links {
+		color.Cyan("Docs: " + color.BlueString(link))
+	}
+}
+
+// validateOptimizeTargets returns an error if --recursive or --target is combined with flags that only make sense for a single Dockerfile
+func validateOptimizeTargets(cmd *cobra.Command) error {
+	switch {
+	case interactive:
+		return fmt.Errorf("--interactive can't be used with --recursive or --target, review the changes of one Dockerfile at a time")
+	case verifyBuild, verifyBoot, verifyRun != "", verifyWithDeps:
+		return fmt.Errorf("--verify-build, --verify-boot, --verify-run and --verify-with-deps can't be used with --recursive or --target, verify one Dockerfile at a time")
+	case cmd.Flags().Changed("dockerfile"), cmd.Flags().Changed("dockerignore"), composeService != "":
+		return fmt.Errorf("--dockerfile, --dockerignore and --compose-service name a single Dockerfile, use --target to optimize several")
+	}
+	return nil
+}
+
+// optimizedTarget is the outcome of optimizing one of the Dockerfiles of a run over many of them
+type optimizedTarget struct {
+	run      *history.Run
+	response *project.OptimizationResponse
+	// skipped explains why the Dockerfile wasn't optimized, eg- it's only used in CI
+	skipped string
+}
+
+// runOptimizeTargets optimizes every Dockerfile found with --recursive or given with --target, up to --jobs at once.
+// The optimized files are written to the output directory at the same paths as the originals,
+// or all the changes are written to a single patch with --patch-file.
+func runOptimizeTargets(ctx context.Context, logger *log.Logger, cfg *config.Config, aiService *ai.AIService, opts *project.OptimizeOptions) {
+	if aiService != nil {
+		attachDocs(logger, aiService, cfg)
+	}
+	platformTargets, err := targetPlatforms(logger, cfg)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	opts.Platforms = platformTargets
+	if pinImages {
+		opts.PinResolver = registry.NewClient()
+	}
+
+	patch := ""
+	partial := runRecursive(logger, func(t *targets.Target, cfg *config.Config, root string) (func(), error) {
+		o, err := optimizeTarget(ctx, logger, aiService, cfg, root, t, *opts)
+		if err != nil {
+			return nil, err
+		}
+		return func() {
+			patch += reportOptimizedTarget(logger, cfg, root, t, o)
+		}, nil
+	})
+
+	if patchFile != "" && patch != "" {
+		if err := os.WriteFile(patchFile, []byte(patch), 0o644); err != nil {
+			logger.Fatalf("Error writing patch file: %v", err)
+		}
+		logger.Infof("\nPatch saved to %s. Apply it with: git apply %s", patchFile, patchFile)
+	}
+	if pullRequest != nil {
+		pullRequest.open(ctx, logger)
+	}
+	if partial {
+		exit(exitPartialFailure)
+	}
+}
+
+// optimizeTarget optimizes a single Dockerfile of a run over many of them, without printing the results.
+// It's safe to run concurrently with other targets.
+func optimizeTarget(ctx context.Context, logger *log.Logger, aiService *ai.AIService, cfg *config.Config, root string, t *targets.Target, opts project.OptimizeOptions) (*optimizedTarget, error) {
+	if rel := projectRelativePath(root, t.Dockerfile); !filepath.IsLocal(filepath.FromSlash(rel)) {
+		return nil, fmt.Errorf("%s is outside the current directory, run dockershrink from a directory containing it", t.Dockerfile)
+	}
+	dir, err := filepath.Abs(t.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("Error resolving project directory: %w", err)
+	}
+
+	class := classifyDockerfile(root, t.Dockerfile, cfg)
+	if class.Kind == classification.KindCI {
+		switch cfg.CIDockerfiles {
+		case config.CIDockerfilesSkip:
+			return &optimizedTarget{
+				skipped: fmt.Sprintf("Skipping CI-only Dockerfile (%s: %s). Set \"ci_dockerfiles\" in %s to change this.", class.Source, class.Reason, config.Filename),
+			}, nil
+		case config.CIDockerfilesRelaxed:
+			opts.Goal = models.GoalBuildSpeed
+		}
+	}
+
+	content, err := os.ReadFile(t.Dockerfile)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %w", t.Dockerfile, err)
+	}
+	dockerfileObject, syntaxActions, err := loadDockerfileForOptimization(ctx, logger, aiService, t.Dockerfile, string(content))
+	if err != nil {
+		return nil, err
+	}
+	dockerignoreContent, err := os.ReadFile(t.Dockerignore)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	// the ignore patterns of the configuration are relative to the current directory, not to the project of the Dockerfile
+	ignored := func(p string) bool {
+		return cfg.Ignored(projectRelativePath(root, filepath.Join(dir, filepath.FromSlash(p))))
+	}
+	proj, _, err := newTargetProject(logger, t, dockerfileObject, &coldLoader{ctx: ctx, logger: logger}, ignored, true)
+	if err != nil {
+		return nil, err
+	}
+	proj.SetEvents(logEvents(logger))
+	policies, err := loadPolicies(cfg)
+	if err != nil {
+		return nil, err
+	}
+	proj.SetPolicies(policies)
+
+	response, err := proj.OptimizeDockerImage(ctx, aiService, &opts)
+	if err != nil {
+		return nil, fmt.Errorf("Error optimizing Docker image (use --debug to get more info): %w", err)
+	}
+	response.ActionsTaken = append(syntaxActions, response.ActionsTaken...)
+	explain.Annotate(syntaxActions)
+	return &optimizedTarget{
+		response: response,
+		run: &history.Run{
+			Command:            "optimize",
+			DockerfilePath:     t.Dockerfile,
+			InputDockerfile:    string(content),
+			InputDockerignore:  string(dockerignoreContent),
+			OutputDockerfile:   response.Dockerfile,
+			OutputDockerignore: response.Dockerignore,
+			ActionsTaken:       response.ActionsTaken,
+			Recommendations:    response.Recommendations,
+
+			EstimatedSizeBefore: response.EstimatedSizeBefore,
+			EstimatedSizeAfter:  response.EstimatedSizeAfter,
+			ScoreBefore:         &response.ScoreBefore,
+			ScoreAfter:          &response.ScoreAfter,
+		},
+	}, nil
+}
+
+// reportOptimizedTarget prints the changes made to a single Dockerfile of a run over many of them, records them
+// and writes the optimized files, unless --patch-file or --create-pr is set. It returns the changes as a patch.
+func reportOptimizedTarget(logger *log.Logger, cfg *config.Config, root string, t *targets.Target, o *optimizedTarget) string {
+	if o.skipped != "" {
+		logger.Infof("%s", o.skipped)
+		return ""
+	}
+	run, response := o.run, o.response
+
+	run.Commit = currentCommit(root)
+	savings, _ := run.Savings()
+	usageReport.AddSavings(savings)
+	if err := history.NewStore(root).Save(run); err != nil {
+		// history is a convenience, failing to record it must not fail the optimization
+		logger.Warnf("* Failed to record this run in history: %v", err)
+	} else {
+		logger.Infof("* Run recorded as %s", run.ID)
+	}
+	report := &sinks.Report{
+		RunID:           run.ID,
+		Command:         run.Command,
+		Timestamp:       run.Timestamp,
+		DockerfilePath:  t.Dockerfile,
+		Owners:          dockerfileOwners(logger, root, cfg, t.Dockerfile),
+		ActionsTaken:    response.ActionsTaken,
+		Recommendations: response.Recommendations,
+
+		EstimatedImageSize:          response.EstimatedSizeBefore,
+		EstimatedOptimizedImageSize: response.EstimatedSizeAfter,
+	}
+	defer sendReport(logger, cfg, report)
+	result := addResult(output.FromReport(report))
+
+	if len(response.ActionsTaken) > 0 {
+		dockerfileRelPath := projectRelativePath(root, t.Dockerfile)
+		dockerignoreRelPath := projectRelativePath(root, t.Dockerignore)
+
+		fmt.Printf("\n============ Changes ============\n")
+		printDiff(diff.Unified("a/"+dockerfileRelPath, "b/"+dockerfileRelPath, run.InputDockerfile, response.Dockerfile))
+		printDiff(diff.Unified("a/"+dockerignoreRelPath, "b/"+dockerignoreRelPath, run.InputDockerignore, response.Dockerignore))
+
+		report.Diff = diff.GitPatch(dockerfileRelPath, run.InputDockerfile, response.Dockerfile) +
+			diff.GitPatch(dockerignoreRelPath, run.InputDockerignore, response.Dockerignore)
+		if pullRequest != nil {
+			pullRequest.addFile(dockerfileRelPath, run.InputDockerfile, response.Dockerfile)
+			pullRequest.addFile(dockerignoreRelPath, run.InputDockerignore, response.Dockerignore)
+			pullRequest.reports = append(pullRequest.reports, report)
+		}
+		if patchFile != "" || pullRequest != nil {
+			result.AddModifiedFile(dockerfileRelPath, "", run.InputDockerfile, response.Dockerfile)
+			result.AddModifiedFile(dockerignoreRelPath, "", run.InputDockerignore, response.Dockerignore)
+		} else {
+			if err := writeOptimizedFiles(response, filepath.FromSlash(dockerfileRelPath), filepath.FromSlash(dockerignoreRelPath)); err != nil {
+				logger.Fatalf("%v", err)
+			}
+			result.AddModifiedFile(dockerfileRelPath, filepath.Join(outputDir, filepath.FromSlash(dockerfileRelPath)), run.InputDockerfile, response.Dockerfile)
+			result.AddModifiedFile(dockerignoreRelPath, filepath.Join(outputDir, filepath.FromSlash(dockerignoreRelPath)), run.InputDockerignore, response.Dockerignore)
+			logger.Infof("\nOptimized file(s) saved to %s/", filepath.Join(outputDir, filepath.Dir(filepath.FromSlash(dockerfileRelPath))))
+		}
+	}
+	printOptimizationActions(logger, response, false)
+	return report.Diff
+}
+
+// loadDockerfileForOptimization parses the Dockerfile to optimize. Syntax errors are fixed if possible,
+// and the fixes are returned as actions so that they show up in the changes.
+// With --repair-syntax, errors that can't be fixed automatically are corrected by the LLM.
+func loadDockerfileForOptimization(ctx context.Context, logger *log.Logger, aiService *ai.AIService, path, content string) (*dockerfile.Dockerfile, []*models.OptimizationAction, error) {
+	df, fixes, err := parseDockerfile(logger, content)
+	if err == nil {
+		var actions []*models.OptimizationAction
+		for _, fix := range fixes {
+			actions = append(actions, &models.OptimizationAction{
+				Rule:        "syntax-recovery",
+				Risk:        models.RiskCosmetic,
+				Filepath:    path,
+				Title:       "Fixed Dockerfile syntax",
+				Description: fix + ", the Dockerfile could not be parsed otherwise.",
+			})
+		}
+		return df, actions, nil
+	}
+
+	var syntaxErrs dockerfile.SyntaxErrors
+	if !repairSyntax || !errors.As(err, &syntaxErrs) {
+		return nil, nil, syntaxError(path, err)
+	}
+	logger.Warnf("%v", syntaxError(path, err))
+	logger.Infof("* Asking the LLM to repair the syntax of %s", path)
+
+	req := &ai.RepairRequest{Dockerfile: content}
+	for _, e := range syntaxErrs {
+		req.Errors = append(req.Errors, e.Error())
+	}
+	repaired, err := aiService.RepairDockerfile(ctx, req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error repairing the syntax of %s: %w", path, err)
+	}
+	df, err = dockerfile.NewDockerfile(repaired.Dockerfile)
+	if err != nil {
+		return nil, nil, syntaxError(path, err)
+	}
+	return df, []*models.OptimizationAction{{
+		Rule:        "syntax-repair",
+		Risk:        models.RiskBehavior,
+		Filepath:    path,
+		Title:       "Repaired Dockerfile syntax",
+		Description: repaired.Explanation + " This syntax repair was made by the LLM, review it carefully.",
+	}}, nil
+}
+
+// writeOptimizedFiles saves the optimized Dockerfile and .dockerignore in the output directory, at the given paths relative to it.
+// The files they replace are backed up, eg- the original Dockerfile with --output-dir ".".
+func writeOptimizedFiles(response *project.OptimizationResponse, dockerfileName, dockerignoreName string) error {
+	dockerfileOutputPath := filepath.Join(outputDir, dockerfileName)
+	if err := os.MkdirAll(filepath.Dir(dockerfileOutputPath), os.ModePerm); err != nil {
+		return fmt.Errorf("Error creating output directory: %w", err)
+	}
+	if err := writeProjectFile("optimize", dockerfileOutputPath, []byte(response.Dockerfile), os.ModePerm); err != nil {
+		return fmt.Errorf("Error writing optimized Dockerfile: %w", err)
+	}
+
+	// if Dockerignore exists, write it to file
+	if response.Dockerignore != "" {
+		dockerignoreOutputPath := filepath.Join(outputDir, dockerignoreName)
+		if err := os.MkdirAll(filepath.Dir(dockerignoreOutputPath), os.ModePerm); err != nil {
+			return fmt.Errorf("Error creating output directory: %w", err)
+		}
+		if err := writeProjectFile("optimize", dockerignoreOutputPath, []byte(response.Dockerignore), os.ModePerm); err != nil {
+			return fmt.Errorf("Error writing optimized .dockerignore: %w", err)
+		}
+	}
+	return nil
 }
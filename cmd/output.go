@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/config"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/output"
+	"github.com/duaraghav8/dockershrink/internal/project"
+	"github.com/duaraghav8/dockershrink/internal/targets"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var outputFormat string
+
+var (
+	// document collects the outcome of the command with --output json or sarif, it's nil otherwise
+	document *output.Document
+	// documentOut is the original stdout. With a document, everything else is written to stderr so that stdout only contains the document.
+	documentOut io.Writer
+)
+
+// setupOutput prepares the output of the command according to --output
+func setupOutput(cmd *cobra.Command, args []string) error {
+	if err := output.ValidateFormat(outputFormat); err != nil {
+		return err
+	}
+	if err := validateReportFormat(); err != nil {
+		return err
+	}
+	if err := validateCIFormat(); err != nil {
+		return err
+	}
+	if outputFormat == output.FormatText {
+		return nil
+	}
+
+	documentOut = os.Stdout
+	os.Stdout = os.Stderr
+	color.Output = os.Stderr
+	document = output.New(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" "), Version)
+	log.OnFatal(func(msg string) {
+		writeDocument(1, msg)
+	})
+	return nil
+}
+
+// addResult adds the outcome for a Dockerfile to the JSON document, if there is one.
+// The result is returned so that the command can keep filling it in.
+func addResult(r *output.Result) *output.Result {
+	if document == nil {
+		return r
+	}
+	return document.Add(r)
+}
+
+// setOutputData sets the output of a command that doesn't work on Dockerfiles in the JSON document, if there is one
+func setOutputData(data any) {
+	if document != nil {
+		document.Data = data
+	}
+}
+
+// writeDocument writes the document to stdout in the output format, if there is one. It's only written once.
+func writeDocument(exitCode int, errMsg string) {
+	if document == nil {
+		return
+	}
+	document.ExitCode = exitCode
+	document.Error = errMsg
+	write := document.Write
+	if outputFormat == output.FormatSARIF {
+		write = document.WriteSARIF
+	}
+	if err := write(documentOut); err != nil {
+		color.Red("Error writing output: %v", err)
+	}
+	document = nil
+}
+
+// addFixes adds the changes that the optimizations which don't need the LLM make to a Dockerfile and its .dockerignore to its result.
+// They're only computed for SARIF, which reports them as the fixes of the findings.
+func addFixes(ctx context.Context, logger *log.Logger, cfg *config.Config, t *targets.Target, r *output.Result) {
+	if outputFormat != output.FormatSARIF {
+		return
+	}
+	if err := suggestFixes(ctx, logger, cfg, t, r); err != nil {
+		logger.Warnf("* Failed to compute fixes for %s: %v", t.Dockerfile, err)
+	}
+}
+
+func suggestFixes(ctx context.Context, logger *log.Logger, cfg *config.Config, t *targets.Target, r *output.Result) error {
+	fixGoal, err := models.ParseGoal(goal)
+	if err != nil {
+		return err
+	}
+	platformTargets, err := targetPlatforms(logger, cfg)
+	if err != nil {
+		return err
+	}
+	// the project is modified while optimizing, so it's loaded again instead of reusing the analyzed one
+	proj, dockerfileObject, err := loadTarget(logger, t, &coldLoader{ctx: ctx, logger: logger})
+	if err != nil {
+		return err
+	}
+	originalDockerfile := dockerfileObject.Raw()
+	originalDockerignore, err := os.ReadFile(t.Dockerignore)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	response, err := proj.OptimizeDockerImage(ctx, nil, &project.OptimizeOptions{Goal: fixGoal, Platforms: platformTargets})
+	if err != nil {
+		return err
+	}
+	r.Fixes = append(r.Fixes,
+		&output.Fix{Path: t.Dockerfile, Original: originalDockerfile, Fixed: response.Dockerfile},
+		&output.Fix{Path: t.Dockerignore, Original: string(originalDockerignore), Fixed: response.Dockerignore},
+	)
+	return nil
+}
+
+// exit writes the reports and the JSON document, if there are any, and exits with the given status
+func exit(code int) {
+	writeReports()
+	writeDocument(code, "")
+	var err error
+	if code != 0 {
+		err = fmt.Errorf("exit status %d", code)
+	}
+	shutdownTelemetry(err)
+	os.Exit(code)
+}
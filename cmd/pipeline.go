@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/duaraghav8/dockershrink/internal/output"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// stdinArg is the argument of optimize that reads the Dockerfile from stdin and writes the optimized one to stdout
+const stdinArg = "-"
+
+var (
+	noContext bool
+
+	// pipelineOut is the original stdout when the Dockerfile is read from stdin. Everything else is written to stderr
+	// so that stdout only contains the optimized Dockerfile.
+	pipelineOut io.Writer
+)
+
+func addPipelineFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&noContext, "no-context", false, "With \"-\", optimize the Dockerfile on its own instead of using the current directory as its build context")
+}
+
+// setupPipeline prepares optimize to read the Dockerfile from stdin and write the optimized one to stdout,
+// returning an error if a flag needs the Dockerfile to be a file or writes to stdout itself
+func setupPipeline(cmd *cobra.Command) error {
+	switch {
+	case contextArchive != "":
+		return fmt.Errorf("\"-\" can't be used with --context, the project is the current directory")
+	case interactive:
+		return fmt.Errorf("--interactive can't be used with \"-\", stdin is the Dockerfile")
+	case multipleTargets():
+		return fmt.Errorf("--recursive and --target can't be used with \"-\", only a single Dockerfile is read from stdin")
+	case cmd.Flags().Changed("patch-file"), createPR:
+		return fmt.Errorf("--patch-file and --create-pr can't be used with \"-\", the optimized Dockerfile is written to stdout")
+	case outputFormat != output.FormatText:
+		return fmt.Errorf("--output %s can't be used with \"-\", stdout is the optimized Dockerfile", outputFormat)
+	}
+
+	pipelineOut = os.Stdout
+	os.Stdout = os.Stderr
+	color.Output = os.Stderr
+	return nil
+}
+
+// enterEmptyContext makes an empty temporary directory the current directory, so that a Dockerfile optimized with
+// --no-context is optimized as if its build context had no files. The returned function goes back to dir and removes it.
+func enterEmptyContext(dir string) (string, func(), error) {
+	empty, err := os.MkdirTemp("", "dockershrink-context-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create an empty build context: %w", err)
+	}
+	if err := os.Chdir(empty); err != nil {
+		os.RemoveAll(empty)
+		return "", nil, fmt.Errorf("failed to enter the empty build context: %w", err)
+	}
+	return empty, func() {
+		os.Chdir(dir)
+		os.RemoveAll(empty)
+	}, nil
+}
+
+// writePipelineOutput writes the Dockerfile to stdout, if it was read from stdin
+func writePipelineOutput(dockerfile string) {
+	if pipelineOut == nil {
+		return
+	}
+	if _, err := io.WriteString(pipelineOut, dockerfile); err != nil {
+		color.Red("Error writing the optimized Dockerfile: %v", err)
+		os.Exit(1)
+	}
+}
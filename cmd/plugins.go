@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/plugin"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// max time allowed for the handshake with a single plugin
+const pluginHandshakeTimeout = 10 * time.Second
+
+var pluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "Manages the plugins dockershrink is configured with",
+}
+
+var pluginsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the configured plugins and checks that they're compatible with this version of dockershrink",
+	Long: `Makes the handshake with every plugin configured in .dockershrink.yaml and prints what it implements.
+A plugin is compatible if it speaks a version of the plugin protocol this version of dockershrink supports, and implements a supported version of the surface it's configured for, eg- a report sink or rules.
+Exits with status 1 if a plugin can't be used.`,
+	Args: cobra.NoArgs,
+	Run:  runPluginsList,
+}
+
+func init() {
+	pluginsCmd.AddCommand(pluginsListCmd)
+	rootCmd.AddCommand(pluginsCmd)
+}
+
+// pluginStatus is the outcome of the handshake with a plugin, it's the output of the command in JSON
+type pluginStatus struct {
+	Command  []string         `json:"command"`
+	Surface  plugin.Kind      `json:"surface"`
+	Manifest *plugin.Manifest `json:"manifest,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+func runPluginsList(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		logger.Fatalf("Error getting current working directory: %v", err)
+	}
+	cfg, err := loadConfig(cwd)
+	if err != nil {
+		logger.Fatalf("Error loading configuration: %v", err)
+	}
+
+	statuses := []*pluginStatus{}
+	for _, r := range cfg.Reports {
+		if r.Type == "plugin" {
+			statuses = append(statuses, &pluginStatus{Command: r.Command, Surface: plugin.KindSink})
+		}
+	}
+	for _, r := range cfg.Lint.Plugins {
+		statuses = append(statuses, &pluginStatus{Command: r.Command, Surface: plugin.KindRule})
+	}
+	setOutputData(statuses)
+	if len(statuses) == 0 {
+		fmt.Printf("No plugins are configured in %s.\n", cwd)
+		return
+	}
+
+	color.Cyan("Plugin protocol: " + color.WhiteString(plugin.Range{Min: plugin.MinProtocolVersion, Max: plugin.ProtocolVersion}.String()))
+	failed := false
+	for _, s := range statuses {
+		ctx, cancel := context.WithTimeout(cmd.Context(), pluginHandshakeTimeout)
+		p, err := plugin.Start(ctx, s.Command)
+		cancel()
+		if err == nil {
+			s.Manifest = p.Manifest
+			err = p.Manifest.Check(s.Surface)
+		}
+
+		fmt.Println("---------------------------------")
+		color.Cyan("Command: " + color.BlueString(strings.Join(s.Command, " ")))
+		color.Cyan("Used as: " + color.WhiteString(string(s.Surface)))
+		if s.Manifest != nil {
+			color.Cyan("Plugin: " + color.WhiteString("%s %s (protocol %d)", s.Manifest.Name, s.Manifest.Version, s.Manifest.ProtocolVersion))
+			capabilities := []string{}
+			for _, c := range s.Manifest.Capabilities {
+				capabilities = append(capabilities, fmt.Sprintf("%s v%d", c.Kind, c.Version))
+			}
+			color.Cyan("Implements: " + color.WhiteString(strings.Join(capabilities, ", ")))
+		}
+		if err != nil {
+			failed = true
+			s.Error = err.Error()
+			color.Cyan("Status: " + color.RedString("INCOMPATIBLE  ") + err.Error())
+			continue
+		}
+		color.Cyan("Status: " + color.GreenString("OK"))
+	}
+
+	if failed {
+		exit(1)
+	}
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/duaraghav8/dockershrink/internal/config"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/policy"
+	"github.com/duaraghav8/dockershrink/internal/project"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// exitViolations is the exit status of lint when it finds problems, and of analyze and lint when an analysis is
+// rejected by --fail-on or --min-score, or violates the policies of the organization.
+// It's distinct from the status 1 of errors, so that CI can tell a Dockerfile that must be improved from a failing tool.
+const exitViolations = 3
+
+var (
+	failOn   string
+	minScore int
+	// policyPaths are the organization policies given with --policy, on top of those of the configuration
+	policyPaths []string
+	// policyBundles are the OPA bundles given with --policy-bundle, whose Rego policies are evaluated with the OPA Go SDK
+	policyBundles []string
+)
+
+func addPolicyFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&failOn, "fail-on", "", fmt.Sprintf("Exit with status %d if there are findings of this severity or a higher one: high, medium, low or info", exitViolations))
+	cmd.Flags().IntVar(&minScore, "min-score", 0, fmt.Sprintf("Exit with status %d if the score of a Dockerfile is below this", exitViolations))
+}
+
+func addOrgPolicyFlag(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&policyPaths, "policy", nil, "Organization policy the Dockerfiles must comply with, eg- approved base images. Can be repeated, and adds to the policies of the configuration")
+}
+
+func addPolicyBundleFlag(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&policyBundles, "policy-bundle", nil, "OPA bundle, a directory or .tar.gz file, whose Rego policies the Dockerfiles are evaluated against. Can be repeated")
+}
+
+// loadPolicies loads the organization policies of the configuration and of --policy, leaving out duplicates
+func loadPolicies(cfg *config.Config) ([]*policy.Policy, error) {
+	policies := []*policy.Policy{}
+	seen := map[string]bool{}
+	for _, path := range append(append([]string{}, cfg.Policies...), policyPaths...) {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		p, err := policy.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+// printPolicyViolations warns about the organization policies an optimized or generated Dockerfile still violates
+func printPolicyViolations(logger *log.Logger, violations []*models.Finding) {
+	if len(violations) == 0 {
+		return
+	}
+	logger.Warnf("\n* The Dockerfile violates %d requirement(s) of your organization's policies, fix them before using it:", len(violations))
+	for _, f := range violations {
+		color.Red("  - %s (%s)", f.Title, f.Code)
+	}
+}
+
+// policyFromFlags returns the policy given by --fail-on and --min-score
+func policyFromFlags(logger *log.Logger) *rules.Policy {
+	p, err := rules.ParsePolicy(failOn, minScore)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	return p
+}
+
+// checkPolicy prints why the analysis of a Dockerfile is rejected by the policy or by the policies of the organization,
+// and returns true if it is
+func checkPolicy(p *rules.Policy, dockerfile string, analysis *project.AnalysisResponse) bool {
+	violations := p.Violations(analysis.Score, analysis.Findings)
+	count := 0
+	for _, f := range analysis.Findings {
+		if rules.IsPolicyViolation(f) {
+			count++
+		}
+	}
+	if count > 0 {
+		violations = append(violations, fmt.Sprintf("%d finding(s) violate the policies of your organization", count))
+	}
+	for _, v := range violations {
+		color.Red("%s: %s", dockerfile, v)
+	}
+	return len(violations) > 0
+}
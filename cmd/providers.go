@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/ai"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/providers"
+	"github.com/fatih/color"
+	"github.com/openai/openai-go"
+	"github.com/spf13/cobra"
+)
+
+// max time allowed for testing a single provider
+const providerTestTimeout = 30 * time.Second
+
+var listModels bool
+
+// providerRoleNames are the names of the roles printed in the results
+var providerRoleNames = map[string]string{
+	providers.RoleLLM:        "LLM",
+	providers.RoleEmbeddings: "Embeddings",
+}
+
+var providersCmd = &cobra.Command{
+	Use:   "providers",
+	Short: "Manages the AI providers used by dockershrink",
+}
+
+var providersTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Checks that the configured AI providers can be used",
+	Long: `Tests every AI provider dockershrink is configured with: the LLM that optimizes and generates Dockerfiles, and the embeddings provider configured in .dockershrink.yaml to search the documentation.
+Credentials are verified, the round-trip latency of a tiny request is measured and the capabilities dockershrink relies on, like structured outputs and tool calling, are checked.
+Exits with status 1 if a configured provider can't be used.`,
+	Run: runProvidersTest,
+}
+
+func init() {
+	providersTestCmd.Flags().BoolVar(&listModels, "list-models", false, "List all the models available with the LLM's credentials")
+
+	providersCmd.AddCommand(providersTestCmd)
+	rootCmd.AddCommand(providersCmd)
+}
+
+func runProvidersTest(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		logger.Fatalf("Error getting current working directory: %v", err)
+	}
+	cfg, err := loadConfig(cwd)
+	if err != nil {
+		logger.Fatalf("Error loading configuration: %v", err)
+	}
+
+	logger.Infof("* Testing LLM provider")
+	ctx, cancel := context.WithTimeout(cmd.Context(), providerTestTimeout)
+	model := ai.OpenAIPreferredModel
+	if cfg.LLM.Model != "" {
+		model = openai.ChatModel(cfg.LLM.Model)
+	}
+	llm := providers.TestLLM(ctx, getOpenAIClient(cfg), model)
+	cancel()
+
+	logger.Infof("* Testing embeddings provider")
+	var embeddings *providers.Result
+	embedder, err := getEmbedder(cfg)
+	if err != nil {
+		embeddings = &providers.Result{Role: providers.RoleEmbeddings, Provider: cfg.Embeddings.Provider, Err: err}
+	} else {
+		ctx, cancel := context.WithTimeout(cmd.Context(), providerTestTimeout)
+		embeddings = providers.TestEmbeddings(ctx, cfg.Embeddings.Provider, embedder)
+		cancel()
+	}
+
+	fmt.Printf("\n============ Providers ============\n")
+	failed := false
+	for _, r := range []*providers.Result{llm, embeddings} {
+		label := fmt.Sprintf("%-12s", providerRoleNames[r.Role])
+		switch {
+		case r.OK():
+			fmt.Println(label + color.GreenString("OK    ") + r.Summary())
+		case errors.Is(r.Err, providers.ErrNotConfigured):
+			fmt.Println(label + color.YellowString("SKIP  ") + r.Summary())
+		default:
+			failed = true
+			fmt.Println(label + color.RedString("FAIL  ") + r.Summary())
+		}
+	}
+
+	if llm.Models != nil {
+		if listModels {
+			fmt.Printf("\n============ Available Models ============\n")
+			for _, m := range llm.Models {
+				fmt.Println(m)
+			}
+		} else {
+			fmt.Printf("\n%d models are available with the LLM's credentials, list them with --list-models.\n", len(llm.Models))
+		}
+	}
+
+	if failed {
+		exit(1)
+	}
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/ci"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/sinks"
+	"github.com/spf13/cobra"
+)
+
+var (
+	createPR bool
+	prBase   string
+
+	// pullRequest is where the pull request of --create-pr is opened, set once the repository is known
+	pullRequest *pullRequestTarget
+)
+
+// pullRequestTarget collects the optimized files of every Dockerfile, to commit them together in a single pull request
+type pullRequestTarget struct {
+	creator ci.PullRequestCreator
+	// prefix is the path of the current directory in the repository, eg- "services/api/"
+	prefix  string
+	base    string
+	files   []*ci.FileChange
+	reports []*sinks.Report
+}
+
+func addPullRequestFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&createPR, "create-pr", false, "Commit the optimized files to a new branch and open a pull request, or a GitLab merge request, with the report as its description. Needs GITHUB_TOKEN or GITLAB_TOKEN")
+	cmd.Flags().StringVar(&prBase, "pr-base", "", "Branch the pull request of --create-pr is merged into (default: the branch checked out, or the default branch of the repository)")
+}
+
+// preparePullRequest finds the repository of the project in dir to open the pull request of --create-pr in, before
+// anything is optimized. remoteURL and ref are the git URL the project was fetched from, if it was.
+func preparePullRequest(dir, remoteURL, ref string) error {
+	prefix, base := "", ref
+	if remoteURL == "" {
+		origin, err := gitOutput(dir, "remote", "get-url", "origin")
+		if err != nil {
+			return fmt.Errorf("--create-pr needs a git repository with an origin remote: %w", err)
+		}
+		remoteURL = origin
+		if prefix, err = gitOutput(dir, "rev-parse", "--show-prefix"); err != nil {
+			return err
+		}
+		// the changes are based on the branch checked out, unless it's detached
+		base, _ = gitOutput(dir, "symbolic-ref", "--quiet", "--short", "HEAD")
+	}
+	if prBase != "" {
+		base = prBase
+	}
+
+	repo, err := ci.ParseRepositoryURL(remoteURL)
+	if err != nil {
+		return err
+	}
+	var creator ci.PullRequestCreator
+	switch repo.Provider() {
+	case ci.ProviderGitHub:
+		token := os.Getenv("GITHUB_TOKEN")
+		if token == "" {
+			return fmt.Errorf("--create-pr needs a GitHub token in GITHUB_TOKEN")
+		}
+		log.AddSecret(token)
+		creator = ci.NewGitHub(repo.APIURL(), repo.Path, token)
+	case ci.ProviderGitLab:
+		token := os.Getenv("GITLAB_TOKEN")
+		if token == "" {
+			return fmt.Errorf("--create-pr needs a GitLab token in GITLAB_TOKEN")
+		}
+		log.AddSecret(token)
+		creator = ci.NewGitLab(repo.APIURL(), repo.Path, token)
+	default:
+		return fmt.Errorf("--create-pr only works with repositories on GitHub and GitLab, %s is hosted on %s", repo.Path, repo.Host)
+	}
+	pullRequest = &pullRequestTarget{creator: creator, prefix: prefix, base: base}
+	return nil
+}
+
+// addFile adds a file to the pull request, if its content changed. Its path is relative to the current directory.
+func (p *pullRequestTarget) addFile(relPath, before, after string) {
+	if before == after {
+		return
+	}
+	p.files = append(p.files, &ci.FileChange{Path: path.Join(p.prefix, relPath), Content: after, New: before == ""})
+}
+
+// open opens the pull request with the files of every Dockerfile and their reports as its description
+func (p *pullRequestTarget) open(ctx context.Context, logger *log.Logger) {
+	if len(p.files) == 0 {
+		logger.Infof("\nNothing was changed, so no pull request was opened")
+		return
+	}
+
+	title := "Optimize the Docker image"
+	if len(p.reports) == 1 {
+		title = fmt.Sprintf("Optimize %s", p.reports[0].DockerfilePath)
+	} else if len(p.reports) > 1 {
+		title = fmt.Sprintf("Optimize %d Dockerfiles", len(p.reports))
+	}
+	rendered := []string{}
+	for _, r := range p.reports {
+		content, err := sinks.Render(r, sinks.FormatMarkdown)
+		if err != nil {
+			logger.Fatalf("Error rendering report: %v", err)
+		}
+		rendered = append(rendered, string(content))
+	}
+
+	pr := &ci.PullRequest{
+		Branch:        "dockershrink/optimize-" + time.Now().Format("20060102-150405"),
+		Base:          p.base,
+		Title:         title,
+		Body:          strings.Join(rendered, "\n---\n\n"),
+		CommitMessage: title + "\n\nThe Dockerfile and .dockerignore were optimized by dockershrink.",
+		Files:         p.files,
+	}
+	url, err := p.creator.CreatePullRequest(ctx, pr)
+	if err != nil {
+		logger.Fatalf("Error creating the pull request: %v", err)
+	}
+	logger.Infof("\nPull request opened: %s", url)
+}
+
+// gitOutput runs git in dir and returns what it printed, trimmed
+func gitOutput(dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/duaraghav8/dockershrink/internal/config"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/output"
+	"github.com/duaraghav8/dockershrink/internal/targets"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// exitPartialFailure is the exit status of a recursive run in which some, but not all, of the Dockerfiles failed.
+// If all of them fail, the run exits with status 1 like any other error.
+const exitPartialFailure = 2
+
+// defaultJobs is the number of Dockerfiles handled at once by runs over many of them
+const defaultJobs = 4
+
+var (
+	recursive   bool
+	targetPaths []string
+	jobs        int
+)
+
+// targetFunc handles a single Dockerfile of a run over many of them. Dockerfiles are handled concurrently,
+// so it must only do the work and leave the printing to the report it returns. Reports are called one at a time,
+// in the order of the Dockerfiles, so that the output reads as if they were handled one after the other.
+type targetFunc func(t *targets.Target, cfg *config.Config, root string) (report func(), err error)
+
+// addTargetFlags adds the flags selecting the Dockerfiles to run on besides --recursive, and how many to handle at once
+func addTargetFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&targetPaths, "target", nil, "Dockerfile, or directory containing a Dockerfile, to run on using its directory as its project. Repeat it to run on several Dockerfiles at once")
+	cmd.Flags().IntVarP(&jobs, "jobs", "j", defaultJobs, "Number of Dockerfiles handled at once with --recursive or --target")
+}
+
+// multipleTargets returns true if the command runs on many Dockerfiles, found with --recursive or given with --target
+func multipleTargets() bool {
+	return recursive || len(targetPaths) > 0
+}
+
+// runRecursive runs fn on every Dockerfile under the current directory, or on those given with --target,
+// with paths relative to it. Dockerfiles found under the directory are skipped if they match the ignore
+// patterns of the configuration. A Dockerfile that fails doesn't stop the others, the errors are collected
+// and printed together at the end. It exits if every Dockerfile failed, and returns true if only some of them did.
+func runRecursive(logger *log.Logger, fn targetFunc) bool {
+	if recursive && len(targetPaths) > 0 {
+		logger.Fatalf("--target can't be used with --recursive")
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		logger.Fatalf("Error getting current working directory: %v", err)
+	}
+	cfg, err := loadConfig(cwd)
+	if err != nil {
+		logger.Fatalf("Error loading configuration: %v", err)
+	}
+
+	if len(targetPaths) > 0 {
+		// Dockerfiles given explicitly are run on even if they match the ignore patterns
+		found, err := targets.FromPaths(targetPaths)
+		if err != nil {
+			logger.Fatalf("Invalid --target: %v", err)
+		}
+		return runTargets(logger, cfg, cwd, found, fn)
+	}
+
+	skipDirs := append(defaultDirsExcludedFromTreeStructure[:], outputDir)
+	all, err := targets.Find(".", skipDirs)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	found := []*targets.Target{}
+	for _, t := range all {
+		if cfg.Ignored(filepath.ToSlash(t.Dockerfile)) {
+			logger.Debug("Skipping ignored Dockerfile", map[string]string{"dockerfile": t.Dockerfile})
+			continue
+		}
+		found = append(found, t)
+	}
+	if len(found) == 0 {
+		logger.Fatalf("No Dockerfiles found under %s", cwd)
+	}
+	return runTargets(logger, cfg, cwd, found, fn)
+}
+
+// runTargets runs fn on every target like runRecursive does, handling up to --jobs targets at once
+func runTargets(logger *log.Logger, cfg *config.Config, cwd string, found []*targets.Target, fn targetFunc) bool {
+	if jobs < 1 {
+		logger.Fatalf("--jobs must be at least 1")
+	}
+	logger.Infof("* Found %d Dockerfile(s)", len(found))
+
+	header := func(t *targets.Target) {
+		fmt.Printf("\n============ %s ============\n", t.Dockerfile)
+	}
+	// one at a time, the header comes first so that the warnings logged while handling a Dockerfile
+	// show up under it. Concurrently, those warnings are interleaved anyway.
+	sequential := jobs == 1 || len(found) == 1
+
+	var failures targets.Failures
+	targets.Each(found, jobs, func(t *targets.Target) (func(), error) {
+		if sequential {
+			header(t)
+		}
+		return fn(t, cfg, cwd)
+	}, func(t *targets.Target, report func(), err error) {
+		if !sequential {
+			header(t)
+		}
+		if err != nil {
+			addResult(&output.Result{Dockerfile: t.Dockerfile, Error: err.Error()})
+		} else if report != nil {
+			report()
+		}
+		failures.Add(t, err)
+	})
+
+	if len(failures) == 0 {
+		return false
+	}
+	printFailures(failures, len(found))
+	if len(failures) == len(found) {
+		exit(1)
+	}
+	return true
+}
+
+// printFailures prints the errors of all the Dockerfiles that failed during a recursive run
+func printFailures(failures targets.Failures, total int) {
+	fmt.Printf("\n============ %d Error(s) ============\n", len(failures))
+	for _, f := range failures {
+		color.Cyan("Dockerfile: " + color.BlueString(f.Target.Dockerfile))
+		color.Red("%v", f.Err)
+		fmt.Println("---------------------------------")
+	}
+	color.Red("%d of %d Dockerfile(s) failed", len(failures), total)
+}
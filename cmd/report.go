@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/sinks"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reportFormat string
+	reportFile   string
+)
+
+// reports are the reports of the Dockerfiles handled by the command, written with --report
+var reports []*sinks.Report
+
+// reportFormats are the formats --report can write
+var reportFormats = []string{sinks.FormatMarkdown, sinks.FormatText}
+
+func addReportFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&reportFormat, "report", "", "Also write a human-readable report of the run: markdown, eg- to post as a pull request comment, or text")
+	cmd.Flags().StringVar(&reportFile, "report-file", "dockershrink-report.md", "File the --report is written to, - for stdout")
+}
+
+func validateReportFormat() error {
+	if reportFormat == "" {
+		return nil
+	}
+	for _, f := range reportFormats {
+		if reportFormat == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown report format %q, must be one of: %s", reportFormat, strings.Join(reportFormats, ", "))
+}
+
+// collectReport keeps the report of a Dockerfile to write it with --report or --ci-format
+func collectReport(r *sinks.Report) {
+	if reportFormat != "" || ciFormat != "" {
+		reports = append(reports, r)
+	}
+}
+
+// writeReports writes the collected reports in the --report and --ci-format formats. They're only written once.
+func writeReports() {
+	writeCIReport(reports)
+	writeHumanReports(reports)
+	reports = nil
+}
+
+// writeHumanReports writes the reports in the --report format, one after the other
+func writeHumanReports(reports []*sinks.Report) {
+	if reportFormat == "" || len(reports) == 0 {
+		return
+	}
+	separator := "\n"
+	if reportFormat == sinks.FormatMarkdown {
+		separator = "\n---\n\n"
+	}
+	rendered := make([]string, 0, len(reports))
+	for _, r := range reports {
+		content, err := sinks.Render(r, reportFormat)
+		if err != nil {
+			color.Red("Error rendering report: %v", err)
+			return
+		}
+		rendered = append(rendered, string(content))
+	}
+	content := strings.Join(rendered, separator)
+
+	if reportFile == "-" {
+		// with --output json or sarif, this is stderr since stdout is reserved for the document
+		fmt.Print("\n" + content)
+		return
+	}
+	if err := os.WriteFile(reportFile, []byte(content), 0o644); err != nil {
+		color.Red("Error writing report: %v", err)
+		return
+	}
+	color.Cyan("Report saved to %s", reportFile)
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/backup"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	revertForce  bool
+	revertDryRun bool
+
+	// runBackup holds the originals of the files the command writes into the project, it's created on the first write
+	runBackup *backup.Backup
+)
+
+var revertCmd = &cobra.Command{
+	Use:   "revert",
+	Short: "Restores the files changed by the last run",
+	Long: `Every time dockershrink writes files, eg- the optimized Dockerfile or the pins updated by "update-pins", the files it overwrites are saved to .dockershrink/backups/<timestamp>/ in the project first.
+revert restores the files of the last run and deletes the files it created. Run it again to revert the run before it.
+Files changed since the run are only overwritten with --force.`,
+	Args: cobra.NoArgs,
+	Run:  runRevert,
+}
+
+func init() {
+	revertCmd.Flags().BoolVar(&revertForce, "force", false, "Restore the files even if they were changed after the run")
+	revertCmd.Flags().BoolVar(&revertDryRun, "dry-run", false, "Print the files that would be restored without changing them")
+
+	rootCmd.AddCommand(revertCmd)
+}
+
+func runRevert(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		logger.Fatalf("Error getting current working directory: %v", err)
+	}
+	b, err := backup.NewStore(cwd).Latest()
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			logger.Fatalf("Nothing to revert, dockershrink hasn't written any files in this project.")
+		}
+		logger.Fatalf("%v", err)
+	}
+
+	modified, err := b.Modified()
+	if err != nil {
+		logger.Fatalf("Error comparing the project with the backup: %v", err)
+	}
+	if len(modified) > 0 && !revertForce && !revertDryRun {
+		logger.Fatalf("Nothing was reverted, these files were changed after the run: %s. Use --force to restore them anyway.", strings.Join(modified, ", "))
+	}
+
+	fmt.Printf("Reverting the %q run of %s\n", b.Command, b.Timestamp.Local().Format("2006-01-02 15:04:05"))
+	for _, f := range b.Files {
+		if f.Existed {
+			fmt.Printf("  restore %s\n", f.Path)
+		} else {
+			fmt.Printf("  delete  %s\n", f.Path)
+		}
+	}
+	if revertDryRun {
+		return
+	}
+	if err := b.Restore(); err != nil {
+		logger.Fatalf("Error reverting: %v", err)
+	}
+	logger.Infof("Reverted %d file(s)", len(b.Files))
+}
+
+// backupProjectFile saves the file at path before content is written to it, so that "dockershrink revert" can restore it.
+// The files written by a single run of a command share a backup.
+func backupProjectFile(command, path string, content []byte) error {
+	if runBackup == nil {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		runBackup = backup.NewStore(cwd).New(command)
+	}
+	if err := runBackup.Add(path, content); err != nil {
+		return fmt.Errorf("Error backing up %s, it was left untouched: %w", path, err)
+	}
+	return nil
+}
+
+// writeProjectFile backs up the file at path and writes content to it
+func writeProjectFile(command, path string, content []byte, perm os.FileMode) error {
+	if err := backupProjectFile(command, path, content); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, perm)
+}
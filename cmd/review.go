@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/ai"
+	"github.com/duaraghav8/dockershrink/internal/diff"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/fatih/color"
+)
+
+const reviewHelp = `y - apply this hunk
+n - do not apply this hunk
+e - edit this hunk before applying it
+r - ask the AI to redo this hunk based on your feedback (requires an OpenAI API key)
+a - apply this hunk and all later hunks in the file
+d - do not apply this hunk or any of the later hunks in the file
+q - quit, do not apply this hunk or any of the remaining ones
+? - print help`
+
+// reviewer walks the user through the changes made by dockershrink, one hunk at a time (like "git add -p")
+type reviewer struct {
+	// ctx stops the review, whether the user is being asked or the AI is redoing a hunk
+	ctx context.Context
+	in  *bufio.Reader
+	// ai is used to redo rejected hunks, it is nil if no OpenAI API key was provided
+	ai *ai.AIService
+	// quit is set once the user chooses to stop reviewing, all remaining hunks are rejected after that
+	quit bool
+}
+
+func newReviewer(ctx context.Context, in io.Reader, aiService *ai.AIService) *reviewer {
+	return &reviewer{ctx: ctx, in: bufio.NewReader(in), ai: aiService}
+}
+
+// review asks the user to accept, reject or edit every hunk of the changes made to a file
+// and returns the file assembled from their decisions.
+func (r *reviewer) review(path, original, optimized string, actions []*models.OptimizationAction) (string, error) {
+	hunks := diff.Hunks(diff.Lines(original, optimized), 3)
+	if len(hunks) == 0 {
+		return optimized, nil
+	}
+
+	color.New(color.Bold).Printf("\n============ Reviewing %s ============\n", path)
+	for _, a := range fileActions(path, actions) {
+		color.Cyan("Action: " + color.GreenString(a.Title))
+	}
+
+	replacements := make([][]string, len(hunks))
+	accepted := false
+	acceptRest, rejectRest := false, r.quit
+	for i, h := range hunks {
+		if acceptRest {
+			replacements[i], accepted = h.BLines(), true
+			continue
+		}
+		if rejectRest {
+			replacements[i] = h.ALines()
+			continue
+		}
+
+		fmt.Println()
+		printDiff(hunkString(h))
+
+		// current is the hunk under review, it changes every time the AI revises it
+		current := h
+		revision := &ai.ReviseRequest{
+			Filepath:      path,
+			OriginalFile:  original,
+			OptimizedFile: optimized,
+			Actions:       fileActions(path, actions),
+			OriginalLines: h.ALines(),
+			ProposedLines: h.BLines(),
+		}
+
+	prompt:
+		for {
+			fmt.Print(color.BlueString("(%d/%d) Apply this hunk to %s [y,n,e,r,a,d,q,?]? ", i+1, len(hunks), path))
+			answer, err := r.readLine()
+			if err != nil {
+				return "", err
+			}
+			if answer == "" {
+				continue
+			}
+
+			switch strings.ToLower(answer) {
+			case "y":
+				replacements[i], accepted = current.BLines(), true
+			case "n":
+				replacements[i] = h.ALines()
+			case "r":
+				revised, err := r.revise(revision, h)
+				if err != nil {
+					color.Red("Failed to redo hunk: %v", err)
+					continue
+				}
+				current = revised
+				fmt.Println()
+				printDiff(hunkString(current))
+				continue
+			case "e":
+				edited, err := editHunk(current)
+				if err != nil {
+					color.Red("Failed to edit hunk: %v", err)
+					continue
+				}
+				replacements[i], accepted = edited, true
+			case "a":
+				replacements[i], accepted = current.BLines(), true
+				acceptRest = true
+			case "d":
+				replacements[i] = h.ALines()
+				rejectRest = true
+			case "q":
+				replacements[i] = h.ALines()
+				rejectRest, r.quit = true, true
+			default:
+				fmt.Println(reviewHelp)
+				continue
+			}
+			break prompt
+		}
+	}
+
+	if !accepted {
+		return original, nil
+	}
+	return diff.Assemble(original, hunks, replacements), nil
+}
+
+// readLine reads the user's answer. If the input was closed, it returns "q" so that reviewing stops.
+func (r *reviewer) readLine() (string, error) {
+	type line struct {
+		text string
+		err  error
+	}
+	// reading can't be interrupted, so the read is left behind if the review is cancelled
+	read := make(chan line, 1)
+	go func() {
+		text, err := r.in.ReadString('\n')
+		read <- line{text, err}
+	}()
+	var answer string
+	var err error
+	select {
+	case <-r.ctx.Done():
+		return "", r.ctx.Err()
+	case l := <-read:
+		answer, err = l.text, l.err
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read answer: %w", err)
+	}
+	if errors.Is(err, io.EOF) && answer == "" {
+		return "q", nil
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+// revise asks the user for feedback and has the AI redo the hunk accordingly.
+// The request accumulates the feedback and revisions, so that repeated revisions
+// of the same hunk continue the same conversation.
+func (r *reviewer) revise(req *ai.ReviseRequest, h *diff.Hunk) (*diff.Hunk, error) {
+	if r.ai == nil {
+		return nil, errors.New("an OpenAI API key is required to redo changes")
+	}
+	fmt.Print(color.BlueString("What should be done differently? "))
+	feedback, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if feedback == "" || feedback == "q" {
+		return nil, errors.New("no feedback given")
+	}
+
+	req.Feedback = append(req.Feedback, feedback)
+	resp, err := r.ai.ReviseChange(r.ctx, req)
+	if err != nil {
+		req.Feedback = req.Feedback[:len(req.Feedback)-1]
+		return nil, err
+	}
+	req.Revisions = append(req.Revisions, resp)
+	if resp.Explanation != "" {
+		color.Cyan("AI: " + color.WhiteString(resp.Explanation))
+	}
+	return revisedHunk(h, splitTextLines(resp.Lines)), nil
+}
+
+// revisedHunk returns a hunk that replaces the original lines of h with the revised ones
+func revisedHunk(h *diff.Hunk, revisedLines []string) *diff.Hunk {
+	ops := diff.Lines(joinTextLines(h.ALines()), joinTextLines(revisedLines))
+	return &diff.Hunk{
+		AStart: h.AStart,
+		ALen:   h.ALen,
+		BStart: h.BStart,
+		BLen:   len(revisedLines),
+		Ops:    ops,
+	}
+}
+
+// fileActions returns the actions that were taken in the file at path
+func fileActions(path string, actions []*models.OptimizationAction) []*models.OptimizationAction {
+	result := []*models.OptimizationAction{}
+	for _, a := range actions {
+		if a.Filepath == path || filepath.Base(a.Filepath) == filepath.Base(path) {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// editHunk opens the new version of the hunk in the user's editor and returns the edited lines
+func editHunk(h *diff.Hunk) ([]string, error) {
+	f, err := os.CreateTemp("", "dockershrink-hunk-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(joinTextLines(h.BLines())); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// the editor may contain arguments, eg- "code --wait"
+	args := append(strings.Fields(editor), f.Name())
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("editor %q failed: %w", editor, err)
+	}
+
+	edited, err := os.ReadFile(f.Name())
+	if err != nil {
+		return nil, err
+	}
+	return splitTextLines(string(edited)), nil
+}
+
+// joinTextLines joins lines into a text where every line ends with a newline
+func joinTextLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// splitTextLines is the inverse of joinTextLines
+func splitTextLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+func hunkString(h *diff.Hunk) string {
+	var sb strings.Builder
+	sb.WriteString(h.Header() + "\n")
+	for _, op := range h.Ops {
+		sb.WriteString(op.Prefix() + op.Text + "\n")
+	}
+	return sb.String()
+}
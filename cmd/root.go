@@ -1,22 +1,63 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/duaraghav8/dockershrink/internal/ai"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/output"
+	"github.com/duaraghav8/dockershrink/internal/plugin"
 	"github.com/spf13/cobra"
 )
 
 var (
-	openaiApiKey    string
-	debug           bool
-	packageJsonPath string
-	outputDir       string
+	openaiApiKey     string
+	debug            bool
+	packageJsonPath  string
+	outputDir        string
+	workspacePackage string
+	offline          bool
+	llmModel         string
+	promptDir        string
+	maxTokens        int64
+	maxCostUSD       float64
+	runTimeout       time.Duration
+	excludePatterns  []string
+	includePatterns  []string
+	logLevel         string
+	logFormat        string
+	logFile          string
 )
 
+// cancelRun releases the context of the run once the command is done
+var cancelRun context.CancelFunc = func() {}
+
 var rootCmd = &cobra.Command{
 	Use:   "dockershrink",
 	Short: "Dockershrink is an AI tool to reduce the size of Docker images",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		setupContext(cmd)
+		if err := setupLogging(); err != nil {
+			return err
+		}
+		if err := setupDefaultNetwork(cmd); err != nil {
+			return err
+		}
+		if err := setupTelemetry(cmd); err != nil {
+			return err
+		}
+		return setupOutput(cmd, args)
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		writeReports()
+		writeDocument(0, "")
+		shutdownTelemetry(nil)
+	},
 }
 
 func Execute() {
@@ -27,15 +68,106 @@ func Execute() {
 		"",
 		"OpenAI API key (alternatively, set the OPENAI_API_KEY environment variable)",
 	)
+	rootCmd.PersistentFlags().StringVar(
+		&authProfile, "auth-profile", "", "Use the API key stored for this profile by \"dockershrink auth login\" (alternatively, set the "+authProfileEnv+" environment variable)",
+	)
 	rootCmd.PersistentFlags().StringVar(
 		&packageJsonPath, "package-json", "", "Path to package.json (default: ./package.json or ./src/package.json)",
 	)
-	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Output detailed logs for debugging")
+	rootCmd.PersistentFlags().StringVar(
+		&workspacePackage, "workspace-package", "", "Name or directory of the monorepo workspace package to build the image for",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&offline, "offline", false, "Don't query registries and endoflife.date for base image data, use cached or built-in data instead",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&llmModel, "model", "", "LLM used to optimize and generate Dockerfiles (default: "+string(ai.OpenAIPreferredModel)+")",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&promptDir, "prompt-dir", "", "Directory of templates overriding the prompts sent to the LLM, eg- OptimizeRequestSystemPrompt"+ai.PromptFileExtension,
+	)
+	rootCmd.PersistentFlags().Int64Var(
+		&maxTokens, "max-tokens", 0, "Stop calling the LLM once a run has used this many tokens, 0 for no limit",
+	)
+	rootCmd.PersistentFlags().Float64Var(
+		&maxCostUSD, "max-cost", 0, "Stop calling the LLM once a run has cost this many US dollars, 0 for no limit",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&outputFormat, "output", output.FormatText, "Output format: text, json or sarif. With json or sarif, stdout only contains the outcome of the command and everything else is written to stderr",
+	)
+	rootCmd.PersistentFlags().DurationVar(
+		&runTimeout, "timeout", 0, "Cancel the run if it takes longer than this, eg- 5m. LLM requests, registry lookups and docker builds in flight are stopped. 0 for no limit",
+	)
+	rootCmd.PersistentFlags().StringArrayVar(
+		&excludePatterns, "exclude", nil, "Pattern in the .gitignore syntax of project files the LLM doesn't see or read, on top of the ones .gitignore ignores, eg- \"vendor/\". Can be repeated",
+	)
+	rootCmd.PersistentFlags().StringArrayVar(
+		&includePatterns, "include", nil, "Pattern in the .gitignore syntax of project files the LLM sees even if .gitignore or --exclude leave them out, eg- \"vendor/acme/\". Can be repeated",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&proxyURL, "proxy", "", "URL of the proxy requests to the LLM, registries and other services go through, eg- http://proxy.corp:3128 (default: $HTTPS_PROXY and $HTTP_PROXY)",
+	)
+	rootCmd.PersistentFlags().StringSliceVar(
+		&noProxy, "no-proxy", nil, "Comma-separated hosts, domains, IPs and CIDRs reached without the proxy, eg- .corp.example.com,10.0.0.0/8 (default: $NO_PROXY)",
+	)
+	rootCmd.PersistentFlags().StringArrayVar(
+		&caCerts, "ca-cert", nil, "PEM file of certificate authorities trusted on top of the system's, eg- the root of a TLS-inspecting proxy. Can be repeated",
+	)
+	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Output detailed logs for debugging, same as --log-level debug")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Only log messages of this level and above: debug, info, warn or error")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", log.FormatText, "Format of the logs: text, or json to write every message as a JSON object on its own line")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "Also append the logs to this file, eg- to keep them along with the results of a CI run")
 
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	plugin.DockershrinkVersion = Version
 
-	if err := rootCmd.Execute(); err != nil {
+	// the first Ctrl-C cancels the work in flight, a second one kills dockershrink right away
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	err := rootCmd.ExecuteContext(ctx)
+	cancelRun()
+	stop()
+	if err != nil {
 		fmt.Println(err)
+		writeDocument(1, err.Error())
+		shutdownTelemetry(err)
 		os.Exit(1)
 	}
 }
+
+// setupLogging configures the loggers of the command according to --log-level, --log-format and --log-file.
+// API keys and tokens are redacted from the logs whatever the options are.
+func setupLogging() error {
+	level, err := log.ParseLevel(logLevel)
+	if err != nil {
+		return err
+	}
+	if logFormat != log.FormatText && logFormat != log.FormatJSON {
+		return fmt.Errorf("invalid log format %q, must be %s or %s", logFormat, log.FormatText, log.FormatJSON)
+	}
+	opts := log.Options{Level: level, Format: logFormat}
+	if logFile != "" {
+		// the file is closed when dockershrink exits
+		f, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to open the log file: %w", err)
+		}
+		opts.File = f
+	}
+	log.Configure(opts)
+	return nil
+}
+
+// setupContext applies --timeout to the context of the command, which every command passes down to
+// the LLM calls, registry lookups and docker invocations it makes
+func setupContext(cmd *cobra.Command) {
+	if runTimeout <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(cmd.Context(), runTimeout)
+	cmd.SetContext(ctx)
+	cancelRun = cancel
+}
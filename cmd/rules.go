@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/ruletest"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var updateFixtures bool
+
+var rulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "Lists and tests dockershrink's rules",
+}
+
+var rulesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists all the rules along with their codes and default severities",
+	Run: func(cmd *cobra.Command, args []string) {
+		printRules()
+	},
+}
+
+var rulesTestCmd = &cobra.Command{
+	Use:   "test [dir...]",
+	Short: "Runs the rules against test fixtures",
+	Long: `Finds every directory containing an input.Dockerfile under the given directories (the current directory by default) and checks the outcome of running the rules on it.
+A fixture can contain the project files the rules look at, eg- package.json or .dockerignore, and at least one of:
+  expected-findings.yaml  the findings analyze must report, no more and no less. "rules" limits the rules run on the fixture.
+  expected.Dockerfile     the Dockerfile optimize must produce without AI.
+
+Example expected-findings.yaml:
+  rules: [DS014]
+  findings:
+    - rule: DS014
+      line: 3
+      severity: low
+
+With --update, the expectations of failing fixtures are overwritten with the actual outcome.
+The command exits with status 1 if any fixture fails.`,
+	Run: runRulesTest,
+}
+
+func init() {
+	rulesTestCmd.Flags().BoolVar(&updateFixtures, "update", false, "Overwrite the expectations of failing fixtures with the actual outcome")
+
+	rulesCmd.AddCommand(rulesListCmd)
+	rulesCmd.AddCommand(rulesTestCmd)
+	rootCmd.AddCommand(rulesCmd)
+}
+
+func runRulesTest(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+
+	dirs := args
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	fixtures := []*ruletest.Fixture{}
+	for _, dir := range dirs {
+		found, err := ruletest.Find(dir)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		fixtures = append(fixtures, found...)
+	}
+	if len(fixtures) == 0 {
+		logger.Fatalf("No fixtures found, a fixture is a directory containing an %s", ruletest.InputFile)
+	}
+
+	failed := 0
+	for _, f := range fixtures {
+		r, err := ruletest.Run(f)
+		if err != nil {
+			failed++
+			fmt.Printf("%s %s\n", color.RedString("ERROR"), f.Dir)
+			color.Red("  %v", err)
+			continue
+		}
+		if r.Passed() {
+			fmt.Printf("%s  %s\n", color.GreenString("PASS"), f.Dir)
+			continue
+		}
+
+		if updateFixtures {
+			if err := ruletest.Update(r); err != nil {
+				logger.Fatalf("Error updating fixture %s: %v", f.Dir, err)
+			}
+			fmt.Printf("%s %s\n", color.YellowString("UPDATED"), f.Dir)
+			continue
+		}
+		failed++
+		fmt.Printf("%s  %s\n", color.RedString("FAIL"), f.Dir)
+		for _, failure := range r.Failures {
+			fmt.Printf("  %s\n", failure)
+		}
+	}
+
+	fmt.Println("---------------------------------")
+	if failed > 0 {
+		color.Red("%d of %d fixture(s) failed", failed, len(fixtures))
+		exit(1)
+	}
+	color.Green("All %d fixture(s) passed", len(fixtures))
+}
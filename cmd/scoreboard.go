@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/batch"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	scoreboardHTMLFile = "index.html"
+	scoreboardCSVFile  = "scoreboard.csv"
+)
+
+var scoreboardDir string
+
+var scoreboardCmd = &cobra.Command{
+	Use:   "scoreboard <summary.json>",
+	Short: "Ranks the repositories of a batch summary in an HTML dashboard and a CSV file",
+	Long: `Reads the summary written by "batch --summary" in the JSON format, and ranks the repositories from the worst Dockerfiles to the best.
+The ranking is written to --dir as a static HTML dashboard (` + scoreboardHTMLFile + `) and as a CSV file (` + scoreboardCSVFile + `), along with the average and worst score,
+the estimated wasted space and the violations each repository has most often, and the violations most frequent across all of them.
+"batch --scoreboard" writes the same files directly.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runScoreboard,
+}
+
+func init() {
+	scoreboardCmd.Flags().StringVar(&scoreboardDir, "dir", "dockershrink-scoreboard", "Directory the dashboard and the CSV ranking are written to")
+
+	rootCmd.AddCommand(scoreboardCmd)
+}
+
+func runScoreboard(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+
+	summary, err := batch.LoadSummary(args[0])
+	if err != nil {
+		logger.Fatalf("Error reading the summary: %v", err)
+	}
+	board := batch.NewScoreboard(summary, time.Now())
+	if err := writeScoreboard(board, scoreboardDir); err != nil {
+		logger.Fatalf("Error writing the scoreboard: %v", err)
+	}
+	setOutputData(board)
+	logger.Infof("Scoreboard of %d repositories saved to %s", len(board.Repositories), filepath.Join(scoreboardDir, scoreboardHTMLFile))
+}
+
+// writeScoreboard writes the scoreboard to dir as an HTML dashboard and a CSV ranking
+func writeScoreboard(board *batch.Scoreboard, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for name, write := range map[string]func(f *os.File) error{
+		scoreboardHTMLFile: func(f *os.File) error { return board.WriteHTML(f) },
+		scoreboardCSVFile:  func(f *os.File) error { return board.WriteCSV(f) },
+	} {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		if err := write(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/duaraghav8/dockershrink/internal/secrets"
+)
+
+// buildSecretArgs returns the secrets as values of docker build's --secret flag
+func buildSecretArgs(buildSecrets []*secrets.BuildSecret) []string {
+	args := make([]string, 0, len(buildSecrets))
+	for _, s := range buildSecrets {
+		args = append(args, s.String())
+	}
+	return args
+}
+
+// printBuildSecrets explains how to build the optimized Dockerfile now that it mounts secrets
+// instead of receiving them as build arguments
+func printBuildSecrets(buildSecrets []*secrets.BuildSecret) {
+	flags := make([]string, 0, len(buildSecrets))
+	buildArgs := make([]string, 0, len(buildSecrets))
+	for _, s := range buildSecrets {
+		flags = append(flags, "--secret "+s.String())
+		buildArgs = append(buildArgs, "--build-arg "+s.Env)
+	}
+
+	fmt.Printf("\n============ Build Secrets ============\n")
+	fmt.Println("The optimized Dockerfile mounts secrets instead of receiving them as build arguments. Build it with:")
+	color.Cyan("  docker build " + strings.Join(flags, " ") + " .")
+	fmt.Printf("Every secret is read from the environment variable of the same name. Remove %s from your build scripts and CI,\n", strings.Join(buildArgs, ", "))
+	fmt.Println("and with docker compose, declare the secrets under the service's build.secrets instead of build.args.")
+}
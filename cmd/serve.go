@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/server"
+	"github.com/duaraghav8/dockershrink/pkg/dockershrink"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+)
+
+// max time the server waits for requests in progress when it's stopped
+const serveShutdownTimeout = 30 * time.Second
+
+var (
+	listenAddr    string
+	grpcAddr      string
+	serveTokenEnv string
+	maxUploadMB   int64
+	tlsCertFile   string
+	tlsKeyFile    string
+	tlsClientCA   string
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Runs dockershrink as an HTTP and gRPC service",
+	Long: `Runs an HTTP server that analyzes and optimizes projects uploaded by its clients, so that internal platforms can call dockershrink as a service instead of shelling out to the CLI.
+
+  POST /v1/analyze         scores the project and returns its findings, like "analyze"
+  POST /v1/optimize        returns the optimized Dockerfile and .dockerignore along with a patch, like "optimize"
+  GET  /v1/badges/<name>   returns the badge of the latest analysis that named it with the "badge" option, as a shields.io endpoint or an SVG with ?format=svg
+  GET  /healthz            returns 200 while the server is up
+
+The project is uploaded as a tarball (Content-Type: application/x-tar or application/gzip), with the options as query parameters,
+or as JSON: {"files": {"Dockerfile": "...", "package.json": "..."}, "goal": "size"}. Other options are dockerfile, dockerignore, platforms, apply_risk, include_security_recommendations and badge.
+Optimizations use the LLM if the OpenAI API key is set when the server starts, and only the rules otherwise.
+Clients that send "Accept: application/x-ndjson" get the progress of the request streamed as lines of JSON, eg- the rules applied and the tool calls of the LLM, followed by a line with the result.
+If the environment variable named by --token-env is set, clients must send it as a bearer token, except to fetch badges. With --tls-cert and --tls-key, the server only accepts HTTPS and HTTP/2,
+and with --tls-client-ca clients must also present a certificate signed by that CA (mutual TLS).
+
+With --grpc-listen, the gRPC API defined in proto/dockershrink/v1/dockershrink.proto is also served on that address, with the same token and TLS.
+Its Analyze, Lint and Optimize RPCs stream the progress of the request followed by its result. The Go client is in the pkg/api/v1 package.`,
+	Example: `  dockershrink serve --listen :8080
+  tar -czf - . | curl --data-binary @- -H "Content-Type: application/gzip" "localhost:8080/v1/analyze?goal=size"
+  dockershrink serve --listen :8080 --grpc-listen :9090 --tls-cert server.crt --tls-key server.key --tls-client-ca clients.crt`,
+	Args: cobra.NoArgs,
+	Run:  runServe,
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&listenAddr, "listen", "127.0.0.1:8080", "Address to listen on")
+	serveCmd.Flags().StringVar(&grpcAddr, "grpc-listen", "", "Address to serve the gRPC API on, it isn't served if empty")
+	serveCmd.Flags().StringVar(&serveTokenEnv, "token-env", "DOCKERSHRINK_SERVER_TOKEN", "Environment variable holding the token clients must send, requests aren't authenticated if it isn't set")
+	serveCmd.Flags().Int64Var(&maxUploadMB, "max-upload-size", server.DefaultMaxUploadSize>>20, "Max size of an uploaded project in MB")
+	serveCmd.Flags().StringVar(&tlsCertFile, "tls-cert", "", "Certificate to serve HTTPS with, in PEM")
+	serveCmd.Flags().StringVar(&tlsKeyFile, "tls-key", "", "Private key of the --tls-cert certificate, in PEM")
+	serveCmd.Flags().StringVar(&tlsClientCA, "tls-client-ca", "", "Only accept clients presenting a certificate signed by the CAs in this PEM file (mutual TLS)")
+	rootCmd.AddCommand(serveCmd)
+}
+
+func runServe(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		logger.Fatalf("Error getting current working directory: %v", err)
+	}
+	cfg, err := loadConfig(cwd)
+	if err != nil {
+		logger.Fatalf("Error loading configuration: %v", err)
+	}
+	if (tlsCertFile == "") != (tlsKeyFile == "") {
+		logger.Fatalf("--tls-cert and --tls-key must be given together")
+	}
+	if tlsClientCA != "" && tlsCertFile == "" {
+		logger.Fatalf("--tls-client-ca requires --tls-cert and --tls-key")
+	}
+	token := os.Getenv(serveTokenEnv)
+	if token == "" && tlsClientCA == "" {
+		logger.Warnf("* %s is not set, requests aren't authenticated", serveTokenEnv)
+	}
+	var llm *dockershrink.LLM
+	if apiKey := openAIAPIKey(cfg); apiKey != "" {
+		llm = &dockershrink.LLM{
+			APIKey:     apiKey,
+			BaseURL:    cfg.LLM.BaseURL,
+			Model:      cfg.LLM.Model,
+			MaxTokens:  cfg.Limits.MaxTokens,
+			MaxCostUSD: cfg.Limits.MaxCostUSD,
+			MaxFileKB:  cfg.Limits.MaxFileKB,
+			MaxReadKB:  cfg.Limits.MaxReadKB,
+		}
+	} else {
+		logger.Warnf("* OpenAI API key is not set, optimizations only apply the rules")
+	}
+
+	opts := &server.Options{
+		LLM:           llm,
+		Offline:       offline,
+		Token:         token,
+		MaxUploadSize: maxUploadMB << 20,
+		Logger:        logger,
+		Telemetry:     telemetryExporter,
+	}
+	l, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		logger.Fatalf("Error listening on %s: %v", listenAddr, err)
+	}
+	srv := &http.Server{Handler: server.New(opts), ReadHeaderTimeout: 10 * time.Second}
+	scheme := "http"
+	if tlsCertFile != "" {
+		if srv.TLSConfig, err = server.TLSConfig(tlsCertFile, tlsKeyFile, tlsClientCA); err != nil {
+			logger.Fatalf("%v", err)
+		}
+		l = tls.NewListener(l, srv.TLSConfig)
+		scheme = "https"
+	}
+
+	var grpcSrv *grpc.Server
+	if grpcAddr != "" {
+		gl, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			logger.Fatalf("Error listening on %s: %v", grpcAddr, err)
+		}
+		grpcSrv = server.NewGRPC(opts, srv.TLSConfig)
+		logger.Infof("* Serving gRPC on %s", gl.Addr())
+		go func() {
+			if err := grpcSrv.Serve(gl); err != nil {
+				logger.Fatalf("gRPC server stopped: %v", err)
+			}
+		}()
+	}
+
+	go func() {
+		<-cmd.Context().Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+		defer cancel()
+		if grpcSrv != nil {
+			// requests in progress are cut off when the HTTP server is done waiting for its own
+			go func() {
+				<-shutdownCtx.Done()
+				grpcSrv.Stop()
+			}()
+			grpcSrv.GracefulStop()
+		}
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	logger.Infof("* Listening on %s://%s", scheme, l.Addr())
+	if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Fatalf("Server stopped: %v", err)
+	}
+	logger.Infof("* Server stopped")
+}
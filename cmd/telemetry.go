@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/telemetry"
+	"github.com/duaraghav8/dockershrink/internal/usage"
+	"github.com/duaraghav8/dockershrink/pkg/events"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// max time dockershrink waits for the last spans and metrics to be exported before exiting
+const telemetryShutdownTimeout = 5 * time.Second
+
+var (
+	// telemetryExporter exports traces and metrics over OTLP if OTEL_EXPORTER_OTLP_ENDPOINT is set, it's nil otherwise
+	telemetryExporter *telemetry.Exporter
+	// runTrace is the trace of the command, the analyses, LLM requests and builds it runs are its spans
+	runTrace *telemetry.Trace
+)
+
+// setupTelemetry starts exporting traces and metrics if the standard OpenTelemetry environment variables configure an endpoint,
+// and starts the usage report if the user opted in
+func setupTelemetry(cmd *cobra.Command) error {
+	log.OnFatal(func(msg string) {
+		shutdownTelemetry(errors.New(msg))
+	})
+	setupUsage(cmd)
+
+	opts, err := telemetry.OptionsFromEnv()
+	if err != nil || opts == nil {
+		return err
+	}
+	opts.ServiceVersion = Version
+	opts.Logger = log.NewLogger(debug)
+	telemetryExporter = telemetry.New(opts)
+	// the server traces every request on its own
+	if cmd.Name() != "serve" || cmd.Parent() != cmd.Root() {
+		runTrace = telemetryExporter.Trace(cmd.CommandPath(), telemetry.SpanKindInternal, nil)
+	}
+	return nil
+}
+
+// setupUsage starts the anonymous usage report of the command, if the user opted in
+func setupUsage(cmd *cobra.Command) {
+	// changing the setting isn't reported
+	if (cmd.Parent() != nil && cmd.Parent().Name() == "telemetry") || usage.DisabledByEnv() != "" || usageEndpoint() == "" {
+		return
+	}
+	settings, err := loadUsageSettings()
+	if err != nil {
+		log.NewLogger(debug).Debug("Failed to read the telemetry settings", map[string]string{"error": err.Error()})
+		return
+	}
+	if settings.Enabled {
+		usageReport = usage.NewReport(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" "), Version)
+	}
+}
+
+// shutdownTelemetry ends the trace of the command and exports the spans and metrics that are left, then sends the usage report.
+// err is set if the command failed. It's a no-op after the first call.
+func shutdownTelemetry(err error) {
+	logger := log.NewLogger(debug)
+	if telemetryExporter != nil {
+		runTrace.End(err)
+		ctx, cancel := context.WithTimeout(context.Background(), telemetryShutdownTimeout)
+		defer cancel()
+		if err := telemetryExporter.Shutdown(ctx); err != nil {
+			logger.Warnf("* Failed to export telemetry: %v", err)
+		}
+		telemetryExporter, runTrace = nil, nil
+	}
+
+	if usageReport != nil {
+		usageReport.Finish(err == nil)
+		ctx, cancel := context.WithTimeout(context.Background(), usage.SendTimeout)
+		defer cancel()
+		// the report only helps the maintainers, the user isn't bothered if it can't be sent
+		if err := usageReport.Send(ctx, http.DefaultClient, usageEndpoint()); err != nil {
+			logger.Debug("Failed to send the usage report", map[string]string{"error": err.Error()})
+		}
+		usageReport = nil
+	}
+}
+
+// telemetryEvents returns the handler turning events into the spans and metrics and the usage report of the command,
+// nil if both are disabled
+func telemetryEvents() events.Handler {
+	if runTrace == nil && usageReport == nil {
+		return nil
+	}
+	return events.Multi(runTrace.Handler(), usageReport.Handler())
+}
+
+// UsageEndpoint is where the usage reports of the users who opted in are sent, set by release builds.
+// Builds without one don't send reports, unless DOCKERSHRINK_TELEMETRY_ENDPOINT is set.
+var UsageEndpoint = ""
+
+// usageReport is the anonymous usage report of the command, nil unless the user opted in
+var usageReport *usage.Report
+
+func usageEndpoint() string {
+	if endpoint := os.Getenv(usage.EnvEndpoint); endpoint != "" {
+		return endpoint
+	}
+	return UsageEndpoint
+}
+
+func loadUsageSettings() (*usage.Settings, error) {
+	path, err := usage.SettingsPath()
+	if err != nil {
+		return nil, err
+	}
+	return usage.LoadSettings(path)
+}
+
+var telemetryCmd = &cobra.Command{
+	Use:   "telemetry",
+	Short: "Shows or changes whether anonymous usage statistics are sent to the maintainers",
+	Long: `Dockershrink can send anonymous usage statistics to its maintainers, which helps them prioritize the rules that matter most. It's off until you run "dockershrink telemetry enable".
+
+A report is sent at the end of every command, with the command, the version of dockershrink, the OS and architecture, whether the command succeeded and how long it took,
+the number of Dockerfiles processed, how often each built-in rule fired, the number of changes made, the bytes saved, and the number, retries and latency of the LLM requests by model.
+File paths, project and repository names, Dockerfiles, custom rules, fine-tuned model names and API keys are never sent, and reports carry no user or machine ID.
+
+The DO_NOT_TRACK and DOCKERSHRINK_NO_TELEMETRY environment variables turn reports off whatever the setting is.
+This is unrelated to the OpenTelemetry traces and metrics exported to your own collector when OTEL_EXPORTER_OTLP_ENDPOINT is set.`,
+}
+
+var telemetryStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Shows whether usage statistics are sent",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := log.NewLogger(debug)
+		settings, err := loadUsageSettings()
+		if err != nil {
+			logger.Fatalf("Error reading the telemetry settings: %v", err)
+		}
+		printUsageStatus(settings)
+	},
+}
+
+var telemetryEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Sends anonymous usage statistics to the maintainers",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		setUsageEnabled(true)
+	},
+}
+
+var telemetryDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Stops sending usage statistics",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		setUsageEnabled(false)
+	},
+}
+
+func init() {
+	telemetryCmd.AddCommand(telemetryStatusCmd)
+	telemetryCmd.AddCommand(telemetryEnableCmd)
+	telemetryCmd.AddCommand(telemetryDisableCmd)
+	rootCmd.AddCommand(telemetryCmd)
+}
+
+func setUsageEnabled(enabled bool) {
+	logger := log.NewLogger(debug)
+	path, err := usage.SettingsPath()
+	if err != nil {
+		logger.Fatalf("Error locating the telemetry settings: %v", err)
+	}
+	settings := &usage.Settings{Enabled: enabled, UpdatedAt: time.Now().UTC()}
+	if err := settings.Save(path); err != nil {
+		logger.Fatalf("Error saving the telemetry settings: %v", err)
+	}
+	printUsageStatus(settings)
+	if enabled {
+		logger.Infof("\nThank you! Run \"dockershrink telemetry disable\" to stop at any time.")
+	}
+}
+
+func printUsageStatus(settings *usage.Settings) {
+	endpoint := usageEndpoint()
+	disabledBy := usage.DisabledByEnv()
+	sending := settings.Enabled && disabledBy == "" && endpoint != ""
+	setOutputData(map[string]any{"enabled": settings.Enabled, "sending": sending, "disabled_by": disabledBy, "endpoint": endpoint})
+
+	status := color.RedString("disabled")
+	if settings.Enabled {
+		status = color.GreenString("enabled")
+	}
+	color.Cyan("Anonymous usage statistics: " + status)
+	switch {
+	case settings.Enabled && disabledBy != "":
+		color.Yellow("Reports aren't sent, %s is set", disabledBy)
+	case settings.Enabled && endpoint == "":
+		color.Yellow("Reports aren't sent, this build of dockershrink has no endpoint to send them to (set %s)", usage.EnvEndpoint)
+	case sending:
+		color.Cyan("Endpoint: " + color.WhiteString(endpoint))
+	}
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/history"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/units"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// defaultMaxGrowthPercent is how much the image may grow between two runs before "trend --check" fails,
+	// unless --max-growth or "trend.max_growth_percent" is set
+	defaultMaxGrowthPercent = 10
+	// width of the bar of the biggest image in the chart
+	trendChartWidth = 40
+)
+
+var (
+	trendCheck     bool
+	trendMaxGrowth float64
+)
+
+var trendCmd = &cobra.Command{
+	Use:   "trend",
+	Short: "Charts the size and score of the image at every git commit and checks that it didn't grow",
+	Long: `Charts the size of the image and the score of the Dockerfile, as they were at every git commit "dockershrink optimize" was run at, oldest first.
+They're taken from the runs recorded in the .dockershrink/history directory of the project, the last run at a commit stands for it.
+The sizes are measured by --verify-build if the images were built, and estimated otherwise.
+
+With --check, the command fails if the image of the last run is bigger than the one of the run before it by more than --max-growth percent,
+eg- in CI after "dockershrink optimize". The limit can also be set with "trend.max_growth_percent" in .dockershrink.yaml, where 0 allows no growth at all.
+It then exits with status 3, so that CI can tell an image that grew from errors, which exit with status 1.`,
+	Args: cobra.NoArgs,
+	Run:  runTrend,
+}
+
+func init() {
+	trendCmd.Flags().StringVar(&dockerfilePath, "dockerfile", "Dockerfile", "Path to Dockerfile")
+	trendCmd.Flags().BoolVar(&trendCheck, "check", false, "Fail if the image grew too much since the previous run")
+	trendCmd.Flags().Float64Var(&trendMaxGrowth, "max-growth", 0, fmt.Sprintf("How many percent the image may grow since the previous run with --check (default: %d)", defaultMaxGrowthPercent))
+
+	rootCmd.AddCommand(trendCmd)
+}
+
+func runTrend(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		logger.Fatalf("Error getting current working directory: %v", err)
+	}
+	cfg, err := loadConfig(cwd)
+	if err != nil {
+		logger.Fatalf("Error loading configuration: %v", err)
+	}
+	maxGrowth := float64(defaultMaxGrowthPercent)
+	if cmd.Flags().Changed("max-growth") {
+		if trendMaxGrowth < 0 {
+			logger.Fatalf("--max-growth must not be negative")
+		}
+		maxGrowth = trendMaxGrowth
+	} else if cfg.Trend.MaxGrowthPercent != nil {
+		maxGrowth = *cfg.Trend.MaxGrowthPercent
+	}
+
+	all, err := history.NewStore(cwd).List()
+	if err != nil {
+		logger.Fatalf("Error reading run history: %v", err)
+	}
+	runs := []*history.Run{}
+	for _, run := range all {
+		if run.Command == "optimize" && projectRelativePath(cwd, run.DockerfilePath) == projectRelativePath(cwd, dockerfilePath) {
+			runs = append(runs, run)
+		}
+	}
+	points := history.Trend(runs)
+	data := map[string]any{"dockerfile": dockerfilePath, "points": points}
+	setOutputData(data)
+	if len(points) == 0 {
+		logger.Infof("No runs of %s recorded yet. Runs are recorded every time \"dockershrink optimize\" is run.", dockerfilePath)
+		return
+	}
+	printTrend(points)
+
+	if !trendCheck {
+		return
+	}
+	if len(runs) < 2 {
+		logger.Infof("\nOnly one run of %s is recorded, there's nothing to compare it with yet.", dockerfilePath)
+		return
+	}
+	previous, latest := runs[len(runs)-2], runs[len(runs)-1]
+	growth, ok := history.Growth(previous, latest)
+	if !ok {
+		logger.Warnf("\nThe size of the image wasn't estimated by run %s or %s, it can't be checked.", previous.ID, latest.ID)
+		return
+	}
+	data["growth_percent"] = growth
+	data["max_growth_percent"] = maxGrowth
+	if growth > maxGrowth {
+		logger.Errorf("\nThe image grew by %.1f%% since run %s, more than the %.1f%% allowed.", growth, previous.ID, maxGrowth)
+		exit(exitViolations)
+	}
+	logger.Infof("\nThe image changed by %+.1f%% since run %s, within the %.1f%% allowed.", growth, previous.ID, maxGrowth)
+}
+
+// printTrend charts the size of the image as bars scaled to the biggest one, followed by the score
+func printTrend(points []*history.Point) {
+	var biggest int64
+	estimated := false
+	for _, p := range points {
+		biggest = max(biggest, p.Size)
+		estimated = estimated || (p.Size > 0 && !p.Verified)
+	}
+
+	fmt.Printf("%-9s  %-16s  %-*s  %10s  %s\n", "COMMIT", "DATE", trendChartWidth, "SIZE", "", "SCORE")
+	for _, p := range points {
+		commit := p.Commit
+		if commit == "" {
+			commit = "-"
+		}
+		bar, size := "", "-"
+		if p.Size > 0 {
+			bar = strings.Repeat("█", max(1, int(p.Size*trendChartWidth/biggest)))
+			size = units.FormatBytes(p.Size)
+			if !p.Verified {
+				size = "~" + size
+			}
+		}
+		score := "-"
+		if p.Score != nil {
+			score = fmt.Sprintf("%d/100", *p.Score)
+		}
+		fmt.Printf("%-9s  %-16s  %s%s  %10s  %s\n",
+			commit,
+			p.Timestamp.Local().Format("2006-01-02 15:04"),
+			color.CyanString(bar),
+			strings.Repeat(" ", trendChartWidth-len([]rune(bar))),
+			size,
+			score,
+		)
+	}
+	if estimated {
+		fmt.Println("~ estimated size, use --verify-build to measure the built image")
+	}
+}
+
+// currentCommit returns the abbreviated git commit the project in dir is at, empty if it's not in a git repository
+func currentCommit(dir string) string {
+	commit, err := gitOutput(dir, "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return commit
+}
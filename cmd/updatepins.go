@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/diff"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/pinning"
+	"github.com/duaraghav8/dockershrink/internal/registry"
+	"github.com/spf13/cobra"
+)
+
+// max time allowed for resolving the digests of all base images
+const updatePinsTimeout = 2 * time.Minute
+
+var updatePinsDryRun bool
+
+var updatePinsCmd = &cobra.Command{
+	Use:   "update-pins",
+	Short: "Updates the digests of pinned base images",
+	Long: `Resolves the tag of every base image pinned to a digest (eg- FROM node:20-alpine@sha256:...) again and updates the digest to the image the tag currently points to.
+Images are pinned by "dockershrink optimize --pin-base-images". Images pinned without a tag and unpinned images are left as they are.
+Registries are queried anonymously, or with the credentials stored by "docker login".`,
+	Run: runUpdatePins,
+}
+
+func init() {
+	updatePinsCmd.Flags().StringVar(&dockerfilePath, "dockerfile", "Dockerfile", "Path to Dockerfile")
+	updatePinsCmd.Flags().BoolVar(&updatePinsDryRun, "dry-run", false, "Print the changes without writing them to the Dockerfile")
+
+	rootCmd.AddCommand(updatePinsCmd)
+}
+
+func runUpdatePins(cmd *cobra.Command, args []string) {
+	logger := log.NewLogger(debug)
+	if offline {
+		logger.Fatalf("update-pins requires access to the registries and cannot be used with --offline")
+	}
+
+	d, err := readDockerfile(logger, dockerfilePath)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	original := d.Raw()
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), updatePinsTimeout)
+	defer cancel()
+	results := pinning.Pin(ctx, d, registry.NewClient(), pinning.UpdatePinned)
+	if len(results) == 0 {
+		logger.Infof("No base images are pinned to a digest. Use \"dockershrink optimize --pin-base-images\" to pin them.")
+		return
+	}
+
+	updated := 0
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			logger.Warnf("* Line %d: could not update %s: %v", r.Line, r.Previous, r.Err)
+		case r.Changed():
+			logger.Infof("* Line %d: updated to %s", r.Line, r.Pinned)
+			updated++
+		default:
+			logger.Debug("Pin is up to date", map[string]string{"line": strconv.Itoa(r.Line), "image": r.Previous})
+		}
+	}
+	if updated == 0 {
+		logger.Infof("All pinned base images are up to date.")
+		return
+	}
+
+	printDiff(diff.Unified("a/"+dockerfilePath, "b/"+dockerfilePath, original, d.Raw()))
+	if updatePinsDryRun {
+		return
+	}
+	if err := writeProjectFile("update-pins", dockerfilePath, []byte(d.Raw()), 0o644); err != nil {
+		logger.Fatalf("Error writing %s: %v", dockerfilePath, err)
+	}
+	logger.Infof("Updated %d pin(s) in %s", updated, dockerfilePath)
+}
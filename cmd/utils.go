@@ -1,20 +1,54 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/duaraghav8/dockershrink/internal/ai"
+	"github.com/duaraghav8/dockershrink/internal/baseimages"
+	"github.com/duaraghav8/dockershrink/internal/classification"
+	"github.com/duaraghav8/dockershrink/internal/config"
+	"github.com/duaraghav8/dockershrink/internal/credentials"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/dockerignore"
+	"github.com/duaraghav8/dockershrink/internal/docs"
+	"github.com/duaraghav8/dockershrink/internal/fileindex"
+	"github.com/duaraghav8/dockershrink/internal/history"
 	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/ownership"
 	"github.com/duaraghav8/dockershrink/internal/packagejson"
+	"github.com/duaraghav8/dockershrink/internal/platform"
+	"github.com/duaraghav8/dockershrink/internal/ratelimit"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+	"github.com/duaraghav8/dockershrink/internal/sinks"
 	"github.com/duaraghav8/dockershrink/internal/tree"
+	"github.com/duaraghav8/dockershrink/internal/workspace"
+	"github.com/duaraghav8/dockershrink/pkg/events"
+	"github.com/fatih/color"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
+	"github.com/spf13/cobra"
 )
 
+// max time allowed for delivering a report to all sinks
+const reportTimeout = time.Minute
+
+// max time allowed for fetching live data about base images
+const baseImagesTimeout = 20 * time.Second
+
 // max number of characters allowed in the directory tree structure
 const dirTreeStrLenLimit = 4400 // ~1K tokens in LLM prompt
 
+// files larger than this are left out of the directory tree, eg- data dumps and bundles
+const maxTreeFileSize = 1024 * 1024
+
 var defaultDirsExcludedFromTreeStructure = [...]string{
 	"node_modules",
 	"jspm_packages",
@@ -24,6 +58,7 @@ var defaultDirsExcludedFromTreeStructure = [...]string{
 	".grunt",
 	".cache",
 	".git",
+	history.Dir,
 	".github",
 	".gitlab",
 	".idea",
@@ -33,25 +68,307 @@ var defaultDirsExcludedFromTreeStructure = [...]string{
 	// "dist",
 }
 
-// getAIService returns an instance of AIService if the OpenAI API key is set
-// this function does not treat the absence of openai API key as an error
-func getAIService(logger *log.Logger) (*ai.AIService, bool) {
-	if openaiApiKey == "" {
-		openaiApiKey = os.Getenv("OPENAI_API_KEY")
+// getAIService returns an instance of AIService if the OpenAI API key is set, using the model and
+// limits of the configuration and the prompts of --prompt-dir. This function does not treat the absence of openai API key as an error.
+func getAIService(logger *log.Logger, cfg *config.Config) (*ai.AIService, bool) {
+	// broken prompt templates fail the run even without an API key, rather than once one is set
+	var prompts ai.Prompts
+	if promptDir != "" {
+		var err error
+		if prompts, err = ai.LoadPrompts(promptDir); err != nil {
+			logger.Fatalf("Error loading prompts from %s: %v", promptDir, err)
+		}
+		for name := range prompts {
+			logger.Debug("Overriding prompt", map[string]string{"prompt": name, "dir": promptDir})
+		}
 	}
-	if openaiApiKey == "" {
+
+	client := getOpenAIClient(cfg)
+	if client == nil {
 		// openai api key was neither provided as a flag nor as an environment variable
 		return nil, false
 	}
-	client := openai.NewClient(
-		option.WithAPIKey(openaiApiKey),
-	)
-	return ai.NewAIService(logger, client), true
+	aiService := ai.NewAIService(logger, client)
+	aiService.Events = logEvents(logger)
+	aiService.Prompts = prompts
+	if cfg.LLM.Model != "" {
+		aiService.Model = openai.ChatModel(cfg.LLM.Model)
+	}
+	aiService.Limiter = ratelimit.New(cfg.LLM.RequestsPerMinute)
+	aiService.FileLimits = ai.FileLimits{MaxFileBytes: cfg.Limits.MaxFileKB * 1024, MaxTotalBytes: cfg.Limits.MaxReadKB * 1024}
+	if cfg.Limits.MaxTokens > 0 || cfg.Limits.MaxCostUSD > 0 {
+		aiService.Budget = &ai.Budget{MaxTokens: cfg.Limits.MaxTokens, MaxCostUSD: cfg.Limits.MaxCostUSD}
+		if cfg.Limits.MaxCostUSD > 0 && !ai.HasKnownPrice(string(aiService.Model)) {
+			logger.Warnf("* The price of %s isn't known, the cost limit can't be enforced", aiService.Model)
+		}
+	}
+	return aiService, true
+}
+
+// getOpenAIClient returns a client of the LLM's API, nil if its API key is not set.
+// The --openai-api-key flag takes precedence over the environment variable named in the configuration.
+func getOpenAIClient(cfg *config.Config) *openai.Client {
+	apiKey := openAIAPIKey(cfg)
+	if apiKey == "" {
+		return nil
+	}
+	log.AddSecret(apiKey)
+	opts := []option.RequestOption{option.WithAPIKey(apiKey)}
+	if cfg.LLM.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(cfg.LLM.BaseURL))
+	}
+	return openai.NewClient(opts...)
+}
+
+// openAIAPIKey returns the API key of the LLM, empty if it's not set.
+// --openai-api-key comes first, then the key of the profile selected with --auth-profile,
+// then the environment variable named in the configuration and last the key of the default profile.
+func openAIAPIKey(cfg *config.Config) string {
+	if openaiApiKey != "" {
+		return openaiApiKey
+	}
+	if profile := selectedAuthProfile(); profile != "" {
+		logger := log.NewLogger(debug)
+		key, err := credentialStore(logger).Key(profile)
+		if errors.Is(err, credentials.ErrNotFound) {
+			logger.Fatalf("Error: profile %s isn't logged in, run \"dockershrink auth login --profile %s\"", profile, profile)
+		}
+		if err != nil {
+			logger.Fatalf("Error reading the API key of profile %s: %v", profile, err)
+		}
+		return key
+	}
+	env := cfg.LLM.APIKeyEnv
+	if env == "" {
+		env = "OPENAI_API_KEY"
+	}
+	if key := os.Getenv(env); key != "" {
+		return key
+	}
+	return defaultProfileKey()
+}
+
+// defaultProfileKey returns the key of the default profile, empty if it isn't logged in or its key can't be read
+func defaultProfileKey() string {
+	store, err := openCredentialStore()
+	if err != nil {
+		return ""
+	}
+	if _, ok := store.Profiles[credentials.DefaultProfile]; !ok {
+		return ""
+	}
+	key, err := store.Key(credentials.DefaultProfile)
+	if err != nil {
+		log.NewLogger(debug).Warnf("* Couldn't read the API key of the %s profile: %v", credentials.DefaultProfile, err)
+		return ""
+	}
+	return key
+}
+
+// loadConfig loads the configuration of the project in dir, layered over the user's configuration.
+// Flags set on the command line override the values of the configuration, the others are set from it.
+func loadConfig(dir string) (*config.Config, error) {
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	flags := rootCmd.PersistentFlags()
+	if flags.Changed("model") {
+		cfg.LLM.Model = llmModel
+	}
+	if flags.Changed("max-tokens") {
+		cfg.Limits.MaxTokens = maxTokens
+	}
+	if flags.Changed("max-cost") {
+		cfg.Limits.MaxCostUSD = maxCostUSD
+	}
+	if err := setupNetwork(rootCmd, cfg); err != nil {
+		return nil, err
+	}
+	if !flags.Changed("output-dir") && cfg.Output.Dir != "" {
+		outputDir = cfg.Output.Dir
+	}
+
+	// an explicit --goal wins over the profile of the configuration
+	p := profile
+	if p == "" && !goalFlagChanged() {
+		p = cfg.Profile
+	}
+	if p != "" {
+		parsed, err := models.ParseProfile(p)
+		if err != nil {
+			return nil, err
+		}
+		goal = string(parsed.Goal())
+	}
+	return cfg, nil
+}
+
+const profileFlagUsage = "Preset of what to optimize for: size, speed, balanced or security. Can't be combined with --goal"
+
+// profileCommands are the commands with a --profile flag, only the one being run can have flags set
+var profileCommands []*cobra.Command
+
+// addProfileFlag adds --profile to a command with a --goal flag, as an alternative to it
+func addProfileFlag(c *cobra.Command) {
+	c.Flags().StringVar(&profile, "profile", "", profileFlagUsage)
+	c.MarkFlagsMutuallyExclusive("goal", "profile")
+	profileCommands = append(profileCommands, c)
+}
+
+func goalFlagChanged() bool {
+	for _, c := range profileCommands {
+		if c.Flags().Changed("goal") {
+			return true
+		}
+	}
+	return false
+}
+
+// attachDocs lets the LLM search dockershrink's documentation using the embeddings provider
+// configured in the project. Documentation is searched by keywords if the provider can't be set up.
+func attachDocs(logger *log.Logger, aiService *ai.AIService, cfg *config.Config) {
+	embedder, err := getEmbedder(cfg)
+	if err != nil {
+		logger.Warnf("* Searching documentation by keywords: %v", err)
+		embedder = nil
+	}
+	cacheDir, err := docs.DefaultCacheDir()
+	if err != nil {
+		logger.Debug("Embeddings won't be cached", map[string]string{"error": err.Error()})
+	}
+	aiService.Docs = docs.NewIndex(docs.Passages(), embedder, cacheDir)
+	logger.Debug("Documentation search enabled", map[string]string{"provider": aiService.Docs.Provider()})
+}
+
+// getEmbedder returns the embedder of the embeddings provider configured in the project.
+// A nil Embedder is returned if the documentation is searched by keywords.
+func getEmbedder(cfg *config.Config) (docs.Embedder, error) {
+	opts := &docs.EmbedderOptions{
+		Provider: cfg.Embeddings.Provider,
+		Model:    cfg.Embeddings.Model,
+		Command:  cfg.Embeddings.Command,
+		Limiter:  ratelimit.New(cfg.Embeddings.RequestsPerMinute),
+	}
+	if opts.Provider == "" || opts.Provider == docs.ProviderOpenAI {
+		apiKey := openAIAPIKey(cfg)
+		if cfg.Embeddings.APIKeyEnv != "" {
+			apiKey = os.Getenv(cfg.Embeddings.APIKeyEnv)
+		}
+		if apiKey != "" {
+			clientOpts := []option.RequestOption{option.WithAPIKey(apiKey)}
+			if cfg.Embeddings.BaseURL != "" {
+				clientOpts = append(clientOpts, option.WithBaseURL(cfg.Embeddings.BaseURL))
+			}
+			opts.Client = openai.NewClient(clientOpts...)
+		}
+	}
+	return docs.NewEmbedder(opts)
+}
+
+// logEvents returns a handler that logs the progress events which aren't printed otherwise, in debug mode.
+// The events are also traced if telemetry is enabled.
+func logEvents(logger *log.Logger) events.Handler {
+	return events.Multi(func(e events.Event) {
+		switch e := e.(type) {
+		case events.ToolCallStarted:
+			logger.Debug("LLM called a tool", map[string]string{"tool": e.Tool, "arguments": e.Arguments})
+		case events.ToolCallFinished:
+			if e.Err != nil {
+				logger.Debug("Tool failed", map[string]string{"tool": e.Tool, "error": e.Err.Error()})
+			}
+		case events.LLMTokensReceived:
+			logger.Debug("Received tokens from LLM", map[string]string{
+				"model":             e.Model,
+				"prompt_tokens":     strconv.FormatInt(e.PromptTokens, 10),
+				"completion_tokens": strconv.FormatInt(e.CompletionTokens, 10),
+			})
+		case events.LLMRequestFinished:
+			if e.Retries > 0 {
+				logger.Debug("Retried LLM request", map[string]string{"model": e.Model, "retries": strconv.Itoa(e.Retries)})
+			}
+		case events.BuildProgress:
+			logger.Debug("Build output", map[string]string{"image": e.Image, "line": e.Line})
+		}
+	}, telemetryEvents())
+}
+
+// targetPlatforms returns the platforms the image is built for.
+// The --platforms flag takes precedence over the platforms in the config file.
+func targetPlatforms(logger *log.Logger, cfg *config.Config) ([]platform.Platform, error) {
+	list := platforms
+	if list == "" {
+		list = strings.Join(cfg.Platforms, ",")
+	}
+	targets, err := platform.ParseList(list)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid platforms: %w", err)
+	}
+	if len(targets) > 0 {
+		logger.Infof("* Target platforms: %s", platform.Join(targets))
+	}
+	return targets, nil
+}
+
+// ruleSeverities returns the severity overrides in the config file, keyed by rule ID.
+// Disabled rules are turned off whatever their severity.
+func ruleSeverities(cfg *config.Config) (map[string]models.Severity, error) {
+	severities := map[string]models.Severity{}
+	// the rules of plugins are only known once they report findings, so they're referred to as written
+	plugins := len(cfg.Lint.Plugins) > 0
+	for ref, value := range cfg.Lint.Severity {
+		id := ref
+		if rule := rules.Lookup(ref); rule != nil {
+			id = rule.ID
+		} else if !plugins {
+			return nil, fmt.Errorf("lint.severity: unknown rule %q", ref)
+		}
+		severity, err := rules.ParseSeverity(value)
+		if err != nil {
+			return nil, fmt.Errorf("lint.severity.%s: %w", ref, err)
+		}
+		severities[id] = severity
+	}
+	for i, ref := range cfg.Lint.Disable {
+		id := ref
+		if rule := rules.Lookup(ref); rule != nil {
+			id = rule.ID
+		} else if !plugins {
+			return nil, fmt.Errorf("lint.disable[%d]: unknown rule %q", i, ref)
+		}
+		severities[id] = rules.SeverityOff
+	}
+	return severities, nil
 }
 
-// getPackageJson reads the package.json file and returns it as a PackageJSON object
+// dockerfileOwners returns the owners of the Dockerfile according to the project's CODEOWNERS
+// file and the owners in the config file. Ownership only routes reports, so failing to
+// determine it never fails the run.
+func dockerfileOwners(logger *log.Logger, projectDir string, cfg *config.Config, dockerfile string) []string {
+	rules := make([]*ownership.Rule, 0, len(cfg.Owners))
+	for _, o := range cfg.Owners {
+		r, err := ownership.NewRule(o.Path, o.Owners)
+		if err != nil {
+			logger.Warnf("* Invalid owners in %s: %v", config.Filename, err)
+			return nil
+		}
+		rules = append(rules, r)
+	}
+	owners, err := ownership.Load(projectDir, rules)
+	if err != nil {
+		logger.Warnf("* Failed to determine the owners of %s: %v", dockerfile, err)
+		return nil
+	}
+	result := owners.Of(projectRelativePath(projectDir, dockerfile))
+	if len(result) > 0 {
+		logger.Infof("* Dockerfile owned by %s", strings.Join(result, ", "))
+	}
+	return result
+}
+
+// getPackageJson reads the package.json file of the project in dir and returns it as a PackageJSON object
 // this function returns an error if the file is not found
-func getPackageJson() (*packagejson.PackageJSON, error) {
+func getPackageJson(dir string) (*packagejson.PackageJSON, error) {
 	if packageJsonPath != "" {
 		// path is provided as a flag, give it preference
 		content, err := os.ReadFile(packageJsonPath)
@@ -64,6 +381,7 @@ func getPackageJson() (*packagejson.PackageJSON, error) {
 	// no path provided in flag, search the default paths
 	paths := []string{"package.json", "src/package.json"}
 	for _, path := range paths {
+		path = filepath.Join(dir, path)
 		if _, err := os.Stat(path); err == nil {
 			content, err := os.ReadFile(path)
 			if err != nil {
@@ -76,12 +394,22 @@ func getPackageJson() (*packagejson.PackageJSON, error) {
 	return nil, fmt.Errorf("No package.json found in the default paths: %w", os.ErrNotExist)
 }
 
-// getDirTree returns the given directory's tree string representation suitable for LLM prompt
-func getDirTree(dir string) (string, error) {
+// getDirTree returns the given directory's tree string representation suitable for LLM prompt.
+// Paths .gitignore ignores, --exclude leaves out and for which ignored returns true aren't listed,
+// neither are binary files and files too large to be worth reading.
+func getDirTree(dir string, ignored func(path string) bool) (string, error) {
 	// Exclude all directories that don't directly contain the project's files.
 	// These dirs increase prompt token count without adding much value.
 	dirsExcludedFromTreeStructure := append(defaultDirsExcludedFromTreeStructure[:], outputDir)
-	cwdTree, err := tree.BuildTreeWithIgnore(dir, dirsExcludedFromTreeStructure)
+	cwdTree, err := tree.BuildTree(dir, tree.Options{
+		IgnoreDirs:  dirsExcludedFromTreeStructure,
+		Gitignore:   true,
+		Exclude:     excludePatterns,
+		Include:     includePatterns,
+		Ignored:     ignored,
+		MaxFileSize: maxTreeFileSize,
+		SkipBinary:  true,
+	})
 	if err != nil {
 		return "", fmt.Errorf("Error building directory tree: %w", err)
 	}
@@ -90,3 +418,225 @@ func getDirTree(dir string) (string, error) {
 	}
 	return cwdTree, nil
 }
+
+// withExcludedPaths extends ignored with the paths --exclude leaves out and --include doesn't add back, so that
+// the LLM can't read the files it doesn't see in the directory tree. ignored may be nil.
+func withExcludedPaths(ignored func(path string) bool) (func(path string) bool, error) {
+	exclude, err := tree.NewPatterns("", excludePatterns)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid --exclude pattern: %w", err)
+	}
+	include, err := tree.NewPatterns("", includePatterns)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid --include pattern: %w", err)
+	}
+	return func(p string) bool {
+		if ignored != nil && ignored(p) {
+			return true
+		}
+		excluded, _ := exclude.Match(p, false)
+		included, _ := include.Match(p, false)
+		return excluded && !included
+	}, nil
+}
+
+// getWorkspace detects whether the given directory is the root of a monorepo.
+// It returns nil if the project does not use workspaces.
+func getWorkspace(dir string) (*workspace.Workspace, error) {
+	ws, err := workspace.Detect(os.DirFS(dir))
+	if err != nil {
+		return nil, fmt.Errorf("Error detecting monorepo workspace: %w", err)
+	}
+	if ws == nil {
+		if workspacePackage != "" {
+			return nil, fmt.Errorf("--workspace-package was specified but the project is not a monorepo")
+		}
+		return nil, nil
+	}
+	if workspacePackage != "" && ws.FindPackage(workspacePackage) == nil {
+		return nil, fmt.Errorf("Package %s not found in the monorepo workspace", workspacePackage)
+	}
+	return ws, nil
+}
+
+// loadBaseImages returns the matrix of official images used as base images by the Dockerfile.
+// Failing to fetch live data is not fatal, cached or built-in data is used instead.
+func loadBaseImages(ctx context.Context, logger *log.Logger, d *dockerfile.Dockerfile) *baseimages.Matrix {
+	var names []string
+	for _, stage := range d.GetStages() {
+		names = append(names, stage.BaseImage().Name())
+	}
+	return loadBaseImageMatrix(ctx, logger, names, false)
+}
+
+func loadBaseImageMatrix(ctx context.Context, logger *log.Logger, names []string, refresh bool) *baseimages.Matrix {
+	opts := &baseimages.LoadOptions{Refresh: refresh}
+	if cache, err := baseimages.DefaultCache(); err == nil {
+		opts.Cache = cache
+	}
+	if !offline {
+		opts.Fetcher = baseimages.NewFetcher()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, baseImagesTimeout)
+	defer cancel()
+	m, err := baseimages.Load(ctx, names, opts)
+	if err != nil {
+		logger.Warnf("Failed to fetch base image data, using cached or built-in data instead (use --offline to skip fetching): %v", err)
+	}
+	return m
+}
+
+// indexProject brings the index of the project's files up to date and persists it.
+// The index is only an optimization, so nil is returned if it can't be updated.
+func indexProject(logger *log.Logger, dir string) *fileindex.Index {
+	x := fileindex.Open(dir)
+	changed, err := x.Update()
+	if err != nil {
+		logger.Warnf("Failed to index project files: %v", err)
+		return nil
+	}
+	logger.Debug("Updated index of project files", map[string]string{"changed_files": strconv.Itoa(len(changed))})
+	if err := x.Save(); err != nil {
+		logger.Warnf("%v", err)
+	}
+	return x
+}
+
+// classifyDockerfile determines whether the given Dockerfile is only used in CI
+func classifyDockerfile(projectDir, dockerfile string, cfg *config.Config) *classification.Result {
+	relPath := dockerfile
+	if abs, err := filepath.Abs(dockerfile); err == nil {
+		if rel, err := filepath.Rel(projectDir, abs); err == nil {
+			relPath = filepath.ToSlash(rel)
+		}
+	}
+	return classification.Classify(os.DirFS(projectDir), relPath, cfg.Dockerfiles)
+}
+
+// readDockerfile reads and parses the Dockerfile at the given path
+func readDockerfile(logger *log.Logger, path string) (*dockerfile.Dockerfile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %w", path, err)
+	}
+	return parseDockerfileAt(logger, path, content)
+}
+
+// parseDockerfileAt parses the content of the Dockerfile at path, recovering from common syntax errors
+func parseDockerfileAt(logger *log.Logger, path string, content []byte) (*dockerfile.Dockerfile, error) {
+	df, _, err := parseDockerfile(logger, string(content))
+	if err != nil {
+		return nil, syntaxError(path, err)
+	}
+	return df, nil
+}
+
+// parseDockerfile parses the given Dockerfile code. If it has syntax errors, the common problems
+// that cause them are fixed in memory and the fixes are returned along with the Dockerfile.
+// The errors of the original code are returned if the fixes don't help.
+func parseDockerfile(logger *log.Logger, content string) (*dockerfile.Dockerfile, []string, error) {
+	df, err := dockerfile.NewDockerfile(content)
+	var syntaxErrs dockerfile.SyntaxErrors
+	if err == nil || !errors.As(err, &syntaxErrs) {
+		return df, nil, err
+	}
+
+	recovered, fixes := dockerfile.Recover(content)
+	if len(fixes) == 0 {
+		return nil, nil, err
+	}
+	df, recoverErr := dockerfile.NewDockerfile(recovered)
+	if recoverErr != nil {
+		return nil, nil, err
+	}
+	for _, fix := range fixes {
+		logger.Warnf("* Dockerfile has syntax errors, recovered: %s", fix)
+	}
+	return df, fixes, nil
+}
+
+// syntaxError describes the errors of a Dockerfile that couldn't be parsed, one per line
+func syntaxError(path string, err error) error {
+	var syntaxErrs dockerfile.SyntaxErrors
+	if !errors.As(err, &syntaxErrs) {
+		return fmt.Errorf("Error parsing %s: %w", path, err)
+	}
+	msg := fmt.Sprintf("%s has %d syntax error(s):", path, len(syntaxErrs))
+	for _, e := range syntaxErrs {
+		if e.Line == 0 {
+			msg += fmt.Sprintf("\n  %s: %s", path, e.Message)
+		} else {
+			msg += fmt.Sprintf("\n  %s:%d:%d: %s", path, e.Line, e.Column, e.Message)
+		}
+	}
+	return errors.New(msg)
+}
+
+// readDockerignore reads the .dockerignore file at the given path.
+// nil is returned without any error if the file doesn't exist.
+func readDockerignore(path string) (*dockerignore.Dockerignore, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %w", path, err)
+	}
+	return dockerignore.NewDockerignore(string(content)), nil
+}
+
+// printDiff prints a unified diff, coloring added and removed lines
+func printDiff(d string) {
+	if d == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(d, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			color.New(color.Bold).Println(line)
+		case strings.HasPrefix(line, "@@"):
+			color.Cyan(line)
+		case strings.HasPrefix(line, "+"):
+			color.Green(line)
+		case strings.HasPrefix(line, "-"):
+			color.Red(line)
+		default:
+			fmt.Println(line)
+		}
+	}
+}
+
+// projectRelativePath returns path relative to the project directory, using forward slashes
+func projectRelativePath(projectDir, path string) string {
+	if filepath.IsAbs(path) {
+		if rel, err := filepath.Rel(projectDir, path); err == nil {
+			path = rel
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// sendReport delivers the report of a run to all the sinks configured by the user, and keeps it for --report.
+// Failing to deliver a report never fails the run.
+func sendReport(logger *log.Logger, cfg *config.Config, report *sinks.Report) {
+	collectReport(report)
+	if len(cfg.Reports) == 0 {
+		return
+	}
+	sink, err := sinks.FromConfig(cfg.Reports)
+	if err != nil {
+		logger.Warnf("* Invalid report configuration in %s: %v", config.Filename, err)
+	}
+	if len(sink.Sinks) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+	if err := sink.Send(ctx, report); err != nil {
+		logger.Warnf("* Failed to deliver report: %v", err)
+		return
+	}
+	logger.Debug("Delivered report", map[string]string{"sinks": strconv.Itoa(len(sink.Sinks))})
+}
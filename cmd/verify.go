@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/compose"
+	"github.com/duaraghav8/dockershrink/internal/docker"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/sandbox"
+	"github.com/duaraghav8/dockershrink/internal/units"
+	"github.com/duaraghav8/dockershrink/internal/verify"
+	"github.com/fatih/color"
+)
+
+// max time allowed for building both the original and optimized images
+const verifyBuildTimeout = 30 * time.Minute
+
+// number of lines of the build output shown when a build fails
+const buildOutputTailLines = 30
+
+// max length of the instruction shown next to each layer
+const layerCodeMaxLength = 70
+
+// max time the application may take to boot during a smoke test
+const smokeTestBootTimeout = time.Minute
+
+// verifyOptions decide how the changes made by dockershrink are verified
+type verifyOptions struct {
+	// smokeTest starts the optimized image and checks that the application boots
+	smokeTest bool
+	// smokeTestCommand is run inside the optimized container once it has booted
+	smokeTestCommand []string
+
+	// compose is only set if the dependencies of composeService must run during the smoke test
+	compose        *compose.File
+	composeService string
+}
+
+// loadComposeService loads the compose file and finds the service built from the Dockerfile.
+// The compose file is looked up in the current directory if path is empty.
+func loadComposeService(path, service, dockerfilePath string) (*compose.File, string, error) {
+	if path == "" {
+		if path = compose.Find("."); path == "" {
+			return nil, "", fmt.Errorf("No compose file found in the current directory, specify one with --compose-file")
+		}
+	}
+	file, err := compose.Load(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to load compose file: %w", err)
+	}
+
+	if service == "" {
+		s := file.ServiceForDockerfile(dockerfilePath)
+		if s == nil {
+			return nil, "", fmt.Errorf("No service in %s is built from %s, specify one with --compose-service", path, dockerfilePath)
+		}
+		service = s.Name
+	}
+	if err := file.Validate(service); err != nil {
+		return nil, "", err
+	}
+	return file, service, nil
+}
+
+// verifyChanges builds the original and optimized image definitions, prints their sizes
+// and optionally smoke tests the optimized image. The sizes of the images are returned, the original one is 0 if it failed to build.
+// ok is false if the optimized image definition fails verification.
+func verifyChanges(ctx context.Context, logger *log.Logger, contextDir string, original, optimized *verify.Definition, opts *verifyOptions) (originalSize, optimizedSize int64, ok bool) {
+	client, err := docker.NewClient()
+	if err != nil {
+		logger.Fatalf("Cannot verify the build: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, verifyBuildTimeout)
+	defer cancel()
+	if err := client.Ping(ctx); err != nil {
+		logger.Fatalf("Cannot verify the build: %v", err)
+	}
+
+	logger.Infof("\n* Building the original and optimized images to verify the changes, this may take a while")
+	report, err := verify.Builds(ctx, client, contextDir, original, optimized, logEvents(logger))
+	if err != nil {
+		logger.Fatalf("Error verifying the build: %v", err)
+	}
+	defer report.Cleanup(client)
+
+	fmt.Printf("\n============ Build Verification ============\n")
+	if report.Original.Err != nil {
+		logger.Warnf("The original Dockerfile failed to build (%v), its size cannot be compared", report.Original.Err)
+	}
+	if !report.OptimizedBuilds() {
+		color.Red("The optimized Dockerfile failed to build: %v", report.Optimized.Err)
+		fmt.Println(tail(report.Optimized.Output, buildOutputTailLines))
+		return 0, 0, false
+	}
+
+	if report.OriginalSize > 0 {
+		color.Cyan("Original image size: " + color.WhiteString(units.FormatBytes(report.OriginalSize)))
+	}
+	color.Cyan("Optimized image size: " + color.WhiteString(units.FormatBytes(report.OptimizedSize)))
+	if savings := report.Savings(); savings > 0 {
+		color.Green("Saved %s (%.1f%%)", units.FormatBytes(savings), float64(savings)*100/float64(report.OriginalSize))
+	} else if savings < 0 {
+		logger.Warnf("The optimized image is %s bigger than the original", units.FormatBytes(-savings))
+	}
+	printLayers(report)
+
+	if !opts.smokeTest {
+		return report.OriginalSize, report.OptimizedSize, true
+	}
+	return report.OriginalSize, report.OptimizedSize, smokeTest(ctx, logger, client, report, opts)
+}
+
+// printLayers prints the size of every layer of the original and optimized images
+// along with the Dockerfile instruction that created it.
+func printLayers(report *verify.BuildReport) {
+	if report.OptimizedLayers == nil {
+		return
+	}
+
+	fmt.Printf("\n============ Image Layers ============\n")
+	if o := report.OriginalLayers; o != nil {
+		n := report.OptimizedLayers
+		color.Cyan("Base image: " + color.WhiteString("%s (%s) -> %s (%s)", o.BaseImage, units.FormatBytes(o.BaseImageSize), n.BaseImage, units.FormatBytes(n.BaseImageSize)))
+		color.Cyan("Layers added by the Dockerfile: " + color.WhiteString("%s -> %s", units.FormatBytes(o.LayersSize()), units.FormatBytes(n.LayersSize())))
+		printBreakdown("Original image", o)
+	}
+	printBreakdown("Optimized image", report.OptimizedLayers)
+}
+
+// printBreakdown prints the layers of an image which have a size, metadata-only layers are skipped
+func printBreakdown(title string, b *verify.SizeBreakdown) {
+	fmt.Println("---------------------------------")
+	color.Cyan(title + ":")
+	fmt.Printf("%10s  base image %s\n", units.FormatBytes(b.BaseImageSize), b.BaseImage)
+	for _, l := range b.Layers {
+		if l.Size == 0 {
+			continue
+		}
+		code := strings.SplitN(l.Instruction.Original(), "\n", 2)[0]
+		if len(code) > layerCodeMaxLength {
+			code = code[:layerCodeMaxLength-3] + "..."
+		}
+		fmt.Printf("%10s  line %d: %s\n", units.FormatBytes(l.Size), l.Instruction.StartLine(), code)
+	}
+}
+
+// smokeTest runs the optimized image and checks that the application still works,
+// optionally alongside the compose services it depends on.
+func smokeTest(ctx context.Context, logger *log.Logger, client *docker.Client, report *verify.BuildReport, opts *verifyOptions) bool {
+	testOpts := &verify.SmokeTestOptions{
+		Command:     opts.smokeTestCommand,
+		BootTimeout: smokeTestBootTimeout,
+		Limits:      sandbox.DefaultLimits(),
+	}
+
+	sb := sandbox.New(client)
+	if opts.compose == nil {
+		passed, err := runSmokeTests(ctx, logger, sb, report, testOpts)
+		if err != nil {
+			logger.Fatalf("Error running smoke test: %v", err)
+		}
+		return passed
+	}
+
+	// an interrupt cancels the smoke test instead of killing dockershrink, so the dependencies are still torn down
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Infof("\n* Starting the services %s depends on", opts.composeService)
+	deps, err := verify.StartDependencies(ctx, client, opts.compose, opts.composeService)
+	passed := false
+	if err == nil {
+		passed, err = runSmokeTests(ctx, logger, sb, report, deps.SmokeTestOptions(testOpts))
+	}
+	// tear down before handling errors, logger.Fatalf exits without running deferred calls
+	if downErr := deps.Down(); downErr != nil {
+		logger.Warnf("%v, remove its containers manually", downErr)
+	}
+	if err != nil {
+		logger.Fatalf("Error running smoke test: %v", err)
+	}
+	return passed
+}
+
+// runSmokeTests smoke tests the optimized image.
+// If it fails, the original image is tested as well so that failures which
+// have nothing to do with the optimization don't fail the run.
+func runSmokeTests(ctx context.Context, logger *log.Logger, sb *sandbox.Sandbox, report *verify.BuildReport, testOpts *verify.SmokeTestOptions) (bool, error) {
+	logger.Infof("\n* Starting the optimized image to smoke test it")
+	optimized, err := verify.SmokeTest(ctx, sb, report.Optimized.ImageID, testOpts)
+	if err != nil {
+		return false, err
+	}
+	if optimized.Passed {
+		color.Green("Smoke test passed: %s", optimized.Reason)
+		return true, nil
+	}
+
+	if report.Original.Err == nil {
+		original, err := verify.SmokeTest(ctx, sb, report.Original.ImageID, testOpts)
+		if err == nil && !original.Passed {
+			logger.Warnf("Smoke test failed for both the original and optimized images (%s), ignoring it", optimized.Reason)
+			return true, nil
+		}
+	}
+
+	color.Red("Smoke test failed: %s", optimized.Reason)
+	if optimized.CommandResult != nil {
+		fmt.Println(tail(optimized.CommandResult.Stdout+optimized.CommandResult.Stderr, buildOutputTailLines))
+	} else if optimized.Logs != "" {
+		fmt.Println(tail(optimized.Logs, buildOutputTailLines))
+	}
+	return false, nil
+}
+
+// tail returns the last n lines of s
+func tail(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -14,6 +14,7 @@ var versionCmd = &cobra.Command{
 	Short: "Print the version number of DockerShrink",
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("DockerShrink CLI version:", Version)
+		setOutputData(map[string]string{"version": Version})
 	},
 }
 
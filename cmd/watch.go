@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/baseline"
+	"github.com/duaraghav8/dockershrink/internal/config"
+	"github.com/duaraghav8/dockershrink/internal/daemon"
+	"github.com/duaraghav8/dockershrink/internal/layerorder"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/output"
+	"github.com/duaraghav8/dockershrink/internal/project"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+	"github.com/duaraghav8/dockershrink/internal/targets"
+	"github.com/duaraghav8/dockershrink/internal/watch"
+	"github.com/fatih/color"
+)
+
+var watchMode bool
+
+// validateWatch returns an error if --watch is combined with flags that only make sense for a single run
+func validateWatch() error {
+	switch {
+	case multipleTargets():
+		return fmt.Errorf("--watch can't be used with --recursive or --target, run it from the directory of a single project")
+	case useDaemon:
+		return fmt.Errorf("--watch already keeps the analysis warm, it can't be used with --daemon")
+	case outputFormat != output.FormatText, reportFormat != "", ciFormat != "", writeBaselinePath != "":
+		return fmt.Errorf("--watch only prints to the terminal, it can't be used with --output, --report, --ci-format or --write-baseline")
+	}
+	return nil
+}
+
+// watchedFiles returns the files whose changes make the analysis of the target run again
+func watchedFiles(t *targets.Target) []string {
+	files := []string{t.Dockerfile, t.Dockerignore, filepath.Join(t.Dir, config.Filename)}
+	for _, m := range layerorder.Manifests {
+		files = append(files, filepath.Join(t.Dir, m))
+	}
+	return files
+}
+
+// runWatch analyzes the project in the current directory, then again whenever its Dockerfile, .dockerignore,
+// manifests or configuration change, printing what changed since the previous analysis. It returns when interrupted.
+func runWatch(ctx context.Context, logger *log.Logger) {
+	if err := validateWatch(); err != nil {
+		logger.Fatalf("%v", err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		logger.Fatalf("Error getting current working directory: %v", err)
+	}
+	cfg, err := loadConfig(cwd)
+	if err != nil {
+		logger.Fatalf("Error loading configuration: %v", err)
+	}
+
+	// the parsed Dockerfile and base image data are kept across runs, so that only what changed is loaded again
+	loader := daemon.NewWarm(&coldLoader{ctx: ctx, logger: logger, index: true})
+	t := projectTarget(cwd)
+	w, err := watch.New(watchedFiles(t)...)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	defer w.Close()
+
+	previous, err := analyzeTarget(ctx, logger, cfg, t, loader)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	applyBaseline(logger, previous)
+	printAnalysis(previous)
+
+	for {
+		logger.Infof("\n* Watching %s for changes, press Ctrl+C to stop", t.Dockerfile)
+		changed, err := w.Wait(ctx)
+		if err != nil {
+			return
+		}
+		fmt.Printf("\n* Changed: %s\n", strings.Join(changed, ", "))
+
+		if reloaded, err := loadConfig(cwd); err != nil {
+			logger.Warnf("Error loading configuration, keeping the previous one: %v", err)
+		} else {
+			cfg = reloaded
+		}
+		analysis, err := analyzeTarget(ctx, logger, cfg, t, loader)
+		if err != nil {
+			// eg- a Dockerfile saved halfway through an edit, the next save is analyzed again
+			logger.Warnf("%v", err)
+			continue
+		}
+		applyBaseline(logger, analysis)
+		printAnalysisChanges(previous, analysis)
+		previous = analysis
+	}
+}
+
+// printAnalysisChanges prints how the score and findings changed from one analysis of a project to the next
+func printAnalysisChanges(previous, current *project.AnalysisResponse) {
+	printScore(current.Score, rules.Breakdown(current.Findings))
+	if delta := current.Score - previous.Score; delta > 0 {
+		color.Green("+%d since the previous change", delta)
+	} else if delta < 0 {
+		color.Red("%d since the previous change", delta)
+	}
+
+	added, resolved := baseline.Compare(previous.Findings, current.Findings)
+	if len(added) == 0 && len(resolved) == 0 {
+		fmt.Printf("No new or resolved findings, %d finding(s) left\n", len(current.Findings))
+		return
+	}
+	for _, f := range resolved {
+		fmt.Printf("%s %s %s\n", color.GreenString("- resolved"), color.New(severityColors[f.Severity]).Sprintf("%s[%s]", f.Code, f.Severity), f.Title)
+	}
+	for _, f := range added {
+		location := f.Filepath
+		if f.Line > 0 {
+			location = fmt.Sprintf("%s:%d", f.Filepath, f.Line)
+		}
+		fmt.Printf("%s %s: %s %s\n", color.RedString("+ new"), color.BlueString(location), color.New(severityColors[f.Severity]).Sprintf("%s[%s]", f.Code, f.Severity), f.Title)
+	}
+	fmt.Printf("%d new, %d resolved, %d finding(s) left\n", len(added), len(resolved), len(current.Findings))
+}
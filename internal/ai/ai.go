@@ -1,8 +1,20 @@
 package ai
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/ai/promptcreator"
+	"github.com/duaraghav8/dockershrink/internal/docs"
 	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/ratelimit"
+	"github.com/duaraghav8/dockershrink/pkg/events"
 	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
 )
 
 const (
@@ -12,13 +24,123 @@ const (
 )
 
 type AIService struct {
-	L      *log.Logger
-	client *openai.Client
+	L *log.Logger
+	// Model answers every request, NewAIService sets it to OpenAIPreferredModel
+	Model openai.ChatModel
+	// Budget caps the tokens and cost of the requests, they're unlimited if it's nil
+	Budget *Budget
+	// Limiter paces the requests, eg- when optimizing many Dockerfiles at once. They aren't paced if it's nil.
+	Limiter *ratelimit.Limiter
+	// Events receives tool calls and token usage, events are discarded if it's nil
+	Events events.Handler
+	// Docs is searched by the get_documentation tool, the tool isn't offered to the LLM if it's nil
+	Docs *docs.Index
+	// Prompts override the embedded prompts, see LoadPrompts
+	Prompts Prompts
+	// FileLimits caps how much of the files the LLM reads is sent to it, DefaultFileLimits if it's not set
+	FileLimits FileLimits
+	client     *openai.Client
 }
 
 func NewAIService(logger *log.Logger, client *openai.Client) *AIService {
 	return &AIService{
 		L:      logger,
+		Model:  OpenAIPreferredModel,
 		client: client,
 	}
 }
+
+// complete sends a request to the LLM, unless the budget is already spent
+func (ai *AIService) complete(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+	if err := ai.Budget.Check(); err != nil {
+		return nil, err
+	}
+	if err := ai.Limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	// the client retries failed requests itself, every attempt goes through the middleware
+	attempts := 0
+	countAttempts := option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		attempts++
+		return next(req)
+	})
+	start := time.Now()
+	response, err := ai.client.Chat.Completions.New(ctx, params, countAttempts)
+	finished := events.LLMRequestFinished{Model: string(params.Model.Value), Retries: max(0, attempts-1), Duration: time.Since(start)}
+	if err != nil {
+		finished.Err = err
+		ai.Events.Emit(finished)
+		return nil, fmt.Errorf("failed to get chat completion: %w", err)
+	}
+	ai.tokensReceived(response)
+	finished.Model = response.Model
+	finished.PromptTokens = response.Usage.PromptTokens
+	finished.CompletionTokens = response.Usage.CompletionTokens
+	ai.Events.Emit(finished)
+	return response, nil
+}
+
+// tokensReceived reports the tokens used by a response from the LLM and charges them to the budget
+func (ai *AIService) tokensReceived(response *openai.ChatCompletion) {
+	ai.Budget.Add(response.Model, response.Usage.PromptTokens, response.Usage.CompletionTokens)
+	ai.Events.Emit(events.LLMTokensReceived{
+		Model:            response.Model,
+		PromptTokens:     response.Usage.PromptTokens,
+		CompletionTokens: response.Usage.CompletionTokens,
+	})
+}
+
+// getDocumentation runs the get_documentation tool and returns the response for the LLM.
+// If embeddings can't be computed, eg- because the provider is unreachable, the documentation is searched by keywords.
+func (ai *AIService) getDocumentation(ctx context.Context, arguments string) (string, error) {
+	var extractedParams struct {
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal([]byte(arguments), &extractedParams); err != nil {
+		return "", fmt.Errorf("failed to parse %s function call arguments (%s) from LLM: %w", ToolGetDocumentation, arguments, err)
+	}
+	if strings.TrimSpace(extractedParams.Query) == "" {
+		return ToolGetDocumentationNoQueryPrompt, nil
+	}
+
+	passages, err := ai.Docs.Search(ctx, extractedParams.Query, DocumentationResults)
+	if err != nil {
+		ai.L.Debug("Failed to search documentation with embeddings, searching by keywords instead", map[string]string{
+			"provider": ai.Docs.Provider(),
+			"error":    err.Error(),
+		})
+		passages = ai.Docs.SearchKeywords(extractedParams.Query, DocumentationResults)
+	}
+	ai.L.Debug("Tool info", map[string]string{
+		"tool":     ToolGetDocumentation,
+		"query":    extractedParams.Query,
+		"passages": fmt.Sprintf("%d", len(passages)),
+	})
+	if len(passages) == 0 {
+		return ToolGetDocumentationNoResultsPrompt, nil
+	}
+
+	response := ""
+	for _, p := range passages {
+		passagePrompt, err := promptcreator.ConstructPrompt(ToolGetDocumentationResponsePassagePrompt, map[string]string{
+			"Title": p.Title,
+			"Text":  p.Text,
+		})
+		if err != nil {
+			return "", err
+		}
+		response += passagePrompt
+	}
+	return response, nil
+}
+
+// documentationCapabilityPrompt describes the get_documentation tool in the system prompt, if it's offered
+func (ai *AIService) documentationCapabilityPrompt() (string, error) {
+	if ai.Docs == nil {
+		return "", nil
+	}
+	return promptcreator.ConstructPrompt(CapabilityGetDocumentationPrompt, map[string]string{
+		"Backtick":             "`",
+		"ToolGetDocumentation": ToolGetDocumentation,
+	})
+}
@@ -0,0 +1,103 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrBudgetExceeded is returned instead of calling the LLM once the budget of the run is spent
+var ErrBudgetExceeded = errors.New("LLM budget exceeded")
+
+// modelPrice is the price of a model in US dollars per million tokens
+type modelPrice struct {
+	prompt     float64
+	completion float64
+}
+
+// modelPrices are the prices of OpenAI's models, keyed by the prefix of their names.
+// Longer prefixes are more specific, eg- gpt-4o-mini isn't priced like gpt-4o.
+var modelPrices = map[string]modelPrice{
+	"gpt-4o-mini":   {prompt: 0.15, completion: 0.60},
+	"gpt-4o":        {prompt: 2.50, completion: 10.00},
+	"gpt-4.1-nano":  {prompt: 0.10, completion: 0.40},
+	"gpt-4.1-mini":  {prompt: 0.40, completion: 1.60},
+	"gpt-4.1":       {prompt: 2.00, completion: 8.00},
+	"gpt-4-turbo":   {prompt: 10.00, completion: 30.00},
+	"gpt-3.5-turbo": {prompt: 0.50, completion: 1.50},
+	"o3-mini":       {prompt: 1.10, completion: 4.40},
+	"o4-mini":       {prompt: 1.10, completion: 4.40},
+}
+
+// priceOf returns the price of the model, false if it isn't known
+func priceOf(model string) (modelPrice, bool) {
+	var price modelPrice
+	longest := 0
+	for prefix, p := range modelPrices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > longest {
+			price, longest = p, len(prefix)
+		}
+	}
+	return price, longest > 0
+}
+
+// HasKnownPrice returns true if the cost of the model's requests can be estimated
+func HasKnownPrice(model string) bool {
+	_, ok := priceOf(model)
+	return ok
+}
+
+// Budget caps the tokens used by the requests of a run and their estimated cost.
+// Requests are answered until a limit is reached, so the last one may exceed it.
+// A nil Budget is unlimited.
+type Budget struct {
+	// MaxTokens caps the prompt and completion tokens, 0 means no limit
+	MaxTokens int64
+	// MaxCostUSD caps the estimated cost in US dollars, 0 means no limit.
+	// Requests to models whose price isn't known cost nothing.
+	MaxCostUSD float64
+
+	mu      sync.Mutex
+	tokens  int64
+	costUSD float64
+}
+
+// Add charges the tokens of a response from the model to the budget
+func (b *Budget) Add(model string, promptTokens, completionTokens int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens += promptTokens + completionTokens
+	if price, ok := priceOf(model); ok {
+		b.costUSD += (float64(promptTokens)*price.prompt + float64(completionTokens)*price.completion) / 1e6
+	}
+}
+
+// Check returns ErrBudgetExceeded if a limit is reached
+func (b *Budget) Check() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.MaxTokens > 0 && b.tokens >= b.MaxTokens {
+		return fmt.Errorf("%w: %d tokens used, the limit is %d", ErrBudgetExceeded, b.tokens, b.MaxTokens)
+	}
+	if b.MaxCostUSD > 0 && b.costUSD >= b.MaxCostUSD {
+		return fmt.Errorf("%w: $%.4f spent, the limit is $%.2f", ErrBudgetExceeded, b.costUSD, b.MaxCostUSD)
+	}
+	return nil
+}
+
+// Spent returns the tokens used so far and their estimated cost in US dollars
+func (b *Budget) Spent() (int64, float64) {
+	if b == nil {
+		return 0, 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens, b.costUSD
+}
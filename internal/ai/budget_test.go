@@ -0,0 +1,41 @@
+package ai
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBudget(t *testing.T) {
+	tests := []struct {
+		name     string
+		budget   *Budget
+		model    string
+		exceeded bool
+	}{
+		{name: "unlimited", budget: nil, model: "gpt-4o-2024-08-06"},
+		{name: "under the token limit", budget: &Budget{MaxTokens: 2000}, model: "gpt-4o-2024-08-06"},
+		{name: "token limit", budget: &Budget{MaxTokens: 1500}, model: "gpt-4o-2024-08-06", exceeded: true},
+		// 1000 prompt and 500 completion tokens of gpt-4o cost $0.0075
+		{name: "under the cost limit", budget: &Budget{MaxCostUSD: 0.01}, model: "gpt-4o-2024-08-06"},
+		{name: "cost limit", budget: &Budget{MaxCostUSD: 0.005}, model: "gpt-4o-2024-08-06", exceeded: true},
+		// gpt-4o-mini is priced on its own, not like gpt-4o
+		{name: "cheaper model", budget: &Budget{MaxCostUSD: 0.005}, model: "gpt-4o-mini"},
+		{name: "unknown price", budget: &Budget{MaxCostUSD: 0.0001}, model: "llama3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.budget.Check(); err != nil {
+				t.Fatalf("expected an unspent budget, got %v", err)
+			}
+			tt.budget.Add(tt.model, 1000, 500)
+			err := tt.budget.Check()
+			if exceeded := errors.Is(err, ErrBudgetExceeded); exceeded != tt.exceeded {
+				t.Errorf("Check() = %v; want exceeded %v", err, tt.exceeded)
+			}
+		})
+	}
+
+	if HasKnownPrice("llama3") || !HasKnownPrice("gpt-4o-2024-08-06") {
+		t.Errorf("unexpected known prices")
+	}
+}
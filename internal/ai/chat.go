@@ -0,0 +1,189 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/ai/promptcreator"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+	"github.com/openai/openai-go"
+)
+
+// Conversation is an ongoing chat with the LLM about the Dockerfile of a project, started with StartChat.
+// Every message is kept across turns, so the LLM knows what the user asked for, what it proposed
+// and which of its proposals were applied.
+type Conversation struct {
+	ai        *AIService
+	directory *restrictedfilesystem.RestrictedFilesystem
+	messages  []openai.ChatCompletionMessageParamUnion
+	// dockerfile is the Dockerfile as the user has it, with the proposals they applied
+	dockerfile string
+	// proposed is the Dockerfile proposed in the last turn, empty if it isn't waiting to be applied or rejected
+	proposed string
+}
+
+// StartChat starts a conversation about the project's Dockerfile.
+// The project is described to the LLM the same way as for an optimization, no request is sent until the first message.
+func (ai *AIService) StartChat(req *OptimizeRequest) (*Conversation, error) {
+	systemInstructions, err := ai.constructChatSystemInstructions(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct system prompt: %w", err)
+	}
+	projectContext, err := ai.constructOptimizeUserQuery(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct user prompt: %w", err)
+	}
+	return &Conversation{
+		ai:        ai,
+		directory: req.ProjectDirectory,
+		messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(systemInstructions),
+			openai.UserMessage(projectContext),
+		},
+		dockerfile: req.Dockerfile,
+	}, nil
+}
+
+// Dockerfile returns the Dockerfile with every proposal applied so far
+func (c *Conversation) Dockerfile() string {
+	return c.dockerfile
+}
+
+// Send sends the user's message and returns the LLM's reply, along with the changes it proposes, if any.
+// A proposal that wasn't applied before the next message is considered rejected.
+// If the request fails, the message is left out of the conversation so that it can be sent again.
+func (c *Conversation) Send(ctx context.Context, message string) (*ChatResponse, error) {
+	if strings.TrimSpace(message) == "" {
+		return nil, errors.New("no message given")
+	}
+	if c.proposed != "" {
+		c.Apply(c.dockerfile)
+	}
+
+	turnStart := len(c.messages)
+	c.messages = append(c.messages, openai.UserMessage(message))
+	resp, err := c.reply(ctx)
+	if err != nil {
+		c.messages = c.messages[:turnStart]
+		return nil, err
+	}
+	if resp.Dockerfile == c.dockerfile {
+		// the LLM returned the Dockerfile unchanged, there's nothing to apply
+		resp.Dockerfile, resp.ActionsTaken = "", nil
+	}
+	c.proposed = resp.Dockerfile
+	return resp, nil
+}
+
+// reply runs the LLM's tool calls until it replies to the last message
+func (c *Conversation) reply(ctx context.Context) (*ChatResponse, error) {
+	for i := 0; i < MaxLLMCalls; i++ {
+		params := openai.ChatCompletionNewParams{
+			Messages:       openai.F(c.messages),
+			Tools:          openai.F(c.ai.tools()),
+			ResponseFormat: openai.F(chatOutput.OpenAIResponseFormat()),
+			Model:          openai.F(c.ai.Model),
+		}
+		response, err := c.ai.complete(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		message := response.Choices[0].Message
+		// the reply is part of the conversation, whether it's a tool call or the answer
+		c.messages = append(c.messages, message)
+
+		if len(message.ToolCalls) > 0 {
+			for _, toolCall := range message.ToolCalls {
+				responsePrompt, err := c.ai.callTool(ctx, c.directory, toolCall)
+				if err != nil {
+					return nil, err
+				}
+				c.messages = append(c.messages, openai.ToolMessage(toolCall.ID, responsePrompt))
+			}
+			continue
+		}
+
+		chatResponse, err := chatOutput.Parse(message.Content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse response from LLM: %w", err)
+		}
+		c.ai.L.Debug("Received chat reply from LLM", map[string]string{
+			"reply":      chatResponse.Reply,
+			"dockerfile": chatResponse.Dockerfile,
+		})
+		if chatResponse.Dockerfile != "" {
+			if ok, err := dockerfile.Validate(chatResponse.Dockerfile); !ok {
+				data := map[string]string{"error": err.Error()}
+				c.ai.L.Debug("LLM returned an invalid Dockerfile", data)
+				feedback, _ := promptcreator.ConstructPrompt(InvalidDockerfileInResponsePrompt, data)
+				c.messages = append(c.messages, openai.SystemMessage(feedback))
+				continue
+			}
+		}
+		for _, a := range chatResponse.ActionsTaken {
+			a.Risk = a.Risk.Normalize()
+		}
+		return chatResponse, nil
+	}
+	return nil, fmt.Errorf("Maximum number of LLM calls reached")
+}
+
+// Apply records the Dockerfile the user ended up with after reviewing the last proposal:
+// the proposal itself, the Dockerfile as it was if they rejected it, or anything in between.
+// The LLM is told about it along with the next message.
+func (c *Conversation) Apply(applied string) {
+	if c.proposed == "" {
+		c.dockerfile = applied
+		return
+	}
+
+	var message string
+	switch applied {
+	case c.proposed:
+		message = ChatProposalAppliedPrompt
+	case c.dockerfile:
+		message = ChatProposalRejectedPrompt
+	default:
+		message, _ = promptcreator.ConstructPrompt(ChatProposalPartiallyAppliedPrompt, map[string]string{
+			"TripleBackticks": "```",
+			"Dockerfile":      applied,
+		})
+	}
+	c.messages = append(c.messages, openai.UserMessage(message))
+	c.dockerfile, c.proposed = applied, ""
+}
+
+func (ai *AIService) constructChatSystemInstructions(req *OptimizeRequest) (string, error) {
+	data := map[string]string{
+		"Backtick":              "`",
+		"TripleBackticks":       "```",
+		"ToolReadFiles":         ToolReadFiles,
+		"ToolDeveloperFeedback": ToolDeveloperFeedback,
+		"RuleBaseImages":        "",
+	}
+	capability, err := ai.documentationCapabilityPrompt()
+	if err != nil {
+		return "", err
+	}
+	data["CapabilityGetDocumentation"] = capability
+
+	goal := req.Goal
+	if goal == "" {
+		goal = models.GoalAll
+	}
+	if req.BaseImages != "" && goal.Includes(models.GoalSize, models.GoalSecurity) {
+		data["BaseImageSummary"] = strings.TrimSpace(req.BaseImages)
+		data["RuleBaseImages"], _ = promptcreator.ConstructPrompt(ai.prompt("RuleBaseImagesPrompt"), data)
+	}
+	if data["RuleOrganizationPolicy"], err = ai.constructOrganizationPolicyPrompt(req.Policies); err != nil {
+		return "", err
+	}
+	if data["OptimizationGoal"], err = promptcreator.ConstructPrompt(ai.prompt(optimizationGoalPrompts[goal]), data); err != nil {
+		return "", err
+	}
+	return promptcreator.ConstructPrompt(ai.prompt("ChatSystemPrompt"), data)
+}
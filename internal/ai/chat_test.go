@@ -0,0 +1,150 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// chatServer replies to chat completions with the given messages in order, and records the requests it received
+type chatServer struct {
+	replies  []string
+	requests []string
+}
+
+func (s *chatServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.requests = append(s.requests, string(body))
+	if len(s.replies) == 0 {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": {"message": "no more replies"}}`))
+		return
+	}
+	message := s.replies[0]
+	s.replies = s.replies[1:]
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"id": "1", "object": "chat.completion", "created": 1, "model": "gpt-4o", "choices": [{"index": 0, "finish_reason": "stop", "message": ` + message + `}]}`))
+}
+
+func chatReply(t *testing.T, resp ChatResponse) string {
+	content, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return `{"role": "assistant", "content": ` + strconv.Quote(string(content)) + `}`
+}
+
+// escaped returns s as it appears in a JSON request
+func escaped(s string) string {
+	quoted := strconv.Quote(s)
+	return quoted[1 : len(quoted)-1]
+}
+
+func TestConversation(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.js"), []byte("require('express')"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	original := "FROM node:20\nCOPY . .\nRUN npm install\n"
+	proposed := "FROM node:20-bookworm-slim\nCOPY . .\nRUN npm install --omit=dev\n"
+
+	server := &chatServer{replies: []string{
+		`{"role": "assistant", "content": null, "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "read_files", "arguments": "{\"filepaths\": [\"index.js\"]}"}}]}`,
+		chatReply(t, ChatResponse{Reply: "Kept debian and left out the devDependencies.", Dockerfile: proposed, ActionsTaken: []*models.OptimizationAction{
+			{Rule: "exclude-devdependencies", Filepath: "Dockerfile", Line: 3, Title: "Install only production dependencies", Description: "Added --omit=dev", Risk: models.RiskSize},
+		}}),
+		chatReply(t, ChatResponse{Reply: "The image runs index.js with node.", Dockerfile: proposed, ActionsTaken: []*models.OptimizationAction{}}),
+	}}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	client := openai.NewClient(option.WithAPIKey("test"), option.WithBaseURL(httpServer.URL), option.WithMaxRetries(0))
+	ai := NewAIService(log.NewLogger(false), client)
+
+	c, err := ai.StartChat(&OptimizeRequest{
+		Dockerfile:       original,
+		ProjectDirectory: restrictedfilesystem.NewRestrictedFilesystem(dir, "", "Dockerfile", ""),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp, err := c.Send(context.Background(), "keep the debian base but shrink node_modules")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Dockerfile != proposed || len(resp.ActionsTaken) != 1 {
+		t.Errorf("expected the proposal and its action, got %+v", resp)
+	}
+	if !strings.Contains(server.requests[1], "require('express')") {
+		t.Errorf("expected the file read by the LLM to be sent to it, got %s", server.requests[1])
+	}
+	c.Apply(proposed)
+	if c.Dockerfile() != proposed {
+		t.Errorf("expected the applied proposal to be the current Dockerfile, got %q", c.Dockerfile())
+	}
+
+	// the second turn continues the conversation, and the same Dockerfile isn't proposed again
+	resp, err = c.Send(context.Background(), "what does the image run?")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Dockerfile != "" || resp.Reply == "" {
+		t.Errorf("expected only a reply, got %+v", resp)
+	}
+	for _, expected := range []string{"keep the debian base but shrink node_modules", "Kept debian and left out the devDependencies.", ChatProposalAppliedPrompt, "what does the image run?"} {
+		if !strings.Contains(server.requests[2], escaped(expected)) {
+			t.Errorf("expected %q to be part of the conversation, got %s", expected, server.requests[2])
+		}
+	}
+
+	// a failed request leaves the message out of the conversation
+	messages := len(c.messages)
+	if _, err := c.Send(context.Background(), "use distroless"); err == nil {
+		t.Fatal("expected the request to fail")
+	}
+	if len(c.messages) != messages {
+		t.Errorf("expected the conversation to be left as it was, got %d messages instead of %d", len(c.messages), messages)
+	}
+}
+
+func TestConversation_Apply(t *testing.T) {
+	original, proposed, partial := "FROM node:20\n", "FROM node:20-slim\nUSER node\n", "FROM node:20-slim\n"
+	tests := []struct {
+		name     string
+		applied  string
+		expected string
+	}{
+		{name: "applied", applied: proposed, expected: ChatProposalAppliedPrompt},
+		{name: "rejected", applied: original, expected: ChatProposalRejectedPrompt},
+		{name: "partially applied", applied: partial, expected: "```\nFROM node:20-slim\n\n```"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Conversation{dockerfile: original, proposed: proposed}
+			c.Apply(tt.applied)
+			if len(c.messages) != 1 {
+				t.Fatalf("expected the LLM to be told about the proposal, got %d messages", len(c.messages))
+			}
+			content, _ := json.Marshal(c.messages[0])
+			if !strings.Contains(string(content), escaped(tt.expected)) {
+				t.Errorf("expected the message to contain %q, got %s", tt.expected, content)
+			}
+			if c.Dockerfile() != tt.applied || c.proposed != "" {
+				t.Errorf("expected %q to be the current Dockerfile with no pending proposal, got %q", tt.applied, c.Dockerfile())
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package ai
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/duaraghav8/dockershrink/internal/packagelock"
+)
+
+const (
+	// sniffSize is the size of the beginning of a file looked at to tell what it is
+	sniffSize = 8 * 1024
+	// minifiedLineLength is the average length of the lines of minified code, which people don't write
+	minifiedLineLength = 500
+)
+
+// bundleMarkers are found at the beginning of bundles written by bundlers, eg- webpack
+var bundleMarkers = []string{"webpackBootstrap", "__webpack_require__", "/******/", "parcelRequire", "__vite__"}
+
+// generatedMarkers are the comments tools put at the beginning of the files they generate
+var generatedMarkers = []string{"Code generated", "@generated", "DO NOT EDIT", "auto-generated", "autogenerated"}
+
+// scriptExtensions are the extensions of the files minified and bundled by bundlers
+var scriptExtensions = map[string]bool{".js": true, ".mjs": true, ".cjs": true, ".css": true}
+
+// generatedKind describes the file if its content is of no use to the LLM, eg- "a binary file (image/png)" or
+// "minified code", "" otherwise. Lockfiles and files with a "generated" comment are only described this way
+// if they're larger than limit, since small ones are worth reading.
+func generatedKind(filepath, content string, limit int) string {
+	head := content[:min(len(content), sniffSize)]
+	if strings.IndexByte(head, 0) >= 0 || !validUTF8Prefix(head, len(head) < len(content)) {
+		contentType := strings.Split(http.DetectContentType([]byte(head)), ";")[0]
+		if strings.HasPrefix(contentType, "text/") {
+			// eg- text that isn't UTF-8, which can't be sent as it is either
+			contentType = "application/octet-stream"
+		}
+		return fmt.Sprintf("a binary file (%s)", contentType)
+	}
+
+	name := path.Base(filepath)
+	ext := strings.ToLower(path.Ext(name))
+	switch {
+	case ext == ".map":
+		return "a source map"
+	case packagelock.IsLockfile(name) && len(content) > limit:
+		return "a lockfile"
+	}
+
+	if scriptExtensions[ext] {
+		if strings.Contains(name, ".min.") || len(content)/(strings.Count(content, "\n")+1) > minifiedLineLength {
+			return "minified code"
+		}
+		for _, m := range bundleMarkers {
+			if strings.Contains(head, m) {
+				return "a bundle generated by a bundler"
+			}
+		}
+	}
+	if len(content) > limit {
+		firstLines := head[:min(len(head), 1024)]
+		for _, m := range generatedMarkers {
+			if strings.Contains(firstLines, m) {
+				return "a generated file"
+			}
+		}
+	}
+	return ""
+}
+
+// validUTF8Prefix returns true if s is valid UTF-8. If s was cut from a longer text, the rune it may have
+// been cut in the middle of is ignored.
+func validUTF8Prefix(s string, cut bool) bool {
+	if cut {
+		for i := len(s) - 1; i >= 0 && i >= len(s)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(s[i]) {
+				if !utf8.FullRuneInString(s[i:]) {
+					s = s[:i]
+				}
+				break
+			}
+		}
+	}
+	return utf8.ValidString(s)
+}
+
+// fileMetadata returns what is sent to the LLM in place of the content of a file that isn't worth reading
+func fileMetadata(kind, content string) string {
+	return fmt.Sprintf("[Not shown: the file is %s, %s, sha256 %x. Its content isn't useful to read, read the files it's made from instead, if there are any.]",
+		kind, formatKB(len(content)), sha256.Sum256([]byte(content)))
+}
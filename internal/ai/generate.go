@@ -10,11 +10,12 @@ import (
 
 	"github.com/duaraghav8/dockershrink/internal/ai/promptcreator"
 	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/pkg/events"
 	"github.com/openai/openai-go"
 )
 
-func (ai *AIService) GenerateDockerfile(req *GenerateRequest) (string, error) {
-	systemInstructions, err := ai.constructGenerateSystemInstructions()
+func (ai *AIService) GenerateDockerfile(ctx context.Context, req *GenerateRequest) (string, error) {
+	systemInstructions, err := ai.constructGenerateSystemInstructions(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to construct system prompt: %w", err)
 	}
@@ -29,22 +30,11 @@ func (ai *AIService) GenerateDockerfile(req *GenerateRequest) (string, error) {
 		openai.SystemMessage(systemInstructions),
 		openai.UserMessage(userQuery),
 	}
-	responseFormat := openai.ResponseFormatJSONSchemaJSONSchemaParam{
-		Name:        openai.F("generated_asset"),
-		Description: openai.F("Dockerfile generated for the project along with any comments you would like to add"),
-		Schema:      openai.F(generateResponseSchema),
-		Strict:      openai.Bool(true),
-	}
 	params := openai.ChatCompletionNewParams{
-		Messages: openai.F(messages),
-		Tools:    openai.F(availableTools),
-		ResponseFormat: openai.F[openai.ChatCompletionNewParamsResponseFormatUnion](
-			openai.ResponseFormatJSONSchemaParam{
-				Type:       openai.F(openai.ResponseFormatJSONSchemaTypeJSONSchema),
-				JSONSchema: openai.F(responseFormat),
-			},
-		),
-		Model: openai.F(OpenAIPreferredModel),
+		Messages:       openai.F(messages),
+		Tools:          openai.F(ai.tools()),
+		ResponseFormat: openai.F(generateOutput.OpenAIResponseFormat()),
+		Model:          openai.F(ai.Model),
 	}
 
 	for i := 0; i < MaxLLMCalls; i++ {
@@ -55,9 +45,9 @@ func (ai *AIService) GenerateDockerfile(req *GenerateRequest) (string, error) {
 			},
 		)
 
-		response, err := ai.client.Chat.Completions.New(context.Background(), params)
+		response, err := ai.complete(ctx, params)
 		if err != nil {
-			return "", fmt.Errorf("failed to get chat completion: %w", err)
+			return "", err
 		}
 
 		ai.L.Debug("Received response from LLM", map[string]string{
@@ -69,8 +59,7 @@ func (ai *AIService) GenerateDockerfile(req *GenerateRequest) (string, error) {
 		if len(toolCalls) == 0 {
 			ai.L.Debug("Response contains final generated Dockerfile", nil)
 
-			generateResponse := GenerateResponse{}
-			err = json.Unmarshal([]byte(response.Choices[0].Message.Content), &generateResponse)
+			generateResponse, err := generateOutput.Parse(response.Choices[0].Message.Content)
 			if err != nil {
 				return "", fmt.Errorf("failed to parse final response from LLM: %w", err)
 			}
@@ -105,6 +94,8 @@ func (ai *AIService) GenerateDockerfile(req *GenerateRequest) (string, error) {
 			params.Messages.Value = append(params.Messages.Value, response.Choices[0].Message)
 
 			for _, toolCall := range toolCalls {
+				ai.Events.Emit(events.ToolCallStarted{Tool: toolCall.Function.Name, Arguments: toolCall.Function.Arguments})
+
 				if toolCall.Function.Name == ToolReadFiles {
 					var extractedParams struct {
 						Filepaths []string `json:"filepaths"`
@@ -119,6 +110,7 @@ func (ai *AIService) GenerateDockerfile(req *GenerateRequest) (string, error) {
 							params.Messages.Value,
 							openai.ToolMessage(toolCall.ID, ToolReadFilesNoFilesSpecifiedPrompt),
 						)
+						ai.Events.Emit(events.ToolCallFinished{Tool: toolCall.Function.Name})
 						continue
 					}
 
@@ -130,7 +122,7 @@ func (ai *AIService) GenerateDockerfile(req *GenerateRequest) (string, error) {
 						},
 					)
 
-					projectFiles, err := req.ProjectDirectory.ReadFiles(extractedParams.Filepaths)
+					projectFiles, err := req.ProjectDirectory.ReadFiles(ctx, extractedParams.Filepaths)
 					if err != nil {
 						// If no such file or directory was found, the LLM probably hallucinated and gave an incorrect filepath.
 						// Send feedback to it.
@@ -152,29 +144,14 @@ func (ai *AIService) GenerateDockerfile(req *GenerateRequest) (string, error) {
 							)
 
 							params.Messages.Value = append(params.Messages.Value, openai.ToolMessage(toolCall.ID, fileNotFoundPrompt))
+							ai.Events.Emit(events.ToolCallFinished{Tool: toolCall.Function.Name, Err: err})
 							continue
 						}
 
 						return "", fmt.Errorf("failed to read file(s) from the project requested by LLM: %w", err)
 					}
 
-					responsePrompt := ""
-					for path, content := range projectFiles {
-						var filePrompt string
-
-						if len(strings.TrimSpace(content)) == 0 {
-							filePrompt = fmt.Sprintf("%s\n[File is empty]\n\n", path)
-						} else {
-							data := map[string]string{
-								"TripleBackticks": "```",
-								"Filepath":        path,
-								"Content":         content,
-							}
-							filePrompt, _ = promptcreator.ConstructPrompt(ToolReadFilesResponseSingleFilePrompt, data)
-						}
-
-						responsePrompt += filePrompt
-					}
+					responsePrompt := readFilesResponse(extractedParams.Filepaths, projectFiles, ai.fileLimits())
 
 					ai.L.Debug(
 						fmt.Sprintf("Tool %s response: [Sending back the files requested by LLM]", ToolReadFiles),
@@ -182,6 +159,17 @@ func (ai *AIService) GenerateDockerfile(req *GenerateRequest) (string, error) {
 					)
 
 					params.Messages.Value = append(params.Messages.Value, openai.ToolMessage(toolCall.ID, responsePrompt))
+					ai.Events.Emit(events.ToolCallFinished{Tool: toolCall.Function.Name})
+					continue
+				}
+
+				if toolCall.Function.Name == ToolGetDocumentation {
+					responsePrompt, err := ai.getDocumentation(ctx, toolCall.Function.Arguments)
+					if err != nil {
+						return "", err
+					}
+					params.Messages.Value = append(params.Messages.Value, openai.ToolMessage(toolCall.ID, responsePrompt))
+					ai.Events.Emit(events.ToolCallFinished{Tool: toolCall.Function.Name})
 					continue
 				}
 
@@ -206,6 +194,7 @@ func (ai *AIService) GenerateDockerfile(req *GenerateRequest) (string, error) {
 					)
 
 					params.Messages.Value = append(params.Messages.Value, openai.ToolMessage(toolCall.ID, extractedParams.Feedback))
+					ai.Events.Emit(events.ToolCallFinished{Tool: toolCall.Function.Name})
 				}
 			}
 		}
@@ -214,14 +203,23 @@ func (ai *AIService) GenerateDockerfile(req *GenerateRequest) (string, error) {
 	return "", fmt.Errorf("Maximum number of LLM calls reached")
 }
 
-func (ai *AIService) constructGenerateSystemInstructions() (string, error) {
+func (ai *AIService) constructGenerateSystemInstructions(req *GenerateRequest) (string, error) {
 	data := map[string]string{
 		"Backtick":              "`",
 		"TripleBackticks":       "```",
 		"ToolReadFiles":         ToolReadFiles,
 		"ToolDeveloperFeedback": ToolDeveloperFeedback,
+		"RuleMonorepoPruning":   ai.constructMonorepoPruningPrompt(req.Workspace, req.WorkspacePackage),
 	}
-	return promptcreator.ConstructPrompt(GenerateRequestSystemPrompt, data)
+	capability, err := ai.documentationCapabilityPrompt()
+	if err != nil {
+		return "", err
+	}
+	data["CapabilityGetDocumentation"] = capability
+	if data["RuleOrganizationPolicy"], err = ai.constructOrganizationPolicyPrompt(req.Policies); err != nil {
+		return "", err
+	}
+	return promptcreator.ConstructPrompt(ai.prompt("GenerateRequestSystemPrompt"), data)
 }
 
 func (ai *AIService) constructGenerateUserQuery(req *GenerateRequest) (string, error) {
@@ -230,6 +228,10 @@ func (ai *AIService) constructGenerateUserQuery(req *GenerateRequest) (string, e
 		"TripleBackticks": "```",
 		"DirTree":         req.ProjectDirectory.DirTree(),
 		"PackageJSON":     req.PackageJSON,
+		"ProjectInfo":     "",
+	}
+	if req.ProjectInfo != nil {
+		data["ProjectInfo"] = req.ProjectInfo.Summary()
 	}
-	return promptcreator.ConstructPrompt(GenerateRequestUserPrompt, data)
+	return promptcreator.ConstructPrompt(ai.prompt("GenerateRequestUserPrompt"), data)
 }
@@ -1,9 +1,11 @@
 package ai
 
 import (
+	"github.com/duaraghav8/dockershrink/internal/ai/structured"
 	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/projectinfo"
 	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
-	"github.com/invopop/jsonschema"
+	"github.com/duaraghav8/dockershrink/internal/workspace"
 )
 
 type OptimizeRequest struct {
@@ -13,6 +15,22 @@ type OptimizeRequest struct {
 
 	DockerfileStageCount uint
 	ProjectDirectory     *restrictedfilesystem.RestrictedFilesystem
+
+	// Workspace is set if the project is a monorepo, nil otherwise
+	Workspace *workspace.Workspace
+	// WorkspacePackage is the workspace package the image is built for (optional)
+	WorkspacePackage string
+
+	// BaseImages describes smaller and supported alternatives to the Dockerfile's base images (optional)
+	BaseImages string
+	// DockerfileNotes explain what isn't obvious from the Dockerfile's code, eg- the images chosen with
+	// build arguments in FROM or the ONBUILD triggers a stage runs (optional)
+	DockerfileNotes string
+	// Policies lists the requirements of the organization's policies, which the Dockerfile must comply with (optional)
+	Policies string
+
+	// Goal decides which rules are applied and how tradeoffs are weighed
+	Goal models.Goal
 }
 
 type OptimizeResponse struct {
@@ -25,6 +43,13 @@ type OptimizeResponse struct {
 type GenerateRequest struct {
 	PackageJSON      string
 	ProjectDirectory *restrictedfilesystem.RestrictedFilesystem
+	ProjectInfo      *projectinfo.Info
+
+	Workspace        *workspace.Workspace
+	WorkspacePackage string
+
+	// Policies lists the requirements of the organization's policies, which the Dockerfile must comply with (optional)
+	Policies string
 }
 
 type GenerateResponse struct {
@@ -32,18 +57,54 @@ type GenerateResponse struct {
 	Comments   string `json:"comments" jsonschema_description:"Additional comments"`
 }
 
-func GenerateSchema[T any]() interface{} {
-	// Structured Outputs uses a subset of JSON schema
-	// These flags are necessary to comply with the subset
-	reflector := jsonschema.Reflector{
-		AllowAdditionalProperties: false,
-		DoNotReference:            true,
-	}
-	var v T
-	schema := reflector.Reflect(v)
-	return schema
+// ReviseRequest asks for a new version of a single rejected change
+type ReviseRequest struct {
+	Filepath      string
+	OriginalFile  string
+	OptimizedFile string
+	Actions       []*models.OptimizationAction
+
+	// OriginalLines are the lines of the original file covered by the rejected change
+	OriginalLines []string
+	// ProposedLines are the lines the optimization originally proposed instead
+	ProposedLines []string
+
+	// Revisions are the earlier revisions of this change, all of which were rejected
+	Revisions []*ReviseResponse
+	// Feedback is the feedback given on the proposal, followed by the feedback given on every revision
+	Feedback []string
+}
+
+type ReviseResponse struct {
+	Lines       string `json:"lines" jsonschema_description:"The lines that replace the original lines of the hunk"`
+	Explanation string `json:"explanation" jsonschema_description:"Short explanation of how the feedback was addressed"`
+}
+
+// RepairRequest asks for a corrected version of a Dockerfile that has syntax errors
+type RepairRequest struct {
+	Dockerfile string
+	// Errors are the syntax errors reported for the Dockerfile
+	Errors []string
+}
+
+type RepairResponse struct {
+	Dockerfile  string `json:"dockerfile" jsonschema_description:"The Dockerfile with its syntax errors corrected"`
+	Explanation string `json:"explanation" jsonschema_description:"Short explanation of the corrections made"`
+}
+
+// ChatResponse is the LLM's answer to a message of a Conversation
+type ChatResponse struct {
+	Reply string `json:"reply" jsonschema_description:"Your reply to the user's message"`
+	// Dockerfile is empty if no changes are proposed
+	Dockerfile   string                       `json:"dockerfile" jsonschema_description:"The complete Dockerfile with your proposed changes, or an empty string if you don't propose any"`
+	ActionsTaken []*models.OptimizationAction `json:"actions_taken" jsonschema_description:"List of the modifications you propose in the Dockerfile, empty if you don't propose any"`
 }
 
-// Generate the JSON schema at initialization time
-var optimizeResponseSchema = GenerateSchema[OptimizeResponse]()
-var generateResponseSchema = GenerateSchema[GenerateResponse]()
+// Responses expected from the LLM. Their schemas are reflected at initialization time.
+var (
+	optimizeOutput = structured.New[OptimizeResponse]("modifications", "Optimized assets for the project along with the actions taken and further recommendations")
+	generateOutput = structured.New[GenerateResponse]("generated_asset", "Dockerfile generated for the project along with any comments you would like to add")
+	reviseOutput   = structured.New[ReviseResponse]("revision", "Revised replacement for the lines of a rejected hunk")
+	repairOutput   = structured.New[RepairResponse]("syntax_repair", "Dockerfile with its syntax errors corrected")
+	chatOutput     = structured.New[ChatResponse]("chat_reply", "Reply to the user's message along with the changes proposed to the Dockerfile")
+)
@@ -2,21 +2,21 @@ package ai
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"io/fs"
 	"strings"
 
 	"github.com/duaraghav8/dockershrink/internal/ai/promptcreator"
 	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+	"github.com/duaraghav8/dockershrink/internal/workspace"
 	"github.com/openai/openai-go"
 )
 
 // OptimizeDockerfile optimizes the given Dockerfile using OpenAI GPT-4o
 // It returns the optimized Dockerfile along with the actions taken and
 // recommendations for further optimization.
-func (ai *AIService) OptimizeDockerfile(req *OptimizeRequest) (*OptimizeResponse, error) {
+func (ai *AIService) OptimizeDockerfile(ctx context.Context, req *OptimizeRequest) (*OptimizeResponse, error) {
 	systemInstructions, err := ai.constructOptimizeSystemInstructions(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to construct system prompt: %w", err)
@@ -32,22 +32,11 @@ func (ai *AIService) OptimizeDockerfile(req *OptimizeRequest) (*OptimizeResponse
 		openai.SystemMessage(systemInstructions),
 		openai.UserMessage(userQuery),
 	}
-	responseFormat := openai.ResponseFormatJSONSchemaJSONSchemaParam{
-		Name:        openai.F("modifications"),
-		Description: openai.F("Optimized assets for the project along with the actions taken and further recommendations"),
-		Schema:      openai.F(optimizeResponseSchema),
-		Strict:      openai.Bool(true),
-	}
 	params := openai.ChatCompletionNewParams{
-		Messages: openai.F(messages),
-		Tools:    openai.F(availableTools),
-		ResponseFormat: openai.F[openai.ChatCompletionNewParamsResponseFormatUnion](
-			openai.ResponseFormatJSONSchemaParam{
-				Type:       openai.F(openai.ResponseFormatJSONSchemaTypeJSONSchema),
-				JSONSchema: openai.F(responseFormat),
-			},
-		),
-		Model: openai.F(OpenAIPreferredModel),
+		Messages:       openai.F(messages),
+		Tools:          openai.F(ai.tools()),
+		ResponseFormat: openai.F(optimizeOutput.OpenAIResponseFormat()),
+		Model:          openai.F(ai.Model),
 	}
 
 	for i := 0; i < MaxLLMCalls; i++ {
@@ -58,9 +47,9 @@ func (ai *AIService) OptimizeDockerfile(req *OptimizeRequest) (*OptimizeResponse
 			},
 		)
 
-		response, err := ai.client.Chat.Completions.New(context.Background(), params)
+		response, err := ai.complete(ctx, params)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get chat completion: %w", err)
+			return nil, err
 		}
 
 		ai.L.Debug("Received response from LLM", map[string]string{
@@ -72,8 +61,7 @@ func (ai *AIService) OptimizeDockerfile(req *OptimizeRequest) (*OptimizeResponse
 		if len(toolCalls) == 0 {
 			ai.L.Debug("Response contains final optimized assets", nil)
 
-			optimizeResponse := OptimizeResponse{}
-			err = json.Unmarshal([]byte(response.Choices[0].Message.Content), &optimizeResponse)
+			optimizeResponse, err := optimizeOutput.Parse(response.Choices[0].Message.Content)
 			if err != nil {
 				return nil, fmt.Errorf("failed to parse final response from LLM: %w", err)
 			}
@@ -98,7 +86,7 @@ func (ai *AIService) OptimizeDockerfile(req *OptimizeRequest) (*OptimizeResponse
 				continue
 			}
 
-			return &optimizeResponse, nil
+			return optimizeResponse, nil
 		} else {
 			ai.L.Debug("LLM has called tool(s)", map[string]string{
 				"message": response.Choices[0].Message.Content,
@@ -108,103 +96,11 @@ func (ai *AIService) OptimizeDockerfile(req *OptimizeRequest) (*OptimizeResponse
 			params.Messages.Value = append(params.Messages.Value, response.Choices[0].Message)
 
 			for _, toolCall := range toolCalls {
-				if toolCall.Function.Name == ToolReadFiles {
-					var extractedParams struct {
-						Filepaths []string `json:"filepaths"`
-					}
-					if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &extractedParams); err != nil {
-						return nil, fmt.Errorf("failed to parse function call arguments (%s) from LLM: %w", toolCall.Function.Arguments, err)
-					}
-					if len(extractedParams.Filepaths) == 0 {
-						// LLM called the tool without any files to read.
-						// Send feedback, no need to run the tool.
-						params.Messages.Value = append(
-							params.Messages.Value,
-							openai.ToolMessage(toolCall.ID, ToolReadFilesNoFilesSpecifiedPrompt),
-						)
-						continue
-					}
-
-					ai.L.Debug(
-						"Tool info",
-						map[string]string{
-							"tool":      toolCall.Function.Name,
-							"filepaths": strings.Join(extractedParams.Filepaths, "\n"),
-						},
-					)
-
-					projectFiles, err := req.ProjectDirectory.ReadFiles(extractedParams.Filepaths)
-					if err != nil {
-						// If no such file or directory was found, the LLM probably hallucinated and gave an incorrect filepath.
-						// Send feedback to it.
-						if errors.Is(err, fs.ErrNotExist) {
-							data := map[string]string{
-								"Filepath": "",
-							}
-							if pathErr, ok := err.(*fs.PathError); ok {
-								data["Filepath"] = pathErr.Path
-							}
-							fileNotFoundPrompt, _ := promptcreator.ConstructPrompt(RequestedFileNotFoundPrompt, data)
-
-							ai.L.Debug(
-								"Filepath requested by LLM does not exist, sending feedback to it.",
-								map[string]string{
-									"filepath":        data["Filepath"],
-									"response_to_llm": fileNotFoundPrompt,
-								},
-							)
-
-							params.Messages.Value = append(params.Messages.Value, openai.ToolMessage(toolCall.ID, fileNotFoundPrompt))
-							continue
-						}
-
-						return nil, fmt.Errorf("failed to read file(s) from the project requested by LLM: %w", err)
-					}
-
-					responsePrompt := ""
-					for path, content := range projectFiles {
-						var filePrompt string
-
-						if len(strings.TrimSpace(content)) == 0 {
-							filePrompt = fmt.Sprintf("%s\n[File is empty]\n\n", path)
-						} else {
-							data := map[string]string{
-								"TripleBackticks": "```",
-								"Filepath":        path,
-								"Content":         content,
-							}
-							filePrompt, _ = promptcreator.ConstructPrompt(ToolReadFilesResponseSingleFilePrompt, data)
-						}
-
-						responsePrompt += filePrompt
-					}
-
-					ai.L.Debug(
-						fmt.Sprintf("Tool %s response: Sending back the files requested by LLM", ToolReadFiles),
-						nil,
-					)
-
-					params.Messages.Value = append(params.Messages.Value, openai.ToolMessage(toolCall.ID, responsePrompt))
-					continue
-				}
-
-				if toolCall.Function.Name == ToolDeveloperFeedback {
-					var extractedParams struct {
-						Feedback string `json:"feedback"`
-					}
-					if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &extractedParams); err != nil {
-						return nil, fmt.Errorf("failed to parse %s function call arguments (%s) from LLM: %w", ToolDeveloperFeedback, toolCall.Function.Arguments, err)
-					}
-
-					ai.L.Debug(
-						fmt.Sprintf("Received feedback for Developer from LLM"),
-						map[string]string{
-							"feedback": extractedParams.Feedback,
-						},
-					)
-
-					params.Messages.Value = append(params.Messages.Value, openai.ToolMessage(toolCall.ID, extractedParams.Feedback))
+				responsePrompt, err := ai.callTool(ctx, req.ProjectDirectory, toolCall)
+				if err != nil {
+					return nil, err
 				}
+				params.Messages.Value = append(params.Messages.Value, openai.ToolMessage(toolCall.ID, responsePrompt))
 			}
 		}
 	}
@@ -219,15 +115,53 @@ func (ai *AIService) constructOptimizeSystemInstructions(req *OptimizeRequest) (
 		"ToolReadFiles":         ToolReadFiles,
 		"ToolDeveloperFeedback": ToolDeveloperFeedback,
 	}
+	capability, err := ai.documentationCapabilityPrompt()
+	if err != nil {
+		return "", err
+	}
+	data["CapabilityGetDocumentation"] = capability
+
+	goal := req.Goal
+	if goal == "" {
+		goal = models.GoalAll
+	}
 
-	multistageBuildsPrompt := ""
-	if req.DockerfileStageCount == 1 {
+	rules := map[string]struct {
+		prompt  string
+		enabled bool
+	}{
 		// Only add instructions for multistage builds if the Dockerfile is single-stage
-		multistageBuildsPrompt, _ = promptcreator.ConstructPrompt(RuleMultistageBuildsPrompt, data)
+		"RuleMultistageBuilds":       {ai.prompt("RuleMultistageBuildsPrompt"), req.DockerfileStageCount == 1 && goal.Includes(models.GoalSize, models.GoalSecurity)},
+		"RuleDepcheck":               {ai.prompt("RuleDepcheckPrompt"), goal.Includes(models.GoalSize)},
+		"RuleExcludeDevDependencies": {ai.prompt("RuleExcludeDevDependenciesPrompt"), goal.Includes(models.GoalSize, models.GoalSecurity)},
+		"RuleStripFinalImage":        {ai.prompt("RuleStripFinalImagePrompt"), goal.Includes(models.GoalSize)},
+		"RuleCacheFriendlyBuilds":    {ai.prompt("RuleCacheFriendlyBuildsPrompt"), goal.Includes(models.GoalBuildSpeed)},
+	}
+	for name, rule := range rules {
+		data[name] = ""
+		if rule.enabled {
+			data[name], _ = promptcreator.ConstructPrompt(rule.prompt, data)
+		}
 	}
 
-	data["RuleMultistageBuilds"] = multistageBuildsPrompt
-	return promptcreator.ConstructPrompt(OptimizeRequestSystemPrompt, data)
+	data["RuleMonorepoPruning"] = ""
+	if goal.Includes(models.GoalSize, models.GoalBuildSpeed) {
+		data["RuleMonorepoPruning"] = ai.constructMonorepoPruningPrompt(req.Workspace, req.WorkspacePackage)
+	}
+	data["RuleBaseImages"] = ""
+	if req.BaseImages != "" && goal.Includes(models.GoalSize, models.GoalSecurity) {
+		data["BaseImageSummary"] = strings.TrimSpace(req.BaseImages)
+		data["RuleBaseImages"], _ = promptcreator.ConstructPrompt(ai.prompt("RuleBaseImagesPrompt"), data)
+	}
+	if data["RuleOrganizationPolicy"], err = ai.constructOrganizationPolicyPrompt(req.Policies); err != nil {
+		return "", err
+	}
+	data["OptimizationGoal"], err = promptcreator.ConstructPrompt(ai.prompt(optimizationGoalPrompts[goal]), data)
+	if err != nil {
+		return "", err
+	}
+
+	return promptcreator.ConstructPrompt(ai.prompt("OptimizeRequestSystemPrompt"), data)
 }
 
 func (ai *AIService) constructOptimizeUserQuery(req *OptimizeRequest) (string, error) {
@@ -237,6 +171,87 @@ func (ai *AIService) constructOptimizeUserQuery(req *OptimizeRequest) (string, e
 		"DirTree":         req.ProjectDirectory.DirTree(),
 		"Dockerfile":      req.Dockerfile,
 		"PackageJSON":     req.PackageJSON,
+		"DockerfileNotes": "",
+		"BuildContexts":   "",
+	}
+	if notes := strings.TrimSpace(req.DockerfileNotes); notes != "" {
+		data["DockerfileNotes"], _ = promptcreator.ConstructPrompt(DockerfileNotesPrompt, map[string]string{"Notes": notes})
+	}
+	buildContexts, err := constructBuildContextsPrompt(req.ProjectDirectory)
+	if err != nil {
+		return "", err
+	}
+	data["BuildContexts"] = buildContexts
+	return promptcreator.ConstructPrompt(ai.prompt("OptimizeRequestUserPrompt"), data)
+}
+
+// constructBuildContextsPrompt describes the named build contexts of the project.
+// An empty string is returned if the Dockerfile isn't built with any.
+func constructBuildContextsPrompt(rfs *restrictedfilesystem.RestrictedFilesystem) (string, error) {
+	contexts := rfs.BuildContexts()
+	if len(contexts) == 0 {
+		return "", nil
+	}
+	descriptions := ""
+	for _, c := range contexts {
+		data := map[string]string{
+			"Backtick":        "`",
+			"TripleBackticks": "```",
+			"ToolReadFiles":   ToolReadFiles,
+			"Name":            c.Name,
+			"Source":          c.Source,
+			"DirTree":         rfs.BuildContextTree(c.Name),
+		}
+		tmpl := BuildContextRemotePrompt
+		if c.IsLocal() {
+			tmpl = BuildContextLocalPrompt
+		}
+		description, err := promptcreator.ConstructPrompt(tmpl, data)
+		if err != nil {
+			return "", err
+		}
+		descriptions += description
+	}
+	return promptcreator.ConstructPrompt(BuildContextsPrompt, map[string]string{
+		"Backtick": "`",
+		"Contexts": strings.TrimSuffix(descriptions, "\n"),
+	})
+}
+
+// optimizationGoalPrompts are the names of the prompts describing each goal
+var optimizationGoalPrompts = map[models.Goal]string{
+	models.GoalSize:       "OptimizationGoalSizePrompt",
+	models.GoalBuildSpeed: "OptimizationGoalBuildSpeedPrompt",
+	models.GoalSecurity:   "OptimizationGoalSecurityPrompt",
+	models.GoalAll:        "OptimizationGoalAllPrompt",
+}
+
+// constructOrganizationPolicyPrompt returns the instructions for complying with the organization's policies.
+// If there are no policies, an empty string is returned.
+func (ai *AIService) constructOrganizationPolicyPrompt(policies string) (string, error) {
+	if strings.TrimSpace(policies) == "" {
+		return "", nil
+	}
+	return promptcreator.ConstructPrompt(ai.prompt("RuleOrganizationPolicyPrompt"), map[string]string{"Policies": policies})
+}
+
+// constructMonorepoPruningPrompt returns the instructions for pruning a monorepo workspace.
+// If the project is not a monorepo, an empty string is returned.
+func (ai *AIService) constructMonorepoPruningPrompt(ws *workspace.Workspace, target string) string {
+	if ws == nil || len(ws.Packages) == 0 {
+		return ""
+	}
+	targetPackage := target
+	if targetPackage == "" {
+		targetPackage = "determine it from the Dockerfile"
+	}
+	data := map[string]string{
+		"Backtick":         "`",
+		"TripleBackticks":  "```",
+		"WorkspaceSummary": ws.Summary(),
+		"TargetPackage":    targetPackage,
+		"PruneCommand":     ws.PruneCommand(target),
 	}
-	return promptcreator.ConstructPrompt(OptimizeRequestUserPrompt, data)
+	prompt, _ := promptcreator.ConstructPrompt(ai.prompt("RuleMonorepoPruningPrompt"), data)
+	return prompt
 }
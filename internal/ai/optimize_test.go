@@ -0,0 +1,125 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/buildcontext"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+)
+
+func TestConstructOptimizeSystemInstructions_Goals(t *testing.T) {
+	tests := []struct {
+		goal       models.Goal
+		included   []string
+		excluded   []string
+		stageCount uint
+	}{
+		{models.GoalSize, []string{"### Multistage Builds", "### Use Depcheck"}, []string{"### Cache-friendly Builds"}, 1},
+		{models.GoalBuildSpeed, []string{"### Cache-friendly Builds"}, []string{"### Use Depcheck", "### Multistage Builds"}, 1},
+		{models.GoalSecurity, []string{"### Exclude devDependencies"}, []string{"### Use Depcheck"}, 1},
+		{models.GoalAll, []string{"### Use Depcheck", "### Cache-friendly Builds"}, []string{"### Multistage Builds"}, 2},
+	}
+
+	ai := &AIService{}
+	for _, tt := range tests {
+		t.Run(string(tt.goal), func(t *testing.T) {
+			prompt, err := ai.constructOptimizeSystemInstructions(&OptimizeRequest{Goal: tt.goal, DockerfileStageCount: tt.stageCount})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, s := range tt.included {
+				if !strings.Contains(prompt, s) {
+					t.Errorf("expected prompt to contain %q", s)
+				}
+			}
+			for _, s := range tt.excluded {
+				if strings.Contains(prompt, s) {
+					t.Errorf("expected prompt to not contain %q", s)
+				}
+			}
+		})
+	}
+}
+
+func TestConstructReviseUserQuery(t *testing.T) {
+	req := &ReviseRequest{
+		Filepath:      "Dockerfile",
+		OriginalFile:  "FROM node:20\nRUN apt-get install -y curl\n",
+		OptimizedFile: "FROM node:20-alpine\n",
+		OriginalLines: []string{"RUN apt-get install -y curl"},
+		ProposedLines: []string{},
+		Feedback:      []string{"keep curl, it's needed at runtime", "use apk"},
+	}
+	query, err := (&AIService{}).constructReviseUserQuery(req)
+	if err != nil {
+		t.Fatalf("constructReviseUserQuery() returned error: %v", err)
+	}
+	for _, expected := range []string{"Original lines of the rejected hunk:\n```\nRUN apt-get install -y curl\n```", "keep curl, it's needed at runtime", "Actions taken:\nNone"} {
+		if !strings.Contains(query, expected) {
+			t.Errorf("query does not contain %q:\n%s", expected, query)
+		}
+	}
+	// later feedback is sent as follow-up messages, not in the first query
+	if strings.Contains(query, "use apk") {
+		t.Errorf("query must only contain the first feedback:\n%s", query)
+	}
+}
+
+func TestConstructOptimizeUserQuery_DockerfileNotes(t *testing.T) {
+	ai := &AIService{}
+	req := &OptimizeRequest{
+		Dockerfile:       "ARG BASE=node:20\nFROM ${BASE}\n",
+		ProjectDirectory: restrictedfilesystem.NewRestrictedFilesystem(t.TempDir(), "", "Dockerfile", ".dockerignore"),
+	}
+	query, err := ai.constructOptimizeUserQuery(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(query, "Notes about the Dockerfile") {
+		t.Errorf("expected no notes section without notes:\n%s", query)
+	}
+
+	req.DockerfileNotes = "* Stage 1 is built from 'node:20' by default (FROM ${BASE})."
+	query, err = ai.constructOptimizeUserQuery(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(query, "Notes about the Dockerfile:\n"+req.DockerfileNotes) {
+		t.Errorf("expected the notes in the query:\n%s", query)
+	}
+}
+
+func TestConstructOptimizeUserQuery_BuildContexts(t *testing.T) {
+	ai := &AIService{}
+	rfs := restrictedfilesystem.NewRestrictedFilesystem(t.TempDir(), "", "Dockerfile", ".dockerignore")
+	req := &OptimizeRequest{Dockerfile: "FROM node:22\nCOPY --from=lib . ./lib\n", ProjectDirectory: rfs}
+	query, err := ai.constructOptimizeUserQuery(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(query, "Named build contexts") {
+		t.Errorf("expected no build contexts section without contexts:\n%s", query)
+	}
+
+	lib, _ := buildcontext.New("lib", "../lib", t.TempDir())
+	base, _ := buildcontext.New("base", "docker-image://node:22-alpine", "")
+	rfs.AddBuildContext(lib, "lib\n└── package.json")
+	rfs.AddBuildContext(base, "")
+	query, err = ai.constructOptimizeUserQuery(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, expected := range []string{
+		"Named build contexts:",
+		"* `lib`: local directory `../lib`",
+		"eg- `lib:package.json`",
+		"└── package.json",
+		"* `base`: `docker-image://node:22-alpine`, its files can't be read.",
+	} {
+		if !strings.Contains(query, expected) {
+			t.Errorf("query does not contain %q:\n%s", expected, query)
+		}
+	}
+}
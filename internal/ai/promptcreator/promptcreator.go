@@ -3,18 +3,19 @@ package promptcreator
 import (
 	"bytes"
 	"fmt"
+	"sort"
+	"strings"
 	"text/template"
+	"text/template/parse"
 )
 
 // ConstructPrompt parses the given templateText, then executes it using data from
 // the provided map[string]string.
 // If the template references a key not in data, it returns an error.
 func ConstructPrompt(templateText string, data map[string]string) (string, error) {
-	tmpl, err := template.New("prompt").
-		Option("missingkey=error").
-		Parse(templateText)
+	tmpl, err := parseTemplate(templateText)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse template: %w", err)
+		return "", err
 	}
 
 	var buf bytes.Buffer
@@ -24,3 +25,118 @@ func ConstructPrompt(templateText string, data map[string]string) (string, error
 
 	return buf.String(), nil
 }
+
+// Placeholders returns the keys of the data the template references, sorted.
+// Keys referenced inside range and with blocks are left out, since the data isn't their dot.
+func Placeholders(templateText string) ([]string, error) {
+	tmpl, err := parseTemplate(templateText)
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]bool{}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			collectPlaceholders(t.Tree.Root, keys, true)
+		}
+	}
+	placeholders := make([]string, 0, len(keys))
+	for k := range keys {
+		placeholders = append(placeholders, k)
+	}
+	sort.Strings(placeholders)
+	return placeholders, nil
+}
+
+// Validate checks that the template can be constructed with data containing only the given keys,
+// so that a broken template fails when it's loaded rather than when its prompt is needed.
+// Every key is checked, including those referenced in branches that executing the template wouldn't reach.
+func Validate(templateText string, keys []string) error {
+	placeholders, err := Placeholders(templateText)
+	if err != nil {
+		return err
+	}
+	data := make(map[string]string, len(keys))
+	for _, k := range keys {
+		data[k] = ""
+	}
+	unknown := []string{}
+	for _, p := range placeholders {
+		if _, ok := data[p]; !ok {
+			unknown = append(unknown, p)
+		}
+	}
+	if len(unknown) > 0 {
+		available := "none"
+		if len(keys) > 0 {
+			sorted := append([]string{}, keys...)
+			sort.Strings(sorted)
+			available = strings.Join(sorted, ", ")
+		}
+		return fmt.Errorf("unknown placeholder(s) %s, the available placeholders are: %s", strings.Join(unknown, ", "), available)
+	}
+	// catches the errors only executing reveals, eg- a template invoking another one that isn't defined
+	_, err = ConstructPrompt(templateText, data)
+	return err
+}
+
+func parseTemplate(templateText string) (*template.Template, error) {
+	tmpl, err := template.New("prompt").
+		Option("missingkey=error").
+		Parse(templateText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// collectPlaceholders adds the keys of the data referenced under node to keys.
+// dot is false where the dot has been rebound, ie- inside range and with blocks.
+func collectPlaceholders(node parse.Node, keys map[string]bool, dot bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			collectPlaceholders(c, keys, dot)
+		}
+	case *parse.ActionNode:
+		collectPlaceholders(n.Pipe, keys, dot)
+	case *parse.IfNode:
+		collectPlaceholders(n.Pipe, keys, dot)
+		collectPlaceholders(n.List, keys, dot)
+		collectPlaceholders(n.ElseList, keys, dot)
+	case *parse.RangeNode:
+		collectPlaceholders(n.Pipe, keys, dot)
+		collectPlaceholders(n.List, keys, false)
+		collectPlaceholders(n.ElseList, keys, dot)
+	case *parse.WithNode:
+		collectPlaceholders(n.Pipe, keys, dot)
+		collectPlaceholders(n.List, keys, false)
+		collectPlaceholders(n.ElseList, keys, dot)
+	case *parse.TemplateNode:
+		collectPlaceholders(n.Pipe, keys, dot)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			collectPlaceholders(c, keys, dot)
+		}
+	case *parse.CommandNode:
+		for _, a := range n.Args {
+			collectPlaceholders(a, keys, dot)
+		}
+	case *parse.ChainNode:
+		collectPlaceholders(n.Node, keys, dot)
+	case *parse.FieldNode:
+		if dot {
+			keys[n.Ident[0]] = true
+		}
+	case *parse.VariableNode:
+		// $ is always the data, whatever the dot is
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			keys[n.Ident[1]] = true
+		}
+	}
+}
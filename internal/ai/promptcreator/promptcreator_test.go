@@ -1,6 +1,7 @@
 package promptcreator
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -43,3 +44,53 @@ You are {{ .age }} years old.
 		t.Fatalf("expected an error for missing key 'age', but got none")
 	}
 }
+
+func TestPlaceholders(t *testing.T) {
+	tests := []struct {
+		templateText string
+		expected     []string
+	}{
+		{"no placeholders", []string{}},
+		{"{{ .b }} {{ .a }} {{ .b }}", []string{"a", "b"}},
+		{"{{ if .cond }}{{ .then }}{{ else }}{{ .otherwise }}{{ end }}", []string{"cond", "otherwise", "then"}},
+		// the dot of range and with blocks isn't the data, but $ still is
+		{"{{ with .notes }}{{ .ignored }}{{ $.name }}{{ end }}", []string{"name", "notes"}},
+		{`{{ define "inner" }}{{ .inner }}{{ end }}{{ template "inner" . }}{{ printf "%s" (.outer) }}`, []string{"inner", "outer"}},
+	}
+	for _, tt := range tests {
+		placeholders, err := Placeholders(tt.templateText)
+		if err != nil {
+			t.Fatalf("Placeholders(%q) returned error: %v", tt.templateText, err)
+		}
+		if strings.Join(placeholders, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("Placeholders(%q) = %v; want %v", tt.templateText, placeholders, tt.expected)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	keys := []string{"name", "city"}
+	tests := []struct {
+		templateText string
+		expectedErr  string
+	}{
+		{templateText: "Hello, {{ .name }} from {{ .city }}!"},
+		{templateText: "Hello!"},
+		// unknown keys are caught even in branches that wouldn't be executed
+		{templateText: "{{ if .name }}{{ .age }}{{ end }}", expectedErr: "unknown placeholder(s) age"},
+		{templateText: "Hello, {{ .name }", expectedErr: "failed to parse template"},
+		{templateText: `{{ template "missing" . }}`, expectedErr: "failed to execute template"},
+	}
+	for _, tt := range tests {
+		err := Validate(tt.templateText, keys)
+		if tt.expectedErr == "" {
+			if err != nil {
+				t.Errorf("Validate(%q) returned error: %v", tt.templateText, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+			t.Errorf("Validate(%q) = %v; want an error containing %q", tt.templateText, err, tt.expectedErr)
+		}
+	}
+}
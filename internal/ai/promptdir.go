@@ -0,0 +1,101 @@
+package ai
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/ai/promptcreator"
+)
+
+// PromptFileExtension is the extension of the templates in a prompt directory, other files are ignored
+const PromptFileExtension = ".tmpl"
+
+// embeddedPromptTemplate is the name under which an override can include the prompt it overrides
+const embeddedPromptTemplate = "default"
+
+// overridablePrompts are the embedded prompts which a prompt directory can override, by name
+var overridablePrompts = map[string]string{
+	"OptimizeRequestSystemPrompt":      OptimizeRequestSystemPrompt,
+	"OptimizeRequestUserPrompt":        OptimizeRequestUserPrompt,
+	"OptimizationGoalSizePrompt":       OptimizationGoalSizePrompt,
+	"OptimizationGoalBuildSpeedPrompt": OptimizationGoalBuildSpeedPrompt,
+	"OptimizationGoalSecurityPrompt":   OptimizationGoalSecurityPrompt,
+	"OptimizationGoalAllPrompt":        OptimizationGoalAllPrompt,
+	"RuleMultistageBuildsPrompt":       RuleMultistageBuildsPrompt,
+	"RuleMonorepoPruningPrompt":        RuleMonorepoPruningPrompt,
+	"RuleBaseImagesPrompt":             RuleBaseImagesPrompt,
+	"RuleOrganizationPolicyPrompt":     RuleOrganizationPolicyPrompt,
+	"RuleDepcheckPrompt":               RuleDepcheckPrompt,
+	"RuleExcludeDevDependenciesPrompt": RuleExcludeDevDependenciesPrompt,
+	"RuleStripFinalImagePrompt":        RuleStripFinalImagePrompt,
+	"RuleCacheFriendlyBuildsPrompt":    RuleCacheFriendlyBuildsPrompt,
+	"GenerateRequestSystemPrompt":      GenerateRequestSystemPrompt,
+	"GenerateRequestUserPrompt":        GenerateRequestUserPrompt,
+	"ReviseChangeSystemPrompt":         ReviseChangeSystemPrompt,
+	"ReviseChangeUserPrompt":           ReviseChangeUserPrompt,
+	"ReviseChangeFollowUpPrompt":       ReviseChangeFollowUpPrompt,
+	"RepairSyntaxSystemPrompt":         RepairSyntaxSystemPrompt,
+	"ChatSystemPrompt":                 ChatSystemPrompt,
+	"RepairSyntaxUserPrompt":           RepairSyntaxUserPrompt,
+}
+
+// Prompts are templates overriding the embedded prompts, by name
+type Prompts map[string]string
+
+// PromptNames returns the names of the prompts which can be overridden, sorted
+func PromptNames() []string {
+	names := make([]string, 0, len(overridablePrompts))
+	for name := range overridablePrompts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadPrompts reads the templates of a prompt directory. A file named after an embedded prompt,
+// eg- OptimizeRequestSystemPrompt.tmpl, replaces it, and can extend it instead by including it with
+// {{ template "default" . }}. Every template is validated against the placeholders of the prompt it
+// overrides, so that a broken template fails right away instead of in the middle of a request.
+func LoadPrompts(dir string) (Prompts, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt directory: %w", err)
+	}
+	prompts := Prompts{}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != PromptFileExtension {
+			continue
+		}
+		name := strings.TrimSuffix(e.Name(), PromptFileExtension)
+		embedded, ok := overridablePrompts[name]
+		if !ok {
+			return nil, fmt.Errorf("%s doesn't override any prompt, the prompts that can be overridden are: %s", e.Name(), strings.Join(PromptNames(), ", "))
+		}
+		content, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		// every key the embedded prompt references is passed to the template when constructing the prompt
+		placeholders, err := promptcreator.Placeholders(embedded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse embedded prompt %s: %w", name, err)
+		}
+		text := `{{ define "` + embeddedPromptTemplate + `" }}` + embedded + `{{ end }}` + string(content)
+		if err := promptcreator.Validate(text, placeholders); err != nil {
+			return nil, fmt.Errorf("invalid prompt template %s: %w", e.Name(), err)
+		}
+		prompts[name] = text
+	}
+	return prompts, nil
+}
+
+// prompt returns the template of the named prompt: its override if there's one, the embedded prompt otherwise
+func (ai *AIService) prompt(name string) string {
+	if text, ok := ai.Prompts[name]; ok {
+		return text
+	}
+	return overridablePrompts[name]
+}
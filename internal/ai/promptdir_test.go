@@ -0,0 +1,105 @@
+package ai
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+func TestLoadPrompts(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		expectedErr string
+	}{
+		{
+			name: "override and extension",
+			files: map[string]string{
+				"OptimizationGoalSizePrompt.tmpl":  "Only care about size.",
+				"OptimizeRequestUserPrompt.tmpl":   "{{ template \"default\" . }}\nThe image runs on arm64, see {{ .TripleBackticks }}uname -m{{ .TripleBackticks }}.",
+				"README.md":                        "ignored",
+				"RepairSyntaxSystemPrompt.tmpl.bk": "ignored",
+			},
+		},
+		{
+			name:        "unknown prompt",
+			files:       map[string]string{"OptimizeSystemPrompt.tmpl": "typo in the name"},
+			expectedErr: "OptimizeSystemPrompt.tmpl doesn't override any prompt",
+		},
+		{
+			name:        "unknown placeholder",
+			files:       map[string]string{"RepairSyntaxUserPrompt.tmpl": "{{ .Dockerfile }} {{ .Dockerignore }}"},
+			expectedErr: "unknown placeholder(s) Dockerignore",
+		},
+		{
+			name:        "broken template",
+			files:       map[string]string{"ReviseChangeFollowUpPrompt.tmpl": "{{ .Feedback"},
+			expectedErr: "invalid prompt template ReviseChangeFollowUpPrompt.tmpl",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			prompts, err := LoadPrompts(dir)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("LoadPrompts() = %v; want an error containing %q", err, tt.expectedErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadPrompts() returned error: %v", err)
+			}
+			if len(prompts) != 2 {
+				t.Errorf("expected 2 prompts, got %d", len(prompts))
+			}
+
+			ai := &AIService{Prompts: prompts}
+			system, err := ai.constructOptimizeSystemInstructions(&OptimizeRequest{Goal: models.GoalSize})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.Contains(system, "Only care about size.") || strings.Contains(system, OptimizationGoalSizePrompt) {
+				t.Errorf("expected the goal prompt to be replaced, got:\n%s", system)
+			}
+		})
+	}
+}
+
+// Every embedded prompt must be valid as its own override, otherwise no override of it could be loaded
+func TestLoadPrompts_Embedded(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range PromptNames() {
+		if err := os.WriteFile(filepath.Join(dir, name+PromptFileExtension), []byte(`{{ template "default" . }}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	prompts, err := LoadPrompts(dir)
+	if err != nil {
+		t.Fatalf("LoadPrompts() returned error: %v", err)
+	}
+	overridden := &AIService{Prompts: prompts}
+	embedded := &AIService{}
+	for _, goal := range []models.Goal{models.GoalSize, models.GoalBuildSpeed, models.GoalSecurity, models.GoalAll} {
+		req := &OptimizeRequest{Goal: goal, DockerfileStageCount: 1, BaseImages: "node:20-alpine"}
+		expected, err := embedded.constructOptimizeSystemInstructions(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		actual, err := overridden.constructOptimizeSystemInstructions(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if actual != expected {
+			t.Errorf("expected including the embedded prompts to leave the %s prompt unchanged", goal)
+		}
+	}
+}
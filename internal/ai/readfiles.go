@@ -0,0 +1,199 @@
+package ai
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/ai/promptcreator"
+	"gopkg.in/yaml.v3"
+)
+
+// FileLimits caps how much of the files requested with read_files is sent back to the LLM.
+// 0 means the default limit.
+type FileLimits struct {
+	// MaxFileBytes is the most of a single file sent back, larger files are truncated and summarized
+	MaxFileBytes int
+	// MaxTotalBytes is the most of all the files requested by a single call sent back
+	MaxTotalBytes int
+}
+
+// DefaultFileLimits keep the response to a call of read_files within ~25K tokens
+var DefaultFileLimits = FileLimits{MaxFileBytes: 24 * 1024, MaxTotalBytes: 96 * 1024}
+
+const (
+	// maxOutlineLines is the number of lines of the outline of a truncated JSON or YAML file
+	maxOutlineLines = 60
+	// maxOutlineDepth is the depth of the keys listed in the outline
+	maxOutlineDepth = 3
+	// maxOutlineValue is the length scalar values are cut to in the outline
+	maxOutlineValue = 60
+)
+
+// fileLimits returns the limits of the service, with the defaults in place of the ones that aren't set
+func (ai *AIService) fileLimits() FileLimits {
+	limits := ai.FileLimits
+	if limits.MaxFileBytes <= 0 {
+		limits.MaxFileBytes = DefaultFileLimits.MaxFileBytes
+	}
+	if limits.MaxTotalBytes <= 0 {
+		limits.MaxTotalBytes = DefaultFileLimits.MaxTotalBytes
+	}
+	return limits
+}
+
+// readFilesResponse returns the response to a call of read_files with the files read from the project, in the
+// order they were requested. Binary and generated files are replaced by their size, type and hash. Files larger
+// than the limit are truncated to their beginning and end, along with an outline of their keys if they're JSON
+// or YAML. Files requested after the total limit is reached aren't sent.
+func readFilesResponse(filepaths []string, files map[string]string, limits FileLimits) string {
+	response := ""
+	remaining := limits.MaxTotalBytes
+	sent := map[string]bool{}
+	for _, p := range filepaths {
+		content, ok := files[p]
+		if !ok || sent[p] {
+			continue
+		}
+		sent[p] = true
+
+		if len(strings.TrimSpace(content)) == 0 {
+			response += fmt.Sprintf("%s\n[File is empty]\n\n", p)
+			continue
+		}
+		if kind := generatedKind(p, content, limits.MaxFileBytes); kind != "" {
+			metadata := fileMetadata(kind, content)
+			response += fmt.Sprintf("%s\n%s\n\n", p, metadata)
+			remaining -= len(metadata)
+			continue
+		}
+		if remaining <= 0 {
+			response += fmt.Sprintf("%s\n[Not sent: the files requested together exceed %s. Request this file on its own if you need it.]\n\n", p, formatKB(limits.MaxTotalBytes))
+			continue
+		}
+		limit := min(limits.MaxFileBytes, remaining)
+		if len(content) > limit {
+			content = truncatedView(p, content, limit)
+			if limit == remaining {
+				// the file was cut to what's left of the total limit, so nothing more fits
+				remaining = 0
+			}
+		}
+		remaining -= len(content)
+
+		data := map[string]string{
+			"TripleBackticks": "```",
+			"Filepath":        p,
+			"Content":         content,
+		}
+		filePrompt, _ := promptcreator.ConstructPrompt(ToolReadFilesResponseSingleFilePrompt, data)
+		response += filePrompt
+	}
+	return response
+}
+
+// truncatedView returns a view of the file that fits in limit bytes: a note saying what was left out, the outline
+// of the keys of JSON and YAML files, and as many lines of the beginning and the end of the file as fit.
+func truncatedView(filepath, content string, limit int) string {
+	outline := ""
+	switch strings.ToLower(path.Ext(filepath)) {
+	case ".json", ".yaml", ".yml":
+		outline = structureOutline(content)
+		if len(outline) > limit/3 {
+			outline = ""
+		}
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	// the note and the outline take up part of the limit, the rest goes to the beginning and the end of the file
+	budget := limit - len(outline) - 300
+	headBudget, tailBudget := budget*2/3, budget/3
+	head, size := 0, 0
+	for head < len(lines) && size+len(lines[head]) <= headBudget {
+		size += len(lines[head])
+		head++
+	}
+	tail, size := len(lines), 0
+	for tail > head && size+len(lines[tail-1]) <= tailBudget {
+		size += len(lines[tail-1])
+		tail--
+	}
+	if head == 0 && tail == len(lines) {
+		// a single huge line, eg- minified code
+		cut := max(budget, 0)
+		return fmt.Sprintf("[Truncated: the file is %s and its lines are too long to show, only its first %s are shown. The rest of it isn't available.]\n%s%s",
+			formatKB(len(content)), formatKB(cut), outline, content[:min(cut, len(content))])
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Truncated: the file is %s, so only its first %d and last %d lines are shown, out of %d. The rest of it isn't available.]\n",
+		formatKB(len(content)), head, len(lines)-tail, len(lines))
+	if outline != "" {
+		b.WriteString("Outline of its keys:\n" + outline + "\n")
+	}
+	b.WriteString(strings.Join(lines[:head], ""))
+	if !strings.HasSuffix(b.String(), "\n") {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "... [%d lines omitted] ...\n", tail-head)
+	b.WriteString(strings.Join(lines[tail:], ""))
+	return b.String()
+}
+
+// structureOutline returns the keys of a JSON or YAML document, nested up to a few levels, along with the
+// number of entries of objects and lists and the values of scalars. It's empty if the content can't be parsed.
+func structureOutline(content string) string {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil || len(doc.Content) == 0 {
+		return ""
+	}
+	lines := []string{}
+	var walk func(n *yaml.Node, indent string, depth int)
+	walk = func(n *yaml.Node, indent string, depth int) {
+		switch n.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if len(lines) >= maxOutlineLines {
+					return
+				}
+				key, value := n.Content[i].Value, n.Content[i+1]
+				lines = append(lines, fmt.Sprintf("%s%s: %s", indent, key, outlineValue(value)))
+				if depth < maxOutlineDepth && value.Kind == yaml.MappingNode {
+					walk(value, indent+"  ", depth+1)
+				}
+			}
+		case yaml.SequenceNode:
+			lines = append(lines, indent+outlineValue(n))
+			if len(n.Content) > 0 && depth < maxOutlineDepth && n.Content[0].Kind == yaml.MappingNode {
+				walk(n.Content[0], indent+"  ", depth+1)
+			}
+		}
+	}
+	walk(doc.Content[0], "", 1)
+	if len(lines) >= maxOutlineLines {
+		lines = append(lines, "...")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// outlineValue describes a value in the outline of a document, eg- "{12 keys}", "[3 items]" or the value itself
+func outlineValue(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return fmt.Sprintf("{%d keys}", len(n.Content)/2)
+	case yaml.SequenceNode:
+		return fmt.Sprintf("[%d items]", len(n.Content))
+	case yaml.AliasNode:
+		return "*" + n.Value
+	}
+	value := strings.ReplaceAll(n.Value, "\n", " ")
+	if len(value) > maxOutlineValue {
+		value = value[:maxOutlineValue] + "..."
+	}
+	return value
+}
+
+// formatKB formats a number of bytes in kilobytes, eg- "812 KB"
+func formatKB(bytes int) string {
+	return fmt.Sprintf("%d KB", (bytes+1023)/1024)
+}
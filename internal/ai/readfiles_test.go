@@ -0,0 +1,153 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReadFilesResponse(t *testing.T) {
+	lines := []string{}
+	for i := 0; i < 2000; i++ {
+		lines = append(lines, fmt.Sprintf("line %d of the log", i))
+	}
+	largeLog := strings.Join(lines, "\n") + "\n"
+
+	deps := []string{}
+	for i := 0; i < 500; i++ {
+		deps = append(deps, fmt.Sprintf(`"dep-%d": "^1.0.%d"`, i, i))
+	}
+	largeJSON := `{"name": "app", "scripts": {"start": "node index.js"}, "dependencies": {` + strings.Join(deps, ",\n") + "}}\n"
+
+	limits := FileLimits{MaxFileBytes: 4 * 1024, MaxTotalBytes: 6 * 1024}
+	tests := []struct {
+		name        string
+		filepaths   []string
+		files       map[string]string
+		contains    []string
+		notContains []string
+		maxLen      int
+	}{
+		{
+			name:      "small file sent as is",
+			filepaths: []string{"index.js"},
+			files:     map[string]string{"index.js": "console.log('hi')\n"},
+			contains:  []string{"index.js\n```\nconsole.log('hi')\n"},
+			notContains: []string{
+				"[Truncated",
+			},
+		},
+		{
+			name:      "empty file",
+			filepaths: []string{".env"},
+			files:     map[string]string{".env": "  \n"},
+			contains:  []string{".env\n[File is empty]"},
+		},
+		{
+			name:      "large file truncated to its beginning and end",
+			filepaths: []string{"build.log"},
+			files:     map[string]string{"build.log": largeLog},
+			contains: []string{
+				"[Truncated: the file is",
+				"line 0 of the log\n",
+				"lines omitted] ...\n",
+				"line 1999 of the log\n",
+			},
+			notContains: []string{"line 1000 of the log", "Outline of its keys"},
+			maxLen:      limits.MaxFileBytes + 100,
+		},
+		{
+			name:      "large JSON file outlined",
+			filepaths: []string{"package.json"},
+			files:     map[string]string{"package.json": largeJSON},
+			contains: []string{
+				"Outline of its keys:\nname: app\nscripts: {1 keys}\n  start: node index.js\ndependencies: {500 keys}\n",
+				`"dep-499": "^1.0.499"`,
+			},
+			notContains: []string{`"dep-250"`},
+			maxLen:      limits.MaxFileBytes + 100,
+		},
+		{
+			name:      "files after the total limit not sent",
+			filepaths: []string{"a.log", "b.log", "c.js"},
+			files:     map[string]string{"a.log": largeLog, "b.log": largeLog, "c.js": "x"},
+			contains: []string{
+				"a.log\n```\n[Truncated",
+				"b.log\n```\n[Truncated",
+				"c.js\n[Not sent: the files requested together exceed 6 KB.",
+			},
+			maxLen: limits.MaxTotalBytes + 300,
+		},
+		{
+			name:      "single huge line",
+			filepaths: []string{"data.txt"},
+			files:     map[string]string{"data.txt": strings.Repeat("a", 10*1024)},
+			contains:  []string{"its lines are too long to show"},
+			maxLen:    limits.MaxFileBytes + 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := readFilesResponse(tt.filepaths, tt.files, limits)
+			for _, s := range tt.contains {
+				if !strings.Contains(response, s) {
+					t.Errorf("response doesn't contain %q:\n%s", s, response)
+				}
+			}
+			for _, s := range tt.notContains {
+				if strings.Contains(response, s) {
+					t.Errorf("response contains %q:\n%s", s, response)
+				}
+			}
+			if tt.maxLen > 0 && len(response) > tt.maxLen {
+				t.Errorf("response is %d bytes, expected at most %d", len(response), tt.maxLen)
+			}
+		})
+	}
+}
+
+func TestGeneratedKind(t *testing.T) {
+	lockfile := `{"name": "app", "lockfileVersion": 3, "packages": {` + strings.Repeat(`"node_modules/x": {"version": "1.0.0"},`+"\n", 200) + "}}\n"
+	tests := []struct {
+		filepath string
+		content  string
+		expected string
+	}{
+		{filepath: "logo.png", content: "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", expected: "a binary file (image/png)"},
+		{filepath: "data.bin", content: "\xff\xfe\xfd text", expected: "a binary file (application/octet-stream)"},
+		{filepath: "README.md", content: "# Café ☕\n", expected: ""},
+		{filepath: "dist/app.js.map", content: `{"version":3,"sources":[]}`, expected: "a source map"},
+		{filepath: "package-lock.json", content: lockfile, expected: "a lockfile"},
+		{filepath: "package-lock.json", content: `{"lockfileVersion": 3}`, expected: ""},
+		{filepath: "public/vendor.min.js", content: "var a=1;\n", expected: "minified code"},
+		{filepath: "dist/app.js", content: strings.Repeat("a=b;", 500), expected: "minified code"},
+		{filepath: "dist/main.js", content: "/******/ (() => { // webpackBootstrap\n", expected: "a bundle generated by a bundler"},
+		{filepath: "src/index.js", content: "const express = require('express')\n", expected: ""},
+		{filepath: "src/schema.ts", content: "// Code generated by protoc. DO NOT EDIT.\n" + strings.Repeat("export type A = string\n", 300), expected: "a generated file"},
+		{filepath: "src/small.ts", content: "// Code generated by protoc. DO NOT EDIT.\nexport type A = string\n", expected: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filepath, func(t *testing.T) {
+			if kind := generatedKind(tt.filepath, tt.content, 4*1024); kind != tt.expected {
+				t.Errorf("generatedKind() = %q; want %q", kind, tt.expected)
+			}
+		})
+	}
+
+	response := readFilesResponse([]string{"logo.png"}, map[string]string{"logo.png": "\x89PNG\r\n\x1a\n\x00"}, DefaultFileLimits)
+	if !strings.HasPrefix(response, "logo.png\n[Not shown: the file is a binary file (image/png), 1 KB, sha256 ") || strings.Contains(response, "PNG\r") {
+		t.Errorf("expected the metadata of the binary file, got %q", response)
+	}
+}
+
+func TestReadFilesResponse_Order(t *testing.T) {
+	files := map[string]string{"b.js": "b", "a.js": "a", "c.js": "c"}
+	response := readFilesResponse([]string{"c.js", "a.js", "b.js", "a.js"}, files, DefaultFileLimits)
+	if i, j, k := strings.Index(response, "c.js"), strings.Index(response, "a.js"), strings.Index(response, "b.js"); !(i < j && j < k) {
+		t.Errorf("files not in the requested order:\n%s", response)
+	}
+	if strings.Count(response, "a.js") != 1 {
+		t.Errorf("file requested twice sent twice:\n%s", response)
+	}
+}
@@ -0,0 +1,82 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/ai/promptcreator"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/openai/openai-go"
+)
+
+// RepairDockerfile asks the LLM to correct the syntax errors of a Dockerfile which
+// couldn't be recovered automatically. The corrected Dockerfile is validated and
+// sent back to the LLM if it still has errors.
+func (ai *AIService) RepairDockerfile(ctx context.Context, req *RepairRequest) (*RepairResponse, error) {
+	if len(req.Errors) == 0 {
+		return nil, errors.New("no syntax errors given for the repair")
+	}
+
+	systemInstructions, err := promptcreator.ConstructPrompt(ai.prompt("RepairSyntaxSystemPrompt"), map[string]string{"TripleBackticks": "```"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct system prompt: %w", err)
+	}
+	userQuery, err := promptcreator.ConstructPrompt(ai.prompt("RepairSyntaxUserPrompt"), map[string]string{
+		"TripleBackticks": "```",
+		"Dockerfile":      req.Dockerfile,
+		"Errors":          "- " + strings.Join(req.Errors, "\n- "),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct user prompt: %w", err)
+	}
+
+	ai.L.Debug("Asking LLM to repair the Dockerfile syntax", map[string]string{
+		"errors": strings.Join(req.Errors, "\n"),
+	})
+
+	params := openai.ChatCompletionNewParams{
+		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(systemInstructions),
+			openai.UserMessage(userQuery),
+		}),
+		ResponseFormat: openai.F(repairOutput.OpenAIResponseFormat()),
+		Model:          openai.F(ai.Model),
+	}
+
+	for i := 0; i < MaxLLMCalls; i++ {
+		response, err := ai.complete(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+
+		repairResponse, err := repairOutput.Parse(response.Choices[0].Message.Content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse response from LLM: %w", err)
+		}
+		ai.L.Debug("Received repaired Dockerfile from LLM", map[string]string{
+			"dockerfile":  repairResponse.Dockerfile,
+			"explanation": repairResponse.Explanation,
+		})
+
+		ok, err := dockerfile.Validate(repairResponse.Dockerfile)
+		if !ok {
+			data := map[string]string{
+				"error": err.Error(),
+			}
+			ai.L.Debug("LLM returned an invalid Dockerfile", data)
+
+			feedback, _ := promptcreator.ConstructPrompt(InvalidDockerfileInResponsePrompt, data)
+			params.Messages.Value = append(
+				params.Messages.Value,
+				openai.AssistantMessage(response.Choices[0].Message.Content),
+				openai.SystemMessage(feedback),
+			)
+			continue
+		}
+		return repairResponse, nil
+	}
+
+	return nil, fmt.Errorf("failed to repair the Dockerfile within %d LLM calls", MaxLLMCalls)
+}
@@ -0,0 +1,96 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/ai/promptcreator"
+	"github.com/openai/openai-go"
+)
+
+// ReviseChange redoes a single change which the user rejected while reviewing an optimization.
+// Every revision of the same change is part of one conversation, so the model
+// sees all the feedback given so far.
+func (ai *AIService) ReviseChange(ctx context.Context, req *ReviseRequest) (*ReviseResponse, error) {
+	if len(req.Feedback) == 0 {
+		return nil, errors.New("no feedback given for the revision")
+	}
+
+	systemInstructions, err := promptcreator.ConstructPrompt(ai.prompt("ReviseChangeSystemPrompt"), map[string]string{"TripleBackticks": "```"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct system prompt: %w", err)
+	}
+	userQuery, err := ai.constructReviseUserQuery(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct user prompt: %w", err)
+	}
+
+	messages := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(systemInstructions),
+		openai.UserMessage(userQuery),
+	}
+	// replay the earlier revisions along with the feedback the user gave on them
+	for i, revision := range req.Revisions {
+		if i+1 >= len(req.Feedback) {
+			break
+		}
+		content, err := json.Marshal(revision)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize revision: %w", err)
+		}
+		followUp, err := promptcreator.ConstructPrompt(ai.prompt("ReviseChangeFollowUpPrompt"), map[string]string{"Feedback": req.Feedback[i+1]})
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct follow-up prompt: %w", err)
+		}
+		messages = append(messages, openai.AssistantMessage(string(content)), openai.UserMessage(followUp))
+	}
+
+	ai.L.Debug("Asking LLM to revise a rejected change", map[string]string{
+		"filepath": req.Filepath,
+		"feedback": req.Feedback[len(req.Feedback)-1],
+	})
+
+	params := openai.ChatCompletionNewParams{
+		Messages:       openai.F(messages),
+		ResponseFormat: openai.F(reviseOutput.OpenAIResponseFormat()),
+		Model:          openai.F(ai.Model),
+	}
+	response, err := ai.complete(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	reviseResponse, err := reviseOutput.Parse(response.Choices[0].Message.Content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse response from LLM: %w", err)
+	}
+	ai.L.Debug("Received revision from LLM", map[string]string{
+		"lines":       reviseResponse.Lines,
+		"explanation": reviseResponse.Explanation,
+	})
+	return reviseResponse, nil
+}
+
+func (ai *AIService) constructReviseUserQuery(req *ReviseRequest) (string, error) {
+	actions := ""
+	for _, a := range req.Actions {
+		actions += fmt.Sprintf("- %s: %s\n", a.Title, a.Description)
+	}
+	if actions == "" {
+		actions = "None\n"
+	}
+	data := map[string]string{
+		"TripleBackticks": "```",
+		"Filepath":        req.Filepath,
+		"OriginalFile":    req.OriginalFile,
+		"OptimizedFile":   req.OptimizedFile,
+		"Actions":         actions,
+		"OriginalLines":   strings.Join(req.OriginalLines, "\n"),
+		"ProposedLines":   strings.Join(req.ProposedLines, "\n"),
+		"Feedback":        req.Feedback[0],
+	}
+	return promptcreator.ConstructPrompt(ai.prompt("ReviseChangeUserPrompt"), data)
+}
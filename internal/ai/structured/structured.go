@@ -0,0 +1,173 @@
+// Package structured declares the responses expected from LLMs once, as Go types,
+// and compiles them to the mechanism each provider offers for structured output.
+package structured
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+	"github.com/openai/openai-go"
+)
+
+// Mechanism is how a provider is made to return output that conforms to a schema
+type Mechanism string
+
+const (
+	// MechanismStrictSchema constrains decoding to the schema, eg- OpenAI Structured Outputs
+	MechanismStrictSchema Mechanism = "strict_schema"
+	// MechanismTool forces the model to call a tool whose input is the schema, eg- Anthropic tool use
+	MechanismTool Mechanism = "tool"
+	// MechanismJSONMode only guarantees syntactically valid JSON.
+	// The schema is described in the prompt and the output is validated against it.
+	MechanismJSONMode Mechanism = "json_mode"
+)
+
+// Output is a structured response of type T expected from an LLM
+type Output[T any] struct {
+	Name        string
+	Description string
+
+	schema *jsonschema.Schema
+	// compiled is the schema as plain JSON values, which is what validation and non-OpenAI providers use
+	compiled map[string]any
+}
+
+// Tool is a tool definition in the format used by Anthropic's Messages API
+type Tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+// Compiled is a schema in the form expected by a provider's API
+type Compiled struct {
+	Mechanism Mechanism
+	// ResponseFormat is the "response_format" parameter, set for MechanismStrictSchema and MechanismJSONMode
+	ResponseFormat map[string]any
+	// Tool and ToolChoice are set for MechanismTool
+	Tool       *Tool
+	ToolChoice map[string]any
+	// Instructions describe the schema and must be added to the prompt for MechanismJSONMode
+	Instructions string
+}
+
+// New reflects the JSON schema of T.
+// It panics if T can't be represented as a schema, so outputs should be declared at package level.
+func New[T any](name, description string) *Output[T] {
+	// Structured Outputs uses a subset of JSON schema
+	// These flags are necessary to comply with the subset
+	reflector := jsonschema.Reflector{
+		AllowAdditionalProperties: false,
+		DoNotReference:            true,
+	}
+	var v T
+	schema := reflector.Reflect(v)
+
+	content, err := json.Marshal(schema)
+	if err != nil {
+		panic(fmt.Sprintf("invalid schema for %s: %v", name, err))
+	}
+	compiled := map[string]any{}
+	if err := json.Unmarshal(content, &compiled); err != nil {
+		panic(fmt.Sprintf("invalid schema for %s: %v", name, err))
+	}
+	// providers reject the meta keywords or ignore them at best
+	delete(compiled, "$schema")
+	delete(compiled, "$id")
+
+	return &Output[T]{Name: name, Description: description, schema: schema, compiled: compiled}
+}
+
+// Schema returns the JSON schema of the output
+func (o *Output[T]) Schema() map[string]any {
+	return o.compiled
+}
+
+// Compile returns the schema in the form expected by a provider that supports the given mechanism
+func (o *Output[T]) Compile(m Mechanism) *Compiled {
+	c := &Compiled{Mechanism: m}
+	switch m {
+	case MechanismStrictSchema:
+		c.ResponseFormat = map[string]any{
+			"type": "json_schema",
+			"json_schema": map[string]any{
+				"name":        o.Name,
+				"description": o.Description,
+				"schema":      o.compiled,
+				"strict":      true,
+			},
+		}
+	case MechanismTool:
+		c.Tool = &Tool{Name: o.Name, Description: o.Description, InputSchema: o.compiled}
+		c.ToolChoice = map[string]any{"type": "tool", "name": o.Name}
+	default:
+		c.ResponseFormat = map[string]any{"type": "json_object"}
+		c.Instructions = o.Instructions()
+	}
+	return c
+}
+
+// OpenAIResponseFormat returns the response format that makes OpenAI models return the output
+// using Structured Outputs
+func (o *Output[T]) OpenAIResponseFormat() openai.ChatCompletionNewParamsResponseFormatUnion {
+	return openai.ResponseFormatJSONSchemaParam{
+		Type: openai.F(openai.ResponseFormatJSONSchemaTypeJSONSchema),
+		JSONSchema: openai.F(openai.ResponseFormatJSONSchemaJSONSchemaParam{
+			Name:        openai.F(o.Name),
+			Description: openai.F(o.Description),
+			Schema:      openai.F[interface{}](o.schema),
+			Strict:      openai.Bool(true),
+		}),
+	}
+}
+
+// Instructions describe the expected output to models that can't be constrained to a schema
+func (o *Output[T]) Instructions() string {
+	schema, _ := json.MarshalIndent(o.compiled, "", "  ")
+	return fmt.Sprintf(
+		"Respond with a single JSON object and nothing else. The object is %s and must conform to this JSON schema:\n```json\n%s\n```",
+		strings.TrimSuffix(lowerFirst(o.Description), "."), schema,
+	)
+}
+
+// Parse validates the content returned by a model against the schema and decodes it.
+// Markdown code fences around the JSON, which models in JSON mode sometimes add, are removed.
+func (o *Output[T]) Parse(content string) (*T, error) {
+	content = stripCodeFence(content)
+
+	var value any
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return nil, fmt.Errorf("response is not valid JSON: %w", err)
+	}
+	if err := validate(o.compiled, value, "$"); err != nil {
+		return nil, fmt.Errorf("response doesn't match the %s schema: %w", o.Name, err)
+	}
+
+	out := new(T)
+	if err := json.Unmarshal([]byte(content), out); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return out, nil
+}
+
+func stripCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") {
+		return content
+	}
+	content = strings.TrimPrefix(content, "```")
+	// drop the language of the fence, eg- ```json
+	if i := strings.IndexByte(content, '\n'); i >= 0 {
+		content = content[i+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(content), "```"))
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
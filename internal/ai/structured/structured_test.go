@@ -0,0 +1,78 @@
+package structured
+
+import (
+	"strings"
+	"testing"
+)
+
+type testAction struct {
+	Title string `json:"title" jsonschema_description:"Title of the action"`
+	Line  int    `json:"line"`
+}
+
+type testResponse struct {
+	Dockerfile string        `json:"dockerfile" jsonschema_description:"The optimized Dockerfile"`
+	Actions    []*testAction `json:"actions"`
+}
+
+var testOutput = New[testResponse]("modifications", "The optimized Dockerfile along with the actions taken")
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"valid", `{"dockerfile": "FROM node", "actions": [{"title": "a", "line": 1}]}`, ""},
+		{"code fence", "```json\n{\"dockerfile\": \"FROM node\", \"actions\": []}\n```", ""},
+		{"invalid json", `{"dockerfile": `, "not valid JSON"},
+		{"missing property", `{"dockerfile": "FROM node"}`, `$: missing required property "actions"`},
+		{"wrong type", `{"dockerfile": "FROM node", "actions": [{"title": 1, "line": 1}]}`, "$.actions[0].title: expected string, got number"},
+		{"not an integer", `{"dockerfile": "FROM node", "actions": [{"title": "a", "line": 1.5}]}`, "$.actions[0].line: expected integer"},
+		{"unexpected property", `{"dockerfile": "FROM node", "actions": [], "extra": true}`, `unexpected property "extra"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testOutput.Parse(tt.content)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Parse() failed: %v", err)
+				}
+				if got.Dockerfile != "FROM node" {
+					t.Errorf("Dockerfile = %q; want %q", got.Dockerfile, "FROM node")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v; want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	strict := testOutput.Compile(MechanismStrictSchema)
+	jsonSchema := strict.ResponseFormat["json_schema"].(map[string]any)
+	if strict.ResponseFormat["type"] != "json_schema" || jsonSchema["strict"] != true || jsonSchema["name"] != "modifications" {
+		t.Errorf("strict schema response format = %v", strict.ResponseFormat)
+	}
+	if _, ok := jsonSchema["schema"].(map[string]any)["$schema"]; ok {
+		t.Errorf("compiled schema must not contain $schema")
+	}
+
+	tool := testOutput.Compile(MechanismTool)
+	if tool.Tool == nil || tool.Tool.Name != "modifications" || tool.ToolChoice["name"] != "modifications" {
+		t.Errorf("tool = %+v, tool choice = %v", tool.Tool, tool.ToolChoice)
+	}
+	if tool.Tool.InputSchema["type"] != "object" {
+		t.Errorf("tool input schema = %v; want an object schema", tool.Tool.InputSchema)
+	}
+
+	jsonMode := testOutput.Compile(MechanismJSONMode)
+	if jsonMode.ResponseFormat["type"] != "json_object" {
+		t.Errorf("JSON mode response format = %v", jsonMode.ResponseFormat)
+	}
+	if !strings.Contains(jsonMode.Instructions, `"dockerfile"`) || !strings.Contains(jsonMode.Instructions, "the optimized Dockerfile along with the actions taken") {
+		t.Errorf("JSON mode instructions don't describe the schema: %s", jsonMode.Instructions)
+	}
+}
@@ -1,14 +1,28 @@
 package ai
 
-import "github.com/openai/openai-go"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
 
-// TODO: Add the "get_documentation" tool
+	"github.com/duaraghav8/dockershrink/internal/ai/promptcreator"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+	"github.com/duaraghav8/dockershrink/pkg/events"
+	"github.com/openai/openai-go"
+)
 
 const (
 	ToolReadFiles         = "read_files"
 	ToolDeveloperFeedback = "developer_feedback"
+	ToolGetDocumentation  = "get_documentation"
 )
 
+// DocumentationResults is the number of passages returned by the get_documentation tool
+const DocumentationResults = 3
+
 var availableTools = []openai.ChatCompletionToolParam{
 	{
 		Type: openai.F(openai.ChatCompletionToolTypeFunction),
@@ -46,3 +60,125 @@ var availableTools = []openai.ChatCompletionToolParam{
 		}),
 	},
 }
+
+var getDocumentationTool = openai.ChatCompletionToolParam{
+	Type: openai.F(openai.ChatCompletionToolTypeFunction),
+	Function: openai.F(openai.FunctionDefinitionParam{
+		Name:        openai.String(ToolGetDocumentation),
+		Description: openai.String("Search dockershrink's documentation on optimizing Docker images"),
+		Parameters: openai.F(openai.FunctionParameters{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "What you want to know, eg- \"cache mount target for yarn\"",
+				},
+			},
+			"required": []string{"query"},
+		}),
+	}),
+}
+
+// tools returns the tools offered to the LLM. get_documentation is only offered if documentation is available.
+func (ai *AIService) tools() []openai.ChatCompletionToolParam {
+	if ai.Docs == nil {
+		return availableTools
+	}
+	tools := append([]openai.ChatCompletionToolParam{}, availableTools...)
+	return append(tools, getDocumentationTool)
+}
+
+// callTool runs a tool called by the LLM and returns the response to send back to it.
+// Mistakes of the LLM, eg- reading a file that doesn't exist, are sent back as feedback,
+// an error is only returned if the conversation can't go on.
+func (ai *AIService) callTool(ctx context.Context, dir *restrictedfilesystem.RestrictedFilesystem, toolCall openai.ChatCompletionMessageToolCall) (string, error) {
+	ai.Events.Emit(events.ToolCallStarted{Tool: toolCall.Function.Name, Arguments: toolCall.Function.Arguments})
+
+	switch toolCall.Function.Name {
+	case ToolReadFiles:
+		var extractedParams struct {
+			Filepaths []string `json:"filepaths"`
+		}
+		if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &extractedParams); err != nil {
+			return "", fmt.Errorf("failed to parse function call arguments (%s) from LLM: %w", toolCall.Function.Arguments, err)
+		}
+		if len(extractedParams.Filepaths) == 0 {
+			// LLM called the tool without any files to read.
+			// Send feedback, no need to run the tool.
+			ai.Events.Emit(events.ToolCallFinished{Tool: toolCall.Function.Name})
+			return ToolReadFilesNoFilesSpecifiedPrompt, nil
+		}
+
+		ai.L.Debug(
+			"Tool info",
+			map[string]string{
+				"tool":      toolCall.Function.Name,
+				"filepaths": strings.Join(extractedParams.Filepaths, "\n"),
+			},
+		)
+
+		projectFiles, err := dir.ReadFiles(ctx, extractedParams.Filepaths)
+		if err != nil {
+			// If no such file or directory was found, the LLM probably hallucinated and gave an incorrect filepath.
+			// Send feedback to it.
+			if errors.Is(err, fs.ErrNotExist) {
+				data := map[string]string{
+					"Filepath": "",
+				}
+				if pathErr, ok := err.(*fs.PathError); ok {
+					data["Filepath"] = pathErr.Path
+				}
+				fileNotFoundPrompt, _ := promptcreator.ConstructPrompt(RequestedFileNotFoundPrompt, data)
+
+				ai.L.Debug(
+					"Filepath requested by LLM does not exist, sending feedback to it.",
+					map[string]string{
+						"filepath":        data["Filepath"],
+						"response_to_llm": fileNotFoundPrompt,
+					},
+				)
+				ai.Events.Emit(events.ToolCallFinished{Tool: toolCall.Function.Name, Err: err})
+				return fileNotFoundPrompt, nil
+			}
+
+			return "", fmt.Errorf("failed to read file(s) from the project requested by LLM: %w", err)
+		}
+
+		ai.L.Debug(
+			fmt.Sprintf("Tool %s response: Sending back the files requested by LLM", ToolReadFiles),
+			nil,
+		)
+		ai.Events.Emit(events.ToolCallFinished{Tool: toolCall.Function.Name})
+		return readFilesResponse(extractedParams.Filepaths, projectFiles, ai.fileLimits()), nil
+
+	case ToolGetDocumentation:
+		responsePrompt, err := ai.getDocumentation(ctx, toolCall.Function.Arguments)
+		if err != nil {
+			return "", err
+		}
+		ai.Events.Emit(events.ToolCallFinished{Tool: toolCall.Function.Name})
+		return responsePrompt, nil
+
+	case ToolDeveloperFeedback:
+		var extractedParams struct {
+			Feedback string `json:"feedback"`
+		}
+		if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &extractedParams); err != nil {
+			return "", fmt.Errorf("failed to parse %s function call arguments (%s) from LLM: %w", ToolDeveloperFeedback, toolCall.Function.Arguments, err)
+		}
+
+		ai.L.Debug(
+			"Received feedback for Developer from LLM",
+			map[string]string{
+				"feedback": extractedParams.Feedback,
+			},
+		)
+		ai.Events.Emit(events.ToolCallFinished{Tool: toolCall.Function.Name})
+		return extractedParams.Feedback, nil
+	}
+
+	// every tool call must be answered for the conversation to go on
+	err := fmt.Errorf("no such tool: %s", toolCall.Function.Name)
+	ai.Events.Emit(events.ToolCallFinished{Tool: toolCall.Function.Name, Err: err})
+	return promptcreator.ConstructPrompt(UnknownToolPrompt, map[string]string{"Tool": toolCall.Function.Name})
+}
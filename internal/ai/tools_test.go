@@ -0,0 +1,70 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/docs"
+	"github.com/duaraghav8/dockershrink/internal/log"
+)
+
+func TestTools_Documentation(t *testing.T) {
+	withoutDocs := &AIService{}
+	withDocs := &AIService{Docs: docs.NewIndex(docs.Passages(), nil, "")}
+
+	hasTool := func(ai *AIService) bool {
+		for _, tool := range ai.tools() {
+			if tool.Function.Value.Name.Value == ToolGetDocumentation {
+				return true
+			}
+		}
+		return false
+	}
+	if hasTool(withoutDocs) {
+		t.Errorf("%s is offered without documentation", ToolGetDocumentation)
+	}
+	if !hasTool(withDocs) {
+		t.Errorf("%s isn't offered with documentation", ToolGetDocumentation)
+	}
+
+	for _, ai := range []*AIService{withoutDocs, withDocs} {
+		prompt, err := ai.constructOptimizeSystemInstructions(&OptimizeRequest{DockerfileStageCount: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mentioned := strings.Contains(prompt, ToolGetDocumentation); mentioned != (ai.Docs != nil) {
+			t.Errorf("system prompt mentions %s = %v; want %v", ToolGetDocumentation, mentioned, ai.Docs != nil)
+		}
+	}
+}
+
+func TestGetDocumentation(t *testing.T) {
+	ai := &AIService{
+		L: log.NewLogger(false),
+		Docs: docs.NewIndex([]*docs.Passage{
+			{Source: "build-cache.md", Title: "Build cache: Cache mounts", Text: "Use --mount=type=cache,target=/root/.npm"},
+		}, nil, ""),
+	}
+	tests := []struct {
+		arguments string
+		expected  string
+		wantErr   bool
+	}{
+		{arguments: `{"query": "npm cache mount"}`, expected: "### Build cache: Cache mounts\nUse --mount=type=cache,target=/root/.npm\n\n"},
+		{arguments: `{"query": "kubernetes"}`, expected: ToolGetDocumentationNoResultsPrompt},
+		{arguments: `{"query": " "}`, expected: ToolGetDocumentationNoQueryPrompt},
+		{arguments: `{"query": `, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arguments, func(t *testing.T) {
+			response, err := ai.getDocumentation(context.Background(), tt.arguments)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getDocumentation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if response != tt.expected {
+				t.Errorf("getDocumentation() = %q; want %q", response, tt.expected)
+			}
+		})
+	}
+}
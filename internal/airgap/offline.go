@@ -0,0 +1,241 @@
+// Package airgap packs the data dockershrink fetches from the network into a bundle, so that static analysis
+// and the documentation search work on machines without internet access. A bundle downloaded on a connected
+// machine holds the base image matrix, including the vulnerability counts of the tags that were scanned,
+// and the embeddings of the documentation computed by the configured embeddings provider.
+// The documentation itself and the rules are built into dockershrink and don't need to be downloaded.
+//
+// A bundle is a gzipped tar archive whose manifest records the SHA-256 of every other entry.
+package airgap
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/baseimages"
+)
+
+// FormatVersion is the version of the bundle format written by this version of dockershrink
+const FormatVersion = 1
+
+// Names of the entries of the archive. Embeddings are stored under EmbeddingsDir, named like the files of the embeddings cache.
+const (
+	ManifestFile   = "manifest.json"
+	BaseImagesFile = "baseimages.json"
+	EmbeddingsDir  = "embeddings/"
+)
+
+// maxEntrySize is the size of the biggest entry read from a bundle, larger ones are refused
+const maxEntrySize = 64 << 20
+
+// Manifest describes a bundle and records the checksum of every entry
+type Manifest struct {
+	FormatVersion       int       `json:"format_version"`
+	DockershrinkVersion string    `json:"dockershrink_version"`
+	CreatedAt           time.Time `json:"created_at"`
+	// Images are the names of the official images in the base image matrix
+	Images []string `json:"images"`
+	// ScannedTags is the number of tags whose vulnerabilities were counted
+	ScannedTags int `json:"scanned_tags"`
+	// EmbeddingsProviders are the providers the embeddings of the documentation were computed by, eg- "local/embed.py"
+	EmbeddingsProviders []string `json:"embeddings_providers,omitempty"`
+	// Files maps the name of every other entry to its hex-encoded SHA-256
+	Files map[string]string `json:"files"`
+}
+
+// Bundle is the content of an offline bundle
+type Bundle struct {
+	Manifest   *Manifest
+	BaseImages *baseimages.Matrix
+	// Embeddings maps the names of the files of the embeddings cache to their content
+	Embeddings map[string][]byte
+}
+
+// New returns a bundle of the base image matrix
+func New(version string, m *baseimages.Matrix) *Bundle {
+	b := &Bundle{
+		Manifest:   &Manifest{DockershrinkVersion: version, CreatedAt: time.Now().UTC()},
+		BaseImages: m,
+		Embeddings: map[string][]byte{},
+	}
+	for name, img := range m.Images {
+		b.Manifest.Images = append(b.Manifest.Images, name)
+		for _, t := range img.Tags {
+			if t.CVEs != nil {
+				b.Manifest.ScannedTags++
+			}
+		}
+	}
+	sort.Strings(b.Manifest.Images)
+	return b
+}
+
+// AddEmbeddings adds the cache file of the embeddings computed by the provider
+func (b *Bundle) AddEmbeddings(provider, cacheFile string) error {
+	content, err := os.ReadFile(cacheFile)
+	if err != nil {
+		return fmt.Errorf("failed to read the embeddings of the documentation: %w", err)
+	}
+	b.Embeddings[filepath.Base(cacheFile)] = content
+	b.Manifest.EmbeddingsProviders = append(b.Manifest.EmbeddingsProviders, provider)
+	return nil
+}
+
+// Write writes the bundle to w as a gzipped tar archive, computing the checksums of the manifest
+func Write(w io.Writer, b *Bundle) error {
+	matrix, err := json.MarshalIndent(b.BaseImages, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize base image matrix: %w", err)
+	}
+	entries := map[string][]byte{BaseImagesFile: matrix}
+	for name, content := range b.Embeddings {
+		entries[EmbeddingsDir+name] = content
+	}
+
+	b.Manifest.FormatVersion = FormatVersion
+	b.Manifest.Files = map[string]string{}
+	for name, content := range entries {
+		b.Manifest.Files[name] = checksum(content)
+	}
+	manifest, err := json.MarshalIndent(b.Manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize manifest: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// the manifest comes first so that it can be inspected with "tar -tzf" without reading everything
+	names = append([]string{ManifestFile}, names...)
+	entries[ManifestFile] = manifest
+	for _, name := range names {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(entries[name])), ModTime: b.Manifest.CreatedAt}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if _, err := tw.Write(entries[name]); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Read reads a bundle written by Write and verifies the checksums of its entries
+func Read(r io.Reader) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not an offline bundle: %w", err)
+	}
+	defer gz.Close()
+
+	entries := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("unexpected entry %s in bundle", hdr.Name)
+		}
+		if hdr.Size > maxEntrySize {
+			return nil, fmt.Errorf("entry %s of bundle is too large (%d bytes)", hdr.Name, hdr.Size)
+		}
+		content, err := io.ReadAll(io.LimitReader(tr, maxEntrySize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+		}
+		entries[hdr.Name] = content
+	}
+
+	manifestContent, ok := entries[ManifestFile]
+	if !ok {
+		return nil, fmt.Errorf("bundle has no %s, it isn't an offline bundle", ManifestFile)
+	}
+	b := &Bundle{Manifest: &Manifest{}, Embeddings: map[string][]byte{}}
+	if err := json.Unmarshal(manifestContent, b.Manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ManifestFile, err)
+	}
+	if b.Manifest.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("the bundle was created by a newer version of dockershrink (%s), upgrade to import it", b.Manifest.DockershrinkVersion)
+	}
+
+	for name, content := range entries {
+		if name == ManifestFile {
+			continue
+		}
+		expected, ok := b.Manifest.Files[name]
+		if !ok {
+			return nil, fmt.Errorf("entry %s isn't listed in the manifest", name)
+		}
+		if checksum(content) != expected {
+			return nil, fmt.Errorf("checksum of %s doesn't match the manifest, the bundle is corrupt or was tampered with", name)
+		}
+		if embeddings, ok := strings.CutPrefix(name, EmbeddingsDir); ok {
+			// entries are named like files of the cache, they must not escape it
+			if embeddings != path.Base(embeddings) || embeddings == "" || strings.HasPrefix(embeddings, ".") {
+				return nil, fmt.Errorf("invalid entry %s in bundle", name)
+			}
+			b.Embeddings[embeddings] = content
+		}
+	}
+	for name := range b.Manifest.Files {
+		if _, ok := entries[name]; !ok {
+			return nil, fmt.Errorf("bundle is missing %s", name)
+		}
+	}
+
+	matrix, ok := entries[BaseImagesFile]
+	if !ok {
+		return nil, fmt.Errorf("bundle has no %s", BaseImagesFile)
+	}
+	b.BaseImages = &baseimages.Matrix{}
+	if err := json.Unmarshal(matrix, b.BaseImages); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", BaseImagesFile, err)
+	}
+	return b, nil
+}
+
+// Install adds the base image matrix to the cache and writes the embeddings to the embeddings cache directory
+func (b *Bundle) Install(cache *baseimages.Cache, embeddingsDir string) error {
+	if err := cache.Import(b.BaseImages); err != nil {
+		return err
+	}
+	if len(b.Embeddings) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(embeddingsDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", embeddingsDir, err)
+	}
+	for name, content := range b.Embeddings {
+		if err := os.WriteFile(filepath.Join(embeddingsDir, name), content, 0o644); err != nil {
+			return fmt.Errorf("failed to write the embeddings of the documentation: %w", err)
+		}
+	}
+	return nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
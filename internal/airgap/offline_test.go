@@ -0,0 +1,162 @@
+package airgap
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/baseimages"
+)
+
+func testMatrix(fetchedAt time.Time, size int64) *baseimages.Matrix {
+	cves := &baseimages.CVECount{High: 2, ScannedAt: fetchedAt}
+	return &baseimages.Matrix{FetchedAt: fetchedAt, Images: map[string]*baseimages.Image{
+		"node": {
+			Name:      "node",
+			Tags:      map[string]*baseimages.Tag{"22-alpine": {Size: size, CVEs: cves}, "22": {Size: 400_000_000}},
+			FetchedAt: fetchedAt,
+		},
+	}}
+}
+
+func TestWriteReadInstall(t *testing.T) {
+	fetchedAt := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	b := New("test", testMatrix(fetchedAt, 50_000_000))
+	cacheFile := filepath.Join(t.TempDir(), "0123456789abcdef.json")
+	os.WriteFile(cacheFile, []byte("[[1,0],[0,1]]"), 0o644)
+	if err := b.AddEmbeddings("local/embed.py", cacheFile); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, b); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	read, err := Read(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	m := read.Manifest
+	if m.FormatVersion != FormatVersion || m.ScannedTags != 1 || !reflect.DeepEqual(m.Images, []string{"node"}) || !reflect.DeepEqual(m.EmbeddingsProviders, []string{"local/embed.py"}) {
+		t.Errorf("unexpected manifest %+v", m)
+	}
+	if read.BaseImages.Image("node").Tags["22-alpine"].CVEs.High != 2 {
+		t.Errorf("expected the vulnerability counts to be kept")
+	}
+
+	// cached images fetched more recently are kept
+	cache := baseimages.NewCache(t.TempDir())
+	newer := testMatrix(fetchedAt.Add(time.Hour), 60_000_000)
+	newer.Images["python"] = &baseimages.Image{Name: "python", FetchedAt: fetchedAt.Add(-time.Hour)}
+	if err := cache.Write(newer); err != nil {
+		t.Fatal(err)
+	}
+	read.BaseImages.Images["python"] = &baseimages.Image{Name: "python", Tags: map[string]*baseimages.Tag{"3.13-slim": {Size: 1}}, FetchedAt: fetchedAt}
+	embeddingsDir := filepath.Join(t.TempDir(), "embeddings")
+	if err := read.Install(cache, embeddingsDir); err != nil {
+		t.Fatalf("Install() failed: %v", err)
+	}
+	cached, err := cache.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := cached.Image("node").Tags["22-alpine"].Size; size != 60_000_000 {
+		t.Errorf("expected the newer cached node image to be kept, got a size of %d", size)
+	}
+	if _, ok := cached.Image("python").Tags["3.13-slim"]; !ok {
+		t.Errorf("expected the older cached python image to be replaced")
+	}
+	if content, err := os.ReadFile(filepath.Join(embeddingsDir, "0123456789abcdef.json")); err != nil || string(content) != "[[1,0],[0,1]]" {
+		t.Errorf("embeddings = %q, %v", content, err)
+	}
+}
+
+func TestRead_Invalid(t *testing.T) {
+	var valid bytes.Buffer
+	if err := Write(&valid, New("test", testMatrix(time.Now(), 1))); err != nil {
+		t.Fatal(err)
+	}
+	entries := readEntries(t, valid.Bytes())
+
+	tests := []struct {
+		name     string
+		modify   func(entries map[string][]byte)
+		expected string
+	}{
+		{
+			name:     "tampered entry",
+			modify:   func(e map[string][]byte) { e[BaseImagesFile] = []byte(`{"images":{}}`) },
+			expected: "checksum",
+		},
+		{
+			name:     "unlisted entry",
+			modify:   func(e map[string][]byte) { e[EmbeddingsDir+"x.json"] = []byte("[]") },
+			expected: "isn't listed",
+		},
+		{
+			name:     "missing manifest",
+			modify:   func(e map[string][]byte) { delete(e, ManifestFile) },
+			expected: "isn't an offline bundle",
+		},
+		{
+			name: "newer format",
+			modify: func(e map[string][]byte) {
+				e[ManifestFile] = bytes.Replace(e[ManifestFile], []byte(`"format_version": 1`), []byte(`"format_version": 99`), 1)
+			},
+			expected: "newer version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modified := map[string][]byte{}
+			for name, content := range entries {
+				modified[name] = content
+			}
+			tt.modify(modified)
+			_, err := Read(bytes.NewReader(writeEntries(t, modified)))
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Read() = %v; want an error containing %q", err, tt.expected)
+			}
+		})
+	}
+}
+
+func readEntries(t *testing.T, archive []byte) map[string][]byte {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		var buf bytes.Buffer
+		buf.ReadFrom(tr)
+		entries[hdr.Name] = buf.Bytes()
+	}
+	return entries
+}
+
+func writeEntries(t *testing.T, entries map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range entries {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))})
+		tw.Write(content)
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
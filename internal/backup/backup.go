@@ -0,0 +1,203 @@
+// Package backup keeps copies of the project files dockershrink is about to overwrite, so that the changes of a run
+// can be reverted without going through git.
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/history"
+)
+
+const manifestFile = "manifest.json"
+
+// File is a file written by a run
+type File struct {
+	// Path is slash-separated and relative to the project, eg- services/api/Dockerfile
+	Path string `json:"path"`
+	// Existed is false if the run created the file, reverting the run deletes it then
+	Existed bool `json:"existed"`
+	// Written is the sha256 of what the run wrote, to tell whether the file was changed afterwards
+	Written string `json:"written"`
+}
+
+// Backup holds the originals of the files written by a run, in .dockershrink/backups/<ID>/ inside the project
+type Backup struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Command   string    `json:"command"`
+	Files     []*File   `json:"files"`
+
+	projectDir string
+	dir        string
+}
+
+// Store manages the backups of a project
+type Store struct {
+	projectDir string
+	dir        string
+}
+
+func NewStore(projectDir string) *Store {
+	return &Store{projectDir: projectDir, dir: filepath.Join(projectDir, history.Dir, "backups")}
+}
+
+// New returns an empty backup for a run of the command. Nothing is written until a file is added to it.
+func (s *Store) New(command string) *Backup {
+	now := time.Now()
+	id := history.NewRunID(now)
+	return &Backup{ID: id, Timestamp: now, Command: command, projectDir: s.projectDir, dir: filepath.Join(s.dir, id)}
+}
+
+// Add copies the file at path, if it exists, into the backup before content is written to it.
+// Files outside the project aren't backed up, and a file added twice keeps its first original.
+func (b *Backup) Add(path string, content []byte) error {
+	rel, err := b.relativePath(path)
+	if err != nil {
+		return err
+	}
+	if rel == "" {
+		return nil
+	}
+	slashRel := filepath.ToSlash(rel)
+	for _, f := range b.Files {
+		if f.Path == slashRel {
+			f.Written = checksum(content)
+			return b.save()
+		}
+	}
+
+	f := &File{Path: slashRel, Written: checksum(content)}
+	original, err := os.ReadFile(filepath.Join(b.projectDir, rel))
+	switch {
+	case err == nil:
+		f.Existed = true
+		dest := filepath.Join(b.dir, "files", rel)
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return fmt.Errorf("failed to create backup directory: %w", err)
+		}
+		if err := os.WriteFile(dest, original, 0o644); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", slashRel, err)
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("failed to back up %s: %w", slashRel, err)
+	}
+	b.Files = append(b.Files, f)
+	return b.save()
+}
+
+// relativePath returns the path relative to the project, "" if it's outside of it
+func (b *Backup) relativePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	projectDir, err := filepath.Abs(b.projectDir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(projectDir, abs)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", nil
+	}
+	return rel, nil
+}
+
+// save writes the manifest, after every file so that a run that fails midway can still be reverted
+func (b *Backup) save() error {
+	if err := os.MkdirAll(b.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	content, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(b.dir, manifestFile), content, 0o644)
+}
+
+// Latest returns the most recent backup. The error wraps fs.ErrNotExist if there's none.
+func (s *Store) Latest() (*Backup, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read backups directory: %w", err)
+	}
+	ids := []string{}
+	for _, e := range entries {
+		if e.IsDir() {
+			ids = append(ids, e.Name())
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no backups found: %w", fs.ErrNotExist)
+	}
+	sort.Strings(ids)
+
+	dir := filepath.Join(s.dir, ids[len(ids)-1])
+	content, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup %s: %w", ids[len(ids)-1], err)
+	}
+	b := &Backup{projectDir: s.projectDir, dir: dir}
+	if err := json.Unmarshal(content, b); err != nil {
+		return nil, fmt.Errorf("failed to parse backup %s: %w", ids[len(ids)-1], err)
+	}
+	return b, nil
+}
+
+// Modified returns the paths of the files that were changed after the run wrote them.
+// Restoring them would lose those changes.
+func (b *Backup) Modified() ([]string, error) {
+	modified := []string{}
+	for _, f := range b.Files {
+		content, err := os.ReadFile(filepath.Join(b.projectDir, filepath.FromSlash(f.Path)))
+		if errors.Is(err, fs.ErrNotExist) {
+			modified = append(modified, f.Path)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if checksum(content) != f.Written {
+			modified = append(modified, f.Path)
+		}
+	}
+	return modified, nil
+}
+
+// Restore puts the originals back, deletes the files the run created and then the backup itself,
+// so that the backup of the run before it is the latest one
+func (b *Backup) Restore() error {
+	for _, f := range b.Files {
+		path := filepath.Join(b.projectDir, filepath.FromSlash(f.Path))
+		if !f.Existed {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("failed to delete %s: %w", f.Path, err)
+			}
+			continue
+		}
+		original, err := os.ReadFile(filepath.Join(b.dir, "files", filepath.FromSlash(f.Path)))
+		if err != nil {
+			return fmt.Errorf("failed to read the backup of %s: %w", f.Path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, original, 0o644); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", f.Path, err)
+		}
+	}
+	return os.RemoveAll(b.dir)
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
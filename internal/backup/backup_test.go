@@ -0,0 +1,86 @@
+package backup
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBackup(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		t.Helper()
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return "<missing>"
+		}
+		return string(content)
+	}
+	store := NewStore(dir)
+
+	if _, err := store.Latest(); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist without backups, got %v", err)
+	}
+
+	write("Dockerfile", "FROM node:20\n")
+	b := store.New("optimize")
+	changes := map[string]string{
+		"Dockerfile":                     "FROM node:20-alpine\n",
+		"services/api/.dockerignore":     "node_modules\n",
+		filepath.Join("..", "elsewhere"): "outside the project",
+	}
+	for name, content := range changes {
+		if err := b.Add(filepath.Join(dir, name), []byte(content)); err != nil {
+			t.Fatalf("Add(%s) failed: %v", name, err)
+		}
+		if filepath.IsLocal(name) {
+			write(name, content)
+		}
+	}
+	// the first original is kept when a file is written twice
+	if err := b.Add(filepath.Join(dir, "Dockerfile"), []byte("FROM node:22-alpine\n")); err != nil {
+		t.Fatal(err)
+	}
+	write("Dockerfile", "FROM node:22-alpine\n")
+
+	latest, err := store.Latest()
+	if err != nil {
+		t.Fatalf("Latest() failed: %v", err)
+	}
+	if latest.ID != b.ID || latest.Command != "optimize" || len(latest.Files) != 2 {
+		t.Fatalf("unexpected backup %+v", latest)
+	}
+	if modified, err := latest.Modified(); err != nil || len(modified) != 0 {
+		t.Errorf("Modified() = %v, %v; want none", modified, err)
+	}
+	write("services/api/.dockerignore", "node_modules\n.git\n")
+	if modified, err := latest.Modified(); err != nil || !reflect.DeepEqual(modified, []string{"services/api/.dockerignore"}) {
+		t.Errorf("Modified() = %v, %v; want the .dockerignore", modified, err)
+	}
+
+	if err := latest.Restore(); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if got := read("Dockerfile"); got != "FROM node:20\n" {
+		t.Errorf("Dockerfile = %q after restoring", got)
+	}
+	if got := read("services/api/.dockerignore"); got != "<missing>" {
+		t.Errorf("the created .dockerignore wasn't deleted, it contains %q", got)
+	}
+	if _, err := store.Latest(); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the backup to be deleted after restoring it, got %v", err)
+	}
+}
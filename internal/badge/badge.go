@@ -0,0 +1,173 @@
+// Package badge renders the score or the estimated image size of a Dockerfile as a badge for the README of its repository,
+// either as an SVG or as the JSON of a shields.io endpoint (https://shields.io/badges/endpoint-badge).
+package badge
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/units"
+)
+
+// Metric is what a badge shows
+type Metric string
+
+const (
+	MetricScore Metric = "score"
+	MetricSize  Metric = "size"
+)
+
+// Format is how a badge is rendered
+type Format string
+
+const (
+	FormatSVG     Format = "svg"
+	FormatShields Format = "shields"
+)
+
+// ParseMetric converts the given string into a Metric
+func ParseMetric(s string) (Metric, error) {
+	switch m := Metric(strings.ToLower(strings.TrimSpace(s))); m {
+	case MetricScore, MetricSize:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid badge metric %q, must be %s or %s", s, MetricScore, MetricSize)
+	}
+}
+
+// ParseFormat converts the given string into a Format
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case FormatSVG, FormatShields:
+		return f, nil
+	default:
+		return "", fmt.Errorf("invalid badge format %q, must be %s or %s", s, FormatSVG, FormatShields)
+	}
+}
+
+// Status is what badges can show about a Dockerfile
+type Status struct {
+	Score int
+	// Grade is the letter grade of the score, eg- "B"
+	Grade string
+	// EstimatedImageSize is in bytes, 0 if it couldn't be estimated
+	EstimatedImageSize int64
+}
+
+// Badge is a label along with a message on a colored background, eg- "dockershrink | 92/100 (A)"
+type Badge struct {
+	Label   string
+	Message string
+	// Color is the name of a shields.io color, eg- "brightgreen"
+	Color string
+}
+
+// colors maps the shields.io colors badges use to their RGB value
+var colors = map[string]string{
+	"brightgreen": "#4c1",
+	"yellow":      "#dfb317",
+	"red":         "#e05d44",
+	"blue":        "#007ec6",
+	"lightgrey":   "#9f9f9f",
+}
+
+// New returns the badge showing a metric of the status
+func New(m Metric, s *Status) *Badge {
+	if m == MetricSize {
+		return Size(s.EstimatedImageSize)
+	}
+	return Score(s.Score, s.Grade)
+}
+
+// Score returns the badge of a score. Like the CLI, it's red below 50, yellow below 80 and green otherwise.
+func Score(score int, grade string) *Badge {
+	color := "brightgreen"
+	if score < 50 {
+		color = "red"
+	} else if score < 80 {
+		color = "yellow"
+	}
+	message := fmt.Sprintf("%d/100", score)
+	if grade != "" {
+		message += " (" + grade + ")"
+	}
+	return &Badge{Label: "dockershrink", Message: message, Color: color}
+}
+
+// Size returns the badge of the estimated size of an image, "unknown" if it's 0
+func Size(bytes int64) *Badge {
+	if bytes <= 0 {
+		return &Badge{Label: "image size", Message: "unknown", Color: "lightgrey"}
+	}
+	return &Badge{Label: "image size", Message: units.FormatBytes(bytes), Color: "blue"}
+}
+
+// Render returns the badge in the given format along with its content type
+func (b *Badge) Render(f Format) ([]byte, string) {
+	if f == FormatShields {
+		return b.Endpoint(), "application/json"
+	}
+	return b.SVG(), "image/svg+xml"
+}
+
+// endpoint is the JSON schema of shields.io endpoint badges
+type endpoint struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Label         string `json:"label"`
+	Message       string `json:"message"`
+	Color         string `json:"color"`
+}
+
+// Endpoint returns the badge as the JSON of a shields.io endpoint
+func (b *Badge) Endpoint() []byte {
+	content, _ := json.MarshalIndent(endpoint{SchemaVersion: 1, Label: b.Label, Message: b.Message, Color: b.Color}, "", "  ")
+	return append(content, '\n')
+}
+
+// SVG returns the badge as an SVG in the flat style of shields.io
+func (b *Badge) SVG() []byte {
+	labelWidth, messageWidth := textWidth(b.Label)+10, textWidth(b.Message)+10
+	width := labelWidth + messageWidth
+	color, ok := colors[b.Color]
+	if !ok {
+		color = colors["lightgrey"]
+	}
+	label, message := html.EscapeString(b.Label), html.EscapeString(b.Message)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">`, width, label, message)
+	fmt.Fprintf(&sb, `<title>%s: %s</title>`, label, message)
+	sb.WriteString(`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`)
+	fmt.Fprintf(&sb, `<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>`, width)
+	fmt.Fprintf(&sb, `<g clip-path="url(#r)"><rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="%s"/><rect width="%d" height="20" fill="url(#s)"/></g>`,
+		labelWidth, labelWidth, messageWidth, color, width)
+	sb.WriteString(`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`)
+	for _, t := range []struct {
+		x    float64
+		text string
+	}{{float64(labelWidth) / 2, label}, {float64(labelWidth) + float64(messageWidth)/2, message}} {
+		fmt.Fprintf(&sb, `<text x="%.1f" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%.1f" y="14">%s</text>`, t.x, t.text, t.x, t.text)
+	}
+	sb.WriteString("</g></svg>\n")
+	return []byte(sb.String())
+}
+
+// textWidth estimates the width in pixels of text in 11px Verdana, which is enough to size badges without the font's metrics
+func textWidth(text string) int {
+	width := 0.0
+	for _, r := range text {
+		switch {
+		case strings.ContainsRune(" .,:;'!|()[]ijlft", r):
+			width += 4
+		case strings.ContainsRune("mwMW%", r):
+			width += 10.5
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '/':
+			width += 7.5
+		default:
+			width += 6.5
+		}
+	}
+	return int(width + 0.5)
+}
@@ -0,0 +1,86 @@
+package badge
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		metric   Metric
+		status   Status
+		expected Badge
+	}{
+		{name: "passing score", metric: MetricScore, status: Status{Score: 92, Grade: "A"}, expected: Badge{Label: "dockershrink", Message: "92/100 (A)", Color: "brightgreen"}},
+		{name: "mediocre score", metric: MetricScore, status: Status{Score: 64, Grade: "D"}, expected: Badge{Label: "dockershrink", Message: "64/100 (D)", Color: "yellow"}},
+		{name: "failing score", metric: MetricScore, status: Status{Score: 49, Grade: "F"}, expected: Badge{Label: "dockershrink", Message: "49/100 (F)", Color: "red"}},
+		{name: "score without grade", metric: MetricScore, status: Status{Score: 80}, expected: Badge{Label: "dockershrink", Message: "80/100", Color: "brightgreen"}},
+		{name: "size", metric: MetricSize, status: Status{EstimatedImageSize: 150 << 20}, expected: Badge{Label: "image size", Message: "150.0 MB", Color: "blue"}},
+		{name: "unknown size", metric: MetricSize, status: Status{Score: 100}, expected: Badge{Label: "image size", Message: "unknown", Color: "lightgrey"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.metric, &tt.status); *got != tt.expected {
+				t.Errorf("New() = %+v; want %+v", *got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	content, contentType := Score(92, "A").Render(FormatShields)
+	if contentType != "application/json" {
+		t.Errorf("unexpected content type %s", contentType)
+	}
+	var e map[string]any
+	if err := json.Unmarshal(content, &e); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{"schemaVersion": 1.0, "label": "dockershrink", "message": "92/100 (A)", "color": "brightgreen"}
+	for k, v := range expected {
+		if e[k] != v {
+			t.Errorf("%s = %v; want %v", k, e[k], v)
+		}
+	}
+}
+
+func TestSVG(t *testing.T) {
+	content, contentType := (&Badge{Label: "a<b", Message: "92/100 (A)", Color: "brightgreen"}).Render(FormatSVG)
+	if contentType != "image/svg+xml" {
+		t.Errorf("unexpected content type %s", contentType)
+	}
+	if err := xml.Unmarshal(content, new(struct{})); err != nil {
+		t.Fatalf("invalid SVG: %v\n%s", err, content)
+	}
+	svg := string(content)
+	for _, want := range []string{`<title>a&lt;b: 92/100 (A)</title>`, `fill="#4c1"`, `<text x="15.0" y="14">a&lt;b</text>`} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("expected the SVG to contain %q, got:\n%s", want, svg)
+		}
+	}
+
+	if textWidth("1.0 GB") <= textWidth("A") {
+		t.Errorf("expected longer messages to make wider badges")
+	}
+	if unknown := (&Badge{Label: "x", Message: "y", Color: "pink"}).SVG(); !strings.Contains(string(unknown), `fill="#9f9f9f"`) {
+		t.Errorf("expected unknown colors to be grey, got:\n%s", unknown)
+	}
+}
+
+func TestParse(t *testing.T) {
+	if m, err := ParseMetric(" Size "); err != nil || m != MetricSize {
+		t.Errorf("ParseMetric() = %s, %v", m, err)
+	}
+	if _, err := ParseMetric("findings"); err == nil {
+		t.Error("expected an error for an unknown metric")
+	}
+	if f, err := ParseFormat("SHIELDS"); err != nil || f != FormatShields {
+		t.Errorf("ParseFormat() = %s, %v", f, err)
+	}
+	if _, err := ParseFormat("png"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,549 @@
+// Package baseimages maintains a matrix of official Docker images along with their
+// release cycles, variants, sizes and known vulnerabilities. It is used to recommend
+// base images that are smaller and still supported upstream.
+package baseimages
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/platform"
+	"github.com/duaraghav8/dockershrink/internal/units"
+)
+
+// now is replaced in tests
+var now = time.Now
+
+// Matrix holds the release cycles and tag details of official images
+type Matrix struct {
+	// FetchedAt is zero if the matrix only contains built-in data
+	FetchedAt time.Time         `json:"fetched_at"`
+	Images    map[string]*Image `json:"images"`
+}
+
+// Image describes an official image
+type Image struct {
+	Name string `json:"name"`
+	// Product is the name of the image's software on endoflife.date
+	Product string `json:"product,omitempty"`
+	// Variants are the tag suffixes of the image's recommended variants, smallest first.
+	// The empty string is the default variant based on a full distribution.
+	Variants []string `json:"variants,omitempty"`
+	// Aliases map codenames used in tags to release cycles, eg- "iron" -> "20"
+	Aliases map[string]string `json:"aliases,omitempty"`
+	// Replacement is the image to use instead if this image is deprecated
+	Replacement string `json:"replacement,omitempty"`
+	// Cycles are the release cycles of the image's software, newest first
+	Cycles []*Cycle `json:"cycles,omitempty"`
+	// Tags maps tags of the form "<cycle>" and "<cycle>-<variant>" to their details
+	Tags      map[string]*Tag `json:"tags,omitempty"`
+	FetchedAt time.Time       `json:"fetched_at"`
+}
+
+// Cycle is a release cycle (usually a major version) of an image's software
+type Cycle struct {
+	Version string `json:"version"`
+	// EOL is the end of life date, zero if it hasn't been announced
+	EOL time.Time `json:"eol"`
+	// Ended is true if the cycle reached its end of life on an unknown date
+	Ended bool `json:"ended,omitempty"`
+	LTS   bool `json:"lts,omitempty"`
+}
+
+// Tag contains the details of a single image tag
+type Tag struct {
+	// Size is the compressed linux/amd64 size reported by the registry
+	Size int64 `json:"size"`
+	// CVEs is nil if the tag hasn't been scanned
+	CVEs *CVECount `json:"cves,omitempty"`
+	// Platforms the tag is published for, eg- "linux/arm64". Empty if they're unknown.
+	Platforms []string `json:"platforms,omitempty"`
+}
+
+// missing returns the given platforms the tag isn't published for.
+// nil is returned if the tag's platforms are unknown.
+func (t *Tag) missing(platforms []platform.Platform) []platform.Platform {
+	if t == nil || len(t.Platforms) == 0 {
+		return nil
+	}
+	published := make([]platform.Platform, 0, len(t.Platforms))
+	for _, s := range t.Platforms {
+		if p, err := platform.Parse(s); err == nil {
+			published = append(published, p)
+		}
+	}
+	var missing []platform.Platform
+	for _, p := range platforms {
+		if !platform.Contains(published, p) {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// CVECount is the number of known vulnerabilities in an image by severity
+type CVECount struct {
+	Critical  int       `json:"critical"`
+	High      int       `json:"high"`
+	Medium    int       `json:"medium"`
+	Low       int       `json:"low"`
+	ScannedAt time.Time `json:"scanned_at"`
+}
+
+func (c *CVECount) Total() int {
+	return c.Critical + c.High + c.Medium + c.Low
+}
+
+func (c *CVECount) String() string {
+	return fmt.Sprintf("%d critical, %d high, %d medium, %d low", c.Critical, c.High, c.Medium, c.Low)
+}
+
+// Supported returns true if the cycle has not reached its end of life at the given time
+func (c *Cycle) Supported(at time.Time) bool {
+	return !c.Ended && (c.EOL.IsZero() || at.Before(c.EOL))
+}
+
+// Recommendation is a smaller or supported alternative to a base image
+type Recommendation struct {
+	Current   string
+	Suggested string
+	// SameRelease is the smallest variant of the current image's release cycle,
+	// empty if the image is deprecated. It differs from Suggested if the release cycle reached its end of life.
+	SameRelease string
+	// CurrentSize and SuggestedSize are compressed sizes, 0 if unknown
+	CurrentSize   int64
+	SuggestedSize int64
+	// CurrentCVEs and SuggestedCVEs are nil if the tags haven't been scanned
+	CurrentCVEs   *CVECount
+	SuggestedCVEs *CVECount
+	// EOL is set if the current image's release cycle has reached its end of life
+	EOL time.Time
+	// Unsupported is true if the current image's release cycle has reached its end of life
+	Unsupported bool
+	// Deprecated is true if the current image is deprecated in favour of another image
+	Deprecated bool
+}
+
+// Savings returns the compressed bytes saved by switching to the suggested image.
+// 0 is returned if the sizes are unknown.
+func (r *Recommendation) Savings() int64 {
+	if r.CurrentSize == 0 || r.SuggestedSize == 0 || r.SuggestedSize > r.CurrentSize {
+		return 0
+	}
+	return r.CurrentSize - r.SuggestedSize
+}
+
+// Details returns a human-readable explanation of the recommendation
+func (r *Recommendation) Details() string {
+	var details []string
+	switch {
+	case r.Deprecated:
+		details = append(details, fmt.Sprintf("'%s' is deprecated", r.Current))
+	case r.Unsupported && !r.EOL.IsZero():
+		details = append(details, fmt.Sprintf("'%s' reached its end of life on %s and no longer receives security fixes", r.Current, r.EOL.Format(time.DateOnly)))
+	case r.Unsupported:
+		details = append(details, fmt.Sprintf("'%s' reached its end of life and no longer receives security fixes", r.Current))
+	}
+	if r.Savings() > 0 {
+		details = append(details, fmt.Sprintf("'%s' is a %s compressed download compared to %s", r.Suggested, units.FormatBytes(r.SuggestedSize), units.FormatBytes(r.CurrentSize)))
+	}
+	if r.CurrentCVEs != nil && r.SuggestedCVEs != nil && r.SuggestedCVEs.Total() < r.CurrentCVEs.Total() {
+		details = append(details, fmt.Sprintf("it has %d known vulnerabilities compared to %d", r.SuggestedCVEs.Total(), r.CurrentCVEs.Total()))
+	}
+	if len(details) == 0 {
+		return ""
+	}
+	return capitalize(strings.Join(details, " and ")) + "."
+}
+
+// Image returns the details of the given official image, nil if it's unknown.
+// Registry and "library/" prefixes are ignored, eg- "docker.io/library/node" is "node".
+func (m *Matrix) Image(name string) *Image {
+	name = strings.TrimPrefix(name, "docker.io/")
+	name = strings.TrimPrefix(name, "library/")
+	return m.Images[name]
+}
+
+// Size returns the compressed size of the given image, 0 if it's unknown
+func (m *Matrix) Size(image *dockerfile.Image) int64 {
+	if m == nil {
+		return 0
+	}
+	img := m.Image(image.Name())
+	if img == nil {
+		return 0
+	}
+	cycle, variant := img.parseTag(image.Tag())
+	if t := img.tag(cycle, variant); t != nil {
+		return t.Size
+	}
+	return 0
+}
+
+// Recommend returns the smallest variant of the given image that is still supported
+// and published for all the given platforms.
+// If the image's release cycle reached its end of life, the newest supported cycle
+// (preferring LTS releases) is suggested instead, which can be a major upgrade.
+// nil is returned if the image is unknown or is already the best choice.
+func (m *Matrix) Recommend(image *dockerfile.Image, platforms []platform.Platform) *Recommendation {
+	return m.recommend(image, false, platforms)
+}
+
+// Upgrade returns the supported release cycle of the given image in the same variant.
+// nil is returned if the image is unknown or its release cycle is still supported.
+func (m *Matrix) Upgrade(image *dockerfile.Image) *Recommendation {
+	rec := m.recommend(image, true, nil)
+	if rec == nil || (!rec.Unsupported && !rec.Deprecated) {
+		return nil
+	}
+	return rec
+}
+
+func (m *Matrix) recommend(image *dockerfile.Image, keepVariant bool, platforms []platform.Platform) *Recommendation {
+	if m == nil {
+		return nil
+	}
+	img := m.Image(image.Name())
+	if img == nil {
+		return nil
+	}
+	rec := &Recommendation{Current: image.FullName()}
+
+	cycle, variant := img.parseTag(image.Tag())
+	if current := img.tag(cycle, variant); current != nil {
+		rec.CurrentSize, rec.CurrentCVEs = current.Size, current.CVEs
+	}
+	currentVariant := variant
+	if cycle != nil && img.Replacement == "" {
+		rec.SameRelease = img.Name + dockerfile.NameTagSep + tagName(cycle.Version, img.smallestVariant(cycle, variant, platforms))
+	}
+
+	if img.Replacement != "" {
+		replacement := m.Image(img.Replacement)
+		if replacement == nil {
+			return nil
+		}
+		rec.Deprecated = true
+		img = replacement
+		cycle, variant = img.parseTag(image.Tag())
+		if !img.hasVariant(variant) {
+			variant = ""
+		}
+	}
+
+	if cycle == nil {
+		// unknown version, nothing can be said about its support
+		return nil
+	}
+	target := cycle
+	if !cycle.Supported(now()) {
+		rec.Unsupported, rec.EOL = true, cycle.EOL
+		target = img.latestSupported()
+	}
+	if target == nil {
+		return nil
+	}
+	if !keepVariant {
+		variant = img.smallestVariant(target, variant, platforms)
+	}
+
+	rec.Suggested = img.Name + dockerfile.NameTagSep + tagName(target.Version, variant)
+	if suggested := img.tag(target, variant); suggested != nil {
+		rec.SuggestedSize, rec.SuggestedCVEs = suggested.Size, suggested.CVEs
+	}
+
+	if !rec.Unsupported && !rec.Deprecated {
+		if target == cycle && variant == currentVariant {
+			return nil
+		}
+		if rec.CurrentSize > 0 && rec.SuggestedSize >= rec.CurrentSize {
+			return nil
+		}
+	}
+	return rec
+}
+
+// Substitute returns a copy of the recommendation that suggests the given image instead, eg- another variant of
+// the suggested release, along with its size and vulnerabilities if they're known
+func (m *Matrix) Substitute(rec *Recommendation, image *dockerfile.Image) *Recommendation {
+	sub := *rec
+	sub.Suggested = image.FullName()
+	sub.SuggestedSize, sub.SuggestedCVEs = 0, nil
+	if m == nil {
+		return &sub
+	}
+	if img := m.Image(image.Name()); img != nil {
+		cycle, variant := img.parseTag(image.Tag())
+		if t := img.tag(cycle, variant); t != nil {
+			sub.SuggestedSize, sub.SuggestedCVEs = t.Size, t.CVEs
+		}
+	}
+	return &sub
+}
+
+// PlatformCheck lists the target platforms a base image isn't published for
+type PlatformCheck struct {
+	Image   string
+	Missing []platform.Platform
+	// Alternative is the smallest variant of the same release that is published for
+	// all the target platforms, empty if there is none
+	Alternative string
+}
+
+// CheckPlatforms returns the platforms the given image isn't published for.
+// nil is returned if the image is published for all of them or its platforms are unknown.
+func (m *Matrix) CheckPlatforms(image *dockerfile.Image, platforms []platform.Platform) *PlatformCheck {
+	if m == nil || len(platforms) == 0 {
+		return nil
+	}
+	img := m.Image(image.Name())
+	if img == nil {
+		return nil
+	}
+	cycle, variant := img.parseTag(image.Tag())
+	missing := img.tag(cycle, variant).missing(platforms)
+	if len(missing) == 0 {
+		return nil
+	}
+
+	check := &PlatformCheck{Image: image.FullName(), Missing: missing}
+	for _, v := range img.Variants {
+		if t := img.tag(cycle, v); t != nil && len(t.Platforms) > 0 && len(t.missing(platforms)) == 0 {
+			check.Alternative = img.Name + dockerfile.NameTagSep + tagName(cycle.Version, v)
+			break
+		}
+	}
+	return check
+}
+
+// Summary describes the supported releases and smaller variants of the given images.
+// Only variants published for all the given platforms are suggested.
+// It is meant to be included in LLM prompts. An empty string is returned if none of the images are known.
+func (m *Matrix) Summary(images []*dockerfile.Image, platforms []platform.Platform) string {
+	if m == nil {
+		return ""
+	}
+	var sb strings.Builder
+	seen := map[string]bool{}
+	for _, image := range images {
+		img := m.Image(image.Name())
+		if img == nil || seen[image.FullName()] {
+			continue
+		}
+		seen[image.FullName()] = true
+
+		sb.WriteString(fmt.Sprintf("- %s: ", image.FullName()))
+		if check := m.CheckPlatforms(image, platforms); check != nil {
+			sb.WriteString(fmt.Sprintf("not published for %s", platform.Join(check.Missing)))
+			if check.Alternative != "" {
+				sb.WriteString(fmt.Sprintf(", use %s instead", check.Alternative))
+			}
+			sb.WriteString(".")
+		} else if rec := m.Recommend(image, platforms); rec != nil {
+			sb.WriteString(fmt.Sprintf("use %s instead. %s", rec.Suggested, rec.Details()))
+		} else {
+			sb.WriteString("already a small and supported tag.")
+		}
+		if img.Replacement != "" {
+			img = m.Image(img.Replacement)
+		}
+		if supported := img.supportedVersions(); len(supported) > 0 {
+			sb.WriteString(fmt.Sprintf(" Supported %s releases: %s.", img.Name, strings.Join(supported, ", ")))
+		}
+		sb.WriteString("\n")
+	}
+	if sb.Len() > 0 && len(platforms) > 0 {
+		return fmt.Sprintf("The image is built for %s. Only use base images published for all of these platforms.\n", platform.Join(platforms)) + sb.String()
+	}
+	return sb.String()
+}
+
+// Names returns the names of the images in the matrix in alphabetical order
+func (m *Matrix) Names() []string {
+	names := make([]string, 0, len(m.Images))
+	for name := range m.Images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// tagName returns the tag of a variant of a release cycle, eg- ("20", "alpine") -> "20-alpine"
+func tagName(version, variant string) string {
+	if variant == "" {
+		return version
+	}
+	return version + "-" + variant
+}
+
+func (i *Image) tag(cycle *Cycle, variant string) *Tag {
+	if cycle == nil {
+		return nil
+	}
+	return i.Tags[tagName(cycle.Version, variant)]
+}
+
+// SetCVEs records the vulnerabilities found in a tag of the image
+func (i *Image) SetCVEs(tag string, cves *CVECount) {
+	if i.Tags == nil {
+		i.Tags = map[string]*Tag{}
+	}
+	if i.Tags[tag] == nil {
+		i.Tags[tag] = &Tag{}
+	}
+	i.Tags[tag].CVEs = cves
+}
+
+// cycle returns the release cycle matching the version.
+// The version can be a full version ("20.11.1"), a cycle ("3.12") or the prefix of cycles ("3").
+func (i *Image) cycle(version string) *Cycle {
+	if version == "" {
+		return nil
+	}
+	if c, ok := i.Aliases[version]; ok {
+		version = c
+	}
+	var match *Cycle
+	for _, c := range i.Cycles {
+		if version == c.Version || strings.HasPrefix(version, c.Version+".") || strings.HasPrefix(version, c.Version+"_") {
+			if match == nil || len(c.Version) > len(match.Version) {
+				match = c
+			}
+		}
+	}
+	if match != nil {
+		return match
+	}
+	// a prefix like "3" refers to the newest "3.x" release
+	for _, c := range i.Cycles {
+		if strings.HasPrefix(c.Version, version+".") {
+			return c
+		}
+	}
+	return nil
+}
+
+// latestSupported returns the newest supported LTS release cycle,
+// or the newest supported cycle if the image has no LTS releases.
+func (i *Image) latestSupported() *Cycle {
+	var newest *Cycle
+	for _, c := range i.Cycles {
+		if !c.Supported(now()) {
+			continue
+		}
+		if c.LTS {
+			return c
+		}
+		if newest == nil {
+			newest = c
+		}
+	}
+	return newest
+}
+
+func (i *Image) supportedVersions() []string {
+	var versions []string
+	for _, c := range i.Cycles {
+		if !c.Supported(now()) {
+			continue
+		}
+		v := c.Version
+		if c.LTS {
+			v += " (LTS)"
+		}
+		if !c.EOL.IsZero() {
+			v += " until " + c.EOL.Format(time.DateOnly)
+		}
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+func (i *Image) hasVariant(variant string) bool {
+	for _, v := range i.Variants {
+		if v == variant {
+			return true
+		}
+	}
+	return false
+}
+
+// smallestVariant returns the smallest recommended variant of the cycle that is published for all
+// the given platforms, if the given variant is a larger one. Variants whose platforms are unknown are
+// assumed to be published for all of them. Unrecommended variants are returned as-is since they can't be compared.
+func (i *Image) smallestVariant(cycle *Cycle, variant string, platforms []platform.Platform) string {
+	if len(i.Variants) == 0 || !i.hasVariant(variant) {
+		return variant
+	}
+	for _, v := range i.Variants {
+		if v == variant {
+			break
+		}
+		if len(i.tag(cycle, v).missing(platforms)) == 0 {
+			return v
+		}
+	}
+	return variant
+}
+
+var (
+	// tag components that name the distribution a variant is based on
+	distroRegex  = regexp.MustCompile(`^(bookworm|bullseye|buster|stretch|trixie|jammy|noble|focal|jdk|windowsservercore.*|nanoserver.*)$`)
+	alpineRegex  = regexp.MustCompile(`^alpine[0-9.]*$`)
+	versionRegex = regexp.MustCompile(`^[0-9]`)
+	// tags that refer to a moving release cycle
+	floatingTags = map[string]bool{"latest": true, "current": true, "stable": true, "lts": true}
+)
+
+// parseTag splits a tag into its release cycle and canonical variant, eg- "20.11-bookworm-slim" -> ("20", "slim").
+// The cycle is nil if the tag's version is unknown.
+func (i *Image) parseTag(tag string) (*Cycle, string) {
+	parts := strings.Split(tag, "-")
+	version := ""
+	if _, alias := i.Aliases[parts[0]]; alias || versionRegex.MatchString(parts[0]) || floatingTags[parts[0]] {
+		version, parts = parts[0], parts[1:]
+	}
+
+	var cycle *Cycle
+	switch version {
+	case "", "latest", "current", "stable":
+		if len(i.Cycles) > 0 {
+			cycle = i.Cycles[0]
+		}
+	case "lts":
+		for _, c := range i.Cycles {
+			if c.LTS {
+				cycle = c
+				break
+			}
+		}
+	default:
+		cycle = i.cycle(version)
+	}
+	return cycle, joinVariant(parts)
+}
+
+func joinVariant(parts []string) string {
+	var variant []string
+	for _, p := range parts {
+		switch {
+		case alpineRegex.MatchString(p):
+			variant = append(variant, "alpine")
+		case distroRegex.MatchString(p):
+		default:
+			variant = append(variant, p)
+		}
+	}
+	return strings.Join(variant, "-")
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,227 @@
+package baseimages
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/platform"
+)
+
+func fixedNow(t *testing.T, at time.Time) {
+	t.Helper()
+	now = func() time.Time { return at }
+	t.Cleanup(func() { now = time.Now })
+}
+
+func TestRecommend(t *testing.T) {
+	fixedNow(t, date(2026, 1, 15))
+	m := Builtin()
+
+	tests := []struct {
+		image       string
+		suggested   string
+		sameRelease string
+		unsupported bool
+		deprecated  bool
+	}{
+		{"node:22", "node:22-alpine", "node:22-alpine", false, false},
+		{"node:22.11.0-bookworm", "node:22-alpine", "node:22-alpine", false, false},
+		{"node:iron-slim", "node:20-alpine", "node:20-alpine", false, false},
+		{"node:16", "node:24-alpine", "node:16-alpine", true, false},
+		{"node:lts", "node:24-alpine", "node:24-alpine", false, false},
+		{"python:3.12-bookworm", "python:3.12-slim", "python:3.12-slim", false, false},
+		{"python:3", "python:3.14-slim", "python:3.14-slim", false, false},
+		{"python:3.8-slim", "python:3.14-slim", "python:3.8-slim", true, false},
+		{"golang:1.23", "golang:1.26-alpine", "golang:1.23-alpine", true, false},
+		{"eclipse-temurin:21-jdk-jammy", "eclipse-temurin:21-jre-alpine", "eclipse-temurin:21-jre-alpine", false, false},
+		{"openjdk:17-jdk-slim", "eclipse-temurin:17-jre-alpine", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			rec := m.Recommend(dockerfile.NewImage(tt.image), nil)
+			if rec == nil {
+				t.Fatal("expected a recommendation")
+			}
+			if rec.Suggested != tt.suggested {
+				t.Errorf("expected %s to be suggested, got %s", tt.suggested, rec.Suggested)
+			}
+			if rec.SameRelease != tt.sameRelease {
+				t.Errorf("expected %q for the same release, got %q", tt.sameRelease, rec.SameRelease)
+			}
+			if rec.Unsupported != tt.unsupported || rec.Deprecated != tt.deprecated {
+				t.Errorf("expected unsupported=%v deprecated=%v, got %v and %v", tt.unsupported, tt.deprecated, rec.Unsupported, rec.Deprecated)
+			}
+		})
+	}
+
+	for _, image := range []string{"node:22-alpine", "python:3.13-slim", "python:3.13-alpine", "node:7", "nginx:latest", "golang:1.26-alpine"} {
+		if rec := m.Recommend(dockerfile.NewImage(image), nil); rec != nil {
+			t.Errorf("expected no recommendation for %s, got %s", image, rec.Suggested)
+		}
+	}
+}
+
+func TestRecommend_Platforms(t *testing.T) {
+	fixedNow(t, date(2026, 1, 15))
+	m := Builtin()
+
+	tests := []struct {
+		image     string
+		platforms string
+		suggested string
+	}{
+		{"eclipse-temurin:21-jdk", "linux/amd64,linux/arm64", "eclipse-temurin:21-jre-alpine"},
+		// the alpine variants are only published for amd64 and arm64
+		{"eclipse-temurin:21-jdk", "linux/amd64,linux/ppc64le", "eclipse-temurin:21-jre"},
+		{"node:22", "linux/s390x", "node:22-alpine"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image+" "+tt.platforms, func(t *testing.T) {
+			platforms, err := platform.ParseList(tt.platforms)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec := m.Recommend(dockerfile.NewImage(tt.image), platforms)
+			if rec == nil {
+				t.Fatal("expected a recommendation")
+			}
+			if rec.Suggested != tt.suggested {
+				t.Errorf("expected %s to be suggested, got %s", tt.suggested, rec.Suggested)
+			}
+		})
+	}
+}
+
+func TestCheckPlatforms(t *testing.T) {
+	m := Builtin()
+	platforms, err := platform.ParseList("linux/amd64,linux/arm/v7,linux/s390x")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := m.CheckPlatforms(dockerfile.NewImage("eclipse-temurin:17-jre-alpine"), platforms)
+	if check == nil {
+		t.Fatal("expected missing platforms")
+	}
+	if platform.Join(check.Missing) != "linux/arm/v7, linux/s390x" {
+		t.Errorf("unexpected missing platforms: %s", platform.Join(check.Missing))
+	}
+	if check.Alternative != "eclipse-temurin:17-jre" {
+		t.Errorf("expected eclipse-temurin:17-jre as the alternative, got %q", check.Alternative)
+	}
+
+	for _, image := range []string{"eclipse-temurin:17-jre", "node:20-alpine", "nginx:latest", "node:7"} {
+		if check := m.CheckPlatforms(dockerfile.NewImage(image), platforms); check != nil {
+			t.Errorf("expected %s to pass, got missing %s", image, platform.Join(check.Missing))
+		}
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	fixedNow(t, date(2026, 1, 15))
+	m := Builtin()
+
+	rec := m.Upgrade(dockerfile.NewImage("node:18-alpine3.19"))
+	if rec == nil {
+		t.Fatal("expected an upgrade for an unsupported release")
+	}
+	if rec.Suggested != "node:24-alpine" {
+		t.Errorf("expected node:24-alpine, got %s", rec.Suggested)
+	}
+	if !strings.Contains(rec.Details(), "reached its end of life on 2025-04-30") {
+		t.Errorf("expected the end of life date in the details, got %q", rec.Details())
+	}
+
+	if rec := m.Upgrade(dockerfile.NewImage("node:22")); rec != nil {
+		t.Errorf("expected no upgrade for a supported release, got %s", rec.Suggested)
+	}
+}
+
+func TestFetchAndLoad(t *testing.T) {
+	fixedNow(t, date(2026, 1, 15))
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch {
+		case r.URL.Path == "/api/go.json":
+			fmt.Fprint(w, `[{"cycle":"1.25","eol":false,"lts":false},{"cycle":"1.24","eol":"2026-02-11","lts":false},{"cycle":"1.20","eol":true,"lts":false}]`)
+		case r.URL.Path == "/v2/repositories/library/golang/tags/1.25-alpine":
+			fmt.Fprint(w, `{"full_size":1,"images":[{"architecture":"arm64","os":"linux","variant":"v8","size":2},{"architecture":"amd64","os":"linux","size":70000000}]}`)
+		case strings.HasPrefix(r.URL.Path, "/v2/repositories/library/golang/tags/"):
+			fmt.Fprint(w, `{"full_size":290000000}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	fetcher := &Fetcher{Client: server.Client(), HubURL: server.URL, EOLURL: server.URL}
+	opts := &LoadOptions{Cache: NewCache(t.TempDir()), Fetcher: fetcher}
+	m, err := Load(context.Background(), []string{"golang", "build"}, opts)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+
+	golang := m.Image("golang")
+	if len(golang.Cycles) != 3 || !golang.Cycles[2].Ended || golang.Cycles[1].EOL != date(2026, 2, 11) {
+		t.Errorf("unexpected release cycles: %+v", golang.Cycles)
+	}
+	if size := golang.Tags["1.25-alpine"].Size; size != 70000000 {
+		t.Errorf("expected the amd64 size of 1.25-alpine, got %d", size)
+	}
+	if platforms := strings.Join(golang.Tags["1.25-alpine"].Platforms, ","); platforms != "linux/arm64,linux/amd64" {
+		t.Errorf("expected the platforms of 1.25-alpine, got %s", platforms)
+	}
+	if _, ok := golang.Tags["1.20"]; ok {
+		t.Error("expected sizes of unsupported releases to not be fetched")
+	}
+	if m.Image("node") == nil {
+		t.Error("expected built-in images to be included")
+	}
+
+	// fresh data is served from the cache
+	fetched := requests
+	opts.Fetcher.EOLURL = "http://127.0.0.1:0"
+	m, err = Load(context.Background(), []string{"golang"}, opts)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if requests != fetched {
+		t.Errorf("expected cached data to be used, got %d new requests", requests-fetched)
+	}
+	if m.Image("golang").Tags["1.25-alpine"].Size != 70000000 {
+		t.Error("expected cached sizes to be loaded")
+	}
+
+	// stale data is fetched again, and the cache is used if that fails
+	fixedNow(t, date(2026, 1, 17))
+	m, err = Load(context.Background(), []string{"golang"}, opts)
+	if err == nil {
+		t.Error("expected an error when fetching fails")
+	}
+	if m.Image("golang").Tags["1.25-alpine"].Size != 70000000 {
+		t.Error("expected cached data when fetching fails")
+	}
+}
+
+func TestParseSarif(t *testing.T) {
+	report := `{"runs":[{"tool":{"driver":{"rules":[
+		{"id":"CVE-1","properties":{"cvssV3_severity":"CRITICAL"}},
+		{"id":"CVE-2","properties":{"cvssV3_severity":"HIGH"}},
+		{"id":"CVE-3","properties":{"cvssV3_severity":"high"}},
+		{"id":"CVE-4","properties":{"cvssV3_severity":"LOW"}}
+	]}}}]}`
+	count, err := parseSarif(report)
+	if err != nil {
+		t.Fatalf("parseSarif() returned error: %v", err)
+	}
+	if count.Critical != 1 || count.High != 2 || count.Medium != 0 || count.Low != 1 {
+		t.Errorf("unexpected count: %s", count)
+	}
+}
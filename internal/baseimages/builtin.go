@@ -0,0 +1,134 @@
+package baseimages
+
+import "time"
+
+const mb = int64(1024 * 1024)
+
+// platforms the variants of official images are published for
+var (
+	debianPlatforms        = []string{"linux/386", "linux/amd64", "linux/arm/v5", "linux/arm/v7", "linux/arm64", "linux/ppc64le", "linux/s390x"}
+	alpinePlatforms        = []string{"linux/386", "linux/amd64", "linux/arm/v6", "linux/arm/v7", "linux/arm64", "linux/ppc64le", "linux/riscv64", "linux/s390x"}
+	nodeDebianPlatforms    = []string{"linux/amd64", "linux/arm/v7", "linux/arm64", "linux/ppc64le", "linux/s390x"}
+	nodeAlpinePlatforms    = []string{"linux/amd64", "linux/arm/v6", "linux/arm/v7", "linux/arm64", "linux/ppc64le", "linux/s390x"}
+	temurinPlatforms       = []string{"linux/amd64", "linux/arm/v7", "linux/arm64", "linux/ppc64le", "linux/s390x"}
+	temurinAlpinePlatforms = []string{"linux/amd64", "linux/arm64"}
+)
+
+// Builtin returns the matrix shipped with dockershrink.
+// It is used when registries and endoflife.date can't be reached, so its release
+// cycles and sizes are approximate and can be outdated.
+func Builtin() *Matrix {
+	images := []*Image{
+		{
+			Name:     "node",
+			Product:  "nodejs",
+			Variants: []string{"alpine", "slim", ""},
+			Aliases:  map[string]string{"krypton": "24", "jod": "22", "iron": "20", "hydrogen": "18", "gallium": "16", "fermium": "14"},
+			Cycles: []*Cycle{
+				{Version: "26", EOL: date(2029, 4, 30)},
+				{Version: "25", EOL: date(2026, 6, 1)},
+				{Version: "24", EOL: date(2028, 4, 30), LTS: true},
+				{Version: "23", EOL: date(2025, 6, 1)},
+				{Version: "22", EOL: date(2027, 4, 30), LTS: true},
+				{Version: "21", EOL: date(2024, 6, 1)},
+				{Version: "20", EOL: date(2026, 4, 30), LTS: true},
+				{Version: "18", EOL: date(2025, 4, 30), LTS: true},
+				{Version: "16", EOL: date(2023, 9, 11), LTS: true},
+				{Version: "14", EOL: date(2023, 4, 30), LTS: true},
+				{Version: "12", EOL: date(2022, 4, 30), LTS: true},
+				{Version: "10", EOL: date(2021, 4, 30), LTS: true},
+			},
+			Tags: variantTags([]string{"26", "24", "22", "20", "18"}, map[string]variant{
+				"":       {390 * mb, nodeDebianPlatforms},
+				"slim":   {75 * mb, nodeDebianPlatforms},
+				"alpine": {55 * mb, nodeAlpinePlatforms},
+			}),
+		},
+		{
+			Name:    "python",
+			Product: "python",
+			// alpine is left out since most wheels aren't built for musl and have to be compiled
+			Variants: []string{"slim", ""},
+			Cycles: []*Cycle{
+				{Version: "3.14", EOL: date(2030, 10, 31)},
+				{Version: "3.13", EOL: date(2029, 10, 31)},
+				{Version: "3.12", EOL: date(2028, 10, 31)},
+				{Version: "3.11", EOL: date(2027, 10, 31)},
+				{Version: "3.10", EOL: date(2026, 10, 31)},
+				{Version: "3.9", EOL: date(2025, 10, 31)},
+				{Version: "3.8", EOL: date(2024, 10, 7)},
+				{Version: "3.7", EOL: date(2023, 6, 27)},
+			},
+			Tags: variantTags([]string{"3.14", "3.13", "3.12", "3.11", "3.10"}, map[string]variant{
+				"":       {390 * mb, debianPlatforms},
+				"slim":   {45 * mb, debianPlatforms},
+				"alpine": {20 * mb, alpinePlatforms},
+			}),
+		},
+		{
+			Name:     "golang",
+			Product:  "go",
+			Variants: []string{"alpine", ""},
+			Cycles: []*Cycle{
+				{Version: "1.26"},
+				{Version: "1.25"},
+				{Version: "1.24", EOL: date(2026, 2, 10)},
+				{Version: "1.23", EOL: date(2025, 8, 12)},
+				{Version: "1.22", EOL: date(2025, 2, 11)},
+			},
+			Tags: variantTags([]string{"1.26", "1.25"}, map[string]variant{
+				"":       {300 * mb, debianPlatforms},
+				"alpine": {80 * mb, alpinePlatforms},
+			}),
+		},
+		{
+			Name:    "eclipse-temurin",
+			Product: "eclipse-temurin",
+			// the final image of a java application only needs the runtime, not the JDK
+			Variants: []string{"jre-alpine", "jre", "alpine", ""},
+			Cycles: []*Cycle{
+				{Version: "25", EOL: date(2031, 9, 30), LTS: true},
+				{Version: "21", EOL: date(2029, 12, 31), LTS: true},
+				{Version: "17", EOL: date(2027, 10, 31), LTS: true},
+				{Version: "11", EOL: date(2027, 10, 31), LTS: true},
+				{Version: "8", EOL: date(2030, 12, 31), LTS: true},
+			},
+			Tags: variantTags([]string{"25", "21", "17", "11", "8"}, map[string]variant{
+				"":           {190 * mb, temurinPlatforms},
+				"alpine":     {165 * mb, temurinAlpinePlatforms},
+				"jre":        {90 * mb, temurinPlatforms},
+				"jre-alpine": {65 * mb, temurinAlpinePlatforms},
+			}),
+		},
+		{
+			Name:        "openjdk",
+			Replacement: "eclipse-temurin",
+		},
+	}
+
+	m := &Matrix{Images: map[string]*Image{}}
+	for _, img := range images {
+		m.Images[img.Name] = img
+	}
+	return m
+}
+
+type variant struct {
+	size      int64
+	platforms []string
+}
+
+// variantTags returns the tags of all variants of the given release cycles
+func variantTags(cycles []string, variants map[string]variant) map[string]*Tag {
+	tags := map[string]*Tag{}
+	for _, c := range cycles {
+		for name, v := range variants {
+			tags[tagName(c, name)] = &Tag{Size: v.size, Platforms: v.platforms}
+		}
+	}
+	return tags
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
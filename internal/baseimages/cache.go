@@ -0,0 +1,177 @@
+package baseimages
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CacheTTL is how long fetched data is used before it's fetched again
+const CacheTTL = 24 * time.Hour
+
+// Cache persists fetched data in the user's cache directory, so that registries
+// and endoflife.date are queried at most once a day.
+type Cache struct {
+	path string
+}
+
+// DefaultCache returns the cache inside the user's cache directory, eg- ~/.cache/dockershrink on linux
+func DefaultCache() (*Cache, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return NewCache(filepath.Join(dir, "dockershrink")), nil
+}
+
+func NewCache(dir string) *Cache {
+	return &Cache{path: filepath.Join(dir, "baseimages.json")}
+}
+
+// Read returns the cached matrix. An empty matrix is returned if nothing is cached yet.
+func (c *Cache) Read() (*Matrix, error) {
+	m := &Matrix{Images: map[string]*Image{}}
+	content, err := os.ReadFile(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read base image cache: %w", err)
+	}
+	if err := json.Unmarshal(content, m); err != nil {
+		return nil, fmt.Errorf("failed to parse base image cache %s: %w", c.path, err)
+	}
+	if m.Images == nil {
+		m.Images = map[string]*Image{}
+	}
+	return m, nil
+}
+
+func (c *Cache) Write(m *Matrix) error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize base image matrix: %w", err)
+	}
+	// the file is replaced in one go, so that runs sharing the cache never read a partially written file
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write base image cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write base image cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write base image cache: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to write base image cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to write base image cache: %w", err)
+	}
+	return nil
+}
+
+// Import adds the images of m to the cache, eg- those of an offline bundle fetched on another machine.
+// Cached images fetched more recently than those of m are kept.
+func (c *Cache) Import(m *Matrix) error {
+	cached, err := c.Read()
+	if err != nil {
+		// a corrupt cache is replaced
+		cached = &Matrix{Images: map[string]*Image{}}
+	}
+	newer := &Matrix{Images: map[string]*Image{}}
+	for name, img := range m.Images {
+		if old := cached.Image(name); old == nil || !old.FetchedAt.After(img.FetchedAt) {
+			newer.Images[name] = img
+		}
+	}
+	cached.merge(newer)
+	if m.FetchedAt.After(cached.FetchedAt) {
+		cached.FetchedAt = m.FetchedAt
+	}
+	return c.Write(cached)
+}
+
+// LoadOptions control where the matrix is loaded from
+type LoadOptions struct {
+	// Cache is optional, data is always fetched if it's nil
+	Cache *Cache
+	// Fetcher is used to fetch live data, nil means that the matrix is loaded offline
+	Fetcher *Fetcher
+	// Refresh fetches live data even if the cached data is still fresh
+	Refresh bool
+}
+
+// Load returns the matrix of the given images, combining the built-in data with cached and live data.
+// Live data is only fetched for images that are missing from the cache or whose cached data expired.
+// If fetching fails, the matrix is still returned along with the error, using cached and built-in data.
+func Load(ctx context.Context, names []string, opts *LoadOptions) (*Matrix, error) {
+	cached := &Matrix{Images: map[string]*Image{}}
+	if opts.Cache != nil {
+		var err error
+		if cached, err = opts.Cache.Read(); err != nil {
+			// a corrupt cache is replaced by fresh data
+			cached = &Matrix{Images: map[string]*Image{}}
+		}
+	}
+
+	builtin := Builtin()
+	var stale []string
+	for _, name := range names {
+		if builtin.Image(name) == nil {
+			// only images with a built-in definition can be fetched
+			continue
+		}
+		img := cached.Image(name)
+		if opts.Refresh || img == nil || now().Sub(img.FetchedAt) > CacheTTL {
+			stale = append(stale, name)
+		}
+	}
+
+	var fetchErr error
+	if opts.Fetcher != nil && len(stale) > 0 {
+		live, err := opts.Fetcher.Fetch(ctx, stale)
+		if err != nil {
+			fetchErr = err
+		} else {
+			cached.merge(live)
+			cached.FetchedAt = live.FetchedAt
+			if opts.Cache != nil {
+				fetchErr = opts.Cache.Write(cached)
+			}
+		}
+	}
+
+	builtin.merge(cached)
+	builtin.FetchedAt = cached.FetchedAt
+	return builtin, fetchErr
+}
+
+// merge overrides the images of m with the images of other.
+// Vulnerability counts are kept if other doesn't have them, since scanning is expensive.
+func (m *Matrix) merge(other *Matrix) {
+	for name, img := range other.Images {
+		if old, ok := m.Images[name]; ok {
+			for tag, t := range old.Tags {
+				if t.CVEs == nil {
+					continue
+				}
+				if n, ok := img.Tags[tag]; ok && n.CVEs == nil {
+					n.CVEs = t.CVEs
+				}
+			}
+		}
+		m.Images[name] = img
+	}
+}
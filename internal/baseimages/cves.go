@@ -0,0 +1,59 @@
+package baseimages
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/docker"
+)
+
+// ScanCVEs counts the known vulnerabilities of an image using Docker Scout.
+// The image is pulled by Docker Scout if it isn't available locally.
+func ScanCVEs(ctx context.Context, client *docker.Client, image string) (*CVECount, error) {
+	out, err := client.Output(ctx, "scout", "cves", "--format", "sarif", image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s for vulnerabilities: %w", image, err)
+	}
+	return parseSarif(out)
+}
+
+// parseSarif counts the vulnerabilities in a SARIF report produced by Docker Scout.
+// Every rule in the report is a distinct CVE and carries its severity as a property.
+func parseSarif(report string) (*CVECount, error) {
+	var sarif struct {
+		Runs []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID         string `json:"id"`
+						Properties struct {
+							Severity string `json:"cvssV3_severity"`
+						} `json:"properties"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal([]byte(report), &sarif); err != nil {
+		return nil, fmt.Errorf("failed to parse vulnerability report: %w", err)
+	}
+
+	count := &CVECount{ScannedAt: now()}
+	for _, run := range sarif.Runs {
+		for _, rule := range run.Tool.Driver.Rules {
+			switch strings.ToUpper(rule.Properties.Severity) {
+			case "CRITICAL":
+				count.Critical++
+			case "HIGH":
+				count.High++
+			case "MEDIUM":
+				count.Medium++
+			default:
+				count.Low++
+			}
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,178 @@
+package baseimages
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/platform"
+)
+
+const (
+	DefaultHubURL = "https://hub.docker.com"
+	DefaultEOLURL = "https://endoflife.date"
+)
+
+// errNotFound is returned if a URL doesn't exist, eg- a variant that isn't published for a release cycle
+var errNotFound = errors.New("not found")
+
+// Fetcher queries Docker Hub and endoflife.date for the current release cycles and tag sizes of official images
+type Fetcher struct {
+	Client *http.Client
+	HubURL string
+	EOLURL string
+}
+
+func NewFetcher() *Fetcher {
+	return &Fetcher{
+		Client: &http.Client{Timeout: 15 * time.Second},
+		HubURL: DefaultHubURL,
+		EOLURL: DefaultEOLURL,
+	}
+}
+
+// Fetch returns a matrix containing live data of the given images.
+// The built-in definition of each image decides which product and variants are looked up,
+// so images unknown to dockershrink are skipped. Sizes are only fetched for supported release cycles.
+func (f *Fetcher) Fetch(ctx context.Context, names []string) (*Matrix, error) {
+	builtin := Builtin()
+	m := &Matrix{FetchedAt: now(), Images: map[string]*Image{}}
+
+	for _, name := range names {
+		img := builtin.Image(name)
+		if img == nil {
+			continue
+		}
+		if img.Replacement != "" {
+			img.FetchedAt = m.FetchedAt
+			m.Images[img.Name] = img
+			continue
+		}
+
+		cycles, err := f.cycles(ctx, img.Product)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch release cycles of %s: %w", img.Name, err)
+		}
+		live := &Image{
+			Name:      img.Name,
+			Product:   img.Product,
+			Variants:  img.Variants,
+			Aliases:   img.Aliases,
+			Cycles:    cycles,
+			Tags:      map[string]*Tag{},
+			FetchedAt: m.FetchedAt,
+		}
+		for _, c := range cycles {
+			if !c.Supported(now()) {
+				continue
+			}
+			for _, variant := range img.Variants {
+				tag := tagName(c.Version, variant)
+				details, err := f.tag(ctx, img.Name, tag)
+				if errors.Is(err, errNotFound) {
+					continue
+				}
+				if err != nil {
+					return nil, fmt.Errorf("failed to fetch details of %s:%s: %w", img.Name, tag, err)
+				}
+				live.Tags[tag] = details
+			}
+		}
+		m.Images[img.Name] = live
+	}
+	return m, nil
+}
+
+// cycles returns the release cycles of a product from endoflife.date, newest first
+func (f *Fetcher) cycles(ctx context.Context, product string) ([]*Cycle, error) {
+	var releases []struct {
+		Cycle string          `json:"cycle"`
+		EOL   json.RawMessage `json:"eol"`
+		LTS   json.RawMessage `json:"lts"`
+	}
+	if err := f.getJSON(ctx, fmt.Sprintf("%s/api/%s.json", f.EOLURL, product), &releases); err != nil {
+		return nil, err
+	}
+
+	cycles := make([]*Cycle, 0, len(releases))
+	for _, r := range releases {
+		c := &Cycle{Version: r.Cycle}
+		// eol and lts are either booleans or the date on which the cycle reaches that state
+		var eol string
+		if err := json.Unmarshal(r.EOL, &eol); err == nil {
+			if t, err := time.Parse(time.DateOnly, eol); err == nil {
+				c.EOL = t
+			}
+		} else {
+			_ = json.Unmarshal(r.EOL, &c.Ended)
+		}
+		var lts string
+		if err := json.Unmarshal(r.LTS, &lts); err == nil {
+			c.LTS = lts != ""
+		} else {
+			_ = json.Unmarshal(r.LTS, &c.LTS)
+		}
+		cycles = append(cycles, c)
+	}
+	return cycles, nil
+}
+
+// tag returns the compressed linux/amd64 size of an official image's tag and the platforms it's published for
+func (f *Fetcher) tag(ctx context.Context, name, tag string) (*Tag, error) {
+	var resp struct {
+		FullSize int64 `json:"full_size"`
+		Images   []struct {
+			Architecture string `json:"architecture"`
+			OS           string `json:"os"`
+			Variant      string `json:"variant"`
+			Size         int64  `json:"size"`
+		} `json:"images"`
+	}
+	if err := f.getJSON(ctx, fmt.Sprintf("%s/v2/repositories/library/%s/tags/%s", f.HubURL, name, tag), &resp); err != nil {
+		return nil, err
+	}
+
+	t := &Tag{Size: resp.FullSize}
+	for _, img := range resp.Images {
+		if img.OS == "linux" && img.Architecture == "amd64" {
+			t.Size = img.Size
+		}
+		// normalize default variants, eg- linux/arm64/v8 is linux/arm64
+		p, err := platform.Parse(platform.Platform{OS: img.OS, Architecture: img.Architecture, Variant: img.Variant}.String())
+		if err == nil && !slices.Contains(t.Platforms, p.String()) {
+			t.Platforms = append(t.Platforms, p.String())
+		}
+	}
+	return t, nil
+}
+
+func (f *Fetcher) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("request to %s failed with status %s: %s", url, resp.Status, bytes.TrimSpace(msg))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse response from %s: %w", url, err)
+	}
+	return nil
+}
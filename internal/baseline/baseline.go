@@ -0,0 +1,130 @@
+// Package baseline records the findings a project already has, so that only new findings are reported.
+// This allows adopting dockershrink on existing Dockerfiles without fixing everything at once.
+package baseline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+// Version is the version of the baseline file format
+const Version = 1
+
+// Baseline is the set of findings accepted when it was written
+type Baseline struct {
+	Version  int      `json:"version"`
+	Findings []*Entry `json:"findings"`
+
+	fingerprints map[string]bool
+}
+
+// Entry is an accepted finding. Only the fingerprint is used to match findings, the rest helps reviewing the file.
+type Entry struct {
+	Fingerprint string `json:"fingerprint"`
+	Code        string `json:"code,omitempty"`
+	Rule        string `json:"rule"`
+	Filepath    string `json:"filepath"`
+	Line        int    `json:"line,omitempty"`
+	Title       string `json:"title"`
+}
+
+// New returns a baseline accepting the given findings
+func New(findings []*models.Finding) *Baseline {
+	b := &Baseline{Version: Version, Findings: []*Entry{}}
+	fingerprints := NewFingerprinter()
+	for _, f := range findings {
+		b.Findings = append(b.Findings, &Entry{
+			Fingerprint: fingerprints.Next(f),
+			Code:        f.Code,
+			Rule:        f.Rule,
+			Filepath:    filepath.ToSlash(filepath.Clean(f.Filepath)),
+			Line:        f.Line,
+			Title:       f.Title,
+		})
+	}
+	return b
+}
+
+// Load reads the baseline file at path
+func Load(path string) (*Baseline, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b := &Baseline{}
+	if err := json.Unmarshal(content, b); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline %s: %w", path, err)
+	}
+	if b.Version > Version {
+		return nil, fmt.Errorf("baseline %s was written by a newer version of dockershrink (format version %d), upgrade dockershrink to use it", path, b.Version)
+	}
+	return b, nil
+}
+
+// Write writes the baseline to path
+func (b *Baseline) Write(path string) error {
+	content, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0o644)
+}
+
+// Filter returns the findings that aren't in the baseline, along with the number of findings that are.
+// All the findings of a Dockerfile must be filtered together, so that identical findings are told apart the same way as when the baseline was written.
+func (b *Baseline) Filter(findings []*models.Finding) ([]*models.Finding, int) {
+	kept := b.filter(findings)
+	return kept, len(findings) - len(kept)
+}
+
+func (b *Baseline) filter(findings []*models.Finding) []*models.Finding {
+	if b.fingerprints == nil {
+		b.fingerprints = make(map[string]bool, len(b.Findings))
+		for _, e := range b.Findings {
+			b.fingerprints[e.Fingerprint] = true
+		}
+	}
+	kept := []*models.Finding{}
+	fingerprints := NewFingerprinter()
+	for _, f := range findings {
+		if !b.fingerprints[fingerprints.Next(f)] {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// Fingerprinter identifies findings across runs, so that new findings can be told from those that were already there.
+// The line isn't part of the fingerprint, since it changes whenever lines are added above the finding.
+type Fingerprinter struct {
+	seen map[string]int
+}
+
+func NewFingerprinter() *Fingerprinter {
+	return &Fingerprinter{seen: map[string]int{}}
+}
+
+// Next returns the fingerprint of the finding. Identical findings in the same file are told apart by the order they appear in.
+func (p *Fingerprinter) Next(f *models.Finding) string {
+	check := f.Code
+	if check == "" {
+		check = f.Rule
+	}
+	key := fmt.Sprintf("%s\x00%s\x00%s", check, filepath.ToSlash(filepath.Clean(f.Filepath)), f.Title)
+	occurrence := p.seen[key]
+	p.seen[key]++
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d", key, occurrence)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Compare returns the findings of after that weren't in before, and those of before that aren't in after anymore.
+// Like with Filter, all the findings of a Dockerfile must be compared together.
+func Compare(before, after []*models.Finding) (added, resolved []*models.Finding) {
+	return New(before).filter(after), New(after).filter(before)
+}
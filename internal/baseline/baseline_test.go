@@ -0,0 +1,73 @@
+package baseline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+func TestBaseline_Filter(t *testing.T) {
+	legacy := []*models.Finding{
+		{Code: "DS001", Rule: "missing-dockerignore", Filepath: "Dockerfile", Title: "No .dockerignore"},
+		{Code: "DS014", Rule: "apt-get-bloat", Filepath: "Dockerfile", Line: 3, Title: "apt-get installs recommended packages"},
+	}
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := New(legacy).Write(path); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	b, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	// the same findings after lines were added above them, plus a second apt-get install and a new rule
+	current := []*models.Finding{
+		{Code: "DS001", Rule: "missing-dockerignore", Filepath: "./Dockerfile", Title: "No .dockerignore"},
+		{Code: "DS014", Rule: "apt-get-bloat", Filepath: "Dockerfile", Line: 5, Title: "apt-get installs recommended packages"},
+		{Code: "DS014", Rule: "apt-get-bloat", Filepath: "Dockerfile", Line: 9, Title: "apt-get installs recommended packages"},
+		{Code: "DS003", Rule: "heavy-final-base-image", Filepath: "Dockerfile", Line: 1, Title: "Final stage uses a heavy base image"},
+	}
+	kept, suppressed := b.Filter(current)
+	if suppressed != 2 {
+		t.Errorf("expected 2 findings to be in the baseline, got %d", suppressed)
+	}
+	if len(kept) != 2 || kept[0].Line != 9 || kept[1].Code != "DS003" {
+		t.Errorf("expected only the new findings to be kept, got %+v", kept)
+	}
+
+	// findings of another Dockerfile aren't in the baseline
+	if kept, _ := b.Filter([]*models.Finding{{Code: "DS001", Filepath: "api/Dockerfile", Title: "No .dockerignore"}}); len(kept) != 1 {
+		t.Errorf("expected a finding of another Dockerfile to be kept")
+	}
+}
+
+func TestLoad_NewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := os.WriteFile(path, []byte(`{"version": 2, "findings": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "newer version") {
+		t.Errorf("expected an error for a baseline of a newer version, got %v", err)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	before := []*models.Finding{
+		{Code: "DS001", Rule: "missing-dockerignore", Filepath: ".dockerignore", Title: "No .dockerignore"},
+		{Code: "DS003", Rule: "heavy-final-base-image", Filepath: "Dockerfile", Line: 1, Title: "Final stage uses a heavy base image"},
+	}
+	after := []*models.Finding{
+		{Code: "DS003", Rule: "heavy-final-base-image", Filepath: "Dockerfile", Line: 2, Title: "Final stage uses a heavy base image"},
+		{Code: "DS010", Rule: "package-manager-cache-left-behind", Filepath: "Dockerfile", Line: 4, Title: "Package manager cache is left in the final image"},
+	}
+	added, resolved := Compare(before, after)
+	if len(added) != 1 || added[0].Code != "DS010" {
+		t.Errorf("expected DS010 to be added, got %+v", added)
+	}
+	if len(resolved) != 1 || resolved[0].Code != "DS001" {
+		t.Errorf("expected DS001 to be resolved, got %+v", resolved)
+	}
+}
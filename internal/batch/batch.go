@@ -0,0 +1,95 @@
+// Package batch finds and clones the repositories of an organization, and summarizes the analyses of all
+// their Dockerfiles, so that platform teams can see how bloated the images are across every repository.
+package batch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Repository is a local copy of a repository whose Dockerfiles are analyzed
+type Repository struct {
+	// Name identifies the repository in the summary, eg- its path under the root directory
+	Name string
+	// URL is the URL the repository was cloned from, empty if it was found on disk
+	URL string
+	Dir string
+}
+
+// Find returns the git repositories under root, sorted by path. Repositories aren't searched for
+// other repositories, eg- submodules, and neither are directories whose names are in skipDirs.
+// root itself is returned if it's a repository.
+func Find(root string, skipDirs []string) ([]*Repository, error) {
+	repos := []*Repository{}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if p != root && (d.Name() == ".git" || slices.Contains(skipDirs, d.Name())) {
+			return filepath.SkipDir
+		}
+		// .git is a file in worktrees and submodules
+		if _, err := os.Stat(filepath.Join(p, ".git")); err != nil {
+			return nil
+		}
+		name := filepath.Base(p)
+		if rel, err := filepath.Rel(root, p); err == nil && rel != "." {
+			name = filepath.ToSlash(rel)
+		} else if abs, err := filepath.Abs(p); err == nil {
+			name = filepath.Base(abs)
+		}
+		repos = append(repos, &Repository{Name: name, Dir: p})
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search %s for repositories: %w", root, err)
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Dir < repos[j].Dir })
+	return repos, nil
+}
+
+// Clone makes a shallow clone of the repository at url in dir, which must not exist yet
+func Clone(ctx context.Context, url, dir string) (*Repository, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "clone", "--depth", "1", "--quiet", "--", url, dir)
+	cmd.Stderr = &stderr
+	// a repository asking for credentials fails instead of waiting for them
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// git explains the error on the first line and adds hints after it
+		msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n")
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("failed to clone %s: %s", url, msg)
+	}
+	return &Repository{Name: RepositoryName(url), URL: url, Dir: dir}, nil
+}
+
+// RepositoryName returns the name of the repository at url, eg- api for https://github.com/acme/api.git
+// or git@github.com:acme/api.git
+func RepositoryName(url string) string {
+	name := strings.TrimRight(url, "/")
+	name = strings.TrimSuffix(name, ".git")
+	if i := strings.LastIndexAny(name, "/:"); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "" {
+		return "repository"
+	}
+	return name
+}
@@ -0,0 +1,112 @@
+package batch
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	for _, p := range []string{
+		"api/.git/HEAD",
+		"api/vendor/lib/.git/HEAD",
+		"team/web/.git/HEAD",
+		"team/notes.md",
+		"node_modules/pkg/.git/HEAD",
+	} {
+		full := filepath.Join(root, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repos, err := Find(root, []string{"node_modules"})
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	expected := []*Repository{
+		{Name: "api", Dir: filepath.Join(root, "api")},
+		{Name: "team/web", Dir: filepath.Join(root, "team", "web")},
+	}
+	if len(repos) != len(expected) {
+		t.Fatalf("Find() returned %d repositories; want %d: %+v", len(repos), len(expected), repos)
+	}
+	for i, e := range expected {
+		if *repos[i] != *e {
+			t.Errorf("Find()[%d] = %+v; want %+v", i, repos[i], e)
+		}
+	}
+
+	// the root is a repository itself
+	repos, err = Find(filepath.Join(root, "api"), nil)
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if len(repos) != 1 || repos[0].Name != "api" {
+		t.Errorf("expected the root repository only, got %+v", repos)
+	}
+}
+
+func TestRepositoryName(t *testing.T) {
+	tests := map[string]string{
+		"https://github.com/acme/api.git": "api",
+		"https://github.com/acme/web/":    "web",
+		"git@github.com:acme/worker.git":  "worker",
+		"git@example.com:billing":         "billing",
+		"/srv/git/payments.git":           "payments",
+		"":                                "repository",
+	}
+	for url, expected := range tests {
+		if name := RepositoryName(url); name != expected {
+			t.Errorf("RepositoryName(%q) = %q; want %q", url, name, expected)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	src := filepath.Join(t.TempDir(), "api")
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = src
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	if err := os.MkdirAll(src, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "Dockerfile"), []byte("FROM node:20\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run("init", "-q")
+	run("config", "user.email", "dev@example.com")
+	run("config", "user.name", "dev")
+	run("add", "-A")
+	run("commit", "-q", "-m", "initial")
+
+	dest := filepath.Join(t.TempDir(), "clone")
+	repo, err := Clone(context.Background(), "file://"+filepath.ToSlash(src), dest)
+	if err != nil {
+		t.Fatalf("Clone() error = %v", err)
+	}
+	if repo.Name != "api" || repo.Dir != dest {
+		t.Errorf("Clone() = %+v", repo)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "Dockerfile")); err != nil {
+		t.Errorf("expected the files of the repository to be cloned: %v", err)
+	}
+
+	if _, err := Clone(context.Background(), "file://"+filepath.ToSlash(filepath.Join(src, "missing")), filepath.Join(t.TempDir(), "clone")); err == nil {
+		t.Errorf("expected an error cloning a repository that doesn't exist")
+	}
+}
@@ -0,0 +1,220 @@
+package batch
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/rules"
+	"github.com/duaraghav8/dockershrink/internal/units"
+)
+
+const (
+	// maxRepositoryRules is the number of rules listed for each repository
+	maxRepositoryRules = 3
+	// maxViolations is the number of rules listed across all the repositories
+	maxViolations = 10
+)
+
+//go:embed scoreboard.html
+var scoreboardHTML string
+
+var scoreboardTemplate = template.Must(template.New("scoreboard").Funcs(template.FuncMap{
+	"size":       units.FormatBytes,
+	"scoreClass": scoreClass,
+	"webURL":     webURL,
+}).Parse(scoreboardHTML))
+
+// Scoreboard ranks the repositories of a summary from the worst Dockerfiles to the best,
+// along with the rules they break most often, so that the teams to help first stand out.
+type Scoreboard struct {
+	GeneratedAt time.Time
+	Totals      Totals
+	// Repositories are ranked by average score, then by estimated waste. Those without
+	// any Dockerfile that could be analyzed come last, without a rank.
+	Repositories []*RepositoryRank
+	// Violations are the rules broken most often across all the repositories
+	Violations []*Violation
+}
+
+// RepositoryRank is the standing of a repository in the scoreboard
+type RepositoryRank struct {
+	// Rank is 1 for the repository with the worst Dockerfiles, 0 if none of its Dockerfiles were analyzed
+	Rank         int
+	Name         string
+	URL          string
+	Dockerfiles  int
+	Failed       int
+	AverageScore float64
+	WorstScore   int
+	Findings     int
+	// EstimatedWaste is the estimated number of bytes that fixing all the findings would save
+	EstimatedWaste int64
+	// TopRules are the rules the repository breaks most often
+	TopRules []*Violation
+	Error    string
+}
+
+// Violation counts the findings of a rule
+type Violation struct {
+	// Rule is the code of the rule, or its name for rules without a code
+	Rule string
+	// Name is the name of the rule, empty if it isn't a built-in rule
+	Name         string
+	Findings     int
+	Repositories int
+}
+
+// NewScoreboard ranks the repositories of the summary
+func NewScoreboard(s *Summary, generatedAt time.Time) *Scoreboard {
+	board := &Scoreboard{GeneratedAt: generatedAt, Totals: s.Totals}
+	violations := map[string]*Violation{}
+	for _, r := range s.Repositories {
+		rank := &RepositoryRank{Name: r.Name, URL: r.URL, Error: r.Error}
+		repoViolations := map[string]*Violation{}
+		analyzed, totalScore := 0, 0
+		for _, d := range r.Dockerfiles {
+			rank.Dockerfiles++
+			if d.Error != "" {
+				rank.Failed++
+				continue
+			}
+			if analyzed == 0 || d.Score < rank.WorstScore {
+				rank.WorstScore = d.Score
+			}
+			analyzed++
+			totalScore += d.Score
+			rank.Findings += d.Findings
+			rank.EstimatedWaste += d.EstimatedSavings
+			for rule, n := range d.Rules {
+				if repoViolations[rule] == nil {
+					repoViolations[rule] = newViolation(rule)
+				}
+				repoViolations[rule].Findings += n
+			}
+		}
+		if analyzed > 0 {
+			rank.AverageScore = float64(totalScore) / float64(analyzed)
+		}
+
+		for rule, v := range repoViolations {
+			if violations[rule] == nil {
+				violations[rule] = newViolation(rule)
+			}
+			violations[rule].Findings += v.Findings
+			violations[rule].Repositories++
+			rank.TopRules = append(rank.TopRules, v)
+		}
+		rank.TopRules = topViolations(rank.TopRules, maxRepositoryRules)
+		board.Repositories = append(board.Repositories, rank)
+	}
+
+	sort.SliceStable(board.Repositories, func(i, j int) bool {
+		a, b := board.Repositories[i], board.Repositories[j]
+		aAnalyzed, bAnalyzed := a.Dockerfiles > a.Failed, b.Dockerfiles > b.Failed
+		if aAnalyzed != bAnalyzed {
+			return aAnalyzed
+		}
+		if a.AverageScore != b.AverageScore {
+			return a.AverageScore < b.AverageScore
+		}
+		if a.EstimatedWaste != b.EstimatedWaste {
+			return a.EstimatedWaste > b.EstimatedWaste
+		}
+		return a.Name < b.Name
+	})
+	for i, r := range board.Repositories {
+		if r.Dockerfiles > r.Failed {
+			r.Rank = i + 1
+		}
+	}
+
+	all := make([]*Violation, 0, len(violations))
+	for _, v := range violations {
+		all = append(all, v)
+	}
+	board.Violations = topViolations(all, maxViolations)
+	return board
+}
+
+func newViolation(rule string) *Violation {
+	v := &Violation{Rule: rule}
+	if r := rules.Lookup(rule); r != nil {
+		v.Name = r.Name
+	}
+	return v
+}
+
+// topViolations returns the n violations with the most findings
+func topViolations(violations []*Violation, n int) []*Violation {
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Findings != violations[j].Findings {
+			return violations[i].Findings > violations[j].Findings
+		}
+		return violations[i].Rule < violations[j].Rule
+	})
+	if len(violations) > n {
+		violations = violations[:n]
+	}
+	return violations
+}
+
+// WriteCSV writes one row per repository, in the order of the ranking
+func (b *Scoreboard) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"rank", "repository", "url", "dockerfiles", "failed", "average_score", "worst_score", "findings", "estimated_waste_mb", "top_violations", "error"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, r := range b.Repositories {
+		row := []string{"", r.Name, r.URL, strconv.Itoa(r.Dockerfiles), strconv.Itoa(r.Failed), "", "", "", "", "", r.Error}
+		if r.Rank > 0 {
+			top := make([]string, 0, len(r.TopRules))
+			for _, v := range r.TopRules {
+				top = append(top, fmt.Sprintf("%s (%d)", v.Rule, v.Findings))
+			}
+			row[0] = strconv.Itoa(r.Rank)
+			row[5] = strconv.FormatFloat(r.AverageScore, 'f', 1, 64)
+			row[6] = strconv.Itoa(r.WorstScore)
+			row[7] = strconv.Itoa(r.Findings)
+			row[8] = strconv.FormatFloat(float64(r.EstimatedWaste)/(1024*1024), 'f', 1, 64)
+			row[9] = strings.Join(top, " ")
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteHTML writes the scoreboard as a static HTML page that doesn't load anything else
+func (b *Scoreboard) WriteHTML(w io.Writer) error {
+	return scoreboardTemplate.Execute(w, b)
+}
+
+// scoreClass returns the CSS class of a score: bad below 50, fair below 80 and good otherwise
+func scoreClass(score float64) string {
+	switch {
+	case score < 50:
+		return "bad"
+	case score < 80:
+		return "fair"
+	default:
+		return "good"
+	}
+}
+
+// webURL returns the page of a repository cloned from url, empty if it was cloned over ssh or from a file
+func webURL(url string) string {
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return ""
+	}
+	return strings.TrimSuffix(url, ".git")
+}
@@ -0,0 +1,115 @@
+package batch
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+func TestNewScoreboard(t *testing.T) {
+	s := &Summary{}
+	s.Add(&RepositorySummary{
+		Name: "web",
+		Dockerfiles: []*DockerfileSummary{
+			NewDockerfileSummary("Dockerfile", 70, []*models.Finding{{Code: "DS010"}}, 0),
+		},
+	})
+	s.Add(&RepositorySummary{Name: "billing", Error: "failed to clone"})
+	s.Add(&RepositorySummary{
+		Name: "api",
+		URL:  "https://github.com/acme/api.git",
+		Dockerfiles: []*DockerfileSummary{
+			NewDockerfileSummary("Dockerfile", 40, []*models.Finding{
+				{Code: "DS003", EstimatedSizeImpact: 900 * 1024 * 1024},
+				{Code: "DS010"},
+				{Code: "DS010"},
+			}, 0),
+			NewDockerfileSummary("worker/Dockerfile", 60, []*models.Finding{{Code: "DS010"}, {Rule: "acme/no-latest"}}, 0),
+			{Path: "legacy/Dockerfile", Error: "syntax error"},
+		},
+	})
+	s.Add(&RepositorySummary{
+		Name:        "docs",
+		Dockerfiles: []*DockerfileSummary{NewDockerfileSummary("Dockerfile", 50, nil, 0)},
+	})
+
+	board := NewScoreboard(s, time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC))
+	order := []string{}
+	for _, r := range board.Repositories {
+		order = append(order, r.Name)
+	}
+	if strings.Join(order, ",") != "api,docs,web,billing" {
+		t.Fatalf("expected the repositories to be ranked worst first, got %v", order)
+	}
+
+	api := board.Repositories[0]
+	if api.Rank != 1 || api.AverageScore != 50 || api.WorstScore != 40 || api.Failed != 1 || api.Findings != 5 || api.EstimatedWaste != 900*1024*1024 {
+		t.Errorf("unexpected rank of api: %+v", api)
+	}
+	if len(api.TopRules) != 3 || api.TopRules[0].Rule != "DS010" || api.TopRules[0].Findings != 3 {
+		t.Errorf("unexpected top rules of api: %+v", api.TopRules)
+	}
+	if board.Repositories[1].Rank != 2 || board.Repositories[3].Rank != 0 {
+		t.Errorf("expected the repositories without analyzed Dockerfiles to have no rank")
+	}
+
+	top := board.Violations[0]
+	if top.Rule != "DS010" || top.Findings != 4 || top.Repositories != 2 || top.Name == "" {
+		t.Errorf("unexpected most frequent violation: %+v", top)
+	}
+	for _, v := range board.Violations {
+		if v.Rule == "acme/no-latest" && v.Name != "" {
+			t.Errorf("expected rules that aren't built in to have no name, got %q", v.Name)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := board.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected a header and a row per repository, got:\n%s", buf.String())
+	}
+	if expected := "1,api,https://github.com/acme/api.git,3,1,50.0,40,5,900.0,DS010 (3) DS003 (1) acme/no-latest (1),"; lines[1] != expected {
+		t.Errorf("unexpected row:\n%s\nwant\n%s", lines[1], expected)
+	}
+	if expected := ",billing,,0,0,,,,,,failed to clone"; lines[4] != expected {
+		t.Errorf("unexpected row:\n%s\nwant\n%s", lines[4], expected)
+	}
+}
+
+func TestScoreboard_WriteHTML(t *testing.T) {
+	s := &Summary{}
+	s.Add(&RepositorySummary{
+		Name: "<api>",
+		URL:  "https://github.com/acme/api.git",
+		Dockerfiles: []*DockerfileSummary{
+			NewDockerfileSummary("Dockerfile", 40, []*models.Finding{{Code: "DS003", EstimatedSizeImpact: 2048}}, 0),
+		},
+	})
+	s.Add(&RepositorySummary{Name: "web", URL: "git@github.com:acme/web.git", Error: "failed to clone"})
+
+	var buf bytes.Buffer
+	if err := NewScoreboard(s, time.Now()).WriteHTML(&buf); err != nil {
+		t.Fatalf("WriteHTML() error = %v", err)
+	}
+	page := buf.String()
+	for _, expected := range []string{
+		`<a href="https://github.com/acme/api">&lt;api&gt;</a>`,
+		`<span class="score bad">40</span>`,
+		"2.0 KB",
+		"DS003",
+		`<div class="error">failed to clone</div>`,
+	} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("expected the page to contain %q", expected)
+		}
+	}
+	if strings.Contains(page, "git@github.com") {
+		t.Errorf("expected repositories cloned over ssh not to be linked")
+	}
+}
@@ -0,0 +1,175 @@
+package batch
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// Summary is the aggregate of the analyses of every Dockerfile in every repository
+type Summary struct {
+	Repositories []*RepositorySummary `json:"repositories"`
+	Totals       Totals               `json:"totals"`
+}
+
+// RepositorySummary is the analysis of the Dockerfiles of a single repository
+type RepositorySummary struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+	// Error is set if the repository couldn't be cloned or searched, none of its Dockerfiles were analyzed then
+	Error       string               `json:"error,omitempty"`
+	Dockerfiles []*DockerfileSummary `json:"dockerfiles"`
+}
+
+// DockerfileSummary is the analysis of a single Dockerfile
+type DockerfileSummary struct {
+	// Path is relative to the root of the repository
+	Path     string `json:"path"`
+	Score    int    `json:"score"`
+	Findings int    `json:"findings"`
+	// Severities counts the findings by severity
+	Severities map[models.Severity]int `json:"severities,omitempty"`
+	// Rules counts the findings by the code of their rule, or its name for rules without a code
+	Rules map[string]int `json:"rules,omitempty"`
+	// EstimatedImageSize is the estimated size of the image in bytes, 0 if it couldn't be estimated
+	EstimatedImageSize int64 `json:"estimated_image_size,omitempty"`
+	// EstimatedSavings is the estimated number of bytes saved by fixing all the findings
+	EstimatedSavings int64  `json:"estimated_savings,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// Totals aggregates the Dockerfiles of all the repositories
+type Totals struct {
+	Repositories int `json:"repositories"`
+	Dockerfiles  int `json:"dockerfiles"`
+	// Failed is the number of Dockerfiles that couldn't be analyzed
+	Failed   int `json:"failed"`
+	Findings int `json:"findings"`
+	// AverageScore is the average score of the Dockerfiles that were analyzed
+	AverageScore     float64 `json:"average_score"`
+	EstimatedSavings int64   `json:"estimated_savings"`
+}
+
+// NewDockerfileSummary summarizes the analysis of the Dockerfile at path
+func NewDockerfileSummary(path string, score int, findings []*models.Finding, estimatedImageSize int64) *DockerfileSummary {
+	s := &DockerfileSummary{
+		Path:               path,
+		Score:              score,
+		Findings:           len(findings),
+		Severities:         map[models.Severity]int{},
+		Rules:              map[string]int{},
+		EstimatedImageSize: estimatedImageSize,
+	}
+	for _, f := range findings {
+		s.Severities[f.Severity]++
+		rule := f.Code
+		if rule == "" {
+			rule = f.Rule
+		}
+		s.Rules[rule]++
+		s.EstimatedSavings += f.EstimatedSizeImpact
+	}
+	return s
+}
+
+// Add adds a repository to the summary and updates the totals
+func (s *Summary) Add(r *RepositorySummary) {
+	s.Repositories = append(s.Repositories, r)
+
+	scored, totalScore := 0, 0
+	s.Totals = Totals{Repositories: len(s.Repositories)}
+	for _, repo := range s.Repositories {
+		for _, d := range repo.Dockerfiles {
+			s.Totals.Dockerfiles++
+			if d.Error != "" {
+				s.Totals.Failed++
+				continue
+			}
+			s.Totals.Findings += d.Findings
+			s.Totals.EstimatedSavings += d.EstimatedSavings
+			totalScore += d.Score
+			scored++
+		}
+	}
+	if scored > 0 {
+		s.Totals.AverageScore = float64(totalScore) / float64(scored)
+	}
+}
+
+// Write writes the summary in the given format
+func (s *Summary) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatJSON:
+		return s.WriteJSON(w)
+	case FormatCSV:
+		return s.WriteCSV(w)
+	default:
+		return fmt.Errorf("unknown summary format %q, must be %s or %s", format, FormatCSV, FormatJSON)
+	}
+}
+
+// LoadSummary reads a summary written in the JSON format
+func LoadSummary(path string) (*Summary, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Summary{}
+	if err := json.Unmarshal(content, s); err != nil {
+		return nil, fmt.Errorf("failed to parse summary %s, only summaries written as JSON can be read: %w", path, err)
+	}
+	return s, nil
+}
+
+func (s *Summary) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// WriteCSV writes one row per Dockerfile. Repositories without Dockerfiles, or that failed, get a single row
+// without a Dockerfile so that every repository shows up in the summary.
+func (s *Summary) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"repository", "url", "dockerfile", "score", "findings", "high", "medium", "low", "info", "estimated_image_size", "estimated_savings", "error"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, r := range s.Repositories {
+		if len(r.Dockerfiles) == 0 {
+			row := make([]string, len(header))
+			row[0], row[1], row[len(row)-1] = r.Name, r.URL, r.Error
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, d := range r.Dockerfiles {
+			row := []string{r.Name, r.URL, d.Path, "", "", "", "", "", "", "", "", d.Error}
+			if d.Error == "" {
+				row[3] = strconv.Itoa(d.Score)
+				row[4] = strconv.Itoa(d.Findings)
+				for i, sev := range []models.Severity{models.SeverityHigh, models.SeverityMedium, models.SeverityLow, models.SeverityInfo} {
+					row[5+i] = strconv.Itoa(d.Severities[sev])
+				}
+				row[9] = strconv.FormatInt(d.EstimatedImageSize, 10)
+				row[10] = strconv.FormatInt(d.EstimatedSavings, 10)
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,77 @@
+package batch
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+func newSummary() *Summary {
+	s := &Summary{}
+	s.Add(&RepositorySummary{
+		Name: "api",
+		URL:  "https://github.com/acme/api.git",
+		Dockerfiles: []*DockerfileSummary{
+			NewDockerfileSummary("Dockerfile", 40, []*models.Finding{
+				{Code: "DS003", Severity: models.SeverityHigh, EstimatedSizeImpact: 900},
+				{Code: "DS010", Severity: models.SeverityLow, EstimatedSizeImpact: 100},
+				{Code: "DS010", Severity: models.SeverityLow},
+			}, 5000),
+			{Path: "worker/Dockerfile", Error: "syntax error"},
+		},
+	})
+	s.Add(&RepositorySummary{
+		Name:        "web",
+		Dockerfiles: []*DockerfileSummary{NewDockerfileSummary("Dockerfile", 90, nil, 0)},
+	})
+	s.Add(&RepositorySummary{Name: "billing", Error: "failed to clone"})
+	return s
+}
+
+func TestSummary_Totals(t *testing.T) {
+	expected := Totals{Repositories: 3, Dockerfiles: 3, Failed: 1, Findings: 3, AverageScore: 65, EstimatedSavings: 1000}
+	if totals := newSummary().Totals; totals != expected {
+		t.Errorf("Totals = %+v; want %+v", totals, expected)
+	}
+}
+
+func TestSummary_WriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newSummary().Write(&buf, FormatCSV); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	expected := strings.Join([]string{
+		"repository,url,dockerfile,score,findings,high,medium,low,info,estimated_image_size,estimated_savings,error",
+		"api,https://github.com/acme/api.git,Dockerfile,40,3,1,0,2,0,5000,1000,",
+		"api,https://github.com/acme/api.git,worker/Dockerfile,,,,,,,,,syntax error",
+		"web,,Dockerfile,90,0,0,0,0,0,0,0,",
+		"billing,,,,,,,,,,,failed to clone",
+	}, "\n") + "\n"
+	if buf.String() != expected {
+		t.Errorf("Write() =\n%s\nwant\n%s", buf.String(), expected)
+	}
+}
+
+func TestSummary_WriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newSummary().Write(&buf, FormatJSON); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	decoded := &Summary{}
+	if err := json.Unmarshal(buf.Bytes(), decoded); err != nil {
+		t.Fatalf("failed to decode the summary: %v", err)
+	}
+	if len(decoded.Repositories) != 3 || decoded.Totals.Dockerfiles != 3 {
+		t.Errorf("unexpected summary: %s", buf.String())
+	}
+	if d := decoded.Repositories[0].Dockerfiles[0]; d.Severities[models.SeverityLow] != 2 || d.Rules["DS010"] != 2 || d.EstimatedSavings != 1000 {
+		t.Errorf("unexpected Dockerfile summary: %+v", d)
+	}
+
+	if err := newSummary().Write(&buf, "xml"); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,99 @@
+// Package buildargs finds the combinations of build arguments a Dockerfile is built with, as declared in
+// compose files, bake files and CI workflows, so that every variant of the image can be analyzed.
+package buildargs
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/buildcontext"
+	"github.com/duaraghav8/dockershrink/internal/compose"
+)
+
+// Combination is a set of build arguments the Dockerfile is built with
+type Combination struct {
+	// Name identifies where the combination is declared, eg- "compose.yaml:api"
+	Name string
+	Args map[string]string
+}
+
+// String returns the arguments of the combination like the flags that pass them, sorted by name
+func (c *Combination) String() string {
+	names := make([]string, 0, len(c.Args))
+	for name := range c.Args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + c.Args[name]
+	}
+	return strings.Join(parts, " ")
+}
+
+// FromCompose returns the build arguments of every service of the compose file built from the Dockerfile
+func FromCompose(f *compose.File, dockerfilePath string) []*Combination {
+	combinations := []*Combination{}
+	for _, s := range f.ServicesForDockerfile(dockerfilePath) {
+		if len(s.Build.Args) == 0 {
+			continue
+		}
+		combinations = append(combinations, &Combination{
+			Name: fmt.Sprintf("%s:%s", filepath.Base(f.Path), s.Name),
+			Args: s.Build.Args,
+		})
+	}
+	return combinations
+}
+
+// FromBake returns the build arguments of every target of the bake file at path built from the Dockerfile
+func FromBake(path, dockerfilePath string) ([]*Combination, error) {
+	targets, err := buildcontext.BakeTargets(path, dockerfilePath)
+	if err != nil {
+		return nil, err
+	}
+	combinations := []*Combination{}
+	for _, t := range targets {
+		if len(t.Args) == 0 {
+			continue
+		}
+		combinations = append(combinations, &Combination{
+			Name: fmt.Sprintf("%s:%s", filepath.Base(path), t.Name),
+			Args: t.Args,
+		})
+	}
+	return combinations, nil
+}
+
+// Relevant returns the combinations that give a value to at least one of the declared ARGs, with only
+// those arguments. Combinations with the same arguments are merged into one, named after all of them.
+func Relevant(combinations []*Combination, declared []string) []*Combination {
+	isDeclared := map[string]bool{}
+	for _, name := range declared {
+		isDeclared[name] = true
+	}
+
+	relevant := []*Combination{}
+	byArgs := map[string]*Combination{}
+	for _, c := range combinations {
+		args := map[string]string{}
+		for name, value := range c.Args {
+			if isDeclared[name] {
+				args[name] = value
+			}
+		}
+		if len(args) == 0 {
+			continue
+		}
+		r := &Combination{Name: c.Name, Args: args}
+		if existing, ok := byArgs[r.String()]; ok {
+			existing.Name += ", " + r.Name
+			continue
+		}
+		byArgs[r.String()] = r
+		relevant = append(relevant, r)
+	}
+	return relevant
+}
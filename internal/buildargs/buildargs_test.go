@@ -0,0 +1,94 @@
+package buildargs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/compose"
+)
+
+func TestFromCompose(t *testing.T) {
+	dir := t.TempDir()
+	f, err := compose.Parse(filepath.Join(dir, "compose.yaml"), []byte(`
+services:
+  api:
+    build:
+      context: .
+      args:
+        TARGET_ENV: production
+  api-dev:
+    build:
+      context: .
+      args:
+        - TARGET_ENV=development
+        - DEBUG=1
+  web:
+    build: ./web
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	combinations := FromCompose(f, filepath.Join(dir, "Dockerfile"))
+	if len(combinations) != 2 {
+		t.Fatalf("FromCompose() returned %d combinations; want 2", len(combinations))
+	}
+	if combinations[0].Name != "compose.yaml:api" || combinations[0].String() != "TARGET_ENV=production" {
+		t.Errorf("combinations[0] = %s %s", combinations[0].Name, combinations[0])
+	}
+	if combinations[1].Name != "compose.yaml:api-dev" || combinations[1].String() != "DEBUG=1 TARGET_ENV=development" {
+		t.Errorf("combinations[1] = %s %s", combinations[1].Name, combinations[1])
+	}
+}
+
+func TestFromBake(t *testing.T) {
+	dir := t.TempDir()
+	bake := `{
+  "target": {
+    "prod": {"args": {"TARGET_ENV": "production"}},
+    "dev": {"args": {"TARGET_ENV": "development"}},
+    "plain": {},
+    "web": {"context": "web", "args": {"TARGET_ENV": "production"}}
+  }
+}`
+	path := filepath.Join(dir, "docker-bake.json")
+	if err := os.WriteFile(path, []byte(bake), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	combinations, err := FromBake(path, filepath.Join(dir, "Dockerfile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, c := range combinations {
+		names = append(names, c.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"docker-bake.json:dev", "docker-bake.json:prod"}) {
+		t.Errorf("FromBake() = %v", names)
+	}
+}
+
+func TestRelevant(t *testing.T) {
+	combinations := []*Combination{
+		{Name: "a", Args: map[string]string{"TARGET_ENV": "production", "UNUSED": "1"}},
+		{Name: "b", Args: map[string]string{"UNUSED": "2"}},
+		{Name: "c", Args: map[string]string{"TARGET_ENV": "production"}},
+		{Name: "d", Args: map[string]string{"TARGET_ENV": "development"}},
+	}
+	relevant := Relevant(combinations, []string{"TARGET_ENV"})
+	if len(relevant) != 2 {
+		t.Fatalf("Relevant() returned %d combinations; want 2", len(relevant))
+	}
+	if relevant[0].Name != "a, c" || relevant[0].String() != "TARGET_ENV=production" {
+		t.Errorf("relevant[0] = %s %s", relevant[0].Name, relevant[0])
+	}
+	if relevant[1].Name != "d" || relevant[1].String() != "TARGET_ENV=development" {
+		t.Errorf("relevant[1] = %s %s", relevant[1].Name, relevant[1])
+	}
+	if combinations[0].Args["UNUSED"] != "1" {
+		t.Errorf("Relevant() must not modify the combinations")
+	}
+}
@@ -0,0 +1,428 @@
+package buildargs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// workflowsDir is where GitHub Actions workflows are, relative to the root of the repository
+var workflowsDir = filepath.Join(".github", "workflows")
+
+var (
+	// matches references to the values of a job's matrix, eg- "${{ matrix.node }}"
+	matrixRefRegex = regexp.MustCompile(`\$\{\{\s*matrix\.([\w-]+)\s*\}\}`)
+	// matches the commands that build an image with the docker CLI
+	dockerBuildRegex = regexp.MustCompile(`\bdocker\s+((buildx|image)\s+)?build\b`)
+)
+
+// buildPushAction is the GitHub Action that builds images, its inputs are read like the flags of docker build
+const buildPushAction = "docker/build-push-action"
+
+type workflow struct {
+	Jobs map[string]*job `yaml:"jobs"`
+}
+
+type job struct {
+	Strategy struct {
+		Matrix map[string]interface{} `yaml:"matrix"`
+	} `yaml:"strategy"`
+	Defaults struct {
+		Run struct {
+			WorkingDirectory string `yaml:"working-directory"`
+		} `yaml:"run"`
+	} `yaml:"defaults"`
+	Steps []*step `yaml:"steps"`
+}
+
+type step struct {
+	Uses             string                 `yaml:"uses"`
+	Run              string                 `yaml:"run"`
+	With             map[string]interface{} `yaml:"with"`
+	WorkingDirectory string                 `yaml:"working-directory"`
+}
+
+// build is an image build found in a step: the Dockerfile it builds and the arguments it passes, as written
+type build struct {
+	dir        string
+	dockerfile string
+	args       []string
+}
+
+// FindWorkflows returns the paths of the GitHub Actions workflows of the repository dir is part of.
+// The directories above dir are searched up to the root of the repository.
+func FindWorkflows(dir string) []string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	for {
+		paths, _ := filepath.Glob(filepath.Join(dir, workflowsDir, "*.y*ml"))
+		if len(paths) > 0 {
+			sort.Strings(paths)
+			return paths
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// FromWorkflow returns the build arguments of every build of the Dockerfile in the GitHub Actions workflow
+// at path, with one combination per value of the job's matrix. Builds run "docker build" or use
+// docker/build-push-action. Arguments whose value is only known while the workflow runs, eg- secrets, are left out.
+func FromWorkflow(path, dockerfilePath string) ([]*Combination, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &workflow{}
+	if err := yaml.Unmarshal(content, w); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	target, err := filepath.Abs(dockerfilePath)
+	if err != nil {
+		return nil, err
+	}
+	// workflows run in the root of the repository, which contains .github/workflows
+	root, err := filepath.Abs(filepath.Join(filepath.Dir(path), "..", ".."))
+	if err != nil {
+		return nil, err
+	}
+
+	jobNames := make([]string, 0, len(w.Jobs))
+	for name := range w.Jobs {
+		jobNames = append(jobNames, name)
+	}
+	sort.Strings(jobNames)
+
+	combinations := []*Combination{}
+	for _, jobName := range jobNames {
+		j := w.Jobs[jobName]
+		if j == nil {
+			continue
+		}
+		for _, values := range expandMatrix(j.Strategy.Matrix) {
+			for _, s := range j.Steps {
+				if s == nil {
+					continue
+				}
+				dir := s.WorkingDirectory
+				if dir == "" {
+					dir = j.Defaults.Run.WorkingDirectory
+				}
+				for _, b := range stepBuilds(s, substitute(dir, values), values) {
+					dockerfile := filepath.Join(root, b.dir, b.dockerfile)
+					if filepath.Clean(dockerfile) != target {
+						continue
+					}
+					args := map[string]string{}
+					for _, arg := range b.args {
+						name, value, ok := strings.Cut(arg, "=")
+						if !ok || strings.Contains(value, "${{") {
+							continue
+						}
+						args[name] = value
+					}
+					if len(args) == 0 {
+						continue
+					}
+					name := fmt.Sprintf("%s:%s", filepath.Base(path), jobName)
+					if len(values) > 0 {
+						name += " (" + (&Combination{Args: values}).String() + ")"
+					}
+					combinations = append(combinations, &Combination{Name: name, Args: args})
+				}
+			}
+		}
+	}
+	return combinations, nil
+}
+
+// stepBuilds returns the builds of a step, run in the given directory relative to the root of the repository,
+// with the references to the values of the matrix replaced
+func stepBuilds(s *step, dir string, values map[string]string) []*build {
+	if strings.HasPrefix(s.Uses, buildPushAction+"@") {
+		context := substitute(withInput(s.With, "context"), values)
+		if context == "" {
+			context = "."
+		}
+		dockerfile := substitute(withInput(s.With, "file"), values)
+		if dockerfile == "" {
+			dockerfile = filepath.Join(context, "Dockerfile")
+		}
+		b := &build{dockerfile: dockerfile}
+		for _, line := range strings.Split(substitute(withInput(s.With, "build-args"), values), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				b.args = append(b.args, line)
+			}
+		}
+		return []*build{b}
+	}
+
+	builds := []*build{}
+	script := strings.ReplaceAll(substitute(s.Run, values), "\\\n", " ")
+	for _, line := range strings.Split(script, "\n") {
+		for _, command := range strings.Split(line, "&&") {
+			loc := dockerBuildRegex.FindStringIndex(command)
+			if loc == nil {
+				continue
+			}
+			if b := parseDockerBuild(shellWords(command[loc[1]:])); b != nil {
+				b.dir = dir
+				builds = append(builds, b)
+			}
+		}
+	}
+	return builds
+}
+
+// parseDockerBuild reads the Dockerfile and the build arguments from the arguments of "docker build"
+func parseDockerBuild(words []string) *build {
+	b := &build{}
+	context := ""
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		next := func() string {
+			if i+1 < len(words) {
+				i++
+				return words[i]
+			}
+			return ""
+		}
+		switch {
+		case word == "--build-arg":
+			b.args = append(b.args, next())
+		case strings.HasPrefix(word, "--build-arg="):
+			b.args = append(b.args, strings.TrimPrefix(word, "--build-arg="))
+		case word == "-f" || word == "--file":
+			b.dockerfile = next()
+		case strings.HasPrefix(word, "--file="):
+			b.dockerfile = strings.TrimPrefix(word, "--file=")
+		case word == "|" || word == ";" || word == ">" || strings.HasPrefix(word, "#"):
+			i = len(words)
+		case strings.HasPrefix(word, "--") && !strings.Contains(word, "=") && !isBooleanFlag(word):
+			// the value of the flag, eg- "--tag app:latest"
+			next()
+		case word == "-t":
+			next()
+		case strings.HasPrefix(word, "-"):
+		default:
+			context = word
+		}
+	}
+	if context == "" || context == "-" {
+		return nil
+	}
+	if b.dockerfile == "" {
+		b.dockerfile = filepath.Join(context, "Dockerfile")
+	}
+	return b
+}
+
+// flags of docker build that don't take a value
+var booleanFlags = map[string]bool{
+	"--no-cache": true, "--pull": true, "--push": true, "--load": true, "--quiet": true, "--rm": true, "--force-rm": true,
+}
+
+func isBooleanFlag(flag string) bool {
+	return booleanFlags[flag]
+}
+
+// shellWords splits a command into words the way a shell does, removing quotes.
+// Expressions of the workflow, eg- "${{ secrets.TOKEN }}", are kept within a single word.
+func shellWords(command string) []string {
+	words := []string{}
+	var word strings.Builder
+	inWord := false
+	var quote byte
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		if strings.HasPrefix(command[i:], "${{") {
+			if end := strings.Index(command[i:], "}}"); end >= 0 {
+				word.WriteString(command[i : i+end+2])
+				inWord = true
+				i += end + 1
+				continue
+			}
+		}
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				word.WriteByte(c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// withInput returns an input of an action as a string
+func withInput(with map[string]interface{}, name string) string {
+	v, ok := with[name]
+	if !ok || v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// expandMatrix returns every combination of the values of a job's matrix, the way GitHub Actions does:
+// the cartesian product of its lists, without the combinations matching an "exclude", extended or
+// added to by the "include" entries. A job without a matrix has a single, empty combination.
+// Lists given as expressions, eg- "${{ fromJSON(...) }}", can't be expanded and are left out.
+func expandMatrix(matrix map[string]interface{}) []map[string]string {
+	keys := []string{}
+	for key, value := range matrix {
+		if _, ok := value.([]interface{}); ok && key != "include" && key != "exclude" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	combinations := []map[string]string{{}}
+	for _, key := range keys {
+		expanded := []map[string]string{}
+		for _, c := range combinations {
+			for _, value := range matrix[key].([]interface{}) {
+				if !isScalar(value) {
+					continue
+				}
+				next := copyMap(c)
+				next[key] = fmt.Sprint(value)
+				expanded = append(expanded, next)
+			}
+		}
+		combinations = expanded
+	}
+	if len(keys) == 0 {
+		combinations = nil
+	}
+
+	for _, exclude := range matrixEntries(matrix["exclude"]) {
+		kept := []map[string]string{}
+		for _, c := range combinations {
+			if !matches(c, exclude) {
+				kept = append(kept, c)
+			}
+		}
+		combinations = kept
+	}
+
+	isKey := map[string]bool{}
+	for _, key := range keys {
+		isKey[key] = true
+	}
+	extensible := len(combinations)
+	for _, include := range matrixEntries(matrix["include"]) {
+		// an entry extends the combinations with the same values of the lists, without changing those values
+		original := map[string]string{}
+		for key, value := range include {
+			if isKey[key] {
+				original[key] = value
+			}
+		}
+		extended := false
+		for _, c := range combinations[:extensible] {
+			if !matches(c, original) {
+				continue
+			}
+			for key, value := range include {
+				if !isKey[key] {
+					c[key] = value
+				}
+			}
+			extended = true
+		}
+		if !extended {
+			combinations = append(combinations, copyMap(include))
+		}
+	}
+
+	if len(combinations) == 0 {
+		return []map[string]string{{}}
+	}
+	return combinations
+}
+
+// matrixEntries returns the scalar values of the entries of a matrix's "include" or "exclude"
+func matrixEntries(value interface{}) []map[string]string {
+	list, _ := value.([]interface{})
+	entries := []map[string]string{}
+	for _, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		entry := map[string]string{}
+		for key, v := range m {
+			if isScalar(v) {
+				entry[key] = fmt.Sprint(v)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// substitute replaces the references to the values of the matrix in s.
+// References to values that aren't known are kept as written.
+func substitute(s string, values map[string]string) string {
+	return matrixRefRegex.ReplaceAllStringFunc(s, func(ref string) string {
+		if value, ok := values[matrixRefRegex.FindStringSubmatch(ref)[1]]; ok {
+			return value
+		}
+		return ref
+	})
+}
+
+// matches returns true if c has all the values of subset
+func matches(c, subset map[string]string) bool {
+	for key, value := range subset {
+		if c[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case string, int, int64, float64, bool:
+		return true
+	}
+	return false
+}
+
+func copyMap(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
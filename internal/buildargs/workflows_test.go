@@ -0,0 +1,141 @@
+package buildargs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testWorkflow = `
+name: images
+on: push
+jobs:
+  build:
+    strategy:
+      matrix:
+        node: [20, 22]
+        env: [production, staging]
+        exclude:
+          - node: 20
+            env: staging
+        include:
+          - env: production
+            tag: latest
+    steps:
+      - uses: actions/checkout@v4
+      - uses: docker/build-push-action@v6
+        with:
+          context: .
+          file: services/api/Dockerfile
+          build-args: |
+            NODE_VERSION=${{ matrix.node }}
+            TARGET_ENV=${{ matrix.env }}
+            NPM_TOKEN=${{ secrets.NPM_TOKEN }}
+  debug:
+    defaults:
+      run:
+        working-directory: services/api
+    steps:
+      - run: |
+          docker buildx build \
+            --build-arg TARGET_ENV=development \
+            --build-arg="DEBUG=1" \
+            -t api:debug --push .
+      - run: docker build -f Dockerfile.test --build-arg TARGET_ENV=test --build-arg TOKEN=${{ secrets.TOKEN }} . && docker push api:test
+`
+
+func TestFromWorkflow(t *testing.T) {
+	dir := t.TempDir()
+	workflows := filepath.Join(dir, ".github", "workflows")
+	if err := os.MkdirAll(workflows, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(workflows, "images.yml")
+	if err := os.WriteFile(path, []byte(testWorkflow), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	project := filepath.Join(dir, "services", "api")
+	if err := os.MkdirAll(project, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if found := FindWorkflows(project); !reflect.DeepEqual(found, []string{path}) {
+		t.Fatalf("FindWorkflows() = %v; want %v", found, []string{path})
+	}
+
+	combinations, err := FromWorkflow(path, filepath.Join(project, "Dockerfile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"images.yml:build (env=production node=20 tag=latest)": "NODE_VERSION=20 TARGET_ENV=production",
+		"images.yml:build (env=production node=22 tag=latest)": "NODE_VERSION=22 TARGET_ENV=production",
+		"images.yml:build (env=staging node=22)":               "NODE_VERSION=22 TARGET_ENV=staging",
+		"images.yml:debug":                                     "DEBUG=1 TARGET_ENV=development",
+	}
+	got := map[string]string{}
+	for _, c := range combinations {
+		got[c.Name] = c.String()
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("FromWorkflow() = %v; want %v", got, expected)
+	}
+
+	combinations, err = FromWorkflow(path, filepath.Join(project, "Dockerfile.test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(combinations) != 1 || combinations[0].String() != "TARGET_ENV=test" {
+		t.Errorf("FromWorkflow(Dockerfile.test) = %v", combinations)
+	}
+}
+
+func TestExpandMatrix(t *testing.T) {
+	tests := []struct {
+		name     string
+		matrix   map[string]interface{}
+		expected []map[string]string
+	}{
+		{
+			name:     "no matrix",
+			expected: []map[string]string{{}},
+		},
+		{
+			name: "product",
+			matrix: map[string]interface{}{
+				"os":   []interface{}{"linux"},
+				"node": []interface{}{20, 22},
+			},
+			expected: []map[string]string{{"node": "20", "os": "linux"}, {"node": "22", "os": "linux"}},
+		},
+		{
+			name: "include adds a combination",
+			matrix: map[string]interface{}{
+				"node":    []interface{}{20},
+				"include": []interface{}{map[string]interface{}{"node": 18, "legacy": true}},
+			},
+			expected: []map[string]string{{"node": "20"}, {"node": "18", "legacy": "true"}},
+		},
+		{
+			name: "only include",
+			matrix: map[string]interface{}{
+				"include": []interface{}{map[string]interface{}{"env": "prod"}, map[string]interface{}{"env": "dev"}},
+			},
+			expected: []map[string]string{{"env": "prod"}, {"env": "dev"}},
+		},
+		{
+			name: "expression",
+			matrix: map[string]interface{}{
+				"node": "${{ fromJSON(needs.setup.outputs.versions) }}",
+			},
+			expected: []map[string]string{{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandMatrix(tt.matrix); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expandMatrix() = %v; want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package buildcontext
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// bakeFileNames are the JSON bake files looked up by FindBake, in the order buildx reads them.
+// HCL bake files can be converted with "docker buildx bake --print > docker-bake.json".
+var bakeFileNames = []string{"docker-bake.json", "docker-bake.override.json"}
+
+// bakeFile is a JSON bake file. Only the parts dockershrink needs are parsed.
+type bakeFile struct {
+	Target map[string]*bakeTarget `json:"target"`
+}
+
+type bakeTarget struct {
+	Context    string            `json:"context"`
+	Dockerfile string            `json:"dockerfile"`
+	Contexts   map[string]string `json:"contexts"`
+	Args       map[string]string `json:"args"`
+}
+
+// BakeTarget is a target of a bake file that builds a given Dockerfile
+type BakeTarget struct {
+	Name string
+	// Contexts are the named build contexts, keyed by name. Relative paths are relative to Dir.
+	Contexts map[string]string
+	// Args are the build arguments, keyed by name
+	Args map[string]string
+	// Dir is the directory of the bake file
+	Dir string
+}
+
+// FindBake returns the paths of the JSON bake files in dir
+func FindBake(dir string) []string {
+	paths := []string{}
+	for _, name := range bakeFileNames {
+		p := filepath.Join(dir, name)
+		if _, err := os.Stat(p); err == nil {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// BakeTargets returns the targets of the bake file at path that build the Dockerfile, sorted by name
+func BakeTargets(path, dockerfilePath string) ([]*BakeTarget, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &bakeFile{}
+	if err := json.Unmarshal(content, f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	target, err := filepath.Abs(dockerfilePath)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(f.Target))
+	for name := range f.Target {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	targets := []*BakeTarget{}
+	for _, name := range names {
+		t := f.Target[name]
+		if t == nil {
+			continue
+		}
+		context := t.Context
+		if context == "" {
+			context = "."
+		}
+		if !filepath.IsAbs(context) {
+			context = filepath.Join(dir, context)
+		}
+		dockerfile := t.Dockerfile
+		if dockerfile == "" {
+			dockerfile = "Dockerfile"
+		}
+		if !filepath.IsAbs(dockerfile) {
+			dockerfile = filepath.Join(context, dockerfile)
+		}
+		if filepath.Clean(dockerfile) != target {
+			continue
+		}
+		targets = append(targets, &BakeTarget{Name: name, Contexts: t.Contexts, Args: t.Args, Dir: dir})
+	}
+	return targets, nil
+}
+
+// FromBake returns the contexts of the bake target built from the Dockerfile. Relative paths are resolved
+// against the directory of the bake file. If several targets build the Dockerfile, the first one by name is used.
+func FromBake(path, dockerfilePath string) ([]*Context, error) {
+	targets, err := BakeTargets(path, dockerfilePath)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range targets {
+		if len(t.Contexts) == 0 {
+			continue
+		}
+		contexts, err := FromMap(t.Contexts, t.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("target %s in %s: %w", t.Name, path, err)
+		}
+		return contexts, nil
+	}
+	return nil, nil
+}
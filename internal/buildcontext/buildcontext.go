@@ -0,0 +1,119 @@
+// Package buildcontext resolves the named build contexts a Dockerfile is built with, eg- "--build-context lib=../lib".
+// Stages refer to them by name in "COPY --from=lib", "RUN --mount=from=lib" and "FROM lib". Contexts are declared on the
+// command line, in the build section of a compose service or in a bake target.
+package buildcontext
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/compose"
+)
+
+// Context is a named build context
+type Context struct {
+	Name string
+	// Source is the value the context was declared with, eg- "../lib", "docker-image://node:22-alpine" or "target:base"
+	Source string
+	// Dir is the absolute path of the directory of a local context. It's empty for contexts that aren't
+	// local directories, whose files can't be inspected.
+	Dir string
+}
+
+// remotePrefixes are the prefixes of sources that aren't local directories
+var remotePrefixes = []string{"docker-image://", "oci-layout://", "target:", "input:", "http://", "https://", "git://", "git@", "ssh://"}
+
+// New returns the context declared with the given source. Relative paths of local contexts are resolved against baseDir.
+func New(name, source, baseDir string) (*Context, error) {
+	if name == "" {
+		return nil, fmt.Errorf("build context %q has no name", source)
+	}
+	if source == "" {
+		return nil, fmt.Errorf("build context %q has no value", name)
+	}
+	c := &Context{Name: name, Source: source}
+	for _, prefix := range remotePrefixes {
+		if strings.HasPrefix(source, prefix) {
+			return c, nil
+		}
+	}
+	dir := source
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(baseDir, dir)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve build context %s: %w", name, err)
+	}
+	c.Dir = abs
+	return c, nil
+}
+
+// Parse parses a context declared as NAME=VALUE, the format of docker build's --build-context flag
+func Parse(declaration, baseDir string) (*Context, error) {
+	name, source, found := strings.Cut(declaration, "=")
+	if !found {
+		return nil, fmt.Errorf("invalid build context %q, must be NAME=VALUE", declaration)
+	}
+	return New(strings.TrimSpace(name), strings.TrimSpace(source), baseDir)
+}
+
+// IsLocal returns true if the context is a directory on this machine
+func (c *Context) IsLocal() bool {
+	return c.Dir != ""
+}
+
+func (c *Context) String() string {
+	return c.Name + "=" + c.Source
+}
+
+// FromMap returns the contexts of a map of names to sources, sorted by name
+func FromMap(sources map[string]string, baseDir string) ([]*Context, error) {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	contexts := make([]*Context, 0, len(names))
+	for _, name := range names {
+		c, err := New(name, sources[name], baseDir)
+		if err != nil {
+			return nil, err
+		}
+		contexts = append(contexts, c)
+	}
+	return contexts, nil
+}
+
+// FromCompose returns the additional contexts of the compose service built from the Dockerfile.
+// Relative paths are resolved against the directory of the compose file, like docker compose does.
+func FromCompose(f *compose.File, dockerfilePath string) ([]*Context, error) {
+	s := f.ServiceForDockerfile(dockerfilePath)
+	if s == nil || s.Build == nil {
+		return nil, nil
+	}
+	contexts, err := FromMap(s.Build.AdditionalContexts, filepath.Dir(f.Path))
+	if err != nil {
+		return nil, fmt.Errorf("service %s in %s: %w", s.Name, f.Path, err)
+	}
+	return contexts, nil
+}
+
+// Merge combines lists of contexts. Contexts in later lists override those of the same name in earlier ones.
+// The result is sorted by name.
+func Merge(lists ...[]*Context) []*Context {
+	byName := map[string]*Context{}
+	for _, list := range lists {
+		for _, c := range list {
+			byName[c.Name] = c
+		}
+	}
+	merged := make([]*Context, 0, len(byName))
+	for _, c := range byName {
+		merged = append(merged, c)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name < merged[j].Name })
+	return merged
+}
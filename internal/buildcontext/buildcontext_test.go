@@ -0,0 +1,121 @@
+package buildcontext
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/compose"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		declaration string
+		name        string
+		source      string
+		dir         string
+		wantErr     bool
+	}{
+		{declaration: "lib=../lib", name: "lib", source: "../lib", dir: "/work/lib"},
+		{declaration: "shared=/opt/shared", name: "shared", source: "/opt/shared", dir: "/opt/shared"},
+		{declaration: "node=docker-image://node:22-alpine", name: "node", source: "docker-image://node:22-alpine"},
+		{declaration: "base=target:base", name: "base", source: "target:base"},
+		{declaration: "repo=https://github.com/org/repo.git", name: "repo", source: "https://github.com/org/repo.git"},
+		{declaration: "lib", wantErr: true},
+		{declaration: "=../lib", wantErr: true},
+		{declaration: "lib=", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.declaration, func(t *testing.T) {
+			c, err := Parse(tt.declaration, "/work/app")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if c.Name != tt.name || c.Source != tt.source || c.Dir != tt.dir {
+				t.Errorf("Parse() = %+v; want name %q, source %q, dir %q", c, tt.name, tt.source, tt.dir)
+			}
+			if c.IsLocal() != (tt.dir != "") {
+				t.Errorf("IsLocal() = %v", c.IsLocal())
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	composeLib, _ := New("lib", "../lib", "/work")
+	composeBase, _ := New("base", "docker-image://node:22", "/work")
+	flagLib, _ := New("lib", "../lib-v2", "/work")
+	merged := Merge([]*Context{composeLib, composeBase}, []*Context{flagLib})
+	if len(merged) != 2 || merged[0] != composeBase || merged[1] != flagLib {
+		t.Errorf("Merge() = %v", merged)
+	}
+}
+
+func TestFromCompose(t *testing.T) {
+	dir := t.TempDir()
+	f, err := compose.Parse(filepath.Join(dir, "compose.yaml"), []byte(`
+services:
+  api:
+    build:
+      context: ./api
+      additional_contexts:
+        lib: ./lib
+  web:
+    build: ./web
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contexts, err := FromCompose(f, filepath.Join(dir, "api", "Dockerfile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contexts) != 1 || contexts[0].Name != "lib" || contexts[0].Dir != filepath.Join(dir, "lib") {
+		t.Errorf("FromCompose(api) = %v", contexts)
+	}
+	if contexts, _ := FromCompose(f, filepath.Join(dir, "web", "Dockerfile")); len(contexts) != 0 {
+		t.Errorf("FromCompose(web) = %v; want no contexts", contexts)
+	}
+}
+
+func TestFromBake(t *testing.T) {
+	dir := t.TempDir()
+	bake := `{
+  "target": {
+    "api": {
+      "context": "services/api",
+      "dockerfile": "Dockerfile.prod",
+      "contexts": {"lib": "libs/common", "alpine": "docker-image://alpine:3.20"}
+    },
+    "web": {"context": "services/web"}
+  }
+}`
+	if err := os.WriteFile(filepath.Join(dir, "docker-bake.json"), []byte(bake), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	paths := FindBake(dir)
+	if len(paths) != 1 {
+		t.Fatalf("FindBake() = %v", paths)
+	}
+
+	contexts, err := FromBake(paths[0], filepath.Join(dir, "services", "api", "Dockerfile.prod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contexts) != 2 {
+		t.Fatalf("FromBake() returned %d contexts; want 2", len(contexts))
+	}
+	if contexts[0].Name != "alpine" || contexts[0].IsLocal() {
+		t.Errorf("contexts[0] = %+v", contexts[0])
+	}
+	if contexts[1].Name != "lib" || contexts[1].Dir != filepath.Join(dir, "libs", "common") {
+		t.Errorf("contexts[1] = %+v", contexts[1])
+	}
+
+	if contexts, _ := FromBake(paths[0], filepath.Join(dir, "services", "web", "Dockerfile")); len(contexts) != 0 {
+		t.Errorf("FromBake(web) = %v; want no contexts", contexts)
+	}
+}
@@ -0,0 +1,337 @@
+// Package bundle packs the outcome of a dockershrink run into a single portable archive, so that a project
+// can be analyzed in a restricted environment, eg- a locked-down CI runner, and the results reviewed and
+// applied on a developer's machine.
+//
+// A bundle is a gzipped tar archive. Its manifest records the SHA-256 of every other entry and can be signed
+// with an ed25519 key, so that tampering with any part of the bundle is detected when it's imported.
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/diff"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/sinks"
+)
+
+// FormatVersion is the version of the bundle format written by this version of dockershrink
+const FormatVersion = 1
+
+// Names of the entries of the archive
+const (
+	ManifestFile  = "manifest.json"
+	SignatureFile = "manifest.sig"
+	FindingsFile  = "findings.json"
+	ReportFile    = "report.json"
+	ChangesFile   = "changes.json"
+	PatchFile     = "changes.patch"
+)
+
+// maxEntrySize is the size of the biggest entry read from a bundle, larger ones are refused
+const maxEntrySize = 32 << 20
+
+// ErrSignature is returned when a bundle's signature is missing or doesn't match the manifest
+var ErrSignature = errors.New("invalid bundle signature")
+
+// Manifest describes a bundle and records the checksum of every entry
+type Manifest struct {
+	FormatVersion       int       `json:"format_version"`
+	DockershrinkVersion string    `json:"dockershrink_version"`
+	CreatedAt           time.Time `json:"created_at"`
+	// RunID is the optimization run the changes come from, empty if the bundle has no changes
+	RunID string `json:"run_id,omitempty"`
+	// Files maps the name of every other entry to its hex-encoded SHA-256
+	Files map[string]string `json:"files"`
+}
+
+// Change is a file of the project modified by the optimization. Path is slash-separated and relative to the project.
+// An empty Original means that the file doesn't exist yet.
+type Change struct {
+	Path      string `json:"path"`
+	Original  string `json:"original"`
+	Optimized string `json:"optimized"`
+}
+
+// Bundle is the content of a bundle
+type Bundle struct {
+	Manifest *Manifest
+	// Report is the report of the run, including the findings and the actions taken
+	Report  *sinks.Report
+	Changes []*Change
+	// Signed is true if the signature of the bundle was verified when reading it
+	Signed bool
+}
+
+// Findings returns the findings of the bundle
+func (b *Bundle) Findings() []*models.Finding {
+	return b.Report.Findings
+}
+
+// Patch returns the changes as a patch that can be applied with "git apply"
+func (b *Bundle) Patch() string {
+	var sb strings.Builder
+	for _, c := range b.Changes {
+		sb.WriteString(diff.GitPatch(c.Path, c.Original, c.Optimized))
+	}
+	return sb.String()
+}
+
+// Write writes the bundle to w as a gzipped tar archive. The manifest's checksums are computed,
+// and the manifest is signed if key isn't nil.
+func Write(w io.Writer, b *Bundle, key ed25519.PrivateKey) error {
+	findings, err := json.MarshalIndent(b.Report.Findings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize findings: %w", err)
+	}
+	report, err := json.MarshalIndent(b.Report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize report: %w", err)
+	}
+	changes, err := json.MarshalIndent(b.Changes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize changes: %w", err)
+	}
+	entries := map[string][]byte{
+		FindingsFile: findings,
+		ReportFile:   report,
+		ChangesFile:  changes,
+		PatchFile:    []byte(b.Patch()),
+	}
+
+	b.Manifest.FormatVersion = FormatVersion
+	b.Manifest.Files = map[string]string{}
+	for name, content := range entries {
+		b.Manifest.Files[name] = checksum(content)
+	}
+	manifest, err := json.MarshalIndent(b.Manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize manifest: %w", err)
+	}
+	entries[ManifestFile] = manifest
+	if key != nil {
+		entries[SignatureFile] = []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, manifest)) + "\n")
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	// the manifest comes first so that it can be inspected with "tar -tzf" without reading everything
+	sort.Slice(names, func(i, j int) bool {
+		if names[i] == ManifestFile || names[j] == ManifestFile {
+			return names[i] == ManifestFile
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(entries[name])), ModTime: b.Manifest.CreatedAt}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if _, err := tw.Write(entries[name]); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Read reads a bundle written by Write and verifies the checksums of its entries.
+// If key isn't nil, the bundle must be signed with the matching private key, otherwise ErrSignature is returned.
+// Without a key, the signature isn't verified and Signed is false.
+func Read(r io.Reader, key ed25519.PublicKey) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a dockershrink bundle: %w", err)
+	}
+	defer gz.Close()
+
+	entries := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("unexpected entry %s in bundle", hdr.Name)
+		}
+		if hdr.Size > maxEntrySize {
+			return nil, fmt.Errorf("entry %s of bundle is too large (%d bytes)", hdr.Name, hdr.Size)
+		}
+		content, err := io.ReadAll(io.LimitReader(tr, maxEntrySize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+		}
+		entries[hdr.Name] = content
+	}
+
+	manifestContent, ok := entries[ManifestFile]
+	if !ok {
+		return nil, fmt.Errorf("bundle has no %s", ManifestFile)
+	}
+	b := &Bundle{Manifest: &Manifest{}}
+	if err := json.Unmarshal(manifestContent, b.Manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ManifestFile, err)
+	}
+	if b.Manifest.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("bundle format version %d is not supported, upgrade dockershrink to import it", b.Manifest.FormatVersion)
+	}
+
+	if key != nil {
+		signature, ok := entries[SignatureFile]
+		if !ok {
+			return nil, fmt.Errorf("%w: the bundle isn't signed", ErrSignature)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+		if err != nil || !ed25519.Verify(key, manifestContent, decoded) {
+			return nil, fmt.Errorf("%w: the manifest wasn't signed with the given key or was modified", ErrSignature)
+		}
+		b.Signed = true
+	}
+
+	for name := range entries {
+		if name == ManifestFile || name == SignatureFile {
+			continue
+		}
+		if _, ok := b.Manifest.Files[name]; !ok {
+			return nil, fmt.Errorf("entry %s of bundle isn't listed in the manifest", name)
+		}
+	}
+	for name, sum := range b.Manifest.Files {
+		content, ok := entries[name]
+		if !ok {
+			return nil, fmt.Errorf("entry %s listed in the manifest is missing from the bundle", name)
+		}
+		if checksum(content) != sum {
+			return nil, fmt.Errorf("checksum of %s doesn't match the manifest, the bundle was modified", name)
+		}
+	}
+
+	b.Report = &sinks.Report{}
+	if err := json.Unmarshal(entries[ReportFile], b.Report); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ReportFile, err)
+	}
+	if err := json.Unmarshal(entries[ChangesFile], &b.Changes); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ChangesFile, err)
+	}
+	for _, c := range b.Changes {
+		if !filepath.IsLocal(filepath.FromSlash(c.Path)) {
+			return nil, fmt.Errorf("change to %s is outside of the project", c.Path)
+		}
+	}
+	return b, nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Conflicts returns the paths of the changes whose files in dir don't match the original the changes were made to,
+// eg- because the project was modified after it was exported. Changes can only be applied without conflicts.
+func (b *Bundle) Conflicts(dir string) ([]string, error) {
+	conflicts := []string{}
+	for _, c := range b.Changes {
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(c.Path)))
+		if errors.Is(err, os.ErrNotExist) {
+			if c.Original != "" {
+				conflicts = append(conflicts, c.Path)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if string(content) != c.Original {
+			conflicts = append(conflicts, c.Path)
+		}
+	}
+	return conflicts, nil
+}
+
+// Apply writes the optimized files of the changes into the project in dir
+func (b *Bundle) Apply(dir string) error {
+	for _, c := range b.Changes {
+		path := filepath.Join(dir, filepath.FromSlash(c.Path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(c.Optimized), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", c.Path, err)
+		}
+	}
+	return nil
+}
+
+// LoadPrivateKey reads an ed25519 private key from a PEM file in the PKCS #8 format,
+// eg- one created with "openssl genpkey -algorithm ed25519"
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+	ed, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ed25519 private key", path)
+	}
+	return ed, nil
+}
+
+// LoadPublicKey reads an ed25519 public key from a PEM file in the PKIX format,
+// eg- one created with "openssl pkey -pubout"
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+	}
+	ed, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ed25519 public key", path)
+	}
+	return ed, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(bytes.TrimSpace(content))
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM file", path)
+	}
+	return block, nil
+}
@@ -0,0 +1,166 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/sinks"
+)
+
+func testBundle() *Bundle {
+	score := 72
+	return &Bundle{
+		Manifest: &Manifest{DockershrinkVersion: "test", CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), RunID: "20260102T030405.000Z"},
+		Report: &sinks.Report{
+			Command:        "export",
+			DockerfilePath: "Dockerfile",
+			Score:          &score,
+			Findings:       []*models.Finding{{Rule: "missing-dockerignore", Code: "DS001", Severity: models.SeverityMedium, Filepath: ".dockerignore"}},
+		},
+		Changes: []*Change{
+			{Path: "Dockerfile", Original: "FROM node:20\nCOPY . .\n", Optimized: "FROM node:20-alpine\nCOPY . .\n"},
+			{Path: ".dockerignore", Optimized: "node_modules\n"},
+		},
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+
+	var signed, unsigned bytes.Buffer
+	if err := Write(&signed, testBundle(), priv); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	if err := Write(&unsigned, testBundle(), nil); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	b, err := Read(bytes.NewReader(signed.Bytes()), pub)
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if !b.Signed || b.Manifest.RunID != "20260102T030405.000Z" || *b.Report.Score != 72 {
+		t.Errorf("unexpected bundle %+v", b.Manifest)
+	}
+	if !reflect.DeepEqual(b.Changes, testBundle().Changes) {
+		t.Errorf("Changes = %+v", b.Changes)
+	}
+	if len(b.Findings()) != 1 || b.Findings()[0].Code != "DS001" {
+		t.Errorf("Findings() = %v", b.Findings())
+	}
+	if !strings.Contains(b.Patch(), "+FROM node:20-alpine") {
+		t.Errorf("Patch() = %q", b.Patch())
+	}
+
+	if b, err := Read(bytes.NewReader(signed.Bytes()), nil); err != nil || b.Signed {
+		t.Errorf("expected a signed bundle to be read without verifying it, got %v", err)
+	}
+	if _, err := Read(bytes.NewReader(signed.Bytes()), otherPub); !errors.Is(err, ErrSignature) {
+		t.Errorf("expected a signature error with another key, got %v", err)
+	}
+	if _, err := Read(bytes.NewReader(unsigned.Bytes()), pub); !errors.Is(err, ErrSignature) {
+		t.Errorf("expected a signature error for an unsigned bundle, got %v", err)
+	}
+}
+
+func TestRead_Tampered(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testBundle(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// rewrite the archive with a modified changes.json
+	entries := map[string][]byte{}
+	gz, _ := gzip.NewReader(&buf)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		content, _ := io.ReadAll(tr)
+		entries[hdr.Name] = content
+	}
+	entries[ChangesFile] = bytes.Replace(entries[ChangesFile], []byte("node:20-alpine"), []byte("evil:latest"), 1)
+
+	var tampered bytes.Buffer
+	gzw := gzip.NewWriter(&tampered)
+	tw := tar.NewWriter(gzw)
+	for name, content := range entries {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))})
+		tw.Write(content)
+	}
+	tw.Close()
+	gzw.Close()
+
+	if _, err := Read(&tampered, nil); err == nil || !strings.Contains(err.Error(), ChangesFile) {
+		t.Errorf("expected a checksum error for %s, got %v", ChangesFile, err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	dir := t.TempDir()
+	b := testBundle()
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(b.Changes[0].Original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	conflicts, err := b.Conflicts(dir)
+	if err != nil || len(conflicts) != 0 {
+		t.Fatalf("Conflicts() = %v, %v; want none", conflicts, err)
+	}
+	if err := b.Apply(dir); err != nil {
+		t.Fatalf("Apply() failed: %v", err)
+	}
+	for _, c := range b.Changes {
+		content, _ := os.ReadFile(filepath.Join(dir, c.Path))
+		if string(content) != c.Optimized {
+			t.Errorf("%s = %q; want %q", c.Path, content, c.Optimized)
+		}
+	}
+
+	// both files now differ from the originals
+	conflicts, _ = b.Conflicts(dir)
+	if !reflect.DeepEqual(conflicts, []string{"Dockerfile", ".dockerignore"}) {
+		t.Errorf("Conflicts() = %v", conflicts)
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	dir := t.TempDir()
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	privDER, _ := x509.MarshalPKCS8PrivateKey(priv)
+	pubDER, _ := x509.MarshalPKIXPublicKey(pub)
+	privPath, pubPath := filepath.Join(dir, "key.pem"), filepath.Join(dir, "key.pub.pem")
+	os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0o600)
+	os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0o644)
+
+	loadedPriv, err := LoadPrivateKey(privPath)
+	if err != nil || !loadedPriv.Equal(priv) {
+		t.Errorf("LoadPrivateKey() = %v", err)
+	}
+	loadedPub, err := LoadPublicKey(pubPath)
+	if err != nil || !loadedPub.Equal(pub) {
+		t.Errorf("LoadPublicKey() = %v", err)
+	}
+	if _, err := LoadPublicKey(privPath); err == nil {
+		t.Errorf("expected an error loading a private key as a public key")
+	}
+}
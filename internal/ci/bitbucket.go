@@ -0,0 +1,209 @@
+package ci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/baseline"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+	"github.com/duaraghav8/dockershrink/internal/sinks"
+)
+
+const (
+	// InsightsReportID identifies the Code Insights report of dockershrink on a commit, uploading it again replaces it
+	InsightsReportID = "dockershrink"
+
+	defaultBitbucketAPIURL = "https://api.bitbucket.org"
+	// bitbucketPipelinesProxy authenticates requests to the Bitbucket API made from Bitbucket Pipelines, so that no token is needed there.
+	// Requests must be made over http for the proxy to add credentials.
+	bitbucketPipelinesProxy  = "http://localhost:29418"
+	bitbucketPipelinesAPIURL = "http://api.bitbucket.org"
+
+	// max number of annotations Bitbucket keeps for a report, and accepts in a single request
+	maxInsightsAnnotations = 1000
+	annotationsPerRequest  = 100
+)
+
+// Insights is a Bitbucket Code Insights report along with its annotations.
+// See https://developer.atlassian.com/cloud/bitbucket/rest/api-group-reports/
+type Insights struct {
+	Report      *InsightsReport       `json:"report"`
+	Annotations []*InsightsAnnotation `json:"annotations"`
+}
+
+type InsightsReport struct {
+	Title      string          `json:"title"`
+	Details    string          `json:"details"`
+	ReportType string          `json:"report_type"`
+	Reporter   string          `json:"reporter"`
+	Result     string          `json:"result"`
+	Data       []*InsightsData `json:"data"`
+}
+
+type InsightsData struct {
+	Title string `json:"title"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+type InsightsAnnotation struct {
+	ExternalID     string `json:"external_id"`
+	AnnotationType string `json:"annotation_type"`
+	Summary        string `json:"summary"`
+	Details        string `json:"details,omitempty"`
+	Path           string `json:"path"`
+	Line           int    `json:"line,omitempty"`
+	Severity       string `json:"severity"`
+}
+
+var insightsSeverities = map[models.Severity]string{
+	models.SeverityHigh:   "HIGH",
+	models.SeverityMedium: "MEDIUM",
+	models.SeverityLow:    "LOW",
+	models.SeverityInfo:   "LOW",
+}
+
+// NewInsights returns the findings of the reports as a Code Insights report.
+// Like lint, the report fails if there are findings with a severity other than info.
+func NewInsights(reports []*sinks.Report) *Insights {
+	i := &Insights{
+		Report: &InsightsReport{
+			Title:      "dockershrink",
+			ReportType: "BUG",
+			Reporter:   "dockershrink",
+			Result:     "PASSED",
+		},
+		Annotations: []*InsightsAnnotation{},
+	}
+
+	fingerprints := baseline.NewFingerprinter()
+	findings := 0
+	var score *int
+	for _, r := range reports {
+		if r.Score != nil && (score == nil || *r.Score < *score) {
+			score = r.Score
+		}
+		for _, f := range r.Findings {
+			findings++
+			if f.Severity != models.SeverityInfo {
+				i.Report.Result = "FAILED"
+			}
+			if len(i.Annotations) == maxInsightsAnnotations {
+				continue
+			}
+			i.Annotations = append(i.Annotations, &InsightsAnnotation{
+				ExternalID:     fingerprints.Next(f),
+				AnnotationType: annotationType(f),
+				Summary:        fmt.Sprintf("%s: %s", checkName(f), f.Title),
+				Details:        f.Description,
+				Path:           filepath.ToSlash(filepath.Clean(f.Filepath)),
+				Line:           f.Line,
+				Severity:       insightsSeverities[f.Severity],
+			})
+		}
+	}
+
+	i.Report.Details = fmt.Sprintf("%d finding(s) in %d Dockerfile(s)", findings, len(reports))
+	if score != nil {
+		// the lowest score, so that a single bad Dockerfile of a monorepo isn't hidden by the others
+		i.Report.Data = append(i.Report.Data, &InsightsData{Title: "Score", Type: "NUMBER", Value: *score})
+	}
+	i.Report.Data = append(i.Report.Data, &InsightsData{Title: "Findings", Type: "NUMBER", Value: findings})
+	return i
+}
+
+// annotationType returns VULNERABILITY for the findings of security rules, CODE_SMELL otherwise
+func annotationType(f *models.Finding) string {
+	for _, r := range rules.All {
+		if (r.ID == f.Code || r.Name == f.Rule) && slices.Contains(r.Goals, models.GoalSecurity) {
+			return "VULNERABILITY"
+		}
+	}
+	return "CODE_SMELL"
+}
+
+// WriteInsights writes the findings of the reports to w as a Code Insights report with its annotations
+func WriteInsights(w io.Writer, reports []*sinks.Report) error {
+	content, err := json.MarshalIndent(NewInsights(reports), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(content))
+	return err
+}
+
+// Bitbucket talks to the Bitbucket Cloud REST API on behalf of a repository
+type Bitbucket struct {
+	apiURL     string
+	repository string
+	token      string
+	http       *http.Client
+}
+
+// NewBitbucket returns a client of the repository, given as workspace/slug.
+// Without a token, requests go through the authenticating proxy of Bitbucket Pipelines.
+func NewBitbucket(apiURL, repository, token string) *Bitbucket {
+	client := &http.Client{Timeout: 30 * time.Second}
+	if token == "" {
+		proxy, _ := url.Parse(bitbucketPipelinesProxy)
+		client.Transport = &http.Transport{Proxy: http.ProxyURL(proxy)}
+		if apiURL == "" {
+			apiURL = bitbucketPipelinesAPIURL
+		}
+	}
+	if apiURL == "" {
+		apiURL = defaultBitbucketAPIURL
+	}
+	return &Bitbucket{apiURL: strings.TrimSuffix(apiURL, "/"), repository: repository, token: token, http: client}
+}
+
+// UploadInsights creates or replaces the report of dockershrink on the commit, along with its annotations
+func (b *Bitbucket) UploadInsights(ctx context.Context, commit string, i *Insights) error {
+	reportURL := fmt.Sprintf("%s/2.0/repositories/%s/commit/%s/reports/%s", b.apiURL, b.repository, commit, InsightsReportID)
+	if err := b.do(ctx, http.MethodPut, reportURL, i.Report); err != nil {
+		return err
+	}
+	for start := 0; start < len(i.Annotations); start += annotationsPerRequest {
+		end := min(start+annotationsPerRequest, len(i.Annotations))
+		if err := b.do(ctx, http.MethodPost, reportURL+"/annotations", i.Annotations[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Bitbucket) do(ctx context.Context, method, url string, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to serialize request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	}
+
+	resp, err := b.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Bitbucket responded to %s %s with status %s: %s", method, req.URL.Path, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package ci
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/sinks"
+)
+
+func TestNewInsights(t *testing.T) {
+	low, high := 40, 90
+	tests := []struct {
+		name     string
+		reports  []*sinks.Report
+		result   string
+		score    any
+		findings int
+	}{
+		{
+			name:     "no findings",
+			reports:  []*sinks.Report{{DockerfilePath: "Dockerfile", Score: &high}},
+			result:   "PASSED",
+			score:    high,
+			findings: 0,
+		},
+		{
+			name: "info findings only",
+			reports: []*sinks.Report{{DockerfilePath: "Dockerfile", Score: &high, Findings: []*models.Finding{
+				{Code: "DS020", Severity: models.SeverityInfo, Filepath: "Dockerfile", Title: "info"},
+			}}},
+			result:   "PASSED",
+			score:    high,
+			findings: 1,
+		},
+		{
+			name: "lowest score of a monorepo",
+			reports: []*sinks.Report{
+				{DockerfilePath: "web/Dockerfile", Score: &high},
+				{DockerfilePath: "api/Dockerfile", Score: &low, Findings: []*models.Finding{
+					{Code: "DS001", Severity: models.SeverityMedium, Filepath: "api/Dockerfile", Title: "No .dockerignore"},
+				}},
+			},
+			result:   "FAILED",
+			score:    low,
+			findings: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := NewInsights(tt.reports)
+			if i.Report.Result != tt.result {
+				t.Errorf("expected result %s, got %s", tt.result, i.Report.Result)
+			}
+			if i.Report.Data[0].Title != "Score" || i.Report.Data[0].Value != tt.score {
+				t.Errorf("expected score %v, got %+v", tt.score, i.Report.Data[0])
+			}
+			if len(i.Annotations) != tt.findings {
+				t.Errorf("expected %d annotations, got %d", tt.findings, len(i.Annotations))
+			}
+		})
+	}
+}
+
+func TestBitbucket_UploadInsights(t *testing.T) {
+	var findings []*models.Finding
+	for n := 0; n < 150; n++ {
+		findings = append(findings, &models.Finding{Code: "DS014", Severity: models.SeverityLow, Filepath: "Dockerfile", Line: n + 1, Title: "npm cache is kept"})
+	}
+	insights := NewInsights([]*sinks.Report{{DockerfilePath: "Dockerfile", Findings: findings}})
+
+	var reports, annotations int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		const reportPath = "/2.0/repositories/acme/app/commit/abc123/reports/" + InsightsReportID
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == reportPath:
+			reports++
+		case r.Method == http.MethodPost && r.URL.Path == reportPath+"/annotations":
+			var batch []*InsightsAnnotation
+			json.NewDecoder(r.Body).Decode(&batch)
+			if len(batch) > annotationsPerRequest {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			annotations += len(batch)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	if err := NewBitbucket(server.URL, "acme/app", "secret").UploadInsights(context.Background(), "abc123", insights); err != nil {
+		t.Fatalf("UploadInsights() failed: %v", err)
+	}
+	if reports != 1 || annotations != 150 {
+		t.Errorf("expected 1 report and 150 annotations, got %d and %d", reports, annotations)
+	}
+	if err := NewBitbucket(server.URL, "acme/app", "wrong").UploadInsights(context.Background(), "abc123", insights); err == nil {
+		t.Errorf("expected an error with an invalid token")
+	}
+}
@@ -0,0 +1,198 @@
+// Package ci integrates dockershrink with CI systems, eg- by commenting its report on the pull request being built.
+package ci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultGitHubAPIURL = "https://api.github.com"
+	// maxCommentLength is the max number of characters GitHub accepts in the body of a comment
+	maxCommentLength = 65536
+	// commentsPerPage is the max number of comments GitHub lists per page
+	commentsPerPage = 100
+)
+
+// truncatedNote ends a comment whose report was too long for GitHub
+const truncatedNote = "\n\n_The report was too long for a comment and was truncated, the full report is in the CI logs._\n"
+
+// Comment is a comment on a pull request
+type Comment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+	URL  string `json:"html_url"`
+}
+
+// GitHub talks to the GitHub REST API on behalf of a repository
+type GitHub struct {
+	apiURL     string
+	repository string
+	token      string
+	http       *http.Client
+}
+
+// NewGitHub returns a client of the repository, given as owner/name.
+// If apiURL is empty, GITHUB_API_URL is used, which is set in GitHub Actions and points to GitHub Enterprise Server when used there.
+func NewGitHub(apiURL, repository, token string) *GitHub {
+	if apiURL == "" {
+		apiURL = os.Getenv("GITHUB_API_URL")
+	}
+	if apiURL == "" {
+		apiURL = defaultGitHubAPIURL
+	}
+	return &GitHub{
+		apiURL:     strings.TrimSuffix(apiURL, "/"),
+		repository: repository,
+		token:      token,
+		http:       &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// marker returns the hidden line that identifies the sticky comment with the given tag
+func marker(tag string) string {
+	if tag == "" {
+		return "<!-- dockershrink -->"
+	}
+	return fmt.Sprintf("<!-- dockershrink:%s -->", tag)
+}
+
+// UpsertComment makes body the sticky comment of dockershrink on the pull request: the comment is created the first time,
+// and updated afterwards so that the pull request always has a single, current report.
+// The tag tells sticky comments apart, eg- when every service of a monorepo is commented on separately.
+// It returns the comment and whether it was created.
+func (g *GitHub) UpsertComment(ctx context.Context, pr int, tag, body string) (*Comment, bool, error) {
+	m := marker(tag)
+	body = m + "\n" + truncate(body, maxCommentLength-len(m)-1)
+
+	existing, err := g.findComment(ctx, pr, m)
+	if err != nil {
+		return nil, false, err
+	}
+	c := &Comment{}
+	if existing != nil {
+		url := fmt.Sprintf("%s/repos/%s/issues/comments/%d", g.apiURL, g.repository, existing.ID)
+		err = g.do(ctx, http.MethodPatch, url, map[string]string{"body": body}, c)
+		return c, false, err
+	}
+	url := fmt.Sprintf("%s/repos/%s/issues/%d/comments", g.apiURL, g.repository, pr)
+	err = g.do(ctx, http.MethodPost, url, map[string]string{"body": body}, c)
+	return c, true, err
+}
+
+// findComment returns the first comment on the pull request that starts with the marker, nil if there's none
+func (g *GitHub) findComment(ctx context.Context, pr int, marker string) (*Comment, error) {
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/repos/%s/issues/%d/comments?per_page=%d&page=%d", g.apiURL, g.repository, pr, commentsPerPage, page)
+		var comments []*Comment
+		if err := g.do(ctx, http.MethodGet, url, nil, &comments); err != nil {
+			return nil, err
+		}
+		for _, c := range comments {
+			if strings.HasPrefix(c.Body, marker) {
+				return c, nil
+			}
+		}
+		if len(comments) < commentsPerPage {
+			return nil, nil
+		}
+	}
+}
+
+// do sends body as JSON, unless it's nil, and decodes the response into result
+func (g *GitHub) do(ctx context.Context, method, url string, body, result any) error {
+	var payload io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to serialize request: %w", err)
+		}
+		payload = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+g.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := g.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("GitHub responded to %s %s with status %s: %s", method, req.URL.Path, resp.Status, bytes.TrimSpace(msg))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to parse the response of GitHub: %w", err)
+	}
+	return nil
+}
+
+// truncate cuts s to at most n bytes at the end of a line, leaving room for a note saying so
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	const closeFence, closeDetails = "\n```", "\n</details>"
+	s = s[:n-len(truncatedNote)-len(closeFence)-len(closeDetails)]
+	if i := strings.LastIndex(s, "\n"); i > 0 {
+		s = s[:i]
+	}
+	// don't leave the diff of the report open, it would swallow the note
+	if strings.Count(s, "```")%2 == 1 {
+		s += closeFence
+	}
+	if strings.Contains(s, "<details>") && !strings.Contains(s, "</details>") {
+		s += closeDetails
+	}
+	return s + truncatedNote
+}
+
+// matches the ref GitHub Actions checks out for pull requests, eg- refs/pull/42/merge
+var pullRequestRef = regexp.MustCompile(`^refs/pull/(\d+)/`)
+
+// PullRequestFromEnv returns the number of the pull request GitHub Actions is running for, read from the event that triggered
+// the workflow or the checked out ref. It returns an error if the workflow wasn't triggered by a pull request.
+func PullRequestFromEnv() (int, error) {
+	if path := os.Getenv("GITHUB_EVENT_PATH"); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read the event of the workflow: %w", err)
+		}
+		event := struct {
+			Number      int `json:"number"`
+			PullRequest struct {
+				Number int `json:"number"`
+			} `json:"pull_request"`
+		}{}
+		if err := json.Unmarshal(content, &event); err != nil {
+			return 0, fmt.Errorf("failed to parse the event of the workflow: %w", err)
+		}
+		if event.PullRequest.Number > 0 {
+			return event.PullRequest.Number, nil
+		}
+		if event.Number > 0 {
+			return event.Number, nil
+		}
+	}
+	if m := pullRequestRef.FindStringSubmatch(os.Getenv("GITHUB_REF")); m != nil {
+		return strconv.Atoi(m[1])
+	}
+	return 0, errors.New("the pull request is unknown, this isn't a GitHub Actions workflow triggered by a pull request")
+}
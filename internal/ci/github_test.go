@@ -0,0 +1,143 @@
+package ci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeGitHub serves the comments of pull request 7 of acme/app
+type fakeGitHub struct {
+	comments []*Comment
+	nextID   int64
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	body := map[string]string{}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/repos/acme/app/issues/7/comments":
+		if r.URL.Query().Get("page") != "1" {
+			fmt.Fprint(w, "[]")
+			return
+		}
+		json.NewEncoder(w).Encode(f.comments)
+	case r.Method == http.MethodPost && r.URL.Path == "/repos/acme/app/issues/7/comments":
+		f.nextID++
+		c := &Comment{ID: f.nextID, Body: body["body"], URL: fmt.Sprintf("https://github.com/acme/app/pull/7#issuecomment-%d", f.nextID)}
+		f.comments = append(f.comments, c)
+		json.NewEncoder(w).Encode(c)
+	case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, "/repos/acme/app/issues/comments/"):
+		for _, c := range f.comments {
+			if r.URL.Path == fmt.Sprintf("/repos/acme/app/issues/comments/%d", c.ID) {
+				c.Body = body["body"]
+				json.NewEncoder(w).Encode(c)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestGitHub_UpsertComment(t *testing.T) {
+	fake := &fakeGitHub{comments: []*Comment{{ID: 100, Body: "LGTM"}}, nextID: 100}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	g := NewGitHub(server.URL, "acme/app", "secret")
+
+	steps := []struct {
+		tag     string
+		body    string
+		created bool
+	}{
+		{body: "first report", created: true},
+		{body: "second report", created: false},
+		{tag: "services/api", body: "api report", created: true},
+	}
+	for _, s := range steps {
+		c, created, err := g.UpsertComment(context.Background(), 7, s.tag, s.body)
+		if err != nil {
+			t.Fatalf("UpsertComment(%q) failed: %v", s.body, err)
+		}
+		if created != s.created {
+			t.Errorf("UpsertComment(%q): expected created to be %v", s.body, s.created)
+		}
+		if c.Body != marker(s.tag)+"\n"+s.body {
+			t.Errorf("unexpected comment body %q", c.Body)
+		}
+	}
+
+	if len(fake.comments) != 3 {
+		t.Fatalf("expected the comment to be updated instead of posted again, got %d comments", len(fake.comments))
+	}
+	if fake.comments[0].Body != "LGTM" || !strings.HasSuffix(fake.comments[1].Body, "second report") {
+		t.Errorf("unexpected comments %+v %+v", fake.comments[0], fake.comments[1])
+	}
+
+	if _, _, err := NewGitHub(server.URL, "acme/app", "wrong").UpsertComment(context.Background(), 7, "", "report"); err == nil {
+		t.Errorf("expected an error with an invalid token")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	report := "### dockershrink\n\n<details>\n<summary>Diff</summary>\n\n```diff\n" + strings.Repeat("+line\n", 1000) + "```\n\n</details>\n"
+	if got := truncate(report, len(report)); got != report {
+		t.Errorf("expected a short report to be left untouched")
+	}
+
+	got := truncate(report, 1000)
+	if len(got) > 1000 {
+		t.Errorf("expected at most 1000 bytes, got %d", len(got))
+	}
+	if !strings.HasSuffix(got, "\n```\n</details>"+truncatedNote) {
+		t.Errorf("expected the diff to be closed before the note, got %q", got[len(got)-200:])
+	}
+}
+
+func TestPullRequestFromEnv(t *testing.T) {
+	event := filepath.Join(t.TempDir(), "event.json")
+	if err := os.WriteFile(event, []byte(`{"action":"opened","number":12,"pull_request":{"number":12}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		eventPath string
+		ref       string
+		expected  int
+	}{
+		{name: "pull request event", eventPath: event, expected: 12},
+		{name: "pull request ref", ref: "refs/pull/34/merge", expected: 34},
+		{name: "branch", ref: "refs/heads/main"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GITHUB_EVENT_PATH", tt.eventPath)
+			t.Setenv("GITHUB_REF", tt.ref)
+			pr, err := PullRequestFromEnv()
+			if tt.expected == 0 {
+				if err == nil {
+					t.Errorf("expected an error, got pull request %d", pr)
+				}
+				return
+			}
+			if err != nil || pr != tt.expected {
+				t.Errorf("expected pull request %d, got %d (%v)", tt.expected, pr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package ci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/baseline"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/sinks"
+)
+
+// CodeQualityIssue is an entry of a GitLab Code Quality report, shown in merge requests and pipelines.
+// See https://docs.gitlab.com/ee/ci/testing/code_quality.html#code-quality-report-format
+type CodeQualityIssue struct {
+	Description string              `json:"description"`
+	CheckName   string              `json:"check_name"`
+	Fingerprint string              `json:"fingerprint"`
+	Severity    string              `json:"severity"`
+	Location    CodeQualityLocation `json:"location"`
+}
+
+type CodeQualityLocation struct {
+	Path  string           `json:"path"`
+	Lines CodeQualityLines `json:"lines"`
+}
+
+type CodeQualityLines struct {
+	Begin int `json:"begin"`
+}
+
+var codeQualitySeverities = map[models.Severity]string{
+	models.SeverityHigh:   "critical",
+	models.SeverityMedium: "major",
+	models.SeverityLow:    "minor",
+	models.SeverityInfo:   "info",
+}
+
+// CodeQuality returns the findings of the reports as the issues of a GitLab Code Quality report
+func CodeQuality(reports []*sinks.Report) []*CodeQualityIssue {
+	issues := []*CodeQualityIssue{}
+	fingerprints := baseline.NewFingerprinter()
+	for _, r := range reports {
+		for _, f := range r.Findings {
+			line := f.Line
+			if line == 0 {
+				// findings about a whole file are shown on its first line
+				line = 1
+			}
+			issues = append(issues, &CodeQualityIssue{
+				Description: f.Title,
+				CheckName:   checkName(f),
+				Fingerprint: fingerprints.Next(f),
+				Severity:    codeQualitySeverities[f.Severity],
+				Location:    CodeQualityLocation{Path: filepath.ToSlash(filepath.Clean(f.Filepath)), Lines: CodeQualityLines{Begin: line}},
+			})
+		}
+	}
+	return issues
+}
+
+// WriteCodeQuality writes the findings of the reports to w as a GitLab Code Quality report
+func WriteCodeQuality(w io.Writer, reports []*sinks.Report) error {
+	content, err := json.MarshalIndent(CodeQuality(reports), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(content))
+	return err
+}
+
+func checkName(f *models.Finding) string {
+	if f.Code != "" {
+		return f.Code
+	}
+	return f.Rule
+}
+
+const defaultGitLabAPIURL = "https://gitlab.com/api/v4"
+
+// GitLab talks to the GitLab REST API on behalf of a project
+type GitLab struct {
+	apiURL string
+	// project is the path of the project, eg- acme/backend/api
+	project string
+	token   string
+	http    *http.Client
+}
+
+// NewGitLab returns a client of the project, given as its path, eg- acme/backend/api.
+// If apiURL is empty, CI_API_V4_URL is used, which is set in GitLab CI and points to self-managed instances when used there.
+func NewGitLab(apiURL, project, token string) *GitLab {
+	if apiURL == "" {
+		apiURL = os.Getenv("CI_API_V4_URL")
+	}
+	if apiURL == "" {
+		apiURL = defaultGitLabAPIURL
+	}
+	return &GitLab{
+		apiURL:  strings.TrimSuffix(apiURL, "/"),
+		project: project,
+		token:   token,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// projectURL returns the URL of an endpoint of the project, eg- "/merge_requests"
+func (g *GitLab) projectURL(endpoint string) string {
+	return fmt.Sprintf("%s/projects/%s%s", g.apiURL, url.PathEscape(g.project), endpoint)
+}
+
+// do sends body as JSON, unless it's nil, and decodes the response into result
+func (g *GitLab) do(ctx context.Context, method, url string, body, result any) error {
+	var payload io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to serialize request: %w", err)
+		}
+		payload = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("PRIVATE-TOKEN", g.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := g.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("GitLab responded to %s %s with status %s: %s", method, req.URL.EscapedPath(), resp.Status, bytes.TrimSpace(msg))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to parse the response of GitLab: %w", err)
+	}
+	return nil
+}
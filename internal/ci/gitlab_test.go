@@ -0,0 +1,66 @@
+package ci
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/sinks"
+)
+
+func TestWriteCodeQuality(t *testing.T) {
+	reports := []*sinks.Report{{
+		DockerfilePath: "Dockerfile",
+		Findings: []*models.Finding{
+			{Code: "DS001", Rule: "missing-dockerignore", Severity: models.SeverityMedium, Filepath: "Dockerfile", Title: "No .dockerignore"},
+			{Code: "DS014", Rule: "npm-cache", Severity: models.SeverityLow, Filepath: "./Dockerfile", Line: 4, Title: "npm cache is kept"},
+			{Code: "DS014", Rule: "npm-cache", Severity: models.SeverityLow, Filepath: "Dockerfile", Line: 9, Title: "npm cache is kept"},
+		},
+	}}
+
+	var buf bytes.Buffer
+	if err := WriteCodeQuality(&buf, reports); err != nil {
+		t.Fatalf("WriteCodeQuality() failed: %v", err)
+	}
+	var issues []*CodeQualityIssue
+	if err := json.Unmarshal(buf.Bytes(), &issues); err != nil {
+		t.Fatalf("invalid report: %v", err)
+	}
+	if len(issues) != 3 {
+		t.Fatalf("expected 3 issues, got %d", len(issues))
+	}
+
+	expected := []struct {
+		check    string
+		severity string
+		path     string
+		line     int
+	}{
+		{"DS001", "major", "Dockerfile", 1},
+		{"DS014", "minor", "Dockerfile", 4},
+		{"DS014", "minor", "Dockerfile", 9},
+	}
+	for i, e := range expected {
+		got := issues[i]
+		if got.CheckName != e.check || got.Severity != e.severity || got.Location.Path != e.path || got.Location.Lines.Begin != e.line {
+			t.Errorf("issue %d: expected %+v, got %+v", i, e, got)
+		}
+	}
+	if issues[1].Fingerprint == issues[2].Fingerprint {
+		t.Errorf("expected identical findings to have distinct fingerprints")
+	}
+
+	// a finding keeps its fingerprint when it moves to another line
+	moved := CodeQuality([]*sinks.Report{{Findings: []*models.Finding{
+		{Code: "DS001", Rule: "missing-dockerignore", Severity: models.SeverityMedium, Filepath: "Dockerfile", Line: 3, Title: "No .dockerignore"},
+	}}})
+	if moved[0].Fingerprint != issues[0].Fingerprint {
+		t.Errorf("expected the fingerprint not to depend on the line")
+	}
+
+	buf.Reset()
+	if err := WriteCodeQuality(&buf, nil); err != nil || bytes.TrimSpace(buf.Bytes())[0] != '[' {
+		t.Errorf("expected an empty list without findings, got %q (%v)", buf.String(), err)
+	}
+}
@@ -0,0 +1,188 @@
+package ci
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+)
+
+// FileChange is the new content of a file committed by a pull request
+type FileChange struct {
+	// Path is relative to the root of the repository, eg- services/api/Dockerfile
+	Path    string
+	Content string
+	// New is set if the file doesn't exist on the base branch yet
+	New bool
+}
+
+// PullRequest is a pull request to open with the changes of an optimization
+type PullRequest struct {
+	// Branch is created from Base with a single commit of the files
+	Branch string
+	// Base is the branch the pull request is merged into, the default branch of the repository if it's empty
+	Base          string
+	Title         string
+	Body          string
+	CommitMessage string
+	Files         []*FileChange
+}
+
+// PullRequestCreator opens pull requests, or merge requests on GitLab
+type PullRequestCreator interface {
+	// CreatePullRequest commits the files to a new branch and opens a pull request from it, returning its URL
+	CreatePullRequest(ctx context.Context, pr *PullRequest) (string, error)
+}
+
+// Repository is a repository hosted on GitHub or GitLab
+type Repository struct {
+	// Host is the host name of the server, eg- github.com or gitlab.acme.com
+	Host string
+	// Path is the owner and name of the repository, eg- acme/api, or the full path of the project on GitLab
+	Path string
+}
+
+// ParseRepositoryURL returns the repository of a git remote URL, eg- https://github.com/acme/api.git,
+// git@gitlab.com:acme/backend/api.git or ssh://git@github.com/acme/api
+func ParseRepositoryURL(remote string) (*Repository, error) {
+	host, path := "", ""
+	if u, err := url.Parse(remote); err == nil && u.Host != "" {
+		host, path = u.Hostname(), u.Path
+	} else if user, rest, found := strings.Cut(remote, "@"); found && !strings.Contains(user, "/") {
+		// scp-like syntax, eg- git@github.com:acme/api.git
+		host, path, _ = strings.Cut(rest, ":")
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || !strings.Contains(path, "/") {
+		return nil, fmt.Errorf("%s isn't the URL of a repository on GitHub or GitLab", remote)
+	}
+	return &Repository{Host: host, Path: path}, nil
+}
+
+// Provider returns ProviderGitHub or ProviderGitLab from the host of the repository, "" if it can't tell
+func (r *Repository) Provider() string {
+	switch {
+	case strings.Contains(r.Host, "github"):
+		return ProviderGitHub
+	case strings.Contains(r.Host, "gitlab"):
+		return ProviderGitLab
+	}
+	return ""
+}
+
+// APIURL returns the URL of the REST API of the server hosting the repository. It's "" for the public servers,
+// which NewGitHub and NewGitLab know about, and for servers of unknown providers.
+func (r *Repository) APIURL() string {
+	switch {
+	case r.Host == "github.com" || r.Host == "gitlab.com":
+		return ""
+	case r.Provider() == ProviderGitHub:
+		// GitHub Enterprise Server
+		return fmt.Sprintf("https://%s/api/v3", r.Host)
+	case r.Provider() == ProviderGitLab:
+		return fmt.Sprintf("https://%s/api/v4", r.Host)
+	}
+	return ""
+}
+
+// CreatePullRequest commits the files on top of the base branch with the git database API, points a new branch
+// at the commit and opens a pull request from it
+func (g *GitHub) CreatePullRequest(ctx context.Context, pr *PullRequest) (string, error) {
+	repoURL := fmt.Sprintf("%s/repos/%s", g.apiURL, g.repository)
+	base := pr.Base
+	if base == "" {
+		repo := struct {
+			DefaultBranch string `json:"default_branch"`
+		}{}
+		if err := g.do(ctx, http.MethodGet, repoURL, nil, &repo); err != nil {
+			return "", err
+		}
+		base = repo.DefaultBranch
+	}
+
+	ref := struct {
+		Object struct {
+			SHA string `json:"sha"`
+		} `json:"object"`
+	}{}
+	if err := g.do(ctx, http.MethodGet, fmt.Sprintf("%s/git/ref/heads/%s", repoURL, base), nil, &ref); err != nil {
+		return "", fmt.Errorf("failed to find the base branch %s: %w", base, err)
+	}
+	parent := struct {
+		Tree struct {
+			SHA string `json:"sha"`
+		} `json:"tree"`
+	}{}
+	if err := g.do(ctx, http.MethodGet, fmt.Sprintf("%s/git/commits/%s", repoURL, ref.Object.SHA), nil, &parent); err != nil {
+		return "", err
+	}
+
+	entries := []map[string]string{}
+	for _, f := range pr.Files {
+		entries = append(entries, map[string]string{"path": f.Path, "mode": "100644", "type": "blob", "content": f.Content})
+	}
+	created := struct {
+		SHA string `json:"sha"`
+	}{}
+	if err := g.do(ctx, http.MethodPost, repoURL+"/git/trees", map[string]any{"base_tree": parent.Tree.SHA, "tree": entries}, &created); err != nil {
+		return "", err
+	}
+	commit := map[string]any{"message": pr.CommitMessage, "tree": created.SHA, "parents": []string{ref.Object.SHA}}
+	if err := g.do(ctx, http.MethodPost, repoURL+"/git/commits", commit, &created); err != nil {
+		return "", err
+	}
+	if err := g.do(ctx, http.MethodPost, repoURL+"/git/refs", map[string]string{"ref": "refs/heads/" + pr.Branch, "sha": created.SHA}, &struct{}{}); err != nil {
+		return "", fmt.Errorf("failed to create the branch %s: %w", pr.Branch, err)
+	}
+
+	opened := struct {
+		URL string `json:"html_url"`
+	}{}
+	body := map[string]string{"title": pr.Title, "head": pr.Branch, "base": base, "body": truncate(pr.Body, maxCommentLength)}
+	if err := g.do(ctx, http.MethodPost, repoURL+"/pulls", body, &opened); err != nil {
+		return "", err
+	}
+	return opened.URL, nil
+}
+
+// CreatePullRequest commits the files to a new branch started from the base branch and opens a merge request from it
+func (g *GitLab) CreatePullRequest(ctx context.Context, pr *PullRequest) (string, error) {
+	base := pr.Base
+	if base == "" {
+		project := struct {
+			DefaultBranch string `json:"default_branch"`
+		}{}
+		if err := g.do(ctx, http.MethodGet, g.projectURL(""), nil, &project); err != nil {
+			return "", err
+		}
+		base = project.DefaultBranch
+	}
+
+	actions := []map[string]string{}
+	for _, f := range pr.Files {
+		action := "update"
+		if f.New {
+			action = "create"
+		}
+		actions = append(actions, map[string]string{"action": action, "file_path": f.Path, "content": f.Content})
+	}
+	commit := map[string]any{"branch": pr.Branch, "start_branch": base, "commit_message": pr.CommitMessage, "actions": actions}
+	if err := g.do(ctx, http.MethodPost, g.projectURL("/repository/commits"), commit, &struct{}{}); err != nil {
+		return "", fmt.Errorf("failed to commit to the branch %s: %w", pr.Branch, err)
+	}
+
+	opened := struct {
+		URL string `json:"web_url"`
+	}{}
+	body := map[string]any{"source_branch": pr.Branch, "target_branch": base, "title": pr.Title, "description": truncate(pr.Body, maxCommentLength), "remove_source_branch": true}
+	if err := g.do(ctx, http.MethodPost, g.projectURL("/merge_requests"), body, &opened); err != nil {
+		return "", err
+	}
+	return opened.URL, nil
+}
@@ -0,0 +1,168 @@
+package ci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseRepositoryURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected *Repository
+		provider string
+		apiURL   string
+		wantErr  bool
+	}{
+		{url: "https://github.com/acme/api.git", expected: &Repository{Host: "github.com", Path: "acme/api"}, provider: ProviderGitHub},
+		{url: "git@github.com:acme/api.git", expected: &Repository{Host: "github.com", Path: "acme/api"}, provider: ProviderGitHub},
+		{url: "ssh://git@github.acme.com/acme/api", expected: &Repository{Host: "github.acme.com", Path: "acme/api"}, provider: ProviderGitHub, apiURL: "https://github.acme.com/api/v3"},
+		{url: "https://gitlab.com/acme/backend/api/", expected: &Repository{Host: "gitlab.com", Path: "acme/backend/api"}, provider: ProviderGitLab},
+		{url: "git@gitlab.acme.com:acme/api.git", expected: &Repository{Host: "gitlab.acme.com", Path: "acme/api"}, provider: ProviderGitLab, apiURL: "https://gitlab.acme.com/api/v4"},
+		{url: "https://git.acme.com/acme/api", expected: &Repository{Host: "git.acme.com", Path: "acme/api"}},
+		{url: "file:///srv/git/api.git", wantErr: true},
+		{url: "https://github.com/acme", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			repo, err := ParseRepositoryURL(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRepositoryURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(repo, tt.expected) {
+				t.Errorf("ParseRepositoryURL() = %+v; want %+v", repo, tt.expected)
+			}
+			if repo.Provider() != tt.provider {
+				t.Errorf("Provider() = %q; want %q", repo.Provider(), tt.provider)
+			}
+			if repo.APIURL() != tt.apiURL {
+				t.Errorf("APIURL() = %q; want %q", repo.APIURL(), tt.apiURL)
+			}
+		})
+	}
+}
+
+// recordingServer responds to the requests with the responses keyed by method and path, and records their bodies
+type recordingServer struct {
+	responses map[string]string
+	requests  []string
+	bodies    map[string]map[string]any
+	header    string
+	token     string
+}
+
+func (s *recordingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get(s.header) != s.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	key := r.Method + " " + r.URL.EscapedPath()
+	s.requests = append(s.requests, key)
+	body := map[string]any{}
+	json.NewDecoder(r.Body).Decode(&body)
+	s.bodies[key] = body
+	response, ok := s.responses[key]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	fmt.Fprint(w, response)
+}
+
+func newPullRequest() *PullRequest {
+	return &PullRequest{
+		Branch:        "dockershrink/optimize",
+		Title:         "Optimize the Docker image",
+		Body:          "## Report",
+		CommitMessage: "Optimize the Docker image with dockershrink",
+		Files: []*FileChange{
+			{Path: "Dockerfile", Content: "FROM node:22-alpine\n"},
+			{Path: ".dockerignore", Content: "node_modules\n", New: true},
+		},
+	}
+}
+
+func TestGitHub_CreatePullRequest(t *testing.T) {
+	s := &recordingServer{
+		header: "Authorization",
+		token:  "Bearer secret",
+		bodies: map[string]map[string]any{},
+		responses: map[string]string{
+			"GET /repos/acme/app":                    `{"default_branch": "main"}`,
+			"GET /repos/acme/app/git/ref/heads/main": `{"object": {"sha": "base"}}`,
+			"GET /repos/acme/app/git/commits/base":   `{"tree": {"sha": "basetree"}}`,
+			"POST /repos/acme/app/git/trees":         `{"sha": "tree"}`,
+			"POST /repos/acme/app/git/commits":       `{"sha": "commit"}`,
+			"POST /repos/acme/app/git/refs":          `{}`,
+			"POST /repos/acme/app/pulls":             `{"html_url": "https://github.com/acme/app/pull/8"}`,
+		},
+	}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	url, err := NewGitHub(server.URL, "acme/app", "secret").CreatePullRequest(context.Background(), newPullRequest())
+	if err != nil {
+		t.Fatalf("CreatePullRequest() failed: %v", err)
+	}
+	if url != "https://github.com/acme/app/pull/8" {
+		t.Errorf("CreatePullRequest() = %q", url)
+	}
+	if len(s.requests) != 7 {
+		t.Errorf("expected 7 requests, got %v", s.requests)
+	}
+	tree := s.bodies["POST /repos/acme/app/git/trees"]
+	if tree["base_tree"] != "basetree" || len(tree["tree"].([]any)) != 2 {
+		t.Errorf("unexpected tree %v", tree)
+	}
+	if commit := s.bodies["POST /repos/acme/app/git/commits"]; commit["tree"] != "tree" || !reflect.DeepEqual(commit["parents"], []any{"base"}) {
+		t.Errorf("unexpected commit %v", commit)
+	}
+	if ref := s.bodies["POST /repos/acme/app/git/refs"]; ref["ref"] != "refs/heads/dockershrink/optimize" || ref["sha"] != "commit" {
+		t.Errorf("unexpected ref %v", ref)
+	}
+	if pr := s.bodies["POST /repos/acme/app/pulls"]; pr["head"] != "dockershrink/optimize" || pr["base"] != "main" || pr["body"] != "## Report" {
+		t.Errorf("unexpected pull request %v", pr)
+	}
+}
+
+func TestGitLab_CreatePullRequest(t *testing.T) {
+	s := &recordingServer{
+		header: "PRIVATE-TOKEN",
+		token:  "secret",
+		bodies: map[string]map[string]any{},
+		responses: map[string]string{
+			"POST /projects/acme%2Fbackend%2Fapi/repository/commits": `{"id": "commit"}`,
+			"POST /projects/acme%2Fbackend%2Fapi/merge_requests":     `{"web_url": "https://gitlab.com/acme/backend/api/-/merge_requests/3"}`,
+		},
+	}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	pr := newPullRequest()
+	pr.Base = "develop"
+	url, err := NewGitLab(server.URL, "acme/backend/api", "secret").CreatePullRequest(context.Background(), pr)
+	if err != nil {
+		t.Fatalf("CreatePullRequest() failed: %v", err)
+	}
+	if url != "https://gitlab.com/acme/backend/api/-/merge_requests/3" {
+		t.Errorf("CreatePullRequest() = %q", url)
+	}
+	commit := s.bodies["POST /projects/acme%2Fbackend%2Fapi/repository/commits"]
+	if commit["branch"] != "dockershrink/optimize" || commit["start_branch"] != "develop" {
+		t.Errorf("unexpected commit %v", commit)
+	}
+	actions := commit["actions"].([]any)
+	if actions[0].(map[string]any)["action"] != "update" || actions[1].(map[string]any)["action"] != "create" {
+		t.Errorf("unexpected actions %v", actions)
+	}
+	if mr := s.bodies["POST /projects/acme%2Fbackend%2Fapi/merge_requests"]; mr["target_branch"] != "develop" || mr["description"] != "## Report" {
+		t.Errorf("unexpected merge request %v", mr)
+	}
+}
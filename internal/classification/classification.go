@@ -0,0 +1,137 @@
+package classification
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Kind describes what a Dockerfile is used for
+type Kind string
+
+const (
+	// KindRelease is a Dockerfile whose image is shipped (deployed, pushed to a registry, etc)
+	KindRelease Kind = "release"
+	// KindCI is a Dockerfile only used to run tests, linters or other CI tasks. Its image never ships.
+	KindCI Kind = "ci"
+)
+
+const (
+	SourcePath     = "path"
+	SourceWorkflow = "workflow"
+	SourceConfig   = "config"
+	SourceDefault  = "default"
+)
+
+// Result is the classification of a Dockerfile along with the reason behind it
+type Result struct {
+	Kind Kind
+	// Source is what the classification is based on: path, workflow, config or default
+	Source string
+	Reason string
+}
+
+var (
+	// directories that conventionally contain CI-only Dockerfiles
+	ciDirs = []string{"ci", ".ci", "test", "tests", "e2e", ".github", ".gitlab", ".circleci", ".buildkite", ".devcontainer"}
+	// matches Dockerfile names like Dockerfile.test, Dockerfile-ci, ci.Dockerfile, e2e.dockerfile
+	ciFilenameRegex     = regexp.MustCompile(`(?i)(^|[._-])(ci|test|tests|testing|e2e|lint|dev|devcontainer)([._-]|$)`)
+	dockerfileWordRegex = regexp.MustCompile(`(?i)dockerfile`)
+
+	workflowGlobs = []string{".github/workflows/*.yml", ".github/workflows/*.yaml", ".gitlab-ci.yml", "bitbucket-pipelines.yml", ".circleci/config.yml"}
+	// indicators in a workflow that the image built from a Dockerfile is published
+	pushIndicators = []string{"push: true", "docker push", "--push", "docker/build-push-action"}
+)
+
+// Classify determines whether the Dockerfile at dockerfilePath (relative to the root of fsys) is CI-only.
+// overrides maps Dockerfile paths to kinds and takes precedence over automatic detection.
+func Classify(fsys fs.FS, dockerfilePath string, overrides map[string]string) *Result {
+	cleanPath := path.Clean(strings.TrimPrefix(dockerfilePath, "./"))
+
+	for p, kind := range overrides {
+		if path.Clean(strings.TrimPrefix(p, "./")) == cleanPath {
+			return &Result{
+				Kind:   Kind(kind),
+				Source: SourceConfig,
+				Reason: "classification set in configuration",
+			}
+		}
+	}
+
+	if r := classifyByPath(cleanPath); r != nil {
+		return r
+	}
+	if r := classifyByWorkflows(fsys, cleanPath); r != nil {
+		return r
+	}
+	return &Result{
+		Kind:   KindRelease,
+		Source: SourceDefault,
+		Reason: "no evidence that the image is only used in CI",
+	}
+}
+
+func classifyByPath(dockerfilePath string) *Result {
+	dir, file := path.Split(dockerfilePath)
+	for _, segment := range strings.Split(strings.Trim(dir, "/"), "/") {
+		for _, ciDir := range ciDirs {
+			if strings.EqualFold(segment, ciDir) {
+				return &Result{
+					Kind:   KindCI,
+					Source: SourcePath,
+					Reason: fmt.Sprintf("located inside the %q directory", segment),
+				}
+			}
+		}
+	}
+
+	// strip the word "dockerfile" so that only the qualifier is matched, eg- "Dockerfile.test" -> ".test"
+	qualifier := dockerfileWordRegex.ReplaceAllString(file, "")
+	if m := ciFilenameRegex.FindStringSubmatch(qualifier); m != nil {
+		return &Result{
+			Kind:   KindCI,
+			Source: SourcePath,
+			Reason: fmt.Sprintf("file name %q indicates a %q Dockerfile", file, strings.ToLower(m[2])),
+		}
+	}
+	return nil
+}
+
+// classifyByWorkflows looks for the Dockerfile in CI workflow definitions.
+// If a workflow references the Dockerfile and publishes the image, it is a release Dockerfile.
+// If it's only referenced by workflows that never publish the image, it is CI-only.
+func classifyByWorkflows(fsys fs.FS, dockerfilePath string) *Result {
+	// the path must not be part of a longer path, eg- "Dockerfile" inside "deploy/Dockerfile"
+	reference := regexp.MustCompile(`(^|[^\w./-])(\./)?` + regexp.QuoteMeta(dockerfilePath) + `($|[^\w./-])`)
+
+	var referencedBy []string
+	for _, glob := range workflowGlobs {
+		files, _ := fs.Glob(fsys, glob)
+		for _, f := range files {
+			content, err := fs.ReadFile(fsys, f)
+			if err != nil || !reference.Match(content) {
+				continue
+			}
+			for _, indicator := range pushIndicators {
+				if strings.Contains(string(content), indicator) {
+					return &Result{
+						Kind:   KindRelease,
+						Source: SourceWorkflow,
+						Reason: fmt.Sprintf("image is published by %s", f),
+					}
+				}
+			}
+			referencedBy = append(referencedBy, f)
+		}
+	}
+	if len(referencedBy) == 0 {
+		return nil
+	}
+	return &Result{
+		Kind:   KindCI,
+		Source: SourceWorkflow,
+		Reason: fmt.Sprintf("only used by %s, which never publish the image", strings.Join(referencedBy, ", ")),
+	}
+}
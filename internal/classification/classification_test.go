@@ -0,0 +1,41 @@
+package classification
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestClassify(t *testing.T) {
+	fsys := fstest.MapFS{
+		".github/workflows/test.yml": {Data: []byte("jobs:\n  test:\n    steps:\n      - run: docker build -f docker/Dockerfile.integration .\n")},
+		".github/workflows/release.yml": {Data: []byte(
+			"jobs:\n  release:\n    steps:\n      - uses: docker/build-push-action@v6\n        with:\n          file: deploy/Dockerfile\n          push: true\n",
+		)},
+	}
+
+	tests := []struct {
+		name           string
+		dockerfilePath string
+		overrides      map[string]string
+		expectedKind   Kind
+		expectedSource string
+	}{
+		{"root Dockerfile", "Dockerfile", nil, KindRelease, SourceDefault},
+		{"test qualifier", "Dockerfile.test", nil, KindCI, SourcePath},
+		{"ci prefix", "ci.Dockerfile", nil, KindCI, SourcePath},
+		{"ci directory", "./ci/Dockerfile", nil, KindCI, SourcePath},
+		{"testimonials is not a test", "Dockerfile.testimonials", nil, KindRelease, SourceDefault},
+		{"only referenced by test workflow", "docker/Dockerfile.integration", nil, KindCI, SourceWorkflow},
+		{"published by release workflow", "deploy/Dockerfile", nil, KindRelease, SourceWorkflow},
+		{"config override", "Dockerfile.test", map[string]string{"./Dockerfile.test": "release"}, KindRelease, SourceConfig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Classify(fsys, tt.dockerfilePath, tt.overrides)
+			if r.Kind != tt.expectedKind || r.Source != tt.expectedSource {
+				t.Errorf("Classify(%q) = %s (%s: %s); want %s (%s)", tt.dockerfilePath, r.Kind, r.Source, r.Reason, tt.expectedKind, tt.expectedSource)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package compose
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ConditionStarted               = "service_started"
+	ConditionHealthy               = "service_healthy"
+	ConditionCompletedSuccessfully = "service_completed_successfully"
+
+	// DefaultNetwork is the network compose attaches services to if they don't declare any
+	DefaultNetwork = "default"
+)
+
+// file names looked up by Find, in the order of precedence used by docker compose
+var defaultFileNames = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// File is a docker compose file. Only the parts dockershrink needs are parsed.
+type File struct {
+	Path     string
+	Services map[string]*Service `yaml:"services"`
+}
+
+// Service is a service declared in a compose file
+type Service struct {
+	Name        string       `yaml:"-"`
+	Image       string       `yaml:"image"`
+	Build       *Build       `yaml:"build"`
+	DependsOn   Dependencies `yaml:"depends_on"`
+	Environment Environment  `yaml:"environment"`
+	Networks    Networks     `yaml:"networks"`
+}
+
+// Build is the build section of a service.
+// It is declared either as the path to the build context or as an object.
+type Build struct {
+	Context    string `yaml:"context"`
+	Dockerfile string `yaml:"dockerfile"`
+	// AdditionalContexts are the named build contexts, keyed by name
+	AdditionalContexts AdditionalContexts `yaml:"additional_contexts"`
+	// Args are the build arguments, declared like the environment
+	Args Environment `yaml:"args"`
+}
+
+// AdditionalContexts is the additional_contexts section of a build.
+// It is declared either as a list of "NAME=VALUE" or as a map.
+type AdditionalContexts map[string]string
+
+// Dependency is a service that must be started before the service that depends on it
+type Dependency struct {
+	Service   string
+	Condition string
+}
+
+// Dependencies is the depends_on section of a service.
+// It is declared either as a list of services or as a map of services to their conditions.
+type Dependencies []Dependency
+
+// Environment is the environment section of a service.
+// It is declared either as a list of "KEY=VALUE" or as a map.
+type Environment map[string]string
+
+// Networks is the networks section of a service.
+// It is declared either as a list of networks or as a map of networks to their options.
+type Networks []string
+
+// Find returns the path of the compose file in dir.
+// An empty string is returned if dir doesn't contain one.
+func Find(dir string) string {
+	for _, name := range defaultFileNames {
+		p := filepath.Join(dir, name)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// Load reads and parses the compose file at path
+func Load(path string) (*File, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(path, content)
+}
+
+// Parse parses the contents of the compose file at path
+func Parse(path string, content []byte) (*File, error) {
+	f := &File{}
+	if err := yaml.Unmarshal(content, f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	f.Path = path
+	for name, s := range f.Services {
+		if s == nil {
+			s = &Service{}
+			f.Services[name] = s
+		}
+		s.Name = name
+	}
+	return f, nil
+}
+
+// Service returns the service with the given name, nil if it doesn't exist
+func (f *File) Service(name string) *Service {
+	return f.Services[name]
+}
+
+// ServiceForDockerfile returns the service built from the Dockerfile at dockerfilePath.
+// If several services build it, the first one by name is returned. nil is returned if no service builds it.
+func (f *File) ServiceForDockerfile(dockerfilePath string) *Service {
+	if services := f.ServicesForDockerfile(dockerfilePath); len(services) > 0 {
+		return services[0]
+	}
+	return nil
+}
+
+// ServicesForDockerfile returns all the services built from the Dockerfile at dockerfilePath, sorted by name
+func (f *File) ServicesForDockerfile(dockerfilePath string) []*Service {
+	target, err := filepath.Abs(dockerfilePath)
+	if err != nil {
+		return nil
+	}
+	dir, err := filepath.Abs(filepath.Dir(f.Path))
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(f.Services))
+	for name := range f.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	services := []*Service{}
+	for _, name := range names {
+		b := f.Services[name].Build
+		if b == nil {
+			continue
+		}
+		context := b.Context
+		if !filepath.IsAbs(context) {
+			context = filepath.Join(dir, context)
+		}
+		dockerfile := b.Dockerfile
+		if dockerfile == "" {
+			dockerfile = "Dockerfile"
+		}
+		if !filepath.IsAbs(dockerfile) {
+			dockerfile = filepath.Join(context, dockerfile)
+		}
+		if filepath.Clean(dockerfile) == target {
+			services = append(services, f.Services[name])
+		}
+	}
+	return services
+}
+
+// ErrServiceNotFound is returned when a service doesn't exist in the compose file
+var ErrServiceNotFound = errors.New("service not found in compose file")
+
+// Validate checks that all the dependencies of a service exist
+func (f *File) Validate(service string) error {
+	s := f.Service(service)
+	if s == nil {
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, service)
+	}
+	for _, d := range s.DependsOn {
+		if f.Service(d.Service) == nil {
+			return fmt.Errorf("%w: %s (dependency of %s)", ErrServiceNotFound, d.Service, service)
+		}
+	}
+	return nil
+}
+
+func (b *Build) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		b.Context = value.Value
+		return nil
+	}
+	type build Build
+	return value.Decode((*build)(b))
+}
+
+func (d *Dependencies) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.SequenceNode {
+		var services []string
+		if err := value.Decode(&services); err != nil {
+			return err
+		}
+		for _, s := range services {
+			*d = append(*d, Dependency{Service: s, Condition: ConditionStarted})
+		}
+		return nil
+	}
+
+	var services map[string]struct {
+		Condition string `yaml:"condition"`
+	}
+	if err := value.Decode(&services); err != nil {
+		return err
+	}
+	for s, opts := range services {
+		condition := opts.Condition
+		if condition == "" {
+			condition = ConditionStarted
+		}
+		*d = append(*d, Dependency{Service: s, Condition: condition})
+	}
+	sort.Slice(*d, func(i, j int) bool {
+		return (*d)[i].Service < (*d)[j].Service
+	})
+	return nil
+}
+
+func (e *Environment) UnmarshalYAML(value *yaml.Node) error {
+	*e = Environment{}
+	if value.Kind == yaml.SequenceNode {
+		var vars []string
+		if err := value.Decode(&vars); err != nil {
+			return err
+		}
+		for _, v := range vars {
+			key, val, found := strings.Cut(v, "=")
+			if !found {
+				// a variable without a value is passed through from the host
+				val = os.Getenv(key)
+			}
+			(*e)[key] = interpolate(val)
+		}
+		return nil
+	}
+
+	if value.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: environment must be a list or a map", value.Line)
+	}
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		key, val := value.Content[i].Value, value.Content[i+1]
+		if val.Tag == "!!null" {
+			(*e)[key] = os.Getenv(key)
+		} else {
+			(*e)[key] = interpolate(val.Value)
+		}
+	}
+	return nil
+}
+
+func (a *AdditionalContexts) UnmarshalYAML(value *yaml.Node) error {
+	*a = AdditionalContexts{}
+	if value.Kind == yaml.SequenceNode {
+		var contexts []string
+		if err := value.Decode(&contexts); err != nil {
+			return err
+		}
+		for _, c := range contexts {
+			name, source, found := strings.Cut(c, "=")
+			if !found {
+				return fmt.Errorf("line %d: additional context %q must be NAME=VALUE", value.Line, c)
+			}
+			(*a)[name] = interpolate(source)
+		}
+		return nil
+	}
+	if value.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: additional_contexts must be a list or a map", value.Line)
+	}
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		(*a)[value.Content[i].Value] = interpolate(value.Content[i+1].Value)
+	}
+	return nil
+}
+
+func (n *Networks) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.SequenceNode {
+		return value.Decode((*[]string)(n))
+	}
+	if value.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: networks must be a list or a map", value.Line)
+	}
+	for i := 0; i < len(value.Content); i += 2 {
+		*n = append(*n, value.Content[i].Value)
+	}
+	sort.Strings(*n)
+	return nil
+}
+
+// Network returns the network the service is reachable on
+func (s *Service) Network() string {
+	if len(s.Networks) == 0 {
+		return DefaultNetwork
+	}
+	return s.Networks[0]
+}
+
+// interpolate substitutes variables in a value from the host environment the way compose does,
+// including defaults like ${PORT:-3000}
+func interpolate(value string) string {
+	return os.Expand(value, func(v string) string {
+		if name, def, found := strings.Cut(v, ":-"); found {
+			if val := os.Getenv(name); val != "" {
+				return val
+			}
+			return def
+		}
+		if name, def, found := strings.Cut(v, "-"); found {
+			if val, ok := os.LookupEnv(name); ok {
+				return val
+			}
+			return def
+		}
+		return os.Getenv(v)
+	})
+}
@@ -0,0 +1,128 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testComposeFile = `
+services:
+  api:
+    build:
+      context: ./api
+      dockerfile: Dockerfile.prod
+      additional_contexts:
+        lib: ../lib
+        base: docker-image://node:22-alpine
+    depends_on:
+      db:
+        condition: service_healthy
+      migrate:
+        condition: service_completed_successfully
+    environment:
+      DATABASE_HOST: db
+      PORT: ${TEST_COMPOSE_PORT:-3000}
+    networks:
+      backend:
+  web:
+    build: ./web
+    depends_on: [api]
+    environment:
+      - API_URL=http://api:3000
+  worker:
+    build:
+      context: ./worker
+      additional_contexts:
+        - shared=../shared
+  migrate:
+    image: migrate/migrate
+  db:
+    image: postgres:16
+`
+
+func TestParse(t *testing.T) {
+	f, err := Parse("/project/compose.yaml", []byte(testComposeFile))
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	api := f.Service("api")
+	if api == nil {
+		t.Fatalf("service api not found")
+	}
+	expectedDeps := Dependencies{
+		{Service: "db", Condition: ConditionHealthy},
+		{Service: "migrate", Condition: ConditionCompletedSuccessfully},
+	}
+	if !reflect.DeepEqual(api.DependsOn, expectedDeps) {
+		t.Errorf("api.DependsOn = %v; want %v", api.DependsOn, expectedDeps)
+	}
+	expectedEnv := Environment{"DATABASE_HOST": "db", "PORT": "3000"}
+	if !reflect.DeepEqual(api.Environment, expectedEnv) {
+		t.Errorf("api.Environment = %v; want %v", api.Environment, expectedEnv)
+	}
+	if api.Network() != "backend" {
+		t.Errorf("api.Network() = %q; want %q", api.Network(), "backend")
+	}
+
+	web := f.Service("web")
+	if web.Build.Context != "./web" {
+		t.Errorf("web.Build.Context = %q; want %q", web.Build.Context, "./web")
+	}
+	if !reflect.DeepEqual(web.DependsOn, Dependencies{{Service: "api", Condition: ConditionStarted}}) {
+		t.Errorf("web.DependsOn = %v", web.DependsOn)
+	}
+	if web.Environment["API_URL"] != "http://api:3000" {
+		t.Errorf("web.Environment = %v", web.Environment)
+	}
+	if web.Network() != DefaultNetwork {
+		t.Errorf("web.Network() = %q; want %q", web.Network(), DefaultNetwork)
+	}
+
+	expectedContexts := AdditionalContexts{"lib": "../lib", "base": "docker-image://node:22-alpine"}
+	if !reflect.DeepEqual(api.Build.AdditionalContexts, expectedContexts) {
+		t.Errorf("api.Build.AdditionalContexts = %v; want %v", api.Build.AdditionalContexts, expectedContexts)
+	}
+	if worker := f.Service("worker"); !reflect.DeepEqual(worker.Build.AdditionalContexts, AdditionalContexts{"shared": "../shared"}) {
+		t.Errorf("worker.Build.AdditionalContexts = %v", worker.Build.AdditionalContexts)
+	}
+
+	if err := f.Validate("api"); err != nil {
+		t.Errorf("Validate(api) failed: %v", err)
+	}
+	if err := f.Validate("cron"); err == nil {
+		t.Errorf("Validate(cron) succeeded for a missing service")
+	}
+}
+
+func TestServiceForDockerfile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "compose.yaml")
+	if err := os.WriteFile(path, []byte(testComposeFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if Find(dir) != path {
+		t.Fatalf("Find() = %q; want %q", Find(dir), path)
+	}
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	tests := map[string]string{
+		filepath.Join(dir, "api", "Dockerfile.prod"): "api",
+		filepath.Join(dir, "web", "Dockerfile"):      "web",
+		filepath.Join(dir, "Dockerfile"):             "",
+	}
+	for dockerfile, expected := range tests {
+		name := ""
+		if s := f.ServiceForDockerfile(dockerfile); s != nil {
+			name = s.Name
+		}
+		if name != expected {
+			t.Errorf("ServiceForDockerfile(%q) = %q; want %q", dockerfile, name, expected)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/ownership"
+	"github.com/duaraghav8/dockershrink/internal/platform"
+	"gopkg.in/yaml.v3"
+)
+
+// Filename is the name of the project-level configuration file
+const Filename = ".dockershrink.yaml"
+
+// UserFilename is the name of the user-level configuration file, in the dockershrink directory of the user's config directory
+const UserFilename = "config.yaml"
+
+const (
+	// CIDockerfilesSkip skips Dockerfiles classified as CI-only
+	CIDockerfilesSkip = "skip"
+	// CIDockerfilesRelaxed only applies rules that speed up builds to CI-only Dockerfiles
+	CIDockerfilesRelaxed = "relaxed"
+	// CIDockerfilesFull treats CI-only Dockerfiles like any other
+	CIDockerfilesFull = "full"
+)
+
+// Config is the user configuration of dockershrink
+type Config struct {
+	// Profile is what the image is optimized for when neither --profile nor --goal are given: size, speed, balanced or security
+	Profile string `yaml:"profile"`
+	// CIDockerfiles decides how Dockerfiles classified as CI-only are handled
+	CIDockerfiles string `yaml:"ci_dockerfiles"`
+	// Dockerfiles overrides the automatic classification of Dockerfiles.
+	// Keys are Dockerfile paths relative to the project root, values are kinds ("ci" or "release").
+	Dockerfiles map[string]string `yaml:"dockerfiles"`
+	// Reports lists the destinations the report of every run is sent to
+	Reports []SinkConfig `yaml:"reports"`
+	// Platforms the image is built for, eg- linux/amd64 and linux/arm64
+	Platforms []string `yaml:"platforms"`
+	// Owners assigns owners to paths of the project, on top of its CODEOWNERS file.
+	// Later entries take precedence over earlier ones and over CODEOWNERS.
+	Owners []OwnersConfig `yaml:"owners"`
+	// Lint configures the rules used by lint and analyze
+	Lint LintConfig `yaml:"lint"`
+	// Embeddings configures how the LLM searches dockershrink's documentation
+	Embeddings EmbeddingsConfig `yaml:"embeddings"`
+	// LLM configures the model that optimizes and generates Dockerfiles
+	LLM LLMConfig `yaml:"llm"`
+	// Ignore lists paths dockershrink never looks at, using the CODEOWNERS (gitignore) syntax, eg- "legacy/".
+	// Dockerfiles under them are skipped by --recursive and the LLM can't read their files.
+	Ignore []string `yaml:"ignore"`
+	// Output configures where the results of optimize and generate are written
+	Output OutputConfig `yaml:"output"`
+	// Limits caps what a single run may spend on the LLM
+	Limits LimitsConfig `yaml:"limits"`
+	// Trend configures "dockershrink trend"
+	Trend TrendConfig `yaml:"trend"`
+	// Policies are the paths of the organization policies every Dockerfile must comply with,
+	// relative to the configuration file that lists them
+	Policies []string `yaml:"policies"`
+	// Network configures how the LLM, registries and other services are reached, eg- through a corporate proxy
+	Network NetworkConfig `yaml:"network"`
+	// Kubernetes configures the manifests the Dockerfile is cross-checked against
+	Kubernetes KubernetesConfig `yaml:"kubernetes"`
+
+	// ignoreRules are the compiled Ignore patterns
+	ignoreRules []*ownership.Rule
+}
+
+// LLMConfig selects the model used by optimize, generate and the other commands that call the LLM
+type LLMConfig struct {
+	// Provider is openai, which also covers OpenAI-compatible APIs through BaseURL
+	Provider string `yaml:"provider"`
+	// Model defaults to the model dockershrink's prompts are tuned for
+	Model string `yaml:"model,omitempty"`
+	// BaseURL points to an OpenAI-compatible API instead of OpenAI's
+	BaseURL string `yaml:"base_url,omitempty"`
+	// APIKeyEnv is the environment variable containing the API key, defaults to OPENAI_API_KEY
+	APIKeyEnv string `yaml:"api_key_env,omitempty"`
+	// RequestsPerMinute paces the requests to the LLM to stay within the rate limits of the API key,
+	// eg- when optimizing many Dockerfiles at once. 0 means no limit.
+	RequestsPerMinute int `yaml:"requests_per_minute,omitempty"`
+}
+
+// NetworkConfig configures the proxy and the certificate authorities of the requests dockershrink sends.
+// The flags of the same name override it.
+type NetworkConfig struct {
+	// Proxy is the URL of the proxy HTTP and HTTPS requests go through, defaults to $HTTPS_PROXY and $HTTP_PROXY
+	Proxy string `yaml:"proxy,omitempty"`
+	// NoProxy lists the hosts, domains, IPs and CIDRs reached without the proxy, eg- ".corp.example.com", defaults to $NO_PROXY
+	NoProxy []string `yaml:"no_proxy,omitempty"`
+	// CACerts are PEM files of certificate authorities trusted on top of the system's,
+	// relative to the configuration file that lists them
+	CACerts []string `yaml:"ca_certs,omitempty"`
+}
+
+// KubernetesConfig locates the Kubernetes manifests and Helm values files that deploy the image,
+// so that the rules warn when the Dockerfile and the way it's deployed don't fit together
+type KubernetesConfig struct {
+	// Manifests are glob patterns of the manifests and values files, eg- "deploy/*.yaml" or "chart/values.yaml",
+	// relative to the configuration file that lists them
+	Manifests []string `yaml:"manifests,omitempty"`
+	// Image is the repository the image is pushed to, eg- "ghcr.io/acme/api", to find its containers in the manifests.
+	// Defaults to the containers whose image is named like the directory of the Dockerfile.
+	Image string `yaml:"image,omitempty"`
+}
+
+// OutputConfig configures where files are written
+type OutputConfig struct {
+	// Dir is the directory optimized and generated files are saved to, defaults to dockershrink.out
+	Dir string `yaml:"dir,omitempty"`
+	// PatchFile makes optimize write its changes to this file as a patch instead of saving the optimized files
+	PatchFile string `yaml:"patch_file,omitempty"`
+}
+
+// LimitsConfig caps the usage of the LLM. A run stops calling the LLM once a limit is reached. 0 means no limit.
+type LimitsConfig struct {
+	// MaxTokens is the number of prompt and completion tokens a run may use
+	MaxTokens int64 `yaml:"max_tokens,omitempty"`
+	// MaxCostUSD is the estimated cost of a run in US dollars, only models with known prices are counted
+	MaxCostUSD float64 `yaml:"max_cost_usd,omitempty"`
+	// MaxFileKB is how much of a file the LLM reads is sent to it, larger files are truncated.
+	// MaxReadKB is how much of all the files it reads at once is sent to it. 0 means the default.
+	MaxFileKB int `yaml:"max_file_kb,omitempty"`
+	MaxReadKB int `yaml:"max_read_kb,omitempty"`
+}
+
+// TrendConfig configures how the image is tracked across runs
+type TrendConfig struct {
+	// MaxGrowthPercent is how much bigger than at the previous run the image may get before "trend --check" fails,
+	// eg- 5 for 5%. The default is used if it's nil, and 0 allows no growth at all.
+	MaxGrowthPercent *float64 `yaml:"max_growth_percent,omitempty"`
+}
+
+// EmbeddingsConfig selects the backend used to search the documentation.
+// Retrieval keeps working offline or without OpenAI by using a local model or keyword search.
+type EmbeddingsConfig struct {
+	// Provider is openai, local, keyword or none. By default, openai is used if an OpenAI API key
+	// is set and keyword search otherwise.
+	Provider string `yaml:"provider"`
+	// Model is the embeddings model, defaults to text-embedding-3-small (openai)
+	Model string `yaml:"model,omitempty"`
+	// BaseURL points to an OpenAI-compatible embeddings API instead of OpenAI's (openai)
+	BaseURL string `yaml:"base_url,omitempty"`
+	// APIKeyEnv is the environment variable containing the API key for BaseURL,
+	// the OpenAI API key is used if it's empty (openai)
+	APIKeyEnv string `yaml:"api_key_env,omitempty"`
+	// Command computes embeddings, eg- a script running an ONNX model (local).
+	// It receives {"texts": [...]} on stdin and writes {"embeddings": [[...], ...]} to stdout.
+	Command []string `yaml:"command,omitempty"`
+	// RequestsPerMinute paces the requests to the provider, 0 means no limit
+	RequestsPerMinute int `yaml:"requests_per_minute,omitempty"`
+}
+
+// LintConfig configures the static rules
+type LintConfig struct {
+	// Severity overrides the severity of rules. Keys are rule IDs (eg- DS014) or names,
+	// values are high, medium, low, info or off to disable the rule.
+	Severity map[string]string `yaml:"severity"`
+	// Disable lists the IDs or names of rules that are never run, like setting their severity to off
+	Disable []string `yaml:"disable"`
+	// Plugins are the rule plugins run along with the built-in rules
+	Plugins []RulePluginConfig `yaml:"plugins"`
+}
+
+// RulePluginConfig configures a rule plugin
+type RulePluginConfig struct {
+	// Command runs the plugin, eg- [./rules/acme.wasm] or [python3, rules/check.py]
+	Command []string `yaml:"command"`
+}
+
+// OwnersConfig assigns owners to the files matching a path pattern
+type OwnersConfig struct {
+	// Path uses the CODEOWNERS syntax, eg- "/services/api/"
+	Path   string   `yaml:"path"`
+	Owners []string `yaml:"owners"`
+}
+
+// SinkConfig configures a single report destination.
+// Only the fields relevant to the sink's type are used.
+// Secrets are never stored in the file, fields ending in "_env" name the environment variable holding them.
+type SinkConfig struct {
+	// Type is the kind of sink: stdout, file, slack, webhook, github_check, s3 or plugin
+	Type string `yaml:"type"`
+
+	// Path is the file the report is written to (file)
+	Path string `yaml:"path,omitempty"`
+	// Format is the report format: json, text or markdown (stdout, file)
+	Format string `yaml:"format,omitempty"`
+
+	// URL is the endpoint the report is posted to (webhook)
+	URL string `yaml:"url,omitempty"`
+	// URLEnv is the environment variable containing the endpoint (slack, webhook)
+	URLEnv string `yaml:"url_env,omitempty"`
+	// Headers are added to the request (webhook)
+	Headers map[string]string `yaml:"headers,omitempty"`
+
+	// Repository is the "owner/name" of the repository, defaults to $GITHUB_REPOSITORY (github_check)
+	Repository string `yaml:"repository,omitempty"`
+	// TokenEnv is the environment variable containing the API token, defaults to GITHUB_TOKEN (github_check)
+	TokenEnv string `yaml:"token_env,omitempty"`
+
+	// Owners restricts the sink to reports of Dockerfiles owned by at least one of these owners,
+	// eg- to send every team the reports of its own services (all sinks)
+	Owners []string `yaml:"owners,omitempty"`
+
+	// Bucket and Key locate the uploaded report, Key may contain {run_id} (s3)
+	Bucket string `yaml:"bucket,omitempty"`
+	Key    string `yaml:"key,omitempty"`
+
+	// Command runs the plugin the report is sent to (plugin)
+	Command []string `yaml:"command,omitempty"`
+}
+
+// Default returns the configuration used when no config file is present
+func Default() *Config {
+	return &Config{
+		CIDockerfiles: CIDockerfilesSkip,
+		Dockerfiles:   map[string]string{},
+	}
+}
+
+// Load reads the user's configuration file and the configuration file of the given project directory.
+// Values in the project's file take precedence: maps are merged with those of the user's file, while other
+// values, including lists, replace them. If neither file exists, the default configuration is returned.
+func Load(projectDir string) (*Config, error) {
+	cfg := Default()
+	if path, err := UserPath(); err == nil {
+		if err := cfg.load(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.load(filepath.Join(projectDir, Filename)); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// UserPath returns the path of the user's configuration file, eg- ~/.config/dockershrink/config.yaml.
+// $XDG_CONFIG_HOME is used instead of ~/.config if it's set.
+func UserPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to locate home directory: %w", err)
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "dockershrink", UserFilename), nil
+}
+
+// load reads the configuration file at path on top of the current values, if the file exists
+func (c *Config) load(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(content, c); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	// the paths listed by this file replace those of the files loaded before it, which are already resolved
+	var declared struct {
+		Policies []string `yaml:"policies"`
+		Network  struct {
+			CACerts []string `yaml:"ca_certs"`
+		} `yaml:"network"`
+		Kubernetes struct {
+			Manifests []string `yaml:"manifests"`
+		} `yaml:"kubernetes"`
+	}
+	if err := yaml.Unmarshal(content, &declared); err == nil {
+		if declared.Policies != nil {
+			c.Policies = resolvePaths(filepath.Dir(path), declared.Policies)
+		}
+		if declared.Network.CACerts != nil {
+			c.Network.CACerts = resolvePaths(filepath.Dir(path), declared.Network.CACerts)
+		}
+		if declared.Kubernetes.Manifests != nil {
+			c.Kubernetes.Manifests = resolvePaths(filepath.Dir(path), declared.Kubernetes.Manifests)
+		}
+	}
+	if err := c.validate(); err != nil {
+		return fmt.Errorf("invalid configuration in %s: %w", path, err)
+	}
+	return nil
+}
+
+// resolvePaths makes the relative paths absolute, relative to dir
+func resolvePaths(dir string, paths []string) []string {
+	resolved := make([]string, len(paths))
+	for i, p := range paths {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		resolved[i] = p
+	}
+	return resolved
+}
+
+// Ignored returns true if the slash-separated path, relative to the project root, matches an Ignore pattern
+func (c *Config) Ignored(p string) bool {
+	for _, r := range c.ignoreRules {
+		if r.Matches(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) validate() error {
+	if c.Profile != "" {
+		if _, err := models.ParseProfile(c.Profile); err != nil {
+			return err
+		}
+	}
+	switch c.CIDockerfiles {
+	case CIDockerfilesSkip, CIDockerfilesRelaxed, CIDockerfilesFull:
+	default:
+		return fmt.Errorf("ci_dockerfiles must be one of %s, %s or %s", CIDockerfilesSkip, CIDockerfilesRelaxed, CIDockerfilesFull)
+	}
+	for path, kind := range c.Dockerfiles {
+		if kind != "ci" && kind != "release" {
+			return fmt.Errorf("dockerfiles.%s: kind must be either ci or release", path)
+		}
+	}
+	for i, r := range c.Reports {
+		if r.Type == "" {
+			return fmt.Errorf("reports[%d]: type is required", i)
+		}
+	}
+	for i, o := range c.Owners {
+		if _, err := ownership.NewRule(o.Path, o.Owners); err != nil {
+			return fmt.Errorf("owners[%d]: %w", i, err)
+		}
+	}
+	for rule, severity := range c.Lint.Severity {
+		switch strings.ToLower(severity) {
+		case "high", "medium", "low", "info", "off":
+		default:
+			return fmt.Errorf("lint.severity.%s: severity must be one of high, medium, low, info or off", rule)
+		}
+	}
+	for i, p := range c.Lint.Plugins {
+		if len(p.Command) == 0 {
+			return fmt.Errorf("lint.plugins[%d]: command is required", i)
+		}
+	}
+	switch c.Embeddings.Provider {
+	case "", "openai", "keyword", "none":
+	case "local":
+		if len(c.Embeddings.Command) == 0 {
+			return fmt.Errorf("embeddings.command is required for the local provider")
+		}
+	default:
+		return fmt.Errorf("embeddings.provider must be one of openai, local, keyword or none")
+	}
+	for i, p := range c.Platforms {
+		if _, err := platform.Parse(p); err != nil {
+			return fmt.Errorf("platforms[%d]: %w", i, err)
+		}
+	}
+	switch c.LLM.Provider {
+	case "", "openai":
+	default:
+		return fmt.Errorf("llm.provider must be openai, use llm.base_url for OpenAI-compatible APIs")
+	}
+	c.ignoreRules = nil
+	for i, pattern := range c.Ignore {
+		r, err := ownership.NewRule(pattern, nil)
+		if err != nil {
+			return fmt.Errorf("ignore[%d]: %w", i, err)
+		}
+		c.ignoreRules = append(c.ignoreRules, r)
+	}
+	if c.LLM.RequestsPerMinute < 0 {
+		return fmt.Errorf("llm.requests_per_minute must not be negative")
+	}
+	if c.Embeddings.RequestsPerMinute < 0 {
+		return fmt.Errorf("embeddings.requests_per_minute must not be negative")
+	}
+	if c.Limits.MaxTokens < 0 {
+		return fmt.Errorf("limits.max_tokens must not be negative")
+	}
+	if c.Limits.MaxCostUSD < 0 {
+		return fmt.Errorf("limits.max_cost_usd must not be negative")
+	}
+	if c.Limits.MaxFileKB < 0 {
+		return fmt.Errorf("limits.max_file_kb must not be negative")
+	}
+	if c.Limits.MaxReadKB < 0 {
+		return fmt.Errorf("limits.max_read_kb must not be negative")
+	}
+	if c.Trend.MaxGrowthPercent != nil && *c.Trend.MaxGrowthPercent < 0 {
+		return fmt.Errorf("trend.max_growth_percent must not be negative")
+	}
+	for i, pattern := range c.Kubernetes.Manifests {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("kubernetes.manifests[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	project := t.TempDir()
+
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := Load(project)
+	if err != nil {
+		t.Fatalf("Load() without files failed: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Errorf("expected the default configuration, got %+v", cfg)
+	}
+
+	write(filepath.Join(home, "dockershrink", UserFilename), `
+llm:
+  model: gpt-4o-mini
+  api_key_env: MY_OPENAI_KEY
+limits:
+  max_tokens: 50000
+  max_cost_usd: 0.5
+  max_file_kb: 32
+lint:
+  severity:
+    DS014: low
+  disable: [DS010]
+ignore: ["fixtures/"]
+policies: [policies/acme.yaml]
+network:
+  proxy: http://proxy.corp:3128
+  no_proxy: [.corp.example.com]
+  ca_certs: [corp-root.pem]
+`)
+	write(filepath.Join(project, Filename), `
+llm:
+  model: gpt-4o
+  requests_per_minute: 30
+limits:
+  max_cost_usd: 2
+lint:
+  severity:
+    DS001: high
+ignore: ["legacy/", "**/*.test.Dockerfile"]
+output:
+  dir: build/dockershrink
+trend:
+  max_growth_percent: 5
+`)
+
+	cfg, err = Load(project)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	expectedLLM := LLMConfig{Model: "gpt-4o", APIKeyEnv: "MY_OPENAI_KEY", RequestsPerMinute: 30}
+	if cfg.LLM != expectedLLM {
+		t.Errorf("LLM = %+v; want %+v", cfg.LLM, expectedLLM)
+	}
+	if cfg.Limits != (LimitsConfig{MaxTokens: 50000, MaxCostUSD: 2, MaxFileKB: 32}) {
+		t.Errorf("Limits = %+v", cfg.Limits)
+	}
+	if !reflect.DeepEqual(cfg.Lint.Severity, map[string]string{"DS014": "low", "DS001": "high"}) {
+		t.Errorf("expected the severities of both files to be merged, got %v", cfg.Lint.Severity)
+	}
+	if !reflect.DeepEqual(cfg.Lint.Disable, []string{"DS010"}) {
+		t.Errorf("Lint.Disable = %v", cfg.Lint.Disable)
+	}
+	if cfg.Trend.MaxGrowthPercent == nil || *cfg.Trend.MaxGrowthPercent != 5 {
+		t.Errorf("Trend.MaxGrowthPercent = %v", cfg.Trend.MaxGrowthPercent)
+	}
+	if cfg.Output.Dir != "build/dockershrink" {
+		t.Errorf("Output.Dir = %q", cfg.Output.Dir)
+	}
+
+	// policies are relative to the file listing them
+	if expected := []string{filepath.Join(home, "dockershrink", "policies", "acme.yaml")}; !reflect.DeepEqual(cfg.Policies, expected) {
+		t.Errorf("Policies = %v; want %v", cfg.Policies, expected)
+	}
+
+	expectedNetwork := NetworkConfig{
+		Proxy:   "http://proxy.corp:3128",
+		NoProxy: []string{".corp.example.com"},
+		CACerts: []string{filepath.Join(home, "dockershrink", "corp-root.pem")},
+	}
+	if !reflect.DeepEqual(cfg.Network, expectedNetwork) {
+		t.Errorf("Network = %+v; want %+v", cfg.Network, expectedNetwork)
+	}
+
+	// the project's ignore patterns replace the user's
+	ignored := map[string]bool{
+		"legacy/Dockerfile":                true,
+		"services/api/e2e.test.Dockerfile": true,
+		"services/api/Dockerfile":          false,
+		"fixtures/Dockerfile":              false,
+	}
+	for p, expected := range ignored {
+		if cfg.Ignored(p) != expected {
+			t.Errorf("Ignored(%q) = %v; want %v", p, !expected, expected)
+		}
+	}
+
+	write(filepath.Join(project, Filename), "policies: [ci/policy.yaml, /etc/dockershrink/org.yaml]\n")
+	cfg, err = Load(project)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if expected := []string{filepath.Join(project, "ci", "policy.yaml"), "/etc/dockershrink/org.yaml"}; !reflect.DeepEqual(cfg.Policies, expected) {
+		t.Errorf("expected the project's policies to replace the user's, got %v; want %v", cfg.Policies, expected)
+	}
+
+	write(filepath.Join(project, Filename), "kubernetes:\n  manifests: [deploy/*.yaml]\n  image: ghcr.io/acme/api\n")
+	cfg, err = Load(project)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	expectedKubernetes := KubernetesConfig{Manifests: []string{filepath.Join(project, "deploy", "*.yaml")}, Image: "ghcr.io/acme/api"}
+	if !reflect.DeepEqual(cfg.Kubernetes, expectedKubernetes) {
+		t.Errorf("Kubernetes = %+v; want %+v", cfg.Kubernetes, expectedKubernetes)
+	}
+
+	// 0 forbids any growth, rather than standing for the default
+	write(filepath.Join(project, Filename), "trend:\n  max_growth_percent: 0\n")
+	cfg, err = Load(project)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.Trend.MaxGrowthPercent == nil || *cfg.Trend.MaxGrowthPercent != 0 {
+		t.Errorf("expected the project's limit of 0 to replace the user's, got %v", cfg.Trend.MaxGrowthPercent)
+	}
+
+	write(filepath.Join(project, Filename), "kubernetes:\n  manifests: [\"deploy/[\"]\n")
+	if _, err := Load(project); err == nil || !strings.Contains(err.Error(), "kubernetes.manifests[0]") {
+		t.Errorf("expected an error for an invalid manifest pattern, got %v", err)
+	}
+
+	write(filepath.Join(project, Filename), "llm:\n  provider: anthropic\n")
+	if _, err := Load(project); err == nil || !strings.Contains(err.Error(), Filename) {
+		t.Errorf("expected an error naming %s for an unsupported provider, got %v", Filename, err)
+	}
+}
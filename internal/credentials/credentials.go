@@ -0,0 +1,196 @@
+// Package credentials keeps the API keys of named profiles, eg- "work" and "personal", so that they don't have to be
+// exported in every shell. Keys are stored in the keychain of the OS when there is one dockershrink can use,
+// and otherwise in a file encrypted with a passphrase. Neither the list of profiles nor the logs ever contain the keys.
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/config"
+)
+
+// DefaultProfile is used when no profile is selected
+const DefaultProfile = "default"
+
+// ErrNotFound is returned when no key is stored for a profile
+var ErrNotFound = errors.New("no API key is stored for this profile")
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ValidateProfileName checks that a profile name can be used as the account of a keychain item, eg- "work"
+func ValidateProfileName(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q, use letters, digits, '-', '_' and '.'", name)
+	}
+	return nil
+}
+
+// Backend stores the keys of the profiles
+type Backend interface {
+	// Name describes where the keys are stored, eg- "macOS keychain"
+	Name() string
+	// Get returns the key of a profile, ErrNotFound if there is none
+	Get(profile string) (string, error)
+	Set(profile, key string) error
+	Delete(profile string) error
+}
+
+// Profile records where the key of a profile is stored
+type Profile struct {
+	// Backend is the name of the backend holding the key
+	Backend   string    `json:"backend"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store keeps the keys of the profiles in the keychain, or in the encrypted file if there is no keychain or it fails
+type Store struct {
+	// Keychain is nil if the OS has no keychain dockershrink can use
+	Keychain Backend
+	File     *EncryptedFile
+	// Profiles are the profiles logged in, by name
+	Profiles map[string]*Profile
+
+	path string
+}
+
+// DefaultPaths returns the paths of the list of profiles and of the encrypted file,
+// eg- ~/.config/dockershrink/credentials.json and ~/.config/dockershrink/credentials.enc
+func DefaultPaths() (profiles, file string, err error) {
+	path, err := config.UserPath()
+	if err != nil {
+		return "", "", err
+	}
+	dir := filepath.Dir(path)
+	return filepath.Join(dir, "credentials.json"), filepath.Join(dir, "credentials.enc"), nil
+}
+
+// Open reads the list of profiles at path
+func Open(path string, keychain Backend, file *EncryptedFile) (*Store, error) {
+	s := &Store{Keychain: keychain, File: file, Profiles: map[string]*Profile{}, path: path}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var saved struct {
+		Profiles map[string]*Profile `json:"profiles"`
+	}
+	if err := json.Unmarshal(content, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if saved.Profiles != nil {
+		s.Profiles = saved.Profiles
+	}
+	return s, nil
+}
+
+// Names returns the names of the profiles, sorted
+func (s *Store) Names() []string {
+	names := make([]string, 0, len(s.Profiles))
+	for name := range s.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Login stores the key of a profile, replacing the one stored before, and returns the backend it was stored in.
+// The keychain is preferred, the encrypted file is used if there is none or it fails.
+func (s *Store) Login(profile, key string) (Backend, error) {
+	if err := ValidateProfileName(profile); err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return nil, errors.New("the API key is empty")
+	}
+	// the key stored before is removed first, in case it's moving from one backend to the other
+	if err := s.deleteKey(profile); err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	var backend Backend
+	var keychainErr error
+	if s.Keychain != nil {
+		if keychainErr = s.Keychain.Set(profile, key); keychainErr == nil {
+			backend = s.Keychain
+		}
+	}
+	if backend == nil {
+		if err := s.File.Set(profile, key); err != nil {
+			if keychainErr != nil {
+				return nil, fmt.Errorf("%w (the %s failed too: %v)", err, s.Keychain.Name(), keychainErr)
+			}
+			return nil, err
+		}
+		backend = s.File
+	}
+	s.Profiles[profile] = &Profile{Backend: backend.Name(), CreatedAt: time.Now().UTC()}
+	return backend, s.save()
+}
+
+// Key returns the key of a profile, ErrNotFound if the profile isn't logged in
+func (s *Store) Key(profile string) (string, error) {
+	backend, err := s.backend(profile)
+	if err != nil {
+		return "", err
+	}
+	return backend.Get(profile)
+}
+
+// Logout deletes the key of a profile
+func (s *Store) Logout(profile string) error {
+	if err := s.deleteKey(profile); err != nil {
+		return err
+	}
+	delete(s.Profiles, profile)
+	return s.save()
+}
+
+func (s *Store) deleteKey(profile string) error {
+	backend, err := s.backend(profile)
+	if err != nil {
+		return err
+	}
+	if err := backend.Delete(profile); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (s *Store) backend(profile string) (Backend, error) {
+	p, ok := s.Profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, profile)
+	}
+	if s.Keychain != nil && p.Backend == s.Keychain.Name() {
+		return s.Keychain, nil
+	}
+	if p.Backend == s.File.Name() {
+		return s.File, nil
+	}
+	return nil, fmt.Errorf("the key of profile %s is stored in the %s, which isn't available", profile, p.Backend)
+}
+
+func (s *Store) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(s.path), err)
+	}
+	content, err := json.MarshalIndent(map[string]any{"profiles": s.Profiles}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path, append(content, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", s.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package credentials
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+// fakeBackend is a keychain that fails to store keys if broken is set
+type fakeBackend struct {
+	keys   map[string]string
+	broken bool
+}
+
+func (b *fakeBackend) Name() string {
+	return "fake keychain"
+}
+
+func (b *fakeBackend) Get(profile string) (string, error) {
+	key, ok := b.keys[profile]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return key, nil
+}
+
+func (b *fakeBackend) Set(profile, key string) error {
+	if b.broken {
+		return errors.New("the keychain is locked")
+	}
+	b.keys[profile] = key
+	return nil
+}
+
+func (b *fakeBackend) Delete(profile string) error {
+	if _, ok := b.keys[profile]; !ok {
+		return ErrNotFound
+	}
+	delete(b.keys, profile)
+	return nil
+}
+
+func passphrase(p string) func() (string, error) {
+	return func() (string, error) { return p, nil }
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	keychain := &fakeBackend{keys: map[string]string{}}
+	file := &EncryptedFile{Path: filepath.Join(dir, "credentials.enc"), Passphrase: passphrase("correct horse")}
+	store, err := Open(filepath.Join(dir, "credentials.json"), keychain, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if backend, err := store.Login("work", "sk-work"); err != nil || backend != Backend(keychain) {
+		t.Fatalf("Login(work) = %v, %v; want the keychain", backend, err)
+	}
+	// the file is used when the keychain fails
+	keychain.broken = true
+	if backend, err := store.Login("personal", "sk-personal"); err != nil || backend != Backend(file) {
+		t.Fatalf("Login(personal) = %v, %v; want the encrypted file", backend, err)
+	}
+	if _, err := store.Login("../work", "sk-work"); err == nil {
+		t.Errorf("expected an invalid profile name to be rejected")
+	}
+
+	// the list of profiles is read back and never contains the keys
+	store, err = Open(filepath.Join(dir, "credentials.json"), keychain, &EncryptedFile{Path: file.Path, Passphrase: passphrase("correct horse")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(store.Names(), []string{"personal", "work"}) {
+		t.Errorf("Names() = %v", store.Names())
+	}
+	for _, name := range []string{"credentials.json", "credentials.enc"} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(content), "sk-") {
+			t.Errorf("%s contains a key in clear: %s", name, content)
+		}
+	}
+	for profile, expected := range map[string]string{"work": "sk-work", "personal": "sk-personal"} {
+		if key, err := store.Key(profile); err != nil || key != expected {
+			t.Errorf("Key(%s) = %q, %v; want %q", profile, key, err, expected)
+		}
+	}
+	if _, err := store.Key("other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a profile that isn't logged in, got %v", err)
+	}
+
+	if err := store.Logout("personal"); err != nil {
+		t.Fatalf("Logout() failed: %v", err)
+	}
+	if _, err := store.Key("personal"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the key to be deleted, got %v", err)
+	}
+}
+
+func TestEncryptedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.enc")
+	file := &EncryptedFile{Path: path, Passphrase: passphrase("correct horse")}
+	if err := file.Set("work", "sk-work"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+
+	wrong := &EncryptedFile{Path: path, Passphrase: passphrase("battery staple")}
+	if _, err := wrong.Get("work"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Get() with the wrong passphrase returned %v", err)
+	}
+	if err := wrong.Set("personal", "sk-personal"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("expected keys not to be added with another passphrase, got %v", err)
+	}
+	if _, err := (&EncryptedFile{Path: path}).Get("work"); err == nil || !strings.Contains(err.Error(), EnvPassphrase) {
+		t.Errorf("expected to be told to set %s, got %v", EnvPassphrase, err)
+	}
+	if key, err := (&EncryptedFile{Path: path, Passphrase: passphrase("correct horse")}).Get("work"); err != nil || key != "sk-work" {
+		t.Errorf("Get() = %q, %v", key, err)
+	}
+}
+
+func TestEncryptedFile_Iterations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.enc")
+	if err := (&EncryptedFile{Path: path, Passphrase: passphrase("correct horse")}).Set("work", "sk-work"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a file tampered with to derive its key with a single iteration
+	weakened := strings.Replace(string(content), `"iterations": 600000`, `"iterations": 1`, 1)
+	if err := os.WriteFile(path, []byte(weakened), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file := &EncryptedFile{Path: path, Passphrase: passphrase("correct horse")}
+	if _, err := file.Get("work"); err == nil || !strings.Contains(err.Error(), "PBKDF2 iterations") {
+		t.Errorf("expected a file with too few iterations to be rejected, got %v", err)
+	}
+	if err := file.Set("personal", "sk-personal"); err == nil {
+		t.Error("expected no key to be added to a file with too few iterations")
+	}
+}
+
+func TestKeychain(t *testing.T) {
+	keyring.MockInit()
+	k := &osKeychain{name: "fake keychain"}
+	if _, err := k.Get("work"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	// keys with whitespace and quotes are written to the keychain as they are
+	if err := k.Set("work", "sk-work \"quoted\"\n"); err != nil {
+		t.Fatal(err)
+	}
+	if key, err := k.Get("work"); err != nil || key != "sk-work \"quoted\"\n" {
+		t.Errorf("Get() = %q, %v", key, err)
+	}
+	if err := k.Delete("work"); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Delete("work"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
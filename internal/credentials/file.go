@@ -0,0 +1,171 @@
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// EnvPassphrase holds the passphrase of the encrypted file, eg- in CI where it can't be prompted for
+const EnvPassphrase = "DOCKERSHRINK_CREDENTIALS_PASSPHRASE"
+
+// pbkdf2Iterations is what OWASP recommends for PBKDF2-HMAC-SHA256. Files recording fewer iterations are
+// rejected, otherwise anyone able to write the file could make the passphrase cheap to brute-force.
+const pbkdf2Iterations = 600_000
+
+// ErrWrongPassphrase is returned when the passphrase doesn't decrypt the file
+var ErrWrongPassphrase = errors.New("wrong passphrase for the encrypted credentials file")
+
+// EncryptedFile keeps the keys in a file, each encrypted with AES-256-GCM using a key derived from a passphrase with PBKDF2-HMAC-SHA256
+type EncryptedFile struct {
+	Path string
+	// Passphrase returns the passphrase, it's only called when a key is read or written
+	Passphrase func() (string, error)
+
+	// key is derived from the passphrase once
+	key []byte
+}
+
+type encryptedFileContent struct {
+	Iterations int                        `json:"iterations"`
+	Salt       []byte                     `json:"salt"`
+	Keys       map[string]*encryptedValue `json:"keys"`
+}
+
+type encryptedValue struct {
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func (f *EncryptedFile) Name() string {
+	return "encrypted file"
+}
+
+func (f *EncryptedFile) Get(profile string) (string, error) {
+	content, err := f.read()
+	if err != nil {
+		return "", err
+	}
+	v, ok := content.Keys[profile]
+	if !ok {
+		return "", ErrNotFound
+	}
+	aead, err := f.cipher(content)
+	if err != nil {
+		return "", err
+	}
+	// the profile is authenticated along with the key, so that keys can't be swapped between profiles
+	plaintext, err := aead.Open(nil, v.Nonce, v.Ciphertext, []byte(profile))
+	if err != nil {
+		f.key = nil
+		return "", ErrWrongPassphrase
+	}
+	return string(plaintext), nil
+}
+
+func (f *EncryptedFile) Set(profile, key string) error {
+	content, err := f.read()
+	if err != nil {
+		return err
+	}
+	aead, err := f.cipher(content)
+	if err != nil {
+		return err
+	}
+	// every key of the file must be encrypted with the same passphrase
+	for name, v := range content.Keys {
+		if _, err := aead.Open(nil, v.Nonce, v.Ciphertext, []byte(name)); err != nil {
+			f.key = nil
+			return ErrWrongPassphrase
+		}
+		break
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	content.Keys[profile] = &encryptedValue{Nonce: nonce, Ciphertext: aead.Seal(nil, nonce, []byte(key), []byte(profile))}
+	return f.write(content)
+}
+
+func (f *EncryptedFile) Delete(profile string) error {
+	content, err := f.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := content.Keys[profile]; !ok {
+		return ErrNotFound
+	}
+	delete(content.Keys, profile)
+	return f.write(content)
+}
+
+// read reads the file, a new salt is generated if it doesn't exist yet
+func (f *EncryptedFile) read() (*encryptedFileContent, error) {
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		return &encryptedFileContent{Iterations: pbkdf2Iterations, Salt: salt, Keys: map[string]*encryptedValue{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Path, err)
+	}
+	content := &encryptedFileContent{}
+	if err := json.Unmarshal(data, content); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", f.Path, err)
+	}
+	if content.Iterations < pbkdf2Iterations {
+		return nil, fmt.Errorf("%s derives its key with %d PBKDF2 iterations, fewer than the %d required, delete it and log in again", f.Path, content.Iterations, pbkdf2Iterations)
+	}
+	if content.Keys == nil {
+		content.Keys = map[string]*encryptedValue{}
+	}
+	return content, nil
+}
+
+func (f *EncryptedFile) write(content *encryptedFileContent) error {
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(f.Path), err)
+	}
+	data, err := json.MarshalIndent(content, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(f.Path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.Path, err)
+	}
+	return nil
+}
+
+func (f *EncryptedFile) cipher(content *encryptedFileContent) (cipher.AEAD, error) {
+	if f.key == nil {
+		if f.Passphrase == nil {
+			return nil, fmt.Errorf("a passphrase is required to use the encrypted credentials file, set %s", EnvPassphrase)
+		}
+		passphrase, err := f.Passphrase()
+		if err != nil {
+			return nil, err
+		}
+		if passphrase == "" {
+			return nil, fmt.Errorf("the passphrase of the encrypted credentials file is empty")
+		}
+		f.key = pbkdf2.Key([]byte(passphrase), content.Salt, content.Iterations, 32, sha256.New)
+	}
+	block, err := aes.NewCipher(f.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
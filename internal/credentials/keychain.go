@@ -0,0 +1,71 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+
+	"github.com/zalando/go-keyring"
+)
+
+// service is the name the keys are stored under in the keychain
+const service = "dockershrink"
+
+// Keychain returns the keychain of the OS, nil if there is none dockershrink can use: the macOS keychain,
+// the Secret Service of the desktop (GNOME Keyring, KWallet) or the Windows Credential Manager.
+// Keys never show up in the arguments of a process: the Secret Service is called over D-Bus and the Credential Manager
+// through its API, while the key is written to the stdin of the security command on macOS.
+func Keychain() Backend {
+	switch runtime.GOOS {
+	case "darwin":
+		return &osKeychain{name: "macOS keychain"}
+	case "windows":
+		return &osKeychain{name: "Windows Credential Manager"}
+	case "linux", "freebsd", "openbsd", "netbsd":
+		// the Secret Service is only there when a desktop session runs one, eg- not over SSH or in CI
+		k := &osKeychain{name: "Secret Service keyring"}
+		if _, err := keyring.Get(service, DefaultProfile); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+			return nil
+		}
+		return k
+	}
+	return nil
+}
+
+// osKeychain stores keys as generic passwords of the OS's keychain, under the dockershrink service
+type osKeychain struct {
+	name string
+}
+
+func (k *osKeychain) Name() string {
+	return k.name
+}
+
+func (k *osKeychain) Get(profile string) (string, error) {
+	key, err := keyring.Get(service, profile)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the key of profile %s from the %s: %w", profile, k.name, err)
+	}
+	return key, nil
+}
+
+func (k *osKeychain) Set(profile, key string) error {
+	if err := keyring.Set(service, profile, key); err != nil {
+		return fmt.Errorf("failed to store the key of profile %s in the %s: %w", profile, k.name, err)
+	}
+	return nil
+}
+
+func (k *osKeychain) Delete(profile string) error {
+	err := keyring.Delete(service, profile)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete the key of profile %s from the %s: %w", profile, k.name, err)
+	}
+	return nil
+}
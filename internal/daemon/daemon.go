@@ -0,0 +1,305 @@
+// Package daemon runs dockershrink as a long-lived local process that editors and the CLI talk to over
+// a unix socket. The daemon keeps parsed Dockerfiles, indexes of project files and base image data warm,
+// so that an analysis takes milliseconds instead of loading everything from scratch.
+//
+// The protocol is a single JSON request per connection, answered by a single JSON response.
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+const (
+	// MethodPing returns the status of the daemon
+	MethodPing = "ping"
+	// MethodAnalyze analyzes a project, like the analyze command
+	MethodAnalyze = "analyze"
+	// MethodStop stops the daemon after responding
+	MethodStop = "stop"
+)
+
+// DefaultIdleTimeout is how long the daemon waits for a request before exiting
+const DefaultIdleTimeout = 30 * time.Minute
+
+// ErrRunning is returned when listening on a socket another daemon is already serving
+var ErrRunning = errors.New("a daemon is already running")
+
+// Request is sent by clients, Analyze is only set for MethodAnalyze
+type Request struct {
+	Method  string          `json:"method"`
+	Analyze *AnalyzeRequest `json:"analyze,omitempty"`
+}
+
+// AnalyzeRequest describes the project to analyze the same way the flags of the analyze command do.
+// Relative paths are relative to Cwd, which is the client's working directory. The Dockerfile, .dockerignore
+// and goal default to the defaults of the analyze command.
+type AnalyzeRequest struct {
+	Cwd          string `json:"cwd"`
+	Dockerfile   string `json:"dockerfile"`
+	Dockerignore string `json:"dockerignore"`
+	Goal         string `json:"goal,omitempty"`
+	// Platforms is a comma-separated list of platforms, like the --platforms flag
+	Platforms     string   `json:"platforms,omitempty"`
+	BuildContexts []string `json:"build_contexts,omitempty"`
+	// Policies and PolicyBundles are the organization policies and OPA bundles given on the command line
+	Policies      []string `json:"policies,omitempty"`
+	PolicyBundles []string `json:"policy_bundles,omitempty"`
+}
+
+// Analysis is the result of analyzing a project
+type Analysis struct {
+	Score            int                       `json:"score"`
+	Findings         []*models.Finding         `json:"findings"`
+	InstructionSizes []*models.InstructionSize `json:"instruction_sizes,omitempty"`
+	Variants         []*models.Variant         `json:"variants,omitempty"`
+}
+
+// Status describes a running daemon
+type Status struct {
+	PID       int        `json:"pid"`
+	StartedAt time.Time  `json:"started_at"`
+	Requests  int64      `json:"requests"`
+	Cache     CacheStats `json:"cache"`
+}
+
+// Response answers a request. Error is set if the request failed.
+type Response struct {
+	Analysis *Analysis `json:"analysis,omitempty"`
+	Status   *Status   `json:"status,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// AnalyzeFunc analyzes the project described by the request
+type AnalyzeFunc func(ctx context.Context, req *AnalyzeRequest) (*Analysis, error)
+
+// Server answers the requests of clients until it's stopped or no request arrives for IdleTimeout
+type Server struct {
+	analyze AnalyzeFunc
+	warm    *Warm
+	// IdleTimeout is how long the server waits for a request before stopping, 0 means forever
+	IdleTimeout time.Duration
+
+	startedAt time.Time
+	requests  atomic.Int64
+
+	mu       sync.Mutex
+	listener net.Listener
+	idle     *time.Timer
+	stopped  bool
+}
+
+// NewServer returns a server that analyzes projects with analyze. warm is only used to report cache statistics.
+func NewServer(analyze AnalyzeFunc, warm *Warm) *Server {
+	return &Server{analyze: analyze, warm: warm, IdleTimeout: DefaultIdleTimeout}
+}
+
+// DefaultSocket returns the path of the socket the daemon listens on by default,
+// eg- $XDG_RUNTIME_DIR/dockershrink.sock or ~/.cache/dockershrink/daemon.sock
+func DefaultSocket() (string, error) {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "dockershrink.sock"), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(dir, "dockershrink", "daemon.sock"), nil
+}
+
+// Listen listens on the unix socket at the given path, which only the current user can connect to.
+// A socket left behind by a daemon that didn't exit cleanly is removed, while ErrRunning is
+// returned if another daemon still answers on it.
+func Listen(socket string) (net.Listener, error) {
+	if _, err := os.Stat(socket); err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err := Ping(ctx, socket)
+		cancel()
+		if err == nil {
+			return nil, fmt.Errorf("%w on %s", ErrRunning, socket)
+		}
+		if err := os.Remove(socket); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", socket, err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(socket), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create directory of socket %s: %w", socket, err)
+	}
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socket, 0o600); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed to restrict access to socket %s: %w", socket, err)
+	}
+	return l, nil
+}
+
+// Serve accepts connections on l until the server is stopped, then closes l.
+// It returns nil when stopped by Stop, a stop request or the idle timeout.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.listener = l
+	s.startedAt = time.Now()
+	if s.IdleTimeout > 0 {
+		s.idle = time.AfterFunc(s.IdleTimeout, s.Stop)
+	}
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			stopped := s.stopped
+			s.mu.Unlock()
+			if stopped {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// Stop stops accepting connections. Requests being handled are answered first.
+func (s *Server) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return
+	}
+	s.stopped = true
+	if s.idle != nil {
+		s.idle.Stop()
+	}
+	if s.listener != nil {
+		s.listener.Close()
+	}
+}
+
+// handle answers the single request of a connection
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	s.resetIdle()
+	defer s.resetIdle()
+
+	req := &Request{}
+	resp := &Response{}
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(req); err != nil {
+		resp.Error = fmt.Sprintf("invalid request: %v", err)
+	} else {
+		s.requests.Add(1)
+		resp = s.respond(req)
+	}
+	_ = json.NewEncoder(conn).Encode(resp)
+
+	if req.Method == MethodStop {
+		s.Stop()
+	}
+}
+
+func (s *Server) respond(req *Request) *Response {
+	switch req.Method {
+	case MethodPing, MethodStop:
+		return &Response{Status: s.status()}
+	case MethodAnalyze:
+		if req.Analyze == nil {
+			return &Response{Error: "analyze request has no project"}
+		}
+		analysis, err := s.analyze(context.Background(), req.Analyze)
+		if err != nil {
+			return &Response{Error: err.Error()}
+		}
+		return &Response{Analysis: analysis}
+	default:
+		return &Response{Error: fmt.Sprintf("unknown method %q", req.Method)}
+	}
+}
+
+func (s *Server) status() *Status {
+	st := &Status{PID: os.Getpid(), StartedAt: s.startedAt, Requests: s.requests.Load()}
+	if s.warm != nil {
+		st.Cache = s.warm.Stats()
+	}
+	return st
+}
+
+// resetIdle restarts the idle timeout, so that the server only stops once no request arrived for a while
+func (s *Server) resetIdle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.idle != nil && !s.stopped {
+		s.idle.Reset(s.IdleTimeout)
+	}
+}
+
+// Call sends a request to the daemon listening on socket and returns its response.
+// An error is returned if the daemon can't be reached or the request failed.
+func Call(ctx context.Context, socket string, req *Request) (*Response, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request to daemon: %w", err)
+	}
+	resp := &Response{}
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(resp); err != nil {
+		return nil, fmt.Errorf("failed to read response of daemon: %w", err)
+	}
+	if resp.Error != "" {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+// Ping returns the status of the daemon listening on socket
+func Ping(ctx context.Context, socket string) (*Status, error) {
+	resp, err := Call(ctx, socket, &Request{Method: MethodPing})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Status, nil
+}
+
+// Analyze has the daemon listening on socket analyze a project
+func Analyze(ctx context.Context, socket string, req *AnalyzeRequest) (*Analysis, error) {
+	resp, err := Call(ctx, socket, &Request{Method: MethodAnalyze, Analyze: req})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Analysis, nil
+}
+
+// Stop stops the daemon listening on socket and returns its status right before stopping
+func Stop(ctx context.Context, socket string) (*Status, error) {
+	resp, err := Call(ctx, socket, &Request{Method: MethodStop})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Status, nil
+}
@@ -0,0 +1,135 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+// socketPath returns a path for a socket in a short temporary directory, since unix socket paths are limited to ~100 bytes
+func socketPath(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "ds")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "d.sock")
+}
+
+func startServer(t *testing.T, s *Server) (string, chan error) {
+	socket := socketPath(t)
+	l, err := Listen(socket)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(l) }()
+	t.Cleanup(s.Stop)
+	return socket, done
+}
+
+func TestServer(t *testing.T) {
+	analyze := func(ctx context.Context, req *AnalyzeRequest) (*Analysis, error) {
+		if req.Dockerfile == "missing" {
+			return nil, errors.New("Dockerfile not found")
+		}
+		return &Analysis{Score: 90, Findings: []*models.Finding{{Title: req.Cwd + "/" + req.Dockerfile}}}, nil
+	}
+	s := NewServer(analyze, NewWarm(nil))
+	socket, done := startServer(t, s)
+	ctx := context.Background()
+
+	status, err := Ping(ctx, socket)
+	if err != nil {
+		t.Fatalf("failed to ping: %v", err)
+	}
+	if status.PID != os.Getpid() || status.StartedAt.IsZero() {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	analysis, err := Analyze(ctx, socket, &AnalyzeRequest{Cwd: "/project", Dockerfile: "Dockerfile"})
+	if err != nil {
+		t.Fatalf("failed to analyze: %v", err)
+	}
+	if analysis.Score != 90 || len(analysis.Findings) != 1 || analysis.Findings[0].Title != "/project/Dockerfile" {
+		t.Errorf("unexpected analysis %+v", analysis)
+	}
+	if _, err := Analyze(ctx, socket, &AnalyzeRequest{Dockerfile: "missing"}); err == nil || err.Error() != "Dockerfile not found" {
+		t.Errorf("expected the error of the analysis, got %v", err)
+	}
+	if _, err := Call(ctx, socket, &Request{Method: "unknown"}); err == nil {
+		t.Errorf("expected an error for an unknown method")
+	}
+
+	if _, err := Listen(socket); !errors.Is(err, ErrRunning) {
+		t.Errorf("expected ErrRunning when listening on the socket of a running daemon, got %v", err)
+	}
+
+	status, err = Stop(ctx, socket)
+	if err != nil {
+		t.Fatalf("failed to stop: %v", err)
+	}
+	if status.Requests != 6 {
+		t.Errorf("expected 6 requests including the ping of Listen and the stop itself, got %d", status.Requests)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected Serve to return nil after stopping, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("server didn't stop")
+	}
+}
+
+func TestServer_IdleTimeout(t *testing.T) {
+	s := NewServer(nil, nil)
+	s.IdleTimeout = 100 * time.Millisecond
+	socket, done := startServer(t, s)
+
+	// requests keep the server running
+	for i := 0; i < 3; i++ {
+		time.Sleep(50 * time.Millisecond)
+		if _, err := Ping(context.Background(), socket); err != nil {
+			t.Fatalf("server stopped while receiving requests: %v", err)
+		}
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected Serve to return nil after the idle timeout, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("server didn't stop after the idle timeout")
+	}
+}
+
+func TestListen_StaleSocket(t *testing.T) {
+	socket := socketPath(t)
+	// a socket file nobody listens on, like the one of a daemon that crashed
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+
+	l, err = Listen(socket)
+	if err != nil {
+		t.Fatalf("expected the stale socket to be replaced, got %v", err)
+	}
+	defer l.Close()
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("failed to stat socket: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected the socket to only be accessible by the user, got %v", info.Mode().Perm())
+	}
+}
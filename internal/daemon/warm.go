@@ -0,0 +1,172 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/baseimages"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/fileindex"
+)
+
+// Loader loads the state of a project that's expensive to build from scratch for every analysis
+type Loader interface {
+	// Dockerfile reads and parses the Dockerfile at the given path
+	Dockerfile(path string) (*dockerfile.Dockerfile, error)
+	// BaseImages returns the data about the official images the Dockerfile's stages are built from
+	BaseImages(d *dockerfile.Dockerfile) *baseimages.Matrix
+	// FileIndex returns the up to date index of the project's files, nil if it can't be used
+	FileIndex(dir string) *fileindex.Index
+}
+
+// Warm is a Loader that keeps what another Loader loads in memory across analyses.
+// Dockerfiles are parsed again only when they change, indexes of project files are updated
+// in place instead of being read from disk and base image data is kept until BaseImagesTTL.
+// It is safe for concurrent use.
+type Warm struct {
+	cold Loader
+	// BaseImagesTTL is how long base image data is used before it's loaded again
+	BaseImagesTTL time.Duration
+
+	mu          sync.Mutex
+	dockerfiles map[string]*cachedDockerfile
+	baseImages  map[string]*cachedMatrix
+	indexes     map[string]*fileindex.Index
+	stats       CacheStats
+}
+
+type cachedDockerfile struct {
+	modTime time.Time
+	size    int64
+	d       *dockerfile.Dockerfile
+}
+
+type cachedMatrix struct {
+	loadedAt time.Time
+	m        *baseimages.Matrix
+}
+
+// CacheStats counts how often the warm state was used instead of loading it again
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	// Projects is the number of projects whose files are indexed in memory
+	Projects int `json:"projects"`
+}
+
+// NewWarm returns a Loader that keeps what cold loads warm
+func NewWarm(cold Loader) *Warm {
+	return &Warm{
+		cold:          cold,
+		BaseImagesTTL: time.Hour,
+		dockerfiles:   map[string]*cachedDockerfile{},
+		baseImages:    map[string]*cachedMatrix{},
+		indexes:       map[string]*fileindex.Index{},
+	}
+}
+
+// Dockerfile returns the parsed Dockerfile, parsing it again only if its size or modification time changed
+func (w *Warm) Dockerfile(path string) (*dockerfile.Dockerfile, error) {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(key)
+	if err != nil {
+		// let the cold loader report the error the way it always does
+		return w.cold.Dockerfile(path)
+	}
+
+	w.mu.Lock()
+	c, ok := w.dockerfiles[key]
+	if ok && c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+		w.stats.Hits++
+		w.mu.Unlock()
+		return c.d, nil
+	}
+	w.stats.Misses++
+	w.mu.Unlock()
+
+	d, err := w.cold.Dockerfile(path)
+	if err != nil {
+		return nil, err
+	}
+	w.mu.Lock()
+	w.dockerfiles[key] = &cachedDockerfile{modTime: info.ModTime(), size: info.Size(), d: d}
+	w.mu.Unlock()
+	return d, nil
+}
+
+// BaseImages returns the base image data of the Dockerfile, loading it again if it's older than BaseImagesTTL
+func (w *Warm) BaseImages(d *dockerfile.Dockerfile) *baseimages.Matrix {
+	key := baseImagesKey(d)
+
+	w.mu.Lock()
+	c, ok := w.baseImages[key]
+	if ok && time.Since(c.loadedAt) < w.BaseImagesTTL {
+		w.stats.Hits++
+		w.mu.Unlock()
+		return c.m
+	}
+	w.stats.Misses++
+	w.mu.Unlock()
+
+	m := w.cold.BaseImages(d)
+	w.mu.Lock()
+	w.baseImages[key] = &cachedMatrix{loadedAt: time.Now(), m: m}
+	w.mu.Unlock()
+	return m
+}
+
+// FileIndex returns the index of the project's files, updating the one in memory if the project was indexed before
+func (w *Warm) FileIndex(dir string) *fileindex.Index {
+	key, err := filepath.Abs(dir)
+	if err != nil {
+		return w.cold.FileIndex(dir)
+	}
+	// indexes are updated one at a time, so that concurrent analyses of a project don't scan it twice
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if x, ok := w.indexes[key]; ok {
+		w.stats.Hits++
+		changed, err := x.Update()
+		if err != nil {
+			delete(w.indexes, key)
+			return nil
+		}
+		if len(changed) > 0 {
+			// the index is only an optimization, failing to persist it doesn't fail the analysis
+			_ = x.Save()
+		}
+		return x
+	}
+	w.stats.Misses++
+	x := w.cold.FileIndex(dir)
+	if x != nil {
+		w.indexes[key] = x
+	}
+	return x
+}
+
+// Stats returns how often the warm state was used so far
+func (w *Warm) Stats() CacheStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	stats := w.stats
+	stats.Projects = len(w.indexes)
+	return stats
+}
+
+// baseImagesKey identifies the set of base images a Dockerfile is built from
+func baseImagesKey(d *dockerfile.Dockerfile) string {
+	names := []string{}
+	for _, stage := range d.GetStages() {
+		names = append(names, stage.BaseImage().Name())
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,96 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/baseimages"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/fileindex"
+)
+
+// countingLoader loads everything from scratch and counts how often it did
+type countingLoader struct {
+	dockerfiles, baseImages, indexes int
+}
+
+func (l *countingLoader) Dockerfile(path string) (*dockerfile.Dockerfile, error) {
+	l.dockerfiles++
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return dockerfile.NewDockerfile(string(content))
+}
+
+func (l *countingLoader) BaseImages(d *dockerfile.Dockerfile) *baseimages.Matrix {
+	l.baseImages++
+	return &baseimages.Matrix{}
+}
+
+func (l *countingLoader) FileIndex(dir string) *fileindex.Index {
+	l.indexes++
+	x := fileindex.Open(dir)
+	if _, err := x.Update(); err != nil {
+		return nil
+	}
+	return x
+}
+
+func TestWarm(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Dockerfile")
+	if err := os.WriteFile(path, []byte("FROM node:20\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cold := &countingLoader{}
+	w := NewWarm(cold)
+
+	d1, err := w.Dockerfile(path)
+	if err != nil {
+		t.Fatalf("failed to load Dockerfile: %v", err)
+	}
+	d2, _ := w.Dockerfile(path)
+	if d1 != d2 || cold.dockerfiles != 1 {
+		t.Errorf("expected an unchanged Dockerfile to be parsed once, parsed %d times", cold.dockerfiles)
+	}
+	if err := os.WriteFile(path, []byte("FROM node:22-alpine\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d3, _ := w.Dockerfile(path)
+	if stage, _ := d3.GetFinalStage(); cold.dockerfiles != 2 || stage.BaseImage().Tag() != "22-alpine" {
+		t.Errorf("expected a changed Dockerfile to be parsed again")
+	}
+	if _, err := w.Dockerfile(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("expected an error for a missing Dockerfile")
+	}
+
+	w.BaseImages(d3)
+	w.BaseImages(d3)
+	if cold.baseImages != 1 {
+		t.Errorf("expected base images to be loaded once, loaded %d times", cold.baseImages)
+	}
+	w.BaseImagesTTL = 0
+	w.BaseImages(d3)
+	if cold.baseImages != 2 {
+		t.Errorf("expected expired base images to be loaded again")
+	}
+
+	x1 := w.FileIndex(dir)
+	if err := os.WriteFile(filepath.Join(dir, "index.js"), make([]byte, 100), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	x2 := w.FileIndex(dir)
+	if x1 == nil || x1 != x2 || cold.indexes != 1 {
+		t.Fatalf("expected the index to be loaded once and updated in place, loaded %d times", cold.indexes)
+	}
+	if x2.Size("index.js") != 100 {
+		t.Errorf("expected the index to include the new file")
+	}
+
+	stats := w.Stats()
+	if stats.Hits != 3 || stats.Misses != 5 || stats.Projects != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,258 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OpKind is the kind of change a line goes through
+type OpKind int
+
+const (
+	OpEqual OpKind = iota
+	OpDelete
+	OpInsert
+)
+
+// Op is a single line in the edit script that transforms one text into another
+type Op struct {
+	Kind OpKind
+	Text string
+	// ALine and BLine are the 1-based line numbers of the line in the old and new texts.
+	// They are 0 if the line doesn't exist in the respective text.
+	ALine int
+	BLine int
+	// NoEOL is true if this is the last line of its text and it isn't terminated by a newline
+	NoEOL bool
+}
+
+// Hunk is a group of changes along with the unchanged lines surrounding them
+type Hunk struct {
+	AStart, ALen int
+	BStart, BLen int
+	Ops          []Op
+}
+
+// Lines computes the line-by-line edit script that transforms a into b.
+// It uses the longest common subsequence, which is fast enough for files the size of a Dockerfile.
+func Lines(a, b string) []Op {
+	aLines, bLines := splitLines(a), splitLines(b)
+	n, m := len(aLines), len(bLines)
+
+	// lcs[i][j] is the length of the LCS of aLines[i:] and bLines[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if aLines[i] == bLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := []Op{}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && aLines[i] == bLines[j]:
+			ops = append(ops, newOp(OpEqual, aLines[i], i+1, j+1))
+			i++
+			j++
+		case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+			// deletions are emitted before insertions, like in git
+			ops = append(ops, newOp(OpDelete, aLines[i], i+1, 0))
+			i++
+		default:
+			ops = append(ops, newOp(OpInsert, bLines[j], 0, j+1))
+			j++
+		}
+	}
+	return ops
+}
+
+// Hunks groups the changes in ops into hunks with the given number of context lines around them
+func Hunks(ops []Op, context int) []*Hunk {
+	// find the [start, end) ranges of ops to include in every hunk
+	ranges := [][2]int{}
+	for idx, op := range ops {
+		if op.Kind == OpEqual {
+			continue
+		}
+		start, end := max(idx-context, 0), min(idx+context+1, len(ops))
+		if n := len(ranges); n > 0 && start <= ranges[n-1][1] {
+			// overlaps with the previous hunk, extend it
+			ranges[n-1][1] = max(ranges[n-1][1], end)
+		} else {
+			ranges = append(ranges, [2]int{start, end})
+		}
+	}
+
+	hunks := make([]*Hunk, 0, len(ranges))
+	for _, r := range ranges {
+		h := &Hunk{Ops: ops[r[0]:r[1]]}
+
+		// number of lines in each text before the hunk
+		aBefore, bBefore := 0, 0
+		for _, op := range ops[:r[0]] {
+			if op.Kind != OpInsert {
+				aBefore++
+			}
+			if op.Kind != OpDelete {
+				bBefore++
+			}
+		}
+		for _, op := range h.Ops {
+			if op.Kind != OpInsert {
+				h.ALen++
+			}
+			if op.Kind != OpDelete {
+				h.BLen++
+			}
+		}
+
+		// an empty range refers to the line before the hunk (unified diff convention)
+		h.AStart, h.BStart = aBefore, bBefore
+		if h.ALen > 0 {
+			h.AStart++
+		}
+		if h.BLen > 0 {
+			h.BStart++
+		}
+		hunks = append(hunks, h)
+	}
+	return hunks
+}
+
+// Header returns the "@@ -a,b +c,d @@" line of the hunk
+func (h *Hunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.AStart, h.ALen, h.BStart, h.BLen)
+}
+
+// ALines returns the lines of the old text covered by the hunk
+func (h *Hunk) ALines() []string {
+	lines := make([]string, 0, h.ALen)
+	for _, op := range h.Ops {
+		if op.Kind != OpInsert {
+			lines = append(lines, op.Text)
+		}
+	}
+	return lines
+}
+
+// BLines returns the lines of the new text covered by the hunk
+func (h *Hunk) BLines() []string {
+	lines := make([]string, 0, h.BLen)
+	for _, op := range h.Ops {
+		if op.Kind != OpDelete {
+			lines = append(lines, op.Text)
+		}
+	}
+	return lines
+}
+
+// Assemble rebuilds a text from a by replacing the region covered by every hunk with
+// the corresponding entry of replacements. Passing h.BLines() for every hunk produces the
+// new text, passing h.ALines() keeps the old one.
+// The hunks must have been computed from a and be in order. The assembled text always ends with a newline.
+func Assemble(a string, hunks []*Hunk, replacements [][]string) string {
+	aLines := splitLines(a)
+	for i := range aLines {
+		aLines[i] = strings.TrimSuffix(aLines[i], "\n")
+	}
+
+	result := []string{}
+	next := 0 // index of the next line of a to copy
+	for i, h := range hunks {
+		// an empty range refers to the line before the hunk
+		start := h.AStart
+		if h.ALen > 0 {
+			start--
+		}
+		result = append(result, aLines[next:start]...)
+		result = append(result, replacements[i]...)
+		next = start + h.ALen
+	}
+	result = append(result, aLines[next:]...)
+
+	if len(result) == 0 {
+		return ""
+	}
+	return strings.Join(result, "\n") + "\n"
+}
+
+// Unified returns the unified diff of a and b with 3 lines of context.
+// An empty string is returned if both texts are identical.
+func Unified(aName, bName, a, b string) string {
+	hunks := Hunks(Lines(a, b), 3)
+	if len(hunks) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", aName, bName))
+	writeHunks(&sb, hunks)
+	return sb.String()
+}
+
+// GitPatch returns a patch that can be applied with "git apply" or "patch -p1" to turn
+// the file at path from a into b. If a is empty, the patch creates the file.
+// An empty string is returned if both texts are identical.
+func GitPatch(path, a, b string) string {
+	hunks := Hunks(Lines(a, b), 3)
+	if len(hunks) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("diff --git a/%s b/%s\n", path, path))
+	if a == "" {
+		sb.WriteString("new file mode 100644\n--- /dev/null\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("--- a/%s\n", path))
+	}
+	sb.WriteString(fmt.Sprintf("+++ b/%s\n", path))
+	writeHunks(&sb, hunks)
+	return sb.String()
+}
+
+func writeHunks(sb *strings.Builder, hunks []*Hunk) {
+	for _, h := range hunks {
+		sb.WriteString(h.Header() + "\n")
+		for _, op := range h.Ops {
+			sb.WriteString(op.Prefix() + op.Text + "\n")
+			if op.NoEOL {
+				sb.WriteString("\\ No newline at end of file\n")
+			}
+		}
+	}
+}
+
+// Prefix returns the unified diff prefix of the line: " ", "-" or "+"
+func (o Op) Prefix() string {
+	switch o.Kind {
+	case OpDelete:
+		return "-"
+	case OpInsert:
+		return "+"
+	default:
+		return " "
+	}
+}
+
+// newOp creates an op from a line that still contains its newline terminator
+func newOp(kind OpKind, line string, aLine, bLine int) Op {
+	text, terminated := strings.CutSuffix(line, "\n")
+	return Op{Kind: kind, Text: text, ALine: aLine, BLine: bLine, NoEOL: !terminated}
+}
+
+// splitLines splits s into lines, keeping their newline terminators so that
+// a missing newline at the end of the text shows up as a change.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
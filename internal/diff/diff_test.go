@@ -0,0 +1,137 @@
+package diff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	a := "FROM node:20\nWORKDIR /app\nCOPY . .\nRUN npm install\nCMD [\"node\", \"index.js\"]\n"
+	b := "FROM node:20-alpine\nWORKDIR /app\nCOPY . .\nRUN npm ci --omit=dev\nCMD [\"node\", \"index.js\"]\n"
+
+	expected := `--- a/Dockerfile
++++ b/Dockerfile
+@@ -1,5 +1,5 @@
+-FROM node:20
++FROM node:20-alpine
+ WORKDIR /app
+ COPY . .
+-RUN npm install
++RUN npm ci --omit=dev
+ CMD ["node", "index.js"]
+`
+	if got := Unified("a/Dockerfile", "b/Dockerfile", a, b); got != expected {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestUnified_Identical(t *testing.T) {
+	if got := Unified("a", "b", "FROM node\n", "FROM node\n"); got != "" {
+		t.Errorf("expected no diff for identical texts, got %q", got)
+	}
+}
+
+func TestHunks_SeparateHunksAndEmptyRanges(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	b := "0\n1\n2\n3\n4\n5\n6\n7\n8\n10\n"
+
+	hunks := Hunks(Lines(a, b), 1)
+	if len(hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %d", len(hunks))
+	}
+	if got := hunks[0].Header(); got != "@@ -1,1 +1,2 @@" {
+		t.Errorf("unexpected header of first hunk: %s", got)
+	}
+	if got := hunks[1].Header(); got != "@@ -8,3 +9,2 @@" {
+		t.Errorf("unexpected header of second hunk: %s", got)
+	}
+}
+
+func TestGitPatch(t *testing.T) {
+	cases := []struct {
+		name, a, b, expected string
+	}{
+		{
+			name: "modified file without trailing newline",
+			a:    "FROM node:20\nCMD [\"node\"]",
+			b:    "FROM node:20-alpine\nCMD [\"node\"]\n",
+			expected: `diff --git a/Dockerfile b/Dockerfile
+--- a/Dockerfile
++++ b/Dockerfile
+@@ -1,2 +1,2 @@
+-FROM node:20
+-CMD ["node"]
+\ No newline at end of file
++FROM node:20-alpine
++CMD ["node"]
+`,
+		},
+		{
+			name: "new file",
+			a:    "",
+			b:    "node_modules\n",
+			expected: `diff --git a/Dockerfile b/Dockerfile
+new file mode 100644
+--- /dev/null
++++ b/Dockerfile
+@@ -0,0 +1,1 @@
++node_modules
+`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := GitPatch("Dockerfile", tc.a, tc.b); got != tc.expected {
+				t.Errorf("unexpected patch:\n%s\nwant:\n%s", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestAssemble(t *testing.T) {
+	a := "FROM node:20\nWORKDIR /app\nCOPY . .\nRUN npm install\nEXPOSE 3000\nENV NODE_ENV=production\nUSER node\nCMD [\"node\", \"index.js\"]\n"
+	b := "FROM node:20-alpine\nWORKDIR /app\nCOPY . .\nRUN npm install\nEXPOSE 3000\nENV NODE_ENV=production\nUSER node\nCMD [\"node\", \"server.js\"]\n"
+
+	hunks := Hunks(Lines(a, b), 1)
+	if len(hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %d", len(hunks))
+	}
+
+	cases := []struct {
+		name         string
+		replacements [][]string
+		expected     string
+	}{
+		{
+			name:         "accept all",
+			replacements: [][]string{hunks[0].BLines(), hunks[1].BLines()},
+			expected:     b,
+		},
+		{
+			name:         "reject all",
+			replacements: [][]string{hunks[0].ALines(), hunks[1].ALines()},
+			expected:     a,
+		},
+		{
+			name:         "accept first, reject second",
+			replacements: [][]string{hunks[0].BLines(), hunks[1].ALines()},
+			expected:     "FROM node:20-alpine\nWORKDIR /app\nCOPY . .\nRUN npm install\nEXPOSE 3000\nENV NODE_ENV=production\nUSER node\nCMD [\"node\", \"index.js\"]\n",
+		},
+		{
+			name:         "edit second",
+			replacements: [][]string{hunks[0].ALines(), {"USER node", "CMD [\"node\", \"app.js\"]"}},
+			expected:     "FROM node:20\nWORKDIR /app\nCOPY . .\nRUN npm install\nEXPOSE 3000\nENV NODE_ENV=production\nUSER node\nCMD [\"node\", \"app.js\"]\n",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Assemble(a, hunks, tc.replacements); got != tc.expected {
+				t.Errorf("Assemble() = %q; want %q", got, tc.expected)
+			}
+		})
+	}
+
+	t.Run("new file", func(t *testing.T) {
+		hunks := Hunks(Lines("", "node_modules\n.git\n"), 3)
+		if got := Assemble("", hunks, [][]string{hunks[0].BLines()}); got != "node_modules\n.git\n" {
+			t.Errorf("Assemble() = %q; want the new file", got)
+		}
+	})
+}
@@ -0,0 +1,215 @@
+// Package distroless plans the migration of the final image of a Dockerfile to a distroless (gcr.io/distroless)
+// or Ubuntu chiseled image. Neither has a shell or a package manager, so everything the application needs at
+// runtime, eg- its interpreter and the shared libraries it loads, has to be copied from the original image.
+package distroless
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+)
+
+// Target is the kind of image the final stage is migrated to
+type Target string
+
+const (
+	TargetDistroless Target = "distroless"
+	// TargetChiseled images are built from scratch with the slices of Ubuntu packages cut by chisel
+	TargetChiseled Target = "chiseled"
+)
+
+// ParseTarget parses the name of a target, eg- given on the command line
+func ParseTarget(s string) (Target, error) {
+	switch t := Target(strings.ToLower(strings.TrimSpace(s))); t {
+	case TargetDistroless, TargetChiseled:
+		return t, nil
+	}
+	return "", fmt.Errorf("unknown migration target %q, must be one of: %s, %s", s, TargetDistroless, TargetChiseled)
+}
+
+// Kinds of runtimes the application can run on
+const (
+	KindNode   = "node"
+	KindPython = "python"
+	KindJava   = "java"
+	// KindBinary is a compiled application, eg- written in Go or Rust
+	KindBinary = "binary"
+)
+
+const (
+	// ChiselBase is the image chisel runs in, its release decides the one slices are cut from
+	ChiselBase    = "ubuntu:24.04"
+	chiselRelease = "ubuntu-24.04"
+	chiselVersion = "v1.1.0"
+
+	distrolessStatic = "gcr.io/distroless/static-debian12"
+	distrolessCC     = "gcr.io/distroless/cc-debian12"
+)
+
+var (
+	// interpreters maps the executables of interpreters to the runtime they're part of
+	interpreters = map[string]string{
+		"node": KindNode, "nodejs": KindNode, "python": KindPython, "python3": KindPython, "java": KindJava,
+	}
+	// runtimeImages maps the official images of runtimes to their kind
+	runtimeImages = map[string]string{
+		"node": KindNode, "python": KindPython, "eclipse-temurin": KindJava, "openjdk": KindJava, "amazoncorretto": KindJava,
+	}
+	// distroless node and java images are only published for these major versions
+	distrolessNode = map[string]bool{"20": true, "22": true, "24": true}
+	distrolessJava = map[string]bool{"17": true, "21": true}
+)
+
+// Runtime describes what runs the application in the image the final stage is migrated to
+type Runtime struct {
+	Kind string
+	// Image is the image the migrated final stage is built from
+	Image string
+	// Interpreter is the path of the interpreter in the migrated image, empty for binaries
+	Interpreter string
+	// Copied are the files of the interpreter copied from the original image, eg- /usr/local for python
+	Copied []string
+	// Env are the ENV variables the interpreter needs, which the original base image set, as KEY=VALUE
+	Env []string
+	// Entrypoint is the ENTRYPOINT of Image, empty if it has none
+	Entrypoint []string
+}
+
+// IsMinimal returns true if the image has no shell to begin with, so there's nothing to migrate
+func IsMinimal(image *dockerfile.Image) bool {
+	name := image.Name()
+	return name == "scratch" || strings.Contains(name, "distroless") || strings.Contains(name, "chisel") || strings.Contains(image.Tag(), "chisel")
+}
+
+// IsInterpreter returns true if the executable is the interpreter of a runtime, eg- "node" or "/usr/local/bin/python3"
+func IsInterpreter(executable string) bool {
+	_, ok := interpreters[path.Base(executable)]
+	return ok
+}
+
+// KindOf returns the kind of runtime the base image provides, KindBinary if it isn't the official image of a runtime
+func KindOf(base *dockerfile.Image) string {
+	if kind, ok := runtimeImages[path.Base(base.Name())]; ok {
+		return kind
+	}
+	return KindBinary
+}
+
+// NewRuntime returns the runtime the final stage built from base is migrated to. static is true if the
+// application is a binary that isn't linked against any shared library. An error explains why the stage
+// can't be migrated, eg- because no distroless image exists for the version of its runtime.
+func NewRuntime(target Target, base *dockerfile.Image, static bool) (*Runtime, error) {
+	if strings.Contains(base.Name(), "alpine") || strings.Contains(base.Tag(), "alpine") {
+		return nil, fmt.Errorf("'%s' is based on alpine, whose binaries are linked against musl and can't run on the glibc of %s images. Build the image from a debian variant first", base.FullName(), target)
+	}
+	kind := KindOf(base)
+	major := majorVersion(base.Tag())
+	r := &Runtime{Kind: kind}
+	switch {
+	case kind == KindNode && target == TargetDistroless:
+		if !distrolessNode[major] {
+			return nil, fmt.Errorf("distroless node images are only published for node 20, 22 and 24, pin the final stage to one of them instead of '%s'", base.FullName())
+		}
+		r.Image, r.Interpreter = fmt.Sprintf("gcr.io/distroless/nodejs%s-debian12", major), "/nodejs/bin/node"
+		r.Entrypoint = []string{r.Interpreter}
+	case kind == KindNode:
+		r.Image, r.Interpreter = "scratch", "/usr/local/bin/node"
+		r.Copied = []string{"/usr/local/bin/node"}
+	case kind == KindPython:
+		// the interpreter of the official image lives in /usr/local along with the packages installed with pip
+		r.Image, r.Interpreter = distrolessCC, "/usr/local/bin/python3"
+		r.Copied = []string{"/usr/local"}
+		r.Env = []string{"LANG=C.UTF-8"}
+	case kind == KindJava && target == TargetDistroless:
+		if !distrolessJava[major] {
+			return nil, fmt.Errorf("distroless java images are only published for java 17 and 21, pin the final stage to one of them instead of '%s'", base.FullName())
+		}
+		r.Image, r.Interpreter = fmt.Sprintf("gcr.io/distroless/java%s-debian12", major), "/usr/bin/java"
+		r.Entrypoint = []string{r.Interpreter, "-jar"}
+	case kind == KindJava:
+		if path.Base(base.Name()) != "eclipse-temurin" {
+			return nil, fmt.Errorf("only the JRE of eclipse-temurin images can be copied into a chiseled image, build the final stage from eclipse-temurin instead of '%s'", base.FullName())
+		}
+		r.Image, r.Interpreter = "scratch", "/opt/java/openjdk/bin/java"
+		r.Copied = []string{"/opt/java/openjdk"}
+		r.Env = []string{"JAVA_HOME=/opt/java/openjdk"}
+	case static:
+		r.Image = distrolessStatic
+	default:
+		r.Image = distrolessCC
+	}
+	if target == TargetChiseled {
+		r.Image = "scratch"
+	}
+	return r, nil
+}
+
+// Slices returns the slices of Ubuntu packages the chiseled image needs, with the CA certificates
+// and time zone database if the application uses them
+func (r *Runtime) Slices(certs, tzdata bool) []string {
+	slices := []string{"base-files_base", "base-files_release-info", "libc6_libs", "libgcc-s1_libs", "libstdc++6_libs"}
+	switch r.Kind {
+	case KindPython:
+		// loaded by the modules of the standard library, eg- ssl, zlib and ctypes
+		slices = append(slices, "libssl3t64_libs", "zlib1g_libs", "libffi8_libs")
+	case KindJava:
+		slices = append(slices, "zlib1g_libs")
+	}
+	if certs {
+		slices = append(slices, "ca-certificates_data")
+	}
+	if tzdata {
+		slices = append(slices, "tzdata_zoneinfo")
+	}
+	return slices
+}
+
+// ChiselStage returns the code of a build stage that cuts the slices into /rootfs with chisel.
+// The chiseled final stage copies /rootfs from it.
+func ChiselStage(name string, slices []string) string {
+	lines := []string{
+		fmt.Sprintf("FROM %s AS %s", ChiselBase, name),
+		"ARG TARGETARCH",
+		"ARG CHISEL_VERSION=" + chiselVersion,
+		"ADD https://github.com/canonical/chisel/releases/download/${CHISEL_VERSION}/chisel_${CHISEL_VERSION}_linux_${TARGETARCH}.tar.gz /tmp/chisel.tar.gz",
+		"RUN apt-get update && apt-get install -y --no-install-recommends ca-certificates && rm -rf /var/lib/apt/lists/* && \\",
+		"    tar -xzf /tmp/chisel.tar.gz -C /usr/bin && \\",
+		"    mkdir /rootfs && \\",
+		fmt.Sprintf("    chisel cut --release %s --root /rootfs %s", chiselRelease, strings.Join(slices, " ")),
+	}
+	return strings.Join(lines, dockerfile.Linebreak)
+}
+
+// providedLibraries are the prefixes of the shared libraries every distroless and chiseled image built by
+// dockershrink has, except static ones: glibc, libgcc and libstdc++. They must never be copied from an image of
+// another distribution, since they have to match the dynamic loader.
+var providedLibraries = []string{
+	"ld-linux", "ld64.so", "libc.so", "libm.so", "libmvec.so", "libpthread.so", "libdl.so", "librt.so", "libresolv.so",
+	"libutil.so", "libanl.so", "libnss_", "libgcc_s.so", "libstdc++.so",
+}
+
+// IsProvided returns true if the migrated image already has the shared library
+func (r *Runtime) IsProvided(library string) bool {
+	if r.Image == distrolessStatic {
+		return false
+	}
+	name := path.Base(library)
+	for _, prefix := range providedLibraries {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// majorVersion returns the major version a tag starts with, eg- "22" for "22.4-bookworm-slim".
+// It's empty for tags without a version, eg- "lts" or "latest".
+func majorVersion(tag string) string {
+	end := 0
+	for end < len(tag) && tag[end] >= '0' && tag[end] <= '9' {
+		end++
+	}
+	return tag[:end]
+}
@@ -0,0 +1,66 @@
+package distroless
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+)
+
+func TestNewRuntime(t *testing.T) {
+	tests := []struct {
+		target      Target
+		base        string
+		static      bool
+		image       string
+		interpreter string
+		err         bool
+	}{
+		{TargetDistroless, "node:22-bookworm-slim", false, "gcr.io/distroless/nodejs22-debian12", "/nodejs/bin/node", false},
+		{TargetDistroless, "node:lts", false, "", "", true},
+		{TargetDistroless, "node:22-alpine", false, "", "", true},
+		{TargetChiseled, "node:18", false, "scratch", "/usr/local/bin/node", false},
+		{TargetDistroless, "python:3.12-slim", false, "gcr.io/distroless/cc-debian12", "/usr/local/bin/python3", false},
+		{TargetDistroless, "eclipse-temurin:21-jre", false, "gcr.io/distroless/java21-debian12", "/usr/bin/java", false},
+		{TargetChiseled, "amazoncorretto:21", false, "", "", true},
+		{TargetDistroless, "debian:12-slim", true, "gcr.io/distroless/static-debian12", "", false},
+		{TargetDistroless, "ubuntu:24.04", false, "gcr.io/distroless/cc-debian12", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.target)+" "+tt.base, func(t *testing.T) {
+			r, err := NewRuntime(tt.target, dockerfile.NewImage(tt.base), tt.static)
+			if (err != nil) != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if r.Image != tt.image || r.Interpreter != tt.interpreter {
+				t.Errorf("expected %s with %q, got %s with %q", tt.image, tt.interpreter, r.Image, r.Interpreter)
+			}
+		})
+	}
+}
+
+func TestParseLdd(t *testing.T) {
+	output := `	linux-vdso.so.1 (0x00007ffd8b5f2000)
+	libpq.so.5 => /usr/lib/x86_64-linux-gnu/libpq.so.5 (0x00007f0e6c4a1000)
+	libc.so.6 => /lib/x86_64-linux-gnu/libc.so.6 (0x00007f0e6c2c0000)
+	/lib64/ld-linux-x86-64.so.2 (0x00007f0e6c52a000)
+`
+	libs, static, err := ParseLdd(output)
+	if err != nil || static {
+		t.Fatalf("expected a dynamic executable, got static=%v err=%v", static, err)
+	}
+	expected := []string{"/usr/lib/x86_64-linux-gnu/libpq.so.5", "/lib/x86_64-linux-gnu/libc.so.6", "/lib64/ld-linux-x86-64.so.2"}
+	if !reflect.DeepEqual(libs, expected) {
+		t.Errorf("expected %v, got %v", expected, libs)
+	}
+
+	if _, static, err := ParseLdd("\tnot a dynamic executable\n"); err != nil || !static {
+		t.Errorf("expected a static executable, got static=%v err=%v", static, err)
+	}
+	if _, _, err := ParseLdd("\tlibvips.so.42 => not found\n\tlibc.so.6 => /lib/libc.so.6 (0x1)\n"); err == nil {
+		t.Errorf("expected an error for a missing library")
+	}
+}
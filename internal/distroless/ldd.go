@@ -0,0 +1,89 @@
+package distroless
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/sandbox"
+)
+
+// lddTimeout is how long ldd may run in the original image
+const lddTimeout = time.Minute
+
+// lddScript resolves the executable passed as its first argument through PATH, the way Docker does, and lists the
+// shared libraries it loads
+const lddScript = `exe=$(command -v "$1") || { echo "$1: not found" >&2; exit 127; }; ldd "$exe"`
+
+// LibraryResolver finds the shared libraries an executable of the original final image loads.
+// An empty list with no error means the executable is statically linked.
+type LibraryResolver interface {
+	SharedLibraries(ctx context.Context, executable string) ([]string, error)
+}
+
+// ImageLibraries resolves shared libraries by running ldd in a sandboxed container of the original image
+type ImageLibraries struct {
+	Sandbox *sandbox.Sandbox
+	// Image is the ID or reference of the image built from the original Dockerfile
+	Image string
+}
+
+func (r *ImageLibraries) SharedLibraries(ctx context.Context, executable string) ([]string, error) {
+	limits := sandbox.DefaultLimits()
+	limits.Timeout = lddTimeout
+	res, err := r.Sandbox.Run(ctx, &sandbox.Spec{
+		Image:      r.Image,
+		Entrypoint: "/bin/sh",
+		Command:    []string{"-c", lddScript, "ldd", executable},
+		Limits:     limits,
+	})
+	if err != nil {
+		return nil, err
+	}
+	libraries, static, err := ParseLdd(res.Stdout + res.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	if !static && !res.Succeeded() {
+		return nil, fmt.Errorf("ldd %s exited with status %d: %s", executable, res.ExitCode, strings.TrimSpace(res.Stderr))
+	}
+	return libraries, nil
+}
+
+// ParseLdd returns the paths of the shared libraries listed in the output of ldd, including the dynamic loader.
+// static is true if ldd reports that the executable isn't dynamically linked. Libraries ldd can't find are
+// returned as an error, since the executable doesn't run without them.
+func ParseLdd(output string) (libraries []string, static bool, err error) {
+	missing := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.Contains(line, "not a dynamic executable") || strings.Contains(line, "statically linked") {
+			return []string{}, true, nil
+		}
+		name, location, resolved := strings.Cut(line, "=>")
+		if !resolved {
+			location = name
+		}
+		location = strings.TrimSpace(location)
+		if location == "not found" {
+			missing = append(missing, strings.TrimSpace(name))
+			continue
+		}
+		// the address ldd prints after every library, eg- "(0x00007f...)"
+		if i := strings.Index(location, " ("); i >= 0 {
+			location = location[:i]
+		}
+		// linux-vdso.so.1 is provided by the kernel and has no path
+		if strings.HasPrefix(location, "/") {
+			libraries = append(libraries, location)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, false, fmt.Errorf("ldd can't find %s", strings.Join(missing, ", "))
+	}
+	if libraries == nil {
+		return nil, false, fmt.Errorf("unexpected output of ldd: %s", strings.TrimSpace(output))
+	}
+	return libraries, false, nil
+}
@@ -0,0 +1,244 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ErrNotFound is returned when the docker CLI is not installed
+var ErrNotFound = errors.New("docker CLI not found in PATH")
+
+// Client talks to the local Docker daemon through the docker CLI.
+// Shelling out keeps dockershrink compatible with whatever daemon, context
+// and credentials the user has already configured.
+type Client struct {
+	bin string
+}
+
+// NewClient returns a client that uses the docker binary found in PATH
+func NewClient() (*Client, error) {
+	bin, err := exec.LookPath("docker")
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return &Client{bin: bin}, nil
+}
+
+// Command returns the command that runs the docker CLI with the given arguments
+func (c *Client) Command(ctx context.Context, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, c.bin, args...)
+}
+
+// Output runs the docker CLI with the given arguments and returns its trimmed stdout.
+// If the command fails, its stderr is included in the returned error.
+func (c *Client) Output(ctx context.Context, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := c.Command(ctx, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("docker %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Ping returns an error if the Docker daemon is not reachable
+func (c *Client) Ping(ctx context.Context) error {
+	if _, err := c.Output(ctx, "version", "--format", "{{.Server.Version}}"); err != nil {
+		return fmt.Errorf("Docker daemon is not reachable: %w", err)
+	}
+	return nil
+}
+
+// BuildOptions describe an image build
+type BuildOptions struct {
+	// ContextDir is the build context sent to the daemon
+	ContextDir string
+	// Dockerfile is the path to the Dockerfile, it may be outside the build context
+	Dockerfile string
+	// Tag is optional, images are only identified by their ID if it's empty
+	Tag string
+	// BuildContexts are additional named build contexts as NAME=VALUE, eg- lib=/src/lib
+	BuildContexts []string
+	// Secrets are passed to the build as the values of --secret, eg- id=npm_token,env=NPM_TOKEN
+	Secrets []string
+	// Progress is optional, it's called with every line of build output as the build runs
+	Progress func(line string)
+}
+
+// BuildResult is the outcome of an image build
+type BuildResult struct {
+	// ImageID is only set if the build succeeded
+	ImageID string
+	// Output is the combined stdout and stderr of the build
+	Output string
+	// Err is set if the build failed
+	Err error
+}
+
+// Build builds an image using BuildKit.
+// A failing build is reported through BuildResult.Err, the returned error is only set
+// if the build could not be run at all.
+func (c *Client) Build(ctx context.Context, opts *BuildOptions) (*BuildResult, error) {
+	iidFile, err := os.CreateTemp("", "dockershrink-iid-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create image ID file: %w", err)
+	}
+	iidFile.Close()
+	defer os.Remove(iidFile.Name())
+
+	args := []string{"build", "--progress", "plain", "--iidfile", iidFile.Name(), "--file", opts.Dockerfile}
+	if opts.Tag != "" {
+		args = append(args, "--tag", opts.Tag)
+	}
+	for _, c := range opts.BuildContexts {
+		args = append(args, "--build-context", c)
+	}
+	for _, s := range opts.Secrets {
+		args = append(args, "--secret", s)
+	}
+	args = append(args, opts.ContextDir)
+
+	var output bytes.Buffer
+	var w io.Writer = &output
+	if opts.Progress != nil {
+		progress := &lineWriter{fn: opts.Progress}
+		defer progress.flush()
+		w = io.MultiWriter(&output, progress)
+	}
+	cmd := c.Command(ctx, args...)
+	cmd.Env = append(os.Environ(), "DOCKER_BUILDKIT=1")
+	cmd.Stdout, cmd.Stderr = w, w
+
+	result := &BuildResult{}
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("failed to run docker build: %w", err)
+		}
+		result.Output = output.String()
+		result.Err = fmt.Errorf("docker build exited with status %d", exitErr.ExitCode())
+		return result, nil
+	}
+
+	id, err := os.ReadFile(iidFile.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read built image ID: %w", err)
+	}
+	result.ImageID = strings.TrimSpace(string(id))
+	result.Output = output.String()
+	return result, nil
+}
+
+// ImageSize returns the size of an image in bytes
+func (c *Client) ImageSize(ctx context.Context, image string) (int64, error) {
+	out, err := c.Output(ctx, "image", "inspect", "--format", "{{.Size}}", image)
+	if err != nil {
+		return 0, err
+	}
+	size, err := strconv.ParseInt(out, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected image size %q: %w", out, err)
+	}
+	return size, nil
+}
+
+// Layer is an entry in the history of an image.
+// Entries created by metadata instructions like ENV or CMD have a size of 0.
+type Layer struct {
+	CreatedBy string
+	Size      int64
+}
+
+// History returns the layers of an image, oldest first
+func (c *Client) History(ctx context.Context, image string) ([]*Layer, error) {
+	// json output because CreatedBy contains newlines for heredocs
+	out, err := c.Output(ctx, "image", "history", "--no-trunc", "--human=false", "--format", "{{json .}}", image)
+	if err != nil {
+		return nil, err
+	}
+	return parseHistory(out)
+}
+
+func parseHistory(out string) ([]*Layer, error) {
+	layers := []*Layer{}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var entry struct {
+			CreatedBy string
+			Size      string
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("unexpected image history entry %q: %w", line, err)
+		}
+		size, err := strconv.ParseInt(entry.Size, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected layer size %q: %w", entry.Size, err)
+		}
+		layers = append(layers, &Layer{CreatedBy: entry.CreatedBy, Size: size})
+	}
+
+	// docker lists the newest layer first
+	for i, j := 0, len(layers)-1; i < j; i, j = i+1, j-1 {
+		layers[i], layers[j] = layers[j], layers[i]
+	}
+	return layers, nil
+}
+
+// ImageExists returns true if the image is available locally
+func (c *Client) ImageExists(ctx context.Context, image string) bool {
+	return c.Command(ctx, "image", "inspect", "--format", "{{.Id}}", image).Run() == nil
+}
+
+// Pull pulls an image from its registry
+func (c *Client) Pull(ctx context.Context, image string) error {
+	_, err := c.Output(ctx, "image", "pull", "--quiet", image)
+	return err
+}
+
+// Save exports an image, including the contents of all its layers, to a tar archive at path
+func (c *Client) Save(ctx context.Context, image, path string) error {
+	_, err := c.Output(ctx, "image", "save", "--output", path, image)
+	return err
+}
+
+// RemoveImage deletes an image, ignoring any errors
+func (c *Client) RemoveImage(ctx context.Context, image string) {
+	_ = c.Command(ctx, "image", "rm", "--force", image).Run()
+}
+
+// lineWriter calls fn with every complete line written to it
+type lineWriter struct {
+	fn  func(line string)
+	buf []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.fn(strings.TrimSuffix(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush calls fn with the last line if it isn't terminated by a line break
+func (w *lineWriter) flush() {
+	if len(w.buf) > 0 {
+		w.fn(string(w.buf))
+		w.buf = nil
+	}
+}
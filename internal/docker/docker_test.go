@@ -0,0 +1,23 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLineWriter(t *testing.T) {
+	var lines []string
+	w := &lineWriter{fn: func(line string) { lines = append(lines, line) }}
+
+	for _, chunk := range []string{"#1 [internal] load", " build definition\r\n#2 DONE", " 0.1s\n\n#3 CACHED"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	w.flush()
+
+	expected := []string{"#1 [internal] load build definition", "#2 DONE 0.1s", "", "#3 CACHED"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %q, got %q", expected, lines)
+	}
+}
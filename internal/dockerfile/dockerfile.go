@@ -3,45 +3,64 @@ package dockerfile
 import (
 	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/moby/buildkit/frontend/dockerfile/parser"
 )
 
 const (
-	CmdFrom string = "FROM"
-	CmdRun         = "RUN"
-	CmdCopy        = "COPY"
+	CmdFrom        string = "FROM"
+	CmdRun                = "RUN"
+	CmdCopy               = "COPY"
+	CmdAdd                = "ADD"
+	CmdEnv                = "ENV"
+	CmdArg                = "ARG"
+	CmdWorkdir            = "WORKDIR"
+	CmdCmd                = "CMD"
+	CmdEntrypoint         = "ENTRYPOINT"
+	CmdUser               = "USER"
+	CmdExpose             = "EXPOSE"
+	CmdHealthcheck        = "HEALTHCHECK"
+	CmdLabel              = "LABEL"
+	CmdOnbuild            = "ONBUILD"
+	CmdShell              = "SHELL"
 )
 
 const Linebreak = "\n"
 
 // Dockerfile provides APIs to programmatically perform Read-Write on Dockerfiles.
 type Dockerfile struct {
-	code string
-	ast  *parser.Node
+	code        string
+	ast         *parser.Node
+	escapeToken rune
+	// buildArgs override the values of ARGs, like --build-arg
+	buildArgs vars
 }
 
+// NewDockerfile parses the given code. If the code has syntax errors, the returned error is SyntaxErrors.
 func NewDockerfile(contents string) (*Dockerfile, error) {
 	if len(strings.TrimSpace(contents)) == 0 {
 		return nil, errors.New("Dockerfile is empty")
 	}
 	result, err := parse(contents)
-	if err != nil {
-		return nil, err
+	if errs := checkSyntax(contents, result, err); len(errs) > 0 {
+		return nil, errs
 	}
 	return &Dockerfile{
-		code: contents,
-		ast:  result.AST,
+		code:        contents,
+		ast:         result.AST,
+		escapeToken: result.EscapeToken,
 	}, nil
 }
 
 // Validate validates the given Dockerfile code
 // This function is not fool-proof and may not detect syntactical errors in the Dockerfile.
-// the moby parser is permissive by design so this function needs to build custom checks
-// for further validation.
+// the moby parser is permissive by design, so on top of parsing, the code is checked for
+// unknown instructions, instructions before FROM, missing arguments and malformed JSON arrays.
 func Validate(code string) (bool, error) {
-	_, err := parse(code)
+	_, err := NewDockerfile(code)
 	return err == nil, err
 }
 
@@ -57,7 +76,7 @@ func (d *Dockerfile) Raw() string {
 func (d *Dockerfile) GetStageCount() uint {
 	count := 0
 	for _, child := range d.ast.Children {
-		if child.Value == CmdFrom {
+		if isFrom(child) {
 			count++
 		}
 	}
@@ -66,25 +85,173 @@ func (d *Dockerfile) GetStageCount() uint {
 
 // GetFinalStage returns the last stage in the Dockerfile
 func (d *Dockerfile) GetFinalStage() (*Stage, error) {
-	var lastStageNode *parser.Node
-	lastStageNodeIndex := -1
-	lastStageIndex := -1
+	stages := d.GetStages()
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("No stages found in Dockerfile: %s", d.code)
+	}
+	return stages[len(stages)-1], nil
+}
 
-	for i, child := range d.ast.Children {
-		if child.Value == CmdFrom {
-			lastStageNode = child
-			lastStageIndex++
-			lastStageNodeIndex = i
+// GetStage returns the stage with the given name, or at the given 0-based index
+// the way "COPY --from" and "FROM <stage>" refer to stages. nil is returned if there is no such stage.
+func (d *Dockerfile) GetStage(ref string) *Stage {
+	stages := d.GetStages()
+	for _, s := range stages {
+		if s.Name() != "" && strings.EqualFold(s.Name(), ref) {
+			return s
 		}
 	}
-	if lastStageNodeIndex < 0 || lastStageIndex < 0 {
-		return nil, fmt.Errorf("No stages found in Dockerfile: %s", d.code)
+	if i, err := strconv.Atoi(ref); err == nil && i >= 0 && i < len(stages) {
+		return stages[i]
 	}
-	return &Stage{
-		nodeIndex:  uint(lastStageNodeIndex),
-		stageIndex: uint(lastStageIndex),
-		astNode:    lastStageNode,
-	}, nil
+	return nil
+}
+
+// GetBaseStage returns the earlier stage that the given stage is built from, eg- "FROM build AS test".
+// nil is returned if the stage is built from an image.
+func (d *Dockerfile) GetBaseStage(stage *Stage) *Stage {
+	name := stage.base
+	for _, s := range d.GetStages() {
+		if s.Index() >= stage.Index() {
+			break
+		}
+		if s.Name() != "" && strings.EqualFold(s.Name(), name) {
+			return s
+		}
+	}
+	return nil
+}
+
+// GetOnbuildTriggers returns the instructions registered with ONBUILD in the stage the given stage is built from.
+// They run at the start of the stage, before its own instructions. Triggers of images outside the Dockerfile aren't known.
+func (d *Dockerfile) GetOnbuildTriggers(stage *Stage) []*Instruction {
+	base := d.GetBaseStage(stage)
+	if base == nil {
+		return nil
+	}
+	triggers := []*Instruction{}
+	for _, ins := range base.Instructions() {
+		if trigger := ins.Trigger(); trigger != nil {
+			trigger.vars = stage.env
+			triggers = append(triggers, trigger)
+		}
+	}
+	return triggers
+}
+
+// GetGlobalArgs returns the ARG instructions declared before the first FROM.
+// They can be used in FROM instructions, eg- "FROM node:${NODE_VERSION}".
+func (d *Dockerfile) GetGlobalArgs() []*Instruction {
+	args := []*Instruction{}
+	for _, child := range d.ast.Children {
+		if isFrom(child) {
+			break
+		}
+		args = append(args, &Instruction{node: child, escapeToken: d.escapeToken})
+	}
+	return args
+}
+
+// EscapeToken returns the escape character of the Dockerfile, set with the "# escape=" parser directive
+func (d *Dockerfile) EscapeToken() rune {
+	return d.escapeToken
+}
+
+// Syntax returns the frontend image set with the "# syntax=" parser directive, empty string if it isn't set
+func (d *Dockerfile) Syntax() string {
+	syntax, _, _, _ := parser.DetectSyntax([]byte(d.code))
+	return syntax
+}
+
+// SyntaxLine returns the line number of the "# syntax=" parser directive, 0 if it isn't set
+func (d *Dockerfile) SyntaxLine() int {
+	_, _, loc, ok := parser.DetectSyntax([]byte(d.code))
+	if !ok || len(loc) == 0 {
+		return 0
+	}
+	return loc[0].Start.Line
+}
+
+// syntaxVersionRegex matches the version of the official Dockerfile frontend, eg- "docker/dockerfile:1.1"
+var syntaxVersionRegex = regexp.MustCompile(`^(?:docker\.io/)?docker/dockerfile(?:-upstream)?:(\d+)(?:\.(\d+))?`)
+
+// SyntaxAtLeast returns false if the syntax directive pins a version of the official Dockerfile frontend
+// that's older than major.minor. Experimental and labs releases, other frontends and Dockerfiles without
+// a directive are assumed to be recent enough, since their version can't be told from the directive.
+func SyntaxAtLeast(syntax string, major, minor int) bool {
+	m := syntaxVersionRegex.FindStringSubmatch(syntax)
+	if m == nil || strings.Contains(syntax, "experimental") || strings.Contains(syntax, "labs") {
+		return true
+	}
+	pinnedMajor, _ := strconv.Atoi(m[1])
+	if m[2] == "" {
+		// eg- "docker/dockerfile:1" is the latest 1.x release
+		return pinnedMajor >= major
+	}
+	pinnedMinor, _ := strconv.Atoi(m[2])
+	return pinnedMajor > major || (pinnedMajor == major && pinnedMinor >= minor)
+}
+
+// SetSyntax sets the frontend image of the "# syntax=" parser directive, adding the directive
+// at the top of the Dockerfile if it isn't set
+func (d *Dockerfile) SetSyntax(syntax string) error {
+	directive := "# syntax=" + syntax
+	line := d.SyntaxLine()
+	if line == 0 {
+		return d.setCode(directive + Linebreak + d.code)
+	}
+	codeLines := strings.Split(d.code, Linebreak)
+	codeLines[line-1] = directive
+	return d.setCode(strings.Join(codeLines, Linebreak))
+}
+
+// GetStages returns all the stages in the Dockerfile in order of declaration.
+// The variables in effect for every instruction are tracked, so that references to them can be expanded.
+// A stage inherits the ENV variables of the stage it's built from, but not its ARGs.
+func (d *Dockerfile) GetStages() []*Stage {
+	global := d.globalArgs()
+	stages := []*Stage{}
+	var current *Stage
+	var args, env vars
+	// stageEnv is the ENV variables every stage ends with
+	stageEnv := []vars{}
+	for i, child := range d.ast.Children {
+		if isFrom(child) {
+			if current != nil {
+				stageEnv = append(stageEnv, env)
+			}
+			current = &Stage{
+				nodeIndex:  uint(i),
+				stageIndex: uint(len(stages)),
+				astNode:    child,
+				base:       child.Next.Value,
+				env:        vars{},
+			}
+			if base := expand(child.Next.Value, global, d.escapeToken); !strings.Contains(base, "$") && base != "" {
+				current.base = base
+			}
+			for si, s := range stages {
+				if s.Name() != "" && strings.EqualFold(s.Name(), current.base) {
+					current.env = stageEnv[si].clone()
+				}
+			}
+			stages = append(stages, current)
+			args, env = vars{}, current.env.clone()
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		in := args.merge(env)
+		current.instructions = append(current.instructions, &Instruction{node: child, vars: in, escapeToken: d.escapeToken})
+		switch strings.ToUpper(child.Value) {
+		case CmdArg:
+			declareArgs(child, args, global, in, d.buildArgs, d.escapeToken)
+		case CmdEnv:
+			declareEnv(child, env, in, d.escapeToken)
+		}
+	}
+	return stages
 }
 
 // SetStageBaseImage sets the base image for a given stage in the Dockerfile
@@ -103,4 +270,57 @@ func (d *Dockerfile) SetStageBaseImage(stage *Stage, image *Image) {
 
 	d.code = modifiedCode
 	d.ast = parsed.AST
+	d.escapeToken = parsed.EscapeToken
+}
+
+// SetStageName names an unnamed stage with "AS", so that other stages can refer to it, eg- with "COPY --from"
+func (d *Dockerfile) SetStageName(stage *Stage, name string) error {
+	if stage.Name() != "" {
+		return fmt.Errorf("stage on line %d is already named %s", stage.StartLine(), stage.Name())
+	}
+	codeLines := strings.Split(d.code, Linebreak)
+	end := stage.astNode.EndLine
+	if end < 1 || end > len(codeLines) {
+		return fmt.Errorf("stage on line %d is not part of the Dockerfile", stage.StartLine())
+	}
+	codeLines[end-1] = strings.TrimRight(codeLines[end-1], " \t") + " AS " + name
+	return d.setCode(strings.Join(codeLines, Linebreak))
+}
+
+// ReplaceInstruction replaces all the lines of the given instruction with code
+func (d *Dockerfile) ReplaceInstruction(ins *Instruction, code string) error {
+	codeLines := strings.Split(d.code, Linebreak)
+	start, end := ins.StartLine()-1, ins.EndLine()
+	if start < 0 || end > len(codeLines) {
+		return fmt.Errorf("instruction on line %d is not part of the Dockerfile", ins.StartLine())
+	}
+	modified := append([]string{}, codeLines[:start]...)
+	modified = append(modified, code)
+	modified = append(modified, codeLines[end:]...)
+	return d.setCode(strings.Join(modified, Linebreak))
+}
+
+// InsertAfter inserts code after the given 1-based line number
+func (d *Dockerfile) InsertAfter(line int, code string) error {
+	codeLines := strings.Split(d.code, Linebreak)
+	if line < 0 || line > len(codeLines) {
+		return fmt.Errorf("line %d is outside the Dockerfile", line)
+	}
+	modified := append([]string{}, codeLines[:line]...)
+	modified = append(modified, code)
+	modified = append(modified, codeLines[line:]...)
+	return d.setCode(strings.Join(modified, Linebreak))
+}
+
+// setCode replaces the code of the Dockerfile. Stages and instructions obtained
+// before calling this function are stale afterwards.
+func (d *Dockerfile) setCode(code string) error {
+	parsed, err := parse(code)
+	if err != nil {
+		return err
+	}
+	d.code = code
+	d.ast = parsed.AST
+	d.escapeToken = parsed.EscapeToken
+	return nil
 }
@@ -1,6 +1,7 @@
 package dockerfile
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 
@@ -20,23 +21,20 @@ RUN echo "Hello World"`
 		}
 	})
 
-	// TODO: enable this test once Validate() can detect dyntactical errors in Dockerfile
-	/*
-			t.Run("Syntax Error", func(t *testing.T) {
-				syntaxErrorDockerfile := `"""
-		FROM node:18-alpine
-		RUN echo "Hello World"
-		"""
-		Some random gibberish text`
-				ok, err := Validate(syntaxErrorDockerfile)
-				if err == nil {
-					t.Fatal("expected an error for Dockerfile with syntax error, got nil")
-				}
-				if ok {
-					t.Fatal("expected Validate to return false for Dockerfile with syntax error")
-				}
-			})
-	*/
+	t.Run("Syntax Error", func(t *testing.T) {
+		syntaxErrorDockerfile := `"""
+FROM node:18-alpine
+RUN echo "Hello World"
+"""
+Some random gibberish text`
+		ok, err := Validate(syntaxErrorDockerfile)
+		if err == nil {
+			t.Fatal("expected an error for Dockerfile with syntax error, got nil")
+		}
+		if ok {
+			t.Fatal("expected Validate to return false for Dockerfile with syntax error")
+		}
+	})
 }
 
 func TestDockerfile_GetStageCount(t *testing.T) {
@@ -116,3 +114,312 @@ FROM python:3.11
 		t.Errorf("expected updated final stage image 'alpine:latest', got '%s'", updatedStage.BaseImage().FullName())
 	}
 }
+
+func TestDockerfile_SetStageName(t *testing.T) {
+	df, err := NewDockerfile("FROM node:22 AS build\nRUN npm ci\nFROM --platform=$TARGETPLATFORM \\\n  node:22-slim\nCMD [\"node\", \"server.js\"]\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stages := df.GetStages()
+	if err := df.SetStageName(stages[0], "other"); err == nil {
+		t.Errorf("expected an error for a named stage")
+	}
+	if err := df.SetStageName(stages[1], "runtime"); err != nil {
+		t.Fatal(err)
+	}
+	expected := "FROM node:22 AS build\nRUN npm ci\nFROM --platform=$TARGETPLATFORM \\\n  node:22-slim AS runtime\nCMD [\"node\", \"server.js\"]\n"
+	if df.Raw() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, df.Raw())
+	}
+	if s := df.GetStage("runtime"); s == nil || s.Index() != 1 {
+		t.Errorf("expected the final stage to be named runtime")
+	}
+}
+
+func TestDockerfile_GetStages(t *testing.T) {
+	df, err := NewDockerfile(`FROM node:18 AS build
+WORKDIR /app
+RUN --mount=type=cache,target=/root/.npm npm ci
+from node:18-alpine
+COPY --from=build /app/dist /app
+CMD ["node", "/app/main.js"]
+`)
+	if err != nil {
+		t.Fatalf("failed to create Dockerfile: %v", err)
+	}
+
+	stages := df.GetStages()
+	if len(stages) != 2 {
+		t.Fatalf("expected 2 stages, got %d", len(stages))
+	}
+	if stages[0].Name() != "build" || stages[1].Name() != "" {
+		t.Errorf("unexpected stage names %q and %q", stages[0].Name(), stages[1].Name())
+	}
+	if len(stages[0].Instructions()) != 2 || len(stages[1].Instructions()) != 2 {
+		t.Fatalf("unexpected number of instructions in stages")
+	}
+
+	run := stages[0].Instructions()[1]
+	if run.Cmd() != CmdRun || run.StartLine() != 3 {
+		t.Errorf("expected RUN on line 3, got %s on line %d", run.Cmd(), run.StartLine())
+	}
+	if v, ok := run.Flag("mount"); !ok || v != "type=cache,target=/root/.npm" {
+		t.Errorf("unexpected value of --mount flag: %q", v)
+	}
+
+	copyInst := stages[1].Instructions()[0]
+	if from, _ := copyInst.Flag("from"); from != "build" {
+		t.Errorf("expected COPY --from=build, got %q", from)
+	}
+	if cmd := stages[1].Instructions()[1]; !cmd.IsJSONForm() || len(cmd.Args()) != 2 {
+		t.Errorf("expected CMD in exec form with 2 args, got %v", cmd.Args())
+	}
+}
+
+func TestDockerfile_GetStage(t *testing.T) {
+	df, err := NewDockerfile(`ARG NODE_VERSION=20
+FROM node:${NODE_VERSION} AS Base
+FROM base AS build
+RUN npm run build
+FROM node:${NODE_VERSION}-alpine
+COPY --from=build /app/dist /app
+`)
+	if err != nil {
+		t.Fatalf("failed to create Dockerfile: %v", err)
+	}
+
+	tests := []struct {
+		ref      string
+		expected int
+	}{
+		{"base", 0},
+		{"BUILD", 1},
+		{"2", 2},
+		{"3", -1},
+		{"test", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			s := df.GetStage(tt.ref)
+			if tt.expected < 0 {
+				if s != nil {
+					t.Errorf("expected no stage, got stage %d", s.Index())
+				}
+				return
+			}
+			if s == nil || int(s.Index()) != tt.expected {
+				t.Errorf("expected stage %d, got %v", tt.expected, s)
+			}
+		})
+	}
+
+	stages := df.GetStages()
+	if base := df.GetBaseStage(stages[1]); base == nil || base.Index() != 0 {
+		t.Errorf("expected build to be based on stage 0, got %v", base)
+	}
+	if base := df.GetBaseStage(stages[2]); base != nil {
+		t.Errorf("expected final stage to be based on an image, got stage %d", base.Index())
+	}
+
+	args := df.GetGlobalArgs()
+	if len(args) != 1 || args[0].Args()[0] != "NODE_VERSION=20" {
+		t.Errorf("expected global ARG NODE_VERSION=20, got %d args", len(args))
+	}
+}
+
+func TestDockerfile_Directives(t *testing.T) {
+	df, err := NewDockerfile("# syntax=docker/dockerfile:1.7\n# escape=`\nFROM node:20\nRUN npm ci `\n  --omit=dev\n")
+	if err != nil {
+		t.Fatalf("failed to create Dockerfile: %v", err)
+	}
+	if df.Syntax() != "docker/dockerfile:1.7" {
+		t.Errorf("expected syntax docker/dockerfile:1.7, got %q", df.Syntax())
+	}
+	if df.SyntaxLine() != 1 {
+		t.Errorf("expected the syntax directive on line 1, got %d", df.SyntaxLine())
+	}
+	if df.EscapeToken() != '`' {
+		t.Errorf("expected escape token `, got %q", df.EscapeToken())
+	}
+	if run := df.GetStages()[0].Instructions()[0]; run.EndLine() != 5 {
+		t.Errorf("expected RUN to end on line 5, got %d", run.EndLine())
+	}
+}
+
+func TestInstruction_Heredocs(t *testing.T) {
+	df, err := NewDockerfile(`FROM node:20
+# install dependencies
+RUN <<EOF
+npm ci --omit=dev
+npm cache clean --force
+EOF
+COPY <<-'CONF' /etc/app.conf
+	port=3000
+CONF
+`)
+	if err != nil {
+		t.Fatalf("failed to create Dockerfile: %v", err)
+	}
+
+	instructions := df.GetStages()[0].Instructions()
+	run, cp := instructions[0], instructions[1]
+	if comments := run.Comments(); len(comments) != 1 || comments[0] != "install dependencies" {
+		t.Errorf("unexpected comments %q", comments)
+	}
+
+	heredocs := run.Heredocs()
+	if len(heredocs) != 1 || heredocs[0].Name != "EOF" || !heredocs[0].Expand {
+		t.Fatalf("unexpected heredocs %+v", heredocs)
+	}
+	if cmd := run.Command(); !strings.Contains(cmd, "npm ci --omit=dev") || !strings.Contains(cmd, "npm cache clean") {
+		t.Errorf("expected command to include the heredoc script, got %q", cmd)
+	}
+
+	heredocs = cp.Heredocs()
+	if len(heredocs) != 1 || heredocs[0].Name != "CONF" || heredocs[0].Expand || !heredocs[0].Chomp {
+		t.Fatalf("unexpected heredocs %+v", heredocs)
+	}
+	if cmd := cp.Command(); strings.Contains(cmd, "port=3000") {
+		t.Errorf("expected COPY command to exclude the heredoc content, got %q", cmd)
+	}
+}
+
+func TestDockerfile_Expansion(t *testing.T) {
+	d, err := NewDockerfile(`ARG NODE_VERSION=20
+ARG BASE=node:${NODE_VERSION}-alpine
+ARG VARIANT
+FROM ${BASE} AS build
+ARG NODE_VERSION
+ENV APP_DIR=/srv/app
+WORKDIR $APP_DIR
+COPY <<EOF ${APP_DIR}/.npmrc
+fund=false
+EOF
+COPY package.json ${APP_DIR}/
+
+FROM build AS test
+COPY --from=build ${APP_DIR}/dist ./dist-${NODE_VERSION}
+
+FROM node:${VARIANT}
+`)
+	if err != nil {
+		t.Fatalf("failed to parse dockerfile: %v", err)
+	}
+	stages := d.GetStages()
+
+	if got := stages[0].BaseImage().FullName(); got != "node:20-alpine" {
+		t.Errorf("BaseImage() = %q; want node:20-alpine", got)
+	}
+	if got := stages[0].DeclaredBaseImage(); got != "${BASE}" {
+		t.Errorf("DeclaredBaseImage() = %q; want ${BASE}", got)
+	}
+	if got := stages[2].BaseImage().FullName(); got != "node:${VARIANT}" {
+		t.Errorf("an ARG without a default must be kept as written, got %q", got)
+	}
+	if base := d.GetBaseStage(stages[1]); base == nil || base.Name() != "build" {
+		t.Errorf("expected stage test to be built from stage build, got %v", base)
+	}
+
+	build := stages[0].Instructions()
+	if got := build[2].ExpandedArgs(); !reflect.DeepEqual(got, []string{"/srv/app"}) {
+		t.Errorf("WORKDIR ExpandedArgs() = %v", got)
+	}
+	if got := build[3].Sources(); len(got) != 0 {
+		t.Errorf("here-documents must not be sources, got %v", got)
+	}
+	if got := build[3].Destination(); got != "/srv/app/.npmrc" {
+		t.Errorf("Destination() = %q", got)
+	}
+	if got := build[4].Args(); !reflect.DeepEqual(got, []string{"package.json", "${APP_DIR}/"}) {
+		t.Errorf("Args() must not be expanded, got %v", got)
+	}
+
+	// ENV is inherited from the stage the stage is built from, ARG isn't
+	test := stages[1].Instructions()
+	if got := test[0].Sources(); !reflect.DeepEqual(got, []string{"/srv/app/dist"}) {
+		t.Errorf("Sources() = %v", got)
+	}
+	if got := test[0].Destination(); got != "./dist-${NODE_VERSION}" {
+		t.Errorf("Destination() = %q", got)
+	}
+
+	if got := Workdirs(stages)[1][0]; got != "/srv/app" {
+		t.Errorf("expected stage test to inherit the WORKDIR /srv/app, got %q", got)
+	}
+}
+
+func TestDockerfile_WithBuildArgs(t *testing.T) {
+	d, err := NewDockerfile(`ARG NODE_VERSION=20
+ARG BASE=node:${NODE_VERSION}-alpine
+FROM ${BASE}
+ARG TARGET_ENV=development
+ARG NODE_VERSION
+ENV NODE_ENV=${TARGET_ENV}
+COPY dist-${NODE_VERSION} ./dist
+`)
+	if err != nil {
+		t.Fatalf("failed to parse dockerfile: %v", err)
+	}
+	if got := d.DeclaredArgs(); !reflect.DeepEqual(got, []string{"BASE", "NODE_VERSION", "TARGET_ENV"}) {
+		t.Errorf("DeclaredArgs() = %v", got)
+	}
+
+	prod := d.WithBuildArgs(map[string]string{"NODE_VERSION": "22", "TARGET_ENV": "production", "UNDECLARED": "x"})
+	stage := prod.GetStages()[0]
+	if got := stage.BaseImage().FullName(); got != "node:22-alpine" {
+		t.Errorf("expected the global ARG to be overridden, got %q", got)
+	}
+	instructions := stage.Instructions()
+	if got := instructions[3].Expand("$NODE_ENV"); got != "production" {
+		t.Errorf("expected the stage ARG to be overridden, got %q", got)
+	}
+	if got := instructions[3].Sources(); !reflect.DeepEqual(got, []string{"dist-22"}) {
+		t.Errorf("Sources() = %v", got)
+	}
+	if got := prod.BuildArgs()["TARGET_ENV"]; got != "production" {
+		t.Errorf("BuildArgs() = %v", prod.BuildArgs())
+	}
+
+	// the original Dockerfile keeps the defaults
+	if got := d.GetStages()[0].BaseImage().FullName(); got != "node:20-alpine" {
+		t.Errorf("expected the original Dockerfile to be unchanged, got %q", got)
+	}
+}
+
+func TestDockerfile_GetOnbuildTriggers(t *testing.T) {
+	d, err := NewDockerfile(`FROM node:20 AS base
+WORKDIR /app
+ONBUILD COPY package.json ./
+ONBUILD RUN npm install
+
+FROM base
+CMD ["node", "index.js"]
+`)
+	if err != nil {
+		t.Fatalf("failed to parse dockerfile: %v", err)
+	}
+	stages := d.GetStages()
+	if d.GetStageCount() != 2 {
+		t.Errorf("expected 2 stages, got %d", d.GetStageCount())
+	}
+	if triggers := d.GetOnbuildTriggers(stages[0]); len(triggers) != 0 {
+		t.Errorf("expected no triggers for a stage built from an image, got %d", len(triggers))
+	}
+
+	triggers := d.GetOnbuildTriggers(stages[1])
+	if len(triggers) != 2 {
+		t.Fatalf("expected 2 triggers, got %d", len(triggers))
+	}
+	if triggers[0].Cmd() != CmdCopy || triggers[1].Cmd() != CmdRun || triggers[1].Command() != "npm install" {
+		t.Errorf("unexpected triggers %s, %s", triggers[0].Original(), triggers[1].Original())
+	}
+	if triggers[1].StartLine() != 4 {
+		t.Errorf("expected the trigger to be on line 4, got %d", triggers[1].StartLine())
+	}
+	if triggers[1].Original() != "RUN npm install" {
+		t.Errorf("Original() = %q; want the code of the trigger", triggers[1].Original())
+	}
+	if stages[0].Instructions()[0].Trigger() != nil {
+		t.Error("WORKDIR must not have a trigger")
+	}
+}
@@ -0,0 +1,344 @@
+package dockerfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// block is an instruction as written in the source, along with the comments and blank lines right above it
+type block struct {
+	prefix []string
+	lines  []string
+	// key identifies what the instruction does, regardless of how it's formatted
+	key string
+}
+
+// blocks splits the code of the Dockerfile into one block per instruction.
+// The lines after the last instruction are returned separately.
+func (d *Dockerfile) blocks() ([]*block, []string) {
+	lines := strings.Split(d.code, Linebreak)
+	blocks := []*block{}
+	next := 0
+	for _, n := range d.ast.Children {
+		start, end := n.StartLine-1, n.EndLine
+		if start < next || end > len(lines) {
+			continue
+		}
+		blocks = append(blocks, &block{
+			prefix: lines[next:start],
+			lines:  lines[start:end],
+			key:    instructionKey(n),
+		})
+		next = end
+	}
+	return blocks, lines[next:]
+}
+
+// instructionKey returns the same key for instructions that only differ in formatting,
+// eg- line continuations, extra whitespace, the case of the instruction or the order of flags
+func instructionKey(n *parser.Node) string {
+	flags := append([]string{}, n.Flags...)
+	sort.Strings(flags)
+	parts := []string{strings.ToLower(n.Value), strings.Join(flags, " ")}
+	jsonForm := n.Attributes["json"]
+	for arg := n.Next; arg != nil; arg = arg.Next {
+		if jsonForm {
+			parts = append(parts, arg.Value)
+		} else {
+			parts = append(parts, strings.Join(strings.Fields(arg.Value), " "))
+		}
+	}
+	for _, h := range n.Heredocs {
+		parts = append(parts, h.Content)
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// ReplaceArgs replaces the arguments of the instruction with args, which must have as many items as
+// Args(). Only the changed arguments are rewritten, the rest of the instruction keeps its formatting,
+// eg- its line continuations and comments.
+func (d *Dockerfile) ReplaceArgs(ins *Instruction, args []string) error {
+	current := ins.Args()
+	if len(args) != len(current) {
+		return fmt.Errorf("instruction on line %d has %d arguments, got %d", ins.StartLine(), len(current), len(args))
+	}
+	codeLines := strings.Split(d.code, Linebreak)
+	start, end := ins.StartLine()-1, ins.EndLine()
+	if start < 0 || end > len(codeLines) {
+		return fmt.Errorf("instruction on line %d is not part of the Dockerfile", ins.StartLine())
+	}
+	code := strings.Join(codeLines[start:end], Linebreak)
+
+	// arguments are searched in order after the instruction's name and flags, so that an argument
+	// that's also part of a flag or another argument isn't replaced by mistake
+	pos := argsOffset(code, ins)
+	var sb strings.Builder
+	sb.WriteString(code[:pos])
+	for i, arg := range current {
+		at := findArg(code, pos, arg)
+		if at < 0 {
+			return fmt.Errorf("argument %q not found on line %d", arg, ins.StartLine())
+		}
+		sb.WriteString(code[pos:at])
+		replacement := args[i]
+		if ins.IsJSONForm() {
+			encoded, _ := json.Marshal(replacement)
+			replacement = string(encoded[1 : len(encoded)-1])
+		}
+		sb.WriteString(replacement)
+		pos = at + len(arg)
+	}
+	sb.WriteString(code[pos:])
+
+	modified := append([]string{}, codeLines[:start]...)
+	modified = append(modified, sb.String())
+	modified = append(modified, codeLines[end:]...)
+	return d.setCode(strings.Join(modified, Linebreak))
+}
+
+// argsOffset returns the offset in the code of the instruction where its arguments start, after its name and flags
+func argsOffset(code string, ins *Instruction) int {
+	pos := strings.Index(strings.ToLower(code), strings.ToLower(ins.node.Value)) + len(ins.node.Value)
+	for _, f := range ins.Flags() {
+		if i := strings.Index(code[pos:], f); i >= 0 {
+			pos += i + len(f)
+		}
+	}
+	return pos
+}
+
+// ShellCommand returns the command of a shell-form instruction as written in the Dockerfile, eg- the script
+// of a RUN, with its line continuations and without the instruction's name and flags
+func (d *Dockerfile) ShellCommand(ins *Instruction) (string, error) {
+	code, _, err := d.shellCommand(ins)
+	return code, err
+}
+
+// ReplaceShellCommand replaces the command of a shell-form instruction, as returned by ShellCommand, with command
+func (d *Dockerfile) ReplaceShellCommand(ins *Instruction, command string) error {
+	_, prefix, err := d.shellCommand(ins)
+	if err != nil {
+		return err
+	}
+	codeLines := strings.Split(d.code, Linebreak)
+	start, end := ins.StartLine()-1, ins.EndLine()
+	modified := append([]string{}, codeLines[:start]...)
+	modified = append(modified, prefix+command)
+	modified = append(modified, codeLines[end:]...)
+	return d.setCode(strings.Join(modified, Linebreak))
+}
+
+// shellCommand returns the command of a shell-form instruction as written, along with the code before it
+func (d *Dockerfile) shellCommand(ins *Instruction) (string, string, error) {
+	if ins.IsJSONForm() || len(ins.node.Heredocs) > 0 {
+		return "", "", fmt.Errorf("instruction on line %d isn't in shell form", ins.StartLine())
+	}
+	codeLines := strings.Split(d.code, Linebreak)
+	start, end := ins.StartLine()-1, ins.EndLine()
+	if start < 0 || end > len(codeLines) {
+		return "", "", fmt.Errorf("instruction on line %d is not part of the Dockerfile", ins.StartLine())
+	}
+	code := strings.Join(codeLines[start:end], Linebreak)
+	pos := argsOffset(code, ins)
+	return code[pos:], code[:pos], nil
+}
+
+// AddFlag adds a flag to the instruction right after its name, eg- "--mount=type=cache,target=/root/.npm" to a RUN
+func (d *Dockerfile) AddFlag(ins *Instruction, flag string) error {
+	codeLines := strings.Split(d.code, Linebreak)
+	start := ins.StartLine() - 1
+	if start < 0 || start >= len(codeLines) {
+		return fmt.Errorf("instruction on line %d is not part of the Dockerfile", ins.StartLine())
+	}
+	line := codeLines[start]
+	pos := strings.Index(strings.ToLower(line), strings.ToLower(ins.node.Value))
+	if pos < 0 {
+		return fmt.Errorf("instruction %s not found on line %d", ins.Cmd(), ins.StartLine())
+	}
+	pos += len(ins.node.Value)
+	codeLines[start] = line[:pos] + " " + flag + line[pos:]
+	return d.setCode(strings.Join(codeLines, Linebreak))
+}
+
+// RemoveInstruction removes all the lines of the given instruction
+func (d *Dockerfile) RemoveInstruction(ins *Instruction) error {
+	codeLines := strings.Split(d.code, Linebreak)
+	start, end := ins.StartLine()-1, ins.EndLine()
+	if start < 0 || end > len(codeLines) {
+		return fmt.Errorf("instruction on line %d is not part of the Dockerfile", ins.StartLine())
+	}
+	modified := append([]string{}, codeLines[:start]...)
+	modified = append(modified, codeLines[end:]...)
+	return d.setCode(strings.Join(modified, Linebreak))
+}
+
+// MoveAfter moves the instruction, along with the comments right above it, after the given 1-based line,
+// which must come after the instruction. It returns the line the instruction starts on afterwards.
+func (d *Dockerfile) MoveAfter(ins *Instruction, line int) (int, error) {
+	codeLines := strings.Split(d.code, Linebreak)
+	start, end := ins.StartLine()-1, ins.EndLine()
+	if start < 0 || end > len(codeLines) {
+		return 0, fmt.Errorf("instruction on line %d is not part of the Dockerfile", ins.StartLine())
+	}
+	if line < end || line > len(codeLines) {
+		return 0, fmt.Errorf("line %d doesn't come after the instruction on line %d", line, ins.StartLine())
+	}
+	start -= attachedComments(codeLines[:start])
+
+	moved := codeLines[start:end]
+	modified := append([]string{}, codeLines[:start]...)
+	modified = append(modified, codeLines[end:line]...)
+	modified = append(modified, moved...)
+	modified = append(modified, codeLines[line:]...)
+	if err := d.setCode(strings.Join(modified, Linebreak)); err != nil {
+		return 0, err
+	}
+	return line - (ins.EndLine() - ins.StartLine()), nil
+}
+
+// findArg returns the index of the first occurrence of arg in code at or after from, as a whole word
+func findArg(code string, from int, arg string) int {
+	isBoundary := func(c byte) bool { return strings.IndexByte(" \t\n\"[],", c) >= 0 }
+	for pos := from; pos <= len(code); {
+		i := strings.Index(code[pos:], arg)
+		if i < 0 {
+			return -1
+		}
+		at := pos + i
+		after := at + len(arg)
+		if (at == 0 || isBoundary(code[at-1])) && (after == len(code) || isBoundary(code[after])) {
+			return at
+		}
+		pos = at + 1
+	}
+	return -1
+}
+
+type editKind int
+
+const (
+	editKeep editKind = iota
+	editDelete
+	editInsert
+)
+
+// edit turns the original block at index o into the modified block at index m.
+// Only o is set for deletions and only m for insertions.
+type edit struct {
+	kind editKind
+	o, m int
+}
+
+// diffBlocks returns the shortest list of edits that turns original into modified,
+// using the longest common subsequence of their instructions
+func diffBlocks(original, modified []*block) []edit {
+	// lcs[i][j] is the length of the longest common subsequence of original[i:] and modified[j:]
+	lcs := make([][]int, len(original)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(modified)+1)
+	}
+	for i := len(original) - 1; i >= 0; i-- {
+		for j := len(modified) - 1; j >= 0; j-- {
+			if original[i].key == modified[j].key {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	edits := []edit{}
+	i, j := 0, 0
+	for i < len(original) || j < len(modified) {
+		switch {
+		case i < len(original) && j < len(modified) && original[i].key == modified[j].key:
+			edits = append(edits, edit{kind: editKeep, o: i, m: j})
+			i++
+			j++
+		case j == len(modified) || (i < len(original) && lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{kind: editDelete, o: i})
+			i++
+		default:
+			edits = append(edits, edit{kind: editInsert, m: j})
+			j++
+		}
+	}
+	return edits
+}
+
+// attachedComments returns the number of comment lines at the end of the prefix, ie- right above the instruction
+func attachedComments(prefix []string) int {
+	n := 0
+	for i := len(prefix) - 1; i >= 0 && strings.HasPrefix(strings.TrimSpace(prefix[i]), "#"); i-- {
+		n++
+	}
+	return n
+}
+
+// Merge rewrites the source of original so that it has the instructions of modified, eg- a Dockerfile
+// returned by the LLM. Only the instructions that actually changed are rewritten, the ones that are the
+// same in both keep their original code even if modified formats them differently. The user's comments
+// and blank lines are kept: a changed instruction keeps the comments above it, a removed instruction
+// takes the comments right above it along, and a new instruction comes with its comments from modified.
+func Merge(original, modified *Dockerfile) (*Dockerfile, error) {
+	oBlocks, trailer := original.blocks()
+	mBlocks, _ := modified.blocks()
+
+	out := []string{}
+	var deleted, inserted []int
+	flush := func() {
+		for k := 0; k < max(len(deleted), len(inserted)); k++ {
+			switch {
+			case k < len(deleted) && k < len(inserted):
+				out = append(out, oBlocks[deleted[k]].prefix...)
+				out = append(out, mBlocks[inserted[k]].lines...)
+			case k < len(deleted):
+				prefix := oBlocks[deleted[k]].prefix
+				// the prefix of the first instruction contains the parser directives, which must stay
+				if deleted[k] > 0 {
+					prefix = prefix[:len(prefix)-attachedComments(prefix)]
+				}
+				out = append(out, prefix...)
+			default:
+				out = append(out, mBlocks[inserted[k]].prefix...)
+				out = append(out, mBlocks[inserted[k]].lines...)
+			}
+		}
+		deleted, inserted = nil, nil
+	}
+	for _, e := range diffBlocks(oBlocks, mBlocks) {
+		switch e.kind {
+		case editKeep:
+			flush()
+			out = append(out, oBlocks[e.o].prefix...)
+			out = append(out, oBlocks[e.o].lines...)
+		case editDelete:
+			deleted = append(deleted, e.o)
+		case editInsert:
+			inserted = append(inserted, e.m)
+		}
+	}
+	flush()
+	out = append(out, trailer...)
+
+	merged, err := NewDockerfile(strings.Join(out, Linebreak))
+	if err != nil {
+		return nil, err
+	}
+	// code taken from modified may not parse the same way in the context of original, eg- with another escape token
+	mergedBlocks, _ := merged.blocks()
+	if len(mergedBlocks) != len(mBlocks) {
+		return nil, errors.New("merged Dockerfile doesn't have the same instructions as the modified one")
+	}
+	for i := range mBlocks {
+		if mergedBlocks[i].key != mBlocks[i].key {
+			return nil, errors.New("merged Dockerfile doesn't have the same instructions as the modified one")
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,296 @@
+package dockerfile
+
+import (
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		modified string
+		expected string
+	}{
+		{
+			name: "unchanged instructions keep their formatting and comments",
+			original: `# syntax=docker/dockerfile:1
+
+# Build the app
+FROM node:20 AS build
+WORKDIR /app
+
+# install OS deps
+RUN apt-get update && \
+    apt-get install -y python3
+
+COPY . .
+RUN npm ci   # everything
+`,
+			modified: `# syntax=docker/dockerfile:1
+FROM node:20 AS build
+WORKDIR /app
+RUN apt-get update && apt-get install -y python3
+COPY . .
+run npm ci   # everything
+`,
+			expected: `# syntax=docker/dockerfile:1
+
+# Build the app
+FROM node:20 AS build
+WORKDIR /app
+
+# install OS deps
+RUN apt-get update && \
+    apt-get install -y python3
+
+COPY . .
+RUN npm ci   # everything
+`,
+		},
+		{
+			name: "changed instructions keep the comments above them",
+			original: `# Base image
+FROM node:20
+
+WORKDIR /app
+# all dependencies
+RUN npm install
+COPY . .
+CMD ["node", "index.js"]
+`,
+			modified: `FROM node:20-alpine
+WORKDIR /app
+RUN npm ci --omit=dev
+COPY . .
+CMD ["node", "index.js"]
+`,
+			expected: `# Base image
+FROM node:20-alpine
+
+WORKDIR /app
+# all dependencies
+RUN npm ci --omit=dev
+COPY . .
+CMD ["node", "index.js"]
+`,
+		},
+		{
+			name: "removed instructions take their comments along and new ones bring theirs",
+			original: `FROM node:20
+WORKDIR /app
+
+# debugging tools
+RUN apt-get update && apt-get install -y vim
+COPY --chown=node:node . .
+CMD ["node", "index.js"]
+`,
+			modified: `FROM node:20 AS build
+WORKDIR /app
+COPY --chown=node:node . .
+RUN npm run build
+
+# Final stage
+FROM node:20-alpine
+COPY --from=build /app/dist /app
+CMD ["node", "index.js"]
+`,
+			expected: `FROM node:20 AS build
+WORKDIR /app
+
+COPY --chown=node:node . .
+RUN npm run build
+
+# Final stage
+FROM node:20-alpine
+COPY --from=build /app/dist /app
+CMD ["node", "index.js"]
+`,
+		},
+		{
+			name: "reordered flags are not a change",
+			original: `FROM node:20
+COPY --chown=node:node --link package.json ./
+`,
+			modified: `FROM node:20
+COPY --link --chown=node:node package.json ./
+`,
+			expected: `FROM node:20
+COPY --chown=node:node --link package.json ./
+`,
+		},
+		{
+			name: "heredocs are kept as a whole",
+			original: `FROM alpine
+# setup
+RUN <<EOF
+apk add curl
+EOF
+CMD ["sh"]
+`,
+			modified: `FROM alpine
+RUN <<EOF
+apk add curl
+EOF
+USER nobody
+CMD ["sh"]
+`,
+			expected: `FROM alpine
+# setup
+RUN <<EOF
+apk add curl
+EOF
+USER nobody
+CMD ["sh"]
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original, err := NewDockerfile(tt.original)
+			if err != nil {
+				t.Fatalf("failed to parse original: %v", err)
+			}
+			modified, err := NewDockerfile(tt.modified)
+			if err != nil {
+				t.Fatalf("failed to parse modified: %v", err)
+			}
+			merged, err := Merge(original, modified)
+			if err != nil {
+				t.Fatalf("Merge() error = %v", err)
+			}
+			if merged.Raw() != tt.expected {
+				t.Errorf("Merge() =\n%s\nwant\n%s", merged.Raw(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestMerge_IncompatibleEscapeToken(t *testing.T) {
+	original, err := NewDockerfile("# escape=`\nFROM mcr.microsoft.com/windows/servercore\nRUN dir\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	modified, err := NewDockerfile("FROM mcr.microsoft.com/windows/servercore\nRUN echo a && \\\n    echo b\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Merge(original, modified); err == nil {
+		t.Error("expected an error when the modified code means something else in the original's context")
+	}
+}
+
+func TestDockerfile_ReplaceArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		args     []string
+		expected string
+	}{
+		{
+			name:     "keeps line continuations and flags",
+			code:     "FROM node:20\n  COPY --from=dist dist \\\n    ./dist\n",
+			args:     []string{"/app/dist", "./dist"},
+			expected: "FROM node:20\n  COPY --from=dist /app/dist \\\n    ./dist\n",
+		},
+		{
+			name:     "json form",
+			code:     "FROM node:20\nCOPY [\"dist\", \"./dist\"]\n",
+			args:     []string{"/app/dist", "./dist"},
+			expected: "FROM node:20\nCOPY [\"/app/dist\", \"./dist\"]\n",
+		},
+		{
+			name:     "argument that is part of another one",
+			code:     "FROM node:20\nCOPY build/dist build ./\n",
+			args:     []string{"build/dist", "/app/build", "./"},
+			expected: "FROM node:20\nCOPY build/dist /app/build ./\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := NewDockerfile(tt.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stage, _ := df.GetFinalStage()
+			if err := df.ReplaceArgs(stage.Instructions()[0], tt.args); err != nil {
+				t.Fatalf("ReplaceArgs() error = %v", err)
+			}
+			if df.Raw() != tt.expected {
+				t.Errorf("ReplaceArgs() = %q; want %q", df.Raw(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestDockerfile_ReplaceShellCommand(t *testing.T) {
+	df, err := NewDockerfile("FROM node:20\nRUN --mount=type=cache,target=/root/.npm cd /app; \\\n    npm ci\nCMD [\"node\", \".\"]\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stage, _ := df.GetFinalStage()
+	run := stage.Instructions()[0]
+	command, err := df.ShellCommand(run)
+	if err != nil {
+		t.Fatalf("ShellCommand() error = %v", err)
+	}
+	if command != " cd /app; \\\n    npm ci" {
+		t.Errorf("ShellCommand() = %q", command)
+	}
+	if err := df.ReplaceShellCommand(run, " cd /app && \\\n    npm ci"); err != nil {
+		t.Fatalf("ReplaceShellCommand() error = %v", err)
+	}
+	expected := "FROM node:20\nRUN --mount=type=cache,target=/root/.npm cd /app && \\\n    npm ci\nCMD [\"node\", \".\"]\n"
+	if df.Raw() != expected {
+		t.Errorf("ReplaceShellCommand() = %q; want %q", df.Raw(), expected)
+	}
+	if _, err := df.ShellCommand(stage.Instructions()[1]); err == nil {
+		t.Error("expected an error for an instruction in exec form")
+	}
+}
+
+func TestDockerfile_MoveAfter(t *testing.T) {
+	tests := []struct {
+		name      string
+		code      string
+		line      int
+		expected  string
+		startLine int
+	}{
+		{
+			name:      "moves the comments above the instruction along",
+			code:      "FROM node:20\n# copy the source\nCOPY . .\nRUN npm ci\nCMD [\"node\", \".\"]\n",
+			line:      4,
+			expected:  "FROM node:20\nRUN npm ci\n# copy the source\nCOPY . .\nCMD [\"node\", \".\"]\n",
+			startLine: 4,
+		},
+		{
+			name:      "multi-line instructions",
+			code:      "FROM node:20\nCOPY src \\\n  ./src\nRUN npm ci \\\n  --omit=dev\n",
+			line:      5,
+			expected:  "FROM node:20\nRUN npm ci \\\n  --omit=dev\nCOPY src \\\n  ./src\n",
+			startLine: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := NewDockerfile(tt.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stage, _ := df.GetFinalStage()
+			startLine, err := df.MoveAfter(stage.Instructions()[0], tt.line)
+			if err != nil {
+				t.Fatalf("MoveAfter() error = %v", err)
+			}
+			if df.Raw() != tt.expected {
+				t.Errorf("MoveAfter() = %q; want %q", df.Raw(), tt.expected)
+			}
+			if startLine != tt.startLine {
+				t.Errorf("MoveAfter() start line = %d; want %d", startLine, tt.startLine)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package dockerfile
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/moby/buildkit/frontend/dockerfile/shell"
+)
+
+// expandedCmds are the instructions whose arguments Docker expands variables in.
+// The commands of RUN, CMD and ENTRYPOINT are expanded by the shell when they run instead.
+var expandedCmds = map[string]bool{
+	CmdAdd:       true,
+	CmdCopy:      true,
+	CmdEnv:       true,
+	CmdArg:       true,
+	CmdExpose:    true,
+	CmdLabel:     true,
+	CmdUser:      true,
+	CmdWorkdir:   true,
+	"STOPSIGNAL": true,
+	"VOLUME":     true,
+}
+
+// vars are the build-time variables in effect for an instruction: the ENV variables and the ARGs
+// whose value is known from their default. It implements shell.EnvGetter.
+type vars map[string]string
+
+func (v vars) Get(key string) (string, bool) {
+	value, ok := v[shell.NormalizeEnvKey(key)]
+	return value, ok
+}
+
+func (v vars) Keys() []string {
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (v vars) clone() vars {
+	c := make(vars, len(v))
+	for k, value := range v {
+		c[k] = value
+	}
+	return c
+}
+
+// merge returns the variables of v overridden by those of other, the way ENV takes precedence over ARG
+func (v vars) merge(other vars) vars {
+	m := v.clone()
+	for k, value := range other {
+		m[k] = value
+	}
+	return m
+}
+
+// expand replaces references to the given variables in word, eg- "node:${NODE_VERSION}".
+// References to variables whose value isn't known, eg- an ARG without a default, are kept as written.
+func expand(word string, v vars, escapeToken rune) string {
+	if !strings.Contains(word, "$") {
+		return word
+	}
+	lex := shell.NewLex(escapeToken)
+	lex.SkipUnsetEnv = true
+	expanded, _, err := lex.ProcessWord(word, v)
+	if err != nil {
+		return word
+	}
+	return expanded
+}
+
+// declareArgs applies an ARG instruction to args. ARGs passed with --build-arg take the value of
+// buildArgs, ARGs without a default take the value of the global ARG of the same name, if it has one,
+// and are left unknown otherwise.
+func declareArgs(n *parser.Node, args, global, current, buildArgs vars, escapeToken rune) {
+	for arg := n.Next; arg != nil; arg = arg.Next {
+		name, value, hasDefault := strings.Cut(arg.Value, "=")
+		name = shell.NormalizeEnvKey(name)
+		override, overridden := buildArgs[name]
+		switch {
+		case overridden:
+			args[name] = override
+		case hasDefault:
+			args[name] = expand(value, current, escapeToken)
+		case global != nil:
+			if value, ok := global[name]; ok {
+				args[name] = value
+			}
+		}
+	}
+}
+
+// declareEnv applies an ENV instruction to env. Its arguments are parsed as (key, value, separator) triplets.
+func declareEnv(n *parser.Node, env, current vars, escapeToken rune) {
+	for key := n.Next; key != nil && key.Next != nil; {
+		env[shell.NormalizeEnvKey(key.Value)] = expand(key.Next.Value, current, escapeToken)
+		separator := key.Next.Next
+		if separator == nil {
+			break
+		}
+		key = separator.Next
+	}
+}
+
+// globalArgs returns the values of the ARGs declared before the first FROM, which can be used in FROM instructions
+func (d *Dockerfile) globalArgs() vars {
+	global := vars{}
+	for _, child := range d.ast.Children {
+		if isFrom(child) {
+			break
+		}
+		if strings.EqualFold(child.Value, CmdArg) {
+			declareArgs(child, global, nil, global, d.buildArgs, d.escapeToken)
+		}
+	}
+	return global
+}
+
+// ExpandedArgs returns the arguments of the instruction with the variables that Docker expands
+// replaced by their values, eg- "COPY $APP_DIR/dist ." becomes "COPY /app/dist .". References to
+// variables whose value isn't known from the Dockerfile are kept as written.
+func (i *Instruction) ExpandedArgs() []string {
+	args := i.Args()
+	if !expandedCmds[i.Cmd()] {
+		return args
+	}
+	for k, arg := range args {
+		args[k] = i.Expand(arg)
+	}
+	return args
+}
+
+// Expand replaces references to the variables in effect for the instruction in word
+func (i *Instruction) Expand(word string) string {
+	return expand(word, i.vars, i.escapeToken)
+}
+
+// Sources returns the source paths of a COPY or ADD instruction with variables expanded.
+// Here-documents, eg- "COPY <<EOF /etc/app.conf", are not files and aren't included.
+func (i *Instruction) Sources() []string {
+	if i.Cmd() != CmdCopy && i.Cmd() != CmdAdd {
+		return nil
+	}
+	args := i.ExpandedArgs()
+	if len(args) < 2 {
+		return nil
+	}
+	sources := []string{}
+	for _, src := range args[:len(args)-1] {
+		if len(i.node.Heredocs) > 0 && strings.HasPrefix(src, "<<") {
+			continue
+		}
+		sources = append(sources, src)
+	}
+	return sources
+}
+
+// Destination returns the destination path of a COPY or ADD instruction with variables expanded
+func (i *Instruction) Destination() string {
+	if i.Cmd() != CmdCopy && i.Cmd() != CmdAdd {
+		return ""
+	}
+	args := i.ExpandedArgs()
+	if len(args) < 2 {
+		return ""
+	}
+	return args[len(args)-1]
+}
+
+// WithBuildArgs returns a copy of the Dockerfile whose ARGs take the given values, the way they do when
+// it's built with "--build-arg NAME=VALUE". Values of ARGs the Dockerfile doesn't declare are ignored.
+func (d *Dockerfile) WithBuildArgs(args map[string]string) *Dockerfile {
+	c := *d
+	c.buildArgs = vars{}
+	for name, value := range args {
+		c.buildArgs[shell.NormalizeEnvKey(name)] = value
+	}
+	return &c
+}
+
+// BuildArgs returns the values the ARGs were given with WithBuildArgs
+func (d *Dockerfile) BuildArgs() map[string]string {
+	return d.buildArgs.clone()
+}
+
+// DeclaredArgs returns the names of all the ARGs declared in the Dockerfile, sorted
+func (d *Dockerfile) DeclaredArgs() []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, child := range d.ast.Children {
+		if !strings.EqualFold(child.Value, CmdArg) {
+			continue
+		}
+		for arg := child.Next; arg != nil; arg = arg.Next {
+			name, _, _ := strings.Cut(arg.Value, "=")
+			if name = shell.NormalizeEnvKey(name); !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -5,19 +5,38 @@ import "strings"
 const (
 	DefaultTag = "latest"
 	NameTagSep = ":"
+	DigestSep  = "@"
 )
 
 type Image struct {
-	name string
-	tag  string
+	name   string
+	tag    string
+	digest string
 }
 
+// NewImage parses an image reference of the form name[:tag][@digest].
+// The name can contain a registry with a port, eg- "localhost:5000/app:1".
+// If neither a tag nor a digest is given, the tag defaults to "latest".
 func NewImage(fullName string) *Image {
-	parts := strings.Split(fullName, NameTagSep)
-	if len(parts) == 1 {
-		return &Image{name: parts[0], tag: DefaultTag}
+	img := &Image{}
+	if i := strings.Index(fullName, DigestSep); i >= 0 {
+		fullName, img.digest = fullName[:i], fullName[i+1:]
 	}
-	return &Image{name: parts[0], tag: parts[1]}
+	// the tag separator must come after the last "/", otherwise it separates a registry's port
+	if i := strings.LastIndex(fullName, NameTagSep); i > strings.LastIndex(fullName, "/") {
+		img.name, img.tag = fullName[:i], fullName[i+1:]
+	} else {
+		img.name = fullName
+	}
+	if img.tag == "" && img.digest == "" {
+		img.tag = DefaultTag
+	}
+	return img
+}
+
+// WithDigest returns a copy of the image pinned to the given digest
+func (i *Image) WithDigest(digest string) *Image {
+	return &Image{name: i.name, tag: i.tag, digest: digest}
 }
 
 // Name returns the name of the image.
@@ -29,12 +48,43 @@ func (i *Image) Name() string {
 
 // Tag returns the tag of the image.
 // For example, for the image "node:alpine", the tag is "alpine".
+// It is empty if the image is only referenced by its digest.
 func (i *Image) Tag() string {
 	return i.tag
 }
 
+// Digest returns the digest the image is pinned to, eg- "sha256:...".
+// It is empty if the image isn't pinned.
+func (i *Image) Digest() string {
+	return i.digest
+}
+
 // FullName returns the full name of the image.
 // For example, for the image "node:alpine", the full name is "node:alpine".
+// The digest is included if the image is pinned, eg- "node:alpine@sha256:...".
 func (i *Image) FullName() string {
-	return i.name + NameTagSep + i.tag
+	name := i.name
+	if i.tag != "" {
+		name += NameTagSep + i.tag
+	}
+	if i.digest != "" {
+		name += DigestSep + i.digest
+	}
+	return name
+}
+
+// FamiliarName returns the reference without the default tag, eg- "node" for "node:latest" and "node:22" for "node:22".
+// It's the name of the named build context that replaces the image, if the Dockerfile is built with one.
+func (i *Image) FamiliarName() string {
+	return strings.TrimSuffix(i.FullName(), NameTagSep+DefaultTag)
+}
+
+// IsLightweight returns true if the image is a minimal variant, ie, alpine, slim, distroless or chiseled
+func (i *Image) IsLightweight() bool {
+	for _, variant := range []string{"alpine", "slim", "distroless", "chiseled"} {
+		if strings.Contains(i.tag, variant) || strings.Contains(i.name, variant) {
+			return true
+		}
+	}
+	return false
 }
@@ -30,3 +30,26 @@ func TestNewImage_NameAndTag(t *testing.T) {
 		t.Errorf("expected full name 'node:alpine', got %q", img.FullName())
 	}
 }
+
+func TestNewImage_References(t *testing.T) {
+	tests := []struct {
+		ref    string
+		name   string
+		tag    string
+		digest string
+	}{
+		{"node:20-alpine@sha256:abc", "node", "20-alpine", "sha256:abc"},
+		{"node@sha256:abc", "node", "", "sha256:abc"},
+		{"localhost:5000/app", "localhost:5000/app", DefaultTag, ""},
+		{"ghcr.io/org/app:1.2", "ghcr.io/org/app", "1.2", ""},
+	}
+	for _, tt := range tests {
+		img := NewImage(tt.ref)
+		if img.Name() != tt.name || img.Tag() != tt.tag || img.Digest() != tt.digest {
+			t.Errorf("%s: expected (%q, %q, %q), got (%q, %q, %q)", tt.ref, tt.name, tt.tag, tt.digest, img.Name(), img.Tag(), img.Digest())
+		}
+	}
+	if full := NewImage("node:20").WithDigest("sha256:abc").FullName(); full != "node:20@sha256:abc" {
+		t.Errorf("expected pinned full name, got %q", full)
+	}
+}
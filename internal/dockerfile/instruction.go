@@ -0,0 +1,127 @@
+package dockerfile
+
+import (
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// Instruction is a single instruction (eg- RUN, COPY) in a Dockerfile
+type Instruction struct {
+	node *parser.Node
+	// vars are the variables in effect when the instruction is built
+	vars        vars
+	escapeToken rune
+}
+
+// Cmd returns the upper-cased name of the instruction, eg- "RUN"
+func (i *Instruction) Cmd() string {
+	return strings.ToUpper(i.node.Value)
+}
+
+// Args returns the parsed arguments of the instruction, excluding flags.
+// For shell-form RUN, CMD and ENTRYPOINT, the entire command is a single argument.
+func (i *Instruction) Args() []string {
+	args := []string{}
+	for n := i.node.Next; n != nil; n = n.Next {
+		args = append(args, n.Value)
+	}
+	return args
+}
+
+// Flags returns the flags passed to the instruction, eg- "--from=build"
+func (i *Instruction) Flags() []string {
+	return i.node.Flags
+}
+
+// Flag returns the value of the given flag (without the leading "--") and whether it was set
+func (i *Instruction) Flag(name string) (string, bool) {
+	prefix := "--" + name
+	for _, f := range i.node.Flags {
+		if f == prefix {
+			return "", true
+		}
+		if strings.HasPrefix(f, prefix+"=") {
+			return strings.TrimPrefix(f, prefix+"="), true
+		}
+	}
+	return "", false
+}
+
+// Heredoc is a here-document attached to an instruction, eg- the script of "RUN <<EOF"
+type Heredoc struct {
+	// Name is the delimiter of the here-document, eg- "EOF"
+	Name    string
+	Content string
+	// Expand is false if the delimiter is quoted, in which case variables in the content aren't expanded
+	Expand bool
+	// Chomp is true for "<<-", which strips leading tabs from the content
+	Chomp bool
+}
+
+// Heredocs returns the here-documents of the instruction in order of declaration
+func (i *Instruction) Heredocs() []Heredoc {
+	heredocs := make([]Heredoc, 0, len(i.node.Heredocs))
+	for _, h := range i.node.Heredocs {
+		heredocs = append(heredocs, Heredoc{Name: h.Name, Content: h.Content, Expand: h.Expand, Chomp: h.Chomp})
+	}
+	return heredocs
+}
+
+// Command returns the arguments of the instruction joined by spaces. For RUN, the scripts passed
+// as here-documents (eg- "RUN <<EOF") are appended, since they are what actually gets executed.
+func (i *Instruction) Command() string {
+	cmd := strings.Join(i.Args(), " ")
+	if i.Cmd() != CmdRun {
+		return cmd
+	}
+	for _, h := range i.node.Heredocs {
+		cmd += "\n" + h.Content
+	}
+	return cmd
+}
+
+// Comments returns the comments directly above the instruction, without the leading "#"
+func (i *Instruction) Comments() []string {
+	return i.node.PrevComment
+}
+
+// IsJSONForm returns true if the instruction uses the exec (JSON array) form
+func (i *Instruction) IsJSONForm() bool {
+	return i.node.Attributes["json"]
+}
+
+// Original returns the code of the instruction as written in the Dockerfile,
+// with line continuations joined.
+func (i *Instruction) Original() string {
+	return i.node.Original
+}
+
+// StartLine returns the 1-based line number where the instruction begins
+func (i *Instruction) StartLine() int {
+	return i.node.StartLine
+}
+
+// EndLine returns the 1-based line number where the instruction ends
+func (i *Instruction) EndLine() int {
+	return i.node.EndLine
+}
+
+// Trigger returns the instruction an ONBUILD instruction registers, eg- "RUN npm ci" for "ONBUILD RUN npm ci".
+// nil is returned for other instructions.
+func (i *Instruction) Trigger() *Instruction {
+	if i.Cmd() != CmdOnbuild || i.node.Next == nil || len(i.node.Next.Children) == 0 {
+		return nil
+	}
+	// the parser only sets the position and code of the ONBUILD instruction itself
+	trigger := *i.node.Next.Children[0]
+	trigger.StartLine, trigger.EndLine = i.node.StartLine, i.node.EndLine
+	if trigger.Original == "" {
+		trigger.Original = strings.TrimSpace(i.node.Original[len(CmdOnbuild):])
+	}
+	return &Instruction{node: &trigger, vars: i.vars, escapeToken: i.escapeToken}
+}
+
+func isFrom(node *parser.Node) bool {
+	return strings.EqualFold(node.Value, CmdFrom)
+}
@@ -0,0 +1,70 @@
+package dockerfile
+
+import (
+	"path"
+	"strconv"
+	"strings"
+)
+
+// RootDir is the working directory of a stage that doesn't set one
+const RootDir = "/"
+
+// ResolvePath resolves p against the working directory dir, the same way Docker resolves
+// WORKDIR and COPY destinations. Paths containing variables can't be resolved statically
+// and are returned unchanged. A trailing slash, which marks a directory, is preserved.
+func ResolvePath(dir, p string) string {
+	if strings.Contains(p, "$") {
+		return p
+	}
+	resolved := p
+	if !path.IsAbs(p) {
+		resolved = path.Join(dir, p)
+	}
+	resolved = path.Clean(resolved)
+	if strings.HasSuffix(p, "/") && resolved != RootDir {
+		resolved += "/"
+	}
+	return resolved
+}
+
+// FindStage returns the stage referenced by name or index, eg- in "COPY --from=build" or "FROM build".
+// nil is returned if ref doesn't refer to a stage of this Dockerfile, eg- if it is an image.
+func FindStage(stages []*Stage, ref string) *Stage {
+	for _, s := range stages {
+		if s.Name() != "" && strings.EqualFold(s.Name(), ref) {
+			return s
+		}
+	}
+	if i, err := strconv.Atoi(ref); err == nil && i >= 0 && i < len(stages) {
+		return stages[i]
+	}
+	return nil
+}
+
+// Workdirs returns the working directory in effect for every instruction of the given stages.
+// workdirs[s][i] is the working directory of the i-th instruction of the s-th stage and
+// workdirs[s][len(instructions)] is the working directory the stage ends with.
+// A stage based on another stage inherits its working directory.
+func Workdirs(stages []*Stage) [][]string {
+	workdirs := make([][]string, len(stages))
+	for si, s := range stages {
+		current := RootDir
+		if base := FindStage(stages[:si], s.base); base != nil {
+			prev := workdirs[base.Index()]
+			current = prev[len(prev)-1]
+		}
+
+		dirs := make([]string, 0, len(s.instructions)+1)
+		for _, ins := range s.instructions {
+			dirs = append(dirs, current)
+			if args := ins.ExpandedArgs(); ins.Cmd() == CmdWorkdir && len(args) > 0 {
+				current = strings.TrimSuffix(ResolvePath(current, args[0]), "/")
+				if current == "" {
+					current = RootDir
+				}
+			}
+		}
+		workdirs[si] = append(dirs, current)
+	}
+	return workdirs
+}
@@ -0,0 +1,67 @@
+package dockerfile
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestResolvePath(t *testing.T) {
+	tests := []struct {
+		dir, p, expected string
+	}{
+		{"/app", "dist", "/app/dist"},
+		{"/app", "./", "/app/"},
+		{"/app", ".", "/app"},
+		{"/app", "/usr/src", "/usr/src"},
+		{"/", "dist/", "/dist/"},
+		{"/app", "../lib", "/lib"},
+		{"/app", "$HOME/app", "$HOME/app"},
+	}
+	for _, tt := range tests {
+		if got := ResolvePath(tt.dir, tt.p); got != tt.expected {
+			t.Errorf("ResolvePath(%q, %q) = %q; want %q", tt.dir, tt.p, got, tt.expected)
+		}
+	}
+}
+
+func TestWorkdirs(t *testing.T) {
+	code := `FROM node:20 AS base
+WORKDIR /usr/src
+WORKDIR app
+RUN npm ci
+
+FROM base AS build
+RUN npm run build
+
+FROM node:20-alpine
+COPY --from=build /usr/src/app/dist ./dist
+CMD ["node", "dist/index.js"]
+`
+	df, err := NewDockerfile(code)
+	if err != nil {
+		t.Fatalf("failed to parse Dockerfile: %v", err)
+	}
+	workdirs := Workdirs(df.GetStages())
+
+	expected := [][]string{
+		{"/", "/usr/src", "/usr/src/app", "/usr/src/app"},
+		{"/usr/src/app", "/usr/src/app"},
+		{"/", "/", "/"},
+	}
+	for i := range expected {
+		if !slices.Equal(workdirs[i], expected[i]) {
+			t.Errorf("workdirs of stage %d = %v; want %v", i, workdirs[i], expected[i])
+		}
+	}
+
+	stages := df.GetStages()
+	if s := FindStage(stages, "BUILD"); s == nil || s.Index() != 1 {
+		t.Errorf("FindStage(build) = %v; want stage 1", s)
+	}
+	if s := FindStage(stages, "0"); s == nil || s.Index() != 0 {
+		t.Errorf("FindStage(0) = %v; want stage 0", s)
+	}
+	if s := FindStage(stages, "node:20"); s != nil {
+		t.Errorf("FindStage(node:20) = %v; want nil", s)
+	}
+}
@@ -1,6 +1,8 @@
 package dockerfile
 
 import (
+	"strings"
+
 	"github.com/moby/buildkit/frontend/dockerfile/parser"
 )
 
@@ -12,8 +14,70 @@ type Stage struct {
 	stageIndex uint
 	// astNode is the FROM node in the AST
 	astNode *parser.Node
+	// instructions are the instructions that follow the FROM node in this stage
+	instructions []*Instruction
+	// base is the image or stage the stage is built from, with the global ARGs expanded
+	base string
+	// env are the ENV variables the stage starts with, inherited from the stage it's built from
+	env vars
 }
 
+// BaseImage returns the image the stage is built from. Global ARGs used in the FROM instruction,
+// eg- "FROM node:${NODE_VERSION}", are replaced by their defaults.
 func (s *Stage) BaseImage() *Image {
-	return NewImage(s.astNode.Next.Value)
+	if s.base == "" {
+		return NewImage(s.DeclaredBaseImage())
+	}
+	return NewImage(s.base)
+}
+
+// DeclaredBaseImage returns the base image as written in the FROM instruction, eg- "node:${NODE_VERSION}"
+func (s *Stage) DeclaredBaseImage() string {
+	return s.astNode.Next.Value
+}
+
+// Index returns the 0-based position of the stage in the Dockerfile
+func (s *Stage) Index() uint {
+	return s.stageIndex
+}
+
+// Name returns the name given to the stage using "AS", or an empty string if it is unnamed
+func (s *Stage) Name() string {
+	n := s.astNode.Next
+	if n != nil && n.Next != nil && strings.EqualFold(n.Next.Value, "AS") && n.Next.Next != nil {
+		return n.Next.Next.Value
+	}
+	return ""
+}
+
+// Platform returns the value of the --platform flag of the FROM instruction, or an empty string if it isn't set
+func (s *Stage) Platform() string {
+	for _, flag := range s.astNode.Flags {
+		if v, ok := strings.CutPrefix(flag, "--platform="); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// BuiltForTargetPlatform returns true if the stage is built once for every platform the image is built for.
+// Stages pinned to a platform, eg- "FROM --platform=$BUILDPLATFORM" for cross-compilation, are built for that platform only.
+func (s *Stage) BuiltForTargetPlatform() bool {
+	p := s.Platform()
+	return p == "" || strings.Contains(p, "TARGETPLATFORM")
+}
+
+// Original returns the FROM instruction of the stage as written in the Dockerfile
+func (s *Stage) Original() string {
+	return s.astNode.Original
+}
+
+// StartLine returns the line number of the FROM instruction of the stage
+func (s *Stage) StartLine() int {
+	return s.astNode.StartLine
+}
+
+// Instructions returns the instructions inside the stage, excluding FROM
+func (s *Stage) Instructions() []*Instruction {
+	return s.instructions
 }
@@ -25,3 +25,26 @@ func TestStage_BaseImage(t *testing.T) {
 		t.Errorf("expected 'node:18-alpine', got '%s'", baseImg.FullName())
 	}
 }
+
+func TestStage_Platform(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{"FROM node:20", ""},
+		{"FROM --platform=$BUILDPLATFORM golang:1.25 AS build", "$BUILDPLATFORM"},
+		{"FROM --platform=linux/amd64 node:20", "linux/amd64"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			d, err := NewDockerfile(tt.code)
+			if err != nil {
+				t.Fatalf("failed to parse dockerfile: %v", err)
+			}
+			if got := d.GetStages()[0].Platform(); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
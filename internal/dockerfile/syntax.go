@@ -0,0 +1,231 @@
+package dockerfile
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// instructions supported by the Dockerfile frontend
+var knownInstructions = []string{
+	CmdFrom, CmdRun, CmdCmd, CmdLabel, "MAINTAINER", CmdExpose, CmdEnv, CmdAdd, CmdCopy, CmdEntrypoint,
+	"VOLUME", CmdUser, CmdWorkdir, CmdArg, "ONBUILD", "STOPSIGNAL", CmdHealthcheck, "SHELL",
+}
+
+// SyntaxError is a problem at a specific location of a Dockerfile
+type SyntaxError struct {
+	// Line and Column are 1-based, Line is 0 if the error isn't tied to a line
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Line == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// SyntaxErrors are all the syntax errors found in a Dockerfile
+type SyntaxErrors []*SyntaxError
+
+func (e SyntaxErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// checkSyntax converts the error returned by the parser into SyntaxErrors and, if the
+// code parsed, looks for the mistakes that the permissive parser accepts.
+func checkSyntax(code string, result *parser.Result, parseErr error) SyntaxErrors {
+	lines := strings.Split(code, Linebreak)
+	if parseErr != nil {
+		e := &SyntaxError{Message: parseErr.Error()}
+		var loc *parser.ErrorLocation
+		if errors.As(parseErr, &loc) && len(loc.Locations) > 0 && len(loc.Locations[0]) > 0 {
+			start := loc.Locations[0][0].Start
+			e.Line, e.Column = start.Line, start.Character+1
+			if start.Character == 0 {
+				e.Column = indentation(lines, start.Line) + 1
+			}
+		}
+		return SyntaxErrors{e}
+	}
+
+	var errs SyntaxErrors
+	at := func(n *parser.Node, format string, args ...any) {
+		errs = append(errs, &SyntaxError{
+			Line:    n.StartLine,
+			Column:  indentation(lines, n.StartLine) + 1,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	seenFrom := false
+	for _, n := range result.AST.Children {
+		cmd := strings.ToUpper(n.Value)
+		if !isKnownInstruction(cmd) {
+			if s := suggestInstruction(cmd); s != "" {
+				at(n, "unknown instruction %s, did you mean %s?", cmd, s)
+			} else {
+				at(n, "unknown instruction %s", cmd)
+			}
+			continue
+		}
+
+		switch {
+		case cmd == CmdFrom:
+			seenFrom = true
+		case !seenFrom && cmd != CmdArg:
+			at(n, "%s must come after a FROM instruction, only ARG can be used before the first FROM", cmd)
+			// report a missing FROM only once
+			seenFrom = true
+		}
+
+		checkArguments(n, cmd, at)
+	}
+	return errs
+}
+
+func checkArguments(n *parser.Node, cmd string, at func(*parser.Node, string, ...any)) {
+	if n.Next == nil {
+		at(n, "%s requires at least one argument", cmd)
+		return
+	}
+
+	switch cmd {
+	case CmdCopy, CmdAdd:
+		count := 0
+		for arg := n.Next; arg != nil; arg = arg.Next {
+			count++
+		}
+		if count < 2 {
+			at(n, "%s requires at least two arguments: a source and a destination", cmd)
+		}
+	case "SHELL":
+		if !n.Attributes["json"] {
+			at(n, "SHELL requires the arguments to be a JSON array, eg- SHELL [\"/bin/sh\", \"-c\"]")
+		}
+	case CmdRun, CmdCmd, CmdEntrypoint:
+		// "[ -f x ]" is a valid shell command, only arrays that start with a quoted element were meant to be JSON
+		if !n.Attributes["json"] && looksLikeJSONArray(n.Next.Value) {
+			at(n, "%s arguments look like a JSON array but aren't valid JSON, use double quotes and separate the elements with commas", cmd)
+		}
+	}
+}
+
+var jsonArrayStartRegex = regexp.MustCompile(`^\[\s*["'\x{201C}\x{201D}\x{2018}\x{2019}]`)
+
+func looksLikeJSONArray(args string) bool {
+	return jsonArrayStartRegex.MatchString(strings.TrimSpace(args))
+}
+
+func isKnownInstruction(cmd string) bool {
+	for _, k := range knownInstructions {
+		if cmd == k {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestInstruction returns the known instruction closest to the given unknown one,
+// empty string if none of them is close enough to be a typo.
+func suggestInstruction(cmd string) string {
+	best, bestDistance := "", 3
+	for _, k := range knownInstructions {
+		if d := editDistance(cmd, k); d < bestDistance {
+			best, bestDistance = k, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// indentation returns the number of leading whitespace characters of the given 1-based line
+func indentation(lines []string, line int) int {
+	if line < 1 || line > len(lines) {
+		return 0
+	}
+	l := lines[line-1]
+	return len(l) - len(strings.TrimLeft(l, " \t\ufeff"))
+}
+
+var (
+	// a JSON array argument of an exec-form instruction
+	execFormRegex = regexp.MustCompile(`(?i)^(\s*(?:RUN|CMD|ENTRYPOINT|SHELL)\s+)(\[.*\])\s*$`)
+	smartQuotes   = strings.NewReplacer("\u201c", `"`, "\u201d", `"`, "\u2018", "'", "\u2019", "'")
+)
+
+// Recover fixes common problems that prevent a Dockerfile from being parsed, or make it
+// parse differently than intended: byte order marks in the middle of the file, carriage
+// returns used as line breaks, non-breaking spaces, and JSON arrays written with smart
+// or single quotes.
+// It returns the fixed code along with a description of every fix applied.
+func Recover(code string) (string, []string) {
+	var fixes []string
+
+	if strings.Contains(code, "\ufeff") {
+		code = strings.ReplaceAll(code, "\ufeff", "")
+		fixes = append(fixes, "Removed byte order marks")
+	}
+	if crlf := strings.ReplaceAll(code, "\r\n", ""); strings.Contains(crlf, "\r") {
+		// old Mac line endings, or a mix of them with CRLF, make the parser see a single line
+		code = strings.ReplaceAll(code, "\r\n", "\n")
+		code = strings.ReplaceAll(code, "\r", "\n")
+		fixes = append(fixes, "Converted carriage returns to line breaks")
+	}
+	if strings.Contains(code, "\u00a0") {
+		code = strings.ReplaceAll(code, "\u00a0", " ")
+		fixes = append(fixes, "Replaced non-breaking spaces with regular spaces")
+	}
+
+	lines := strings.Split(code, Linebreak)
+	for i, line := range lines {
+		m := execFormRegex.FindStringSubmatch(strings.TrimSuffix(line, "\r"))
+		if m == nil {
+			continue
+		}
+		array := smartQuotes.Replace(m[2])
+		if !strings.Contains(array, `"`) {
+			array = strings.ReplaceAll(array, "'", `"`)
+		}
+		if array != m[2] {
+			lines[i] = strings.Replace(line, m[2], array, 1)
+			fixes = append(fixes, fmt.Sprintf("Replaced invalid quotes in the JSON array on line %d", i+1))
+		}
+	}
+	return strings.Join(lines, Linebreak), fixes
+}
+
+// IsKnownInstruction returns true if the Dockerfile frontend supports the instruction, eg- "add" or "ADD"
+func IsKnownInstruction(name string) bool {
+	return slices.Contains(knownInstructions, strings.ToUpper(name))
+}
@@ -0,0 +1,137 @@
+package dockerfile
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNewDockerfile_SyntaxErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected []string
+	}{
+		{
+			name: "valid",
+			code: "ARG NODE=20\nFROM node:${NODE}\nSHELL [\"/bin/sh\", \"-c\"]\nRUN [ -f /etc/os-release ] && echo ok\nCMD [\"node\", \"index.js\"]",
+		},
+		{
+			name:     "unknown instruction with suggestion",
+			code:     "FROM node:20\n  RUNN npm ci",
+			expected: []string{"line 2, column 3: unknown instruction RUNN, did you mean RUN?"},
+		},
+		{
+			name:     "unknown instruction",
+			code:     "FROM node:20\nINSTALL express",
+			expected: []string{"line 2, column 1: unknown instruction INSTALL"},
+		},
+		{
+			name:     "instruction before FROM",
+			code:     "ARG NODE=20\nRUN echo hi\nFROM node:20\nWORKDIR /app",
+			expected: []string{"line 2, column 1: RUN must come after a FROM instruction, only ARG can be used before the first FROM"},
+		},
+		{
+			name: "missing arguments",
+			code: "FROM node:20\nEXPOSE\nCOPY . \nADD --chown=node app.tgz",
+			expected: []string{
+				"line 2, column 1: EXPOSE requires at least one argument",
+				"line 3, column 1: COPY requires at least two arguments: a source and a destination",
+				"line 4, column 1: ADD requires at least two arguments: a source and a destination",
+			},
+		},
+		{
+			name: "invalid JSON arrays",
+			code: "FROM node:20\nCMD ['node', 'index.js']\nENTRYPOINT [\"node\", \"index.js\"\nSHELL /bin/bash -c",
+			expected: []string{
+				"line 2, column 1: CMD arguments look like a JSON array but aren't valid JSON, use double quotes and separate the elements with commas",
+				"line 3, column 1: ENTRYPOINT arguments look like a JSON array but aren't valid JSON, use double quotes and separate the elements with commas",
+				"line 4, column 1: SHELL requires the arguments to be a JSON array, eg- SHELL [\"/bin/sh\", \"-c\"]",
+			},
+		},
+		{
+			name:     "parser error",
+			code:     "FROM node:20\nRUN <<EOF\necho hi",
+			expected: []string{"line 2, column 1: unterminated heredoc"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDockerfile(tt.code)
+			if len(tt.expected) == 0 {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			var errs SyntaxErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("expected SyntaxErrors, got %v", err)
+			}
+			var got []string
+			for _, e := range errs {
+				got = append(got, e.Error())
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name          string
+		code          string
+		expected      string
+		expectedFixes int
+	}{
+		{
+			name:     "valid code is untouched",
+			code:     "FROM node:20\r\nRUN npm ci \\\r\n  --omit=dev\r\n",
+			expected: "FROM node:20\r\nRUN npm ci \\\r\n  --omit=dev\r\n",
+		},
+		{
+			name:          "byte order marks",
+			code:          "\ufeffFROM node:20\n\ufeffRUN npm ci",
+			expected:      "FROM node:20\nRUN npm ci",
+			expectedFixes: 1,
+		},
+		{
+			name:          "carriage returns",
+			code:          "FROM node:20\rRUN npm ci \\\r  --omit=dev\r\n",
+			expected:      "FROM node:20\nRUN npm ci \\\n  --omit=dev\n",
+			expectedFixes: 1,
+		},
+		{
+			name:          "non-breaking spaces",
+			code:          "FROM node:20\nRUN\u00a0npm ci",
+			expected:      "FROM node:20\nRUN npm ci",
+			expectedFixes: 1,
+		},
+		{
+			name:          "quotes in JSON arrays",
+			code:          "FROM node:20\nENTRYPOINT [\u201cnode\u201d, \u201cindex.js\u201d]\nCMD ['--port', '3000']\nRUN echo 'hi'",
+			expected:      "FROM node:20\nENTRYPOINT [\"node\", \"index.js\"]\nCMD [\"--port\", \"3000\"]\nRUN echo 'hi'",
+			expectedFixes: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, fixes := Recover(tt.code)
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+			if len(fixes) != tt.expectedFixes {
+				t.Errorf("expected %d fixes, got %q", tt.expectedFixes, fixes)
+			}
+			if tt.expectedFixes > 0 {
+				if _, err := NewDockerfile(got); err != nil {
+					t.Errorf("recovered code doesn't parse: %v", err)
+				}
+			}
+		})
+	}
+}
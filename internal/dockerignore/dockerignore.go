@@ -1,6 +1,8 @@
 package dockerignore
 
 import (
+	"path"
+	"regexp"
 	"strings"
 )
 
@@ -12,16 +14,34 @@ func normalizeEntry(e string) string {
 
 type Dockerignore struct {
 	rawData string
+	// patterns are compiled from the contents the file had when they were last matched
+	patterns     []*ignorePattern
+	compiledFrom string
 }
 
 func NewDockerignore(content string) *Dockerignore {
 	return &Dockerignore{rawData: content}
 }
 
+// Raw returns the content of the file, empty if the project has none
 func (d *Dockerignore) Raw() string {
+	if d == nil {
+		return ""
+	}
 	return d.rawData
 }
 
+// Contains returns true if the given entry is present in the .dockerignore file
+func (d *Dockerignore) Contains(entry string) bool {
+	n := normalizeEntry(entry)
+	for _, e := range strings.Split(d.rawData, "\n") {
+		if normalizeEntry(e) == n {
+			return true
+		}
+	}
+	return false
+}
+
 // AddIfNotPresent adds the given entries to the .dockerignore file if they are not already present in it.
 // It returns the entries that were added.
 func (d *Dockerignore) AddIfNotPresent(entries []string) []string {
@@ -65,3 +85,89 @@ func (d *Dockerignore) AddIfNotPresent(entries []string) []string {
 
 	return toBeAdded
 }
+
+// Excludes returns true if the file or directory at the given path of the build context is left out of it.
+// Patterns match the path or any of its parent directories, and the last pattern that matches decides,
+// so that "!" patterns can add back files excluded by earlier ones.
+func (d *Dockerignore) Excludes(p string) bool {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	excluded := false
+	for _, pattern := range d.compile() {
+		if pattern.re.MatchString(p) {
+			excluded = !pattern.negated
+		}
+	}
+	return excluded
+}
+
+// ignorePattern is a pattern of the .dockerignore file, compiled to match paths
+type ignorePattern struct {
+	re      *regexp.Regexp
+	negated bool
+}
+
+// compile returns the patterns of the file, compiling them again only if the file changed
+func (d *Dockerignore) compile() []*ignorePattern {
+	if d.patterns != nil && d.compiledFrom == d.rawData {
+		return d.patterns
+	}
+	d.patterns = []*ignorePattern{}
+	d.compiledFrom = d.rawData
+	for _, line := range strings.Split(d.rawData, "\n") {
+		pattern := strings.TrimSpace(line)
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(pattern, "!")), "/")
+		if pattern == "" {
+			continue
+		}
+		if re, err := regexp.Compile(patternRegex(pattern)); err == nil {
+			d.patterns = append(d.patterns, &ignorePattern{re: re, negated: negated})
+		}
+	}
+	return d.patterns
+}
+
+// patternRegex translates a .dockerignore pattern to a regular expression that matches the paths it
+// excludes: "**" matches any number of directories, "*" and "?" don't match the path separator
+func patternRegex(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta(pattern[i:]))
+				i = len(pattern)
+				continue
+			}
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		case c == '\\' && i+1 < len(pattern):
+			b.WriteString(regexp.QuoteMeta(pattern[i+1 : i+2]))
+			i++
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	// a pattern matching a directory excludes everything in it
+	b.WriteString("(/.*)?$")
+	return b.String()
+}
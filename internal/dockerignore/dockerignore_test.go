@@ -65,3 +65,26 @@ func TestDockerignore_AddIfNotPresent_TrailingSlashHandling(t *testing.T) {
 		t.Errorf("expected added entry '.github', got %q", added[0])
 	}
 }
+
+func TestDockerignore_Excludes(t *testing.T) {
+	d := NewDockerignore("# build output\nnode_modules\n/dist/\n*.md\n!README.md\n**/*.test.js\ndocs/**/*.png\n.env*\n")
+	tests := map[string]bool{
+		"node_modules":            true,
+		"node_modules/a/index.js": true,
+		"dist/main.js":            true,
+		"CHANGELOG.md":            true,
+		"README.md":               false,
+		"docs/guide.md":           false,
+		"src/app.test.js":         true,
+		"app.test.js":             true,
+		"docs/img/a/b.png":        true,
+		".env.production":         true,
+		"src/app.js":              false,
+		"src/node_modules":        false,
+	}
+	for p, expected := range tests {
+		if got := d.Excludes(p); got != expected {
+			t.Errorf("expected Excludes(%q) to be %v, got %v", p, expected, got)
+		}
+	}
+}
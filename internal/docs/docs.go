@@ -0,0 +1,103 @@
+// Package docs searches dockershrink's documentation on optimizing images, so that the LLM can look up
+// guidance it needs instead of receiving all of it in the prompt. Passages are ranked by the similarity of
+// their embeddings to the query, or by keywords if no embeddings provider is available.
+package docs
+
+import (
+	"embed"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:embed corpus/*.md
+var corpus embed.FS
+
+// Passage is a section of a document in the corpus
+type Passage struct {
+	// Source is the document the passage belongs to, eg- build-cache.md
+	Source string `json:"source"`
+	// Title is the title of the document followed by the title of the section, eg- "Build cache: Cache mounts"
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+var (
+	loadCorpus     sync.Once
+	corpusPassages []*Passage
+)
+
+// Passages returns the passages of the built-in documentation, sorted by document
+func Passages() []*Passage {
+	loadCorpus.Do(func() {
+		entries, err := corpus.ReadDir("corpus")
+		if err != nil {
+			// the corpus is embedded at build time, it can't be missing
+			panic(err)
+		}
+		names := make([]string, 0, len(entries))
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			content, err := corpus.ReadFile(path.Join("corpus", name))
+			if err != nil {
+				panic(err)
+			}
+			corpusPassages = append(corpusPassages, split(name, string(content))...)
+		}
+	})
+	return corpusPassages
+}
+
+// Lookup returns the passage of the built-in documentation with the given title, nil if there is none
+func Lookup(title string) *Passage {
+	for _, p := range Passages() {
+		if p.Title == title {
+			return p
+		}
+	}
+	return nil
+}
+
+// split divides a markdown document into passages, one for every "## " section.
+// Text before the first section is not part of any passage.
+func split(source, content string) []*Passage {
+	title := strings.TrimSuffix(source, path.Ext(source))
+	passages := []*Passage{}
+	var current *Passage
+	var text []string
+
+	flush := func() {
+		if current != nil {
+			current.Text = strings.TrimSpace(strings.Join(text, "\n"))
+			if current.Text != "" {
+				passages = append(passages, current)
+			}
+		}
+		current, text = nil, nil
+	}
+	for _, line := range strings.Split(content, "\n") {
+		switch {
+		case strings.HasPrefix(line, "# "):
+			title = strings.TrimSpace(strings.TrimPrefix(line, "# "))
+		case strings.HasPrefix(line, "## "):
+			flush()
+			current = &Passage{
+				Source: source,
+				Title:  title + ": " + strings.TrimSpace(strings.TrimPrefix(line, "## ")),
+			}
+		case current != nil:
+			text = append(text, line)
+		}
+	}
+	flush()
+	return passages
+}
+
+// content is what gets embedded and searched for a passage
+func (p *Passage) content() string {
+	return p.Title + "\n" + p.Text
+}
@@ -0,0 +1,45 @@
+package docs
+
+import "testing"
+
+func TestSplit(t *testing.T) {
+	content := `# Build cache
+
+Introduction that isn't part of any section.
+
+## Cache mounts
+Use a cache mount.
+
+Second paragraph.
+
+## Empty section
+
+## Secret mounts
+Use a secret mount.
+`
+	passages := split("build-cache.md", content)
+	expected := []*Passage{
+		{Source: "build-cache.md", Title: "Build cache: Cache mounts", Text: "Use a cache mount.\n\nSecond paragraph."},
+		{Source: "build-cache.md", Title: "Build cache: Secret mounts", Text: "Use a secret mount."},
+	}
+	if len(passages) != len(expected) {
+		t.Fatalf("split() returned %d passages; want %d", len(passages), len(expected))
+	}
+	for i, e := range expected {
+		if *passages[i] != *e {
+			t.Errorf("passage %d = %+v; want %+v", i, passages[i], e)
+		}
+	}
+}
+
+func TestPassages(t *testing.T) {
+	passages := Passages()
+	if len(passages) == 0 {
+		t.Fatal("Passages() returned no passages")
+	}
+	for _, p := range passages {
+		if p.Source == "" || p.Title == "" || p.Text == "" {
+			t.Errorf("incomplete passage %+v", p)
+		}
+	}
+}
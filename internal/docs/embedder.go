@@ -0,0 +1,179 @@
+package docs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/ratelimit"
+	"github.com/openai/openai-go"
+)
+
+const (
+	// ProviderOpenAI computes embeddings with the OpenAI embeddings API, or any API compatible with it
+	ProviderOpenAI = "openai"
+	// ProviderLocal computes embeddings by running a local command, eg- a script running an ONNX model
+	ProviderLocal = "local"
+	// ProviderKeyword doesn't use embeddings, passages are ranked by the keywords they share with the query
+	ProviderKeyword = "keyword"
+	// ProviderNone is an alias of ProviderKeyword
+	ProviderNone = "none"
+
+	// DefaultOpenAIModel is the embeddings model used by the openai provider if none is configured
+	DefaultOpenAIModel = openai.EmbeddingModelTextEmbedding3Small
+)
+
+// Embedder computes the embeddings of texts, one vector per text in the same order
+type Embedder interface {
+	// Name identifies the embedder and its model. Embeddings computed by embedders with
+	// different names are never compared with each other.
+	Name() string
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+// EmbedderOptions select and configure the embeddings provider
+type EmbedderOptions struct {
+	// Provider is one of openai, local, keyword or none. If it's empty, openai is used
+	// when Client is set and keyword search otherwise.
+	Provider string
+	// Model is the embeddings model (openai)
+	Model string
+	// Client is used to call the embeddings API (openai)
+	Client *openai.Client
+	// Command is the program and arguments that compute embeddings (local)
+	Command []string
+	// Limiter paces the requests to the provider, they aren't paced if it's nil
+	Limiter *ratelimit.Limiter
+}
+
+// NewEmbedder returns the embedder of the configured provider.
+// A nil Embedder is returned for keyword search, which doesn't need one.
+func NewEmbedder(opts *EmbedderOptions) (Embedder, error) {
+	e, err := newEmbedder(opts)
+	if err != nil || e == nil || opts.Limiter == nil {
+		return e, err
+	}
+	return &limitedEmbedder{Embedder: e, limiter: opts.Limiter}, nil
+}
+
+func newEmbedder(opts *EmbedderOptions) (Embedder, error) {
+	provider := opts.Provider
+	if provider == "" {
+		provider = ProviderKeyword
+		if opts.Client != nil {
+			provider = ProviderOpenAI
+		}
+	}
+
+	switch provider {
+	case ProviderOpenAI:
+		if opts.Client == nil {
+			return nil, fmt.Errorf("the %s embeddings provider requires an OpenAI API key", ProviderOpenAI)
+		}
+		model := opts.Model
+		if model == "" {
+			model = DefaultOpenAIModel
+		}
+		return &OpenAIEmbedder{client: opts.Client, model: model}, nil
+	case ProviderLocal:
+		if len(opts.Command) == 0 {
+			return nil, fmt.Errorf("the %s embeddings provider requires a command", ProviderLocal)
+		}
+		return &CommandEmbedder{command: opts.Command}, nil
+	case ProviderKeyword, ProviderNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown embeddings provider %q, must be one of %s, %s, %s or %s", provider, ProviderOpenAI, ProviderLocal, ProviderKeyword, ProviderNone)
+	}
+}
+
+// limitedEmbedder waits for its limiter before every request
+type limitedEmbedder struct {
+	Embedder
+	limiter *ratelimit.Limiter
+}
+
+func (e *limitedEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	if err := e.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return e.Embedder.Embed(ctx, texts)
+}
+
+// OpenAIEmbedder uses the OpenAI embeddings API
+type OpenAIEmbedder struct {
+	client *openai.Client
+	model  string
+}
+
+func (e *OpenAIEmbedder) Name() string {
+	return ProviderOpenAI + "/" + e.model
+}
+
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	response, err := e.client.Embeddings.New(ctx, openai.EmbeddingNewParams{
+		Input: openai.F[openai.EmbeddingNewParamsInputUnion](openai.EmbeddingNewParamsInputArrayOfStrings(texts)),
+		Model: openai.F(e.model),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get embeddings from %s: %w", e.Name(), err)
+	}
+	vectors := make([][]float64, len(texts))
+	for _, d := range response.Data {
+		if d.Index < 0 || int(d.Index) >= len(texts) {
+			return nil, fmt.Errorf("%s returned an embedding for input %d, only %d inputs were sent", e.Name(), d.Index, len(texts))
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("%s returned no embedding for input %d", e.Name(), i)
+		}
+	}
+	return vectors, nil
+}
+
+// CommandEmbedder runs a command to compute embeddings, so that any local model can be used.
+// The command receives {"texts": ["..."]} on stdin and must write {"embeddings": [[0.1, ...]]} to stdout.
+type CommandEmbedder struct {
+	command []string
+}
+
+type commandRequest struct {
+	Texts []string `json:"texts"`
+}
+
+type commandResponse struct {
+	Embeddings [][]float64 `json:"embeddings"`
+}
+
+func (e *CommandEmbedder) Name() string {
+	return ProviderLocal + "/" + strings.Join(e.command, " ")
+}
+
+func (e *CommandEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	input, err := json.Marshal(&commandRequest{Texts: texts})
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.command[0], e.command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("embeddings command %q failed: %w: %s", e.command[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	response := &commandResponse{}
+	if err := json.Unmarshal(stdout.Bytes(), response); err != nil {
+		return nil, fmt.Errorf("failed to parse the output of embeddings command %q: %w", e.command[0], err)
+	}
+	if len(response.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("embeddings command %q returned %d embeddings for %d texts", e.command[0], len(response.Embeddings), len(texts))
+	}
+	return response.Embeddings, nil
+}
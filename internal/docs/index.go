@@ -0,0 +1,284 @@
+package docs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Index searches a set of passages
+type Index struct {
+	passages []*Passage
+	// embedder is nil if passages are only searched by keywords
+	embedder Embedder
+	// cacheDir is where the embeddings of the passages are persisted, they are computed on every run if it's empty
+	cacheDir string
+	keywords *keywordIndex
+
+	mu      sync.Mutex
+	vectors [][]float64
+}
+
+// NewIndex returns an index of the passages. If embedder is nil, passages are searched by keywords.
+func NewIndex(passages []*Passage, embedder Embedder, cacheDir string) *Index {
+	return &Index{
+		passages: passages,
+		embedder: embedder,
+		cacheDir: cacheDir,
+		keywords: newKeywordIndex(passages),
+	}
+}
+
+// DefaultCacheDir returns the directory inside the user's cache directory where embeddings are
+// persisted, eg- ~/.cache/dockershrink/embeddings on linux
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(dir, "dockershrink", "embeddings"), nil
+}
+
+// Provider describes how the index is searched, eg- "openai/text-embedding-3-small" or "keyword"
+func (idx *Index) Provider() string {
+	if idx.embedder == nil {
+		return ProviderKeyword
+	}
+	return idx.embedder.Name()
+}
+
+// Search returns up to k passages most relevant to the query, the most relevant first.
+// An error is returned if embeddings can't be computed, SearchKeywords can be used instead.
+func (idx *Index) Search(ctx context.Context, query string, k int) ([]*Passage, error) {
+	if idx.embedder == nil {
+		return idx.SearchKeywords(query, k), nil
+	}
+	vectors, err := idx.passageVectors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	q, err := idx.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	if len(q) != 1 {
+		return nil, fmt.Errorf("%s returned %d embeddings for the query", idx.embedder.Name(), len(q))
+	}
+
+	scores := make([]float64, len(idx.passages))
+	for i, v := range vectors {
+		scores[i] = cosineSimilarity(q[0], v)
+	}
+	return top(idx.passages, scores, k), nil
+}
+
+// SearchKeywords returns up to k passages sharing keywords with the query, the most relevant first
+func (idx *Index) SearchKeywords(query string, k int) []*Passage {
+	return top(idx.passages, idx.keywords.scores(query), k)
+}
+
+// Precompute computes the embeddings of the passages unless they're cached already, and returns the path of the
+// cache file holding them, eg- to ship it to a machine without access to the embeddings provider
+func (idx *Index) Precompute(ctx context.Context) (string, error) {
+	if idx.embedder == nil || idx.cacheDir == "" {
+		return "", fmt.Errorf("the %s index has no embeddings to cache", idx.Provider())
+	}
+	if _, err := idx.passageVectors(ctx); err != nil {
+		return "", err
+	}
+	path := idx.cachePath()
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("failed to cache the embeddings of the documentation: %w", err)
+	}
+	return path, nil
+}
+
+// passageVectors returns the embeddings of the passages, computing them on first use.
+// They are read from and written to the cache so that they're only computed once per embedder and corpus.
+func (idx *Index) passageVectors(ctx context.Context) ([][]float64, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.vectors != nil {
+		return idx.vectors, nil
+	}
+
+	texts := idx.texts()
+	cachePath := idx.cachePath()
+	if cachePath != "" {
+		if content, err := os.ReadFile(cachePath); err == nil {
+			var vectors [][]float64
+			if json.Unmarshal(content, &vectors) == nil && len(vectors) == len(texts) {
+				idx.vectors = vectors
+				return vectors, nil
+			}
+		}
+	}
+
+	vectors, err := idx.embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("%s returned %d embeddings for %d passages", idx.embedder.Name(), len(vectors), len(texts))
+	}
+	idx.vectors = vectors
+
+	if cachePath != "" {
+		// the cache only saves time, failing to write it isn't an error
+		if content, err := json.Marshal(vectors); err == nil && os.MkdirAll(idx.cacheDir, 0o755) == nil {
+			_ = os.WriteFile(cachePath, content, 0o644)
+		}
+	}
+	return vectors, nil
+}
+
+func (idx *Index) texts() []string {
+	texts := make([]string, len(idx.passages))
+	for i, p := range idx.passages {
+		texts[i] = p.content()
+	}
+	return texts
+}
+
+// cachePath returns the file the embeddings of the passages are cached in, empty if they aren't cached
+func (idx *Index) cachePath() string {
+	if idx.cacheDir == "" {
+		return ""
+	}
+	return filepath.Join(idx.cacheDir, cacheKey(idx.embedder.Name(), idx.texts())+".json")
+}
+
+// cacheKey identifies the embeddings of the texts computed by the named embedder
+func cacheKey(embedder string, texts []string) string {
+	h := sha256.New()
+	h.Write([]byte(embedder))
+	for _, t := range texts {
+		h.Write([]byte{0})
+		h.Write([]byte(t))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// top returns up to k passages with the highest positive scores, keeping the corpus order for equal scores
+func top(passages []*Passage, scores []float64, k int) []*Passage {
+	order := make([]int, len(passages))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return scores[order[i]] > scores[order[j]] })
+
+	results := []*Passage{}
+	for _, i := range order {
+		if len(results) == k || scores[i] <= 0 {
+			break
+		}
+		results = append(results, passages[i])
+	}
+	return results
+}
+
+// keywordIndex ranks passages with BM25
+type keywordIndex struct {
+	terms     []map[string]int
+	lengths   []int
+	avgLength float64
+	// docFreq is the number of passages each term appears in
+	docFreq map[string]int
+}
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+func newKeywordIndex(passages []*Passage) *keywordIndex {
+	ki := &keywordIndex{docFreq: map[string]int{}}
+	total := 0
+	for _, p := range passages {
+		counts := map[string]int{}
+		tokens := tokenize(p.content())
+		for _, t := range tokens {
+			counts[t]++
+		}
+		for t := range counts {
+			ki.docFreq[t]++
+		}
+		ki.terms = append(ki.terms, counts)
+		ki.lengths = append(ki.lengths, len(tokens))
+		total += len(tokens)
+	}
+	if len(passages) > 0 {
+		ki.avgLength = float64(total) / float64(len(passages))
+	}
+	return ki
+}
+
+func (ki *keywordIndex) scores(query string) []float64 {
+	scores := make([]float64, len(ki.terms))
+	n := float64(len(ki.terms))
+	seen := map[string]bool{}
+	for _, t := range tokenize(query) {
+		if seen[t] || ki.docFreq[t] == 0 {
+			continue
+		}
+		seen[t] = true
+		df := float64(ki.docFreq[t])
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for i, counts := range ki.terms {
+			tf := float64(counts[t])
+			if tf == 0 {
+				continue
+			}
+			norm := 1 - bm25B + bm25B*float64(ki.lengths[i])/ki.avgLength
+			scores[i] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+	}
+	return scores
+}
+
+// stopwords are too common to tell passages apart
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "be": true, "by": true, "can": true,
+	"do": true, "for": true, "from": true, "how": true, "i": true, "if": true, "in": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "should": true, "so": true, "that": true, "the": true,
+	"this": true, "to": true, "use": true, "what": true, "when": true, "with": true,
+}
+
+// tokenize splits text into lowercase words, keeping characters that are common in commands and
+// image names, eg- "--omit=dev" yields "omit" and "dev", "node:22-alpine" yields "node", "22" and "alpine".
+func tokenize(text string) []string {
+	tokens := []string{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if !stopwords[word] {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,197 @@
+package docs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/ratelimit"
+)
+
+// wordEmbedder embeds texts as vectors counting the occurrences of a fixed set of words
+type wordEmbedder struct {
+	words []string
+	calls int
+	err   error
+}
+
+func (e *wordEmbedder) Name() string { return "test/words" }
+
+func (e *wordEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	e.calls++
+	if e.err != nil {
+		return nil, e.err
+	}
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vectors[i] = make([]float64, len(e.words))
+		for j, w := range e.words {
+			vectors[i][j] = float64(strings.Count(strings.ToLower(text), w))
+		}
+	}
+	return vectors, nil
+}
+
+var testPassages = []*Passage{
+	{Source: "a.md", Title: "Base images: Alpine", Text: "Use alpine images for a smaller final stage."},
+	{Source: "a.md", Title: "Build cache: Cache mounts", Text: "Keep the npm cache out of the image with a cache mount."},
+	{Source: "b.md", Title: "Layers: dockerignore", Text: "Exclude node_modules from the build context."},
+}
+
+func titles(passages []*Passage) []string {
+	t := []string{}
+	for _, p := range passages {
+		t = append(t, p.Title)
+	}
+	return t
+}
+
+func TestIndex_SearchKeywords(t *testing.T) {
+	idx := NewIndex(testPassages, nil, "")
+	tests := []struct {
+		query    string
+		k        int
+		expected []string
+	}{
+		{query: "how do I use a cache mount for npm?", k: 3, expected: []string{"Build cache: Cache mounts"}},
+		{query: "exclude NODE_MODULES", k: 3, expected: []string{"Layers: dockerignore"}},
+		{query: "smaller alpine image", k: 1, expected: []string{"Base images: Alpine"}},
+		{query: "kubernetes", k: 3, expected: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := idx.Search(context.Background(), tt.query, tt.k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(titles(results), ", "); got != strings.Join(tt.expected, ", ") {
+				t.Errorf("Search() = [%s]; want [%s]", got, strings.Join(tt.expected, ", "))
+			}
+		})
+	}
+}
+
+func TestIndex_SearchEmbeddings(t *testing.T) {
+	cacheDir := t.TempDir()
+	embedder := &wordEmbedder{words: []string{"alpine", "cache", "node_modules"}}
+	idx := NewIndex(testPassages, embedder, cacheDir)
+
+	results, err := idx.Search(context.Background(), "npm cache", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Title != "Build cache: Cache mounts" {
+		t.Errorf("Search() = %v; want the cache mounts passage", titles(results))
+	}
+	if _, err := idx.Search(context.Background(), "alpine", 2); err != nil {
+		t.Fatal(err)
+	}
+	// passages are embedded once, then only the queries are
+	if embedder.calls != 3 {
+		t.Errorf("Embed() was called %d times; want 3", embedder.calls)
+	}
+
+	// a new index reads the embeddings of the passages from the cache
+	entries, _ := os.ReadDir(cacheDir)
+	if len(entries) != 1 {
+		t.Fatalf("cache contains %d files; want 1", len(entries))
+	}
+	cached := &wordEmbedder{words: embedder.words}
+	if _, err := NewIndex(testPassages, cached, cacheDir).Search(context.Background(), "alpine", 1); err != nil {
+		t.Fatal(err)
+	}
+	if cached.calls != 1 {
+		t.Errorf("Embed() was called %d times with a warm cache; want 1", cached.calls)
+	}
+}
+
+func TestIndex_Precompute(t *testing.T) {
+	cacheDir := t.TempDir()
+	embedder := &wordEmbedder{words: []string{"alpine", "cache"}}
+	path, err := NewIndex(testPassages, embedder, cacheDir).Precompute(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(path) != cacheDir || embedder.calls != 1 {
+		t.Errorf("Precompute() = %s after %d calls to Embed()", path, embedder.calls)
+	}
+	// the embeddings are read from the cache file when it's copied to another machine
+	other := t.TempDir()
+	content, _ := os.ReadFile(path)
+	os.WriteFile(filepath.Join(other, filepath.Base(path)), content, 0o644)
+	offline := &wordEmbedder{words: embedder.words}
+	if _, err := NewIndex(testPassages, offline, other).Precompute(context.Background()); err != nil || offline.calls != 0 {
+		t.Errorf("expected the copied embeddings to be used, got %v after %d calls", err, offline.calls)
+	}
+
+	if _, err := NewIndex(testPassages, nil, cacheDir).Precompute(context.Background()); err == nil {
+		t.Errorf("expected an error for an index searched by keywords")
+	}
+}
+
+func TestIndex_SearchEmbeddingsError(t *testing.T) {
+	embedder := &wordEmbedder{err: errors.New("unavailable")}
+	idx := NewIndex(testPassages, embedder, "")
+	if _, err := idx.Search(context.Background(), "cache", 1); err == nil {
+		t.Fatal("Search() expected an error")
+	}
+	if results := idx.SearchKeywords("cache", 1); len(results) != 1 {
+		t.Errorf("SearchKeywords() returned %d results; want 1", len(results))
+	}
+}
+
+func TestNewEmbedder(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     *EmbedderOptions
+		expected string
+		wantErr  bool
+	}{
+		{name: "keyword by default without a client", opts: &EmbedderOptions{}, expected: ""},
+		{name: "none", opts: &EmbedderOptions{Provider: ProviderNone}, expected: ""},
+		{name: "local", opts: &EmbedderOptions{Provider: ProviderLocal, Command: []string{"embed", "--onnx"}}, expected: "local/embed --onnx"},
+		{name: "local with a rate limit", opts: &EmbedderOptions{Provider: ProviderLocal, Command: []string{"embed"}, Limiter: ratelimit.New(60)}, expected: "local/embed"},
+		{name: "keyword with a rate limit", opts: &EmbedderOptions{Limiter: ratelimit.New(60)}, expected: ""},
+		{name: "local without a command", opts: &EmbedderOptions{Provider: ProviderLocal}, wantErr: true},
+		{name: "openai without a client", opts: &EmbedderOptions{Provider: ProviderOpenAI}, wantErr: true},
+		{name: "unknown", opts: &EmbedderOptions{Provider: "word2vec"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewEmbedder(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewEmbedder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			name := ""
+			if e != nil {
+				name = e.Name()
+			}
+			if name != tt.expected {
+				t.Errorf("NewEmbedder() = %q; want %q", name, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCommandEmbedder(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "embed.sh")
+	// ignores the input and returns a fixed embedding for each of the two texts
+	content := "#!/bin/sh\ncat > /dev/null\necho '{\"embeddings\": [[1, 0], [0, 1]]}'\n"
+	if err := os.WriteFile(script, []byte(content), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	e := &CommandEmbedder{command: []string{script}}
+	vectors, err := e.Embed(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][1] != 1 {
+		t.Errorf("Embed() = %v", vectors)
+	}
+	if _, err := e.Embed(context.Background(), []string{"a"}); err == nil {
+		t.Error("Embed() expected an error when the number of embeddings doesn't match")
+	}
+}
@@ -0,0 +1,264 @@
+// Package explain tells users why dockershrink changed their image definition: the principle behind every action,
+// why it makes the image better and where to read more about it, so that they learn the practices instead of copying the changes.
+package explain
+
+import (
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+// Upstream documentation the principles link to
+const (
+	linkBestPractices = "https://docs.docker.com/build/building/best-practices/"
+	linkMultistage    = "https://docs.docker.com/build/building/multi-stage/"
+	linkCache         = "https://docs.docker.com/build/cache/"
+	linkSecrets       = "https://docs.docker.com/build/building/secrets/"
+	linkReference     = "https://docs.docker.com/reference/dockerfile/"
+	linkNpmCI         = "https://docs.npmjs.com/cli/commands/npm-ci"
+)
+
+// principle is the practice behind the actions of one or more rules
+type principle struct {
+	name      string
+	rationale string
+	buildTime string
+	docs      []string
+	links     []string
+}
+
+var (
+	dockerignore = &principle{
+		name:      "Keep the build context small with .dockerignore",
+		rationale: "Files the image doesn't need, eg- node_modules, .git or local .env files, are sent to the builder on every build, end up in the image with \"COPY . .\" and invalidate the cache whenever they change.",
+		buildTime: "Faster: less context is sent to the builder and unrelated changes no longer invalidate the cache.",
+		docs:      []string{"Layers: Using .dockerignore"},
+		links:     []string{linkBestPractices},
+	}
+	smallBaseImage = &principle{
+		name:      "Use a small final base image",
+		rationale: "The final base image is shipped with every copy of the image. Slim and alpine variants leave out compilers, documentation and tools the application doesn't use, which also removes their vulnerabilities.",
+		buildTime: "Faster pulls and pushes of the smaller image, builds themselves take about as long.",
+		docs:      []string{"Base images: Slim and alpine variants"},
+		links:     []string{linkBestPractices},
+	}
+	distroless = &principle{
+		name:      "Run on a distroless image",
+		rationale: "Distroless images only contain the runtime and the libraries it links against, without a shell or package manager, so there is little to exploit and little to ship.",
+		buildTime: "Faster pulls and pushes of the smaller image.",
+		docs:      []string{"Base images: Distroless images"},
+		links:     []string{linkMultistage},
+	}
+	platforms = &principle{
+		name:      "Build on base images available for every target platform",
+		rationale: "A base image without a variant for one of the platforms the image is built for fails the build on that platform, or runs under emulation.",
+		links:     []string{linkReference},
+	}
+	pinning = &principle{
+		name:      "Pin base images by digest",
+		rationale: "Tags are moved to new images, so a digest makes builds reproducible and changes to the base image explicit and reviewable.",
+		buildTime: "Cached layers are reused until the digest is updated on purpose.",
+		docs:      []string{"Base images: Pinning base images"},
+		links:     []string{linkBestPractices},
+	}
+	multistage = &principle{
+		name:      "Separate build and runtime stages",
+		rationale: "Compilers, dev dependencies and sources are only needed to build the application. Building in one stage and copying the output into a clean final stage keeps them out of the image.",
+		buildTime: "Stages that don't depend on each other are built in parallel by BuildKit.",
+		docs:      []string{"Multistage builds: Separating build and runtime stages", "Multistage builds: Choosing what to copy into the final stage"},
+		links:     []string{linkMultistage},
+	}
+	runtimeFiles = &principle{
+		name:      "Copy only what the application needs at runtime",
+		rationale: "Whatever is copied into the final stage is shipped with the image even if the application never reads it, eg- tests, sources of compiled code and documentation.",
+		docs:      []string{"Multistage builds: Choosing what to copy into the final stage"},
+		links:     []string{linkMultistage},
+	}
+	stagePaths = &principle{
+		name:      "Keep the paths of the final stage working",
+		rationale: "Moving instructions into another stage changes their working directory, so relative paths are rewritten for the application to find its files as before.",
+		docs:      []string{"Multistage builds: Choosing what to copy into the final stage"},
+		links:     []string{linkReference},
+	}
+	productionDependencies = &principle{
+		name:      "Install only production dependencies",
+		rationale: "devDependencies such as test frameworks, bundlers and type definitions are never used by the running application, yet often make up most of node_modules.",
+		buildTime: "Faster: fewer packages are downloaded and installed in the final stage.",
+		docs:      []string{"Node.js dependencies: Installing only production dependencies", "Node.js dependencies: Pruning dependencies after the build"},
+		links:     []string{linkMultistage},
+	}
+	lockfile = &principle{
+		name:      "Install dependencies from the lockfile",
+		rationale: "Installing from the lockfile, eg- with \"npm ci\", installs exactly the versions that were tested instead of whatever matches the ranges of package.json on the day of the build.",
+		buildTime: "Usually faster: dependencies don't have to be resolved again.",
+		docs:      []string{"Node.js dependencies: Using the lockfile"},
+		links:     []string{linkNpmCI},
+	}
+	layerOrder = &principle{
+		name:      "Order instructions from the least to the most frequently changed",
+		rationale: "Docker reuses the cached layers up to the first instruction whose inputs changed. Installing dependencies before copying the sources means that code changes don't reinstall them.",
+		buildTime: "Faster rebuilds: code changes reuse the cached dependency install.",
+		docs:      []string{"Build cache: Ordering instructions for caching", "Build cache: Cache mounts"},
+		links:     []string{linkCache},
+	}
+	chainedCommands = &principle{
+		name:      "Combine related RUN instructions",
+		rationale: "Every RUN instruction adds a layer. Files deleted in a later layer are still shipped in the earlier one, so installing and cleaning up must happen in the same instruction.",
+		buildTime: "Slightly faster: fewer layers are created and pushed.",
+		docs:      []string{"Layers: Combining RUN instructions"},
+		links:     []string{linkBestPractices},
+	}
+	systemPackages = &principle{
+		name:      "Install only the system packages the image needs, and clean up in the same layer",
+		rationale: "Package managers install recommended packages and keep their indexes and caches by default, which the application never uses.",
+		buildTime: "Faster: fewer packages are downloaded and installed.",
+		docs:      []string{"Layers: System packages", "Node.js dependencies: Cleaning the package manager cache"},
+		links:     []string{linkBestPractices},
+	}
+	buildTools = &principle{
+		name:      "Keep build tools out of the final stage",
+		rationale: "Compilers and headers are only needed to build native modules. Installing them in a build stage keeps them, and their vulnerabilities, out of the image.",
+		docs:      []string{"Multistage builds: Separating build and runtime stages", "Layers: System packages"},
+		links:     []string{linkMultistage},
+	}
+	secretMounts = &principle{
+		name:      "Pass secrets with secret mounts",
+		rationale: "Secrets passed with ARG or ENV, or copied with COPY, remain in the layers or the history of the image, where anyone who can pull it can read them.",
+		docs:      []string{"Build cache: Secret mounts"},
+		links:     []string{linkSecrets},
+	}
+	monorepo = &principle{
+		name:      "Build only the workspace package the image runs",
+		rationale: "Copying the whole monorepo installs the dependencies of every package and invalidates the cache whenever any of them changes.",
+		buildTime: "Faster: only the package and its dependencies are installed, and changes to other packages don't invalidate the cache.",
+		docs:      []string{"Monorepos: Pruning the workspace", "Monorepos: Installing a single workspace package"},
+	}
+	leastPrivilege = &principle{
+		name:      "Run with the least privilege",
+		rationale: "A process running as root, or able to gain root through setuid binaries, turns any vulnerability of the application into control of the container.",
+		links:     []string{linkBestPractices},
+	}
+	healthcheck = &principle{
+		name:      "Declare how to check the health of the container",
+		rationale: "A HEALTHCHECK lets Docker and orchestrators tell a running container apart from a working one, and restart it when it stops responding.",
+		links:     []string{linkReference},
+	}
+	verifiedDownloads = &principle{
+		name:      "Verify what is downloaded during the build",
+		rationale: "A script piped from the network into a shell runs whatever the server returns on the day of the build. Checking it against a known checksum makes the build reproducible and tamper-evident.",
+		links:     []string{linkBestPractices},
+	}
+	productionEnv = &principle{
+		name:      "Run Node.js in production mode",
+		rationale: "NODE_ENV=production makes package managers skip devDependencies and many libraries, eg- Express, skip debugging features that slow them down.",
+		docs:      []string{"Node.js dependencies: Installing only production dependencies"},
+	}
+	syntax = &principle{
+		name:      "Keep the Dockerfile valid",
+		rationale: "The Dockerfile couldn't be parsed as it was, so it was repaired before being optimized. Check that the repaired instructions are what you meant.",
+		links:     []string{linkReference},
+	}
+)
+
+// rules maps the rules dockershrink takes actions for to their principle
+var rules = map[string]*principle{
+	"create-dockerignore":               dockerignore,
+	"update-dockerignore":               dockerignore,
+	"final-stage-slim-baseimage":        smallBaseImage,
+	"migrate-final-stage":               distroless,
+	"base-image-platforms":              platforms,
+	"pin-base-images":                   pinning,
+	"copy-runtime-paths-only":           runtimeFiles,
+	"fix-copy-from-relative-paths":      stagePaths,
+	"restore-final-stage-workdir":       stagePaths,
+	"unresolved-entrypoint-path":        stagePaths,
+	"install-ignores-lockfile":          lockfile,
+	"reorder-layers":                    layerOrder,
+	"run-commands-not-chained":          chainedCommands,
+	"separate-system-package-installs":  chainedCommands,
+	"apt-get-bloat":                     systemPackages,
+	"apt-get-install-recommends":        systemPackages,
+	"apk-add-cache":                     systemPackages,
+	"downloaded-archive-left-behind":    systemPackages,
+	"build-tools-in-final-stage":        buildTools,
+	"secret-mounts":                     secretMounts,
+	"prune-monorepo-workspace":          monorepo,
+	"harden-final-stage":                leastPrivilege,
+	"run-as-non-root":                   leastPrivilege,
+	"drop-setuid-binaries":              leastPrivilege,
+	"add-healthcheck":                   healthcheck,
+	"remote-script-piped-to-shell":      verifiedDownloads,
+	"set-node-env-production":           productionEnv,
+	"syntax-repair":                     syntax,
+	"syntax-recovery":                   syntax,
+	"devdependencies-in-final-stage":    productionDependencies,
+	"source-copied-before-dependencies": layerOrder,
+}
+
+// keywords find the principle of the rules the LLM names itself, eg- "use-multistage-builds".
+// They're matched in order, so more specific keywords come first.
+var keywords = []struct {
+	keyword   string
+	principle *principle
+}{
+	{"dockerignore", dockerignore},
+	{"multistage", multistage},
+	{"multi-stage", multistage},
+	{"devdependencies", productionDependencies},
+	{"dev-dependencies", productionDependencies},
+	{"depcheck", productionDependencies},
+	{"monorepo", monorepo},
+	{"workspace", monorepo},
+	{"lockfile", lockfile},
+	{"distroless", distroless},
+	{"base-image", smallBaseImage},
+	{"alpine", smallBaseImage},
+	{"slim", smallBaseImage},
+	{"secret", secretMounts},
+	{"cache", layerOrder},
+	{"layer", layerOrder},
+	{"strip", runtimeFiles},
+	{"runtime", runtimeFiles},
+	{"non-root", leastPrivilege},
+}
+
+// For returns the explanation of an action taken for the given rule, nil if dockershrink doesn't know its principle
+func For(rule string) *models.Explanation {
+	p := rules[rule]
+	if p == nil {
+		normalized := strings.ToLower(strings.ReplaceAll(rule, "_", "-"))
+		for _, k := range keywords {
+			if strings.Contains(normalized, k.keyword) {
+				p = k.principle
+				break
+			}
+		}
+	}
+	if p == nil {
+		return nil
+	}
+	return &models.Explanation{
+		Principle:       p.name,
+		Rationale:       p.rationale,
+		Docs:            append([]string{}, p.docs...),
+		Links:           append([]string{}, p.links...),
+		BuildTimeImpact: p.buildTime,
+	}
+}
+
+// Annotate explains the actions that aren't explained yet.
+// The size impact of actions measured before, eg- while optimizing, is kept.
+func Annotate(actions []*models.OptimizationAction) {
+	for _, a := range actions {
+		e := For(a.Rule)
+		switch {
+		case e == nil:
+			continue
+		case a.Explanation == nil:
+			a.Explanation = e
+		case a.Explanation.Principle == "":
+			e.EstimatedSizeImpact = a.Explanation.EstimatedSizeImpact
+			a.Explanation = e
+		}
+	}
+}
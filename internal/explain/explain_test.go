@@ -0,0 +1,74 @@
+package explain
+
+import (
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/docs"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+func TestFor(t *testing.T) {
+	tests := []struct {
+		rule      string
+		principle string
+	}{
+		{rule: "create-dockerignore", principle: dockerignore.name},
+		{rule: "reorder-layers", principle: layerOrder.name},
+		{rule: "secret-mounts", principle: secretMounts.name},
+		// rules named by the LLM
+		{rule: "use-multistage-builds", principle: multistage.name},
+		{rule: "Exclude_devDependencies", principle: productionDependencies.name},
+		{rule: "cache-friendly-builds", principle: layerOrder.name},
+		{rule: "use-small-base-images", principle: smallBaseImage.name},
+		{rule: "prune-the-monorepo-workspace", principle: monorepo.name},
+		{rule: "comply-with-policies"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			e := For(tt.rule)
+			switch {
+			case tt.principle == "" && e != nil:
+				t.Errorf("expected no explanation, got %q", e.Principle)
+			case tt.principle != "" && (e == nil || e.Principle != tt.principle):
+				t.Errorf("expected the principle %q, got %+v", tt.principle, e)
+			}
+		})
+	}
+}
+
+func TestPrinciples(t *testing.T) {
+	for rule, p := range rules {
+		if p.name == "" || p.rationale == "" {
+			t.Errorf("the principle of %s has no name or rationale", rule)
+		}
+		for _, title := range p.docs {
+			if docs.Lookup(title) == nil {
+				t.Errorf("the principle of %s refers to %q, which isn't part of the documentation", rule, title)
+			}
+		}
+	}
+	for _, k := range keywords {
+		for _, title := range k.principle.docs {
+			if docs.Lookup(title) == nil {
+				t.Errorf("the principle of %s refers to %q, which isn't part of the documentation", k.keyword, title)
+			}
+		}
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	measured := &models.OptimizationAction{Rule: "reorder-layers", Explanation: &models.Explanation{EstimatedSizeImpact: -2048}}
+	explained := &models.OptimizationAction{Rule: "reorder-layers", Explanation: &models.Explanation{Principle: "custom"}}
+	unknown := &models.OptimizationAction{Rule: "comply-with-policies"}
+	Annotate([]*models.OptimizationAction{measured, explained, unknown})
+
+	if measured.Explanation.Principle != layerOrder.name || measured.Explanation.EstimatedSizeImpact != -2048 {
+		t.Errorf("expected the measured size impact to be kept, got %+v", measured.Explanation)
+	}
+	if explained.Explanation.Principle != "custom" {
+		t.Errorf("expected existing explanations to be kept, got %+v", explained.Explanation)
+	}
+	if unknown.Explanation != nil {
+		t.Errorf("expected no explanation for an unknown rule, got %+v", unknown.Explanation)
+	}
+}
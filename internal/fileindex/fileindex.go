@@ -0,0 +1,354 @@
+// Package fileindex keeps an index of a project's files and their content hashes
+// inside the project's .dockershrink directory. Commands that run repeatedly over the
+// same project update the index instead of reading the whole tree again: directories
+// whose modification time hasn't changed aren't listed again, and files whose size
+// and modification time haven't changed aren't hashed again.
+package fileindex
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/history"
+)
+
+// version of the index format, indexes of other versions are discarded
+const version = 1
+
+// Filename is the name of the index file inside the project's .dockershrink directory
+const Filename = "index.jsonl"
+
+// size of the buffer used to stream file contents into the hash
+const hashBufferSize = 64 * 1024
+
+var hashBuffers = sync.Pool{
+	New: func() any {
+		b := make([]byte, hashBufferSize)
+		return &b
+	},
+}
+
+// Entry is a file or directory in the index
+type Entry struct {
+	// Path is slash-separated and relative to the root of the project, "." for the root itself
+	Path string `json:"p"`
+	Dir  bool   `json:"d,omitempty"`
+	// Size is the total size of all regular files inside a directory
+	Size int64 `json:"s"`
+	// ModTime is in nanoseconds since the unix epoch
+	ModTime int64 `json:"m"`
+	// Hash is the hex-encoded sha256 of a file's content, empty if it hasn't been computed yet
+	Hash string `json:"h,omitempty"`
+}
+
+type header struct {
+	Version   int   `json:"version"`
+	ScannedAt int64 `json:"scanned_at"`
+}
+
+// Index contains the metadata of every file and directory inside a project
+type Index struct {
+	root string
+	file string
+
+	mu      sync.Mutex
+	entries map[string]*Entry
+	// children maps directories to the sorted names of their entries
+	children  map[string][]string
+	scannedAt int64
+}
+
+// Open loads the index of the project at root.
+// An empty index is returned if the project hasn't been indexed yet or its index can't be read.
+// Call Update to bring the index in sync with the project's files.
+func Open(root string) *Index {
+	x := &Index{
+		root:     root,
+		file:     filepath.Join(root, history.Dir, Filename),
+		entries:  map[string]*Entry{},
+		children: map[string][]string{},
+	}
+	if err := x.load(); err != nil {
+		// the index is only a cache, start over
+		x.entries, x.children, x.scannedAt = map[string]*Entry{}, map[string][]string{}, 0
+	}
+	return x
+}
+
+func (x *Index) load() error {
+	f, err := os.Open(x.file)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(f))
+	var h header
+	if err := dec.Decode(&h); err != nil {
+		return err
+	}
+	if h.Version != version {
+		return fmt.Errorf("unsupported index version %d", h.Version)
+	}
+	x.scannedAt = h.ScannedAt
+
+	for {
+		e := &Entry{}
+		if err := dec.Decode(e); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		x.entries[e.Path] = e
+		if e.Path != "." {
+			parent := path.Dir(e.Path)
+			x.children[parent] = append(x.children[parent], path.Base(e.Path))
+		}
+	}
+	for dir := range x.children {
+		sort.Strings(x.children[dir])
+	}
+	return nil
+}
+
+// Save persists the index inside the project's .dockershrink directory.
+// Entries are written one per line so that neither writing nor loading the index
+// requires the whole file in memory.
+func (x *Index) Save() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(x.file), 0o755); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(x.file), Filename+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	err = enc.Encode(header{Version: version, ScannedAt: x.scannedAt})
+	if err == nil {
+		err = x.walk(".", func(e *Entry) error { return enc.Encode(e) })
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	// replace the index atomically so that a concurrent run never reads a partial file
+	if err := os.Rename(tmp.Name(), x.file); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return nil
+}
+
+// Update scans the project for changes and returns the paths of files that were added,
+// modified or removed since the last scan, in alphabetical order.
+// Hashes of changed files are discarded and computed again on demand.
+func (x *Index) Update() ([]string, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	s := &scan{
+		old:      x.entries,
+		children: x.children,
+		// files modified in the same instant as the last scan could have been modified
+		// again afterwards without a change in their modification time
+		racyAfter: x.scannedAt - int64(time.Second),
+		entries:   map[string]*Entry{},
+		next:      map[string][]string{},
+	}
+	startedAt := time.Now().UnixNano()
+	if _, err := s.dir(x.root, "."); err != nil {
+		return nil, err
+	}
+	for p, e := range x.entries {
+		if _, ok := s.entries[p]; !ok && !e.Dir {
+			s.changed = append(s.changed, p)
+		}
+	}
+
+	x.entries, x.children, x.scannedAt = s.entries, s.next, startedAt
+	sort.Strings(s.changed)
+	return s.changed, nil
+}
+
+// scan holds the state of a single Update
+type scan struct {
+	old       map[string]*Entry
+	children  map[string][]string
+	racyAfter int64
+
+	entries map[string]*Entry
+	next    map[string][]string
+	changed []string
+}
+
+// dir indexes a directory recursively and returns its entry
+func (s *scan) dir(abs, rel string) (*Entry, error) {
+	info, err := os.Lstat(abs)
+	if err != nil {
+		return nil, err
+	}
+	entry := &Entry{Path: rel, Dir: true, ModTime: info.ModTime().UnixNano()}
+
+	// the entries of a directory only change if its modification time does
+	names, listed := s.children[rel]
+	if old := s.old[rel]; old == nil || !old.Dir || old.ModTime != entry.ModTime || !listed {
+		dirEntries, err := os.ReadDir(abs)
+		if err != nil && !errors.Is(err, fs.ErrPermission) {
+			return nil, err
+		}
+		names = make([]string, 0, len(dirEntries))
+		for _, d := range dirEntries {
+			names = append(names, d.Name())
+		}
+	}
+
+	var indexed []string
+	for _, name := range names {
+		if rel == "." && name == history.Dir {
+			continue
+		}
+		childAbs, childRel := filepath.Join(abs, name), path.Join(rel, name)
+		info, err := os.Lstat(childAbs)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case info.IsDir():
+			child, err := s.dir(childAbs, childRel)
+			if err != nil {
+				return nil, err
+			}
+			entry.Size += child.Size
+		case info.Mode().IsRegular():
+			entry.Size += s.file(childRel, info).Size
+		default:
+			// symlinks, sockets, etc don't take up space in the build context
+			continue
+		}
+		indexed = append(indexed, name)
+	}
+
+	s.entries[rel] = entry
+	s.next[rel] = indexed
+	return entry, nil
+}
+
+func (s *scan) file(rel string, info fs.FileInfo) *Entry {
+	entry := &Entry{Path: rel, Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+	old := s.old[rel]
+	switch {
+	case old == nil || old.Dir || old.Size != entry.Size || old.ModTime != entry.ModTime:
+		s.changed = append(s.changed, rel)
+	case entry.ModTime < s.racyAfter:
+		entry.Hash = old.Hash
+	}
+	s.entries[rel] = entry
+	return entry
+}
+
+// Stat returns the entry at the given path, nil if it doesn't exist
+func (x *Index) Stat(p string) *Entry {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.entries[clean(p)]
+}
+
+// Size returns the size of a file or the total size of all files inside a directory.
+// 0 is returned if the path doesn't exist.
+func (x *Index) Size(p string) int64 {
+	if e := x.Stat(p); e != nil {
+		return e.Size
+	}
+	return 0
+}
+
+// Hash returns the sha256 of a file's content, computing it if it isn't in the index yet.
+// The file is streamed through a fixed-size buffer so that hashing large files doesn't need much memory.
+func (x *Index) Hash(p string) (string, error) {
+	p = clean(p)
+	x.mu.Lock()
+	e := x.entries[p]
+	x.mu.Unlock()
+
+	if e == nil || e.Dir {
+		return "", &fs.PathError{Op: "hash", Path: p, Err: fs.ErrNotExist}
+	}
+	if e.Hash != "" {
+		return e.Hash, nil
+	}
+
+	f, err := os.Open(filepath.Join(x.root, filepath.FromSlash(p)))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	buf := hashBuffers.Get().(*[]byte)
+	defer hashBuffers.Put(buf)
+	h := sha256.New()
+	if _, err := io.CopyBuffer(h, f, *buf); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", p, err)
+	}
+
+	x.mu.Lock()
+	e.Hash = hex.EncodeToString(h.Sum(nil))
+	x.mu.Unlock()
+	return e.Hash, nil
+}
+
+// Walk calls fn for the entry at dir and every entry below it, in lexical order.
+// fn must not call other methods of the index.
+func (x *Index) Walk(dir string, fn func(e *Entry) error) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.walk(clean(dir), fn)
+}
+
+func (x *Index) walk(p string, fn func(e *Entry) error) error {
+	e := x.entries[p]
+	if e == nil {
+		return nil
+	}
+	if err := fn(e); err != nil {
+		return err
+	}
+	for _, name := range x.children[p] {
+		if err := x.walk(path.Join(p, name), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func clean(p string) string {
+	return path.Clean(strings.TrimPrefix(filepath.ToSlash(p), "/"))
+}
@@ -0,0 +1,113 @@
+package fileindex
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, root, name, content string, modTime time.Time) {
+	t.Helper()
+	p := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(p, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	root := t.TempDir()
+	old := time.Now().Add(-time.Hour)
+	writeFile(t, root, "package.json", `{"name":"app"}`, old)
+	writeFile(t, root, "src/index.js", "console.log(1)", old)
+	writeFile(t, root, "node_modules/a/index.js", "module.exports = 1", old)
+	writeFile(t, root, ".dockershrink/history/run.json", "{}", old)
+
+	x := Open(root)
+	changed, err := x.Update()
+	if err != nil {
+		t.Fatalf("Update() returned error: %v", err)
+	}
+	expected := []string{"node_modules/a/index.js", "package.json", "src/index.js"}
+	if !reflect.DeepEqual(changed, expected) {
+		t.Errorf("expected every file to be changed on the first scan, got %v", changed)
+	}
+	if size := x.Size("node_modules"); size != 18 {
+		t.Errorf("expected node_modules to be 18 bytes, got %d", size)
+	}
+	if size := x.Size("."); size != 46 {
+		t.Errorf("expected the project to be 46 bytes without .dockershrink, got %d", size)
+	}
+	hash, err := x.Hash("src/index.js")
+	if err != nil {
+		t.Fatalf("Hash() returned error: %v", err)
+	}
+	sum := sha256.Sum256([]byte("console.log(1)"))
+	if hash != hex.EncodeToString(sum[:]) {
+		t.Errorf("unexpected hash %s", hash)
+	}
+	if _, err := x.Hash("src"); err == nil {
+		t.Error("expected an error when hashing a directory")
+	}
+	if err := x.Save(); err != nil {
+		t.Fatalf("Save() returned error: %v", err)
+	}
+
+	// a reloaded index only reports the files that changed since
+	writeFile(t, root, "src/index.js", "console.log(2)", time.Now())
+	writeFile(t, root, "src/util.js", "", old)
+	if err := os.RemoveAll(filepath.Join(root, "node_modules")); err != nil {
+		t.Fatal(err)
+	}
+
+	x = Open(root)
+	if e := x.Stat("package.json"); e == nil || e.Size != 14 {
+		t.Fatalf("expected the saved index to be loaded, got %+v", e)
+	}
+	changed, err = x.Update()
+	if err != nil {
+		t.Fatalf("Update() returned error: %v", err)
+	}
+	expected = []string{"node_modules/a/index.js", "src/index.js", "src/util.js"}
+	if !reflect.DeepEqual(changed, expected) {
+		t.Errorf("expected %v to be changed, got %v", expected, changed)
+	}
+	if e := x.Stat("src/index.js"); e.Hash != "" {
+		t.Error("expected the hash of a modified file to be discarded")
+	}
+	if x.Stat("node_modules") != nil {
+		t.Error("expected removed directories to be dropped from the index")
+	}
+
+	var walked []string
+	_ = x.Walk("src", func(e *Entry) error {
+		walked = append(walked, e.Path)
+		return nil
+	})
+	if expected := []string{"src", "src/index.js", "src/util.js"}; !reflect.DeepEqual(walked, expected) {
+		t.Errorf("expected walk to visit %v, got %v", expected, walked)
+	}
+}
+
+func TestOpen_CorruptIndex(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, ".dockershrink/"+Filename, "not json", time.Now())
+	writeFile(t, root, "a.txt", "a", time.Now())
+
+	x := Open(root)
+	if _, err := x.Update(); err != nil {
+		t.Fatalf("Update() returned error: %v", err)
+	}
+	if x.Size("a.txt") != 1 {
+		t.Error("expected a corrupt index to be rebuilt")
+	}
+}
@@ -0,0 +1,140 @@
+// Package githook runs dockershrink from git hooks: it installs the hook, and reads the changes staged for a commit
+// so that only the Dockerfiles being committed are checked.
+package githook
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// PreCommit is the name of the hook run before every commit
+const PreCommit = "pre-commit"
+
+// marker identifies the hooks installed by dockershrink, so that hooks written by others are never overwritten
+const marker = "# installed by dockershrink"
+
+// preCommitScript lints the staged Dockerfiles. It lets the commit through if dockershrink isn't installed,
+// so that contributors without it aren't blocked.
+const preCommitScript = `#!/bin/sh
+` + marker + `: lints the Dockerfiles and .dockerignore files staged for the commit.
+# Skip it with: git commit --no-verify
+if ! command -v dockershrink >/dev/null 2>&1; then
+  echo "dockershrink is not installed, skipping the Dockerfile checks" >&2
+  exit 0
+fi
+exec dockershrink lint --staged%s
+`
+
+// ErrForeignHook is returned when installing over a hook that wasn't installed by dockershrink
+var ErrForeignHook = errors.New("a pre-commit hook that wasn't installed by dockershrink already exists")
+
+// git runs git in dir and returns its output
+func git(dir string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return stdout.Bytes(), nil
+}
+
+// StagedFiles returns the files under dir that are added, copied, modified or renamed in the index, relative to dir.
+// Deleted files are left out, since there's nothing left to check.
+func StagedFiles(dir string) ([]string, error) {
+	out, err := git(dir, "diff", "--cached", "--name-only", "--diff-filter=ACMR", "--relative", "-z")
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, f := range strings.Split(string(out), "\x00") {
+		if f != "" {
+			files = append(files, filepath.FromSlash(f))
+		}
+	}
+	return files, nil
+}
+
+// StagedContent returns the content of the file as staged in the index, which may differ from the working tree
+// when only some of its changes were staged. The path is relative to dir.
+func StagedContent(dir, path string) ([]byte, error) {
+	return git(dir, "show", ":./"+filepath.ToSlash(path))
+}
+
+// hookPath returns the path of the hook in the repository of dir, honoring core.hooksPath and worktrees
+func hookPath(dir, name string) (string, error) {
+	out, err := git(dir, "rev-parse", "--git-path", "hooks/"+name)
+	if err != nil {
+		return "", err
+	}
+	p := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dir, p)
+	}
+	return p, nil
+}
+
+// Install installs the pre-commit hook in the repository of dir and returns its path.
+// The hook runs lint with the given extra arguments, eg- --fail-on high.
+// A hook that wasn't installed by dockershrink is only replaced if force is set.
+func Install(dir string, args []string, force bool) (string, error) {
+	path, err := hookPath(dir, PreCommit)
+	if err != nil {
+		return "", err
+	}
+	if existing, err := os.ReadFile(path); err == nil && !bytes.Contains(existing, []byte(marker)) && !force {
+		return "", fmt.Errorf("%w: %s", ErrForeignHook, path)
+	}
+
+	extra := ""
+	for _, a := range args {
+		extra += " " + shellQuote(a)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(preCommitScript, extra)), 0o755); err != nil {
+		return "", err
+	}
+	// the file may have existed with other permissions
+	return path, os.Chmod(path, 0o755)
+}
+
+// Uninstall removes the pre-commit hook from the repository of dir, if it was installed by dockershrink.
+// It returns the path of the removed hook, empty if there was nothing to remove.
+func Uninstall(dir string) (string, error) {
+	path, err := hookPath(dir, PreCommit)
+	if err != nil {
+		return "", err
+	}
+	existing, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if !bytes.Contains(existing, []byte(marker)) {
+		return "", fmt.Errorf("%w: %s", ErrForeignHook, path)
+	}
+	return path, os.Remove(path)
+}
+
+// shellQuote quotes s for a POSIX shell, unless it only has characters that are safe unquoted
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=./,:") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
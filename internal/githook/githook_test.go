@@ -0,0 +1,147 @@
+package githook
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newRepo returns a new git repository with a commit of the given files
+func newRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	run("init", "-q")
+	run("config", "user.email", "dev@example.com")
+	run("config", "user.name", "dev")
+	for name, content := range files {
+		writeFile(t, filepath.Join(dir, name), content)
+	}
+	run("add", "-A")
+	run("commit", "-q", "-m", "initial")
+	return dir
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStagedFiles(t *testing.T) {
+	dir := newRepo(t, map[string]string{
+		"Dockerfile":              "FROM node:20\n",
+		"services/api/Dockerfile": "FROM node:20\n",
+		"old.Dockerfile":          "FROM node:20\n",
+	})
+	writeFile(t, filepath.Join(dir, "services/api/Dockerfile"), "FROM node:22\n")
+	writeFile(t, filepath.Join(dir, "services/api/.dockerignore"), "node_modules\n")
+	// changed but not staged
+	writeFile(t, filepath.Join(dir, "Dockerfile"), "FROM node:22\n")
+	cmd := exec.Command("git", "add", "services")
+	cmd.Dir = dir
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	cmd = exec.Command("git", "rm", "-q", "old.Dockerfile")
+	cmd.Dir = dir
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	// the staged content differs from the working tree
+	writeFile(t, filepath.Join(dir, "services/api/Dockerfile"), "FROM node:24\n")
+
+	files, err := StagedFiles(dir)
+	if err != nil {
+		t.Fatalf("StagedFiles() failed: %v", err)
+	}
+	expected := []string{filepath.FromSlash("services/api/.dockerignore"), filepath.FromSlash("services/api/Dockerfile")}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected %v, got %v", expected, files)
+	}
+
+	// paths are relative to the directory
+	files, err = StagedFiles(filepath.Join(dir, "services"))
+	if err != nil || len(files) != 2 || files[1] != filepath.FromSlash("api/Dockerfile") {
+		t.Errorf("expected paths relative to the directory, got %v (%v)", files, err)
+	}
+
+	content, err := StagedContent(filepath.Join(dir, "services"), filepath.FromSlash("api/Dockerfile"))
+	if err != nil || string(content) != "FROM node:22\n" {
+		t.Errorf("expected the staged content, got %q (%v)", content, err)
+	}
+}
+
+func TestInstall(t *testing.T) {
+	dir := newRepo(t, map[string]string{"Dockerfile": "FROM node:20\n"})
+
+	path, err := Install(dir, []string{"--fail-on", "high", "--goal=size"}, false)
+	if err != nil {
+		t.Fatalf("Install() failed: %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "exec dockershrink lint --staged --fail-on high --goal=size\n") {
+		t.Errorf("unexpected hook:\n%s", content)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm()&0o100 == 0 {
+		t.Errorf("expected the hook to be executable")
+	}
+	// installing again replaces the hook
+	if _, err := Install(dir, nil, false); err != nil {
+		t.Errorf("expected the hook to be reinstalled, got %v", err)
+	}
+
+	if removed, err := Uninstall(dir); err != nil || removed != path {
+		t.Fatalf("Uninstall() = %q, %v", removed, err)
+	}
+	if removed, err := Uninstall(dir); err != nil || removed != "" {
+		t.Errorf("expected nothing to uninstall, got %q, %v", removed, err)
+	}
+
+	writeFile(t, path, "#!/bin/sh\nnpm test\n")
+	if _, err := Install(dir, nil, false); !errors.Is(err, ErrForeignHook) {
+		t.Errorf("expected another hook not to be overwritten, got %v", err)
+	}
+	if _, err := Uninstall(dir); !errors.Is(err, ErrForeignHook) {
+		t.Errorf("expected another hook not to be removed, got %v", err)
+	}
+	if _, err := Install(dir, nil, true); err != nil {
+		t.Errorf("expected --force to overwrite another hook, got %v", err)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"--fail-on":       "--fail-on",
+		"a b":             "'a b'",
+		"it's":            `'it'\''s'`,
+		"":                "''",
+		"--platforms=a,b": "--platforms=a,b",
+	}
+	for in, expected := range tests {
+		if got := shellQuote(in); got != expected {
+			t.Errorf("shellQuote(%q) = %q; want %q", in, got, expected)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+// Dir is the directory inside a project where dockershrink keeps its state
+const Dir = ".dockershrink"
+
+// LatestRunID can be passed to Store.Get to fetch the most recent run
+const LatestRunID = "latest"
+
+// Run is the record of a single dockershrink run over a project
+type Run struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Command   string    `json:"command"`
+	// Commit is the git commit the project was at, eg- "3f2c1a9", empty outside of a git repository
+	Commit string `json:"commit,omitempty"`
+
+	DockerfilePath     string `json:"dockerfile_path"`
+	InputDockerfile    string `json:"input_dockerfile"`
+	OutputDockerfile   string `json:"output_dockerfile"`
+	InputDockerignore  string `json:"input_dockerignore"`
+	OutputDockerignore string `json:"output_dockerignore"`
+	// InputHash is the sha256 of the input Dockerfile and .dockerignore, runs over the same files have the same hash
+	InputHash string `json:"input_hash"`
+
+	// EstimatedSizeBefore and EstimatedSizeAfter are the estimated sizes of the image in bytes before and after the run
+	EstimatedSizeBefore int64 `json:"estimated_size_before,omitempty"`
+	EstimatedSizeAfter  int64 `json:"estimated_size_after,omitempty"`
+	// VerifiedSizeBefore and VerifiedSizeAfter are the sizes of the images built by --verify-build, 0 if they weren't built
+	VerifiedSizeBefore int64 `json:"verified_size_before,omitempty"`
+	VerifiedSizeAfter  int64 `json:"verified_size_after,omitempty"`
+	// ScoreBefore and ScoreAfter grade the Dockerfile before and after the run, like analyze.
+	// They're nil for runs recorded before scores were kept.
+	ScoreBefore *int `json:"score_before,omitempty"`
+	ScoreAfter  *int `json:"score_after,omitempty"`
+
+	ActionsTaken    []*models.OptimizationAction `json:"actions_taken"`
+	Recommendations []*models.OptimizationAction `json:"recommendations"`
+}
+
+// Store persists runs as JSON files inside the project's .dockershrink/history directory
+type Store struct {
+	dir string
+}
+
+func NewStore(projectDir string) *Store {
+	return &Store{dir: filepath.Join(projectDir, Dir, "history")}
+}
+
+// NewRunID returns a sortable, human-readable ID for a run started at the given time
+func NewRunID(t time.Time) string {
+	return t.UTC().Format("20060102T150405.000Z")
+}
+
+// Save persists the given run. The run's ID and timestamp are set if they're empty.
+func (s *Store) Save(run *Run) error {
+	if run.Timestamp.IsZero() {
+		run.Timestamp = time.Now()
+	}
+	if run.ID == "" {
+		run.ID = NewRunID(run.Timestamp)
+	}
+	if run.InputHash == "" {
+		run.InputHash = InputHash(run.InputDockerfile, run.InputDockerignore)
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	content, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize run: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, run.ID+".json"), content, 0o644); err != nil {
+		return fmt.Errorf("failed to write run %s: %w", run.ID, err)
+	}
+	return nil
+}
+
+// InputHash returns the sha256 of a Dockerfile and its .dockerignore
+func InputHash(dockerfile, dockerignore string) string {
+	h := sha256.New()
+	// the length keeps content moving from one file to the other from producing the same hash
+	fmt.Fprintf(h, "%d\n%s%s", len(dockerfile), dockerfile, dockerignore)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Savings returns the bytes the run saved, measured by --verify-build if the images were built and estimated otherwise.
+// verified is false for estimates. 0 is returned if the run didn't estimate the size of the image.
+func (r *Run) Savings() (savings int64, verified bool) {
+	if r.VerifiedSizeBefore > 0 && r.VerifiedSizeAfter > 0 {
+		return r.VerifiedSizeBefore - r.VerifiedSizeAfter, true
+	}
+	if r.EstimatedSizeBefore > 0 && r.EstimatedSizeAfter > 0 {
+		return r.EstimatedSizeBefore - r.EstimatedSizeAfter, false
+	}
+	return 0, false
+}
+
+// ImageSize returns the size of the image built from the input Dockerfile, measured by --verify-build if the images
+// were built and estimated otherwise. 0 is returned if it wasn't estimated.
+func (r *Run) ImageSize() (size int64, verified bool) {
+	if r.VerifiedSizeBefore > 0 {
+		return r.VerifiedSizeBefore, true
+	}
+	return r.EstimatedSizeBefore, false
+}
+
+// Get returns the run with the given ID.
+// The ID can be "latest" or a unique prefix of a run ID.
+func (s *Store) Get(id string) (*Run, error) {
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no runs recorded yet: %w", fs.ErrNotExist)
+	}
+	if id == LatestRunID {
+		return s.read(ids[len(ids)-1])
+	}
+
+	matches := []string{}
+	for _, candidate := range ids {
+		if candidate == id {
+			return s.read(candidate)
+		}
+		if strings.HasPrefix(candidate, id) {
+			matches = append(matches, candidate)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("run %s not found: %w", id, fs.ErrNotExist)
+	case 1:
+		return s.read(matches[0])
+	default:
+		return nil, fmt.Errorf("run ID %s is ambiguous, it matches %d runs", id, len(matches))
+	}
+}
+
+// List returns all the recorded runs, oldest first
+func (s *Store) List() ([]*Run, error) {
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]*Run, 0, len(ids))
+	for _, id := range ids {
+		run, err := s.read(id)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// ids returns the IDs of all recorded runs in chronological order
+func (s *Store) ids() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read history directory: %w", err)
+	}
+	ids := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			ids = append(ids, strings.TrimSuffix(e.Name(), ".json"))
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (s *Store) read(id string) (*Run, error) {
+	content, err := os.ReadFile(filepath.Join(s.dir, id+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run %s: %w", id, err)
+	}
+	run := &Run{}
+	if err := json.Unmarshal(content, run); err != nil {
+		return nil, fmt.Errorf("failed to parse run %s: %w", id, err)
+	}
+	return run, nil
+}
+
+// Regressions returns the findings in current whose rule did not fire on a previous run's output.
+// Such findings indicate an optimization that was applied earlier and has since been undone.
+func Regressions(previous, current []*models.Finding) []*models.Finding {
+	fired := map[string]struct{}{}
+	for _, f := range previous {
+		fired[f.Rule] = struct{}{}
+	}
+	regressions := []*models.Finding{}
+	for _, f := range current {
+		if _, ok := fired[f.Rule]; !ok {
+			regressions = append(regressions, f)
+		}
+	}
+	return regressions
+}
@@ -0,0 +1,112 @@
+package history
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+func TestStore(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	if _, err := store.Get(LatestRunID); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist for an empty store, got %v", err)
+	}
+
+	first := &Run{Timestamp: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), OutputDockerfile: "FROM node:20-alpine"}
+	second := &Run{Timestamp: time.Date(2025, 2, 2, 3, 4, 5, 0, time.UTC), OutputDockerfile: "FROM node:22-alpine"}
+	for _, r := range []*Run{first, second} {
+		if err := store.Save(r); err != nil {
+			t.Fatalf("failed to save run: %v", err)
+		}
+	}
+	if first.ID != "20250102T030405.000Z" {
+		t.Errorf("unexpected run ID %q", first.ID)
+	}
+
+	latest, err := store.Get(LatestRunID)
+	if err != nil {
+		t.Fatalf("failed to get latest run: %v", err)
+	}
+	if latest.ID != second.ID {
+		t.Errorf("expected latest run %s, got %s", second.ID, latest.ID)
+	}
+
+	byPrefix, err := store.Get("202501")
+	if err != nil {
+		t.Fatalf("failed to get run by prefix: %v", err)
+	}
+	if byPrefix.OutputDockerfile != first.OutputDockerfile {
+		t.Errorf("expected the first run, got %s", byPrefix.ID)
+	}
+
+	if _, err := store.Get("2025"); err == nil {
+		t.Error("expected an error for an ambiguous run ID")
+	}
+
+	runs, err := store.List()
+	if err != nil {
+		t.Fatalf("failed to list runs: %v", err)
+	}
+	if len(runs) != 2 || runs[0].ID != first.ID {
+		t.Errorf("expected 2 runs in chronological order, got %d", len(runs))
+	}
+}
+
+func TestRegressions(t *testing.T) {
+	previous := []*models.Finding{{Rule: "apt-get-bloat"}}
+	current := []*models.Finding{{Rule: "apt-get-bloat"}, {Rule: "heavy-final-base-image"}}
+
+	got := Regressions(previous, current)
+	if len(got) != 1 || got[0].Rule != "heavy-final-base-image" {
+		t.Errorf("Regressions() = %v; want [heavy-final-base-image]", got)
+	}
+	if got := Regressions(current, previous); len(got) != 0 {
+		t.Errorf("Regressions() = %v; want none", got)
+	}
+}
+
+func TestStore_InputHash(t *testing.T) {
+	store := NewStore(t.TempDir())
+	runs := []*Run{
+		{InputDockerfile: "FROM node:20\n", InputDockerignore: "node_modules\n"},
+		{InputDockerfile: "FROM node:20\n", InputDockerignore: "node_modules\n", Timestamp: time.Now().Add(time.Second)},
+		{InputDockerfile: "FROM node:20\nnode_modules\n", Timestamp: time.Now().Add(2 * time.Second)},
+	}
+	for _, r := range runs {
+		if err := store.Save(r); err != nil {
+			t.Fatalf("failed to save run: %v", err)
+		}
+	}
+	if runs[0].InputHash == "" || runs[0].InputHash != runs[1].InputHash {
+		t.Errorf("expected runs over the same files to have the same hash, got %q and %q", runs[0].InputHash, runs[1].InputHash)
+	}
+	if runs[0].InputHash == runs[2].InputHash {
+		t.Error("expected content moving from the .dockerignore to the Dockerfile to change the hash")
+	}
+}
+
+func TestRun_Savings(t *testing.T) {
+	tests := []struct {
+		name     string
+		run      *Run
+		savings  int64
+		verified bool
+	}{
+		{name: "no sizes", run: &Run{}},
+		{name: "estimated", run: &Run{EstimatedSizeBefore: 300, EstimatedSizeAfter: 100}, savings: 200},
+		{name: "built", run: &Run{EstimatedSizeBefore: 300, EstimatedSizeAfter: 100, VerifiedSizeBefore: 500, VerifiedSizeAfter: 450}, savings: 50, verified: true},
+		{name: "original failed to build", run: &Run{EstimatedSizeBefore: 300, EstimatedSizeAfter: 100, VerifiedSizeAfter: 450}, savings: 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			savings, verified := tt.run.Savings()
+			if savings != tt.savings || verified != tt.verified {
+				t.Errorf("Savings() = %d, %v; want %d, %v", savings, verified, tt.savings, tt.verified)
+			}
+		})
+	}
+}
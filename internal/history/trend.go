@@ -0,0 +1,47 @@
+package history
+
+import "time"
+
+// Point is the state of the image at a git commit, taken from the last run at that commit
+type Point struct {
+	// Commit is empty for runs outside of a git repository, each of them is a point of its own
+	Commit    string    `json:"commit,omitempty"`
+	RunID     string    `json:"run_id"`
+	Timestamp time.Time `json:"timestamp"`
+	// Size is the size of the image in bytes, 0 if it wasn't estimated
+	Size     int64 `json:"size"`
+	Verified bool  `json:"verified"`
+	Score    *int  `json:"score,omitempty"`
+}
+
+// Trend returns the image as it was at every commit the runs were made at, oldest first.
+// The runs must be in chronological order, like List returns them. The Dockerfile as committed is
+// what's measured, not the one optimized by the run.
+func Trend(runs []*Run) []*Point {
+	points := []*Point{}
+	for _, r := range runs {
+		size, verified := r.ImageSize()
+		p := &Point{Commit: r.Commit, RunID: r.ID, Timestamp: r.Timestamp, Size: size, Verified: verified, Score: r.ScoreBefore}
+		// a later run at the same commit replaces the earlier one, eg- after verifying the images
+		if n := len(points); n > 0 && p.Commit != "" && points[n-1].Commit == p.Commit {
+			points[n-1] = p
+			continue
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+// Growth returns by how many percent the image grew from the previous run to the current one, negative if it shrank.
+// Sizes measured by --verify-build are only compared with each other, estimates are compared otherwise.
+// ok is false if the sizes can't be compared, eg- because one of them wasn't estimated.
+func Growth(previous, current *Run) (percent float64, ok bool) {
+	before, after := previous.EstimatedSizeBefore, current.EstimatedSizeBefore
+	if previous.VerifiedSizeBefore > 0 && current.VerifiedSizeBefore > 0 {
+		before, after = previous.VerifiedSizeBefore, current.VerifiedSizeBefore
+	}
+	if before <= 0 || after <= 0 {
+		return 0, false
+	}
+	return float64(after-before) * 100 / float64(before), true
+}
@@ -0,0 +1,55 @@
+package history
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrend(t *testing.T) {
+	start := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	score := 60
+	runs := []*Run{
+		{ID: "1", Commit: "aaa", Timestamp: start, EstimatedSizeBefore: 300},
+		{ID: "2", Commit: "aaa", Timestamp: start.Add(time.Minute), EstimatedSizeBefore: 300, VerifiedSizeBefore: 320, ScoreBefore: &score},
+		{ID: "3", Commit: "bbb", Timestamp: start.Add(time.Hour), EstimatedSizeBefore: 200},
+		{ID: "4", Timestamp: start.Add(2 * time.Hour), EstimatedSizeBefore: 210},
+		{ID: "5", Timestamp: start.Add(3 * time.Hour)},
+	}
+	points := Trend(runs)
+	if len(points) != 4 {
+		t.Fatalf("expected a point per commit and per run outside of git, got %d", len(points))
+	}
+	if p := points[0]; p.RunID != "2" || p.Size != 320 || !p.Verified || p.Score == nil || *p.Score != 60 {
+		t.Errorf("expected the last run at the first commit with its built size, got %+v", p)
+	}
+	if p := points[1]; p.Commit != "bbb" || p.Size != 200 || p.Verified || p.Score != nil {
+		t.Errorf("unexpected point %+v", p)
+	}
+	if points[3].Size != 0 {
+		t.Errorf("expected no size for a run without an estimate, got %d", points[3].Size)
+	}
+}
+
+func TestGrowth(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous *Run
+		current  *Run
+		expected float64
+		ok       bool
+	}{
+		{name: "estimated", previous: &Run{EstimatedSizeBefore: 200}, current: &Run{EstimatedSizeBefore: 250}, expected: 25, ok: true},
+		{name: "shrank", previous: &Run{EstimatedSizeBefore: 200}, current: &Run{EstimatedSizeBefore: 100}, expected: -50, ok: true},
+		{name: "built", previous: &Run{EstimatedSizeBefore: 200, VerifiedSizeBefore: 400}, current: &Run{EstimatedSizeBefore: 200, VerifiedSizeBefore: 440}, expected: 10, ok: true},
+		{name: "only one built", previous: &Run{EstimatedSizeBefore: 200}, current: &Run{EstimatedSizeBefore: 210, VerifiedSizeBefore: 440}, expected: 5, ok: true},
+		{name: "not estimated", previous: &Run{}, current: &Run{EstimatedSizeBefore: 210}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			growth, ok := Growth(tt.previous, tt.current)
+			if growth != tt.expected || ok != tt.ok {
+				t.Errorf("Growth() = %v, %v; want %v, %v", growth, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Package imports finds the npm packages the source files of a nodejs project import. It's a lightweight scan of
+// require() calls and import statements that doesn't parse the code, so a package mentioned in a comment counts
+// as imported, which errs on the side of keeping it.
+package imports
+
+import (
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxFileSize is the size above which files are assumed to be generated or bundled, and skipped
+	maxFileSize = 512 * 1024
+	// maxFiles is the number of source files after which the scan stops
+	maxFiles = 10000
+)
+
+var (
+	// importRegex matches the specifier of require("x"), import("x"), import x from "x", export * from "x"
+	// and import "x"
+	importRegex = regexp.MustCompile(`(?:\brequire\s*\(\s*|\bimport\s*\(\s*|\bfrom\s*|\bimport\s+)["']([^"'\s]+)["']`)
+	// devFileRegex matches the names of test files and stories, eg- "app.test.ts" or "button.stories.jsx"
+	devFileRegex = regexp.MustCompile(`(?i)\.(test|spec|stories|story|e2e|bench)\.[cm]?[jt]sx?$`)
+	// configFileRegex matches the configuration files of test and build tools, eg- "jest.config.js" or ".eslintrc.cjs"
+	configFileRegex = regexp.MustCompile(`(?i)^\.?(jest|vitest|webpack|vite|rollup|babel|eslint|prettier|postcss|tailwind|tsup|karma|cypress|playwright|commitlint|stylelint|mocha|lint-staged)(\.[\w-]+)*\.(config|conf|setup)\.[cm]?[jt]s$|^\.(eslintrc|prettierrc|babelrc|mocharc)\.[cm]?js$|^(gulpfile|gruntfile|setuptests)\.[cm]?[jt]sx?$`)
+)
+
+var (
+	sourceExtensions = map[string]bool{
+		".js": true, ".cjs": true, ".mjs": true, ".jsx": true, ".ts": true, ".cts": true, ".mts": true, ".tsx": true,
+	}
+	// skipDirs are installed, generated or bundled code, not the project's sources
+	skipDirs = map[string]bool{
+		"node_modules": true, ".git": true, "dist": true, "build": true, "out": true, "coverage": true,
+		".next": true, ".nuxt": true, ".output": true, ".turbo": true, ".cache": true, "vendor": true,
+	}
+	// devDirs only contain tests, their fixtures and stories
+	devDirs = map[string]bool{
+		"test": true, "tests": true, "__tests__": true, "__mocks__": true, "spec": true, "e2e": true, "cypress": true,
+		"playwright": true, "fixtures": true, "__fixtures__": true, ".storybook": true, "stories": true, "benchmarks": true,
+	}
+)
+
+// Usage maps the packages imported by the project to the source files importing them, sorted by path
+type Usage map[string][]string
+
+// Scan returns the packages imported by the JavaScript and TypeScript files of the project, eg- "@aws-sdk/client-s3"
+// for require("@aws-sdk/client-s3/dist/index.js"). Relative imports and node's builtin modules are left out.
+func Scan(fsys fs.FS) (Usage, error) {
+	usage := Usage{}
+	files := 0
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != "." && skipDirs[d.Name()] {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !sourceExtensions[path.Ext(p)] || strings.HasSuffix(p, ".d.ts") {
+			return nil
+		}
+		if info, err := d.Info(); err != nil || info.Size() > maxFileSize {
+			return nil
+		}
+		if files++; files > maxFiles {
+			return fs.SkipAll
+		}
+		content, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return nil
+		}
+		seen := map[string]bool{}
+		for _, specifier := range Specifiers(content) {
+			name := PackageName(specifier)
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			usage[name] = append(usage[name], p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, files := range usage {
+		sort.Strings(files)
+	}
+	return usage, nil
+}
+
+// DevOnly returns true if the package is imported, but only by files that run while testing or building
+// the project, eg- tests and the configuration of the bundler
+func (u Usage) DevOnly(pkg string) bool {
+	files := u[pkg]
+	for _, f := range files {
+		if !IsDevFile(f) {
+			return false
+		}
+	}
+	return len(files) > 0
+}
+
+// IsDevFile returns true if the file only runs while testing or building the project, eg- "src/app.test.ts",
+// "test/setup.js" or "webpack.config.js"
+func IsDevFile(name string) bool {
+	base := path.Base(name)
+	if devFileRegex.MatchString(base) || configFileRegex.MatchString(base) {
+		return true
+	}
+	for _, dir := range strings.Split(path.Dir(name), "/") {
+		if devDirs[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// Specifiers returns the string literals the code requires or imports, eg- "./routes" and "express"
+func Specifiers(content []byte) []string {
+	specifiers := []string{}
+	for _, m := range importRegex.FindAllSubmatch(content, -1) {
+		specifiers = append(specifiers, string(m[1]))
+	}
+	return specifiers
+}
+
+// PackageName returns the name of the package an import specifier refers to, eg- "lodash" for "lodash/merge".
+// It's empty for relative and absolute paths, node's builtin modules and the project's own subpath imports.
+func PackageName(specifier string) string {
+	if strings.HasPrefix(specifier, ".") || strings.HasPrefix(specifier, "/") || strings.HasPrefix(specifier, "#") || strings.Contains(specifier, ":") {
+		return ""
+	}
+	parts := strings.SplitN(specifier, "/", 3)
+	if strings.HasPrefix(specifier, "@") {
+		if len(parts) < 2 {
+			return ""
+		}
+		return parts[0] + "/" + parts[1]
+	}
+	return parts[0]
+}
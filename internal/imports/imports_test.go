@@ -0,0 +1,72 @@
+package imports
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestScan(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.js":                      {Data: []byte("const express = require('express')\nconst { merge } = require(\"lodash/merge\")\nconst fs = require('node:fs')\nrequire('./routes')\n")},
+		"src/db.ts":                     {Data: []byte("import { Client } from 'pg'\nimport type { S3 } from '@aws-sdk/client-s3/dist-types'\nexport * from './models'\nimport '#config'\n")},
+		"src/db.test.ts":                {Data: []byte("import { describe } from 'vitest'\nimport supertest from 'supertest'\nimport { Client } from 'pg'\n")},
+		"test/setup.js":                 {Data: []byte("require('dotenv').config()\nrequire('supertest')\n")},
+		"webpack.config.js":             {Data: []byte("const TerserPlugin = require('terser-webpack-plugin')\n")},
+		"src/lazy.mjs":                  {Data: []byte("const chalk = await import('chalk')\n")},
+		"types/global.d.ts":             {Data: []byte("import 'express-serve-static-core'\n")},
+		"node_modules/pg/index.js":      {Data: []byte("require('pg-pool')\n")},
+		"dist/index.js":                 {Data: []byte("require('left-pad')\n")},
+		"README.md":                     {Data: []byte("import x from 'markdown'\n")},
+		"src/components/ui.stories.tsx": {Data: []byte("import { Meta } from '@storybook/react'\n")},
+	}
+
+	usage, err := Scan(fsys)
+	if err != nil {
+		t.Fatalf("failed to scan: %v", err)
+	}
+	expected := Usage{
+		"express":               {"index.js"},
+		"lodash":                {"index.js"},
+		"pg":                    {"src/db.test.ts", "src/db.ts"},
+		"@aws-sdk/client-s3":    {"src/db.ts"},
+		"vitest":                {"src/db.test.ts"},
+		"supertest":             {"src/db.test.ts", "test/setup.js"},
+		"dotenv":                {"test/setup.js"},
+		"terser-webpack-plugin": {"webpack.config.js"},
+		"chalk":                 {"src/lazy.mjs"},
+		"@storybook/react":      {"src/components/ui.stories.tsx"},
+	}
+	if !reflect.DeepEqual(usage, expected) {
+		t.Errorf("expected %v, got %v", expected, usage)
+	}
+
+	for pkg, devOnly := range map[string]bool{"supertest": true, "dotenv": true, "terser-webpack-plugin": true, "pg": false, "express": false, "left-pad": false} {
+		if usage.DevOnly(pkg) != devOnly {
+			t.Errorf("expected DevOnly(%s) to be %v", pkg, devOnly)
+		}
+	}
+}
+
+func TestIsDevFile(t *testing.T) {
+	tests := map[string]bool{
+		"src/app.test.ts":           true,
+		"src/app.spec.jsx":          true,
+		"__tests__/app.js":          true,
+		"packages/api/e2e/login.ts": true,
+		"jest.config.js":            true,
+		"webpack.prod.config.cjs":   true,
+		".eslintrc.js":              true,
+		"vitest.setup.ts":           true,
+		"gulpfile.js":               true,
+		"src/app.ts":                false,
+		"src/testing.js":            false,
+		"next.config.js":            false,
+		"src/config.js":             false,
+	}
+	for name, expected := range tests {
+		if got := IsDevFile(name); got != expected {
+			t.Errorf("expected IsDevFile(%q) to be %v, got %v", name, expected, got)
+		}
+	}
+}
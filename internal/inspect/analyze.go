@@ -0,0 +1,317 @@
+package inspect
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/units"
+)
+
+const (
+	// number of layers reported in Report.LargestLayers
+	largestLayersCount = 5
+	// caches and duplicates smaller than this are not reported
+	minReportedWaste = 1 << 20
+	// max number of examples listed in the description of a finding
+	maxExamples = 5
+)
+
+// Report is the outcome of inspecting a built image
+type Report struct {
+	Findings []*models.Finding
+	// LargestLayers are the biggest layers of the image, largest first
+	LargestLayers []*Layer
+}
+
+// cache is a directory where package managers and build tools leave files that aren't needed at runtime
+type cache struct {
+	name string
+	dir  string
+	fix  string
+	// anywhere matches dir anywhere in the path instead of only as its prefix
+	anywhere bool
+}
+
+var caches = []cache{
+	{"npm cache", "/root/.npm/", "Run 'npm cache clean --force' in the same RUN as 'npm install', or use a cache mount: RUN --mount=type=cache,target=/root/.npm npm ci", false},
+	{"yarn cache", "/usr/local/share/.cache/yarn/", "Run 'yarn cache clean' in the same RUN as 'yarn install', or use a cache mount", false},
+	{"yarn cache", "/root/.cache/yarn/", "Run 'yarn cache clean' in the same RUN as 'yarn install', or use a cache mount", false},
+	{"pnpm store", "/root/.local/share/pnpm/store/", "Use a cache mount for the pnpm store: RUN --mount=type=cache,target=/root/.local/share/pnpm/store pnpm install", false},
+	{"pip cache", "/root/.cache/pip/", "Use 'pip install --no-cache-dir'", false},
+	{"go build cache", "/root/.cache/go-build/", "Build in a separate stage or use a cache mount for /root/.cache/go-build", false},
+	{"apt package lists", "/var/lib/apt/lists/", "Run 'rm -rf /var/lib/apt/lists/*' in the same RUN as 'apt-get install'", false},
+	{"apt package archives", "/var/cache/apt/archives/", "Run 'apt-get clean' in the same RUN as 'apt-get install'", false},
+	{"apk cache", "/var/cache/apk/", "Use 'apk add --no-cache'", false},
+	{"yum cache", "/var/cache/yum/", "Run 'yum clean all' in the same RUN as 'yum install'", false},
+	{"dnf cache", "/var/cache/dnf/", "Run 'dnf clean all' in the same RUN as 'dnf install'", false},
+	{"build tool cache", "/node_modules/.cache/", "Delete node_modules/.cache after the build, or only copy the build output into the final stage", true},
+	{"temporary files", "/tmp/", "Delete temporary files in the same RUN that creates them", false},
+}
+
+var (
+	secretFileRegex = regexp.MustCompile(`^(\.env(\..+)?|id_(rsa|dsa|ecdsa|ed25519)|.+\.(pem|key|p12|pfx)|\.npmrc|\.netrc|\.git-credentials|\.pgpass|credentials)$`)
+	// example env files are meant to be committed and never contain real secrets
+	exampleEnvRegex = regexp.MustCompile(`^\.env\.(example|sample|template|dist|defaults)$`)
+	// keys and certificates under these directories are shipped by the OS, eg- CA certificates
+	ignoredSecretDirs = []string{"/etc/ssl/", "/etc/pki/", "/usr/share/", "/usr/lib/", "/usr/local/share/", "/usr/local/lib/"}
+
+	secretKeyRegex = regexp.MustCompile(`(?i)(secret|token|passw(or)?d|api_?key|private_?key|credential|(^|_)auth$)`)
+	// variables that only point to a secret, eg- PASSWORD_FILE=/run/secrets/db
+	secretReferenceRegex = regexp.MustCompile(`(?i)_(file|path|dir|url)$`)
+	assignmentRegex      = regexp.MustCompile(`(?:^|\s)([A-Za-z_][A-Za-z0-9_]*)=("[^"]*"|'[^']*'|\S*)`)
+)
+
+// Analyze looks for inefficiencies in the layers of an image and recommends changes
+// to the Dockerfile that built it, without needing the Dockerfile itself.
+func Analyze(img *Image) *Report {
+	fs := img.Filesystem()
+
+	r := &Report{}
+	r.Findings = append(r.Findings, checkSecretFiles(img, fs)...)
+	r.Findings = append(r.Findings, checkSecretVariables(img)...)
+	r.Findings = append(r.Findings, checkCaches(img, fs)...)
+	r.Findings = append(r.Findings, checkLayerWaste(img)...)
+	r.Findings = append(r.Findings, checkDuplicates(img)...)
+
+	r.LargestLayers = append([]*Layer{}, img.Layers...)
+	sort.SliceStable(r.LargestLayers, func(i, j int) bool {
+		return r.LargestLayers[i].Size > r.LargestLayers[j].Size
+	})
+	if len(r.LargestLayers) > largestLayersCount {
+		r.LargestLayers = r.LargestLayers[:largestLayersCount]
+	}
+	return r
+}
+
+// checkSecretFiles reports files that commonly contain credentials.
+// Files deleted in a later layer are reported too, since they can still be extracted from the layer that added them.
+func checkSecretFiles(img *Image, fs map[string]*File) []*models.Finding {
+	findings := []*models.Finding{}
+	for _, l := range img.Layers {
+		for _, f := range l.Files {
+			if f.Dir || !isSecretFile(f.Path) {
+				continue
+			}
+			description := fmt.Sprintf("%s was added by %s and may contain credentials. ", f.Path, layerName(l))
+			if fs[f.Path] == f {
+				description += "Anyone who can pull the image can read it."
+			} else {
+				description += "It is deleted or replaced by a later layer, but can still be extracted from the layer that added it."
+			}
+			description += " Exclude it with .dockerignore, and pass secrets needed during the build with RUN --mount=type=secret instead of copying them."
+			findings = append(findings, &models.Finding{
+				Rule:        "image-secret-file",
+				Severity:    models.SeverityHigh,
+				Filepath:    img.Ref,
+				Title:       fmt.Sprintf("Possible secret file %s in the image", f.Path),
+				Description: description,
+			})
+		}
+	}
+	return findings
+}
+
+func isSecretFile(p string) bool {
+	base := path.Base(p)
+	if exampleEnvRegex.MatchString(base) || !secretFileRegex.MatchString(base) {
+		return false
+	}
+	if base == "credentials" && !strings.HasSuffix(p, "/.aws/credentials") {
+		return false
+	}
+	// packages ship test fixtures like keys and certificates
+	if strings.Contains(p, "/node_modules/") {
+		return false
+	}
+	for _, dir := range ignoredSecretDirs {
+		if strings.HasPrefix(p, dir) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkSecretVariables reports environment variables and build arguments that look like they hold secrets.
+// Both are stored in the image's metadata in plain text.
+func checkSecretVariables(img *Image) []*models.Finding {
+	findings := []*models.Finding{}
+	seen := map[string]bool{}
+	report := func(key, source string) {
+		if seen[key] || !secretKeyRegex.MatchString(key) || secretReferenceRegex.MatchString(key) {
+			return
+		}
+		seen[key] = true
+		findings = append(findings, &models.Finding{
+			Rule:     "image-secret-variable",
+			Severity: models.SeverityHigh,
+			Filepath: img.Ref,
+			Title:    fmt.Sprintf("%s may contain a secret", key),
+			Description: fmt.Sprintf(
+				"The value of %s is stored in plain text in the %s, where anyone who can pull the image can read it with 'docker history' or 'docker inspect'. "+
+					"Pass it with RUN --mount=type=secret during the build, or set it at runtime instead.", key, source,
+			),
+		})
+	}
+
+	for _, env := range img.Env {
+		if key, value, ok := strings.Cut(env, "="); ok && value != "" {
+			report(key, "environment of the image")
+		}
+	}
+	for _, h := range img.History {
+		for _, m := range assignmentRegex.FindAllStringSubmatch(h.CreatedBy, -1) {
+			if value := strings.Trim(m[2], `"'`); value != "" {
+				report(m[1], "history of the image")
+			}
+		}
+	}
+	return findings
+}
+
+// checkCaches reports package manager caches and temporary files left in the final filesystem
+func checkCaches(img *Image, fs map[string]*File) []*models.Finding {
+	sizes := make([]int64, len(caches))
+	layers := make([]map[int]bool, len(caches))
+	for p, f := range fs {
+		for i, c := range caches {
+			if strings.HasPrefix(p, c.dir) || (c.anywhere && strings.Contains(p, c.dir)) {
+				sizes[i] += f.Size
+				if layers[i] == nil {
+					layers[i] = map[int]bool{}
+				}
+				layers[i][f.Layer] = true
+				break
+			}
+		}
+	}
+
+	findings := []*models.Finding{}
+	for i, c := range caches {
+		if sizes[i] < minReportedWaste {
+			continue
+		}
+		names := []string{}
+		for _, l := range img.Layers {
+			if layers[i][l.Index] {
+				names = append(names, layerName(l))
+			}
+		}
+		findings = append(findings, &models.Finding{
+			Rule:     "image-leftover-cache",
+			Severity: wasteSeverity(sizes[i]),
+			Filepath: img.Ref,
+			Title:    fmt.Sprintf("%s left in the image (%s)", capitalize(c.name), units.FormatBytes(sizes[i])),
+			Description: fmt.Sprintf(
+				"%s under %s is not needed at runtime. It was left behind by %s. %s.",
+				capitalize(c.name), strings.TrimSuffix(c.dir, "/"), strings.Join(names, ", "), c.fix,
+			),
+			EstimatedSizeImpact: sizes[i],
+		})
+	}
+	return findings
+}
+
+// checkDuplicates reports files with identical contents stored more than once across the layers
+func checkDuplicates(img *Image) []*models.Finding {
+	groups := map[string][]*File{}
+	for _, l := range img.Layers {
+		for _, f := range l.Files {
+			if f.Hash != "" {
+				groups[f.Hash] = append(groups[f.Hash], f)
+			}
+		}
+	}
+
+	type duplicate struct {
+		files []*File
+		waste int64
+	}
+	duplicates := []*duplicate{}
+	var total int64
+	rewritten := false
+	for _, files := range groups {
+		if len(files) < 2 {
+			continue
+		}
+		d := &duplicate{files: files, waste: files[0].Size * int64(len(files)-1)}
+		duplicates = append(duplicates, d)
+		total += d.waste
+		for _, f := range files[1:] {
+			if f.Path == files[0].Path {
+				rewritten = true
+			}
+		}
+	}
+	if total < minReportedWaste {
+		return nil
+	}
+	sort.Slice(duplicates, func(i, j int) bool {
+		if duplicates[i].waste != duplicates[j].waste {
+			return duplicates[i].waste > duplicates[j].waste
+		}
+		return duplicates[i].files[0].Path < duplicates[j].files[0].Path
+	})
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d files are stored more than once across the layers of the image, wasting %s. ", len(duplicates), units.FormatBytes(total)))
+	if rewritten {
+		sb.WriteString("Some of them are rewritten with the same contents by a later layer, which usually happens when 'RUN chown -R' or 'RUN chmod -R' is used after copying files. Use COPY --chown or COPY --chmod instead. ")
+	} else {
+		sb.WriteString("Copy each file only once, or only copy the files the application needs into the final stage. ")
+	}
+	sb.WriteString("Largest duplicates:")
+	for _, d := range duplicates[:min(len(duplicates), maxExamples)] {
+		locations := []string{}
+		for _, f := range d.files {
+			locations = append(locations, fmt.Sprintf("%s (layer %d)", f.Path, f.Layer))
+		}
+		sb.WriteString(fmt.Sprintf("\n- %s: %s", units.FormatBytes(d.files[0].Size), strings.Join(locations, ", ")))
+	}
+
+	return []*models.Finding{{
+		Rule:                "image-duplicate-files",
+		Severity:            wasteSeverity(total),
+		Filepath:            img.Ref,
+		Title:               fmt.Sprintf("Duplicate files waste %s", units.FormatBytes(total)),
+		Description:         sb.String(),
+		EstimatedSizeImpact: total,
+	}}
+}
+
+// LayerInstruction returns a readable version of the instruction that created a layer
+func LayerInstruction(l *Layer) string {
+	s := strings.TrimSpace(l.CreatedBy)
+	s = strings.TrimSpace(strings.TrimSuffix(s, "# buildkit"))
+	s = strings.TrimSpace(strings.TrimPrefix(s, "/bin/sh -c #(nop)"))
+	if s == "" {
+		return "unknown instruction"
+	}
+	return s
+}
+
+func layerName(l *Layer) string {
+	instruction := LayerInstruction(l)
+	if len(instruction) > 60 {
+		instruction = instruction[:57] + "..."
+	}
+	return fmt.Sprintf("layer %d (%s)", l.Index, instruction)
+}
+
+func wasteSeverity(size int64) models.Severity {
+	switch {
+	case size >= 100<<20:
+		return models.SeverityHigh
+	case size >= 10<<20:
+		return models.SeverityMedium
+	default:
+		return models.SeverityLow
+	}
+}
+
+func capitalize(s string) string {
+	return strings.ToUpper(s[:1]) + s[1:]
+}
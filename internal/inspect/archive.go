@@ -0,0 +1,248 @@
+package inspect
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// files smaller than this are not hashed, duplicates among them are rarely worth reporting
+const hashMinSize = 4 << 10
+
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// manifestEntry is an image in the manifest.json of a "docker save" archive
+type manifestEntry struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// imageConfig is the part of the image config that dockershrink uses
+type imageConfig struct {
+	Config struct {
+		Env        []string
+		User       string
+		WorkingDir string
+		Entrypoint []string
+		Cmd        []string
+	} `json:"config"`
+	History []struct {
+		CreatedBy  string `json:"created_by"`
+		EmptyLayer bool   `json:"empty_layer"`
+	} `json:"history"`
+}
+
+// readArchive reads an archive created by "docker save".
+// Both the legacy and the OCI layouts are supported.
+func readArchive(archivePath string) (*Image, error) {
+	manifest, links, err := readManifest(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifest) == 0 {
+		return nil, errors.New("archive doesn't contain any image")
+	}
+	m := manifest[0]
+
+	// newer versions of docker store the legacy paths as symlinks to the OCI blobs
+	resolve := func(name string) string {
+		name = path.Clean(name)
+		for i := 0; i < 8; i++ {
+			target, ok := links[name]
+			if !ok {
+				break
+			}
+			name = target
+		}
+		return name
+	}
+	configName := resolve(m.Config)
+	layerIndexes := map[string][]int{}
+	for i, l := range m.Layers {
+		name := resolve(l)
+		layerIndexes[name] = append(layerIndexes[name], i)
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img := &Image{Layers: make([]*Layer, len(m.Layers))}
+	var config *imageConfig
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := path.Clean(hdr.Name)
+		if name == configName {
+			config = &imageConfig{}
+			if err := json.NewDecoder(tr).Decode(config); err != nil {
+				return nil, fmt.Errorf("invalid image config: %w", err)
+			}
+			continue
+		}
+		indexes, ok := layerIndexes[name]
+		if !ok || !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		layer, err := readLayer(tr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid layer %s: %w", name, err)
+		}
+		for _, i := range indexes {
+			img.Layers[i] = layer.withIndex(i)
+		}
+	}
+
+	if config == nil {
+		return nil, fmt.Errorf("image config %s not found in archive", m.Config)
+	}
+	for i, l := range img.Layers {
+		if l == nil {
+			return nil, fmt.Errorf("layer %s not found in archive", m.Layers[i])
+		}
+		img.Size += l.Size
+	}
+
+	img.Env = config.Config.Env
+	img.User = config.Config.User
+	img.WorkingDir = config.Config.WorkingDir
+	img.Entrypoint = config.Config.Entrypoint
+	img.Cmd = config.Config.Cmd
+
+	// every history entry that isn't an empty layer created the next layer
+	next := 0
+	for _, h := range config.History {
+		entry := &HistoryEntry{CreatedBy: h.CreatedBy, Layer: -1}
+		if !h.EmptyLayer && next < len(img.Layers) {
+			entry.Layer = next
+			img.Layers[next].CreatedBy = h.CreatedBy
+			next++
+		}
+		img.History = append(img.History, entry)
+	}
+	return img, nil
+}
+
+// readManifest returns the manifest of the archive and the symlinks in it
+func readManifest(archivePath string) ([]*manifestEntry, map[string]string, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	var manifest []*manifestEntry
+	links := map[string]string{}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		name := path.Clean(hdr.Name)
+		switch {
+		case hdr.Typeflag == tar.TypeSymlink:
+			links[name] = path.Join(path.Dir(name), hdr.Linkname)
+		case name == "manifest.json":
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				return nil, nil, fmt.Errorf("invalid manifest.json: %w", err)
+			}
+		}
+	}
+	if manifest == nil {
+		return nil, nil, errors.New("manifest.json not found, is this an archive created by docker save?")
+	}
+	return manifest, links, nil
+}
+
+// readLayer reads the files of a layer tarball, which may be gzip compressed
+func readLayer(r io.Reader) (*Layer, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	layer := &Layer{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Clean("/" + hdr.Name)
+		dir, base := path.Split(name)
+		dir = path.Clean(dir)
+		switch {
+		case base == whiteoutOpaque:
+			layer.Opaque = append(layer.Opaque, dir)
+			continue
+		case strings.HasPrefix(base, whiteoutPrefix):
+			layer.Deleted = append(layer.Deleted, path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)))
+			continue
+		}
+
+		f := &File{Path: name, Dir: hdr.Typeflag == tar.TypeDir}
+		if hdr.FileInfo().Mode().IsRegular() {
+			f.Size = hdr.Size
+			if f.Size >= hashMinSize {
+				h := sha256.New()
+				if _, err := io.Copy(h, tr); err != nil {
+					return nil, err
+				}
+				f.Hash = hex.EncodeToString(h.Sum(nil))
+			}
+		}
+		layer.Size += f.Size
+		layer.Files = append(layer.Files, f)
+	}
+	return layer, nil
+}
+
+// withIndex returns a copy of the layer at the given index of the image.
+// The same layer blob can be used more than once in an image.
+func (l *Layer) withIndex(i int) *Layer {
+	c := *l
+	c.Index = i
+	c.Files = make([]*File, len(l.Files))
+	for j, f := range l.Files {
+		fc := *f
+		fc.Layer = i
+		c.Files[j] = &fc
+	}
+	return &c
+}
@@ -0,0 +1,134 @@
+package inspect
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/docker"
+)
+
+// Image is a built image reconstructed from its exported archive
+type Image struct {
+	Ref string
+	// Size is the total size of the files in all the layers, in bytes
+	Size int64
+
+	Env        []string
+	User       string
+	WorkingDir string
+	Entrypoint []string
+	Cmd        []string
+
+	// History contains an entry for every instruction, including the ones that didn't create a layer
+	History []*HistoryEntry
+	// Layers are the filesystem layers, base image first
+	Layers []*Layer
+}
+
+// HistoryEntry is the instruction that created a layer or changed the image's config
+type HistoryEntry struct {
+	CreatedBy string
+	// Layer is the index of the layer created by the instruction, -1 if it only changed the config
+	Layer int
+}
+
+// Layer is a filesystem diff applied on top of the layers before it
+type Layer struct {
+	Index     int
+	CreatedBy string
+	// Size is the total size of the files added or modified by the layer
+	Size  int64
+	Files []*File
+	// Deleted are the paths removed from the layers below
+	Deleted []string
+	// Opaque are the directories whose contents from the layers below are hidden
+	Opaque []string
+}
+
+// File is a file or directory added or modified by a layer
+type File struct {
+	// Path is absolute, eg- /usr/local/bin/node
+	Path  string
+	Size  int64
+	Dir   bool
+	Layer int
+	// Hash is the sha256 of the file's contents.
+	// It's only computed for regular files of at least hashMinSize bytes.
+	Hash string
+}
+
+// Load exports an image with the local Docker daemon and reconstructs its layers.
+// The image is pulled if it isn't available locally, or always if pull is true.
+func Load(ctx context.Context, client *docker.Client, ref string, pull bool) (*Image, error) {
+	if pull || !client.ImageExists(ctx, ref) {
+		if err := client.Pull(ctx, ref); err != nil {
+			return nil, fmt.Errorf("failed to pull %s: %w", ref, err)
+		}
+	}
+
+	f, err := os.CreateTemp("", "dockershrink-inspect-*.tar")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	if err := client.Save(ctx, ref, f.Name()); err != nil {
+		return nil, fmt.Errorf("failed to export %s: %w", ref, err)
+	}
+	img, err := readArchive(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the layers of %s: %w", ref, err)
+	}
+	img.Ref = ref
+	return img, nil
+}
+
+// Filesystem returns the final filesystem of the image, after all the layers are applied, keyed by path
+func (img *Image) Filesystem() map[string]*File {
+	return img.applyLayers(nil)
+}
+
+// applyLayers applies the layers of the image in order and returns the final filesystem.
+// onRemove is called, if not nil, for every file that is deleted or replaced by a later layer.
+func (img *Image) applyLayers(onRemove func(f *File, by *Layer)) map[string]*File {
+	fs := map[string]*File{}
+	remove := func(p string, by *Layer) {
+		if f, ok := fs[p]; ok {
+			if onRemove != nil {
+				onRemove(f, by)
+			}
+			delete(fs, p)
+		}
+	}
+	removeChildren := func(dir string, by *Layer) {
+		prefix := strings.TrimSuffix(dir, "/") + "/"
+		for p := range fs {
+			if strings.HasPrefix(p, prefix) {
+				remove(p, by)
+			}
+		}
+	}
+
+	for _, l := range img.Layers {
+		// whiteouts only apply to the layers below, so they're processed before the layer's own files
+		for _, dir := range l.Opaque {
+			removeChildren(dir, l)
+		}
+		for _, p := range l.Deleted {
+			if f, ok := fs[p]; !ok || f.Dir {
+				removeChildren(p, l)
+			}
+			remove(p, l)
+		}
+		for _, f := range l.Files {
+			if old, ok := fs[f.Path]; ok && !old.Dir && onRemove != nil {
+				onRemove(old, l)
+			}
+			fs[f.Path] = f
+		}
+	}
+	return fs
+}
@@ -0,0 +1,139 @@
+package inspect
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+type testFile struct {
+	name    string
+	content []byte
+}
+
+func tarball(t *testing.T, files []testFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0o644, Size: int64(len(f.content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(f.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writeTestArchive writes an archive in the layout of "docker save"
+func writeTestArchive(t *testing.T) string {
+	t.Helper()
+	big := bytes.Repeat([]byte("a"), 2<<20)
+	cache := bytes.Repeat([]byte("c"), 2<<20)
+
+	config, _ := json.Marshal(map[string]any{
+		"config": map[string]any{"Env": []string{"PATH=/usr/bin", "API_TOKEN=abc", "PASSWORD_FILE=/run/secrets/db"}},
+		"history": []map[string]any{
+			{"created_by": "/bin/sh -c #(nop) ADD file:123 in / "},
+			{"created_by": "WORKDIR /app", "empty_layer": true},
+			{"created_by": "RUN |1 NPM_TOKEN=xyz /bin/sh -c npm ci # buildkit"},
+		},
+	})
+	manifest, _ := json.Marshal([]map[string]any{{
+		"Config": "config.json",
+		"Layers": []string{"layer0/layer.tar", "layer1/layer.tar"},
+	}})
+
+	archive := tarball(t, []testFile{
+		{"manifest.json", manifest},
+		{"config.json", config},
+		{"layer0/layer.tar", tarball(t, []testFile{
+			{"usr/bin/big", big},
+			{"app/.env", []byte("SECRET=1")},
+			{"app/.env.example", []byte("SECRET=")},
+			{"etc/ssl/certs/ca.pem", []byte("cert")},
+		})},
+		{"layer1/layer.tar", tarball(t, []testFile{
+			{"app/.wh..env", nil},
+			{"app/big-copy", big},
+			{"root/.npm/_cacache/index", cache},
+		})},
+	})
+	p := filepath.Join(t.TempDir(), "image.tar")
+	if err := os.WriteFile(p, archive, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestReadArchive(t *testing.T) {
+	img, err := readArchive(writeTestArchive(t))
+	if err != nil {
+		t.Fatalf("readArchive() failed: %v", err)
+	}
+	if len(img.Layers) != 2 || len(img.History) != 3 {
+		t.Fatalf("got %d layers and %d history entries; want 2 and 3", len(img.Layers), len(img.History))
+	}
+	if img.Layers[1].CreatedBy != "RUN |1 NPM_TOKEN=xyz /bin/sh -c npm ci # buildkit" || img.History[1].Layer != -1 {
+		t.Errorf("history is not mapped to the layers: %+v", img.History)
+	}
+	if img.Size != 6<<20+int64(len("SECRET=1")+len("SECRET=")+len("cert")) {
+		t.Errorf("Size = %d", img.Size)
+	}
+
+	fs := img.Filesystem()
+	if _, ok := fs["/app/.env"]; ok {
+		t.Errorf("Filesystem() contains /app/.env, which was deleted by a whiteout")
+	}
+	if f, ok := fs["/app/big-copy"]; !ok || f.Layer != 1 || f.Hash != fs["/usr/bin/big"].Hash {
+		t.Errorf("Filesystem() = %v; want /app/big-copy from layer 1 with the same hash as /usr/bin/big", fs["/app/big-copy"])
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	img, err := readArchive(writeTestArchive(t))
+	if err != nil {
+		t.Fatalf("readArchive() failed: %v", err)
+	}
+	img.Ref = "example:latest"
+	r := Analyze(img)
+
+	titles := []string{}
+	rules := map[string]int{}
+	for _, f := range r.Findings {
+		titles = append(titles, f.Title)
+		rules[f.Rule]++
+	}
+	expected := map[string]int{
+		"image-secret-file":     1,
+		"image-secret-variable": 2,
+		"image-leftover-cache":  1,
+		"image-duplicate-files": 1,
+	}
+	for rule, count := range expected {
+		if rules[rule] != count {
+			t.Errorf("got %d findings of %s; want %d. Findings: %s", rules[rule], rule, count, strings.Join(titles, "; "))
+		}
+	}
+	for _, f := range r.Findings {
+		if f.Rule == "image-secret-file" && !strings.Contains(f.Description, "can still be extracted") {
+			t.Errorf("deleted secret file not reported as extractable: %s", f.Description)
+		}
+		if f.Rule == "image-duplicate-files" && (f.EstimatedSizeImpact != 2<<20 || f.Severity != models.SeverityLow) {
+			t.Errorf("duplicate files finding = %+v", f)
+		}
+	}
+	if len(r.LargestLayers) != 2 || r.LargestLayers[0].Index != 1 {
+		t.Errorf("LargestLayers are not sorted by size")
+	}
+}
@@ -0,0 +1,268 @@
+package inspect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/sandbox"
+	"github.com/duaraghav8/dockershrink/internal/units"
+)
+
+const (
+	// profileStartMarker is created once the access times are reset, the application starts after it exists
+	profileStartMarker = "/.dockershrink-profile-start"
+	// accessTimesFile is where the access times of all files are written to once the workload is done
+	accessTimesFile = "/.dockershrink-access-times"
+
+	// access times are reset to 1980-01-01 so that any read made afterwards updates them, even with relatime
+	resetTimestamp = "198001010000"
+	// files accessed after the reset have an access time later than this. The margin covers the timezone
+	// of the container, which touch uses to interpret resetTimestamp.
+	resetUnixThreshold = 315532800 + 2*24*60*60
+
+	// max time for resetting or collecting the access times of all files in the image
+	profileCommandTimeout = 5 * time.Minute
+	// max time the workload may run for
+	workloadTimeout = 5 * time.Minute
+	// max number of never read files and directories reported
+	maxUnreadReported = 10
+)
+
+// ErrNoShell is returned when profiling an image that doesn't contain the tools needed to track file access
+var ErrNoShell = errors.New("profiling requires /bin/sh, find, stat and touch in the image")
+
+// waitForStart blocks the application until its files' access times have been reset, then runs it.
+// The application's entrypoint and command are passed as arguments.
+var waitForStart = fmt.Sprintf(`while [ ! -e %s ]; do sleep 0.1; done; exec "$@"`, profileStartMarker)
+
+var (
+	resetAccessTimes = fmt.Sprintf(`find / -xdev -type f -exec touch -a -t %s {} + 2>/dev/null; touch %s`, resetTimestamp, profileStartMarker)
+	// find fails if some directories can't be read, which must not discard the access times of all other files
+	collectAccessTimes = fmt.Sprintf(`find / -xdev -type f -exec stat -c '%%X %%n' {} + > %[1]s 2>/dev/null; [ -s %[1]s ]`, accessTimesFile)
+)
+
+// ProfileOptions control how an image is run while its file accesses are recorded
+type ProfileOptions struct {
+	// Workload is run inside the container after the application has booted, eg- a script exercising its API.
+	// Only the files read while booting are recorded if it's empty.
+	Workload []string
+	// Duration is how long the application runs before the workload
+	Duration time.Duration
+	Limits   sandbox.Limits
+}
+
+// RuntimeProfile records which files of an image were read while it ran
+type RuntimeProfile struct {
+	// Files maps the path of every regular file that was tracked to whether it was read.
+	// Files missing from it, eg- because they were deleted at runtime, must be assumed to be read.
+	Files map[string]bool
+	// WorkloadResult is only set if a workload was run
+	WorkloadResult *sandbox.Result
+}
+
+// Counts returns the number of files that were read and the number of files that were tracked
+func (p *RuntimeProfile) Counts() (read, total int) {
+	for _, r := range p.Files {
+		if r {
+			read++
+		}
+	}
+	return read, len(p.Files)
+}
+
+// Profile runs the image in a sandbox with an optional workload and records which files it reads,
+// by resetting the access time of every file before the application starts and collecting them afterwards.
+// Files read by the tools doing this, eg- find and sleep, are recorded as read as well.
+func Profile(ctx context.Context, sb *sandbox.Sandbox, img *Image, opts *ProfileOptions) (*RuntimeProfile, error) {
+	if _, ok := img.Filesystem()["/bin/sh"]; !ok {
+		return nil, ErrNoShell
+	}
+	command := append(append([]string{}, img.Entrypoint...), img.Cmd...)
+	if len(command) == 0 {
+		return nil, errors.New("the image has no entrypoint or command to run")
+	}
+
+	c, err := sb.Start(ctx, &sandbox.Spec{
+		Image:      img.Ref,
+		Entrypoint: "/bin/sh",
+		Command:    append([]string{"-c", waitForStart, "dockershrink-profile"}, command...),
+		Limits:     opts.Limits,
+		// root needs these to reset the access times of, and list, files owned by other users
+		CapAdd: []string{"FOWNER", "DAC_READ_SEARCH"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer c.Remove()
+
+	if err := runAsRoot(ctx, c, resetAccessTimes); err != nil {
+		return nil, fmt.Errorf("failed to reset access times: %w", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(opts.Duration):
+	}
+	if err := checkRunning(ctx, c); err != nil {
+		return nil, err
+	}
+
+	profile := &RuntimeProfile{}
+	if len(opts.Workload) > 0 {
+		if profile.WorkloadResult, err = c.Exec(ctx, opts.Workload, workloadTimeout); err != nil {
+			return nil, err
+		}
+		if err := checkRunning(ctx, c); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := runAsRoot(ctx, c, collectAccessTimes); err != nil {
+		return nil, fmt.Errorf("failed to collect access times: %w", err)
+	}
+	out, err := c.ReadFile(ctx, accessTimesFile)
+	if err != nil {
+		return nil, err
+	}
+	if profile.Files, err = parseAccessTimes(string(out)); err != nil {
+		return nil, err
+	}
+	if read, _ := profile.Counts(); read == 0 {
+		return nil, errors.New("no file access was recorded, the storage driver may be mounting the image with noatime")
+	}
+	return profile, nil
+}
+
+func runAsRoot(ctx context.Context, c *sandbox.Container, script string) error {
+	res, err := c.ExecAs(ctx, "0", []string{"/bin/sh", "-c", script}, profileCommandTimeout)
+	if err != nil {
+		return err
+	}
+	if !res.Succeeded() {
+		if res.ExitCode == 127 {
+			return ErrNoShell
+		}
+		return fmt.Errorf("exited with status %d: %s", res.ExitCode, strings.TrimSpace(res.Stderr))
+	}
+	return nil
+}
+
+// checkRunning returns an error if the application has exited, since files can only be listed in a running container
+func checkRunning(ctx context.Context, c *sandbox.Container) error {
+	state, err := c.State(ctx)
+	if err != nil {
+		return err
+	}
+	if !state.Running {
+		return fmt.Errorf("the application exited with status %d before its files could be recorded:\n%s", state.ExitCode, c.Logs(ctx))
+	}
+	return nil
+}
+
+// parseAccessTimes parses the output of collectAccessTimes, one "<access time> <path>" line per file
+func parseAccessTimes(out string) (map[string]bool, error) {
+	files := map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+		atime, p, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("unexpected access time %q", line)
+		}
+		t, err := strconv.ParseInt(atime, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected access time %q", line)
+		}
+		if p == profileStartMarker || p == accessTimesFile {
+			continue
+		}
+		files[p] = t > resetUnixThreshold
+	}
+	return files, nil
+}
+
+// CheckRuntimeProfile reports the largest files and directories of the image that were never read while it was
+// profiled. Directories are only reported if none of the files inside them were read.
+func CheckRuntimeProfile(img *Image, profile *RuntimeProfile, workload []string) []*models.Finding {
+	// unread is the size of the never read files under a path, read is true if any file under it was read
+	unread := map[string]int64{}
+	read := map[string]bool{}
+	layers := map[string]map[int]bool{}
+	fs := img.Filesystem()
+	for p, f := range fs {
+		if f.Dir || f.Size == 0 {
+			continue
+		}
+		wasRead, tracked := profile.Files[p]
+		for n := p; n != "/"; n = path.Dir(n) {
+			if tracked && !wasRead {
+				unread[n] += f.Size
+				if layers[n] == nil {
+					layers[n] = map[int]bool{}
+				}
+				layers[n][f.Layer] = true
+			} else {
+				read[n] = true
+			}
+		}
+	}
+
+	// only the topmost never read path is reported, not every file and directory inside it
+	paths := []string{}
+	for p, size := range unread {
+		parent := path.Dir(p)
+		if read[p] || (parent != "/" && !read[parent]) || size < minReportedWaste {
+			continue
+		}
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if unread[paths[i]] != unread[paths[j]] {
+			return unread[paths[i]] > unread[paths[j]]
+		}
+		return paths[i] < paths[j]
+	})
+	if len(paths) > maxUnreadReported {
+		paths = paths[:maxUnreadReported]
+	}
+
+	during := "while the image ran"
+	if len(workload) > 0 {
+		during = fmt.Sprintf("while the image ran %q", strings.Join(workload, " "))
+	}
+	findings := []*models.Finding{}
+	for _, p := range paths {
+		names := []string{}
+		for _, l := range img.Layers {
+			if layers[p][l.Index] {
+				names = append(names, layerName(l))
+			}
+		}
+		what := p + " was never read"
+		if f, ok := fs[p]; !ok || f.Dir {
+			what = "No file under " + p + " was read"
+		}
+		findings = append(findings, &models.Finding{
+			Rule:     "image-unread-files",
+			Severity: wasteSeverity(unread[p]),
+			Filepath: img.Ref,
+			Title:    fmt.Sprintf("%s was never read at runtime (%s)", p, units.FormatBytes(unread[p])),
+			Description: fmt.Sprintf(
+				"%s %s. It was added by %s. If the application doesn't need it on other code paths either, "+
+					"exclude it with .dockerignore, stop copying it into the final stage or delete it in the same RUN that creates it.",
+				what, during, strings.Join(names, ", "),
+			),
+			EstimatedSizeImpact: unread[p],
+		})
+	}
+	return findings
+}
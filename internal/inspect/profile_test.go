@@ -0,0 +1,90 @@
+package inspect
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAccessTimes(t *testing.T) {
+	files, err := parseAccessTimes(`1700000000 /app/index.js
+315532800 /app/README.md
+315532800 /app/docs/my guide.md
+1700000001 /.dockershrink-profile-start
+`)
+	if err != nil {
+		t.Fatalf("parseAccessTimes() error = %v", err)
+	}
+	expected := map[string]bool{
+		"/app/index.js":         true,
+		"/app/README.md":        false,
+		"/app/docs/my guide.md": false,
+	}
+	if len(files) != len(expected) {
+		t.Errorf("parseAccessTimes() = %v; want %v", files, expected)
+	}
+	for p, read := range expected {
+		if got, ok := files[p]; !ok || got != read {
+			t.Errorf("parseAccessTimes()[%q] = %v, %v; want %v", p, got, ok, read)
+		}
+	}
+
+	if _, err := parseAccessTimes("not-a-time /app/index.js\n"); err == nil {
+		t.Error("parseAccessTimes() expected an error for invalid output")
+	}
+}
+
+func TestCheckRuntimeProfile(t *testing.T) {
+	img := &Image{
+		Ref: "app:latest",
+		Layers: []*Layer{
+			{Index: 0, CreatedBy: "/bin/sh -c #(nop) ADD file:abc in /", Files: []*File{
+				{Path: "/bin/sh", Size: 1 << 20, Layer: 0},
+				{Path: "/usr/share/doc", Dir: true, Layer: 0},
+				{Path: "/usr/share/doc/a.txt", Size: 2 << 20, Layer: 0},
+				{Path: "/usr/share/doc/b.txt", Size: 2 << 20, Layer: 0},
+			}},
+			{Index: 1, CreatedBy: "COPY . /app # buildkit", Files: []*File{
+				{Path: "/app/index.js", Size: 1 << 10, Layer: 1},
+				{Path: "/app/fixtures.bin", Size: 5 << 20, Layer: 1},
+				{Path: "/app/small.txt", Size: 10, Layer: 1},
+				// deleted at runtime, so its access time is unknown
+				{Path: "/app/cache.bin", Size: 5 << 20, Layer: 1},
+				{Path: "/app/node_modules/a/index.js", Size: 10, Layer: 1},
+				{Path: "/app/node_modules/a/big.wasm", Size: 3 << 20, Layer: 1},
+			}},
+		},
+	}
+	profile := &RuntimeProfile{Files: map[string]bool{
+		"/bin/sh":                      true,
+		"/usr/share/doc/a.txt":         false,
+		"/usr/share/doc/b.txt":         false,
+		"/app/index.js":                true,
+		"/app/fixtures.bin":            false,
+		"/app/small.txt":               false,
+		"/app/node_modules/a/index.js": true,
+		"/app/node_modules/a/big.wasm": false,
+	}}
+	if read, total := profile.Counts(); read != 3 || total != 8 {
+		t.Errorf("Counts() = %d, %d; want 3, 8", read, total)
+	}
+
+	findings := CheckRuntimeProfile(img, profile, []string{"npm", "test"})
+	titles := []string{}
+	for _, f := range findings {
+		titles = append(titles, f.Title)
+	}
+	expected := []string{
+		"/app/fixtures.bin was never read at runtime (5.0 MB)",
+		"/usr was never read at runtime (4.0 MB)",
+		"/app/node_modules/a/big.wasm was never read at runtime (3.0 MB)",
+	}
+	if strings.Join(titles, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("CheckRuntimeProfile() = %q; want %q", titles, expected)
+	}
+	if !strings.Contains(findings[1].Description, `No file under /usr was read while the image ran "npm test"`) {
+		t.Errorf("unexpected description %q", findings[1].Description)
+	}
+	if findings[0].EstimatedSizeImpact != 5<<20 {
+		t.Errorf("EstimatedSizeImpact = %d; want %d", findings[0].EstimatedSizeImpact, 5<<20)
+	}
+}
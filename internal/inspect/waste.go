@@ -0,0 +1,128 @@
+package inspect
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/units"
+)
+
+// directories where compilers, headers and other build tools are installed
+var toolchainPrefixes = []string{
+	"/usr/lib/gcc/", "/usr/libexec/gcc/", "/usr/include/", "/usr/local/include/",
+	"/usr/bin/gcc", "/usr/bin/g++", "/usr/bin/cc", "/usr/bin/c++", "/usr/bin/make", "/usr/bin/ld",
+	"/usr/lib/x86_64-linux-gnu/libLLVM", "/root/.node-gyp/", "/root/.cache/node-gyp/", "/usr/local/go/",
+}
+
+// wastedLayer is the data added by one layer and deleted or replaced by a later one
+type wastedLayer struct {
+	added, removed *Layer
+	size           int64
+	toolchainSize  int64
+	files          []*File
+}
+
+// checkLayerWaste reports files that are added by a layer and deleted or replaced by a later one.
+// The files are hidden from the final filesystem, but the layer that added them still stores them.
+func checkLayerWaste(img *Image) []*models.Finding {
+	wasted := map[[2]int]*wastedLayer{}
+	hashes := map[int]map[string]string{}
+	img.applyLayers(func(f *File, by *Layer) {
+		if f.Size == 0 {
+			return
+		}
+		// replacing a file with identical contents is reported as a duplicate
+		if hashes[by.Index] == nil {
+			hashes[by.Index] = map[string]string{}
+			for _, nf := range by.Files {
+				hashes[by.Index][nf.Path] = nf.Hash
+			}
+		}
+		if f.Hash != "" && hashes[by.Index][f.Path] == f.Hash {
+			return
+		}
+		key := [2]int{f.Layer, by.Index}
+		w, ok := wasted[key]
+		if !ok {
+			w = &wastedLayer{added: img.Layers[f.Layer], removed: by}
+			wasted[key] = w
+		}
+		w.size += f.Size
+		w.files = append(w.files, f)
+		if isToolchainFile(f.Path) {
+			w.toolchainSize += f.Size
+		}
+	})
+
+	sorted := []*wastedLayer{}
+	for _, w := range wasted {
+		if w.size >= minReportedWaste {
+			sorted = append(sorted, w)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].size != sorted[j].size {
+			return sorted[i].size > sorted[j].size
+		}
+		return sorted[i].added.Index < sorted[j].added.Index
+	})
+
+	findings := []*models.Finding{}
+	for _, w := range sorted {
+		sort.Slice(w.files, func(i, j int) bool {
+			return w.files[i].Size > w.files[j].Size
+		})
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf(
+			"%s of files added by %s are deleted or replaced by %s. They are hidden from the final filesystem, but the layer that added them still stores them. %s\nLargest files:",
+			units.FormatBytes(w.size), layerName(w.added), layerName(w.removed), wasteSuggestion(w),
+		))
+		for _, f := range w.files[:min(len(w.files), maxExamples)] {
+			sb.WriteString(fmt.Sprintf("\n- %s: %s", units.FormatBytes(f.Size), f.Path))
+		}
+
+		findings = append(findings, &models.Finding{
+			Rule:                "image-layer-waste",
+			Severity:            wasteSeverity(w.size),
+			Filepath:            img.Ref,
+			Title:               fmt.Sprintf("%s added by layer %d is deleted in layer %d", units.FormatBytes(w.size), w.added.Index, w.removed.Index),
+			Description:         sb.String(),
+			EstimatedSizeImpact: w.size,
+		})
+	}
+	return findings
+}
+
+// wasteSuggestion returns the Dockerfile change that avoids storing the wasted files
+func wasteSuggestion(w *wastedLayer) string {
+	switch {
+	case w.toolchainSize*2 > w.size:
+		return "Most of them are build tools. Use a multistage build: install the tools and build the application in a separate stage, then only copy the build output into the final stage."
+	case layerKind(w.added) == "RUN" && layerKind(w.removed) == "RUN":
+		return fmt.Sprintf("Merge the RUN instructions of layers %d to %d into a single RUN, so that the files are deleted in the same layer that creates them.", w.added.Index, w.removed.Index)
+	case layerKind(w.added) == "COPY" || layerKind(w.added) == "ADD":
+		return "Instead of copying the files and deleting them later, use RUN --mount=type=bind to access them only while the command runs, or use a multistage build and only copy the result into the final stage."
+	default:
+		return "Use a multistage build to create these files in a separate stage and only copy what is needed into the final stage."
+	}
+}
+
+// layerKind returns the instruction that created the layer, eg- RUN or COPY
+func layerKind(l *Layer) string {
+	instruction := LayerInstruction(l)
+	if strings.HasPrefix(instruction, "/bin/sh -c") || strings.HasPrefix(instruction, "|") {
+		return "RUN"
+	}
+	return strings.ToUpper(strings.Fields(instruction)[0])
+}
+
+func isToolchainFile(p string) bool {
+	for _, prefix := range toolchainPrefixes {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package inspect
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckLayerWaste(t *testing.T) {
+	layer := func(i int, createdBy string, files []*File, deleted ...string) *Layer {
+		l := &Layer{Index: i, CreatedBy: createdBy, Files: files, Deleted: deleted}
+		for _, f := range files {
+			f.Layer = i
+			l.Size += f.Size
+		}
+		return l
+	}
+	img := &Image{Ref: "example:latest", Layers: []*Layer{
+		layer(0, "/bin/sh -c #(nop) ADD file:123 in / ", []*File{{Path: "/bin/sh", Size: 1 << 20}}),
+		layer(1, "RUN /bin/sh -c apt-get update && apt-get install -y build-essential # buildkit", []*File{
+			{Path: "/var/cache/apt/archives/gcc.deb", Size: 3 << 20},
+			{Path: "/usr/lib/gcc/x86_64-linux-gnu/12/cc1", Size: 20 << 20},
+		}),
+		layer(2, "RUN /bin/sh -c rm -rf /var/cache/apt/archives # buildkit", nil, "/var/cache/apt/archives"),
+		layer(3, "COPY app.tar.gz /app/ # buildkit", []*File{{Path: "/app/app.tar.gz", Size: 2 << 20}}),
+		layer(4, "RUN /bin/sh -c tar xzf app.tar.gz && rm app.tar.gz # buildkit", []*File{{Path: "/app/index.js", Size: 4 << 20}}, "/app/app.tar.gz"),
+		layer(5, "RUN /bin/sh -c apt-get purge -y build-essential # buildkit", nil, "/usr/lib/gcc"),
+	}}
+
+	findings := checkLayerWaste(img)
+	expected := []struct {
+		title      string
+		suggestion string
+	}{
+		{"20.0 MB added by layer 1 is deleted in layer 5", "multistage build"},
+		{"3.0 MB added by layer 1 is deleted in layer 2", "Merge the RUN instructions of layers 1 to 2"},
+		{"2.0 MB added by layer 3 is deleted in layer 4", "RUN --mount=type=bind"},
+	}
+	if len(findings) != len(expected) {
+		t.Fatalf("got %d findings; want %d", len(findings), len(expected))
+	}
+	for i, e := range expected {
+		if findings[i].Title != e.title {
+			t.Errorf("finding %d title = %q; want %q", i, findings[i].Title, e.title)
+		}
+		if !strings.Contains(findings[i].Description, e.suggestion) {
+			t.Errorf("finding %d description = %q; want it to suggest %q", i, findings[i].Description, e.suggestion)
+		}
+	}
+	if findings[0].EstimatedSizeImpact != 20<<20 {
+		t.Errorf("EstimatedSizeImpact = %d; want %d", findings[0].EstimatedSizeImpact, 20<<20)
+	}
+}
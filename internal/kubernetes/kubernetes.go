@@ -0,0 +1,358 @@
+// Package kubernetes reads the containers that Kubernetes manifests and Helm values files run, so that the
+// Dockerfile of an image can be cross-checked against how the image is deployed, eg- with the resources it's given.
+// Only the parts dockershrink needs are parsed.
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"gopkg.in/yaml.v3"
+)
+
+// Pull policies of images
+const (
+	PullAlways       = "Always"
+	PullIfNotPresent = "IfNotPresent"
+	PullNever        = "Never"
+)
+
+// Container is a container of a workload, or the image of a Helm chart as configured by its values
+type Container struct {
+	// Path and Line locate the container in its file
+	Path string
+	Line int
+	// Workload is what runs the container, eg- "Deployment/api", or the values of the chart, eg- "values.yaml"
+	Workload string
+	Name     string
+	Image    string
+	// ImagePullPolicy is empty if it isn't set, in which case Kubernetes pulls images tagged latest every time
+	ImagePullPolicy string
+	// Command overrides the ENTRYPOINT of the image, Args its CMD
+	Command []string
+	Args    []string
+	// Limits and Requests are the quantities of resources, eg- {"memory": "256Mi", "cpu": "500m"}
+	Limits   map[string]string
+	Requests map[string]string
+	// HelmValues is true for the image of a Helm values file, whose tag defaults to the appVersion of the chart
+	HelmValues bool
+}
+
+// Memory returns the memory limit of the container in bytes, or its request if it has no limit.
+// 0 is returned if neither is set or they can't be parsed.
+func (c *Container) Memory() int64 {
+	for _, resources := range []map[string]string{c.Limits, c.Requests} {
+		if q, ok := resources["memory"]; ok {
+			if bytes, err := ParseQuantity(q); err == nil {
+				return bytes
+			}
+		}
+	}
+	return 0
+}
+
+// Describe returns the name of the container along with its workload, eg- "container api of Deployment/api",
+// or the chart whose values configure it, eg- "the worker subchart of values.yaml"
+func (c *Container) Describe() string {
+	if c.HelmValues && c.Name != "" {
+		return fmt.Sprintf("the %s subchart of %s", c.Name, filepath.Base(c.Path))
+	}
+	if c.HelmValues {
+		return "the chart of " + filepath.Base(c.Path)
+	}
+	return fmt.Sprintf("container %s of %s", c.Name, c.Workload)
+}
+
+// kinds of workloads, mapped to the path of their pod spec
+var podSpecs = map[string][]string{
+	"Pod":                   {"spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+type containerSpec struct {
+	Name            string `yaml:"name"`
+	Image           string `yaml:"image"`
+	ImagePullPolicy string `yaml:"imagePullPolicy"`
+	runSpec         `yaml:",inline"`
+}
+
+// runSpec is how a container is run, which Helm charts usually configure with values of the same name
+type runSpec struct {
+	Command   []string `yaml:"command"`
+	Args      []string `yaml:"args"`
+	Resources struct {
+		Limits   map[string]string `yaml:"limits"`
+		Requests map[string]string `yaml:"requests"`
+	} `yaml:"resources"`
+}
+
+// helmImage is the image section of Helm values, as laid out by "helm create"
+type helmImage struct {
+	Registry   string `yaml:"registry"`
+	Repository string `yaml:"repository"`
+	Tag        string `yaml:"tag"`
+	Digest     string `yaml:"digest"`
+	PullPolicy string `yaml:"pullPolicy"`
+}
+
+// Load returns the containers of the manifests in the YAML file at path, which can hold several documents.
+// A document without a kind is read as the values of a Helm chart, which configure the image of the chart
+// and of its subcharts, eg- image.repository and image.tag.
+func Load(path string) ([]*Container, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	containers := []*Container{}
+	decoder := yaml.NewDecoder(f)
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		found, err := fromDocument(path, doc.Content[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		containers = append(containers, found...)
+	}
+	return containers, nil
+}
+
+// Find returns the files matching the given glob patterns, eg- "deploy/*.yaml", sorted and without duplicates
+func Find(patterns []string) ([]string, error) {
+	seen := map[string]bool{}
+	files := []string{}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no manifest matches %s", pattern)
+		}
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				files = append(files, m)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Match returns the containers that run the given image repository, eg- "ghcr.io/acme/api".
+// If repository is empty, the containers whose image is named like project, eg- "ghcr.io/acme/api:1.2" for the
+// project "api", are returned, or the only container if there's just one.
+func Match(containers []*Container, repository, project string) []*Container {
+	matched := []*Container{}
+	for _, c := range containers {
+		name := dockerfile.NewImage(c.Image).Name()
+		switch {
+		case repository != "" && (name == repository || strings.HasSuffix(name, "/"+repository)):
+		case repository == "" && path.Base(name) == project:
+		default:
+			continue
+		}
+		matched = append(matched, c)
+	}
+	if len(matched) == 0 && repository == "" && len(containers) == 1 {
+		return containers
+	}
+	return matched
+}
+
+func fromDocument(path string, root *yaml.Node) ([]*Container, error) {
+	if root.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	var head struct {
+		Kind     string `yaml:"kind"`
+		Metadata struct {
+			Name string `yaml:"name"`
+		} `yaml:"metadata"`
+	}
+	if err := root.Decode(&head); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case head.Kind == "":
+		return fromHelmValues(path, root)
+	case strings.HasSuffix(head.Kind, "List"):
+		containers := []*Container{}
+		if items := lookup(root, "items"); items != nil && items.Kind == yaml.SequenceNode {
+			for _, item := range items.Content {
+				found, err := fromDocument(path, item)
+				if err != nil {
+					return nil, err
+				}
+				containers = append(containers, found...)
+			}
+		}
+		return containers, nil
+	}
+
+	specPath, ok := podSpecs[head.Kind]
+	if !ok {
+		return nil, nil
+	}
+	list := lookup(lookup(root, specPath...), "containers")
+	if list == nil || list.Kind != yaml.SequenceNode {
+		return nil, nil
+	}
+	containers := []*Container{}
+	for _, node := range list.Content {
+		var spec containerSpec
+		if err := node.Decode(&spec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		containers = append(containers, &Container{
+			Path:            path,
+			Line:            node.Line,
+			Workload:        head.Kind + "/" + head.Metadata.Name,
+			Name:            spec.Name,
+			Image:           spec.Image,
+			ImagePullPolicy: spec.ImagePullPolicy,
+			Command:         spec.Command,
+			Args:            spec.Args,
+			Limits:          spec.Resources.Limits,
+			Requests:        spec.Resources.Requests,
+		})
+	}
+	return containers, nil
+}
+
+// fromHelmValues returns the image configured by the values of a chart, and those of its subcharts,
+// which are configured under their name
+func fromHelmValues(path string, root *yaml.Node) ([]*Container, error) {
+	containers := []*Container{}
+	charts := []*yaml.Node{root}
+	names := []string{""}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i+1].Kind == yaml.MappingNode {
+			charts = append(charts, root.Content[i+1])
+			names = append(names, root.Content[i].Value)
+		}
+	}
+
+	for i, chart := range charts {
+		imageNode := lookup(chart, "image")
+		if imageNode == nil {
+			continue
+		}
+		var image helmImage
+		switch imageNode.Kind {
+		case yaml.ScalarNode:
+			image.Repository = imageNode.Value
+		case yaml.MappingNode:
+			if err := imageNode.Decode(&image); err != nil {
+				return nil, fmt.Errorf("line %d: %w", imageNode.Line, err)
+			}
+		}
+		if image.Repository == "" {
+			continue
+		}
+		var spec runSpec
+		if err := chart.Decode(&spec); err != nil {
+			// charts lay their values out as they like, only the image is required
+			spec = runSpec{}
+		}
+
+		ref := image.Repository
+		if image.Registry != "" {
+			ref = image.Registry + "/" + ref
+		}
+		if image.Tag != "" {
+			ref += dockerfile.NameTagSep + image.Tag
+		}
+		if image.Digest != "" {
+			ref += dockerfile.DigestSep + image.Digest
+		}
+		workload := filepath.Base(path)
+		if names[i] != "" {
+			workload += ":" + names[i]
+		}
+		containers = append(containers, &Container{
+			Path:            path,
+			Line:            imageNode.Line,
+			Workload:        workload,
+			Name:            names[i],
+			Image:           ref,
+			ImagePullPolicy: image.PullPolicy,
+			Command:         spec.Command,
+			Args:            spec.Args,
+			Limits:          spec.Resources.Limits,
+			Requests:        spec.Resources.Requests,
+			HelmValues:      true,
+		})
+	}
+	return containers, nil
+}
+
+// lookup returns the node at the given keys of nested mappings, nil if there's none
+func lookup(node *yaml.Node, keys ...string) *yaml.Node {
+	for _, key := range keys {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		node = next
+	}
+	return node
+}
+
+// multipliers of the suffixes of quantities
+var quantitySuffixes = map[string]float64{
+	"Ki": 1 << 10, "Mi": 1 << 20, "Gi": 1 << 30, "Ti": 1 << 40, "Pi": 1 << 50, "Ei": 1 << 60,
+	"k": 1e3, "M": 1e6, "G": 1e9, "T": 1e12, "P": 1e15, "E": 1e18,
+	"m": 1e-3,
+}
+
+// ParseQuantity parses a Kubernetes quantity of memory into bytes, eg- "256Mi", "1G" or "1e9"
+func ParseQuantity(q string) (int64, error) {
+	q = strings.TrimSpace(q)
+	number, multiplier := q, 1.0
+	// two-letter suffixes first, so that "Mi" isn't read as "M"
+	for _, n := range []int{2, 1} {
+		if m, ok := quantitySuffixes[q[max(len(q)-n, 0):]]; ok && len(q) > n {
+			number, multiplier = q[:len(q)-n], m
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("invalid quantity %q", q)
+	}
+	return int64(math.Ceil(value * multiplier)), nil
+}
@@ -0,0 +1,207 @@
+package kubernetes
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []*Container
+	}{
+		{
+			name: "deployment and service",
+			content: `apiVersion: v1
+kind: Service
+metadata:
+  name: api
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  template:
+    spec:
+      containers:
+        - name: api
+          image: ghcr.io/acme/api:1.4.0
+          imagePullPolicy: IfNotPresent
+          command: [sh, -c]
+          args: ["node server.js"]
+          resources:
+            limits:
+              memory: 256Mi
+        - name: proxy
+          image: envoyproxy/envoy:v1.31
+`,
+			expected: []*Container{
+				{Line: 14, Workload: "Deployment/api", Name: "api", Image: "ghcr.io/acme/api:1.4.0", ImagePullPolicy: PullIfNotPresent,
+					Command: []string{"sh", "-c"}, Args: []string{"node server.js"}, Limits: map[string]string{"memory": "256Mi"}},
+				{Line: 22, Workload: "Deployment/api", Name: "proxy", Image: "envoyproxy/envoy:v1.31"},
+			},
+		},
+		{
+			name: "cronjob in a list",
+			content: `apiVersion: v1
+kind: List
+items:
+  - apiVersion: batch/v1
+    kind: CronJob
+    metadata:
+      name: cleanup
+    spec:
+      jobTemplate:
+        spec:
+          template:
+            spec:
+              containers:
+                - name: cleanup
+                  image: ghcr.io/acme/api
+`,
+			expected: []*Container{
+				{Line: 14, Workload: "CronJob/cleanup", Name: "cleanup", Image: "ghcr.io/acme/api"},
+			},
+		},
+		{
+			name: "helm values",
+			content: `replicaCount: 2
+image:
+  repository: acme/api
+  registry: ghcr.io
+  pullPolicy: IfNotPresent
+  tag: ""
+resources:
+  requests:
+    memory: 128Mi
+worker:
+  image: ghcr.io/acme/worker:2.0.1
+  command: [npm, run, worker]
+ingress:
+  enabled: false
+`,
+			expected: []*Container{
+				{Line: 3, Workload: "values.yaml", Image: "ghcr.io/acme/api", ImagePullPolicy: PullIfNotPresent,
+					Requests: map[string]string{"memory": "128Mi"}, HelmValues: true},
+				{Line: 11, Workload: "values.yaml:worker", Name: "worker", Image: "ghcr.io/acme/worker:2.0.1",
+					Command: []string{"npm", "run", "worker"}, HelmValues: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "values.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			containers, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			for _, c := range tt.expected {
+				c.Path = path
+			}
+			if !reflect.DeepEqual(containers, tt.expected) {
+				t.Errorf("Load() returned:")
+				for _, c := range containers {
+					t.Errorf("  %+v", c)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad_InvalidYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deployment.yaml")
+	if err := os.WriteFile(path, []byte("kind: Deployment\nspec: [\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("expected an error for invalid YAML")
+	}
+}
+
+func TestContainer_Describe(t *testing.T) {
+	tests := []struct {
+		container *Container
+		expected  string
+	}{
+		{container: &Container{Path: "deploy/api.yaml", Workload: "Deployment/api", Name: "api"}, expected: "container api of Deployment/api"},
+		{container: &Container{Path: "chart/values.yaml", Workload: "values.yaml", HelmValues: true}, expected: "the chart of values.yaml"},
+		{container: &Container{Path: "chart/values.yaml", Workload: "values.yaml:worker", Name: "worker", HelmValues: true}, expected: "the worker subchart of values.yaml"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.container.Describe(); got != tt.expected {
+			t.Errorf("Describe() = %q; want %q", got, tt.expected)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	api := &Container{Image: "ghcr.io/acme/api:1.4.0"}
+	worker := &Container{Image: "ghcr.io/acme/worker:1.4.0"}
+	proxy := &Container{Image: "envoyproxy/envoy:v1.31"}
+
+	tests := []struct {
+		name       string
+		containers []*Container
+		repository string
+		project    string
+		expected   []*Container
+	}{
+		{name: "repository", containers: []*Container{api, worker, proxy}, repository: "ghcr.io/acme/api", expected: []*Container{api}},
+		{name: "repository without registry", containers: []*Container{api, worker, proxy}, repository: "acme/worker", expected: []*Container{worker}},
+		{name: "project name", containers: []*Container{api, worker, proxy}, project: "api", expected: []*Container{api}},
+		{name: "single container", containers: []*Container{proxy}, project: "api", expected: []*Container{proxy}},
+		{name: "no match", containers: []*Container{worker, proxy}, project: "api", expected: []*Container{}},
+		{name: "single container of another repository", containers: []*Container{proxy}, repository: "ghcr.io/acme/api", expected: []*Container{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Match(tt.containers, tt.repository, tt.project); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Match() = %v; want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		quantity string
+		expected int64
+		invalid  bool
+	}{
+		{quantity: "256Mi", expected: 256 << 20},
+		{quantity: "1Gi", expected: 1 << 30},
+		{quantity: "1.5Gi", expected: 3 << 29},
+		{quantity: "500M", expected: 500_000_000},
+		{quantity: "1G", expected: 1_000_000_000},
+		{quantity: "128974848", expected: 128974848},
+		{quantity: "129e6", expected: 129_000_000},
+		{quantity: "1500m", expected: 2},
+		{quantity: "Mi", invalid: true},
+		{quantity: "", invalid: true},
+		{quantity: "-1Gi", invalid: true},
+		{quantity: "lots", invalid: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseQuantity(tt.quantity)
+		if tt.invalid {
+			if err == nil {
+				t.Errorf("ParseQuantity(%q) = %d; want an error", tt.quantity, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.expected {
+			t.Errorf("ParseQuantity(%q) = %d, %v; want %d", tt.quantity, got, err, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+// Package layerorder detects instructions that are ordered so that the dependency installation of a stage
+// can't be cached across builds, and reorders them.
+package layerorder
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+)
+
+// InstallRegex matches commands that install nodejs dependencies
+var InstallRegex = regexp.MustCompile(`\b(npm\s+(ci|install|i)|yarn\s+install|pnpm\s+(install|i))\b|\byarn\s*($|&&|;|--)`)
+
+var (
+	// splits a shell command into the commands it runs
+	commandSeparatorRegex = regexp.MustCompile(`&&|\|\||;|\n`)
+	// matches the variables assigned at the start of a command, eg- "NODE_ENV=production npm ci"
+	assignmentRegex = regexp.MustCompile(`^(\w+=\S*\s+)+`)
+	// matches commands that can run along with an installation without needing the project's source code
+	setupRegex = regexp.MustCompile(`^((npm|yarn|pnpm)\s+(cache|config|set|store|prune)\b|corepack\b|rm\s|set\s|apk\s|apt-get\s|apt\s|yum\s|dnf\s|true$)`)
+	// matches sources of ADD that are fetched rather than copied from the build context
+	remoteSourceRegex = regexp.MustCompile(`^(https?://|git@|git://)`)
+)
+
+// Manifests are the files package managers read to install dependencies. They change much less often
+// than the source code, so they're copied on their own before installing.
+var Manifests = []string{
+	"package.json",
+	"package-lock.json",
+	"npm-shrinkwrap.json",
+	"yarn.lock",
+	"pnpm-lock.yaml",
+	"bun.lock",
+	"bun.lockb",
+	".npmrc",
+	".yarnrc",
+	".pnpmfile.cjs",
+	// patch-package applies the patches after installing
+	"patches",
+}
+
+// IsManifest returns true if the source of a COPY only refers to files needed to install dependencies,
+// eg- "package*.json"
+func IsManifest(src string) bool {
+	name := path.Base(path.Clean(src))
+	for _, m := range Manifests {
+		if ok, _ := path.Match(name, m); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Issue is a COPY or ADD of files that change more often than the dependencies, placed before the
+// installation of dependencies. Any change to the copied files installs the dependencies again.
+type Issue struct {
+	Stage *dockerfile.Stage
+	Copy  *dockerfile.Instruction
+	// Install is the first installation of dependencies after Copy
+	Install *dockerfile.Instruction
+	// Broad is true if Copy copies the root of the build context
+	Broad bool
+}
+
+// Find returns the first issue of every stage
+func Find(d *dockerfile.Dockerfile) []*Issue {
+	issues := []*Issue{}
+	for _, stage := range d.GetStages() {
+		var issue *Issue
+		for _, inst := range stage.Instructions() {
+			if issue == nil && isCopyFromContext(inst) && !copiesOnlyManifests(inst) {
+				issue = &Issue{Stage: stage, Copy: inst, Broad: copiesEverything(inst)}
+				continue
+			}
+			if issue != nil && IsInstall(inst) {
+				issue.Install = inst
+				issues = append(issues, issue)
+				break
+			}
+		}
+	}
+	return issues
+}
+
+// IsInstall returns true if the instruction is a RUN that installs dependencies
+func IsInstall(inst *dockerfile.Instruction) bool {
+	return inst.Cmd() == dockerfile.CmdRun && InstallRegex.MatchString(inst.Command())
+}
+
+// Result is the outcome of reordering the instructions of an issue
+type Result struct {
+	Issue *Issue
+	// CopyLine is the line Copy starts on in the reordered Dockerfile, 0 if it wasn't moved
+	CopyLine int
+	// InstallLine is the line Install starts on in the reordered Dockerfile
+	InstallLine int
+	// ManifestsCopy is the COPY added before Install to copy the manifests, empty if Copy was narrow
+	ManifestsCopy string
+	// Reason explains why the instructions weren't reordered
+	Reason string
+}
+
+// Reordered returns true if Copy was moved after Install
+func (r *Result) Reordered() bool {
+	return r.Reason == ""
+}
+
+// Reorder moves the copies of every issue after the installation of dependencies. Broad copies are
+// preceded by a copy of the manifests, which must be the ones found at the root of the build context.
+// d isn't modified, the reordered Dockerfile is returned along with the result of every issue.
+func Reorder(d *dockerfile.Dockerfile, manifests []string) (*dockerfile.Dockerfile, []*Result, error) {
+	reordered, err := dockerfile.NewDockerfile(d.Raw())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	issues := Find(d)
+	results := make([]*Result, len(issues))
+	for i, issue := range issues {
+		results[i] = &Result{Issue: issue, InstallLine: issue.Install.StartLine(), Reason: unsafeReason(issue, manifests)}
+	}
+
+	// reorder the last stage first, so that the lines of the earlier ones stay the same
+	for i := len(results) - 1; i >= 0; i-- {
+		r := results[i]
+		if !r.Reordered() {
+			continue
+		}
+		if err := reorder(reordered, r, manifests); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// the manifests copies added to earlier stages shift the lines of the later ones
+	shift := 0
+	for _, r := range results {
+		if r.CopyLine != 0 {
+			r.CopyLine += shift
+		}
+		r.InstallLine += shift
+		if r.ManifestsCopy != "" {
+			shift++
+		}
+	}
+	return reordered, results, nil
+}
+
+// reorder moves the copy of the issue after the installation in d
+func reorder(d *dockerfile.Dockerfile, r *Result, manifests []string) error {
+	issue := r.Issue
+	cp := instructionAt(d, issue.Copy.StartLine())
+	install := instructionAt(d, issue.Install.StartLine())
+	if cp == nil || install == nil {
+		return fmt.Errorf("instructions on lines %d and %d not found", issue.Copy.StartLine(), issue.Install.StartLine())
+	}
+	// the comments above the copy are moved along with it
+	above := issue.Copy.StartLine() - 1 - commentsAbove(d, cp)
+
+	copyLines := cp.EndLine() - cp.StartLine() + 1
+	installLine, err := d.MoveAfter(cp, install.EndLine())
+	if err != nil {
+		return err
+	}
+	r.CopyLine = installLine
+	r.InstallLine = install.StartLine() - copyLines - (issue.Copy.StartLine() - 1 - above)
+	if !issue.Broad {
+		return nil
+	}
+
+	r.ManifestsCopy = manifestsCopy(issue.Copy, manifests)
+	if err := d.InsertAfter(above, r.ManifestsCopy); err != nil {
+		return err
+	}
+	r.CopyLine++
+	r.InstallLine++
+	return nil
+}
+
+// unsafeReason returns why moving the copy of the issue after the installation could change the image
+// or break the build, empty if it's safe to move
+func unsafeReason(issue *Issue, manifests []string) string {
+	cp := issue.Copy
+	if len(cp.Heredocs()) > 0 {
+		return "it copies here-documents"
+	}
+	if issue.Broad {
+		if len(cp.Sources()) > 1 {
+			return "it copies other files along with the entire build context"
+		}
+		if !contains(manifests, "package.json") {
+			return "there's no package.json at the root of the build context"
+		}
+		if strings.ContainsAny(cp.Args()[len(cp.Args())-1], " \t") {
+			return "its destination contains whitespace"
+		}
+	} else {
+		for _, src := range cp.Sources() {
+			if IsManifest(src) {
+				return "it copies the files needed to install dependencies along with others"
+			}
+		}
+	}
+	if reason := installReason(issue.Install); reason != "" {
+		return reason
+	}
+
+	between := false
+	for _, inst := range issue.Stage.Instructions() {
+		switch {
+		case inst == cp:
+			between = true
+		case inst == issue.Install:
+			return ""
+		case !between:
+		case inst.Cmd() == dockerfile.CmdRun:
+			return fmt.Sprintf("the RUN instruction on line %d may need the copied files", inst.StartLine())
+		case inst.Cmd() == dockerfile.CmdCopy || inst.Cmd() == dockerfile.CmdAdd:
+			return fmt.Sprintf("it would overwrite the files copied on line %d", inst.StartLine())
+		case inst.Cmd() == dockerfile.CmdWorkdir:
+			return fmt.Sprintf("the WORKDIR on line %d would change where the files are copied to", inst.StartLine())
+		}
+	}
+	return ""
+}
+
+// installReason returns why the installation may need the source code, eg- because it also builds it
+func installReason(install *dockerfile.Instruction) string {
+	if install.IsJSONForm() || len(install.Heredocs()) > 0 {
+		return fmt.Sprintf("the RUN instruction on line %d isn't a plain shell command", install.StartLine())
+	}
+	for _, command := range commandSeparatorRegex.Split(install.Command(), -1) {
+		command = strings.TrimSpace(assignmentRegex.ReplaceAllString(strings.TrimSpace(command), ""))
+		if command == "" || InstallRegex.MatchString(command) || setupRegex.MatchString(command) {
+			continue
+		}
+		return fmt.Sprintf("the RUN instruction on line %d also runs '%s', which may need the copied files", install.StartLine(), command)
+	}
+	return ""
+}
+
+// manifestsCopy returns a COPY of the manifests to the destination of a broad copy, with the same flags
+func manifestsCopy(broad *dockerfile.Instruction, manifests []string) string {
+	args := broad.Args()
+	dest := args[len(args)-1]
+	if len(manifests) > 1 && !strings.HasSuffix(dest, "/") {
+		dest += "/"
+	}
+	parts := append([]string{dockerfile.CmdCopy}, broad.Flags()...)
+	parts = append(parts, manifests...)
+	return strings.Join(append(parts, dest), " ")
+}
+
+// EstimateInstallTime returns a rough estimate of how long installing the given number of direct
+// dependencies takes without a cache, for reporting the time saved by caching the installation
+func EstimateInstallTime(dependencies int) time.Duration {
+	return 10*time.Second + time.Duration(dependencies)*time.Second
+}
+
+// isCopyFromContext returns true if the instruction copies files from the build context
+func isCopyFromContext(inst *dockerfile.Instruction) bool {
+	if inst.Cmd() != dockerfile.CmdCopy && inst.Cmd() != dockerfile.CmdAdd {
+		return false
+	}
+	if _, fromStage := inst.Flag("from"); fromStage {
+		return false
+	}
+	for _, src := range inst.Sources() {
+		if !remoteSourceRegex.MatchString(src) {
+			return true
+		}
+	}
+	return false
+}
+
+// copiesOnlyManifests returns true if a COPY or ADD instruction only copies manifests
+func copiesOnlyManifests(inst *dockerfile.Instruction) bool {
+	for _, src := range inst.Sources() {
+		if !IsManifest(src) {
+			return false
+		}
+	}
+	return true
+}
+
+// copiesEverything returns true if a COPY or ADD instruction copies the root of the build context
+func copiesEverything(inst *dockerfile.Instruction) bool {
+	for _, src := range inst.Sources() {
+		if src == "." || src == "./" || src == "/" || src == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// commentsAbove returns the number of comment lines right above the instruction
+func commentsAbove(d *dockerfile.Dockerfile, inst *dockerfile.Instruction) int {
+	lines := strings.Split(d.Raw(), dockerfile.Linebreak)
+	n := 0
+	for i := inst.StartLine() - 2; i >= 0 && strings.HasPrefix(strings.TrimSpace(lines[i]), "#"); i-- {
+		n++
+	}
+	return n
+}
+
+func instructionAt(d *dockerfile.Dockerfile, line int) *dockerfile.Instruction {
+	for _, stage := range d.GetStages() {
+		for _, inst := range stage.Instructions() {
+			if inst.StartLine() == line {
+				return inst
+			}
+		}
+	}
+	return nil
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
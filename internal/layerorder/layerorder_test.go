@@ -0,0 +1,126 @@
+package layerorder
+
+import (
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+)
+
+func TestIsManifest(t *testing.T) {
+	tests := map[string]bool{
+		"package.json":     true,
+		"package*.json":    true,
+		"./yarn.lock":      true,
+		"patches/":         true,
+		"src":              false,
+		"tsconfig.json":    false,
+		".":                false,
+		"app/package.json": true,
+	}
+	for src, expected := range tests {
+		if got := IsManifest(src); got != expected {
+			t.Errorf("IsManifest(%q) = %v, want %v", src, got, expected)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	d, err := dockerfile.NewDockerfile(`FROM node:22 AS build
+WORKDIR /app
+COPY package*.json ./
+COPY src ./src
+RUN npm ci
+
+FROM node:22 AS deps
+COPY package.json ./
+RUN npm ci
+COPY . .
+
+FROM node:22-alpine
+COPY --from=build /app /app
+ADD https://example.com/app.tar.gz /tmp/
+RUN npm ci
+`)
+	if err != nil {
+		t.Fatalf("failed to parse dockerfile: %v", err)
+	}
+	issues := Find(d)
+	if len(issues) != 1 {
+		t.Fatalf("expected 1 issue, got %d", len(issues))
+	}
+	if issues[0].Copy.StartLine() != 4 || issues[0].Install.StartLine() != 5 || issues[0].Broad {
+		t.Errorf("expected a narrow copy on line 4 before the install on line 5, got %+v", issues[0])
+	}
+}
+
+func TestReorder(t *testing.T) {
+	manifests := []string{"package.json", "package-lock.json"}
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		// reasons are the expected reasons of every result, empty if it was reordered
+		reasons []string
+		// lines are the expected copy and install lines of every result
+		lines [][2]int
+	}{
+		{
+			name:     "broad copy",
+			input:    "FROM node:22\nWORKDIR /app\n# the app\nCOPY --chown=node:node . .\nENV NODE_ENV=production\nRUN npm ci --omit=dev && npm cache clean --force\nCMD [\"node\", \"index.js\"]\n",
+			expected: "FROM node:22\nWORKDIR /app\nCOPY --chown=node:node package.json package-lock.json ./\nENV NODE_ENV=production\nRUN npm ci --omit=dev && npm cache clean --force\n# the app\nCOPY --chown=node:node . .\nCMD [\"node\", \"index.js\"]\n",
+			reasons:  []string{""},
+			lines:    [][2]int{{7, 5}},
+		},
+		{
+			name:     "narrow copy in every stage",
+			input:    "FROM node:22 AS build\nCOPY package.json ./\nCOPY tsconfig.json src/ ./\nRUN npm ci\nRUN npm run build\n\nFROM node:22\nCOPY . .\nRUN npm ci\n",
+			expected: "FROM node:22 AS build\nCOPY package.json ./\nRUN npm ci\nCOPY tsconfig.json src/ ./\nRUN npm run build\n\nFROM node:22\nCOPY package.json package-lock.json ./\nRUN npm ci\nCOPY . .\n",
+			reasons:  []string{"", ""},
+			lines:    [][2]int{{4, 3}, {10, 9}},
+		},
+		{
+			name:     "install that builds",
+			input:    "FROM node:22\nCOPY . .\nRUN npm ci && npm run build\n",
+			expected: "FROM node:22\nCOPY . .\nRUN npm ci && npm run build\n",
+			reasons:  []string{"the RUN instruction on line 3 also runs 'npm run build', which may need the copied files"},
+			lines:    [][2]int{{0, 3}},
+		},
+		{
+			name:     "copy that's overwritten",
+			input:    "FROM node:22\nCOPY . .\nCOPY config.prod.json config.json\nRUN npm ci\n",
+			expected: "FROM node:22\nCOPY . .\nCOPY config.prod.json config.json\nRUN npm ci\n",
+			reasons:  []string{"it would overwrite the files copied on line 3"},
+			lines:    [][2]int{{0, 4}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := dockerfile.NewDockerfile(tt.input)
+			if err != nil {
+				t.Fatalf("failed to parse dockerfile: %v", err)
+			}
+			reordered, results, err := Reorder(d, manifests)
+			if err != nil {
+				t.Fatalf("Reorder() error = %v", err)
+			}
+			if d.Raw() != tt.input {
+				t.Errorf("expected the original Dockerfile to be left as it is")
+			}
+			if reordered.Raw() != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, reordered.Raw())
+			}
+			if len(results) != len(tt.reasons) {
+				t.Fatalf("expected %d results, got %d", len(tt.reasons), len(results))
+			}
+			for i, r := range results {
+				if r.Reason != tt.reasons[i] {
+					t.Errorf("expected reason %q, got %q", tt.reasons[i], r.Reason)
+				}
+				if lines := [2]int{r.CopyLine, r.InstallLine}; lines != tt.lines[i] {
+					t.Errorf("expected copy and install on lines %v, got %v", tt.lines[i], lines)
+				}
+			}
+		})
+	}
+}
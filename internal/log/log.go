@@ -1,52 +1,227 @@
 package log
 
 import (
-	"log"
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/fatih/color"
 )
 
-type Logger struct {
-	debugEnabled bool
-	logger       *log.Logger
+// Level is the severity of a message, messages below the level of a logger aren't written
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{LevelDebug: "debug", LevelInfo: "info", LevelWarn: "warn", LevelError: "error"}
+
+func (l Level) String() string {
+	return levelNames[l]
 }
 
-// NewLogger creates a logger. If debug = true, debug messages are printed.
-func NewLogger(debug bool) *Logger {
-	return &Logger{
-		debugEnabled: debug,
-		logger:       log.New(os.Stdout, "", 0),
+// ParseLevel parses debug, info, warn or error
+func ParseLevel(s string) (Level, error) {
+	for level, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return level, nil
+		}
 	}
+	return 0, fmt.Errorf("invalid log level %q, must be one of debug, info, warn or error", s)
 }
 
-func (l *Logger) Debug(msg string, data map[string]string) {
-	if !l.debugEnabled {
+const (
+	// FormatText writes messages for people, in color on the console
+	FormatText = "text"
+	// FormatJSON writes every message as a JSON object on its own line, eg- to ingest the logs of CI runs
+	FormatJSON = "json"
+)
+
+// Options configure the loggers
+type Options struct {
+	Level  Level
+	Format string
+	// File receives every message as well, without colors, eg- a log file kept next to the results of a CI run
+	File io.Writer
+}
+
+var (
+	options = Options{Level: LevelInfo, Format: FormatText}
+
+	// secrets are redacted from every message, along with the values matching secretPatterns
+	secrets   []string
+	secretsMu sync.RWMutex
+)
+
+// secretPatterns match API keys and tokens that end up in messages, eg- in the debug dumps of prompts or requests
+var secretPatterns = []*regexp.Regexp{
+	// OpenAI and Anthropic API keys
+	regexp.MustCompile(`sk-[A-Za-z0-9_-]{16,}`),
+	// GitHub tokens
+	regexp.MustCompile(`gh[pousr]_[A-Za-z0-9]{20,}`),
+	regexp.MustCompile(`github_pat_[A-Za-z0-9_]{20,}`),
+	// GitLab personal access tokens
+	regexp.MustCompile(`glpat-[A-Za-z0-9_-]{20,}`),
+	// AWS access key IDs
+	regexp.MustCompile(`AKIA[0-9A-Z]{16}`),
+	regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]{8,}`),
+}
+
+const redacted = "[REDACTED]"
+
+// Configure sets the options of the loggers created afterwards
+func Configure(opts Options) {
+	options = opts
+}
+
+// AddSecret makes every logger redact a value, eg- the API key read from the environment
+func AddSecret(secret string) {
+	// short values would redact ordinary words
+	if len(secret) < 8 {
 		return
 	}
-	l.logger.Printf("[DEBUG] %s\n", msg)
-	for k, v := range data {
-		l.logger.Printf("    %s=%s", k, v)
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	secrets = append(secrets, secret)
+}
+
+// Redact replaces the secrets in s, the ones added with AddSecret and values that look like API keys or tokens
+func Redact(s string) string {
+	secretsMu.RLock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	secretsMu.RUnlock()
+	for _, p := range secretPatterns {
+		if p.NumSubexp() > 0 {
+			s = p.ReplaceAllString(s, "${1}"+redacted)
+		} else {
+			s = p.ReplaceAllString(s, redacted)
+		}
+	}
+	return s
+}
+
+// onFatal are called with the message of a fatal error before the process exits
+var onFatal []func(msg string)
+
+// OnFatal registers a function that is called with the message of a fatal error before the process exits,
+// eg- to flush output that would otherwise be lost. Functions are called in the order they were registered.
+func OnFatal(fn func(msg string)) {
+	onFatal = append(onFatal, fn)
+}
+
+type Logger struct {
+	level  Level
+	format string
+	file   io.Writer
+}
+
+// NewLogger creates a logger with the configured options. If debug = true, debug messages are printed
+// whatever the configured level is.
+func NewLogger(debug bool) *Logger {
+	l := &Logger{level: options.Level, format: options.Format, file: options.File}
+	if debug {
+		l.level = LevelDebug
 	}
+	return l
+}
+
+func (l *Logger) Debug(msg string, data map[string]string) {
+	l.write(LevelDebug, 0, msg, data)
 }
 
 func (l *Logger) Infof(format string, a ...any) {
-	l.printf(color.FgGreen, format, a...)
+	l.write(LevelInfo, color.FgGreen, fmt.Sprintf(format, a...), nil)
 }
 
 func (l *Logger) Warnf(format string, a ...any) {
-	l.printf(color.FgYellow, format, a...)
+	l.write(LevelWarn, color.FgYellow, fmt.Sprintf(format, a...), nil)
 }
 
 func (l *Logger) Errorf(format string, a ...any) {
-	l.printf(color.FgRed, format, a...)
+	l.write(LevelError, color.FgRed, fmt.Sprintf(format, a...), nil)
 }
 
 func (l *Logger) Fatalf(format string, a ...any) {
 	l.Errorf(format, a...)
+	msg := strings.TrimSpace(Redact(fmt.Sprintf(format, a...)))
+	for _, fn := range onFatal {
+		fn(msg)
+	}
 	os.Exit(1)
 }
 
-func (l *Logger) printf(c color.Attribute, format string, a ...any) {
-	color.New(c).Printf(format+"\n", a...)
+// write writes a message to the console, which is color.Output so that it follows --output, and to the file
+func (l *Logger) write(level Level, c color.Attribute, msg string, data map[string]string) {
+	if level < l.level {
+		return
+	}
+	msg = Redact(msg)
+	if data != nil {
+		redactedData := make(map[string]string, len(data))
+		for k, v := range data {
+			redactedData[k] = Redact(v)
+		}
+		data = redactedData
+	}
+
+	if l.format == FormatJSON {
+		record := jsonRecord(level, msg, data)
+		fmt.Fprintln(color.Output, record)
+		if l.file != nil {
+			fmt.Fprintln(l.file, record)
+		}
+		return
+	}
+
+	text := msg
+	if level == LevelDebug {
+		text = "[DEBUG] " + msg + dataText(data)
+		fmt.Fprintln(color.Output, text)
+	} else {
+		color.New(c).Fprintln(color.Output, text)
+	}
+	if l.file != nil {
+		// the blank lines separating sections on the console are left out
+		fmt.Fprintf(l.file, "%s %-5s %s\n", time.Now().Format(time.RFC3339), strings.ToUpper(level.String()), strings.TrimSpace(text))
+	}
+}
+
+// dataText lists the data of a debug message on indented lines, sorted by key
+func dataText(data map[string]string) string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(fmt.Sprintf("\n    %s=%s", k, data[k]))
+	}
+	return sb.String()
+}
+
+func jsonRecord(level Level, msg string, data map[string]string) string {
+	record := struct {
+		Time  string            `json:"time"`
+		Level string            `json:"level"`
+		Msg   string            `json:"msg"`
+		Data  map[string]string `json:"data,omitempty"`
+	}{time.Now().Format(time.RFC3339Nano), level.String(), strings.TrimSpace(msg), data}
+	content, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Sprintf(`{"level":"error","msg":%q}`, err.Error())
+	}
+	return string(content)
 }
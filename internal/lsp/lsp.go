@@ -0,0 +1,687 @@
+// Package lsp implements a Language Server Protocol server that editors run over stdio.
+// Dockerfiles are analyzed with the static rules as they're edited and the findings are published as diagnostics.
+// Code actions offer the changes the rules make as quick fixes, and optimizing the Dockerfile with the AI on demand.
+//
+// The server keeps the documents the editor has open, the analysis and optimization of a document is left to the
+// functions it's created with, so that it doesn't depend on how projects are loaded.
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"github.com/duaraghav8/dockershrink/internal/diff"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+// CommandOptimize optimizes the Dockerfile whose URI is its argument with the AI, and applies the result in the editor
+const CommandOptimize = "dockershrink.optimize"
+
+// DefaultDelay is how long the server waits after a change before analyzing the document, so that
+// a Dockerfile isn't analyzed again on every key stroke
+const DefaultDelay = 300 * time.Millisecond
+
+// source is the name diagnostics are reported under
+const source = "dockershrink"
+
+// File is a Dockerfile opened in the editor
+type File struct {
+	// URI is the URI of the document in the editor, eg- file:///home/me/project/Dockerfile
+	URI string
+	// Path is the absolute path of the file
+	Path string
+	// Root is the workspace folder the file is in, empty if it isn't in one
+	Root string
+	// Content is the text of the document, which may not be saved yet
+	Content string
+}
+
+// AnalyzeFunc runs the static rules on the Dockerfile and the project it builds
+type AnalyzeFunc func(ctx context.Context, f *File) ([]*models.Finding, error)
+
+// OptimizeFunc returns the optimized Dockerfile. Only the static rules optimize it unless withAI is set.
+type OptimizeFunc func(ctx context.Context, f *File, withAI bool) (string, error)
+
+// Server answers the requests of an editor until it exits or the connection is closed
+type Server struct {
+	analyze  AnalyzeFunc
+	optimize OptimizeFunc
+	// Delay is how long to wait after a change before analyzing the document
+	Delay time.Duration
+
+	ctx   context.Context
+	out   io.Writer
+	outMu sync.Mutex
+	// background are the analyses and requests being answered, Serve waits for them before returning
+	background sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+	roots  []string
+	docs   map[string]*document
+	nextID int
+}
+
+type document struct {
+	file File
+	// version is incremented by the editor on every change
+	version int
+	// timer analyzes the document once it hasn't changed for the server's Delay
+	timer *time.Timer
+	// findings are those of the last analysis, which was of the version analyzed
+	findings []*models.Finding
+	analyzed int
+	// fixed is the document optimized by the static rules, which was computed for the version fixedVersion
+	fixed        *string
+	fixedVersion int
+}
+
+// NewServer returns a server that analyzes Dockerfiles with analyze and optimizes them with optimize
+func NewServer(analyze AnalyzeFunc, optimize OptimizeFunc) *Server {
+	return &Server{analyze: analyze, optimize: optimize, Delay: DefaultDelay, docs: map[string]*document{}}
+}
+
+// Serve reads the messages of the editor from in and writes the server's messages to out.
+// It returns once the editor sends the exit notification or closes in.
+func (s *Server) Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	s.ctx, s.out = ctx, out
+	defer func() {
+		s.mu.Lock()
+		s.closed = true
+		for _, d := range s.docs {
+			if d.timer != nil {
+				d.timer.Stop()
+			}
+		}
+		s.mu.Unlock()
+		cancel()
+		s.background.Wait()
+	}()
+
+	r := bufio.NewReader(in)
+	for {
+		content, err := readMessage(r)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read message: %w", err)
+		}
+		var m message
+		if err := json.Unmarshal(content, &m); err != nil {
+			s.answer(json.RawMessage("null"), nil, &responseError{Code: codeParseError, Message: err.Error()})
+			continue
+		}
+		if m.Method == "exit" {
+			return nil
+		}
+		s.handle(&m)
+	}
+}
+
+func (s *Server) handle(m *message) {
+	switch m.Method {
+	case "":
+		// a response to a request of the server, eg- workspace/applyEdit, nothing waits for it
+	case "initialize":
+		result, err := s.initialize(m.Params)
+		s.answer(m.ID, result, err)
+	case "shutdown":
+		s.answer(m.ID, nil, nil)
+	case "textDocument/didOpen":
+		s.didOpen(m.Params)
+	case "textDocument/didChange":
+		s.didChange(m.Params)
+	case "textDocument/didClose":
+		s.didClose(m.Params)
+	case "textDocument/codeAction":
+		// computing the quick fixes optimizes the Dockerfile, which mustn't hold up the changes sent meanwhile
+		s.start(func() {
+			result, err := s.codeActions(m.Params)
+			s.answer(m.ID, result, err)
+		})
+	case "workspace/executeCommand":
+		s.start(func() {
+			result, err := s.executeCommand(m.Params)
+			s.answer(m.ID, result, err)
+		})
+	default:
+		// notifications that aren't supported are ignored, eg- initialized or $/cancelRequest
+		if m.ID != nil {
+			s.answer(m.ID, nil, &responseError{Code: codeMethodNotFound, Message: "method not supported: " + m.Method})
+		}
+	}
+}
+
+func (s *Server) initialize(params json.RawMessage) (any, error) {
+	var p initializeParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	for _, f := range p.WorkspaceFolders {
+		if path, err := uriPath(f.URI); err == nil {
+			s.roots = append(s.roots, path)
+		}
+	}
+	if path, err := uriPath(p.RootURI); err == nil && len(s.roots) == 0 {
+		s.roots = append(s.roots, path)
+	}
+	s.mu.Unlock()
+
+	return &initializeResult{
+		Capabilities: serverCapabilities{
+			TextDocumentSync:       textDocumentSyncOptions{OpenClose: true, Change: 1},
+			CodeActionProvider:     codeActionOptions{CodeActionKinds: []string{"quickfix", "source.fixAll", "source"}},
+			ExecuteCommandProvider: executeCommandOptions{Commands: []string{CommandOptimize}},
+		},
+		ServerInfo: serverInfo{Name: "dockershrink"},
+	}, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) {
+	var p didOpenParams
+	if err := decode(params, &p); err != nil {
+		s.logError("Invalid textDocument/didOpen: %v", err)
+		return
+	}
+	path, err := uriPath(p.TextDocument.URI)
+	if err != nil {
+		// eg- an untitled document, which has no project to analyze
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if previous, ok := s.docs[p.TextDocument.URI]; ok && previous.timer != nil {
+		previous.timer.Stop()
+	}
+	d := &document{
+		file:    File{URI: p.TextDocument.URI, Path: path, Root: s.root(path), Content: p.TextDocument.Text},
+		version: p.TextDocument.Version,
+	}
+	s.docs[d.file.URI] = d
+	s.schedule(d, 0)
+}
+
+func (s *Server) didChange(params json.RawMessage) {
+	var p didChangeParams
+	if err := decode(params, &p); err != nil {
+		s.logError("Invalid textDocument/didChange: %v", err)
+		return
+	}
+	if len(p.ContentChanges) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return
+	}
+	// the whole document is sent on every change, since that's how the server asked to be synced
+	d.file.Content = p.ContentChanges[len(p.ContentChanges)-1].Text
+	d.version = p.TextDocument.Version
+	s.schedule(d, s.Delay)
+}
+
+func (s *Server) didClose(params json.RawMessage) {
+	var p didCloseParams
+	if err := decode(params, &p); err != nil {
+		s.logError("Invalid textDocument/didClose: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	d, ok := s.docs[p.TextDocument.URI]
+	if ok {
+		if d.timer != nil {
+			d.timer.Stop()
+		}
+		delete(s.docs, p.TextDocument.URI)
+	}
+	s.mu.Unlock()
+	if ok {
+		s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: p.TextDocument.URI, Version: d.version, Diagnostics: []diagnostic{}})
+	}
+}
+
+// schedule analyzes the document after delay, unless it changes before. It must be called with s.mu held.
+func (s *Server) schedule(d *document, delay time.Duration) {
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	file, version := d.file, d.version
+	d.timer = time.AfterFunc(delay, func() {
+		s.start(func() { s.publishDiagnostics(&file, version) })
+	})
+}
+
+// publishDiagnostics analyzes the given version of the document and publishes its findings,
+// unless the document changed in the meantime
+func (s *Server) publishDiagnostics(file *File, version int) {
+	findings, err := s.analyze(s.ctx, file)
+	diagnostics := []diagnostic{}
+	var syntaxErrs dockerfile.SyntaxErrors
+	switch {
+	case errors.As(err, &syntaxErrs):
+		findings = nil
+		diagnostics = syntaxDiagnostics(file.Content, syntaxErrs)
+	case err != nil:
+		if s.ctx.Err() == nil {
+			s.logError("Failed to analyze %s: %v", file.Path, err)
+		}
+		return
+	}
+
+	s.mu.Lock()
+	d, ok := s.docs[file.URI]
+	if !ok || d.version != version {
+		s.mu.Unlock()
+		return
+	}
+	d.findings, d.analyzed = findings, version
+	s.mu.Unlock()
+
+	lines := strings.Split(file.Content, "\n")
+	for _, f := range findings {
+		diagnostics = append(diagnostics, newDiagnostic(file, lines, f))
+	}
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: file.URI, Version: version, Diagnostics: diagnostics})
+}
+
+// codeActions returns the quick fixes of the findings in the requested range, the fix of every finding
+// of the Dockerfile at once and optimizing it with the AI
+func (s *Server) codeActions(params json.RawMessage) (any, error) {
+	var p codeActionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	actions := []*codeAction{}
+	s.mu.Lock()
+	d, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		s.mu.Unlock()
+		return actions, nil
+	}
+	file, version := d.file, d.version
+	var findings []*models.Finding
+	if d.analyzed == version {
+		findings = d.findings
+	}
+	s.mu.Unlock()
+
+	fixed, err := s.fixed(&file, version)
+	if err != nil {
+		if s.ctx.Err() != nil {
+			return nil, err
+		}
+		// the fixes of rule plugins and the AI are still offered
+		s.logError("Failed to fix %s: %v", file.Path, err)
+		fixed = file.Content
+	}
+
+	lines := strings.Split(file.Content, "\n")
+	for _, f := range findings {
+		line := diagnosticLine(&file, f)
+		if line < p.Range.Start.Line || line > p.Range.End.Line {
+			continue
+		}
+		var edits []textEdit
+		switch {
+		case f.Fix != nil:
+			edits = []textEdit{lineReplacement(lines, f.Fix)}
+		case samePath(f.Filepath, file.Path):
+			edits = hunkEdits(file.Content, fixed, f.Line)
+		}
+		if len(edits) == 0 {
+			continue
+		}
+		actions = append(actions, &codeAction{
+			Title:       "Fix: " + f.Title,
+			Kind:        "quickfix",
+			Diagnostics: []diagnostic{newDiagnostic(&file, lines, f)},
+			Edit:        &workspaceEdit{Changes: map[string][]textEdit{file.URI: edits}},
+		})
+	}
+	if edits := hunkEdits(file.Content, fixed, 0); len(edits) > 0 {
+		actions = append(actions, &codeAction{
+			Title: "Fix all dockershrink findings",
+			Kind:  "source.fixAll",
+			Edit:  &workspaceEdit{Changes: map[string][]textEdit{file.URI: edits}},
+		})
+	}
+	actions = append(actions, &codeAction{
+		Title:   "Optimize with AI",
+		Kind:    "source",
+		Command: &command{Title: "Optimize with AI", Command: CommandOptimize, Arguments: []any{file.URI}},
+	})
+	return actions, nil
+}
+
+// fixed returns the given version of the document optimized by the static rules.
+// It's computed once per version, since the editor asks for code actions whenever the cursor moves.
+func (s *Server) fixed(file *File, version int) (string, error) {
+	s.mu.Lock()
+	if d, ok := s.docs[file.URI]; ok && d.fixed != nil && d.fixedVersion == version {
+		defer s.mu.Unlock()
+		return *d.fixed, nil
+	}
+	s.mu.Unlock()
+
+	fixed, err := s.optimize(s.ctx, file, false)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	if d, ok := s.docs[file.URI]; ok && d.version == version {
+		d.fixed, d.fixedVersion = &fixed, version
+	}
+	s.mu.Unlock()
+	return fixed, nil
+}
+
+// executeCommand optimizes a Dockerfile with the AI and asks the editor to apply the changes
+func (s *Server) executeCommand(params json.RawMessage) (any, error) {
+	var p executeCommandParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	var uri string
+	if p.Command != CommandOptimize {
+		return nil, &responseError{Code: codeInvalidParams, Message: "unknown command: " + p.Command}
+	}
+	if len(p.Arguments) != 1 || json.Unmarshal(p.Arguments[0], &uri) != nil {
+		return nil, &responseError{Code: codeInvalidParams, Message: CommandOptimize + " takes the URI of the Dockerfile as its only argument"}
+	}
+
+	s.mu.Lock()
+	d, ok := s.docs[uri]
+	var file File
+	var version int
+	if ok {
+		file, version = d.file, d.version
+	}
+	s.mu.Unlock()
+	if !ok {
+		return nil, &responseError{Code: codeInvalidParams, Message: uri + " isn't open"}
+	}
+
+	s.notify("window/showMessage", messageParams{Type: messageInfo, Message: fmt.Sprintf("Optimizing %s with AI, this may take a minute.", filepath.Base(file.Path))})
+	optimized, err := s.optimize(s.ctx, &file, true)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to optimize %s: %w", filepath.Base(file.Path), err)
+	}
+	edits := hunkEdits(file.Content, optimized, 0)
+	if len(edits) == 0 {
+		s.notify("window/showMessage", messageParams{Type: messageInfo, Message: filepath.Base(file.Path) + " is already optimized."})
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	d, ok = s.docs[uri]
+	changed := !ok || d.version != version
+	s.mu.Unlock()
+	if changed {
+		return nil, fmt.Errorf("%s changed while it was being optimized, optimize it again", filepath.Base(file.Path))
+	}
+	s.request("workspace/applyEdit", applyWorkspaceEditParams{
+		Label: "Optimize with AI",
+		Edit:  workspaceEdit{Changes: map[string][]textEdit{uri: edits}},
+	})
+	return nil, nil
+}
+
+// start runs fn in the background, unless the server is exiting
+func (s *Server) start(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		fn()
+	}()
+}
+
+// root returns the workspace folder the path is in, the innermost one if they're nested.
+// It must be called with s.mu held.
+func (s *Server) root(path string) string {
+	root := ""
+	for _, r := range s.roots {
+		if rel, err := filepath.Rel(r, path); err == nil && !strings.HasPrefix(rel, "..") && len(r) > len(root) {
+			root = r
+		}
+	}
+	return root
+}
+
+func (s *Server) answer(id json.RawMessage, result any, err error) {
+	if err == nil {
+		s.write(&response{JSONRPC: "2.0", ID: id, Result: result})
+		return
+	}
+	var respErr *responseError
+	if !errors.As(err, &respErr) {
+		respErr = &responseError{Code: codeInternalError, Message: err.Error()}
+	}
+	s.write(&errorResponse{JSONRPC: "2.0", ID: id, Error: respErr})
+}
+
+func (s *Server) notify(method string, params any) {
+	s.write(&notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// request sends a request to the editor, its response is ignored
+func (s *Server) request(method string, params any) {
+	s.mu.Lock()
+	s.nextID++
+	id := s.nextID
+	s.mu.Unlock()
+	s.write(&request{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+}
+
+// logError writes an error to the editor's log of the server
+func (s *Server) logError(format string, a ...any) {
+	s.notify("window/logMessage", messageParams{Type: messageError, Message: fmt.Sprintf(format, a...)})
+}
+
+func (s *Server) write(v any) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	// the editor is gone if the message can't be written, which Serve finds out when reading from it
+	writeMessage(s.out, v)
+}
+
+func (e *responseError) Error() string {
+	return e.Message
+}
+
+func decode(params json.RawMessage, v any) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// uriPath returns the path of a file URI
+func uriPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("%s is not a file URI", uri)
+	}
+	return filepath.FromSlash(u.Path), nil
+}
+
+func samePath(a, b string) bool {
+	return filepath.Clean(a) == filepath.Clean(b)
+}
+
+// diagnosticLine returns the 0-based line a finding is reported on. Findings about the whole Dockerfile
+// and about other files of the project, eg- the .dockerignore, are reported on the first line.
+func diagnosticLine(file *File, f *models.Finding) int {
+	if f.Line == 0 || !samePath(f.Filepath, file.Path) {
+		return 0
+	}
+	return f.Line - 1
+}
+
+var severities = map[models.Severity]int{
+	models.SeverityHigh:   severityWarning,
+	models.SeverityMedium: severityWarning,
+	models.SeverityLow:    severityInformation,
+	models.SeverityInfo:   severityHint,
+}
+
+func newDiagnostic(file *File, lines []string, f *models.Finding) diagnostic {
+	message := f.Title
+	if f.Description != "" {
+		message += "\n\n" + f.Description
+	}
+	if !samePath(f.Filepath, file.Path) {
+		rel, err := filepath.Rel(filepath.Dir(file.Path), f.Filepath)
+		if err != nil {
+			rel = f.Filepath
+		}
+		message = rel + ": " + message
+	}
+	code := f.Code
+	if code == "" {
+		code = f.Rule
+	}
+	severity, ok := severities[f.Severity]
+	if !ok {
+		severity = severityInformation
+	}
+	return diagnostic{
+		Range:    lineRange(lines, diagnosticLine(file, f)),
+		Severity: severity,
+		Code:     code,
+		Source:   source,
+		Message:  message,
+	}
+}
+
+// syntaxDiagnostics reports the syntax errors of a Dockerfile, the errors that aren't tied to a line on the first one
+func syntaxDiagnostics(content string, errs dockerfile.SyntaxErrors) []diagnostic {
+	lines := strings.Split(content, "\n")
+	diagnostics := make([]diagnostic, 0, len(errs))
+	for _, e := range errs {
+		diagnostics = append(diagnostics, diagnostic{
+			Range:    lineRange(lines, max(e.Line-1, 0)),
+			Severity: severityError,
+			Source:   source,
+			Message:  e.Message,
+		})
+	}
+	return diagnostics
+}
+
+// lineRange returns the range of a whole line, or of the last line if the document is shorter
+func lineRange(lines []string, line int) lspRange {
+	line = min(line, len(lines)-1)
+	return lspRange{
+		Start: position{Line: line},
+		End:   position{Line: line, Character: utf16Len(lines[line])},
+	}
+}
+
+// lineStart returns the position of the start of a 0-based line, or the end of the document if it has fewer lines
+func lineStart(lines []string, line int) position {
+	if line < len(lines) {
+		return position{Line: line}
+	}
+	last := len(lines) - 1
+	return position{Line: last, Character: utf16Len(lines[last])}
+}
+
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// lineReplacement returns the edit of a fix of a rule plugin
+func lineReplacement(lines []string, fix *models.Fix) textEdit {
+	replacement := fix.Replacement
+	if !strings.HasSuffix(replacement, "\n") {
+		replacement += "\n"
+	}
+	return textEdit{
+		Range:   lspRange{Start: lineStart(lines, fix.Line-1), End: lineStart(lines, fix.EndLine)},
+		NewText: replacement,
+	}
+}
+
+// hunkEdits returns the edits that turn original into fixed. If line isn't 0, only the edits of
+// the 1-based line are returned, like the quick fixes of the SARIF report.
+func hunkEdits(original, fixed string, line int) []textEdit {
+	lines := strings.Split(original, "\n")
+	edits := []textEdit{}
+	for _, h := range diff.Hunks(diff.Lines(original, fixed), 0) {
+		// the first line after the changed lines of the original, or where the lines are inserted
+		start, end := h.AStart, h.AStart+h.ALen
+		if h.ALen == 0 {
+			start++
+			end++
+		}
+		if line > 0 && (line < start || line >= max(end, start+1)) {
+			continue
+		}
+		if line > 0 && h.ALen > 1 && h.ALen == h.BLen {
+			// every line of the hunk was replaced by one line, eg- consecutive instructions fixed by different
+			// rules, so only the replacement of the finding's line fixes it
+			deleted, inserted := h.ALines()[line-start], h.Ops[0]
+			for i, n := 0, 0; i < len(h.Ops); i++ {
+				if h.Ops[i].Kind == diff.OpInsert {
+					if n == line-start {
+						inserted = h.Ops[i]
+						break
+					}
+					n++
+				}
+			}
+			newText := inserted.Text
+			if !inserted.NoEOL {
+				newText += "\n"
+			}
+			if deleted != inserted.Text {
+				edits = append(edits, textEdit{
+					Range:   lspRange{Start: lineStart(lines, line-1), End: lineStart(lines, line)},
+					NewText: newText,
+				})
+			}
+			continue
+		}
+		var inserted strings.Builder
+		for _, op := range h.Ops {
+			if op.Kind == diff.OpInsert {
+				inserted.WriteString(op.Text)
+				if !op.NoEOL {
+					inserted.WriteString("\n")
+				}
+			}
+		}
+		edits = append(edits, textEdit{
+			Range:   lspRange{Start: lineStart(lines, start-1), End: lineStart(lines, end-1)},
+			NewText: inserted.String(),
+		})
+	}
+	return edits
+}
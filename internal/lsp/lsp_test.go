@@ -0,0 +1,351 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+// client talks to a server over pipes like an editor does
+type client struct {
+	t        *testing.T
+	in       *io.PipeWriter
+	messages chan map[string]any
+	done     chan error
+	nextID   int
+}
+
+func startServer(t *testing.T, s *Server) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &client{t: t, in: clientOut, messages: make(chan map[string]any, 100), done: make(chan error, 1)}
+	go func() {
+		c.done <- s.Serve(context.Background(), serverIn, serverOut)
+		serverOut.Close()
+	}()
+	go func() {
+		r := bufio.NewReader(clientIn)
+		for {
+			content, err := readMessage(r)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			var m map[string]any
+			if err := json.Unmarshal(content, &m); err != nil {
+				t.Errorf("the server sent invalid JSON: %s", content)
+			}
+			c.messages <- m
+		}
+	}()
+	t.Cleanup(func() { clientOut.Close() })
+	return c
+}
+
+func (c *client) notify(method string, params any) {
+	if err := writeMessage(c.in, &notification{JSONRPC: "2.0", Method: method, Params: params}); err != nil {
+		c.t.Fatalf("failed to send %s: %v", method, err)
+	}
+}
+
+// call sends a request and returns the response, along with the messages the server sent before it
+func (c *client) call(method string, params any) (map[string]any, []map[string]any) {
+	c.nextID++
+	if err := writeMessage(c.in, &request{JSONRPC: "2.0", ID: c.nextID, Method: method, Params: params}); err != nil {
+		c.t.Fatalf("failed to send %s: %v", method, err)
+	}
+	before := []map[string]any{}
+	for {
+		m := c.receive()
+		if m["method"] == nil && m["id"] == float64(c.nextID) {
+			return m, before
+		}
+		before = append(before, m)
+	}
+}
+
+// receive returns the next message of the server
+func (c *client) receive() map[string]any {
+	select {
+	case m, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("the server closed the connection")
+		}
+		return m
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the server")
+		return nil
+	}
+}
+
+// receiveMethod returns the next message of the server with the given method, skipping the others
+func (c *client) receiveMethod(method string) map[string]any {
+	for {
+		if m := c.receive(); m["method"] == method {
+			return m
+		}
+	}
+}
+
+// decodeAs converts a decoded JSON value to v
+func decodeAs(t *testing.T, value any, v any) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		t.Fatalf("unexpected message %s: %v", content, err)
+	}
+}
+
+// applyEdits applies non-overlapping edits of ASCII text, in any order
+func applyEdits(text string, edits []textEdit) string {
+	offset := func(p position) int {
+		lines := strings.SplitAfter(text, "\n")
+		o := 0
+		for _, l := range lines[:p.Line] {
+			o += len(l)
+		}
+		return o + p.Character
+	}
+	// the edits are applied from the last one, so that the offsets of the others stay the same
+	for i := 0; i < len(edits); i++ {
+		for j := i + 1; j < len(edits); j++ {
+			if offset(edits[j].Range.Start) > offset(edits[i].Range.Start) {
+				edits[i], edits[j] = edits[j], edits[i]
+			}
+		}
+	}
+	for _, e := range edits {
+		text = text[:offset(e.Range.Start)] + e.NewText + text[offset(e.Range.End):]
+	}
+	return text
+}
+
+func TestServer(t *testing.T) {
+	original := "FROM node:20\nWORKDIR /app\nADD . .\nRUN npm install\n"
+	fixed := "FROM node:20\nWORKDIR /app\nCOPY . .\nRUN npm ci\n"
+	optimized := "FROM node:20-slim\nWORKDIR /app\nCOPY . .\nRUN npm ci --omit=dev\n"
+
+	analyze := func(ctx context.Context, f *File) ([]*models.Finding, error) {
+		if f.Root != "/project" || f.Path != "/project/api/Dockerfile" {
+			t.Errorf("unexpected file %+v", f)
+		}
+		return []*models.Finding{
+			{Rule: "add-instead-of-copy", Code: "DS010", Severity: models.SeverityLow, Filepath: f.Path, Line: 3, Title: "Use COPY instead of ADD"},
+			{Rule: "plugin-rule", Severity: models.SeverityHigh, Filepath: f.Path, Line: 4, Title: "Use npm ci", Fix: &models.Fix{Line: 4, EndLine: 4, Replacement: "RUN npm ci"}},
+			{Rule: "create-dockerignore", Code: "DS001", Severity: models.SeverityMedium, Filepath: "/project/api/.dockerignore", Title: "Add a .dockerignore"},
+		}, nil
+	}
+	optimize := func(ctx context.Context, f *File, withAI bool) (string, error) {
+		if withAI {
+			return optimized, nil
+		}
+		return fixed, nil
+	}
+	s := NewServer(analyze, optimize)
+	s.Delay = 50 * time.Millisecond
+	c := startServer(t, s)
+
+	resp, _ := c.call("initialize", map[string]any{"workspaceFolders": []map[string]any{{"uri": "file:///project", "name": "project"}}})
+	var init initializeResult
+	decodeAs(t, resp["result"], &init)
+	if init.Capabilities.TextDocumentSync.Change != 1 || len(init.Capabilities.ExecuteCommandProvider.Commands) != 1 {
+		t.Errorf("unexpected capabilities %+v", init.Capabilities)
+	}
+	c.notify("initialized", map[string]any{})
+
+	uri := "file:///project/api/Dockerfile"
+	c.notify("textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": uri, "languageId": "dockerfile", "version": 1, "text": original}})
+	var published publishDiagnosticsParams
+	decodeAs(t, c.receiveMethod("textDocument/publishDiagnostics")["params"], &published)
+	if published.URI != uri || published.Version != 1 || len(published.Diagnostics) != 3 {
+		t.Fatalf("expected the 3 findings to be published, got %+v", published)
+	}
+	for i, expected := range []diagnostic{
+		{Range: lspRange{Start: position{Line: 2}, End: position{Line: 2, Character: 7}}, Severity: severityInformation, Code: "DS010", Source: source, Message: "Use COPY instead of ADD"},
+		{Range: lspRange{Start: position{Line: 3}, End: position{Line: 3, Character: 15}}, Severity: severityWarning, Code: "plugin-rule", Source: source, Message: "Use npm ci"},
+		{Range: lspRange{Start: position{Line: 0}, End: position{Line: 0, Character: 12}}, Severity: severityWarning, Code: "DS001", Source: source, Message: ".dockerignore: Add a .dockerignore"},
+	} {
+		if published.Diagnostics[i] != expected {
+			t.Errorf("expected diagnostic %+v, got %+v", expected, published.Diagnostics[i])
+		}
+	}
+
+	// the quick fixes of the findings on the requested lines, the fix of all of them and the AI
+	resp, _ = c.call("textDocument/codeAction", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"range":        lspRange{Start: position{Line: 2}, End: position{Line: 3}},
+		"context":      map[string]any{"diagnostics": []any{}},
+	})
+	var actions []*codeAction
+	decodeAs(t, resp["result"], &actions)
+	titles := []string{}
+	for _, a := range actions {
+		titles = append(titles, a.Kind+": "+a.Title)
+	}
+	expectedTitles := []string{"quickfix: Fix: Use COPY instead of ADD", "quickfix: Fix: Use npm ci", "source.fixAll: Fix all dockershrink findings", "source: Optimize with AI"}
+	if strings.Join(titles, ", ") != strings.Join(expectedTitles, ", ") {
+		t.Fatalf("expected code actions %v, got %v", expectedTitles, titles)
+	}
+	for i, expected := range []string{
+		"FROM node:20\nWORKDIR /app\nCOPY . .\nRUN npm install\n",
+		"FROM node:20\nWORKDIR /app\nADD . .\nRUN npm ci\n",
+		fixed,
+	} {
+		if got := applyEdits(original, actions[i].Edit.Changes[uri]); got != expected {
+			t.Errorf("expected %q to fix the Dockerfile into %q, got %q", actions[i].Title, expected, got)
+		}
+	}
+
+	// optimizing with the AI asks the editor to apply the changes
+	command := actions[3].Command
+	resp, before := c.call("workspace/executeCommand", map[string]any{"command": command.Command, "arguments": command.Arguments})
+	if resp["error"] != nil {
+		t.Fatalf("unexpected error %v", resp["error"])
+	}
+	var applied *applyWorkspaceEditParams
+	for _, m := range before {
+		if m["method"] == "workspace/applyEdit" {
+			decodeAs(t, m["params"], &applied)
+		}
+	}
+	if applied == nil {
+		t.Fatalf("expected the editor to be asked to apply the changes, got %v", before)
+	}
+	if got := applyEdits(original, applied.Edit.Changes[uri]); got != optimized {
+		t.Errorf("expected the AI's changes to be applied, got %q", got)
+	}
+
+	// only the last of quick changes is analyzed
+	c.notify("textDocument/didChange", map[string]any{"textDocument": map[string]any{"uri": uri, "version": 2}, "contentChanges": []any{map[string]any{"text": "FROM node:20\n"}}})
+	c.notify("textDocument/didChange", map[string]any{"textDocument": map[string]any{"uri": uri, "version": 3}, "contentChanges": []any{map[string]any{"text": fixed}}})
+	decodeAs(t, c.receiveMethod("textDocument/publishDiagnostics")["params"], &published)
+	if published.Version != 3 {
+		t.Errorf("expected the last version to be analyzed, got version %d", published.Version)
+	}
+
+	c.notify("textDocument/didClose", map[string]any{"textDocument": map[string]any{"uri": uri}})
+	decodeAs(t, c.receiveMethod("textDocument/publishDiagnostics")["params"], &published)
+	if len(published.Diagnostics) != 0 {
+		t.Errorf("expected the diagnostics of the closed document to be cleared, got %+v", published.Diagnostics)
+	}
+
+	resp, _ = c.call("textDocument/hover", map[string]any{})
+	if resp["error"] == nil {
+		t.Errorf("expected an unsupported method to fail, got %v", resp)
+	}
+	c.call("shutdown", nil)
+	c.notify("exit", nil)
+	select {
+	case err := <-c.done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the server to exit")
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	analyze := func(ctx context.Context, f *File) ([]*models.Finding, error) {
+		if strings.HasPrefix(f.Content, "FROM") {
+			return nil, errors.New("failed to read package.json")
+		}
+		return nil, dockerfile.SyntaxErrors{{Line: 2, Column: 1, Message: "unknown instruction: RNU"}, {Message: "no FROM instruction"}}
+	}
+	optimize := func(ctx context.Context, f *File, withAI bool) (string, error) {
+		return "", errors.New("chat requires an OpenAI API key")
+	}
+	c := startServer(t, NewServer(analyze, optimize))
+	c.call("initialize", map[string]any{"rootUri": "file:///project"})
+
+	c.notify("textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": "file:///project/Dockerfile", "version": 1, "text": "# base\nRNU x\n"}})
+	var published publishDiagnosticsParams
+	decodeAs(t, c.receiveMethod("textDocument/publishDiagnostics")["params"], &published)
+	if len(published.Diagnostics) != 2 {
+		t.Fatalf("expected the syntax errors to be published, got %+v", published)
+	}
+	if d := published.Diagnostics[0]; d.Severity != severityError || d.Range.Start.Line != 1 || d.Message != "unknown instruction: RNU" {
+		t.Errorf("unexpected diagnostic %+v", d)
+	}
+	if d := published.Diagnostics[1]; d.Range.Start.Line != 0 {
+		t.Errorf("expected the error without a line to be on the first one, got %+v", d)
+	}
+
+	// other errors are logged rather than published
+	c.notify("textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": "file:///project/api.Dockerfile", "version": 1, "text": "FROM node:20\n"}})
+	var logged messageParams
+	decodeAs(t, c.receiveMethod("window/logMessage")["params"], &logged)
+	if logged.Type != messageError || !strings.Contains(logged.Message, "failed to read package.json") {
+		t.Errorf("expected the error to be logged, got %+v", logged)
+	}
+
+	resp, _ := c.call("workspace/executeCommand", map[string]any{"command": CommandOptimize, "arguments": []string{"file:///project/api.Dockerfile"}})
+	if e, _ := resp["error"].(map[string]any); e == nil || !strings.Contains(e["message"].(string), "OpenAI API key") {
+		t.Errorf("expected the optimization to fail, got %v", resp)
+	}
+	resp, _ = c.call("workspace/executeCommand", map[string]any{"command": CommandOptimize, "arguments": []string{"file:///project/other/Dockerfile"}})
+	if e, _ := resp["error"].(map[string]any); e == nil || e["code"] != float64(codeInvalidParams) {
+		t.Errorf("expected a document that isn't open to be rejected, got %v", resp)
+	}
+}
+
+func TestHunkEdits(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		fixed    string
+		line     int
+		expected string
+	}{
+		{
+			name:     "every change",
+			original: "FROM node:20\nADD . .\nRUN npm install\n",
+			fixed:    "FROM node:20\nCOPY . .\nRUN npm ci\n",
+			expected: "FROM node:20\nCOPY . .\nRUN npm ci\n",
+		},
+		{
+			name:     "changes of a line",
+			original: "FROM node:20\nADD . .\nRUN npm install\n",
+			fixed:    "FROM node:20\nCOPY . .\nRUN npm ci\n",
+			line:     3,
+			expected: "FROM node:20\nADD . .\nRUN npm ci\n",
+		},
+		{
+			name:     "inserted lines",
+			original: "FROM node:20\nCMD [\"node\", \"index.js\"]\n",
+			fixed:    "FROM node:20\nUSER node\nCMD [\"node\", \"index.js\"]\n",
+			line:     2,
+			expected: "FROM node:20\nUSER node\nCMD [\"node\", \"index.js\"]\n",
+		},
+		{
+			name:     "no newline at the end",
+			original: "FROM node:20\nRUN npm install",
+			fixed:    "FROM node:20\nRUN npm ci\n",
+			expected: "FROM node:20\nRUN npm ci\n",
+		},
+		{
+			name:     "line without changes",
+			original: "FROM node:20\nADD . .\n",
+			fixed:    "FROM node:20\nCOPY . .\n",
+			line:     1,
+			expected: "FROM node:20\nADD . .\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyEdits(tt.original, hunkEdits(tt.original, tt.fixed, tt.line)); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+// Severity indicates how much a finding affects the Docker image
+type Severity string
+
+const (
+	SeverityInfo   Severity = "info"
+	SeverityLow    Severity = "low"
+	SeverityMedium Severity = "medium"
+	SeverityHigh   Severity = "high"
+)
+
+// Finding is an inefficiency detected in a project's Docker image definition
+type Finding struct {
+	Rule        string   `json:"rule"`
+	Code        string   `json:"code,omitempty"`
+	Severity    Severity `json:"severity"`
+	Filepath    string   `json:"filepath"`
+	Line        int      `json:"line,omitempty"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	// EstimatedSizeImpact is the estimated number of bytes that can be saved by fixing the finding.
+	// 0 means that the impact is unknown or the finding doesn't affect the size of the image.
+	EstimatedSizeImpact int64 `json:"estimated_size_impact,omitempty"`
+	// Fix is a change of the Dockerfile that fixes the finding, nil if there's none.
+	// Only the findings of rule plugins have one.
+	Fix *Fix `json:"fix,omitempty"`
+}
+
+// Fix replaces the lines of a Dockerfile from Line to EndLine, both included, with Replacement
+type Fix struct {
+	Line        int    `json:"line"`
+	EndLine     int    `json:"end_line"`
+	Replacement string `json:"replacement"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Goal is the aspect of a Docker image the user wants to optimize for
+type Goal string
+
+const (
+	GoalSize       Goal = "size"
+	GoalBuildSpeed Goal = "build-speed"
+	GoalSecurity   Goal = "security"
+	GoalAll        Goal = "all"
+)
+
+var Goals = []Goal{GoalSize, GoalBuildSpeed, GoalSecurity, GoalAll}
+
+// ParseGoal converts the given string into a Goal.
+// An error is returned if the string is not a known goal.
+func ParseGoal(s string) (Goal, error) {
+	for _, g := range Goals {
+		if string(g) == strings.ToLower(strings.TrimSpace(s)) {
+			return g, nil
+		}
+	}
+	names := make([]string, len(Goals))
+	for i, g := range Goals {
+		names[i] = string(g)
+	}
+	return "", fmt.Errorf("invalid goal %q, must be one of: %s", s, strings.Join(names, ", "))
+}
+
+// Includes returns true if optimizing for g also covers any of the given goals.
+// GoalAll includes every goal.
+func (g Goal) Includes(goals ...Goal) bool {
+	if g == GoalAll || g == "" {
+		return true
+	}
+	for _, other := range goals {
+		if g == other {
+			return true
+		}
+	}
+	return false
+}
@@ -6,4 +6,24 @@ type OptimizationAction struct {
 	Title       string `json:"title" jsonschema_description:"Title of the action taken"`
 	Description string `json:"description" jsonschema_description:"Description of the action taken"`
 	Line        int    `json:"line" jsonschema_description:"(Field is Optional) Line number in the Dockerfile where the action was taken"`
+	Risk        Risk   `json:"risk" jsonschema:"enum=cosmetic,enum=cache-impacting,enum=size-impacting,enum=behavior-changing" jsonschema_description:"How much the action can change the image or how it's built"`
+	// Explanation is added by dockershrink after the action is taken, it's not part of the LLM's output
+	Explanation *Explanation `json:"explanation,omitempty" jsonschema:"-"`
+}
+
+// Explanation tells why an action was taken and what it saves, so that users learn the practice behind it
+type Explanation struct {
+	// Principle is the practice the action follows, eg- "Separate build and runtime stages"
+	Principle string `json:"principle,omitempty"`
+	// Rationale is why following the principle makes the image better
+	Rationale string `json:"rationale,omitempty"`
+	// Docs are the titles of the sections of dockershrink's documentation about the principle, eg- "Build cache: Cache mounts"
+	Docs []string `json:"docs,omitempty"`
+	// Links point to the upstream documentation, eg- Docker's
+	Links []string `json:"links,omitempty"`
+	// EstimatedSizeImpact is the estimated change in the size of the image in bytes, negative if the action shrinks it.
+	// It's 0 if it wasn't measured, eg- when the action was taken together with others.
+	EstimatedSizeImpact int64 `json:"estimated_size_impact,omitempty"`
+	// BuildTimeImpact describes how the action changes the time builds take
+	BuildTimeImpact string `json:"build_time_impact,omitempty"`
 }
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Profile is a named preset of what to optimize the image for.
+// Each profile runs the rules and steers the LLM towards a single Goal.
+type Profile string
+
+const (
+	ProfileSize     Profile = "size"
+	ProfileSpeed    Profile = "speed"
+	ProfileBalanced Profile = "balanced"
+	ProfileSecurity Profile = "security"
+)
+
+var Profiles = []Profile{ProfileSize, ProfileSpeed, ProfileBalanced, ProfileSecurity}
+
+var profileGoals = map[Profile]Goal{
+	ProfileSize:     GoalSize,
+	ProfileSpeed:    GoalBuildSpeed,
+	ProfileBalanced: GoalAll,
+	ProfileSecurity: GoalSecurity,
+}
+
+// ParseProfile converts the given string into a Profile.
+// An error is returned if the string is not a known profile.
+func ParseProfile(s string) (Profile, error) {
+	for _, p := range Profiles {
+		if string(p) == strings.ToLower(strings.TrimSpace(s)) {
+			return p, nil
+		}
+	}
+	names := make([]string, len(Profiles))
+	for i, p := range Profiles {
+		names[i] = string(p)
+	}
+	return "", fmt.Errorf("invalid profile %q, must be one of: %s", s, strings.Join(names, ", "))
+}
+
+// Goal returns the goal the profile optimizes for
+func (p Profile) Goal() Goal {
+	return profileGoals[p]
+}
@@ -0,0 +1,16 @@
+package models
+
+import "testing"
+
+func TestProfile(t *testing.T) {
+	expected := map[string]Goal{"size": GoalSize, " Speed": GoalBuildSpeed, "balanced": GoalAll, "SECURITY": GoalSecurity}
+	for s, goal := range expected {
+		p, err := ParseProfile(s)
+		if err != nil || p.Goal() != goal {
+			t.Errorf("ParseProfile(%q).Goal() = %q, %v; want %q", s, p.Goal(), err, goal)
+		}
+	}
+	if _, err := ParseProfile("build-speed"); err == nil {
+		t.Errorf("expected an error for an unknown profile")
+	}
+}
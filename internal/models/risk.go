@@ -0,0 +1,67 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Risk classifies how much an optimization action can change the image or how it's built
+type Risk string
+
+const (
+	// RiskCosmetic changes neither the image nor how it's built, eg- comments or formatting
+	RiskCosmetic Risk = "cosmetic"
+	// RiskCache only changes how layers are cached or images are resolved, the image's content stays the same,
+	// eg- reordering instructions, adding cache mounts or pinning base images
+	RiskCache Risk = "cache-impacting"
+	// RiskSize removes files from the image that the application isn't expected to need,
+	// eg- excluding files from the build context or dev dependencies from the final stage
+	RiskSize Risk = "size-impacting"
+	// RiskBehavior can change how the application runs or how the image must be built,
+	// eg- replacing a base image, running as another user or requiring build secrets
+	RiskBehavior Risk = "behavior-changing"
+)
+
+// Risks are all the risk levels, from the safest to the riskiest
+var Risks = []Risk{RiskCosmetic, RiskCache, RiskSize, RiskBehavior}
+
+// ParseRisk converts the given string into a Risk. The level can be written without its suffix,
+// eg- "cache" for cache-impacting, and can be prefixed with "<=".
+// An error is returned if the string is not a known risk level.
+func ParseRisk(s string) (Risk, error) {
+	level := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), "<=")))
+	for _, r := range Risks {
+		if string(r) == level || strings.SplitN(string(r), "-", 2)[0] == level {
+			return r, nil
+		}
+	}
+	names := make([]string, len(Risks))
+	for i, r := range Risks {
+		names[i] = string(r)
+	}
+	return "", fmt.Errorf("invalid risk %q, must be one of: %s", s, strings.Join(names, ", "))
+}
+
+// level returns the position of the risk in Risks. Unknown risks are treated as behavior-changing.
+func (r Risk) level() int {
+	for i, known := range Risks {
+		if r == known {
+			return i
+		}
+	}
+	return len(Risks) - 1
+}
+
+// AtMost returns true if r is as safe as max or safer.
+// An empty max allows every risk.
+func (r Risk) AtMost(max Risk) bool {
+	if max == "" {
+		return true
+	}
+	return r.level() <= max.level()
+}
+
+// Normalize returns r if it's a known risk level, and RiskBehavior otherwise
+func (r Risk) Normalize() Risk {
+	return Risks[r.level()]
+}
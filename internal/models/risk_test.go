@@ -0,0 +1,20 @@
+package models
+
+import "testing"
+
+func TestRisk(t *testing.T) {
+	for _, s := range []string{"size", "<=size", " size-impacting", "SIZE"} {
+		if r, err := ParseRisk(s); err != nil || r != RiskSize {
+			t.Errorf("ParseRisk(%q) = %q, %v; want %q", s, r, err, RiskSize)
+		}
+	}
+	if _, err := ParseRisk("dangerous"); err == nil {
+		t.Errorf("expected an error for an unknown risk")
+	}
+	if !RiskCache.AtMost(RiskSize) || RiskBehavior.AtMost(RiskSize) || !RiskBehavior.AtMost("") {
+		t.Errorf("unexpected ordering of risks")
+	}
+	if Risk("unknown").Normalize() != RiskBehavior {
+		t.Errorf("expected unknown risks to be treated as behavior-changing")
+	}
+}
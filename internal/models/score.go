@@ -0,0 +1,20 @@
+package models
+
+// ScoreBreakdown grades an image definition as a whole and on each aspect the rules check
+type ScoreBreakdown struct {
+	// Grade is the letter grade of the overall score, from A to F
+	Grade string `json:"grade"`
+	// Size grades the findings of the rules that reduce the size of the image
+	Size AspectScore `json:"size"`
+	// Cache grades the findings of the rules that make builds reuse the layer cache
+	Cache AspectScore `json:"cache"`
+	// Security grades the findings of the rules that harden the image
+	Security AspectScore `json:"security"`
+}
+
+// AspectScore grades one aspect of the image definition from 0 to 100, like the overall score
+type AspectScore struct {
+	Score    int    `json:"score"`
+	Grade    string `json:"grade"`
+	Findings int    `json:"findings"`
+}
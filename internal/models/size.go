@@ -0,0 +1,12 @@
+package models
+
+// InstructionSize is the estimated number of bytes an instruction of the Dockerfile adds to the final image
+type InstructionSize struct {
+	Filepath    string `json:"filepath"`
+	Line        int    `json:"line"`
+	Instruction string `json:"instruction"`
+	// EstimatedSize is the estimated number of bytes the instruction's layer adds to the image
+	EstimatedSize int64 `json:"estimated_size"`
+	// Basis is what the estimate is based on, eg- "build context" or "apt packages"
+	Basis string `json:"basis"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// Variant is the analysis of the image built with one combination of build arguments
+type Variant struct {
+	// Name identifies where the combination of build arguments is declared, eg- "compose.yaml:api"
+	Name     string            `json:"name"`
+	Args     map[string]string `json:"args"`
+	Score    int               `json:"score"`
+	Findings []*Finding        `json:"findings"`
+}
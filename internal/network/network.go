@@ -0,0 +1,222 @@
+// Package network sets up the HTTP transport dockershrink reaches the LLM, registries and other services with,
+// so that it works on corporate networks: behind a proxy, and with certificate authorities of the organization.
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Options configure the transport. Empty values fall back to the environment variables curl and Go programs honor.
+type Options struct {
+	// Proxy is the URL of the proxy HTTP and HTTPS requests go through, eg- http://proxy.corp.example.com:3128.
+	// $HTTPS_PROXY and $HTTP_PROXY are used if it's empty.
+	Proxy string
+	// NoProxy lists the hosts reached without the proxy: host names, domains (eg- ".corp.example.com"), IPs and CIDRs,
+	// optionally with a port, or "*" for all of them. $NO_PROXY is used if it's nil.
+	NoProxy []string
+	// CACerts are PEM files of certificate authorities trusted on top of those of the system,
+	// eg- the root of a proxy that inspects TLS traffic
+	CACerts []string
+}
+
+// Transport returns a transport like http.DefaultTransport, configured with the options
+func Transport(opts Options) (*http.Transport, error) {
+	proxy, err := ProxyFunc(opts)
+	if err != nil {
+		return nil, err
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = proxy
+	if len(opts.CACerts) > 0 {
+		pool, err := CertPool(opts.CACerts)
+		if err != nil {
+			return nil, err
+		}
+		t.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return t, nil
+}
+
+// CertPool returns the certificate authorities of the system along with those of the given PEM files
+func CertPool(files []string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	for _, f := range files {
+		content, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificates: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(content) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", f)
+		}
+	}
+	return pool, nil
+}
+
+// ProxyFunc returns the function choosing the proxy of a request, if any
+func ProxyFunc(opts Options) (func(*http.Request) (*url.URL, error), error) {
+	httpProxy, httpsProxy := opts.Proxy, opts.Proxy
+	if opts.Proxy == "" {
+		httpProxy, httpsProxy = getenv("HTTP_PROXY"), getenv("HTTPS_PROXY")
+	}
+	httpURL, err := parseProxy(httpProxy)
+	if err != nil {
+		return nil, err
+	}
+	httpsURL, err := parseProxy(httpsProxy)
+	if err != nil {
+		return nil, err
+	}
+	noProxy := opts.NoProxy
+	if noProxy == nil {
+		noProxy = strings.Split(getenv("NO_PROXY"), ",")
+	}
+	bypass, err := parseNoProxy(noProxy)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		proxy := httpURL
+		if req.URL.Scheme == "https" {
+			proxy = httpsURL
+		}
+		if proxy == nil || bypass.matches(req.URL) {
+			return nil, nil
+		}
+		return proxy, nil
+	}, nil
+}
+
+// getenv returns the upper or lower case variable, eg- HTTPS_PROXY or https_proxy
+func getenv(name string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return os.Getenv(strings.ToLower(name))
+}
+
+// parseProxy parses the URL of a proxy, which is assumed to use http if it has no scheme, eg- proxy.corp:3128
+func parseProxy(proxy string) (*url.URL, error) {
+	if proxy == "" {
+		return nil, nil
+	}
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %s: the scheme must be http, https or socks5", u.Redacted())
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %s: the host is missing", u.Redacted())
+	}
+	return u, nil
+}
+
+// noProxy are the hosts reached without the proxy
+type noProxy struct {
+	all   bool
+	cidrs []*net.IPNet
+	hosts []noProxyHost
+}
+
+// noProxyHost is either a domain or an IP, port is empty if it applies to every port
+type noProxyHost struct {
+	domain string
+	ip     net.IP
+	port   string
+}
+
+func parseNoProxy(entries []string) (*noProxy, error) {
+	p := &noProxy{}
+	for _, e := range entries {
+		e = strings.ToLower(strings.TrimSpace(e))
+		switch {
+		case e == "":
+			continue
+		case e == "*":
+			p.all = true
+			continue
+		}
+		if _, cidr, err := net.ParseCIDR(e); err == nil {
+			p.cidrs = append(p.cidrs, cidr)
+			continue
+		}
+		host, port := e, ""
+		if h, pt, err := net.SplitHostPort(e); err == nil {
+			host, port = h, pt
+		}
+		if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+			p.hosts = append(p.hosts, noProxyHost{ip: ip, port: port})
+			continue
+		}
+		// *.example.com is the same as .example.com
+		host = strings.TrimPrefix(host, "*")
+		if host == "" || host == "." || strings.ContainsAny(host, "/*") {
+			return nil, errors.New("invalid no-proxy entry " + e)
+		}
+		p.hosts = append(p.hosts, noProxyHost{domain: host, port: port})
+	}
+	return p, nil
+}
+
+// matches returns true if the URL is reached without the proxy.
+// Like with Go's default proxy settings, localhost and loopback addresses never go through the proxy.
+func (p *noProxy) matches(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	if p.all || host == "localhost" {
+		return true
+	}
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	ip := net.ParseIP(host)
+	if ip != nil {
+		if ip.IsLoopback() {
+			return true
+		}
+		for _, cidr := range p.cidrs {
+			if cidr.Contains(ip) {
+				return true
+			}
+		}
+	}
+	for _, h := range p.hosts {
+		if h.port != "" && h.port != port {
+			continue
+		}
+		switch {
+		case ip != nil:
+			if h.ip.Equal(ip) {
+				return true
+			}
+		// .example.com only matches subdomains, example.com matches itself and its subdomains
+		case strings.HasPrefix(h.domain, "."):
+			if strings.HasSuffix(host, h.domain) {
+				return true
+			}
+		case h.domain != "":
+			if host == h.domain || strings.HasSuffix(host, "."+h.domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package network
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProxyFunc(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "http-proxy.corp:3128")
+	t.Setenv("HTTPS_PROXY", "http://https-proxy.corp:3128")
+	t.Setenv("NO_PROXY", "registry.corp,.internal")
+
+	tests := []struct {
+		name     string
+		opts     Options
+		url      string
+		expected string
+	}{
+		{name: "https from the environment", url: "https://api.openai.com/v1/chat/completions", expected: "http://https-proxy.corp:3128"},
+		{name: "http from the environment", url: "http://registry.example.com/v2/", expected: "http://http-proxy.corp:3128"},
+		{name: "no_proxy from the environment", url: "https://registry.corp/v2/", expected: ""},
+		{name: "subdomain of no_proxy", url: "https://llm.eu.internal/v1", expected: ""},
+		{name: "loopback is never proxied", url: "http://127.0.0.1:11434/v1", expected: ""},
+		{name: "localhost is never proxied", url: "http://localhost:11434/v1", expected: ""},
+		{name: "explicit proxy", opts: Options{Proxy: "http://explicit.corp:8080"}, url: "https://api.openai.com/v1", expected: "http://explicit.corp:8080"},
+		{name: "explicit proxy keeps no_proxy of the environment", opts: Options{Proxy: "http://explicit.corp:8080"}, url: "https://registry.corp/v2/", expected: ""},
+		{name: "explicit no_proxy replaces the environment", opts: Options{NoProxy: []string{"api.openai.com"}}, url: "https://registry.corp/v2/", expected: "http://https-proxy.corp:3128"},
+		{name: "explicit no_proxy", opts: Options{NoProxy: []string{"api.openai.com"}}, url: "https://api.openai.com/v1", expected: ""},
+		{name: "empty no_proxy disables the environment", opts: Options{NoProxy: []string{}}, url: "https://registry.corp/v2/", expected: "http://https-proxy.corp:3128"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy, err := ProxyFunc(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			u, err := proxy(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			if u != nil {
+				got = u.String()
+			}
+			if got != tt.expected {
+				t.Errorf("proxy of %s = %q; want %q", tt.url, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNoProxy(t *testing.T) {
+	p, err := parseNoProxy([]string{" Example.com ", ".corp.example.net", "*.svc.local", "10.0.0.0/8", "192.168.1.5", "gitlab.acme.io:8443", "[::1]:80"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url      string
+		expected bool
+	}{
+		{"https://example.com/", true},
+		{"https://api.example.com/", true},
+		{"https://notexample.com/", false},
+		{"https://corp.example.net/", false},
+		{"https://ci.corp.example.net/", true},
+		{"http://registry.svc.local:5000/", true},
+		{"https://10.1.2.3/", true},
+		{"https://11.1.2.3/", false},
+		{"https://192.168.1.5:8443/", true},
+		{"https://192.168.1.6/", false},
+		{"https://gitlab.acme.io:8443/", true},
+		{"https://gitlab.acme.io/", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+		if got := p.matches(req.URL); got != tt.expected {
+			t.Errorf("matches(%s) = %v; want %v", tt.url, got, tt.expected)
+		}
+	}
+
+	if _, err := parseNoProxy([]string{"*"}); err != nil {
+		t.Errorf("unexpected error for *: %v", err)
+	}
+	for _, invalid := range []string{"example.com/path", "."} {
+		if _, err := parseNoProxy([]string{invalid}); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestParseProxy(t *testing.T) {
+	for _, invalid := range []string{"ftp://proxy.corp", "http://", "http://proxy corp"} {
+		if _, err := ProxyFunc(Options{Proxy: invalid}); err == nil {
+			t.Errorf("expected an error for proxy %q", invalid)
+		}
+	}
+}
+
+func TestTransport(t *testing.T) {
+	t.Run("proxy", func(t *testing.T) {
+		var requested string
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requested = r.URL.String()
+		}))
+		defer proxy.Close()
+
+		transport, err := Transport(Options{Proxy: proxy.URL, NoProxy: []string{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := (&http.Client{Transport: transport}).Get("http://registry.example.invalid/v2/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if requested != "http://registry.example.invalid/v2/" {
+			t.Errorf("the proxy received %q", requested)
+		}
+	})
+
+	t.Run("CA certificates", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+
+		transport, err := Transport(Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := (&http.Client{Transport: transport}).Get(server.URL); err == nil {
+			t.Fatalf("expected the certificate of the test server not to be trusted")
+		}
+
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		content := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		if err := os.WriteFile(caFile, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		transport, err = Transport(Options{CACerts: []string{caFile}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+		if err != nil {
+			t.Fatalf("expected the certificate to be trusted, got %v", err)
+		}
+		resp.Body.Close()
+
+		notPEM := filepath.Join(t.TempDir(), "ca.txt")
+		os.WriteFile(notPEM, []byte("not a certificate"), 0o644)
+		if _, err := Transport(Options{CACerts: []string{notPEM}}); err == nil {
+			t.Errorf("expected an error for a file without certificates")
+		}
+	})
+}
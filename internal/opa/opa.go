@@ -0,0 +1,213 @@
+// Package opa evaluates Dockerfiles against policies written in Rego, for teams that already encode their
+// container standards in OPA bundles. Bundles, a directory or a .tar.gz file, are loaded and evaluated in-process
+// with the OPA Go SDK, so the opa CLI doesn't have to be installed.
+//
+// The Dockerfile is given to the policies as input (see Input), and the rules of the "dockershrink"
+// package of a bundle report violations: deny rules report high severity violations and warn rules
+// medium severity ones. Like in conftest, a violation is either a message or an object with the message
+// in "msg", optionally along with the "line" of the Dockerfile and a "severity".
+package opa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// Query is the document of a bundle holding its deny and warn rules
+const Query = "data.dockershrink"
+
+// ruleSeverities are the rules that report violations along with the default severity of their violations
+var ruleSeverities = map[string]models.Severity{
+	"deny": models.SeverityHigh,
+	"warn": models.SeverityMedium,
+}
+
+// Input is the Dockerfile as seen by the policies, available as input in Rego
+type Input struct {
+	// Path is the path of the Dockerfile
+	Path string `json:"path"`
+	// GlobalArgs are the ARG instructions before the first stage
+	GlobalArgs []*Instruction `json:"global_args"`
+	Stages     []*Stage       `json:"stages"`
+}
+
+// Stage is a stage of the Dockerfile
+type Stage struct {
+	Index uint `json:"index"`
+	// Name is the name given with "AS", empty if there's none
+	Name string `json:"name"`
+	// BaseImage is the image the stage is built from, as declared, eg- node:22-alpine or another stage
+	BaseImage string `json:"base_image"`
+	// From is the FROM instruction of the stage
+	From *Instruction `json:"from"`
+	// Instructions are the instructions of the stage after FROM
+	Instructions []*Instruction `json:"instructions"`
+}
+
+// Instruction is an instruction of the Dockerfile
+type Instruction struct {
+	// Cmd is the name of the instruction in uppercase, eg- RUN
+	Cmd   string   `json:"cmd"`
+	Flags []string `json:"flags"`
+	// Args are the arguments of the instruction after flags, with the build arguments expanded
+	Args     []string `json:"args"`
+	JSON     bool     `json:"json"`
+	Original string   `json:"original"`
+	Line     int      `json:"line"`
+	EndLine  int      `json:"end_line"`
+}
+
+// NewInput returns the input of the policies for the Dockerfile at path
+func NewInput(path string, d *dockerfile.Dockerfile) *Input {
+	input := &Input{Path: path, GlobalArgs: []*Instruction{}, Stages: []*Stage{}}
+	for _, arg := range d.GetGlobalArgs() {
+		input.GlobalArgs = append(input.GlobalArgs, newInstruction(arg))
+	}
+	for _, s := range d.GetStages() {
+		stage := &Stage{
+			Index:        s.Index(),
+			Name:         s.Name(),
+			BaseImage:    s.DeclaredBaseImage(),
+			Instructions: []*Instruction{},
+		}
+		stage.From = &Instruction{
+			Cmd:      dockerfile.CmdFrom,
+			Flags:    []string{},
+			Args:     []string{s.BaseImage().FullName()},
+			Original: s.Original(),
+			Line:     s.StartLine(),
+			EndLine:  s.StartLine(),
+		}
+		if s.Name() != "" {
+			stage.From.Args = append(stage.From.Args, "AS", s.Name())
+		}
+		if platform := s.Platform(); platform != "" {
+			stage.From.Flags = append(stage.From.Flags, "--platform="+platform)
+		}
+		for _, inst := range s.Instructions() {
+			stage.Instructions = append(stage.Instructions, newInstruction(inst))
+		}
+		input.Stages = append(input.Stages, stage)
+	}
+	return input
+}
+
+func newInstruction(i *dockerfile.Instruction) *Instruction {
+	flags := i.Flags()
+	if flags == nil {
+		flags = []string{}
+	}
+	args := i.ExpandedArgs()
+	if args == nil {
+		args = []string{}
+	}
+	return &Instruction{
+		Cmd:      i.Cmd(),
+		Flags:    flags,
+		Args:     args,
+		JSON:     i.IsJSONForm(),
+		Original: i.Original(),
+		Line:     i.StartLine(),
+		EndLine:  i.EndLine(),
+	}
+}
+
+// Violation is a violation of a policy reported by a bundle
+type Violation struct {
+	// Bundle is the name of the bundle, the name of its file or directory
+	Bundle string
+	// Rule is the rule that reported the violation: deny or warn
+	Rule     string
+	Message  string
+	Severity models.Severity
+	// Line is the line of the Dockerfile the violation is about, 0 if it's about the whole Dockerfile
+	Line int
+}
+
+// Evaluate evaluates the bundle at path against the input with the OPA Go SDK and returns its violations, sorted by line.
+// A bundle without a dockershrink package reports no violations.
+func Evaluate(ctx context.Context, bundle string, input *Input) ([]*Violation, error) {
+	query := rego.New(rego.Query(Query), rego.LoadBundle(bundle), rego.Input(input))
+	results, err := query.Eval(ctx)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate policy bundle %s: %w", bundle, err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(filepath.Clean(bundle)), ".tar.gz")
+	violations := []*Violation{}
+	for _, r := range results {
+		for _, expr := range r.Expressions {
+			value, err := json.Marshal(expr.Value)
+			if err != nil {
+				return nil, err
+			}
+			found, err := parseViolations(name, value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid result of policy bundle %s: %w", bundle, err)
+			}
+			violations = append(violations, found...)
+		}
+	}
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Line < violations[j].Line })
+	return violations, nil
+}
+
+// parseViolations reads the violations reported by the deny and warn rules of the dockershrink package
+func parseViolations(bundle string, value json.RawMessage) ([]*Violation, error) {
+	document := map[string]json.RawMessage{}
+	if err := json.Unmarshal(value, &document); err != nil {
+		return nil, fmt.Errorf("%s must be a package: %w", Query, err)
+	}
+	rules := make([]string, 0, len(ruleSeverities))
+	for rule := range ruleSeverities {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+
+	violations := []*Violation{}
+	for _, rule := range rules {
+		raw, ok := document[rule]
+		if !ok {
+			continue
+		}
+		// deny and warn are partial sets, which OPA returns as arrays
+		results := []json.RawMessage{}
+		if err := json.Unmarshal(raw, &results); err != nil {
+			return nil, fmt.Errorf("%s must be a set of messages or objects: %w", rule, err)
+		}
+		for _, result := range results {
+			v := &Violation{Bundle: bundle, Rule: rule, Severity: ruleSeverities[rule]}
+			if err := json.Unmarshal(result, &v.Message); err != nil {
+				var object struct {
+					Msg      string          `json:"msg"`
+					Line     int             `json:"line"`
+					Severity models.Severity `json:"severity"`
+				}
+				if err := json.Unmarshal(result, &object); err != nil || object.Msg == "" {
+					return nil, fmt.Errorf("a violation of %s must be a message or an object with a msg, got %s", rule, result)
+				}
+				v.Message, v.Line = object.Msg, object.Line
+				switch object.Severity {
+				case "":
+				case models.SeverityInfo, models.SeverityLow, models.SeverityMedium, models.SeverityHigh:
+					v.Severity = object.Severity
+				default:
+					return nil, fmt.Errorf("invalid severity %q of a violation of %s, must be one of high, medium, low or info", object.Severity, rule)
+				}
+			}
+			violations = append(violations, v)
+		}
+	}
+	return violations, nil
+}
@@ -0,0 +1,181 @@
+package opa
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+func TestNewInput(t *testing.T) {
+	df, err := dockerfile.NewDockerfile(`ARG NODE_VERSION=22
+FROM --platform=$BUILDPLATFORM node:${NODE_VERSION}-alpine AS build
+RUN --mount=type=cache,target=/root/.npm npm ci
+
+FROM build
+CMD ["node", "index.js"]
+`)
+	if err != nil {
+		t.Fatalf("failed to parse Dockerfile: %v", err)
+	}
+	input := NewInput("Dockerfile", df)
+	if len(input.GlobalArgs) != 1 || input.GlobalArgs[0].Cmd != "ARG" {
+		t.Fatalf("expected the global ARG, got %+v", input.GlobalArgs)
+	}
+	if len(input.Stages) != 2 {
+		t.Fatalf("expected 2 stages, got %d", len(input.Stages))
+	}
+
+	build := input.Stages[0]
+	if build.Name != "build" || build.BaseImage != "node:${NODE_VERSION}-alpine" {
+		t.Errorf("unexpected stage: %+v", build)
+	}
+	expectedFrom := &Instruction{
+		Cmd:      "FROM",
+		Flags:    []string{"--platform=$BUILDPLATFORM"},
+		Args:     []string{"node:22-alpine", "AS", "build"},
+		Original: "FROM --platform=$BUILDPLATFORM node:${NODE_VERSION}-alpine AS build",
+		Line:     2,
+		EndLine:  2,
+	}
+	if !reflect.DeepEqual(build.From, expectedFrom) {
+		t.Errorf("unexpected FROM:\n%+v\nwant:\n%+v", build.From, expectedFrom)
+	}
+	run := build.Instructions[0]
+	if run.Cmd != "RUN" || run.Line != 3 || !reflect.DeepEqual(run.Flags, []string{"--mount=type=cache,target=/root/.npm"}) {
+		t.Errorf("unexpected RUN: %+v", run)
+	}
+	cmd := input.Stages[1].Instructions[0]
+	if !cmd.JSON || !reflect.DeepEqual(cmd.Args, []string{"node", "index.js"}) {
+		t.Errorf("unexpected CMD: %+v", cmd)
+	}
+
+	// empty lists are given to the policies as arrays, not null
+	content, err := json.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "null") {
+		t.Errorf("expected no null in the input, got %s", content)
+	}
+}
+
+// writeBundle writes a bundle with the given files to dir, or to a .tar.gz file if dir ends with .tar.gz
+func writeBundle(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	if !strings.HasSuffix(dir, ".tar.gz") {
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: "/" + name, Mode: 0o644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	if err := os.WriteFile(dir, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const testPolicy = `package dockershrink
+
+import rego.v1
+
+deny contains {"msg": "ADD from URLs is banned", "line": 3} if {
+	count(input.stages) > 0
+}
+
+deny contains "the final stage must run as non-root"
+
+warn contains {"msg": sprintf("pin the base image %s", [input.stages[0].base_image]), "line": 1, "severity": "low"}
+
+helper := true
+`
+
+func TestEvaluate(t *testing.T) {
+	df, _ := dockerfile.NewDockerfile("FROM node:22\n")
+	expected := []*Violation{
+		{Bundle: "acme", Rule: "deny", Message: "the final stage must run as non-root", Severity: models.SeverityHigh},
+		{Bundle: "acme", Rule: "warn", Message: "pin the base image node:22", Severity: models.SeverityLow, Line: 1},
+		{Bundle: "acme", Rule: "deny", Message: "ADD from URLs is banned", Severity: models.SeverityHigh, Line: 3},
+	}
+
+	for _, name := range []string{"acme", "acme.tar.gz"} {
+		t.Run(name, func(t *testing.T) {
+			bundle := filepath.Join(t.TempDir(), name)
+			if name == "acme" {
+				if err := os.Mkdir(bundle, 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			writeBundle(t, bundle, map[string]string{"policy.rego": testPolicy})
+
+			violations, err := Evaluate(context.Background(), bundle, NewInput("Dockerfile", df))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(violations, expected) {
+				for _, v := range violations {
+					t.Logf("%+v", v)
+				}
+				t.Errorf("unexpected violations")
+			}
+		})
+	}
+}
+
+func TestEvaluate_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   string
+	}{
+		{name: "undefined package", files: map[string]string{"policy.rego": "package acme\n\ndeny[\"x\"]"}},
+		{name: "rego error", files: map[string]string{"policy.rego": "package dockershrink\n\ndeny[msg"}, err: "rego_parse_error"},
+		{name: "no bundle", err: "failed to evaluate policy bundle"},
+		{name: "invalid violation", files: map[string]string{"policy.rego": "package dockershrink\n\ndeny[{\"line\": 2}]"}, err: "must be a message or an object with a msg"},
+		{name: "invalid severity", files: map[string]string{"policy.rego": "package dockershrink\n\ndeny[{\"msg\": \"x\", \"severity\": \"critical\"}]"}, err: `invalid severity "critical"`},
+		{name: "not a package", files: map[string]string{"data.json": `{"dockershrink": true}`}, err: "must be a package"},
+	}
+	df, _ := dockerfile.NewDockerfile("FROM node:22\n")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundle := filepath.Join(t.TempDir(), "bundle")
+			if tt.files != nil {
+				if err := os.Mkdir(bundle, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				writeBundle(t, bundle, tt.files)
+			}
+			violations, err := Evaluate(context.Background(), bundle, NewInput("Dockerfile", df))
+			if tt.err == "" {
+				if err != nil || len(violations) != 0 {
+					t.Errorf("expected no violations, got %v, %v", violations, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
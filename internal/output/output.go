@@ -0,0 +1,172 @@
+// Package output defines the JSON document commands write to stdout with --output json, so that
+// dockershrink can be scripted and integrated into other systems.
+//
+// The document is versioned by SchemaVersion. Fields are only ever added within a version, and every
+// list is present even when it's empty, so that consumers don't need to tell null and [] apart.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/diff"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/sinks"
+)
+
+// SchemaVersion is the version of the document's schema. It's incremented when fields are removed or change meaning.
+const SchemaVersion = 1
+
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatSARIF = "sarif"
+)
+
+var Formats = []string{FormatText, FormatJSON, FormatSARIF}
+
+// ValidateFormat returns an error if format isn't a known output format
+func ValidateFormat(format string) error {
+	for _, f := range Formats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid output format %q, must be one of: %s", format, strings.Join(Formats, ", "))
+}
+
+// Document is the outcome of a command
+type Document struct {
+	SchemaVersion       int    `json:"schema_version"`
+	DockershrinkVersion string `json:"dockershrink_version"`
+	// Command is the command that was run, eg- "analyze" or "rules list"
+	Command  string `json:"command"`
+	ExitCode int    `json:"exit_code"`
+	// Error is the error the command failed with, if any
+	Error string `json:"error,omitempty"`
+	// Results has an entry for every Dockerfile the command analyzed, optimized or generated
+	Results []*Result `json:"results"`
+	// Data is the output of commands that don't work on Dockerfiles, eg- the list of rules. Its format depends on the command.
+	Data any `json:"data,omitempty"`
+}
+
+// Result is the outcome of a command for a single Dockerfile
+type Result struct {
+	Dockerfile string `json:"dockerfile"`
+	// RunID is set if the command was recorded in the project's history
+	RunID string `json:"run_id,omitempty"`
+	// Score is only set by commands that analyze the image definition
+	Score            *int                         `json:"score,omitempty"`
+	ScoreBreakdown   *models.ScoreBreakdown       `json:"score_breakdown,omitempty"`
+	Findings         []*models.Finding            `json:"findings"`
+	InstructionSizes []*models.InstructionSize    `json:"instruction_sizes"`
+	Variants         []*models.Variant            `json:"variants"`
+	ActionsTaken     []*models.OptimizationAction `json:"actions_taken"`
+	Recommendations  []*models.OptimizationAction `json:"recommendations"`
+	ModifiedFiles    []*ModifiedFile              `json:"modified_files"`
+	// EstimatedImageSize is the estimated size of the image in bytes, before any optimization
+	EstimatedImageSize int64 `json:"estimated_image_size,omitempty"`
+	// EstimatedOptimizedImageSize is the estimated size of the image built from the optimized files
+	EstimatedOptimizedImageSize int64 `json:"estimated_optimized_image_size,omitempty"`
+	// Error is set if the Dockerfile failed while others succeeded, eg- in a recursive run
+	Error string `json:"error,omitempty"`
+
+	// Fixes are changes that resolve the findings without having been made. They're only reported in SARIF.
+	Fixes []*Fix `json:"-"`
+}
+
+// Fix is a change to a file of the project that resolves some of the findings
+type Fix struct {
+	Path     string
+	Original string
+	Fixed    string
+}
+
+// ModifiedFile is a file of the project the command changed or created
+type ModifiedFile struct {
+	// Path is the file of the project, relative to it
+	Path string `json:"path"`
+	// OutputPath is where the new content was written, empty if it wasn't written, eg- because a patch was
+	OutputPath string `json:"output_path,omitempty"`
+	// Diff is the change as a patch that can be applied with "git apply"
+	Diff string `json:"diff"`
+}
+
+// AddModifiedFile records that the command changed the file at path from original to modified.
+// Nothing is recorded if both are identical.
+func (r *Result) AddModifiedFile(path, outputPath, original, modified string) {
+	if original == modified {
+		return
+	}
+	r.ModifiedFiles = append(r.ModifiedFiles, &ModifiedFile{
+		Path:       path,
+		OutputPath: outputPath,
+		Diff:       diff.GitPatch(path, original, modified),
+	})
+}
+
+// New creates the document of a command
+func New(command, version string) *Document {
+	return &Document{SchemaVersion: SchemaVersion, DockershrinkVersion: version, Command: command, Results: []*Result{}}
+}
+
+// Add adds a result to the document and returns it, so that it can be filled in further
+func (d *Document) Add(r *Result) *Result {
+	d.Results = append(d.Results, r)
+	return r
+}
+
+// FromReport returns the result described by a run report
+func FromReport(r *sinks.Report) *Result {
+	return &Result{
+		Dockerfile:       r.DockerfilePath,
+		RunID:            r.RunID,
+		Score:            r.Score,
+		ScoreBreakdown:   r.ScoreBreakdown,
+		Findings:         r.Findings,
+		InstructionSizes: r.InstructionSizes,
+		Variants:         r.Variants,
+		ActionsTaken:     r.ActionsTaken,
+		Recommendations:  r.Recommendations,
+
+		EstimatedImageSize:          r.EstimatedImageSize,
+		EstimatedOptimizedImageSize: r.EstimatedOptimizedImageSize,
+	}
+}
+
+// Write writes the document to w as indented JSON
+func (d *Document) Write(w io.Writer) error {
+	for _, r := range d.Results {
+		r.normalize()
+	}
+	content, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(content))
+	return err
+}
+
+// normalize replaces missing lists with empty ones
+func (r *Result) normalize() {
+	if r.Findings == nil {
+		r.Findings = []*models.Finding{}
+	}
+	if r.InstructionSizes == nil {
+		r.InstructionSizes = []*models.InstructionSize{}
+	}
+	if r.Variants == nil {
+		r.Variants = []*models.Variant{}
+	}
+	if r.ActionsTaken == nil {
+		r.ActionsTaken = []*models.OptimizationAction{}
+	}
+	if r.Recommendations == nil {
+		r.Recommendations = []*models.OptimizationAction{}
+	}
+	if r.ModifiedFiles == nil {
+		r.ModifiedFiles = []*ModifiedFile{}
+	}
+}
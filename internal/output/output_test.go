@@ -0,0 +1,62 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/sinks"
+)
+
+func TestWrite(t *testing.T) {
+	score := 80
+	d := New("analyze", "1.2.3")
+	d.Add(FromReport(&sinks.Report{
+		DockerfilePath: "Dockerfile",
+		Score:          &score,
+		Findings:       []*models.Finding{{Code: "DS001", Rule: "missing-dockerignore", Severity: models.SeverityMedium}},
+	}))
+	d.Add(&Result{Dockerfile: "services/api/Dockerfile", Error: "syntax error"})
+
+	var buf bytes.Buffer
+	if err := d.Write(&buf); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Write() produced invalid JSON: %v", err)
+	}
+	if decoded["schema_version"] != float64(SchemaVersion) || decoded["command"] != "analyze" || decoded["dockershrink_version"] != "1.2.3" {
+		t.Errorf("unexpected envelope %v", decoded)
+	}
+
+	results := decoded["results"].([]interface{})
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	failed := results[1].(map[string]interface{})
+	for _, field := range []string{"findings", "instruction_sizes", "variants", "actions_taken", "recommendations", "modified_files"} {
+		if !reflect.DeepEqual(failed[field], []interface{}{}) {
+			t.Errorf("expected %s to be an empty list, got %v", field, failed[field])
+		}
+	}
+	if _, ok := failed["score"]; ok {
+		t.Errorf("expected no score for a failed result")
+	}
+	if results[0].(map[string]interface{})["score"] != float64(80) {
+		t.Errorf("unexpected result %v", results[0])
+	}
+}
+
+func TestValidateFormat(t *testing.T) {
+	for _, format := range Formats {
+		if err := ValidateFormat(format); err != nil {
+			t.Errorf("ValidateFormat(%q) = %v", format, err)
+		}
+	}
+	if err := ValidateFormat("yaml"); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,283 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/diff"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+)
+
+// SARIF 2.1.0, as ingested by GitHub Code Scanning and other SAST dashboards.
+// Only the parts of the format dockershrink fills in are modeled.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	// sarifSrcRoot is the base of all file locations, which are relative to the directory dockershrink ran in
+	sarifSrcRoot = "%SRCROOT%"
+	toolURI      = "https://github.com/duaraghav8/dockershrink"
+)
+
+type sarifLog struct {
+	Version string      `json:"version"`
+	Schema  string      `json:"$schema"`
+	Runs    []*sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool          `json:"tool"`
+	Invocations []*sarifInvocation `json:"invocations"`
+	Results     []*sarifResult     `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string       `json:"name"`
+	Version        string       `json:"version"`
+	InformationURI string       `json:"informationUri"`
+	Rules          []*sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	FullDescription      sarifMessage       `json:"fullDescription"`
+	Help                 sarifMessage       `json:"help"`
+	HelpURI              string             `json:"helpUri"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+	Properties           sarifRuleProps     `json:"properties"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifRuleProps struct {
+	Tags []string `json:"tags"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                 `json:"executionSuccessful"`
+	ExitCode                   int                  `json:"exitCode"`
+	ToolExecutionNotifications []*sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level     string           `json:"level"`
+	Message   sarifMessage     `json:"message"`
+	Locations []*sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	RuleIndex  *int              `json:"ruleIndex,omitempty"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []*sarifLocation  `json:"locations"`
+	Fixes      []*sarifFix       `json:"fixes,omitempty"`
+	Properties *sarifResultProps `json:"properties,omitempty"`
+}
+
+type sarifResultProps struct {
+	// EstimatedSizeImpact is the estimated number of bytes that can be saved by fixing the finding
+	EstimatedSizeImpact int64 `json:"estimatedSizeImpact"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage           `json:"description"`
+	ArtifactChanges []*sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []*sarifReplacement   `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion   `json:"deletedRegion"`
+	InsertedContent *sarifContent `json:"insertedContent,omitempty"`
+}
+
+type sarifContent struct {
+	Text string `json:"text"`
+}
+
+var sarifLevels = map[models.Severity]string{
+	models.SeverityHigh:   "error",
+	models.SeverityMedium: "warning",
+	models.SeverityLow:    "note",
+	models.SeverityInfo:   "note",
+}
+
+// WriteSARIF writes the findings of the document to w as a SARIF log with a single run.
+// Every rule is described in the log, and findings are reported with the fixes that resolve them, if any.
+func (d *Document) WriteSARIF(w io.Writer) error {
+	run := &sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "dockershrink",
+			Version:        d.DockershrinkVersion,
+			InformationURI: toolURI,
+			Rules:          make([]*sarifRule, 0, len(rules.All)),
+		}},
+		Results: []*sarifResult{},
+	}
+	ruleIndexes := map[string]int{}
+	for i, r := range rules.All {
+		tags := make([]string, 0, len(r.Goals))
+		for _, g := range r.Goals {
+			tags = append(tags, string(g))
+		}
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, &sarifRule{
+			ID:                   r.ID,
+			Name:                 r.Name,
+			ShortDescription:     sarifMessage{Text: r.Name},
+			FullDescription:      sarifMessage{Text: r.Description},
+			Help:                 sarifMessage{Text: fmt.Sprintf("%s See %s", r.Description, r.HelpURI())},
+			HelpURI:              r.HelpURI(),
+			DefaultConfiguration: sarifConfiguration{Level: sarifLevels[r.Severity]},
+			Properties:           sarifRuleProps{Tags: tags},
+		})
+		ruleIndexes[r.ID] = i
+		ruleIndexes[r.Name] = i
+	}
+
+	invocation := &sarifInvocation{ExecutionSuccessful: d.Error == "", ExitCode: d.ExitCode}
+	if d.Error != "" {
+		invocation.ToolExecutionNotifications = append(invocation.ToolExecutionNotifications, &sarifNotification{
+			Level:   "error",
+			Message: sarifMessage{Text: d.Error},
+		})
+	}
+	for _, r := range d.Results {
+		if r.Error != "" {
+			invocation.ToolExecutionNotifications = append(invocation.ToolExecutionNotifications, &sarifNotification{
+				Level:     "error",
+				Message:   sarifMessage{Text: r.Error},
+				Locations: []*sarifLocation{newSARIFLocation(r.Dockerfile, 0)},
+			})
+		}
+		for _, f := range r.Findings {
+			run.Results = append(run.Results, newSARIFResult(f, r.Fixes, ruleIndexes))
+		}
+	}
+	run.Invocations = []*sarifInvocation{invocation}
+
+	content, err := json.MarshalIndent(&sarifLog{Version: sarifVersion, Schema: sarifSchema, Runs: []*sarifRun{run}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(content))
+	return err
+}
+
+func newSARIFResult(f *models.Finding, fixes []*Fix, ruleIndexes map[string]int) *sarifResult {
+	result := &sarifResult{
+		RuleID:    f.Code,
+		Level:     sarifLevels[f.Severity],
+		Message:   sarifMessage{Text: f.Title + "\n\n" + f.Description},
+		Locations: []*sarifLocation{newSARIFLocation(f.Filepath, f.Line)},
+	}
+	if result.RuleID == "" {
+		result.RuleID = f.Rule
+	}
+	if i, ok := ruleIndexes[result.RuleID]; ok {
+		result.RuleIndex = &i
+	}
+	if f.EstimatedSizeImpact > 0 {
+		result.Properties = &sarifResultProps{EstimatedSizeImpact: f.EstimatedSizeImpact}
+	}
+	for _, fix := range fixes {
+		if replacements := fix.replacements(f); len(replacements) > 0 {
+			result.Fixes = append(result.Fixes, &sarifFix{
+				Description: sarifMessage{Text: "Apply the changes dockershrink makes to " + fix.Path},
+				ArtifactChanges: []*sarifArtifactChange{{
+					ArtifactLocation: newSARIFArtifactLocation(fix.Path),
+					Replacements:     replacements,
+				}},
+			})
+		}
+	}
+	return result
+}
+
+// replacements returns the changes of the fix that resolve the finding.
+// A finding on a line is resolved by the changes to that line, while a finding about a
+// whole file is resolved by all the changes to the file.
+func (fix *Fix) replacements(f *models.Finding) []*sarifReplacement {
+	if filepath.ToSlash(filepath.Clean(fix.Path)) != filepath.ToSlash(filepath.Clean(f.Filepath)) {
+		return nil
+	}
+	replacements := []*sarifReplacement{}
+	for _, h := range diff.Hunks(diff.Lines(fix.Original, fix.Fixed), 0) {
+		// the first line after the changed lines of the original, or where the lines are inserted
+		start, end := h.AStart, h.AStart+h.ALen
+		if h.ALen == 0 {
+			start++
+			end++
+		}
+		if f.Line > 0 && (f.Line < start || f.Line >= max(end, start+1)) {
+			continue
+		}
+		r := &sarifReplacement{DeletedRegion: sarifRegion{StartLine: start, StartColumn: 1, EndLine: end, EndColumn: 1}}
+		var inserted strings.Builder
+		for _, op := range h.Ops {
+			if op.Kind == diff.OpInsert {
+				inserted.WriteString(op.Text)
+				if !op.NoEOL {
+					inserted.WriteString("\n")
+				}
+			}
+		}
+		if inserted.Len() > 0 {
+			r.InsertedContent = &sarifContent{Text: inserted.String()}
+		}
+		replacements = append(replacements, r)
+	}
+	return replacements
+}
+
+func newSARIFLocation(path string, line int) *sarifLocation {
+	l := &sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: newSARIFArtifactLocation(path)}}
+	if line > 0 {
+		l.PhysicalLocation.Region = &sarifRegion{StartLine: line}
+	}
+	return l
+}
+
+func newSARIFArtifactLocation(path string) sarifArtifactLocation {
+	return sarifArtifactLocation{URI: filepath.ToSlash(filepath.Clean(path)), URIBaseID: sarifSrcRoot}
+}
@@ -0,0 +1,107 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+)
+
+func TestWriteSARIF(t *testing.T) {
+	d := New("lint", "1.2.3")
+	d.ExitCode = 1
+	d.Add(&Result{
+		Dockerfile: "Dockerfile",
+		Findings: []*models.Finding{
+			{Code: "DS013", Rule: "add-instead-of-copy", Severity: models.SeverityLow, Filepath: "Dockerfile", Line: 3, Title: "ADD is used"},
+			{Code: "DS003", Rule: "heavy-final-base-image", Severity: models.SeverityHigh, Filepath: "Dockerfile", Line: 1, Title: "Heavy base", EstimatedSizeImpact: 1000},
+			{Code: "DS001", Rule: "missing-dockerignore", Severity: models.SeverityMedium, Filepath: ".dockerignore", Title: "No .dockerignore"},
+		},
+		Fixes: []*Fix{
+			{Path: "Dockerfile", Original: "FROM node:20\nWORKDIR /app\nADD . .\n", Fixed: "FROM node:20\nWORKDIR /app\nCOPY . .\n"},
+			{Path: ".dockerignore", Original: "", Fixed: "node_modules\n"},
+		},
+	})
+	d.Add(&Result{Dockerfile: "api/Dockerfile", Error: "syntax error"})
+
+	var buf bytes.Buffer
+	if err := d.WriteSARIF(&buf); err != nil {
+		t.Fatalf("WriteSARIF() failed: %v", err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("WriteSARIF() produced invalid JSON: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected log %+v", log)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != len(rules.All) {
+		t.Errorf("expected %d rules, got %d", len(rules.All), len(run.Tool.Driver.Rules))
+	}
+	for _, r := range run.Tool.Driver.Rules {
+		if r.FullDescription.Text == "" || r.Help.Text == "" || !strings.HasPrefix(r.HelpURI, rules.DocsURL+"#") {
+			t.Errorf("expected rule %s to have a description, help and a link to its docs, got %+v", r.ID, r)
+		}
+	}
+	if len(run.Invocations) != 1 || !run.Invocations[0].ExecutionSuccessful || len(run.Invocations[0].ToolExecutionNotifications) != 1 {
+		t.Errorf("unexpected invocations %+v", run.Invocations)
+	}
+
+	tests := []struct {
+		ruleID       string
+		level        string
+		replacements []sarifReplacement
+	}{
+		{
+			ruleID: "DS013",
+			level:  "note",
+			replacements: []sarifReplacement{{
+				DeletedRegion:   sarifRegion{StartLine: 3, StartColumn: 1, EndLine: 4, EndColumn: 1},
+				InsertedContent: &sarifContent{Text: "COPY . .\n"},
+			}},
+		},
+		{ruleID: "DS003", level: "error"},
+		{
+			ruleID: "DS001",
+			level:  "warning",
+			replacements: []sarifReplacement{{
+				DeletedRegion:   sarifRegion{StartLine: 1, StartColumn: 1, EndLine: 1, EndColumn: 1},
+				InsertedContent: &sarifContent{Text: "node_modules\n"},
+			}},
+		},
+	}
+	if len(run.Results) != len(tests) {
+		t.Fatalf("expected %d results, got %d", len(tests), len(run.Results))
+	}
+	for i, tt := range tests {
+		r := run.Results[i]
+		if r.RuleID != tt.ruleID || r.Level != tt.level {
+			t.Errorf("result %d: got %s (%s), want %s (%s)", i, r.RuleID, r.Level, tt.ruleID, tt.level)
+		}
+		if r.RuleIndex == nil || run.Tool.Driver.Rules[*r.RuleIndex].ID != tt.ruleID {
+			t.Errorf("%s: rule index doesn't point to its rule", tt.ruleID)
+		}
+		var replacements []sarifReplacement
+		for _, fix := range r.Fixes {
+			for _, c := range fix.ArtifactChanges {
+				for _, rep := range c.Replacements {
+					replacements = append(replacements, *rep)
+				}
+			}
+		}
+		if len(replacements) != len(tt.replacements) {
+			t.Errorf("%s: expected %d replacements, got %+v", tt.ruleID, len(tt.replacements), replacements)
+			continue
+		}
+		for j, rep := range replacements {
+			want := tt.replacements[j]
+			if rep.DeletedRegion != want.DeletedRegion || rep.InsertedContent.Text != want.InsertedContent.Text {
+				t.Errorf("%s: replacement %d = %+v %q, want %+v %q", tt.ruleID, j, rep.DeletedRegion, rep.InsertedContent.Text, want.DeletedRegion, want.InsertedContent.Text)
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Package ownership finds the teams owning the files of a project, using its CODEOWNERS file
+// and the owners configured in .dockershrink.yaml, so that reports and changes spanning many
+// Dockerfiles of a monorepo can be split per owning team.
+package ownership
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// CodeownersLocations are the paths a CODEOWNERS file is looked up at, in order, like GitHub does
+var CodeownersLocations = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// Rule assigns owners to the files matching a pattern
+type Rule struct {
+	// Pattern uses the CODEOWNERS (gitignore) syntax, eg- "/services/api/" or "*.go"
+	Pattern string
+	// Owners are users or teams, eg- "@acme/api-team". Empty means that the files have no owner.
+	Owners []string
+
+	regex *regexp.Regexp
+}
+
+func NewRule(pattern string, owners []string) (*Rule, error) {
+	re, err := compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return &Rule{Pattern: pattern, Owners: owners, regex: re}, nil
+}
+
+// Matches returns true if the slash-separated path, relative to the project root, matches the rule's pattern
+func (r *Rule) Matches(p string) bool {
+	return r.regex.MatchString(strings.TrimPrefix(path.Clean(p), "/"))
+}
+
+// Owners maps the files of a project to their owners.
+// Like in CODEOWNERS, the last matching rule decides the owners of a file.
+type Owners struct {
+	Rules []*Rule
+	// Source is the CODEOWNERS file the rules were read from, empty if there is none
+	Source string
+}
+
+// Parse reads rules written in the CODEOWNERS format
+func Parse(content string) (*Owners, error) {
+	o := &Owners{}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		// GitLab sections, eg- "[Backend] @acme/backend", only group rules and aren't rules themselves
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") || strings.HasPrefix(line, "^[") {
+			continue
+		}
+		fields := strings.Fields(line)
+		owners := []string{}
+		for _, f := range fields[1:] {
+			if strings.HasPrefix(f, "#") {
+				break
+			}
+			owners = append(owners, f)
+		}
+		r, err := NewRule(fields[0], owners)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		o.Rules = append(o.Rules, r)
+	}
+	return o, scanner.Err()
+}
+
+// Load reads the CODEOWNERS file of the project at root, if there is one, and adds the given
+// rules after its own so that they take precedence.
+func Load(root string, extra []*Rule) (*Owners, error) {
+	o := &Owners{}
+	for _, loc := range CodeownersLocations {
+		content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(loc)))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", loc, err)
+		}
+		if o, err = Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", loc, err)
+		}
+		o.Source = loc
+		break
+	}
+	o.Rules = append(o.Rules, extra...)
+	return o, nil
+}
+
+// Of returns the owners of the file at the given slash-separated path, relative to the project root.
+// nil is returned if no rule matches the file.
+func (o *Owners) Of(p string) []string {
+	for i := len(o.Rules) - 1; i >= 0; i-- {
+		if o.Rules[i].Matches(p) {
+			return o.Rules[i].Owners
+		}
+	}
+	return nil
+}
+
+// Group is a set of files owned by the same owners
+type Group struct {
+	// Owners are empty for the files that nobody owns
+	Owners []string
+	Paths  []string
+}
+
+// Key identifies the owners of the group in branch names, file names and the like, eg- "acme-api-team"
+func (g *Group) Key() string {
+	if len(g.Owners) == 0 {
+		return "unowned"
+	}
+	return slug(strings.Join(g.Owners, "-"))
+}
+
+// Group splits the given paths by their owners. Groups are sorted by owners, with the
+// unowned files last, and paths keep their order within a group.
+func (o *Owners) Group(paths []string) []*Group {
+	byOwners := map[string]*Group{}
+	for _, p := range paths {
+		owners := o.Of(p)
+		key := strings.Join(owners, " ")
+		g, ok := byOwners[key]
+		if !ok {
+			g = &Group{Owners: owners}
+			byOwners[key] = g
+		}
+		g.Paths = append(g.Paths, p)
+	}
+
+	groups := make([]*Group, 0, len(byOwners))
+	for _, g := range byOwners {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if (len(groups[i].Owners) == 0) != (len(groups[j].Owners) == 0) {
+			return len(groups[j].Owners) == 0
+		}
+		return strings.Join(groups[i].Owners, " ") < strings.Join(groups[j].Owners, " ")
+	})
+	return groups
+}
+
+var slugRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+func slug(s string) string {
+	return strings.Trim(slugRegex.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// compile translates a CODEOWNERS pattern into a regular expression.
+// Patterns containing a slash (other than a trailing one) are relative to the project root,
+// other patterns match at any depth. A pattern matching a directory matches everything inside it.
+func compile(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, errors.New("empty pattern")
+	}
+	dirOnly := strings.HasSuffix(pattern, "/")
+	p := strings.TrimSuffix(pattern, "/")
+	anchored := strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	if !anchored {
+		sb.WriteString("(.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			sb.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case p[i] == '*':
+			sb.WriteString("[^/]*")
+		case p[i] == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		}
+	}
+	if dirOnly {
+		sb.WriteString("/.*$")
+	} else {
+		sb.WriteString("(/.*)?$")
+	}
+	return regexp.Compile(sb.String())
+}
@@ -0,0 +1,116 @@
+package ownership
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOwners_Of(t *testing.T) {
+	o, err := Parse(`# default owners
+*                     @acme/platform
+
+[Services]
+/services/api/        @acme/api-team @alice # comment
+services/web          @acme/web-team
+**/Dockerfile.test    @acme/qa
+docs/*.md             @acme/docs
+/services/legacy/
+`)
+	if err != nil {
+		t.Fatalf("failed to parse CODEOWNERS: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		expected []string
+	}{
+		{"Dockerfile", []string{"@acme/platform"}},
+		{"services/api/Dockerfile", []string{"@acme/api-team", "@alice"}},
+		{"services/api/Dockerfile.test", []string{"@acme/qa"}},
+		{"services/web/Dockerfile", []string{"@acme/web-team"}},
+		{"services/api-gateway/Dockerfile", []string{"@acme/platform"}},
+		{"docs/README.md", []string{"@acme/docs"}},
+		{"docs/guides/setup.md", []string{"@acme/platform"}},
+		{"services/legacy/Dockerfile", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := o.Of(tt.path); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".github"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".github", "CODEOWNERS"), []byte("/services/ @acme/backend\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// only the first CODEOWNERS file found is used
+	if err := os.WriteFile(filepath.Join(dir, "CODEOWNERS"), []byte("* @acme/everyone\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	extra, err := NewRule("/services/worker/", []string{"@acme/jobs"})
+	if err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+	o, err := Load(dir, []*Rule{extra})
+	if err != nil {
+		t.Fatalf("failed to load owners: %v", err)
+	}
+	if o.Source != ".github/CODEOWNERS" {
+		t.Errorf("expected rules from .github/CODEOWNERS, got %q", o.Source)
+	}
+	if got := o.Of("services/api/Dockerfile"); !reflect.DeepEqual(got, []string{"@acme/backend"}) {
+		t.Errorf("expected @acme/backend, got %q", got)
+	}
+	if got := o.Of("services/worker/Dockerfile"); !reflect.DeepEqual(got, []string{"@acme/jobs"}) {
+		t.Errorf("expected configured owners to take precedence, got %q", got)
+	}
+	if got := o.Of("Dockerfile"); got != nil {
+		t.Errorf("expected no owners, got %q", got)
+	}
+
+	o, err = Load(t.TempDir(), nil)
+	if err != nil || o.Source != "" || len(o.Rules) != 0 {
+		t.Errorf("expected no rules without a CODEOWNERS file, got %v (%v)", o, err)
+	}
+}
+
+func TestOwners_Group(t *testing.T) {
+	o, err := Parse("/services/api/ @acme/api-team\n/services/web/ @acme/web-team\n")
+	if err != nil {
+		t.Fatalf("failed to parse CODEOWNERS: %v", err)
+	}
+	groups := o.Group([]string{
+		"services/web/Dockerfile",
+		"Dockerfile",
+		"services/api/Dockerfile",
+		"services/api/worker/Dockerfile",
+	})
+
+	var got [][]string
+	var keys []string
+	for _, g := range groups {
+		got = append(got, g.Paths)
+		keys = append(keys, g.Key())
+	}
+	expected := [][]string{
+		{"services/api/Dockerfile", "services/api/worker/Dockerfile"},
+		{"services/web/Dockerfile"},
+		{"Dockerfile"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected groups %q, got %q", expected, got)
+	}
+	if !reflect.DeepEqual(keys, []string{"acme-api-team", "acme-web-team", "unowned"}) {
+		t.Errorf("unexpected group keys %q", keys)
+	}
+}
@@ -23,17 +23,74 @@ func (p *PackageJSON) Raw() map[string]interface{} {
 }
 
 func (p *PackageJSON) String() string {
+	if p == nil {
+		return ""
+	}
 	return p.rawDataStr
 }
 
-// func (p *PackageJSON) GetScript(name string) (string, error) {
-// 	scripts, ok := p.rawData["scripts"].(map[string]interface{})
-// 	if !ok {
-// 		return "", errors.New("scripts not found in package.json")
-// 	}
-// 	script, ok := scripts[name].(string)
-// 	if !ok {
-// 		return "", nil
-// 	}
-// 	return script, nil
-// }
+// GetScript returns the command of the given npm script.
+// An empty string is returned if the script is not defined.
+func (p *PackageJSON) GetScript(name string) string {
+	scripts, ok := p.rawData["scripts"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	script, _ := scripts[name].(string)
+	return script
+}
+
+// GetMain returns the value of the "main" field, ie, the entrypoint of the package
+func (p *PackageJSON) GetMain() string {
+	main, _ := p.rawData["main"].(string)
+	return main
+}
+
+// GetNodeVersion returns the version constraint of nodejs declared under "engines"
+func (p *PackageJSON) GetNodeVersion() string {
+	engines, ok := p.rawData["engines"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	version, _ := engines["node"].(string)
+	return version
+}
+
+// HasDependency returns true if the given module is declared under dependencies or devDependencies
+func (p *PackageJSON) HasDependency(name string) bool {
+	_, ok := p.GetDependencies()[name]
+	if ok {
+		return true
+	}
+	_, ok = p.GetDevDependencies()[name]
+	return ok
+}
+
+// GetDependencies returns the production dependencies of the package mapped to their versions
+func (p *PackageJSON) GetDependencies() map[string]string {
+	return p.dependencyMap("dependencies")
+}
+
+// GetDevDependencies returns the development dependencies of the package mapped to their versions
+func (p *PackageJSON) GetDevDependencies() map[string]string {
+	return p.dependencyMap("devDependencies")
+}
+
+// GetOptionalDependencies returns the optional dependencies of the package mapped to their versions.
+// They're installed along with the production dependencies, unless they fail to install.
+func (p *PackageJSON) GetOptionalDependencies() map[string]string {
+	return p.dependencyMap("optionalDependencies")
+}
+
+func (p *PackageJSON) dependencyMap(field string) map[string]string {
+	result := map[string]string{}
+	deps, ok := p.rawData[field].(map[string]interface{})
+	if !ok {
+		return result
+	}
+	for name, version := range deps {
+		v, _ := version.(string)
+		result[name] = v
+	}
+	return result
+}
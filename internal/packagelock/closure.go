@@ -0,0 +1,90 @@
+package packagelock
+
+import (
+	"sort"
+	"strings"
+)
+
+// Closure returns the packages installed for the given dependencies of the root project and everything they
+// depend on, sorted by path. Dependencies are resolved the way node resolves them, from the node_modules of
+// the package that requires them up to the one at the root. Workspaces linked in node_modules are followed,
+// and dependencies missing from the lockfile, eg- optional ones for other platforms, are ignored.
+func (l *Lockfile) Closure(roots []string) []*Package {
+	reached := map[string]*Package{}
+	queue := []*Package{}
+	visit := func(from, name string) {
+		p := l.resolve(from, name)
+		if p == nil || reached[p.Path] != nil {
+			return
+		}
+		reached[p.Path] = p
+		queue = append(queue, p)
+	}
+	for _, name := range roots {
+		visit("", name)
+	}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, name := range p.Dependencies {
+			visit(p.Path, name)
+		}
+		// npm 7 and later install the peer dependencies of packages too
+		for _, name := range p.PeerDependencies {
+			visit(p.Path, name)
+		}
+	}
+
+	closure := []*Package{}
+	for _, p := range reached {
+		// workspaces are part of the project, not packages installed in node_modules
+		if strings.Contains(p.Path, "node_modules/") {
+			closure = append(closure, p)
+		}
+	}
+	sort.Slice(closure, func(i, j int) bool { return closure[i].Path < closure[j].Path })
+	return closure
+}
+
+// DevOnly returns the packages that aren't in the closure of the given production dependencies of the root
+// project, ie, those only installed for its devDependencies, sorted by name
+func (l *Lockfile) DevOnly(production []string) []*Package {
+	prod := map[string]bool{}
+	for _, p := range l.Closure(production) {
+		prod[p.Path] = true
+	}
+	dev := []*Package{}
+	for _, p := range l.Packages {
+		if !prod[p.Path] {
+			dev = append(dev, p)
+		}
+	}
+	return dev
+}
+
+// resolve returns the package that node loads when the package at the given path requires name,
+// nil if it isn't installed. An empty path is the root project.
+func (l *Lockfile) resolve(from, name string) *Package {
+	for {
+		key := "node_modules/" + name
+		if from != "" {
+			key = from + "/" + key
+		}
+		if target, ok := l.links[key]; ok {
+			key = target
+		}
+		if p, ok := l.paths[key]; ok {
+			return p
+		}
+		if from == "" {
+			return nil
+		}
+		// eg- from node_modules/a/node_modules/b up to node_modules/a
+		i := strings.LastIndex(from, "/node_modules/")
+		if i < 0 {
+			from = ""
+		} else {
+			from = from[:i]
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package packagelock
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClosure(t *testing.T) {
+	tests := []struct {
+		name     string
+		lockfile string
+		roots    []string
+		closure  []string
+		devOnly  []string
+	}{
+		{
+			name: "nested and hoisted packages",
+			lockfile: `{
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "app", "dependencies": {"express": "^4"}, "devDependencies": {"jest": "^29"}},
+    "node_modules/express": {"version": "4.21.0", "dependencies": {"debug": "2.6.9", "qs": "6.13.0"}},
+    "node_modules/express/node_modules/debug": {"version": "2.6.9", "dependencies": {"ms": "2.0.0"}},
+    "node_modules/express/node_modules/ms": {"version": "2.0.0"},
+    "node_modules/qs": {"version": "6.13.0"},
+    "node_modules/debug": {"version": "4.3.7", "dev": true, "dependencies": {"ms": "^2.1.3"}},
+    "node_modules/ms": {"version": "2.1.3", "dev": true},
+    "node_modules/jest": {"version": "29.7.0", "dev": true, "dependencies": {"debug": "^4"}}
+  }
+}`,
+			roots:   []string{"express"},
+			closure: []string{"node_modules/express", "node_modules/express/node_modules/debug", "node_modules/express/node_modules/ms", "node_modules/qs"},
+			devOnly: []string{"debug", "jest", "ms"},
+		},
+		{
+			name: "peer dependencies and workspaces",
+			lockfile: `{
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "app", "workspaces": ["packages/*"]},
+    "node_modules/api": {"resolved": "packages/api", "link": true},
+    "packages/api": {"name": "api", "dependencies": {"next": "^14"}},
+    "node_modules/next": {"version": "14.2.0", "peerDependencies": {"react": "^18"}},
+    "node_modules/react": {"version": "18.3.1"},
+    "node_modules/typescript": {"version": "5.6.2", "dev": true}
+  }
+}`,
+			roots:   []string{"api", "missing"},
+			closure: []string{"node_modules/next", "node_modules/react"},
+			devOnly: []string{"typescript"},
+		},
+		{
+			name: "lockfile version 1",
+			lockfile: `{
+  "lockfileVersion": 1,
+  "dependencies": {
+    "express": {"version": "4.21.0", "requires": {"debug": "2.6.9"}, "dependencies": {"debug": {"version": "2.6.9"}}},
+    "debug": {"version": "4.3.7", "dev": true},
+    "eslint": {"version": "9.0.0", "dev": true, "requires": {"debug": "^4"}}
+  }
+}`,
+			roots:   []string{"express"},
+			closure: []string{"node_modules/express", "node_modules/express/node_modules/debug"},
+			devOnly: []string{"debug", "eslint"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := Parse([]byte(tt.lockfile))
+			if err != nil {
+				t.Fatalf("failed to parse lockfile: %v", err)
+			}
+			closure := []string{}
+			for _, p := range l.Closure(tt.roots) {
+				closure = append(closure, p.Path)
+			}
+			if !reflect.DeepEqual(closure, tt.closure) {
+				t.Errorf("expected closure %v, got %v", tt.closure, closure)
+			}
+			devOnly := []string{}
+			for _, p := range l.DevOnly(tt.roots) {
+				devOnly = append(devOnly, p.Name)
+			}
+			if !reflect.DeepEqual(devOnly, tt.devOnly) {
+				t.Errorf("expected dev-only packages %v, got %v", tt.devOnly, devOnly)
+			}
+		})
+	}
+}
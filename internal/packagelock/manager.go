@@ -0,0 +1,139 @@
+package packagelock
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"strings"
+)
+
+// Package managers of nodejs projects
+const (
+	ManagerNPM  = "npm"
+	ManagerYarn = "yarn"
+	ManagerPNPM = "pnpm"
+	ManagerBun  = "bun"
+)
+
+// Manager is the package manager that wrote the lockfile of a project
+type Manager struct {
+	Name string
+	// Lockfile is the name of the lockfile at the root of the project, eg- "yarn.lock"
+	Lockfile string
+	// Berry is true if the lockfile is written by yarn 2 or later, whose options differ from yarn 1
+	Berry bool
+}
+
+// lockfiles maps the lockfiles to the package manager that writes them. Projects with more than
+// one are usually migrating away from npm, so its lockfiles come last.
+var lockfiles = []struct {
+	name    string
+	manager string
+}{
+	{"pnpm-lock.yaml", ManagerPNPM},
+	{"yarn.lock", ManagerYarn},
+	{"bun.lock", ManagerBun},
+	{"bun.lockb", ManagerBun},
+	{"npm-shrinkwrap.json", ManagerNPM},
+	{"package-lock.json", ManagerNPM},
+}
+
+// IsLockfile returns true if name is the name of the lockfile of a package manager, eg- "yarn.lock"
+func IsLockfile(name string) bool {
+	for _, l := range lockfiles {
+		if l.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectManager returns the package manager of the project from its lockfile. The "packageManager" field of
+// package.json decides between several lockfiles. nil is returned if the project doesn't have a lockfile.
+func DetectManager(fsys fs.FS) *Manager {
+	declared := ""
+	if content, err := fs.ReadFile(fsys, "package.json"); err == nil {
+		var pkg struct {
+			PackageManager string `json:"packageManager"`
+		}
+		if json.Unmarshal(content, &pkg) == nil {
+			// eg- "pnpm@9.12.0+sha512.4abf..."
+			declared, _, _ = strings.Cut(pkg.PackageManager, "@")
+		}
+	}
+	var found *Manager
+	for _, l := range lockfiles {
+		content, err := fs.ReadFile(fsys, l.name)
+		if err != nil {
+			continue
+		}
+		m := &Manager{Name: l.manager, Lockfile: l.name}
+		// the lockfiles of yarn 2 and later start with the metadata of the lockfile format
+		m.Berry = m.Name == ManagerYarn && bytes.Contains(content, []byte("__metadata:"))
+		if declared == "" || declared == m.Name {
+			return m
+		}
+		if found == nil {
+			found = m
+		}
+	}
+	return found
+}
+
+// FrozenFlag returns the option that makes the install command fail instead of updating the lockfile when it's
+// out of date with package.json. It's empty for npm, whose "ci" command does so.
+func (m *Manager) FrozenFlag() string {
+	switch {
+	case m.Name == ManagerNPM:
+		return ""
+	case m.Berry:
+		return "--immutable"
+	}
+	return "--frozen-lockfile"
+}
+
+// ProductionFlag returns the option of the install command that leaves out devDependencies. It's empty
+// for yarn 2 and later, which can only do so with the workspace-tools plugin.
+func (m *Manager) ProductionFlag() string {
+	switch {
+	case m.Name == ManagerNPM:
+		return "--omit=dev"
+	case m.Name == ManagerPNPM:
+		return "--prod"
+	case m.Berry:
+		return ""
+	}
+	return "--production"
+}
+
+// FrozenInstall returns the words of the command that installs exactly the versions of the lockfile,
+// eg- "npm ci" or "yarn install --frozen-lockfile"
+func (m *Manager) FrozenInstall() []string {
+	if m.Name == ManagerNPM {
+		return []string{"npm", "ci"}
+	}
+	return []string{m.Name, "install", m.FrozenFlag()}
+}
+
+// NeedsCorepack returns true if the package manager isn't part of the official node images and has to be
+// enabled with corepack, eg- pnpm or yarn 2 and later
+func (m *Manager) NeedsCorepack() bool {
+	return m.Name == ManagerPNPM || m.Berry
+}
+
+// PruneCommand returns the words of the command that removes the devDependencies from an installed
+// node_modules, eg- after building the project with them
+func (m *Manager) PruneCommand() []string {
+	switch {
+	case m.Name == ManagerNPM:
+		return []string{"npm", "prune", "--omit=dev"}
+	case m.Name == ManagerPNPM:
+		return []string{"pnpm", "prune", "--prod"}
+	case m.Name == ManagerBun:
+		return []string{"bun", "install", "--production"}
+	case m.Berry:
+		return []string{"yarn", "workspaces", "focus", "--production"}
+	}
+	// yarn 1 doesn't have a prune command, but installing again leaves out what isn't needed
+	return []string{"yarn", "install", "--production", m.FrozenFlag()}
+}
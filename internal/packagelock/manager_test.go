@@ -0,0 +1,79 @@
+package packagelock
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDetectManager(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		expected string
+		frozen   string
+	}{
+		{
+			name:     "npm",
+			files:    fstest.MapFS{"package-lock.json": {Data: []byte("{}")}},
+			expected: "npm:package-lock.json",
+			frozen:   "npm ci",
+		},
+		{
+			name:     "yarn classic",
+			files:    fstest.MapFS{"yarn.lock": {Data: []byte("# yarn lockfile v1\n")}},
+			expected: "yarn:yarn.lock",
+			frozen:   "yarn install --frozen-lockfile",
+		},
+		{
+			name:     "yarn berry",
+			files:    fstest.MapFS{"yarn.lock": {Data: []byte("__metadata:\n  version: 8\n")}},
+			expected: "yarn:yarn.lock",
+			frozen:   "yarn install --immutable",
+		},
+		{
+			name:     "bun",
+			files:    fstest.MapFS{"bun.lockb": {Data: []byte{0}}},
+			expected: "bun:bun.lockb",
+			frozen:   "bun install --frozen-lockfile",
+		},
+		{
+			name: "packageManager decides between lockfiles",
+			files: fstest.MapFS{
+				"package.json":      {Data: []byte(`{"packageManager": "npm@10.8.2"}`)},
+				"pnpm-lock.yaml":    {Data: []byte("lockfileVersion: '9.0'\n")},
+				"package-lock.json": {Data: []byte("{}")},
+			},
+			expected: "npm:package-lock.json",
+			frozen:   "npm ci",
+		},
+		{
+			name:     "pnpm is preferred without packageManager",
+			files:    fstest.MapFS{"pnpm-lock.yaml": {Data: []byte("lockfileVersion: '9.0'\n")}, "package-lock.json": {Data: []byte("{}")}},
+			expected: "pnpm:pnpm-lock.yaml",
+			frozen:   "pnpm install --frozen-lockfile",
+		},
+		{
+			name:  "no lockfile",
+			files: fstest.MapFS{"package.json": {Data: []byte("{}")}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := DetectManager(tt.files)
+			if m == nil {
+				if tt.expected != "" {
+					t.Fatalf("expected %s, got no package manager", tt.expected)
+				}
+				return
+			}
+			if got := m.Name + ":" + m.Lockfile; got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+			if got := strings.Join(m.FrozenInstall(), " "); got != tt.frozen {
+				t.Errorf("expected frozen install %q, got %q", tt.frozen, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+// Package packagelock reads the lockfiles of nodejs projects. The package-lock.json of npm lists every package
+// installed in node_modules, including the dependencies of dependencies, and the other lockfiles tell which
+// package manager the project is installed with.
+package packagelock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Filenames are the lockfiles npm writes, in the order npm prefers them
+var Filenames = []string{"npm-shrinkwrap.json", "package-lock.json"}
+
+// Package is a package installed in node_modules
+type Package struct {
+	Name    string
+	Version string
+	// Path is where the package is installed, eg- "node_modules/ws/node_modules/bufferutil"
+	Path string
+	// Dev is true if the package is only installed for the devDependencies
+	Dev bool
+	// HasInstallScript is true if npm runs a script when installing the package, eg- to compile it with node-gyp.
+	// Lockfiles older than version 2 don't record it.
+	HasInstallScript bool
+	// Dependencies are the names of the packages it depends on, including optional ones
+	Dependencies []string
+	// OptionalDependencies are the names of the packages it depends on that are only installed on some platforms
+	OptionalDependencies []string
+	// PeerDependencies are the names of the packages it expects the application to install along with it
+	PeerDependencies []string
+}
+
+// Lockfile is a parsed package-lock.json
+type Lockfile struct {
+	Packages []*Package
+	// paths indexes the packages and workspaces by their path
+	paths map[string]*Package
+	// links maps the paths of the workspaces linked in node_modules to their directory
+	links map[string]string
+}
+
+// lockfile is the JSON structure of package-lock.json. Version 1 nests the packages under "dependencies",
+// versions 2 and 3 list them under "packages" keyed by their path in node_modules.
+type lockfile struct {
+	LockfileVersion int                     `json:"lockfileVersion"`
+	Packages        map[string]*entry       `json:"packages"`
+	Dependencies    map[string]*legacyEntry `json:"dependencies"`
+}
+
+type entry struct {
+	Name                 string            `json:"name"`
+	Version              string            `json:"version"`
+	Dev                  bool              `json:"dev"`
+	HasInstallScript     bool              `json:"hasInstallScript"`
+	Link                 bool              `json:"link"`
+	Resolved             string            `json:"resolved"`
+	Dependencies         map[string]string `json:"dependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	PeerDependencies     map[string]string `json:"peerDependencies"`
+}
+
+type legacyEntry struct {
+	Version      string                  `json:"version"`
+	Dev          bool                    `json:"dev"`
+	Requires     map[string]string       `json:"requires"`
+	Dependencies map[string]*legacyEntry `json:"dependencies"`
+}
+
+// Parse parses the contents of package-lock.json or npm-shrinkwrap.json
+func Parse(content []byte) (*Lockfile, error) {
+	var raw lockfile
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, err
+	}
+	l := &Lockfile{paths: map[string]*Package{}, links: map[string]string{}}
+	if raw.LockfileVersion >= 2 && raw.Packages != nil {
+		for key, e := range raw.Packages {
+			if e.Link {
+				l.links[key] = e.Resolved
+				continue
+			}
+			p := &Package{
+				Name:                 e.Name,
+				Version:              e.Version,
+				Path:                 key,
+				Dev:                  e.Dev,
+				HasInstallScript:     e.HasInstallScript,
+				Dependencies:         sortedKeys(e.Dependencies, e.OptionalDependencies),
+				OptionalDependencies: sortedKeys(e.OptionalDependencies),
+				PeerDependencies:     sortedKeys(e.PeerDependencies),
+			}
+			l.paths[key] = p
+			// the root project is keyed by an empty path, workspaces by their directory
+			i := strings.LastIndex(key, "node_modules/")
+			if i < 0 {
+				continue
+			}
+			p.Name = key[i+len("node_modules/"):]
+			l.Packages = append(l.Packages, p)
+		}
+	} else {
+		l.addLegacy("", raw.Dependencies)
+	}
+	sort.SliceStable(l.Packages, func(i, j int) bool { return l.Packages[i].Name < l.Packages[j].Name })
+	return l, nil
+}
+
+// addLegacy adds the packages of a version 1 lockfile, which nests packages installed under others
+func (l *Lockfile) addLegacy(parent string, deps map[string]*legacyEntry) {
+	for name, e := range deps {
+		p := &Package{
+			Name:         name,
+			Version:      e.Version,
+			Path:         path.Join(parent, "node_modules", name),
+			Dev:          e.Dev,
+			Dependencies: sortedKeys(e.Requires),
+		}
+		l.Packages = append(l.Packages, p)
+		l.paths[p.Path] = p
+		l.addLegacy(p.Path, e.Dependencies)
+	}
+}
+
+// Read reads the lockfile at the root of the project. The returned error wraps fs.ErrNotExist
+// if the project doesn't have one, eg- because it's installed with yarn or pnpm.
+func Read(fsys fs.FS) (*Lockfile, error) {
+	for _, name := range Filenames {
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			continue
+		}
+		l, err := Parse(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		return l, nil
+	}
+	return nil, fmt.Errorf("no lockfile of npm found: %w", fs.ErrNotExist)
+}
+
+// buildHelpers are packages that native modules depend on to compile their addon or download a prebuilt one
+var buildHelpers = map[string]bool{
+	"node-gyp":             true,
+	"node-gyp-build":       true,
+	"prebuild-install":     true,
+	"node-pre-gyp":         true,
+	"@mapbox/node-pre-gyp": true,
+	"nan":                  true,
+	"node-addon-api":       true,
+	"bindings":             true,
+	"cmake-js":             true,
+}
+
+// scriptOnly are packages whose install scripts don't build or download any native code
+var scriptOnly = map[string]bool{
+	"core-js":           true,
+	"core-js-pure":      true,
+	"es5-ext":           true,
+	"esbuild":           true,
+	"husky":             true,
+	"nodemon":           true,
+	"protobufjs":        true,
+	"styled-components": true,
+}
+
+// knownNative are native modules recognized by name, since version 1 lockfiles don't record install scripts
+var knownNative = map[string]bool{
+	"argon2":         true,
+	"bcrypt":         true,
+	"better-sqlite3": true,
+	"bufferutil":     true,
+	"canvas":         true,
+	"cpu-features":   true,
+	"libpq":          true,
+	"node-pty":       true,
+	"oracledb":       true,
+	"re2":            true,
+	"sharp":          true,
+	"sqlite3":        true,
+	"ssh2":           true,
+	"utf-8-validate": true,
+}
+
+// NativeModule is a package that loads native code, which is compiled or downloaded for the C library
+// (glibc or musl) of the system it's installed on
+type NativeModule struct {
+	Name    string
+	Version string
+	// Reason tells how the package was recognized as native, eg- "depends on node-gyp-build"
+	Reason string
+}
+
+// NativeModules returns the native modules installed for the production dependencies, sorted by name.
+// Packages installed more than once are only returned once.
+func (l *Lockfile) NativeModules() []*NativeModule {
+	modules := []*NativeModule{}
+	seen := map[string]bool{}
+	for _, p := range l.Packages {
+		if p.Dev || seen[p.Name] {
+			continue
+		}
+		if reason := p.nativeReason(); reason != "" {
+			seen[p.Name] = true
+			modules = append(modules, &NativeModule{Name: p.Name, Version: p.Version, Reason: reason})
+		}
+	}
+	return modules
+}
+
+// nativeReason returns why the package is a native module, empty if it isn't one
+func (p *Package) nativeReason() string {
+	for _, dep := range p.Dependencies {
+		if buildHelpers[dep] {
+			return "depends on " + dep
+		}
+	}
+	// eg- sharp installs one of @img/sharp-linux-x64 and @img/sharp-linuxmusl-x64
+	for _, dep := range p.OptionalDependencies {
+		if isMuslVariant(dep) {
+			return "installs prebuilt binaries for either glibc or musl"
+		}
+	}
+	switch {
+	case p.HasInstallScript && !scriptOnly[p.Name]:
+		return "runs an install script"
+	case knownNative[p.Name]:
+		return "is a known native module"
+	}
+	return ""
+}
+
+// isMuslVariant returns true if the name of a package is that of the musl build of prebuilt binaries,
+// eg- "@img/sharp-linuxmusl-x64" or "@swc/core-linux-x64-musl"
+func isMuslVariant(name string) bool {
+	base := path.Base(name)
+	return strings.Contains(base, "linuxmusl") || strings.Contains(base, "linux-musl") || strings.HasSuffix(base, "-musl")
+}
+
+// Names returns the names of the native modules
+func Names(modules []*NativeModule) []string {
+	names := make([]string, 0, len(modules))
+	for _, m := range modules {
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+func sortedKeys(maps ...map[string]string) []string {
+	keys := []string{}
+	for _, m := range maps {
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
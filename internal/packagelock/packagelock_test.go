@@ -0,0 +1,80 @@
+package packagelock
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNativeModules(t *testing.T) {
+	tests := []struct {
+		name     string
+		lockfile string
+		expected []string
+	}{
+		{
+			name: "lockfile version 3",
+			lockfile: `{
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "app", "dependencies": {"bcrypt": "^5.1.1", "sharp": "^0.33.5", "express": "^4"}},
+    "node_modules/bcrypt": {"version": "5.1.1", "hasInstallScript": true, "dependencies": {"@mapbox/node-pre-gyp": "^1.0.11", "node-addon-api": "^5.0.0"}},
+    "node_modules/sharp": {"version": "0.33.5", "hasInstallScript": true, "optionalDependencies": {"@img/sharp-linux-x64": "0.33.5", "@img/sharp-linuxmusl-x64": "0.33.5"}},
+    "node_modules/express": {"version": "4.21.0", "dependencies": {"body-parser": "1.20.3"}},
+    "node_modules/core-js": {"version": "3.38.1", "hasInstallScript": true},
+    "node_modules/node-pty": {"version": "1.0.0", "dev": true, "hasInstallScript": true, "dependencies": {"nan": "^2.17.0"}},
+    "node_modules/ws/node_modules/bufferutil": {"version": "4.0.8", "hasInstallScript": true, "dependencies": {"node-gyp-build": "^4.3.0"}},
+    "packages/api": {"name": "api"}
+  }
+}`,
+			expected: []string{"bcrypt:depends on @mapbox/node-pre-gyp", "bufferutil:depends on node-gyp-build", "sharp:installs prebuilt binaries for either glibc or musl"},
+		},
+		{
+			name: "lockfile version 1",
+			lockfile: `{
+  "lockfileVersion": 1,
+  "dependencies": {
+    "canvas": {"version": "2.11.2", "requires": {"@mapbox/node-pre-gyp": "^1.0.0", "nan": "^2.17.0"}},
+    "sqlite3": {"version": "5.1.7"},
+    "mocha": {"version": "10.0.0", "dev": true, "dependencies": {"fsevents": {"version": "2.3.3", "dev": true, "requires": {"bindings": "^1"}}}},
+    "lodash": {"version": "4.17.21"}
+  }
+}`,
+			expected: []string{"canvas:depends on @mapbox/node-pre-gyp", "sqlite3:is a known native module"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := Parse([]byte(tt.lockfile))
+			if err != nil {
+				t.Fatalf("failed to parse lockfile: %v", err)
+			}
+			found := []string{}
+			for _, m := range l.NativeModules() {
+				found = append(found, m.Name+":"+m.Reason)
+			}
+			if !reflect.DeepEqual(found, tt.expected) {
+				t.Errorf("expected native modules %v, got %v", tt.expected, found)
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	if _, err := Read(fstest.MapFS{}); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist without a lockfile, got %v", err)
+	}
+	if _, err := Read(fstest.MapFS{"package-lock.json": {Data: []byte("{")}}); err == nil {
+		t.Error("expected an error for a malformed lockfile")
+	}
+	l, err := Read(fstest.MapFS{"npm-shrinkwrap.json": {Data: []byte(`{"lockfileVersion": 2, "packages": {"node_modules/argon2": {"version": "0.41.1"}}}`)}})
+	if err != nil {
+		t.Fatalf("failed to read npm-shrinkwrap.json: %v", err)
+	}
+	if names := Names(l.NativeModules()); !reflect.DeepEqual(names, []string{"argon2"}) {
+		t.Errorf("expected argon2 to be native, got %v", names)
+	}
+}
@@ -0,0 +1,91 @@
+// Package pinning pins the base images of a Dockerfile to digests, so that builds
+// don't silently pick up a different image when a tag is moved.
+package pinning
+
+import (
+	"context"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+)
+
+// Resolver resolves an image reference to the digest it currently points to
+type Resolver interface {
+	Digest(ctx context.Context, image string) (string, error)
+}
+
+// Mode decides which base images are pinned
+type Mode int
+
+const (
+	// PinUnpinned pins the images that aren't pinned yet
+	PinUnpinned Mode = iota
+	// UpdatePinned resolves the tags of pinned images again and updates their digests
+	UpdatePinned
+)
+
+// Result is the outcome of pinning the base image of a single stage
+type Result struct {
+	Line     int
+	Previous string
+	// Pinned is the new reference of the image, empty if it couldn't be pinned
+	Pinned string
+	Err    error
+}
+
+// Changed returns true if the stage's FROM instruction was rewritten
+func (r *Result) Changed() bool {
+	return r.Pinned != "" && r.Pinned != r.Previous
+}
+
+// Pin pins the base image of every stage to the digest its tag currently points to,
+// eg- "node:20-alpine" becomes "node:20-alpine@sha256:...". The tag is kept for readability.
+// The mode decides whether unpinned images are pinned or pinned ones are updated.
+// Stages based on other stages, scratch or build arguments are skipped.
+func Pin(ctx context.Context, d *dockerfile.Dockerfile, resolver Resolver, mode Mode) []*Result {
+	stageNames := map[string]bool{}
+	digests := map[string]string{}
+	var results []*Result
+
+	for _, stage := range d.GetStages() {
+		image := stage.BaseImage()
+		name := stage.Name()
+		skip := stageNames[strings.ToLower(image.Name())] ||
+			image.Name() == "scratch" ||
+			strings.Contains(stage.DeclaredBaseImage(), "$")
+		switch mode {
+		case PinUnpinned:
+			skip = skip || image.Digest() != ""
+		case UpdatePinned:
+			// an image pinned without a tag has nothing to be resolved again
+			skip = skip || image.Digest() == "" || image.Tag() == ""
+		}
+		if name != "" {
+			stageNames[strings.ToLower(name)] = true
+		}
+		if skip {
+			continue
+		}
+
+		result := &Result{Line: stage.StartLine(), Previous: image.FullName()}
+		results = append(results, result)
+
+		// resolve the tag, not the pinned digest
+		tagged := image.WithDigest("").FullName()
+		digest, ok := digests[tagged]
+		if !ok {
+			digest, result.Err = resolver.Digest(ctx, tagged)
+			if result.Err != nil {
+				continue
+			}
+			digests[tagged] = digest
+		}
+		pinned := image.WithDigest(digest)
+		result.Pinned = pinned.FullName()
+		if result.Changed() {
+			// stages fetched earlier stay valid since only the image on the FROM line changes
+			d.SetStageBaseImage(stage, pinned)
+		}
+	}
+	return results
+}
@@ -0,0 +1,125 @@
+package pinning
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+)
+
+type fakeResolver struct {
+	digests map[string]string
+	calls   int
+}
+
+func (r *fakeResolver) Digest(ctx context.Context, image string) (string, error) {
+	r.calls++
+	if d, ok := r.digests[image]; ok {
+		return d, nil
+	}
+	return "", errors.New("not found")
+}
+
+func TestPin(t *testing.T) {
+	resolver := &fakeResolver{digests: map[string]string{
+		"node:20-alpine":      "sha256:aaa",
+		"nginx:latest":        "sha256:bbb",
+		"ghcr.io/org/app:1.0": "sha256:ccc",
+	}}
+
+	tests := []struct {
+		name     string
+		mode     Mode
+		input    string
+		expected string
+		results  int
+		errors   int
+	}{
+		{
+			name: "pin unpinned images",
+			mode: PinUnpinned,
+			input: `FROM node:20-alpine AS build
+RUN npm ci
+FROM build AS test
+FROM nginx
+COPY --from=build /app /app`,
+			expected: `FROM node:20-alpine@sha256:aaa AS build
+RUN npm ci
+FROM build AS test
+FROM nginx:latest@sha256:bbb
+COPY --from=build /app /app`,
+			results: 2,
+		},
+		{
+			name: "skip pinned, scratch and arg images",
+			mode: PinUnpinned,
+			input: `ARG BASE=node:20
+FROM ${BASE}
+FROM scratch
+FROM ghcr.io/org/app:1.0@sha256:old`,
+			expected: `ARG BASE=node:20
+FROM ${BASE}
+FROM scratch
+FROM ghcr.io/org/app:1.0@sha256:old`,
+		},
+		{
+			name: "update pinned images",
+			mode: UpdatePinned,
+			input: `FROM node:20-alpine@sha256:old
+FROM node:20-alpine@sha256:old
+FROM nginx
+FROM alpine@sha256:zzz`,
+			expected: `FROM node:20-alpine@sha256:aaa
+FROM node:20-alpine@sha256:aaa
+FROM nginx
+FROM alpine@sha256:zzz`,
+			results: 2,
+		},
+		{
+			name:     "unresolvable image",
+			mode:     PinUnpinned,
+			input:    "FROM unknown:1",
+			expected: "FROM unknown:1",
+			results:  1,
+			errors:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := dockerfile.NewDockerfile(tt.input)
+			if err != nil {
+				t.Fatalf("failed to parse dockerfile: %v", err)
+			}
+			results := Pin(context.Background(), d, resolver, tt.mode)
+			if d.Raw() != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, d.Raw())
+			}
+			if len(results) != tt.results {
+				t.Errorf("expected %d results, got %d", tt.results, len(results))
+			}
+			errs := 0
+			for _, r := range results {
+				if r.Err != nil {
+					errs++
+				}
+			}
+			if errs != tt.errors {
+				t.Errorf("expected %d errors, got %d", tt.errors, errs)
+			}
+		})
+	}
+}
+
+func TestPin_CachesDigests(t *testing.T) {
+	resolver := &fakeResolver{digests: map[string]string{"node:20": "sha256:aaa"}}
+	d, err := dockerfile.NewDockerfile("FROM node:20 AS a\nFROM node:20 AS b\n")
+	if err != nil {
+		t.Fatalf("failed to parse dockerfile: %v", err)
+	}
+	Pin(context.Background(), d, resolver, PinUnpinned)
+	if resolver.calls != 1 {
+		t.Errorf("expected the digest to be resolved once, got %d calls", resolver.calls)
+	}
+}
@@ -0,0 +1,101 @@
+// Package platform parses the platforms images are built for, eg- "linux/arm64".
+package platform
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Platform is an OS and CPU architecture combination, optionally with an architecture variant
+type Platform struct {
+	OS           string
+	Architecture string
+	Variant      string
+}
+
+// architecture names that aren't the ones used by OCI images
+var architectureAliases = map[string]string{
+	"x86_64":  "amd64",
+	"x86-64":  "amd64",
+	"aarch64": "arm64",
+	"armhf":   "arm",
+	"armel":   "arm",
+	"i386":    "386",
+	"x86":     "386",
+}
+
+// Parse parses a platform of the form "os/arch[/variant]". The OS defaults to linux if only the architecture is given.
+// Default variants are dropped so that equal platforms compare equal, eg- "linux/arm64/v8" is "linux/arm64".
+func Parse(s string) (Platform, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(s)), "/")
+	if len(parts) == 1 {
+		parts = append([]string{"linux"}, parts...)
+	}
+	if len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return Platform{}, fmt.Errorf("invalid platform %q, expected os/arch[/variant] like linux/arm64", s)
+	}
+
+	p := Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	if arch, ok := architectureAliases[p.Architecture]; ok {
+		if p.Architecture == "armel" && p.Variant == "" {
+			p.Variant = "v6"
+		}
+		p.Architecture = arch
+	}
+	switch {
+	case p.Architecture == "arm64" && p.Variant == "v8":
+		p.Variant = ""
+	case p.Architecture == "arm" && p.Variant == "":
+		p.Variant = "v7"
+	}
+	return p, nil
+}
+
+// ParseList parses a comma-separated list of platforms, eg- "linux/amd64,linux/arm64".
+// Duplicates are dropped.
+func ParseList(s string) ([]Platform, error) {
+	var platforms []Platform
+	for _, item := range strings.Split(s, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		p, err := Parse(item)
+		if err != nil {
+			return nil, err
+		}
+		if !Contains(platforms, p) {
+			platforms = append(platforms, p)
+		}
+	}
+	return platforms, nil
+}
+
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// Contains returns true if the platform is in the list
+func Contains(platforms []Platform, p Platform) bool {
+	for _, q := range platforms {
+		if q == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Join returns the platforms as a comma-separated list
+func Join(platforms []Platform) string {
+	s := make([]string, len(platforms))
+	for i, p := range platforms {
+		s[i] = p.String()
+	}
+	return strings.Join(s, ", ")
+}
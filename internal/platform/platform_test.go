@@ -0,0 +1,58 @@
+package platform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		err      bool
+	}{
+		{input: "linux/amd64", expected: "linux/amd64"},
+		{input: "arm64", expected: "linux/arm64"},
+		{input: "linux/arm64/v8", expected: "linux/arm64"},
+		{input: "linux/aarch64", expected: "linux/arm64"},
+		{input: "linux/x86_64", expected: "linux/amd64"},
+		{input: "linux/arm", expected: "linux/arm/v7"},
+		{input: "linux/arm/v6", expected: "linux/arm/v6"},
+		{input: " Linux/PPC64LE ", expected: "linux/ppc64le"},
+		{input: "windows/amd64", expected: "windows/amd64"},
+		{input: "linux/", err: true},
+		{input: "linux/arm/v7/extra", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p, err := Parse(tt.input)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error, got %s", p)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p.String() != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, p)
+			}
+		})
+	}
+}
+
+func TestParseList(t *testing.T) {
+	platforms, err := ParseList("linux/amd64, linux/arm64,,linux/arm64/v8")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []Platform{{OS: "linux", Architecture: "amd64"}, {OS: "linux", Architecture: "arm64"}}
+	if !reflect.DeepEqual(platforms, expected) {
+		t.Errorf("expected %v, got %v", expected, platforms)
+	}
+	if Join(platforms) != "linux/amd64, linux/arm64" {
+		t.Errorf("unexpected joined platforms %q", Join(platforms))
+	}
+}
@@ -0,0 +1,263 @@
+// Package plugin implements the protocol dockershrink speaks with plugins, external programs that
+// extend one of its surfaces, eg- a sink delivering reports to a destination dockershrink doesn't support.
+//
+// A plugin is run once per request. It receives a Request as JSON on stdin and writes a Response to stdout.
+// Before it's used, dockershrink sends a "handshake" request announcing the protocol and surface versions it
+// supports, and the plugin answers with a Manifest of what it implements. Plugins built for versions of
+// dockershrink that are too old or too new are rejected before any other request, with a message saying
+// which side needs to be upgraded, so that they can't corrupt a run.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// ProtocolVersion is the newest version of the protocol this version of dockershrink speaks.
+// It's incremented when the envelope of requests and responses changes incompatibly.
+const ProtocolVersion = 1
+
+// MinProtocolVersion is the oldest version of the protocol this version of dockershrink still speaks
+const MinProtocolVersion = 1
+
+// MethodHandshake is the first request sent to every plugin
+const MethodHandshake = "handshake"
+
+// DockershrinkVersion is the version of dockershrink announced to plugins
+var DockershrinkVersion = "dev"
+
+// Kind is a surface of dockershrink that plugins can implement
+type Kind string
+
+const (
+	// KindTool is a tool the LLM can call while optimizing
+	KindTool Kind = "tool"
+	// KindRule is a static rule run by analyze and lint
+	KindRule Kind = "rule"
+	// KindAnalyzer inspects a project to detect facts about it, eg- its framework
+	KindAnalyzer Kind = "analyzer"
+	// KindSink delivers the report of a run to a destination
+	KindSink Kind = "sink"
+)
+
+// Kinds are all the surfaces plugins can implement
+var Kinds = []Kind{KindTool, KindRule, KindAnalyzer, KindSink}
+
+// Range is an inclusive range of versions
+type Range struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+func (r Range) String() string {
+	if r.Min == r.Max {
+		return fmt.Sprint(r.Min)
+	}
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+// Surfaces maps every surface this version of dockershrink loads plugins for to the versions of it that it supports.
+// The version of a surface is incremented when its requests or responses change incompatibly.
+var Surfaces = map[Kind]Range{
+	KindSink: {Min: 1, Max: 1},
+	KindRule: {Min: 1, Max: 1},
+}
+
+// Request is sent to the plugin on stdin
+type Request struct {
+	// ProtocolVersion is the version of the protocol the request is made with.
+	// The handshake is made with the newest version dockershrink speaks, later requests with the one agreed on.
+	ProtocolVersion     int             `json:"protocol_version"`
+	DockershrinkVersion string          `json:"dockershrink_version"`
+	Method              string          `json:"method"`
+	Params              json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is written by the plugin to stdout
+type Response struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	// Error is set if the plugin failed to handle the request
+	Error string `json:"error,omitempty"`
+}
+
+// HandshakeParams announces what dockershrink supports
+type HandshakeParams struct {
+	Protocol Range          `json:"protocol"`
+	Surfaces map[Kind]Range `json:"surfaces"`
+}
+
+// Manifest describes a plugin, it's the result of the handshake
+type Manifest struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// ProtocolVersion is the version of the protocol the plugin speaks
+	ProtocolVersion int          `json:"protocol_version"`
+	Capabilities    []Capability `json:"capabilities"`
+}
+
+// Capability is a surface implemented by the plugin
+type Capability struct {
+	Kind Kind `json:"kind"`
+	// Version is the version of the surface the plugin implements
+	Version int `json:"version"`
+}
+
+// Capability returns the plugin's capability for the given surface, nil if it doesn't implement it
+func (m *Manifest) Capability(kind Kind) *Capability {
+	for i := range m.Capabilities {
+		if m.Capabilities[i].Kind == kind {
+			return &m.Capabilities[i]
+		}
+	}
+	return nil
+}
+
+// IncompatibleError is returned when a plugin can't be used with this version of dockershrink
+type IncompatibleError struct {
+	Plugin string
+	Reason string
+	// Fix is what the user can do about it
+	Fix string
+}
+
+func (e *IncompatibleError) Error() string {
+	return fmt.Sprintf("plugin %s is incompatible with dockershrink %s: %s. %s", e.Plugin, DockershrinkVersion, e.Reason, e.Fix)
+}
+
+// Check returns an IncompatibleError if the plugin doesn't speak a supported version of the protocol
+// or doesn't implement a supported version of the given surface.
+func (m *Manifest) Check(kind Kind) error {
+	incompatible := func(fix, format string, a ...any) error {
+		return &IncompatibleError{Plugin: m.Name, Reason: fmt.Sprintf(format, a...), Fix: fix}
+	}
+	supported := Range{Min: MinProtocolVersion, Max: ProtocolVersion}
+	switch {
+	case m.ProtocolVersion < supported.Min:
+		return incompatible("Upgrade the plugin", "it speaks protocol version %d, but only %s is supported", m.ProtocolVersion, supported)
+	case m.ProtocolVersion > supported.Max:
+		return incompatible("Upgrade dockershrink", "it speaks protocol version %d, but only %s is supported", m.ProtocolVersion, supported)
+	}
+
+	c := m.Capability(kind)
+	if c == nil {
+		return incompatible(fmt.Sprintf("Use a %s plugin instead", kind), "it doesn't implement the %s surface, only: %s", kind, m.kinds())
+	}
+	versions, ok := Surfaces[kind]
+	switch {
+	case !ok:
+		return incompatible("Upgrade dockershrink", "%s plugins aren't supported", kind)
+	case c.Version < versions.Min:
+		return incompatible("Upgrade the plugin", "it implements version %d of the %s surface, but only %s is supported", c.Version, kind, versions)
+	case c.Version > versions.Max:
+		return incompatible("Upgrade dockershrink", "it implements version %d of the %s surface, but only %s is supported", c.Version, kind, versions)
+	}
+	return nil
+}
+
+func (m *Manifest) kinds() string {
+	kinds := make([]string, 0, len(m.Capabilities))
+	for _, c := range m.Capabilities {
+		kinds = append(kinds, fmt.Sprintf("%s (v%d)", c.Kind, c.Version))
+	}
+	if len(kinds) == 0 {
+		return "none"
+	}
+	sort.Strings(kinds)
+	return strings.Join(kinds, ", ")
+}
+
+// Plugin is a plugin the handshake was made with
+type Plugin struct {
+	Manifest *Manifest
+	command  []string
+}
+
+// Start makes the handshake with the plugin run by command and returns it.
+// The plugin still has to be checked for the surface it's used for.
+// Plugins that are WebAssembly modules, eg- rules.wasm, are run in-process with wazero, see runWasm.
+func Start(ctx context.Context, command []string) (*Plugin, error) {
+	if len(command) == 0 {
+		return nil, errors.New("plugin command is empty")
+	}
+	p := &Plugin{command: command}
+	params := &HandshakeParams{Protocol: Range{Min: MinProtocolVersion, Max: ProtocolVersion}, Surfaces: Surfaces}
+	m := &Manifest{}
+	if err := p.call(ctx, ProtocolVersion, MethodHandshake, params, m); err != nil {
+		return nil, fmt.Errorf("handshake with plugin %q failed, it may have been built for an older version of dockershrink: %w", command[0], err)
+	}
+	if m.Name == "" {
+		m.Name = command[0]
+	}
+	p.Manifest = m
+	return p, nil
+}
+
+// Load makes the handshake with the plugin run by command and checks that it can be used for the given surface
+func Load(ctx context.Context, command []string, kind Kind) (*Plugin, error) {
+	p, err := Start(ctx, command)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Manifest.Check(kind); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Call sends a request to the plugin and decodes its result into result, unless it's nil
+func (p *Plugin) Call(ctx context.Context, method string, params, result any) error {
+	return p.call(ctx, p.Manifest.ProtocolVersion, method, params, result)
+}
+
+func (p *Plugin) call(ctx context.Context, protocolVersion int, method string, params, result any) error {
+	req := &Request{ProtocolVersion: protocolVersion, DockershrinkVersion: DockershrinkVersion, Method: method}
+	if params != nil {
+		encoded, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		req.Params = encoded
+	}
+	input, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	var stdout, stderr bytes.Buffer
+	if isWasm(p.command) {
+		err = runWasm(ctx, p.command, bytes.NewReader(input), &stdout, &stderr)
+	} else {
+		cmd := exec.CommandContext(ctx, p.command[0], p.command[1:]...)
+		cmd.Stdin = bytes.NewReader(input)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		err = cmd.Run()
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", method, err, strings.TrimSpace(stderr.String()))
+	}
+
+	resp := &Response{}
+	if err := json.Unmarshal(stdout.Bytes(), resp); err != nil {
+		return fmt.Errorf("%s: failed to parse the response: %w", method, err)
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("%s: %s", method, resp.Error)
+	}
+	if result == nil {
+		return nil
+	}
+	if len(resp.Result) == 0 {
+		return fmt.Errorf("%s: the response has no result", method)
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("%s: failed to parse the result: %w", method, err)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest *Manifest
+		kind     Kind
+		// fix is expected in the error, empty if the plugin is compatible
+		fix string
+	}{
+		{
+			name:     "compatible",
+			manifest: &Manifest{Name: "p", ProtocolVersion: 1, Capabilities: []Capability{{Kind: KindSink, Version: 1}}},
+			kind:     KindSink,
+		},
+		{
+			name:     "old protocol",
+			manifest: &Manifest{Name: "p", ProtocolVersion: 0, Capabilities: []Capability{{Kind: KindSink, Version: 1}}},
+			kind:     KindSink,
+			fix:      "Upgrade the plugin",
+		},
+		{
+			name:     "new protocol",
+			manifest: &Manifest{Name: "p", ProtocolVersion: ProtocolVersion + 1, Capabilities: []Capability{{Kind: KindSink, Version: 1}}},
+			kind:     KindSink,
+			fix:      "Upgrade dockershrink",
+		},
+		{
+			name:     "missing capability",
+			manifest: &Manifest{Name: "p", ProtocolVersion: 1, Capabilities: []Capability{{Kind: KindRule, Version: 1}}},
+			kind:     KindSink,
+			fix:      "Use a sink plugin instead",
+		},
+		{
+			name:     "unsupported surface",
+			manifest: &Manifest{Name: "p", ProtocolVersion: 1, Capabilities: []Capability{{Kind: KindAnalyzer, Version: 1}}},
+			kind:     KindAnalyzer,
+			fix:      "Upgrade dockershrink",
+		},
+		{
+			name:     "new surface version",
+			manifest: &Manifest{Name: "p", ProtocolVersion: 1, Capabilities: []Capability{{Kind: KindSink, Version: 2}}},
+			kind:     KindSink,
+			fix:      "Upgrade dockershrink",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.manifest.Check(tt.kind)
+			if tt.fix == "" {
+				if err != nil {
+					t.Errorf("Check() = %v, expected no error", err)
+				}
+				return
+			}
+			var incompatible *IncompatibleError
+			if !errors.As(err, &incompatible) {
+				t.Fatalf("Check() = %v, expected an IncompatibleError", err)
+			}
+			if incompatible.Fix != tt.fix {
+				t.Errorf("Fix = %q, want %q", incompatible.Fix, tt.fix)
+			}
+		})
+	}
+}
+
+// writePlugin writes a plugin script that answers the handshake with manifest and every other request with result
+func writePlugin(t *testing.T, manifest, result string) string {
+	script := filepath.Join(t.TempDir(), "plugin.sh")
+	content := "#!/bin/sh\nrequest=$(cat)\ncase \"$request\" in\n" +
+		"  *'\"method\":\"handshake\"'*) echo '{\"result\": " + manifest + "}' ;;\n" +
+		"  *) echo '{\"result\": " + result + "}' ;;\nesac\n"
+	if err := os.WriteFile(script, []byte(content), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+func TestLoad(t *testing.T) {
+	script := writePlugin(t, `{"name": "echo", "version": "0.1.0", "protocol_version": 1, "capabilities": [{"kind": "sink", "version": 1}]}`, `{"ok": true}`)
+	p, err := Load(context.Background(), []string{script}, KindSink)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if p.Manifest.Name != "echo" || p.Manifest.Version != "0.1.0" {
+		t.Errorf("unexpected manifest %+v", p.Manifest)
+	}
+	result := struct {
+		OK bool `json:"ok"`
+	}{}
+	if err := p.Call(context.Background(), "sink.send", map[string]string{"run_id": "1"}, &result); err != nil || !result.OK {
+		t.Errorf("Call() = %v, %+v", err, result)
+	}
+
+	if _, err := Load(context.Background(), []string{script}, KindRule); err == nil {
+		t.Error("Load() expected an error for a surface the plugin doesn't implement")
+	}
+}
+
+func TestLoad_OldPlugin(t *testing.T) {
+	// a plugin that predates the handshake and doesn't understand it
+	script := filepath.Join(t.TempDir(), "plugin.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ncat > /dev/null\necho 'report sent'\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Load(context.Background(), []string{script}, KindSink)
+	if err == nil || !strings.Contains(err.Error(), "handshake") {
+		t.Errorf("Load() = %v, expected the handshake to fail", err)
+	}
+}
+
+// wasmModule returns a WASI command that writes output to stdout, whatever its input
+func wasmModule(output string) []byte {
+	uleb := func(n int) []byte {
+		b := []byte{}
+		for {
+			c := byte(n & 0x7f)
+			if n >>= 7; n != 0 {
+				c |= 0x80
+			}
+			b = append(b, c)
+			if n == 0 {
+				return b
+			}
+		}
+	}
+	vec := func(count int, items ...[]byte) []byte {
+		return append(uleb(count), bytes.Join(items, nil)...)
+	}
+	name := func(s string) []byte {
+		return append(uleb(len(s)), s...)
+	}
+	section := func(id byte, payload []byte) []byte {
+		return append(append([]byte{id}, uleb(len(payload))...), payload...)
+	}
+
+	// the iovec at 0 points to output at 16, the number of bytes written is stored at 8
+	data := binary.LittleEndian.AppendUint32(nil, 16)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(output)))
+	data = append(append(data, make([]byte, 8)...), output...)
+	// fd_write(1, 0, 1, 8), then drop its result
+	code := []byte{0x00, 0x41, 0x01, 0x41, 0x00, 0x41, 0x01, 0x41, 0x08, 0x10, 0x00, 0x1a, 0x0b}
+
+	return bytes.Join([][]byte{
+		{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00},
+		section(1, vec(2, []byte{0x60, 0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x01, 0x7f}, []byte{0x60, 0x00, 0x00})),
+		section(2, vec(1, name("wasi_snapshot_preview1"), name("fd_write"), []byte{0x00, 0x00})),
+		section(3, vec(1, []byte{0x01})),
+		section(5, vec(1, []byte{0x00, 0x01})),
+		section(7, vec(2, name("memory"), []byte{0x02, 0x00}, name("_start"), []byte{0x00, 0x01})),
+		section(10, vec(1, uleb(len(code)), code)),
+		section(11, vec(1, []byte{0x00, 0x41, 0x00, 0x0b}, vec(len(data), data))),
+	}, nil)
+}
+
+func TestLoad_Wasm(t *testing.T) {
+	module := filepath.Join(t.TempDir(), "sink.wasm")
+	manifest := `{"result": {"name": "wasm-sink", "version": "1.0.0", "protocol_version": 1, "capabilities": [{"kind": "sink", "version": 1}]}}`
+	if err := os.WriteFile(module, wasmModule(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Load(context.Background(), []string{module}, KindSink)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if p.Manifest.Name != "wasm-sink" {
+		t.Errorf("unexpected manifest %+v", p.Manifest)
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.wasm")
+	if err := os.WriteFile(invalid, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(context.Background(), []string{invalid}, KindSink); err == nil || !strings.Contains(err.Error(), "failed to compile") {
+		t.Errorf("Load() = %v, expected the module to fail to compile", err)
+	}
+}
@@ -0,0 +1,52 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// wasmCache keeps the modules compiled, since a new runtime is created for every request
+var wasmCache = wazero.NewCompilationCache()
+
+// isWasm returns true if the plugin run by command is a WebAssembly module, eg- rules.wasm
+func isWasm(command []string) bool {
+	return len(command) > 0 && strings.EqualFold(filepath.Ext(command[0]), ".wasm")
+}
+
+// runWasm runs the WebAssembly module run by command in-process with wazero, as a WASI command whose arguments
+// are command. The module isn't given access to files, the network or the environment, so that it can only read
+// its request from stdin and write its response to stdout. It's stopped when ctx is done.
+func runWasm(ctx context.Context, command []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	code, err := os.ReadFile(command[0])
+	if err != nil {
+		return fmt.Errorf("failed to read the WebAssembly module: %w", err)
+	}
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCompilationCache(wasmCache).WithCloseOnContextDone(true))
+	defer runtime.Close(ctx)
+	wasi_snapshot_preview1.MustInstantiate(ctx, runtime)
+
+	compiled, err := runtime.CompileModule(ctx, code)
+	if err != nil {
+		return fmt.Errorf("failed to compile the WebAssembly module: %w", err)
+	}
+	config := wazero.NewModuleConfig().WithName("").WithArgs(command...).WithStdin(stdin).WithStdout(stdout).WithStderr(stderr)
+	module, err := runtime.InstantiateModule(ctx, compiled, config)
+	if module != nil {
+		module.Close(ctx)
+	}
+	// WASI commands exit with proc_exit, which ends the module with an error even when they succeed
+	var exit *sys.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == 0 {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,311 @@
+// Package policy loads the policies of an organization, which every image definition must comply with,
+// eg- the approved base images and registries. Dockerfiles are checked against them by the rules and
+// they're given to the LLM, so that optimized and generated Dockerfiles comply with them.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// UserNonRoot is the required user that allows any user but root
+const UserNonRoot = "non-root"
+
+// defaultRegistry is the registry of images whose names don't start with one
+const defaultRegistry = "docker.io"
+
+// Policy is a pack of requirements for image definitions, eg- the base images approved by the organization
+type Policy struct {
+	// Name identifies the policy in findings, defaults to the name of its file
+	Name string `yaml:"name"`
+	// Severity is the severity of violations, high by default
+	Severity models.Severity `yaml:"severity"`
+	// AllowedBaseImages are patterns of the base images stages can be built from, eg- node:*-alpine, where * matches
+	// any characters. A pattern without a tag matches every tag. Any base image is allowed if it's empty.
+	AllowedBaseImages []string `yaml:"allowed_base_images"`
+	// AllowedRegistries are the registries base images can be pulled from, optionally followed by a path,
+	// eg- ghcr.io/acme. Images without a registry are pulled from docker.io. Any registry is allowed if it's empty.
+	AllowedRegistries []string `yaml:"allowed_registries"`
+	// RequiredUser is the user the final stage must run as: non-root for any user but root, or the name or UID of
+	// a user. The user isn't checked if it's empty.
+	RequiredUser string `yaml:"required_user"`
+	// BannedInstructions are instructions Dockerfiles must not use
+	BannedInstructions []*BannedInstruction `yaml:"banned_instructions"`
+	// MaxImageSize is the maximum estimated size of the image, eg- 300MB. The size isn't checked if it's empty.
+	MaxImageSize string `yaml:"max_image_size"`
+
+	baseImages   []*regexp.Regexp
+	maxImageSize int64
+}
+
+// BannedInstruction is an instruction Dockerfiles must not use, eg- ADD from URLs
+type BannedInstruction struct {
+	// Instruction is the name of the instruction, eg- ADD
+	Instruction string `yaml:"instruction"`
+	// Arguments is a pattern the instruction is only banned with, eg- http* for ADD from URLs, where * matches
+	// any characters. The instruction is banned whatever its arguments if it's empty.
+	Arguments string `yaml:"arguments"`
+	// Reason explains why the instruction is banned and what to do instead
+	Reason string `yaml:"reason"`
+
+	arguments *regexp.Regexp
+}
+
+// Load reads the policy at path
+func Load(path string) (*Policy, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %w", err)
+	}
+	p := &Policy{}
+	if err := yaml.Unmarshal(content, p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy %s: %w", path, err)
+	}
+	if p.Name == "" {
+		p.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := p.compile(); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// compile validates the policy and prepares its patterns
+func (p *Policy) compile() error {
+	if p.Severity == "" {
+		p.Severity = models.SeverityHigh
+	}
+	switch p.Severity {
+	case models.SeverityInfo, models.SeverityLow, models.SeverityMedium, models.SeverityHigh:
+	default:
+		return fmt.Errorf("invalid severity %q, must be one of high, medium, low or info", p.Severity)
+	}
+	p.baseImages = nil
+	for _, pattern := range p.AllowedBaseImages {
+		p.baseImages = append(p.baseImages, imagePattern(pattern))
+	}
+	for i, r := range p.AllowedRegistries {
+		if strings.TrimSpace(r) == "" {
+			return fmt.Errorf("allowed_registries[%d] is empty", i)
+		}
+	}
+	if p.RequiredUser == "root" || p.RequiredUser == "0" {
+		return errors.New("required_user can't be root, leave it empty to allow any user")
+	}
+	for i, b := range p.BannedInstructions {
+		if !dockerfile.IsKnownInstruction(b.Instruction) {
+			return fmt.Errorf("banned_instructions[%d]: unknown instruction %q", i, b.Instruction)
+		}
+		b.Instruction = strings.ToUpper(b.Instruction)
+		if b.Arguments != "" {
+			b.arguments = wildcard(b.Arguments)
+		}
+	}
+	p.maxImageSize = 0
+	if p.MaxImageSize != "" {
+		size, err := ParseSize(p.MaxImageSize)
+		if err != nil {
+			return fmt.Errorf("max_image_size: %w", err)
+		}
+		p.maxImageSize = size
+	}
+	return nil
+}
+
+// AllowsBaseImage returns true if stages can be built from the image
+func (p *Policy) AllowsBaseImage(image *dockerfile.Image) bool {
+	if len(p.baseImages) == 0 {
+		return true
+	}
+	ref := normalizeName(image.Name())
+	if image.Tag() != "" {
+		ref += dockerfile.NameTagSep + image.Tag()
+	}
+	for _, re := range p.baseImages {
+		if re.MatchString(ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsRegistry returns true if the image can be pulled from its registry
+func (p *Policy) AllowsRegistry(image *dockerfile.Image) bool {
+	if len(p.AllowedRegistries) == 0 {
+		return true
+	}
+	repository := Repository(image)
+	for _, r := range p.AllowedRegistries {
+		r = strings.TrimSuffix(r, "/")
+		if repository == r || strings.HasPrefix(repository, r+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsUser returns true if the final stage can run as the user, empty if no USER instruction sets it
+func (p *Policy) AllowsUser(user string) bool {
+	switch p.RequiredUser {
+	case "":
+		return true
+	case UserNonRoot:
+		name, _, _ := strings.Cut(user, ":")
+		return name != "" && name != "root" && name != "0"
+	default:
+		name, _, _ := strings.Cut(user, ":")
+		return name == p.RequiredUser
+	}
+}
+
+// Bans returns the ban the instruction violates, nil if it's allowed
+func (p *Policy) Bans(inst *dockerfile.Instruction) *BannedInstruction {
+	for _, b := range p.BannedInstructions {
+		if inst.Cmd() != b.Instruction {
+			continue
+		}
+		if b.arguments == nil {
+			return b
+		}
+		for _, arg := range inst.ExpandedArgs() {
+			if b.arguments.MatchString(arg) {
+				return b
+			}
+		}
+	}
+	return nil
+}
+
+// MaxImageSizeBytes returns the maximum estimated size of the image in bytes, 0 if it isn't checked
+func (p *Policy) MaxImageSizeBytes() int64 {
+	return p.maxImageSize
+}
+
+// Describe lists the requirements of the policy, one per line, eg- for the LLM
+func (p *Policy) Describe() string {
+	lines := []string{}
+	if len(p.AllowedBaseImages) > 0 {
+		lines = append(lines, fmt.Sprintf("Every stage must be built from one of these base images, where * matches any characters and a name without a tag matches every tag: %s", strings.Join(p.AllowedBaseImages, ", ")))
+	}
+	if len(p.AllowedRegistries) > 0 {
+		lines = append(lines, fmt.Sprintf("Base images must be pulled from these registries, images without a registry are pulled from %s: %s", defaultRegistry, strings.Join(p.AllowedRegistries, ", ")))
+	}
+	switch p.RequiredUser {
+	case "":
+	case UserNonRoot:
+		lines = append(lines, "The final stage must run as a non-root user, set with a USER instruction")
+	default:
+		lines = append(lines, fmt.Sprintf("The final stage must run as the user %s, set with a USER instruction", p.RequiredUser))
+	}
+	for _, b := range p.BannedInstructions {
+		line := fmt.Sprintf("Never use %s", b.Instruction)
+		if b.Arguments != "" {
+			line += fmt.Sprintf(" with arguments matching %s", b.Arguments)
+		}
+		if b.Reason != "" {
+			line += ": " + b.Reason
+		}
+		lines = append(lines, line)
+	}
+	if p.maxImageSize > 0 {
+		lines = append(lines, fmt.Sprintf("The final image must not be bigger than %s", p.MaxImageSize))
+	}
+	for i, l := range lines {
+		lines[i] = "- " + l
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Describe lists the requirements of all the policies, eg- for the LLM. It's empty if there are none.
+func Describe(policies []*Policy) string {
+	descriptions := []string{}
+	for _, p := range policies {
+		if d := p.Describe(); d != "" {
+			descriptions = append(descriptions, d)
+		}
+	}
+	return strings.Join(descriptions, "\n")
+}
+
+// Repository returns the registry and path of the image, eg- docker.io/library/node for node:22
+func Repository(image *dockerfile.Image) string {
+	name := image.Name()
+	first, rest, found := strings.Cut(name, "/")
+	// like docker, the first component is a registry if it looks like a host
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return first + "/" + rest
+	}
+	if !found {
+		return defaultRegistry + "/library/" + name
+	}
+	return defaultRegistry + "/" + name
+}
+
+// ParseSize parses a size in bytes, optionally followed by a unit, eg- 300MB, 1.5GB or 512KiB.
+// Units are powers of 1024 like the sizes dockershrink prints.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	number, unit := s, ""
+	if i >= 0 {
+		number, unit = s[:i], strings.ToUpper(strings.TrimSpace(s[i:]))
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q, eg- 300MB", s)
+	}
+	multiplier, ok := sizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid unit in size %q, must be one of B, KB, MB, GB or TB", s)
+	}
+	return int64(n * multiplier), nil
+}
+
+// sizeUnits are the multipliers of the units of sizes, eg- M, MB and MiB are all mebibytes
+var sizeUnits = map[string]float64{"": 1, "B": 1}
+
+func init() {
+	for i, prefix := range []string{"K", "M", "G", "T"} {
+		multiplier := float64(int64(1) << (10 * (i + 1)))
+		for _, unit := range []string{prefix, prefix + "B", prefix + "IB"} {
+			sizeUnits[unit] = multiplier
+		}
+	}
+}
+
+// imagePattern compiles a pattern of base images. A pattern without a tag matches every tag.
+func imagePattern(pattern string) *regexp.Regexp {
+	name, tag := pattern, ""
+	if i := strings.LastIndex(pattern, dockerfile.NameTagSep); i > strings.LastIndex(pattern, "/") {
+		name, tag = pattern[:i], pattern[i+1:]
+	}
+	if tag == "" {
+		tag = "*"
+	}
+	return wildcard(normalizeName(name) + dockerfile.NameTagSep + tag)
+}
+
+// normalizeName strips the default registry from the name of an image, eg- docker.io/library/node is node
+func normalizeName(name string) string {
+	for _, prefix := range []string{"docker.io/library/", "index.docker.io/library/", "docker.io/", "index.docker.io/"} {
+		if strings.HasPrefix(name, prefix) {
+			return strings.TrimPrefix(name, prefix)
+		}
+	}
+	return name
+}
+
+// wildcard compiles a pattern matching whole strings, where * matches any characters
+func wildcard(pattern string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(pattern)
+	return regexp.MustCompile("^" + strings.ReplaceAll(quoted, `\*`, ".*") + "$")
+}
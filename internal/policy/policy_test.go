@@ -0,0 +1,220 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{
+			name: "valid",
+			content: `allowed_base_images: ["node:*-alpine"]
+required_user: non-root
+banned_instructions:
+  - instruction: add
+    arguments: http*
+max_image_size: 300MB
+`,
+		},
+		{name: "invalid severity", content: "severity: critical\n", err: `invalid severity "critical"`},
+		{name: "empty registry", content: "allowed_registries: ['']\n", err: "allowed_registries[0] is empty"},
+		{name: "root user", content: "required_user: root\n", err: "required_user can't be root"},
+		{name: "unknown instruction", content: "banned_instructions:\n  - instruction: FETCH\n", err: `unknown instruction "FETCH"`},
+		{name: "invalid size", content: "max_image_size: 300XB\n", err: "invalid unit"},
+		{name: "invalid YAML", content: "allowed_base_images: node\n", err: "failed to parse policy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "acme.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			p, err := Load(path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p.Name != "acme" || p.Severity != models.SeverityHigh {
+				t.Errorf("expected the name of the file and high severity by default, got %q and %q", p.Name, p.Severity)
+			}
+			if p.BannedInstructions[0].Instruction != "ADD" {
+				t.Errorf("expected the banned instruction to be uppercased, got %q", p.BannedInstructions[0].Instruction)
+			}
+			if p.MaxImageSizeBytes() != 300*1024*1024 {
+				t.Errorf("expected a maximum size of 300MB, got %d", p.MaxImageSizeBytes())
+			}
+		})
+	}
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing policy")
+	}
+}
+
+func compiled(t *testing.T, p *Policy) *Policy {
+	t.Helper()
+	if err := p.compile(); err != nil {
+		t.Fatalf("failed to compile policy: %v", err)
+	}
+	return p
+}
+
+func TestAllowsBaseImage(t *testing.T) {
+	p := compiled(t, &Policy{AllowedBaseImages: []string{"node:*-alpine", "gcr.io/distroless/nodejs*", "ghcr.io/acme/*"}})
+	tests := []struct {
+		image    string
+		expected bool
+	}{
+		{"node:22-alpine", true},
+		{"docker.io/library/node:22-alpine", true},
+		{"node:22-alpine@sha256:abc", true},
+		{"node:22", false},
+		{"node", false},
+		{"gcr.io/distroless/nodejs22-debian12:nonroot", true},
+		{"gcr.io/distroless/static", false},
+		{"ghcr.io/acme/base:1.0", true},
+		{"ghcr.io/other/base:1.0", false},
+	}
+	for _, tt := range tests {
+		if got := p.AllowsBaseImage(dockerfile.NewImage(tt.image)); got != tt.expected {
+			t.Errorf("AllowsBaseImage(%s) = %t; want %t", tt.image, got, tt.expected)
+		}
+	}
+
+	if !compiled(t, &Policy{}).AllowsBaseImage(dockerfile.NewImage("ubuntu")) {
+		t.Error("expected a policy without allowed base images to allow any")
+	}
+}
+
+func TestAllowsRegistry(t *testing.T) {
+	p := compiled(t, &Policy{AllowedRegistries: []string{"docker.io/library", "ghcr.io/acme/"}})
+	tests := []struct {
+		image    string
+		expected bool
+	}{
+		{"node:22", true},
+		{"docker.io/library/node:22", true},
+		{"bitnami/node:22", false},
+		{"ghcr.io/acme/base", true},
+		{"ghcr.io/acme-forks/base", false},
+		{"localhost:5000/node", false},
+	}
+	for _, tt := range tests {
+		if got := p.AllowsRegistry(dockerfile.NewImage(tt.image)); got != tt.expected {
+			t.Errorf("AllowsRegistry(%s) = %t; want %t", tt.image, got, tt.expected)
+		}
+	}
+}
+
+func TestAllowsUser(t *testing.T) {
+	tests := []struct {
+		required string
+		user     string
+		expected bool
+	}{
+		{"", "", true},
+		{UserNonRoot, "", false},
+		{UserNonRoot, "root", false},
+		{UserNonRoot, "0:0", false},
+		{UserNonRoot, "node", true},
+		{UserNonRoot, "1000:1000", true},
+		{"app", "app:app", true},
+		{"app", "node", false},
+	}
+	for _, tt := range tests {
+		p := &Policy{RequiredUser: tt.required}
+		if got := p.AllowsUser(tt.user); got != tt.expected {
+			t.Errorf("AllowsUser(%q) with required user %q = %t; want %t", tt.user, tt.required, got, tt.expected)
+		}
+	}
+}
+
+func TestBans(t *testing.T) {
+	p := compiled(t, &Policy{BannedInstructions: []*BannedInstruction{
+		{Instruction: "ADD", Arguments: "http*"},
+		{Instruction: "maintainer"},
+	}})
+	df, err := dockerfile.NewDockerfile(`FROM node:22
+MAINTAINER someone
+ADD https://example.com/tool.tgz /tmp/
+ADD src /app/src
+`)
+	if err != nil {
+		t.Fatalf("failed to parse Dockerfile: %v", err)
+	}
+	expected := []bool{true, true, false}
+	for i, inst := range df.GetStages()[0].Instructions() {
+		if got := p.Bans(inst) != nil; got != expected[i] {
+			t.Errorf("Bans(%s) = %t; want %t", inst.Cmd(), got, expected[i])
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size     string
+		expected int64
+		err      bool
+	}{
+		{size: "1024", expected: 1024},
+		{size: "10B", expected: 10},
+		{size: "512KiB", expected: 512 * 1024},
+		{size: "300MB", expected: 300 * 1024 * 1024},
+		{size: "300 mb", expected: 300 * 1024 * 1024},
+		{size: "1.5G", expected: 1536 * 1024 * 1024},
+		{size: "", err: true},
+		{size: "-1MB", err: true},
+		{size: "MB", err: true},
+		{size: "10PB", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.size)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseSize(%q) = %d; want an error", tt.size, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.expected {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", tt.size, got, err, tt.expected)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	if d := Describe(nil); d != "" {
+		t.Errorf("expected no description without policies, got %q", d)
+	}
+	policies := []*Policy{
+		compiled(t, &Policy{AllowedBaseImages: []string{"node:*-alpine"}, RequiredUser: UserNonRoot}),
+		compiled(t, &Policy{}),
+		compiled(t, &Policy{
+			BannedInstructions: []*BannedInstruction{{Instruction: "ADD", Arguments: "http*", Reason: "Download files with curl and verify their checksum."}},
+			MaxImageSize:       "300MB",
+		}),
+	}
+	expected := `- Every stage must be built from one of these base images, where * matches any characters and a name without a tag matches every tag: node:*-alpine
+- The final stage must run as a non-root user, set with a USER instruction
+- Never use ADD with arguments matching http*: Download files with curl and verify their checksum.
+- The final image must not be bigger than 300MB`
+	if got := Describe(policies); got != expected {
+		t.Errorf("unexpected description:\n%s\nwant:\n%s", got, expected)
+	}
+}
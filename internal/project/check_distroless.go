@@ -0,0 +1,457 @@
+package project
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/distroless"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+const (
+	// runtimeStageName is given to the original final stage if it has no name, the migrated final stage copies
+	// the application from it
+	runtimeStageName = "runtime"
+	chiselStageName  = "chisel"
+	// nonrootUID is the unprivileged user of distroless images, which chiseled images run as too
+	nonrootUID = "65532"
+)
+
+var (
+	// shells run the command they're given through a shell, which distroless and chiseled images don't have
+	shells = map[string]bool{"sh": true, "bash": true, "ash": true, "dash": true, "zsh": true}
+	// packageManagers run the scripts of package.json, they aren't part of distroless and chiseled images
+	packageManagers = map[string]bool{"npm": true, "yarn": true, "pnpm": true}
+	// metadataCmds only describe how the container runs, so they're carried over to the migrated final stage
+	metadataCmds = map[string]bool{dockerfile.CmdEnv: true, dockerfile.CmdExpose: true, dockerfile.CmdLabel: true, "STOPSIGNAL": true, "VOLUME": true}
+)
+
+// migrateFinalStage turns the final stage into a build stage and appends a new final stage built from a distroless
+// or chiseled image, which copies the application, its runtime and the shared libraries it loads from it.
+// libraries finds the shared libraries of the executable the container starts, nil if it can't be run.
+// Final stages that can't be migrated safely, eg- because they start the application with a shell script,
+// get a recommendation instead.
+func (p *Project) migrateFinalStage(ctx context.Context, target distroless.Target, libraries distroless.LibraryResolver) {
+	final, err := p.dockerfile.GetFinalStage()
+	if err != nil {
+		return
+	}
+	chain := p.stageChain(final)
+	root := chain[len(chain)-1]
+	base := root.BaseImage()
+	if p.baseIsNamedContext(root) || distroless.IsMinimal(base) || strings.Contains(base.FullName(), "$") {
+		return
+	}
+	filepath := p.directory.GetDockerfileFilePath()
+	recommend := func(reason string) {
+		p.addRecommendation(&models.OptimizationAction{
+			Rule:        "migrate-final-stage",
+			Risk:        models.RiskBehavior,
+			Filepath:    filepath,
+			Line:        final.StartLine(),
+			Title:       fmt.Sprintf("Migrate the final stage to a %s image", target),
+			Description: fmt.Sprintf("%s images have no shell or package manager, which makes them smaller and leaves an attacker who gets into the container without tools. The final stage wasn't migrated automatically: %s.", target, reason),
+		})
+	}
+
+	entrypoint, cmd, err := p.startCommand(chain)
+	if err != nil {
+		recommend(err.Error())
+		return
+	}
+	argv := append(append([]string{}, entrypoint...), cmd...)
+	kind := distroless.KindOf(base)
+	if distroless.IsInterpreter(argv[0]) && kind == distroless.KindBinary {
+		recommend(fmt.Sprintf("the application runs with '%s', which was installed in '%s' rather than coming with the official image of its runtime, so it isn't known which of its files to copy. Build the final stage from the official image first", argv[0], base.FullName()))
+		return
+	}
+
+	workdirs := dockerfile.Workdirs(p.dockerfile.GetStages())
+	workdir := workdirs[final.Index()][len(final.Instructions())]
+	executable := argv[0]
+	if strings.Contains(executable, "/") {
+		executable = dockerfile.ResolvePath(workdir, executable)
+	}
+
+	// distroless images of a runtime come with its interpreter and everything it loads
+	notes := []string{}
+	var found []string
+	static := false
+	if kind == distroless.KindBinary || kind == distroless.KindPython || target == distroless.TargetChiseled {
+		if libraries == nil {
+			notes = append(notes, fmt.Sprintf("The shared libraries '%s' loads weren't checked since the original image couldn't be run, copy those 'ldd %s' lists from the original stage.", executable, executable))
+		} else if found, err = libraries.SharedLibraries(ctx, executable); err != nil {
+			notes = append(notes, fmt.Sprintf("The shared libraries '%s' loads couldn't be listed (%v), copy those it needs from the original stage.", executable, err))
+		} else {
+			static = len(found) == 0
+		}
+	}
+	runtime, err := distroless.NewRuntime(target, base, static)
+	if err != nil {
+		recommend(err.Error())
+		return
+	}
+
+	name := final.Name()
+	if name == "" {
+		name = p.unusedStageName(runtimeStageName)
+		if err := p.dockerfile.SetStageName(final, name); err != nil {
+			return
+		}
+	}
+	final, _ = p.dockerfile.GetFinalStage()
+	chain = p.stageChain(final)
+
+	files := append([]string{}, runtime.Copied...)
+	if workdir != dockerfile.RootDir {
+		files = append(files, workdir)
+	}
+	for _, s := range chain {
+		for i, inst := range s.Instructions() {
+			dest := inst.Destination()
+			if dest == "" {
+				continue
+			}
+			dest = strings.TrimSuffix(dockerfile.ResolvePath(workdirs[s.Index()][i], dest), "/")
+			if dest == "" || strings.Contains(dest, "$") {
+				notes = append(notes, fmt.Sprintf("The files copied to '%s' on line %d aren't copied into the migrated stage, copy those the application needs.", inst.Destination(), inst.StartLine()))
+				continue
+			}
+			files = append(files, dest)
+		}
+	}
+	if kind == distroless.KindBinary && !strings.Contains(argv[0], "/") && !copiedFile(files, executable) {
+		notes = append(notes, fmt.Sprintf("'%s' doesn't seem to be copied into the image, if it was installed with a package manager copy its configuration and data files from the original stage too.", executable))
+	}
+
+	installed, customCerts := chainSystemPackages(chain)
+	certs := kind != distroless.KindBinary || customCerts || installed["ca-certificates"]
+	tzdata := installed["tzdata"] || chainSetsEnv(chain, "TZ")
+	if customCerts {
+		files = append(files, "/etc/ssl/certs")
+	}
+	extra := []string{}
+	for pkg := range installed {
+		if pkg != "ca-certificates" && pkg != "tzdata" {
+			extra = append(extra, pkg)
+		}
+	}
+	if len(extra) > 0 {
+		sort.Strings(extra)
+		notes = append(notes, fmt.Sprintf("Only the shared libraries of the system packages installed in the final stage (%s) are copied, copy any other files of them the application uses from the original stage.", strings.Join(extra, ", ")))
+	}
+
+	libs := []string{}
+	for _, l := range found {
+		if !runtime.IsProvided(l) && !copiedFile(files, l) {
+			libs = append(libs, l)
+		}
+	}
+
+	lines := []string{}
+	if target == distroless.TargetChiseled {
+		chisel := p.unusedStageName(chiselStageName)
+		lines = append(lines, distroless.ChiselStage(chisel, runtime.Slices(certs, tzdata)), "")
+		lines = append(lines, "FROM "+runtime.Image, fmt.Sprintf("COPY --from=%s /rootfs /", chisel))
+	} else {
+		lines = append(lines, "FROM "+runtime.Image)
+	}
+	for _, f := range topLevelFiles(files) {
+		lines = append(lines, fmt.Sprintf("COPY --from=%s %s %s", name, f, f))
+	}
+	lines = append(lines, copyLibraries(name, libs)...)
+	if workdir != dockerfile.RootDir {
+		lines = append(lines, "WORKDIR "+workdir)
+	}
+	for _, env := range runtime.Env {
+		lines = append(lines, "ENV "+env)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		for _, inst := range chain[i].Instructions() {
+			if metadataCmds[inst.Cmd()] {
+				lines = append(lines, inst.Original())
+			}
+			if inst.Cmd() == dockerfile.CmdHealthcheck && !strings.EqualFold(strings.Join(inst.Args(), " "), "NONE") {
+				notes = append(notes, fmt.Sprintf("The HEALTHCHECK on line %d wasn't carried over since the commands it runs aren't in the image, add one the application can run by itself.", inst.StartLine()))
+			}
+		}
+	}
+	if user := chainUser(chain); user != "" && !rootUsers[user] {
+		if !isNumericUser(user) {
+			notes = append(notes, fmt.Sprintf("The user '%s' doesn't exist in the image, so it runs as the unprivileged user %s instead. Files the application writes to must be owned by it, eg- 'COPY --chown=%s'.", user, nonrootUID, nonrootUID))
+			user = nonrootUID
+		}
+		lines = append(lines, "USER "+user)
+	}
+	lines = append(lines, startInstructions(runtime, entrypoint, cmd)...)
+
+	at := final.StartLine()
+	if instructions := final.Instructions(); len(instructions) > 0 {
+		at = instructions[len(instructions)-1].EndLine()
+	}
+	if err := p.dockerfile.InsertAfter(at, dockerfile.Linebreak+strings.Join(lines, dockerfile.Linebreak)); err != nil {
+		recommend(fmt.Sprintf("the new final stage could not be added: %v", err))
+		return
+	}
+
+	image := runtime.Image
+	if target == distroless.TargetChiseled {
+		image = "a chiseled Ubuntu image"
+	}
+	copied := "the application"
+	if len(libs) > 0 {
+		copied = fmt.Sprintf("the application and the shared libraries it loads (%s)", strings.Join(libs, ", "))
+	}
+	p.addActionTaken(&models.OptimizationAction{
+		Rule:        "migrate-final-stage",
+		Risk:        models.RiskBehavior,
+		Filepath:    filepath,
+		Line:        at + 2,
+		Title:       fmt.Sprintf("Migrated the final stage to %s", image),
+		Description: strings.TrimSpace(fmt.Sprintf("The final image is now built from %s, which has no shell or package manager. The original final stage is kept as the '%s' stage and %s is copied from it. Commands like 'docker exec sh' don't work in the new image. %s", image, name, copied, strings.Join(notes, " "))),
+	})
+}
+
+// startCommand returns the ENTRYPOINT and CMD the container of the chain starts with, in exec form. An error
+// explains why they can't run without a shell, eg- a shell script or "npm start" whose script isn't known.
+func (p *Project) startCommand(chain []*dockerfile.Stage) ([]string, []string, error) {
+	var entrypoint, cmd *dockerfile.Instruction
+	for _, s := range chain {
+		instructions := s.Instructions()
+		for i := len(instructions) - 1; i >= 0; i-- {
+			switch instructions[i].Cmd() {
+			case dockerfile.CmdEntrypoint:
+				if entrypoint == nil {
+					entrypoint = instructions[i]
+				}
+			case dockerfile.CmdCmd:
+				if cmd == nil {
+					cmd = instructions[i]
+				}
+			}
+		}
+	}
+	if entrypoint == nil && cmd == nil {
+		return nil, nil, fmt.Errorf("the final stage has no CMD or ENTRYPOINT, so the command the application runs with isn't known")
+	}
+	var entrypointArgs, cmdArgs []string
+	for _, c := range []struct {
+		inst *dockerfile.Instruction
+		args *[]string
+	}{{entrypoint, &entrypointArgs}, {cmd, &cmdArgs}} {
+		if c.inst == nil {
+			continue
+		}
+		// the CMD isn't passed to an ENTRYPOINT in shell form
+		if c.inst == cmd && entrypoint != nil && !entrypoint.IsJSONForm() {
+			continue
+		}
+		args := c.inst.Args()
+		if !c.inst.IsJSONForm() {
+			var ok bool
+			if args, ok = splitCommand(strings.Join(args, " ")); !ok {
+				return nil, nil, fmt.Errorf("'%s' on line %d needs a shell to run, which the image doesn't have. Rewrite it in exec form, eg- CMD [\"node\", \"server.js\"]", c.inst.Original(), c.inst.StartLine())
+			}
+		}
+		*c.args = args
+	}
+
+	argv := append(append([]string{}, entrypointArgs...), cmdArgs...)
+	if len(argv) == 0 {
+		return nil, nil, fmt.Errorf("the command the application runs with is empty")
+	}
+	executable := path.Base(argv[0])
+	if shells[executable] {
+		return nil, nil, fmt.Errorf("the application is started with %s, which the image doesn't have. Start it with its executable in exec form instead, eg- CMD [\"node\", \"server.js\"]", executable)
+	}
+	if packageManagers[executable] {
+		script := p.packageScript(argv)
+		if script == nil {
+			return nil, nil, fmt.Errorf("the application is started with '%s', but %s isn't part of the image. Start it with node in exec form instead, eg- CMD [\"node\", \"server.js\"]", strings.Join(argv, " "), executable)
+		}
+		return nil, script, nil
+	}
+	return entrypointArgs, cmdArgs, nil
+}
+
+// packageScript returns the command of the package.json script a package manager runs, eg- "node server.js" for
+// "npm start", as long as it runs node without a shell. nil is returned otherwise.
+func (p *Project) packageScript(argv []string) []string {
+	if p.packageJSON == nil || len(argv) < 2 {
+		return nil
+	}
+	name := argv[1]
+	if name == "run" || name == "run-script" {
+		if len(argv) < 3 {
+			return nil
+		}
+		name = argv[2]
+	}
+	args, ok := splitCommand(p.packageJSON.GetScript(name))
+	if !ok || path.Base(args[0]) != "node" {
+		return nil
+	}
+	return args
+}
+
+// splitCommand returns the words of a shell command if it's a single simple command that doesn't need a shell
+// to run, ie, without variables, globs, redirections or operators
+func splitCommand(command string) ([]string, bool) {
+	script, err := rules.ParseScript(command)
+	if err != nil || len(script.Statements()) != 1 {
+		return nil, false
+	}
+	st := script.Statements()[0]
+	if _, ok := st.Cmd.(*syntax.CallExpr); !ok || st.Negated || st.Background || len(st.Redirs) > 0 {
+		return nil, false
+	}
+	c := script.Commands()[0]
+	if len(c.Assignments) > 0 {
+		return nil, false
+	}
+	argv := c.Argv()
+	if len(argv) == 0 {
+		return nil, false
+	}
+	for _, a := range argv {
+		if strings.ContainsAny(a, "$*?~`") {
+			return nil, false
+		}
+	}
+	return argv, true
+}
+
+// startInstructions returns the ENTRYPOINT and CMD of the migrated final stage. The interpreter is replaced by
+// its path in the migrated image, and the image's own ENTRYPOINT is overridden unless it runs the same interpreter.
+func startInstructions(runtime *distroless.Runtime, entrypoint, cmd []string) []string {
+	exec := func(instruction string, args []string) string {
+		encoded, _ := json.Marshal(args)
+		return instruction + " " + string(encoded)
+	}
+	interpreter := func(args []string) []string {
+		args = append([]string{}, args...)
+		if len(args) > 0 && runtime.Interpreter != "" && distroless.IsInterpreter(args[0]) {
+			args[0] = runtime.Interpreter
+			if runtime.Kind == distroless.KindPython {
+				// python, python3 and python3.12 are all in /usr/local/bin of the official image
+				args[0] = path.Join(path.Dir(runtime.Interpreter), path.Base(args[0]))
+			}
+		}
+		return args
+	}
+
+	if len(entrypoint) > 0 {
+		lines := []string{exec(dockerfile.CmdEntrypoint, interpreter(entrypoint))}
+		if len(cmd) > 0 {
+			lines = append(lines, exec(dockerfile.CmdCmd, cmd))
+		}
+		return lines
+	}
+	if len(runtime.Entrypoint) == 0 {
+		return []string{exec(dockerfile.CmdCmd, interpreter(cmd))}
+	}
+	if len(runtime.Entrypoint) == 1 && distroless.IsInterpreter(cmd[0]) {
+		return []string{exec(dockerfile.CmdCmd, cmd[1:])}
+	}
+	return []string{exec(dockerfile.CmdEntrypoint, interpreter(cmd))}
+}
+
+// chainSystemPackages returns the system packages installed by the RUN instructions of the chain, and whether
+// custom CA certificates are added to the system's, eg- with update-ca-certificates
+func chainSystemPackages(chain []*dockerfile.Stage) (map[string]bool, bool) {
+	installed := map[string]bool{}
+	customCerts := false
+	for _, s := range chain {
+		for _, inst := range s.Instructions() {
+			if strings.HasPrefix(inst.Destination(), "/usr/local/share/ca-certificates") {
+				customCerts = true
+			}
+			if inst.Cmd() != dockerfile.CmdRun || inst.IsJSONForm() {
+				continue
+			}
+			script, err := rules.ParseScript(inst.Command())
+			if err != nil {
+				continue
+			}
+			for _, install := range rules.SystemInstalls(script) {
+				for _, pkg := range install.Packages {
+					name, _, _ := strings.Cut(pkg, "=")
+					installed[name] = true
+				}
+			}
+			for _, c := range script.Commands() {
+				if c.Name() == "update-ca-certificates" {
+					customCerts = true
+				}
+			}
+		}
+	}
+	return installed, customCerts
+}
+
+// unusedStageName returns name, followed by a number if a stage already has it
+func (p *Project) unusedStageName(name string) string {
+	candidate := name
+	for i := 2; p.dockerfile.GetStage(candidate) != nil; i++ {
+		candidate = fmt.Sprintf("%s-%d", name, i)
+	}
+	return candidate
+}
+
+// copiedFile returns true if the file is one of the copied paths or inside one of them
+func copiedFile(copied []string, file string) bool {
+	for _, c := range copied {
+		if file == c || strings.HasPrefix(file, strings.TrimSuffix(c, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// topLevelFiles returns the paths without those inside another one of them, sorted
+func topLevelFiles(files []string) []string {
+	sorted := append([]string{}, files...)
+	sort.Strings(sorted)
+	top := []string{}
+	for _, f := range sorted {
+		if !copiedFile(top, f) {
+			top = append(top, f)
+		}
+	}
+	return top
+}
+
+// copyLibraries returns the COPY instructions that copy the shared libraries from the stage, one per directory
+func copyLibraries(stage string, libs []string) []string {
+	dirs := []string{}
+	byDir := map[string][]string{}
+	for _, l := range libs {
+		dir := path.Dir(l)
+		if _, ok := byDir[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], l)
+	}
+	lines := []string{}
+	for _, dir := range dirs {
+		lines = append(lines, fmt.Sprintf("COPY --from=%s %s %s/", stage, strings.Join(byDir[dir], " "), dir))
+	}
+	return lines
+}
+
+// isNumericUser returns true if the user is given as a UID, optionally with a GID, eg- "1000:1000"
+func isNumericUser(user string) bool {
+	for _, part := range strings.Split(user, ":") {
+		if part == "" || strings.Trim(part, "0123456789") != "" {
+			return false
+		}
+	}
+	return true
+}
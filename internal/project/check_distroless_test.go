@@ -0,0 +1,134 @@
+package project
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/distroless"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/packagejson"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+)
+
+// fakeLibraries returns the same shared libraries for every executable
+type fakeLibraries []string
+
+func (f fakeLibraries) SharedLibraries(ctx context.Context, executable string) ([]string, error) {
+	return f, nil
+}
+
+func TestMigrateFinalStage(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		target      distroless.Target
+		libraries   distroless.LibraryResolver
+		packageJSON string
+		// expected is the code appended to the Dockerfile, empty if the final stage isn't migrated
+		expected        string
+		recommendations int
+	}{
+		{
+			name:   "node on distroless",
+			input:  "FROM node:22-slim\nWORKDIR /app\nCOPY package*.json ./\nRUN npm ci --omit=dev\nCOPY src ./src\nENV NODE_ENV=production\nEXPOSE 3000\nUSER node\nCMD [\"node\", \"src/server.js\"]\n",
+			target: distroless.TargetDistroless,
+			expected: `FROM gcr.io/distroless/nodejs22-debian12
+COPY --from=runtime /app /app
+WORKDIR /app
+ENV NODE_ENV=production
+EXPOSE 3000
+USER 65532
+CMD ["src/server.js"]
+`,
+		},
+		{
+			name:        "npm start",
+			input:       "FROM node:20-bookworm AS app\nWORKDIR /app\nCOPY . .\nCMD npm start\n",
+			target:      distroless.TargetDistroless,
+			packageJSON: `{"scripts": {"start": "node index.js"}}`,
+			expected: `FROM gcr.io/distroless/nodejs20-debian12
+COPY --from=app /app /app
+WORKDIR /app
+CMD ["index.js"]
+`,
+		},
+		{
+			name:      "go binary with shared libraries",
+			input:     "FROM golang:1.23 AS build\nRUN go build -o /server .\n\nFROM debian:12-slim\nRUN apt-get update && apt-get install -y --no-install-recommends libpq5 ca-certificates && rm -rf /var/lib/apt/lists/*\nCOPY --from=build /server /usr/local/bin/server\nENTRYPOINT [\"/usr/local/bin/server\"]\nCMD [\"--port\", \"8080\"]\n",
+			target:    distroless.TargetDistroless,
+			libraries: fakeLibraries{"/lib/x86_64-linux-gnu/libc.so.6", "/usr/lib/x86_64-linux-gnu/libpq.so.5", "/usr/lib/x86_64-linux-gnu/libssl.so.3", "/lib64/ld-linux-x86-64.so.2"},
+			expected: `FROM gcr.io/distroless/cc-debian12
+COPY --from=runtime /usr/local/bin/server /usr/local/bin/server
+COPY --from=runtime /usr/lib/x86_64-linux-gnu/libpq.so.5 /usr/lib/x86_64-linux-gnu/libssl.so.3 /usr/lib/x86_64-linux-gnu/
+ENTRYPOINT ["/usr/local/bin/server"]
+CMD ["--port","8080"]
+`,
+		},
+		{
+			name:      "static binary on chiseled",
+			input:     "FROM debian:12\nCOPY server /server\nENV TZ=Europe/Berlin\nCMD [\"/server\"]\n",
+			target:    distroless.TargetChiseled,
+			libraries: fakeLibraries{},
+			expected: distroless.ChiselStage("chisel", []string{"base-files_base", "base-files_release-info", "libc6_libs", "libgcc-s1_libs", "libstdc++6_libs", "tzdata_zoneinfo"}) + `
+
+FROM scratch
+COPY --from=chisel /rootfs /
+COPY --from=runtime /server /server
+ENV TZ=Europe/Berlin
+CMD ["/server"]
+`,
+		},
+		{
+			name:            "shell script",
+			input:           "FROM node:22-slim\nCOPY . .\nCMD node server.js > /var/log/app.log\n",
+			target:          distroless.TargetDistroless,
+			recommendations: 1,
+		},
+		{
+			name:            "alpine",
+			input:           "FROM node:22-alpine\nCOPY . .\nCMD [\"node\", \"server.js\"]\n",
+			target:          distroless.TargetDistroless,
+			recommendations: 1,
+		},
+		{
+			name:   "already distroless",
+			input:  "FROM node:22 AS build\nRUN npm ci\nFROM gcr.io/distroless/nodejs22-debian12\nCOPY --from=build /app /app\nCMD [\"/app/server.js\"]\n",
+			target: distroless.TargetDistroless,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.input)
+			if err != nil {
+				t.Fatalf("failed to parse dockerfile: %v", err)
+			}
+			var pj *packagejson.PackageJSON
+			if tt.packageJSON != "" {
+				if pj, err = packagejson.NewPackageJSON(tt.packageJSON); err != nil {
+					t.Fatal(err)
+				}
+			}
+			fs := restrictedfilesystem.NewRestrictedFilesystem("/tmp", "", "Dockerfile", ".dockerignore")
+			p := NewProject(df, nil, pj, fs, nil, "")
+
+			p.migrateFinalStage(context.Background(), tt.target, tt.libraries)
+			if len(p.recommendations) != tt.recommendations {
+				t.Errorf("expected %d recommendations, got %d", tt.recommendations, len(p.recommendations))
+			}
+			if tt.expected == "" {
+				if p.dockerfile.Raw() != tt.input {
+					t.Errorf("expected the Dockerfile to be unchanged, got:\n%s", p.dockerfile.Raw())
+				}
+				return
+			}
+			if !strings.HasSuffix(p.dockerfile.Raw(), "\n\n"+tt.expected) {
+				t.Errorf("expected the migrated stage:\n%s\ngot:\n%s", tt.expected, p.dockerfile.Raw())
+			}
+			if len(p.actionsTaken) != 1 {
+				t.Errorf("expected 1 action, got %d", len(p.actionsTaken))
+			}
+		})
+	}
+}
@@ -8,6 +8,9 @@ import (
 
 	"github.com/duaraghav8/dockershrink/internal/dockerfile"
 	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/packagelock"
+	"github.com/duaraghav8/dockershrink/internal/platform"
+	"github.com/duaraghav8/dockershrink/internal/rules"
 )
 
 const (
@@ -65,8 +68,12 @@ func (p *Project) finalStageLightBaseImage() {
 	finalStage, _ := p.dockerfile.GetFinalStage()
 	finalStageBaseImage := finalStage.BaseImage()
 
-	if isAlpineOrSlim(finalStageBaseImage) {
-		// a light image is already being used, nothing to do, exit
+	if isAlpineOrSlim(finalStageBaseImage) || p.dockerfile.GetBaseStage(finalStage) != nil || p.baseIsNamedContext(finalStage) {
+		// a light image is already being used or the stage is built from another one or a named context, nothing to do, exit
+		return
+	}
+	if strings.Contains(finalStageBaseImage.FullName(), "$") {
+		// the image depends on build arguments without a default, so it isn't known
 		return
 	}
 
@@ -75,15 +82,85 @@ func (p *Project) finalStageLightBaseImage() {
 		tag := getNodeAlpineEquivalentTagForImage(finalStageBaseImage)
 		preferredImage = dockerfile.NewImage(fmt.Sprintf("node:%s", tag))
 	}
+	details := ""
+	if rec := p.baseImages.Recommend(finalStageBaseImage, p.platforms); rec != nil {
+		if rec.Unsupported || rec.Deprecated {
+			glibc := ""
+			if slim, native := rules.GlibcVariant(p.rulesContext(), finalStage, dockerfile.NewImage(rec.Suggested)); slim != nil {
+				glibc = glibcDetails(slim, dockerfile.NewImage(rec.Suggested), native)
+				rec = p.baseImages.Substitute(rec, slim)
+			}
+			// upgrading the release is left to the developer since it can break the application
+			p.addRecommendation(&models.OptimizationAction{
+				Rule:        rule,
+				Risk:        models.RiskBehavior,
+				Filepath:    p.directory.GetDockerfileFilePath(),
+				Title:       "Upgrade the base image of the final stage to a supported release",
+				Description: fmt.Sprintf("%s Use '%s' instead.%s", rec.Details(), rec.Suggested, glibc),
+			})
+			if rec.SameRelease != "" {
+				preferredImage = dockerfile.NewImage(rec.SameRelease)
+			}
+		} else {
+			preferredImage = dockerfile.NewImage(rec.Suggested)
+			if d := rec.Details(); d != "" {
+				details = " " + d
+			}
+		}
+	}
+
+	// native modules built against glibc fail to load on alpine, which uses musl
+	if slim, native := rules.GlibcVariant(p.rulesContext(), finalStage, preferredImage); slim != nil {
+		details = glibcDetails(slim, preferredImage, native)
+		preferredImage = slim
+	}
+
+	if check := p.baseImages.CheckPlatforms(preferredImage, p.platforms); check != nil {
+		// a smaller image that can't be built for every target platform is no improvement
+		p.addRecommendation(&models.OptimizationAction{
+			Rule:        rule,
+			Risk:        models.RiskBehavior,
+			Filepath:    p.directory.GetDockerfileFilePath(),
+			Title:       "Use a smaller base image for the final image produced",
+			Description: fmt.Sprintf("'%s' is based on a full operating system distribution. Its smaller variant '%s' isn't published for %s, so pick a small base image that is published for all target platforms (%s).", finalStageBaseImage.FullName(), preferredImage.FullName(), platform.Join(check.Missing), platform.Join(p.platforms)),
+		})
+		return
+	}
+	for _, pol := range p.policies {
+		if !pol.AllowsBaseImage(preferredImage) || !pol.AllowsRegistry(preferredImage) {
+			// the organization's approved images come first, even if they're bigger
+			p.addRecommendation(&models.OptimizationAction{
+				Rule:        rule,
+				Risk:        models.RiskBehavior,
+				Filepath:    p.directory.GetDockerfileFilePath(),
+				Title:       "Use a smaller base image for the final image produced",
+				Description: fmt.Sprintf("'%s' is based on a full operating system distribution. Its smaller variant '%s' isn't approved by the %s policy, so pick the smallest of the approved base images.", finalStageBaseImage.FullName(), preferredImage.FullName(), pol.Name),
+			})
+			return
+		}
+	}
+
+	if declared := finalStage.DeclaredBaseImage(); strings.Contains(declared, "$") {
+		// the image is chosen with build arguments, which may be set to something else when building
+		p.addRecommendation(&models.OptimizationAction{
+			Rule:        rule,
+			Risk:        models.RiskBehavior,
+			Filepath:    p.directory.GetDockerfileFilePath(),
+			Title:       "Use a smaller base image for the final image produced",
+			Description: fmt.Sprintf("The final stage is built from '%s', which is '%s' by default. Use '%s' instead, eg- by changing the default value of the build argument.%s", declared, finalStageBaseImage.FullName(), preferredImage.FullName(), details),
+		})
+		return
+	}
 
 	if p.dockerfile.GetStageCount() == 1 {
 		// In case of a single stage, we'll only give a recommendation.
 		// This is because this stage is probably building and/or testing, and we don't want to cause limitations in that.
 		rec := &models.OptimizationAction{
 			Rule:        rule,
+			Risk:        models.RiskBehavior,
 			Filepath:    p.directory.GetDockerfileFilePath(),
 			Title:       "Use a smaller base image for the final image produced",
-			Description: fmt.Sprintf("Use '%s' instead of '%s' as the base image. This will significantly decrease the final image's size. This practice is best combined with Multistage builds. The final stage of your Dockerfile must use a slim base image. Since all testing and build processes take place in a previous stage, dev dependencies and a heavy distro isn't really needed in the final image. Enable AI to generate code for multistage build.", preferredImage.FullName(), finalStageBaseImage.FullName()),
+			Description: fmt.Sprintf("Use '%s' instead of '%s' as the base image. This will significantly decrease the final image's size. This practice is best combined with Multistage builds. The final stage of your Dockerfile must use a slim base image. Since all testing and build processes take place in a previous stage, dev dependencies and a heavy distro isn't really needed in the final image. Enable AI to generate code for multistage build.%s", preferredImage.FullName(), finalStageBaseImage.FullName(), details),
 		}
 		p.addRecommendation(rec)
 		return
@@ -93,11 +170,34 @@ func (p *Project) finalStageLightBaseImage() {
 	log.Printf("Setting new (smaller) base image for the final stage of multistage Dockerfile: %s", preferredImage.FullName())
 	p.dockerfile.SetStageBaseImage(finalStage, preferredImage)
 
+	// the smaller image lacks libraries the full one has, which the dependencies may load at runtime
+	if packages, family := rules.MissingRuntimePackages(p.rulesContext()); len(packages) > 0 {
+		code := "RUN " + rules.SystemInstallCommand(family, packages)
+		if err := p.dockerfile.InsertAfter(finalStage.StartLine(), code); err == nil {
+			details += fmt.Sprintf(" Installed %s in the final stage, which the application's dependencies load at runtime.", strings.Join(packages, ", "))
+		}
+	}
+
 	action := &models.OptimizationAction{
 		Rule:        rule,
+		Risk:        models.RiskBehavior,
 		Filepath:    p.directory.GetDockerfileFilePath(),
 		Title:       "Used a new, smaller base image for the final stage in Multistage Dockerfile",
-		Description: fmt.Sprintf("Used '%s' instead of '%s' as the base image of the final stage. This becomes the base image of the final image produced, reducing the size significantly.", preferredImage.FullName(), finalStageBaseImage.FullName()),
+		Description: fmt.Sprintf("Used '%s' instead of '%s' as the base image of the final stage. This becomes the base image of the final image produced, reducing the size significantly.%s", preferredImage.FullName(), finalStageBaseImage.FullName(), details),
 	}
 	p.addActionTaken(action)
 }
+
+// glibcDetails tells why the slim variant of a node image is used rather than its alpine variant
+func glibcDetails(slim, alpine *dockerfile.Image, native []*packagelock.NativeModule) string {
+	return fmt.Sprintf(" '%s' is chosen over '%s' since the native modules %s are built against glibc, while alpine uses musl.", slim.FullName(), alpine.FullName(), strings.Join(packagelock.Names(native), ", "))
+}
+
+// baseImagesOf returns the base images of all stages of the Dockerfile
+func baseImagesOf(d *dockerfile.Dockerfile) []*dockerfile.Image {
+	var images []*dockerfile.Image
+	for _, stage := range d.GetStages() {
+		images = append(images, stage.BaseImage())
+	}
+	return images
+}
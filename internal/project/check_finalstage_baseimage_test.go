@@ -1,9 +1,14 @@
 package project
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/duaraghav8/dockershrink/internal/baseimages"
 	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
 )
 
 func TestIsAlpineOrSlim(t *testing.T) {
@@ -56,3 +61,106 @@ func TestGetNodeAlpineEquivalentTagForImage(t *testing.T) {
 		})
 	}
 }
+
+// sharpLockfile has sharp, whose prebuilt binaries are downloaded for the C library of the stage installing it
+const sharpLockfile = `{"lockfileVersion": 3, "packages": {"node_modules/sharp": {"version": "0.33.5", "hasInstallScript": true, "dependencies": {"node-addon-api": "^8.0.0"}}}}`
+
+func TestFinalStageLightBaseImage(t *testing.T) {
+	build := "FROM node:24 AS build\nWORKDIR /app\nRUN npm ci && npm run build\n\n"
+	tests := []struct {
+		name            string
+		code            string
+		lockfile        string
+		expected        string
+		actions         int
+		recommendations int
+		// suggests is the image the recommendations suggest, node:24-alpine by default
+		suggests string
+	}{
+		{
+			name:     "final stage image is replaced",
+			code:     build + "FROM node:24\nCOPY --from=build /app/dist /app\n",
+			expected: build + "FROM node:24-alpine\nCOPY --from=build /app/dist /app\n",
+			actions:  1,
+		},
+		{
+			name:            "image chosen with a build argument is only recommended",
+			code:            "ARG BASE=node:24\n" + build + "FROM ${BASE}\nCOPY --from=build /app/dist /app\n",
+			recommendations: 1,
+		},
+		{
+			name: "image of a build argument without a default is unknown",
+			code: "ARG BASE\n" + build + "FROM ${BASE}\nCOPY --from=build /app/dist /app\n",
+		},
+		{
+			name:     "native modules built on debian keep a glibc image with their libraries",
+			code:     build + "FROM node:24\nCOPY --from=build /app /app\n",
+			lockfile: `{"lockfileVersion": 3, "packages": {"node_modules/pg-native": {"version": "3.2.0", "dependencies": {"libpq": "^1.8.13"}}, "node_modules/libpq": {"version": "1.8.13", "hasInstallScript": true, "dependencies": {"bindings": "1.5.0", "nan": "^2.19.0"}}}}`,
+			expected: build + "FROM node:24-slim\nRUN apt-get update && apt-get install -y --no-install-recommends libpq5 && rm -rf /var/lib/apt/lists/*\nCOPY --from=build /app /app\n",
+			actions:  1,
+		},
+		{
+			name:     "native modules built on alpine",
+			code:     "FROM node:24-alpine AS build\nRUN npm ci\n\nFROM node:24\nCOPY --from=build /app /app\n",
+			lockfile: `{"lockfileVersion": 3, "packages": {"node_modules/bcrypt": {"version": "5.1.1", "dependencies": {"node-addon-api": "^5.0.0"}}}}`,
+			expected: "FROM node:24-alpine AS build\nRUN npm ci\n\nFROM node:24-alpine\nCOPY --from=build /app /app\n",
+			actions:  1,
+		},
+		{
+			name:            "native modules built on debian keep a glibc image when upgrading a release",
+			code:            build + "FROM node:16\nCOPY --from=build /app /app\n",
+			lockfile:        sharpLockfile,
+			expected:        build + "FROM node:16-slim\nCOPY --from=build /app /app\n",
+			actions:         1,
+			recommendations: 1,
+			suggests:        "node:24-slim",
+		},
+		{
+			name: "final stage built from another stage",
+			code: build + "FROM build\nCMD [\"node\", \"dist/index.js\"]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.code)
+			if err != nil {
+				t.Fatalf("failed to parse Dockerfile: %v", err)
+			}
+			dir := t.TempDir()
+			if tt.lockfile != "" {
+				if err := os.WriteFile(filepath.Join(dir, "package-lock.json"), []byte(tt.lockfile), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			fs := restrictedfilesystem.NewRestrictedFilesystem(dir, "", "Dockerfile", ".dockerignore")
+			p := NewProject(df, nil, nil, fs, nil, "")
+			p.SetBaseImages(baseimages.Builtin())
+
+			p.finalStageLightBaseImage()
+
+			expected := tt.expected
+			if expected == "" {
+				expected = tt.code
+			}
+			if p.dockerfile.Raw() != expected {
+				t.Errorf("unexpected Dockerfile:\n%s\nexpected:\n%s", p.dockerfile.Raw(), expected)
+			}
+			if len(p.actionsTaken) != tt.actions {
+				t.Errorf("expected %d actions, got %d", tt.actions, len(p.actionsTaken))
+			}
+			if len(p.recommendations) != tt.recommendations {
+				t.Errorf("expected %d recommendations, got %d", tt.recommendations, len(p.recommendations))
+			}
+			suggests := tt.suggests
+			if suggests == "" {
+				suggests = "node:24-alpine"
+			}
+			for _, r := range p.recommendations {
+				if !strings.Contains(r.Description, "Use '"+suggests+"'") {
+					t.Errorf("expected the recommendation to suggest %s: %s", suggests, r.Description)
+				}
+			}
+		})
+	}
+}
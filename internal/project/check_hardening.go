@@ -0,0 +1,233 @@
+package project
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+// dropSetuidCommand removes the setuid and setgid bits from every file in the image, so that a compromised
+// process can't use them to gain privileges
+const dropSetuidCommand = "RUN find / -xdev -perm /6000 -type f -exec chmod a-s {} + || true"
+
+// rootUsers are the values of USER that run the container as root
+var rootUsers = map[string]bool{"root": true, "0": true, "root:root": true, "0:0": true}
+
+// hardenFinalStage adds the instructions that harden the container the final stage runs in: setuid and
+// setgid bits are dropped, NODE_ENV is set to production, the container runs as a non-root user and
+// gets a HEALTHCHECK. They are added right before the stage's CMD or ENTRYPOINT, so they don't affect
+// how the stage is built. Hardening that can't be done safely is recommended instead.
+func (p *Project) hardenFinalStage() {
+	finalStage, err := p.dockerfile.GetFinalStage()
+	if err != nil || p.baseIsNamedContext(finalStage) {
+		return
+	}
+	chain := p.stageChain(finalStage)
+	root := chain[len(chain)-1].BaseImage()
+	// distroless and scratch images have no shell or package manager to harden
+	shell := root.Name() != "scratch" && !strings.Contains(root.Name(), "distroless")
+	isNode := root.Name() == "node" || strings.HasSuffix(root.Name(), "/node")
+	filepath := p.directory.GetDockerfileFilePath()
+
+	var (
+		lines   []string
+		actions []*models.OptimizationAction
+	)
+	add := func(code string, a *models.OptimizationAction) {
+		lines = append(lines, code)
+		a.Filepath, a.Risk = filepath, models.RiskBehavior
+		actions = append(actions, a)
+	}
+
+	user := chainUser(chain)
+	// only root can remove the bits, and the instructions are added after the stage's last USER
+	if shell && (user == "" || rootUsers[user]) {
+		add(dropSetuidCommand, &models.OptimizationAction{
+			Rule:        "drop-setuid-binaries",
+			Title:       "Removed the setuid and setgid bits from the final image",
+			Description: "Binaries with the setuid or setgid bit run with the privileges of their owner, usually root, which lets an attacker who gets into the container escalate their privileges. None of them are needed to run the application.",
+		})
+	}
+
+	if (isNode || p.packageJSON != nil) && !chainSetsEnv(chain, "NODE_ENV") {
+		add("ENV NODE_ENV=production", &models.OptimizationAction{
+			Rule:        "set-node-env-production",
+			Title:       "Set NODE_ENV to production",
+			Description: "Many libraries, eg- express, disable debugging aids like verbose error pages and enable caching when NODE_ENV is production. It's set right before the application starts, so it doesn't affect how dependencies are installed.",
+		})
+	}
+
+	if user == "" || rootUsers[user] {
+		switch {
+		case isNode:
+			add("USER node", &models.OptimizationAction{
+				Rule:        "run-as-non-root",
+				Title:       "Run the application as the non-root node user",
+				Description: "Containers run as root by default, so a vulnerability in the application gives an attacker root access to the container. The official node images come with an unprivileged user called node. Files it needs to write to must be owned by it, eg- 'COPY --chown=node:node'.",
+			})
+		case strings.Contains(root.Name(), "distroless"):
+			p.addRecommendation(&models.OptimizationAction{
+				Rule:        "run-as-non-root",
+				Risk:        models.RiskBehavior,
+				Filepath:    filepath,
+				Line:        finalStage.StartLine(),
+				Title:       "Use the nonroot variant of the distroless image",
+				Description: fmt.Sprintf("Containers run as root by default, so a vulnerability in the application gives an attacker root access to the container. Use the ':nonroot' tag of '%s', which runs as an unprivileged user.", root.Name()),
+			})
+		default:
+			p.addRecommendation(&models.OptimizationAction{
+				Rule:        "run-as-non-root",
+				Risk:        models.RiskBehavior,
+				Filepath:    filepath,
+				Line:        finalStage.StartLine(),
+				Title:       "Run the application as a non-root user",
+				Description: fmt.Sprintf("Containers run as root by default, so a vulnerability in the application gives an attacker root access to the container. Create an unprivileged user in the final stage, eg- 'RUN adduser --system --uid 10001 app', and switch to it with 'USER app' before the application starts. The users available in '%s' aren't known, so it wasn't added automatically.", root.FullName()),
+			})
+		}
+	}
+
+	if !chainHas(chain, dockerfile.CmdHealthcheck) {
+		port := exposedPort(chain)
+		if isNode && port != 0 {
+			add(nodeHealthcheck(port), &models.OptimizationAction{
+				Rule:        "add-healthcheck",
+				Title:       "Added a HEALTHCHECK",
+				Description: fmt.Sprintf("Without a HEALTHCHECK, Docker and orchestrators only know whether the process is running, not whether it can serve requests. The check requests http://localhost:%d/ with node itself, since the image may not have curl or wget, and fails on errors or 5xx responses. Point it to a dedicated health endpoint if the application has one.", port),
+			})
+		} else {
+			p.addRecommendation(&models.OptimizationAction{
+				Rule:        "add-healthcheck",
+				Risk:        models.RiskBehavior,
+				Filepath:    filepath,
+				Line:        finalStage.StartLine(),
+				Title:       "Add a HEALTHCHECK",
+				Description: "Without a HEALTHCHECK, Docker and orchestrators only know whether the process is running, not whether it can serve requests. Add one that checks a health endpoint of the application, eg- 'HEALTHCHECK CMD wget -qO- http://localhost:3000/health || exit 1'.",
+			})
+		}
+	}
+
+	if len(lines) == 0 {
+		return
+	}
+	at := insertionLine(finalStage)
+	if err := p.dockerfile.InsertAfter(at, strings.Join(lines, dockerfile.Linebreak)); err != nil {
+		p.addRecommendation(&models.OptimizationAction{
+			Rule:        "harden-final-stage",
+			Risk:        models.RiskBehavior,
+			Filepath:    filepath,
+			Title:       "Harden the final stage",
+			Description: fmt.Sprintf("The hardening instructions could not be added: %v. Add them before the application starts:\n%s", err, strings.Join(lines, "\n")),
+		})
+		return
+	}
+	for i, a := range actions {
+		a.Line = at + 1 + i
+		p.addActionTaken(a)
+	}
+}
+
+// stageChain returns the stage followed by the stages it's built from, up to the one built from an image
+func (p *Project) stageChain(stage *dockerfile.Stage) []*dockerfile.Stage {
+	chain := []*dockerfile.Stage{stage}
+	for base := p.dockerfile.GetBaseStage(stage); base != nil && len(chain) <= int(p.dockerfile.GetStageCount()); base = p.dockerfile.GetBaseStage(base) {
+		chain = append(chain, base)
+	}
+	return chain
+}
+
+// chainHas returns true if any stage of the chain has an instruction of the given kind
+func chainHas(chain []*dockerfile.Stage, cmd string) bool {
+	for _, stage := range chain {
+		for _, inst := range stage.Instructions() {
+			if inst.Cmd() == cmd {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// chainSetsEnv returns true if any stage of the chain sets the ENV variable
+func chainSetsEnv(chain []*dockerfile.Stage, key string) bool {
+	for _, stage := range chain {
+		for _, inst := range stage.Instructions() {
+			if _, ok := envValueOf(inst, key); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// envValueOf returns the value an ENV instruction sets the variable to
+func envValueOf(inst *dockerfile.Instruction, key string) (string, bool) {
+	if inst.Cmd() != dockerfile.CmdEnv {
+		return "", false
+	}
+	args := inst.Args()
+	// ENV arguments are parsed as (key, value, separator) triplets
+	for i := 0; i+1 < len(args); i += 3 {
+		if args[i] == key {
+			return args[i+1], true
+		}
+	}
+	return "", false
+}
+
+// chainUser returns the user the last USER instruction of the chain switches to, empty if there's none
+func chainUser(chain []*dockerfile.Stage) string {
+	for _, stage := range chain {
+		instructions := stage.Instructions()
+		for i := len(instructions) - 1; i >= 0; i-- {
+			if instructions[i].Cmd() == dockerfile.CmdUser {
+				if args := instructions[i].ExpandedArgs(); len(args) > 0 {
+					return args[0]
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// exposedPort returns the first TCP port exposed by the chain, 0 if none is known
+func exposedPort(chain []*dockerfile.Stage) int {
+	for _, stage := range chain {
+		for _, inst := range stage.Instructions() {
+			if inst.Cmd() != dockerfile.CmdExpose {
+				continue
+			}
+			for _, arg := range inst.ExpandedArgs() {
+				port, proto, _ := strings.Cut(arg, "/")
+				if proto != "" && proto != "tcp" {
+					continue
+				}
+				if n, err := strconv.Atoi(port); err == nil && n > 0 {
+					return n
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// nodeHealthcheck returns a HEALTHCHECK that requests the port with node, which every node image has
+func nodeHealthcheck(port int) string {
+	return fmt.Sprintf(`HEALTHCHECK --interval=30s --timeout=5s --start-period=10s --retries=3 CMD node -e "require('http').get('http://localhost:%d/', r => process.exit(r.statusCode < 500 ? 0 : 1)).on('error', () => process.exit(1))"`, port)
+}
+
+// insertionLine returns the line after which instructions that only affect the running container are added
+// to the stage: right before its first CMD or ENTRYPOINT, or after its last instruction
+func insertionLine(stage *dockerfile.Stage) int {
+	instructions := stage.Instructions()
+	last := stage.StartLine()
+	for _, inst := range instructions {
+		if inst.Cmd() == dockerfile.CmdCmd || inst.Cmd() == dockerfile.CmdEntrypoint {
+			return inst.StartLine() - 1
+		}
+		last = inst.EndLine()
+	}
+	return last
+}
@@ -0,0 +1,89 @@
+package project
+
+import (
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+)
+
+func TestHardenFinalStage(t *testing.T) {
+	healthcheck := nodeHealthcheck(3000)
+
+	tests := []struct {
+		name            string
+		input           string
+		expected        string
+		actions         []string
+		recommendations []string
+	}{
+		{
+			name:     "node image",
+			input:    "FROM node:22-alpine\nWORKDIR /app\nCOPY . .\nEXPOSE 3000\nCMD [\"node\", \"index.js\"]\n",
+			expected: "FROM node:22-alpine\nWORKDIR /app\nCOPY . .\nEXPOSE 3000\n" + dropSetuidCommand + "\nENV NODE_ENV=production\nUSER node\n" + healthcheck + "\nCMD [\"node\", \"index.js\"]\n",
+			actions:  []string{"drop-setuid-binaries", "set-node-env-production", "run-as-non-root", "add-healthcheck"},
+		},
+		{
+			name:     "already hardened in the base stage",
+			input:    "FROM node:22-alpine AS base\nENV NODE_ENV=production\nUSER node\nHEALTHCHECK CMD true\n\nFROM base\nCOPY . .\n",
+			expected: "FROM node:22-alpine AS base\nENV NODE_ENV=production\nUSER node\nHEALTHCHECK CMD true\n\nFROM base\nCOPY . .\n",
+		},
+		{
+			name:            "root user and no exposed port",
+			input:           "FROM node:22-alpine\nUSER root\nCOPY . .\nENTRYPOINT [\"node\", \"index.js\"]\n",
+			expected:        "FROM node:22-alpine\nUSER root\nCOPY . .\n" + dropSetuidCommand + "\nENV NODE_ENV=production\nUSER node\nENTRYPOINT [\"node\", \"index.js\"]\n",
+			actions:         []string{"drop-setuid-binaries", "set-node-env-production", "run-as-non-root"},
+			recommendations: []string{"add-healthcheck"},
+		},
+		{
+			name:            "distroless image",
+			input:           "FROM gcr.io/distroless/nodejs22-debian12\nCOPY . .\nCMD [\"index.js\"]\n",
+			expected:        "FROM gcr.io/distroless/nodejs22-debian12\nCOPY . .\nCMD [\"index.js\"]\n",
+			recommendations: []string{"run-as-non-root", "add-healthcheck"},
+		},
+		{
+			name:     "non-root user",
+			input:    "FROM alpine:3.20\nUSER 1000\nHEALTHCHECK NONE\n",
+			expected: "FROM alpine:3.20\nUSER 1000\nHEALTHCHECK NONE\n",
+		},
+		{
+			name:            "unknown image",
+			input:           "FROM alpine:3.20\nCOPY app /app\n",
+			expected:        "FROM alpine:3.20\nCOPY app /app\n" + dropSetuidCommand + "\n",
+			actions:         []string{"drop-setuid-binaries"},
+			recommendations: []string{"run-as-non-root", "add-healthcheck"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.input)
+			if err != nil {
+				t.Fatalf("failed to parse dockerfile: %v", err)
+			}
+			fs := restrictedfilesystem.NewRestrictedFilesystem("/tmp", "", "Dockerfile", ".dockerignore")
+			p := NewProject(df, nil, nil, fs, nil, "")
+
+			p.hardenFinalStage()
+			if p.dockerfile.Raw() != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, p.dockerfile.Raw())
+			}
+			if len(p.actionsTaken) != len(tt.actions) {
+				t.Fatalf("expected %d actions, got %d", len(tt.actions), len(p.actionsTaken))
+			}
+			for i, a := range p.actionsTaken {
+				if a.Rule != tt.actions[i] {
+					t.Errorf("expected action %q, got %q", tt.actions[i], a.Rule)
+				}
+			}
+			if len(p.recommendations) != len(tt.recommendations) {
+				t.Fatalf("expected %d recommendations, got %d", len(tt.recommendations), len(p.recommendations))
+			}
+			for i, r := range p.recommendations {
+				if r.Rule != tt.recommendations[i] {
+					t.Errorf("expected recommendation %q, got %q", tt.recommendations[i], r.Rule)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package project
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/layerorder"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+// lifecycleScripts are the scripts of package.json that npm runs when installing the project's dependencies,
+// they usually need the project's source code
+var lifecycleScripts = []string{"preinstall", "install", "postinstall", "prepare"}
+
+// reorderLayers moves the instructions that copy source code after the installation of dependencies,
+// so that the installation is cached as long as the dependencies don't change
+func (p *Project) reorderLayers() {
+	rule := "reorder-layers"
+
+	issues := layerorder.Find(p.dockerfile)
+	if len(issues) == 0 {
+		return
+	}
+	savings := p.rebuildSavings()
+
+	// the installation needs the source code if npm runs the project's scripts during it, and workspaces
+	// need the package.json of every package, which the monorepo pruning takes care of
+	reason := ""
+	if p.workspace != nil {
+		reason = fmt.Sprintf("this project is a %s workspace, which needs the package.json of every package to install dependencies", p.workspace.Manager)
+	} else if p.packageJSON != nil {
+		for _, script := range lifecycleScripts {
+			if p.packageJSON.GetScript(script) != "" {
+				reason = fmt.Sprintf("package.json has a %s script, which runs when installing dependencies and may need the source code", script)
+				break
+			}
+		}
+	}
+	if reason != "" {
+		for _, issue := range issues {
+			p.addRecommendation(&models.OptimizationAction{
+				Rule:        rule,
+				Risk:        models.RiskCache,
+				Filepath:    p.directory.GetDockerfileFilePath(),
+				Line:        issue.Copy.StartLine(),
+				Title:       "Copy the source code after installing dependencies",
+				Description: fmt.Sprintf("Any change to the files copied on line %d installs the dependencies on line %d again. They weren't reordered because %s.%s", issue.Copy.StartLine(), issue.Install.StartLine(), reason, savings),
+			})
+		}
+		return
+	}
+
+	reordered, results, err := layerorder.Reorder(p.dockerfile, p.manifests())
+	if err != nil {
+		p.addRecommendation(&models.OptimizationAction{
+			Rule:        rule,
+			Risk:        models.RiskCache,
+			Filepath:    p.directory.GetDockerfileFilePath(),
+			Title:       "Copy the source code after installing dependencies",
+			Description: fmt.Sprintf("The instructions could not be reordered: %v. Copy package.json and the lockfile first, install dependencies, then copy the rest of the source code.", err),
+		})
+		return
+	}
+	p.dockerfile = reordered
+
+	for _, r := range results {
+		issue := r.Issue
+		if !r.Reordered() {
+			p.addRecommendation(&models.OptimizationAction{
+				Rule:        rule,
+				Risk:        models.RiskCache,
+				Filepath:    p.directory.GetDockerfileFilePath(),
+				Line:        issue.Copy.StartLine(),
+				Title:       "Copy the source code after installing dependencies",
+				Description: fmt.Sprintf("Any change to the files copied on line %d installs the dependencies on line %d again. They weren't reordered because %s.%s", issue.Copy.StartLine(), issue.Install.StartLine(), r.Reason, savings),
+			})
+			continue
+		}
+
+		action := &models.OptimizationAction{
+			Rule:        rule,
+			Risk:        models.RiskCache,
+			Filepath:    p.directory.GetDockerfileFilePath(),
+			Line:        r.CopyLine,
+			Title:       fmt.Sprintf("Copied %s after installing dependencies", strings.Join(issue.Copy.Sources(), ", ")),
+			Description: fmt.Sprintf("The dependencies installed on line %d are now cached until the dependencies themselves change, instead of being installed again whenever the copied files change.%s", r.InstallLine, savings),
+		}
+		if r.ManifestsCopy != "" {
+			action.Title = "Install dependencies before copying the source code"
+			action.Description = fmt.Sprintf("Only the files needed to install dependencies are copied before installing them, with '%s'. The rest of the source code is copied afterwards, so the dependencies installed on line %d are cached until the dependencies themselves change.%s", r.ManifestsCopy, r.InstallLine, savings)
+		}
+		p.addActionTaken(action)
+	}
+}
+
+// manifests returns the files needed to install dependencies that are at the root of the build context
+// and not excluded by .dockerignore
+func (p *Project) manifests() []string {
+	found := []string{}
+	for _, m := range layerorder.Manifests {
+		if p.dockerignore != nil && p.dockerignore.Contains(m) {
+			continue
+		}
+		if _, err := fs.Stat(p.directory.FS(), m); err == nil {
+			found = append(found, m)
+		}
+	}
+	return found
+}
+
+// rebuildSavings describes the time saved on every rebuild once the installation of dependencies
+// is cached, empty if the project has no package.json to estimate it from
+func (p *Project) rebuildSavings() string {
+	if p.packageJSON == nil {
+		return ""
+	}
+	deps := len(p.packageJSON.GetDependencies()) + len(p.packageJSON.GetDevDependencies())
+	return fmt.Sprintf(" Caching the installation saves roughly %s on every rebuild that only changes the source code, the estimated time to install %d dependencies.", layerorder.EstimateInstallTime(deps).Round(time.Second), deps)
+}
@@ -0,0 +1,84 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/dockerignore"
+	"github.com/duaraghav8/dockershrink/internal/packagejson"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+)
+
+func TestReorderLayers(t *testing.T) {
+	tests := []struct {
+		name            string
+		input           string
+		packageJSON     string
+		dockerignore    string
+		expected        string
+		actions         int
+		recommendations int
+	}{
+		{
+			name:         "source copied before install",
+			input:        "FROM node:22-alpine\nWORKDIR /app\nCOPY . .\nRUN npm ci\nCMD [\"node\", \"index.js\"]\n",
+			packageJSON:  `{"dependencies": {"express": "^4.0.0"}}`,
+			dockerignore: ".npmrc\n",
+			expected:     "FROM node:22-alpine\nWORKDIR /app\nCOPY package.json package-lock.json ./\nRUN npm ci\nCOPY . .\nCMD [\"node\", \"index.js\"]\n",
+			actions:      1,
+		},
+		{
+			name:            "install runs the project's scripts",
+			input:           "FROM node:22-alpine\nCOPY . .\nRUN npm ci\n",
+			packageJSON:     `{"scripts": {"postinstall": "node scripts/setup.js"}}`,
+			expected:        "FROM node:22-alpine\nCOPY . .\nRUN npm ci\n",
+			recommendations: 1,
+		},
+		{
+			name:        "already ordered",
+			input:       "FROM node:22-alpine\nCOPY package*.json ./\nRUN npm ci\nCOPY . .\n",
+			packageJSON: `{}`,
+			expected:    "FROM node:22-alpine\nCOPY package*.json ./\nRUN npm ci\nCOPY . .\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range []string{"package.json", "package-lock.json", ".npmrc"} {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			df, err := dockerfile.NewDockerfile(tt.input)
+			if err != nil {
+				t.Fatalf("failed to parse dockerfile: %v", err)
+			}
+			pkg, err := packagejson.NewPackageJSON(tt.packageJSON)
+			if err != nil {
+				t.Fatalf("failed to parse package.json: %v", err)
+			}
+			fs := restrictedfilesystem.NewRestrictedFilesystem(dir, "", "Dockerfile", ".dockerignore")
+			p := NewProject(df, dockerignore.NewDockerignore(tt.dockerignore), pkg, fs, nil, "")
+
+			p.reorderLayers()
+			if p.dockerfile.Raw() != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, p.dockerfile.Raw())
+			}
+			if len(p.actionsTaken) != tt.actions {
+				t.Errorf("expected %d actions, got %d", tt.actions, len(p.actionsTaken))
+			}
+			if len(p.recommendations) != tt.recommendations {
+				t.Errorf("expected %d recommendations, got %d", tt.recommendations, len(p.recommendations))
+			}
+			for _, a := range append(p.actionsTaken, p.recommendations...) {
+				if strings.Contains(tt.packageJSON, "express") && !strings.Contains(a.Description, "roughly 11s") {
+					t.Errorf("expected the rebuild time saved to be estimated, got %q", a.Description)
+				}
+			}
+		})
+	}
+}
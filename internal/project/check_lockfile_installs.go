@@ -0,0 +1,116 @@
+package project
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/packagelock"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+)
+
+// freezeDependencyInstalls rewrites the commands that install the project's dependencies without enforcing its
+// lockfile to the frozen install of the package manager that wrote the lockfile, eg- "npm install" to "npm ci",
+// or to "yarn install --frozen-lockfile" in a project with a yarn.lock. Commands are only rewritten if the
+// lockfile is copied into the image before they run, since the frozen install fails without it.
+func (p *Project) freezeDependencyInstalls() {
+	manager := packagelock.DetectManager(p.directory.FS())
+	if manager == nil {
+		return
+	}
+	c := p.rulesContext()
+	for _, r := range p.runScripts(p.dockerfile.GetStages()) {
+		installs := rules.UnfrozenInstalls(r.script, manager)
+		if len(installs) == 0 {
+			continue
+		}
+		chain := p.stageChain(r.stage)
+		corepackEnabled := enablesCorepack(chain, r.inst)
+		code := r.code
+		rewritten := []string{}
+		for i := len(installs) - 1; i >= 0; i-- {
+			install := installs[i]
+			command := strings.Join(install.Command.Argv(), " ")
+			frozen := strings.Join(install.Frozen, " ")
+			switch {
+			case !rules.LockfileAvailable(c, r.stage, r.inst, manager.Lockfile):
+				p.addRecommendation(&models.OptimizationAction{
+					Rule:        "install-ignores-lockfile",
+					Risk:        models.RiskBehavior,
+					Filepath:    p.directory.GetDockerfileFilePath(),
+					Line:        r.inst.StartLine(),
+					Title:       fmt.Sprintf("Copy %s and install the dependencies from it", manager.Lockfile),
+					Description: fmt.Sprintf("'%s' resolves the versions of the dependencies again, since %s isn't copied into the image before it runs. Copy it along with package.json and use '%s', which installs exactly the versions of the lockfile.", command, manager.Lockfile, strings.Join(manager.FrozenInstall(), " ")),
+				})
+				continue
+			case frozen == "", install.Manager != manager.Name && manager.NeedsCorepack() && !corepackEnabled && chain[len(chain)-1].BaseImage().Name() != "node":
+				// the options have no equivalent, or the package manager can't be enabled in the image
+				p.addRecommendation(&models.OptimizationAction{
+					Rule:        "install-ignores-lockfile",
+					Risk:        models.RiskBehavior,
+					Filepath:    p.directory.GetDockerfileFilePath(),
+					Line:        r.inst.StartLine(),
+					Title:       fmt.Sprintf("Install the dependencies with %s from %s", manager.Name, manager.Lockfile),
+					Description: fmt.Sprintf("The project is installed with %s, but '%s' ignores %s and resolves every version again. Install %s in the image and use '%s' instead.", manager.Name, command, manager.Lockfile, manager.Name, strings.Join(manager.FrozenInstall(), " ")),
+				})
+				continue
+			}
+			start, end, ok := argsSpan(code, install.Command.Args, install.Command.ArgEnds, install.First)
+			if !ok {
+				continue
+			}
+			code = code[:start] + frozen + code[end:]
+			if install.Manager != manager.Name && manager.NeedsCorepack() && !corepackEnabled {
+				code = code[:install.Command.Pos] + "corepack enable && " + code[install.Command.Pos:]
+			}
+			rewritten = append(rewritten, fmt.Sprintf("'%s' with '%s'", command, frozen))
+		}
+		if len(rewritten) == 0 {
+			continue
+		}
+		if err := p.dockerfile.ReplaceShellCommand(r.inst, code); err != nil {
+			continue
+		}
+		// the commands were rewritten from the last one
+		for i, j := 0, len(rewritten)-1; i < j; i, j = i+1, j-1 {
+			rewritten[i], rewritten[j] = rewritten[j], rewritten[i]
+		}
+		p.addActionTaken(&models.OptimizationAction{
+			Rule:        "install-ignores-lockfile",
+			Risk:        models.RiskBehavior,
+			Filepath:    p.directory.GetDockerfileFilePath(),
+			Line:        r.inst.StartLine(),
+			Title:       fmt.Sprintf("Installed the dependencies from %s", manager.Lockfile),
+			Description: fmt.Sprintf("Replaced %s, which installs exactly the versions of %s instead of resolving them again, and is faster since nothing has to be resolved. The build now fails if the lockfile is out of date with package.json, update it with %s and commit it.", strings.Join(rewritten, " and "), manager.Lockfile, manager.Name),
+		})
+	}
+}
+
+// enablesCorepack returns true if a RUN instruction of the chain enables package managers with corepack,
+// before or in the given instruction
+func enablesCorepack(chain []*dockerfile.Stage, inst *dockerfile.Instruction) bool {
+	for _, stage := range chain {
+		for _, i := range stage.Instructions() {
+			if i.Cmd() == dockerfile.CmdRun && i.StartLine() <= inst.StartLine() && strings.Contains(i.Command(), "corepack enable") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// argsSpan returns the offsets in the code of the arguments from first to the last one. ok is false if any of them
+// is quoted or escaped, since its text in the code differs from the argument.
+func argsSpan(code string, args []string, ends []int, first int) (start, end int, ok bool) {
+	if first >= len(args) || len(args) != len(ends) {
+		return 0, 0, false
+	}
+	for i := first; i < len(args); i++ {
+		s := ends[i] - len(args[i])
+		if s < 0 || code[s:ends[i]] != args[i] {
+			return 0, 0, false
+		}
+	}
+	return ends[first] - len(args[first]), ends[len(ends)-1], true
+}
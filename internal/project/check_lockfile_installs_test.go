@@ -0,0 +1,97 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+)
+
+func TestFreezeDependencyInstalls(t *testing.T) {
+	tests := []struct {
+		name            string
+		files           map[string]string
+		input           string
+		expected        string
+		actions         int
+		recommendations int
+	}{
+		{
+			name:     "npm install with package-lock.json",
+			files:    map[string]string{"package-lock.json": "{}"},
+			input:    "FROM node:22-alpine\nWORKDIR /app\nCOPY package*.json ./\nRUN npm install --omit=dev && npm cache clean --force\nCOPY . .\n",
+			expected: "FROM node:22-alpine\nWORKDIR /app\nCOPY package*.json ./\nRUN npm ci --omit=dev && npm cache clean --force\nCOPY . .\n",
+			actions:  1,
+		},
+		{
+			name:     "npm install in a yarn project",
+			files:    map[string]string{"yarn.lock": "# yarn lockfile v1\n"},
+			input:    "FROM node:22-alpine\nCOPY package.json yarn.lock ./\nRUN NODE_ENV=production npm install --production --no-audit\n",
+			expected: "FROM node:22-alpine\nCOPY package.json yarn.lock ./\nRUN NODE_ENV=production yarn install --frozen-lockfile --production\n",
+			actions:  1,
+		},
+		{
+			name:     "npm install in a pnpm project enables corepack",
+			files:    map[string]string{"pnpm-lock.yaml": "lockfileVersion: '9.0'\n"},
+			input:    "FROM node:22-slim\nCOPY . .\nRUN npm i\n",
+			expected: "FROM node:22-slim\nCOPY . .\nRUN corepack enable && pnpm install --frozen-lockfile\n",
+			actions:  1,
+		},
+		{
+			name:     "yarn berry",
+			files:    map[string]string{"yarn.lock": "__metadata:\n  version: 8\n", ".yarnrc.yml": "nodeLinker: node-modules\n"},
+			input:    "FROM node:22-slim\nCOPY . .\nRUN corepack enable && yarn\n",
+			expected: "FROM node:22-slim\nCOPY . .\nRUN corepack enable && yarn install --immutable\n",
+			actions:  1,
+		},
+		{
+			name:            "lockfile isn't copied",
+			files:           map[string]string{"package-lock.json": "{}"},
+			input:           "FROM node:22-alpine\nCOPY package.json ./\nRUN npm install\n",
+			recommendations: 1,
+		},
+		{
+			name:  "frozen installs and packages added",
+			files: map[string]string{"package-lock.json": "{}"},
+			input: "FROM node:22-alpine\nCOPY . .\nRUN npm ci && npm install -g pm2 && npm install --no-save left-pad\n",
+		},
+		{
+			name:  "no lockfile",
+			input: "FROM node:22-alpine\nCOPY . .\nRUN npm install\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			df, err := dockerfile.NewDockerfile(tt.input)
+			if err != nil {
+				t.Fatalf("failed to parse dockerfile: %v", err)
+			}
+			fs := restrictedfilesystem.NewRestrictedFilesystem(dir, "", "Dockerfile", ".dockerignore")
+			p := NewProject(df, nil, nil, fs, nil, "")
+
+			p.freezeDependencyInstalls()
+			expected := tt.expected
+			if expected == "" {
+				expected = tt.input
+			}
+			if p.dockerfile.Raw() != expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", expected, p.dockerfile.Raw())
+			}
+			if len(p.actionsTaken) != tt.actions {
+				t.Errorf("expected %d actions, got %d", tt.actions, len(p.actionsTaken))
+			}
+			if len(p.recommendations) != tt.recommendations {
+				t.Errorf("expected %d recommendations, got %d", tt.recommendations, len(p.recommendations))
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package project
+
+import (
+	"fmt"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/workspace"
+)
+
+func (p *Project) monorepoWorkspacePruning() {
+	rule := "prune-monorepo-workspace"
+
+	if p.workspace == nil || len(p.workspace.Packages) == 0 {
+		return
+	}
+	if workspace.IsPruned(p.dockerfile.Raw()) {
+		return
+	}
+
+	target := p.workspacePackage
+	if target == "" {
+		target = "<package>"
+	}
+	rec := &models.OptimizationAction{
+		Rule:     rule,
+		Risk:     models.RiskSize,
+		Filepath: p.directory.GetDockerfileFilePath(),
+		Title:    "Prune the monorepo so only the target package is copied into the image",
+		Description: fmt.Sprintf(
+			"This project is a %s workspace with %d packages, but the Dockerfile copies and installs the whole monorepo. "+
+				"Run '%s' in a separate stage and only copy its output forward, so the image contains just the target package and the workspace packages it depends on.",
+			p.workspace.Manager, len(p.workspace.Packages), p.workspace.PruneCommand(target),
+		),
+	}
+	p.addRecommendation(rec)
+}
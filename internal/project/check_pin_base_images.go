@@ -0,0 +1,38 @@
+package project
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/pinning"
+)
+
+func (p *Project) pinBaseImages(ctx context.Context, resolver pinning.Resolver) {
+	rule := "pin-base-images"
+
+	for _, r := range pinning.Pin(ctx, p.dockerfile, resolver, pinning.PinUnpinned) {
+		if r.Err != nil {
+			p.addRecommendation(&models.OptimizationAction{
+				Rule:        rule,
+				Risk:        models.RiskCache,
+				Filepath:    p.directory.GetDockerfileFilePath(),
+				Line:        r.Line,
+				Title:       fmt.Sprintf("Pin '%s' to a digest", r.Previous),
+				Description: fmt.Sprintf("The digest of '%s' could not be resolved: %v. Pin it manually (eg- using \"docker buildx imagetools inspect\") so that every build uses the same image.", r.Previous, r.Err),
+			})
+			continue
+		}
+		if !r.Changed() {
+			continue
+		}
+		p.addActionTaken(&models.OptimizationAction{
+			Rule:        rule,
+			Risk:        models.RiskCache,
+			Filepath:    p.directory.GetDockerfileFilePath(),
+			Line:        r.Line,
+			Title:       "Pinned the base image to its digest",
+			Description: fmt.Sprintf("Pinned '%s' to '%s', so that builds keep using the same image even if the tag is moved to a new one. Run \"dockershrink update-pins\" to update the digest later.", r.Previous, r.Pinned),
+		})
+	}
+}
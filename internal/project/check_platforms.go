@@ -0,0 +1,55 @@
+package project
+
+import (
+	"fmt"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/platform"
+)
+
+// checkPlatforms makes sure that the base images introduced by the AI are published for every target platform.
+// Base images taken from the original Dockerfile are left alone, since the developer chose them.
+func (p *Project) checkPlatforms(original *dockerfile.Dockerfile) {
+	rule := "base-image-platforms"
+	if len(p.platforms) == 0 {
+		return
+	}
+
+	originalImages := map[string]bool{}
+	for _, image := range baseImagesOf(original) {
+		originalImages[image.FullName()] = true
+	}
+
+	for _, stage := range p.dockerfile.GetStages() {
+		image := stage.BaseImage()
+		if originalImages[image.FullName()] || !stage.BuiltForTargetPlatform() || p.baseIsNamedContext(stage) {
+			continue
+		}
+		check := p.baseImages.CheckPlatforms(image, p.platforms)
+		if check == nil {
+			continue
+		}
+
+		if check.Alternative == "" {
+			p.addRecommendation(&models.OptimizationAction{
+				Rule:        rule,
+				Risk:        models.RiskBehavior,
+				Filepath:    p.directory.GetDockerfileFilePath(),
+				Line:        stage.StartLine(),
+				Title:       fmt.Sprintf("Base image %s is not available for every target platform", image.FullName()),
+				Description: fmt.Sprintf("'%s' isn't published for %s, so the image can't be built for all of its target platforms. Use a base image that is published for %s.", image.FullName(), platform.Join(check.Missing), platform.Join(p.platforms)),
+			})
+			continue
+		}
+		p.dockerfile.SetStageBaseImage(stage, dockerfile.NewImage(check.Alternative))
+		p.addActionTaken(&models.OptimizationAction{
+			Rule:        rule,
+			Risk:        models.RiskBehavior,
+			Filepath:    p.directory.GetDockerfileFilePath(),
+			Line:        stage.StartLine(),
+			Title:       "Used a base image that is available for every target platform",
+			Description: fmt.Sprintf("Used '%s' instead of '%s', which isn't published for %s.", check.Alternative, image.FullName(), platform.Join(check.Missing)),
+		})
+	}
+}
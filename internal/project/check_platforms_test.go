@@ -0,0 +1,70 @@
+package project
+
+import (
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/baseimages"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/platform"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+)
+
+func TestCheckPlatforms(t *testing.T) {
+	original := "FROM eclipse-temurin:21-jdk\nCOPY . .\n"
+	tests := []struct {
+		name            string
+		optimized       string
+		platforms       string
+		expected        string
+		actions         int
+		recommendations int
+	}{
+		{
+			name:      "musl-only variant replaced",
+			optimized: "FROM eclipse-temurin:21-jdk AS build\nCOPY . .\n\nFROM eclipse-temurin:21-jre-alpine\nCOPY --from=build /app /app\n",
+			platforms: "linux/amd64,linux/ppc64le",
+			expected:  "FROM eclipse-temurin:21-jdk AS build\nCOPY . .\n\nFROM eclipse-temurin:21-jre\nCOPY --from=build /app /app\n",
+			actions:   1,
+		},
+		{
+			name:      "variant available for all platforms",
+			optimized: "FROM eclipse-temurin:21-jre-alpine\n",
+			platforms: "linux/amd64,linux/arm64",
+			expected:  "FROM eclipse-temurin:21-jre-alpine\n",
+		},
+		{
+			name:            "no alternative",
+			optimized:       "FROM node:22-alpine\n",
+			platforms:       "linux/riscv64",
+			expected:        "FROM node:22-alpine\n",
+			recommendations: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orig, err := dockerfile.NewDockerfile(original)
+			if err != nil {
+				t.Fatalf("failed to parse original dockerfile: %v", err)
+			}
+			df, err := dockerfile.NewDockerfile(tt.optimized)
+			if err != nil {
+				t.Fatalf("failed to parse optimized dockerfile: %v", err)
+			}
+			fs := restrictedfilesystem.NewRestrictedFilesystem("/tmp", "", "Dockerfile", ".dockerignore")
+			p := NewProject(df, nil, nil, fs, nil, "")
+			p.SetBaseImages(baseimages.Builtin())
+			if p.platforms, err = platform.ParseList(tt.platforms); err != nil {
+				t.Fatal(err)
+			}
+
+			p.checkPlatforms(orig)
+			if p.dockerfile.Raw() != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, p.dockerfile.Raw())
+			}
+			if len(p.actionsTaken) != tt.actions || len(p.recommendations) != tt.recommendations {
+				t.Errorf("expected %d actions and %d recommendations, got %d and %d", tt.actions, tt.recommendations, len(p.actionsTaken), len(p.recommendations))
+			}
+		})
+	}
+}
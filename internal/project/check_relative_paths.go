@@ -0,0 +1,292 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+// extensions of files that are commonly executed by CMD and ENTRYPOINT
+var scriptExtensions = []string{".js", ".mjs", ".cjs", ".ts", ".sh"}
+
+// correctRelativePaths verifies that relative paths still resolve after the Dockerfile was restructured
+// by AI, which is the most common way a multistage rewrite silently breaks the image.
+// Mismatches that can be fixed safely are corrected, the rest are recommended to the user.
+// Only the instructions that don't exist in the original Dockerfile are checked.
+func (p *Project) correctRelativePaths(original *dockerfile.Dockerfile) {
+	p.fixCopyFromSources(original)
+	p.restoreFinalStageWorkdir(original)
+	p.checkEntrypointPaths(original)
+}
+
+// fixCopyFromSources corrects relative source paths in "COPY --from=<stage>".
+// Such sources are resolved from the root of the source stage, not its WORKDIR,
+// so "COPY --from=build dist ./dist" doesn't copy /app/dist if the build stage's WORKDIR is /app.
+func (p *Project) fixCopyFromSources(original *dockerfile.Dockerfile) {
+	rule := "fix-copy-from-relative-paths"
+	existing := instructionSet(original)
+
+	type edit struct {
+		ins  *dockerfile.Instruction
+		args []string
+	}
+	edits := []edit{}
+	fixes := []string{}
+
+	stages := p.dockerfile.GetStages()
+	workdirs := dockerfile.Workdirs(stages)
+	for si, stage := range stages {
+		for _, ins := range stage.Instructions() {
+			from, ok := ins.Flag("from")
+			if ins.Cmd() != dockerfile.CmdCopy || !ok || existing[ins.Original()] {
+				continue
+			}
+			source := dockerfile.FindStage(stages[:si], from)
+			if source == nil {
+				continue
+			}
+			sourceWorkdir := workdirs[source.Index()][len(source.Instructions())]
+			if sourceWorkdir == dockerfile.RootDir {
+				continue
+			}
+
+			args := ins.Args()
+			if len(args) < 2 {
+				continue
+			}
+			changed := false
+			for i, src := range args[:len(args)-1] {
+				if path.IsAbs(src) || strings.Contains(src, "$") || isRootRelative(src, sourceWorkdir) {
+					continue
+				}
+				args[i] = dockerfile.ResolvePath(sourceWorkdir, src)
+				fixes = append(fixes, fmt.Sprintf("line %d: '%s' -> '%s'", ins.StartLine(), src, args[i]))
+				changed = true
+			}
+			if changed {
+				edits = append(edits, edit{ins: ins, args: args})
+			}
+		}
+	}
+	if len(edits) == 0 {
+		return
+	}
+
+	// apply the edits bottom-up so that the line numbers of the remaining ones stay valid
+	for i := len(edits) - 1; i >= 0; i-- {
+		// only the paths are rewritten, unless they can't be found in the code as written
+		if err := p.dockerfile.ReplaceArgs(edits[i].ins, edits[i].args); err == nil {
+			continue
+		}
+		if err := p.dockerfile.ReplaceInstruction(edits[i].ins, renderInstruction(edits[i].ins, edits[i].args)); err != nil {
+			return
+		}
+	}
+	p.addActionTaken(&models.OptimizationAction{
+		Rule:     rule,
+		Risk:     models.RiskCosmetic,
+		Filepath: p.directory.GetDockerfileFilePath(),
+		Title:    "Corrected relative source paths in COPY --from instructions",
+		Description: "Source paths of COPY --from are resolved from the root of the source stage, not its WORKDIR. " +
+			"They were made absolute so that the intended files are copied:\n" + strings.Join(fixes, "\n"),
+	})
+}
+
+// restoreFinalStageWorkdir adds the WORKDIR of the original final stage back to the new final stage
+// if it was dropped while the stage still relies on relative paths.
+func (p *Project) restoreFinalStageWorkdir(original *dockerfile.Dockerfile) {
+	rule := "restore-final-stage-workdir"
+	if original == nil {
+		return
+	}
+
+	origStages := original.GetStages()
+	origWorkdirs := dockerfile.Workdirs(origStages)
+	origWorkdir := origWorkdirs[len(origWorkdirs)-1][len(origStages[len(origStages)-1].Instructions())]
+	if origWorkdir == dockerfile.RootDir {
+		return
+	}
+
+	stages := p.dockerfile.GetStages()
+	final := stages[len(stages)-1]
+	workdirs := dockerfile.Workdirs(stages)
+	if workdirs[len(stages)-1][0] != dockerfile.RootDir {
+		// inherited from another stage
+		return
+	}
+	usesRelativePaths := false
+	for _, ins := range final.Instructions() {
+		switch ins.Cmd() {
+		case dockerfile.CmdWorkdir:
+			return
+		case dockerfile.CmdCopy, dockerfile.CmdAdd:
+			if dest := ins.Destination(); dest != "" && !path.IsAbs(dest) {
+				usesRelativePaths = true
+			}
+		case dockerfile.CmdCmd, dockerfile.CmdEntrypoint:
+			for _, s := range scriptPaths(ins) {
+				if !path.IsAbs(s) {
+					usesRelativePaths = true
+				}
+			}
+		}
+	}
+	if !usesRelativePaths {
+		return
+	}
+
+	if err := p.dockerfile.InsertAfter(final.StartLine(), "WORKDIR "+origWorkdir); err != nil {
+		return
+	}
+	p.addActionTaken(&models.OptimizationAction{
+		Rule:     rule,
+		Risk:     models.RiskCosmetic,
+		Filepath: p.directory.GetDockerfileFilePath(),
+		Title:    "Restored the WORKDIR of the final stage",
+		Description: fmt.Sprintf(
+			"The final stage uses relative paths but no longer sets a WORKDIR, so they would resolve against '/' instead of '%s'. "+
+				"Added 'WORKDIR %s' to the final stage.", origWorkdir, origWorkdir,
+		),
+	})
+}
+
+// checkEntrypointPaths flags scripts run by CMD or ENTRYPOINT of the final stage which were
+// copied into the original final image, but are no longer copied into the new one.
+func (p *Project) checkEntrypointPaths(original *dockerfile.Dockerfile) {
+	rule := "unresolved-entrypoint-path"
+	if original == nil {
+		return
+	}
+
+	origProvided := providedScripts(original)
+	stages := p.dockerfile.GetStages()
+	workdirs := dockerfile.Workdirs(stages)
+	final := stages[len(stages)-1]
+	finalWorkdirs := workdirs[len(stages)-1]
+
+	for i, ins := range final.Instructions() {
+		if ins.Cmd() != dockerfile.CmdCmd && ins.Cmd() != dockerfile.CmdEntrypoint {
+			continue
+		}
+		for _, script := range scriptPaths(ins) {
+			resolved := dockerfile.ResolvePath(finalWorkdirs[i], script)
+			if isProvided(stages, workdirs, resolved) || !origProvided[script] {
+				continue
+			}
+			p.addRecommendation(&models.OptimizationAction{
+				Rule:     rule,
+				Risk:     models.RiskBehavior,
+				Filepath: p.directory.GetDockerfileFilePath(),
+				Line:     ins.StartLine(),
+				Title:    fmt.Sprintf("'%s' may not exist in the final image", script),
+				Description: fmt.Sprintf(
+					"%s runs '%s', which resolves to '%s' in the final stage. No instruction in the final stage copies a file to that path, "+
+						"but the original Dockerfile did. Check the WORKDIR and the COPY destinations of the final stage.",
+					ins.Cmd(), script, resolved,
+				),
+			})
+		}
+	}
+}
+
+// providedScripts returns the scripts run by the final stage which are copied into the final image
+func providedScripts(df *dockerfile.Dockerfile) map[string]bool {
+	provided := map[string]bool{}
+	stages := df.GetStages()
+	workdirs := dockerfile.Workdirs(stages)
+	final := stages[len(stages)-1]
+	for i, ins := range final.Instructions() {
+		if ins.Cmd() != dockerfile.CmdCmd && ins.Cmd() != dockerfile.CmdEntrypoint {
+			continue
+		}
+		for _, script := range scriptPaths(ins) {
+			if isProvided(stages, workdirs, dockerfile.ResolvePath(workdirs[len(stages)-1][i], script)) {
+				provided[script] = true
+			}
+		}
+	}
+	return provided
+}
+
+// isProvided returns true if a COPY or ADD in the final stage puts a file at the given path.
+// Files are assumed to be provided if the final stage is based on another stage.
+func isProvided(stages []*dockerfile.Stage, workdirs [][]string, file string) bool {
+	final := stages[len(stages)-1]
+	if final.Index() > 0 && dockerfile.FindStage(stages[:final.Index()], final.BaseImage().Name()) != nil {
+		return true
+	}
+	for i, ins := range final.Instructions() {
+		if ins.Cmd() != dockerfile.CmdCopy && ins.Cmd() != dockerfile.CmdAdd {
+			continue
+		}
+		dest := ins.Destination()
+		if dest == "" {
+			continue
+		}
+		dest = strings.TrimSuffix(dockerfile.ResolvePath(workdirs[len(stages)-1][i], dest), "/")
+		if dest == "" || file == dest || strings.HasPrefix(file, dest+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// scriptPaths returns the arguments of a CMD or ENTRYPOINT instruction that look like paths to scripts
+func scriptPaths(ins *dockerfile.Instruction) []string {
+	words := ins.Args()
+	if !ins.IsJSONForm() && len(words) > 0 {
+		words = strings.Fields(words[0])
+	}
+	paths := []string{}
+	for _, w := range words {
+		if strings.HasPrefix(w, "-") || strings.Contains(w, "://") || strings.Contains(w, "$") {
+			continue
+		}
+		isScript := strings.HasPrefix(w, "./")
+		for _, ext := range scriptExtensions {
+			if strings.HasSuffix(w, ext) {
+				isScript = true
+			}
+		}
+		if isScript {
+			paths = append(paths, w)
+		}
+	}
+	return paths
+}
+
+// isRootRelative returns true if the relative path src already starts at the root of the stage,
+// eg- "app/dist" in a stage whose WORKDIR is /app.
+func isRootRelative(src, workdir string) bool {
+	first := strings.SplitN(strings.TrimPrefix(path.Clean(src), "./"), "/", 2)[0]
+	workdirFirst := strings.SplitN(strings.TrimPrefix(workdir, "/"), "/", 2)[0]
+	return first == workdirFirst
+}
+
+// renderInstruction returns the code of the instruction with new arguments, keeping its flags and form
+func renderInstruction(ins *dockerfile.Instruction, args []string) string {
+	parts := append([]string{ins.Cmd()}, ins.Flags()...)
+	if ins.IsJSONForm() {
+		encoded, _ := json.Marshal(args)
+		return strings.Join(parts, " ") + " " + string(encoded)
+	}
+	return strings.Join(append(parts, args...), " ")
+}
+
+// instructionSet returns the code of all the instructions in the Dockerfile
+func instructionSet(df *dockerfile.Dockerfile) map[string]bool {
+	set := map[string]bool{}
+	if df == nil {
+		return set
+	}
+	for _, s := range df.GetStages() {
+		for _, ins := range s.Instructions() {
+			set[ins.Original()] = true
+		}
+	}
+	return set
+}
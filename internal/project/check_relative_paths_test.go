@@ -0,0 +1,132 @@
+package project
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+)
+
+func TestCorrectRelativePaths(t *testing.T) {
+	original := `FROM node:20
+WORKDIR /app
+COPY . .
+RUN npm ci && npm run build
+CMD ["node", "dist/index.js"]
+`
+	tests := []struct {
+		name            string
+		optimized       string
+		expected        string
+		actions         int
+		recommendations int
+	}{
+		{
+			name: "copy from source resolved against source workdir",
+			optimized: `FROM node:20 AS build
+WORKDIR /app
+COPY . .
+RUN npm ci && npm run build
+
+FROM node:20-alpine
+WORKDIR /app
+COPY --from=build dist ./dist
+CMD ["node", "dist/index.js"]
+`,
+			expected: `FROM node:20 AS build
+WORKDIR /app
+COPY . .
+RUN npm ci && npm run build
+
+FROM node:20-alpine
+WORKDIR /app
+COPY --from=build /app/dist ./dist
+CMD ["node", "dist/index.js"]
+`,
+			actions: 1,
+		},
+		{
+			name: "root relative and absolute sources are untouched",
+			optimized: `FROM node:20 AS build
+WORKDIR /app
+COPY . .
+RUN npm ci && npm run build
+
+FROM node:20-alpine
+WORKDIR /app
+COPY --from=build app/dist ./dist
+COPY --from=build /app/node_modules ./node_modules
+CMD ["node", "dist/index.js"]
+`,
+		},
+		{
+			name: "dropped final stage workdir is restored",
+			optimized: `FROM node:20 AS build
+WORKDIR /app
+COPY . .
+RUN npm ci && npm run build
+
+FROM node:20-alpine
+COPY --from=build /app/dist ./dist
+CMD ["node", "dist/index.js"]
+`,
+			expected: `FROM node:20 AS build
+WORKDIR /app
+COPY . .
+RUN npm ci && npm run build
+
+FROM node:20-alpine
+WORKDIR /app
+COPY --from=build /app/dist ./dist
+CMD ["node", "dist/index.js"]
+`,
+			actions: 1,
+		},
+		{
+			name: "entrypoint script not copied into final stage",
+			optimized: `FROM node:20 AS build
+WORKDIR /app
+COPY . .
+RUN npm ci && npm run build
+
+FROM node:20-alpine
+WORKDIR /app
+COPY --from=build /app/node_modules ./node_modules
+CMD ["node", "dist/index.js"]
+`,
+			recommendations: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orig, err := dockerfile.NewDockerfile(original)
+			if err != nil {
+				t.Fatalf("failed to parse original Dockerfile: %v", err)
+			}
+			df, err := dockerfile.NewDockerfile(tt.optimized)
+			if err != nil {
+				t.Fatalf("failed to parse optimized Dockerfile: %v", err)
+			}
+			fs := restrictedfilesystem.NewRestrictedFilesystem("/tmp", "", "Dockerfile", ".dockerignore")
+			p := NewProject(df, nil, nil, fs, nil, "")
+
+			p.correctRelativePaths(orig)
+
+			expected := tt.expected
+			if expected == "" {
+				expected = tt.optimized
+			}
+			if strings.TrimSpace(p.dockerfile.Raw()) != strings.TrimSpace(expected) {
+				t.Errorf("unexpected Dockerfile:\n%s\nexpected:\n%s", p.dockerfile.Raw(), expected)
+			}
+			if len(p.actionsTaken) != tt.actions {
+				t.Errorf("expected %d actions, got %d", tt.actions, len(p.actionsTaken))
+			}
+			if len(p.recommendations) != tt.recommendations {
+				t.Errorf("expected %d recommendations, got %d", tt.recommendations, len(p.recommendations))
+			}
+		})
+	}
+}
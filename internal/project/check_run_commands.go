@@ -0,0 +1,236 @@
+package project
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+)
+
+// matches the paths that can be removed without quoting them
+var plainPathRegex = regexp.MustCompile(`^[A-Za-z0-9_./${}-]+$`)
+
+// runScript is the script of a shell-form RUN instruction, parsed from its code as written in the Dockerfile
+// so that positions in the script are positions in the code
+type runScript struct {
+	stage  *dockerfile.Stage
+	inst   *dockerfile.Instruction
+	code   string
+	script *rules.Script
+}
+
+// runScripts returns the scripts of the RUN instructions of the stages that can be rewritten, from the last one
+// to the first, so that rewriting an instruction doesn't move the lines of those left to rewrite.
+// Instructions in exec form or with here-documents, run by a shell that isn't POSIX or with comments between
+// their lines are left out.
+func (p *Project) runScripts(stages []*dockerfile.Stage) []*runScript {
+	if p.dockerfile.EscapeToken() != '\\' {
+		return nil
+	}
+	scripts := []*runScript{}
+	for _, stage := range stages {
+		for _, inst := range stage.Instructions() {
+			if inst.Cmd() != dockerfile.CmdRun {
+				continue
+			}
+			if posix, _ := rules.RunShell(stage, inst); !posix {
+				continue
+			}
+			code, err := p.dockerfile.ShellCommand(inst)
+			if err != nil {
+				continue
+			}
+			// the Dockerfile parser drops comments and blank lines between the lines of an instruction, but the shell wouldn't
+			lines := strings.Split(code, dockerfile.Linebreak)
+			commented := false
+			for _, line := range lines[1:] {
+				if trimmed := strings.TrimSpace(line); trimmed == "" || strings.HasPrefix(trimmed, "#") {
+					commented = true
+				}
+			}
+			if commented {
+				continue
+			}
+			script, err := rules.ParseScript(code)
+			if err != nil {
+				continue
+			}
+			scripts = append(scripts, &runScript{stage: stage, inst: inst, code: code, script: script})
+		}
+	}
+	sort.SliceStable(scripts, func(i, j int) bool {
+		return scripts[i].inst.StartLine() > scripts[j].inst.StartLine()
+	})
+	return scripts
+}
+
+// chainRunCommands chains the commands of RUN instructions separated by ";" with "&&", so that the instruction
+// fails as soon as one of them fails instead of building an image without what the failed command should've done
+func (p *Project) chainRunCommands() {
+	for _, r := range p.runScripts(p.dockerfile.GetStages()) {
+		if _, errexit := rules.RunShell(r.stage, r.inst); errexit {
+			continue
+		}
+		ignored := r.script.IgnoredFailures()
+		code := r.code
+		chained := 0
+		for i := len(ignored) - 1; i >= 0; i-- {
+			st := ignored[i]
+			if !st.Semicolon.IsValid() {
+				continue
+			}
+			separator := " &&"
+			next := int(st.Semicolon.Offset()) + 1
+			if next < len(code) && !strings.ContainsRune(" \t\n", rune(code[next])) {
+				separator += " "
+			}
+			code = code[:rules.StmtEnd(st)] + separator + code[next:]
+			chained++
+		}
+		if chained == 0 {
+			continue
+		}
+		if err := p.dockerfile.ReplaceShellCommand(r.inst, code); err != nil {
+			continue
+		}
+		p.addActionTaken(&models.OptimizationAction{
+			Rule:        "run-commands-not-chained",
+			Risk:        models.RiskBehavior,
+			Filepath:    p.directory.GetDockerfileFilePath(),
+			Line:        r.inst.StartLine(),
+			Title:       "Chained the commands of the RUN instruction with &&",
+			Description: "The commands were separated by ';', so the build went on when one of them failed and the image was built without what it should have done. They're now chained with &&, so the build stops at the first command that fails. Add '|| true' after the commands that are allowed to fail.",
+		})
+	}
+}
+
+// cleanUpRunCommands removes the apt package lists and the downloaded archives that RUN instructions leave in
+// their layers, in the same instruction. Archives are only removed in the stages of the final image, and only
+// if no later instruction mentions them.
+func (p *Project) cleanUpRunCommands() {
+	finalStage, err := p.dockerfile.GetFinalStage()
+	if err != nil {
+		return
+	}
+	final := map[uint]bool{}
+	for _, s := range p.stageChain(finalStage) {
+		final[s.Index()] = true
+	}
+
+	for _, r := range p.runScripts(p.dockerfile.GetStages()) {
+		statements := r.script.Statements()
+		// commands added after a background job would run before it's done
+		if len(statements) == 0 || statements[len(statements)-1].Background {
+			continue
+		}
+		removals := []string{}
+
+		aptLists := rules.LeavesAptLists(r.inst, r.script) && !p.installsFromAptListsAfter(r.inst)
+		if aptLists {
+			removals = append(removals, "rm -rf /var/lib/apt/lists/*")
+		}
+
+		archives := []string{}
+		if final[r.stage.Index()] {
+			seen := map[string]bool{}
+			for _, d := range rules.LeftoverArchives(r.script) {
+				if seen[d.File] || !plainPathRegex.MatchString(d.File) || p.mentionedAfter(r.inst, path.Base(d.File)) {
+					continue
+				}
+				seen[d.File] = true
+				archives = append(archives, d.File)
+			}
+		}
+		if len(archives) > 0 {
+			removals = append(removals, "rm -f "+strings.Join(archives, " "))
+		}
+
+		if len(removals) == 0 {
+			continue
+		}
+		end := rules.StmtEnd(statements[len(statements)-1])
+		code := r.code[:end] + " && " + strings.Join(removals, " && ") + r.code[end:]
+		if err := p.dockerfile.ReplaceShellCommand(r.inst, code); err != nil {
+			continue
+		}
+		if aptLists {
+			p.addActionTaken(&models.OptimizationAction{
+				Rule:        "apt-get-bloat",
+				Risk:        models.RiskSize,
+				Filepath:    p.directory.GetDockerfileFilePath(),
+				Line:        r.inst.StartLine(),
+				Title:       "Removed the apt package lists after installing the packages",
+				Description: "apt-get update downloads the package lists to /var/lib/apt/lists, which aren't needed once the packages are installed. They're now removed in the same RUN instruction, so they don't end up in its layer.",
+			})
+		}
+		if len(archives) > 0 {
+			names := []string{}
+			for _, a := range archives {
+				names = append(names, path.Base(a))
+			}
+			p.addActionTaken(&models.OptimizationAction{
+				Rule:        "downloaded-archive-left-behind",
+				Risk:        models.RiskSize,
+				Filepath:    p.directory.GetDockerfileFilePath(),
+				Line:        r.inst.StartLine(),
+				Title:       fmt.Sprintf("Removed the downloaded %s after installing it", strings.Join(names, ", ")),
+				Description: "Downloaded archives are only needed to install what's inside them. They're now removed at the end of the RUN instruction that downloads them, so they don't end up in the final image.",
+			})
+		}
+	}
+}
+
+// installsFromAptListsAfter returns true if a RUN instruction after the given one installs apt packages without
+// downloading the package lists itself, so it needs the lists left by the earlier one
+func (p *Project) installsFromAptListsAfter(inst *dockerfile.Instruction) bool {
+	for _, stage := range p.dockerfile.GetStages() {
+		for _, i := range stage.Instructions() {
+			if i.StartLine() <= inst.StartLine() || i.Cmd() != dockerfile.CmdRun {
+				continue
+			}
+			cmd := i.Command()
+			if (strings.Contains(cmd, "apt-get install") || strings.Contains(cmd, "apt install")) && !strings.Contains(cmd, "update") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mentionedAfter returns true if an instruction after the given one mentions name, eg- copies or extracts a file
+func (p *Project) mentionedAfter(inst *dockerfile.Instruction, name string) bool {
+	for _, stage := range p.dockerfile.GetStages() {
+		for _, i := range stage.Instructions() {
+			if i.StartLine() > inst.StartLine() && strings.Contains(i.Original()+i.Command(), name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// recommendVerifiedScripts recommends verifying the remote scripts RUN instructions pipe into a shell, which
+// can't be done automatically since the checksum of the script has to come from a source that can be trusted
+func (p *Project) recommendVerifiedScripts() {
+	for _, r := range p.runScripts(p.dockerfile.GetStages()) {
+		for _, s := range rules.UnpinnedScripts(r.script) {
+			url := s.URL
+			if url == "" {
+				url = "the internet"
+			}
+			p.addRecommendation(&models.OptimizationAction{
+				Rule:        "remote-script-piped-to-shell",
+				Risk:        models.RiskBehavior,
+				Filepath:    p.directory.GetDockerfileFilePath(),
+				Line:        r.inst.StartLine(),
+				Title:       fmt.Sprintf("Verify the script downloaded from %s before running it", url),
+				Description: "The script is piped into a shell as it's served, so a compromised server or a new version of the script changes what runs in the build. Download a pinned version of it to a file, check it against a checksum published by its authors with 'sha256sum -c' and then run the file.",
+			})
+		}
+	}
+}
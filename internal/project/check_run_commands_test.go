@@ -0,0 +1,123 @@
+package project
+
+import (
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+)
+
+func TestChainRunCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		actions  int
+	}{
+		{
+			name:     "commands separated by semicolons",
+			input:    "FROM node:22-slim\nRUN cd /app;npm ci ;  \\\n    npm run build\n",
+			expected: "FROM node:22-slim\nRUN cd /app && npm ci &&  \\\n    npm run build\n",
+			actions:  1,
+		},
+		{
+			name:     "fallbacks and the last command are kept",
+			input:    "FROM node:22-slim\nRUN rm -rf /tmp/* || true; npm ci;\n",
+			expected: "FROM node:22-slim\nRUN rm -rf /tmp/* || true; npm ci;\n",
+		},
+		{
+			name:     "shell that exits on errors",
+			input:    "FROM node:22-slim\nSHELL [\"/bin/bash\", \"-ec\"]\nRUN cd /app; npm ci\n",
+			expected: "FROM node:22-slim\nSHELL [\"/bin/bash\", \"-ec\"]\nRUN cd /app; npm ci\n",
+		},
+		{
+			name:     "comments between the lines",
+			input:    "FROM node:22-slim\nRUN cd /app; \\\n# install\n    npm ci\n",
+			expected: "FROM node:22-slim\nRUN cd /app; \\\n# install\n    npm ci\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.input)
+			if err != nil {
+				t.Fatalf("failed to parse dockerfile: %v", err)
+			}
+			fs := restrictedfilesystem.NewRestrictedFilesystem("/tmp", "", "Dockerfile", ".dockerignore")
+			p := NewProject(df, nil, nil, fs, nil, "")
+
+			p.chainRunCommands()
+			if p.dockerfile.Raw() != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, p.dockerfile.Raw())
+			}
+			if len(p.actionsTaken) != tt.actions {
+				t.Errorf("expected %d actions, got %d", tt.actions, len(p.actionsTaken))
+			}
+		})
+	}
+}
+
+func TestCleanUpRunCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		actions  int
+	}{
+		{
+			name:     "apt package lists",
+			input:    "FROM debian:12-slim\nRUN apt-get update && \\\n    apt-get install -y --no-install-recommends curl\nCMD [\"curl\"]\n",
+			expected: "FROM debian:12-slim\nRUN apt-get update && \\\n    apt-get install -y --no-install-recommends curl && rm -rf /var/lib/apt/lists/*\nCMD [\"curl\"]\n",
+			actions:  1,
+		},
+		{
+			name:     "package lists needed by a later install",
+			input:    "FROM debian:12-slim\nRUN apt-get update && apt-get install -y curl\nRUN apt-get install -y git && rm -rf /var/lib/apt/lists/*\n",
+			expected: "FROM debian:12-slim\nRUN apt-get update && apt-get install -y curl\nRUN apt-get install -y git && rm -rf /var/lib/apt/lists/*\n",
+		},
+		{
+			name:     "downloaded archives in the final image",
+			input:    "FROM node:22-slim AS build\nRUN wget https://example.com/tool.tar.gz && tar -xzf tool.tar.gz\n\nFROM node:22-slim\nRUN cd /tmp && curl -fsSLO https://example.com/go.tgz && tar -C /usr/local -xzf go.tgz # go\n",
+			expected: "FROM node:22-slim AS build\nRUN wget https://example.com/tool.tar.gz && tar -xzf tool.tar.gz\n\nFROM node:22-slim\nRUN cd /tmp && curl -fsSLO https://example.com/go.tgz && tar -C /usr/local -xzf go.tgz && rm -f /tmp/go.tgz # go\n",
+			actions:  1,
+		},
+		{
+			name:     "archive used by a later instruction",
+			input:    "FROM debian:12-slim\nRUN wget -q https://example.com/tool.deb\nRUN dpkg -i tool.deb\n",
+			expected: "FROM debian:12-slim\nRUN wget -q https://example.com/tool.deb\nRUN dpkg -i tool.deb\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.input)
+			if err != nil {
+				t.Fatalf("failed to parse dockerfile: %v", err)
+			}
+			fs := restrictedfilesystem.NewRestrictedFilesystem("/tmp", "", "Dockerfile", ".dockerignore")
+			p := NewProject(df, nil, nil, fs, nil, "")
+
+			p.cleanUpRunCommands()
+			if p.dockerfile.Raw() != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, p.dockerfile.Raw())
+			}
+			if len(p.actionsTaken) != tt.actions {
+				t.Errorf("expected %d actions, got %d", tt.actions, len(p.actionsTaken))
+			}
+		})
+	}
+}
+
+func TestRecommendVerifiedScripts(t *testing.T) {
+	df, err := dockerfile.NewDockerfile("FROM debian:12-slim\nRUN curl -fsSL https://deb.nodesource.com/setup_22.x | bash -\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := restrictedfilesystem.NewRestrictedFilesystem("/tmp", "", "Dockerfile", ".dockerignore")
+	p := NewProject(df, nil, nil, fs, nil, "")
+
+	p.recommendVerifiedScripts()
+	if len(p.recommendations) != 1 || p.recommendations[0].Line != 2 {
+		t.Errorf("expected a recommendation for line 2, got %+v", p.recommendations)
+	}
+}
@@ -0,0 +1,144 @@
+package project
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/runtimepaths"
+)
+
+// narrowRuntimeCopy replaces "COPY . ." in the final stage with copies of only the files and directories the
+// application loads at runtime, found by tracing its start command through the code, eg- "node server.js".
+// The copy is only narrowed if the trace followed everything and no instruction after it runs commands,
+// which may need the other files of the project. Incomplete traces are recommended along with what they
+// couldn't follow.
+func (p *Project) narrowRuntimeCopy() {
+	rule := "copy-runtime-paths-only"
+	stages := p.dockerfile.GetStages()
+	final := stages[len(stages)-1]
+	workdirs := dockerfile.Workdirs(stages)
+	finalWorkdirs := workdirs[len(stages)-1]
+
+	var copyInst *dockerfile.Instruction
+	dest := ""
+	for i, inst := range final.Instructions() {
+		switch {
+		case inst.Cmd() == dockerfile.CmdCopy && isContextRootCopy(inst):
+			if copyInst != nil {
+				return
+			}
+			copyInst = inst
+			dest = strings.TrimSuffix(dockerfile.ResolvePath(finalWorkdirs[i], inst.Destination()), "/")
+		case copyInst != nil && (inst.Cmd() == dockerfile.CmdRun || inst.Cmd() == dockerfile.CmdHealthcheck):
+			return
+		}
+	}
+	if copyInst == nil || dest == "" || strings.Contains(dest, "$") {
+		return
+	}
+	entrypoint, cmd, err := p.startCommand(p.stageChain(final))
+	if err != nil {
+		return
+	}
+	command := append(append([]string{}, entrypoint...), cmd...)
+	fsys := p.directory.FS()
+	result, err := runtimepaths.Trace(fsys, command, dest, finalWorkdirs[len(finalWorkdirs)-1])
+	if err != nil {
+		return
+	}
+
+	paths := []string{}
+	for _, f := range result.Paths() {
+		if p.dockerignore == nil || !p.dockerignore.Excludes(f) {
+			paths = append(paths, f)
+		}
+	}
+	if len(paths) == 0 || paths[0] == "." {
+		return
+	}
+	if !result.Complete() {
+		p.addRecommendation(&models.OptimizationAction{
+			Rule:     rule,
+			Risk:     models.RiskBehavior,
+			Filepath: p.directory.GetDockerfileFilePath(),
+			Line:     copyInst.StartLine(),
+			Title:    "Copy only the files the application loads at runtime",
+			Description: fmt.Sprintf("'%s' copies the whole build context into the final image. Following '%s' through the code finds %s, but the trace is incomplete: %s. Copy those paths along with the files loaded in ways that can't be traced instead of the whole project.",
+				copyInst.Original(), strings.Join(command, " "), strings.Join(paths, ", "), strings.Join(result.Unresolved, "; ")),
+		})
+		return
+	}
+
+	needed := map[string]bool{}
+	for _, f := range paths {
+		needed[f] = true
+	}
+	left := []string{}
+	entries, _ := fs.ReadDir(fsys, ".")
+	for _, e := range entries {
+		if !needed[e.Name()] && (p.dockerignore == nil || !p.dockerignore.Excludes(e.Name())) && e.Name() != p.directory.GetDockerfileFilePath() {
+			left = append(left, e.Name())
+		}
+	}
+	if len(left) == 0 {
+		return
+	}
+
+	if err := p.dockerfile.ReplaceInstruction(copyInst, runtimeCopies(copyInst, paths, fsys)); err != nil {
+		return
+	}
+	p.addActionTaken(&models.OptimizationAction{
+		Rule:     rule,
+		Risk:     models.RiskBehavior,
+		Filepath: p.directory.GetDockerfileFilePath(),
+		Line:     copyInst.StartLine(),
+		Title:    "Copied only the files the application loads at runtime",
+		Description: fmt.Sprintf("Replaced '%s', which copied the whole build context, with copies of %s. They were found by following '%s' through the imports of the code and the paths it reads, so %s no longer end up in the final image. If the application reads other files, eg- through paths built at runtime, add them to the COPY instructions.",
+			copyInst.Original(), strings.Join(paths, ", "), strings.Join(command, " "), strings.Join(left, ", ")),
+	})
+}
+
+// isContextRootCopy returns true if a COPY instruction copies the root of the build context, eg- "COPY . ."
+func isContextRootCopy(inst *dockerfile.Instruction) bool {
+	if _, ok := inst.Flag("from"); ok {
+		return false
+	}
+	sources := inst.Sources()
+	if len(sources) != 1 {
+		return false
+	}
+	src := path.Clean(sources[0])
+	return src == "." || src == "/"
+}
+
+// runtimeCopies returns the COPY instructions that copy the given paths of the build context to the destination
+// of the original instruction, with its flags. Files are copied together, and every directory on its own
+// since COPY copies the contents of a directory rather than the directory itself.
+func runtimeCopies(inst *dockerfile.Instruction, paths []string, fsys fs.FS) string {
+	flags := strings.Join(append([]string{dockerfile.CmdCopy}, inst.Flags()...), " ")
+	dest := inst.Destination()
+	dir := strings.TrimSuffix(dest, "/")
+	if dir == "" {
+		dir = "."
+	}
+	files, dirs := []string{}, []string{}
+	for _, p := range paths {
+		if info, err := fs.Stat(fsys, p); err == nil && info.IsDir() {
+			dirs = append(dirs, p)
+		} else {
+			files = append(files, p)
+		}
+	}
+	lines := []string{}
+	if len(files) > 0 {
+		lines = append(lines, fmt.Sprintf("%s %s %s/", flags, strings.Join(files, " "), dir))
+	}
+	for _, d := range dirs {
+		lines = append(lines, fmt.Sprintf("%s %s %s/%s", flags, d, dir, d))
+	}
+	return strings.Join(lines, dockerfile.Linebreak)
+}
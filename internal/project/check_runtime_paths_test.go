@@ -0,0 +1,104 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+)
+
+func TestNarrowRuntimeCopy(t *testing.T) {
+	express := map[string]string{
+		"package.json":     `{"dependencies": {"express": "^4.19.0"}}`,
+		"server.js":        "const express = require('express');\nconst routes = require('./routes');\nexpress().use(routes).listen(3000);\n",
+		"routes/index.js":  "module.exports = require('express').Router();\n",
+		"test/app.test.js": "require('../server');\n",
+		"README.md":        "# app\n",
+	}
+
+	tests := []struct {
+		name            string
+		files           map[string]string
+		input           string
+		expected        string
+		actions         int
+		recommendations int
+	}{
+		{
+			name:     "express application",
+			files:    express,
+			input:    "FROM node:22-alpine\nWORKDIR /app\nCOPY package*.json ./\nRUN npm ci --omit=dev\nCOPY . .\nCMD [\"node\", \"server.js\"]\n",
+			expected: "FROM node:22-alpine\nWORKDIR /app\nCOPY package*.json ./\nRUN npm ci --omit=dev\nCOPY package.json server.js ./\nCOPY routes ./routes\nCMD [\"node\", \"server.js\"]\n",
+			actions:  1,
+		},
+		{
+			name:     "python module with flags on the copy",
+			files:    map[string]string{"app/__init__.py": "", "app/__main__.py": "from app import db\n", "app/db.py": "import os\n", "docs/index.md": "# docs\n"},
+			input:    "FROM python:3.12-slim\nCOPY --chown=app:app . /srv/\nWORKDIR /srv\nCMD [\"python\", \"-m\", \"app\"]\n",
+			expected: "FROM python:3.12-slim\nCOPY --chown=app:app app /srv/app\nWORKDIR /srv\nCMD [\"python\", \"-m\", \"app\"]\n",
+			actions:  1,
+		},
+		{
+			name: "computed require is recommended",
+			files: map[string]string{
+				"package.json": `{"dependencies": {}}`,
+				"server.js":    "const name = process.env.PLUGIN;\nrequire('./plugins/' + name);\n",
+				"docs/a.md":    "# docs\n",
+			},
+			input:           "FROM node:22-alpine\nWORKDIR /app\nCOPY . .\nCMD [\"node\", \"server.js\"]\n",
+			recommendations: 1,
+		},
+		{
+			name:  "commands run after the copy",
+			files: express,
+			input: "FROM node:22-alpine\nWORKDIR /app\nCOPY . .\nRUN npm ci --omit=dev\nCMD [\"node\", \"server.js\"]\n",
+		},
+		{
+			name:  "entrypoint is built in the image",
+			files: express,
+			input: "FROM node:22-alpine\nWORKDIR /app\nCOPY . .\nCMD [\"node\", \"dist/server.js\"]\n",
+		},
+		{
+			name:  "copy from a build stage",
+			files: express,
+			input: "FROM node:22 AS build\nWORKDIR /app\nCOPY . .\nRUN npm ci\n\nFROM node:22-alpine\nWORKDIR /app\nCOPY --from=build /app .\nCMD [\"node\", \"server.js\"]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			df, err := dockerfile.NewDockerfile(tt.input)
+			if err != nil {
+				t.Fatalf("failed to parse dockerfile: %v", err)
+			}
+			fs := restrictedfilesystem.NewRestrictedFilesystem(dir, "", "Dockerfile", ".dockerignore")
+			p := NewProject(df, nil, nil, fs, nil, "")
+
+			p.narrowRuntimeCopy()
+			expected := tt.expected
+			if expected == "" {
+				expected = tt.input
+			}
+			if p.dockerfile.Raw() != expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", expected, p.dockerfile.Raw())
+			}
+			if len(p.actionsTaken) != tt.actions {
+				t.Errorf("expected %d actions, got %d", tt.actions, len(p.actionsTaken))
+			}
+			if len(p.recommendations) != tt.recommendations {
+				t.Errorf("expected %d recommendations, got %d", tt.recommendations, len(p.recommendations))
+			}
+		})
+	}
+}
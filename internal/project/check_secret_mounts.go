@@ -0,0 +1,82 @@
+package project
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/secrets"
+)
+
+// migrateSecretMounts replaces the build arguments and ENV variables that pass secrets into the build with
+// BuildKit secret mounts. It returns the secrets the image must be built with afterwards.
+func (p *Project) migrateSecretMounts() []*secrets.BuildSecret {
+	rule := "secret-mounts"
+
+	migrated, migration, err := secrets.Migrate(p.dockerfile)
+	if err != nil {
+		p.addRecommendation(&models.OptimizationAction{
+			Rule:        rule,
+			Risk:        models.RiskBehavior,
+			Filepath:    p.directory.GetDockerfileFilePath(),
+			Title:       "Pass secrets to the build with secret mounts",
+			Description: fmt.Sprintf("The secrets passed as build arguments could not be moved to secret mounts: %v. Mount them in the RUN instructions that need them with '--mount=type=secret,id=<id>,env=<NAME>' instead.", err),
+		})
+		return nil
+	}
+	p.dockerfile = migrated
+
+	for _, r := range migration.Results {
+		s := r.Secret
+		if !r.Migrated() {
+			// secrets written in the Dockerfile need to be rotated, which the static rules already point out
+			if s.Literal {
+				continue
+			}
+			p.addRecommendation(&models.OptimizationAction{
+				Rule:        rule,
+				Risk:        models.RiskBehavior,
+				Filepath:    p.directory.GetDockerfileFilePath(),
+				Line:        s.Line,
+				Title:       fmt.Sprintf("Pass %s to the build with a secret mount", s.Name),
+				Description: fmt.Sprintf("%s is stored in the image, but it was left as it is because %s. Mount it only in the RUN instructions that need it with '--mount=type=secret,id=%s,env=%s' and build with 'docker build --secret id=%s,env=%s'.", s.Name, r.Reason, strings.ToLower(s.Name), s.Name, strings.ToLower(s.Name), s.Name),
+			})
+			continue
+		}
+		if len(r.Runs) == 0 {
+			// an ARG that's only used through the ENV set to it is reported along with the ENV
+			continue
+		}
+
+		runs := make([]string, 0, len(r.Runs))
+		for _, line := range r.Runs {
+			runs = append(runs, fmt.Sprint(line))
+		}
+		where := "a build argument"
+		if s.Cmd == dockerfile.CmdEnv {
+			where = fmt.Sprintf("an ENV variable set to the build argument %s", s.Source)
+		}
+		p.addActionTaken(&models.OptimizationAction{
+			Rule:     rule,
+			Risk:     models.RiskBehavior,
+			Filepath: p.directory.GetDockerfileFilePath(),
+			Line:     s.Line,
+			Title:    fmt.Sprintf("Replaced the %s %s with a secret mount", s.Cmd, s.Name),
+			Description: fmt.Sprintf("%s was passed as %s, which stores it in the image where anyone who can pull the image can read it. It's now only mounted in the RUN instruction(s) on line(s) %s. Build the image with 'docker build --secret %s' instead of passing it with --build-arg.",
+				s.Name, where, strings.Join(runs, ", "), r.Build),
+		})
+	}
+
+	if migration.SyntaxChanged {
+		p.addActionTaken(&models.OptimizationAction{
+			Rule:        rule,
+			Risk:        models.RiskCache,
+			Filepath:    p.directory.GetDockerfileFilePath(),
+			Line:        1,
+			Title:       "Set the Dockerfile syntax to support secrets mounted as environment variables",
+			Description: fmt.Sprintf("Secret mounts with the env option need version 1.10 of the Dockerfile syntax or newer, so the syntax directive was set to '%s'.", secrets.MountSyntax),
+		})
+	}
+	return migration.BuildSecrets()
+}
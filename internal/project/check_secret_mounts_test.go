@@ -0,0 +1,76 @@
+package project
+
+import (
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+)
+
+func TestMigrateSecretMounts(t *testing.T) {
+	tests := []struct {
+		name            string
+		input           string
+		expected        string
+		secrets         []string
+		actions         int
+		recommendations int
+	}{
+		{
+			name:     "build argument used by an install",
+			input:    "FROM node:22-alpine\nARG NPM_TOKEN\nRUN npm ci\n",
+			expected: "# syntax=docker/dockerfile:1\nFROM node:22-alpine\nRUN --mount=type=secret,id=npm_token,env=NPM_TOKEN npm ci\n",
+			secrets:  []string{"id=npm_token,env=NPM_TOKEN"},
+			// the secret mount and the syntax directive
+			actions: 2,
+		},
+		{
+			name:     "env set to a build argument",
+			input:    "# syntax=docker/dockerfile:1.10\nFROM node:22-alpine\nARG NPM_TOKEN\nENV NPM_TOKEN=${NPM_TOKEN}\nRUN npm ci\n",
+			expected: "# syntax=docker/dockerfile:1.10\nFROM node:22-alpine\nRUN --mount=type=secret,id=npm_token,env=NPM_TOKEN npm ci\n",
+			secrets:  []string{"id=npm_token,env=NPM_TOKEN"},
+			actions:  1,
+		},
+		{
+			name:            "secret used outside RUN",
+			input:           "FROM node:22-alpine\nARG NPM_TOKEN\nLABEL token=$NPM_TOKEN\nRUN npm ci\n",
+			expected:        "FROM node:22-alpine\nARG NPM_TOKEN\nLABEL token=$NPM_TOKEN\nRUN npm ci\n",
+			recommendations: 1,
+		},
+		{
+			name:     "literal secrets are left to the static rules",
+			input:    "FROM node:22-alpine\nENV NPM_TOKEN=abc\nRUN npm ci\n",
+			expected: "FROM node:22-alpine\nENV NPM_TOKEN=abc\nRUN npm ci\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.input)
+			if err != nil {
+				t.Fatalf("failed to parse dockerfile: %v", err)
+			}
+			fs := restrictedfilesystem.NewRestrictedFilesystem("/tmp", "", "Dockerfile", ".dockerignore")
+			p := NewProject(df, nil, nil, fs, nil, "")
+
+			secrets := p.migrateSecretMounts()
+			if p.dockerfile.Raw() != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, p.dockerfile.Raw())
+			}
+			if len(secrets) != len(tt.secrets) {
+				t.Fatalf("expected %d build secrets, got %d", len(tt.secrets), len(secrets))
+			}
+			for i, s := range secrets {
+				if s.String() != tt.secrets[i] {
+					t.Errorf("expected build secret %q, got %q", tt.secrets[i], s.String())
+				}
+			}
+			if len(p.actionsTaken) != tt.actions {
+				t.Errorf("expected %d actions, got %d", tt.actions, len(p.actionsTaken))
+			}
+			if len(p.recommendations) != tt.recommendations {
+				t.Errorf("expected %d recommendations, got %d", tt.recommendations, len(p.recommendations))
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package project
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+)
+
+// mergeSystemPackageInstalls merges consecutive RUN instructions of a stage that only install system packages
+// with the same package manager into the first one, so that the packages are installed in a single layer
+func (p *Project) mergeSystemPackageInstalls() {
+	for p.mergeLastSystemPackageInstalls() {
+	}
+}
+
+// mergeLastSystemPackageInstalls merges the last pair of consecutive RUN instructions that can be merged.
+// It returns false if there's none.
+func (p *Project) mergeLastSystemPackageInstalls() bool {
+	scripts := map[int]*runScript{}
+	for _, r := range p.runScripts(p.dockerfile.GetStages()) {
+		scripts[r.inst.StartLine()] = r
+	}
+	stages := p.dockerfile.GetStages()
+	for s := len(stages) - 1; s >= 0; s-- {
+		instructions := stages[s].Instructions()
+		for i := len(instructions) - 1; i > 0; i-- {
+			first, second := scripts[instructions[i-1].StartLine()], scripts[instructions[i].StartLine()]
+			if first == nil || second == nil || !canMergeRuns(first, second) {
+				continue
+			}
+			firstLine, secondLine := first.inst.StartLine(), second.inst.StartLine()
+			// the second instruction is removed first, so that the lines of the first one don't move
+			code := strings.TrimRight(first.code, " \t") + " && \\" + dockerfile.Linebreak + "    " + strings.TrimSpace(second.code)
+			if err := p.dockerfile.RemoveInstruction(second.inst); err != nil {
+				return false
+			}
+			if err := p.dockerfile.ReplaceShellCommand(first.inst, code); err != nil {
+				return false
+			}
+			p.addActionTaken(&models.OptimizationAction{
+				Rule:        "separate-system-package-installs",
+				Risk:        models.RiskCache,
+				Filepath:    p.directory.GetDockerfileFilePath(),
+				Line:        firstLine,
+				Title:       fmt.Sprintf("Merged the package installs of the RUN instructions on lines %d and %d", firstLine, secondLine),
+				Description: "Every RUN instruction adds a layer, and installing packages in separate ones usually downloads the package index again. The packages are now installed by a single RUN instruction.",
+			})
+			return true
+		}
+	}
+	return false
+}
+
+// canMergeRuns returns true if the second RUN instruction can be appended to the first one: both only install
+// packages with the same package manager and are run with the same flags, eg- mounts
+func canMergeRuns(first, second *runScript) bool {
+	family := rules.SystemInstallOnly(first.script)
+	if family == "" || family != rules.SystemInstallOnly(second.script) {
+		return false
+	}
+	// comments above the second instruction would end up above the instruction that follows it
+	if len(second.inst.Comments()) > 0 {
+		return false
+	}
+	statements := first.script.Statements()
+	if len(statements) == 0 || statements[len(statements)-1].Background {
+		return false
+	}
+	return rules.SameFlags(first.inst, second.inst)
+}
+
+// addSystemInstallFlag adds the option that keeps the package manager from adding files the image doesn't need
+// to the commands that install system packages without it: "--no-install-recommends" to apt-get install or
+// "--no-cache" to apk add
+func (p *Project) addSystemInstallFlag(flag string) {
+	for _, r := range p.runScripts(p.dockerfile.GetStages()) {
+		// an apk cache mount is meant to keep the index around
+		if flag == "--no-cache" && rules.CachesApkIndex(r.inst) {
+			continue
+		}
+		installs := rules.SystemInstalls(r.script)
+		code := r.code
+		added := 0
+		for i := len(installs) - 1; i >= 0; i-- {
+			install := installs[i]
+			if install.MissingFlag() != flag {
+				continue
+			}
+			end := install.Command.ArgEnds[install.Subcommand]
+			code = code[:end] + " " + flag + code[end:]
+			added++
+		}
+		if added == 0 {
+			continue
+		}
+		if err := p.dockerfile.ReplaceShellCommand(r.inst, code); err != nil {
+			continue
+		}
+		action := &models.OptimizationAction{
+			Rule:        "apt-get-install-recommends",
+			Risk:        models.RiskBehavior,
+			Filepath:    p.directory.GetDockerfileFilePath(),
+			Line:        r.inst.StartLine(),
+			Title:       "Added --no-install-recommends to apt-get install",
+			Description: "apt-get installed the recommended packages of every package, which are rarely needed in an image. They're now skipped. If the image relied on one of them, eg- ca-certificates for curl, install it explicitly.",
+		}
+		if flag == "--no-cache" {
+			action.Rule = "apk-add-cache"
+			action.Risk = models.RiskSize
+			action.Title = "Added --no-cache to apk add"
+			action.Description = "apk stored the package index it downloaded in /var/cache/apk, which isn't needed once the packages are installed. It's now downloaded without being stored in the layer."
+		}
+		p.addActionTaken(action)
+	}
+}
+
+// recommendBuilderStage recommends moving the build tools installed in the final image to a build stage,
+// which can't be done automatically since what they build has to be copied from it
+func (p *Project) recommendBuilderStage() {
+	for _, tools := range rules.BuildTools(p.rulesContext()) {
+		packages := strings.Join(tools.Packages, ", ")
+		p.addRecommendation(&models.OptimizationAction{
+			Rule:        "build-tools-in-final-stage",
+			Risk:        models.RiskBehavior,
+			Filepath:    p.directory.GetDockerfileFilePath(),
+			Line:        tools.Instruction.StartLine(),
+			Title:       fmt.Sprintf("Install %s in a build stage", packages),
+			Description: fmt.Sprintf("%s are only needed to compile code while building, but they're installed in the final image. Install them in a build stage, compile there (eg- the native modules in node_modules) and copy only the result into the final stage with 'COPY --from=<stage>'.", packages),
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package project
+
+import (
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+)
+
+func TestMergeSystemPackageInstalls(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		actions  int
+	}{
+		{
+			name:     "consecutive installs",
+			input:    "FROM debian:12-slim\nRUN apt-get update && apt-get install -y curl\nRUN apt-get install -y \\\n      git\nRUN apt-get install -y jq\nCMD [\"bash\"]\n",
+			expected: "FROM debian:12-slim\nRUN apt-get update && apt-get install -y curl && \\\n    apt-get install -y \\\n      git && \\\n    apt-get install -y jq\nCMD [\"bash\"]\n",
+			actions:  2,
+		},
+		{
+			name:     "other commands, managers or flags",
+			input:    "FROM debian:12-slim\nRUN apt-get update && apt-get install -y curl\nRUN cd /tmp && apt-get install -y git\nRUN apt-get install -y jq\nRUN --mount=type=cache,target=/var/cache/apt apt-get install -y make\n",
+			expected: "FROM debian:12-slim\nRUN apt-get update && apt-get install -y curl\nRUN cd /tmp && apt-get install -y git\nRUN apt-get install -y jq\nRUN --mount=type=cache,target=/var/cache/apt apt-get install -y make\n",
+		},
+		{
+			name:     "comments above the second install",
+			input:    "FROM alpine:3.20\nRUN apk add --no-cache curl\n# for the healthcheck\nRUN apk add --no-cache wget\n",
+			expected: "FROM alpine:3.20\nRUN apk add --no-cache curl\n# for the healthcheck\nRUN apk add --no-cache wget\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.input)
+			if err != nil {
+				t.Fatalf("failed to parse dockerfile: %v", err)
+			}
+			fs := restrictedfilesystem.NewRestrictedFilesystem("/tmp", "", "Dockerfile", ".dockerignore")
+			p := NewProject(df, nil, nil, fs, nil, "")
+
+			p.mergeSystemPackageInstalls()
+			if p.dockerfile.Raw() != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, p.dockerfile.Raw())
+			}
+			if len(p.actionsTaken) != tt.actions {
+				t.Errorf("expected %d actions, got %d", tt.actions, len(p.actionsTaken))
+			}
+		})
+	}
+}
+
+func TestAddSystemInstallFlag(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		flag     string
+		expected string
+		actions  int
+	}{
+		{
+			name:     "apt-get install",
+			input:    "FROM debian:12-slim\nRUN apt-get update && sudo apt-get -y install curl && apt install --no-install-recommends git\n",
+			flag:     "--no-install-recommends",
+			expected: "FROM debian:12-slim\nRUN apt-get update && sudo apt-get -y install --no-install-recommends curl && apt install --no-install-recommends git\n",
+			actions:  1,
+		},
+		{
+			name:     "apk add",
+			input:    "FROM alpine:3.20\nRUN apk add curl\nRUN --mount=type=cache,target=/var/cache/apk apk add git\n",
+			flag:     "--no-cache",
+			expected: "FROM alpine:3.20\nRUN apk add --no-cache curl\nRUN --mount=type=cache,target=/var/cache/apk apk add git\n",
+			actions:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.input)
+			if err != nil {
+				t.Fatalf("failed to parse dockerfile: %v", err)
+			}
+			fs := restrictedfilesystem.NewRestrictedFilesystem("/tmp", "", "Dockerfile", ".dockerignore")
+			p := NewProject(df, nil, nil, fs, nil, "")
+
+			p.addSystemInstallFlag(tt.flag)
+			if p.dockerfile.Raw() != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, p.dockerfile.Raw())
+			}
+			if len(p.actionsTaken) != tt.actions {
+				t.Errorf("expected %d actions, got %d", tt.actions, len(p.actionsTaken))
+			}
+		})
+	}
+}
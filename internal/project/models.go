@@ -1,6 +1,37 @@
 package project
 
-import "github.com/duaraghav8/dockershrink/internal/models"
+import (
+	"github.com/duaraghav8/dockershrink/internal/buildargs"
+	"github.com/duaraghav8/dockershrink/internal/distroless"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/pinning"
+	"github.com/duaraghav8/dockershrink/internal/platform"
+	"github.com/duaraghav8/dockershrink/internal/secrets"
+)
+
+// OptimizeOptions controls how the Docker image of a project is optimized
+type OptimizeOptions struct {
+	// Goal decides which rules are run. Defaults to models.GoalAll.
+	Goal models.Goal
+	// PinResolver is used to pin every base image to its digest. Base images aren't pinned if it's nil.
+	PinResolver pinning.Resolver
+	// Platforms the image is built for. Base images that aren't published for all of them are never recommended.
+	Platforms []platform.Platform
+	// MaxRisk is the riskiest change that's applied, riskier ones are returned as recommendations instead.
+	// Every change is applied if it's empty.
+	MaxRisk models.Risk
+	// Hardening adds a non-root USER, a HEALTHCHECK and other container hardening to the final stage.
+	// It only runs when the goal includes security.
+	Hardening bool
+	// MigrateTo moves the final stage to a distroless or chiseled image, its base image is kept if it's empty.
+	MigrateTo distroless.Target
+	// Libraries finds the shared libraries the application loads in the original image, so that they're copied
+	// into the migrated one. They aren't if it's nil.
+	Libraries distroless.LibraryResolver
+	// Explain estimates how much every change made on its own saves, which estimates the size of the image
+	// before and after each step of the optimization
+	Explain bool
+}
 
 type OptimizationResponse struct {
 	Dockerfile   string
@@ -8,9 +39,52 @@ type OptimizationResponse struct {
 
 	ActionsTaken    []*models.OptimizationAction
 	Recommendations []*models.OptimizationAction
+
+	// BuildSecrets are the secrets the optimized Dockerfile mounts, which must be passed to
+	// the build with "docker build --secret"
+	BuildSecrets []*secrets.BuildSecret
+
+	// EstimatedSizeBefore and EstimatedSizeAfter are the estimated sizes of the image in bytes before and
+	// after the optimization, 0 if they couldn't be estimated
+	EstimatedSizeBefore int64
+	EstimatedSizeAfter  int64
+	// ScoreBefore and ScoreAfter grade the Dockerfile before and after the optimization, like analyze
+	ScoreBefore int
+	ScoreAfter  int
+
+	// PolicyViolations are the organization policies the optimized Dockerfile still violates
+	PolicyViolations []*models.Finding
 }
 
 type GenerationResponse struct {
 	Dockerfile   string
 	Dockerignore string
+
+	// PolicyViolations are the organization policies the generated Dockerfile violates
+	PolicyViolations []*models.Finding
+}
+
+// AnalyzeOptions controls how the Docker image definition of a project is analyzed
+type AnalyzeOptions struct {
+	// Goal decides which rules are run. Defaults to models.GoalAll.
+	Goal models.Goal
+	// Platforms the image is built for, base images are checked against them
+	Platforms []platform.Platform
+	// Severities overrides the severity of rules, keyed by rule ID
+	Severities map[string]models.Severity
+	// BuildArgs are the combinations of build arguments the image is built with.
+	// The image definition is analyzed once more under each of them.
+	BuildArgs []*buildargs.Combination
+}
+
+type AnalysisResponse struct {
+	Findings []*models.Finding
+	// Score grades the image definition from 0 (worst) to 100 (no inefficiencies found)
+	Score int
+	// Sizes estimates what the instructions of the final image add to its size, biggest first
+	Sizes []*models.InstructionSize
+	// EstimatedImageSize is the estimated size of the image in bytes, 0 if it couldn't be estimated
+	EstimatedImageSize int64
+	// Variants are the analyses under each combination of build arguments, in the order they were given
+	Variants []*models.Variant
 }
@@ -0,0 +1,56 @@
+package project
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/packagelock"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+)
+
+// dockerfileNotes describes what the LLM can't tell from the Dockerfile's code alone: the images that
+// build arguments in FROM instructions resolve to and the ONBUILD triggers that run at the start of a stage.
+// An empty string is returned if there is nothing to describe.
+func dockerfileNotes(d *dockerfile.Dockerfile) string {
+	notes := []string{}
+	for _, stage := range d.GetStages() {
+		name := stageLabel(stage)
+		declared := stage.DeclaredBaseImage()
+		if resolved := stage.BaseImage().FullName(); strings.Contains(declared, "$") && !strings.Contains(resolved, "$") {
+			notes = append(notes, fmt.Sprintf("* %s is built from '%s' by default (FROM %s). Keep using the build arguments so that the image can still be chosen when building.", name, resolved, declared))
+		}
+		triggers := d.GetOnbuildTriggers(stage)
+		if len(triggers) == 0 {
+			continue
+		}
+		steps := make([]string, 0, len(triggers))
+		for _, t := range triggers {
+			steps = append(steps, t.Original())
+		}
+		notes = append(notes, fmt.Sprintf("* %s runs these ONBUILD triggers of '%s' before its own instructions: %s", name, declared, strings.Join(steps, "; ")))
+	}
+	return strings.Join(notes, "\n")
+}
+
+// stageLabel refers to a stage by its name, or its position if it doesn't have one
+func stageLabel(stage *dockerfile.Stage) string {
+	if stage.Name() != "" {
+		return fmt.Sprintf("Stage '%s'", stage.Name())
+	}
+	return fmt.Sprintf("Stage %d", stage.Index()+1)
+}
+
+// nativeModuleNotes describes the native modules of the application's dependencies, which the LLM has to keep
+// working when it changes the base images. An empty string is returned if there are none.
+func (p *Project) nativeModuleNotes() string {
+	native := rules.NativeModules(p.rulesContext())
+	if len(native) == 0 {
+		return ""
+	}
+	note := fmt.Sprintf("* The production dependencies include native modules: %s. They're compiled or downloaded for the C library (glibc or musl) of the stage that installs them, so they fail to load in a final stage based on another distribution, eg- alpine instead of debian.", strings.Join(packagelock.Names(native), ", "))
+	if packages, _ := rules.MissingRuntimePackages(p.rulesContext()); len(packages) > 0 {
+		note += fmt.Sprintf(" The final stage must install %s, which they load at runtime.", strings.Join(packages, ", "))
+	}
+	return note
+}
@@ -0,0 +1,44 @@
+package project
+
+import (
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+)
+
+func TestDockerfileNotes(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected string
+	}{
+		{
+			name:     "nothing to describe",
+			code:     "FROM node:20-alpine\nCMD [\"node\", \"index.js\"]\n",
+			expected: "",
+		},
+		{
+			name: "build arguments and ONBUILD triggers",
+			code: `ARG NODE_VERSION=20
+FROM node:${NODE_VERSION} AS base
+ONBUILD COPY . /app
+ONBUILD RUN npm ci
+FROM base
+`,
+			expected: "* Stage 'base' is built from 'node:20' by default (FROM node:${NODE_VERSION}). Keep using the build arguments so that the image can still be chosen when building.\n" +
+				"* Stage 2 runs these ONBUILD triggers of 'base' before its own instructions: COPY . /app; RUN npm ci",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.code)
+			if err != nil {
+				t.Fatalf("failed to parse Dockerfile: %v", err)
+			}
+			if got := dockerfileNotes(df); got != tt.expected {
+				t.Errorf("dockerfileNotes() =\n%s\nwant\n%s", got, tt.expected)
+			}
+		})
+	}
+}
@@ -1,15 +1,30 @@
 package project
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"strings"
 
 	"github.com/duaraghav8/dockershrink/internal/ai"
+	"github.com/duaraghav8/dockershrink/internal/baseimages"
 	"github.com/duaraghav8/dockershrink/internal/dockerfile"
 	"github.com/duaraghav8/dockershrink/internal/dockerignore"
+	"github.com/duaraghav8/dockershrink/internal/explain"
+	"github.com/duaraghav8/dockershrink/internal/fileindex"
+	"github.com/duaraghav8/dockershrink/internal/kubernetes"
 	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/opa"
 	"github.com/duaraghav8/dockershrink/internal/packagejson"
+	"github.com/duaraghav8/dockershrink/internal/platform"
+	"github.com/duaraghav8/dockershrink/internal/policy"
+	"github.com/duaraghav8/dockershrink/internal/projectinfo"
 	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+	"github.com/duaraghav8/dockershrink/internal/secrets"
+	"github.com/duaraghav8/dockershrink/internal/workspace"
+	"github.com/duaraghav8/dockershrink/pkg/events"
 )
 
 type Project struct {
@@ -21,6 +36,25 @@ type Project struct {
 	actionsTaken    []*models.OptimizationAction
 
 	directory *restrictedfilesystem.RestrictedFilesystem
+
+	// workspace is only set if the project is a monorepo
+	workspace        *workspace.Workspace
+	workspacePackage string
+
+	// baseImages is nil if no data about official base images is available
+	baseImages *baseimages.Matrix
+	// files is nil if the project hasn't been indexed
+	files *fileindex.Index
+
+	events events.Handler
+	// platforms the image is built for, empty if they weren't declared
+	platforms []platform.Platform
+	// policies are the organization policies the image definition must comply with
+	policies []*policy.Policy
+	// manifests are the containers of the Kubernetes manifests that run the image
+	kubernetesContainers []*kubernetes.Container
+	// explain measures the size impact of every step of the optimization
+	explain bool
 }
 
 func NewProject(
@@ -28,46 +62,86 @@ func NewProject(
 	dockerignore *dockerignore.Dockerignore,
 	packageJson *packagejson.PackageJSON,
 	directory *restrictedfilesystem.RestrictedFilesystem,
+	ws *workspace.Workspace,
+	workspacePackage string,
 ) *Project {
 	return &Project{
-		dockerfile:      dockerfile,
-		dockerignore:    dockerignore,
-		packageJSON:     packageJson,
-		directory:       directory,
-		recommendations: []*models.OptimizationAction{},
-		actionsTaken:    []*models.OptimizationAction{},
+		dockerfile:       dockerfile,
+		dockerignore:     dockerignore,
+		packageJSON:      packageJson,
+		directory:        directory,
+		workspace:        ws,
+		workspacePackage: workspacePackage,
+		recommendations:  []*models.OptimizationAction{},
+		actionsTaken:     []*models.OptimizationAction{},
 	}
 }
 
-func (p *Project) OptimizeDockerImage(aiService *ai.AIService) (*OptimizationResponse, error) {
-	p.createAndOptimizeDockerignore()
+// SetBaseImages sets the matrix of official images used to recommend smaller and supported base images
+func (p *Project) SetBaseImages(m *baseimages.Matrix) {
+	p.baseImages = m
+}
+
+// SetFileIndex sets the index of the project's files, used instead of walking the project directory
+func (p *Project) SetFileIndex(x *fileindex.Index) {
+	p.files = x
+}
+
+// SetPolicies sets the organization policies the image definition must comply with. Analyses report their
+// violations, and optimizations and generations are asked to comply with them.
+func (p *Project) SetPolicies(policies []*policy.Policy) {
+	p.policies = policies
+}
+
+// SetManifests sets the containers of the Kubernetes manifests and Helm values that run the image,
+// which analyses cross-check the Dockerfile against
+func (p *Project) SetManifests(containers []*kubernetes.Container) {
+	p.kubernetesContainers = containers
+}
+
+// SetEvents sets the handler that receives the progress of operations on the project
+func (p *Project) SetEvents(h events.Handler) {
+	p.events = h
+}
+
+func (p *Project) OptimizeDockerImage(ctx context.Context, aiService *ai.AIService, opts *OptimizeOptions) (*OptimizationResponse, error) {
+	goal := defaultGoal(opts.Goal)
+	p.platforms = opts.Platforms
+	p.explain = opts.Explain
+	p.events.Emit(events.AnalysisStarted{
+		Operation:  events.OperationOptimize,
+		Goal:       string(goal),
+		Dockerfile: p.directory.GetDockerfileFilePath(),
+	})
+	resp, err := p.optimizeDockerImage(ctx, aiService, goal, opts)
+	p.events.Emit(events.AnalysisFinished{Operation: events.OperationOptimize, Err: err})
+	return resp, err
+}
+
+func (p *Project) optimizeDockerImage(ctx context.Context, aiService *ai.AIService, goal models.Goal, opts *OptimizeOptions) (*OptimizationResponse, error) {
+	sizeBefore := p.estimateImageSize()
+	scoreBefore := p.score()
+
+	// A smaller build context speeds up builds and keeps unnecessary files out of the image
+	if goal.Includes(models.GoalSize, models.GoalBuildSpeed) {
+		p.applyStep(opts.MaxRisk, func() error {
+			p.createAndOptimizeDockerignore()
+			return nil
+		})
+	}
+	if p.dockerignore == nil {
+		p.dockerignore = dockerignore.NewDockerignore("")
+	}
 
 	// Optimize Dockerfile
 	originalDockerfile := p.dockerfile
 
 	if aiService != nil {
-		req := &ai.OptimizeRequest{
-			Dockerfile:           p.dockerfile.Raw(),
-			Dockerignore:         p.dockerignore.Raw(),
-			PackageJSON:          p.packageJSON.String(),
-			ProjectDirectory:     p.directory,
-			DockerfileStageCount: p.dockerfile.GetStageCount(),
-		}
-		resp, err := aiService.OptimizeDockerfile(req)
+		_, err := p.applyStep(opts.MaxRisk, func() error {
+			return p.optimizeWithAI(ctx, aiService, goal, originalDockerfile)
+		})
 		if err != nil {
-			return nil, fmt.Errorf("AI service failed to optimize Dockerfile: %w", err)
-		}
-
-		p.dockerfile, err = dockerfile.NewDockerfile(resp.Dockerfile)
-		if err != nil {
-			return nil, fmt.Errorf("Failed to process Dockerfile returned by AI service: %w", err)
-		}
-
-		for _, r := range resp.Recommendations {
-			p.addRecommendation(r)
-		}
-		for _, a := range resp.ActionsTaken {
-			p.addActionTaken(a)
+			return nil, err
 		}
 	}
 
@@ -86,26 +160,270 @@ func (p *Project) OptimizeDockerImage(aiService *ai.AIService) (*OptimizationRes
 	origFinalStageBaseImage := origFinalStage.BaseImage()
 	newFinalStageBaseImage := newFinalStage.BaseImage()
 
-	if (origStageCount == newStageCount) && (origFinalStageBaseImage.FullName() == newFinalStageBaseImage.FullName()) {
-		p.finalStageLightBaseImage()
+	// a migrated final stage gets a minimal base image anyway
+	if goal.Includes(models.GoalSize, models.GoalSecurity) && opts.MigrateTo == "" &&
+		(origStageCount == newStageCount) &&
+		(origFinalStageBaseImage.FullName() == newFinalStageBaseImage.FullName()) {
+		p.applyStep(opts.MaxRisk, func() error {
+			p.finalStageLightBaseImage()
+			return nil
+		})
+	}
+
+	if opts.MigrateTo != "" && goal.Includes(models.GoalSize, models.GoalSecurity) {
+		p.applyStep(opts.MaxRisk, func() error {
+			p.migrateFinalStage(ctx, opts.MigrateTo, opts.Libraries)
+			return nil
+		})
+	}
+
+	if goal.Includes(models.GoalBuildSpeed) {
+		p.applyStep(opts.MaxRisk, func() error {
+			p.reorderLayers()
+			return nil
+		})
+	}
+
+	if goal.Includes(models.GoalSize, models.GoalBuildSpeed) {
+		p.monorepoWorkspacePruning()
+	}
+
+	var buildSecrets []*secrets.BuildSecret
+	if goal.Includes(models.GoalSecurity) {
+		applied, _ := p.applyStep(opts.MaxRisk, func() error {
+			buildSecrets = p.migrateSecretMounts()
+			return nil
+		})
+		if !applied {
+			buildSecrets = nil
+		}
+	}
+
+	if opts.Hardening && goal.Includes(models.GoalSecurity) {
+		p.applyStep(opts.MaxRisk, func() error {
+			p.hardenFinalStage()
+			return nil
+		})
 	}
 
+	if goal.Includes(models.GoalSize) {
+		p.applyStep(opts.MaxRisk, func() error {
+			p.narrowRuntimeCopy()
+			return nil
+		})
+	}
+
+	if goal.Includes(models.GoalSize, models.GoalBuildSpeed) {
+		p.applyStep(opts.MaxRisk, func() error {
+			p.mergeSystemPackageInstalls()
+			return nil
+		})
+	}
+	if goal.Includes(models.GoalSize) {
+		// the flags are added in separate steps since skipping recommended packages can change the behavior
+		p.applyStep(opts.MaxRisk, func() error {
+			p.addSystemInstallFlag("--no-cache")
+			return nil
+		})
+		p.applyStep(opts.MaxRisk, func() error {
+			p.addSystemInstallFlag("--no-install-recommends")
+			return nil
+		})
+	}
+	if goal.Includes(models.GoalSize, models.GoalSecurity) {
+		p.recommendBuilderStage()
+	}
+	if goal.Includes(models.GoalBuildSpeed, models.GoalSecurity) {
+		p.applyStep(opts.MaxRisk, func() error {
+			p.freezeDependencyInstalls()
+			return nil
+		})
+	}
+
+	// the scripts of RUN instructions are fixed after the steps that add or change them
+	p.applyStep(opts.MaxRisk, func() error {
+		p.chainRunCommands()
+		return nil
+	})
+	if goal.Includes(models.GoalSize) {
+		p.applyStep(opts.MaxRisk, func() error {
+			p.cleanUpRunCommands()
+			return nil
+		})
+	}
+	if goal.Includes(models.GoalSecurity) {
+		p.recommendVerifiedScripts()
+	}
+
+	// pin last, so that base images changed by the other optimizations are pinned too
+	if opts.PinResolver != nil {
+		p.applyStep(opts.MaxRisk, func() error {
+			p.pinBaseImages(ctx, opts.PinResolver)
+			return nil
+		})
+	}
+
+	// a cancelled run would otherwise be reported as digests that couldn't be resolved
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	explain.Annotate(p.actionsTaken)
+	explain.Annotate(p.recommendations)
 	return &OptimizationResponse{
 		Dockerfile:      p.dockerfile.Raw(),
 		Dockerignore:    p.dockerignore.Raw(),
 		ActionsTaken:    p.actionsTaken,
 		Recommendations: p.recommendations,
+		BuildSecrets:    buildSecrets,
+
+		EstimatedSizeBefore: sizeBefore,
+		EstimatedSizeAfter:  p.estimateImageSize(),
+		ScoreBefore:         scoreBefore,
+		ScoreAfter:          p.score(),
+		PolicyViolations:    rules.CheckPolicies(p.rulesContext()),
 	}, nil
 }
 
-func (p *Project) GenerateDockerImage(aiService *ai.AIService) (*GenerationResponse, error) {
+// estimateImageSize estimates the size of the image built from the project's Dockerfile in its current state
+func (p *Project) estimateImageSize() int64 {
+	c := p.rulesContext()
+	return rules.EstimateImageSize(c, rules.EstimateSizes(c))
+}
+
+// score grades the project's Dockerfile in its current state against all the rules, like analyze does by default
+func (p *Project) score() int {
+	return rules.Score(rules.Run(p.rulesContext(), models.GoalAll))
+}
+
+// AnalyzeDockerImage runs static analysis on the project's image definition and reports its inefficiencies.
+// The project is never modified.
+func (p *Project) AnalyzeDockerImage(opts *AnalyzeOptions) *AnalysisResponse {
+	goal := defaultGoal(opts.Goal)
+	p.platforms = opts.Platforms
+	p.events.Emit(events.AnalysisStarted{
+		Operation:  events.OperationAnalyze,
+		Goal:       string(goal),
+		Dockerfile: p.directory.GetDockerfileFilePath(),
+	})
+	c := p.rulesContext()
+	c.Severities = opts.Severities
+	findings := rules.Run(c, goal)
+	for _, f := range findings {
+		p.events.Emit(events.RuleApplied{Rule: f.Rule, Title: f.Title, Filepath: f.Filepath, Line: f.Line})
+	}
+
+	variants := []*models.Variant{}
+	for _, combination := range opts.BuildArgs {
+		vc := p.rulesContext()
+		vc.Dockerfile = p.dockerfile.WithBuildArgs(combination.Args)
+		vc.Severities = opts.Severities
+		vf := rules.Run(vc, goal)
+		variants = append(variants, &models.Variant{
+			Name:     combination.Name,
+			Args:     combination.Args,
+			Score:    rules.Score(vf),
+			Findings: vf,
+		})
+	}
+	p.events.Emit(events.AnalysisFinished{Operation: events.OperationAnalyze})
+	sizes := rules.EstimateSizes(c)
+	return &AnalysisResponse{
+		Findings:           findings,
+		Score:              rules.Score(findings),
+		Sizes:              sizes,
+		EstimatedImageSize: rules.EstimateImageSize(c, sizes),
+		Variants:           variants,
+	}
+}
+
+// CheckPolicyBundles evaluates the image definition against the Rego policies of the OPA bundles
+// and returns their violations as findings
+func (p *Project) CheckPolicyBundles(ctx context.Context, bundles []string) ([]*models.Finding, error) {
+	path := p.directory.GetDockerfileFilePath()
+	input := opa.NewInput(path, p.dockerfile)
+	findings := []*models.Finding{}
+	for _, bundle := range bundles {
+		violations, err := opa.Evaluate(ctx, bundle, input)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range violations {
+			findings = append(findings, rules.PolicyBundleFinding(path, v))
+		}
+	}
+	return findings, nil
+}
+
+// CheckRulePlugins runs the rules of the plugins on the image definition and returns their findings.
+// severities overrides the severities of the rules of the plugins, like those of the built-in rules.
+func (p *Project) CheckRulePlugins(ctx context.Context, plugins []*rules.RulePlugin, severities map[string]models.Severity) ([]*models.Finding, error) {
+	c := p.rulesContext()
+	c.Severities = severities
+	facts := projectinfo.Inspect(p.directory.FS(), p.packageJSON)
+	findings := []*models.Finding{}
+	for _, plugin := range plugins {
+		found, err := plugin.Check(ctx, c, facts)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, found...)
+	}
+	return findings, nil
+}
+
+func (p *Project) rulesContext() *rules.Context {
+	return &rules.Context{
+		Dockerfile:       p.dockerfile,
+		DockerfilePath:   p.directory.GetDockerfileFilePath(),
+		Dockerignore:     p.dockerignore,
+		DockerignorePath: p.directory.GetDockerignoreFilePath(),
+		PackageJSON:      p.packageJSON,
+		Workspace:        p.workspace,
+		ProjectDir:       p.directory.FS(),
+		Files:            p.files,
+		BuildContexts:    p.buildContextFS(),
+		BaseImages:       p.baseImages,
+		Platforms:        p.platforms,
+		Policies:         p.policies,
+		Manifests:        p.kubernetesContainers,
+	}
+}
+
+// buildContextFS returns the named build contexts of the project keyed by name, with nil for those that aren't local
+func (p *Project) buildContextFS() map[string]fs.FS {
+	contexts := map[string]fs.FS{}
+	for _, c := range p.directory.BuildContexts() {
+		contexts[c.Name] = p.directory.BuildContextFS(c.Name)
+	}
+	return contexts
+}
+
+// baseIsNamedContext returns true if a named build context replaces the image the stage is built from
+func (p *Project) baseIsNamedContext(stage *dockerfile.Stage) bool {
+	return p.directory.BuildContext(stage.BaseImage().FamiliarName()) != nil
+}
+
+func (p *Project) GenerateDockerImage(ctx context.Context, aiService *ai.AIService, info *projectinfo.Info) (*GenerationResponse, error) {
+	p.events.Emit(events.AnalysisStarted{Operation: events.OperationGenerate})
+	resp, err := p.generateDockerImage(ctx, aiService, info)
+	p.events.Emit(events.AnalysisFinished{Operation: events.OperationGenerate, Err: err})
+	return resp, err
+}
+
+func (p *Project) generateDockerImage(ctx context.Context, aiService *ai.AIService, info *projectinfo.Info) (*GenerationResponse, error) {
 	p.createAndOptimizeDockerignore()
+	p.dockerignore.AddIfNotPresent(generatedDockerignoreEntries(info))
 
 	req := &ai.GenerateRequest{
 		PackageJSON:      p.packageJSON.String(),
 		ProjectDirectory: p.directory,
+		ProjectInfo:      info,
+		Workspace:        p.workspace,
+		WorkspacePackage: p.workspacePackage,
+		Policies:         policy.Describe(p.policies),
 	}
-	resp_df, err := aiService.GenerateDockerfile(req)
+	resp_df, err := aiService.GenerateDockerfile(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("AI service failed to generate Dockerfile: %w", err)
 	}
@@ -116,17 +434,138 @@ func (p *Project) GenerateDockerImage(aiService *ai.AIService) (*GenerationRespo
 	}
 
 	return &GenerationResponse{
-		Dockerfile:   p.dockerfile.Raw(),
-		Dockerignore: p.dockerignore.Raw(),
+		Dockerfile:       p.dockerfile.Raw(),
+		Dockerignore:     p.dockerignore.Raw(),
+		PolicyViolations: rules.CheckPolicies(p.rulesContext()),
 	}, nil
 }
 
+// optimizeWithAI has the LLM optimize the Dockerfile, then corrects the mistakes it's known to make
+func (p *Project) optimizeWithAI(ctx context.Context, aiService *ai.AIService, goal models.Goal, originalDockerfile *dockerfile.Dockerfile) error {
+	resp, err := aiService.OptimizeDockerfile(ctx, p.aiRequest(goal))
+	if err != nil {
+		return fmt.Errorf("AI service failed to optimize Dockerfile: %w", err)
+	}
+
+	p.dockerfile, err = dockerfile.NewDockerfile(resp.Dockerfile)
+	if err != nil {
+		return fmt.Errorf("Failed to process Dockerfile returned by AI service: %w", err)
+	}
+	// Rewrite only the instructions the LLM changed so that the user's comments and formatting
+	// survive and the diff is reviewable. The LLM's code is used as is if it can't be merged.
+	if merged, err := dockerfile.Merge(originalDockerfile, p.dockerfile); err == nil {
+		p.dockerfile = merged
+	}
+
+	// the risks classified by the LLM can't be trusted to be valid
+	for _, r := range resp.Recommendations {
+		r.Risk = r.Risk.Normalize()
+		p.addRecommendation(r)
+	}
+	for _, a := range resp.ActionsTaken {
+		a.Risk = a.Risk.Normalize()
+		p.addActionTaken(a)
+	}
+
+	p.correctRelativePaths(originalDockerfile)
+	p.checkPlatforms(originalDockerfile)
+	return nil
+}
+
+// aiRequest describes the project to the LLM
+func (p *Project) aiRequest(goal models.Goal) *ai.OptimizeRequest {
+	return &ai.OptimizeRequest{
+		Dockerfile:           p.dockerfile.Raw(),
+		Dockerignore:         p.dockerignore.Raw(),
+		PackageJSON:          p.packageJSON.String(),
+		ProjectDirectory:     p.directory,
+		DockerfileStageCount: p.dockerfile.GetStageCount(),
+		Workspace:            p.workspace,
+		WorkspacePackage:     p.workspacePackage,
+		BaseImages:           p.baseImages.Summary(baseImagesOf(p.dockerfile), p.platforms),
+		DockerfileNotes:      strings.TrimSpace(dockerfileNotes(p.dockerfile) + "\n" + p.nativeModuleNotes()),
+		Policies:             policy.Describe(p.policies),
+		Goal:                 goal,
+	}
+}
+
+// StartChat starts a conversation with the LLM about the project's Dockerfile, see ai.Conversation.
+// The LLM knows as much about the project as when it optimizes it.
+func (p *Project) StartChat(aiService *ai.AIService, goal models.Goal) (*ai.Conversation, error) {
+	if aiService == nil {
+		return nil, errors.New("an OpenAI API key is required to chat about the Dockerfile")
+	}
+	return aiService.StartChat(p.aiRequest(goal))
+}
+
+// applyStep runs a step of the optimization that changes the Dockerfile or .dockerignore. If any action
+// the step takes is riskier than maxRisk, all of its changes are undone, since they depend on each other,
+// and its actions are turned into recommendations. It returns false if the changes were undone.
+// Errors returned by the step are passed on as they are.
+func (p *Project) applyStep(maxRisk models.Risk, step func() error) (bool, error) {
+	dockerfileCode := p.dockerfile.Raw()
+	dockerignoreCode := ""
+	if p.dockerignore != nil {
+		dockerignoreCode = p.dockerignore.Raw()
+	}
+	taken := len(p.actionsTaken)
+	var sizeBefore int64
+	if p.explain {
+		sizeBefore = p.estimateImageSize()
+	}
+
+	if err := step(); err != nil {
+		return false, err
+	}
+	// the impact can only be told apart when the step made a single change. It's measured before the change
+	// is undone for being too risky, so that the recommendation tells what it would save.
+	if p.explain && len(p.actionsTaken) == taken+1 {
+		if impact := p.estimateImageSize() - sizeBefore; impact != 0 {
+			p.actionsTaken[taken].Explanation = &models.Explanation{EstimatedSizeImpact: impact}
+		}
+	}
+	risk := models.RiskCosmetic
+	for _, a := range p.actionsTaken[taken:] {
+		if !a.Risk.AtMost(risk) {
+			risk = a.Risk.Normalize()
+		}
+	}
+	if risk.AtMost(maxRisk) {
+		return true, nil
+	}
+
+	// the code was valid before the step, so it can be parsed again
+	p.dockerfile, _ = dockerfile.NewDockerfile(dockerfileCode)
+	p.dockerignore = dockerignore.NewDockerignore(dockerignoreCode)
+	undone := p.actionsTaken[taken:]
+	p.actionsTaken = p.actionsTaken[:taken]
+	for _, a := range undone {
+		if a.Risk.Normalize() == risk {
+			a.Description += fmt.Sprintf(" This change wasn't applied because it's %s, above the maximum risk of %s.", risk, maxRisk)
+		} else {
+			a.Description += fmt.Sprintf(" This change wasn't applied because it goes together with a %s change, above the maximum risk of %s.", risk, maxRisk)
+		}
+		p.recommendations = append(p.recommendations, a)
+	}
+	return false, nil
+}
+
 func (p *Project) addRecommendation(r *models.OptimizationAction) {
 	p.recommendations = append(p.recommendations, r)
+	p.events.Emit(events.RuleApplied{Rule: r.Rule, Title: r.Title, Filepath: r.Filepath, Line: r.Line})
 }
 
 func (p *Project) addActionTaken(a *models.OptimizationAction) {
 	p.actionsTaken = append(p.actionsTaken, a)
+	p.events.Emit(events.RuleApplied{Rule: a.Rule, Title: a.Title, Filepath: a.Filepath, Line: a.Line, Changed: true})
+}
+
+// defaultGoal returns the goal to optimize for, all goals if none was given
+func defaultGoal(goal models.Goal) models.Goal {
+	if goal == "" {
+		return models.GoalAll
+	}
+	return goal
 }
 
 // optimizeDockerignore ensures that .dockerignore exists and contains the recommended entries
@@ -138,6 +577,7 @@ func (p *Project) createAndOptimizeDockerignore() {
 		p.dockerignore = dockerignore.NewDockerignore("")
 		action := &models.OptimizationAction{
 			Rule:        "create-dockerignore",
+			Risk:        models.RiskSize,
 			Filepath:    dockerignoreFilepath,
 			Title:       "Created .dockerignore file",
 			Description: "Created a new .dockerignore file to exclude unnecessary files & folders from the Docker build context.",
@@ -151,6 +591,7 @@ func (p *Project) createAndOptimizeDockerignore() {
 	if len(added) > 0 {
 		action := &models.OptimizationAction{
 			Rule:        "update-dockerignore",
+			Risk:        models.RiskSize,
 			Filepath:    dockerignoreFilepath,
 			Title:       "Updated .dockerignore file",
 			Description: fmt.Sprintf("Added the following entries to .dockerignore to exclude them from the Docker build context:\n%s", strings.Join(added, "\n")),
@@ -158,3 +599,19 @@ func (p *Project) createAndOptimizeDockerignore() {
 		p.addActionTaken(action)
 	}
 }
+
+// generatedDockerignoreEntries returns the entries a freshly generated .dockerignore
+// should contain on top of the defaults, based on the facts known about the project.
+func generatedDockerignoreEntries(info *projectinfo.Info) []string {
+	entries := []string{"coverage", ".nyc_output", ".env", "*.log", "Dockerfile*", ".dockerignore"}
+	if info == nil {
+		return entries
+	}
+	switch info.Framework {
+	case "nextjs":
+		entries = append(entries, ".next")
+	case "nuxt":
+		entries = append(entries, ".nuxt", ".output")
+	}
+	return entries
+}
@@ -0,0 +1,254 @@
+package project
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/baseimages"
+	"github.com/duaraghav8/dockershrink/internal/buildargs"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+	"github.com/duaraghav8/dockershrink/pkg/events"
+)
+
+func TestOptimizeDockerImage_Events(t *testing.T) {
+	df, err := dockerfile.NewDockerfile("FROM node:20\nCOPY . .\n")
+	if err != nil {
+		t.Fatalf("failed to parse dockerfile: %v", err)
+	}
+	fs := restrictedfilesystem.NewRestrictedFilesystem(t.TempDir(), "", "Dockerfile", "")
+	p := NewProject(df, nil, nil, fs, nil, "")
+
+	var received []events.Event
+	p.SetEvents(func(e events.Event) { received = append(received, e) })
+
+	resp, err := p.OptimizeDockerImage(context.Background(), nil, &OptimizeOptions{Goal: models.GoalSize})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(received) < 2 {
+		t.Fatalf("expected the operation to start and finish, got %v", received)
+	}
+	started, ok := received[0].(events.AnalysisStarted)
+	if !ok || started.Operation != events.OperationOptimize || started.Goal != string(models.GoalSize) {
+		t.Errorf("expected the first event to be the start of the optimization, got %#v", received[0])
+	}
+	if finished, ok := received[len(received)-1].(events.AnalysisFinished); !ok || finished.Err != nil {
+		t.Errorf("expected the last event to be the successful end of the optimization, got %#v", received[len(received)-1])
+	}
+
+	applied := 0
+	for _, e := range received {
+		if r, ok := e.(events.RuleApplied); ok {
+			applied++
+			if !r.Changed && r.Rule == "create-dockerignore" {
+				t.Errorf("expected %s to be reported as a change", r.Rule)
+			}
+		}
+	}
+	if expected := len(resp.ActionsTaken) + len(resp.Recommendations); applied != expected {
+		t.Errorf("expected %d rules to be reported, got %d", expected, applied)
+	}
+}
+
+func TestOptimizeDockerImage_EstimatedSizes(t *testing.T) {
+	df, err := dockerfile.NewDockerfile("FROM node:20 AS build\nWORKDIR /app\nRUN npm ci\n\nFROM node:20\nWORKDIR /app\nCOPY --from=build /app /app\nCMD [\"node\", \"index.js\"]\n")
+	if err != nil {
+		t.Fatalf("failed to parse dockerfile: %v", err)
+	}
+	fs := restrictedfilesystem.NewRestrictedFilesystem(t.TempDir(), "", "Dockerfile", "")
+	p := NewProject(df, nil, nil, fs, nil, "")
+	p.SetBaseImages(baseimages.Builtin())
+
+	resp, err := p.OptimizeDockerImage(context.Background(), nil, &OptimizeOptions{Goal: models.GoalSize})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.EstimatedSizeBefore == 0 || resp.EstimatedSizeAfter == 0 || resp.EstimatedSizeAfter >= resp.EstimatedSizeBefore {
+		t.Errorf("expected the lighter base image to shrink the estimate, got %d -> %d", resp.EstimatedSizeBefore, resp.EstimatedSizeAfter)
+	}
+	if resp.ScoreAfter <= resp.ScoreBefore {
+		t.Errorf("expected the optimization to improve the score, got %d -> %d", resp.ScoreBefore, resp.ScoreAfter)
+	}
+}
+
+func TestOptimizeDockerImage_Explain(t *testing.T) {
+	code := "FROM node:20 AS build\nWORKDIR /app\nRUN npm ci\n\nFROM node:20\nWORKDIR /app\nCOPY --from=build /app /app\nCMD [\"node\", \"index.js\"]\n"
+	for _, measured := range []bool{false, true} {
+		df, err := dockerfile.NewDockerfile(code)
+		if err != nil {
+			t.Fatalf("failed to parse dockerfile: %v", err)
+		}
+		fs := restrictedfilesystem.NewRestrictedFilesystem(t.TempDir(), "", "Dockerfile", "")
+		p := NewProject(df, nil, nil, fs, nil, "")
+		p.SetBaseImages(baseimages.Builtin())
+
+		resp, err := p.OptimizeDockerImage(context.Background(), nil, &OptimizeOptions{Goal: models.GoalSize, Explain: measured})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var slim *models.OptimizationAction
+		for _, a := range resp.ActionsTaken {
+			if a.Explanation == nil || a.Explanation.Principle == "" || a.Explanation.Rationale == "" {
+				t.Errorf("expected action %s to be explained, got %+v", a.Rule, a.Explanation)
+			}
+			if a.Rule == "final-stage-slim-baseimage" {
+				slim = a
+			}
+		}
+		if slim == nil {
+			t.Fatalf("expected the final base image to be replaced")
+		}
+		if impact := slim.Explanation.EstimatedSizeImpact; measured != (impact < 0) {
+			t.Errorf("explain=%v: unexpected size impact %d of the lighter base image", measured, impact)
+		}
+	}
+}
+
+func TestOptimizeDockerImage_MaxRisk(t *testing.T) {
+	code := "FROM node:22-alpine\nARG NPM_TOKEN\nRUN npm ci\n"
+	tests := []struct {
+		name    string
+		maxRisk models.Risk
+		// applied are the rules of the actions taken, deferred those turned into recommendations
+		applied  []string
+		deferred []string
+	}{
+		{
+			name:    "every change",
+			applied: []string{"create-dockerignore", "update-dockerignore", "secret-mounts", "secret-mounts"},
+		},
+		{
+			name:     "up to size-impacting",
+			maxRisk:  models.RiskSize,
+			applied:  []string{"create-dockerignore", "update-dockerignore"},
+			deferred: []string{"secret-mounts", "secret-mounts"},
+		},
+		{
+			name:     "cosmetic only",
+			maxRisk:  models.RiskCosmetic,
+			deferred: []string{"create-dockerignore", "update-dockerignore", "secret-mounts", "secret-mounts"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(code)
+			if err != nil {
+				t.Fatalf("failed to parse dockerfile: %v", err)
+			}
+			fs := restrictedfilesystem.NewRestrictedFilesystem(t.TempDir(), "", "Dockerfile", "")
+			p := NewProject(df, nil, nil, fs, nil, "")
+			resp, err := p.OptimizeDockerImage(context.Background(), nil, &OptimizeOptions{MaxRisk: tt.maxRisk})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			applied := []string{}
+			for _, a := range resp.ActionsTaken {
+				applied = append(applied, a.Rule)
+			}
+			deferred := []string{}
+			for _, r := range resp.Recommendations {
+				if strings.Contains(r.Description, "wasn't applied") {
+					deferred = append(deferred, r.Rule)
+				}
+			}
+			if strings.Join(applied, " ") != strings.Join(tt.applied, " ") {
+				t.Errorf("expected actions %v, got %v", tt.applied, applied)
+			}
+			if strings.Join(deferred, " ") != strings.Join(tt.deferred, " ") {
+				t.Errorf("expected deferred actions %v, got %v", tt.deferred, deferred)
+			}
+
+			secretsApplied := strings.Contains(resp.Dockerfile, "type=secret")
+			if secretsApplied != (len(resp.BuildSecrets) > 0) || secretsApplied != (tt.maxRisk == "") {
+				t.Errorf("expected the secret mounts and build secrets to be applied together, got:\n%s", resp.Dockerfile)
+			}
+			if tt.maxRisk == models.RiskCosmetic && resp.Dockerignore != "" {
+				t.Errorf("expected no .dockerignore, got %q", resp.Dockerignore)
+			}
+		})
+	}
+}
+
+func TestAnalyzeDockerImage_BuildArgs(t *testing.T) {
+	df, err := dockerfile.NewDockerfile(`FROM node:22-alpine
+ARG TARGET_ENV=development
+ENV NODE_ENV=${TARGET_ENV}
+WORKDIR /app
+COPY package.json package-lock.json ./
+RUN npm ci
+`)
+	if err != nil {
+		t.Fatalf("failed to parse dockerfile: %v", err)
+	}
+	fs := restrictedfilesystem.NewRestrictedFilesystem(t.TempDir(), "", "Dockerfile", "")
+	p := NewProject(df, nil, nil, fs, nil, "")
+
+	resp := p.AnalyzeDockerImage(&AnalyzeOptions{
+		Goal: models.GoalSize,
+		BuildArgs: []*buildargs.Combination{
+			{Name: "compose.yaml:api", Args: map[string]string{"TARGET_ENV": "production"}},
+			{Name: "compose.yaml:api-dev", Args: map[string]string{"TARGET_ENV": "development"}},
+		},
+	})
+	hasDevDependencies := func(findings []*models.Finding) bool {
+		for _, f := range findings {
+			if f.Code == "DS007" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !hasDevDependencies(resp.Findings) {
+		t.Errorf("expected devDependencies to be reported with the default build arguments")
+	}
+	if len(resp.Variants) != 2 {
+		t.Fatalf("expected 2 variants, got %d", len(resp.Variants))
+	}
+	prod, dev := resp.Variants[0], resp.Variants[1]
+	if prod.Name != "compose.yaml:api" || hasDevDependencies(prod.Findings) {
+		t.Errorf("expected no devDependencies in the production variant, got %+v", prod)
+	}
+	if !hasDevDependencies(dev.Findings) {
+		t.Errorf("expected devDependencies in the development variant")
+	}
+	if prod.Score <= dev.Score {
+		t.Errorf("expected the production variant to score higher, got %d and %d", prod.Score, dev.Score)
+	}
+}
+
+// cancelingResolver cancels the run while the first base image is being pinned
+type cancelingResolver struct {
+	cancel context.CancelFunc
+}
+
+func (r *cancelingResolver) Digest(ctx context.Context, image string) (string, error) {
+	r.cancel()
+	return "", ctx.Err()
+}
+
+func TestOptimizeDockerImage_Cancelled(t *testing.T) {
+	df, err := dockerfile.NewDockerfile("FROM node:20\nCOPY . .\n")
+	if err != nil {
+		t.Fatalf("failed to parse dockerfile: %v", err)
+	}
+	fs := restrictedfilesystem.NewRestrictedFilesystem(t.TempDir(), "", "Dockerfile", "")
+	p := NewProject(df, nil, nil, fs, nil, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resp, err := p.OptimizeDockerImage(ctx, nil, &OptimizeOptions{Goal: models.GoalSize, PinResolver: &cancelingResolver{cancel}})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the optimization to be cancelled, got %v", err)
+	}
+	if resp != nil {
+		t.Errorf("expected no response from a cancelled optimization, got %#v", resp)
+	}
+}
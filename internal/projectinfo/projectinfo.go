@@ -0,0 +1,159 @@
+package projectinfo
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/packagejson"
+	"github.com/duaraghav8/dockershrink/internal/packagelock"
+)
+
+const (
+	LanguageNodeJS  = "nodejs"
+	LanguagePython  = "python"
+	LanguageGo      = "go"
+	LanguageUnknown = "unknown"
+)
+
+// Info contains facts about a project which are relevant for building its Docker image
+type Info struct {
+	Language string `json:"language"`
+	// Framework is the primary web or application framework used by the project, if any
+	Framework string `json:"framework,omitempty"`
+	// Entrypoint is the file that starts the application, relative to the project root
+	Entrypoint string `json:"entrypoint,omitempty"`
+	// Port is the port the application listens on, 0 if unknown
+	Port int `json:"port,omitempty"`
+	// NodeVersion is the nodejs version the project expects, if declared
+	NodeVersion string `json:"node_version,omitempty"`
+	// NativeModules are the production dependencies that load native code, read from the npm lockfile
+	NativeModules []string `json:"native_modules,omitempty"`
+}
+
+// frameworks maps nodejs modules to the framework they represent along with its default port.
+// Order matters: meta-frameworks are checked before the servers they're built on.
+var frameworks = []struct {
+	module      string
+	name        string
+	defaultPort int
+}{
+	{"next", "nextjs", 3000},
+	{"nuxt", "nuxt", 3000},
+	{"@remix-run/node", "remix", 3000},
+	{"@nestjs/core", "nestjs", 3000},
+	{"fastify", "fastify", 3000},
+	{"koa", "koa", 3000},
+	{"@hapi/hapi", "hapi", 3000},
+	{"express", "express", 3000},
+}
+
+var (
+	// matches "node server.js", "node ./dist/main.js", "nodemon src/index.ts", etc
+	startScriptRegex = regexp.MustCompile(`(?:node|nodemon|ts-node|tsx)\s+(?:-[^\s]+\s+)*([^\s&;|]+\.(?:js|mjs|cjs|ts))`)
+	// matches ".listen(8080", "PORT || 8080", "port: 8080"
+	portRegex = regexp.MustCompile(`(?:\.listen\(\s*|PORT\s*\|\|\s*|PORT\s*\?\?\s*|port:\s*)(\d{2,5})`)
+
+	defaultEntrypoints = []string{"index.js", "server.js", "app.js", "main.js", "src/index.js", "src/server.js", "src/main.js", "src/app.js"}
+)
+
+// Inspect examines the project in the given filesystem and returns facts about it.
+// packageJSON is optional.
+func Inspect(fsys fs.FS, packageJSON *packagejson.PackageJSON) *Info {
+	info := &Info{Language: detectLanguage(fsys, packageJSON)}
+	if info.Language != LanguageNodeJS || packageJSON == nil {
+		return info
+	}
+
+	for _, f := range frameworks {
+		if packageJSON.HasDependency(f.module) {
+			info.Framework = f.name
+			info.Port = f.defaultPort
+			break
+		}
+	}
+
+	info.Entrypoint = detectEntrypoint(fsys, packageJSON)
+	if info.Entrypoint != "" {
+		if content, err := fs.ReadFile(fsys, info.Entrypoint); err == nil {
+			if m := portRegex.FindSubmatch(content); m != nil {
+				if port, err := strconv.Atoi(string(m[1])); err == nil && port > 0 && port < 65536 {
+					info.Port = port
+				}
+			}
+		}
+	}
+
+	if lock, err := packagelock.Read(fsys); err == nil {
+		info.NativeModules = packagelock.Names(lock.NativeModules())
+	}
+
+	info.NodeVersion = packageJSON.GetNodeVersion()
+	if info.NodeVersion == "" {
+		for _, f := range []string{".nvmrc", ".node-version"} {
+			if content, err := fs.ReadFile(fsys, f); err == nil {
+				info.NodeVersion = strings.TrimPrefix(strings.TrimSpace(string(content)), "v")
+				break
+			}
+		}
+	}
+
+	return info
+}
+
+// Summary returns a human-readable description of the facts suitable for LLM prompts
+func (i *Info) Summary() string {
+	lines := []string{fmt.Sprintf("Language: %s", i.Language)}
+	if i.Framework != "" {
+		lines = append(lines, fmt.Sprintf("Framework: %s", i.Framework))
+	}
+	if i.Entrypoint != "" {
+		lines = append(lines, fmt.Sprintf("Entrypoint: %s", i.Entrypoint))
+	}
+	if i.Port != 0 {
+		lines = append(lines, fmt.Sprintf("Port: %d", i.Port))
+	}
+	if i.NodeVersion != "" {
+		lines = append(lines, fmt.Sprintf("NodeJS version: %s", i.NodeVersion))
+	}
+	if len(i.NativeModules) > 0 {
+		lines = append(lines, fmt.Sprintf("Native modules: %s", strings.Join(i.NativeModules, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func detectLanguage(fsys fs.FS, packageJSON *packagejson.PackageJSON) string {
+	switch {
+	case packageJSON != nil, fileExists(fsys, "package.json"):
+		return LanguageNodeJS
+	case fileExists(fsys, "requirements.txt"), fileExists(fsys, "pyproject.toml"), fileExists(fsys, "Pipfile"):
+		return LanguagePython
+	case fileExists(fsys, "go.mod"):
+		return LanguageGo
+	default:
+		return LanguageUnknown
+	}
+}
+
+func detectEntrypoint(fsys fs.FS, packageJSON *packagejson.PackageJSON) string {
+	if m := startScriptRegex.FindStringSubmatch(packageJSON.GetScript("start")); m != nil {
+		return path.Clean(m[1])
+	}
+	if main := packageJSON.GetMain(); main != "" && fileExists(fsys, path.Clean(main)) {
+		return path.Clean(main)
+	}
+	for _, f := range defaultEntrypoints {
+		if fileExists(fsys, f) {
+			return f
+		}
+	}
+	return ""
+}
+
+func fileExists(fsys fs.FS, name string) bool {
+	_, err := fs.Stat(fsys, name)
+	return err == nil
+}
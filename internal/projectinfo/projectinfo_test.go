@@ -0,0 +1,91 @@
+package projectinfo
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/duaraghav8/dockershrink/internal/packagejson"
+)
+
+func TestInspect_ExpressApp(t *testing.T) {
+	pkgContent := `{
+  "name": "api",
+  "scripts": {"start": "node --enable-source-maps ./src/server.js"},
+  "dependencies": {"express": "^4.19.0"},
+  "engines": {"node": ">=20"}
+}`
+	fsys := fstest.MapFS{
+		"package.json":  {Data: []byte(pkgContent)},
+		"src/server.js": {Data: []byte("const app = require('express')();\napp.listen(process.env.PORT || 8080);")},
+	}
+	pkg, err := packagejson.NewPackageJSON(pkgContent)
+	if err != nil {
+		t.Fatalf("failed to parse package.json: %v", err)
+	}
+
+	info := Inspect(fsys, pkg)
+	if info.Language != LanguageNodeJS {
+		t.Errorf("expected language %q, got %q", LanguageNodeJS, info.Language)
+	}
+	if info.Framework != "express" {
+		t.Errorf("expected framework express, got %q", info.Framework)
+	}
+	if info.Entrypoint != "src/server.js" {
+		t.Errorf("expected entrypoint src/server.js, got %q", info.Entrypoint)
+	}
+	if info.Port != 8080 {
+		t.Errorf("expected port 8080, got %d", info.Port)
+	}
+	if info.NodeVersion != ">=20" {
+		t.Errorf("expected node version >=20, got %q", info.NodeVersion)
+	}
+}
+
+func TestInspect_FallbackEntrypointAndNvmrc(t *testing.T) {
+	pkgContent := `{"name": "worker"}`
+	fsys := fstest.MapFS{
+		"package.json": {Data: []byte(pkgContent)},
+		"index.js":     {Data: []byte("console.log('hi')")},
+		".nvmrc":       {Data: []byte("v22.1.0\n")},
+	}
+	pkg, _ := packagejson.NewPackageJSON(pkgContent)
+
+	info := Inspect(fsys, pkg)
+	if info.Entrypoint != "index.js" {
+		t.Errorf("expected entrypoint index.js, got %q", info.Entrypoint)
+	}
+	if info.Port != 0 {
+		t.Errorf("expected unknown port, got %d", info.Port)
+	}
+	if info.NodeVersion != "22.1.0" {
+		t.Errorf("expected node version 22.1.0, got %q", info.NodeVersion)
+	}
+}
+
+func TestInspect_NonNodeProject(t *testing.T) {
+	fsys := fstest.MapFS{
+		"requirements.txt": {Data: []byte("flask\n")},
+	}
+	info := Inspect(fsys, nil)
+	if info.Language != LanguagePython {
+		t.Errorf("expected language %q, got %q", LanguagePython, info.Language)
+	}
+}
+
+func TestInspect_NativeModules(t *testing.T) {
+	pkgContent := `{"name": "api", "dependencies": {"bcrypt": "^5.1.1"}}`
+	fsys := fstest.MapFS{
+		"package.json":      {Data: []byte(pkgContent)},
+		"package-lock.json": {Data: []byte(`{"lockfileVersion": 3, "packages": {"node_modules/bcrypt": {"version": "5.1.1", "dependencies": {"node-addon-api": "^5.0.0"}}}}`)},
+	}
+	pkg, _ := packagejson.NewPackageJSON(pkgContent)
+
+	info := Inspect(fsys, pkg)
+	if len(info.NativeModules) != 1 || info.NativeModules[0] != "bcrypt" {
+		t.Errorf("expected bcrypt to be a native module, got %v", info.NativeModules)
+	}
+	if !strings.Contains(info.Summary(), "Native modules: bcrypt") {
+		t.Errorf("expected the summary to list the native modules:\n%s", info.Summary())
+	}
+}
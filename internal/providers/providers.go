@@ -0,0 +1,187 @@
+// Package providers checks that the AI providers dockershrink is configured with can be used:
+// that their credentials are valid, how long they take to respond and what they support.
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/ai/structured"
+	"github.com/duaraghav8/dockershrink/internal/docs"
+	"github.com/openai/openai-go"
+)
+
+const (
+	// RoleLLM is the provider of the chat model that optimizes and generates Dockerfiles
+	RoleLLM = "llm"
+	// RoleEmbeddings is the provider that computes embeddings to search the documentation
+	RoleEmbeddings = "embeddings"
+)
+
+const (
+	// CapabilityStructuredOutputs is support for responses constrained to a JSON schema, which the LLM must support
+	CapabilityStructuredOutputs = "structured outputs"
+	// CapabilityToolCalling is support for tools, which the LLM uses to read the project's files
+	CapabilityToolCalling = "tool calling"
+	// CapabilityEmbeddings is support for computing embeddings of texts
+	CapabilityEmbeddings = "embeddings"
+	// CapabilityKeywordSearch is searching documentation by keywords, which needs no provider
+	CapabilityKeywordSearch = "keyword search"
+)
+
+// ErrNotConfigured is returned for a provider that isn't set up, eg- because no API key was given
+var ErrNotConfigured = errors.New("not configured")
+
+// Result is the outcome of testing a single provider
+type Result struct {
+	Role     string
+	Provider string
+	// Model is the model dockershrink uses with the provider
+	Model string
+	// Latency is the round-trip time of the smallest request the provider accepts, 0 if it failed
+	Latency time.Duration
+	// Models are the models available with the credentials, nil if the provider can't list them
+	Models []string
+	// Capabilities are the features of the provider dockershrink relies on that work
+	Capabilities []string
+	// Err is why the provider can't be used, nil if it works
+	Err error
+}
+
+// OK returns true if the provider can be used
+func (r *Result) OK() bool {
+	return r.Err == nil
+}
+
+// pong is the smallest structured output, requested to check that the model supports Structured Outputs
+type pong struct {
+	OK bool `json:"ok" jsonschema_description:"Always true"`
+}
+
+var pongOutput = structured.New[pong]("pong", "Response to a connectivity check.")
+
+// pingTool is offered along with the structured output, like the tools dockershrink offers when optimizing
+var pingTool = openai.ChatCompletionToolParam{
+	Type: openai.F(openai.ChatCompletionToolTypeFunction),
+	Function: openai.F(openai.FunctionDefinitionParam{
+		Name:        openai.String("ping"),
+		Description: openai.String("Checks connectivity, never needs to be called"),
+		Parameters: openai.F(openai.FunctionParameters{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		}),
+	}),
+}
+
+// TestLLM checks the credentials of the OpenAI client by listing the available models, then measures the
+// latency of a one-token completion and checks that the model supports the structured outputs and tools
+// dockershrink needs. A nil client means that no API key was given.
+func TestLLM(ctx context.Context, client *openai.Client, model string) *Result {
+	r := &Result{Role: RoleLLM, Provider: "openai", Model: model}
+	if client == nil {
+		r.Err = fmt.Errorf("%w: set the OPENAI_API_KEY environment variable or pass --openai-api-key", ErrNotConfigured)
+		return r
+	}
+
+	pager := client.Models.ListAutoPaging(ctx)
+	for pager.Next() {
+		r.Models = append(r.Models, pager.Current().ID)
+	}
+	if err := pager.Err(); err != nil {
+		r.Models = nil
+		r.Err = fmt.Errorf("failed to list models, check the API key and that the API is reachable: %w", err)
+		return r
+	}
+	sort.Strings(r.Models)
+	if !contains(r.Models, model) {
+		r.Err = fmt.Errorf("model %s isn't available with this API key", model)
+		return r
+	}
+
+	start := time.Now()
+	_, err := client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+		Messages:  openai.F([]openai.ChatCompletionMessageParamUnion{openai.UserMessage("ping")}),
+		Model:     openai.F(model),
+		MaxTokens: openai.Int(1),
+	})
+	if err != nil {
+		r.Err = fmt.Errorf("failed to get a chat completion: %w", err)
+		return r
+	}
+	r.Latency = time.Since(start)
+
+	response, err := client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+		Messages:       openai.F([]openai.ChatCompletionMessageParamUnion{openai.UserMessage("Respond with ok set to true.")}),
+		Model:          openai.F(model),
+		Tools:          openai.F([]openai.ChatCompletionToolParam{pingTool}),
+		ResponseFormat: openai.F(pongOutput.OpenAIResponseFormat()),
+	})
+	if err != nil {
+		r.Err = fmt.Errorf("model %s doesn't support %s with %s: %w", model, CapabilityStructuredOutputs, CapabilityToolCalling, err)
+		return r
+	}
+	r.Capabilities = append(r.Capabilities, CapabilityToolCalling)
+	if len(response.Choices) > 0 && len(response.Choices[0].Message.ToolCalls) == 0 {
+		if _, err := pongOutput.Parse(response.Choices[0].Message.Content); err != nil {
+			r.Err = fmt.Errorf("model %s returned a response that doesn't conform to the schema: %w", model, err)
+			return r
+		}
+	}
+	r.Capabilities = append(r.Capabilities, CapabilityStructuredOutputs)
+	return r
+}
+
+// TestEmbeddings measures the latency of computing the embedding of a single word with the embedder of
+// the given provider. A nil embedder means that the documentation is searched by keywords.
+func TestEmbeddings(ctx context.Context, provider string, embedder docs.Embedder) *Result {
+	r := &Result{Role: RoleEmbeddings, Provider: provider}
+	if embedder == nil {
+		r.Provider = docs.ProviderKeyword
+		r.Capabilities = []string{CapabilityKeywordSearch}
+		return r
+	}
+	r.Model = strings.TrimPrefix(embedder.Name(), provider+"/")
+
+	start := time.Now()
+	vectors, err := embedder.Embed(ctx, []string{"ping"})
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	r.Latency = time.Since(start)
+	if len(vectors) != 1 || len(vectors[0]) == 0 {
+		r.Err = fmt.Errorf("%s returned no embedding", embedder.Name())
+		return r
+	}
+	r.Capabilities = []string{fmt.Sprintf("%s (%d dimensions)", CapabilityEmbeddings, len(vectors[0]))}
+	return r
+}
+
+// Summary describes the result in a single line, eg- "openai (gpt-4o): 412ms, tool calling, structured outputs"
+func (r *Result) Summary() string {
+	name := r.Provider
+	if r.Model != "" {
+		name += " (" + r.Model + ")"
+	}
+	if !r.OK() {
+		return fmt.Sprintf("%s: %v", name, r.Err)
+	}
+	details := append([]string{}, r.Capabilities...)
+	if r.Latency > 0 {
+		details = append([]string{r.Latency.Round(time.Millisecond).String()}, details...)
+	}
+	return fmt.Sprintf("%s: %s", name, strings.Join(details, ", "))
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/docs"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestTestLLM(t *testing.T) {
+	tests := []struct {
+		name         string
+		apiKey       string
+		content      string
+		err          bool
+		capabilities int
+	}{
+		{name: "valid credentials", apiKey: "valid", content: `{"ok": true}`, capabilities: 2},
+		{name: "invalid credentials", apiKey: "invalid", err: true},
+		{name: "output not conforming to the schema", apiKey: "valid", content: `{"done": true}`, err: true, capabilities: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer valid" {
+					w.WriteHeader(http.StatusUnauthorized)
+					w.Write([]byte(`{"error": {"message": "Incorrect API key provided"}}`))
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				switch r.URL.Path {
+				case "/models":
+					w.Write([]byte(`{"object": "list", "data": [{"id": "gpt-4o", "object": "model", "created": 1, "owned_by": "openai"}, {"id": "gpt-4o-mini", "object": "model", "created": 1, "owned_by": "openai"}]}`))
+				case "/chat/completions":
+					w.Write([]byte(`{"id": "1", "object": "chat.completion", "created": 1, "model": "gpt-4o", "choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": ` + strconv.Quote(tt.content) + `}}]}`))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			client := openai.NewClient(option.WithAPIKey(tt.apiKey), option.WithBaseURL(server.URL), option.WithMaxRetries(0))
+			r := TestLLM(context.Background(), client, "gpt-4o")
+			if r.OK() == tt.err {
+				t.Fatalf("expected error: %v, got %v", tt.err, r.Err)
+			}
+			if len(r.Capabilities) != tt.capabilities {
+				t.Errorf("expected %d capabilities, got %v", tt.capabilities, r.Capabilities)
+			}
+			if !tt.err && (len(r.Models) != 2 || r.Latency <= 0) {
+				t.Errorf("expected the models and latency to be reported, got %v and %s", r.Models, r.Latency)
+			}
+		})
+	}
+
+	t.Run("model not available", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"object": "list", "data": [{"id": "gpt-4o-mini", "object": "model", "created": 1, "owned_by": "openai"}]}`))
+		}))
+		defer server.Close()
+
+		client := openai.NewClient(option.WithAPIKey("valid"), option.WithBaseURL(server.URL), option.WithMaxRetries(0))
+		if r := TestLLM(context.Background(), client, "gpt-4o"); r.OK() {
+			t.Error("expected an error for a model that isn't available")
+		}
+	})
+
+	t.Run("no client", func(t *testing.T) {
+		if r := TestLLM(context.Background(), nil, "gpt-4o"); !errors.Is(r.Err, ErrNotConfigured) {
+			t.Errorf("expected ErrNotConfigured, got %v", r.Err)
+		}
+	})
+}
+
+type fakeEmbedder struct {
+	vectors [][]float64
+	err     error
+}
+
+func (e *fakeEmbedder) Name() string {
+	return "local/fake"
+}
+
+func (e *fakeEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	return e.vectors, e.err
+}
+
+func TestTestEmbeddings(t *testing.T) {
+	tests := []struct {
+		name       string
+		embedder   docs.Embedder
+		err        bool
+		capability string
+	}{
+		{name: "keyword search", capability: CapabilityKeywordSearch},
+		{name: "working embedder", embedder: &fakeEmbedder{vectors: [][]float64{{0.1, 0.2, 0.3}}}, capability: "embeddings (3 dimensions)"},
+		{name: "failing embedder", embedder: &fakeEmbedder{err: errors.New("connection refused")}, err: true},
+		{name: "no embedding returned", embedder: &fakeEmbedder{vectors: [][]float64{}}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := TestEmbeddings(context.Background(), docs.ProviderLocal, tt.embedder)
+			if r.OK() == tt.err {
+				t.Fatalf("expected error: %v, got %v", tt.err, r.Err)
+			}
+			if tt.capability != "" && (len(r.Capabilities) != 1 || r.Capabilities[0] != tt.capability) {
+				t.Errorf("expected capability %q, got %v", tt.capability, r.Capabilities)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// Package ratelimit paces the requests made to a provider, so that runs handling many Dockerfiles
+// at once stay within the provider's rate limits instead of failing with "too many requests".
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter lets a fixed number of requests through per minute, evenly spaced.
+// A nil Limiter doesn't limit anything. It is safe for concurrent use.
+type Limiter struct {
+	interval time.Duration
+
+	mu sync.Mutex
+	// next is when the next request may be made
+	next time.Time
+}
+
+// New returns a limiter letting perMinute requests through per minute, nil if perMinute isn't positive
+func New(perMinute int) *Limiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &Limiter{interval: time.Minute / time.Duration(perMinute)}
+}
+
+// Wait blocks until a request may be made, or until ctx is done in which case its error is returned.
+// Requests are let through in the order Wait was called.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := at.Sub(now)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
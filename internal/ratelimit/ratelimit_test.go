@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		perMinute int
+		expected  time.Duration
+	}{
+		{perMinute: 0},
+		{perMinute: -1},
+		{perMinute: 60, expected: time.Second},
+		{perMinute: 600, expected: 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		l := New(tt.perMinute)
+		if tt.expected == 0 {
+			if l != nil {
+				t.Errorf("New(%d) = %v; want no limit", tt.perMinute, l)
+			}
+			continue
+		}
+		if l == nil || l.interval != tt.expected {
+			t.Errorf("New(%d) = %v; want an interval of %s", tt.perMinute, l, tt.expected)
+		}
+	}
+}
+
+func TestWait(t *testing.T) {
+	// 10ms between requests
+	l := New(6000)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.Wait(context.Background()); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	// the first request goes right away, the other 4 are spaced out
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("expected 5 requests to take at least 40ms, took %s", elapsed)
+	}
+}
+
+func TestWait_Cancelled(t *testing.T) {
+	l := New(1)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("expected the first request to go right away, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected waiting to stop with the context, got %v", err)
+	}
+}
+
+func TestWait_Nil(t *testing.T) {
+	var l *Limiter
+	if err := l.Wait(context.Background()); err != nil {
+		t.Errorf("expected a nil limiter to let requests through, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a nil limiter to return the error of the context, got %v", err)
+	}
+}
@@ -0,0 +1,284 @@
+// Package registry resolves image tags to digests using the registry HTTP API (distribution spec),
+// without requiring a Docker daemon.
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+)
+
+const (
+	DockerHub = "docker.io"
+	// dockerHubHost is the host that serves the registry API of Docker Hub
+	dockerHubHost = "registry-1.docker.io"
+	// dockerHubAuthKey is the key of Docker Hub's credentials in the docker config file
+	dockerHubAuthKey = "https://index.docker.io/v1/"
+)
+
+// manifestMediaTypes are accepted when resolving a tag. Indexes are preferred so that the digest
+// of a multi-platform image covers all of its platforms.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// ErrNotFound is returned if the image or its tag doesn't exist in the registry
+var ErrNotFound = errors.New("image not found")
+
+// Reference is a parsed image reference
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses an image reference like "node:20", "ghcr.io/org/app:1.0" or "app@sha256:...".
+// Images without a registry are on Docker Hub, where official images live under "library/".
+func ParseReference(ref string) (*Reference, error) {
+	img := dockerfile.NewImage(ref)
+	r := &Reference{Registry: DockerHub, Repository: img.Name(), Tag: img.Tag(), Digest: img.Digest()}
+	if r.Repository == "" || strings.Contains(ref, "$") {
+		return nil, fmt.Errorf("invalid image reference %q", ref)
+	}
+
+	// the first component is a registry if it looks like a host name
+	if first, rest, ok := strings.Cut(r.Repository, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		r.Registry, r.Repository = first, rest
+	}
+	if r.Registry == DockerHub && !strings.Contains(r.Repository, "/") {
+		r.Repository = "library/" + r.Repository
+	}
+	return r, nil
+}
+
+func (r *Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// Client talks to registries anonymously, or with the credentials stored in the docker config file
+type Client struct {
+	http *http.Client
+}
+
+func NewClient() *Client {
+	return &Client{http: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Digest returns the digest of the manifest that the given image reference currently points to.
+// For multi-platform images, this is the digest of the image index.
+func (c *Client) Digest(ctx context.Context, image string) (string, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	version := ref.Tag
+	if ref.Digest != "" {
+		version = ref.Digest
+	}
+
+	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", baseURL(ref.Registry), ref.Repository, version)
+	resp, err := c.do(ctx, http.MethodHead, manifestURL, ref)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	// not every registry returns the digest, compute it from the manifest instead
+	resp, err = c.do(ctx, http.MethodGet, manifestURL, ref)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return "", fmt.Errorf("failed to read manifest of %s: %w", image, err)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// do sends a request for a manifest, authenticating if the registry asks for it
+func (c *Client) do(ctx context.Context, method, manifestURL string, ref *Reference) (*http.Response, error) {
+	send := func(authorization string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, method, manifestURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		return c.http.Do(req)
+	}
+
+	resp, err := send("")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		authorization, err := c.authorize(ctx, challenge, ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to authenticate with %s: %w", ref.Registry, err)
+		}
+		if resp, err = send(authorization); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %w", ref, ErrNotFound)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		resp.Body.Close()
+		return nil, fmt.Errorf("registry %s responded with status %s", ref.Registry, resp.Status)
+	}
+	return resp, nil
+}
+
+// matches the parameters of a WWW-Authenticate challenge, eg- realm="https://auth.docker.io/token"
+var challengeParamRegex = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// authorize answers a registry's authentication challenge and returns the value of the Authorization header
+func (c *Client) authorize(ctx context.Context, challenge string, ref *Reference) (string, error) {
+	username, password := credentials(ref.Registry)
+	scheme, params, _ := strings.Cut(challenge, " ")
+
+	if strings.EqualFold(scheme, "Basic") {
+		if username == "" {
+			return "", errors.New("registry requires credentials, log in with \"docker login\"")
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)), nil
+	}
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported authentication scheme %q", scheme)
+	}
+
+	values := map[string]string{}
+	for _, m := range challengeParamRegex.FindAllStringSubmatch(params, -1) {
+		values[m[1]] = m[2]
+	}
+	if values["realm"] == "" {
+		return "", errors.New("authentication challenge has no realm")
+	}
+	query := url.Values{}
+	if values["service"] != "" {
+		query.Set("service", values["service"])
+	}
+	scope := values["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", ref.Repository)
+	}
+	query.Set("scope", scope)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, values["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed with status %s", resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to parse token: %w", err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// credentials returns the credentials stored for a registry by "docker login".
+// Credential helpers aren't supported, in which case the registry is accessed anonymously.
+func credentials(registry string) (string, string) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", ""
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		return "", ""
+	}
+	var conf struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(content, &conf); err != nil {
+		return "", ""
+	}
+
+	key := registry
+	if registry == DockerHub {
+		key = dockerHubAuthKey
+	}
+	auth, ok := conf.Auths[key]
+	if !ok {
+		return "", ""
+	}
+	decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+	if err != nil {
+		return "", ""
+	}
+	username, password, _ := strings.Cut(string(decoded), ":")
+	return username, password
+}
+
+// baseURL returns the URL of a registry's API. Local registries are accessed over plain HTTP, like docker does.
+func baseURL(registry string) string {
+	if registry == DockerHub {
+		return "https://" + dockerHubHost
+	}
+	host := registry
+	if h, _, ok := strings.Cut(registry, ":"); ok {
+		host = h
+	}
+	if host == "localhost" || host == "127.0.0.1" {
+		return "http://" + registry
+	}
+	return "https://" + registry
+}
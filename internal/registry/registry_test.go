@@ -0,0 +1,114 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		ref      string
+		expected Reference
+	}{
+		{"node", Reference{Registry: DockerHub, Repository: "library/node", Tag: "latest"}},
+		{"node:20-alpine", Reference{Registry: DockerHub, Repository: "library/node", Tag: "20-alpine"}},
+		{"bitnami/node:20", Reference{Registry: DockerHub, Repository: "bitnami/node", Tag: "20"}},
+		{"ghcr.io/org/app:1.0", Reference{Registry: "ghcr.io", Repository: "org/app", Tag: "1.0"}},
+		{"localhost:5000/app", Reference{Registry: "localhost:5000", Repository: "app", Tag: "latest"}},
+		{"node:20@sha256:abc", Reference{Registry: DockerHub, Repository: "library/node", Tag: "20", Digest: "sha256:abc"}},
+		{"node@sha256:abc", Reference{Registry: DockerHub, Repository: "library/node", Digest: "sha256:abc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			r, err := ParseReference(tt.ref)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *r != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, *r)
+			}
+		})
+	}
+
+	if _, err := ParseReference("${BASE}"); err == nil {
+		t.Errorf("expected an error for a reference with a build argument")
+	}
+}
+
+func TestClient_Digest(t *testing.T) {
+	manifest := `{"schemaVersion":2}`
+	sum := sha256.Sum256([]byte(manifest))
+	computed := "sha256:" + hex.EncodeToString(sum[:])
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:org/app:pull" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"token":"secret"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !strings.Contains(r.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json") {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/org/app/manifests/1.0":
+			w.Header().Set("Docker-Content-Digest", "sha256:fromheader")
+		case "/v2/org/app/manifests/2.0":
+			// no digest header, the digest has to be computed from the manifest
+			if r.Method == http.MethodGet {
+				fmt.Fprint(w, manifest)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	host := strings.TrimPrefix(server.URL, "http://")
+	c := NewClient()
+
+	tests := []struct {
+		image    string
+		expected string
+		err      error
+	}{
+		{image: host + "/org/app:1.0", expected: "sha256:fromheader"},
+		{image: host + "/org/app:2.0", expected: computed},
+		{image: host + "/org/app:3.0", err: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			digest, err := c.Digest(context.Background(), tt.image)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected error %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if digest != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, digest)
+			}
+		})
+	}
+}
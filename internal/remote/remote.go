@@ -0,0 +1,207 @@
+// Package remote fetches projects that aren't on disk, eg- a git repository or a tarball of the build context,
+// into a temporary workspace, so that they can be optimized like a local checkout.
+package remote
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Workspace is the temporary directory a remote project was fetched into
+type Workspace struct {
+	// Dir is the root of the project, which can be a subdirectory of the temporary directory, eg- the
+	// top-level directory of a tarball
+	Dir string
+	// Source is where the project was fetched from, eg- "https://github.com/acme/api#v1.2.0"
+	Source string
+	tmp    string
+}
+
+// Remove deletes the workspace
+func (w *Workspace) Remove() error {
+	return os.RemoveAll(w.tmp)
+}
+
+// IsGitURL returns true if s looks like the URL of a git repository rather than a local path,
+// eg- https://github.com/acme/api, git@github.com:acme/api.git or file:///srv/git/api.git
+func IsGitURL(s string) bool {
+	s, _ = SplitRef(s)
+	for _, prefix := range []string{"https://", "http://", "ssh://", "git://", "file://", "git@"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitRef splits a git URL into the URL of the repository and the branch, tag or commit after "#",
+// eg- "v1.2.0" for https://github.com/acme/api#v1.2.0. The ref is empty if there's none.
+func SplitRef(s string) (url string, ref string) {
+	url, ref, _ = strings.Cut(s, "#")
+	return url, ref
+}
+
+// FetchGit makes a shallow clone of the repository at a git URL, checked out at the ref after "#" if there's one,
+// or the default branch otherwise
+func FetchGit(ctx context.Context, source string) (*Workspace, error) {
+	url, ref := SplitRef(source)
+	tmp, err := os.MkdirTemp("", "dockershrink-remote-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a workspace: %w", err)
+	}
+	w := &Workspace{Dir: filepath.Join(tmp, "project"), Source: source, tmp: tmp}
+
+	steps := [][]string{{"clone", "--depth", "1", "--quiet", "--", url, w.Dir}}
+	if ref != "" {
+		// fetching the ref works for commits as well as branches and tags, unlike clone --branch
+		steps = [][]string{
+			{"init", "--quiet", w.Dir},
+			{"-C", w.Dir, "fetch", "--depth", "1", "--quiet", "--", url, ref},
+			{"-C", w.Dir, "checkout", "--quiet", "FETCH_HEAD"},
+		}
+	}
+	for _, args := range steps {
+		if err := git(ctx, args...); err != nil {
+			w.Remove()
+			return nil, fmt.Errorf("failed to fetch %s: %w", source, err)
+		}
+	}
+	return w, nil
+}
+
+// git runs a git command, returning the first line of what it printed on failure
+func git(ctx context.Context, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Stderr = &stderr
+	// a repository asking for credentials fails instead of waiting for them
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// git explains the error on the first line and adds hints after it
+		msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n")
+		if msg == "" {
+			return err
+		}
+		return fmt.Errorf("%s", msg)
+	}
+	return nil
+}
+
+// ExtractArchive extracts a tarball of a project, compressed with gzip or not, eg- one made with
+// "tar czf ctx.tar.gz .". If all of its files are in a single directory, eg- the archives of GitHub
+// releases, that directory is the root of the project.
+// Entries leading outside of the workspace, including symbolic links, are refused.
+func ExtractArchive(path string) (*Workspace, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tmp, err := os.MkdirTemp("", "dockershrink-remote-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a workspace: %w", err)
+	}
+	w := &Workspace{Dir: filepath.Join(tmp, "project"), Source: path, tmp: tmp}
+	if err := extract(f, w.Dir); err != nil {
+		w.Remove()
+		return nil, fmt.Errorf("failed to extract %s: %w", path, err)
+	}
+	if entries, err := os.ReadDir(w.Dir); err == nil && len(entries) == 1 && entries[0].IsDir() {
+		w.Dir = filepath.Join(w.Dir, entries[0].Name())
+	}
+	return w, nil
+}
+
+func extract(r io.Reader, dir string) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.FromSlash(strings.TrimPrefix(hdr.Name, "./"))
+		if name == "" || name == "." {
+			continue
+		}
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("%s leads outside of the archive", hdr.Name)
+		}
+		// an earlier entry can be a symbolic link the entry would be written through, which archives of
+		// directories never have, since tar doesn't follow links
+		if linked(dir, name) {
+			return fmt.Errorf("%s is inside a symbolic link of the archive", hdr.Name)
+		}
+		target := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode)&0o755|0o600)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if filepath.IsAbs(hdr.Linkname) || !filepath.IsLocal(filepath.Join(filepath.Dir(name), filepath.FromSlash(hdr.Linkname))) {
+				return fmt.Errorf("%s links to %s, outside of the archive", hdr.Name, hdr.Linkname)
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		}
+		// hard links, devices and the like aren't part of build contexts
+	}
+}
+
+// linked returns true if the path relative to dir, or one of its parent directories, is a symbolic link
+func linked(dir, name string) bool {
+	p := dir
+	for _, part := range strings.Split(name, string(filepath.Separator)) {
+		p = filepath.Join(p, part)
+		if info, err := os.Lstat(p); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return true
+		}
+	}
+	return false
+}
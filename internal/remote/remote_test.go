@@ -0,0 +1,205 @@
+package remote
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsGitURL(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected bool
+		url      string
+		ref      string
+	}{
+		{source: "https://github.com/acme/api", expected: true, url: "https://github.com/acme/api"},
+		{source: "https://github.com/acme/api#v1.2.0", expected: true, url: "https://github.com/acme/api", ref: "v1.2.0"},
+		{source: "git@github.com:acme/api.git#main", expected: true, url: "git@github.com:acme/api.git", ref: "main"},
+		{source: "file:///srv/git/api.git", expected: true, url: "file:///srv/git/api.git"},
+		{source: "services/api", expected: false, url: "services/api"},
+		{source: "./ctx.tar.gz", expected: false, url: "./ctx.tar.gz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			if got := IsGitURL(tt.source); got != tt.expected {
+				t.Errorf("IsGitURL() = %v; want %v", got, tt.expected)
+			}
+			if url, ref := SplitRef(tt.source); url != tt.url || ref != tt.ref {
+				t.Errorf("SplitRef() = %q, %q; want %q, %q", url, ref, tt.url, tt.ref)
+			}
+		})
+	}
+}
+
+type entry struct {
+	name     string
+	content  string
+	typeflag byte
+	linkname string
+}
+
+func writeArchive(t *testing.T, entries []entry, compress bool) string {
+	t.Helper()
+	var buf bytes.Buffer
+	var gz *gzip.Writer
+	tw := tar.NewWriter(&buf)
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(gz)
+	}
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.linkname, Mode: 0o644, Size: int64(len(e.content))}
+		if e.typeflag == tar.TypeDir {
+			hdr.Mode = 0o755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "ctx.tar.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractArchive(t *testing.T) {
+	tests := []struct {
+		name     string
+		entries  []entry
+		compress bool
+		// expected maps the files of the project to their contents
+		expected map[string]string
+		wantErr  string
+	}{
+		{
+			name: "gzip",
+			entries: []entry{
+				{name: "./", typeflag: tar.TypeDir},
+				{name: "./Dockerfile", content: "FROM node:22\n", typeflag: tar.TypeReg},
+				{name: "./src/index.js", content: "app", typeflag: tar.TypeReg},
+				{name: "./main.js", typeflag: tar.TypeSymlink, linkname: "src/index.js"},
+			},
+			compress: true,
+			expected: map[string]string{"Dockerfile": "FROM node:22\n", "src/index.js": "app", "main.js": "app"},
+		},
+		{
+			name: "single top-level directory",
+			entries: []entry{
+				{name: "api-1.2.0/", typeflag: tar.TypeDir},
+				{name: "api-1.2.0/Dockerfile", content: "FROM node:22\n", typeflag: tar.TypeReg},
+			},
+			expected: map[string]string{"Dockerfile": "FROM node:22\n"},
+		},
+		{
+			name:    "path outside of the archive",
+			entries: []entry{{name: "../evil.sh", content: "x", typeflag: tar.TypeReg}},
+			wantErr: "leads outside of the archive",
+		},
+		{
+			name:    "link outside of the archive",
+			entries: []entry{{name: "src/passwd", typeflag: tar.TypeSymlink, linkname: "../../etc/passwd"}},
+			wantErr: "outside of the archive",
+		},
+		{
+			name: "file written through a link",
+			entries: []entry{
+				{name: "src", typeflag: tar.TypeSymlink, linkname: "lib"},
+				{name: "src/index.js", content: "x", typeflag: tar.TypeReg},
+			},
+			wantErr: "inside a symbolic link",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := ExtractArchive(writeArchive(t, tt.entries, tt.compress))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExtractArchive() failed: %v", err)
+			}
+			defer w.Remove()
+			for name, expected := range tt.expected {
+				content, err := os.ReadFile(filepath.Join(w.Dir, name))
+				if err != nil || string(content) != expected {
+					t.Errorf("%s = %q, %v; want %q", name, content, err, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	repo := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(repo, "Dockerfile"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("init", "--quiet")
+	write("FROM node:20\n")
+	run("add", ".")
+	run("commit", "--quiet", "-m", "first")
+	run("tag", "v1")
+	write("FROM node:22\n")
+	run("commit", "--quiet", "-am", "second")
+	url := "file://" + filepath.ToSlash(repo)
+
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{source: url, expected: "FROM node:22\n"},
+		{source: url + "#v1", expected: "FROM node:20\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			w, err := FetchGit(context.Background(), tt.source)
+			if err != nil {
+				t.Fatalf("FetchGit() failed: %v", err)
+			}
+			defer w.Remove()
+			content, err := os.ReadFile(filepath.Join(w.Dir, "Dockerfile"))
+			if err != nil || string(content) != tt.expected {
+				t.Errorf("Dockerfile = %q, %v; want %q", content, err, tt.expected)
+			}
+		})
+	}
+
+	if _, err := FetchGit(context.Background(), url+"#missing"); err == nil || !strings.Contains(err.Error(), "failed to fetch") {
+		t.Errorf("expected an error fetching a missing ref, got %v", err)
+	}
+}
@@ -1,11 +1,15 @@
 package restrictedfilesystem
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/buildcontext"
 )
 
 // RestrictedFilesystem is a filesystem that limits access to files and folders inside a specific root directory.
@@ -16,6 +20,13 @@ type RestrictedFilesystem struct {
 	dockerfilePath   string
 	dockerignorePath string
 	dirTree          string
+
+	// buildContexts are the named build contexts the Dockerfile is built with, in the order they were added
+	buildContexts []*buildcontext.Context
+	// contextTrees are the directory trees of the local named contexts, keyed by name
+	contextTrees map[string]string
+	// ignored returns true for the slash-separated paths relative to the root directory that can't be read
+	ignored func(path string) bool
 }
 
 func NewRestrictedFilesystem(
@@ -32,15 +43,38 @@ func NewRestrictedFilesystem(
 	}
 }
 
-func (rfs *RestrictedFilesystem) ReadFiles(filepaths []string) (map[string]string, error) {
+// ReadFiles returns the contents of files, keyed by the paths they were requested with.
+// Paths are relative to the root directory. Files of a local named build context are read
+// by prefixing their path with the name of the context, eg- "lib:src/index.js".
+// Reading stops once ctx is done.
+func (rfs *RestrictedFilesystem) ReadFiles(ctx context.Context, filepaths []string) (map[string]string, error) {
 	result := make(map[string]string)
 	for _, path := range filepaths {
-		absPath, err := filepath.Abs(filepath.Join(rfs.rootDir, path))
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		root, rel := rfs.rootDir, path
+		if name, p, found := strings.Cut(path, ":"); found {
+			if c := rfs.BuildContext(name); c != nil {
+				if !c.IsLocal() {
+					return nil, fmt.Errorf("access denied: build context %s is not a local directory: %s", name, path)
+				}
+				root, rel = c.Dir, p
+			}
+		}
+		absPath, err := resolve(root, rel)
 		if err != nil {
 			return nil, err
 		}
-		if !strings.HasPrefix(absPath, rfs.rootDir) {
-			return nil, fmt.Errorf("access denied: attempting to access files outside the root directory: %s", path)
+		realPath, inside, err := resolveLinks(root, absPath)
+		if err != nil {
+			return nil, err
+		}
+		if !inside {
+			return nil, fmt.Errorf("access denied: %s is a link to a file outside the root directory", path)
+		}
+		if root == rfs.rootDir && rfs.ignored != nil && (rfs.ignored(filepath.ToSlash(filepath.Clean(rel))) || rfs.ignored(realPath)) {
+			return nil, fmt.Errorf("access denied: %s is ignored by the project's configuration", path)
 		}
 		file, err := os.Open(absPath)
 		if err != nil {
@@ -56,6 +90,92 @@ func (rfs *RestrictedFilesystem) ReadFiles(filepaths []string) (map[string]strin
 	return result, nil
 }
 
+// resolve returns the absolute path of a file relative to root, refusing paths that lead outside of it
+func resolve(root, path string) (string, error) {
+	absPath, err := filepath.Abs(filepath.Join(root, path))
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("access denied: attempting to access files outside the root directory: %s", path)
+	}
+	return absPath, nil
+}
+
+// resolveLinks returns the slash-separated path relative to root of the file at absPath once the symbolic
+// links along its path are resolved, and whether it's inside root. Files that don't exist are left for
+// os.Open to report.
+func resolveLinks(root, absPath string) (string, bool, error) {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", false, err
+	}
+	realPath, err := filepath.EvalSymlinks(absPath)
+	if os.IsNotExist(err) {
+		realRoot, realPath = root, absPath
+	} else if err != nil {
+		return "", false, err
+	}
+	rel, err := filepath.Rel(realRoot, realPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false, nil
+	}
+	return filepath.ToSlash(rel), true, nil
+}
+
+// SetIgnored denies reading the files of the root directory for which ignored returns true.
+// ignored receives slash-separated paths relative to the root directory.
+func (rfs *RestrictedFilesystem) SetIgnored(ignored func(path string) bool) {
+	rfs.ignored = ignored
+}
+
+// AddBuildContext makes a named build context available along with the directory tree of its files.
+// The tree is ignored for contexts that aren't local directories.
+func (rfs *RestrictedFilesystem) AddBuildContext(c *buildcontext.Context, tree string) {
+	rfs.buildContexts = append(rfs.buildContexts, c)
+	if rfs.contextTrees == nil {
+		rfs.contextTrees = map[string]string{}
+	}
+	if c.IsLocal() {
+		rfs.contextTrees[c.Name] = tree
+	}
+}
+
+// BuildContexts returns the named build contexts the Dockerfile is built with
+func (rfs *RestrictedFilesystem) BuildContexts() []*buildcontext.Context {
+	return rfs.buildContexts
+}
+
+// BuildContext returns the named build context with the given name, nil if there is none
+func (rfs *RestrictedFilesystem) BuildContext(name string) *buildcontext.Context {
+	for _, c := range rfs.buildContexts {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// BuildContextFS returns a read-only view of a local named build context, nil if the context isn't local
+func (rfs *RestrictedFilesystem) BuildContextFS(name string) fs.FS {
+	c := rfs.BuildContext(name)
+	if c == nil || !c.IsLocal() {
+		return nil
+	}
+	return os.DirFS(c.Dir)
+}
+
+// BuildContextTree returns the directory tree of a local named build context
+func (rfs *RestrictedFilesystem) BuildContextTree(name string) string {
+	return rfs.contextTrees[name]
+}
+
+// FS returns a read-only view of the root directory
+func (rfs *RestrictedFilesystem) FS() fs.FS {
+	return os.DirFS(rfs.rootDir)
+}
+
 func (rfs *RestrictedFilesystem) DirTree() string {
 	return rfs.dirTree
 }
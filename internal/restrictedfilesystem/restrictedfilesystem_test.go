@@ -0,0 +1,82 @@
+package restrictedfilesystem
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/buildcontext"
+)
+
+func TestReadFiles(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "app")
+	lib := filepath.Join(parent, "lib")
+	files := map[string]string{
+		filepath.Join(root, "index.js"):       "app",
+		filepath.Join(root, "legacy", "a.js"): "legacy",
+		filepath.Join(lib, "src", "index.js"): "lib",
+		filepath.Join(parent, "app-secrets"):  "secret",
+		filepath.Join(parent, "secret.txt"):   "secret",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links := map[string]string{
+		filepath.Join(root, "alias.js"): "index.js",
+		filepath.Join(root, "shared"):   filepath.Join("..", "lib", "src"),
+		filepath.Join(root, "current"):  "legacy",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symbolic links aren't supported: %v", err)
+		}
+	}
+
+	rfs := NewRestrictedFilesystem(root, "", "Dockerfile", "")
+	libContext, _ := buildcontext.New("lib", "../lib", root)
+	imageContext, _ := buildcontext.New("base", "docker-image://node:22-alpine", root)
+	rfs.AddBuildContext(libContext, "")
+	rfs.AddBuildContext(imageContext, "")
+	rfs.SetIgnored(func(p string) bool { return strings.HasPrefix(p, "legacy/") })
+
+	tests := []struct {
+		path     string
+		expected string
+		wantErr  bool
+	}{
+		{path: "index.js", expected: "app"},
+		{path: "lib:src/index.js", expected: "lib"},
+		{path: "../secret.txt", wantErr: true},
+		{path: "../app-secrets", wantErr: true},
+		{path: "lib:../secret.txt", wantErr: true},
+		{path: "lib:../app/index.js", wantErr: true},
+		{path: "base:etc/passwd", wantErr: true},
+		// a prefix that isn't a build context is part of the path
+		{path: "other:index.js", wantErr: true},
+		{path: "legacy/a.js", wantErr: true},
+		{path: "./legacy/a.js", wantErr: true},
+		{path: "alias.js", expected: "app"},
+		{path: "shared/index.js", wantErr: true},
+		{path: "current/a.js", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := rfs.ReadFiles(context.Background(), []string{tt.path})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if result[tt.path] != tt.expected {
+				t.Errorf("ReadFiles()[%q] = %q; want %q", tt.path, result[tt.path], tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,786 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/layerorder"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/packagelock"
+	"github.com/duaraghav8/dockershrink/internal/platform"
+	"github.com/duaraghav8/dockershrink/internal/secrets"
+	"github.com/duaraghav8/dockershrink/internal/units"
+	"github.com/duaraghav8/dockershrink/internal/workspace"
+)
+
+var (
+	// matches commands that install nodejs dependencies
+	installRegex = layerorder.InstallRegex
+	// matches options that exclude dev dependencies from installation
+	omitDevRegex = regexp.MustCompile(`--omit[= ]dev|--only[= ]prod(uction)?|--production|--prod\b|NODE_ENV=production`)
+	// matches commands that build, test or lint the code
+	buildStepRegex = regexp.MustCompile(`\b(npm|yarn|pnpm)\s+(run\s+)?(build|test|lint)\b|\btsc\b|\bwebpack\b|\bvite\s+build\b|\bjest\b|\beslint\b`)
+	// matches commands that clean the package manager cache
+	cacheCleanRegex = regexp.MustCompile(`npm\s+cache\s+clean|yarn\s+cache\s+clean|pnpm\s+store\s+prune|rm\s+-rf?\s+\S*(\.npm|\.cache|yarn)`)
+	// matches sources of ADD that are fetched rather than copied from the build context
+	remoteSourceRegex = regexp.MustCompile(`^(https?://|git@|git://)`)
+	// matches local archives, which ADD extracts
+	archiveRegex = regexp.MustCompile(`\.(tar|tar\.gz|tgz|tar\.bz2|tbz2?|tar\.xz|txz|tar\.zst)$`)
+	// matches the tags of the onbuild variants of images, eg- node:8-onbuild
+	onbuildTagRegex = regexp.MustCompile(`(^|-)onbuild$`)
+)
+
+// packageCache is the download cache of a package manager, which a BuildKit cache mount can keep across builds
+type packageCache struct {
+	manager string
+	// install matches the commands that fill the cache
+	install *regexp.Regexp
+	// targets are the directories of the cache for the root user
+	targets []string
+}
+
+var packageCaches = []*packageCache{
+	{"npm", regexp.MustCompile(`\bnpm\s+(ci|install|i)\b`), []string{"/root/.npm"}},
+	{"yarn", regexp.MustCompile(`\byarn\s+(install|add)\b|\byarn\s*($|&&|;|--)`), []string{"/usr/local/share/.cache/yarn"}},
+	{"pnpm", regexp.MustCompile(`\bpnpm\s+(install|i|fetch)\b`), []string{"/root/.local/share/pnpm/store"}},
+	{"pip", regexp.MustCompile(`\bpip3?\s+install\b|\bpython3?\s+-m\s+pip\s+install\b`), []string{"/root/.cache/pip"}},
+	{"go", regexp.MustCompile(`\bgo\s+(mod\s+download|build|install)\b`), []string{"/root/go/pkg/mod", "/root/.cache/go-build"}},
+	{"cargo", regexp.MustCompile(`\bcargo\s+(build|fetch|install)\b`), []string{"/usr/local/cargo/registry"}},
+}
+
+// mountFlags returns the RUN flags that mount the cache
+func (p *packageCache) mountFlags() string {
+	flags := make([]string, 0, len(p.targets))
+	for _, target := range p.targets {
+		flags = append(flags, "--mount=type=cache,target="+target)
+	}
+	return strings.Join(flags, " ")
+}
+
+// supportsRunMounts returns false if the syntax directive pins a version of the Dockerfile frontend that's
+// older than 1.2, which doesn't support "RUN --mount" unless it's an experimental one. Without a directive,
+// BuildKit's built-in frontend is used, which supports it.
+func supportsRunMounts(syntax string) bool {
+	return dockerfile.SyntaxAtLeast(syntax, 1, 2)
+}
+
+// dockerignoreEntries are the entries every nodejs project's .dockerignore must contain
+var dockerignoreEntries = []string{"node_modules", ".git"}
+
+var ruleMissingDockerignore = &Rule{
+	ID:          "DS001",
+	Name:        "missing-dockerignore",
+	Description: "The project has no .dockerignore, so everything in it, eg- node_modules, .git and local .env files, is sent to the builder and can end up in the image.",
+	Severity:    models.SeverityMedium,
+	Goals:       []models.Goal{models.GoalSize, models.GoalBuildSpeed},
+	Check: func(c *Context) []*models.Finding {
+		if c.Dockerignore != nil {
+			return nil
+		}
+		impact := int64(0)
+		if copiesEntireContext(c.Dockerfile) {
+			for _, e := range dockerignoreEntries {
+				impact += c.size(e)
+			}
+		}
+		// the path is where the file is expected to be, if it's known
+		location := c.DockerignorePath
+		if location == "" {
+			location = ".dockerignore"
+		}
+		return []*models.Finding{{
+			Filepath:            location,
+			Title:               "Project has no .dockerignore file",
+			Description:         "Without a .dockerignore, the entire project directory (including node_modules and .git) is sent to the Docker daemon as build context and can end up inside the image.",
+			EstimatedSizeImpact: impact,
+		}}
+	},
+}
+
+var ruleDockerignoreMissingEntries = &Rule{
+	ID:          "DS002",
+	Name:        "dockerignore-missing-entries",
+	Description: "The .dockerignore doesn't exclude paths every Node.js project should keep out of the build context, eg- node_modules, .git or npm-debug.log.",
+	Severity:    models.SeverityLow,
+	Goals:       []models.Goal{models.GoalSize, models.GoalBuildSpeed},
+	Check: func(c *Context) []*models.Finding {
+		if c.Dockerignore == nil {
+			return nil
+		}
+		findings := []*models.Finding{}
+		for _, e := range dockerignoreEntries {
+			if c.Dockerignore.Contains(e) {
+				continue
+			}
+			impact := int64(0)
+			if copiesEntireContext(c.Dockerfile) {
+				impact = c.size(e)
+			}
+			findings = append(findings, &models.Finding{
+				Filepath:            c.DockerignorePath,
+				Title:               fmt.Sprintf("%s is not excluded from the build context", e),
+				Description:         fmt.Sprintf("Add %s to .dockerignore so it isn't sent to the Docker daemon or copied into the image.", e),
+				EstimatedSizeImpact: impact,
+			})
+		}
+		return findings
+	},
+}
+
+var ruleHeavyFinalBaseImage = &Rule{
+	ID:          "DS003",
+	Name:        "heavy-final-base-image",
+	Description: "The final stage is based on a full image, which ships compilers and tools the application doesn't need at runtime. Use the slim or alpine variant instead.",
+	Severity:    models.SeverityHigh,
+	Goals:       []models.Goal{models.GoalSize, models.GoalSecurity},
+	Check: func(c *Context) []*models.Finding {
+		final := finalStage(c.Dockerfile)
+		if final == nil || c.baseIsNamedContext(final) {
+			return nil
+		}
+		image := final.BaseImage()
+		if image.IsLightweight() || image.Name() == "scratch" {
+			return nil
+		}
+		// native modules built against glibc fail to load on alpine, which uses musl, so slim is the smallest variant then
+		variant, variants, glibc := "alpine", "an alpine, slim or distroless variant", ""
+		if image.Name() == "node" {
+			if native := NativeModulesBuiltOnGlibc(c, final); len(native) > 0 {
+				variant, variants = "slim", "a slim or distroless variant"
+				glibc = fmt.Sprintf(" Alpine variants are ruled out since the native modules %s are built against glibc, while alpine uses musl.", strings.Join(packagelock.Names(native), ", "))
+			}
+		}
+		// sizes are compressed, ie- what is downloaded when pulling the image, both the measured and the estimated ones
+		impact := lightweightVariantSavings(image, variant)
+		description := fmt.Sprintf("The final image is based on '%s', which contains a full operating system distribution. Use %s instead.", image.FullName(), variants)
+		if impact > 0 {
+			description += fmt.Sprintf(" The %s variant is a compressed download about %s smaller.", variant, units.FormatBytes(impact))
+		}
+		if rec := c.BaseImages.Recommend(image, c.Platforms); rec != nil {
+			if slim, _ := GlibcVariant(c, final, dockerfile.NewImage(rec.Suggested)); slim != nil {
+				rec = c.BaseImages.Substitute(rec, slim)
+			}
+			description = strings.TrimSpace(fmt.Sprintf("The final image is based on '%s', which contains a full operating system distribution. Use '%s' instead. %s", image.FullName(), rec.Suggested, rec.Details()))
+			if savings := rec.Savings(); savings > 0 {
+				impact = savings
+			}
+		}
+		description += glibc
+		return []*models.Finding{{
+			Filepath:            c.DockerfilePath,
+			Line:                final.StartLine(),
+			Title:               "Final stage uses a heavy base image",
+			Description:         description,
+			EstimatedSizeImpact: impact,
+		}}
+	},
+}
+
+var ruleUnsupportedBaseImage = &Rule{
+	ID:          "DS004",
+	Name:        "unsupported-base-image",
+	Description: "A base image has reached its end of life or is deprecated, so it no longer receives security fixes.",
+	Severity:    models.SeverityMedium,
+	Goals:       []models.Goal{models.GoalSecurity},
+	Check: func(c *Context) []*models.Finding {
+		findings := []*models.Finding{}
+		for _, stage := range c.Dockerfile.GetStages() {
+			if c.baseIsNamedContext(stage) {
+				continue
+			}
+			rec := c.BaseImages.Upgrade(stage.BaseImage())
+			if rec == nil {
+				continue
+			}
+			title := fmt.Sprintf("Base image %s has reached its end of life", rec.Current)
+			if rec.Deprecated {
+				title = fmt.Sprintf("Base image %s is deprecated", rec.Current)
+			}
+			findings = append(findings, &models.Finding{
+				Filepath:    c.DockerfilePath,
+				Line:        stage.StartLine(),
+				Title:       title,
+				Description: fmt.Sprintf("%s Upgrade to '%s'.", rec.Details(), rec.Suggested),
+			})
+		}
+		return findings
+	},
+}
+
+var ruleBaseImagePlatforms = &Rule{
+	ID:          "DS005",
+	Name:        "base-image-platforms",
+	Description: "A base image isn't published for every platform the image is built for, so the build fails or runs under emulation on them.",
+	Severity:    models.SeverityHigh,
+	Goals:       []models.Goal{models.GoalSize, models.GoalBuildSpeed, models.GoalSecurity},
+	Check: func(c *Context) []*models.Finding {
+		findings := []*models.Finding{}
+		for _, stage := range c.Dockerfile.GetStages() {
+			if !stage.BuiltForTargetPlatform() || c.baseIsNamedContext(stage) {
+				continue
+			}
+			check := c.BaseImages.CheckPlatforms(stage.BaseImage(), c.Platforms)
+			if check == nil {
+				continue
+			}
+			description := fmt.Sprintf("'%s' isn't published for %s, so the image can't be built for all of its target platforms.", check.Image, platform.Join(check.Missing))
+			if check.Alternative != "" {
+				description += fmt.Sprintf(" Use '%s' instead, which is published for all of them.", check.Alternative)
+			}
+			findings = append(findings, &models.Finding{
+				Filepath:    c.DockerfilePath,
+				Line:        stage.StartLine(),
+				Title:       fmt.Sprintf("Base image %s is not available for every target platform", check.Image),
+				Description: description,
+			})
+		}
+		return findings
+	},
+}
+
+var ruleUntaggedBaseImage = &Rule{
+	ID:          "DS012",
+	Name:        "untagged-base-image",
+	Description: "A base image doesn't specify a version, so builds aren't reproducible and can break when the latest tag moves.",
+	Severity:    models.SeverityMedium,
+	Goals:       []models.Goal{models.GoalSecurity, models.GoalBuildSpeed},
+	Check: func(c *Context) []*models.Finding {
+		findings := []*models.Finding{}
+		for _, stage := range c.Dockerfile.GetStages() {
+			img := stage.BaseImage()
+			if img.Name() == "scratch" || strings.Contains(img.FullName(), "$") || c.Dockerfile.GetBaseStage(stage) != nil || c.baseIsNamedContext(stage) {
+				continue
+			}
+			if img.Tag() != dockerfile.DefaultTag || img.Digest() != "" {
+				continue
+			}
+			findings = append(findings, &models.Finding{
+				Filepath:    c.DockerfilePath,
+				Line:        stage.StartLine(),
+				Title:       fmt.Sprintf("Base image %s doesn't specify a version", img.Name()),
+				Description: fmt.Sprintf("'%s' resolves to whatever the latest tag points to at build time, so builds aren't reproducible and can break or grow without any change to the Dockerfile. Use a specific tag, eg- '%s:<version>-slim'.", img.FullName(), img.Name()),
+			})
+		}
+		return findings
+	},
+}
+
+var ruleAddInsteadOfCopy = &Rule{
+	ID:          "DS013",
+	Name:        "add-instead-of-copy",
+	Description: "ADD is used to copy local files. COPY does the same without ADD's implicit extraction of archives and downloads.",
+	Severity:    models.SeverityLow,
+	Goals:       []models.Goal{models.GoalSecurity},
+	Check: func(c *Context) []*models.Finding {
+		findings := []*models.Finding{}
+		for _, stage := range c.Dockerfile.GetStages() {
+			for _, inst := range stage.Instructions() {
+				if inst.Cmd() != dockerfile.CmdAdd || len(inst.Heredocs()) > 0 {
+					continue
+				}
+				local := true
+				for _, src := range copySources(inst) {
+					if remoteSourceRegex.MatchString(src) || archiveRegex.MatchString(src) {
+						local = false
+					}
+				}
+				if !local {
+					continue
+				}
+				findings = append(findings, &models.Finding{
+					Filepath:    c.DockerfilePath,
+					Line:        inst.StartLine(),
+					Title:       "ADD is used to copy local files",
+					Description: "ADD implicitly extracts archives and fetches URLs, which makes its behavior depend on the files being copied. Use COPY for plain files and directories.",
+				})
+			}
+		}
+		return findings
+	},
+}
+
+var ruleMissingMultistageBuild = &Rule{
+	ID:          "DS006",
+	Name:        "missing-multistage-build",
+	Description: "The Dockerfile has a single stage, so the tools and files needed to build the application ship in the final image.",
+	Severity:    models.SeverityMedium,
+	Goals:       []models.Goal{models.GoalSize, models.GoalSecurity},
+	Check: func(c *Context) []*models.Finding {
+		stages := c.Dockerfile.GetStages()
+		if len(stages) != 1 {
+			return nil
+		}
+		for _, inst := range stages[0].Instructions() {
+			if inst.Cmd() == dockerfile.CmdRun && buildStepRegex.MatchString(inst.Command()) {
+				return []*models.Finding{{
+					Filepath:    c.DockerfilePath,
+					Line:        inst.StartLine(),
+					Title:       "Build and test tooling ships in the final image",
+					Description: "The Dockerfile builds or tests the code in its only stage, so compilers, dev dependencies and intermediate artifacts end up in the final image. Use a multistage build and only copy the runtime artifacts into a lightweight final stage.",
+				}}
+			}
+		}
+		return nil
+	},
+}
+
+var ruleDevDependenciesInFinalStage = &Rule{
+	ID:          "DS007",
+	Name:        "devdependencies-in-final-stage",
+	Description: "devDependencies are installed in the final stage, even though the application doesn't need them at runtime.",
+	Severity:    models.SeverityHigh,
+	Goals:       []models.Goal{models.GoalSize, models.GoalSecurity},
+	Check: func(c *Context) []*models.Finding {
+		final := finalStage(c.Dockerfile)
+		if final == nil {
+			return nil
+		}
+		productionEnv := false
+		for _, inst := range stageInstructions(c.Dockerfile, final) {
+			if inst.Cmd() == dockerfile.CmdEnv || inst.Cmd() == dockerfile.CmdArg {
+				if v, ok := envValue(inst, "NODE_ENV"); ok && v == "production" {
+					productionEnv = true
+				}
+				continue
+			}
+			// NODE_ENV may also be set through a build argument, eg- ENV NODE_ENV=${TARGET_ENV}
+			if inst.Cmd() != dockerfile.CmdRun || productionEnv || inst.Expand("$NODE_ENV") == "production" {
+				continue
+			}
+			cmd := inst.Command()
+			if installRegex.MatchString(cmd) && !omitDevRegex.MatchString(cmd) {
+				return []*models.Finding{{
+					Filepath:    c.DockerfilePath,
+					Line:        inst.StartLine(),
+					Title:       "devDependencies are installed in the final image",
+					Description: "Dependencies are installed in the final stage without excluding devDependencies. Set NODE_ENV=production before installing or use --omit=dev (npm), --production (yarn) or --prod (pnpm).",
+				}}
+			}
+		}
+		return nil
+	},
+}
+
+var ruleNodeModulesCopiedFromContext = &Rule{
+	ID:          "DS008",
+	Name:        "node-modules-copied-from-context",
+	Description: "node_modules is copied from the build context, which brings in the devDependencies and platform-specific binaries of the host.",
+	Severity:    models.SeverityHigh,
+	Goals:       []models.Goal{models.GoalSize},
+	Check: func(c *Context) []*models.Finding {
+		findings := []*models.Finding{}
+		for _, stage := range c.Dockerfile.GetStages() {
+			for _, inst := range stageInstructions(c.Dockerfile, stage) {
+				// only local named contexts contain the host's node_modules, not images
+				named := c.copiedNamedContext(inst)
+				if !isCopyFromContext(inst) && (named == "" || c.BuildContexts[named] == nil) {
+					continue
+				}
+				for _, src := range copySources(inst) {
+					if !strings.Contains(src, "node_modules") {
+						continue
+					}
+					title, impact := "node_modules is copied from the build context", c.size("node_modules")
+					if named != "" {
+						title = fmt.Sprintf("node_modules is copied from the build context '%s'", named)
+						impact = c.sizeIn(named, src)
+					}
+					findings = append(findings, &models.Finding{
+						Filepath:            c.DockerfilePath,
+						Line:                inst.StartLine(),
+						Title:               title,
+						Description:         "node_modules on the host usually contains devDependencies and platform-specific binaries. Install dependencies inside the image instead.",
+						EstimatedSizeImpact: impact,
+					})
+					break
+				}
+			}
+		}
+		return findings
+	},
+}
+
+var ruleUnprunedMonorepo = &Rule{
+	ID:          "DS009",
+	Name:        "unpruned-monorepo",
+	Description: "The entire monorepo is copied into the image, instead of only the package being built and the packages it depends on.",
+	Severity:    models.SeverityHigh,
+	Goals:       []models.Goal{models.GoalSize, models.GoalBuildSpeed},
+	Check: func(c *Context) []*models.Finding {
+		if c.Workspace == nil || len(c.Workspace.Packages) < 2 || workspace.IsPruned(c.Dockerfile.Raw()) {
+			return nil
+		}
+		if !copiesEntireContext(c.Dockerfile) {
+			return nil
+		}
+		impact := int64(0)
+		for _, p := range c.Workspace.Packages {
+			impact += c.size(p.Dir)
+		}
+		return []*models.Finding{{
+			Filepath:    c.DockerfilePath,
+			Title:       "The entire monorepo is copied into the image",
+			Description: fmt.Sprintf("This %s workspace has %d packages but the image only needs one of them. Prune the workspace with '%s' before installing dependencies.", c.Workspace.Manager, len(c.Workspace.Packages), c.Workspace.PruneCommand("")),
+			// all but one package can be left out, assume the packages are of similar size
+			EstimatedSizeImpact: impact * int64(len(c.Workspace.Packages)-1) / int64(len(c.Workspace.Packages)),
+		}}
+	},
+}
+
+var ruleAptGetInstallRecommends = &Rule{
+	ID:          "DS014",
+	Name:        "apt-get-install-recommends",
+	Description: "apt-get install runs without --no-install-recommends, which installs recommended packages the image rarely needs.",
+	Severity:    models.SeverityMedium,
+	Goals:       []models.Goal{models.GoalSize},
+	Check: func(c *Context) []*models.Finding {
+		findings := []*models.Finding{}
+		for _, inst := range aptGetInstalls(c.Dockerfile) {
+			if strings.Contains(inst.Command(), "--no-install-recommends") {
+				continue
+			}
+			findings = append(findings, &models.Finding{
+				Filepath:    c.DockerfilePath,
+				Line:        inst.StartLine(),
+				Title:       "apt-get install pulls in recommended packages",
+				Description: "apt-get installs the recommended packages of every package by default, which are rarely needed in an image. Use --no-install-recommends to skip them.",
+			})
+		}
+		return findings
+	},
+}
+
+var ruleAptGetBloat = &Rule{
+	ID:          "DS015",
+	Name:        "apt-get-bloat",
+	Description: "apt-get install leaves the package lists in the layer. Remove /var/lib/apt/lists in the same RUN instruction.",
+	Severity:    models.SeverityMedium,
+	Goals:       []models.Goal{models.GoalSize},
+	Check: func(c *Context) []*models.Finding {
+		findings := []*models.Finding{}
+		for _, inst := range aptGetInstalls(c.Dockerfile) {
+			if script := runScript(inst); !LeavesAptLists(inst, script) {
+				continue
+			}
+			findings = append(findings, &models.Finding{
+				Filepath:    c.DockerfilePath,
+				Line:        inst.StartLine(),
+				Title:       "apt-get install leaves the package lists in the layer",
+				Description: "To keep this layer small, remove /var/lib/apt/lists/* in the same RUN instruction.",
+			})
+		}
+		return findings
+	},
+}
+
+var rulePackageManagerCacheLeftBehind = &Rule{
+	ID:          "DS010",
+	Name:        "package-manager-cache-left-behind",
+	Description: "The cache of a package manager is left in the layer that filled it, which makes the image bigger without any benefit.",
+	Severity:    models.SeverityLow,
+	Goals:       []models.Goal{models.GoalSize},
+	Check: func(c *Context) []*models.Finding {
+		final := finalStage(c.Dockerfile)
+		if final == nil {
+			return nil
+		}
+		for _, inst := range stageInstructions(c.Dockerfile, final) {
+			if inst.Cmd() != dockerfile.CmdRun {
+				continue
+			}
+			cmd := inst.Command()
+			if !installRegex.MatchString(cmd) || cacheCleanRegex.MatchString(cmd) {
+				continue
+			}
+			if mount, ok := inst.Flag("mount"); ok && strings.Contains(mount, "type=cache") {
+				continue
+			}
+			return []*models.Finding{{
+				Filepath:    c.DockerfilePath,
+				Line:        inst.StartLine(),
+				Title:       "Package manager cache is left in the final image",
+				Description: "Installing dependencies leaves the package manager's download cache inside the layer. Clean it in the same RUN instruction (eg- npm cache clean --force) or use a BuildKit cache mount.",
+			}}
+		}
+		return nil
+	},
+}
+
+var ruleSourceCopiedBeforeDependencies = &Rule{
+	ID:          "DS011",
+	Name:        "source-copied-before-dependencies",
+	Description: "Source code is copied before dependencies are installed, so any change to it invalidates the cache of the dependency installation.",
+	Severity:    models.SeverityLow,
+	Goals:       []models.Goal{models.GoalBuildSpeed},
+	Check: func(c *Context) []*models.Finding {
+		findings := []*models.Finding{}
+		for _, issue := range layerorder.Find(c.Dockerfile) {
+			title := "Source code is copied before installing dependencies"
+			description := fmt.Sprintf("Any change in the project invalidates the cache of the dependency installation on line %d. Copy package.json and the lockfile first, install dependencies, then copy the rest of the source code.", issue.Install.StartLine())
+			if !issue.Broad {
+				title = fmt.Sprintf("%s is copied before installing dependencies", strings.Join(issue.Copy.Sources(), ", "))
+				description = fmt.Sprintf("Any change to the copied files invalidates the cache of the dependency installation on line %d. Copy them after installing dependencies.", issue.Install.StartLine())
+			}
+			if c.PackageJSON != nil {
+				deps := len(c.PackageJSON.GetDependencies()) + len(c.PackageJSON.GetDevDependencies())
+				description += fmt.Sprintf(" Installing the %d dependencies again takes roughly %s on every rebuild.", deps, layerorder.EstimateInstallTime(deps))
+			}
+			findings = append(findings, &models.Finding{
+				Filepath:    c.DockerfilePath,
+				Line:        issue.Copy.StartLine(),
+				Title:       title,
+				Description: description,
+			})
+		}
+		return findings
+	},
+}
+
+var ruleMissingCacheMount = &Rule{
+	ID:          "DS017",
+	Name:        "missing-cache-mount",
+	Description: "A package manager downloads every package again whenever its layer is rebuilt. A cache mount keeps its cache between builds.",
+	Severity:    models.SeverityLow,
+	Goals:       []models.Goal{models.GoalBuildSpeed},
+	Check: func(c *Context) []*models.Finding {
+		findings := []*models.Finding{}
+		upgrade := ""
+		if !supportsRunMounts(c.Dockerfile.Syntax()) {
+			upgrade = fmt.Sprintf(" Cache mounts need a newer Dockerfile syntax than '%s', change the directive to '# syntax=docker/dockerfile:1'.", c.Dockerfile.Syntax())
+		}
+		for _, stage := range c.Dockerfile.GetStages() {
+			for _, inst := range stage.Instructions() {
+				if inst.Cmd() != dockerfile.CmdRun {
+					continue
+				}
+				if mount, ok := inst.Flag("mount"); ok && strings.Contains(mount, "type=cache") {
+					continue
+				}
+				cmd := inst.Command()
+				for _, cache := range packageCaches {
+					if !cache.install.MatchString(cmd) {
+						continue
+					}
+					findings = append(findings, &models.Finding{
+						Filepath: c.DockerfilePath,
+						Line:     inst.StartLine(),
+						Title:    fmt.Sprintf("%s downloads every package again when the layer is rebuilt", cache.manager),
+						Description: fmt.Sprintf("Any change to the dependencies invalidates this layer, and %s's download cache starts out empty. Use a BuildKit cache mount to reuse it across builds without storing it in the image, eg- 'RUN %s %s'.%s",
+							cache.manager, cache.mountFlags(), strings.TrimSpace(inst.Original()[len(dockerfile.CmdRun):]), upgrade),
+					})
+					break
+				}
+			}
+		}
+		return findings
+	},
+}
+
+var ruleOutdatedSyntaxDirective = &Rule{
+	ID:          "DS018",
+	Name:        "outdated-syntax-directive",
+	Description: "The syntax directive pins a Dockerfile frontend that doesn't support RUN --mount, which cache and secret mounts need.",
+	Severity:    models.SeverityMedium,
+	Goals:       []models.Goal{models.GoalBuildSpeed, models.GoalSize},
+	Check: func(c *Context) []*models.Finding {
+		syntax := c.Dockerfile.Syntax()
+		if supportsRunMounts(syntax) {
+			return nil
+		}
+		for _, stage := range c.Dockerfile.GetStages() {
+			for _, inst := range stage.Instructions() {
+				if _, ok := inst.Flag("mount"); !ok || inst.Cmd() != dockerfile.CmdRun {
+					continue
+				}
+				return []*models.Finding{{
+					Filepath:    c.DockerfilePath,
+					Line:        c.Dockerfile.SyntaxLine(),
+					Title:       "Syntax directive doesn't support RUN --mount",
+					Description: fmt.Sprintf("'%s' is older than version 1.2 of the Dockerfile syntax, so the RUN --mount on line %d fails to build. Change the directive to '# syntax=docker/dockerfile:1'.", syntax, inst.StartLine()),
+				}}
+			}
+		}
+		return nil
+	},
+}
+
+var ruleSecretInBuildArg = &Rule{
+	ID:          "DS019",
+	Name:        "secret-in-build-arg",
+	Description: "A secret is passed as a build argument, which is recorded in the image's history. Use a secret mount instead.",
+	Severity:    models.SeverityHigh,
+	Goals:       []models.Goal{models.GoalSecurity},
+	Check: func(c *Context) []*models.Finding {
+		findings := []*models.Finding{}
+		for _, s := range secrets.Find(c.Dockerfile) {
+			id := strings.ToLower(s.Name)
+			if s.Source != "" {
+				id = strings.ToLower(s.Source)
+			}
+			mount := fmt.Sprintf("Mount it only where it's needed with 'RUN --mount=type=secret,id=%s,env=%s' and build with 'docker build --secret id=%s,env=%s'.", id, s.Name, id, s.Name)
+			f := &models.Finding{Filepath: c.DockerfilePath, Line: s.Line}
+			switch {
+			case s.Literal:
+				f.Title = fmt.Sprintf("Secret %s is written in the Dockerfile", s.Name)
+				f.Description = fmt.Sprintf("The value of %s is part of the Dockerfile and is stored in the image, so anyone with access to either can read it. Remove it, rotate the secret and pass it at build time instead. %s", s.Name, mount)
+			case s.Cmd == dockerfile.CmdEnv:
+				f.Title = fmt.Sprintf("Secret %s is stored in the image's environment", s.Name)
+				f.Description = fmt.Sprintf("ENV variables are part of the image's configuration, so anyone who can pull the image can read %s with 'docker inspect'. %s", s.Name, mount)
+			default:
+				f.Title = fmt.Sprintf("Secret %s is passed as a build argument", s.Name)
+				f.Description = fmt.Sprintf("The values of build arguments are recorded in the image's history, so anyone who can pull the image can read %s with 'docker history'. %s", s.Name, mount)
+			}
+			findings = append(findings, f)
+		}
+		return findings
+	},
+}
+
+var ruleOnbuildTriggers = &Rule{
+	ID:          "DS016",
+	Name:        "onbuild-triggers",
+	Description: "A stage runs ONBUILD triggers, which execute instructions that aren't visible in the Dockerfile.",
+	Severity:    models.SeverityInfo,
+	Goals:       []models.Goal{models.GoalSize, models.GoalBuildSpeed, models.GoalSecurity},
+	Check: func(c *Context) []*models.Finding {
+		findings := []*models.Finding{}
+		for _, stage := range c.Dockerfile.GetStages() {
+			if img := stage.BaseImage(); c.Dockerfile.GetBaseStage(stage) == nil && onbuildTagRegex.MatchString(img.Tag()) {
+				findings = append(findings, &models.Finding{
+					Filepath:    c.DockerfilePath,
+					Line:        stage.StartLine(),
+					Title:       fmt.Sprintf("Base image %s runs hidden ONBUILD triggers", img.FullName()),
+					Description: "The onbuild variants of images copy the entire build context and install dependencies before the stage's own instructions, which can't be seen in the Dockerfile and defeats layer caching. They are deprecated, use the regular variant and write these steps explicitly.",
+				})
+				continue
+			}
+			triggers := c.Dockerfile.GetOnbuildTriggers(stage)
+			if len(triggers) == 0 {
+				continue
+			}
+			steps := make([]string, 0, len(triggers))
+			for _, t := range triggers {
+				steps = append(steps, fmt.Sprintf("%s (line %d)", t.Original(), t.StartLine()))
+			}
+			findings = append(findings, &models.Finding{
+				Filepath:    c.DockerfilePath,
+				Line:        stage.StartLine(),
+				Title:       fmt.Sprintf("Stage runs the ONBUILD triggers of %s", stage.DeclaredBaseImage()),
+				Description: fmt.Sprintf("These instructions run at the start of the stage, before its own instructions, and are checked as part of it: %s.", strings.Join(steps, ", ")),
+			})
+		}
+		return findings
+	},
+}
+
+// aptGetInstalls returns the RUN instructions that install packages with apt-get
+func aptGetInstalls(d *dockerfile.Dockerfile) []*dockerfile.Instruction {
+	installs := []*dockerfile.Instruction{}
+	for _, stage := range d.GetStages() {
+		for _, inst := range stage.Instructions() {
+			if inst.Cmd() != dockerfile.CmdRun {
+				continue
+			}
+			script := runScript(inst)
+			if script == nil && strings.Contains(inst.Command(), "apt-get install") || script != nil && installsAptPackages(script) {
+				installs = append(installs, inst)
+			}
+		}
+	}
+	return installs
+}
+
+// stageInstructions returns the instructions that run in the stage, starting with the ONBUILD
+// triggers of the stage it's built from
+func stageInstructions(d *dockerfile.Dockerfile, stage *dockerfile.Stage) []*dockerfile.Instruction {
+	return append(d.GetOnbuildTriggers(stage), stage.Instructions()...)
+}
+
+func finalStage(d *dockerfile.Dockerfile) *dockerfile.Stage {
+	stages := d.GetStages()
+	if len(stages) == 0 {
+		return nil
+	}
+	return stages[len(stages)-1]
+}
+
+// isCopyFromContext returns true if the instruction copies files from the build context
+func isCopyFromContext(inst *dockerfile.Instruction) bool {
+	if inst.Cmd() != dockerfile.CmdCopy && inst.Cmd() != dockerfile.CmdAdd {
+		return false
+	}
+	_, fromStage := inst.Flag("from")
+	return !fromStage
+}
+
+// copiedNamedContext returns the name of the named build context a COPY or ADD instruction copies files from,
+// eg- "lib" for "COPY --from=lib src/ ./lib". Stages take precedence over contexts of the same name.
+// An empty string is returned if the instruction doesn't copy from a named context.
+func (c *Context) copiedNamedContext(inst *dockerfile.Instruction) string {
+	if inst.Cmd() != dockerfile.CmdCopy && inst.Cmd() != dockerfile.CmdAdd {
+		return ""
+	}
+	from, ok := inst.Flag("from")
+	if !ok || dockerfile.FindStage(c.Dockerfile.GetStages(), from) != nil {
+		return ""
+	}
+	if _, ok := c.BuildContexts[from]; !ok {
+		return ""
+	}
+	return from
+}
+
+// baseIsNamedContext returns true if a named build context replaces the image a stage is built from,
+// eg- "FROM base" built with "--build-context base=docker-image://node:22-alpine"
+func (c *Context) baseIsNamedContext(stage *dockerfile.Stage) bool {
+	_, ok := c.BuildContexts[stage.BaseImage().FamiliarName()]
+	return ok
+}
+
+// copySources returns the source paths of a COPY or ADD instruction, excluding here-documents
+func copySources(inst *dockerfile.Instruction) []string {
+	return inst.Sources()
+}
+
+// copiesEverything returns true if a COPY or ADD instruction copies the root of the build context
+func copiesEverything(inst *dockerfile.Instruction) bool {
+	for _, src := range copySources(inst) {
+		if src == "." || src == "./" || src == "/" || src == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func copiesEntireContext(d *dockerfile.Dockerfile) bool {
+	for _, stage := range d.GetStages() {
+		for _, inst := range stage.Instructions() {
+			if isCopyFromContext(inst) && copiesEverything(inst) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// envValue returns the value assigned to key by an ENV or ARG instruction
+func envValue(inst *dockerfile.Instruction, key string) (string, bool) {
+	args := inst.Args()
+	if inst.Cmd() == dockerfile.CmdArg {
+		for _, a := range args {
+			if k, v, ok := strings.Cut(a, "="); ok && k == key {
+				return strings.Trim(v, `"'`), true
+			}
+		}
+		return "", false
+	}
+	// ENV arguments are parsed as (key, value, separator) triplets
+	for i := 0; i+1 < len(args); i += 3 {
+		if args[i] == key {
+			return strings.Trim(args[i+1], `"'`), true
+		}
+	}
+	return "", false
+}
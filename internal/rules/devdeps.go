@@ -0,0 +1,335 @@
+package rules
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/imports"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/packagelock"
+)
+
+var (
+	// matches commands that remove packages from an installed node_modules, eg- "npm prune --omit=dev"
+	pruneRegex = regexp.MustCompile(`\b(npm|pnpm|yarn)\s+prune\b`)
+	// matches commands that delete node_modules
+	removeNodeModulesRegex = regexp.MustCompile(`\brm\s+-\w+\s+(\S*/)?node_modules\b`)
+)
+
+// maxExamples is the number of packages named in the description of a finding
+const maxExamples = 5
+
+// productionDependencies returns the names of the production dependencies of package.json, including
+// the optional ones, sorted
+func (c *Context) productionDependencies() []string {
+	names := []string{}
+	if c.PackageJSON == nil {
+		return names
+	}
+	for name := range c.PackageJSON.GetDependencies() {
+		names = append(names, name)
+	}
+	for name := range c.PackageJSON.GetOptionalDependencies() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lockfile returns the npm lockfile of the project, nil if it doesn't have one or package.json, which
+// declares the dependencies the packages of the lockfile are installed for
+func (c *Context) lockfile() *packagelock.Lockfile {
+	if c.ProjectDir == nil || c.PackageJSON == nil {
+		return nil
+	}
+	lock, err := packagelock.Read(c.ProjectDir)
+	if err != nil {
+		return nil
+	}
+	return lock
+}
+
+// packagesSize estimates the size of the packages from the node_modules in the build context, or from the
+// known sizes of the top-level packages they're installed for if the build context doesn't have node_modules
+func (c *Context) packagesSize(packages []*packagelock.Package, topLevel []string) int64 {
+	var size int64
+	if len(packages) > 0 && c.size("node_modules") > 0 {
+		for _, p := range packages {
+			size += c.size(p.Path)
+		}
+		return size
+	}
+	for _, name := range topLevel {
+		size += npmPackageSize(name)
+	}
+	return size
+}
+
+// devInstall returns the instruction whose installation of the devDependencies is still in node_modules at the
+// end of the stage, along with the directory it installs them in. nil is returned if the stage doesn't install
+// them, or removes them afterwards, eg- with "npm prune --omit=dev".
+func (c *Context) devInstall(stage *dockerfile.Stage, workdirs [][]string) (*dockerfile.Instruction, string) {
+	var install *dockerfile.Instruction
+	dir := ""
+	for _, s := range c.stageChain(stage) {
+		production := false
+		for i, inst := range s.Instructions() {
+			if inst.Cmd() == dockerfile.CmdEnv || inst.Cmd() == dockerfile.CmdArg {
+				if v, ok := envValue(inst, "NODE_ENV"); ok {
+					production = v == "production"
+				}
+				continue
+			}
+			if inst.Cmd() != dockerfile.CmdRun {
+				continue
+			}
+			cmd := inst.Command()
+			omitsDev := production || omitDevRegex.MatchString(cmd) || inst.Expand("$NODE_ENV") == "production"
+			switch {
+			case installRegex.MatchString(cmd) && omitsDev, pruneRegex.MatchString(cmd) && omitsDev, removeNodeModulesRegex.MatchString(cmd):
+				install = nil
+			case installRegex.MatchString(cmd):
+				install, dir = inst, workdirs[s.Index()][i]
+			}
+		}
+	}
+	return install, dir
+}
+
+// copiesNodeModules returns true if a COPY instruction copies the node_modules installed in dir by the stage
+// it copies from, eg- "COPY --from=build /app/node_modules ./node_modules" or "COPY --from=build /app ."
+func copiesNodeModules(inst *dockerfile.Instruction, dir string) bool {
+	for _, src := range copySources(inst) {
+		src = strings.TrimSuffix(dockerfile.ResolvePath(dockerfile.RootDir, src), "/")
+		if src == "" || src == dockerfile.RootDir || src == dir || strings.HasPrefix(dir, src+"/") || path.Base(src) == "node_modules" {
+			return true
+		}
+	}
+	return false
+}
+
+// prunedLater returns true if a RUN instruction of the stage after the given line removes the devDependencies
+func prunedLater(stage *dockerfile.Stage, line int) bool {
+	for _, inst := range stage.Instructions() {
+		if inst.Cmd() != dockerfile.CmdRun || inst.StartLine() <= line {
+			continue
+		}
+		cmd := inst.Command()
+		omitsDev := omitDevRegex.MatchString(cmd) || inst.Expand("$NODE_ENV") == "production"
+		if (pruneRegex.MatchString(cmd) || installRegex.MatchString(cmd)) && omitsDev {
+			return true
+		}
+	}
+	return false
+}
+
+// examples returns the first names of the sorted list, followed by how many are left out
+func examples(names []string) string {
+	if len(names) <= maxExamples {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(names[:maxExamples], ", "), len(names)-maxExamples)
+}
+
+var ruleDevDependenciesCopiedIntoFinalStage = &Rule{
+	ID:          "DS032",
+	Name:        "devdependencies-copied-into-final-stage",
+	Description: "devDependencies are copied into the final stage from a stage that installed them.",
+	Severity:    models.SeverityHigh,
+	Goals:       []models.Goal{models.GoalSize, models.GoalSecurity},
+	Check: func(c *Context) []*models.Finding {
+		final := finalStage(c.Dockerfile)
+		if final == nil {
+			return nil
+		}
+		workdirs := dockerfile.Workdirs(c.Dockerfile.GetStages())
+		prune := "npm prune --omit=dev"
+		if c.ProjectDir != nil {
+			if manager := packagelock.DetectManager(c.ProjectDir); manager != nil {
+				prune = strings.Join(manager.PruneCommand(), " ")
+			}
+		}
+
+		devDependencies := []string{}
+		if c.PackageJSON != nil {
+			for name := range c.PackageJSON.GetDevDependencies() {
+				devDependencies = append(devDependencies, name)
+			}
+			sort.Strings(devDependencies)
+		}
+		var devOnly []*packagelock.Package
+		if lock := c.lockfile(); lock != nil {
+			devOnly = lock.DevOnly(c.productionDependencies())
+		}
+
+		findings := []*models.Finding{}
+		for _, inst := range final.Instructions() {
+			from, ok := inst.Flag("from")
+			if inst.Cmd() != dockerfile.CmdCopy || !ok {
+				continue
+			}
+			source := c.Dockerfile.GetStage(from)
+			if source == nil || source.Index() >= final.Index() {
+				continue
+			}
+			install, dir := c.devInstall(source, workdirs)
+			if install == nil || !copiesNodeModules(inst, dir) || prunedLater(final, inst.StartLine()) {
+				continue
+			}
+
+			packages := "the devDependencies"
+			switch {
+			case len(devOnly) > 0 && len(devDependencies) > 0:
+				packages = fmt.Sprintf("the devDependencies (%s) and what only they depend on, %d packages of the lockfile in total,", examples(devDependencies), len(devOnly))
+			case len(devDependencies) > 0:
+				packages = fmt.Sprintf("the devDependencies (%s)", examples(devDependencies))
+			}
+			findings = append(findings, &models.Finding{
+				Filepath: c.DockerfilePath,
+				Line:     inst.StartLine(),
+				Title:    "devDependencies are copied into the final image",
+				Description: fmt.Sprintf("%s installs %s on line %d and they're still in node_modules when the final stage copies it, but the application doesn't need them at runtime. Remove them with '%s' at the end of the stage, after building, or install only the production dependencies in a separate stage and copy node_modules from there.",
+					stageLabel(source), packages, install.StartLine(), prune),
+				EstimatedSizeImpact: c.packagesSize(devOnly, devDependencies),
+			})
+		}
+		return findings
+	},
+}
+
+// productionInstall returns the instruction that installs or keeps only the production dependencies the final
+// image ends up with, nil if the final image doesn't install them that way
+func (c *Context) productionInstall(final *dockerfile.Stage) *dockerfile.Instruction {
+	for _, stage := range DependencyStages(c, final) {
+		for _, s := range c.stageChain(stage) {
+			production := false
+			for _, inst := range stageInstructions(c.Dockerfile, s) {
+				if inst.Cmd() == dockerfile.CmdEnv || inst.Cmd() == dockerfile.CmdArg {
+					if v, ok := envValue(inst, "NODE_ENV"); ok {
+						production = v == "production"
+					}
+					continue
+				}
+				cmd := inst.Command()
+				omitsDev := production || omitDevRegex.MatchString(cmd) || inst.Expand("$NODE_ENV") == "production"
+				if inst.Cmd() == dockerfile.CmdRun && (installRegex.MatchString(cmd) || pruneRegex.MatchString(cmd)) && omitsDev {
+					return inst
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// runtimeCommands returns the commands that start the application: the start script and main file of
+// package.json, and the CMD, ENTRYPOINT and ENV instructions of the final image, eg- NODE_OPTIONS
+func (c *Context) runtimeCommands(final *dockerfile.Stage) []string {
+	words := []string{c.PackageJSON.GetScript("start"), c.PackageJSON.GetMain()}
+	for _, s := range c.stageChain(final) {
+		for _, inst := range stageInstructions(c.Dockerfile, s) {
+			switch inst.Cmd() {
+			case dockerfile.CmdCmd, dockerfile.CmdEntrypoint, dockerfile.CmdEnv:
+				words = append(words, inst.Args()...)
+			}
+		}
+	}
+	return strings.Fields(strings.Join(words, " "))
+}
+
+// startsWith returns true if the application is started with the package, eg- "node -r dotenv/config index.js"
+func startsWith(commands []string, pkg string) bool {
+	for _, w := range commands {
+		w = strings.Trim(w, `"'`)
+		if w == pkg || strings.HasPrefix(w, pkg+"/") || strings.HasPrefix(w, "--require="+pkg) || strings.HasPrefix(w, "--import="+pkg) {
+			return true
+		}
+	}
+	return false
+}
+
+var ruleDevOnlyProductionDependency = &Rule{
+	ID:          "DS033",
+	Name:        "dev-only-production-dependency",
+	Description: "Production dependencies are only imported by tests and build tooling. Move them to devDependencies so that they're left out of the final image.",
+	Severity:    models.SeverityMedium,
+	Goals:       []models.Goal{models.GoalSize},
+	Check: func(c *Context) []*models.Finding {
+		final := finalStage(c.Dockerfile)
+		if final == nil || c.ProjectDir == nil || c.PackageJSON == nil {
+			return nil
+		}
+		install := c.productionInstall(final)
+		if install == nil {
+			return nil
+		}
+		usage, err := imports.Scan(c.ProjectDir)
+		if err != nil {
+			return nil
+		}
+		commands := c.runtimeCommands(final)
+		production := c.productionDependencies()
+		misfiled := map[string]bool{}
+		for name := range c.PackageJSON.GetDependencies() {
+			if usage.DevOnly(name) && !startsWith(commands, name) {
+				misfiled[name] = true
+			}
+		}
+		if len(misfiled) == 0 {
+			return nil
+		}
+
+		// packages other production dependencies depend on are installed anyway
+		var freed []*packagelock.Package
+		if lock := c.lockfile(); lock != nil {
+			kept := []string{}
+			for _, name := range production {
+				if !misfiled[name] {
+					kept = append(kept, name)
+				}
+			}
+			needed := map[string]bool{}
+			for _, p := range lock.Closure(kept) {
+				needed[p.Path] = true
+			}
+			for name := range misfiled {
+				if needed["node_modules/"+name] {
+					delete(misfiled, name)
+				}
+			}
+			for _, p := range lock.Closure(production) {
+				if !needed[p.Path] {
+					freed = append(freed, p)
+				}
+			}
+		}
+		names := []string{}
+		for name := range misfiled {
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			return nil
+		}
+		sort.Strings(names)
+
+		listed := []string{}
+		for _, name := range names {
+			listed = append(listed, fmt.Sprintf("%s (imported by %s)", name, examples(usage[name])))
+		}
+		installed := "they're installed"
+		if len(freed) > len(names) {
+			installed = fmt.Sprintf("they're installed along with the %d other packages only they depend on", len(freed)-len(names))
+		}
+		return []*models.Finding{{
+			Filepath: c.DockerfilePath,
+			Line:     install.StartLine(),
+			Title:    "Production dependencies are only used by tests and build tooling",
+			Description: fmt.Sprintf("%s are listed under dependencies in package.json, but only tests and the configuration of build tools import them. The production install on line %d still puts them in the final image: %s. Move these %d package(s) to devDependencies.",
+				strings.Join(listed, ", "), install.StartLine(), installed, len(names)),
+			EstimatedSizeImpact: c.packagesSize(freed, names),
+		}}
+	},
+}
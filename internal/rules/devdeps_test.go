@@ -0,0 +1,218 @@
+package rules
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/packagejson"
+)
+
+const devLockfile = `{
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "app", "dependencies": {"express": "^4", "jest": "^29", "dotenv": "^16"}, "devDependencies": {"typescript": "^5"}},
+    "node_modules/express": {"version": "4.21.0", "dependencies": {"qs": "6.13.0"}},
+    "node_modules/qs": {"version": "6.13.0"},
+    "node_modules/dotenv": {"version": "16.4.5"},
+    "node_modules/jest": {"version": "29.7.0", "dependencies": {"jest-cli": "29.7.0"}},
+    "node_modules/jest-cli": {"version": "29.7.0", "dependencies": {"yargs": "^17"}},
+    "node_modules/yargs": {"version": "17.7.2"},
+    "node_modules/typescript": {"version": "5.6.2", "dev": true}
+  }
+}`
+
+var packageCountRegex = regexp.MustCompile(`\d+ packages`)
+
+const devPackageJSON = `{"main": "dist/index.js", "scripts": {"start": "node -r dotenv/config dist/index.js"}, "dependencies": {"express": "^4", "jest": "^29", "dotenv": "^16"}, "devDependencies": {"typescript": "^5"}}`
+
+func TestRun_DevDependenciesCopiedIntoFinalStage(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected []string
+	}{
+		{
+			name: "node_modules copied from the build stage",
+			code: `FROM node:22 AS build
+WORKDIR /app
+COPY package*.json ./
+RUN npm ci
+COPY . .
+RUN npm run build
+
+FROM node:22-slim
+WORKDIR /app
+COPY --from=build /app/node_modules ./node_modules
+COPY --from=build /app/dist ./dist
+CMD ["node", "dist/index.js"]
+`,
+			expected: []string{"DS032:10:4 packages"},
+		},
+		{
+			name: "working directory copied from a stage built on the build stage",
+			code: `FROM node:22 AS deps
+WORKDIR /app
+RUN npm install
+
+FROM deps AS build
+RUN npm run build
+
+FROM node:22-slim
+COPY --from=build /app /app
+`,
+			expected: []string{"DS032:9:4 packages"},
+		},
+		{
+			name: "pruned after building",
+			code: `FROM node:22 AS build
+WORKDIR /app
+RUN npm ci
+RUN npm run build && npm prune --omit=dev
+
+FROM node:22-slim
+COPY --from=build /app/node_modules /app/node_modules
+`,
+			expected: []string{},
+		},
+		{
+			name: "production install in the build stage",
+			code: `FROM node:22 AS deps
+WORKDIR /app
+ENV NODE_ENV=production
+RUN npm ci
+
+FROM node:22-slim
+COPY --from=deps /app/node_modules /app/node_modules
+`,
+			expected: []string{},
+		},
+		{
+			name: "only the build output is copied",
+			code: `FROM node:22 AS build
+WORKDIR /app
+RUN npm ci && npm run build
+
+FROM node:22-slim
+COPY --from=build /app/dist /app/dist
+`,
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.code)
+			if err != nil {
+				t.Fatalf("failed to parse Dockerfile: %v", err)
+			}
+			c := &Context{
+				Dockerfile:     df,
+				DockerfilePath: "Dockerfile",
+				ProjectDir:     fstest.MapFS{"package-lock.json": {Data: []byte(devLockfile)}},
+			}
+			// the application only depends on express and dotenv
+			if c.PackageJSON, err = packagejson.NewPackageJSON(`{"dependencies": {"express": "^4", "dotenv": "^16"}, "devDependencies": {"jest": "^29", "typescript": "^5"}}`); err != nil {
+				t.Fatal(err)
+			}
+
+			found := []string{}
+			for _, f := range Run(c, models.GoalAll) {
+				if f.Code != "DS032" {
+					continue
+				}
+				found = append(found, fmt.Sprintf("%s:%d:%s", f.Code, f.Line, packageCountRegex.FindString(f.Description)))
+				if !strings.Contains(f.Description, "npm prune --omit=dev") {
+					t.Errorf("expected the fix to prune with npm, got %q", f.Description)
+				}
+			}
+			if !reflect.DeepEqual(found, tt.expected) {
+				t.Errorf("expected findings %v, got %v", tt.expected, found)
+			}
+		})
+	}
+}
+
+func TestRun_DevOnlyProductionDependency(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		files    fstest.MapFS
+		expected string
+	}{
+		{
+			name: "test runner listed as a production dependency",
+			code: `FROM node:22-slim
+WORKDIR /app
+COPY package*.json ./
+RUN npm ci --omit=dev
+COPY dist ./dist
+CMD ["npm", "start"]
+`,
+			files: fstest.MapFS{
+				"src/index.ts":      {Data: []byte("import express from 'express'\n")},
+				"src/index.test.ts": {Data: []byte("import { expect } from 'jest'\nimport 'dotenv/config'\n")},
+			},
+			expected: "4:jest (imported by src/index.test.ts):2 other packages",
+		},
+		{
+			name: "dependencies installed with the devDependencies",
+			code: `FROM node:22-slim
+COPY . .
+RUN npm ci
+`,
+			files: fstest.MapFS{
+				"src/index.test.ts": {Data: []byte("import { expect } from 'jest'\n")},
+			},
+		},
+		{
+			name: "dependencies imported at runtime",
+			code: `FROM node:22-slim
+ENV NODE_ENV=production
+COPY . .
+RUN npm ci
+`,
+			files: fstest.MapFS{
+				"src/index.ts":      {Data: []byte("import express from 'express'\nimport { run } from 'jest'\n")},
+				"src/index.test.ts": {Data: []byte("import { expect } from 'jest'\n")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.code)
+			if err != nil {
+				t.Fatalf("failed to parse Dockerfile: %v", err)
+			}
+			tt.files["package-lock.json"] = &fstest.MapFile{Data: []byte(devLockfile)}
+			c := &Context{Dockerfile: df, DockerfilePath: "Dockerfile", ProjectDir: tt.files}
+			if c.PackageJSON, err = packagejson.NewPackageJSON(devPackageJSON); err != nil {
+				t.Fatal(err)
+			}
+
+			found := ""
+			for _, f := range Run(c, models.GoalAll) {
+				if f.Code != "DS033" {
+					continue
+				}
+				listed, _, _ := strings.Cut(f.Description, " are listed")
+				_, rest, _ := strings.Cut(f.Description, "along with the ")
+				others, _, _ := strings.Cut(rest, " only they")
+				found = fmt.Sprintf("%d:%s:%s", f.Line, listed, others)
+				// the count is kept out of the title, so that baselines keep matching when packages are added or removed
+				if count := strings.Count(listed, "(imported by"); f.Title != "Production dependencies are only used by tests and build tooling" || !strings.Contains(f.Description, fmt.Sprintf("Move these %d package(s)", count)) {
+					t.Errorf("expected a fixed title and the %d package(s) in the description, got %q: %q", count, f.Title, f.Description)
+				}
+			}
+			if found != tt.expected {
+				t.Errorf("expected finding %q, got %q", tt.expected, found)
+			}
+		})
+	}
+}
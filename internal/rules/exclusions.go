@@ -0,0 +1,263 @@
+package rules
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/units"
+)
+
+// nonRuntimeDirs are the directories projects keep files in that applications don't load at runtime,
+// along with what they hold
+var nonRuntimeDirs = map[string]string{
+	"test":             "tests",
+	"tests":            "tests",
+	"__tests__":        "tests",
+	"spec":             "tests",
+	"specs":            "tests",
+	"e2e":              "tests",
+	"cypress":          "tests",
+	"__mocks__":        "tests",
+	"fixtures":         "test fixtures",
+	"__fixtures__":     "test fixtures",
+	"testdata":         "test fixtures",
+	"coverage":         "test coverage reports",
+	".nyc_output":      "test coverage reports",
+	"docs":             "documentation",
+	"doc":              "documentation",
+	"examples":         "examples",
+	"example":          "examples",
+	"benchmarks":       "benchmarks",
+	".storybook":       "storybook configuration",
+	"storybook-static": "a storybook build",
+	".github":          "CI configuration",
+	".gitlab":          "CI configuration",
+	".circleci":        "CI configuration",
+	".vscode":          "editor settings",
+	".idea":            "editor settings",
+}
+
+// videoExtensions are the extensions of video files, which are rarely served from the image itself
+var videoExtensions = map[string]bool{".mp4": true, ".mov": true, ".webm": true, ".avi": true, ".mkv": true, ".m4v": true, ".wmv": true}
+
+// matches commands that run the tests, which need the tests and their fixtures in the stage
+var testRunRegex = regexp.MustCompile(`\b(npm|yarn|pnpm|bun)\s+(run\s+)?test\b|\bjest\b|\bvitest\b|\bmocha\b|\bpytest\b|\bgo\s+test\b|\bcypress\s+run\b`)
+
+const (
+	// minExclusionSize is the size below which paths aren't worth excluding
+	minExclusionSize = 1 * MB
+	// maxExclusions is the number of the largest paths reported
+	maxExclusions = 10
+	// maxExclusionDepth is how deep the project is searched for the paths
+	maxExclusionDepth = 6
+)
+
+// exclusion is a path of the build context the application doesn't need at runtime
+type exclusion struct {
+	// pattern is the entry of .dockerignore that excludes it, eg- "tests" or "assets/*.mp4"
+	pattern string
+	// path is the directory, or one of the files the pattern matches
+	path string
+	what string
+	size int64
+	// files is the number of files the pattern matches, 0 for directories
+	files int
+}
+
+// exclusions returns the largest paths of the build context that hold files applications don't load at
+// runtime, eg- tests, docs and videos, largest first. Paths .dockerignore excludes and paths the Dockerfile
+// copies explicitly are left out, so are node_modules and .git, which DS001 and DS002 report.
+func (c *Context) exclusions() []*exclusion {
+	if c.ProjectDir == nil {
+		return nil
+	}
+	ignored := func(p string) bool {
+		return c.Dockerignore != nil && c.Dockerignore.Excludes(p)
+	}
+	negations := c.Dockerignore != nil && strings.Contains(c.Dockerignore.Raw(), "!")
+	found := []*exclusion{}
+	videos := map[string]*exclusion{}
+	_ = fs.WalkDir(c.ProjectDir, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == "." {
+			return nil
+		}
+		if ignored(p) {
+			// "!" patterns can add back the files of an excluded directory
+			if d.IsDir() && !negations {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			name := d.Name()
+			switch {
+			case contains(dockerignoreEntries, name) || name == "vendor":
+				return fs.SkipDir
+			case nonRuntimeDirs[name] != "":
+				if !c.copiesExplicitly(p) {
+					found = append(found, &exclusion{pattern: p, path: p, what: nonRuntimeDirs[name], size: c.size(p)})
+				}
+				return fs.SkipDir
+			case strings.Count(p, "/") >= maxExclusionDepth:
+				return fs.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(path.Ext(p))
+		if !videoExtensions[ext] || !d.Type().IsRegular() || c.copiesExplicitly(p) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		pattern := path.Join(path.Dir(p), "*"+path.Ext(p))
+		if videos[pattern] == nil {
+			videos[pattern] = &exclusion{pattern: pattern, path: p, what: "videos"}
+			found = append(found, videos[pattern])
+		}
+		videos[pattern].size += info.Size()
+		videos[pattern].files++
+		return nil
+	})
+
+	large := []*exclusion{}
+	for _, e := range found {
+		if e.size >= minExclusionSize {
+			large = append(large, e)
+		}
+	}
+	sort.SliceStable(large, func(i, j int) bool { return large[i].size > large[j].size })
+	if len(large) > maxExclusions {
+		large = large[:maxExclusions]
+	}
+	return large
+}
+
+// copiesExplicitly returns true if a COPY or ADD instruction names the path of the build context or a
+// path inside it as a source, eg- "COPY docs ./docs", which means the image needs it
+func (c *Context) copiesExplicitly(p string) bool {
+	for _, stage := range c.Dockerfile.GetStages() {
+		for _, inst := range stageInstructions(c.Dockerfile, stage) {
+			if !isCopyFromContext(inst) || c.copiedNamedContext(inst) != "" {
+				continue
+			}
+			for _, src := range copySources(inst) {
+				src = path.Clean(strings.TrimPrefix(src, "/"))
+				if src == p || strings.HasPrefix(src, p+"/") {
+					return true
+				}
+				if matched, _ := path.Match(src, p); matched && src != "*" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// finalCopyOf returns the COPY or ADD instruction of the final image that copies the path of the build context
+// along with a parent directory, eg- "COPY . .", nil if the path isn't copied into the final image
+func (c *Context) finalCopyOf(p string) *dockerfile.Instruction {
+	stage := finalStage(c.Dockerfile)
+	if stage == nil {
+		return nil
+	}
+	for _, s := range c.stageChain(stage) {
+		for _, inst := range stageInstructions(c.Dockerfile, s) {
+			if !isCopyFromContext(inst) || c.copiedNamedContext(inst) != "" {
+				continue
+			}
+			if copiesEverything(inst) {
+				return inst
+			}
+			for _, src := range copySources(inst) {
+				if src = path.Clean(strings.TrimPrefix(src, "/")); strings.HasPrefix(p, src+"/") {
+					return inst
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// runsTests returns true if a RUN instruction of the Dockerfile runs the tests
+func (c *Context) runsTests() bool {
+	for _, stage := range c.Dockerfile.GetStages() {
+		for _, inst := range stageInstructions(c.Dockerfile, stage) {
+			if inst.Cmd() == dockerfile.CmdRun && testRunRegex.MatchString(inst.Command()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+var ruleNonRuntimePathsInContext = &Rule{
+	ID:          "DS034",
+	Name:        "non-runtime-paths-in-build-context",
+	Description: "Paths the application doesn't need at runtime, eg- tests, docs and media, are sent to the build context or copied into the final image.",
+	Severity:    models.SeverityLow,
+	Goals:       []models.Goal{models.GoalSize, models.GoalBuildSpeed},
+	Check: func(c *Context) []*models.Finding {
+		dockerignorePath := c.DockerignorePath
+		if dockerignorePath == "" {
+			dockerignorePath = ".dockerignore"
+		}
+		testsRun := c.runsTests()
+
+		findings := []*models.Finding{}
+		for _, e := range c.exclusions() {
+			what := e.what
+			if e.files > 0 {
+				what = fmt.Sprintf("%d %s", e.files, e.what)
+			}
+			// the tests have to stay in the build context if a stage runs them
+			neededForBuild := testsRun && strings.Contains(e.what, "test")
+			copyInst := c.finalCopyOf(e.path)
+
+			switch {
+			case copyInst != nil && neededForBuild:
+				findings = append(findings, &models.Finding{
+					Filepath: c.DockerfilePath,
+					Line:     copyInst.StartLine(),
+					Title:    fmt.Sprintf("%s is copied into the final image", e.pattern),
+					Description: fmt.Sprintf("%s holds %s, %s the application doesn't load at runtime, and '%s' copies it into the final image. A stage runs the tests, so it can't be excluded from the build context; copy only the paths the application needs in the final stage instead, eg- 'COPY src ./src'.",
+						e.pattern, what, units.FormatBytes(e.size), copyInst.Original()),
+					EstimatedSizeImpact: e.size,
+				})
+			case copyInst != nil:
+				findings = append(findings, &models.Finding{
+					Filepath: dockerignorePath,
+					Title:    fmt.Sprintf("%s is copied into the final image", e.pattern),
+					Description: fmt.Sprintf("%s holds %s, %s the application doesn't load at runtime, and '%s' copies it into the final image. Add '%s' to .dockerignore, or copy only the paths the application needs in the final stage.",
+						e.pattern, what, units.FormatBytes(e.size), copyInst.Original(), e.pattern),
+					EstimatedSizeImpact: e.size,
+				})
+			case !neededForBuild:
+				findings = append(findings, &models.Finding{
+					Filepath: dockerignorePath,
+					Title:    fmt.Sprintf("%s is sent to the build context", e.pattern),
+					Description: fmt.Sprintf("%s holds %s, %s the build doesn't use, which is sent to the Docker daemon with every build. Add '%s' to .dockerignore to speed up builds.",
+						e.pattern, what, units.FormatBytes(e.size), e.pattern),
+				})
+			}
+		}
+		return findings
+	},
+}
@@ -0,0 +1,164 @@
+package rules
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/duaraghav8/dockershrink/internal/baseline"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/dockerignore"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+func TestRun_NonRuntimePathsInContext(t *testing.T) {
+	file := func(size int64) *fstest.MapFile {
+		return &fstest.MapFile{Data: make([]byte, size)}
+	}
+	project := fstest.MapFS{
+		"package.json":               {Data: []byte(`{}`)},
+		"src/index.js":               file(100),
+		"src/__tests__/app.test.js":  file(MB),
+		"tests/fixtures/large.json":  file(2 * MB),
+		"docs/guide.md":              file(3 * MB),
+		"examples/small.js":          file(100),
+		"assets/videos/intro.mp4":    file(MB),
+		"assets/videos/demo.MP4":     file(MB / 2),
+		"assets/videos/tutorial.mp4": file(MB / 2),
+		"node_modules/x/test/a.js":   file(5 * MB),
+	}
+
+	tests := []struct {
+		name         string
+		code         string
+		dockerignore string
+		expected     []string
+	}{
+		{
+			name: "whole context copied into the final image",
+			code: `FROM node:22-slim
+WORKDIR /app
+COPY . .
+CMD ["node", "src/index.js"]
+`,
+			dockerignore: "node_modules\n.git\n",
+			expected: []string{
+				".dockerignore:0:docs is copied into the final image",
+				".dockerignore:0:tests is copied into the final image",
+				".dockerignore:0:assets/videos/*.mp4 is copied into the final image",
+				".dockerignore:0:src/__tests__ is copied into the final image",
+			},
+		},
+		{
+			name: "excluded by .dockerignore",
+			code: `FROM node:22-slim
+COPY . .
+`,
+			dockerignore: "node_modules\n.git\ndocs\n**/__tests__\nassets\n!assets/videos/intro.mp4\n",
+			expected: []string{
+				".dockerignore:0:tests is copied into the final image",
+				".dockerignore:0:assets/videos/*.mp4 is copied into the final image",
+			},
+		},
+		{
+			name: "tests run in a build stage",
+			code: `FROM node:22 AS build
+WORKDIR /app
+COPY . .
+RUN npm ci && npm test
+
+FROM node:22-slim
+WORKDIR /app
+COPY --from=build /app/node_modules ./node_modules
+COPY src ./src
+CMD ["node", "src/index.js"]
+`,
+			dockerignore: "node_modules\n.git\n",
+			expected: []string{
+				".dockerignore:0:docs is sent to the build context",
+				".dockerignore:0:assets/videos/*.mp4 is sent to the build context",
+				"Dockerfile:9:src/__tests__ is copied into the final image",
+			},
+		},
+		{
+			name: "paths copied explicitly",
+			code: `FROM node:22-slim
+COPY src ./src
+COPY docs ./docs
+COPY assets/videos/intro.mp4 assets/videos/tutorial.mp4 ./assets/
+`,
+			dockerignore: "node_modules\n.git\n",
+			expected: []string{
+				".dockerignore:0:tests is sent to the build context",
+				".dockerignore:0:src/__tests__ is copied into the final image",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.code)
+			if err != nil {
+				t.Fatalf("failed to parse Dockerfile: %v", err)
+			}
+			c := &Context{
+				Dockerfile:       df,
+				DockerfilePath:   "Dockerfile",
+				Dockerignore:     dockerignore.NewDockerignore(tt.dockerignore),
+				DockerignorePath: ".dockerignore",
+				ProjectDir:       project,
+			}
+
+			found := []string{}
+			for _, f := range Run(c, models.GoalAll) {
+				if f.Code != "DS034" {
+					continue
+				}
+				found = append(found, fmt.Sprintf("%s:%d:%s", f.Filepath, f.Line, f.Title))
+			}
+			if !reflect.DeepEqual(found, tt.expected) {
+				t.Errorf("expected findings %v, got %v", tt.expected, found)
+			}
+		})
+	}
+}
+
+func TestRun_NonRuntimePathsInContextBaseline(t *testing.T) {
+	// the titles of the findings are fingerprinted by baselines, so they must not change along with the sizes
+	run := func(docsSize int64) []*models.Finding {
+		df, err := dockerfile.NewDockerfile("FROM node:22-slim\nCOPY . .\n")
+		if err != nil {
+			t.Fatalf("failed to parse Dockerfile: %v", err)
+		}
+		c := &Context{
+			Dockerfile:       df,
+			DockerfilePath:   "Dockerfile",
+			Dockerignore:     dockerignore.NewDockerignore("node_modules\n"),
+			DockerignorePath: ".dockerignore",
+			ProjectDir: fstest.MapFS{
+				"package.json":  {Data: []byte(`{}`)},
+				"docs/guide.md": {Data: make([]byte, docsSize)},
+			},
+		}
+		findings := []*models.Finding{}
+		for _, f := range Run(c, models.GoalAll) {
+			if f.Code == "DS034" {
+				findings = append(findings, f)
+			}
+		}
+		return findings
+	}
+
+	before := run(2 * MB)
+	if len(before) != 1 {
+		t.Fatalf("expected docs to be reported, got %v", before)
+	}
+	after := run(3 * MB)
+	if before[0].Description == after[0].Description {
+		t.Errorf("expected the description to have the new size, got %q", after[0].Description)
+	}
+	if kept, _ := baseline.New(before).Filter(after); len(kept) != 0 {
+		t.Errorf("expected the finding to stay in the baseline after docs grew, got %v", kept)
+	}
+}
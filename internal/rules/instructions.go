@@ -0,0 +1,311 @@
+package rules
+
+import (
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+const (
+	basisBuildContext    = "build context"
+	basisNamedContext    = "named build context"
+	basisSystemPackages  = "system packages"
+	basisNodeModules     = "node_modules in the build context"
+	basisNpmPackages     = "npm packages"
+	basisCopiedFromStage = "dependencies installed in stage"
+)
+
+var (
+	// splits a shell command into the commands it runs
+	shellSeparatorRegex = regexp.MustCompile(`&&|\|\||;|\n`)
+	// matches the variables assigned at the start of a command, eg- "DEBIAN_FRONTEND=noninteractive apt-get install"
+	assignmentRegex = regexp.MustCompile(`^(\w+=\S*\s+)+`)
+	// matches commands that install system packages, followed by the packages
+	systemInstallRegex = regexp.MustCompile(`^(apt-get|apt|yum|dnf|microdnf)\s+(.*\s)?install\s+(.*)$|^apk\s+(.*\s)?add\s+(.*)$`)
+	// matches commands that install the given npm packages rather than the project's dependencies
+	npmPackageInstallRegex = regexp.MustCompile(`^(npm\s+(install|i|add)|yarn\s+(global\s+)?add|pnpm\s+(add|install|i))\s+(.*)$`)
+	// flags of package managers that take a value as the next word
+	valueFlagRegex = regexp.MustCompile(`^(-t|--target-release|--virtual|-X|--repository|--setopt|--registry|--prefix|-C)$`)
+)
+
+// EstimateSizes estimates the number of bytes every instruction that's part of the final image adds to it,
+// without building the image. Files copied from the build context are measured, while installed packages
+// are estimated from the known sizes of common packages. Instructions that add nothing, or whose size
+// can't be estimated, are left out. The result is sorted by size, biggest first.
+func EstimateSizes(c *Context) []*models.InstructionSize {
+	stage := finalStage(c.Dockerfile)
+	if stage == nil {
+		return nil
+	}
+	sizes := []*models.InstructionSize{}
+	for _, s := range c.stageChain(stage) {
+		for _, inst := range stageInstructions(c.Dockerfile, s) {
+			size, basis := c.instructionSize(inst)
+			if size <= 0 {
+				continue
+			}
+			sizes = append(sizes, &models.InstructionSize{
+				Filepath:      c.DockerfilePath,
+				Line:          inst.StartLine(),
+				Instruction:   inst.Original(),
+				EstimatedSize: size,
+				Basis:         basis,
+			})
+		}
+	}
+	sort.SliceStable(sizes, func(i, j int) bool {
+		if sizes[i].EstimatedSize != sizes[j].EstimatedSize {
+			return sizes[i].EstimatedSize > sizes[j].EstimatedSize
+		}
+		return sizes[i].Line < sizes[j].Line
+	})
+	return sizes
+}
+
+// EstimateImageSize estimates the size of the final image: the compressed size of the image it's built on, if it's known,
+// plus what its instructions add according to sizes, which are the estimates of EstimateSizes.
+// 0 is returned if nothing could be estimated.
+func EstimateImageSize(c *Context, sizes []*models.InstructionSize) int64 {
+	var total int64
+	for _, s := range sizes {
+		total += s.EstimatedSize
+	}
+	stage := finalStage(c.Dockerfile)
+	if stage == nil {
+		return total
+	}
+	root := c.stageChain(stage)[0]
+	if !c.baseIsNamedContext(root) {
+		total += c.BaseImages.Size(root.BaseImage())
+	}
+	return total
+}
+
+// stageChain returns the stages whose filesystem the given stage is built on, starting with the stage itself
+func (c *Context) stageChain(stage *dockerfile.Stage) []*dockerfile.Stage {
+	chain := []*dockerfile.Stage{stage}
+	for base := c.Dockerfile.GetBaseStage(stage); base != nil && len(chain) <= int(c.Dockerfile.GetStageCount()); base = c.Dockerfile.GetBaseStage(base) {
+		chain = append(chain, base)
+	}
+	// the instructions of the earliest stage run first
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
+}
+
+// instructionSize estimates the bytes the instruction adds to the image and what the estimate is based on
+func (c *Context) instructionSize(inst *dockerfile.Instruction) (int64, string) {
+	switch inst.Cmd() {
+	case dockerfile.CmdCopy, dockerfile.CmdAdd:
+		return c.copySize(inst)
+	case dockerfile.CmdRun:
+		return c.runSize(inst)
+	}
+	return 0, ""
+}
+
+// copySize estimates the bytes copied by a COPY or ADD instruction
+func (c *Context) copySize(inst *dockerfile.Instruction) (int64, string) {
+	if named := c.copiedNamedContext(inst); named != "" {
+		var size int64
+		for _, src := range copySources(inst) {
+			size += c.sizeIn(named, src)
+		}
+		return size, basisNamedContext
+	}
+	if from, ok := inst.Flag("from"); ok {
+		// only the dependencies installed by another stage can be estimated, not what it builds
+		source := dockerfile.FindStage(c.Dockerfile.GetStages(), from)
+		if source == nil {
+			return 0, ""
+		}
+		for _, src := range copySources(inst) {
+			if strings.Contains(src, "node_modules") {
+				size, _ := c.stageInstallSize(source)
+				return size, basisCopiedFromStage + " " + from
+			}
+		}
+		return 0, ""
+	}
+
+	var size int64
+	for _, src := range copySources(inst) {
+		if remoteSourceRegex.MatchString(src) {
+			continue
+		}
+		size += c.contextSize(src)
+	}
+	return size, basisBuildContext
+}
+
+// contextSize returns the total size of the files a source of COPY matches in the build context.
+// The root of the context leaves out the entries of .dockerignore.
+func (c *Context) contextSize(src string) int64 {
+	src = path.Clean(strings.TrimPrefix(src, "/"))
+	if src == "." || src == "*" {
+		if c.ProjectDir == nil {
+			return 0
+		}
+		entries, err := fs.ReadDir(c.ProjectDir, ".")
+		if err != nil {
+			return 0
+		}
+		var size int64
+		for _, e := range entries {
+			if c.Dockerignore != nil && c.Dockerignore.Contains(e.Name()) {
+				continue
+			}
+			size += c.size(e.Name())
+		}
+		return size
+	}
+	if !strings.ContainsAny(src, "*?[") {
+		return c.size(src)
+	}
+	if c.ProjectDir == nil {
+		return 0
+	}
+	matches, err := fs.Glob(c.ProjectDir, src)
+	if err != nil {
+		return 0
+	}
+	var size int64
+	for _, m := range matches {
+		size += c.size(m)
+	}
+	return size
+}
+
+// runSize estimates the bytes installed by a RUN instruction. Only package installations are estimated,
+// the size of anything else a command creates can't be known without running it.
+func (c *Context) runSize(inst *dockerfile.Instruction) (int64, string) {
+	var system int64
+	var npm int64
+	npmBasis := ""
+	for _, command := range shellSeparatorRegex.Split(inst.Command(), -1) {
+		command = assignmentRegex.ReplaceAllString(strings.TrimSpace(command), "")
+		if m := systemInstallRegex.FindStringSubmatch(command); m != nil {
+			packages := m[3]
+			if strings.HasPrefix(command, "apk") {
+				packages = m[5]
+			}
+			for _, pkg := range packageArgs(packages) {
+				system += systemPackageSize(pkg)
+			}
+			continue
+		}
+		if m := npmPackageInstallRegex.FindStringSubmatch(command); m != nil && len(packageArgs(m[5])) > 0 {
+			for _, pkg := range packageArgs(m[5]) {
+				npm += npmPackageSize(pkg)
+			}
+			npmBasis = basisNpmPackages
+			continue
+		}
+		if installRegex.MatchString(command) {
+			size, basis := c.dependenciesSize(inst)
+			npm += size
+			npmBasis = basis
+		}
+	}
+
+	cmd := inst.Command()
+	mount, _ := inst.Flag("mount")
+	if strings.Contains(cmd, "apt-get update") && !strings.Contains(cmd, "/var/lib/apt/lists") && !strings.Contains(mount, "/var/lib/apt") {
+		system += aptListsSize
+	}
+	switch {
+	case npm > 0 && system > npm:
+		return system + npm, basisSystemPackages + " and " + npmBasis
+	case npm > 0 && system > 0:
+		return system + npm, npmBasis + " and " + basisSystemPackages
+	case npm > 0:
+		return npm, npmBasis
+	}
+	return system, basisSystemPackages
+}
+
+// dependenciesSize estimates the size of the project's dependencies installed by the RUN instruction.
+// node_modules in the build context is the most accurate measure, unless the installation leaves out
+// devDependencies, in which case the size is estimated from the dependencies in package.json.
+func (c *Context) dependenciesSize(inst *dockerfile.Instruction) (int64, string) {
+	production := omitDevRegex.MatchString(inst.Command()) || inst.Expand("$NODE_ENV") == "production"
+	if !production {
+		if size := c.size("node_modules"); size > 0 {
+			return size, basisNodeModules
+		}
+	}
+	if c.PackageJSON == nil {
+		return 0, ""
+	}
+	var size int64
+	for name := range c.PackageJSON.GetDependencies() {
+		size += npmPackageSize(name)
+	}
+	if !production {
+		for name := range c.PackageJSON.GetDevDependencies() {
+			size += npmPackageSize(name)
+		}
+	}
+	return size, basisNpmPackages
+}
+
+// stageInstallSize estimates the size of the dependencies installed by the stage and the stages it's built from
+func (c *Context) stageInstallSize(stage *dockerfile.Stage) (int64, string) {
+	var size int64
+	basis := ""
+	for _, s := range c.stageChain(stage) {
+		for _, inst := range stageInstructions(c.Dockerfile, s) {
+			if inst.Cmd() == dockerfile.CmdRun && installRegex.MatchString(inst.Command()) {
+				n, b := c.dependenciesSize(inst)
+				size += n
+				basis = b
+			}
+		}
+	}
+	return size, basis
+}
+
+// packageArgs returns the packages in the arguments of an install command, leaving out its flags
+func packageArgs(args string) []string {
+	packages := []string{}
+	skip := false
+	for _, word := range strings.Fields(args) {
+		switch {
+		case skip:
+			skip = false
+		case valueFlagRegex.MatchString(word):
+			skip = true
+		case strings.HasPrefix(word, "-") || strings.ContainsAny(word, "$\\<>|"):
+		default:
+			packages = append(packages, word)
+		}
+	}
+	return packages
+}
+
+// systemPackageSize returns the approximate installed size of a system package, eg- "curl=7.88.1-10"
+func systemPackageSize(pkg string) int64 {
+	name, _, _ := strings.Cut(pkg, "=")
+	if size, ok := systemPackageSizes[name]; ok {
+		return size
+	}
+	return defaultSystemPackageSize
+}
+
+// npmPackageSize returns the approximate installed size of an npm package, eg- "typescript@5" or "@swc/core@1"
+func npmPackageSize(pkg string) int64 {
+	name := pkg
+	if i := strings.LastIndex(pkg, "@"); i > 0 {
+		name = pkg[:i]
+	}
+	if size, ok := npmPackageSizes[name]; ok {
+		return size
+	}
+	return defaultNpmPackageSize
+}
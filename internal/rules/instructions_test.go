@@ -0,0 +1,195 @@
+package rules
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/duaraghav8/dockershrink/internal/baseimages"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/dockerignore"
+	"github.com/duaraghav8/dockershrink/internal/packagejson"
+)
+
+func TestEstimateSizes(t *testing.T) {
+	projectDir := fstest.MapFS{
+		"package.json":            {Data: make([]byte, 100)},
+		"src/main.js":             {Data: make([]byte, 2*MB)},
+		"node_modules/a/index.js": {Data: make([]byte, 50*MB)},
+		".git/objects/pack":       {Data: make([]byte, 20*MB)},
+	}
+	packageJSON, err := packagejson.NewPackageJSON(`{"dependencies": {"express": "4", "puppeteer": "22"}, "devDependencies": {"typescript": "5"}}`)
+	if err != nil {
+		t.Fatalf("failed to parse package.json: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		dockerfile   string
+		dockerignore string
+		// expected estimates by line, in the expected order
+		lines []int
+		sizes map[int]int64
+		basis map[int]string
+	}{
+		{
+			name: "copies from the build context are measured",
+			dockerfile: `FROM node:20-alpine
+WORKDIR /app
+COPY package.json ./
+COPY src ./src
+CMD ["node", "src/main.js"]
+`,
+			lines: []int{4, 3},
+			sizes: map[int]int64{4: 2 * MB, 3: 100},
+			basis: map[int]string{4: basisBuildContext},
+		},
+		{
+			name: "copying the entire context leaves out what .dockerignore excludes",
+			dockerfile: `FROM node:20-alpine
+COPY . .
+`,
+			dockerignore: "node_modules\n.git\n",
+			lines:        []int{2},
+			sizes:        map[int]int64{2: 2*MB + 100},
+		},
+		{
+			name: "system packages and apt lists",
+			dockerfile: `FROM node:20
+RUN apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y --no-install-recommends curl unknown-pkg=1.2
+RUN apk add --no-cache --virtual .build-deps build-base
+`,
+			lines: []int{3, 2},
+			sizes: map[int]int64{
+				2: aptListsSize + systemPackageSizes["curl"] + defaultSystemPackageSize,
+				3: systemPackageSizes["build-base"],
+			},
+			basis: map[int]string{2: basisSystemPackages},
+		},
+		{
+			name: "node_modules in the build context measures a full installation",
+			dockerfile: `FROM node:20-alpine
+COPY package.json ./
+RUN npm ci
+`,
+			lines: []int{3, 2},
+			sizes: map[int]int64{3: 50 * MB},
+			basis: map[int]string{3: basisNodeModules},
+		},
+		{
+			name: "production installations are estimated from package.json",
+			dockerfile: `FROM node:20-alpine
+ENV NODE_ENV=production
+RUN npm ci && npm install -g pm2
+`,
+			lines: []int{3},
+			sizes: map[int]int64{3: defaultNpmPackageSize + npmPackageSizes["puppeteer"] + defaultNpmPackageSize},
+			basis: map[int]string{3: basisNpmPackages},
+		},
+		{
+			name: "only the final image is estimated",
+			dockerfile: `FROM node:20 AS build
+COPY src ./src
+RUN npm ci --omit=dev
+
+FROM node:20-alpine
+COPY --from=build /app/node_modules ./node_modules
+COPY --from=build /app/dist ./dist
+`,
+			lines: []int{6},
+			sizes: map[int]int64{6: defaultNpmPackageSize + npmPackageSizes["puppeteer"]},
+			basis: map[int]string{6: basisCopiedFromStage + " build"},
+		},
+		{
+			name: "stages built from another stage include its instructions",
+			dockerfile: `FROM node:20-alpine AS base
+COPY src ./src
+
+FROM base
+RUN apk add curl
+`,
+			lines: []int{5, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.dockerfile)
+			if err != nil {
+				t.Fatalf("failed to parse Dockerfile: %v", err)
+			}
+			c := &Context{
+				Dockerfile:     df,
+				DockerfilePath: "Dockerfile",
+				PackageJSON:    packageJSON,
+				ProjectDir:     projectDir,
+			}
+			if tt.dockerignore != "" {
+				c.Dockerignore = dockerignore.NewDockerignore(tt.dockerignore)
+			}
+
+			sizes := EstimateSizes(c)
+			if len(sizes) != len(tt.lines) {
+				t.Fatalf("expected %d estimates, got %d: %+v", len(tt.lines), len(sizes), sizes)
+			}
+			for i, s := range sizes {
+				if s.Line != tt.lines[i] {
+					t.Errorf("expected estimate %d to be for line %d, got %d", i, tt.lines[i], s.Line)
+				}
+				if want, ok := tt.sizes[s.Line]; ok && s.EstimatedSize != want {
+					t.Errorf("expected line %d to add %d bytes, got %d", s.Line, want, s.EstimatedSize)
+				}
+				if want, ok := tt.basis[s.Line]; ok && s.Basis != want {
+					t.Errorf("expected line %d to be estimated from %q, got %q", s.Line, want, s.Basis)
+				}
+			}
+		})
+	}
+}
+
+func TestEstimateImageSize(t *testing.T) {
+	projectDir := fstest.MapFS{"src/main.js": {Data: make([]byte, 2*MB)}}
+	matrix := baseimages.Builtin()
+
+	tests := []struct {
+		name       string
+		dockerfile string
+		// base is the image whose size is expected to be included, empty if none
+		base string
+	}{
+		{
+			name:       "known base image",
+			dockerfile: "FROM node:20-alpine\nCOPY src ./src\n",
+			base:       "node:20-alpine",
+		},
+		{
+			name:       "final stage built from another stage",
+			dockerfile: "FROM node:20-alpine AS base\nRUN echo hi\n\nFROM base\nCOPY src ./src\n",
+			base:       "node:20-alpine",
+		},
+		{
+			name:       "unknown base image",
+			dockerfile: "FROM example.com/acme/runtime:1\nCOPY src ./src\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.dockerfile)
+			if err != nil {
+				t.Fatalf("failed to parse Dockerfile: %v", err)
+			}
+			c := &Context{Dockerfile: df, DockerfilePath: "Dockerfile", ProjectDir: projectDir, BaseImages: matrix}
+
+			expected := int64(2 * MB)
+			if tt.base != "" {
+				base := matrix.Size(dockerfile.NewImage(tt.base))
+				if base == 0 {
+					t.Fatalf("size of %s is unknown", tt.base)
+				}
+				expected += base
+			}
+			if got := EstimateImageSize(c, EstimateSizes(c)); got != expected {
+				t.Errorf("EstimateImageSize() = %d, want %d", got, expected)
+			}
+		})
+	}
+}
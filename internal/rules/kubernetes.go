@@ -0,0 +1,264 @@
+package rules
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/distroless"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/kubernetes"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/units"
+)
+
+var (
+	// manifestShells are the shells a manifest can run its command with, which distroless images don't have
+	manifestShells = map[string]bool{"sh": true, "bash": true, "ash": true, "dash": true, "zsh": true}
+	// manifestPackageManagers run the scripts of package.json, they aren't part of distroless images
+	manifestPackageManagers = map[string]bool{"npm": true, "npx": true, "yarn": true, "pnpm": true}
+)
+
+// matches "apk add" commands installing bash, which alpine images don't come with
+var apkAddBashRegex = regexp.MustCompile(`\bapk\s+add\b[^;&|]*\sbash\b`)
+
+// oversizedImageRatio is how many times the memory a container gets the image may weigh before it's reported
+const oversizedImageRatio = 4
+
+// manifestCommand returns the command the container overrides the image's with: its command, or its args if
+// the image has no ENTRYPOINT, in which case they replace the CMD. nil is returned if the image's command is kept.
+func (c *Context) manifestCommand(container *kubernetes.Container) []string {
+	if len(container.Command) > 0 {
+		return container.Command
+	}
+	if len(container.Args) > 0 && c.finalInstruction(dockerfile.CmdEntrypoint) == nil {
+		return container.Args
+	}
+	return nil
+}
+
+// finalInstruction returns the last instruction of the given kind the final image ends up with, eg- its
+// ENTRYPOINT, nil if there's none
+func (c *Context) finalInstruction(cmd string) *dockerfile.Instruction {
+	stage := finalStage(c.Dockerfile)
+	if stage == nil {
+		return nil
+	}
+	var found *dockerfile.Instruction
+	for _, s := range c.stageChain(stage) {
+		for _, inst := range s.Instructions() {
+			if inst.Cmd() == cmd {
+				found = inst
+			}
+		}
+	}
+	return found
+}
+
+// installsBash returns true if a RUN instruction of the final image installs bash with apk
+func (c *Context) installsBash() bool {
+	stage := finalStage(c.Dockerfile)
+	if stage == nil {
+		return false
+	}
+	for _, s := range c.stageChain(stage) {
+		for _, inst := range stageInstructions(c.Dockerfile, s) {
+			if inst.Cmd() == dockerfile.CmdRun && apkAddBashRegex.MatchString(inst.Command()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// smallerBaseImage returns the image optimize builds the final stage from instead of the given heavy one,
+// nil if it doesn't change it
+func (c *Context) smallerBaseImage(base *dockerfile.Image) *dockerfile.Image {
+	if base.IsLightweight() || base.Name() == "scratch" {
+		return nil
+	}
+	if rec := c.BaseImages.Recommend(base, c.Platforms); rec != nil {
+		return dockerfile.NewImage(rec.Suggested)
+	}
+	if base.Name() == "node" {
+		return dockerfile.NewImage("node:alpine")
+	}
+	return nil
+}
+
+var ruleManifestEntrypointOverride = &Rule{
+	ID:          "DS035",
+	Name:        "manifest-entrypoint-override",
+	Description: "A Kubernetes manifest overrides the entrypoint of the image with an executable the final image doesn't have.",
+	Severity:    models.SeverityMedium,
+	Goals:       []models.Goal{models.GoalSize, models.GoalSecurity},
+	Check: func(c *Context) []*models.Finding {
+		stage := finalStage(c.Dockerfile)
+		if stage == nil || len(c.Manifests) == 0 {
+			return nil
+		}
+		root := c.stageChain(stage)[0]
+		if c.baseIsNamedContext(root) {
+			return nil
+		}
+		base := root.BaseImage()
+
+		findings := []*models.Finding{}
+		for _, container := range c.Manifests {
+			command := c.manifestCommand(container)
+			if len(command) == 0 {
+				continue
+			}
+			executable := path.Base(command[0])
+			run := strings.Join(command, " ")
+			finding := &models.Finding{
+				Filepath: container.Path,
+				Line:     container.Line,
+				Title:    fmt.Sprintf("%s runs the image with %s", capitalize(container.Describe()), executable),
+			}
+
+			switch {
+			case (manifestShells[executable] || manifestPackageManagers[executable]) && distroless.IsMinimal(base):
+				finding.Description = fmt.Sprintf("%s overrides the command of the image with '%s', but the final image is based on '%s', which has no shell or package manager, so the container fails to start. Run the application directly, eg- with the CMD of the Dockerfile.",
+					capitalize(container.Describe()), run, base.FullName())
+			case executable == "bash" && IsMusl(base) && !c.installsBash():
+				finding.Description = fmt.Sprintf("%s overrides the command of the image with '%s', but the final image is based on '%s', which comes with sh rather than bash, so the container fails to start. Use sh, or run the application directly.",
+					capitalize(container.Describe()), run, base.FullName())
+			case executable == "bash" && !IsMusl(base):
+				smaller := c.smallerBaseImage(base)
+				if smaller == nil || !IsMusl(smaller) {
+					continue
+				}
+				finding.Description = fmt.Sprintf("%s overrides the command of the image with '%s'. Optimizing the image moves its final stage from '%s' to '%s', which comes with sh rather than bash, so the container would fail to start. Use sh, or run the application directly, before deploying the optimized image.",
+					capitalize(container.Describe()), run, base.FullName(), smaller.FullName())
+			// alpine images can't be migrated to distroless ones, their binaries need musl
+			case (manifestShells[executable] || manifestPackageManagers[executable]) && !IsMusl(base):
+				finding.Description = fmt.Sprintf("%s overrides the command of the image with '%s'. Migrating the final stage to a distroless or chiseled image (optimize --migrate-to) removes the shell and the package manager, so the container would fail to start. Run the application directly, eg- '%s', so that the image can be migrated.",
+					capitalize(container.Describe()), run, c.directCommand())
+			default:
+				continue
+			}
+			findings = append(findings, finding)
+		}
+		return findings
+	},
+}
+
+// capitalize returns s with its first letter upper-cased, for descriptions of containers starting a sentence
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// directCommand returns an example of running the application without a shell or package manager
+func (c *Context) directCommand() string {
+	if c.PackageJSON != nil {
+		return "[node, server.js]"
+	}
+	return "[/app/server]"
+}
+
+var ruleManifestResourcesOversizedImage = &Rule{
+	ID:          "DS036",
+	Name:        "manifest-resources-oversized-image",
+	Description: "The image is large compared to the memory limit of the containers that run it, so pulling and starting it takes longer than it should.",
+	Severity:    models.SeverityLow,
+	Goals:       []models.Goal{models.GoalSize},
+	Check: func(c *Context) []*models.Finding {
+		stage := finalStage(c.Dockerfile)
+		if stage == nil || len(c.Manifests) == 0 {
+			return nil
+		}
+		size := EstimateImageSize(c, EstimateSizes(c))
+		root := c.stageChain(stage)[0]
+		var impact int64
+		suggestion := "Trim it with 'dockershrink optimize'."
+		if !c.baseIsNamedContext(root) {
+			base := root.BaseImage()
+			impact = lightweightVariantSavings(base, "alpine")
+			if rec := c.BaseImages.Recommend(base, c.Platforms); rec != nil {
+				suggestion = fmt.Sprintf("Start by building the final stage from '%s' instead of '%s'.", rec.Suggested, base.FullName())
+				// the savings of the recommendation are measured like the size of the image is estimated
+				if savings := rec.Savings(); savings > 0 {
+					impact = savings
+				}
+			}
+		}
+		impact = min(impact, size)
+
+		findings := []*models.Finding{}
+		for _, container := range c.Manifests {
+			memory := container.Memory()
+			if memory == 0 || size < oversizedImageRatio*memory {
+				continue
+			}
+			findings = append(findings, &models.Finding{
+				Filepath: container.Path,
+				Line:     container.Line,
+				Title:    fmt.Sprintf("The image is oversized for the memory of %s", container.Describe()),
+				Description: fmt.Sprintf("%s gets %s of memory, so the application is small, yet the image is estimated at %s, %d times as much. Every node the workload is scheduled on pulls it, which slows down scaling and rollouts. An image that much larger than what it runs usually carries a full distribution, build tools or dev dependencies. %s",
+					capitalize(container.Describe()), units.FormatBytes(memory), units.FormatBytes(size), size/memory, suggestion),
+				EstimatedSizeImpact: impact,
+			})
+		}
+		return findings
+	},
+}
+
+var ruleManifestMutableImageTag = &Rule{
+	ID:          "DS037",
+	Name:        "manifest-mutable-image-tag",
+	Description: "A Kubernetes manifest runs the latest tag of the image, so pods can run different versions of it.",
+	Severity:    models.SeverityMedium,
+	Goals:       []models.Goal{models.GoalSecurity},
+	Check: func(c *Context) []*models.Finding {
+		pinned := c.pinsBaseImages()
+		findings := []*models.Finding{}
+		for _, container := range c.Manifests {
+			image := dockerfile.NewImage(container.Image)
+			if image.Digest() != "" || image.Tag() != dockerfile.DefaultTag || strings.ContainsAny(container.Image, "${}") {
+				continue
+			}
+			// the tag of a Helm chart's image defaults to the appVersion of the chart
+			if container.HelmValues && !strings.HasSuffix(container.Image, dockerfile.NameTagSep+dockerfile.DefaultTag) {
+				continue
+			}
+			description := fmt.Sprintf("%s runs '%s', which is whatever the latest tag points to when a pod starts, so pods of the same workload can run different builds and rolling back doesn't bring back the previous image.",
+				capitalize(container.Describe()), container.Image)
+			if container.ImagePullPolicy == kubernetes.PullIfNotPresent {
+				description += " With imagePullPolicy IfNotPresent, every node keeps running the latest it pulled first, so which build runs depends on the node."
+			}
+			if pinned {
+				description += fmt.Sprintf(" The Dockerfile pins its base images by digest, deploy the image the same way, eg- '%s@sha256:<digest>'.", image.Name())
+			} else {
+				description += fmt.Sprintf(" Deploy a version tag or a digest, eg- '%s:<version>'.", image.Name())
+			}
+			findings = append(findings, &models.Finding{
+				Filepath:    container.Path,
+				Line:        container.Line,
+				Title:       fmt.Sprintf("%s runs the latest tag of its image", capitalize(container.Describe())),
+				Description: description,
+			})
+		}
+		return findings
+	},
+}
+
+// pinsBaseImages returns true if every stage of the Dockerfile built from an image pins it by digest
+func (c *Context) pinsBaseImages() bool {
+	pinned := false
+	for _, stage := range c.Dockerfile.GetStages() {
+		img := stage.BaseImage()
+		if img.Name() == "scratch" || c.Dockerfile.GetBaseStage(stage) != nil || c.baseIsNamedContext(stage) {
+			continue
+		}
+		if img.Digest() == "" {
+			return false
+		}
+		pinned = true
+	}
+	return pinned
+}
@@ -0,0 +1,198 @@
+package rules
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/baseimages"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/kubernetes"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+func TestRun_KubernetesManifests(t *testing.T) {
+	matrix := &baseimages.Matrix{Images: map[string]*baseimages.Image{
+		"node": {
+			Name:     "node",
+			Variants: []string{"alpine", "slim", ""},
+			Cycles:   []*baseimages.Cycle{{Version: "22", LTS: true}},
+			Tags: map[string]*baseimages.Tag{
+				"22":        {Size: 400 * MB},
+				"22-alpine": {Size: 50 * MB},
+			},
+		},
+	}}
+	container := func(image string, mutate func(c *kubernetes.Container)) *kubernetes.Container {
+		c := &kubernetes.Container{Path: "deploy/api.yaml", Line: 12, Workload: "Deployment/api", Name: "api", Image: image}
+		if mutate != nil {
+			mutate(c)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name      string
+		code      string
+		manifests []*kubernetes.Container
+		expected  []string
+	}{
+		{
+			name: "bash on an image optimize moves to alpine",
+			code: `FROM node:22
+CMD ["node", "server.js"]
+`,
+			manifests: []*kubernetes.Container{container("ghcr.io/acme/api:1.4.0", func(c *kubernetes.Container) {
+				c.Command = []string{"/bin/bash", "-c", "node server.js"}
+			})},
+			expected: []string{"DS035:deploy/api.yaml:12:Container api of Deployment/api runs the image with bash"},
+		},
+		{
+			name: "bash on alpine",
+			code: `FROM node:22-alpine
+CMD ["node", "server.js"]
+`,
+			manifests: []*kubernetes.Container{container("ghcr.io/acme/api:1.4.0", func(c *kubernetes.Container) {
+				c.Args = []string{"bash", "start.sh"}
+			})},
+			expected: []string{"DS035:deploy/api.yaml:12:Container api of Deployment/api runs the image with bash"},
+		},
+		{
+			name: "bash installed on alpine",
+			code: `FROM node:22-alpine
+RUN apk add --no-cache bash curl
+CMD ["node", "server.js"]
+`,
+			manifests: []*kubernetes.Container{container("ghcr.io/acme/api:1.4.0", func(c *kubernetes.Container) {
+				c.Command = []string{"bash", "start.sh"}
+			})},
+			expected: []string{},
+		},
+		{
+			name: "args passed to the entrypoint",
+			code: `FROM gcr.io/distroless/nodejs22-debian12
+ENTRYPOINT ["/nodejs/bin/node"]
+CMD ["server.js"]
+`,
+			manifests: []*kubernetes.Container{container("ghcr.io/acme/api:1.4.0", func(c *kubernetes.Container) {
+				c.Args = []string{"npm", "start"}
+			})},
+			expected: []string{},
+		},
+		{
+			name: "npm on distroless",
+			code: `FROM node:22-slim AS build
+RUN npm ci
+
+FROM gcr.io/distroless/nodejs22-debian12
+CMD ["server.js"]
+`,
+			manifests: []*kubernetes.Container{container("ghcr.io/acme/api:1.4.0", func(c *kubernetes.Container) {
+				c.Command = []string{"npm", "start"}
+			})},
+			expected: []string{"DS035:deploy/api.yaml:12:Container api of Deployment/api runs the image with npm"},
+		},
+		{
+			name: "shell blocking the migration to distroless",
+			code: `FROM node:22-slim
+CMD ["node", "server.js"]
+`,
+			manifests: []*kubernetes.Container{container("ghcr.io/acme/api:1.4.0", func(c *kubernetes.Container) {
+				c.Command = []string{"sh", "-c", "node server.js"}
+			})},
+			expected: []string{"DS035:deploy/api.yaml:12:Container api of Deployment/api runs the image with sh"},
+		},
+		{
+			name: "memory limit far below the image size",
+			code: `FROM node:22
+CMD ["node", "server.js"]
+`,
+			manifests: []*kubernetes.Container{
+				container("ghcr.io/acme/api:1.4.0", func(c *kubernetes.Container) {
+					c.Limits = map[string]string{"memory": "64Mi"}
+				}),
+				container("ghcr.io/acme/api:1.4.0", func(c *kubernetes.Container) {
+					c.Name = "worker"
+					c.Requests = map[string]string{"memory": "512Mi"}
+				}),
+			},
+			expected: []string{"DS036:deploy/api.yaml:12:The image is oversized for the memory of container api of Deployment/api"},
+		},
+		{
+			name: "latest tag",
+			code: `FROM node:22-slim
+CMD ["node", "server.js"]
+`,
+			manifests: []*kubernetes.Container{
+				container("ghcr.io/acme/api", nil),
+				container("ghcr.io/acme/api:latest", func(c *kubernetes.Container) { c.Name = "sidecar" }),
+				container("ghcr.io/acme/api@sha256:4f0e", func(c *kubernetes.Container) { c.Name = "pinned" }),
+				container("ghcr.io/acme/api", func(c *kubernetes.Container) {
+					c.Workload, c.Name, c.HelmValues = "values.yaml", "", true
+				}),
+			},
+			expected: []string{
+				"DS037:deploy/api.yaml:12:Container api of Deployment/api runs the latest tag of its image",
+				"DS037:deploy/api.yaml:12:Container sidecar of Deployment/api runs the latest tag of its image",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.code)
+			if err != nil {
+				t.Fatalf("failed to parse Dockerfile: %v", err)
+			}
+			c := &Context{Dockerfile: df, DockerfilePath: "Dockerfile", BaseImages: matrix, Manifests: tt.manifests}
+
+			found := []string{}
+			for _, f := range Run(c, models.GoalAll) {
+				switch f.Code {
+				case "DS035", "DS036", "DS037":
+					found = append(found, fmt.Sprintf("%s:%s:%d:%s", f.Code, f.Filepath, f.Line, f.Title))
+				}
+				// the sizes are kept out of the title, so that baselines keep matching when they change
+				if f.Code == "DS036" && !strings.Contains(f.Description, "estimated at 400.0 MB, 6 times as much") {
+					t.Errorf("expected the description to have the size of the image and its ratio to the memory, got %q", f.Description)
+				}
+			}
+			if !reflect.DeepEqual(found, tt.expected) {
+				t.Errorf("expected findings %v, got %v", tt.expected, found)
+			}
+		})
+	}
+}
+
+func TestRun_KubernetesMutableTagDescription(t *testing.T) {
+	tests := []struct {
+		code       string
+		pullPolicy string
+		expected   string
+	}{
+		{
+			code:     "FROM node:22-slim\n",
+			expected: "Container api of Deployment/api runs 'ghcr.io/acme/api:latest', which is whatever the latest tag points to when a pod starts, so pods of the same workload can run different builds and rolling back doesn't bring back the previous image. Deploy a version tag or a digest, eg- 'ghcr.io/acme/api:<version>'.",
+		},
+		{
+			code:       "FROM node:22-slim@sha256:8a3f\n",
+			pullPolicy: kubernetes.PullIfNotPresent,
+			expected:   "Container api of Deployment/api runs 'ghcr.io/acme/api:latest', which is whatever the latest tag points to when a pod starts, so pods of the same workload can run different builds and rolling back doesn't bring back the previous image. With imagePullPolicy IfNotPresent, every node keeps running the latest it pulled first, so which build runs depends on the node. The Dockerfile pins its base images by digest, deploy the image the same way, eg- 'ghcr.io/acme/api@sha256:<digest>'.",
+		},
+	}
+
+	for _, tt := range tests {
+		df, err := dockerfile.NewDockerfile(tt.code)
+		if err != nil {
+			t.Fatalf("failed to parse Dockerfile: %v", err)
+		}
+		c := &Context{Dockerfile: df, DockerfilePath: "Dockerfile", Manifests: []*kubernetes.Container{
+			{Path: "deploy/api.yaml", Workload: "Deployment/api", Name: "api", Image: "ghcr.io/acme/api:latest", ImagePullPolicy: tt.pullPolicy},
+		}}
+		findings := ruleManifestMutableImageTag.Check(c)
+		if len(findings) != 1 || findings[0].Description != tt.expected {
+			t.Errorf("expected a finding described as %q, got %v", tt.expected, findings)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package rules
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/packagelock"
+)
+
+var (
+	// nodeManagers are the package managers of nodejs projects
+	nodeManagers = map[string]bool{
+		packagelock.ManagerNPM: true, packagelock.ManagerYarn: true, packagelock.ManagerPNPM: true, packagelock.ManagerBun: true,
+	}
+	// elsewhereFlags make the package manager install the dependencies of another project or globally
+	elsewhereFlags = map[string]bool{
+		"--prefix": true, "-C": true, "--cwd": true, "--dir": true, "-g": true, "--global": true, "--filter": true,
+	}
+	// productionFlags leave out devDependencies, with any of the package managers
+	productionFlags = map[string]bool{
+		"--omit=dev": true, "--production": true, "--only=prod": true, "--only=production": true, "--prod": true, "-P": true,
+	}
+	// cosmeticFlags only change what the package manager prints or fetches, so they're left out when
+	// switching to another package manager
+	cosmeticFlags = map[string]bool{
+		"--no-audit": true, "--no-fund": true, "--silent": true, "-s": true, "--quiet": true, "-q": true,
+		"--non-interactive": true, "--no-progress": true, "--prefer-offline": true,
+	}
+)
+
+// UnfrozenInstall is a command that installs the dependencies of the project without enforcing its lockfile,
+// so the versions are resolved again when the lockfile is out of date with package.json, or ignored when the
+// lockfile is written by another package manager
+type UnfrozenInstall struct {
+	Command *Command
+	// Manager is the package manager run by the command, eg- "npm"
+	Manager string
+	// First is the index in Command.Args of the package manager
+	First int
+	// Frozen are the words of the command that installs the same dependencies from the lockfile, which replace
+	// Command.Args[First:]. It's empty if the options of the command have no equivalent, eg- with yarn 2.
+	Frozen []string
+}
+
+// UnfrozenInstalls returns the commands of the script that install the project's dependencies without enforcing
+// the lockfile of its package manager. Commands that add packages, install elsewhere or run after the script
+// changes directories are left out, since they don't install from the project's lockfile.
+func UnfrozenInstalls(script *Script, manager *packagelock.Manager) []*UnfrozenInstall {
+	installs := []*UnfrozenInstall{}
+	for _, c := range script.Commands() {
+		if c.Name() == "cd" || c.Name() == "pushd" {
+			break
+		}
+		if !nodeManagers[c.Name()] {
+			continue
+		}
+		argv := c.Argv()
+		flags, subcommand, extra := []string{}, "", 0
+		for _, a := range argv[1:] {
+			switch {
+			case strings.HasPrefix(a, "-"):
+				flags = append(flags, a)
+			case subcommand == "":
+				subcommand = a
+			default:
+				extra++
+			}
+		}
+		if extra > 0 || !installsFromLockfile(c.Name(), subcommand) || frozen(flags) {
+			continue
+		}
+		elsewhere := false
+		for _, f := range flags {
+			name, _, _ := strings.Cut(f, "=")
+			elsewhere = elsewhere || elsewhereFlags[name]
+		}
+		if elsewhere {
+			continue
+		}
+		installs = append(installs, &UnfrozenInstall{
+			Command: c,
+			Manager: c.Name(),
+			First:   len(c.Args) - len(argv),
+			Frozen:  frozenInstall(c.Name(), flags, manager),
+		})
+	}
+	return installs
+}
+
+// installsFromLockfile returns true if the subcommand of the package manager installs the dependencies
+// of package.json, eg- "npm install" or just "yarn"
+func installsFromLockfile(manager, subcommand string) bool {
+	switch subcommand {
+	case "install", "i":
+		return manager != packagelock.ManagerYarn || subcommand == "install"
+	case "":
+		return manager == packagelock.ManagerYarn
+	}
+	return false
+}
+
+// frozen returns true if the install command enforces the lockfile, or deliberately doesn't
+func frozen(flags []string) bool {
+	for _, f := range flags {
+		switch f {
+		case "--frozen-lockfile", "--immutable", "--no-frozen-lockfile", "--no-immutable", "--pure-lockfile", "--no-package-lock", "--no-save":
+			return true
+		}
+	}
+	return false
+}
+
+// frozenInstall returns the command that installs from the lockfile of the project's package manager with the
+// same options as the install command, empty if they can't be translated to the project's package manager
+func frozenInstall(command string, flags []string, manager *packagelock.Manager) []string {
+	words := manager.FrozenInstall()
+	if command == manager.Name {
+		return append(words, flags...)
+	}
+	if manager.Name == packagelock.ManagerBun {
+		// bun isn't part of the node images and can't be enabled with corepack
+		return nil
+	}
+	for _, f := range flags {
+		switch {
+		case cosmeticFlags[f]:
+		case productionFlags[f] && manager.ProductionFlag() != "":
+			if !isOneOf(manager.ProductionFlag(), words) {
+				words = append(words, manager.ProductionFlag())
+			}
+		default:
+			return nil
+		}
+	}
+	return words
+}
+
+// LockfileAvailable returns true if the lockfile is in the filesystem of the stage when the instruction runs:
+// it's copied from the build context by an earlier instruction of the stage or those it's built from, or
+// mounted by the instruction itself
+func LockfileAvailable(c *Context, stage *dockerfile.Stage, inst *dockerfile.Instruction, lockfile string) bool {
+	if c.Dockerignore != nil && c.Dockerignore.Contains(lockfile) {
+		return false
+	}
+	if mount, ok := inst.Flag("mount"); ok && strings.Contains(mount, "type=bind") && (strings.Contains(mount, "source="+lockfile) || !strings.Contains(mount, "source=")) {
+		return true
+	}
+	for _, s := range c.stageChain(stage) {
+		for _, i := range stageInstructions(c.Dockerfile, s) {
+			if s.Index() == stage.Index() && i.StartLine() >= inst.StartLine() {
+				break
+			}
+			if !isCopyFromContext(i) {
+				continue
+			}
+			if copiesEverything(i) {
+				return true
+			}
+			for _, src := range copySources(i) {
+				if ok, _ := path.Match(path.Base(path.Clean(src)), lockfile); ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+var ruleUnfrozenInstall = &Rule{
+	ID:          "DS031",
+	Name:        "install-ignores-lockfile",
+	Description: "A dependency installation doesn't enforce the lockfile, so the image can get different versions of the dependencies than the ones that were tested.",
+	Severity:    models.SeverityMedium,
+	Goals:       []models.Goal{models.GoalBuildSpeed, models.GoalSecurity},
+	Check: func(c *Context) []*models.Finding {
+		if c.ProjectDir == nil {
+			return nil
+		}
+		manager := packagelock.DetectManager(c.ProjectDir)
+		if manager == nil {
+			return nil
+		}
+		findings := []*models.Finding{}
+		for _, stage := range c.Dockerfile.GetStages() {
+			for _, inst := range stage.Instructions() {
+				if inst.Cmd() != dockerfile.CmdRun {
+					continue
+				}
+				script := runScript(inst)
+				if script == nil {
+					continue
+				}
+				for _, install := range UnfrozenInstalls(script, manager) {
+					command := strings.Join(install.Command.Argv(), " ")
+					fix := strings.Join(install.Frozen, " ")
+					if fix == "" {
+						fix = strings.Join(manager.FrozenInstall(), " ")
+					}
+					if manager.NeedsCorepack() && install.Manager != manager.Name {
+						fix = "corepack enable && " + fix
+					}
+					description := fmt.Sprintf("'%s' resolves the versions of the dependencies again when they don't match %s, so the image can end up with other versions than the ones the project was tested with. Use '%s', which installs exactly the versions of the lockfile and fails if it's out of date.", command, manager.Lockfile, fix)
+					if install.Manager != manager.Name {
+						description = fmt.Sprintf("The project is installed with %s, but '%s' ignores %s and resolves every version again, so the image can end up with other versions than the ones the project was tested with. Use '%s' instead.", manager.Name, command, manager.Lockfile, fix)
+					}
+					if !LockfileAvailable(c, stage, inst, manager.Lockfile) {
+						description += fmt.Sprintf(" Copy %s into the image before installing, eg- along with package.json.", manager.Lockfile)
+					}
+					findings = append(findings, &models.Finding{
+						Filepath:    c.DockerfilePath,
+						Line:        inst.StartLine(),
+						Title:       fmt.Sprintf("'%s' doesn't enforce %s", command, manager.Lockfile),
+						Description: description,
+					})
+				}
+			}
+		}
+		return findings
+	},
+}
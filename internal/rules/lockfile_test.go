@@ -0,0 +1,117 @@
+package rules
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/packagelock"
+)
+
+func TestUnfrozenInstalls(t *testing.T) {
+	npm := &packagelock.Manager{Name: packagelock.ManagerNPM, Lockfile: "package-lock.json"}
+	yarn := &packagelock.Manager{Name: packagelock.ManagerYarn, Lockfile: "yarn.lock"}
+	berry := &packagelock.Manager{Name: packagelock.ManagerYarn, Lockfile: "yarn.lock", Berry: true}
+	bun := &packagelock.Manager{Name: packagelock.ManagerBun, Lockfile: "bun.lockb"}
+	tests := []struct {
+		script   string
+		manager  *packagelock.Manager
+		expected []string
+	}{
+		{"npm install", npm, []string{"npm ci"}},
+		{"npm i --omit=dev --no-audit", npm, []string{"npm ci --omit=dev --no-audit"}},
+		{"npm ci && npm install -g pm2 && npm install lodash", npm, []string{}},
+		{"npm install --no-package-lock", npm, []string{}},
+		{"npm install --prefix client", npm, []string{}},
+		{"cd client && npm install", npm, []string{}},
+		{"sudo yarn --production", yarn, []string{"yarn install --frozen-lockfile --production"}},
+		{"yarn install --frozen-lockfile", yarn, []string{}},
+		{"npm install --production --no-fund", yarn, []string{"yarn install --frozen-lockfile --production"}},
+		{"npm install --legacy-peer-deps", yarn, []string{""}},
+		{"npm install --omit=dev", berry, []string{""}},
+		{"yarn install", berry, []string{"yarn install --immutable"}},
+		{"pnpm i --no-frozen-lockfile", npm, []string{}},
+		{"npm install", bun, []string{""}},
+		{"bun install", bun, []string{"bun install --frozen-lockfile"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.script, func(t *testing.T) {
+			script, err := ParseScript(tt.script)
+			if err != nil {
+				t.Fatalf("failed to parse script: %v", err)
+			}
+			found := []string{}
+			for _, install := range UnfrozenInstalls(script, tt.manager) {
+				found = append(found, strings.Join(install.Frozen, " "))
+			}
+			if !reflect.DeepEqual(found, tt.expected) {
+				t.Errorf("expected frozen installs %q, got %q", tt.expected, found)
+			}
+		})
+	}
+}
+
+func TestRun_UnfrozenInstall(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		files    fstest.MapFS
+		expected []string
+	}{
+		{
+			name: "npm install",
+			code: `FROM node:22-alpine
+COPY package.json package-lock.json ./
+RUN npm install --omit=dev
+`,
+			files:    fstest.MapFS{"package-lock.json": {Data: []byte("{}")}},
+			expected: []string{"DS031:3:'npm install --omit=dev' doesn't enforce package-lock.json"},
+		},
+		{
+			name: "other package manager",
+			code: `FROM node:22-alpine
+COPY . .
+RUN npm ci
+`,
+			files:    fstest.MapFS{"pnpm-lock.yaml": {Data: []byte("lockfileVersion: '9.0'\n")}},
+			expected: []string{},
+		},
+		{
+			name: "packageManager decides between lockfiles",
+			code: `FROM node:22-alpine
+COPY . .
+RUN yarn
+`,
+			files: fstest.MapFS{
+				"package.json":      {Data: []byte(`{"packageManager": "npm@10.8.2"}`)},
+				"yarn.lock":         {Data: []byte("# yarn lockfile v1\n")},
+				"package-lock.json": {Data: []byte("{}")},
+			},
+			expected: []string{"DS031:3:'yarn' doesn't enforce package-lock.json"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.code)
+			if err != nil {
+				t.Fatalf("failed to parse Dockerfile: %v", err)
+			}
+			c := &Context{Dockerfile: df, DockerfilePath: "Dockerfile", ProjectDir: tt.files}
+			found := []string{}
+			for _, f := range Run(c, models.GoalAll) {
+				if f.Code == "DS031" {
+					found = append(found, fmt.Sprintf("%s:%d:%s", f.Code, f.Line, f.Title))
+				}
+			}
+			if !reflect.DeepEqual(found, tt.expected) {
+				t.Errorf("expected findings %v, got %v", tt.expected, found)
+			}
+		})
+	}
+}
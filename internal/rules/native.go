@@ -0,0 +1,179 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/packagelock"
+)
+
+// NativeModules returns the native modules among the production dependencies of the application and their
+// dependencies, read from its npm lockfile. None are returned if the project doesn't have one.
+func NativeModules(c *Context) []*packagelock.NativeModule {
+	if c.ProjectDir == nil {
+		return nil
+	}
+	lock, err := packagelock.Read(c.ProjectDir)
+	if err != nil {
+		return nil
+	}
+	return lock.NativeModules()
+}
+
+// IsMusl returns true if the binaries of the image are linked against musl instead of glibc, eg- alpine images
+func IsMusl(image *dockerfile.Image) bool {
+	return strings.Contains(image.Name(), "alpine") || strings.Contains(image.Tag(), "alpine")
+}
+
+// DependencyStages returns the stages that install the npm dependencies the given stage ends up with: the
+// stages it's built on that run an install command, and those of the stages it copies files from
+func DependencyStages(c *Context, stage *dockerfile.Stage) []*dockerfile.Stage {
+	return c.dependencyStages(stage, map[uint]bool{})
+}
+
+func (c *Context) dependencyStages(stage *dockerfile.Stage, visited map[uint]bool) []*dockerfile.Stage {
+	stages := []*dockerfile.Stage{}
+	for _, s := range c.stageChain(stage) {
+		if visited[s.Index()] {
+			continue
+		}
+		visited[s.Index()] = true
+		for _, inst := range stageInstructions(c.Dockerfile, s) {
+			switch inst.Cmd() {
+			case dockerfile.CmdRun:
+				if installRegex.MatchString(inst.Command()) && !containsStage(stages, s) {
+					stages = append(stages, s)
+				}
+			case dockerfile.CmdCopy:
+				from, ok := inst.Flag("from")
+				if !ok {
+					continue
+				}
+				if source := c.Dockerfile.GetStage(from); source != nil && source.Index() < s.Index() {
+					stages = append(stages, c.dependencyStages(source, visited)...)
+				}
+			}
+		}
+	}
+	return stages
+}
+
+func containsStage(stages []*dockerfile.Stage, stage *dockerfile.Stage) bool {
+	for _, s := range stages {
+		if s.Index() == stage.Index() {
+			return true
+		}
+	}
+	return false
+}
+
+// NativeModulesBuiltOnGlibc returns the native modules the given final stage ends up with, unless all of them are
+// installed by other stages based on alpine, in which case they're built for musl already
+func NativeModulesBuiltOnGlibc(c *Context, final *dockerfile.Stage) []*packagelock.NativeModule {
+	native := NativeModules(c)
+	if len(native) == 0 {
+		return nil
+	}
+	stages := DependencyStages(c, final)
+	if len(stages) == 0 {
+		// node_modules, if any, is copied from the build context, which is hardly ever built on alpine
+		return native
+	}
+	for _, s := range stages {
+		if s.Index() == final.Index() || !IsMusl(c.stageChain(s)[0].BaseImage()) {
+			return native
+		}
+	}
+	return nil
+}
+
+// GlibcVariant returns the slim variant of the given node alpine image if the final stage can't be based on it
+// because the native modules it ends up with are built against glibc, while alpine uses musl, along with the
+// modules. nil is returned if the image can be used as it is.
+func GlibcVariant(c *Context, final *dockerfile.Stage, image *dockerfile.Image) (*dockerfile.Image, []*packagelock.NativeModule) {
+	if image.Name() != "node" || !IsMusl(image) {
+		return nil, nil
+	}
+	native := NativeModulesBuiltOnGlibc(c, final)
+	if len(native) == 0 {
+		return nil, nil
+	}
+	return dockerfile.NewImage("node" + dockerfile.NameTagSep + NodeSlimTag(image)), native
+}
+
+// NodeSlimTag returns the tag of the slim variant of the same release as the given node image,
+// eg- node:22-alpine -> node:22-slim (so "22-slim" is returned as response)
+func NodeSlimTag(image *dockerfile.Image) string {
+	tag := image.Tag()
+	if strings.Contains(tag, "slim") {
+		return tag
+	}
+	if tag == dockerfile.DefaultTag || strings.HasPrefix(tag, "alpine") {
+		return "slim"
+	}
+	// eg- 22-alpine3.20 = 22-slim
+	release, _, _ := strings.Cut(tag, "-alpine")
+	return release + "-slim"
+}
+
+// libcOf returns the name of the C library the binaries of the image are linked against
+func libcOf(image *dockerfile.Image) string {
+	if IsMusl(image) {
+		return "musl"
+	}
+	return "glibc"
+}
+
+var ruleNativeModuleLibcMismatch = &Rule{
+	ID:          "DS030",
+	Name:        "native-module-libc-mismatch",
+	Description: "Native modules built against one C library, glibc or musl, are copied into a final image based on the other, where they fail to load.",
+	Severity:    models.SeverityHigh,
+	Goals:       []models.Goal{models.GoalSize, models.GoalBuildSpeed, models.GoalSecurity},
+	Check: func(c *Context) []*models.Finding {
+		stage := finalStage(c.Dockerfile)
+		if stage == nil {
+			return nil
+		}
+		first := c.stageChain(stage)[0]
+		base := first.BaseImage()
+		if base.Name() == "scratch" || strings.Contains(base.FullName(), "$") || c.baseIsNamedContext(first) {
+			return nil
+		}
+		native := NativeModules(c)
+		if len(native) == 0 {
+			return nil
+		}
+		inFinal := map[uint]bool{}
+		for _, s := range c.stageChain(stage) {
+			inFinal[s.Index()] = true
+		}
+
+		findings := []*models.Finding{}
+		for _, s := range DependencyStages(c, stage) {
+			sourceFirst := c.stageChain(s)[0]
+			source := sourceFirst.BaseImage()
+			if inFinal[s.Index()] || strings.Contains(source.FullName(), "$") || c.baseIsNamedContext(sourceFirst) || IsMusl(source) == IsMusl(base) {
+				continue
+			}
+			findings = append(findings, &models.Finding{
+				Filepath: c.DockerfilePath,
+				Line:     stage.StartLine(),
+				Title:    fmt.Sprintf("Native modules installed on %s are copied into a final image based on %s", libcOf(source), libcOf(base)),
+				Description: fmt.Sprintf("%s load native code that's compiled or downloaded for the C library of the system they're installed on. %s installs them on '%s', which uses %s, but the final stage is based on '%s', which uses %s, so they fail to load when the application requires them. Build the dependencies on the same distribution as the final stage, eg- by basing both stages on the same variant of the image.",
+					strings.Join(packagelock.Names(native), ", "), stageLabel(s), source.FullName(), libcOf(source), base.FullName(), libcOf(base)),
+			})
+		}
+		return findings
+	},
+}
+
+// stageLabel refers to a stage by its name, or its position if it doesn't have one
+func stageLabel(stage *dockerfile.Stage) string {
+	if stage.Name() != "" {
+		return fmt.Sprintf("Stage '%s'", stage.Name())
+	}
+	return fmt.Sprintf("Stage %d", stage.Index()+1)
+}
@@ -0,0 +1,204 @@
+package rules
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/duaraghav8/dockershrink/internal/baseimages"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/packagejson"
+)
+
+const nativeLockfile = `{
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "app", "dependencies": {"bcrypt": "^5.1.1", "image-resizer": "^1"}},
+    "node_modules/bcrypt": {"version": "5.1.1", "hasInstallScript": true, "dependencies": {"@mapbox/node-pre-gyp": "^1.0.11", "node-addon-api": "^5.0.0"}},
+    "node_modules/image-resizer": {"version": "1.0.0", "dependencies": {"canvas": "^2"}},
+    "node_modules/canvas": {"version": "2.11.2", "hasInstallScript": true, "dependencies": {"@mapbox/node-pre-gyp": "^1.0.0", "nan": "^2.17.0"}}
+  }
+}`
+
+func TestRun_NativeModules(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		lockfile string
+		expected []string
+	}{
+		{
+			name: "modules built on debian copied into alpine",
+			code: `FROM node:22 AS deps
+WORKDIR /app
+COPY package.json package-lock.json ./
+RUN npm ci --omit=dev
+
+FROM node:22-alpine
+COPY --from=deps /app/node_modules /app/node_modules
+`,
+			lockfile: nativeLockfile,
+			expected: []string{"DS020:6:cairo", "DS020:6:giflib", "DS020:6:libjpeg", "DS020:6:pango", "DS030:6:glibc"},
+		},
+		{
+			name: "modules installed through another stage",
+			code: `FROM node:22-alpine AS deps
+RUN npm ci --omit=dev
+
+FROM node:22-alpine AS build
+COPY --from=deps /app/node_modules ./node_modules
+RUN npm run build
+
+FROM gcr.io/distroless/nodejs22-debian12
+COPY --from=build /app /app
+`,
+			lockfile: nativeLockfile,
+			expected: []string{"DS030:8:musl"},
+		},
+		{
+			name: "modules built on the same distribution",
+			code: `FROM node:22-slim AS deps
+RUN npm ci --omit=dev
+
+FROM node:22-slim
+COPY --from=deps /app/node_modules /app/node_modules
+`,
+			lockfile: nativeLockfile,
+			expected: []string{},
+		},
+		{
+			name: "no native modules",
+			code: `FROM node:22 AS deps
+RUN npm ci --omit=dev
+
+FROM node:22-alpine
+COPY --from=deps /app/node_modules /app/node_modules
+`,
+			lockfile: `{"lockfileVersion": 3, "packages": {"node_modules/express": {"version": "4.21.0"}}}`,
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.code)
+			if err != nil {
+				t.Fatalf("failed to parse Dockerfile: %v", err)
+			}
+			c := &Context{
+				Dockerfile:     df,
+				DockerfilePath: "Dockerfile",
+				ProjectDir:     fstest.MapFS{"package-lock.json": {Data: []byte(tt.lockfile)}},
+			}
+			if c.PackageJSON, err = packagejson.NewPackageJSON(`{"dependencies": {"bcrypt": "^5.1.1", "image-resizer": "^1"}}`); err != nil {
+				t.Fatal(err)
+			}
+
+			found := []string{}
+			for _, f := range Run(c, models.GoalAll) {
+				switch f.Code {
+				case "DS020":
+					for _, lib := range runtimeLibraries {
+						if strings.Contains(f.Title, lib.name) {
+							found = append(found, fmt.Sprintf("%s:%d:%s", f.Code, f.Line, lib.name))
+							break
+						}
+					}
+				case "DS030":
+					libc := "glibc"
+					if strings.HasPrefix(f.Title, "Native modules installed on musl") {
+						libc = "musl"
+					}
+					found = append(found, fmt.Sprintf("%s:%d:%s", f.Code, f.Line, libc))
+				}
+			}
+			sort.Strings(found)
+			if !reflect.DeepEqual(found, tt.expected) {
+				t.Errorf("expected findings %v, got %v", tt.expected, found)
+			}
+		})
+	}
+}
+
+func TestRun_HeavyFinalBaseImageNativeModules(t *testing.T) {
+	df, err := dockerfile.NewDockerfile(`FROM node:22 AS build
+RUN npm ci --omit=dev
+
+FROM node:22
+COPY --from=build /app /app
+`)
+	if err != nil {
+		t.Fatalf("failed to parse Dockerfile: %v", err)
+	}
+	lockfile := `{"lockfileVersion": 3, "packages": {"node_modules/sharp": {"version": "0.33.5", "hasInstallScript": true, "dependencies": {"node-addon-api": "^8.0.0"}}}}`
+
+	tests := []struct {
+		name       string
+		baseImages *baseimages.Matrix
+		expected   string
+	}{
+		{name: "known base images", baseImages: baseimages.Builtin(), expected: "Use 'node:22-slim' instead"},
+		{name: "unknown base images", expected: "Use a slim or distroless variant instead. The slim variant"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Context{
+				Dockerfile:     df,
+				DockerfilePath: "Dockerfile",
+				ProjectDir:     fstest.MapFS{"package-lock.json": {Data: []byte(lockfile)}},
+				BaseImages:     tt.baseImages,
+			}
+			f := findingRules(Run(c, models.GoalAll))["heavy-final-base-image"]
+			if f == nil {
+				t.Fatal("expected a finding for rule heavy-final-base-image")
+			}
+			if !strings.Contains(f.Description, tt.expected) || !strings.Contains(f.Description, "the native modules sharp are built against glibc") {
+				t.Errorf("expected the slim variant to be suggested because of sharp, got %q", f.Description)
+			}
+		})
+	}
+}
+
+func TestNodeSlimTag(t *testing.T) {
+	tests := []struct {
+		image    string
+		expected string
+	}{
+		{"node:22-alpine", "22-slim"},
+		{"node:22.7-alpine3.20", "22.7-slim"},
+		{"node:alpine", "slim"},
+		{"node:alpine3.20", "slim"},
+		{"node:latest", "slim"},
+		{"node:lts-bookworm", "lts-bookworm-slim"},
+		{"node:22-slim", "22-slim"}, // already slim
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			got := NodeSlimTag(dockerfile.NewImage(tt.image))
+			if got != tt.expected {
+				t.Errorf("NodeSlimTag(%q) = %q; want %q", tt.image, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMissingRuntimePackages(t *testing.T) {
+	df, err := dockerfile.NewDockerfile("FROM node:22-alpine\nRUN apk add --no-cache cairo\nCOPY . .\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Context{Dockerfile: df, ProjectDir: fstest.MapFS{"package-lock.json": {Data: []byte(nativeLockfile)}}}
+	packages, family := MissingRuntimePackages(c)
+	if family != familyApk {
+		t.Errorf("expected the apk family, got %s", family)
+	}
+	expected := []string{"pango", "libjpeg-turbo", "giflib"}
+	if !reflect.DeepEqual(packages, expected) {
+		t.Errorf("expected packages %v, got %v", expected, packages)
+	}
+}
@@ -0,0 +1,148 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/units"
+	"github.com/duaraghav8/dockershrink/internal/opa"
+	"github.com/duaraghav8/dockershrink/internal/policy"
+)
+
+// Codes of the findings of organization policies. They aren't rules: policies can't be turned off,
+// suppressed or given another severity from the project, and they're checked whatever the goal.
+const (
+	CodePolicyBaseImage         = "POL001"
+	CodePolicyRegistry          = "POL002"
+	CodePolicyUser              = "POL003"
+	CodePolicyBannedInstruction = "POL004"
+	CodePolicyImageSize         = "POL005"
+	// CodePolicyBundle is the code of the violations reported by the Rego policies of OPA bundles
+	CodePolicyBundle = "POL006"
+)
+
+// IsPolicyViolation returns true if the finding is a violation of an organization policy
+func IsPolicyViolation(f *models.Finding) bool {
+	return strings.HasPrefix(f.Code, "POL")
+}
+
+// CheckPolicies returns the violations of the organization policies of the context
+func CheckPolicies(c *Context) []*models.Finding {
+	findings := []*models.Finding{}
+	if len(c.Policies) == 0 {
+		return findings
+	}
+	finalStage, err := c.Dockerfile.GetFinalStage()
+	if err != nil {
+		return findings
+	}
+	var imageSize int64 = -1
+	for _, p := range c.Policies {
+		add := func(code, rule string, line int, title, description string) *models.Finding {
+			f := &models.Finding{
+				Rule:        rule,
+				Code:        code,
+				Severity:    p.Severity,
+				Filepath:    c.DockerfilePath,
+				Line:        line,
+				Title:       title,
+				Description: description + fmt.Sprintf(" It's required by the %s policy of your organization.", p.Name),
+			}
+			findings = append(findings, f)
+			return f
+		}
+
+		for _, stage := range c.Dockerfile.GetStages() {
+			image := stage.BaseImage()
+			if c.Dockerfile.GetBaseStage(stage) != nil || c.baseIsNamedContext(stage) || image.Name() == "scratch" || strings.Contains(image.FullName(), "$") {
+				continue
+			}
+			if !p.AllowsBaseImage(image) {
+				add(CodePolicyBaseImage, "policy-base-image", stage.StartLine(),
+					fmt.Sprintf("Base image %s isn't approved", image.FullName()),
+					fmt.Sprintf("Build the stage from one of the approved base images: %s.", strings.Join(p.AllowedBaseImages, ", ")))
+			}
+			if !p.AllowsRegistry(image) {
+				add(CodePolicyRegistry, "policy-registry", stage.StartLine(),
+					fmt.Sprintf("Base image %s is pulled from an unapproved registry", image.FullName()),
+					fmt.Sprintf("%s isn't in one of the approved registries: %s.", policy.Repository(image), strings.Join(p.AllowedRegistries, ", ")))
+			}
+			for _, inst := range stage.Instructions() {
+				if b := p.Bans(inst); b != nil {
+					description := fmt.Sprintf("%s isn't allowed", b.Instruction)
+					if b.Arguments != "" {
+						description += fmt.Sprintf(" with arguments matching %s", b.Arguments)
+					}
+					description += "."
+					if b.Reason != "" {
+						description += " " + b.Reason
+					}
+					add(CodePolicyBannedInstruction, "policy-banned-instruction", inst.StartLine(),
+						fmt.Sprintf("%s instruction is banned", b.Instruction), description)
+				}
+			}
+		}
+
+		if user := c.finalUser(finalStage); !p.AllowsUser(user) {
+			title, description := "The final stage runs as root", "Run the final stage as an unprivileged user with a USER instruction, eg- 'USER node' for the official node images."
+			if p.RequiredUser != policy.UserNonRoot {
+				title = fmt.Sprintf("The final stage doesn't run as the user %s", p.RequiredUser)
+				description = fmt.Sprintf("Switch to the user %s with 'USER %s' in the final stage.", p.RequiredUser, p.RequiredUser)
+			}
+			if user != "" && p.RequiredUser != policy.UserNonRoot {
+				title = fmt.Sprintf("The final stage runs as %s instead of %s", user, p.RequiredUser)
+			}
+			add(CodePolicyUser, "policy-user", finalStage.StartLine(), title, description)
+		}
+
+		if limit := p.MaxImageSizeBytes(); limit > 0 {
+			if imageSize < 0 {
+				imageSize = EstimateImageSize(c, EstimateSizes(c))
+			}
+			// an image whose size can't be estimated isn't known to be too big
+			if imageSize > limit {
+				f := add(CodePolicyImageSize, "policy-image-size", 0,
+					"The image is bigger than allowed",
+					fmt.Sprintf("The image is estimated at %s, above the maximum of %s.", units.FormatBytes(imageSize), p.MaxImageSize))
+				f.EstimatedSizeImpact = imageSize - limit
+			}
+		}
+	}
+	return findings
+}
+
+// PolicyBundleFinding returns the finding of a violation reported by the Rego policies of an OPA bundle
+func PolicyBundleFinding(dockerfilePath string, v *opa.Violation) *models.Finding {
+	return &models.Finding{
+		Rule:        "policy-bundle",
+		Code:        CodePolicyBundle,
+		Severity:    v.Severity,
+		Filepath:    dockerfilePath,
+		Line:        v.Line,
+		Title:       v.Message,
+		Description: fmt.Sprintf("Reported by the %s rule of the %s policy bundle of your organization.", v.Rule, v.Bundle),
+	}
+}
+
+// finalUser returns the user the final stage runs as, empty if it's root because no USER instruction sets it.
+// Images whose tag says they run as an unprivileged user, eg- the nonroot distroless images, count as nonroot.
+func (c *Context) finalUser(stage *dockerfile.Stage) string {
+	chain := c.stageChain(stage)
+	for i := len(chain) - 1; i >= 0; i-- {
+		instructions := chain[i].Instructions()
+		for j := len(instructions) - 1; j >= 0; j-- {
+			if instructions[j].Cmd() != dockerfile.CmdUser {
+				continue
+			}
+			if args := instructions[j].ExpandedArgs(); len(args) > 0 {
+				return args[0]
+			}
+		}
+	}
+	if strings.Contains(chain[0].BaseImage().Tag(), "nonroot") {
+		return "nonroot"
+	}
+	return ""
+}
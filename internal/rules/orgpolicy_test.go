@@ -0,0 +1,116 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/baseimages"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/policy"
+)
+
+func loadPolicy(t *testing.T, content string) *policy.Policy {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "acme.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := policy.Load(path)
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	return p
+}
+
+func TestCheckPolicies(t *testing.T) {
+	p := loadPolicy(t, `severity: medium
+allowed_base_images: ["node:*-alpine"]
+allowed_registries: ["docker.io/library"]
+required_user: non-root
+banned_instructions:
+  - instruction: ADD
+    arguments: http*
+max_image_size: 1MB
+`)
+	tests := []struct {
+		name       string
+		dockerfile string
+		// expected are the codes of the violations, sorted, along with their lines
+		expected []string
+	}{
+		{
+			name: "compliant",
+			dockerfile: `FROM node:22-alpine AS build
+RUN npm ci
+
+FROM scratch
+COPY --from=build /app /app
+USER 1000
+`,
+			expected: nil,
+		},
+		{
+			name: "violations",
+			dockerfile: `FROM bitnami/node:22 AS build
+ADD https://example.com/tool.tgz /tmp/
+
+FROM node:22
+COPY --from=build /app /app
+`,
+			expected: []string{"POL001:1", "POL001:4", "POL002:1", "POL003:4", "POL004:2", "POL005:0"},
+		},
+		{
+			name: "user of the base stage",
+			dockerfile: `FROM node:22-alpine AS base
+USER node
+
+FROM base
+CMD ["node", "index.js"]
+`,
+			// only the size of node:22-alpine is above the maximum
+			expected: []string{"POL005:0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.dockerfile)
+			if err != nil {
+				t.Fatalf("failed to parse Dockerfile: %v", err)
+			}
+			c := &Context{Dockerfile: df, DockerfilePath: "Dockerfile", BaseImages: baseimages.Builtin(), Policies: []*policy.Policy{p}}
+			var got []string
+			for _, f := range CheckPolicies(c) {
+				if f.Severity != models.SeverityMedium || !IsPolicyViolation(f) {
+					t.Errorf("unexpected finding: %+v", f)
+				}
+				got = append(got, f.Code+":"+strconv.Itoa(f.Line))
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected violations %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRun_Policies(t *testing.T) {
+	p := loadPolicy(t, "allowed_base_images: [\"node:*-alpine\"]\n")
+	df, _ := dockerfile.NewDockerfile("# dockershrink:ignore-file POL001\nFROM node:20\nCMD [\"node\", \"index.js\"]\n")
+	c := &Context{
+		Dockerfile:     df,
+		DockerfilePath: "Dockerfile",
+		Policies:       []*policy.Policy{p},
+	}
+	found := map[string]bool{}
+	for _, f := range Run(c, models.GoalBuildSpeed) {
+		found[f.Code] = true
+	}
+	if !found[CodePolicyBaseImage] {
+		t.Error("expected policies to be checked whatever the goal and the suppressions")
+	}
+}
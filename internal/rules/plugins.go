@@ -0,0 +1,149 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/opa"
+	"github.com/duaraghav8/dockershrink/internal/plugin"
+	"github.com/duaraghav8/dockershrink/internal/projectinfo"
+)
+
+// MethodCheck is the request a rule plugin receives for every Dockerfile analyzed, with CheckParams as its params
+// and CheckResult as its result
+const MethodCheck = "rule.check"
+
+// CheckParams is what a rule plugin checks
+type CheckParams struct {
+	// Dockerfile is the parsed Dockerfile, in the same shape as the input of OPA bundles
+	Dockerfile *opa.Input `json:"dockerfile"`
+	// Content is the Dockerfile as written
+	Content string `json:"content"`
+	// Dockerignore is the content of the .dockerignore file, empty if there's none
+	Dockerignore string `json:"dockerignore"`
+	// Project are the facts detected about the project, eg- its framework
+	Project *projectinfo.Info `json:"project"`
+}
+
+// CheckResult is the result of a rule plugin
+type CheckResult struct {
+	Findings []*PluginFinding `json:"findings"`
+}
+
+// PluginFinding is a finding reported by a rule plugin
+type PluginFinding struct {
+	// Rule is the ID of the rule, eg- ACME001. Severity overrides and suppressions refer to it.
+	Rule string `json:"rule"`
+	// Name is the name of the rule, eg- no-latest-tag. Defaults to the ID.
+	Name string `json:"name"`
+	// Severity defaults to medium
+	Severity            models.Severity `json:"severity"`
+	Line                int             `json:"line"`
+	Title               string          `json:"title"`
+	Description         string          `json:"description"`
+	EstimatedSizeImpact int64           `json:"estimated_size_impact"`
+	Fix                 *models.Fix     `json:"fix"`
+}
+
+// RulePlugin is a plugin implementing rules, eg- the standards of an organization
+type RulePlugin struct {
+	plugin *plugin.Plugin
+}
+
+// LoadRulePlugin makes the handshake with the rule plugin run by command
+func LoadRulePlugin(ctx context.Context, command []string) (*RulePlugin, error) {
+	p, err := plugin.Load(ctx, command, plugin.KindRule)
+	if err != nil {
+		return nil, err
+	}
+	return &RulePlugin{plugin: p}, nil
+}
+
+// Name returns the name of the plugin
+func (p *RulePlugin) Name() string {
+	return p.plugin.Manifest.Name
+}
+
+// Check runs the rules of the plugin on the Dockerfile of the context. Like those of the built-in rules, findings
+// suppressed by comments in the Dockerfile or of rules turned off are left out, and severities are overridden by
+// the Severities of the context, whose keys are the IDs or the names of the plugin's rules.
+func (p *RulePlugin) Check(ctx context.Context, c *Context, facts *projectinfo.Info) ([]*models.Finding, error) {
+	params := &CheckParams{
+		Dockerfile: opa.NewInput(c.DockerfilePath, c.Dockerfile),
+		Content:    c.Dockerfile.Raw(),
+		Project:    facts,
+	}
+	if c.Dockerignore != nil {
+		params.Dockerignore = c.Dockerignore.Raw()
+	}
+	result := &CheckResult{}
+	if err := p.plugin.Call(ctx, MethodCheck, params, result); err != nil {
+		return nil, fmt.Errorf("rule plugin %s failed: %w", p.Name(), err)
+	}
+
+	suppressed := parseSuppressions(c.Dockerfile.Raw())
+	findings := []*models.Finding{}
+	for _, pf := range result.Findings {
+		if err := p.validate(pf); err != nil {
+			return nil, err
+		}
+		f := &models.Finding{
+			Rule:                pf.Name,
+			Code:                pf.Rule,
+			Severity:            pf.Severity,
+			Filepath:            c.DockerfilePath,
+			Line:                pf.Line,
+			Title:               pf.Title,
+			Description:         pf.Description,
+			EstimatedSizeImpact: pf.EstimatedSizeImpact,
+			Fix:                 pf.Fix,
+		}
+		if f.Rule == "" {
+			f.Rule = f.Code
+		}
+		if f.Severity == "" {
+			f.Severity = models.SeverityMedium
+		}
+		override, ok := c.Severities[f.Code]
+		if !ok {
+			override = c.Severities[f.Rule]
+		}
+		if override == SeverityOff {
+			continue
+		}
+		if override != "" {
+			f.Severity = override
+		}
+		if suppressed.matches(f, c.DockerfilePath) {
+			continue
+		}
+		findings = append(findings, f)
+	}
+	return findings, nil
+}
+
+// validate returns an error if the plugin reported an invalid finding
+func (p *RulePlugin) validate(f *PluginFinding) error {
+	invalid := func(format string, a ...any) error {
+		return fmt.Errorf("rule plugin %s reported an invalid finding: %s", p.Name(), fmt.Sprintf(format, a...))
+	}
+	switch {
+	case f.Rule == "":
+		return invalid("the rule is required")
+	case f.Title == "":
+		return invalid("the title of %s is required", f.Rule)
+	case Lookup(f.Rule) != nil || strings.HasPrefix(strings.ToUpper(f.Rule), "POL"):
+		return invalid("%s is the ID of a built-in rule, give the rules of the plugin their own prefix", f.Rule)
+	}
+	switch f.Severity {
+	case "", models.SeverityInfo, models.SeverityLow, models.SeverityMedium, models.SeverityHigh:
+	default:
+		return invalid("invalid severity %q of %s, must be one of high, medium, low or info", f.Severity, f.Rule)
+	}
+	if f.Fix != nil && (f.Fix.Line < 1 || f.Fix.EndLine < f.Fix.Line) {
+		return invalid("the fix of %s must replace lines from line to end_line, got %d to %d", f.Rule, f.Fix.Line, f.Fix.EndLine)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package rules
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/projectinfo"
+)
+
+// writeRulePlugin writes a rule plugin that saves the request it's asked to check next to it and reports findings
+func writeRulePlugin(t *testing.T, findings string) string {
+	t.Helper()
+	dir := t.TempDir()
+	script := filepath.Join(dir, "plugin.sh")
+	content := "#!/bin/sh\nrequest=$(cat)\ncase \"$request\" in\n" +
+		"  *'\"method\":\"handshake\"'*) echo '{\"result\": {\"name\": \"acme\", \"protocol_version\": 1, \"capabilities\": [{\"kind\": \"rule\", \"version\": 1}]}}' ;;\n" +
+		"  *) echo \"$request\" > \"$(dirname \"$0\")/request\"; echo '{\"result\": {\"findings\": " + findings + "}}' ;;\nesac\n"
+	if err := os.WriteFile(script, []byte(content), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+func TestRulePlugin_Check(t *testing.T) {
+	script := writeRulePlugin(t, `[
+		{"rule": "ACME001", "name": "no-latest", "severity": "high", "line": 1, "title": "Pin the base image", "fix": {"line": 1, "end_line": 1, "replacement": "FROM node:22.11-alpine"}},
+		{"rule": "ACME002", "line": 3, "title": "Suppressed"},
+		{"rule": "ACME003", "title": "Turned off"},
+		{"rule": "ACME004", "title": "Overridden by name", "name": "labels"}
+	]`)
+	p, err := LoadRulePlugin(context.Background(), []string{script})
+	if err != nil {
+		t.Fatalf("LoadRulePlugin() error = %v", err)
+	}
+	df, err := dockerfile.NewDockerfile("FROM node:latest\n# dockershrink:ignore ACME002\nRUN npm ci\n")
+	if err != nil {
+		t.Fatalf("failed to parse Dockerfile: %v", err)
+	}
+	c := &Context{
+		Dockerfile:     df,
+		DockerfilePath: "Dockerfile",
+		Severities:     map[string]models.Severity{"ACME003": SeverityOff, "labels": models.SeverityLow},
+	}
+
+	findings, err := p.Check(context.Background(), c, &projectinfo.Info{Language: projectinfo.LanguageNodeJS, Framework: "express"})
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	expected := []*models.Finding{
+		{
+			Rule: "no-latest", Code: "ACME001", Severity: models.SeverityHigh, Filepath: "Dockerfile", Line: 1, Title: "Pin the base image",
+			Fix: &models.Fix{Line: 1, EndLine: 1, Replacement: "FROM node:22.11-alpine"},
+		},
+		{Rule: "labels", Code: "ACME004", Severity: models.SeverityLow, Filepath: "Dockerfile", Title: "Overridden by name"},
+	}
+	if !reflect.DeepEqual(findings, expected) {
+		for _, f := range findings {
+			t.Logf("%+v", f)
+		}
+		t.Errorf("unexpected findings")
+	}
+
+	request, err := os.ReadFile(filepath.Join(filepath.Dir(script), "request"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`"method":"rule.check"`, `"base_image":"node:latest"`, `"framework":"express"`} {
+		if !strings.Contains(string(request), s) {
+			t.Errorf("expected the request to contain %s, got %s", s, request)
+		}
+	}
+}
+
+func TestRulePlugin_InvalidFindings(t *testing.T) {
+	tests := []struct {
+		findings string
+		err      string
+	}{
+		{findings: `[{"title": "No rule"}]`, err: "the rule is required"},
+		{findings: `[{"rule": "ACME001"}]`, err: "the title of ACME001 is required"},
+		{findings: `[{"rule": "DS001", "title": "Built-in"}]`, err: "ID of a built-in rule"},
+		{findings: `[{"rule": "POL001", "title": "Policy"}]`, err: "ID of a built-in rule"},
+		{findings: `[{"rule": "ACME001", "title": "x", "severity": "critical"}]`, err: `invalid severity "critical"`},
+		{findings: `[{"rule": "ACME001", "title": "x", "fix": {"line": 3, "end_line": 2}}]`, err: "must replace lines"},
+	}
+	df, _ := dockerfile.NewDockerfile("FROM node:22\n")
+	c := &Context{Dockerfile: df, DockerfilePath: "Dockerfile"}
+	for _, tt := range tests {
+		p, err := LoadRulePlugin(context.Background(), []string{writeRulePlugin(t, tt.findings)})
+		if err != nil {
+			t.Fatalf("LoadRulePlugin() error = %v", err)
+		}
+		if _, err := p.Check(context.Background(), c, &projectinfo.Info{}); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Check() with %s = %v, expected an error containing %q", tt.findings, err, tt.err)
+		}
+	}
+}
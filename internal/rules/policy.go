@@ -0,0 +1,57 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+// Policy decides whether the analysis of a Dockerfile is acceptable, eg- to fail a CI pipeline when it isn't
+type Policy struct {
+	// FailOn rejects the analysis if it has a finding of this severity or a higher one, empty to accept any finding
+	FailOn models.Severity
+	// MinScore rejects the analysis if its score is lower, 0 to accept any score
+	MinScore int
+}
+
+// ParsePolicy returns the policy described by a severity and a score, eg- from --fail-on and --min-score
+func ParsePolicy(failOn string, minScore int) (*Policy, error) {
+	p := &Policy{MinScore: minScore}
+	if minScore < 0 || minScore > 100 {
+		return nil, fmt.Errorf("invalid minimum score %d, must be between 0 and 100", minScore)
+	}
+	if failOn != "" {
+		sev := models.Severity(strings.ToLower(failOn))
+		if _, ok := severityRank[sev]; !ok {
+			return nil, fmt.Errorf("invalid severity %q, must be one of high, medium, low or info", failOn)
+		}
+		p.FailOn = sev
+	}
+	return p, nil
+}
+
+// IsSet returns true if the policy rejects anything
+func (p *Policy) IsSet() bool {
+	return p.FailOn != "" || p.MinScore > 0
+}
+
+// Violations returns why an analysis with the given score and findings is rejected, nothing if it's accepted
+func (p *Policy) Violations(score int, findings []*models.Finding) []string {
+	var violations []string
+	if p.MinScore > 0 && score < p.MinScore {
+		violations = append(violations, fmt.Sprintf("the score %d is below the minimum of %d", score, p.MinScore))
+	}
+	if p.FailOn != "" {
+		count := 0
+		for _, f := range findings {
+			if severityRank[f.Severity] >= severityRank[p.FailOn] {
+				count++
+			}
+		}
+		if count > 0 {
+			violations = append(violations, fmt.Sprintf("%d finding(s) have a severity of %s or higher", count, p.FailOn))
+		}
+	}
+	return violations
+}
@@ -0,0 +1,258 @@
+package rules
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/baseimages"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/dockerignore"
+	"github.com/duaraghav8/dockershrink/internal/fileindex"
+	"github.com/duaraghav8/dockershrink/internal/kubernetes"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/packagejson"
+	"github.com/duaraghav8/dockershrink/internal/platform"
+	"github.com/duaraghav8/dockershrink/internal/policy"
+	"github.com/duaraghav8/dockershrink/internal/workspace"
+)
+
+// Context contains everything a rule can inspect while analyzing a project
+type Context struct {
+	Dockerfile     *dockerfile.Dockerfile
+	DockerfilePath string
+
+	// Dockerignore is nil if the project doesn't have a .dockerignore file
+	Dockerignore     *dockerignore.Dockerignore
+	DockerignorePath string
+
+	// PackageJSON is nil if the project doesn't have a package.json file
+	PackageJSON *packagejson.PackageJSON
+	// Workspace is nil if the project is not a monorepo
+	Workspace *workspace.Workspace
+	// ProjectDir is the build context. It is nil if the project files are not available.
+	ProjectDir fs.FS
+	// Files indexes the build context. If it's nil, sizes are computed by walking ProjectDir.
+	Files *fileindex.Index
+	// BuildContexts are the named build contexts the Dockerfile is built with, keyed by name.
+	// The FS is nil for contexts that aren't local directories, eg- docker-image://node:22-alpine.
+	BuildContexts map[string]fs.FS
+	// BaseImages is nil if no data about official base images is available
+	BaseImages *baseimages.Matrix
+	// Platforms the image is built for, empty if the user didn't declare them
+	Platforms []platform.Platform
+	// Severities overrides the severity of rules, keyed by rule ID. SeverityOff disables a rule.
+	Severities map[string]models.Severity
+	// Policies are the organization policies the image definition must comply with
+	Policies []*policy.Policy
+	// Manifests are the containers of the Kubernetes manifests and Helm values that run the image,
+	// empty if none are configured
+	Manifests []*kubernetes.Container
+}
+
+// Rule is a static check that detects a specific inefficiency in a project's image definition.
+// Rules never modify the project.
+type Rule struct {
+	// ID is the stable code of the rule, eg- "DS001". It is used in suppressions and configuration.
+	ID   string
+	Name string
+	// Description tells what the rule detects and why it matters, it's also published in docs/rules.md
+	Description string
+	Severity    models.Severity
+	// Goals are the optimization goals this rule is relevant to
+	Goals []models.Goal
+	Check func(c *Context) []*models.Finding
+}
+
+// DocsURL is the documentation of all the rules, every rule has a section in it
+const DocsURL = "https://github.com/duaraghav8/dockershrink/blob/main/docs/rules.md"
+
+// HelpURI returns the link to the rule's section in the documentation
+func (r *Rule) HelpURI() string {
+	return DocsURL + "#" + strings.ToLower(r.ID) + "-" + r.Name
+}
+
+// All is the list of all the static rules, in the order they are run
+var All = []*Rule{
+	ruleMissingDockerignore,
+	ruleDockerignoreMissingEntries,
+	ruleHeavyFinalBaseImage,
+	ruleUnsupportedBaseImage,
+	ruleBaseImagePlatforms,
+	ruleUntaggedBaseImage,
+	ruleMissingMultistageBuild,
+	ruleDevDependenciesInFinalStage,
+	ruleNodeModulesCopiedFromContext,
+	ruleUnprunedMonorepo,
+	ruleAptGetInstallRecommends,
+	ruleAptGetBloat,
+	rulePackageManagerCacheLeftBehind,
+	ruleSourceCopiedBeforeDependencies,
+	ruleAddInsteadOfCopy,
+	ruleOnbuildTriggers,
+	ruleMissingCacheMount,
+	ruleOutdatedSyntaxDirective,
+	ruleSecretInBuildArg,
+	ruleMissingRuntimeLibrary,
+	ruleUnneededSystemPackage,
+	ruleRunCommandsNotChained,
+	ruleRemoteScriptPipedToShell,
+	ruleDownloadedArchiveLeftBehind,
+	ruleApkAddCache,
+	ruleSeparateSystemPackageInstalls,
+	ruleBuildToolsInFinalStage,
+	rulePackageExtrasInFinalImage,
+	ruleUnstrippedBinary,
+	ruleNativeModuleLibcMismatch,
+	ruleUnfrozenInstall,
+	ruleDevDependenciesCopiedIntoFinalStage,
+	ruleDevOnlyProductionDependency,
+	ruleNonRuntimePathsInContext,
+	ruleManifestEntrypointOverride,
+	ruleManifestResourcesOversizedImage,
+	ruleManifestMutableImageTag,
+}
+
+// SeverityOff disables a rule when used as its severity override
+const SeverityOff models.Severity = "off"
+
+// Lookup returns the rule with the given ID or name, nil if there is no such rule
+func Lookup(ref string) *Rule {
+	for _, rule := range All {
+		if strings.EqualFold(rule.ID, ref) || rule.Name == ref {
+			return rule
+		}
+	}
+	return nil
+}
+
+// ParseSeverity parses a severity override, which is either a severity or "off"
+func ParseSeverity(s string) (models.Severity, error) {
+	switch sev := models.Severity(strings.ToLower(s)); sev {
+	case models.SeverityInfo, models.SeverityLow, models.SeverityMedium, models.SeverityHigh, SeverityOff:
+		return sev, nil
+	default:
+		return "", fmt.Errorf("invalid severity %q, must be one of high, medium, low, info or off", s)
+	}
+}
+
+// severityPenalty is the number of points deducted from the score for every finding of a severity
+var severityPenalty = map[models.Severity]int{
+	models.SeverityInfo:   0,
+	models.SeverityLow:    3,
+	models.SeverityMedium: 8,
+	models.SeverityHigh:   15,
+}
+
+var severityRank = map[models.Severity]int{
+	models.SeverityInfo:   0,
+	models.SeverityLow:    1,
+	models.SeverityMedium: 2,
+	models.SeverityHigh:   3,
+}
+
+// Run runs all the rules relevant to the given goal and returns their findings,
+// sorted by severity (highest first) and then by line number.
+// Findings suppressed by "# dockershrink:ignore" comments in the Dockerfile are left out,
+// while the violations of organization policies are always returned.
+func Run(c *Context, goal models.Goal) []*models.Finding {
+	findings := []*models.Finding{}
+	suppressed := parseSuppressions(c.Dockerfile.Raw())
+	for _, rule := range All {
+		override := c.Severities[rule.ID]
+		if !goal.Includes(rule.Goals...) || override == SeverityOff {
+			continue
+		}
+		for _, f := range rule.Check(c) {
+			f.Rule = rule.Name
+			f.Code = rule.ID
+			if override != "" {
+				f.Severity = override
+			} else if f.Severity == "" {
+				f.Severity = rule.Severity
+			}
+			if suppressed.matches(f, c.DockerfilePath) {
+				continue
+			}
+			findings = append(findings, f)
+		}
+	}
+	findings = append(findings, CheckPolicies(c)...)
+	SortFindings(findings)
+	return findings
+}
+
+// SortFindings sorts findings by severity (highest first) and then by line number, like Run
+func SortFindings(findings []*models.Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if severityRank[findings[i].Severity] != severityRank[findings[j].Severity] {
+			return severityRank[findings[i].Severity] > severityRank[findings[j].Severity]
+		}
+		return findings[i].Line < findings[j].Line
+	})
+}
+
+// Score grades the image definition from 0 to 100 based on its findings.
+// 100 means that no inefficiencies were found.
+func Score(findings []*models.Finding) int {
+	score := 100
+	for _, f := range findings {
+		score -= severityPenalty[f.Severity]
+	}
+	if score < 0 {
+		return 0
+	}
+	return score
+}
+
+// Grade returns the letter grade of a score: A from 90, B from 80, C from 70, D from 60 and F below
+func Grade(score int) string {
+	switch {
+	case score >= 90:
+		return "A"
+	case score >= 80:
+		return "B"
+	case score >= 70:
+		return "C"
+	case score >= 60:
+		return "D"
+	default:
+		return "F"
+	}
+}
+
+// Breakdown grades the image definition on its size efficiency, cache friendliness and security posture.
+// Each aspect is scored like Score, from the findings of the rules relevant to the goal of the same name,
+// so a finding of a rule relevant to several goals, eg- dev dependencies in the final image, lowers each of them.
+// Findings of organization policies and rule plugins only count towards the overall grade.
+func Breakdown(findings []*models.Finding) *models.ScoreBreakdown {
+	var size, cache, security []*models.Finding
+	for _, f := range findings {
+		rule := Lookup(f.Code)
+		if rule == nil || rule.Name != f.Rule {
+			continue
+		}
+		for _, g := range rule.Goals {
+			switch g {
+			case models.GoalSize:
+				size = append(size, f)
+			case models.GoalBuildSpeed:
+				cache = append(cache, f)
+			case models.GoalSecurity:
+				security = append(security, f)
+			}
+		}
+	}
+	return &models.ScoreBreakdown{
+		Grade:    Grade(Score(findings)),
+		Size:     aspectScore(size),
+		Cache:    aspectScore(cache),
+		Security: aspectScore(security),
+	}
+}
+
+func aspectScore(findings []*models.Finding) models.AspectScore {
+	score := Score(findings)
+	return models.AspectScore{Score: score, Grade: Grade(score), Findings: len(findings)}
+}
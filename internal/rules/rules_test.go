@@ -0,0 +1,668 @@
+package rules
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/baseimages"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/dockerignore"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/platform"
+)
+
+func findingRules(findings []*models.Finding) map[string]*models.Finding {
+	result := map[string]*models.Finding{}
+	for _, f := range findings {
+		result[f.Rule] = f
+	}
+	return result
+}
+
+func TestRun_BloatedDockerfile(t *testing.T) {
+	df, err := dockerfile.NewDockerfile(`FROM node:20
+WORKDIR /app
+COPY . .
+RUN npm install
+RUN apt-get update && apt-get install -y curl
+RUN npm run build
+CMD ["node", "dist/main.js"]
+`)
+	if err != nil {
+		t.Fatalf("failed to parse Dockerfile: %v", err)
+	}
+	c := &Context{
+		Dockerfile:     df,
+		DockerfilePath: "Dockerfile",
+		ProjectDir: fstest.MapFS{
+			"node_modules/a/index.js": {Data: make([]byte, 2048)},
+			"src/main.ts":             {Data: []byte("console.log(1)")},
+		},
+	}
+
+	found := findingRules(Run(c, models.GoalAll))
+	for _, rule := range []string{
+		"missing-dockerignore",
+		"heavy-final-base-image",
+		"missing-multistage-build",
+		"devdependencies-in-final-stage",
+		"apt-get-bloat",
+		"package-manager-cache-left-behind",
+		"source-copied-before-dependencies",
+	} {
+		if _, ok := found[rule]; !ok {
+			t.Errorf("expected a finding for rule %s", rule)
+		}
+	}
+
+	if impact := found["missing-dockerignore"].EstimatedSizeImpact; impact != 2048 {
+		t.Errorf("expected missing-dockerignore impact of 2048 bytes, got %d", impact)
+	}
+	if line := found["apt-get-bloat"].Line; line != 5 {
+		t.Errorf("expected apt-get-bloat on line 5, got %d", line)
+	}
+}
+
+func TestRun_OptimizedDockerfile(t *testing.T) {
+	df, err := dockerfile.NewDockerfile(`FROM node:20 AS build
+WORKDIR /app
+COPY package*.json .
+RUN --mount=type=cache,target=/root/.npm npm ci
+COPY . .
+RUN npm run build
+
+FROM node:20-alpine
+WORKDIR /app
+ENV NODE_ENV=production
+COPY package*.json .
+RUN --mount=type=cache,target=/root/.npm npm ci
+COPY --from=build /app/dist ./dist
+CMD ["node", "dist/main.js"]
+`)
+	if err != nil {
+		t.Fatalf("failed to parse Dockerfile: %v", err)
+	}
+	c := &Context{
+		Dockerfile:       df,
+		DockerfilePath:   "Dockerfile",
+		Dockerignore:     dockerignore.NewDockerignore("node_modules\n.git\n"),
+		DockerignorePath: ".dockerignore",
+	}
+
+	findings := Run(c, models.GoalAll)
+	if len(findings) != 0 {
+		for _, f := range findings {
+			t.Errorf("unexpected finding %s: %s", f.Rule, f.Title)
+		}
+	}
+	if Score(findings) != 100 {
+		t.Errorf("expected a perfect score, got %d", Score(findings))
+	}
+}
+
+func TestRun_GoalFiltersRules(t *testing.T) {
+	df, _ := dockerfile.NewDockerfile("FROM node:20\nCOPY . .\nRUN npm ci\n")
+	c := &Context{Dockerfile: df, DockerfilePath: "Dockerfile"}
+
+	found := findingRules(Run(c, models.GoalBuildSpeed))
+	if _, ok := found["heavy-final-base-image"]; ok {
+		t.Error("expected size rules to be skipped for the build-speed goal")
+	}
+	if _, ok := found["source-copied-before-dependencies"]; !ok {
+		t.Error("expected build-speed rules to run for the build-speed goal")
+	}
+}
+
+func TestScore(t *testing.T) {
+	findings := []*models.Finding{
+		{Severity: models.SeverityHigh},
+		{Severity: models.SeverityMedium},
+		{Severity: models.SeverityLow},
+		{Severity: models.SeverityInfo},
+	}
+	if got := Score(findings); got != 74 {
+		t.Errorf("expected score 74, got %d", got)
+	}
+}
+
+func TestGrade(t *testing.T) {
+	tests := []struct {
+		score    int
+		expected string
+	}{
+		{100, "A"}, {90, "A"}, {89, "B"}, {80, "B"}, {79, "C"}, {70, "C"}, {65, "D"}, {60, "D"}, {59, "F"}, {0, "F"},
+	}
+	for _, tt := range tests {
+		if got := Grade(tt.score); got != tt.expected {
+			t.Errorf("Grade(%d) = %s; want %s", tt.score, got, tt.expected)
+		}
+	}
+}
+
+func TestBreakdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		findings []*models.Finding
+		expected models.ScoreBreakdown
+	}{
+		{
+			name:     "no findings",
+			findings: []*models.Finding{},
+			expected: models.ScoreBreakdown{
+				Grade:    "A",
+				Size:     models.AspectScore{Score: 100, Grade: "A"},
+				Cache:    models.AspectScore{Score: 100, Grade: "A"},
+				Security: models.AspectScore{Score: 100, Grade: "A"},
+			},
+		},
+		{
+			name: "findings count towards every goal of their rule",
+			findings: []*models.Finding{
+				{Code: "DS001", Rule: "missing-dockerignore", Severity: models.SeverityHigh},
+				{Code: "DS007", Rule: "devdependencies-in-final-stage", Severity: models.SeverityMedium},
+				{Code: "DS004", Rule: "unsupported-base-image", Severity: models.SeverityLow},
+			},
+			expected: models.ScoreBreakdown{
+				Grade:    "C",
+				Size:     models.AspectScore{Score: 77, Grade: "C", Findings: 2},
+				Cache:    models.AspectScore{Score: 85, Grade: "B", Findings: 1},
+				Security: models.AspectScore{Score: 89, Grade: "B", Findings: 2},
+			},
+		},
+		{
+			name: "policy and plugin findings only count towards the overall grade",
+			findings: []*models.Finding{
+				{Code: CodePolicyBundle, Rule: "opa", Severity: models.SeverityHigh},
+				{Code: "DS001", Rule: "acme-plugin-rule", Severity: models.SeverityHigh},
+			},
+			expected: models.ScoreBreakdown{
+				Grade:    "C",
+				Size:     models.AspectScore{Score: 100, Grade: "A"},
+				Cache:    models.AspectScore{Score: 100, Grade: "A"},
+				Security: models.AspectScore{Score: 100, Grade: "A"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Breakdown(tt.findings); *got != tt.expected {
+				t.Errorf("Breakdown() = %+v; want %+v", *got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPolicy_Violations(t *testing.T) {
+	findings := []*models.Finding{
+		{Rule: "a", Severity: models.SeverityMedium},
+		{Rule: "b", Severity: models.SeverityLow},
+	}
+	tests := []struct {
+		failOn     string
+		minScore   int
+		score      int
+		violations int
+	}{
+		{score: 10},
+		{failOn: "high", score: 89},
+		{failOn: "medium", score: 89, violations: 1},
+		{failOn: "LOW", score: 89, violations: 1},
+		{minScore: 90, score: 89, violations: 1},
+		{minScore: 89, score: 89},
+		{failOn: "info", minScore: 90, score: 89, violations: 2},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.failOn, tt.minScore), func(t *testing.T) {
+			p, err := ParsePolicy(tt.failOn, tt.minScore)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := p.Violations(tt.score, findings); len(got) != tt.violations {
+				t.Errorf("expected %d violation(s), got %v", tt.violations, got)
+			}
+		})
+	}
+
+	for _, invalid := range []struct {
+		failOn   string
+		minScore int
+	}{{"off", 0}, {"critical", 0}, {"", 101}, {"", -1}} {
+		if _, err := ParsePolicy(invalid.failOn, invalid.minScore); err == nil {
+			t.Errorf("expected an error for --fail-on %q --min-score %d", invalid.failOn, invalid.minScore)
+		}
+	}
+}
+
+func TestRun_BaseImageMatrix(t *testing.T) {
+	df, err := dockerfile.NewDockerfile(`FROM node:16 AS build
+RUN npm ci
+
+FROM node:22
+COPY --from=build /app /app
+`)
+	if err != nil {
+		t.Fatalf("failed to parse Dockerfile: %v", err)
+	}
+	m := &baseimages.Matrix{Images: map[string]*baseimages.Image{
+		"node": {
+			Name:     "node",
+			Variants: []string{"alpine", "slim", ""},
+			Cycles: []*baseimages.Cycle{
+				{Version: "22", LTS: true},
+				{Version: "16", EOL: time.Date(2023, 9, 11, 0, 0, 0, 0, time.UTC), LTS: true},
+			},
+			Tags: map[string]*baseimages.Tag{
+				"22":        {Size: 400 * MB},
+				"22-alpine": {Size: 50 * MB},
+			},
+		},
+	}}
+	c := &Context{Dockerfile: df, DockerfilePath: "Dockerfile", BaseImages: m}
+
+	found := findingRules(Run(c, models.GoalAll))
+	heavy, ok := found["heavy-final-base-image"]
+	if !ok {
+		t.Fatal("expected a finding for rule heavy-final-base-image")
+	}
+	if !strings.Contains(heavy.Description, "Use 'node:22-alpine' instead") {
+		t.Errorf("expected a specific tag to be recommended, got %q", heavy.Description)
+	}
+	// the impact is the difference of the compressed sizes the description quotes
+	if heavy.EstimatedSizeImpact != 350*MB || !strings.Contains(heavy.Description, "50.0 MB compressed download compared to 400.0 MB") {
+		t.Errorf("expected the impact to be the compressed savings quoted, got %d: %q", heavy.EstimatedSizeImpact, heavy.Description)
+	}
+	unsupported, ok := found["unsupported-base-image"]
+	if !ok {
+		t.Fatal("expected a finding for rule unsupported-base-image")
+	}
+	if unsupported.Line != 1 || !strings.Contains(unsupported.Description, "Upgrade to 'node:22'") {
+		t.Errorf("unexpected finding on line %d: %q", unsupported.Line, unsupported.Description)
+	}
+}
+
+func TestRun_BaseImagePlatforms(t *testing.T) {
+	df, err := dockerfile.NewDockerfile(`FROM --platform=$BUILDPLATFORM eclipse-temurin:21-alpine AS build
+RUN ./gradlew build
+
+FROM eclipse-temurin:21-jre-alpine
+COPY --from=build /app /app
+`)
+	if err != nil {
+		t.Fatalf("failed to parse Dockerfile: %v", err)
+	}
+	platforms, err := platform.ParseList("linux/amd64,linux/s390x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Context{Dockerfile: df, DockerfilePath: "Dockerfile", BaseImages: baseimages.Builtin(), Platforms: platforms}
+
+	var found []*models.Finding
+	for _, f := range Run(c, models.GoalSize) {
+		if f.Rule == "base-image-platforms" {
+			found = append(found, f)
+		}
+	}
+	// the build stage only runs on the build platform
+	if len(found) != 1 {
+		t.Fatalf("expected 1 finding for rule base-image-platforms, got %d", len(found))
+	}
+	if found[0].Line != 4 || !strings.Contains(found[0].Description, "isn't published for linux/s390x") ||
+		!strings.Contains(found[0].Description, "Use 'eclipse-temurin:21-jre' instead") {
+		t.Errorf("unexpected finding on line %d: %q", found[0].Line, found[0].Description)
+	}
+
+	c.Platforms = nil
+	for _, f := range Run(c, models.GoalSize) {
+		if f.Rule == "base-image-platforms" {
+			t.Errorf("expected no findings without target platforms, got %q", f.Description)
+		}
+	}
+}
+
+func TestRun_HeredocScripts(t *testing.T) {
+	df, err := dockerfile.NewDockerfile(`FROM node:20-alpine
+WORKDIR /app
+COPY package.json package-lock.json ./
+RUN <<EOF
+apk add --no-cache curl
+npm install
+EOF
+COPY . .
+CMD ["node", "index.js"]
+`)
+	if err != nil {
+		t.Fatalf("failed to parse Dockerfile: %v", err)
+	}
+	c := &Context{Dockerfile: df, DockerfilePath: "Dockerfile", ProjectDir: fstest.MapFS{}}
+
+	found := findingRules(Run(c, models.GoalAll))
+	f, ok := found["devdependencies-in-final-stage"]
+	if !ok {
+		t.Fatal("expected the npm install inside the heredoc to be found")
+	}
+	if f.Line != 4 {
+		t.Errorf("expected finding on line 4, got %d", f.Line)
+	}
+}
+
+func TestAll_UniqueIDs(t *testing.T) {
+	seen := map[string]string{}
+	for _, rule := range All {
+		if !strings.HasPrefix(rule.ID, "DS") || len(rule.ID) != 5 {
+			t.Errorf("rule %s has an invalid ID %q", rule.Name, rule.ID)
+		}
+		if other, ok := seen[rule.ID]; ok {
+			t.Errorf("rules %s and %s share the ID %s", other, rule.Name, rule.ID)
+		}
+		seen[rule.ID] = rule.Name
+		if Lookup(strings.ToLower(rule.ID)) != rule || Lookup(rule.Name) != rule {
+			t.Errorf("rule %s cannot be looked up by its ID or name", rule.ID)
+		}
+	}
+}
+
+func TestAll_Documented(t *testing.T) {
+	docs, err := os.ReadFile("../../docs/rules.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range All {
+		if rule.Description == "" {
+			t.Errorf("rule %s has no description", rule.ID)
+		}
+		// the anchor GitHub generates for the heading of the rule's section
+		if !strings.Contains(string(docs), fmt.Sprintf("\n## %s %s\n", rule.ID, rule.Name)) {
+			t.Errorf("rule %s has no section in docs/rules.md, which %s links to", rule.ID, rule.HelpURI())
+		}
+	}
+}
+
+func TestRun_LintRules(t *testing.T) {
+	df, err := dockerfile.NewDockerfile(`FROM debian AS tools
+RUN apt-get update && apt-get install -y curl && rm -rf /var/lib/apt/lists/*
+
+FROM tools AS build
+ADD https://example.com/tool.tar.gz /tmp/
+ADD vendor.tgz /opt/
+
+FROM node:20-slim
+ADD package.json ./
+`)
+	if err != nil {
+		t.Fatalf("failed to parse Dockerfile: %v", err)
+	}
+	c := &Context{Dockerfile: df, DockerfilePath: "Dockerfile", Dockerignore: dockerignore.NewDockerignore("node_modules\n.git\n")}
+
+	lines := map[string][]int{}
+	for _, f := range Run(c, models.GoalAll) {
+		lines[f.Code] = append(lines[f.Code], f.Line)
+	}
+	for code, expected := range map[string][]int{
+		"DS012": {1},
+		"DS013": {9},
+		"DS014": {2},
+	} {
+		if len(lines[code]) != len(expected) || lines[code][0] != expected[0] {
+			t.Errorf("expected %s on lines %v, got %v", code, expected, lines[code])
+		}
+	}
+	if _, ok := lines["DS015"]; ok {
+		t.Error("expected no DS015 since the apt lists are removed")
+	}
+}
+
+func TestRun_Suppressions(t *testing.T) {
+	df, err := dockerfile.NewDockerfile(`# dockershrink:ignore-file missing-dockerignore
+FROM node:20
+
+# dockershrink:ignore DS014
+RUN apt-get update && \
+    apt-get install -y curl
+# dockershrink:ignore
+RUN apt-get install -y git
+RUN apt-get install -y jq
+`)
+	if err != nil {
+		t.Fatalf("failed to parse Dockerfile: %v", err)
+	}
+	c := &Context{Dockerfile: df, DockerfilePath: "Dockerfile"}
+
+	var got []string
+	for _, f := range Run(c, models.GoalSize) {
+		if f.Code == "DS001" || f.Code == "DS014" || f.Code == "DS015" {
+			got = append(got, fmt.Sprintf("%s:%d", f.Code, f.Line))
+		}
+	}
+	sort.Strings(got)
+	expected := []string{"DS014:9", "DS015:5", "DS015:9"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected findings %v, got %v", expected, got)
+	}
+}
+
+func TestRun_SeverityOverrides(t *testing.T) {
+	df, err := dockerfile.NewDockerfile("FROM node:20\nRUN apt-get install -y --no-install-recommends curl\n")
+	if err != nil {
+		t.Fatalf("failed to parse Dockerfile: %v", err)
+	}
+	c := &Context{
+		Dockerfile:     df,
+		DockerfilePath: "Dockerfile",
+		Severities:     map[string]models.Severity{"DS015": models.SeverityHigh, "DS001": SeverityOff},
+	}
+
+	found := findingRules(Run(c, models.GoalAll))
+	if _, ok := found["missing-dockerignore"]; ok {
+		t.Error("expected the disabled rule not to run")
+	}
+	if f := found["apt-get-bloat"]; f == nil || f.Severity != models.SeverityHigh {
+		t.Errorf("expected apt-get-bloat with high severity, got %+v", f)
+	}
+
+	if _, err := ParseSeverity("critical"); err == nil {
+		t.Error("expected an error for an unknown severity")
+	}
+}
+
+func TestRun_OnbuildTriggersAndArgs(t *testing.T) {
+	df, err := dockerfile.NewDockerfile(`ARG BASE=node:20
+FROM node:8-onbuild AS legacy
+
+FROM ${BASE} AS base
+WORKDIR /app
+ONBUILD COPY . .
+ONBUILD RUN npm install
+
+FROM base
+CMD ["node", "index.js"]
+`)
+	if err != nil {
+		t.Fatalf("failed to parse Dockerfile: %v", err)
+	}
+	c := &Context{Dockerfile: df, DockerfilePath: "Dockerfile", ProjectDir: fstest.MapFS{}}
+
+	lines := map[string][]int{}
+	for _, f := range Run(c, models.GoalAll) {
+		lines[f.Code] = append(lines[f.Code], f.Line)
+	}
+	expected := map[string][]int{
+		// the triggers run in the stage built from base
+		"DS007": {7},
+		"DS010": {7},
+		"DS016": {2, 9},
+	}
+	for code, want := range expected {
+		if !reflect.DeepEqual(lines[code], want) {
+			t.Errorf("expected %s on lines %v, got %v", code, want, lines[code])
+		}
+	}
+	// the base image of the first stage is resolved from the ARG, so it's known to be tagged
+	if _, ok := lines["DS012"]; ok {
+		t.Errorf("unexpected DS012 on lines %v", lines["DS012"])
+	}
+}
+
+func TestRun_NamedBuildContexts(t *testing.T) {
+	df, err := dockerfile.NewDockerfile(`FROM node AS build
+WORKDIR /app
+COPY --from=lib node_modules ./node_modules
+COPY --from=image /usr/lib/node_modules ./global
+
+FROM base
+COPY --from=build /app/node_modules ./node_modules
+CMD ["node", "index.js"]
+`)
+	if err != nil {
+		t.Fatalf("failed to parse Dockerfile: %v", err)
+	}
+	c := &Context{
+		Dockerfile:     df,
+		DockerfilePath: "Dockerfile",
+		ProjectDir:     fstest.MapFS{},
+		BuildContexts: map[string]fs.FS{
+			"lib":   fstest.MapFS{"node_modules/a/index.js": {Data: make([]byte, 1024)}},
+			"image": nil,
+			// replaces the image of "FROM node"
+			"node": nil,
+			"base": nil,
+		},
+	}
+
+	lines := map[string][]int{}
+	found := map[string]*models.Finding{}
+	for _, f := range Run(c, models.GoalAll) {
+		lines[f.Code] = append(lines[f.Code], f.Line)
+		found[f.Code] = f
+	}
+	if !reflect.DeepEqual(lines["DS008"], []int{3}) {
+		t.Fatalf("expected DS008 on line 3, got %v", lines["DS008"])
+	}
+	if f := found["DS008"]; f.EstimatedSizeImpact != 1024 || !strings.Contains(f.Title, "'lib'") {
+		t.Errorf("unexpected DS008 finding: %+v", f)
+	}
+	// both base images are replaced by named contexts, so they aren't known
+	for _, code := range []string{"DS003", "DS012"} {
+		if _, ok := lines[code]; ok {
+			t.Errorf("unexpected %s on lines %v", code, lines[code])
+		}
+	}
+}
+
+func TestRun_CacheMounts(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected []string
+	}{
+		{
+			name: "installs without cache mounts",
+			code: `FROM golang:1.25 AS build
+RUN go mod download
+RUN --mount=type=cache,target=/root/.cache/go-build go build -o /app .
+
+FROM python:3.12-slim
+RUN pip install --no-cache-dir -r requirements.txt
+RUN apt-get update
+`,
+			expected: []string{"DS017:2", "DS017:6"},
+		},
+		{
+			name: "old syntax directive is reported along with the mounts that need it",
+			code: `# syntax=docker/dockerfile:1.1
+FROM node:20-alpine
+RUN --mount=type=cache,target=/root/.npm npm ci
+RUN cargo build --release
+`,
+			expected: []string{"DS017:4", "DS018:1"},
+		},
+		{
+			name: "experimental syntax supports mounts",
+			code: `# syntax=docker/dockerfile:1.0-experimental
+FROM node:20-alpine
+RUN --mount=type=cache,target=/root/.npm npm ci
+`,
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.code)
+			if err != nil {
+				t.Fatalf("failed to parse Dockerfile: %v", err)
+			}
+			c := &Context{Dockerfile: df, DockerfilePath: "Dockerfile"}
+			found := []string{}
+			for _, f := range Run(c, models.GoalBuildSpeed) {
+				if f.Code == "DS017" || f.Code == "DS018" {
+					found = append(found, fmt.Sprintf("%s:%d", f.Code, f.Line))
+				}
+			}
+			sort.Strings(found)
+			if !reflect.DeepEqual(found, tt.expected) {
+				t.Errorf("expected findings %v, got %v", tt.expected, found)
+			}
+		})
+	}
+}
+
+func TestSupportsRunMounts(t *testing.T) {
+	tests := map[string]bool{
+		"":                                true,
+		"docker/dockerfile:1":             true,
+		"docker/dockerfile:1.2":           true,
+		"docker/dockerfile:1.10":          true,
+		"docker.io/docker/dockerfile:1.7": true,
+		"docker/dockerfile:1.1":           false,
+		"docker/dockerfile:1.0.0":         false,
+		"docker/dockerfile:1.1-labs":      true,
+		"docker/dockerfile:experimental":  true,
+		"example.com/custom/frontend:1.0": true,
+	}
+	for syntax, expected := range tests {
+		if got := supportsRunMounts(syntax); got != expected {
+			t.Errorf("supportsRunMounts(%q) = %v; want %v", syntax, got, expected)
+		}
+	}
+}
+
+func TestRun_Secrets(t *testing.T) {
+	df, err := dockerfile.NewDockerfile(`ARG SENTRY_AUTH_TOKEN=abc
+FROM node:22-alpine
+ARG NPM_TOKEN NODE_ENV=production
+ENV NODE_AUTH_TOKEN=$NPM_TOKEN
+ENV API_KEY=hardcoded
+RUN npm ci
+`)
+	if err != nil {
+		t.Fatalf("failed to parse Dockerfile: %v", err)
+	}
+	c := &Context{Dockerfile: df, DockerfilePath: "Dockerfile"}
+	found := []string{}
+	for _, f := range Run(c, models.GoalSecurity) {
+		if f.Code == "DS019" {
+			found = append(found, fmt.Sprintf("%d:%s", f.Line, f.Title))
+		}
+	}
+	expected := []string{
+		"1:Secret SENTRY_AUTH_TOKEN is written in the Dockerfile",
+		"3:Secret NPM_TOKEN is passed as a build argument",
+		"4:Secret NODE_AUTH_TOKEN is stored in the image's environment",
+		"5:Secret API_KEY is written in the Dockerfile",
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected findings %v, got %v", expected, found)
+	}
+	for _, f := range Run(c, models.GoalSize) {
+		if f.Code == "DS019" {
+			t.Errorf("expected DS019 to only run for the security goal")
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package rules
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+// families of system package managers, which share package names
+const (
+	familyApt = "apt"
+	familyApk = "apk"
+	familyYum = "yum"
+)
+
+// bundling describes whether the prebuilt binaries of a package include a system library
+type bundling int
+
+const (
+	// bundledNever means that the package always loads the library from the system
+	bundledNever bundling = iota
+	// bundledGlibc means that prebuilt binaries include the library on glibc distros, but it's built from source on alpine
+	bundledGlibc
+	// bundledAlways means that prebuilt binaries include the library everywhere
+	bundledAlways
+)
+
+// libraryUser is an application dependency that loads a system library at runtime
+type libraryUser struct {
+	// ecosystem is "npm" or "pip"
+	ecosystem string
+	name      string
+	bundled   bundling
+}
+
+// bundles returns true if the prebuilt binaries of the user include the library on the given family of distros
+func (u *libraryUser) bundles(family string) bool {
+	return u.bundled == bundledAlways || (u.bundled == bundledGlibc && family != familyApk)
+}
+
+// runtimeLibrary is a system library that application dependencies need in the final image
+type runtimeLibrary struct {
+	name  string
+	users []*libraryUser
+	// runtime lists the packages that provide the library by family, any one of them is enough
+	runtime map[string][]string
+	// dev lists the packages with the headers to build against the library by family, which aren't needed at runtime
+	dev map[string][]string
+}
+
+var runtimeLibraries = []*runtimeLibrary{
+	{
+		name:    "libvips",
+		users:   []*libraryUser{{"npm", "sharp", bundledAlways}},
+		runtime: map[string][]string{familyApt: {"libvips42", "libvips42t64", "libvips"}, familyApk: {"vips"}, familyYum: {"vips"}},
+		dev:     map[string][]string{familyApt: {"libvips-dev"}, familyApk: {"vips-dev"}, familyYum: {"vips-devel"}},
+	},
+	{
+		name:    "cairo",
+		users:   []*libraryUser{{"npm", "canvas", bundledGlibc}, {"pip", "cairosvg", bundledNever}, {"pip", "pycairo", bundledNever}, {"pip", "weasyprint", bundledNever}},
+		runtime: map[string][]string{familyApt: {"libcairo2"}, familyApk: {"cairo"}, familyYum: {"cairo"}},
+		dev:     map[string][]string{familyApt: {"libcairo2-dev"}, familyApk: {"cairo-dev"}, familyYum: {"cairo-devel"}},
+	},
+	{
+		name:    "pango",
+		users:   []*libraryUser{{"npm", "canvas", bundledGlibc}, {"pip", "weasyprint", bundledNever}},
+		runtime: map[string][]string{familyApt: {"libpango-1.0-0", "libpangocairo-1.0-0"}, familyApk: {"pango"}, familyYum: {"pango"}},
+		dev:     map[string][]string{familyApt: {"libpango1.0-dev"}, familyApk: {"pango-dev"}, familyYum: {"pango-devel"}},
+	},
+	{
+		name:    "libjpeg",
+		users:   []*libraryUser{{"npm", "canvas", bundledGlibc}},
+		runtime: map[string][]string{familyApt: {"libjpeg62-turbo", "libjpeg-turbo8", "libjpeg8"}, familyApk: {"libjpeg-turbo"}, familyYum: {"libjpeg-turbo"}},
+		dev:     map[string][]string{familyApt: {"libjpeg-dev", "libjpeg62-turbo-dev", "libjpeg-turbo8-dev"}, familyApk: {"jpeg-dev", "libjpeg-turbo-dev"}, familyYum: {"libjpeg-turbo-devel"}},
+	},
+	{
+		name:    "giflib",
+		users:   []*libraryUser{{"npm", "canvas", bundledGlibc}},
+		runtime: map[string][]string{familyApt: {"libgif7"}, familyApk: {"giflib"}, familyYum: {"giflib"}},
+		dev:     map[string][]string{familyApt: {"libgif-dev"}, familyApk: {"giflib-dev"}, familyYum: {"giflib-devel"}},
+	},
+	{
+		name:    "libpq",
+		users:   []*libraryUser{{"npm", "pg-native", bundledNever}, {"npm", "libpq", bundledNever}, {"pip", "psycopg2", bundledNever}},
+		runtime: map[string][]string{familyApt: {"libpq5"}, familyApk: {"libpq"}, familyYum: {"libpq", "postgresql-libs"}},
+		dev:     map[string][]string{familyApt: {"libpq-dev"}, familyApk: {"postgresql-dev", "libpq-dev"}, familyYum: {"libpq-devel", "postgresql-devel"}},
+	},
+	{
+		name:    "the MySQL client library",
+		users:   []*libraryUser{{"pip", "mysqlclient", bundledNever}},
+		runtime: map[string][]string{familyApt: {"libmariadb3", "libmysqlclient21"}, familyApk: {"mariadb-connector-c"}, familyYum: {"mariadb-connector-c", "mysql-libs"}},
+		dev:     map[string][]string{familyApt: {"default-libmysqlclient-dev", "libmariadb-dev"}, familyApk: {"mariadb-dev", "mariadb-connector-c-dev"}, familyYum: {"mariadb-devel", "mysql-devel"}},
+	},
+	{
+		name:    "libaio",
+		users:   []*libraryUser{{"npm", "oracledb", bundledNever}},
+		runtime: map[string][]string{familyApt: {"libaio1", "libaio1t64"}, familyApk: {"libaio"}, familyYum: {"libaio"}},
+		dev:     map[string][]string{familyApt: {"libaio-dev"}, familyApk: {"libaio-dev"}, familyYum: {"libaio-devel"}},
+	},
+	{
+		name:    "libldap",
+		users:   []*libraryUser{{"pip", "python-ldap", bundledNever}},
+		runtime: map[string][]string{familyApt: {"libldap-2.5-0", "libldap2", "libldap-2.4-2"}, familyApk: {"libldap", "openldap"}, familyYum: {"openldap"}},
+		dev:     map[string][]string{familyApt: {"libldap2-dev", "libldap-dev"}, familyApk: {"openldap-dev"}, familyYum: {"openldap-devel"}},
+	},
+	{
+		name:    "ImageMagick",
+		users:   []*libraryUser{{"npm", "gm", bundledNever}, {"npm", "imagemagick", bundledNever}, {"pip", "wand", bundledNever}},
+		runtime: map[string][]string{familyApt: {"imagemagick", "graphicsmagick"}, familyApk: {"imagemagick", "graphicsmagick"}, familyYum: {"ImageMagick", "GraphicsMagick"}},
+		dev:     map[string][]string{familyApt: {"libmagickwand-dev"}, familyApk: {"imagemagick-dev"}, familyYum: {"ImageMagick-devel"}},
+	},
+	{
+		name:    "ffmpeg",
+		users:   []*libraryUser{{"npm", "fluent-ffmpeg", bundledNever}},
+		runtime: map[string][]string{familyApt: {"ffmpeg"}, familyApk: {"ffmpeg"}, familyYum: {"ffmpeg"}},
+	},
+}
+
+var (
+	// matches commands that remove system packages, followed by the packages
+	systemRemoveRegex = regexp.MustCompile(`^(apt-get|apt|yum|dnf|microdnf)\s+(.*\s)?(remove|purge)\s+(.*)$|^apk\s+(.*\s)?del\s+(.*)$`)
+	// matches the name of the virtual package apk installs the packages under, which "apk del" removes together
+	apkVirtualRegex = regexp.MustCompile(`(?:--virtual|-t)[= ](\S+)`)
+	// matches the end of the name of a requirement in requirements.txt, eg- "psycopg2>=2.9" or "psycopg2[pool]"
+	requirementEndRegex = regexp.MustCompile(`[\s=<>~!;\[@]`)
+)
+
+// systemPackage is a package installed in the final image
+type systemPackage struct {
+	name   string
+	family string
+	inst   *dockerfile.Instruction
+}
+
+// packageFamily returns the family of the package manager a command runs, eg- "apt" for "apt-get install"
+func packageFamily(command string) string {
+	switch strings.Fields(command)[0] {
+	case "apk":
+		return familyApk
+	case "yum", "dnf", "microdnf":
+		return familyYum
+	}
+	return familyApt
+}
+
+// finalSystemPackages returns the system packages installed in the final image and not removed later on,
+// along with the family of its package manager. The family is guessed from the base image if no package
+// is installed: apk on alpine, apt everywhere else.
+func (c *Context) finalSystemPackages() ([]*systemPackage, string) {
+	stage := finalStage(c.Dockerfile)
+	if stage == nil {
+		return nil, ""
+	}
+	chain := c.stageChain(stage)
+	family := familyApt
+	if strings.Contains(chain[0].BaseImage().FullName(), "alpine") {
+		family = familyApk
+	}
+
+	installed := []*systemPackage{}
+	virtual := map[string][]string{}
+	for _, s := range chain {
+		for _, inst := range stageInstructions(c.Dockerfile, s) {
+			if inst.Cmd() != dockerfile.CmdRun {
+				continue
+			}
+			for _, command := range shellSeparatorRegex.Split(inst.Command(), -1) {
+				command = assignmentRegex.ReplaceAllString(strings.TrimSpace(command), "")
+				if m := systemInstallRegex.FindStringSubmatch(command); m != nil {
+					family = packageFamily(command)
+					packages := m[3]
+					if family == familyApk {
+						packages = m[5]
+					}
+					names := []string{}
+					for _, pkg := range packageArgs(packages) {
+						name, _, _ := strings.Cut(pkg, "=")
+						name, _, _ = strings.Cut(name, ">")
+						names = append(names, name)
+						installed = append(installed, &systemPackage{name: name, family: family, inst: inst})
+					}
+					if v := apkVirtualRegex.FindStringSubmatch(command); v != nil && family == familyApk {
+						virtual[v[1]] = names
+					}
+					continue
+				}
+				if m := systemRemoveRegex.FindStringSubmatch(command); m != nil {
+					removed := map[string]bool{}
+					args := m[4]
+					if strings.HasPrefix(command, "apk") {
+						args = m[6]
+					}
+					for _, name := range packageArgs(args) {
+						removed[name] = true
+						for _, pkg := range virtual[name] {
+							removed[pkg] = true
+						}
+					}
+					kept := installed[:0]
+					for _, p := range installed {
+						if !removed[p.name] {
+							kept = append(kept, p)
+						}
+					}
+					installed = kept
+				}
+			}
+		}
+	}
+	return installed, family
+}
+
+// applicationDependencies returns the production dependencies of the application by ecosystem,
+// read from package.json and requirements.txt, along with the native modules in the npm lockfile
+func (c *Context) applicationDependencies() map[string]map[string]bool {
+	deps := map[string]map[string]bool{"npm": {}, "pip": {}}
+	if c.PackageJSON != nil {
+		for name := range c.PackageJSON.GetDependencies() {
+			deps["npm"][name] = true
+		}
+	}
+	// dependencies of dependencies load libraries too, eg- sharp installed for an image processing library
+	for _, m := range NativeModules(c) {
+		deps["npm"][m.Name] = true
+	}
+	if c.ProjectDir == nil {
+		return deps
+	}
+	f, err := c.ProjectDir.Open("requirements.txt")
+	if err != nil {
+		return deps
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "-") {
+			continue
+		}
+		if i := requirementEndRegex.FindStringIndex(line); i != nil {
+			line = line[:i[0]]
+		}
+		deps["pip"][strings.ReplaceAll(strings.ToLower(line), "_", "-")] = true
+	}
+	return deps
+}
+
+// usedBy returns the dependencies of the application that load the library
+func (l *runtimeLibrary) usedBy(deps map[string]map[string]bool) []*libraryUser {
+	users := []*libraryUser{}
+	for _, u := range l.users {
+		if deps[u.ecosystem][u.name] {
+			users = append(users, u)
+		}
+	}
+	return users
+}
+
+func userNames(users []*libraryUser) string {
+	names := make([]string, 0, len(users))
+	for _, u := range users {
+		names = append(names, u.name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// installsAny returns true if any of the packages is one of the given names
+func installsAny(packages []*systemPackage, names []string) bool {
+	for _, p := range packages {
+		if isOneOf(p.name, names) {
+			return true
+		}
+	}
+	return false
+}
+
+func isOneOf(name string, names []string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// missingLibrary is a system library the final image doesn't have, along with the dependencies that need it
+type missingLibrary struct {
+	lib    *runtimeLibrary
+	needed []*libraryUser
+}
+
+// missingRuntimeLibraries returns the system libraries the application's dependencies load at runtime, which
+// the final stage doesn't install, along with the family of its package manager. Only images based on minimal
+// variants are checked, since the full images ship most common libraries.
+func (c *Context) missingRuntimeLibraries() ([]*missingLibrary, string) {
+	stage := finalStage(c.Dockerfile)
+	if stage == nil {
+		return nil, ""
+	}
+	base := c.stageChain(stage)[0].BaseImage()
+	if !base.IsLightweight() || c.baseIsNamedContext(c.stageChain(stage)[0]) {
+		return nil, ""
+	}
+	installed, family := c.finalSystemPackages()
+	deps := c.applicationDependencies()
+
+	missing := []*missingLibrary{}
+	for _, lib := range runtimeLibraries {
+		needed := []*libraryUser{}
+		for _, u := range lib.usedBy(deps) {
+			if !u.bundles(family) {
+				needed = append(needed, u)
+			}
+		}
+		// development packages depend on the runtime ones
+		if len(needed) == 0 || installsAny(installed, lib.runtime[family]) || installsAny(installed, lib.dev[family]) {
+			continue
+		}
+		missing = append(missing, &missingLibrary{lib: lib, needed: needed})
+	}
+	return missing, family
+}
+
+// MissingRuntimePackages returns the system packages the final stage has to install for the libraries the
+// application's dependencies load at runtime, along with the family of its package manager, eg- "apk"
+func MissingRuntimePackages(c *Context) ([]string, string) {
+	missing, family := c.missingRuntimeLibraries()
+	packages := []string{}
+	for _, m := range missing {
+		if pkgs := m.lib.runtime[family]; len(pkgs) > 0 && !isOneOf(pkgs[0], packages) {
+			packages = append(packages, pkgs[0])
+		}
+	}
+	return packages, family
+}
+
+var ruleMissingRuntimeLibrary = &Rule{
+	ID:          "DS020",
+	Name:        "missing-runtime-library",
+	Description: "The final image lacks a shared library a native module or binary needs at runtime, so the application fails when it loads it.",
+	Severity:    models.SeverityMedium,
+	Goals:       []models.Goal{models.GoalSize, models.GoalBuildSpeed, models.GoalSecurity},
+	Check: func(c *Context) []*models.Finding {
+		missing, family := c.missingRuntimeLibraries()
+		if len(missing) == 0 {
+			return nil
+		}
+		stage := finalStage(c.Dockerfile)
+		base := c.stageChain(stage)[0].BaseImage()
+
+		findings := []*models.Finding{}
+		for _, m := range missing {
+			install := fmt.Sprintf("Install '%s' in the final stage", m.lib.runtime[family][0])
+			if strings.Contains(base.FullName(), "distroless") {
+				install = fmt.Sprintf("Copy it from a build stage that installs '%s', or use a base image that has a package manager", m.lib.runtime[family][0])
+			}
+			findings = append(findings, &models.Finding{
+				Filepath:    c.DockerfilePath,
+				Line:        stage.StartLine(),
+				Title:       fmt.Sprintf("Final image doesn't have %s, which %s needs at runtime", m.lib.name, userNames(m.needed)),
+				Description: fmt.Sprintf("%s loads %s when the application runs, but the final stage doesn't install it and the minimal base image %s doesn't include it, so the application is likely to fail when it's first used. %s.", userNames(m.needed), m.lib.name, base.FullName(), install),
+			})
+		}
+		return findings
+	},
+}
+
+var ruleUnneededSystemPackage = &Rule{
+	ID:          "DS021",
+	Name:        "unneeded-system-package",
+	Description: "The final image has system packages the application doesn't seem to need at runtime, eg- development headers or libraries bundled with npm packages.",
+	Severity:    models.SeverityLow,
+	Goals:       []models.Goal{models.GoalSize, models.GoalSecurity},
+	Check: func(c *Context) []*models.Finding {
+		installed, _ := c.finalSystemPackages()
+		if len(installed) == 0 {
+			return nil
+		}
+		deps := c.applicationDependencies()
+
+		findings := []*models.Finding{}
+		for _, p := range installed {
+			for _, lib := range runtimeLibraries {
+				users := lib.usedBy(deps)
+				switch {
+				case isOneOf(p.name, lib.dev[p.family]):
+					runtime := "its runtime package"
+					if len(lib.runtime[p.family]) > 0 {
+						runtime = "'" + lib.runtime[p.family][0] + "'"
+					}
+					findings = append(findings, &models.Finding{
+						Filepath:            c.DockerfilePath,
+						Line:                p.inst.StartLine(),
+						Title:               fmt.Sprintf("Final image has the development files of %s", lib.name),
+						Description:         fmt.Sprintf("'%s' contains headers and tools to build against %s, which are only needed while compiling native modules. Compile them in a build stage and only install %s in the final stage.", p.name, lib.name, runtime),
+						EstimatedSizeImpact: systemPackageSize(p.name),
+					})
+				case !isOneOf(p.name, lib.runtime[p.family]):
+				case len(users) == 0:
+					findings = append(findings, &models.Finding{
+						Filepath:            c.DockerfilePath,
+						Line:                p.inst.StartLine(),
+						Title:               fmt.Sprintf("'%s' doesn't seem to be needed at runtime", p.name),
+						Description:         fmt.Sprintf("None of the application's dependencies are known to load %s. Remove '%s' unless the application uses it directly, eg- by running it as a command.", lib.name, p.name),
+						EstimatedSizeImpact: systemPackageSize(p.name),
+					})
+				case !lib.needed(users, p.family):
+					findings = append(findings, &models.Finding{
+						Filepath:            c.DockerfilePath,
+						Line:                p.inst.StartLine(),
+						Title:               fmt.Sprintf("'%s' duplicates the %s bundled with %s", p.name, lib.name, userNames(users)),
+						Description:         fmt.Sprintf("The prebuilt binaries of %s include their own copy of %s, so '%s' is only needed if they are built from source.", userNames(users), lib.name, p.name),
+						EstimatedSizeImpact: systemPackageSize(p.name),
+					})
+				}
+			}
+		}
+		return findings
+	},
+}
+
+// needed returns true if any of the users loads the library from the system on the given family of distros
+func (l *runtimeLibrary) needed(users []*libraryUser, family string) bool {
+	for _, u := range users {
+		if !u.bundles(family) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package rules
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/packagejson"
+)
+
+func TestRun_RuntimeLibraries(t *testing.T) {
+	tests := []struct {
+		name         string
+		code         string
+		dependencies string
+		requirements string
+		expected     []string
+	}{
+		{
+			name: "missing libraries on alpine",
+			code: `FROM node:22-alpine AS build
+RUN apk add --no-cache cairo-dev pango-dev jpeg-dev giflib-dev
+RUN npm ci
+
+FROM node:22-alpine
+RUN apk add --no-cache cairo
+COPY --from=build /app /app
+`,
+			dependencies: `{"canvas": "2", "sharp": "0.33", "pg-native": "3"}`,
+			expected:     []string{"DS020:5:giflib", "DS020:5:libjpeg", "DS020:5:libpq", "DS020:5:pango"},
+		},
+		{
+			name: "prebuilt binaries bundle the libraries on debian",
+			code: `FROM node:22-slim
+RUN apt-get update && apt-get install -y --no-install-recommends libvips42 libpq-dev && rm -rf /var/lib/apt/lists/*
+`,
+			dependencies: `{"canvas": "2", "sharp": "0.33", "pg-native": "3"}`,
+			expected:     []string{"DS021:2:libpq", "DS021:2:libvips"},
+		},
+		{
+			name: "python requirements",
+			code: `FROM python:3.12-slim
+RUN apt-get update && apt-get install -y ffmpeg
+`,
+			requirements: "# database\npsycopg2==2.9.9\nDjango>=5\n",
+			expected:     []string{"DS020:1:libpq", "DS021:2:ffmpeg"},
+		},
+		{
+			name: "build dependencies removed in the same stage",
+			code: `FROM node:22-alpine
+RUN apk add --no-cache libpq && apk add --no-cache --virtual .build-deps postgresql-dev python3 make g++ \
+    && npm ci && apk del .build-deps
+`,
+			dependencies: `{"pg-native": "3"}`,
+			expected:     []string{},
+		},
+		{
+			name: "full images aren't checked for missing libraries",
+			code: `FROM node:22
+RUN npm ci
+`,
+			dependencies: `{"pg-native": "3"}`,
+			expected:     []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.code)
+			if err != nil {
+				t.Fatalf("failed to parse Dockerfile: %v", err)
+			}
+			c := &Context{Dockerfile: df, DockerfilePath: "Dockerfile", ProjectDir: fstest.MapFS{}}
+			if tt.dependencies != "" {
+				if c.PackageJSON, err = packagejson.NewPackageJSON(`{"dependencies": ` + tt.dependencies + `}`); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.requirements != "" {
+				c.ProjectDir = fstest.MapFS{"requirements.txt": {Data: []byte(tt.requirements)}}
+			}
+
+			found := []string{}
+			for _, f := range Run(c, models.GoalAll) {
+				if f.Code != "DS020" && f.Code != "DS021" {
+					continue
+				}
+				for _, lib := range runtimeLibraries {
+					if strings.Contains(f.Title, lib.name) {
+						found = append(found, fmt.Sprintf("%s:%d:%s", f.Code, f.Line, lib.name))
+						break
+					}
+				}
+			}
+			sort.Strings(found)
+			if !reflect.DeepEqual(found, tt.expected) {
+				t.Errorf("expected findings %v, got %v", tt.expected, found)
+			}
+		})
+	}
+}
@@ -0,0 +1,540 @@
+package rules
+
+import (
+	"path"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// wrappers run the command given as their arguments, eg- "sudo apt-get install"
+var wrappers = map[string]bool{"sudo": true, "env": true, "exec": true, "command": true, "nohup": true, "time": true}
+
+// interpreters are the commands that run a script piped into them
+var interpreters = map[string]bool{
+	"sh": true, "bash": true, "ash": true, "dash": true, "zsh": true, "ksh": true,
+	"python": true, "python3": true, "perl": true, "ruby": true, "node": true, "php": true,
+}
+
+// curlValueFlags and wgetValueFlags are the options taking a value as the next word, besides the output file
+var (
+	curlValueFlags = map[string]bool{
+		"-H": true, "--header": true, "-u": true, "--user": true, "-d": true, "--data": true, "--data-binary": true,
+		"--data-raw": true, "-X": true, "--request": true, "--retry": true, "--retry-delay": true, "--retry-max-time": true,
+		"-m": true, "--max-time": true, "--connect-timeout": true, "--proto": true, "--proto-redir": true, "-A": true,
+		"--user-agent": true, "--cacert": true, "-E": true, "--cert": true, "--key": true, "-K": true, "--config": true,
+		"-b": true, "--cookie": true, "-e": true, "--referer": true, "-F": true, "--form": true, "-T": true,
+		"--upload-file": true, "--limit-rate": true, "-r": true, "--range": true, "--resolve": true, "-w": true,
+		"--write-out": true, "-x": true, "--proxy": true, "-C": true, "--continue-at": true, "-y": true, "-Y": true,
+		"-z": true, "-U": true, "-t": true, "-Q": true, "-P": true,
+	}
+	wgetValueFlags = map[string]bool{
+		"-o": true, "-a": true, "-t": true, "-T": true, "-w": true, "-U": true, "-e": true, "-i": true, "-B": true,
+		"-Q": true, "-l": true, "-A": true, "-R": true, "-D": true, "-I": true, "-X": true,
+	}
+)
+
+// Script is a shell script run by a RUN instruction, parsed with mvdan.cc/sh so that rules can tell the commands
+// of the script apart instead of matching its text, eg- an "apt-get install" from an "echo apt-get install"
+type Script struct {
+	File *syntax.File
+	src  string
+	// commands are the simple commands of the script by their node, in the order they're written
+	commands []*Command
+	calls    map[*syntax.CallExpr]*Command
+}
+
+// Command is a simple command of a script, eg- "apt-get install -y curl"
+type Command struct {
+	Call *syntax.CallExpr
+	// Assignments are the variables set for the command as written, eg- "DEBIAN_FRONTEND=noninteractive"
+	Assignments []string
+	// Args are the words of the command with quotes removed, eg- "apt-get", "install", "-y" and "curl".
+	// Expansions are kept as written, eg- "$HOME" or "$(nproc)".
+	Args []string
+	// ArgEnds are the offsets in the script where each of the Args ends
+	ArgEnds []int
+	// Pos is the offset of the command in the script
+	Pos int
+}
+
+// ParseScript parses a shell script, bash extensions included
+func ParseScript(src string) (*Script, error) {
+	f, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(src), "")
+	if err != nil {
+		return nil, err
+	}
+	s := &Script{File: f, src: src, calls: map[*syntax.CallExpr]*Command{}}
+	syntax.Walk(f, func(node syntax.Node) bool {
+		switch n := node.(type) {
+		case *syntax.CmdSubst, *syntax.ProcSubst:
+			// the output of a command substitution is an argument of the command that runs it
+			return false
+		case *syntax.CallExpr:
+			s.commands = append(s.commands, s.command(n))
+		}
+		return true
+	})
+	return s, nil
+}
+
+// Statements returns the top-level statements of the script, in order
+func (s *Script) Statements() []*syntax.Stmt {
+	return s.File.Stmts
+}
+
+// Code returns the code of a statement of the script, without the ";" or "&" that ends it
+func (s *Script) Code(st *syntax.Stmt) string {
+	return s.src[st.Pos().Offset():StmtEnd(st)]
+}
+
+// StmtEnd returns the offset where a statement ends, without the ";" or "&" that ends it
+func StmtEnd(st *syntax.Stmt) int {
+	unterminated := *st
+	unterminated.Semicolon = syntax.Pos{}
+	return int(unterminated.End().Offset())
+}
+
+// Commands returns the simple commands of the script, including those run by compound commands,
+// in the order they're written. Commands run by command substitutions are left out.
+func (s *Script) Commands() []*Command {
+	return s.commands
+}
+
+// command returns the command of a node of the script
+func (s *Script) command(call *syntax.CallExpr) *Command {
+	if c, ok := s.calls[call]; ok {
+		return c
+	}
+	c := &Command{Call: call, Pos: int(call.Pos().Offset())}
+	for _, a := range call.Assigns {
+		c.Assignments = append(c.Assignments, s.src[a.Pos().Offset():a.End().Offset()])
+	}
+	for _, w := range call.Args {
+		c.Args = append(c.Args, s.word(w))
+		c.ArgEnds = append(c.ArgEnds, int(w.End().Offset()))
+	}
+	s.calls[call] = c
+	return c
+}
+
+// word returns a word with quotes removed. Expansions are kept as written.
+func (s *Script) word(w *syntax.Word) string {
+	var b strings.Builder
+	s.writeParts(&b, w.Parts, false)
+	return b.String()
+}
+
+func (s *Script) writeParts(b *strings.Builder, parts []syntax.WordPart, quoted bool) {
+	for _, part := range parts {
+		switch p := part.(type) {
+		case *syntax.Lit:
+			b.WriteString(unescape(p.Value, quoted))
+		case *syntax.SglQuoted:
+			if p.Dollar {
+				b.WriteString(s.src[p.Pos().Offset():p.End().Offset()])
+			} else {
+				b.WriteString(p.Value)
+			}
+		case *syntax.DblQuoted:
+			s.writeParts(b, p.Parts, true)
+		default:
+			b.WriteString(s.src[p.Pos().Offset():p.End().Offset()])
+		}
+	}
+}
+
+// unescape removes the backslashes of a literal. Within double quotes, backslashes only escape $, `, ", \ and new lines.
+func unescape(lit string, quoted bool) string {
+	if !strings.Contains(lit, "\\") {
+		return lit
+	}
+	var b strings.Builder
+	for i := 0; i < len(lit); i++ {
+		if lit[i] != '\\' || i == len(lit)-1 {
+			b.WriteByte(lit[i])
+			continue
+		}
+		next := lit[i+1]
+		if quoted && !strings.ContainsRune("$`\"\\\n", rune(next)) {
+			b.WriteByte(lit[i])
+			continue
+		}
+		i++
+		if next != '\n' {
+			b.WriteByte(next)
+		}
+	}
+	return b.String()
+}
+
+// Argv returns the arguments of the command that actually runs, leaving out wrappers like sudo or env
+// along with their flags and variables
+func (c *Command) Argv() []string {
+	args := c.Args
+	for len(args) > 0 && wrappers[path.Base(args[0])] {
+		args = args[1:]
+		for len(args) > 0 && (strings.HasPrefix(args[0], "-") || strings.Contains(args[0], "=")) {
+			args = args[1:]
+		}
+	}
+	return args
+}
+
+// Name returns the name of the command that actually runs, eg- "apt-get" for "sudo /usr/bin/apt-get install"
+func (c *Command) Name() string {
+	argv := c.Argv()
+	if len(argv) == 0 {
+		return ""
+	}
+	return path.Base(argv[0])
+}
+
+// HasArg returns true if one of the arguments of the command is arg
+func (c *Command) HasArg(arg string) bool {
+	for _, a := range c.Argv() {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
+
+// setsErrexit returns true for "set -e", "set -eux" or "set -o errexit"
+func (c *Command) setsErrexit() bool {
+	if c.Name() != "set" {
+		return false
+	}
+	args := c.Argv()[1:]
+	for i, a := range args {
+		if strings.HasPrefix(a, "-") && !strings.HasPrefix(a, "--") && strings.ContainsRune(a, 'e') {
+			return true
+		}
+		if a == "-o" && i+1 < len(args) && args[i+1] == "errexit" {
+			return true
+		}
+	}
+	return false
+}
+
+// simpleCommand returns the command of a statement if it's a simple command, nil otherwise
+func (s *Script) simpleCommand(st *syntax.Stmt) *Command {
+	if call, ok := st.Cmd.(*syntax.CallExpr); ok {
+		return s.command(call)
+	}
+	return nil
+}
+
+// IgnoredFailures returns the top-level statements whose failure doesn't fail the script: those followed by
+// ";" or a new line, since the script goes on with the next statement, unless errexit was turned on before
+// with "set -e". Statements that can't fail, eg- "true", and those ending with a "||" fallback are left out.
+func (s *Script) IgnoredFailures() []*syntax.Stmt {
+	ignored := []*syntax.Stmt{}
+	statements := s.Statements()
+	for i, st := range statements {
+		c := s.simpleCommand(st)
+		if c != nil && c.setsErrexit() {
+			return ignored
+		}
+		if st.Background || i == len(statements)-1 {
+			continue
+		}
+		if b, ok := st.Cmd.(*syntax.BinaryCmd); ok && b.Op == syntax.OrStmt {
+			continue
+		}
+		if c != nil {
+			switch c.Name() {
+			case "true", ":", "set":
+				continue
+			}
+		}
+		ignored = append(ignored, st)
+	}
+	return ignored
+}
+
+// RemoteScript is a script that's downloaded and run in one go, eg- "curl -fsSL https://deb.nodesource.com/setup_22.x | bash -"
+type RemoteScript struct {
+	// URL is the address of the script as written, eg- "$INSTALLER_URL", empty if it couldn't be found
+	URL string
+	// Command is the curl or wget command that downloads the script
+	Command *Command
+}
+
+// RemoteScripts returns the scripts downloaded with curl or wget and piped into a shell or another
+// interpreter, or run with eg- sh -c "$(curl -fsSL https://get.docker.com)"
+func (s *Script) RemoteScripts() []*RemoteScript {
+	scripts := []*RemoteScript{}
+	// the pipelines within a pipeline, eg- "b | c" in "a | b | c", which are checked along with it
+	inner := map[*syntax.BinaryCmd]bool{}
+	syntax.Walk(s.File, func(node syntax.Node) bool {
+		switch n := node.(type) {
+		case *syntax.BinaryCmd:
+			if (n.Op != syntax.Pipe && n.Op != syntax.PipeAll) || inner[n] {
+				return true
+			}
+			pipeline := s.pipeline(n, inner)
+			for i, c := range pipeline {
+				if c.Name() != "curl" && c.Name() != "wget" {
+					continue
+				}
+				for _, next := range pipeline[i+1:] {
+					if interpreters[next.Name()] {
+						scripts = append(scripts, &RemoteScript{URL: download(c).URL, Command: c})
+						break
+					}
+				}
+			}
+		case *syntax.CallExpr:
+			if c := s.command(n); interpreters[c.Name()] {
+				scripts = append(scripts, s.substitutedScripts(n)...)
+			}
+		}
+		return true
+	})
+	return scripts
+}
+
+// pipeline returns the simple commands of a pipeline, in order, and marks the pipelines within it as inner
+func (s *Script) pipeline(b *syntax.BinaryCmd, inner map[*syntax.BinaryCmd]bool) []*Command {
+	commands := []*Command{}
+	for _, st := range []*syntax.Stmt{b.X, b.Y} {
+		switch cmd := st.Cmd.(type) {
+		case *syntax.BinaryCmd:
+			if cmd.Op == syntax.Pipe || cmd.Op == syntax.PipeAll {
+				inner[cmd] = true
+				commands = append(commands, s.pipeline(cmd, inner)...)
+			}
+		case *syntax.CallExpr:
+			commands = append(commands, s.command(cmd))
+		}
+	}
+	return commands
+}
+
+// substitutedScripts returns the scripts downloaded by the command substitutions in the arguments of an
+// interpreter, eg- sh -c "$(curl -fsSL https://get.docker.com)"
+func (s *Script) substitutedScripts(call *syntax.CallExpr) []*RemoteScript {
+	scripts := []*RemoteScript{}
+	for _, w := range call.Args[1:] {
+		syntax.Walk(w, func(node syntax.Node) bool {
+			subst, ok := node.(*syntax.CmdSubst)
+			if !ok {
+				return true
+			}
+			syntax.Walk(subst, func(node syntax.Node) bool {
+				if n, ok := node.(*syntax.CallExpr); ok {
+					if c := s.command(n); c.Name() == "curl" || c.Name() == "wget" {
+						scripts = append(scripts, &RemoteScript{URL: download(c).URL, Command: c})
+					}
+				}
+				return true
+			})
+			return false
+		})
+	}
+	return scripts
+}
+
+// Download is a file downloaded with curl or wget
+type Download struct {
+	URL string
+	// File is the path the file is saved to, eg- "/tmp/node.tar.xz". Relative paths are resolved against
+	// the directory the script changed to before, if any.
+	File    string
+	Command *Command
+}
+
+// Downloads returns the files the script downloads with curl or wget. Downloads written to stdout,
+// eg- "curl -fsSL $URL | tar -xz", aren't files and are left out.
+func (s *Script) Downloads() []*Download {
+	downloads := []*Download{}
+	// relative paths are resolved against the last directory changed to, eg- with "cd /tmp"
+	dir := ""
+	for _, c := range s.Commands() {
+		var d *Download
+		switch c.Name() {
+		case "cd":
+			if argv := c.Argv(); len(argv) == 2 && path.IsAbs(argv[1]) {
+				dir = argv[1]
+			}
+		case "curl", "wget":
+			d = download(c)
+		}
+		if d == nil || d.File == "" || d.File == "-" || d.File == "/dev/null" {
+			continue
+		}
+		if dir != "" && !path.IsAbs(d.File) && !strings.HasPrefix(d.File, "$") {
+			d.File = path.Join(dir, d.File)
+		}
+		downloads = append(downloads, d)
+	}
+	return downloads
+}
+
+// Removes returns true if a command of the script running after the given one removes file with rm,
+// eg- "rm -f /tmp/node.tar.xz", "rm -rf /tmp" or "rm *.tar.gz"
+func (s *Script) Removes(file string, after *Command) bool {
+	file = path.Clean(file)
+	for _, c := range s.Commands() {
+		if c.Pos <= after.Pos || c.Name() != "rm" {
+			continue
+		}
+		for _, a := range c.Argv()[1:] {
+			if strings.HasPrefix(a, "-") {
+				continue
+			}
+			if removes(path.Clean(a), file) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// removes returns true if removing target removes file. Relative paths are compared by their end, since
+// the directory they're relative to isn't known.
+func removes(target, file string) bool {
+	if target == file || strings.HasPrefix(file, target+"/") {
+		return true
+	}
+	if ok, _ := path.Match(target, file); ok {
+		return true
+	}
+	if path.IsAbs(target) && path.IsAbs(file) {
+		return false
+	}
+	if strings.HasSuffix(file, "/"+target) || strings.HasSuffix(target, "/"+file) {
+		return true
+	}
+	ok, _ := path.Match(path.Base(target), path.Base(file))
+	return ok && (!path.IsAbs(target) || !strings.Contains(file, "/"))
+}
+
+// remoteName returns the name a file downloaded from url is saved with by default
+func remoteName(url string) string {
+	url, _, _ = strings.Cut(url, "?")
+	url, _, _ = strings.Cut(url, "#")
+	if _, rest, ok := strings.Cut(url, "://"); ok {
+		url = rest
+	}
+	if i := strings.Index(url, "/"); i < 0 || i == len(url)-1 {
+		return ""
+	}
+	return path.Base(url)
+}
+
+// download returns what a curl or wget command downloads
+func download(c *Command) *Download {
+	if c.Name() == "wget" {
+		return wgetDownload(c)
+	}
+	return curlDownload(c)
+}
+
+func curlDownload(c *Command) *Download {
+	d := &Download{Command: c}
+	remote := false
+	args := c.Argv()[1:]
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "-o" || a == "--output":
+			if i+1 < len(args) {
+				d.File = args[i+1]
+				i++
+			}
+		case a == "-O" || a == "--remote-name":
+			remote = true
+		case a == "--url":
+			if i+1 < len(args) {
+				d.URL = args[i+1]
+				i++
+			}
+		case curlValueFlags[a]:
+			i++
+		case strings.HasPrefix(a, "-") && !strings.HasPrefix(a, "--"):
+			// combined short options, eg- "-fsSLo" or "-fsSLO", where o takes the rest of the word or the next one
+			for j := 1; j < len(a); j++ {
+				if a[j] == 'O' {
+					remote = true
+					continue
+				}
+				if a[j] != 'o' && !curlValueFlags["-"+string(a[j])] {
+					continue
+				}
+				value := a[j+1:]
+				if value == "" && i+1 < len(args) {
+					i++
+					value = args[i]
+				}
+				if a[j] == 'o' {
+					d.File = value
+				}
+				break
+			}
+		case !strings.HasPrefix(a, "-") && d.URL == "":
+			d.URL = a
+		}
+	}
+	if d.File == "" && remote {
+		d.File = remoteName(d.URL)
+	}
+	return d
+}
+
+func wgetDownload(c *Command) *Download {
+	d := &Download{Command: c}
+	dir := ""
+	output := false
+	args := c.Argv()[1:]
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "-O" || a == "--output-document":
+			if i+1 < len(args) {
+				d.File, output = args[i+1], true
+				i++
+			}
+		case strings.HasPrefix(a, "--output-document="):
+			d.File, output = strings.TrimPrefix(a, "--output-document="), true
+		case a == "-P" || a == "--directory-prefix":
+			if i+1 < len(args) {
+				dir = args[i+1]
+				i++
+			}
+		case strings.HasPrefix(a, "--directory-prefix="):
+			dir = strings.TrimPrefix(a, "--directory-prefix=")
+		case wgetValueFlags[a]:
+			i++
+		case strings.HasPrefix(a, "-") && !strings.HasPrefix(a, "--"):
+			// combined short options, eg- "-qO-" or "-qO /tmp/node.tar.xz"
+			for j := 1; j < len(a); j++ {
+				if a[j] != 'O' && a[j] != 'P' && !wgetValueFlags["-"+string(a[j])] {
+					continue
+				}
+				value := a[j+1:]
+				if value == "" && i+1 < len(args) {
+					i++
+					value = args[i]
+				}
+				switch a[j] {
+				case 'O':
+					d.File, output = value, true
+				case 'P':
+					dir = value
+				}
+				break
+			}
+		case !strings.HasPrefix(a, "-") && d.URL == "":
+			d.URL = a
+		}
+	}
+	if !output {
+		d.File = remoteName(d.URL)
+		if d.File != "" && dir != "" {
+			d.File = path.Join(dir, d.File)
+		}
+	}
+	return d
+}
@@ -0,0 +1,245 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+)
+
+// commandArgs returns the args of the simple commands of the script
+func commandArgs(s *Script) [][]string {
+	args := [][]string{}
+	for _, c := range s.Commands() {
+		args = append(args, c.Args)
+	}
+	return args
+}
+
+func TestParseScript(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     string
+		commands   [][]string
+		statements []string
+	}{
+		{
+			name:       "list",
+			script:     "apt-get update &&     apt-get install -y curl ;     echo done",
+			commands:   [][]string{{"apt-get", "update"}, {"apt-get", "install", "-y", "curl"}, {"echo", "done"}},
+			statements: []string{"apt-get update &&     apt-get install -y curl", "echo done"},
+		},
+		{
+			name:       "quotes and expansions",
+			script:     `echo "apt-get install $PKG" 'a && b' \; $(nproc) ${HOME}/x "$(echo "nested")" "a\"b\c"`,
+			commands:   [][]string{{"echo", "apt-get install $PKG", "a && b", ";", "$(nproc)", "${HOME}/x", `$(echo "nested")`, `a"b\c`}},
+			statements: []string{`echo "apt-get install $PKG" 'a && b' \; $(nproc) ${HOME}/x "$(echo "nested")" "a\"b\c"`},
+		},
+		{
+			name:       "pipelines, redirections and assignments",
+			script:     "DEBIAN_FRONTEND=noninteractive apt-get install -y curl 2>&1 >/dev/null | tee log || true",
+			commands:   [][]string{{"apt-get", "install", "-y", "curl"}, {"tee", "log"}, {"true"}},
+			statements: []string{"DEBIAN_FRONTEND=noninteractive apt-get install -y curl 2>&1 >/dev/null | tee log || true"},
+		},
+		{
+			name:       "line continuations and comments",
+			script:     "npm ci \\\n  --omit=dev # production only\nnpm cache clean --force",
+			commands:   [][]string{{"npm", "ci", "--omit=dev"}, {"npm", "cache", "clean", "--force"}},
+			statements: []string{"npm ci \\\n  --omit=dev", "npm cache clean --force"},
+		},
+		{
+			name:   "compound commands",
+			script: "if [ -f yarn.lock ]; then yarn install; elif true; then :; else npm ci; fi; for f in a b; do rm \"$f\"; done\ncase $ARCH in\n  amd64|x86_64) A=x64 ;;\n  *) exit 1 ;;\nesac\n(cd /app && make) && { echo ok; }",
+			commands: [][]string{
+				{"[", "-f", "yarn.lock", "]"}, {"yarn", "install"}, {"true"}, {":"}, {"npm", "ci"},
+				{"rm", "$f"}, nil, {"exit", "1"}, {"cd", "/app"}, {"make"}, {"echo", "ok"},
+			},
+			statements: []string{
+				"if [ -f yarn.lock ]; then yarn install; elif true; then :; else npm ci; fi", "for f in a b; do rm \"$f\"; done",
+				"case $ARCH in\n  amd64|x86_64) A=x64 ;;\n  *) exit 1 ;;\nesac", "(cd /app && make) && { echo ok; }",
+			},
+		},
+		{
+			name:       "bash extensions",
+			script:     "[[ -f yarn.lock ]] && yarn install; for ((i=0; i<3; i++)); do echo \"$i\"; done",
+			commands:   [][]string{{"yarn", "install"}, {"echo", "$i"}},
+			statements: []string{"[[ -f yarn.lock ]] && yarn install", "for ((i=0; i<3; i++)); do echo \"$i\"; done"},
+		},
+		{
+			name:       "here-documents",
+			script:     "cat <<-EOF > /etc/app.conf\n\tport=3000 && x ; y\n\tEOF\nnode index.js",
+			commands:   [][]string{{"cat"}, {"node", "index.js"}},
+			statements: []string{"cat <<-EOF > /etc/app.conf", "node index.js"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseScript(tt.script)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := commandArgs(s); !reflect.DeepEqual(got, tt.commands) {
+				t.Errorf("unexpected commands:\n%q\nwant:\n%q", got, tt.commands)
+			}
+			statements := []string{}
+			for _, st := range s.Statements() {
+				statements = append(statements, s.Code(st))
+			}
+			if !reflect.DeepEqual(statements, tt.statements) {
+				t.Errorf("unexpected statements %q, want %q", statements, tt.statements)
+			}
+		})
+	}
+}
+
+func TestParseScript_Positions(t *testing.T) {
+	script := "cd /app ;  npm ci && echo ok"
+	s, err := ParseScript(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := s.Statements()[0]
+	if got := script[first.Pos().Offset():StmtEnd(first)]; got != "cd /app" {
+		t.Errorf("unexpected statement %q", got)
+	}
+	if script[first.Semicolon.Offset()] != ';' {
+		t.Errorf("expected the operator at %d, got %q", first.Semicolon.Offset(), script[first.Semicolon.Offset()])
+	}
+	if ends := s.Commands()[1].ArgEnds; !reflect.DeepEqual(ends, []int{14, 17}) {
+		t.Errorf("unexpected ends of the args %v", ends)
+	}
+}
+
+func TestParseScript_Errors(t *testing.T) {
+	for _, script := range []string{"echo 'unterminated", "echo \"a\nb", "echo $(nproc", "apt-get update &&", "if true; then\n  echo", "echo a; ; echo b"} {
+		t.Run(script, func(t *testing.T) {
+			if _, err := ParseScript(script); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestCommand_Name(t *testing.T) {
+	s, _ := ParseScript("sudo -E /usr/bin/apt-get install -y curl; env DEBIAN_FRONTEND=noninteractive apt install vim")
+	names := []string{}
+	for _, c := range s.Commands() {
+		names = append(names, c.Name())
+	}
+	if !reflect.DeepEqual(names, []string{"apt-get", "apt"}) {
+		t.Errorf("unexpected names %q", names)
+	}
+	if !s.Commands()[0].HasArg("install") {
+		t.Error("expected the install argument")
+	}
+}
+
+func TestScript_IgnoredFailures(t *testing.T) {
+	tests := []struct {
+		script   string
+		expected []string
+	}{
+		{script: "cd /app; npm ci; npm run build", expected: []string{"cd /app", "npm ci"}},
+		{script: "cd /app && npm ci", expected: []string{}},
+		{script: "cd /app && npm ci\nnpm run build", expected: []string{"cd /app && npm ci"}},
+		{script: "set -eux\napk add curl\nnpm ci", expected: []string{}},
+		{script: "apk add curl\nset -o errexit\nnpm ci\nnpm test", expected: []string{"apk add curl"}},
+		{script: "rm -rf /tmp/* || true; npm ci", expected: []string{}},
+		{script: "npm ci;", expected: []string{}},
+		{script: "npm start & npm test", expected: []string{}},
+		{script: "if [ -f x ]; then a; b; fi; c", expected: []string{"if [ -f x ]; then a; b; fi"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.script, func(t *testing.T) {
+			s, err := ParseScript(tt.script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, st := range s.IgnoredFailures() {
+				got = append(got, s.Code(st))
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("got %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestScript_RemoteScripts(t *testing.T) {
+	tests := []struct {
+		script string
+		urls   []string
+	}{
+		{script: "curl -fsSL https://deb.nodesource.com/setup_22.x | sudo -E bash -", urls: []string{"https://deb.nodesource.com/setup_22.x"}},
+		{script: "wget -qO- https://get.pnpm.io/install.sh | ENV=\"$HOME/.bashrc\" SHELL=\"$(which bash)\" bash -", urls: []string{"https://get.pnpm.io/install.sh"}},
+		{script: `sh -c "$(curl -fsSL https://get.docker.com)"`, urls: []string{"https://get.docker.com"}},
+		{script: "curl -fsSL $INSTALLER | sh", urls: []string{"$INSTALLER"}},
+		{script: "curl -fsSL https://example.com/install.sh | tee install.sh | sh", urls: []string{"https://example.com/install.sh"}},
+		{script: "curl -fsSL https://example.com/node.tar.gz | tar -xz -C /opt", urls: []string{}},
+		{script: "echo 'curl https://x | sh'", urls: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.script, func(t *testing.T) {
+			s, err := ParseScript(tt.script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			urls := []string{}
+			for _, r := range s.RemoteScripts() {
+				urls = append(urls, r.URL)
+			}
+			if !reflect.DeepEqual(urls, tt.urls) {
+				t.Errorf("got %q, want %q", urls, tt.urls)
+			}
+		})
+	}
+}
+
+func TestScript_Downloads(t *testing.T) {
+	tests := []struct {
+		script  string
+		files   []string
+		removed []bool
+	}{
+		{
+			script:  "wget https://nodejs.org/dist/v22.0.0/node-v22.0.0-linux-x64.tar.xz && tar -xf node-v22.0.0-linux-x64.tar.xz",
+			files:   []string{"node-v22.0.0-linux-x64.tar.xz"},
+			removed: []bool{false},
+		},
+		{
+			script:  "curl -fsSLo /tmp/go.tgz https://go.dev/dl/go1.23.4.linux-amd64.tar.gz && tar -C /usr/local -xzf /tmp/go.tgz && rm /tmp/go.tgz",
+			files:   []string{"/tmp/go.tgz"},
+			removed: []bool{true},
+		},
+		{
+			script:  "cd /tmp && curl -O https://example.com/tool.zip?v=1 && unzip tool.zip && rm -rf /tmp",
+			files:   []string{"/tmp/tool.zip"},
+			removed: []bool{true},
+		},
+		{
+			script:  "wget -q -P /opt https://example.com/a.deb && curl --output b.rpm https://example.com/b && rm -f /opt/*.deb",
+			files:   []string{"/opt/a.deb", "b.rpm"},
+			removed: []bool{true, false},
+		},
+		{
+			script:  "wget -qO- https://example.com/a.tar.gz | tar -xz && curl -fsSL -o - https://example.com/b | sh",
+			files:   []string{},
+			removed: []bool{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.script, func(t *testing.T) {
+			s, err := ParseScript(tt.script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files, removed := []string{}, []bool{}
+			for _, d := range s.Downloads() {
+				files = append(files, d.File)
+				removed = append(removed, s.Removes(d.File, d.Command))
+			}
+			if !reflect.DeepEqual(files, tt.files) || !reflect.DeepEqual(removed, tt.removed) {
+				t.Errorf("got %q removed %v, want %q removed %v", files, removed, tt.files, tt.removed)
+			}
+		})
+	}
+}
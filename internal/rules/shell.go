@@ -0,0 +1,284 @@
+package rules
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+var (
+	// matches a git commit in a URL, eg- "https://raw.githubusercontent.com/nvm-sh/nvm/977563e/install.sh"
+	commitRegex = regexp.MustCompile(`\b[0-9a-f]{40}\b`)
+	// matches a here-document passed as the whole command of a RUN instruction, eg- "<<EOF" or "<<-'EOF'"
+	heredocOnlyRegex = regexp.MustCompile(`^<<-?["']?[A-Za-z_][A-Za-z0-9_]*["']?$`)
+)
+
+// posixShells are the shells RUN instructions are checked for, other shells (eg- pwsh) have a different syntax
+var posixShells = map[string]bool{"sh": true, "bash": true, "ash": true, "dash": true, "zsh": true, "ksh": true}
+
+// archiveExtensions are the extensions of downloaded files that are only needed to install what's inside them
+var archiveExtensions = []string{
+	".tar", ".tar.gz", ".tgz", ".tar.xz", ".txz", ".tar.bz2", ".tbz2", ".tar.zst", ".zip", ".7z",
+	".gz", ".xz", ".bz2", ".zst", ".deb", ".rpm", ".apk",
+}
+
+// runScript parses the shell script the RUN instruction runs. nil is returned for the exec form, for scripts of
+// here-documents run by other interpreters (eg- "#!/usr/bin/env python3") and for scripts that can't be parsed.
+func runScript(inst *dockerfile.Instruction) *Script {
+	if inst.IsJSONForm() {
+		return nil
+	}
+	src := strings.Join(inst.Args(), " ")
+	heredocs := inst.Heredocs()
+	if len(heredocs) == 1 && heredocOnlyRegex.MatchString(strings.TrimSpace(src)) {
+		// the here-document is the script itself
+		src = heredocs[0].Content
+		if line, _, _ := strings.Cut(src, "\n"); strings.HasPrefix(line, "#!") && !isPosixShebang(line) {
+			return nil
+		}
+	} else {
+		for _, h := range heredocs {
+			src += "\n" + h.Content + h.Name + "\n"
+		}
+	}
+	script, err := ParseScript(src)
+	if err != nil {
+		return nil
+	}
+	return script
+}
+
+// isPosixShebang returns true if the shebang runs the script with a POSIX shell, eg- "#!/bin/sh" or "#!/usr/bin/env bash"
+func isPosixShebang(line string) bool {
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return true
+	}
+	name := path.Base(fields[0])
+	if name == "env" && len(fields) > 1 {
+		name = path.Base(fields[1])
+	}
+	return posixShells[name]
+}
+
+// RunShell returns whether the RUN instruction of the stage runs with a POSIX shell, given the SHELL instructions
+// before it, and whether the shell is set to exit on the first failing command, eg- with SHELL ["/bin/sh", "-e", "-c"]
+func RunShell(stage *dockerfile.Stage, inst *dockerfile.Instruction) (bool, bool) {
+	posix, errexit := true, false
+	for _, i := range stage.Instructions() {
+		if i.StartLine() >= inst.StartLine() {
+			break
+		}
+		if i.Cmd() != dockerfile.CmdShell || len(i.Args()) == 0 {
+			continue
+		}
+		args := i.Args()
+		posix, errexit = posixShells[path.Base(args[0])], false
+		for j, a := range args[1:] {
+			if strings.HasPrefix(a, "-") && !strings.HasPrefix(a, "--") && strings.ContainsRune(a, 'e') {
+				errexit = true
+			}
+			if a == "-o" && j+2 < len(args) && args[j+2] == "errexit" {
+				errexit = true
+			}
+		}
+	}
+	return posix, errexit
+}
+
+// UnpinnedScripts returns the remote scripts the script pipes into a shell, except those downloaded from
+// a URL that pins a commit
+func UnpinnedScripts(script *Script) []*RemoteScript {
+	unpinned := []*RemoteScript{}
+	for _, r := range script.RemoteScripts() {
+		if !commitRegex.MatchString(r.URL) {
+			unpinned = append(unpinned, r)
+		}
+	}
+	return unpinned
+}
+
+// LeftoverArchives returns the archives and packages the script downloads and doesn't remove later on
+func LeftoverArchives(script *Script) []*Download {
+	leftovers := []*Download{}
+	for _, d := range script.Downloads() {
+		if isArchive(d.File) && !script.Removes(d.File, d.Command) {
+			leftovers = append(leftovers, d)
+		}
+	}
+	return leftovers
+}
+
+func isArchive(file string) bool {
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(strings.ToLower(file), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// installsAptPackages returns true if the script installs packages with apt-get or apt
+func installsAptPackages(script *Script) bool {
+	for _, c := range script.Commands() {
+		if (c.Name() == "apt-get" || c.Name() == "apt") && c.HasArg("install") {
+			return true
+		}
+	}
+	return false
+}
+
+// LeavesAptLists returns true if the RUN instruction installs packages with apt-get and leaves the package
+// lists in its layer, ie- it doesn't remove /var/lib/apt/lists nor mount a cache on it
+func LeavesAptLists(inst *dockerfile.Instruction, script *Script) bool {
+	for _, f := range inst.Flags() {
+		if strings.HasPrefix(f, "--mount=") && strings.Contains(f, "/var/lib/apt") {
+			return false
+		}
+	}
+	if script == nil {
+		return strings.Contains(inst.Command(), "apt-get install") && !strings.Contains(inst.Command(), "/var/lib/apt/lists")
+	}
+	if !installsAptPackages(script) {
+		return false
+	}
+	for _, c := range script.Commands() {
+		if c.Name() != "rm" {
+			continue
+		}
+		for _, a := range c.Argv()[1:] {
+			if strings.HasPrefix(a, "/var/lib/apt/lists") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// summarize returns the code of a statement on a single line, shortened if it's long
+func summarize(code string) string {
+	code = strings.Join(strings.Fields(strings.ReplaceAll(code, "\\\n", " ")), " ")
+	if len(code) > 60 {
+		code = code[:57] + "..."
+	}
+	return code
+}
+
+var ruleRunCommandsNotChained = &Rule{
+	ID:          "DS022",
+	Name:        "run-commands-not-chained",
+	Description: "A RUN instruction chains its commands with ';' or newlines, so the build goes on when one of them fails.",
+	Severity:    models.SeverityMedium,
+	Goals:       []models.Goal{models.GoalSize, models.GoalBuildSpeed, models.GoalSecurity},
+	Check: func(c *Context) []*models.Finding {
+		findings := []*models.Finding{}
+		for _, stage := range c.Dockerfile.GetStages() {
+			for _, inst := range stageInstructions(c.Dockerfile, stage) {
+				if inst.Cmd() != dockerfile.CmdRun {
+					continue
+				}
+				if posix, errexit := RunShell(stage, inst); !posix || errexit {
+					continue
+				}
+				script := runScript(inst)
+				if script == nil {
+					continue
+				}
+				ignored := script.IgnoredFailures()
+				if len(ignored) == 0 {
+					continue
+				}
+				first := ignored[0]
+				fix := "Chain the commands with && instead, or start the script with 'set -e'."
+				separator := "';'"
+				if !first.Semicolon.IsValid() {
+					fix = "Start the script with 'set -e', or chain the commands with &&."
+					separator = "a new line"
+				}
+				findings = append(findings, &models.Finding{
+					Filepath: c.DockerfilePath,
+					Line:     inst.StartLine(),
+					Title:    "RUN instruction goes on when its commands fail",
+					Description: fmt.Sprintf("'%s' is followed by %s, so if it fails the build goes on with the next command and the image is built without what it should have done. %s",
+						summarize(script.Code(first)), separator, fix),
+				})
+			}
+		}
+		return findings
+	},
+}
+
+var ruleRemoteScriptPipedToShell = &Rule{
+	ID:          "DS023",
+	Name:        "remote-script-piped-to-shell",
+	Description: "A script is downloaded and piped to a shell without verifying its checksum or signature.",
+	Severity:    models.SeverityHigh,
+	Goals:       []models.Goal{models.GoalSecurity},
+	Check: func(c *Context) []*models.Finding {
+		findings := []*models.Finding{}
+		for _, stage := range c.Dockerfile.GetStages() {
+			for _, inst := range stageInstructions(c.Dockerfile, stage) {
+				if inst.Cmd() != dockerfile.CmdRun {
+					continue
+				}
+				script := runScript(inst)
+				if script == nil {
+					continue
+				}
+				for _, r := range UnpinnedScripts(script) {
+					url := r.URL
+					if url == "" {
+						url = "the internet"
+					}
+					findings = append(findings, &models.Finding{
+						Filepath:    c.DockerfilePath,
+						Line:        inst.StartLine(),
+						Title:       fmt.Sprintf("Script downloaded from %s is run without being verified", url),
+						Description: "The script is run as it's served, so whoever controls the server or the connection can run anything in the build, and a new version of the script changes the image without any change to the Dockerfile. Download a pinned version of the script to a file, verify its checksum (eg- with 'sha256sum -c') and then run it.",
+					})
+				}
+			}
+		}
+		return findings
+	},
+}
+
+var ruleDownloadedArchiveLeftBehind = &Rule{
+	ID:          "DS024",
+	Name:        "downloaded-archive-left-behind",
+	Description: "A downloaded archive is extracted but not removed in the same RUN instruction, so it stays in the layer.",
+	Severity:    models.SeverityMedium,
+	Goals:       []models.Goal{models.GoalSize},
+	Check: func(c *Context) []*models.Finding {
+		stage := finalStage(c.Dockerfile)
+		if stage == nil {
+			return nil
+		}
+		findings := []*models.Finding{}
+		for _, s := range c.stageChain(stage) {
+			for _, inst := range stageInstructions(c.Dockerfile, s) {
+				if inst.Cmd() != dockerfile.CmdRun {
+					continue
+				}
+				script := runScript(inst)
+				if script == nil {
+					continue
+				}
+				for _, d := range LeftoverArchives(script) {
+					findings = append(findings, &models.Finding{
+						Filepath: c.DockerfilePath,
+						Line:     inst.StartLine(),
+						Title:    fmt.Sprintf("Downloaded archive %s is left in the layer", path.Base(d.File)),
+						Description: fmt.Sprintf("%s is only needed to install what's inside it, but it stays in the final image since the RUN instruction that downloads it doesn't remove it. Remove it in the same instruction, eg- '&& rm -f %s', or extract it straight from the download, eg- 'curl -fsSL <url> | tar -xz'.",
+							path.Base(d.File), d.File),
+					})
+				}
+			}
+		}
+		return findings
+	},
+}
@@ -0,0 +1,132 @@
+package rules
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"testing/fstest"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+func TestRun_ShellScripts(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected []string
+	}{
+		{
+			name: "commands separated by semicolons",
+			code: `FROM node:22-slim
+RUN cd /app; npm ci; npm run build
+RUN rm -rf /tmp/* || true; npm cache clean --force
+`,
+			expected: []string{"DS022:2"},
+		},
+		{
+			name: "errexit set by the script or the shell",
+			code: `FROM node:22-slim
+RUN <<EOF
+set -eu
+cd /app
+npm ci
+EOF
+SHELL ["/bin/bash", "-euo", "pipefail", "-c"]
+RUN cd /app; npm ci
+`,
+			expected: []string{},
+		},
+		{
+			name: "here-document without errexit",
+			code: `FROM node:22-slim
+RUN <<EOF
+cd /app
+npm ci
+EOF
+RUN <<EOF
+#!/usr/bin/env python3
+print("ok"); print("done")
+EOF
+`,
+			expected: []string{"DS022:2"},
+		},
+		{
+			name: "remote scripts piped to a shell",
+			code: `FROM node:22-slim
+RUN curl -fsSL https://deb.nodesource.com/setup_22.x | bash - && apt-get install -y nodejs && rm -rf /var/lib/apt/lists/*
+RUN curl -fsSL https://raw.githubusercontent.com/nvm-sh/nvm/977563e97ddc66facf3a8e31c6cff01d236f09bd/install.sh | bash
+RUN echo "curl https://example.com | sh" > /usr/local/bin/install
+`,
+			expected: []string{"DS023:2"},
+		},
+		{
+			name: "downloaded archives in the final image",
+			code: `FROM node:22-slim AS build
+RUN wget https://example.com/tool.tar.gz && tar -xzf tool.tar.gz
+
+FROM node:22-slim
+RUN curl -fsSLo /tmp/go.tgz https://go.dev/dl/go1.23.4.linux-amd64.tar.gz && tar -C /usr/local -xzf /tmp/go.tgz
+RUN wget -q https://example.com/tool.deb && dpkg -i tool.deb && rm tool.deb
+RUN curl -fsSL https://example.com/node.tar.xz | tar -xJ -C /usr/local
+`,
+			expected: []string{"DS024:5"},
+		},
+		{
+			name: "apt package lists",
+			code: `FROM debian:12-slim
+RUN apt-get update && apt-get install -y --no-install-recommends curl
+RUN --mount=type=cache,target=/var/lib/apt,sharing=locked apt-get update && apt-get install -y --no-install-recommends git
+RUN apt-get update && apt-get install -y --no-install-recommends jq && rm -rf /var/lib/apt/lists/*
+RUN echo "apt-get install vim" >> /notes
+`,
+			expected: []string{"DS015:2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.code)
+			if err != nil {
+				t.Fatalf("failed to parse Dockerfile: %v", err)
+			}
+			c := &Context{Dockerfile: df, DockerfilePath: "Dockerfile", ProjectDir: fstest.MapFS{}}
+			found := []string{}
+			for _, f := range Run(c, models.GoalAll) {
+				switch f.Code {
+				case "DS015", "DS022", "DS023", "DS024":
+					found = append(found, fmt.Sprintf("%s:%d", f.Code, f.Line))
+				}
+			}
+			sort.Strings(found)
+			if !reflect.DeepEqual(found, tt.expected) {
+				t.Errorf("expected findings %v, got %v", tt.expected, found)
+			}
+		})
+	}
+}
+
+func TestRunShell(t *testing.T) {
+	df, err := dockerfile.NewDockerfile(`FROM mcr.microsoft.com/powershell
+RUN echo default
+SHELL ["pwsh", "-Command"]
+RUN Write-Host ok
+SHELL ["/bin/sh", "-o", "errexit", "-c"]
+RUN echo errexit
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stage, _ := df.GetFinalStage()
+	got := [][2]bool{}
+	for _, inst := range stage.Instructions() {
+		if inst.Cmd() == dockerfile.CmdRun {
+			posix, errexit := RunShell(stage, inst)
+			got = append(got, [2]bool{posix, errexit})
+		}
+	}
+	if expected := [][2]bool{{true, false}, {false, false}, {true, true}}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
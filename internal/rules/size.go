@@ -0,0 +1,132 @@
+package rules
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+)
+
+const MB = int64(1024 * 1024)
+
+// approximate compressed linux/amd64 sizes of the official nodejs image variants, ie- what is downloaded when
+// pulling them. They're only used when the sizes of the base images aren't known, which are compressed sizes too.
+var nodeImageVariantSizes = map[string]int64{
+	"full":   390 * MB,
+	"slim":   75 * MB,
+	"alpine": 55 * MB,
+}
+
+// lightweightVariantSavings estimates the compressed bytes saved by switching the given image
+// to its given variant, eg- alpine. 0 is returned if the estimate is unknown.
+func lightweightVariantSavings(image *dockerfile.Image, variant string) int64 {
+	if image.Name() != "node" {
+		return 0
+	}
+	return nodeImageVariantSizes["full"] - nodeImageVariantSizes[variant]
+}
+
+// size returns the total size of all files under the given path of the build context
+func (c *Context) size(path string) int64 {
+	if c.Files != nil {
+		return c.Files.Size(strings.TrimSuffix(path, "/"))
+	}
+	return dirSize(c.ProjectDir, path)
+}
+
+// sizeIn returns the total size of all files under the given path of a named build context.
+// 0 is returned if the context isn't a local directory.
+func (c *Context) sizeIn(context, p string) int64 {
+	return dirSize(c.BuildContexts[context], path.Clean(strings.TrimPrefix(p, "/")))
+}
+
+// dirSize returns the total size of all regular files under the given path.
+// 0 is returned if fsys is nil or the path doesn't exist.
+func dirSize(fsys fs.FS, path string) int64 {
+	if fsys == nil {
+		return 0
+	}
+	var size int64
+	_ = fs.WalkDir(fsys, strings.TrimSuffix(path, "/"), func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// approximate installed sizes of system packages that are commonly installed in images,
+// keyed by the name used by apt, apk and yum/dnf
+var systemPackageSizes = map[string]int64{
+	"build-essential":      220 * MB,
+	"build-base":           200 * MB,
+	"gcc":                  90 * MB,
+	"g++":                  80 * MB,
+	"make":                 2 * MB,
+	"python3":              30 * MB,
+	"python":               30 * MB,
+	"python3-pip":          40 * MB,
+	"git":                  35 * MB,
+	"curl":                 5 * MB,
+	"wget":                 3 * MB,
+	"ca-certificates":      1 * MB,
+	"vim":                  35 * MB,
+	"openssh-client":       6 * MB,
+	"chromium":             300 * MB,
+	"google-chrome-stable": 330 * MB,
+	"ffmpeg":               120 * MB,
+	"imagemagick":          60 * MB,
+	"libvips-dev":          180 * MB,
+	"postgresql-client":    12 * MB,
+	"default-jre":          200 * MB,
+	"openjdk-17-jdk":       320 * MB,
+	"bash":                 2 * MB,
+	"tini":                 1 * MB,
+	"dumb-init":            1 * MB,
+	"locales-all":          230 * MB,
+}
+
+// defaultSystemPackageSize is assumed for system packages whose size isn't known, along with their dependencies
+const defaultSystemPackageSize = 10 * MB
+
+// aptListsSize is the size of the package lists downloaded by apt-get update, left in the layer unless removed
+const aptListsSize = 40 * MB
+
+// approximate sizes of npm packages that are much bigger than average, along with their dependencies
+var npmPackageSizes = map[string]int64{
+	"puppeteer":        300 * MB,
+	"playwright":       250 * MB,
+	"next":             110 * MB,
+	"aws-sdk":          90 * MB,
+	"typescript":       23 * MB,
+	"@swc/core":        50 * MB,
+	"esbuild":          10 * MB,
+	"webpack":          30 * MB,
+	"@angular/core":    30 * MB,
+	"react-native":     80 * MB,
+	"electron":         200 * MB,
+	"sharp":            35 * MB,
+	"@prisma/client":   20 * MB,
+	"prisma":           60 * MB,
+	"@nestjs/core":     5 * MB,
+	"jest":             30 * MB,
+	"eslint":           15 * MB,
+	"firebase":         40 * MB,
+	"firebase-admin":   40 * MB,
+	"googleapis":       110 * MB,
+	"@sentry/node":     10 * MB,
+	"lodash":           5 * MB,
+	"moment":           4 * MB,
+	"rxjs":             10 * MB,
+	"@tensorflow/tfjs": 90 * MB,
+}
+
+// defaultNpmPackageSize is assumed for npm packages whose size isn't known, along with their dependencies
+const defaultNpmPackageSize = 3 * MB
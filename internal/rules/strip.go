@@ -0,0 +1,236 @@
+package rules
+
+import (
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+// matches the option of Cargo.toml that strips the binaries it builds
+var cargoStripRegex = regexp.MustCompile(`(?m)^\s*strip\s*=\s*(true|"symbols"|"debuginfo")`)
+
+// debugSymbolsSize is the typical size of the symbol table and debug information of a compiled binary,
+// about a quarter of a 25MB binary
+const debugSymbolsSize = 6 * MB
+
+// packageExtras are the files system packages install which applications rarely need at runtime, along with
+// the percentage of the installed size of the packages they take up on average
+var packageExtras = []struct {
+	name    string
+	path    string
+	percent int64
+}{
+	{"documentation", "/usr/share/doc", 4},
+	{"man pages", "/usr/share/man", 2},
+	{"translations", "/usr/share/locale", 3},
+}
+
+// finalRunCommands returns the commands of the RUN instructions of the final image, joined
+func (c *Context) finalRunCommands(chain []*dockerfile.Stage) string {
+	commands := []string{}
+	for _, s := range chain {
+		for _, inst := range stageInstructions(c.Dockerfile, s) {
+			if inst.Cmd() == dockerfile.CmdRun {
+				commands = append(commands, inst.Command())
+			}
+		}
+	}
+	return strings.Join(commands, "\n")
+}
+
+var rulePackageExtrasInFinalImage = &Rule{
+	ID:          "DS028",
+	Name:        "package-extras-in-final-image",
+	Description: "The final image keeps the documentation, man pages and locales of the system packages it installs.",
+	Severity:    models.SeverityLow,
+	Goals:       []models.Goal{models.GoalSize},
+	Check: func(c *Context) []*models.Finding {
+		stage := finalStage(c.Dockerfile)
+		if stage == nil {
+			return nil
+		}
+		installed, family := c.finalSystemPackages()
+		if len(installed) == 0 {
+			return nil
+		}
+		chain := c.stageChain(stage)
+		commands := c.finalRunCommands(chain)
+
+		findings := []*models.Finding{}
+		for _, p := range installed {
+			if p.name != "locales-all" {
+				continue
+			}
+			findings = append(findings, &models.Finding{
+				Filepath:            c.DockerfilePath,
+				Line:                p.inst.StartLine(),
+				Title:               "Final image has every locale",
+				Description:         "locales-all contains all of the locales glibc supports, while applications rarely use more than one. Install 'locales' instead and generate only the ones the application uses, eg- \"sed -i '/en_US.UTF-8/s/^# //' /etc/locale.gen && locale-gen\".",
+				EstimatedSizeImpact: systemPackageSize(p.name),
+			})
+			break
+		}
+
+		// alpine packages ship their documentation in separate -doc packages, and the slim, distroless and
+		// ubuntu images are set up not to install it
+		base := chain[0].BaseImage()
+		if family == familyApk || base.IsLightweight() || strings.Contains(base.Name(), "ubuntu") || c.baseIsNamedContext(chain[0]) {
+			return findings
+		}
+		if family == familyYum && strings.Contains(commands, "nodocs") {
+			return findings
+		}
+		total := int64(0)
+		for _, p := range installed {
+			total += systemPackageSize(p.name)
+		}
+		avoid := "tell dpkg not to install them before installing the packages, eg- \"echo 'path-exclude=%s/*' > /etc/dpkg/dpkg.cfg.d/excludes\""
+		if family == familyYum {
+			avoid = "install the packages with '--setopt=tsflags=nodocs', which skips documentation and man pages"
+		}
+		for _, extra := range packageExtras {
+			if strings.Contains(commands, extra.path) {
+				continue
+			}
+			findings = append(findings, &models.Finding{
+				Filepath: c.DockerfilePath,
+				Line:     installed[0].inst.StartLine(),
+				Title:    fmt.Sprintf("Final image has the %s of the system packages it installs", extra.name),
+				Description: fmt.Sprintf("The system packages installed in the final stage ship %s in %s, which the application doesn't need at runtime. Remove them in the RUN instruction that installs the packages, eg- '&& rm -rf %s/*', or %s. The slim variants of the base images are set up that way already.",
+					extra.name, extra.path, extra.path, strings.ReplaceAll(avoid, "%s", extra.path)),
+				EstimatedSizeImpact: total * extra.percent / 100,
+			})
+		}
+		return findings
+	},
+}
+
+// unstrippedBuild returns the compiler that builds a binary with debug symbols in the script, eg- "go build"
+// without '-ldflags="-s -w"', along with how to leave them out. Empty strings are returned if there's none.
+// cargoStrips is true if Cargo.toml strips the binaries it builds already.
+func unstrippedBuild(script *Script, cargoStrips bool) (string, string) {
+	for _, cmd := range script.Commands() {
+		argv := cmd.Argv()
+		switch {
+		case cmd.Name() == "go" && len(argv) > 1 && (argv[1] == "build" || argv[1] == "install"):
+			stripped := false
+			for i, a := range argv {
+				flags := ""
+				if strings.HasPrefix(a, "-ldflags=") {
+					flags = strings.TrimPrefix(a, "-ldflags=")
+				} else if a == "-ldflags" && i+1 < len(argv) {
+					flags = argv[i+1]
+				}
+				for _, f := range strings.Fields(flags) {
+					if f == "-s" {
+						stripped = true
+					}
+				}
+			}
+			if !stripped {
+				return "go " + argv[1], "Build it with '-ldflags=\"-s -w\"'"
+			}
+		case cmd.Name() == "cargo" && len(argv) > 1 && argv[1] == "build" && !cargoStrips:
+			if !strings.Contains(strings.Join(cmd.Assignments, " "), "STRIP") {
+				return "cargo build", "Set 'strip = true' under [profile.release] in Cargo.toml"
+			}
+		}
+	}
+	return "", ""
+}
+
+// runsStrip returns true if a RUN instruction of the stages strips binaries with strip
+func (c *Context) runsStrip(stages []*dockerfile.Stage) bool {
+	for _, s := range stages {
+		for _, inst := range stageInstructions(c.Dockerfile, s) {
+			if inst.Cmd() != dockerfile.CmdRun {
+				continue
+			}
+			script := runScript(inst)
+			if script == nil {
+				continue
+			}
+			for _, cmd := range script.Commands() {
+				if cmd.Name() == "strip" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+var ruleUnstrippedBinary = &Rule{
+	ID:          "DS029",
+	Name:        "unstripped-binary",
+	Description: "A binary is built with its debug symbols for the final image, which makes it several times bigger.",
+	Severity:    models.SeverityLow,
+	Goals:       []models.Goal{models.GoalSize},
+	Check: func(c *Context) []*models.Finding {
+		final := finalStage(c.Dockerfile)
+		if final == nil {
+			return nil
+		}
+		chain := c.stageChain(final)
+		inFinal := map[uint]bool{}
+		for _, s := range chain {
+			inFinal[s.Index()] = true
+		}
+		// binaries are stripped in the stage that builds them or after they're copied into the final image
+		stripped := c.runsStrip(chain)
+
+		cargoStrips := false
+		if c.ProjectDir != nil {
+			if data, err := fs.ReadFile(c.ProjectDir, "Cargo.toml"); err == nil {
+				cargoStrips = cargoStripRegex.Match(data)
+			}
+		}
+
+		findings := []*models.Finding{}
+		reported := map[uint]bool{}
+		for _, s := range chain {
+			for _, inst := range stageInstructions(c.Dockerfile, s) {
+				from, ok := inst.Flag("from")
+				if inst.Cmd() != dockerfile.CmdCopy || !ok {
+					continue
+				}
+				source := c.Dockerfile.GetStage(from)
+				if source == nil || inFinal[source.Index()] || reported[source.Index()] {
+					continue
+				}
+				reported[source.Index()] = true
+				sourceChain := c.stageChain(source)
+				if stripped || c.runsStrip(sourceChain) {
+					continue
+				}
+				for _, b := range sourceChain {
+					for _, run := range stageInstructions(c.Dockerfile, b) {
+						if run.Cmd() != dockerfile.CmdRun {
+							continue
+						}
+						script := runScript(run)
+						if script == nil {
+							continue
+						}
+						build, fix := unstrippedBuild(script, cargoStrips)
+						if build == "" {
+							continue
+						}
+						findings = append(findings, &models.Finding{
+							Filepath:            c.DockerfilePath,
+							Line:                run.StartLine(),
+							Title:               fmt.Sprintf("'%s' builds a binary with debug symbols for the final image", build),
+							Description:         fmt.Sprintf("The binary copied into the final image on line %d keeps its symbol table and debug information, which usually make up a quarter of its size and are only needed to debug it. %s, or run 'strip' on it before copying it.", inst.StartLine(), fix),
+							EstimatedSizeImpact: debugSymbolsSize,
+						})
+					}
+				}
+			}
+		}
+		return findings
+	},
+}
@@ -0,0 +1,117 @@
+package rules
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+func TestRun_StripFinalImage(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		files    fstest.MapFS
+		expected []string
+	}{
+		{
+			name: "docs, man pages and translations",
+			code: `FROM debian:12
+RUN apt-get update && apt-get install -y --no-install-recommends curl && rm -rf /var/lib/apt/lists/*
+`,
+			expected: []string{"DS028:2:/usr/share/doc", "DS028:2:/usr/share/locale", "DS028:2:/usr/share/man"},
+		},
+		{
+			name: "docs removed",
+			code: `FROM debian:12
+RUN apt-get update && apt-get install -y --no-install-recommends curl && rm -rf /var/lib/apt/lists/* /usr/share/doc/*
+`,
+			expected: []string{"DS028:2:/usr/share/locale", "DS028:2:/usr/share/man"},
+		},
+		{
+			name: "slim and alpine images",
+			code: `FROM debian:12-slim AS deb
+RUN apt-get update && apt-get install -y --no-install-recommends curl && rm -rf /var/lib/apt/lists/*
+
+FROM alpine:3.20
+RUN apk add --no-cache curl
+`,
+			expected: []string{},
+		},
+		{
+			name: "every locale",
+			code: `FROM debian:12-slim
+RUN apt-get update && apt-get install -y --no-install-recommends locales-all && rm -rf /var/lib/apt/lists/*
+`,
+			expected: []string{"DS028:2:locales-all"},
+		},
+		{
+			name: "go binary with debug symbols",
+			code: `FROM golang:1.23 AS build
+RUN go mod download
+RUN CGO_ENABLED=0 go build -o /app ./cmd/app
+
+FROM gcr.io/distroless/static
+COPY --from=build /app /app
+`,
+			expected: []string{"DS029:3"},
+		},
+		{
+			name: "stripped binaries",
+			code: `FROM golang:1.23 AS build
+RUN go build -ldflags="-s -w" -o /app ./cmd/app
+
+FROM rust:1.80 AS rust
+RUN cargo build --release
+
+FROM debian:12-slim AS tools
+RUN cargo build --release && strip target/release/tool
+
+FROM gcr.io/distroless/cc
+COPY --from=build /app /app
+COPY --from=rust /target/release/server /server
+COPY --from=tools /target/release/tool /tool
+`,
+			files:    fstest.MapFS{"Cargo.toml": {Data: []byte("[profile.release]\nstrip = true\n")}},
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.code)
+			if err != nil {
+				t.Fatalf("failed to parse Dockerfile: %v", err)
+			}
+			files := tt.files
+			if files == nil {
+				files = fstest.MapFS{}
+			}
+			c := &Context{Dockerfile: df, DockerfilePath: "Dockerfile", ProjectDir: files}
+			found := []string{}
+			for _, f := range Run(c, models.GoalAll) {
+				switch f.Code {
+				case "DS028":
+					item := "locales-all"
+					for _, extra := range packageExtras {
+						if strings.Contains(f.Description, extra.path) {
+							item = extra.path
+						}
+					}
+					found = append(found, fmt.Sprintf("%s:%d:%s", f.Code, f.Line, item))
+				case "DS029":
+					found = append(found, fmt.Sprintf("%s:%d", f.Code, f.Line))
+				}
+			}
+			sort.Strings(found)
+			if !reflect.DeepEqual(found, tt.expected) {
+				t.Errorf("expected findings %v, got %v", tt.expected, found)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package rules
+
+import (
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+const (
+	// ignoreDirective suppresses findings on the instruction right below the comment,
+	// eg- "# dockershrink:ignore DS014 DS015". Without any rules, all findings are suppressed.
+	ignoreDirective = "dockershrink:ignore"
+	// ignoreFileDirective suppresses findings anywhere in the project, including the ones
+	// that aren't tied to a line of the Dockerfile, eg- "# dockershrink:ignore-file DS001"
+	ignoreFileDirective = "dockershrink:ignore-file"
+)
+
+// suppressions are the findings the user chose to ignore using comments in the Dockerfile
+type suppressions struct {
+	file *ruleSet
+	// lines maps the starting line of instructions to the rules ignored on them
+	lines map[int]*ruleSet
+}
+
+// ruleSet is a set of rule IDs or names
+type ruleSet struct {
+	all  bool
+	refs []string
+}
+
+// add adds rules to the set, no rules at all means every rule
+func (r *ruleSet) add(refs []string) {
+	if len(refs) == 0 {
+		r.all = true
+	}
+	r.refs = append(r.refs, refs...)
+}
+
+func (r *ruleSet) matches(f *models.Finding) bool {
+	if r == nil {
+		return false
+	}
+	if r.all {
+		return true
+	}
+	for _, ref := range r.refs {
+		if strings.EqualFold(ref, f.Code) || ref == f.Rule {
+			return true
+		}
+	}
+	return false
+}
+
+func parseSuppressions(code string) *suppressions {
+	s := &suppressions{file: &ruleSet{}, lines: map[int]*ruleSet{}}
+	var pending *ruleSet
+	inInstruction := false
+
+	for i, line := range strings.Split(code, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if comment, ok := strings.CutPrefix(trimmed, "#"); ok {
+			directive, args, _ := strings.Cut(strings.TrimSpace(comment), " ")
+			refs := strings.FieldsFunc(args, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+			switch directive {
+			case ignoreFileDirective:
+				s.file.add(refs)
+			case ignoreDirective:
+				if pending == nil {
+					pending = &ruleSet{}
+				}
+				pending.add(refs)
+			}
+			continue
+		}
+
+		// continuation lines of an instruction aren't instructions themselves
+		if !inInstruction {
+			if pending != nil {
+				s.lines[i+1] = pending
+				pending = nil
+			}
+		}
+		inInstruction = strings.HasSuffix(strings.TrimRight(line, " \t\r"), `\`)
+	}
+	return s
+}
+
+// matches returns true if the finding was suppressed. Findings in files other than
+// the Dockerfile, or not tied to a line, can only be suppressed for the whole file.
+func (s *suppressions) matches(f *models.Finding, dockerfilePath string) bool {
+	if s.file.matches(f) {
+		return true
+	}
+	if f.Line == 0 || f.Filepath != dockerfilePath {
+		return false
+	}
+	return s.lines[f.Line].matches(f)
+}
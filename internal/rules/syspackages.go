@@ -0,0 +1,293 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+// systemManagers maps the system package managers to their family
+var systemManagers = map[string]string{
+	"apt-get": familyApt, "apt": familyApt, "apk": familyApk, "yum": familyYum, "dnf": familyYum, "microdnf": familyYum,
+}
+
+// buildTools are the packages that are only needed to compile code, on top of the development packages
+// ending with -dev or -devel
+var buildTools = map[string]bool{
+	"build-essential": true, "build-base": true, "gcc": true, "g++": true, "gcc-c++": true, "clang": true,
+	"make": true, "cmake": true, "autoconf": true, "automake": true, "libtool": true, "binutils": true,
+	"pkg-config": true, "pkgconf": true, "pkgconfig": true, "linux-headers": true, "node-gyp": true,
+}
+
+// SystemInstall is a command that installs system packages, eg- "apt-get install -y curl"
+type SystemInstall struct {
+	// Manager is the package manager run by the command, eg- "apt-get" or "apk"
+	Manager  string
+	Packages []string
+	Command  *Command
+	// Subcommand is the index in Command.Args of "install" or "add"
+	Subcommand int
+	family     string
+}
+
+// SystemInstalls returns the commands of the script that install system packages
+func SystemInstalls(script *Script) []*SystemInstall {
+	installs := []*SystemInstall{}
+	for _, c := range script.Commands() {
+		family, ok := systemManagers[c.Name()]
+		if !ok {
+			continue
+		}
+		argv := c.Argv()
+		offset := len(c.Args) - len(argv)
+		for i := 1; i < len(argv); i++ {
+			a := argv[i]
+			if valueFlagRegex.MatchString(a) || a == "-o" || a == "-c" {
+				i++
+				continue
+			}
+			if strings.HasPrefix(a, "-") {
+				continue
+			}
+			// the first word that isn't an option is the subcommand
+			if a == "install" || (a == "add" && family == familyApk) {
+				installs = append(installs, &SystemInstall{
+					Manager:    c.Name(),
+					Packages:   packageArgs(strings.Join(argv[i+1:], " ")),
+					Command:    c,
+					Subcommand: offset + i,
+					family:     family,
+				})
+			}
+			break
+		}
+	}
+	return installs
+}
+
+// MissingFlag returns the option that keeps the package manager from adding files the image doesn't need,
+// or empty if it's set or the manager doesn't have one: --no-install-recommends for apt, which skips the
+// recommended packages, and --no-cache for apk, which doesn't store the package index
+func (i *SystemInstall) MissingFlag() string {
+	flag := ""
+	switch i.family {
+	case familyApt:
+		flag = "--no-install-recommends"
+		for _, a := range i.Command.Args {
+			if strings.Contains(a, "Install-Recommends") {
+				return ""
+			}
+		}
+	case familyApk:
+		flag = "--no-cache"
+	default:
+		return ""
+	}
+	if i.Command.HasArg(flag) {
+		return ""
+	}
+	return flag
+}
+
+// SystemInstallOnly returns the family of the package manager if the script only installs system packages,
+// eg- "apt-get update && apt-get install -y curl && rm -rf /var/lib/apt/lists/*", and empty otherwise
+func SystemInstallOnly(script *Script) string {
+	family := ""
+	for _, c := range script.Commands() {
+		if c.Name() == "rm" {
+			continue
+		}
+		f, ok := systemManagers[c.Name()]
+		if !ok || (family != "" && f != family) {
+			return ""
+		}
+		family = f
+	}
+	if len(SystemInstalls(script)) == 0 {
+		return ""
+	}
+	return family
+}
+
+// isBuildTool returns true if the package is only needed to compile code
+func isBuildTool(name string) bool {
+	return buildTools[name] || strings.HasSuffix(name, "-dev") || strings.HasSuffix(name, "-devel")
+}
+
+// InstalledPackages are the packages a RUN instruction installs
+type InstalledPackages struct {
+	Instruction *dockerfile.Instruction
+	Packages    []string
+}
+
+// BuildTools returns the packages in the final image that are only needed to compile code, eg- gcc or
+// python3-dev, by the instruction that installs them. Development packages of the runtime libraries the
+// application uses are left to DS021.
+func BuildTools(c *Context) []*InstalledPackages {
+	installed, _ := c.finalSystemPackages()
+	tools := []*InstalledPackages{}
+	for _, p := range installed {
+		if !isBuildTool(p.name) || isRuntimeLibraryDev(p) {
+			continue
+		}
+		if len(tools) == 0 || tools[len(tools)-1].Instruction != p.inst {
+			tools = append(tools, &InstalledPackages{Instruction: p.inst})
+		}
+		last := tools[len(tools)-1]
+		last.Packages = append(last.Packages, p.name)
+	}
+	return tools
+}
+
+func isRuntimeLibraryDev(p *systemPackage) bool {
+	for _, lib := range runtimeLibraries {
+		if isOneOf(p.name, lib.dev[p.family]) {
+			return true
+		}
+	}
+	return false
+}
+
+// CachesApkIndex returns true if the RUN instruction keeps the apk index out of its layer, with a cache mount
+// or by removing it
+func CachesApkIndex(inst *dockerfile.Instruction) bool {
+	for _, f := range inst.Flags() {
+		if strings.HasPrefix(f, "--mount=") && strings.Contains(f, "/var/cache/apk") {
+			return true
+		}
+	}
+	return strings.Contains(inst.Command(), "/var/cache/apk")
+}
+
+var ruleApkAddCache = &Rule{
+	ID:          "DS025",
+	Name:        "apk-add-cache",
+	Description: "apk add runs without --no-cache, which leaves the package index in the layer.",
+	Severity:    models.SeverityLow,
+	Goals:       []models.Goal{models.GoalSize},
+	Check: func(c *Context) []*models.Finding {
+		findings := []*models.Finding{}
+		for _, stage := range c.Dockerfile.GetStages() {
+			for _, inst := range stage.Instructions() {
+				if inst.Cmd() != dockerfile.CmdRun || CachesApkIndex(inst) {
+					continue
+				}
+				script := runScript(inst)
+				if script == nil {
+					continue
+				}
+				for _, i := range SystemInstalls(script) {
+					if i.family != familyApk || i.MissingFlag() == "" {
+						continue
+					}
+					findings = append(findings, &models.Finding{
+						Filepath:    c.DockerfilePath,
+						Line:        inst.StartLine(),
+						Title:       "apk add leaves the package index in the layer",
+						Description: "apk stores the package index it downloads in /var/cache/apk, which isn't needed once the packages are installed. Use 'apk add --no-cache' to download it without storing it.",
+					})
+					break
+				}
+			}
+		}
+		return findings
+	},
+}
+
+var ruleSeparateSystemPackageInstalls = &Rule{
+	ID:          "DS026",
+	Name:        "separate-system-package-installs",
+	Description: "System packages are installed by consecutive RUN instructions, each of which adds a layer and updates the package index again.",
+	Severity:    models.SeverityLow,
+	Goals:       []models.Goal{models.GoalSize, models.GoalBuildSpeed},
+	Check: func(c *Context) []*models.Finding {
+		findings := []*models.Finding{}
+		for _, stage := range c.Dockerfile.GetStages() {
+			var previous *dockerfile.Instruction
+			previousFamily := ""
+			for _, inst := range stage.Instructions() {
+				family := ""
+				if inst.Cmd() == dockerfile.CmdRun {
+					if script := runScript(inst); script != nil {
+						family = SystemInstallOnly(script)
+					}
+				}
+				if family != "" && family == previousFamily && SameFlags(previous, inst) {
+					findings = append(findings, &models.Finding{
+						Filepath: c.DockerfilePath,
+						Line:     inst.StartLine(),
+						Title:    "System packages are installed by consecutive RUN instructions",
+						Description: fmt.Sprintf("The RUN instructions on lines %d and %d both install packages with %s, so each of them adds a layer and usually downloads the package index again. Install all the packages in a single RUN instruction.",
+							previous.StartLine(), inst.StartLine(), familyManager(family)),
+					})
+				}
+				previous, previousFamily = inst, family
+			}
+		}
+		return findings
+	},
+}
+
+// SameFlags returns true if the instructions have the same flags, in any order
+func SameFlags(a, b *dockerfile.Instruction) bool {
+	aFlags, bFlags := append([]string{}, a.Flags()...), append([]string{}, b.Flags()...)
+	sort.Strings(aFlags)
+	sort.Strings(bFlags)
+	return strings.Join(aFlags, " ") == strings.Join(bFlags, " ")
+}
+
+// familyManager returns the usual package manager of the family, for messages
+func familyManager(family string) string {
+	switch family {
+	case familyApk:
+		return "apk"
+	case familyYum:
+		return "yum"
+	}
+	return "apt-get"
+}
+
+// SystemInstallCommand returns a command that installs the packages with the usual package manager of the family
+// without leaving the package index or cache in the layer, eg- "apk add --no-cache libpq"
+func SystemInstallCommand(family string, packages []string) string {
+	list := strings.Join(packages, " ")
+	switch family {
+	case familyApk:
+		return "apk add --no-cache " + list
+	case familyYum:
+		return "yum install -y " + list + " && yum clean all"
+	}
+	return "apt-get update && apt-get install -y --no-install-recommends " + list + " && rm -rf /var/lib/apt/lists/*"
+}
+
+var ruleBuildToolsInFinalStage = &Rule{
+	ID:          "DS027",
+	Name:        "build-tools-in-final-stage",
+	Description: "Build tools, eg- compilers and make, are installed in the final stage, although they're only needed to build native modules.",
+	Severity:    models.SeverityMedium,
+	Goals:       []models.Goal{models.GoalSize, models.GoalSecurity},
+	Check: func(c *Context) []*models.Finding {
+		findings := []*models.Finding{}
+		for _, tools := range BuildTools(c) {
+			move := "Move them to a build stage, compile there and copy only the result into the final stage, eg- with 'COPY --from=build /app/node_modules ./node_modules'."
+			if c.Dockerfile.GetStageCount() == 1 {
+				move = "Add a build stage that installs them and compiles the code, and copy only the result into the final stage, eg- with 'COPY --from=build /app/node_modules ./node_modules'."
+			}
+			impact := int64(0)
+			for _, p := range tools.Packages {
+				impact += systemPackageSize(p)
+			}
+			findings = append(findings, &models.Finding{
+				Filepath:            c.DockerfilePath,
+				Line:                tools.Instruction.StartLine(),
+				Title:               fmt.Sprintf("Final image has the build tools %s", strings.Join(tools.Packages, ", ")),
+				Description:         fmt.Sprintf("%s are only needed to compile code, eg- native modules, while building. In the final image they take up space and give an attacker a compiler to work with. %s", strings.Join(tools.Packages, ", "), move),
+				EstimatedSizeImpact: impact,
+			})
+		}
+		return findings
+	},
+}
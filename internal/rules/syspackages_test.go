@@ -0,0 +1,102 @@
+package rules
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+func TestRun_SystemPackages(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected []string
+	}{
+		{
+			name: "apk add without --no-cache",
+			code: `FROM alpine:3.20
+RUN apk update && apk add curl
+RUN apk add --no-cache git
+RUN --mount=type=cache,target=/var/cache/apk apk add jq
+`,
+			expected: []string{"DS025:2", "DS026:3"},
+		},
+		{
+			name: "consecutive installs",
+			code: `FROM debian:12-slim
+RUN apt-get update && apt-get install -y --no-install-recommends curl && rm -rf /var/lib/apt/lists/*
+RUN apt-get update && apt-get install -y --no-install-recommends git && rm -rf /var/lib/apt/lists/*
+RUN echo done
+RUN apt-get update && apt-get install -y --no-install-recommends jq && rm -rf /var/lib/apt/lists/*
+`,
+			expected: []string{"DS026:3"},
+		},
+		{
+			name: "build tools in the final image",
+			code: `FROM node:22-slim
+RUN apt-get update && apt-get install -y --no-install-recommends python3 make g++ libpq-dev && rm -rf /var/lib/apt/lists/*
+RUN npm ci
+`,
+			expected: []string{"DS027:2:g++,make"},
+		},
+		{
+			name: "build tools removed or in a build stage",
+			code: `FROM node:22-alpine AS build
+RUN apk add --no-cache python3 make g++
+RUN npm ci
+
+FROM node:22-alpine
+RUN apk add --no-cache --virtual .build-deps gcc musl-dev && npm rebuild && apk del .build-deps
+COPY --from=build /app /app
+`,
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df, err := dockerfile.NewDockerfile(tt.code)
+			if err != nil {
+				t.Fatalf("failed to parse Dockerfile: %v", err)
+			}
+			c := &Context{Dockerfile: df, DockerfilePath: "Dockerfile", ProjectDir: fstest.MapFS{}}
+			found := []string{}
+			for _, f := range Run(c, models.GoalAll) {
+				switch f.Code {
+				case "DS025", "DS026":
+					found = append(found, fmt.Sprintf("%s:%d", f.Code, f.Line))
+				case "DS027":
+					tools := BuildTools(c)
+					packages := tools[0].Packages
+					sort.Strings(packages)
+					found = append(found, fmt.Sprintf("%s:%d:%s", f.Code, f.Line, strings.Join(packages, ",")))
+				}
+			}
+			sort.Strings(found)
+			if !reflect.DeepEqual(found, tt.expected) {
+				t.Errorf("expected findings %v, got %v", tt.expected, found)
+			}
+		})
+	}
+}
+
+func TestSystemInstalls(t *testing.T) {
+	script, err := ParseScript("apt-get update && DEBIAN_FRONTEND=noninteractive sudo apt-get -o Dpkg::Options::=--force-confold -y install curl=7.88.1-10 git && apk add -t .deps gcc && yum -y remove vim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, i := range SystemInstalls(script) {
+		got = append(got, fmt.Sprintf("%s %s %v %s", i.Manager, i.Command.Args[i.Subcommand], i.Packages, i.MissingFlag()))
+	}
+	expected := []string{"apt-get install [curl=7.88.1-10 git] --no-install-recommends", "apk add [gcc] --no-cache"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
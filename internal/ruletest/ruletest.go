@@ -0,0 +1,285 @@
+// Package ruletest checks rules against declarative fixtures. A fixture is a directory with an
+// input.Dockerfile, the project files the rules need (eg- package.json or .dockerignore) and the
+// expected outcome: the findings in expected-findings.yaml and the Dockerfile optimize produces
+// without AI in expected.Dockerfile. Either expectation may be left out.
+package ruletest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/baseimages"
+	"github.com/duaraghav8/dockershrink/internal/diff"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/dockerignore"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/packagejson"
+	"github.com/duaraghav8/dockershrink/internal/project"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	InputFile            = "input.Dockerfile"
+	ExpectedFile         = "expected.Dockerfile"
+	ExpectedFindingsFile = "expected-findings.yaml"
+)
+
+// Expectations is the content of expected-findings.yaml
+type Expectations struct {
+	// Rules limits the rules run on the fixture to these IDs or names, so that a fixture only
+	// has to list the findings of the rule under test. All rules are run if it's empty.
+	Rules []string `yaml:"rules,omitempty"`
+	// Findings must be reported exactly, no finding may be missing and no other finding may be reported
+	Findings []*ExpectedFinding `yaml:"findings"`
+}
+
+// ExpectedFinding matches a finding. Fields left empty match any value.
+type ExpectedFinding struct {
+	// Rule is the ID or name of the rule, eg- DS014 or apt-get-install-recommends
+	Rule     string `yaml:"rule"`
+	Line     int    `yaml:"line,omitempty"`
+	Severity string `yaml:"severity,omitempty"`
+	Title    string `yaml:"title,omitempty"`
+}
+
+func (e *ExpectedFinding) String() string {
+	s := e.Rule
+	if e.Line > 0 {
+		s += fmt.Sprintf(" on line %d", e.Line)
+	}
+	if e.Title != "" {
+		s += fmt.Sprintf(" (%s)", e.Title)
+	}
+	return s
+}
+
+func (e *ExpectedFinding) matches(f *models.Finding) bool {
+	return (strings.EqualFold(e.Rule, f.Code) || e.Rule == f.Rule) &&
+		(e.Line == 0 || e.Line == f.Line) &&
+		(e.Severity == "" || strings.EqualFold(e.Severity, string(f.Severity))) &&
+		(e.Title == "" || e.Title == f.Title)
+}
+
+// Fixture is a directory containing an input.Dockerfile
+type Fixture struct {
+	// Name is the path of the fixture relative to the directory it was found in
+	Name string
+	Dir  string
+}
+
+// Find returns the fixtures in dir and its subdirectories, sorted by name.
+// dir itself is a fixture if it contains an input.Dockerfile.
+func Find(dir string) ([]*Fixture, error) {
+	fixtures := []*Fixture{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != InputFile {
+			return nil
+		}
+		fixtureDir := filepath.Dir(p)
+		name, err := filepath.Rel(dir, fixtureDir)
+		if err != nil || name == "." {
+			name = filepath.Base(fixtureDir)
+		}
+		fixtures = append(fixtures, &Fixture{Name: filepath.ToSlash(name), Dir: fixtureDir})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find fixtures in %s: %w", dir, err)
+	}
+	sort.Slice(fixtures, func(i, j int) bool { return fixtures[i].Name < fixtures[j].Name })
+	return fixtures, nil
+}
+
+// Result is the outcome of running a fixture
+type Result struct {
+	Fixture *Fixture
+	// Failures describe every way the outcome differs from the expectations
+	Failures []string
+
+	// Findings and Dockerfile are the actual outcome, used to update the expectations
+	Findings   []*models.Finding
+	Dockerfile string
+	// Expectations are nil if the fixture has no expected-findings.yaml
+	Expectations *Expectations
+}
+
+func (r *Result) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+func (r *Result) failf(format string, a ...any) {
+	r.Failures = append(r.Failures, fmt.Sprintf(format, a...))
+}
+
+// Run runs the rules on the fixture and compares the outcome with its expectations.
+// An error is only returned if the fixture itself is invalid.
+func Run(f *Fixture) (*Result, error) {
+	r := &Result{Fixture: f}
+	expectations, err := readExpectations(f.Dir)
+	if err != nil {
+		return nil, err
+	}
+	r.Expectations = expectations
+	expectedDockerfile, err := os.ReadFile(filepath.Join(f.Dir, ExpectedFile))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if expectations == nil && expectedDockerfile == nil {
+		return nil, fmt.Errorf("%s: no %s or %s to compare against", f.Name, ExpectedFindingsFile, ExpectedFile)
+	}
+
+	severities := map[string]models.Severity{}
+	if expectations != nil && len(expectations.Rules) > 0 {
+		for _, ref := range expectations.Rules {
+			if rules.Lookup(ref) == nil {
+				return nil, fmt.Errorf("%s: unknown rule %q", f.Name, ref)
+			}
+		}
+		for _, rule := range rules.All {
+			if !selected(rule, expectations.Rules) {
+				severities[rule.ID] = rules.SeverityOff
+			}
+		}
+	}
+
+	proj, err := newProject(f.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	r.Findings = proj.AnalyzeDockerImage(&project.AnalyzeOptions{Severities: severities}).Findings
+	if expectations != nil {
+		compareFindings(r, expectations.Findings)
+	}
+
+	// the project is modified while optimizing, so a fresh one is used
+	if proj, err = newProject(f.Dir); err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	optimized, err := proj.OptimizeDockerImage(context.Background(), nil, &project.OptimizeOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	r.Dockerfile = optimized.Dockerfile
+	if expectedDockerfile != nil && string(expectedDockerfile) != optimized.Dockerfile {
+		r.failf("optimized Dockerfile differs from %s:\n%s", ExpectedFile, diff.Unified(ExpectedFile, "optimized", string(expectedDockerfile), optimized.Dockerfile))
+	}
+	return r, nil
+}
+
+// Update writes the actual outcome of the fixture as its expectations. Only the expectations
+// the fixture already has are written, and the rules it limits itself to are kept.
+func Update(r *Result) error {
+	if r.Expectations != nil {
+		e := &Expectations{Rules: r.Expectations.Rules, Findings: []*ExpectedFinding{}}
+		for _, f := range r.Findings {
+			e.Findings = append(e.Findings, &ExpectedFinding{Rule: f.Code, Line: f.Line, Severity: string(f.Severity), Title: f.Title})
+		}
+		out, err := yaml.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(r.Fixture.Dir, ExpectedFindingsFile), out, 0o644); err != nil {
+			return err
+		}
+	}
+	expectedPath := filepath.Join(r.Fixture.Dir, ExpectedFile)
+	if _, err := os.Stat(expectedPath); err != nil {
+		return nil
+	}
+	return os.WriteFile(expectedPath, []byte(r.Dockerfile), 0o644)
+}
+
+// compareFindings records a failure for every expected finding that wasn't reported and every unexpected one
+func compareFindings(r *Result, expected []*ExpectedFinding) {
+	matched := make([]bool, len(r.Findings))
+	for _, e := range expected {
+		found := false
+		for i, f := range r.Findings {
+			if !matched[i] && e.matches(f) {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			r.failf("expected finding %s was not reported", e)
+		}
+	}
+	for i, f := range r.Findings {
+		if !matched[i] {
+			r.failf("unexpected finding %s on line %d: %s", f.Code, f.Line, f.Title)
+		}
+	}
+}
+
+func selected(rule *rules.Rule, refs []string) bool {
+	for _, ref := range refs {
+		if rules.Lookup(ref) == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func readExpectations(dir string) (*Expectations, error) {
+	content, err := os.ReadFile(filepath.Join(dir, ExpectedFindingsFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	e := &Expectations{}
+	if err := yaml.Unmarshal(content, e); err != nil {
+		return nil, fmt.Errorf("invalid %s in %s: %w", ExpectedFindingsFile, dir, err)
+	}
+	for i, f := range e.Findings {
+		if f == nil || f.Rule == "" {
+			return nil, fmt.Errorf("invalid %s in %s: finding %d has no rule", ExpectedFindingsFile, dir, i+1)
+		}
+	}
+	return e, nil
+}
+
+// newProject loads the fixture's input.Dockerfile along with its .dockerignore and package.json, if present.
+// Only the built-in data about base images is used so that fixtures don't depend on the network.
+func newProject(dir string) (*project.Project, error) {
+	code, err := os.ReadFile(filepath.Join(dir, InputFile))
+	if err != nil {
+		return nil, err
+	}
+	df, err := dockerfile.NewDockerfile(string(code))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", InputFile, err)
+	}
+
+	var di *dockerignore.Dockerignore
+	dockerignorePath := ""
+	if content, err := os.ReadFile(filepath.Join(dir, ".dockerignore")); err == nil {
+		di, dockerignorePath = dockerignore.NewDockerignore(string(content)), ".dockerignore"
+	}
+	var pj *packagejson.PackageJSON
+	if content, err := os.ReadFile(filepath.Join(dir, "package.json")); err == nil {
+		if pj, err = packagejson.NewPackageJSON(string(content)); err != nil {
+			return nil, fmt.Errorf("invalid package.json: %w", err)
+		}
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	proj := project.NewProject(df, di, pj, restrictedfilesystem.NewRestrictedFilesystem(abs, "", InputFile, dockerignorePath), nil, "")
+	proj.SetBaseImages(baseimages.Builtin())
+	return proj, nil
+}
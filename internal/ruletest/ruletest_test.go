@@ -0,0 +1,157 @@
+package ruletest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	fixtures, err := Find("testdata")
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	expected := []string{
+		"add-instead-of-copy",
+		"apt-get-install-recommends",
+		"apt-get-install-recommends/suppressed",
+		"final-stage-base-image",
+		"missing-cache-mount",
+		"onbuild-triggers",
+		"secret-in-build-arg",
+	}
+	if len(fixtures) != len(expected) {
+		t.Fatalf("Find() returned %d fixtures; want %d", len(fixtures), len(expected))
+	}
+	for i, name := range expected {
+		if fixtures[i].Name != name {
+			t.Errorf("Find()[%d].Name = %q; want %q", i, fixtures[i].Name, name)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	fixtures, err := Find("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range fixtures {
+		t.Run(f.Name, func(t *testing.T) {
+			r, err := Run(f)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if !r.Passed() {
+				t.Errorf("fixture failed:\n%s", strings.Join(r.Failures, "\n"))
+			}
+		})
+	}
+}
+
+// writeFixture creates a fixture in a temporary directory from a map of file names to contents
+func writeFixture(t *testing.T, files map[string]string) *Fixture {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &Fixture{Name: filepath.Base(dir), Dir: dir}
+}
+
+func TestRun_Failures(t *testing.T) {
+	f := writeFixture(t, map[string]string{
+		InputFile: "FROM node:24-alpine\nADD . .\n",
+		ExpectedFindingsFile: `rules: [DS013, DS014]
+findings:
+  - rule: DS014
+  - rule: DS013
+    line: 1
+`,
+		ExpectedFile: "FROM node:24-alpine\nCOPY . .\n",
+	})
+	r, err := Run(f)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	expected := []string{
+		"expected finding DS014 was not reported",
+		"expected finding DS013 on line 1 was not reported",
+		"unexpected finding DS013 on line 2: ADD is used to copy local files",
+		"optimized Dockerfile differs from expected.Dockerfile",
+	}
+	if len(r.Failures) != len(expected) {
+		t.Fatalf("got %d failures; want %d:\n%s", len(r.Failures), len(expected), strings.Join(r.Failures, "\n"))
+	}
+	for i, e := range expected {
+		if !strings.HasPrefix(r.Failures[i], e) {
+			t.Errorf("failure %d = %q; want it to start with %q", i, r.Failures[i], e)
+		}
+	}
+}
+
+func TestRun_InvalidFixture(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{
+			name:  "no expectations",
+			files: map[string]string{InputFile: "FROM node:24-alpine\n"},
+		},
+		{
+			name: "unknown rule",
+			files: map[string]string{
+				InputFile:            "FROM node:24-alpine\n",
+				ExpectedFindingsFile: "rules: [DS999]\nfindings: []\n",
+			},
+		},
+		{
+			name: "finding without a rule",
+			files: map[string]string{
+				InputFile:            "FROM node:24-alpine\n",
+				ExpectedFindingsFile: "findings:\n  - line: 1\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Run(writeFixture(t, tt.files)); err == nil {
+				t.Error("Run() expected an error")
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	f := writeFixture(t, map[string]string{
+		InputFile:            "FROM node:24-alpine\nADD . .\n",
+		ExpectedFindingsFile: "rules: [add-instead-of-copy]\nfindings: []\n",
+	})
+	r, err := Run(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Passed() {
+		t.Fatal("expected the fixture to fail before updating it")
+	}
+	if err := Update(r); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(f.Dir, ExpectedFile)); err == nil {
+		t.Errorf("Update() created %s, which the fixture didn't have", ExpectedFile)
+	}
+
+	r, err = Run(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Passed() {
+		t.Errorf("fixture failed after updating it:\n%s", strings.Join(r.Failures, "\n"))
+	}
+	if len(r.Expectations.Rules) != 1 || r.Expectations.Rules[0] != "add-instead-of-copy" {
+		t.Errorf("Update() changed the rules to %v", r.Expectations.Rules)
+	}
+}
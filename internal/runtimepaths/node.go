@@ -0,0 +1,287 @@
+package runtimepaths
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/imports"
+)
+
+var (
+	// nodeInterpreters run a javascript or typescript file given as their first argument
+	nodeInterpreters = map[string]bool{"node": true, "nodejs": true, "ts-node": true, "tsx": true, "nodemon": true}
+	// nodeValueFlags are the options of the interpreters that take a value as the next argument
+	nodeValueFlags = map[string]bool{
+		"-r": true, "--require": true, "--import": true, "--loader": true, "--experimental-loader": true,
+		"--env-file": true, "--conditions": true, "-C": true, "--title": true, "-P": true, "--project": true,
+	}
+	// nodeExtensions are tried in order when a relative import leaves out the extension
+	nodeExtensions = []string{".js", ".mjs", ".cjs", ".json", ".node", ".ts", ".tsx", ".mts", ".cts", ".jsx"}
+	// conventionalPaths are read by packages from the working directory without the code naming them
+	conventionalPaths = map[string][]string{
+		"dotenv": {".env"},
+		"config": {"config"},
+	}
+	// conventionalFrameworks load the files of the project by convention, eg- the pages of nextjs
+	conventionalFrameworks = map[string]bool{
+		"next": true, "nuxt": true, "sails": true, "@adonisjs/core": true, "@loopback/core": true, "@strapi/strapi": true,
+	}
+)
+
+var (
+	// matches require() and import() of anything but a string literal
+	computedImportRegex = regexp.MustCompile(`\b(?:require|import)\s*\(\s*[^'"\s)]`)
+	// matches the declarations of __dirname and __filename in ES modules, which are derived from import.meta.url
+	locationDeclarationRegex = regexp.MustCompile(`(?m)^[ \t]*(?:export[ \t]+)?(?:const|let|var)[ \t]+__(?:dirname|filename)[ \t]*=.*$`)
+	// matches the references to the location of the file or the working directory
+	locationRegex = regexp.MustCompile(`\b__dirname\b|\b__filename\b|\bimport\.meta\.(?:url|dirname|filename)\b|\bprocess\.cwd\(\)`)
+	// matches path.join(__dirname, "views") and path.resolve(process.cwd(), "public", "index.html")
+	joinRegex = regexp.MustCompile(`\b(?:join|resolve)\(\s*(__dirname|import\.meta\.dirname|process\.cwd\(\))((?:\s*,\s*['"][^'"]*['"])*)`)
+	// matches __dirname + "/views"
+	concatRegex = regexp.MustCompile(`(__dirname|import\.meta\.dirname|process\.cwd\(\))\s*\+\s*['"]([^'"]*)['"]`)
+	// matches new URL("./views", import.meta.url)
+	urlRegex = regexp.MustCompile(`\bnew\s+URL\(\s*['"]([^'"]+)['"]\s*,\s*import\.meta\.url\s*\)`)
+	// matches the literal paths passed to functions that read files, which are relative to the working directory
+	readRegex    = regexp.MustCompile(`\b(?:readFileSync|readFile|createReadStream|readdirSync|readdir|existsSync|sendFile|static)\(\s*['"]([^'"]+)['"]`)
+	literalRegex = regexp.MustCompile(`['"]([^'"]*)['"]`)
+)
+
+// builtins are the modules of node that can be imported without the "node:" prefix
+var builtins = map[string]bool{
+	"assert": true, "async_hooks": true, "buffer": true, "child_process": true, "cluster": true, "console": true,
+	"constants": true, "crypto": true, "dgram": true, "diagnostics_channel": true, "dns": true, "domain": true,
+	"events": true, "fs": true, "http": true, "http2": true, "https": true, "inspector": true, "module": true,
+	"net": true, "os": true, "path": true, "perf_hooks": true, "process": true, "punycode": true, "querystring": true,
+	"readline": true, "repl": true, "stream": true, "string_decoder": true, "sys": true, "timers": true, "tls": true,
+	"trace_events": true, "tty": true, "url": true, "util": true, "v8": true, "vm": true, "wasi": true,
+	"worker_threads": true, "zlib": true,
+}
+
+// nodeTracer follows the imports of javascript and typescript files
+type nodeTracer struct {
+	*tracer
+	// packages are the dependencies declared in the package.json of the project
+	packages map[string]bool
+	// used are the packages the traced files import
+	used map[string]bool
+}
+
+// traceNode traces the script the node interpreter runs along with the modules it preloads
+func (t *tracer) traceNode(args []string) (string, error) {
+	n := &nodeTracer{tracer: t, packages: map[string]bool{}, used: map[string]bool{}}
+	cwd, _ := t.local(".")
+	for _, dir := range []string{".", cwd} {
+		pkg := t.readPackageJSON(dir)
+		if pkg == nil {
+			continue
+		}
+		for _, deps := range []map[string]string{pkg.Dependencies, pkg.DevDependencies, pkg.OptionalDependencies, pkg.PeerDependencies} {
+			for name := range deps {
+				n.packages[name] = true
+			}
+		}
+	}
+
+	script := ""
+	preloaded := []string{}
+	for i := 0; i < len(args) && script == ""; i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch {
+		case name == "-e" || name == "--eval" || name == "-p" || name == "--print":
+			return "", fmt.Errorf("the application is started with code given on the command line")
+		case nodeValueFlags[name]:
+			if !hasValue {
+				if i+1 >= len(args) {
+					continue
+				}
+				i++
+				value = args[i]
+			}
+			switch name {
+			case "--env-file":
+				if p, ok := t.local(value); ok {
+					t.addPath(p)
+				}
+			case "-r", "--require", "--import", "--loader", "--experimental-loader":
+				preloaded = append(preloaded, value)
+			}
+		case strings.HasPrefix(arg, "-"):
+		default:
+			script = arg
+		}
+	}
+	if script == "" {
+		return "", fmt.Errorf("the command doesn't run a script")
+	}
+	p, ok := t.local(script)
+	if !ok {
+		return "", fmt.Errorf("%s isn't part of the project", script)
+	}
+	entrypoint := n.resolveModule(p)
+	if entrypoint == "" {
+		return "", fmt.Errorf("%s isn't part of the project, eg- because it's built in the image", script)
+	}
+
+	for _, m := range preloaded {
+		n.importSpecifier(cwd, "the start command", m)
+	}
+	n.node(entrypoint)
+
+	for pkg := range n.used {
+		if conventionalFrameworks[pkg] {
+			n.addUnresolved("%s loads the files of the project by convention", pkg)
+		}
+		for _, p := range conventionalPaths[pkg] {
+			t.addPath(path.Join(cwd, p))
+		}
+	}
+	// node reads the nearest package.json of every module, eg- for its "type"
+	for f := range n.files {
+		for dir := path.Dir(f); ; dir = path.Dir(dir) {
+			if t.isFile(path.Join(dir, "package.json")) {
+				n.files[path.Join(dir, "package.json")] = true
+				break
+			}
+			if dir == "." {
+				break
+			}
+		}
+	}
+	return entrypoint, nil
+}
+
+// node traces a module of the project and the modules it imports
+func (n *nodeTracer) node(file string) {
+	if n.files[file] {
+		return
+	}
+	n.files[file] = true
+	if ext := path.Ext(file); ext == ".json" || ext == ".node" {
+		return
+	}
+	content, err := fs.ReadFile(n.fsys, file)
+	if err != nil {
+		n.addUnresolved("%s can't be read", file)
+		return
+	}
+	for _, s := range imports.Specifiers(content) {
+		n.importSpecifier(path.Dir(file), file, s)
+	}
+	if computedImportRegex.Match(content) {
+		n.addUnresolved("%s imports a computed path", file)
+	}
+	n.reads(file, content)
+}
+
+// importSpecifier follows a module imported from the given directory
+func (n *nodeTracer) importSpecifier(dir, importer, specifier string) {
+	switch {
+	case strings.HasPrefix(specifier, "."), strings.HasPrefix(specifier, "/"):
+		p := path.Join(dir, specifier)
+		if path.IsAbs(specifier) {
+			var ok bool
+			if p, ok = n.local(specifier); !ok {
+				n.addUnresolved("%s imports %s, which is outside of the project", importer, specifier)
+				return
+			}
+		}
+		resolved := n.resolveModule(p)
+		if resolved == "" {
+			n.addUnresolved("%s imports '%s', which isn't part of the project", importer, specifier)
+			return
+		}
+		n.node(resolved)
+	case strings.HasPrefix(specifier, "#"):
+		n.addUnresolved("%s imports '%s' through the imports of package.json", importer, specifier)
+	case strings.Contains(specifier, ":"):
+		// eg- "node:fs"
+	default:
+		name := imports.PackageName(specifier)
+		switch {
+		case builtins[name]:
+		case n.packages[name]:
+			n.used[name] = true
+		default:
+			n.addUnresolved("%s imports '%s', which isn't a dependency of package.json, eg- a path alias", importer, specifier)
+		}
+	}
+}
+
+// resolveModule returns the file node loads for an import of the given path of the project, empty if there's none
+func (n *nodeTracer) resolveModule(p string) string {
+	if n.isFile(p) {
+		return p
+	}
+	// typescript sources are imported with the extension of the code they're compiled to
+	for js, ts := range map[string]string{".js": ".ts", ".mjs": ".mts", ".cjs": ".cts", ".jsx": ".tsx"} {
+		if strings.HasSuffix(p, js) && n.isFile(strings.TrimSuffix(p, js)+ts) {
+			return strings.TrimSuffix(p, js) + ts
+		}
+	}
+	for _, ext := range nodeExtensions {
+		if n.isFile(p + ext) {
+			return p + ext
+		}
+	}
+	if !n.isDir(p) {
+		return ""
+	}
+	if pkg := n.readPackageJSON(p); pkg != nil && pkg.Main != "" {
+		if main := path.Join(p, pkg.Main); main != p {
+			if resolved := n.resolveModule(main); resolved != "" {
+				return resolved
+			}
+		}
+	}
+	for _, ext := range nodeExtensions {
+		if index := path.Join(p, "index"+ext); n.isFile(index) {
+			return index
+		}
+	}
+	return ""
+}
+
+// reads records the files and directories the module reads through literal paths, relative to its own location
+// or the working directory
+func (n *nodeTracer) reads(file string, content []byte) {
+	// the usual declarations of __dirname in ES modules are followed through the uses of __dirname
+	code := locationDeclarationRegex.ReplaceAll(content, nil)
+	cwd, _ := n.local(".")
+	base := func(location string) string {
+		if location == "process.cwd()" {
+			return cwd
+		}
+		return path.Dir(file)
+	}
+
+	followed := 0
+	for _, m := range joinRegex.FindAllSubmatch(code, -1) {
+		parts := []string{base(string(m[1]))}
+		for _, l := range literalRegex.FindAllSubmatch(m[2], -1) {
+			parts = append(parts, string(l[1]))
+		}
+		n.addPath(path.Join(parts...))
+		followed++
+	}
+	for _, m := range concatRegex.FindAllSubmatch(code, -1) {
+		n.addPath(path.Join(base(string(m[1])), string(m[2])))
+		followed++
+	}
+	for _, m := range urlRegex.FindAllSubmatch(code, -1) {
+		n.addPath(path.Join(path.Dir(file), string(m[1])))
+		followed++
+	}
+	if len(locationRegex.FindAll(code, -1)) > followed {
+		n.addUnresolved("%s refers to its location or the working directory in a way that can't be followed", file)
+	}
+
+	for _, m := range readRegex.FindAllSubmatch(code, -1) {
+		if p, ok := n.local(string(m[1])); ok {
+			n.addPath(p)
+		}
+	}
+}
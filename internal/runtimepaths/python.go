@@ -0,0 +1,275 @@
+package runtimepaths
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	// pythonServers run the module given as "module:variable", eg- "gunicorn app.wsgi:application"
+	pythonServers = map[string]bool{"gunicorn": true, "uvicorn": true, "hypercorn": true, "daphne": true, "waitress-serve": true}
+	// pythonValueFlags are the options of the interpreter and servers that take a value as the next argument
+	pythonValueFlags = map[string]bool{
+		"-W": true, "-X": true, "-c": true, "--config": true, "-b": true, "--bind": true, "-w": true, "--workers": true,
+		"-k": true, "--worker-class": true, "--host": true, "--port": true, "--app-dir": true, "--env-file": true,
+		"--log-level": true, "-t": true, "--timeout": true,
+	}
+)
+
+var (
+	// matches python, python3 and python3.12
+	pythonInterpreterRegex = regexp.MustCompile(`^python(\d(\.\d+)?)?$`)
+	// matches the "module:variable" argument of the servers, eg- "app.main:app" or "app:create_app()"
+	appRegex = regexp.MustCompile(`^([A-Za-z_][\w.]*):[A-Za-z_][\w.]*(\(.*\))?$`)
+	// matches "import a.b, c as d"
+	pyImportRegex = regexp.MustCompile(`(?m)^[ \t]*import[ \t]+([\w.]+(?:[ \t]+as[ \t]+\w+)?(?:[ \t]*,[ \t]*[\w.]+(?:[ \t]+as[ \t]+\w+)?)*)`)
+	// matches "from a.b import c, d" and "from . import c"
+	pyFromRegex = regexp.MustCompile(`(?m)^[ \t]*from[ \t]+(\.*[\w.]*)[ \t]+import[ \t]+\(?[ \t]*([\w, \t]+|\*)`)
+	// matches code that imports modules whose names are computed or listed in strings, eg- the apps of django
+	pyDynamicRegex = regexp.MustCompile(`\bimportlib\.import_module\(|\b__import__\(|\bINSTALLED_APPS\b|\bDJANGO_SETTINGS_MODULE\b|\bpkgutil\.|\bexec\(`)
+	// matches the literal paths opened relative to the working directory
+	pyOpenRegex = regexp.MustCompile(`\bopen\(\s*[rRbBuUfF]?['"]([^'"]+)['"]`)
+	// matches os.path.join(os.path.dirname(__file__), "templates")
+	pyJoinRegex = regexp.MustCompile(`os\.path\.join\(\s*os\.path\.dirname\(\s*(?:os\.path\.(?:abspath|realpath)\()?\s*__file__\s*\)?\s*\)((?:\s*,\s*['"][^'"]*['"])*)`)
+	// matches Path(__file__).parent / "templates"
+	pyPathRegex = regexp.MustCompile(`Path\(\s*__file__\s*\)(?:\.resolve\(\))?\.parent((?:\s*/\s*['"][^'"]*['"])*)`)
+	// matches the flask application, which serves the templates and static directories next to its module
+	flaskRegex = regexp.MustCompile(`\bFlask\(\s*__name__`)
+)
+
+// pythonTracer follows the imports of python modules of the project
+type pythonTracer struct {
+	*tracer
+	// roots are the directories of the project python imports modules from, ie, sys.path
+	roots []string
+}
+
+// tracePython traces the script or module the python interpreter or a server runs
+func (t *tracer) tracePython(interpreter string, args []string) (string, error) {
+	cwd, _ := t.local(".")
+	p := &pythonTracer{tracer: t, roots: []string{cwd}}
+
+	script, module, config := "", "", ""
+	for i := 0; i < len(args) && script == "" && module == ""; i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch {
+		case name == "-m" && !pythonServers[interpreter]:
+			if i+1 >= len(args) {
+				continue
+			}
+			// eg- "python -m uvicorn app.main:app"
+			if pythonServers[args[i+1]] {
+				return t.tracePython(args[i+1], args[i+2:])
+			}
+			module = args[i+1]
+		case name == "-c" && !pythonServers[interpreter]:
+			return "", fmt.Errorf("the application is started with code given on the command line")
+		case pythonValueFlags[name]:
+			if !hasValue && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			switch name {
+			case "-c", "--config":
+				config = value
+			case "--app-dir":
+				if dir, ok := t.local(value); ok {
+					p.roots = append([]string{dir}, p.roots...)
+				}
+			case "--env-file":
+				if f, ok := t.local(value); ok {
+					t.addPath(f)
+				}
+			}
+		case strings.HasPrefix(arg, "-"):
+		case pythonServers[interpreter]:
+			if m := appRegex.FindStringSubmatch(arg); m != nil {
+				module = m[1]
+			}
+		default:
+			script = arg
+		}
+	}
+
+	if config != "" && strings.HasSuffix(config, ".py") {
+		if f, ok := t.local(config); ok && t.isFile(f) {
+			p.python(f)
+		}
+	}
+	switch {
+	case script != "":
+		f, ok := t.local(script)
+		if !ok || !t.isFile(f) {
+			return "", fmt.Errorf("%s isn't part of the project", script)
+		}
+		// python imports modules from the directory of the script, not the working directory
+		p.roots = []string{path.Dir(f)}
+		p.python(f)
+		return f, nil
+	case module != "":
+		files := p.module(module)
+		if len(files) == 0 {
+			return "", fmt.Errorf("the module %s isn't part of the project", module)
+		}
+		entrypoint := files[len(files)-1]
+		// "python -m package" runs the __main__ module of the package
+		if path.Base(entrypoint) == "__init__.py" && !pythonServers[interpreter] {
+			main := path.Join(path.Dir(entrypoint), "__main__.py")
+			if !t.isFile(main) {
+				return "", fmt.Errorf("the package %s doesn't have a __main__ module", module)
+			}
+			files = append(files, main)
+			entrypoint = main
+		}
+		for _, f := range files {
+			p.python(f)
+		}
+		return entrypoint, nil
+	}
+	return "", fmt.Errorf("the command doesn't run a script or module")
+}
+
+// module returns the files python loads for a module of the project, the package __init__ modules first.
+// None are returned if the module isn't part of the project, eg- a third-party package.
+func (p *pythonTracer) module(name string) []string {
+	for _, root := range p.roots {
+		if files := p.moduleIn(root, name); files != nil {
+			return files
+		}
+	}
+	return nil
+}
+
+func (p *pythonTracer) moduleIn(root, name string) []string {
+	files := []string{}
+	parts := strings.Split(name, ".")
+	dir := root
+	for i, part := range parts {
+		base := path.Join(dir, part)
+		last := i == len(parts)-1
+		switch {
+		case last && p.isFile(base+".py"):
+			return append(files, base+".py")
+		case p.isDir(base):
+			init := path.Join(base, "__init__.py")
+			switch {
+			case p.isFile(init):
+				files = append(files, init)
+			case last:
+				// a namespace package without an __init__ module is loaded as a whole
+				p.addPath(base)
+			}
+			if last {
+				return files
+			}
+			dir = base
+		case last && p.isFile(path.Join(dir, part+".so")):
+			return append(files, path.Join(dir, part+".so"))
+		default:
+			if i == 0 {
+				return nil
+			}
+			// eg- a name imported from the package rather than a submodule
+			return files
+		}
+	}
+	return files
+}
+
+// python traces a module of the project and the modules it imports
+func (p *pythonTracer) python(file string) {
+	if p.files[file] {
+		return
+	}
+	p.files[file] = true
+	if path.Ext(file) != ".py" {
+		return
+	}
+	content, err := fs.ReadFile(p.fsys, file)
+	if err != nil {
+		p.addUnresolved("%s can't be read", file)
+		return
+	}
+	follow := func(name string) {
+		for _, f := range p.module(name) {
+			p.python(f)
+		}
+	}
+	for _, m := range pyImportRegex.FindAllSubmatch(content, -1) {
+		for _, item := range strings.Split(string(m[1]), ",") {
+			follow(strings.Fields(item)[0])
+		}
+	}
+	for _, m := range pyFromRegex.FindAllSubmatch(content, -1) {
+		from := string(m[1])
+		names := strings.Split(string(m[2]), ",")
+		if !strings.HasPrefix(from, ".") {
+			follow(from)
+			for _, n := range names {
+				if n = strings.TrimSpace(n); n != "" && n != "*" {
+					follow(from + "." + strings.Fields(n)[0])
+				}
+			}
+			continue
+		}
+		// relative imports start from the package of the module, one package up for every extra dot
+		dir := path.Dir(file)
+		module := strings.TrimLeft(from, ".")
+		for i := 1; i < len(from)-len(module); i++ {
+			dir = path.Dir(dir)
+		}
+		targets := []string{module}
+		if module == "" {
+			targets = nil
+		}
+		for _, n := range names {
+			if n = strings.TrimSpace(n); n != "" && n != "*" {
+				target := strings.Fields(n)[0]
+				if module != "" {
+					target = module + "." + target
+				}
+				targets = append(targets, target)
+			}
+		}
+		for _, target := range targets {
+			for _, f := range p.moduleIn(dir, target) {
+				p.python(f)
+			}
+		}
+	}
+	if pyDynamicRegex.Match(content) {
+		p.addUnresolved("%s imports modules whose names are computed or configured", file)
+	}
+	p.reads(file, content)
+}
+
+// reads records the files and directories the module reads through literal paths
+func (p *pythonTracer) reads(file string, content []byte) {
+	followed := 0
+	for _, re := range []*regexp.Regexp{pyJoinRegex, pyPathRegex} {
+		for _, m := range re.FindAllSubmatch(content, -1) {
+			parts := []string{path.Dir(file)}
+			for _, l := range literalRegex.FindAllSubmatch(m[1], -1) {
+				parts = append(parts, string(l[1]))
+			}
+			p.addPath(path.Join(parts...))
+			followed++
+		}
+	}
+	if strings.Count(string(content), "__file__") > followed {
+		p.addUnresolved("%s refers to its location in a way that can't be followed", file)
+	}
+	for _, m := range pyOpenRegex.FindAllSubmatch(content, -1) {
+		if f, ok := p.local(string(m[1])); ok {
+			p.addPath(f)
+		}
+	}
+	if flaskRegex.Match(content) {
+		p.addPath(path.Join(path.Dir(file), "templates"))
+		p.addPath(path.Join(path.Dir(file), "static"))
+	}
+}
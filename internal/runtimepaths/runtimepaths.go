@@ -0,0 +1,171 @@
+// Package runtimepaths statically traces the command a container starts with, eg- "node server.js" or
+// "python -m app", to the files of the project the application loads at runtime. It follows the local imports
+// of nodejs and python code, along with the files and directories the code reads through literal paths.
+// Whatever can't be followed, eg- a require() of a computed path, is reported, so that callers only narrow
+// what's copied into an image when the trace is complete.
+package runtimepaths
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Result is the outcome of tracing the start command of an application
+type Result struct {
+	// Entrypoint is the file the command runs, relative to the root of the project
+	Entrypoint string
+	// Files are the files of the project the application loads, sorted
+	Files []string
+	// Dirs are the directories the application reads files from, eg- "public" served by express.static, sorted
+	Dirs []string
+	// Unresolved explains what the trace couldn't follow, eg- "src/plugins.js requires a computed path".
+	// The application may load files that aren't part of the result if it isn't empty.
+	Unresolved []string
+}
+
+// Complete returns true if the trace followed everything the application loads
+func (r *Result) Complete() bool {
+	return len(r.Unresolved) == 0
+}
+
+// Paths returns the top-level files and directories of the project that contain everything the application
+// loads, sorted. It's "." if the application reads the root of the project itself.
+func (r *Result) Paths() []string {
+	seen := map[string]bool{}
+	paths := []string{}
+	for _, p := range append(append([]string{}, r.Files...), r.Dirs...) {
+		if p == "." {
+			return []string{"."}
+		}
+		top, _, _ := strings.Cut(p, "/")
+		if !seen[top] {
+			seen[top] = true
+			paths = append(paths, top)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// tracer collects the paths the application loads while following its code
+type tracer struct {
+	fsys fs.FS
+	// root is the directory of the image the project is copied to, and workdir the one the command runs in
+	root, workdir string
+	files         map[string]bool
+	dirs          map[string]bool
+	unresolved    []string
+}
+
+// Trace follows the start command of an application, in exec form, through the project in fsys. The project is
+// copied to the directory root of the image and the command runs in workdir, eg- both "/app". An error is
+// returned if the command doesn't run a file of the project with node or python.
+func Trace(fsys fs.FS, command []string, root, workdir string) (*Result, error) {
+	if len(command) == 0 {
+		return nil, fmt.Errorf("the start command is empty")
+	}
+	t := &tracer{fsys: fsys, root: path.Clean(root), workdir: path.Clean(workdir), files: map[string]bool{}, dirs: map[string]bool{}}
+	if _, ok := t.local("."); !ok {
+		return nil, fmt.Errorf("the command runs in %s, outside of %s where the project is copied", workdir, root)
+	}
+
+	var entrypoint string
+	var err error
+	switch interpreter := path.Base(command[0]); {
+	case nodeInterpreters[interpreter]:
+		entrypoint, err = t.traceNode(command[1:])
+	case pythonInterpreterRegex.MatchString(interpreter), pythonServers[interpreter]:
+		entrypoint, err = t.tracePython(interpreter, command[1:])
+	default:
+		return nil, fmt.Errorf("'%s' isn't a nodejs or python interpreter", command[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Entrypoint: entrypoint, Unresolved: t.unresolved}
+	for f := range t.files {
+		result.Files = append(result.Files, f)
+	}
+	for d := range t.dirs {
+		result.Dirs = append(result.Dirs, d)
+	}
+	sort.Strings(result.Files)
+	sort.Strings(result.Dirs)
+	return result, nil
+}
+
+// local returns the path of the project a path of the image refers to, relative to the working directory
+// if it isn't absolute. ok is false if it's outside the project.
+func (t *tracer) local(p string) (string, bool) {
+	if !path.IsAbs(p) {
+		p = path.Join(t.workdir, p)
+	}
+	p = path.Clean(p)
+	switch {
+	case p == t.root:
+		return ".", true
+	case t.root == "/":
+		return strings.TrimPrefix(p, "/"), true
+	case strings.HasPrefix(p, t.root+"/"):
+		return strings.TrimPrefix(p, t.root+"/"), true
+	}
+	return "", false
+}
+
+// addPath records a file or directory of the project the application reads. Paths that don't exist are
+// assumed to be created at runtime, eg- a directory of uploads.
+func (t *tracer) addPath(p string) {
+	p = path.Clean(p)
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return
+	}
+	info, err := fs.Stat(t.fsys, p)
+	switch {
+	case err != nil:
+	case info.IsDir():
+		t.dirs[p] = true
+	default:
+		t.files[p] = true
+	}
+}
+
+func (t *tracer) addUnresolved(format string, args ...any) {
+	t.unresolved = append(t.unresolved, fmt.Sprintf(format, args...))
+}
+
+func (t *tracer) isFile(p string) bool {
+	info, err := fs.Stat(t.fsys, p)
+	return err == nil && !info.IsDir()
+}
+
+func (t *tracer) isDir(p string) bool {
+	info, err := fs.Stat(t.fsys, p)
+	return err == nil && info.IsDir()
+}
+
+// readPackageJSON returns the fields of the package.json in the given directory of the project that
+// affect how node loads its files, nil if there's none
+func (t *tracer) readPackageJSON(dir string) *packageJSON {
+	content, err := fs.ReadFile(t.fsys, path.Join(dir, "package.json"))
+	if err != nil {
+		return nil
+	}
+	var pkg packageJSON
+	if json.Unmarshal(content, &pkg) != nil {
+		return nil
+	}
+	return &pkg
+}
+
+type packageJSON struct {
+	Main                 string            `json:"main"`
+	Dependencies         map[string]string `json:"dependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	PeerDependencies     map[string]string `json:"peerDependencies"`
+}
@@ -0,0 +1,160 @@
+package runtimepaths
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTrace(t *testing.T) {
+	tests := []struct {
+		name       string
+		files      fstest.MapFS
+		command    []string
+		root       string
+		workdir    string
+		entrypoint string
+		paths      []string
+		unresolved []string
+		err        string
+	}{
+		{
+			name: "express server",
+			files: fstest.MapFS{
+				"package.json":         {Data: []byte(`{"dependencies": {"express": "^4", "dotenv": "^16"}}`)},
+				"server.js":            {Data: []byte("require('dotenv').config()\nconst express = require('express')\nconst routes = require('./routes')\nconst path = require('node:path')\napp.use(express.static(path.join(__dirname, 'public')))\napp.set('views', path.join(__dirname, 'views'))\n")},
+				"routes/index.js":      {Data: []byte("const users = require('./users')\nconst config = require('../config.json')\n")},
+				"routes/users.js":      {Data: []byte("const { db } = require('../lib/db')\n")},
+				"lib/db.ts":            {Data: []byte("import fs from 'fs'\nexport const ca = fs.readFileSync('./certs/ca.pem')\n")},
+				"config.json":          {Data: []byte("{}")},
+				"certs/ca.pem":         {Data: []byte("pem")},
+				"public/index.html":    {Data: []byte("<html>")},
+				"views/home.ejs":       {Data: []byte("<%= title %>")},
+				".env":                 {Data: []byte("PORT=3000")},
+				"test/server.test.js":  {Data: []byte("require('../server')")},
+				"docs/architecture.md": {Data: []byte("# docs")},
+				"scripts/seed.js":      {Data: []byte("require('../lib/db')")},
+			},
+			command:    []string{"node", "server.js"},
+			root:       "/app",
+			workdir:    "/app",
+			entrypoint: "server.js",
+			paths:      []string{".env", "certs", "config.json", "lib", "package.json", "public", "routes", "server.js", "views"},
+		},
+		{
+			name: "ES module with a preloaded script",
+			files: fstest.MapFS{
+				"package.json":        {Data: []byte(`{"type": "module", "dependencies": {"fastify": "^5"}}`)},
+				"src/main.mjs":        {Data: []byte("import { fileURLToPath } from 'node:url'\nconst __filename = fileURLToPath(import.meta.url)\nconst __dirname = path.dirname(__filename)\nimport app from './app.js'\nconst schema = new URL('../schemas/user.json', import.meta.url)\n")},
+				"src/app.ts":          {Data: []byte("import Fastify from 'fastify'\nexport * from './plugins/auth'\n")},
+				"src/plugins/auth.ts": {Data: []byte("export const auth = {}\n")},
+				"schemas/user.json":   {Data: []byte("{}")},
+				"instrument.js":       {Data: []byte("globalThis.started = Date.now()\n")},
+				"README.md":           {Data: []byte("# app")},
+			},
+			command:    []string{"node", "--import", "./instrument.js", "/srv/src/main.mjs"},
+			root:       "/srv",
+			workdir:    "/srv",
+			entrypoint: "src/main.mjs",
+			paths:      []string{"instrument.js", "package.json", "schemas", "src"},
+		},
+		{
+			name: "computed imports and path aliases",
+			files: fstest.MapFS{
+				"package.json": {Data: []byte(`{"dependencies": {}}`)},
+				"index.js":     {Data: []byte("const plugins = fs.readdirSync(dir).map(f => require(f))\nconst utils = require('@/utils')\nconsole.log(__dirname)\n")},
+			},
+			command:    []string{"node", "index.js"},
+			root:       "/",
+			workdir:    "/",
+			entrypoint: "index.js",
+			paths:      []string{"index.js", "package.json"},
+			unresolved: []string{"index.js imports '@/utils', which isn't a dependency of package.json, eg- a path alias", "index.js imports a computed path", "index.js refers to its location or the working directory in a way that can't be followed"},
+		},
+		{
+			name: "python module run by gunicorn",
+			files: fstest.MapFS{
+				"app/__init__.py":                 {Data: []byte("from flask import Flask\napp = Flask(__name__)\nfrom . import views\n")},
+				"app/views.py":                    {Data: []byte("from .models import User\nimport app.services.mail as mail\nimport os, json\n")},
+				"app/models.py":                   {Data: []byte("import sqlalchemy\n")},
+				"app/services/__init__.py":        {Data: []byte("")},
+				"app/services/mail.py":            {Data: []byte("TEMPLATES = os.path.join(os.path.dirname(__file__), 'emails')\n")},
+				"app/services/emails/welcome.txt": {Data: []byte("hi")},
+				"app/templates/index.html":        {Data: []byte("<html>")},
+				"gunicorn.conf.py":                {Data: []byte("workers = 2\n")},
+				"tests/test_views.py":             {Data: []byte("import app\n")},
+			},
+			command:    []string{"gunicorn", "-c", "gunicorn.conf.py", "--bind", "0.0.0.0:8000", "app:app"},
+			root:       "/code",
+			workdir:    "/code",
+			entrypoint: "app/__init__.py",
+			paths:      []string{"app", "gunicorn.conf.py"},
+		},
+		{
+			name: "python script in a subdirectory",
+			files: fstest.MapFS{
+				"src/main.py":              {Data: []byte("import settings\nfrom utils.io import load\nwith open('data/words.txt') as f:\n    pass\n")},
+				"src/settings.py":          {Data: []byte("DEBUG = False\n")},
+				"src/utils/__init__.py":    {Data: []byte("")},
+				"src/utils/io.py":          {Data: []byte("import importlib\nmod = importlib.import_module(name)\n")},
+				"data/words.txt":           {Data: []byte("a")},
+				"notebooks/analysis.ipynb": {Data: []byte("{}")},
+			},
+			command:    []string{"python3", "-u", "src/main.py"},
+			root:       "/app",
+			workdir:    "/app",
+			entrypoint: "src/main.py",
+			paths:      []string{"data", "src"},
+			unresolved: []string{"src/utils/io.py imports modules whose names are computed or configured"},
+		},
+		{
+			name:    "script built in the image",
+			files:   fstest.MapFS{"package.json": {Data: []byte(`{}`)}, "src/main.ts": {Data: []byte("")}},
+			command: []string{"node", "dist/main.js"},
+			root:    "/app",
+			workdir: "/app",
+			err:     "isn't part of the project",
+		},
+		{
+			name:    "not an interpreter",
+			files:   fstest.MapFS{},
+			command: []string{"./server"},
+			root:    "/app",
+			workdir: "/app",
+			err:     "isn't a nodejs or python interpreter",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Trace(tt.files, tt.command, tt.root, tt.workdir)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Entrypoint != tt.entrypoint {
+				t.Errorf("expected entrypoint %s, got %s", tt.entrypoint, result.Entrypoint)
+			}
+			if !reflect.DeepEqual(result.Paths(), tt.paths) {
+				t.Errorf("expected paths %v, got %v (files %v, dirs %v)", tt.paths, result.Paths(), result.Files, result.Dirs)
+			}
+			unresolved := result.Unresolved
+			if unresolved == nil {
+				unresolved = []string{}
+			}
+			expected := tt.unresolved
+			if expected == nil {
+				expected = []string{}
+			}
+			if !reflect.DeepEqual(unresolved, expected) {
+				t.Errorf("expected unresolved %v, got %v", expected, unresolved)
+			}
+		})
+	}
+}
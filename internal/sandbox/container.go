@@ -0,0 +1,142 @@
+package sandbox
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Container is a long-running sandbox, eg- an application under test
+type Container struct {
+	sandbox *Sandbox
+	name    string
+}
+
+// State is the state of a sandbox container
+type State struct {
+	Running   bool `json:"Running"`
+	ExitCode  int  `json:"ExitCode"`
+	OOMKilled bool `json:"OOMKilled"`
+	Health    *struct {
+		// Status is "starting", "healthy" or "unhealthy"
+		Status string `json:"Status"`
+	} `json:"Health"`
+}
+
+// HealthStatus returns the status of the container's health check, or "" if the image doesn't define one
+func (s *State) HealthStatus() string {
+	if s.Health == nil {
+		return ""
+	}
+	return s.Health.Status
+}
+
+// Start runs the spec in a container in the background, with the same limits as Run.
+// The spec's timeout is ignored, the caller must Remove the container when it's done with it.
+func (s *Sandbox) Start(ctx context.Context, spec *Spec) (*Container, error) {
+	name, err := containerName()
+	if err != nil {
+		return nil, err
+	}
+	c := &Container{sandbox: s, name: name}
+	if _, err := s.docker.Output(ctx, spec.runArgs(name, true)...); err != nil {
+		c.Remove()
+		return nil, fmt.Errorf("failed to start sandbox: %w", err)
+	}
+	return c, nil
+}
+
+// State returns the current state of the container
+func (c *Container) State(ctx context.Context) (*State, error) {
+	out, err := c.sandbox.docker.Output(ctx, "inspect", "--format", "{{json .State}}", c.name)
+	if err != nil {
+		return nil, err
+	}
+	state := &State{}
+	if err := json.Unmarshal([]byte(out), state); err != nil {
+		return nil, fmt.Errorf("failed to parse container state: %w", err)
+	}
+	return state, nil
+}
+
+// Exec runs a command inside the running container and waits for it to finish
+func (c *Container) Exec(ctx context.Context, command []string, timeout time.Duration) (*Result, error) {
+	return c.ExecAs(ctx, "", command, timeout)
+}
+
+// ExecAs is like Exec, but runs the command as the given user instead of the image's user, eg- "0" for root
+func (c *Container) ExecAs(ctx context.Context, user string, command []string, timeout time.Duration) (*Result, error) {
+	if len(command) == 0 {
+		return nil, errors.New("no command given")
+	}
+	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := []string{"exec"}
+	if user != "" {
+		args = append(args, "--user", user)
+	}
+	args = append(args, c.name)
+
+	stdout, stderr := &limitedBuffer{max: maxOutputBytes}, &limitedBuffer{max: maxOutputBytes}
+	cmd := c.sandbox.docker.Command(execCtx, append(args, command...)...)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	result := &Result{
+		Duration: time.Since(start),
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		TimedOut: errors.Is(execCtx.Err(), context.DeadlineExceeded),
+	}
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("failed to run command in sandbox: %w", err)
+	}
+	if exitErr != nil {
+		result.ExitCode = exitErr.ExitCode()
+	}
+	return result, nil
+}
+
+// ReadFile returns the contents of a file inside the container.
+// Unlike the output of Exec, the contents are not truncated.
+func (c *Container) ReadFile(ctx context.Context, path string) ([]byte, error) {
+	var out, stderr bytes.Buffer
+	cmd := c.sandbox.docker.Command(ctx, "cp", c.name+":"+path, "-")
+	cmd.Stdout, cmd.Stderr = &out, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to copy %s from sandbox: %s", path, strings.TrimSpace(stderr.String()))
+	}
+
+	// docker cp writes a tar archive to stdout
+	tr := tar.NewReader(&out)
+	if _, err := tr.Next(); err != nil {
+		return nil, fmt.Errorf("failed to read %s from sandbox: %w", path, err)
+	}
+	return io.ReadAll(tr)
+}
+
+// Logs returns the stdout and stderr of the container's main process
+func (c *Container) Logs(ctx context.Context) string {
+	var out limitedBuffer
+	out.max = maxOutputBytes
+	cmd := c.sandbox.docker.Command(ctx, "logs", c.name)
+	cmd.Stdout, cmd.Stderr = &out, &out
+	_ = cmd.Run()
+	return out.String()
+}
+
+// Remove stops and deletes the container
+func (c *Container) Remove() {
+	c.sandbox.remove(c.name)
+}
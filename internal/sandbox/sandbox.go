@@ -0,0 +1,268 @@
+package sandbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/docker"
+)
+
+// maxOutputBytes is the max amount of stdout and stderr captured from a command, each
+const maxOutputBytes = 1 << 20
+
+// Limits constrain the resources available to a sandboxed command
+type Limits struct {
+	// CPUs is the number of CPUs the command can use, eg- 0.5
+	CPUs float64
+	// Memory is the max memory in bytes. Swap is disabled.
+	Memory int64
+	// Pids is the max number of processes
+	Pids int
+	// Timeout is how long the command may run before it is killed
+	Timeout time.Duration
+	// Network enables network access. Commands have no network by default.
+	Network bool
+}
+
+// DefaultLimits are used for any command dockershrink runs on behalf of the user
+func DefaultLimits() Limits {
+	return Limits{
+		CPUs:    1,
+		Memory:  512 << 20,
+		Pids:    256,
+		Timeout: 2 * time.Minute,
+	}
+}
+
+// Mount is a directory from the host made available inside the sandbox
+type Mount struct {
+	Source   string
+	Target   string
+	ReadOnly bool
+}
+
+// Spec describes a command to run inside the sandbox
+type Spec struct {
+	// Image is the image the sandbox container is created from
+	Image string
+	// Command overrides the image's command. The image's default command is run if it's empty.
+	Command []string
+	// Entrypoint overrides the image's entrypoint
+	Entrypoint string
+	Workdir    string
+	Env        map[string]string
+	Mounts     []Mount
+	Limits     Limits
+
+	// Network is a docker network to attach the container to, eg- to reach other containers.
+	// It takes precedence over Limits.Network.
+	Network string
+	// NetworkAliases are the hostnames of the container on Network
+	NetworkAliases []string
+
+	// CapAdd are the Linux capabilities granted to the container, eg- "FOWNER".
+	// All other capabilities are dropped.
+	CapAdd []string
+}
+
+// Result is the structured outcome of a sandboxed command
+type Result struct {
+	ExitCode int           `json:"exit_code"`
+	Stdout   string        `json:"stdout"`
+	Stderr   string        `json:"stderr"`
+	Duration time.Duration `json:"duration"`
+	// TimedOut is true if the command was killed because it exceeded its timeout
+	TimedOut bool `json:"timed_out"`
+	// OOMKilled is true if the command was killed because it exceeded its memory limit
+	OOMKilled bool `json:"oom_killed"`
+}
+
+// Succeeded returns true if the command ran to completion and exited with status 0
+func (r *Result) Succeeded() bool {
+	return r.ExitCode == 0 && !r.TimedOut && !r.OOMKilled
+}
+
+// Sandbox runs commands in throwaway containers with constrained resources,
+// so that nothing dockershrink executes can affect the host.
+type Sandbox struct {
+	docker *docker.Client
+}
+
+func New(client *docker.Client) *Sandbox {
+	return &Sandbox{docker: client}
+}
+
+// Run runs the command described by spec and waits for it to finish.
+// A non-zero exit code is reported in the result and is not an error.
+// An error is only returned if the sandbox itself could not be created.
+func (s *Sandbox) Run(ctx context.Context, spec *Spec) (*Result, error) {
+	name, err := containerName()
+	if err != nil {
+		return nil, err
+	}
+	// the container is kept after it exits so that its state can be inspected
+	defer s.remove(name)
+
+	timeout := spec.Limits.Timeout
+	if timeout <= 0 {
+		timeout = DefaultLimits().Timeout
+	}
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout, stderr := &limitedBuffer{max: maxOutputBytes}, &limitedBuffer{max: maxOutputBytes}
+	// the context is not passed to the docker CLI because killing the CLI leaves the container running
+	cmd := s.docker.Command(context.Background(), spec.runArgs(name, false)...)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start sandbox: %w", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	result := &Result{}
+	select {
+	case err = <-done:
+	case <-runCtx.Done():
+		s.remove(name)
+		<-done
+		if !errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("sandbox was cancelled: %w", runCtx.Err())
+		}
+		result.TimedOut = true
+		err = nil
+	}
+	result.Duration = time.Since(start)
+	result.Stdout, result.Stderr = stdout.String(), stderr.String()
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("failed to run sandbox: %w", err)
+	}
+	if exitErr != nil {
+		result.ExitCode = exitErr.ExitCode()
+	}
+	if !result.TimedOut {
+		// docker run exits with 125 if the container could not be created
+		if result.ExitCode == 125 {
+			return nil, fmt.Errorf("failed to create sandbox container: %s", result.Stderr)
+		}
+		oom, _ := s.docker.Output(context.Background(), "inspect", "--format", "{{.State.OOMKilled}}", name)
+		result.OOMKilled = oom == "true"
+	}
+	return result, nil
+}
+
+func (s *Sandbox) remove(name string) {
+	_ = s.docker.Command(context.Background(), "rm", "--force", name).Run()
+}
+
+// runArgs returns the arguments to "docker" which run the spec in a container with the given name.
+// If detach is true, docker returns as soon as the container has started.
+func (spec *Spec) runArgs(name string, detach bool) []string {
+	limits := spec.Limits
+	defaults := DefaultLimits()
+	if limits.CPUs <= 0 {
+		limits.CPUs = defaults.CPUs
+	}
+	if limits.Memory <= 0 {
+		limits.Memory = defaults.Memory
+	}
+	if limits.Pids <= 0 {
+		limits.Pids = defaults.Pids
+	}
+
+	args := []string{"run"}
+	if detach {
+		args = append(args, "--detach")
+	}
+	args = append(args,
+		"--name", name,
+		"--cpus", strconv.FormatFloat(limits.CPUs, 'f', -1, 64),
+		"--memory", strconv.FormatInt(limits.Memory, 10),
+		// same as memory, which disables swap
+		"--memory-swap", strconv.FormatInt(limits.Memory, 10),
+		"--pids-limit", strconv.Itoa(limits.Pids),
+		"--security-opt", "no-new-privileges",
+		"--cap-drop", "ALL",
+	)
+	for _, c := range spec.CapAdd {
+		args = append(args, "--cap-add", c)
+	}
+	switch {
+	case spec.Network != "":
+		args = append(args, "--network", spec.Network)
+		for _, alias := range spec.NetworkAliases {
+			args = append(args, "--network-alias", alias)
+		}
+	case !limits.Network:
+		args = append(args, "--network", "none")
+	}
+	if spec.Workdir != "" {
+		args = append(args, "--workdir", spec.Workdir)
+	}
+	if spec.Entrypoint != "" {
+		args = append(args, "--entrypoint", spec.Entrypoint)
+	}
+
+	envKeys := make([]string, 0, len(spec.Env))
+	for k := range spec.Env {
+		envKeys = append(envKeys, k)
+	}
+	sort.Strings(envKeys)
+	for _, k := range envKeys {
+		args = append(args, "--env", k+"="+spec.Env[k])
+	}
+
+	for _, m := range spec.Mounts {
+		mount := fmt.Sprintf("type=bind,source=%s,target=%s", m.Source, m.Target)
+		if m.ReadOnly {
+			mount += ",readonly"
+		}
+		args = append(args, "--mount", mount)
+	}
+
+	args = append(args, spec.Image)
+	return append(args, spec.Command...)
+}
+
+func containerName() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate sandbox name: %w", err)
+	}
+	return "dockershrink-sandbox-" + hex.EncodeToString(b), nil
+}
+
+// limitedBuffer keeps the first max bytes written to it and discards the rest
+type limitedBuffer struct {
+	buf       []byte
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - len(b.buf); room > 0 {
+		b.buf = append(b.buf, p[:min(room, len(p))]...)
+	}
+	if len(b.buf)+len(p) > b.max {
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return string(b.buf) + "\n... (truncated)"
+	}
+	return string(b.buf)
+}
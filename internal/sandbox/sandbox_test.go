@@ -0,0 +1,66 @@
+package sandbox
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSpecRunArgs(t *testing.T) {
+	spec := &Spec{
+		Image:   "node:20-alpine",
+		Command: []string{"node", "index.js"},
+		Workdir: "/app",
+		Env:     map[string]string{"PORT": "3000", "NODE_ENV": "production"},
+		Mounts:  []Mount{{Source: "/src", Target: "/app", ReadOnly: true}},
+	}
+
+	args := strings.Join(spec.runArgs("sb", false), " ")
+	for _, expected := range []string{
+		"run --name sb",
+		"--cpus 1 --memory 536870912 --memory-swap 536870912 --pids-limit 256",
+		"--network none",
+		"--env NODE_ENV=production --env PORT=3000",
+		"--mount type=bind,source=/src,target=/app,readonly",
+	} {
+		if !strings.Contains(args, expected) {
+			t.Errorf("runArgs() = %q; want it to contain %q", args, expected)
+		}
+	}
+	if !strings.HasSuffix(args, "node:20-alpine node index.js") {
+		t.Errorf("runArgs() = %q; want it to end with the image and command", args)
+	}
+	if detached := spec.runArgs("sb", true); detached[1] != "--detach" {
+		t.Errorf("runArgs() = %v; want a detached container", detached)
+	}
+
+	spec.Limits = Limits{CPUs: 0.5, Network: true}
+	withNetwork := spec.runArgs("sb", false)
+	if slices.Contains(withNetwork, "none") {
+		t.Errorf("runArgs() = %v; network must not be disabled", withNetwork)
+	}
+	if !slices.Contains(withNetwork, "0.5") {
+		t.Errorf("runArgs() = %v; want --cpus 0.5", withNetwork)
+	}
+
+	spec.CapAdd = []string{"FOWNER"}
+	if withCaps := strings.Join(spec.runArgs("sb", false), " "); !strings.Contains(withCaps, "--cap-drop ALL --cap-add FOWNER") {
+		t.Errorf("runArgs() = %q; want FOWNER added after dropping all capabilities", withCaps)
+	}
+
+	spec.Limits, spec.CapAdd = Limits{}, nil
+	spec.Network, spec.NetworkAliases = "verify_default", []string{"api"}
+	onNetwork := strings.Join(spec.runArgs("sb", false), " ")
+	if !strings.Contains(onNetwork, "--network verify_default --network-alias api") || strings.Contains(onNetwork, "none") {
+		t.Errorf("runArgs() = %q; want the container attached to verify_default as api", onNetwork)
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	b := &limitedBuffer{max: 5}
+	b.Write([]byte("abc"))
+	b.Write([]byte("defgh"))
+	if got := b.String(); got != "abcde\n... (truncated)" {
+		t.Errorf("String() = %q; want truncated output", got)
+	}
+}
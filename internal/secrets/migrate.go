@@ -0,0 +1,372 @@
+package secrets
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+)
+
+// MountSyntax is the syntax directive set by Migrate if the Dockerfile's doesn't support the env option
+// of secret mounts, which was added in version 1.10 of the Dockerfile syntax
+const MountSyntax = "docker/dockerfile:1"
+
+// matches commands that install packages or fetch code, which commonly read registry credentials
+// from the environment without referring to them, eg- npm reads "${NPM_TOKEN}" from .npmrc
+var credentialUsersRegex = regexp.MustCompile(`\b(npm|pnpm)\s+(ci|install|i|add)\b|\byarn(\s+(install|add)\b|\s*($|&&|;|--))|\bpip3?\s+install\b|\bpoetry\s+install\b|\bbundle\s+install\b|\bcomposer\s+install\b|\bgo\s+(mod\s+download|get)\b|\bgit\s+clone\b`)
+
+// BuildSecret is a secret passed to the build, eg- "docker build --secret id=npm_token,env=NPM_TOKEN"
+type BuildSecret struct {
+	// ID identifies the secret in the mounts of RUN instructions
+	ID string
+	// Env is the environment variable the secret is read from when the image is built
+	Env string
+}
+
+// String returns the value of docker build's --secret flag that passes the secret
+func (s *BuildSecret) String() string {
+	return "id=" + s.ID + ",env=" + s.Env
+}
+
+// Result is the outcome of migrating a single secret
+type Result struct {
+	Secret *Secret
+	// Build is the secret the image must now be built with, nil if the secret wasn't migrated
+	Build *BuildSecret
+	// Runs are the lines of the RUN instructions the secret is mounted in, in the original Dockerfile
+	Runs []int
+	// Reason explains why the secret wasn't migrated
+	Reason string
+}
+
+// Migrated returns true if the variable was replaced with a secret mount
+func (r *Result) Migrated() bool {
+	return r.Build != nil
+}
+
+// Migration is the outcome of migrating all the secrets of a Dockerfile
+type Migration struct {
+	Results []*Result
+	// SyntaxChanged is true if the syntax directive was added or upgraded to MountSyntax
+	SyntaxChanged bool
+}
+
+// BuildSecrets returns the secrets the migrated Dockerfile must be built with, sorted by ID
+func (m *Migration) BuildSecrets() []*BuildSecret {
+	byID := map[string]*BuildSecret{}
+	for _, r := range m.Results {
+		if r.Migrated() {
+			byID[r.Build.ID] = r.Build
+		}
+	}
+	secrets := make([]*BuildSecret, 0, len(byID))
+	for _, s := range byID {
+		secrets = append(secrets, s)
+	}
+	sort.Slice(secrets, func(i, j int) bool { return secrets[i].ID < secrets[j].ID })
+	return secrets
+}
+
+// candidate is a secret that Migrate replaces with a secret mount, unless reason gets set
+type candidate struct {
+	result *Result
+	decl   *dockerfile.Instruction
+	// env is the variable the secret is exposed as to RUN instructions
+	env string
+	// related are the candidates that must be migrated together with this one, eg- an ARG and the ENV set to it
+	related []*candidate
+}
+
+func (c *candidate) block(reason string) {
+	if c.result.Reason != "" {
+		return
+	}
+	c.result.Reason = reason
+	for _, r := range c.related {
+		r.block(reason)
+	}
+}
+
+// Migrate replaces the build arguments holding secrets, and the ENV variables set to them, with secret mounts
+// on the RUN instructions that need them, eg-
+//
+//	ARG NPM_TOKEN
+//	RUN npm ci
+//
+// becomes
+//
+//	RUN --mount=type=secret,id=npm_token,env=NPM_TOKEN npm ci
+//
+// The secret is mounted in the RUN instructions that refer to the variable or install packages. A secret is
+// left as it is if its value is written in the Dockerfile, if an instruction other than RUN uses it, or if no
+// RUN instruction that needs it can be found. The syntax directive is set to MountSyntax if it's older than 1.10.
+// The migrated Dockerfile is returned, d isn't modified.
+func Migrate(d *dockerfile.Dockerfile) (*dockerfile.Dockerfile, *Migration, error) {
+	migration := &Migration{}
+	candidates := []*candidate{}
+	stages := d.GetStages()
+
+	for _, stage := range stages {
+		active := map[string]*candidate{}
+		stageCandidates := []*candidate{}
+		add := func(c *candidate) {
+			migration.Results = append(migration.Results, c.result)
+			stageCandidates = append(stageCandidates, c)
+			active[c.env] = c
+		}
+		// blockReferences prevents the migration of the active secrets that code expands
+		blockReferences := func(inst *dockerfile.Instruction, code string) {
+			for name, c := range active {
+				if references(code, name) {
+					c.block(fmt.Sprintf("the %s instruction on line %d uses it", inst.Cmd(), inst.StartLine()))
+				}
+			}
+		}
+
+		for _, inst := range stage.Instructions() {
+			switch inst.Cmd() {
+			case dockerfile.CmdArg:
+				for _, a := range inst.Args() {
+					name, value, hasValue := strings.Cut(a, "=")
+					if hasValue {
+						blockReferences(inst, value)
+					}
+					if !IsSecretName(name) {
+						// the variable no longer refers to the secret
+						delete(active, name)
+						continue
+					}
+					s := &Secret{Name: name, Cmd: dockerfile.CmdArg, Line: inst.StartLine(), Stage: stage, Literal: value != ""}
+					c := &candidate{result: &Result{Secret: s}, decl: inst, env: name}
+					if s.Literal {
+						c.result.Reason = "its value is written in the Dockerfile"
+					}
+					add(c)
+				}
+			case dockerfile.CmdEnv:
+				for _, pair := range envPairs(inst) {
+					source := variable(pair.value)
+					arg := active[source]
+					if arg == nil || arg.result.Secret.Cmd != dockerfile.CmdArg {
+						blockReferences(inst, pair.value)
+						delete(active, pair.key)
+						continue
+					}
+					s := &Secret{Name: pair.key, Cmd: dockerfile.CmdEnv, Line: inst.StartLine(), Stage: stage, Source: source}
+					c := &candidate{result: &Result{Secret: s}, decl: inst, env: pair.key, related: []*candidate{arg}}
+					arg.related = append(arg.related, c)
+					if arg.result.Reason != "" {
+						c.result.Reason = arg.result.Reason
+					}
+					add(c)
+				}
+			case dockerfile.CmdRun:
+				cmd := inst.Command()
+				installs := credentialUsersRegex.MatchString(cmd)
+				for name, c := range active {
+					// an ARG that an ENV is set to is only mounted where it's referred to, the ENV covers the installs
+					implicit := installs && (c.result.Secret.Cmd == dockerfile.CmdEnv || len(c.related) == 0)
+					if implicit || references(cmd, name) {
+						c.result.Runs = append(c.result.Runs, inst.StartLine())
+					}
+				}
+			default:
+				blockReferences(inst, inst.Original())
+			}
+		}
+
+		for _, c := range stageCandidates {
+			if c.result.Secret.Cmd == dockerfile.CmdEnv {
+				// ENV variables are inherited by the stages built from this one
+				for _, other := range stages {
+					if base := d.GetBaseStage(other); base != nil && base.Index() == stage.Index() {
+						c.block(fmt.Sprintf("the stage on line %d is built from this stage and inherits it", other.StartLine()))
+					}
+				}
+			}
+			if len(c.result.Runs) == 0 && !hasRuns(c.related) {
+				c.block("no RUN instruction that needs it could be found")
+			}
+		}
+		candidates = append(candidates, stageCandidates...)
+	}
+
+	// every declaration and RUN instruction is edited once, from the bottom up, so that
+	// the line numbers of the instructions above stay valid
+	removed := map[int]map[string]bool{}
+	mounts := map[int][]string{}
+	for _, c := range candidates {
+		if c.result.Reason != "" {
+			continue
+		}
+		build := &BuildSecret{ID: strings.ToLower(c.result.Secret.Name), Env: c.result.Secret.Name}
+		if c.result.Secret.Source != "" {
+			build = &BuildSecret{ID: strings.ToLower(c.result.Secret.Source), Env: c.result.Secret.Source}
+		}
+		c.result.Build = build
+
+		line := c.decl.StartLine()
+		if removed[line] == nil {
+			removed[line] = map[string]bool{}
+		}
+		removed[line][c.env] = true
+		for _, run := range c.result.Runs {
+			mounts[run] = appendUnique(mounts[run], fmt.Sprintf("--mount=type=secret,id=%s,env=%s", build.ID, c.env))
+		}
+	}
+	if len(removed) == 0 {
+		return d, migration, nil
+	}
+	removeUnusedGlobalArgs(d, stages, removed)
+
+	migrated, err := dockerfile.NewDockerfile(d.Raw())
+	if err != nil {
+		return nil, nil, err
+	}
+	lines := []int{}
+	for line := range removed {
+		lines = append(lines, line)
+	}
+	for line := range mounts {
+		if removed[line] == nil {
+			lines = append(lines, line)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(lines)))
+	for _, line := range lines {
+		inst := instructionAt(migrated, line)
+		if inst == nil {
+			return nil, nil, fmt.Errorf("instruction on line %d not found", line)
+		}
+		if names := removed[line]; names != nil {
+			if err := removeVariables(migrated, inst, names); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		// every flag is added right after the instruction's name, so they're added in reverse to keep their order
+		flags := mounts[line]
+		for i := len(flags) - 1; i >= 0; i-- {
+			flag := flags[i]
+			if hasFlag(inst, flag) {
+				continue
+			}
+			if err := migrated.AddFlag(inst, flag); err != nil {
+				return nil, nil, err
+			}
+			inst = instructionAt(migrated, line)
+		}
+	}
+
+	if migrated.Syntax() == "" || !dockerfile.SyntaxAtLeast(migrated.Syntax(), 1, 10) {
+		if err := migrated.SetSyntax(MountSyntax); err != nil {
+			return nil, nil, err
+		}
+		migration.SyntaxChanged = true
+	}
+	return migrated, migration, nil
+}
+
+// removeUnusedGlobalArgs adds the secret ARGs declared before the first FROM to removed, if every stage that
+// declared them again had its declaration removed and no FROM instruction uses them
+func removeUnusedGlobalArgs(d *dockerfile.Dockerfile, stages []*dockerfile.Stage, removed map[int]map[string]bool) {
+	for _, inst := range d.GetGlobalArgs() {
+		for _, a := range inst.Args() {
+			name, value, _ := strings.Cut(a, "=")
+			if !IsSecretName(name) || value != "" {
+				continue
+			}
+			used, redeclared := false, false
+			for _, stage := range stages {
+				used = used || references(stage.DeclaredBaseImage(), name)
+				for _, si := range stage.Instructions() {
+					if si.Cmd() != dockerfile.CmdArg {
+						continue
+					}
+					for _, sa := range si.Args() {
+						if n, _, _ := strings.Cut(sa, "="); n == name {
+							redeclared = true
+							used = used || !removed[si.StartLine()][name]
+						}
+					}
+				}
+			}
+			if used || !redeclared {
+				continue
+			}
+			if removed[inst.StartLine()] == nil {
+				removed[inst.StartLine()] = map[string]bool{}
+			}
+			removed[inst.StartLine()][name] = true
+		}
+	}
+}
+
+// removeVariables removes the named variables from an ARG or ENV instruction, and the instruction
+// itself if it declares nothing else
+func removeVariables(d *dockerfile.Dockerfile, inst *dockerfile.Instruction, names map[string]bool) error {
+	kept := []string{}
+	if inst.Cmd() == dockerfile.CmdArg {
+		for _, a := range inst.Args() {
+			if name, _, _ := strings.Cut(a, "="); !names[name] {
+				kept = append(kept, a)
+			}
+		}
+	} else {
+		for _, pair := range envPairs(inst) {
+			if !names[pair.key] {
+				kept = append(kept, pair.key+"="+pair.value)
+			}
+		}
+	}
+	if len(kept) == 0 {
+		return d.RemoveInstruction(inst)
+	}
+	return d.ReplaceInstruction(inst, inst.Cmd()+" "+strings.Join(kept, " "))
+}
+
+// instructionAt returns the instruction starting on the given line, including ARGs declared before the first FROM
+func instructionAt(d *dockerfile.Dockerfile, line int) *dockerfile.Instruction {
+	for _, inst := range d.GetGlobalArgs() {
+		if inst.StartLine() == line {
+			return inst
+		}
+	}
+	for _, stage := range d.GetStages() {
+		for _, inst := range stage.Instructions() {
+			if inst.StartLine() == line {
+				return inst
+			}
+		}
+	}
+	return nil
+}
+
+func hasFlag(inst *dockerfile.Instruction, flag string) bool {
+	for _, f := range inst.Flags() {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+func hasRuns(candidates []*candidate) bool {
+	for _, c := range candidates {
+		if len(c.result.Runs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, item := range list {
+		if item == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,140 @@
+// Package secrets finds secrets that are passed to a Dockerfile as build arguments or environment variables,
+// eg- the token of a private npm registry, and moves them to BuildKit secret mounts. Build arguments end up in
+// the image's history and ENV variables in its configuration, so anyone who can pull the image can read them.
+// A secret mount exposes the secret to a single RUN instruction without storing it anywhere in the image.
+package secrets
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+)
+
+var (
+	// secretWords are the words of variable names that hold secrets, eg- NPM_TOKEN or DB_PASSWORD
+	secretWords = map[string]bool{
+		"TOKEN": true, "SECRET": true, "PASSWORD": true, "PASSWD": true, "APIKEY": true, "CREDENTIALS": true, "AUTHTOKEN": true,
+	}
+	// keyQualifiers turn KEY into a secret when they precede it, eg- API_KEY or AWS_SECRET_ACCESS_KEY
+	keyQualifiers = map[string]bool{
+		"API": true, "ACCESS": true, "PRIVATE": true, "SECRET": true, "SIGNING": true, "ENCRYPTION": true,
+	}
+	// locationWords end the names of variables that only point to a secret, eg- PASSWORD_FILE or TOKEN_URL
+	locationWords = map[string]bool{"FILE": true, "PATH": true, "DIR": true, "URL": true}
+
+	// matches a value that's a single variable, eg- "$NPM_TOKEN" or "${NPM_TOKEN}"
+	variableRegex = regexp.MustCompile(`^\$(?:\{([A-Za-z_][A-Za-z0-9_]*)\}|([A-Za-z_][A-Za-z0-9_]*))$`)
+)
+
+// IsSecretName returns true if the name of a variable suggests that it holds a secret
+func IsSecretName(name string) bool {
+	words := strings.FieldsFunc(strings.ToUpper(name), func(r rune) bool {
+		return r == '_' || r == '-' || r == '.'
+	})
+	if len(words) == 0 || locationWords[words[len(words)-1]] {
+		return false
+	}
+	for i, w := range words {
+		if secretWords[w] || (w == "KEY" && i > 0 && keyQualifiers[words[i-1]]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Secret is a variable holding a secret, declared by an ARG or ENV instruction
+type Secret struct {
+	// Name is the name of the variable, eg- NPM_TOKEN
+	Name string
+	// Cmd is the instruction that declares the variable, ARG or ENV
+	Cmd  string
+	Line int
+	// Stage is the stage the variable is declared in, nil for an ARG declared before the first FROM
+	Stage *dockerfile.Stage
+	// Literal is true if the value of the secret is written in the Dockerfile, eg- "ENV NPM_TOKEN=abc"
+	Literal bool
+	// Source is the build argument an ENV variable is set to, eg- NPM_TOKEN for "ENV NODE_AUTH_TOKEN=$NPM_TOKEN"
+	Source string
+}
+
+// Find returns the secrets declared in the Dockerfile, in order of declaration. These are ARG and ENV
+// variables whose name suggests a secret, and ENV variables set to such a build argument.
+// ARGs declared before the first FROM are only returned if they have a default value,
+// since they aren't available to RUN instructions unless a stage declares them again.
+func Find(d *dockerfile.Dockerfile) []*Secret {
+	found := []*Secret{}
+	for _, inst := range d.GetGlobalArgs() {
+		for _, a := range inst.Args() {
+			name, value, _ := strings.Cut(a, "=")
+			if IsSecretName(name) && value != "" {
+				found = append(found, &Secret{Name: name, Cmd: dockerfile.CmdArg, Line: inst.StartLine(), Literal: true})
+			}
+		}
+	}
+
+	for _, stage := range d.GetStages() {
+		args := map[string]bool{}
+		for _, inst := range stage.Instructions() {
+			switch inst.Cmd() {
+			case dockerfile.CmdArg:
+				for _, a := range inst.Args() {
+					name, value, _ := strings.Cut(a, "=")
+					if !IsSecretName(name) {
+						continue
+					}
+					args[name] = true
+					found = append(found, &Secret{
+						Name: name, Cmd: dockerfile.CmdArg, Line: inst.StartLine(), Stage: stage, Literal: value != "",
+					})
+				}
+			case dockerfile.CmdEnv:
+				for _, pair := range envPairs(inst) {
+					source := variable(pair.value)
+					if !IsSecretName(pair.key) && !args[source] {
+						continue
+					}
+					found = append(found, &Secret{
+						Name:    pair.key,
+						Cmd:     dockerfile.CmdEnv,
+						Line:    inst.StartLine(),
+						Stage:   stage,
+						Literal: !strings.Contains(pair.value, "$"),
+						Source:  source,
+					})
+				}
+			}
+		}
+	}
+	return found
+}
+
+type envPair struct {
+	key, value string
+}
+
+// envPairs returns the variables set by an ENV instruction. Values keep their quotes.
+func envPairs(inst *dockerfile.Instruction) []envPair {
+	args := inst.Args()
+	pairs := []envPair{}
+	// ENV arguments are parsed as (key, value, separator) triplets
+	for i := 0; i+1 < len(args); i += 3 {
+		pairs = append(pairs, envPair{key: args[i], value: args[i+1]})
+	}
+	return pairs
+}
+
+// variable returns the name of the variable a value consists of, eg- NPM_TOKEN for "${NPM_TOKEN}".
+// An empty string is returned if the value is anything else.
+func variable(value string) string {
+	m := variableRegex.FindStringSubmatch(strings.Trim(value, `"`))
+	if m == nil {
+		return ""
+	}
+	return m[1] + m[2]
+}
+
+// references returns true if code expands the variable, eg- "$NPM_TOKEN" or "${NPM_TOKEN:-}"
+func references(code, name string) bool {
+	return regexp.MustCompile(`\$\{?` + regexp.QuoteMeta(name) + `\b`).MatchString(code)
+}
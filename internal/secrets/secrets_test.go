@@ -0,0 +1,175 @@
+package secrets
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+)
+
+func TestIsSecretName(t *testing.T) {
+	tests := map[string]bool{
+		"NPM_TOKEN":             true,
+		"NODE_AUTH_TOKEN":       true,
+		"GITHUB_TOKEN":          true,
+		"DB_PASSWORD":           true,
+		"api-key":               true,
+		"AWS_SECRET_ACCESS_KEY": true,
+		"STRIPE_APIKEY":         true,
+		"PASSWORD_FILE":         false,
+		"TOKEN_URL":             false,
+		"NODE_ENV":              false,
+		"CACHE_KEY":             false,
+		"TOKENIZER":             false,
+	}
+	for name, expected := range tests {
+		if got := IsSecretName(name); got != expected {
+			t.Errorf("IsSecretName(%q) = %v; want %v", name, got, expected)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	d, err := dockerfile.NewDockerfile(`ARG BASE=node:22
+ARG NPM_TOKEN
+ARG SENTRY_AUTH_TOKEN=abc
+FROM ${BASE}
+ARG NPM_TOKEN NODE_ENV=production
+ENV NODE_AUTH_TOKEN=${NPM_TOKEN} REGISTRY=$NODE_ENV
+ENV API_KEY="hardcoded"
+RUN npm ci`)
+	if err != nil {
+		t.Fatalf("failed to parse dockerfile: %v", err)
+	}
+
+	found := Find(d)
+	got := []string{}
+	for _, s := range found {
+		got = append(got, s.Cmd+" "+s.Name)
+	}
+	expected := []string{"ARG SENTRY_AUTH_TOKEN", "ARG NPM_TOKEN", "ENV NODE_AUTH_TOKEN", "ENV API_KEY"}
+	if strings.Join(got, ", ") != strings.Join(expected, ", ") {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if !found[0].Literal || found[0].Stage != nil {
+		t.Errorf("expected the global ARG to be literal and outside any stage")
+	}
+	if found[1].Literal || found[1].Line != 5 {
+		t.Errorf("expected the stage ARG on line 5 not to be literal, got line %d", found[1].Line)
+	}
+	if found[2].Source != "NPM_TOKEN" || found[2].Literal {
+		t.Errorf("expected the ENV to be set from NPM_TOKEN, got %q", found[2].Source)
+	}
+	if !found[3].Literal {
+		t.Errorf("expected the ENV with a value to be literal")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		secrets  []string
+		// skipped are the secrets that aren't migrated
+		skipped []string
+	}{
+		{
+			name: "arg used by an install",
+			input: `FROM node:22-alpine
+WORKDIR /app
+ARG NPM_TOKEN
+COPY package.json package-lock.json .npmrc ./
+RUN npm ci
+COPY . .
+RUN npm run build`,
+			expected: `# syntax=docker/dockerfile:1
+FROM node:22-alpine
+WORKDIR /app
+COPY package.json package-lock.json .npmrc ./
+RUN --mount=type=secret,id=npm_token,env=NPM_TOKEN npm ci
+COPY . .
+RUN npm run build`,
+			secrets: []string{"id=npm_token,env=NPM_TOKEN"},
+		},
+		{
+			name: "env set from a global arg",
+			input: `# syntax=docker/dockerfile:1.4
+ARG GH_TOKEN
+FROM node:22 AS build
+ARG GH_TOKEN NODE_ENV=production
+ENV NODE_AUTH_TOKEN=$GH_TOKEN
+RUN echo "//npm.pkg.github.com/:_authToken=${NODE_AUTH_TOKEN}" > .npmrc && yarn install
+RUN ls`,
+			expected: `# syntax=docker/dockerfile:1
+FROM node:22 AS build
+ARG NODE_ENV=production
+RUN --mount=type=secret,id=gh_token,env=NODE_AUTH_TOKEN echo "//npm.pkg.github.com/:_authToken=${NODE_AUTH_TOKEN}" > .npmrc && yarn install
+RUN ls`,
+			secrets: []string{"id=gh_token,env=GH_TOKEN"},
+		},
+		{
+			name: "secrets that can't be migrated",
+			input: `# syntax=docker/dockerfile:1
+FROM node:22 AS base
+ARG NPM_TOKEN
+ENV NPM_TOKEN=$NPM_TOKEN
+RUN npm ci
+FROM base
+ARG API_KEY=abc
+RUN curl -H "key: $API_KEY" https://example.com
+ARG DEPLOY_TOKEN
+LABEL token=$DEPLOY_TOKEN
+RUN ./deploy.sh $DEPLOY_TOKEN
+ARG SENTRY_AUTH_TOKEN
+RUN ls`,
+			skipped: []string{"NPM_TOKEN", "NPM_TOKEN", "API_KEY", "DEPLOY_TOKEN", "SENTRY_AUTH_TOKEN"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := dockerfile.NewDockerfile(tt.input)
+			if err != nil {
+				t.Fatalf("failed to parse dockerfile: %v", err)
+			}
+			migrated, migration, err := Migrate(d)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			expected := tt.expected
+			if expected == "" {
+				expected = tt.input
+			}
+			if migrated.Raw() != expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", expected, migrated.Raw())
+			}
+			if d.Raw() != tt.input {
+				t.Errorf("expected the original Dockerfile to be left untouched")
+			}
+
+			secrets := []string{}
+			for _, s := range migration.BuildSecrets() {
+				secrets = append(secrets, s.String())
+			}
+			if strings.Join(secrets, " ") != strings.Join(tt.secrets, " ") {
+				t.Errorf("expected build secrets %v, got %v", tt.secrets, secrets)
+			}
+			skipped := []string{}
+			for _, r := range migration.Results {
+				if !r.Migrated() {
+					if r.Reason == "" {
+						t.Errorf("expected a reason for not migrating %s", r.Secret.Name)
+					}
+					skipped = append(skipped, r.Secret.Name)
+				}
+			}
+			if strings.Join(skipped, " ") != strings.Join(tt.skipped, " ") {
+				t.Errorf("expected %v to be skipped, got %v", tt.skipped, skipped)
+			}
+			if migration.SyntaxChanged != (len(tt.secrets) > 0) {
+				t.Errorf("expected SyntaxChanged to be %v", len(tt.secrets) > 0)
+			}
+		})
+	}
+}
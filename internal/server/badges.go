@@ -0,0 +1,90 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/duaraghav8/dockershrink/internal/badge"
+)
+
+// badgesPath is where badges are served. Unlike the rest of the API, they don't require the token,
+// so that shields.io and the viewers of a README can fetch them.
+const badgesPath = "/v1/badges/"
+
+// maxBadgeNameLength limits the length of the names clients give their badges
+const maxBadgeNameLength = 200
+
+// badges holds the status of the latest analysis of every project that named a badge.
+// They're kept in memory, so projects are analyzed again after the server restarts.
+type badges struct {
+	mu       sync.RWMutex
+	statuses map[string]*badge.Status
+}
+
+func (b *badges) set(name string, s *badge.Status) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.statuses[name] = s
+}
+
+func (b *badges) get(name string) (*badge.Status, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	s, ok := b.statuses[name]
+	return s, ok
+}
+
+// validateBadgeName checks that a badge name is a path of letters, digits, '-', '_' and '.', eg- "acme/api"
+func validateBadgeName(name string) error {
+	if len(name) > maxBadgeNameLength {
+		return fmt.Errorf("the badge name is longer than %d characters", maxBadgeNameLength)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid badge name %q", name)
+		}
+		for _, r := range part {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.", r)) {
+				return fmt.Errorf("invalid badge name %q, it can only contain letters, digits, '-', '_', '.' and '/'", name)
+			}
+		}
+	}
+	return nil
+}
+
+// isBadgeRequest returns true if the request fetches a badge, which is public
+func isBadgeRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, badgesPath)
+}
+
+// handleBadge serves the badge of the latest analysis that named it, as the JSON of a shields.io endpoint or as an SVG
+func (s *Server) handleBadge(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	metric, format := badge.MetricScore, badge.FormatShields
+	var err error
+	if m := q.Get("metric"); m != "" {
+		metric, err = badge.ParseMetric(m)
+	}
+	if f := q.Get("format"); f != "" && err == nil {
+		format, err = badge.ParseFormat(f)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	name := r.PathValue("name")
+	status, ok := s.badges.get(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("no project was analyzed with badge "+name))
+		return
+	}
+	content, contentType := badge.New(metric, status).Render(format)
+	w.Header().Set("Content-Type", contentType)
+	// the badge changes with every analysis, caches like GitHub's image proxy must check for a new one
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(content)
+}
@@ -0,0 +1,348 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/telemetry"
+	apiv1 "github.com/duaraghav8/dockershrink/pkg/api/v1"
+	"github.com/duaraghav8/dockershrink/pkg/dockershrink"
+	"github.com/duaraghav8/dockershrink/pkg/events"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcMessageOverhead is how much larger than the project a request can be, for its options and the encoding of its files
+const grpcMessageOverhead = 1 << 20
+
+// NewGRPC returns the gRPC server of the API defined in proto/dockershrink/v1/dockershrink.proto.
+// It's served over TLS if tlsConfig isn't nil, see TLSConfig.
+func NewGRPC(opts *Options, tlsConfig *tls.Config) *grpc.Server {
+	if opts.MaxUploadSize <= 0 {
+		opts.MaxUploadSize = DefaultMaxUploadSize
+	}
+	svc := &grpcService{opts: opts}
+	serverOpts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(int(opts.MaxUploadSize) + grpcMessageOverhead),
+		grpc.StreamInterceptor(svc.intercept),
+	}
+	if tlsConfig != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	srv := grpc.NewServer(serverOpts...)
+	apiv1.RegisterDockerShrinkServer(srv, svc)
+	return srv
+}
+
+// grpcService implements the RPCs of the gRPC API
+type grpcService struct {
+	apiv1.UnimplementedDockerShrinkServer
+	opts *Options
+}
+
+// intercept authenticates and logs every RPC
+func (g *grpcService) intercept(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	var err error
+	if md, _ := metadata.FromIncomingContext(ss.Context()); g.opts.Token != "" && !g.opts.validAuthorization(strings.Join(md.Get("authorization"), "")) {
+		err = status.Error(codes.Unauthenticated, "missing or invalid bearer token")
+	} else {
+		err = handler(srv, ss)
+	}
+	if g.opts.Logger != nil {
+		g.opts.Logger.Infof("%s %s %s", info.FullMethod, status.Code(err), time.Since(start).Round(time.Millisecond))
+	}
+	return err
+}
+
+func (g *grpcService) Analyze(req *apiv1.AnalyzeRequest, stream apiv1.DockerShrink_AnalyzeServer) error {
+	dir, project, err := g.writeProject(req.GetProject())
+	if err != nil {
+		return err
+	}
+	defer dir.Remove()
+
+	progress := func(p *apiv1.Progress) *apiv1.AnalyzeResponse {
+		return &apiv1.AnalyzeResponse{Event: &apiv1.AnalyzeResponse_Progress{Progress: p}}
+	}
+	return serveStream(g.trace("Analyze"), stream.Send, progress, func(h events.Handler) (*apiv1.AnalyzeResponse, error) {
+		analysis, err := dockershrink.Analyze(stream.Context(), dockershrink.AnalyzeInput{
+			Project:   project,
+			Goal:      req.GetGoal(),
+			Platforms: req.GetPlatforms(),
+			Offline:   g.opts.Offline,
+			Events:    h,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result := &apiv1.AnalyzeResult{
+			Score:              int32(analysis.Score),
+			ScoreBreakdown:     scoreBreakdownProto(analysis.ScoreBreakdown),
+			Findings:           findingsProto(analysis.Findings),
+			EstimatedImageSize: analysis.EstimatedImageSize,
+		}
+		for _, s := range analysis.InstructionSizes {
+			result.InstructionSizes = append(result.InstructionSizes, &apiv1.InstructionSize{
+				Filepath:      s.Filepath,
+				Line:          int32(s.Line),
+				Instruction:   s.Instruction,
+				EstimatedSize: s.EstimatedSize,
+				Basis:         s.Basis,
+			})
+		}
+		return &apiv1.AnalyzeResponse{Event: &apiv1.AnalyzeResponse_Result{Result: result}}, nil
+	})
+}
+
+func (g *grpcService) Lint(req *apiv1.LintRequest, stream apiv1.DockerShrink_LintServer) error {
+	dir, project, err := g.writeProject(req.GetProject())
+	if err != nil {
+		return err
+	}
+	defer dir.Remove()
+
+	severities := make(map[string]dockershrink.Severity, len(req.GetSeverities()))
+	for rule, severity := range req.GetSeverities() {
+		severities[rule] = dockershrink.Severity(severity)
+	}
+	progress := func(p *apiv1.Progress) *apiv1.LintResponse {
+		return &apiv1.LintResponse{Event: &apiv1.LintResponse_Progress{Progress: p}}
+	}
+	return serveStream(g.trace("Lint"), stream.Send, progress, func(h events.Handler) (*apiv1.LintResponse, error) {
+		analysis, err := dockershrink.Analyze(stream.Context(), dockershrink.AnalyzeInput{
+			Project:    project,
+			Goal:       req.GetGoal(),
+			Platforms:  req.GetPlatforms(),
+			Severities: severities,
+			Offline:    g.opts.Offline,
+			Events:     h,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result := &apiv1.LintResult{Findings: findingsProto(analysis.Findings)}
+		for _, f := range analysis.Findings {
+			if f.Severity != models.SeverityInfo {
+				result.Failed = true
+			}
+		}
+		return &apiv1.LintResponse{Event: &apiv1.LintResponse_Result{Result: result}}, nil
+	})
+}
+
+func (g *grpcService) Optimize(req *apiv1.OptimizeRequest, stream apiv1.DockerShrink_OptimizeServer) error {
+	dir, project, err := g.writeProject(req.GetProject())
+	if err != nil {
+		return err
+	}
+	defer dir.Remove()
+
+	progress := func(p *apiv1.Progress) *apiv1.OptimizeResponse {
+		return &apiv1.OptimizeResponse{Event: &apiv1.OptimizeResponse_Progress{Progress: p}}
+	}
+	return serveStream(g.trace("Optimize"), stream.Send, progress, func(h events.Handler) (*apiv1.OptimizeResponse, error) {
+		optimized, err := dockershrink.Optimize(stream.Context(), dockershrink.OptimizeInput{
+			Project:   project,
+			Goal:      req.GetGoal(),
+			Platforms: req.GetPlatforms(),
+			MaxRisk:   req.GetApplyRisk(),
+			Hardening: req.GetIncludeSecurityRecommendations(),
+			Explain:   req.GetExplain(),
+			LLM:       g.opts.LLM,
+			Offline:   g.opts.Offline,
+			Events:    h,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result := &apiv1.OptimizeResult{
+			Dockerfile:                  optimized.Dockerfile,
+			Dockerignore:                optimized.Dockerignore,
+			Diff:                        optimized.Diff,
+			ActionsTaken:                actionsProto(optimized.ActionsTaken),
+			Recommendations:             actionsProto(optimized.Recommendations),
+			EstimatedImageSize:          optimized.EstimatedImageSize,
+			EstimatedOptimizedImageSize: optimized.EstimatedOptimizedImageSize,
+		}
+		for _, s := range optimized.BuildSecrets {
+			result.BuildSecrets = append(result.BuildSecrets, &apiv1.BuildSecret{Id: s.ID, Env: s.Env})
+		}
+		return &apiv1.OptimizeResponse{Event: &apiv1.OptimizeResponse_Result{Result: result}}, nil
+	})
+}
+
+// trace starts the trace of an RPC, nil if telemetry is disabled
+func (g *grpcService) trace(method string) *telemetry.Trace {
+	return g.opts.Telemetry.Trace(apiv1.DockerShrink_ServiceDesc.ServiceName+"/"+method, telemetry.SpanKindServer, map[string]any{
+		"rpc.system":  "grpc",
+		"rpc.service": apiv1.DockerShrink_ServiceDesc.ServiceName,
+		"rpc.method":  method,
+	})
+}
+
+// serveStream runs the operation of an RPC, sends its progress as it runs and then its result.
+// progress wraps a progress event in a message of the stream. The operation is traced if trace isn't nil.
+func serveStream[M any](trace *telemetry.Trace, send func(M) error, progress func(*apiv1.Progress) M, run func(events.Handler) (M, error)) error {
+	// the LLM's tool calls can emit events concurrently, while messages must be sent one at a time
+	var mu sync.Mutex
+	var sendErr error
+	result, err := run(events.Multi(func(e events.Event) {
+		p := progressOf(e)
+		if p == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if sendErr == nil {
+			sendErr = send(progress(progressProto(p)))
+		}
+	}, trace.Handler()))
+	trace.End(err)
+	if err != nil {
+		return grpcStatus(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if sendErr != nil {
+		return sendErr
+	}
+	return send(result)
+}
+
+// grpcStatus returns the status an RPC fails with for an error of the operation it ran
+func grpcStatus(err error) error {
+	switch {
+	case errors.Is(err, dockershrink.ErrInvalidInput):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, dockershrink.ErrInvalidProject):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// writeProject writes the uploaded project to a temporary directory, which must be removed once the RPC is done.
+// The error is a status the RPC can fail with.
+func (g *grpcService) writeProject(p *apiv1.Project) (*projectDir, dockershrink.Project, error) {
+	dir, err := newProjectDir(g.opts.MaxUploadSize)
+	if err != nil {
+		return nil, dockershrink.Project{}, status.Error(codes.Internal, err.Error())
+	}
+	if tarball := p.GetTarball(); len(tarball) > 0 {
+		gzipped := bytes.HasPrefix(tarball, []byte{0x1f, 0x8b})
+		err = dir.extractTar(bytes.NewReader(tarball), gzipped)
+	} else {
+		err = dir.writeFiles(p.GetFiles())
+	}
+	switch {
+	case errors.Is(err, errTooLarge):
+		err = status.Errorf(codes.ResourceExhausted, "the project is larger than the %d bytes allowed", g.opts.MaxUploadSize)
+	case err != nil:
+		err = status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		dir.Remove()
+		return nil, dockershrink.Project{}, err
+	}
+
+	project := dockershrink.Project{Dir: dir.root, Dockerfile: p.GetDockerfile(), Dockerignore: p.GetDockerignore()}
+	if project.Dockerfile == "" {
+		project.Dockerfile = "Dockerfile"
+	}
+	if project.Dockerignore == "" {
+		project.Dockerignore = ".dockerignore"
+	}
+	for _, name := range []string{project.Dockerfile, project.Dockerignore} {
+		if _, err := dir.path(name); err != nil {
+			dir.Remove()
+			return nil, dockershrink.Project{}, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	return dir, project, nil
+}
+
+func progressProto(p *Progress) *apiv1.Progress {
+	return &apiv1.Progress{
+		Type:             p.Type,
+		Operation:        p.Operation,
+		Goal:             p.Goal,
+		Dockerfile:       p.Dockerfile,
+		Tool:             p.Tool,
+		Arguments:        p.Arguments,
+		Rule:             p.Rule,
+		Title:            p.Title,
+		Filepath:         p.Filepath,
+		Line:             int32(p.Line),
+		Changed:          p.Changed,
+		Model:            p.Model,
+		PromptTokens:     p.PromptTokens,
+		CompletionTokens: p.CompletionTokens,
+		Error:            p.Error,
+	}
+}
+
+func scoreBreakdownProto(b *models.ScoreBreakdown) *apiv1.ScoreBreakdown {
+	if b == nil {
+		return nil
+	}
+	aspect := func(a models.AspectScore) *apiv1.AspectScore {
+		return &apiv1.AspectScore{Score: int32(a.Score), Grade: a.Grade, Findings: int32(a.Findings)}
+	}
+	return &apiv1.ScoreBreakdown{Grade: b.Grade, Size: aspect(b.Size), Cache: aspect(b.Cache), Security: aspect(b.Security)}
+}
+
+func findingsProto(findings []*models.Finding) []*apiv1.Finding {
+	out := make([]*apiv1.Finding, 0, len(findings))
+	for _, f := range findings {
+		out = append(out, &apiv1.Finding{
+			Rule:                f.Rule,
+			Code:                f.Code,
+			Severity:            string(f.Severity),
+			Filepath:            f.Filepath,
+			Line:                int32(f.Line),
+			Title:               f.Title,
+			Description:         f.Description,
+			EstimatedSizeImpact: f.EstimatedSizeImpact,
+		})
+	}
+	return out
+}
+
+func actionsProto(actions []*models.OptimizationAction) []*apiv1.Action {
+	out := make([]*apiv1.Action, 0, len(actions))
+	for _, a := range actions {
+		action := &apiv1.Action{
+			Rule:        a.Rule,
+			Filepath:    a.Filepath,
+			Title:       a.Title,
+			Description: a.Description,
+			Line:        int32(a.Line),
+			Risk:        string(a.Risk),
+		}
+		if e := a.Explanation; e != nil {
+			action.Explanation = &apiv1.Explanation{
+				Principle:           e.Principle,
+				Rationale:           e.Rationale,
+				Docs:                e.Docs,
+				Links:               e.Links,
+				EstimatedSizeImpact: e.EstimatedSizeImpact,
+				BuildTimeImpact:     e.BuildTimeImpact,
+			}
+		}
+		out = append(out, action)
+	}
+	return out
+}
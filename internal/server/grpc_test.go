@@ -0,0 +1,272 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	apiv1 "github.com/duaraghav8/dockershrink/pkg/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// grpcClient serves the gRPC API in memory and returns a client of it
+func grpcClient(t *testing.T, opts *Options) apiv1.DockerShrinkClient {
+	t.Helper()
+	l := bufconn.Listen(1 << 20)
+	srv := NewGRPC(opts, nil)
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return apiv1.NewDockerShrinkClient(conn)
+}
+
+// receive reads a stream to its end and returns the types of its progress events and its last message
+func receive[M any, P interface {
+	*M
+	GetProgress() *apiv1.Progress
+}](t *testing.T, stream grpc.ServerStreamingClient[M]) ([]string, P, error) {
+	t.Helper()
+	var types []string
+	var last P
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return types, last, nil
+		}
+		if err != nil {
+			return types, last, err
+		}
+		if p := P(msg).GetProgress(); p != nil {
+			types = append(types, p.GetType())
+		}
+		last = P(msg)
+	}
+}
+
+func TestGRPC_Analyze(t *testing.T) {
+	client := grpcClient(t, &Options{Offline: true})
+	files := map[string]string{"Dockerfile": testDockerfile, "package.json": `{"name": "app"}`}
+	tarballBytes, _ := io.ReadAll(tarball(t, files))
+
+	tests := []struct {
+		name    string
+		project *apiv1.Project
+	}{
+		{name: "file map", project: &apiv1.Project{Files: files}},
+		{name: "tarball", project: &apiv1.Project{Tarball: tarballBytes}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := client.Analyze(context.Background(), &apiv1.AnalyzeRequest{Project: tt.project, Goal: "size"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			types, last, err := receive(t, stream)
+			if err != nil {
+				t.Fatalf("Analyze() failed: %v", err)
+			}
+			if len(types) < 2 || types[0] != "analysis_started" || types[len(types)-1] != "analysis_finished" {
+				t.Errorf("expected the analysis to start and finish, got %v", types)
+			}
+			result := last.GetResult()
+			if result == nil || result.GetScore() >= 100 || result.GetScoreBreakdown().GetGrade() == "" {
+				t.Fatalf("expected the last message to have a graded score below 100, got %v", last)
+			}
+			for _, f := range result.GetFindings() {
+				if f.GetCode() == "DS001" {
+					return
+				}
+			}
+			t.Errorf("expected the missing .dockerignore to be reported, got %v", result.GetFindings())
+		})
+	}
+}
+
+func TestGRPC_Lint(t *testing.T) {
+	client := grpcClient(t, &Options{Offline: true})
+	project := &apiv1.Project{Files: map[string]string{"Dockerfile": testDockerfile, "package.json": `{"name": "app"}`}}
+
+	stream, err := client.Lint(context.Background(), &apiv1.LintRequest{Project: project})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, last, err := receive(t, stream)
+	if err != nil {
+		t.Fatalf("Lint() failed: %v", err)
+	}
+	if !last.GetResult().GetFailed() {
+		t.Errorf("expected the lint to fail for a Dockerfile without .dockerignore, got %v", last.GetResult())
+	}
+
+	stream, err = client.Lint(context.Background(), &apiv1.LintRequest{Project: project, Severities: map[string]string{"DS001": "off"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, last, err = receive(t, stream); err != nil {
+		t.Fatalf("Lint() failed: %v", err)
+	}
+	for _, f := range last.GetResult().GetFindings() {
+		if f.GetCode() == "DS001" {
+			t.Errorf("expected DS001 to be turned off, got %v", f)
+		}
+	}
+}
+
+func TestGRPC_Optimize(t *testing.T) {
+	client := grpcClient(t, &Options{Offline: true})
+	stream, err := client.Optimize(context.Background(), &apiv1.OptimizeRequest{
+		Project: &apiv1.Project{Files: map[string]string{"Dockerfile": testDockerfile, "package.json": `{"name": "app"}`}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	types, last, err := receive(t, stream)
+	if err != nil {
+		t.Fatalf("Optimize() failed: %v", err)
+	}
+	if !strings.Contains(strings.Join(types, " "), "rule_applied") {
+		t.Errorf("expected the rules applied to be streamed, got %v", types)
+	}
+	result := last.GetResult()
+	if !strings.Contains(result.GetDockerignore(), "node_modules") || !strings.Contains(result.GetDiff(), "+++ b/.dockerignore") {
+		t.Errorf("expected a .dockerignore excluding node_modules to be created, got %v", result)
+	}
+}
+
+func TestGRPC_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    *Options
+		token   string
+		project *apiv1.Project
+		want    codes.Code
+	}{
+		{
+			name:    "path outside of the project",
+			project: &apiv1.Project{Files: map[string]string{"../Dockerfile": "FROM node:20"}},
+			want:    codes.InvalidArgument,
+		},
+		{
+			name:    "invalid Dockerfile",
+			project: &apiv1.Project{Files: map[string]string{"Dockerfile": "FROM"}},
+			want:    codes.FailedPrecondition,
+		},
+		{
+			name:    "too large",
+			opts:    &Options{Offline: true, MaxUploadSize: 64},
+			project: &apiv1.Project{Files: map[string]string{"Dockerfile": strings.Repeat("#", 100)}},
+			want:    codes.ResourceExhausted,
+		},
+		{
+			name:    "missing token",
+			opts:    &Options{Offline: true, Token: "secret"},
+			project: &apiv1.Project{Files: map[string]string{"Dockerfile": "FROM node:20"}},
+			want:    codes.Unauthenticated,
+		},
+		{
+			name:    "wrong token",
+			opts:    &Options{Offline: true, Token: "secret"},
+			token:   "secre",
+			project: &apiv1.Project{Files: map[string]string{"Dockerfile": "FROM node:20"}},
+			want:    codes.Unauthenticated,
+		},
+		{
+			name:    "valid token",
+			opts:    &Options{Offline: true, Token: "secret"},
+			token:   "secret",
+			project: &apiv1.Project{Files: map[string]string{"Dockerfile": "FROM node:20"}},
+			want:    codes.OK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			if opts == nil {
+				opts = &Options{Offline: true}
+			}
+			ctx := context.Background()
+			if tt.token != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+tt.token)
+			}
+			stream, err := grpcClient(t, opts).Analyze(ctx, &apiv1.AnalyzeRequest{Project: tt.project})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _, err = receive(t, stream)
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("expected status %s, got %s: %v", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestGRPC_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	serverCert := newCertificate(t, dir, "server")
+	clientCert := newCertificate(t, dir, "client")
+	strangerCert := newCertificate(t, dir, "stranger")
+
+	cfg, err := TLSConfig(serverCert.certFile, serverCert.keyFile, clientCert.certFile)
+	if err != nil {
+		t.Fatalf("TLSConfig() failed: %v", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewGRPC(&Options{Offline: true}, cfg)
+	go srv.Serve(l)
+	defer srv.Stop()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCert.x509)
+	analyze := func(cert *certificate) error {
+		tlsConfig := &tls.Config{RootCAs: roots}
+		if cert != nil {
+			tlsConfig.Certificates = []tls.Certificate{cert.tls}
+		}
+		conn, err := grpc.NewClient(l.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		stream, err := apiv1.NewDockerShrinkClient(conn).Analyze(context.Background(), &apiv1.AnalyzeRequest{
+			Project: &apiv1.Project{Files: map[string]string{"Dockerfile": "FROM node:20"}},
+		})
+		if err != nil {
+			return err
+		}
+		_, _, err = receive(t, stream)
+		return err
+	}
+
+	if err := analyze(clientCert); err != nil {
+		t.Errorf("expected a client with a certificate signed by the client CA to be served, got %v", err)
+	}
+	if err := analyze(strangerCert); err == nil {
+		t.Error("expected a client with a certificate signed by another CA to be rejected")
+	}
+	if err := analyze(nil); err == nil {
+		t.Error("expected a client without a certificate to be rejected")
+	}
+}
@@ -0,0 +1,470 @@
+// Package server exposes dockershrink as an HTTP service, so that internal platforms can analyze and optimize
+// the Docker image definitions of projects without shelling out to the CLI.
+//
+// Every request uploads the project, either as a tarball or as a JSON map of file names to their content.
+// The project is written to a temporary directory for the duration of the request, so requests are independent
+// and run concurrently. Clients that accept application/x-ndjson get the progress of the request streamed as it runs,
+// followed by its result. NewGRPC serves the same operations, along with linting, as the gRPC API of the apiv1 package.
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/badge"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/telemetry"
+	"github.com/duaraghav8/dockershrink/pkg/dockershrink"
+	"github.com/duaraghav8/dockershrink/pkg/events"
+)
+
+// DefaultMaxUploadSize is the default max size of a project, in bytes
+const DefaultMaxUploadSize = 32 << 20
+
+const (
+	contentTypeJSON   = "application/json"
+	contentTypeNDJSON = "application/x-ndjson"
+	contentTypeTar    = "application/x-tar"
+	contentTypeGzip   = "application/gzip"
+)
+
+// Options configures the server
+type Options struct {
+	// LLM optimizes Dockerfiles, only the rules are applied if it's nil
+	LLM *dockershrink.LLM
+	// Offline only uses cached or built-in data about base images, instead of fetching it
+	Offline bool
+	// Token must be sent by clients as a bearer token. Requests aren't authenticated if it's empty.
+	Token string
+	// MaxUploadSize is the max size of a project, in bytes. Defaults to DefaultMaxUploadSize.
+	MaxUploadSize int64
+	// Logger logs every request, nothing is logged if it's nil
+	Logger *log.Logger
+	// Telemetry traces every analysis and optimization, nothing is traced if it's nil
+	Telemetry *telemetry.Exporter
+}
+
+// Request is the JSON body of a request. When the project is uploaded as a tarball,
+// the other fields are read from the query parameters of the same name instead.
+type Request struct {
+	// Files maps the slash-separated paths of the project's files, relative to it, to their content
+	Files map[string]string `json:"files"`
+	// Dockerfile and Dockerignore are the paths of the files in the project, "Dockerfile" and ".dockerignore" by default
+	Dockerfile   string `json:"dockerfile,omitempty"`
+	Dockerignore string `json:"dockerignore,omitempty"`
+	// Goal is size, build-speed, security or all, the default
+	Goal string `json:"goal,omitempty"`
+	// Platforms is a comma-separated list of the platforms the image is built for, eg- linux/amd64,linux/arm64
+	Platforms string `json:"platforms,omitempty"`
+	// ApplyRisk only applies the changes of an optimization up to this risk level, all of them by default
+	ApplyRisk string `json:"apply_risk,omitempty"`
+	// Hardening also hardens the container when optimizing it, like --include-security-recommendations
+	Hardening bool `json:"include_security_recommendations,omitempty"`
+	// Explain also estimates the size impact of every action of an optimization, like --explain
+	Explain bool `json:"explain,omitempty"`
+	// Badge names the project, eg- "acme/api". The badge of its latest analysis is then served at /v1/badges/<badge>.
+	Badge string `json:"badge,omitempty"`
+}
+
+// AnalyzeResponse is the response of /v1/analyze
+type AnalyzeResponse struct {
+	Score              int                       `json:"score"`
+	ScoreBreakdown     *models.ScoreBreakdown    `json:"score_breakdown"`
+	Findings           []*models.Finding         `json:"findings"`
+	InstructionSizes   []*models.InstructionSize `json:"instruction_sizes"`
+	Variants           []*models.Variant         `json:"variants"`
+	EstimatedImageSize int64                     `json:"estimated_image_size,omitempty"`
+}
+
+// OptimizeResponse is the response of /v1/optimize. The files are returned whole, along with a patch of the changes.
+type OptimizeResponse struct {
+	Dockerfile                  string                       `json:"dockerfile"`
+	Dockerignore                string                       `json:"dockerignore"`
+	Diff                        string                       `json:"diff"`
+	ActionsTaken                []*models.OptimizationAction `json:"actions_taken"`
+	Recommendations             []*models.OptimizationAction `json:"recommendations"`
+	BuildSecrets                []*BuildSecret               `json:"build_secrets"`
+	EstimatedImageSize          int64                        `json:"estimated_image_size,omitempty"`
+	EstimatedOptimizedImageSize int64                        `json:"estimated_optimized_image_size,omitempty"`
+}
+
+// BuildSecret is a secret the optimized Dockerfile mounts, which must be passed to docker build with --secret
+type BuildSecret struct {
+	ID  string `json:"id"`
+	Env string `json:"env"`
+}
+
+// ErrorResponse is the response of a failed request
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// StreamMessage is a line of a streamed response. Every line but the last has the progress of the request,
+// the last one has its result, or the error it failed with.
+type StreamMessage struct {
+	Progress *Progress `json:"progress,omitempty"`
+	Result   any       `json:"result,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Progress is a progress event of a request, see the events package for what each type means
+type Progress struct {
+	Type       string `json:"type"`
+	Operation  string `json:"operation,omitempty"`
+	Goal       string `json:"goal,omitempty"`
+	Dockerfile string `json:"dockerfile,omitempty"`
+	// Tool and Arguments are set for the tool calls of the LLM
+	Tool      string `json:"tool,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	// Rule, Title, Filepath, Line and Changed are set when a rule is applied
+	Rule     string `json:"rule,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Filepath string `json:"filepath,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Changed  bool   `json:"changed,omitempty"`
+	// Model and the token counts are set for every response of the LLM
+	Model            string `json:"model,omitempty"`
+	PromptTokens     int64  `json:"prompt_tokens,omitempty"`
+	CompletionTokens int64  `json:"completion_tokens,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// Server handles the requests of the HTTP API
+type Server struct {
+	opts   *Options
+	mux    *http.ServeMux
+	badges *badges
+}
+
+// New returns the handler of the HTTP API
+func New(opts *Options) *Server {
+	if opts.MaxUploadSize <= 0 {
+		opts.MaxUploadSize = DefaultMaxUploadSize
+	}
+	s := &Server{opts: opts, mux: http.NewServeMux(), badges: &badges{statuses: map[string]*badge.Status{}}}
+	s.mux.HandleFunc("POST /v1/analyze", s.handleAnalyze)
+	s.mux.HandleFunc("POST /v1/optimize", s.handleOptimize)
+	s.mux.HandleFunc("GET "+badgesPath+"{name...}", s.handleBadge)
+	s.mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	if s.opts.Token != "" && r.URL.Path != "/healthz" && !isBadgeRequest(r) && !s.authorized(r) {
+		writeError(rec, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+	} else {
+		s.mux.ServeHTTP(rec, r)
+	}
+	if s.opts.Logger != nil {
+		s.opts.Logger.Infof("%s %s %d %s", r.Method, r.URL.Path, rec.status, time.Since(start).Round(time.Millisecond))
+	}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	return s.opts.validAuthorization(r.Header.Get("Authorization"))
+}
+
+// validAuthorization tells whether the value of an Authorization header has the bearer token of the server.
+// The token is compared in constant time, so that the time it takes doesn't reveal how much of it was guessed.
+func (o *Options) validAuthorization(header string) bool {
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(o.Token)) == 1
+}
+
+// statusRecorder records the status of a response to log it
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush sends what's been written so far to the client, so that the progress of streamed responses isn't buffered
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying response writer, for http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (s *Server) handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	req, dir, ok := s.readProject(w, r)
+	if !ok {
+		return
+	}
+	defer dir.Remove()
+
+	respond(w, r, s.trace(r), func(progress events.Handler) (any, error) {
+		analysis, err := dockershrink.Analyze(r.Context(), dockershrink.AnalyzeInput{
+			Project:   dir.project(req),
+			Goal:      req.Goal,
+			Platforms: platforms(req),
+			Offline:   s.opts.Offline,
+			Events:    progress,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if req.Badge != "" {
+			s.badges.set(req.Badge, &badge.Status{Score: analysis.Score, Grade: analysis.ScoreBreakdown.Grade, EstimatedImageSize: analysis.EstimatedImageSize})
+		}
+		return &AnalyzeResponse{
+			Score:              analysis.Score,
+			ScoreBreakdown:     analysis.ScoreBreakdown,
+			Findings:           analysis.Findings,
+			InstructionSizes:   analysis.InstructionSizes,
+			Variants:           analysis.Variants,
+			EstimatedImageSize: analysis.EstimatedImageSize,
+		}, nil
+	})
+}
+
+func (s *Server) handleOptimize(w http.ResponseWriter, r *http.Request) {
+	req, dir, ok := s.readProject(w, r)
+	if !ok {
+		return
+	}
+	defer dir.Remove()
+
+	respond(w, r, s.trace(r), func(progress events.Handler) (any, error) {
+		optimized, err := dockershrink.Optimize(r.Context(), dockershrink.OptimizeInput{
+			Project:   dir.project(req),
+			Goal:      req.Goal,
+			Platforms: platforms(req),
+			MaxRisk:   req.ApplyRisk,
+			Hardening: req.Hardening,
+			Explain:   req.Explain,
+			LLM:       s.opts.LLM,
+			Offline:   s.opts.Offline,
+			Events:    progress,
+		})
+		if err != nil {
+			return nil, err
+		}
+		resp := &OptimizeResponse{
+			Dockerfile:                  optimized.Dockerfile,
+			Dockerignore:                optimized.Dockerignore,
+			Diff:                        optimized.Diff,
+			ActionsTaken:                optimized.ActionsTaken,
+			Recommendations:             optimized.Recommendations,
+			BuildSecrets:                []*BuildSecret{},
+			EstimatedImageSize:          optimized.EstimatedImageSize,
+			EstimatedOptimizedImageSize: optimized.EstimatedOptimizedImageSize,
+		}
+		for _, s := range optimized.BuildSecrets {
+			resp.BuildSecrets = append(resp.BuildSecrets, &BuildSecret{ID: s.ID, Env: s.Env})
+		}
+		return resp, nil
+	})
+}
+
+func (d *projectDir) project(req *Request) dockershrink.Project {
+	return dockershrink.Project{Dir: d.root, Dockerfile: req.Dockerfile, Dockerignore: req.Dockerignore}
+}
+
+func platforms(req *Request) []string {
+	if req.Platforms == "" {
+		return nil
+	}
+	return strings.Split(req.Platforms, ",")
+}
+
+// trace starts the trace of a request, nil if telemetry is disabled
+func (s *Server) trace(r *http.Request) *telemetry.Trace {
+	return s.opts.Telemetry.Trace(r.Method+" "+r.URL.Path, telemetry.SpanKindServer, map[string]any{
+		"http.request.method": r.Method,
+		"url.path":            r.URL.Path,
+	})
+}
+
+// respond runs the operation of a request and writes its result. If the client accepts application/x-ndjson,
+// the progress of the operation is streamed as it runs, otherwise only the result is written once it's done.
+// The operation is traced if trace isn't nil.
+func respond(w http.ResponseWriter, r *http.Request, trace *telemetry.Trace, run func(progress events.Handler) (any, error)) {
+	if !acceptsNDJSON(r) {
+		result, err := run(trace.Handler())
+		trace.End(err)
+		switch {
+		case errors.Is(err, dockershrink.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, err)
+			return
+		case errors.Is(err, dockershrink.ErrInvalidProject):
+			writeError(w, http.StatusUnprocessableEntity, err)
+			return
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeNDJSON)
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	send := func(m *StreamMessage) {
+		_ = enc.Encode(m)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	result, err := run(events.Multi(func(e events.Event) {
+		if p := progressOf(e); p != nil {
+			send(&StreamMessage{Progress: p})
+		}
+	}, trace.Handler()))
+	trace.End(err)
+	if err != nil {
+		send(&StreamMessage{Error: err.Error()})
+		return
+	}
+	send(&StreamMessage{Result: result})
+}
+
+func acceptsNDJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, _ := mime.ParseMediaType(strings.TrimSpace(accept)); mediaType == contentTypeNDJSON {
+			return true
+		}
+	}
+	return false
+}
+
+// progressOf returns the progress event sent to clients for an event, nil for the events they aren't sent
+func progressOf(e events.Event) *Progress {
+	errString := func(err error) string {
+		if err == nil {
+			return ""
+		}
+		return err.Error()
+	}
+	switch e := e.(type) {
+	case events.AnalysisStarted:
+		return &Progress{Type: "analysis_started", Operation: string(e.Operation), Goal: e.Goal, Dockerfile: e.Dockerfile}
+	case events.AnalysisFinished:
+		return &Progress{Type: "analysis_finished", Operation: string(e.Operation), Error: errString(e.Err)}
+	case events.ToolCallStarted:
+		return &Progress{Type: "tool_call_started", Tool: e.Tool, Arguments: e.Arguments}
+	case events.ToolCallFinished:
+		return &Progress{Type: "tool_call_finished", Tool: e.Tool, Error: errString(e.Err)}
+	case events.RuleApplied:
+		return &Progress{Type: "rule_applied", Rule: e.Rule, Title: e.Title, Filepath: e.Filepath, Line: e.Line, Changed: e.Changed}
+	case events.LLMTokensReceived:
+		return &Progress{Type: "llm_tokens_received", Model: e.Model, PromptTokens: e.PromptTokens, CompletionTokens: e.CompletionTokens}
+	}
+	return nil
+}
+
+// readProject reads the request and writes the uploaded project to a temporary directory.
+// If it fails, the error is written to the response and false is returned.
+func (s *Server) readProject(w http.ResponseWriter, r *http.Request) (*Request, *projectDir, bool) {
+	body := http.MaxBytesReader(w, r.Body, s.opts.MaxUploadSize)
+	dir, err := newProjectDir(s.opts.MaxUploadSize)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, nil, false
+	}
+
+	req := &Request{}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case contentTypeJSON, "":
+		if err = json.NewDecoder(body).Decode(req); err != nil {
+			err = fmt.Errorf("invalid request body: %w", err)
+		} else {
+			err = dir.writeFiles(req.Files)
+		}
+	case contentTypeTar, contentTypeGzip, "application/x-gzip", "application/x-gtar":
+		req = requestFromQuery(r)
+		err = dir.extractTar(body, mediaType != contentTypeTar)
+	default:
+		dir.Remove()
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content type %q, upload the project as %s or as a tarball", mediaType, contentTypeJSON))
+		return nil, nil, false
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, errTooLarge), errors.As(err, &maxBytesErr):
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("the project is larger than the %d bytes allowed", s.opts.MaxUploadSize))
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err)
+	}
+	if err != nil {
+		dir.Remove()
+		return nil, nil, false
+	}
+
+	if req.Dockerfile == "" {
+		req.Dockerfile = "Dockerfile"
+	}
+	if req.Dockerignore == "" {
+		req.Dockerignore = ".dockerignore"
+	}
+	for _, p := range []string{req.Dockerfile, req.Dockerignore} {
+		if _, err := dir.path(p); err != nil {
+			dir.Remove()
+			writeError(w, http.StatusBadRequest, err)
+			return nil, nil, false
+		}
+	}
+	if req.Badge != "" {
+		if err := validateBadgeName(req.Badge); err != nil {
+			dir.Remove()
+			writeError(w, http.StatusBadRequest, err)
+			return nil, nil, false
+		}
+	}
+	return req, dir, true
+}
+
+func requestFromQuery(r *http.Request) *Request {
+	q := r.URL.Query()
+	hardening, _ := strconv.ParseBool(q.Get("include_security_recommendations"))
+	explain, _ := strconv.ParseBool(q.Get("explain"))
+	return &Request{
+		Dockerfile:   q.Get("dockerfile"),
+		Dockerignore: q.Get("dockerignore"),
+		Goal:         q.Get("goal"),
+		Platforms:    q.Get("platforms"),
+		ApplyRisk:    q.Get("apply_risk"),
+		Hardening:    hardening,
+		Explain:      explain,
+		Badge:        q.Get("badge"),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, &ErrorResponse{Error: err.Error()})
+}
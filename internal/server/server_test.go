@@ -0,0 +1,378 @@
+package server
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/pkg/events"
+)
+
+const testDockerfile = "FROM node:20\nWORKDIR /app\nCOPY . .\nRUN npm install\nCMD [\"node\", \"index.js\"]\n"
+
+func jsonBody(t *testing.T, req *Request) *bytes.Reader {
+	t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(body)
+}
+
+func tarball(t *testing.T, files map[string]string) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "./", Typeflag: tar.TypeDir, Mode: 0o755})
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	// symlinks could point outside of the project, they're left out
+	tw.WriteHeader(&tar.Header{Name: "passwd", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"})
+	tw.Close()
+	gz.Close()
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestServer_Analyze(t *testing.T) {
+	files := map[string]string{"Dockerfile": testDockerfile, "package.json": `{"name": "app"}`, "index.js": "console.log('hi')"}
+	tests := []struct {
+		name        string
+		contentType string
+		url         string
+		body        func(t *testing.T) *bytes.Reader
+	}{
+		{
+			name:        "file map",
+			contentType: "application/json",
+			url:         "/v1/analyze",
+			body:        func(t *testing.T) *bytes.Reader { return jsonBody(t, &Request{Files: files, Goal: "size"}) },
+		},
+		{
+			name:        "tarball",
+			contentType: "application/gzip",
+			url:         "/v1/analyze?goal=size",
+			body:        func(t *testing.T) *bytes.Reader { return tarball(t, files) },
+		},
+	}
+
+	srv := httptest.NewServer(New(&Options{Offline: true}))
+	defer srv.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(srv.URL+tt.url, tt.contentType, tt.body(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", resp.StatusCode)
+			}
+			analysis := &AnalyzeResponse{}
+			if err := json.NewDecoder(resp.Body).Decode(analysis); err != nil {
+				t.Fatal(err)
+			}
+			if analysis.Score >= 100 || len(analysis.Findings) == 0 {
+				t.Errorf("expected findings for a single stage Dockerfile without .dockerignore, got score %d", analysis.Score)
+			}
+			if analysis.ScoreBreakdown == nil || analysis.ScoreBreakdown.Size.Findings == 0 {
+				t.Errorf("expected the size findings to be graded, got %+v", analysis.ScoreBreakdown)
+			}
+			for _, f := range analysis.Findings {
+				if f.Code == "DS001" {
+					return
+				}
+			}
+			t.Errorf("expected the missing .dockerignore to be reported, got %+v", analysis.Findings)
+		})
+	}
+}
+
+func TestServer_Optimize(t *testing.T) {
+	srv := httptest.NewServer(New(&Options{Offline: true}))
+	defer srv.Close()
+
+	body := jsonBody(t, &Request{Files: map[string]string{"Dockerfile": testDockerfile, "package.json": `{"name": "app"}`}})
+	resp, err := http.Post(srv.URL+"/v1/optimize", "application/json", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	optimized := &OptimizeResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(optimized); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(optimized.Dockerignore, "node_modules") {
+		t.Errorf("expected a .dockerignore excluding node_modules to be created, got %q", optimized.Dockerignore)
+	}
+	if !strings.Contains(optimized.Diff, "+++ b/.dockerignore") {
+		t.Errorf("expected the diff to create .dockerignore, got %q", optimized.Diff)
+	}
+}
+
+func TestServer_Stream(t *testing.T) {
+	srv := httptest.NewServer(New(&Options{Offline: true}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/optimize", jsonBody(t, &Request{Files: map[string]string{"Dockerfile": testDockerfile}}))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/x-ndjson")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("expected a stream, got %s", ct)
+	}
+
+	types := []string{}
+	var last map[string]json.RawMessage
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		msg := &StreamMessage{}
+		if err := json.Unmarshal(scanner.Bytes(), msg); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		if msg.Progress != nil {
+			types = append(types, msg.Progress.Type)
+		}
+		last = nil
+		json.Unmarshal(scanner.Bytes(), &last)
+	}
+
+	if len(types) < 3 || types[0] != "analysis_started" || types[len(types)-1] != "analysis_finished" || !slices.Contains(types, "rule_applied") {
+		t.Errorf("expected the optimization to start, apply rules and finish, got %v", types)
+	}
+	if _, ok := last["result"]; !ok {
+		t.Errorf("expected the last line to have the result, got %v", last)
+	}
+}
+
+func TestServer_StreamFlushesProgress(t *testing.T) {
+	// the result of the operation is only produced once the client has read its first progress event,
+	// which it can't if the progress is buffered until the operation is done
+	read := make(chan struct{})
+	s := New(&Options{Offline: true})
+	s.mux.HandleFunc("POST /test/stream", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, r, nil, func(progress events.Handler) (any, error) {
+			progress.Emit(events.AnalysisStarted{Operation: events.OperationAnalyze})
+			select {
+			case <-read:
+				return map[string]string{"status": "done"}, nil
+			case <-time.After(5 * time.Second):
+				return nil, errors.New("the progress wasn't sent before the result")
+			}
+		})
+	})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/test/stream", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	if !scanner.Scan() {
+		t.Fatalf("expected a progress event, got %v", scanner.Err())
+	}
+	close(read)
+	first := &StreamMessage{}
+	if err := json.Unmarshal(scanner.Bytes(), first); err != nil || first.Progress == nil || first.Progress.Type != "analysis_started" {
+		t.Fatalf("expected the first line to be the start of the analysis, got %q", scanner.Text())
+	}
+	if !scanner.Scan() {
+		t.Fatalf("expected the result, got %v", scanner.Err())
+	}
+	last := &StreamMessage{}
+	if err := json.Unmarshal(scanner.Bytes(), last); err != nil || last.Error != "" || last.Result == nil {
+		t.Errorf("expected the last line to have the result, got %q", scanner.Text())
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        *Options
+		method      string
+		contentType string
+		token       string
+		body        string
+		want        int
+	}{
+		{
+			name:        "path outside of the project",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"files": {"../Dockerfile": "FROM node:20"}}`,
+			want:        http.StatusBadRequest,
+		},
+		{
+			name:        "absolute path",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"files": {"/etc/cron.d/x": "FROM node:20"}}`,
+			want:        http.StatusBadRequest,
+		},
+		{
+			name:        "no Dockerfile",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"files": {"package.json": "{}"}}`,
+			want:        http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "invalid goal",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"files": {"Dockerfile": "FROM node:20"}, "goal": "speed"}`,
+			want:        http.StatusBadRequest,
+		},
+		{
+			name:        "too large",
+			opts:        &Options{MaxUploadSize: 64, Offline: true},
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"files": {"Dockerfile": "` + strings.Repeat("#", 100) + `"}}`,
+			want:        http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "unsupported content type",
+			method:      http.MethodPost,
+			contentType: "text/plain",
+			body:        "FROM node:20",
+			want:        http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "wrong method",
+			method:      http.MethodGet,
+			contentType: "application/json",
+			want:        http.StatusMethodNotAllowed,
+		},
+		{
+			name:        "missing token",
+			opts:        &Options{Token: "secret", Offline: true},
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"files": {"Dockerfile": "FROM node:20"}}`,
+			want:        http.StatusUnauthorized,
+		},
+		{
+			name:        "wrong token",
+			opts:        &Options{Token: "secret", Offline: true},
+			method:      http.MethodPost,
+			contentType: "application/json",
+			token:       "secre",
+			body:        `{"files": {"Dockerfile": "FROM node:20"}}`,
+			want:        http.StatusUnauthorized,
+		},
+		{
+			name:        "valid token",
+			opts:        &Options{Token: "secret", Offline: true},
+			method:      http.MethodPost,
+			contentType: "application/json",
+			token:       "secret",
+			body:        `{"files": {"Dockerfile": "FROM node:20"}}`,
+			want:        http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			if opts == nil {
+				opts = &Options{Offline: true}
+			}
+			req := httptest.NewRequest(tt.method, "/v1/analyze", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			New(opts).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("expected status %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestServer_Badge(t *testing.T) {
+	srv := httptest.NewServer(New(&Options{Token: "secret", Offline: true}))
+	defer srv.Close()
+
+	get := func(t *testing.T, path string) (*http.Response, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body bytes.Buffer
+		body.ReadFrom(resp.Body)
+		return resp, body.String()
+	}
+
+	if resp, _ := get(t, "/v1/badges/acme/api"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status 404 before the project is analyzed, got %d", resp.StatusCode)
+	}
+
+	files := map[string]string{"Dockerfile": testDockerfile, "package.json": `{"name": "app"}`}
+	for _, tt := range []struct {
+		badge string
+		want  int
+	}{{"acme/api", http.StatusOK}, {"../api", http.StatusBadRequest}, {"acme api", http.StatusBadRequest}} {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/analyze", jsonBody(t, &Request{Files: files, Badge: tt.badge}))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("expected status %d for badge %q, got %d", tt.want, tt.badge, resp.StatusCode)
+		}
+	}
+
+	// badges are public, so that shields.io can fetch them
+	resp, body := get(t, "/v1/badges/acme/api")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("expected the shields.io endpoint, got %d %s: %s", resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	}
+	endpoint := map[string]any{}
+	if err := json.Unmarshal([]byte(body), &endpoint); err != nil {
+		t.Fatal(err)
+	}
+	if endpoint["label"] != "dockershrink" || !strings.Contains(endpoint["message"].(string), "/100 (") {
+		t.Errorf("unexpected badge %v", endpoint)
+	}
+
+	resp, body = get(t, "/v1/badges/acme/api?metric=size&format=svg")
+	if resp.Header.Get("Content-Type") != "image/svg+xml" || !strings.Contains(body, "image size") {
+		t.Errorf("expected the SVG of the image size, got %s: %s", resp.Header.Get("Content-Type"), body)
+	}
+	if resp, _ := get(t, "/v1/badges/acme/api?format=png"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown format, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// certificate is a self-signed certificate usable by servers and clients, along with its key
+type certificate struct {
+	certFile, keyFile string
+	tls               tls.Certificate
+	x509              *x509.Certificate
+}
+
+func newCertificate(t *testing.T, dir, name string) *certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &certificate{certFile: filepath.Join(dir, name+".crt"), keyFile: filepath.Join(dir, name+".key")}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	os.WriteFile(c.certFile, certPEM, 0o600)
+	os.WriteFile(c.keyFile, keyPEM, 0o600)
+	if c.tls, err = tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		t.Fatal(err)
+	}
+	c.x509, _ = x509.ParseCertificate(der)
+	return c
+}
+
+func TestTLSConfig_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	serverCert := newCertificate(t, dir, "server")
+	clientCert := newCertificate(t, dir, "client")
+	strangerCert := newCertificate(t, dir, "stranger")
+
+	cfg, err := TLSConfig(serverCert.certFile, serverCert.keyFile, clientCert.certFile)
+	if err != nil {
+		t.Fatalf("TLSConfig() failed: %v", err)
+	}
+	l, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: New(&Options{Offline: true})}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCert.x509)
+	tests := []struct {
+		name    string
+		certs   []tls.Certificate
+		wantErr bool
+	}{
+		{name: "trusted client", certs: []tls.Certificate{clientCert.tls}},
+		{name: "untrusted client", certs: []tls.Certificate{strangerCert.tls}, wantErr: true},
+		{name: "no client certificate", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: &http.Transport{
+				TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: tt.certs},
+				ForceAttemptHTTP2: true,
+			}}
+			resp, err := client.Get("https://" + l.Addr().String() + "/healthz")
+			if tt.wantErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Errorf("expected the connection to be refused, got status %d", resp.StatusCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+				t.Errorf("expected 200 over HTTP/2, got %d over %s", resp.StatusCode, resp.Proto)
+			}
+		})
+	}
+}
+
+func TestTLSConfig_InvalidClientCA(t *testing.T) {
+	dir := t.TempDir()
+	serverCert := newCertificate(t, dir, "server")
+	ca := filepath.Join(dir, "ca.pem")
+	os.WriteFile(ca, []byte("not a certificate"), 0o600)
+	if _, err := TLSConfig(serverCert.certFile, serverCert.keyFile, ca); err == nil {
+		t.Error("expected an error for a client CA file without certificates")
+	}
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// maxFiles is the max number of files a project can be uploaded with
+const maxFiles = 10000
+
+// errTooLarge is returned when an upload is bigger than the server accepts
+var errTooLarge = errors.New("the project is too large")
+
+// projectDir is a temporary directory the uploaded files of a project are written to
+type projectDir struct {
+	root string
+	// left is the number of bytes that can still be written, so that compressed uploads can't expand without bounds
+	left  int64
+	files int
+}
+
+func newProjectDir(maxSize int64) (*projectDir, error) {
+	root, err := os.MkdirTemp("", "dockershrink-server-")
+	if err != nil {
+		return nil, err
+	}
+	return &projectDir{root: root, left: maxSize}, nil
+}
+
+func (d *projectDir) Remove() error {
+	return os.RemoveAll(d.root)
+}
+
+// path returns where the file with the given slash-separated name of the upload is written.
+// Names that would end up outside of the project are rejected.
+func (d *projectDir) path(name string) (string, error) {
+	clean := path.Clean("/" + strings.ReplaceAll(name, `\`, "/"))
+	if clean == "/" || clean != "/"+strings.TrimPrefix(path.Clean(name), "./") {
+		return "", fmt.Errorf("invalid file name %q, names must be relative to the project and can't contain ..", name)
+	}
+	return filepath.Join(d.root, filepath.FromSlash(clean)), nil
+}
+
+// writeFile writes the file with the given name, relative to the project
+func (d *projectDir) writeFile(name string, r io.Reader) error {
+	p, err := d.path(name)
+	if err != nil {
+		return err
+	}
+	if d.files++; d.files > maxFiles {
+		return fmt.Errorf("%w: it has more than %d files", errTooLarge, maxFiles)
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, err := io.Copy(f, io.LimitReader(r, d.left+1))
+	if err != nil {
+		return err
+	}
+	if d.left -= n; d.left < 0 {
+		return errTooLarge
+	}
+	return nil
+}
+
+// writeFiles writes a map of file names to their content
+func (d *projectDir) writeFiles(files map[string]string) error {
+	for name, content := range files {
+		if err := d.writeFile(name, strings.NewReader(content)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractTar writes the regular files and directories of a tarball, gzipped or not.
+// Symlinks and other special files are left out, so that they can't point outside of the project.
+func (d *projectDir) extractTar(r io.Reader, gzipped bool) error {
+	if gzipped {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("invalid gzip stream: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tarball: %w", err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			if err := d.writeFile(hdr.Name, tr); err != nil {
+				return err
+			}
+		case tar.TypeDir:
+			p, err := d.path(hdr.Name)
+			if err != nil {
+				// the root of the tarball is often stored as "./"
+				if path.Clean(hdr.Name) == "." {
+					continue
+				}
+				return err
+			}
+			if err := os.MkdirAll(p, 0o755); err != nil {
+				return err
+			}
+		}
+	}
+}
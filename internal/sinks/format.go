@@ -0,0 +1,169 @@
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/units"
+)
+
+const (
+	FormatJSON     = "json"
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+)
+
+// Render returns the report in the given format
+func Render(r *Report, format string) ([]byte, error) {
+	switch format {
+	case "", FormatJSON:
+		return json.MarshalIndent(r, "", "  ")
+	case FormatText:
+		return []byte(r.Summary()), nil
+	case FormatMarkdown:
+		return []byte(r.Markdown()), nil
+	default:
+		return nil, fmt.Errorf("unknown report format %q, must be %s, %s or %s", format, FormatJSON, FormatText, FormatMarkdown)
+	}
+}
+
+// Title is a one-line summary of the report
+func (r *Report) Title() string {
+	parts := []string{}
+	if r.Score != nil {
+		score := fmt.Sprintf("score %d/100", *r.Score)
+		if r.ScoreBreakdown != nil {
+			score += " (" + r.ScoreBreakdown.Grade + ")"
+		}
+		parts = append(parts, score)
+	}
+	if r.Findings != nil {
+		parts = append(parts, fmt.Sprintf("%d finding(s)", len(r.Findings)))
+	}
+	if r.ActionsTaken != nil || r.Recommendations != nil {
+		parts = append(parts, fmt.Sprintf("%d action(s) taken, %d recommendation(s)", len(r.ActionsTaken), len(r.Recommendations)))
+	}
+	title := fmt.Sprintf("dockershrink %s: %s", r.Command, r.DockerfilePath)
+	if len(parts) > 0 {
+		title += " (" + strings.Join(parts, ", ") + ")"
+	}
+	return title
+}
+
+// Summary returns a human-readable plain text version of the report
+func (r *Report) Summary() string {
+	var sb strings.Builder
+	sb.WriteString(r.Title() + "\n")
+	if len(r.Owners) > 0 {
+		sb.WriteString("Owners: " + strings.Join(r.Owners, ", ") + "\n")
+	}
+	if len(r.Findings) > 0 {
+		sb.WriteString("\nFindings:\n")
+		for _, f := range r.Findings {
+			location := f.Filepath
+			if f.Line > 0 {
+				location = fmt.Sprintf("%s:%d", f.Filepath, f.Line)
+			}
+			sb.WriteString(fmt.Sprintf("- [%s] %s (%s)\n", f.Severity, f.Title, location))
+		}
+	}
+	if len(r.ActionsTaken) > 0 {
+		sb.WriteString("\nActions taken:\n")
+		for _, a := range r.ActionsTaken {
+			sb.WriteString(fmt.Sprintf("- %s (%s)\n", a.Title, a.Filepath))
+		}
+	}
+	if len(r.Recommendations) > 0 {
+		sb.WriteString("\nRecommendations:\n")
+		for _, a := range r.Recommendations {
+			sb.WriteString(fmt.Sprintf("- %s (%s)\n", a.Title, a.Filepath))
+		}
+	}
+	return sb.String()
+}
+
+// Markdown returns a human-readable version of the report in GitHub flavored markdown,
+// suitable for a pull request comment. The diff is collapsed so that it doesn't bury the summary.
+func (r *Report) Markdown() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("### dockershrink %s: `%s`\n\n", r.Command, r.DockerfilePath))
+
+	if r.EstimatedImageSize > 0 {
+		if r.EstimatedOptimizedImageSize > 0 {
+			change := float64(r.EstimatedOptimizedImageSize-r.EstimatedImageSize) / float64(r.EstimatedImageSize) * 100
+			sb.WriteString(fmt.Sprintf("**Estimated image size:** %s → %s (%+.1f%%)\n\n", units.FormatBytes(r.EstimatedImageSize), units.FormatBytes(r.EstimatedOptimizedImageSize), change))
+		} else {
+			sb.WriteString(fmt.Sprintf("**Estimated image size:** %s\n\n", units.FormatBytes(r.EstimatedImageSize)))
+		}
+	}
+	if r.Score != nil {
+		if b := r.ScoreBreakdown; b != nil {
+			sb.WriteString(fmt.Sprintf("**Score:** %d/100 (%s): size %d (%s), cache %d (%s), security %d (%s)\n\n",
+				*r.Score, b.Grade, b.Size.Score, b.Size.Grade, b.Cache.Score, b.Cache.Grade, b.Security.Score, b.Security.Grade))
+		} else {
+			sb.WriteString(fmt.Sprintf("**Score:** %d/100\n\n", *r.Score))
+		}
+	}
+	if len(r.Owners) > 0 {
+		sb.WriteString("**Owners:** " + strings.Join(r.Owners, ", ") + "\n\n")
+	}
+
+	if len(r.Findings) > 0 {
+		sb.WriteString(fmt.Sprintf("#### %d finding(s)\n\n", len(r.Findings)))
+		sb.WriteString("| Severity | Rule | Location | Finding | Est. savings |\n|---|---|---|---|---|\n")
+		for _, f := range r.Findings {
+			location := f.Filepath
+			if f.Line > 0 {
+				location = fmt.Sprintf("%s:%d", f.Filepath, f.Line)
+			}
+			savings := ""
+			if f.EstimatedSizeImpact > 0 {
+				savings = units.FormatBytes(f.EstimatedSizeImpact)
+			}
+			rule := f.Code
+			if rule == "" {
+				rule = f.Rule
+			}
+			sb.WriteString(markdownRow(string(f.Severity), rule, location, f.Title, savings))
+		}
+		sb.WriteString("\n")
+	}
+	if len(r.ActionsTaken) > 0 {
+		sb.WriteString(fmt.Sprintf("#### %d action(s) taken\n\n", len(r.ActionsTaken)))
+		sb.WriteString("| Action | File | Risk |\n|---|---|---|\n")
+		for _, a := range r.ActionsTaken {
+			sb.WriteString(markdownRow(a.Title, a.Filepath, string(a.Risk)))
+		}
+		sb.WriteString("\n")
+	}
+	if len(r.Recommendations) > 0 {
+		sb.WriteString(fmt.Sprintf("#### %d recommendation(s)\n\n", len(r.Recommendations)))
+		for _, a := range r.Recommendations {
+			sb.WriteString(fmt.Sprintf("- **%s** (`%s`): %s\n", a.Title, a.Filepath, strings.Join(strings.Fields(a.Description), " ")))
+		}
+		sb.WriteString("\n")
+	}
+
+	if r.Diff != "" {
+		// a fence longer than any backtick run in the diff, so that it can't be closed early
+		fence := "```"
+		for strings.Contains(r.Diff, fence) {
+			fence += "`"
+		}
+		sb.WriteString("<details>\n<summary>Diff</summary>\n\n")
+		sb.WriteString(fence + "diff\n" + strings.TrimSuffix(r.Diff, "\n") + "\n" + fence + "\n\n")
+		sb.WriteString("</details>\n")
+	}
+	return sb.String()
+}
+
+// markdownRow returns a row of a markdown table, escaping the characters that would break it
+func markdownRow(cells ...string) string {
+	escaped := make([]string, len(cells))
+	for i, c := range cells {
+		c = strings.ReplaceAll(c, "|", "\\|")
+		escaped[i] = strings.Join(strings.Fields(c), " ")
+	}
+	return "| " + strings.Join(escaped, " | ") + " |\n"
+}
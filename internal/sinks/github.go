@@ -0,0 +1,104 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/config"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+// max number of annotations the GitHub API accepts in a single request
+const maxCheckAnnotations = 50
+
+// githubCheckSink publishes the report as a GitHub check run on the current commit.
+// It is meant to be used in GitHub Actions, where the repository and commit are read from the environment.
+type githubCheckSink struct {
+	apiURL     string
+	repository string
+	sha        string
+	token      string
+}
+
+func newGithubCheckSink(cfg *config.SinkConfig) (ReportSink, error) {
+	s := &githubCheckSink{
+		apiURL:     cfg.URL,
+		repository: cfg.Repository,
+		sha:        os.Getenv("GITHUB_SHA"),
+	}
+	if s.apiURL == "" {
+		s.apiURL = os.Getenv("GITHUB_API_URL")
+	}
+	if s.apiURL == "" {
+		s.apiURL = "https://api.github.com"
+	}
+	if s.repository == "" {
+		s.repository = os.Getenv("GITHUB_REPOSITORY")
+	}
+	tokenEnv := cfg.TokenEnv
+	if tokenEnv == "" {
+		tokenEnv = "GITHUB_TOKEN"
+	}
+	s.token = os.Getenv(tokenEnv)
+
+	switch {
+	case s.repository == "":
+		return nil, errors.New("repository is required when GITHUB_REPOSITORY is not set")
+	case s.sha == "":
+		return nil, errors.New("GITHUB_SHA is not set")
+	case s.token == "":
+		return nil, fmt.Errorf("environment variable %s is not set", tokenEnv)
+	}
+	return s, nil
+}
+
+func (s *githubCheckSink) Name() string {
+	return "github_check"
+}
+
+func (s *githubCheckSink) Send(ctx context.Context, r *Report) error {
+	conclusion := "success"
+	if len(r.Findings) > 0 || len(r.ActionsTaken) > 0 || len(r.Recommendations) > 0 {
+		conclusion = "neutral"
+	}
+
+	annotations := []map[string]any{}
+	for _, f := range r.Findings {
+		if f.Line == 0 || len(annotations) == maxCheckAnnotations {
+			continue
+		}
+		level := "notice"
+		if f.Severity == models.SeverityHigh || f.Severity == models.SeverityMedium {
+			level = "warning"
+		}
+		annotations = append(annotations, map[string]any{
+			"path":             f.Filepath,
+			"start_line":       f.Line,
+			"end_line":         f.Line,
+			"annotation_level": level,
+			"title":            f.Title,
+			"message":          f.Description,
+		})
+	}
+
+	body := map[string]any{
+		"name":       "dockershrink",
+		"head_sha":   s.sha,
+		"status":     "completed",
+		"conclusion": conclusion,
+		"output": map[string]any{
+			"title":       r.Title(),
+			"summary":     r.Summary(),
+			"annotations": annotations,
+		},
+	}
+	url := fmt.Sprintf("%s/repos/%s/check-runs", strings.TrimSuffix(s.apiURL, "/"), s.repository)
+	headers := map[string]string{
+		"Authorization": "Bearer " + s.token,
+		"Accept":        "application/vnd.github+json",
+	}
+	return postJSON(ctx, url, headers, body)
+}
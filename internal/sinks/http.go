@@ -0,0 +1,111 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/config"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// postJSON sends body as JSON to url and returns an error if the response is not a 2xx
+func postJSON(ctx context.Context, url string, headers map[string]string, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to serialize request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("request failed with status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// resolveURL returns the url from the config, or from the environment variable it names
+func resolveURL(cfg *config.SinkConfig) (string, error) {
+	if cfg.URL != "" {
+		return cfg.URL, nil
+	}
+	if cfg.URLEnv == "" {
+		return "", errors.New("either url or url_env is required")
+	}
+	url := os.Getenv(cfg.URLEnv)
+	if url == "" {
+		return "", fmt.Errorf("environment variable %s is not set", cfg.URLEnv)
+	}
+	return url, nil
+}
+
+// webhookSink posts the JSON report to an HTTP endpoint
+type webhookSink struct {
+	url     string
+	headers map[string]string
+}
+
+func newWebhookSink(cfg *config.SinkConfig) (ReportSink, error) {
+	url, err := resolveURL(cfg)
+	if err != nil {
+		return nil, err
+	}
+	headers := map[string]string{}
+	for k, v := range cfg.Headers {
+		// allow secrets in headers, eg- "Authorization: Bearer ${TOKEN}"
+		headers[k] = os.ExpandEnv(v)
+	}
+	return &webhookSink{url: url, headers: headers}, nil
+}
+
+func (s *webhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *webhookSink) Send(ctx context.Context, r *Report) error {
+	return postJSON(ctx, s.url, s.headers, r)
+}
+
+// slackSink posts a summary of the report to a Slack incoming webhook
+type slackSink struct {
+	url string
+}
+
+func newSlackSink(cfg *config.SinkConfig) (ReportSink, error) {
+	c := *cfg
+	if c.URL == "" && c.URLEnv == "" {
+		c.URLEnv = "SLACK_WEBHOOK_URL"
+	}
+	url, err := resolveURL(&c)
+	if err != nil {
+		return nil, err
+	}
+	return &slackSink{url: url}, nil
+}
+
+func (s *slackSink) Name() string {
+	return "slack"
+}
+
+func (s *slackSink) Send(ctx context.Context, r *Report) error {
+	return postJSON(ctx, s.url, nil, map[string]string{"text": "```" + r.Summary() + "```"})
+}
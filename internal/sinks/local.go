@@ -0,0 +1,81 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/config"
+)
+
+// stdoutSink prints the report
+type stdoutSink struct {
+	w      io.Writer
+	format string
+}
+
+func newStdoutSink(cfg *config.SinkConfig) (ReportSink, error) {
+	if _, err := Render(&Report{}, cfg.Format); err != nil {
+		return nil, err
+	}
+	return &stdoutSink{w: os.Stdout, format: cfg.Format}, nil
+}
+
+func (s *stdoutSink) Name() string {
+	return "stdout"
+}
+
+func (s *stdoutSink) Send(ctx context.Context, r *Report) error {
+	content, err := Render(r, s.format)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(s.w, string(content))
+	return err
+}
+
+// fileSink writes the report to a file.
+// The path may contain {run_id}, so that every run gets its own file.
+type fileSink struct {
+	path   string
+	format string
+}
+
+func newFileSink(cfg *config.SinkConfig) (ReportSink, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("path is required for file sinks")
+	}
+	if _, err := Render(&Report{}, cfg.Format); err != nil {
+		return nil, err
+	}
+	return &fileSink{path: cfg.Path, format: cfg.Format}, nil
+}
+
+func (s *fileSink) Name() string {
+	return "file " + s.path
+}
+
+func (s *fileSink) Send(ctx context.Context, r *Report) error {
+	content, err := Render(r, s.format)
+	if err != nil {
+		return err
+	}
+	path := expandRunID(s.path, r)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0o644)
+}
+
+// expandRunID replaces {run_id} in s with the report's run ID
+func expandRunID(s string, r *Report) string {
+	id := r.RunID
+	if id == "" {
+		id = r.Timestamp.UTC().Format("20060102T150405Z")
+	}
+	return strings.ReplaceAll(s, "{run_id}", id)
+}
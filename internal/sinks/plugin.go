@@ -0,0 +1,38 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/config"
+	"github.com/duaraghav8/dockershrink/internal/plugin"
+)
+
+// MethodSend is the request a sink plugin receives for every report, with the report as its params
+const MethodSend = "sink.send"
+
+// pluginSink delivers reports through a plugin, for destinations dockershrink doesn't support
+type pluginSink struct {
+	command []string
+}
+
+func newPluginSink(cfg *config.SinkConfig) (ReportSink, error) {
+	if len(cfg.Command) == 0 {
+		return nil, errors.New("command is required")
+	}
+	return &pluginSink{command: cfg.Command}, nil
+}
+
+func (s *pluginSink) Name() string {
+	return "plugin/" + strings.Join(s.command, " ")
+}
+
+func (s *pluginSink) Send(ctx context.Context, r *Report) error {
+	// the handshake is made for every report, the plugin may have been upgraded in between
+	p, err := plugin.Load(ctx, s.command, plugin.KindSink)
+	if err != nil {
+		return err
+	}
+	return p.Call(ctx, MethodSend, r, nil)
+}
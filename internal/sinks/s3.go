@@ -0,0 +1,53 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/config"
+)
+
+// s3Sink uploads the JSON report to an S3 bucket.
+// It uses the AWS CLI so that every credential source supported by AWS (profiles, SSO, instance roles) works.
+type s3Sink struct {
+	bucket string
+	key    string
+}
+
+func newS3Sink(cfg *config.SinkConfig) (ReportSink, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("bucket is required for s3 sinks")
+	}
+	if _, err := exec.LookPath("aws"); err != nil {
+		return nil, errors.New("the AWS CLI is required for s3 sinks but was not found in PATH")
+	}
+	key := cfg.Key
+	if key == "" {
+		key = "dockershrink/{run_id}.json"
+	}
+	return &s3Sink{bucket: cfg.Bucket, key: key}, nil
+}
+
+func (s *s3Sink) Name() string {
+	return "s3 " + s.bucket
+}
+
+func (s *s3Sink) Send(ctx context.Context, r *Report) error {
+	content, err := Render(r, FormatJSON)
+	if err != nil {
+		return err
+	}
+	dest := fmt.Sprintf("s3://%s/%s", s.bucket, strings.TrimPrefix(expandRunID(s.key, r), "/"))
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "aws", "s3", "cp", "-", dest, "--content-type", "application/json")
+	cmd.Stdin, cmd.Stderr = bytes.NewReader(content), &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to upload report to %s: %w: %s", dest, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
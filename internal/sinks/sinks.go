@@ -0,0 +1,137 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/config"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+// Report is the outcome of a dockershrink run, delivered to every configured sink
+type Report struct {
+	RunID          string    `json:"run_id,omitempty"`
+	Command        string    `json:"command"`
+	Timestamp      time.Time `json:"timestamp"`
+	DockerfilePath string    `json:"dockerfile_path"`
+	// Owners of the Dockerfile according to CODEOWNERS and the configuration
+	Owners []string `json:"owners,omitempty"`
+
+	// Score and Findings are only set by commands that analyze the image definition
+	Score    *int              `json:"score,omitempty"`
+	Findings []*models.Finding `json:"findings,omitempty"`
+	// ScoreBreakdown grades the score and its size, cache and security aspects, set along with it
+	ScoreBreakdown *models.ScoreBreakdown `json:"score_breakdown,omitempty"`
+	// InstructionSizes estimates what the instructions of the final image add to its size, biggest first
+	InstructionSizes []*models.InstructionSize `json:"instruction_sizes,omitempty"`
+	// Variants are the analyses under each combination of build arguments the image is built with
+	Variants []*models.Variant `json:"variants,omitempty"`
+
+	ActionsTaken    []*models.OptimizationAction `json:"actions_taken,omitempty"`
+	Recommendations []*models.OptimizationAction `json:"recommendations,omitempty"`
+
+	// EstimatedImageSize is the estimated size of the image in bytes, before any optimization
+	EstimatedImageSize int64 `json:"estimated_image_size,omitempty"`
+	// EstimatedOptimizedImageSize is the estimated size of the image built from the optimized files, only set by optimize
+	EstimatedOptimizedImageSize int64 `json:"estimated_optimized_image_size,omitempty"`
+	// Diff is the change made to the project's files as a git patch, only set by optimize
+	Diff string `json:"diff,omitempty"`
+}
+
+// ReportSink is a destination that run reports are delivered to
+type ReportSink interface {
+	// Name identifies the sink in error messages
+	Name() string
+	Send(ctx context.Context, r *Report) error
+}
+
+// factory creates a sink from its configuration
+type factory func(cfg *config.SinkConfig) (ReportSink, error)
+
+// factories maps every sink type to its constructor.
+// Adding a new destination only requires registering it here.
+var factories = map[string]factory{
+	"stdout":       newStdoutSink,
+	"file":         newFileSink,
+	"slack":        newSlackSink,
+	"webhook":      newWebhookSink,
+	"github_check": newGithubCheckSink,
+	"s3":           newS3Sink,
+	"plugin":       newPluginSink,
+}
+
+// New creates the sink described by cfg
+func New(cfg *config.SinkConfig) (ReportSink, error) {
+	f, ok := factories[cfg.Type]
+	if !ok {
+		types := make([]string, 0, len(factories))
+		for t := range factories {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		return nil, fmt.Errorf("unknown report sink type %q, must be one of: %s", cfg.Type, strings.Join(types, ", "))
+	}
+	return f(cfg)
+}
+
+// FromConfig creates a sink that fans reports out to all the sinks in the configuration.
+// Sinks that cannot be created are left out and their errors are returned along with the valid sinks,
+// so that a single misconfigured destination doesn't prevent reports from reaching the others.
+func FromConfig(cfgs []config.SinkConfig) (*Multi, error) {
+	m := &Multi{}
+	var errs []error
+	for i := range cfgs {
+		s, err := New(&cfgs[i])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("reports[%d] (%s): %w", i, cfgs[i].Type, err))
+			continue
+		}
+		if len(cfgs[i].Owners) > 0 {
+			s = &ownedSink{ReportSink: s, owners: cfgs[i].Owners}
+		}
+		m.Sinks = append(m.Sinks, s)
+	}
+	return m, errors.Join(errs...)
+}
+
+// Multi delivers reports to multiple sinks.
+// A failing sink doesn't prevent the report from being delivered to the others.
+type Multi struct {
+	Sinks []ReportSink
+}
+
+func (m *Multi) Name() string {
+	return "multi"
+}
+
+// Send delivers the report to every sink and returns the errors of all the sinks that failed
+func (m *Multi) Send(ctx context.Context, r *Report) error {
+	var errs []error
+	for _, s := range m.Sinks {
+		if err := s.Send(ctx, r); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ownedSink only delivers the reports of Dockerfiles owned by one of its owners
+type ownedSink struct {
+	ReportSink
+	owners []string
+}
+
+func (s *ownedSink) Send(ctx context.Context, r *Report) error {
+	for _, o := range r.Owners {
+		for _, want := range s.owners {
+			if strings.EqualFold(o, want) {
+				return s.ReportSink.Send(ctx, r)
+			}
+		}
+	}
+	return nil
+}
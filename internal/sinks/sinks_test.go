@@ -0,0 +1,265 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/config"
+	"github.com/duaraghav8/dockershrink/internal/models"
+)
+
+func testReport() *Report {
+	score := 77
+	return &Report{
+		RunID:          "20250102T030405.000Z",
+		Command:        "analyze",
+		Timestamp:      time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		DockerfilePath: "Dockerfile",
+		Score:          &score,
+		Findings: []*models.Finding{
+			{Rule: "heavy-final-base-image", Severity: models.SeverityHigh, Filepath: "Dockerfile", Line: 1, Title: "Final stage uses a heavy base image"},
+		},
+	}
+}
+
+func TestNew_UnknownType(t *testing.T) {
+	if _, err := New(&config.SinkConfig{Type: "carrier-pigeon"}); err == nil {
+		t.Error("expected an error for an unknown sink type")
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := New(&config.SinkConfig{Type: "file", Path: filepath.Join(dir, "reports", "{run_id}.json")})
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	if err := sink.Send(context.Background(), testReport()); err != nil {
+		t.Fatalf("failed to send report: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "reports", "20250102T030405.000Z.json"))
+	if err != nil {
+		t.Fatalf("report was not written: %v", err)
+	}
+	r := &Report{}
+	if err := json.Unmarshal(content, r); err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+	if *r.Score != 77 || len(r.Findings) != 1 {
+		t.Errorf("unexpected report content: %s", content)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var received *Report
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		auth = req.Header.Get("Authorization")
+		received = &Report{}
+		json.NewDecoder(req.Body).Decode(received)
+	}))
+	defer server.Close()
+
+	t.Setenv("WEBHOOK_TOKEN", "secret")
+	sink, err := New(&config.SinkConfig{Type: "webhook", URL: server.URL, Headers: map[string]string{"Authorization": "Bearer ${WEBHOOK_TOKEN}"}})
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	if err := sink.Send(context.Background(), testReport()); err != nil {
+		t.Fatalf("failed to send report: %v", err)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Authorization header = %q; want %q", auth, "Bearer secret")
+	}
+	if received == nil || received.RunID != "20250102T030405.000Z" {
+		t.Errorf("webhook did not receive the report")
+	}
+}
+
+func TestGithubCheckSink(t *testing.T) {
+	var body map[string]any
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path = req.URL.Path
+		json.NewDecoder(req.Body).Decode(&body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	t.Setenv("GITHUB_SHA", "abc123")
+	t.Setenv("GITHUB_TOKEN", "token")
+	sink, err := New(&config.SinkConfig{Type: "github_check", URL: server.URL, Repository: "acme/api"})
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	if err := sink.Send(context.Background(), testReport()); err != nil {
+		t.Fatalf("failed to send report: %v", err)
+	}
+	if path != "/repos/acme/api/check-runs" {
+		t.Errorf("unexpected API path %s", path)
+	}
+	if body["head_sha"] != "abc123" || body["conclusion"] != "neutral" {
+		t.Errorf("unexpected check run: %v", body)
+	}
+	annotations := body["output"].(map[string]any)["annotations"].([]any)
+	if len(annotations) != 1 {
+		t.Errorf("expected 1 annotation, got %d", len(annotations))
+	}
+}
+
+type failingSink struct{}
+
+func (failingSink) Name() string                              { return "failing" }
+func (failingSink) Send(ctx context.Context, r *Report) error { return errors.New("unreachable") }
+
+func TestPluginSink(t *testing.T) {
+	dir := t.TempDir()
+	received := filepath.Join(dir, "received.json")
+	script := filepath.Join(dir, "plugin.sh")
+	// answers the handshake and saves the request of every report
+	content := "#!/bin/sh\nrequest=$(cat)\ncase \"$request\" in\n" +
+		"  *'\"method\":\"handshake\"'*) echo '{\"result\": {\"name\": \"save\", \"protocol_version\": 1, \"capabilities\": [{\"kind\": \"sink\", \"version\": 1}]}}' ;;\n" +
+		"  *) echo \"$request\" > " + received + "; echo '{}' ;;\nesac\n"
+	if err := os.WriteFile(script, []byte(content), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	sink, err := New(&config.SinkConfig{Type: "plugin", Command: []string{script}})
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	if err := sink.Send(context.Background(), testReport()); err != nil {
+		t.Fatalf("failed to send report: %v", err)
+	}
+	request, err := os.ReadFile(received)
+	if err != nil {
+		t.Fatalf("report was not received: %v", err)
+	}
+	req := struct {
+		Method string  `json:"method"`
+		Params *Report `json:"params"`
+	}{}
+	if err := json.Unmarshal(request, &req); err != nil {
+		t.Fatalf("request is not valid JSON: %v", err)
+	}
+	if req.Method != MethodSend || *req.Params.Score != 77 {
+		t.Errorf("unexpected request: %s", request)
+	}
+
+	if _, err := New(&config.SinkConfig{Type: "plugin"}); err == nil {
+		t.Error("expected an error for a plugin sink without a command")
+	}
+}
+
+func TestMulti_ContinuesAfterFailure(t *testing.T) {
+	dir := t.TempDir()
+	fileSink, _ := New(&config.SinkConfig{Type: "file", Path: filepath.Join(dir, "report.txt"), Format: FormatText})
+	m := &Multi{Sinks: []ReportSink{failingSink{}, fileSink}}
+
+	err := m.Send(context.Background(), testReport())
+	if err == nil || !strings.Contains(err.Error(), "failing: unreachable") {
+		t.Errorf("expected the error of the failing sink, got %v", err)
+	}
+	content, _ := os.ReadFile(filepath.Join(dir, "report.txt"))
+	if !strings.Contains(string(content), "score 77/100") {
+		t.Errorf("report was not delivered to the remaining sinks: %q", content)
+	}
+}
+
+func TestFromConfig_SkipsInvalidSinks(t *testing.T) {
+	m, err := FromConfig([]config.SinkConfig{
+		{Type: "stdout"},
+		{Type: "file"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "reports[1] (file)") {
+		t.Errorf("expected an error for the invalid file sink, got %v", err)
+	}
+	if len(m.Sinks) != 1 || m.Sinks[0].Name() != "stdout" {
+		t.Errorf("expected only the stdout sink to be created, got %d sinks", len(m.Sinks))
+	}
+}
+
+func TestFromConfig_RoutesByOwner(t *testing.T) {
+	dir := t.TempDir()
+	m, err := FromConfig([]config.SinkConfig{
+		{Type: "file", Path: filepath.Join(dir, "api.json"), Owners: []string{"@acme/api-team"}},
+		{Type: "file", Path: filepath.Join(dir, "web.json"), Owners: []string{"@acme/web-team"}},
+		{Type: "file", Path: filepath.Join(dir, "all.json")},
+	})
+	if err != nil {
+		t.Fatalf("failed to create sinks: %v", err)
+	}
+
+	r := testReport()
+	r.Owners = []string{"@ACME/api-team"}
+	if err := m.Send(context.Background(), r); err != nil {
+		t.Fatalf("failed to send report: %v", err)
+	}
+	for file, delivered := range map[string]bool{"api.json": true, "web.json": false, "all.json": true} {
+		_, err := os.Stat(filepath.Join(dir, file))
+		if delivered != (err == nil) {
+			t.Errorf("expected delivery to %s to be %v", file, delivered)
+		}
+	}
+}
+
+func TestReport_Markdown(t *testing.T) {
+	r := &Report{
+		Command:                     "optimize",
+		DockerfilePath:              "Dockerfile",
+		EstimatedImageSize:          400 * 1024 * 1024,
+		EstimatedOptimizedImageSize: 100 * 1024 * 1024,
+		ActionsTaken: []*models.OptimizationAction{
+			{Title: "Used a smaller base image | alpine", Filepath: "Dockerfile", Risk: models.RiskBehavior},
+		},
+		Recommendations: []*models.OptimizationAction{
+			{Title: "Use multistage builds", Filepath: "Dockerfile", Description: "Build in one stage,\nrun in another."},
+		},
+		Diff: "--- a/Dockerfile\n+++ b/Dockerfile\n@@ -1 +1 @@\n-FROM node:20\n+FROM node:20-alpine\n",
+	}
+	md := r.Markdown()
+	for _, want := range []string{
+		"### dockershrink optimize: `Dockerfile`",
+		"**Estimated image size:** 400.0 MB → 100.0 MB (-75.0%)",
+		"| Action | File | Risk |",
+		"| Used a smaller base image \\| alpine | Dockerfile | behavior-changing |",
+		"- **Use multistage builds** (`Dockerfile`): Build in one stage, run in another.",
+		"<details>\n<summary>Diff</summary>\n\n```diff\n--- a/Dockerfile",
+		"+FROM node:20-alpine\n```\n\n</details>\n",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("expected the report to contain %q, got:\n%s", want, md)
+		}
+	}
+
+	md = testReport().Markdown()
+	if !strings.Contains(md, "**Score:** 77/100") || !strings.Contains(md, "| high | heavy-final-base-image | Dockerfile:1 | Final stage uses a heavy base image |  |") {
+		t.Errorf("unexpected findings in the report:\n%s", md)
+	}
+	if strings.Contains(md, "<details>") {
+		t.Errorf("expected no diff without changes:\n%s", md)
+	}
+
+	r = testReport()
+	r.ScoreBreakdown = &models.ScoreBreakdown{
+		Grade:    "C",
+		Size:     models.AspectScore{Score: 85, Grade: "B", Findings: 1},
+		Cache:    models.AspectScore{Score: 100, Grade: "A"},
+		Security: models.AspectScore{Score: 92, Grade: "A", Findings: 1},
+	}
+	if md := r.Markdown(); !strings.Contains(md, "**Score:** 77/100 (C): size 85 (B), cache 100 (A), security 92 (A)") {
+		t.Errorf("expected the graded breakdown in the report:\n%s", md)
+	}
+	if title := r.Title(); !strings.Contains(title, "score 77/100 (C)") {
+		t.Errorf("expected the grade in the title, got %q", title)
+	}
+}
@@ -0,0 +1,210 @@
+// Package targets finds the Dockerfiles in a directory tree, so that commands can run on all of
+// them at once, and collects the failures of individual Dockerfiles without aborting the run.
+package targets
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Target is a Dockerfile along with the project it builds
+type Target struct {
+	// Dir is the directory of the Dockerfile, which is used as its build context
+	Dir        string
+	Dockerfile string
+	// Dockerignore is the .dockerignore file that applies to the Dockerfile. The file may not exist.
+	Dockerignore string
+}
+
+// IsDockerfile returns true if the file name is a conventional Dockerfile name,
+// eg- Dockerfile, Dockerfile.prod or api.Dockerfile
+func IsDockerfile(name string) bool {
+	lower := strings.ToLower(name)
+	if strings.HasSuffix(lower, ".dockerignore") {
+		return false
+	}
+	return lower == "dockerfile" || strings.HasPrefix(lower, "dockerfile.") || strings.HasSuffix(lower, ".dockerfile")
+}
+
+// Find returns every Dockerfile under root, sorted by path. Directories whose names are in
+// skipDirs, eg- node_modules, are not searched. Paths of the targets are joined with root.
+func Find(root string, skipDirs []string) ([]*Target, error) {
+	targets := []*Target{}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != root && slices.Contains(skipDirs, d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !IsDockerfile(d.Name()) {
+			return nil
+		}
+		dir := filepath.Dir(p)
+		targets = append(targets, &Target{Dir: dir, Dockerfile: p, Dockerignore: dockerignoreFor(dir, d.Name())})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search %s for Dockerfiles: %w", root, err)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Dockerfile < targets[j].Dockerfile })
+	return targets, nil
+}
+
+// FromChangedFiles returns the Dockerfiles affected by changes to the given files, sorted by path:
+// the changed Dockerfiles, and the Dockerfiles that changed ignore files apply to.
+// Files that no longer exist are left out.
+func FromChangedFiles(paths []string) ([]*Target, error) {
+	seen := map[string]bool{}
+	targets := []*Target{}
+	add := func(p string) {
+		if seen[p] {
+			return
+		}
+		if info, err := os.Stat(p); err != nil || !info.Mode().IsRegular() {
+			return
+		}
+		seen[p] = true
+		dir := filepath.Dir(p)
+		targets = append(targets, &Target{Dir: dir, Dockerfile: p, Dockerignore: dockerignoreFor(dir, filepath.Base(p))})
+	}
+
+	for _, p := range paths {
+		p = filepath.Clean(p)
+		dir, name := filepath.Split(p)
+		switch {
+		case IsDockerfile(name):
+			add(p)
+		case name == ".dockerignore":
+			// the ignore file of the build context applies to all the Dockerfiles next to it
+			entries, err := os.ReadDir(filepath.Join(dir, "."))
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			for _, e := range entries {
+				if IsDockerfile(e.Name()) {
+					add(filepath.Join(dir, e.Name()))
+				}
+			}
+		case strings.HasSuffix(name, ".dockerignore"):
+			add(strings.TrimSuffix(p, ".dockerignore"))
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Dockerfile < targets[j].Dockerfile })
+	return targets, nil
+}
+
+// FromPaths returns the Dockerfiles at the given paths, in the order they were given. A path may be a Dockerfile
+// or a directory, which stands for the Dockerfile in it. Paths given more than once are only returned once.
+func FromPaths(paths []string) ([]*Target, error) {
+	seen := map[string]bool{}
+	targets := []*Target{}
+	for _, p := range paths {
+		p = filepath.Clean(p)
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			p = filepath.Join(p, "Dockerfile")
+			if info, err = os.Stat(p); err != nil {
+				return nil, err
+			}
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s is not a file", p)
+		}
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		dir := filepath.Dir(p)
+		targets = append(targets, &Target{Dir: dir, Dockerfile: p, Dockerignore: dockerignoreFor(dir, filepath.Base(p))})
+	}
+	return targets, nil
+}
+
+// dockerignoreFor returns the ignore file BuildKit uses for the Dockerfile: <Dockerfile>.dockerignore
+// if it exists, otherwise the .dockerignore at the root of the build context.
+func dockerignoreFor(dir, dockerfile string) string {
+	specific := filepath.Join(dir, dockerfile+".dockerignore")
+	if _, err := os.Stat(specific); err == nil {
+		return specific
+	}
+	return filepath.Join(dir, ".dockerignore")
+}
+
+// Each calls fn on every target using up to jobs goroutines, and done with the result of each target.
+// done is called in the order of the targets and never concurrently, so that the results can be printed
+// and aggregated as if the targets were handled one after the other. fn must be safe for concurrent use
+// when jobs is more than 1.
+func Each[R any](ts []*Target, jobs int, fn func(*Target) (R, error), done func(*Target, R, error)) {
+	if jobs <= 1 || len(ts) <= 1 {
+		for _, t := range ts {
+			r, err := fn(t)
+			done(t, r, err)
+		}
+		return
+	}
+
+	type result struct {
+		value R
+		err   error
+	}
+	results := make([]chan result, len(ts))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+	queue := make(chan int)
+	go func() {
+		defer close(queue)
+		for i := range ts {
+			queue <- i
+		}
+	}()
+	for w := 0; w < min(jobs, len(ts)); w++ {
+		go func() {
+			for i := range queue {
+				r, err := fn(ts[i])
+				results[i] <- result{value: r, err: err}
+			}
+		}()
+	}
+	for i, t := range ts {
+		r := <-results[i]
+		done(t, r.value, r.err)
+	}
+}
+
+// Failure is the error a single target failed with
+type Failure struct {
+	Target *Target
+	Err    error
+}
+
+// Failures collects the errors of the targets that failed during a run over many targets
+type Failures []*Failure
+
+func (f Failures) Error() string {
+	msgs := make([]string, 0, len(f))
+	for _, failure := range f {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", failure.Target.Dockerfile, failure.Err))
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Add records the failure of a target, nil errors are ignored
+func (f *Failures) Add(t *Target, err error) {
+	if err != nil {
+		*f = append(*f, &Failure{Target: t, Err: err})
+	}
+}
@@ -0,0 +1,240 @@
+package targets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIsDockerfile(t *testing.T) {
+	tests := map[string]bool{
+		"Dockerfile":              true,
+		"dockerfile":              true,
+		"Dockerfile.prod":         true,
+		"api.Dockerfile":          true,
+		"Dockerfile.dockerignore": false,
+		".dockerignore":           false,
+		"Dockerfiles":             false,
+		"docker-compose.yml":      false,
+	}
+	for name, expected := range tests {
+		if got := IsDockerfile(name); got != expected {
+			t.Errorf("IsDockerfile(%q) = %v; want %v", name, got, expected)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	for _, p := range []string{
+		"Dockerfile",
+		"services/api/Dockerfile",
+		"services/api/Dockerfile.dockerignore",
+		"services/web/web.Dockerfile",
+		"services/web/.dockerignore",
+		"node_modules/pkg/Dockerfile",
+	} {
+		full := filepath.Join(root, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte("FROM node:20\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	targets, err := Find(root, []string{"node_modules"})
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	expected := []*Target{
+		{Dir: root, Dockerfile: "Dockerfile", Dockerignore: ".dockerignore"},
+		{Dir: "services/api", Dockerfile: "services/api/Dockerfile", Dockerignore: "services/api/Dockerfile.dockerignore"},
+		{Dir: "services/web", Dockerfile: "services/web/web.Dockerfile", Dockerignore: "services/web/.dockerignore"},
+	}
+	if len(targets) != len(expected) {
+		t.Fatalf("Find() returned %d targets; want %d", len(targets), len(expected))
+	}
+	for i, e := range expected {
+		dir := e.Dir
+		if dir != root {
+			dir = filepath.Join(root, filepath.FromSlash(dir))
+		}
+		e = &Target{
+			Dir:          dir,
+			Dockerfile:   filepath.Join(root, filepath.FromSlash(e.Dockerfile)),
+			Dockerignore: filepath.Join(root, filepath.FromSlash(e.Dockerignore)),
+		}
+		if *targets[i] != *e {
+			t.Errorf("Find()[%d] = %+v; want %+v", i, targets[i], e)
+		}
+	}
+}
+
+func TestFromChangedFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, p := range []string{
+		"Dockerfile",
+		"services/api/Dockerfile",
+		"services/api/Dockerfile.dockerignore",
+		"services/web/web.Dockerfile",
+		"services/web/Dockerfile.dev",
+		"services/web/.dockerignore",
+	} {
+		full := filepath.Join(root, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte("FROM node:20\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(p string) string {
+		return filepath.Join(root, filepath.FromSlash(p))
+	}
+
+	targets, err := FromChangedFiles([]string{
+		path("services/web/.dockerignore"),
+		path("services/api/Dockerfile.dockerignore"),
+		path("services/web/Dockerfile.dev"),
+		path("src/index.js"),
+		path("deleted/Dockerfile"),
+	})
+	if err != nil {
+		t.Fatalf("FromChangedFiles() error = %v", err)
+	}
+	expected := []*Target{
+		{Dir: path("services/api"), Dockerfile: path("services/api/Dockerfile"), Dockerignore: path("services/api/Dockerfile.dockerignore")},
+		{Dir: path("services/web"), Dockerfile: path("services/web/Dockerfile.dev"), Dockerignore: path("services/web/.dockerignore")},
+		{Dir: path("services/web"), Dockerfile: path("services/web/web.Dockerfile"), Dockerignore: path("services/web/.dockerignore")},
+	}
+	if len(targets) != len(expected) {
+		t.Fatalf("FromChangedFiles() returned %d targets; want %d: %+v", len(targets), len(expected), targets)
+	}
+	for i, e := range expected {
+		if *targets[i] != *e {
+			t.Errorf("FromChangedFiles()[%d] = %+v; want %+v", i, targets[i], e)
+		}
+	}
+}
+
+func TestFromPaths(t *testing.T) {
+	root := t.TempDir()
+	for _, p := range []string{
+		"services/api/Dockerfile",
+		"services/api/Dockerfile.dockerignore",
+		"services/web/web.Dockerfile",
+		"docs/README.md",
+	} {
+		full := filepath.Join(root, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte("FROM node:20\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(p string) string {
+		return filepath.Join(root, filepath.FromSlash(p))
+	}
+
+	targets, err := FromPaths([]string{
+		path("services/web/web.Dockerfile"),
+		path("services/api"),
+		path("services/api/Dockerfile"),
+	})
+	if err != nil {
+		t.Fatalf("FromPaths() error = %v", err)
+	}
+	expected := []*Target{
+		{Dir: path("services/web"), Dockerfile: path("services/web/web.Dockerfile"), Dockerignore: path("services/web/.dockerignore")},
+		{Dir: path("services/api"), Dockerfile: path("services/api/Dockerfile"), Dockerignore: path("services/api/Dockerfile.dockerignore")},
+	}
+	if len(targets) != len(expected) {
+		t.Fatalf("FromPaths() returned %d targets; want %d: %+v", len(targets), len(expected), targets)
+	}
+	for i, e := range expected {
+		if *targets[i] != *e {
+			t.Errorf("FromPaths()[%d] = %+v; want %+v", i, targets[i], e)
+		}
+	}
+
+	for _, p := range []string{path("services/worker/Dockerfile"), path("docs")} {
+		if _, err := FromPaths([]string{p}); err == nil {
+			t.Errorf("FromPaths(%q) expected an error", p)
+		}
+	}
+}
+
+func TestEach(t *testing.T) {
+	ts := []*Target{}
+	for i := 0; i < 10; i++ {
+		ts = append(ts, &Target{Dockerfile: fmt.Sprintf("%d/Dockerfile", i)})
+	}
+
+	for _, jobs := range []int{0, 1, 3, 20} {
+		var running, maxRunning atomic.Int32
+		fn := func(tg *Target) (string, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			if tg.Dockerfile == "4/Dockerfile" {
+				return "", errors.New("failed")
+			}
+			return strings.ToUpper(tg.Dockerfile), nil
+		}
+
+		done := []string{}
+		Each(ts, jobs, fn, func(tg *Target, r string, err error) {
+			if err != nil {
+				r = err.Error()
+			}
+			done = append(done, r)
+		})
+
+		expected := []string{}
+		for _, tg := range ts {
+			if tg.Dockerfile == "4/Dockerfile" {
+				expected = append(expected, "failed")
+				continue
+			}
+			expected = append(expected, strings.ToUpper(tg.Dockerfile))
+		}
+		if !slices.Equal(done, expected) {
+			t.Errorf("jobs=%d: expected the results in the order of the targets, got %v", jobs, done)
+		}
+		if limit := int32(max(jobs, 1)); maxRunning.Load() > limit {
+			t.Errorf("jobs=%d: %d targets ran at once", jobs, maxRunning.Load())
+		}
+		if jobs > 1 && maxRunning.Load() < 2 {
+			t.Errorf("jobs=%d: expected targets to run concurrently", jobs)
+		}
+	}
+}
+
+func TestFailures(t *testing.T) {
+	var failures Failures
+	failures.Add(&Target{Dockerfile: "api/Dockerfile"}, errors.New("syntax error"))
+	failures.Add(&Target{Dockerfile: "web/Dockerfile"}, nil)
+	failures.Add(&Target{Dockerfile: "worker/Dockerfile"}, errors.New("no package.json"))
+
+	if len(failures) != 2 {
+		t.Fatalf("expected 2 failures, got %d", len(failures))
+	}
+	expected := "api/Dockerfile: syntax error\nworker/Dockerfile: no package.json"
+	if failures.Error() != expected {
+		t.Errorf("Error() = %q; want %q", failures.Error(), expected)
+	}
+}
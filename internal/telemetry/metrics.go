@@ -0,0 +1,102 @@
+package telemetry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Metrics exported, their values are cumulative since dockershrink started
+const (
+	MetricOperations        = "dockershrink.operations"
+	MetricOperationDuration = "dockershrink.operation.duration"
+	MetricLLMRequests       = "dockershrink.llm.requests"
+	MetricLLMRetries        = "dockershrink.llm.retries"
+	MetricLLMTokens         = "dockershrink.llm.tokens"
+	MetricLLMDuration       = "dockershrink.llm.duration"
+	MetricToolCalls         = "dockershrink.tool.calls"
+	MetricToolDuration      = "dockershrink.tool.duration"
+	MetricRulesApplied      = "dockershrink.rules.applied"
+	MetricBuilds            = "dockershrink.builds"
+	MetricBuildDuration     = "dockershrink.build.duration"
+)
+
+type instrument struct {
+	description string
+	unit        string
+	// histogram instruments record durations, the others are counters
+	histogram bool
+}
+
+var instruments = map[string]instrument{
+	MetricOperations:        {description: "Analyses, optimizations and generations run", unit: "{operation}"},
+	MetricOperationDuration: {description: "Duration of the analyses, optimizations and generations", unit: "s", histogram: true},
+	MetricLLMRequests:       {description: "Requests sent to the LLM", unit: "{request}"},
+	MetricLLMRetries:        {description: "Requests to the LLM retried, eg- after being rate limited", unit: "{retry}"},
+	MetricLLMTokens:         {description: "Tokens used by the LLM, by type: input or output", unit: "{token}"},
+	MetricLLMDuration:       {description: "Latency of the requests to the LLM, including retries", unit: "s", histogram: true},
+	MetricToolCalls:         {description: "Tools called by the LLM", unit: "{call}"},
+	MetricToolDuration:      {description: "Duration of the tools called by the LLM", unit: "s", histogram: true},
+	MetricRulesApplied:      {description: "Rules that changed an image definition, recommended a change or found an issue", unit: "{rule}"},
+	MetricBuilds:            {description: "Images built to verify optimizations", unit: "{build}"},
+	MetricBuildDuration:     {description: "Duration of the image builds", unit: "s", histogram: true},
+}
+
+// durationBounds are the upper bounds of the buckets of the duration histograms, in seconds
+var durationBounds = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// metric is the value of an instrument for a set of attributes
+type metric struct {
+	name  string
+	attrs map[string]any
+	// value of a counter, number of values recorded by a histogram
+	count int64
+	// sum of the values recorded by a histogram
+	sum float64
+	// buckets count the values recorded by a histogram in each of durationBounds, the last one counts the bigger values
+	buckets []int64
+}
+
+// add adds n to a counter
+func (e *Exporter) add(name string, attrs map[string]any, n int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.metric(name, attrs).count += n
+}
+
+// record records a duration in a histogram
+func (e *Exporter) record(name string, attrs map[string]any, d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	m := e.metric(name, attrs)
+	seconds := d.Seconds()
+	m.count++
+	m.sum += seconds
+	bucket := sort.SearchFloat64s(durationBounds, seconds)
+	m.buckets[bucket]++
+}
+
+// metric returns the metric of an instrument for a set of attributes, creating it the first time. e.mu must be held.
+func (e *Exporter) metric(name string, attrs map[string]any) *metric {
+	key := name + "{" + attrsKey(attrs) + "}"
+	m, ok := e.metrics[key]
+	if !ok {
+		m = &metric{name: name, attrs: attrs}
+		if instruments[name].histogram {
+			m.buckets = make([]int64, len(durationBounds)+1)
+		}
+		e.metrics[key] = m
+	}
+	return m
+}
+
+// attrsKey returns the attributes sorted by key, eg- "model=gpt-4o,type=input"
+func attrsKey(attrs map[string]any) string {
+	pairs := make([]string, 0, len(attrs))
+	for k, v := range attrs {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
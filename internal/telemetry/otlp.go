@@ -0,0 +1,285 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scopeName is the instrumentation scope of the spans and metrics
+const scopeName = "github.com/duaraghav8/dockershrink"
+
+// The OTLP messages, encoded in JSON as described by https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding.
+// 64-bit integers are encoded as strings.
+
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpTracesRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue  `json:"attributes,omitempty"`
+	Events            []otlpSpanEvent `json:"events,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpSpanEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+// status codes of spans
+const (
+	statusOK    = 1
+	statusError = 2
+)
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Unit        string         `json:"unit"`
+	Sum         *otlpSum       `json:"sum,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+}
+
+// aggregationCumulative is the temporality of every metric, their values are totals since dockershrink started
+const aggregationCumulative = 2
+
+type otlpSum struct {
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+	DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpNumberDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	AsInt             string         `json:"asInt"`
+}
+
+type otlpHistogram struct {
+	AggregationTemporality int                      `json:"aggregationTemporality"`
+	DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+}
+
+type otlpHistogramDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	Count             string         `json:"count"`
+	Sum               float64        `json:"sum"`
+	BucketCounts      []string       `json:"bucketCounts"`
+	ExplicitBounds    []float64      `json:"explicitBounds"`
+}
+
+func (e *Exporter) resource() otlpResource {
+	attrs := map[string]any{"service.name": e.opts.ServiceName}
+	if e.opts.ServiceVersion != "" {
+		attrs["service.version"] = e.opts.ServiceVersion
+	}
+	return otlpResource{Attributes: keyValues(attrs)}
+}
+
+func (e *Exporter) scope() otlpScope {
+	return otlpScope{Name: scopeName, Version: e.opts.ServiceVersion}
+}
+
+func (e *Exporter) tracesRequest(spans []*span) *otlpTracesRequest {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		o := otlpSpan{
+			TraceID:           s.traceID,
+			SpanID:            s.id,
+			ParentSpanID:      s.parentID,
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: unixNano(s.start),
+			EndTimeUnixNano:   unixNano(s.end),
+			Attributes:        keyValues(s.attrs),
+			Status:            otlpStatus{Code: statusOK},
+		}
+		if s.err != nil {
+			o.Status = otlpStatus{Code: statusError, Message: s.err.Error()}
+		}
+		for _, ev := range s.events {
+			o.Events = append(o.Events, otlpSpanEvent{TimeUnixNano: unixNano(ev.time), Name: ev.name, Attributes: keyValues(ev.attrs)})
+		}
+		encoded = append(encoded, o)
+	}
+	return &otlpTracesRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   e.resource(),
+		ScopeSpans: []otlpScopeSpans{{Scope: e.scope(), Spans: encoded}},
+	}}}
+}
+
+func (e *Exporter) metricsRequest(metrics []*metric, now time.Time) *otlpMetricsRequest {
+	// every instrument is sent once, with a data point for each set of attributes
+	byName := map[string][]*metric{}
+	for _, m := range metrics {
+		byName[m.name] = append(byName[m.name], m)
+	}
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	start, end := unixNano(e.start), unixNano(now)
+	encoded := make([]otlpMetric, 0, len(names))
+	for _, name := range names {
+		points := byName[name]
+		sort.Slice(points, func(i, j int) bool { return attrsKey(points[i].attrs) < attrsKey(points[j].attrs) })
+		inst := instruments[name]
+		o := otlpMetric{Name: name, Description: inst.description, Unit: inst.unit}
+		if inst.histogram {
+			o.Histogram = &otlpHistogram{AggregationTemporality: aggregationCumulative}
+			for _, m := range points {
+				buckets := make([]string, len(m.buckets))
+				for i, n := range m.buckets {
+					buckets[i] = strconv.FormatInt(n, 10)
+				}
+				o.Histogram.DataPoints = append(o.Histogram.DataPoints, otlpHistogramDataPoint{
+					Attributes:        keyValues(m.attrs),
+					StartTimeUnixNano: start,
+					TimeUnixNano:      end,
+					Count:             strconv.FormatInt(m.count, 10),
+					Sum:               m.sum,
+					BucketCounts:      buckets,
+					ExplicitBounds:    durationBounds,
+				})
+			}
+		} else {
+			o.Sum = &otlpSum{AggregationTemporality: aggregationCumulative, IsMonotonic: true}
+			for _, m := range points {
+				o.Sum.DataPoints = append(o.Sum.DataPoints, otlpNumberDataPoint{
+					Attributes:        keyValues(m.attrs),
+					StartTimeUnixNano: start,
+					TimeUnixNano:      end,
+					AsInt:             strconv.FormatInt(m.count, 10),
+				})
+			}
+		}
+		encoded = append(encoded, o)
+	}
+	return &otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     e.resource(),
+		ScopeMetrics: []otlpScopeMetrics{{Scope: e.scope(), Metrics: encoded}},
+	}}}
+}
+
+// post sends an OTLP message to a path under the endpoint, eg- /v1/traces
+func (e *Exporter) post(ctx context.Context, path string, message any) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(e.opts.Endpoint, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.opts.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// keyValues encodes attributes sorted by key
+func keyValues(attrs map[string]any) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		var value map[string]any
+		switch v := attrs[k].(type) {
+		case string:
+			value = map[string]any{"stringValue": v}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		kvs = append(kvs, otlpKeyValue{Key: k, Value: value})
+	}
+	return kvs
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,394 @@
+// Package telemetry exports traces and metrics of dockershrink's operations over OTLP, eg- to monitor "dockershrink serve" in production.
+// Spans and metrics are derived from the progress events of package events: analyses, LLM requests, tool calls, rules and image builds.
+// They're encoded as JSON and sent over HTTP, which OpenTelemetry collectors and most tracing vendors accept.
+package telemetry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/pkg/events"
+)
+
+// The standard OpenTelemetry environment variables dockershrink reads
+const (
+	EnvEndpoint    = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	EnvHeaders     = "OTEL_EXPORTER_OTLP_HEADERS"
+	EnvServiceName = "OTEL_SERVICE_NAME"
+	EnvDisabled    = "OTEL_SDK_DISABLED"
+)
+
+const (
+	DefaultServiceName = "dockershrink"
+	// DefaultInterval is how often the spans and metrics are exported, they're also exported on Shutdown
+	DefaultInterval = 10 * time.Second
+)
+
+// Options configure the exporter
+type Options struct {
+	// Endpoint is the base URL of the OTLP/HTTP receiver, eg- http://localhost:4318.
+	// Traces are sent to /v1/traces and metrics to /v1/metrics under it.
+	Endpoint string
+	// Headers are sent with every export, eg- the API key of a tracing vendor
+	Headers        map[string]string
+	ServiceName    string
+	ServiceVersion string
+	// Interval between exports, DefaultInterval if 0
+	Interval time.Duration
+	// Client sends the exports, http.DefaultClient if nil
+	Client *http.Client
+	// Logger is warned when exports fail, failures aren't reported if it's nil
+	Logger *log.Logger
+}
+
+// OptionsFromEnv reads the options from the standard OpenTelemetry environment variables.
+// nil is returned if OTEL_EXPORTER_OTLP_ENDPOINT isn't set or OTEL_SDK_DISABLED is true.
+func OptionsFromEnv() (*Options, error) {
+	endpoint := strings.TrimSpace(os.Getenv(EnvEndpoint))
+	if endpoint == "" {
+		return nil, nil
+	}
+	if disabled, _ := strconv.ParseBool(os.Getenv(EnvDisabled)); disabled {
+		return nil, nil
+	}
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%s must be an http or https URL, eg- http://localhost:4318, got %q", EnvEndpoint, endpoint)
+	}
+	headers, err := parseHeaders(os.Getenv(EnvHeaders))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", EnvHeaders, err)
+	}
+	serviceName := os.Getenv(EnvServiceName)
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	return &Options{Endpoint: endpoint, Headers: headers, ServiceName: serviceName}, nil
+}
+
+// parseHeaders parses a list of headers like "api-key=secret,x-team=platform", the values are URL-encoded
+func parseHeaders(s string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", pair)
+		}
+		value, err := url.QueryUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s: %w", key, err)
+		}
+		headers[key] = value
+	}
+	return headers, nil
+}
+
+// Exporter collects the spans and metrics of the traces it starts and exports them periodically.
+// A nil *Exporter is valid and discards everything, so that callers don't have to check whether telemetry is enabled.
+type Exporter struct {
+	opts  *Options
+	start time.Time
+
+	mu      sync.Mutex
+	spans   []*span
+	metrics map[string]*metric
+	// failing is set once an export failed, so that the logger is only warned again after an export succeeds
+	failing bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates an exporter and starts exporting in the background, call Shutdown to stop it
+func New(opts *Options) *Exporter {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.ServiceName == "" {
+		opts.ServiceName = DefaultServiceName
+	}
+	e := &Exporter{
+		opts:    opts,
+		start:   time.Now(),
+		metrics: map[string]*metric{},
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+func (e *Exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), e.opts.Interval)
+			e.report(e.flush(ctx))
+			cancel()
+		}
+	}
+}
+
+// Shutdown stops the background exports and exports the spans and metrics collected since the last one.
+// Traces still running aren't exported, End them first.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	if e == nil {
+		return nil
+	}
+	close(e.stop)
+	<-e.done
+	return e.flush(ctx)
+}
+
+func (e *Exporter) report(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err == nil {
+		e.failing = false
+		return
+	}
+	if !e.failing && e.opts.Logger != nil {
+		e.opts.Logger.Warnf("* Failed to export telemetry to %s: %v", e.opts.Endpoint, err)
+	}
+	e.failing = true
+}
+
+// flush exports the ended spans and the current value of every metric
+func (e *Exporter) flush(ctx context.Context) error {
+	e.mu.Lock()
+	spans := e.spans
+	e.spans = nil
+	metrics := make([]*metric, 0, len(e.metrics))
+	for _, m := range e.metrics {
+		copied := *m
+		copied.buckets = append([]int64(nil), m.buckets...)
+		metrics = append(metrics, &copied)
+	}
+	e.mu.Unlock()
+
+	now := time.Now()
+	var errs []error
+	if len(spans) > 0 {
+		if err := e.post(ctx, "/v1/traces", e.tracesRequest(spans)); err != nil {
+			errs = append(errs, fmt.Errorf("failed to export %d span(s): %w", len(spans), err))
+		}
+	}
+	if len(metrics) > 0 {
+		if err := e.post(ctx, "/v1/metrics", e.metricsRequest(metrics, now)); err != nil {
+			errs = append(errs, fmt.Errorf("failed to export metrics: %w", err))
+		}
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// Trace starts a trace whose root span has the given name, eg- the command being run or the request being served.
+// Its Handler turns the events of the operations run for it into spans and metrics.
+func (e *Exporter) Trace(name string, kind SpanKind, attrs map[string]any) *Trace {
+	if e == nil {
+		return nil
+	}
+	t := &Trace{e: e, id: newID(16), tools: map[string][]*span{}, builds: map[string]*span{}}
+	t.root = t.startSpan(name, kind, nil, attrs)
+	return t
+}
+
+// Trace is the trace of a command or request
+type Trace struct {
+	e    *Exporter
+	id   string
+	root *span
+
+	mu sync.Mutex
+	// analyses are the operations running, the spans of LLM requests, tool calls and builds are children of the last one
+	analyses []*span
+	tools    map[string][]*span
+	builds   map[string]*span
+}
+
+// End ends the root span of the trace, err is set if the command or request failed
+func (t *Trace) End(err error) {
+	if t == nil {
+		return
+	}
+	t.e.endSpan(t.root, time.Now(), err)
+}
+
+// Handler returns the handler the events of the operations run for the trace are sent to, nil if t is nil.
+// It's safe to use from several goroutines, eg- when Dockerfiles are analyzed in parallel.
+func (t *Trace) Handler() events.Handler {
+	if t == nil {
+		return nil
+	}
+	return t.handle
+}
+
+func (t *Trace) handle(ev events.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+
+	switch ev := ev.(type) {
+	case events.AnalysisStarted:
+		attrs := map[string]any{"dockershrink.operation": string(ev.Operation)}
+		if ev.Goal != "" {
+			attrs["dockershrink.goal"] = ev.Goal
+		}
+		if ev.Dockerfile != "" {
+			attrs["dockershrink.dockerfile"] = ev.Dockerfile
+		}
+		t.analyses = append(t.analyses, t.startSpan("dockershrink."+string(ev.Operation), SpanKindInternal, t.root, attrs))
+
+	case events.AnalysisFinished:
+		// the last operation of the kind is the one finishing, unless operations of the same kind run in parallel
+		for i := len(t.analyses) - 1; i >= 0; i-- {
+			s := t.analyses[i]
+			if s.attrs["dockershrink.operation"] != string(ev.Operation) {
+				continue
+			}
+			t.analyses = append(t.analyses[:i], t.analyses[i+1:]...)
+			t.e.endSpan(s, now, ev.Err)
+			t.e.add(MetricOperations, map[string]any{"operation": string(ev.Operation), "error": ev.Err != nil}, 1)
+			t.e.record(MetricOperationDuration, map[string]any{"operation": string(ev.Operation)}, now.Sub(s.start))
+			break
+		}
+
+	case events.LLMRequestFinished:
+		s := t.startSpan("chat "+ev.Model, SpanKindClient, t.parent(), map[string]any{
+			"gen_ai.request.model":       ev.Model,
+			"gen_ai.usage.input_tokens":  ev.PromptTokens,
+			"gen_ai.usage.output_tokens": ev.CompletionTokens,
+			"dockershrink.llm.retries":   ev.Retries,
+		})
+		s.start = now.Add(-ev.Duration)
+		t.e.endSpan(s, now, ev.Err)
+		t.e.add(MetricLLMRequests, map[string]any{"model": ev.Model, "error": ev.Err != nil}, 1)
+		t.e.add(MetricLLMRetries, map[string]any{"model": ev.Model}, int64(ev.Retries))
+		t.e.add(MetricLLMTokens, map[string]any{"model": ev.Model, "type": "input"}, ev.PromptTokens)
+		t.e.add(MetricLLMTokens, map[string]any{"model": ev.Model, "type": "output"}, ev.CompletionTokens)
+		t.e.record(MetricLLMDuration, map[string]any{"model": ev.Model}, ev.Duration)
+
+	case events.ToolCallStarted:
+		s := t.startSpan("execute_tool "+ev.Tool, SpanKindInternal, t.parent(), map[string]any{"gen_ai.tool.name": ev.Tool})
+		t.tools[ev.Tool] = append(t.tools[ev.Tool], s)
+
+	case events.ToolCallFinished:
+		started := t.tools[ev.Tool]
+		if len(started) == 0 {
+			return
+		}
+		s := started[len(started)-1]
+		t.tools[ev.Tool] = started[:len(started)-1]
+		t.e.endSpan(s, now, ev.Err)
+		t.e.add(MetricToolCalls, map[string]any{"tool": ev.Tool, "error": ev.Err != nil}, 1)
+		t.e.record(MetricToolDuration, map[string]any{"tool": ev.Tool}, now.Sub(s.start))
+
+	case events.RuleApplied:
+		if s := t.parent(); s != t.root {
+			s.events = append(s.events, &spanEvent{time: now, name: "rule_applied", attrs: map[string]any{
+				"dockershrink.rule":    ev.Rule,
+				"dockershrink.changed": ev.Changed,
+			}})
+		}
+		t.e.add(MetricRulesApplied, map[string]any{"rule": ev.Rule, "changed": ev.Changed}, 1)
+
+	case events.BuildStarted:
+		t.builds[ev.Image] = t.startSpan("docker build "+ev.Image, SpanKindClient, t.parent(), map[string]any{"dockershrink.image": ev.Image})
+
+	case events.BuildFinished:
+		s, ok := t.builds[ev.Image]
+		if !ok {
+			return
+		}
+		delete(t.builds, ev.Image)
+		if ev.Size > 0 {
+			s.attrs["dockershrink.image.size"] = ev.Size
+		}
+		t.e.endSpan(s, now, ev.Err)
+		t.e.add(MetricBuilds, map[string]any{"image": ev.Image, "error": ev.Err != nil}, 1)
+		t.e.record(MetricBuildDuration, map[string]any{"image": ev.Image}, now.Sub(s.start))
+	}
+}
+
+// parent returns the span the spans of an event are children of
+func (t *Trace) parent() *span {
+	if len(t.analyses) > 0 {
+		return t.analyses[len(t.analyses)-1]
+	}
+	return t.root
+}
+
+func (t *Trace) startSpan(name string, kind SpanKind, parent *span, attrs map[string]any) *span {
+	s := &span{traceID: t.id, id: newID(8), name: name, kind: kind, start: time.Now(), attrs: attrs}
+	if s.attrs == nil {
+		s.attrs = map[string]any{}
+	}
+	if parent != nil {
+		s.parentID = parent.id
+	}
+	return s
+}
+
+// SpanKind is the kind of a span in OTLP
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+type span struct {
+	traceID, id, parentID string
+	name                  string
+	kind                  SpanKind
+	start, end            time.Time
+	attrs                 map[string]any
+	events                []*spanEvent
+	err                   error
+}
+
+type spanEvent struct {
+	time  time.Time
+	name  string
+	attrs map[string]any
+}
+
+// endSpan ends a span, which is exported with the next batch
+func (e *Exporter) endSpan(s *span, end time.Time, err error) {
+	s.end = end
+	s.err = err
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, s)
+}
+
+// newID returns a random trace or span ID of n bytes, hex-encoded
+func newID(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
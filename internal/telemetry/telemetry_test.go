@@ -0,0 +1,197 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/pkg/events"
+)
+
+func TestOptionsFromEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected *Options
+		wantErr  bool
+	}{
+		{name: "not configured", env: map[string]string{}},
+		{
+			name:     "endpoint",
+			env:      map[string]string{EnvEndpoint: "http://localhost:4318"},
+			expected: &Options{Endpoint: "http://localhost:4318", Headers: map[string]string{}, ServiceName: DefaultServiceName},
+		},
+		{
+			name: "headers and service name",
+			env: map[string]string{
+				EnvEndpoint:    "https://otlp.example.com",
+				EnvHeaders:     "api-key=s%3Dcret, x-team=platform",
+				EnvServiceName: "dockershrink-ci",
+			},
+			expected: &Options{
+				Endpoint:    "https://otlp.example.com",
+				Headers:     map[string]string{"api-key": "s=cret", "x-team": "platform"},
+				ServiceName: "dockershrink-ci",
+			},
+		},
+		{name: "disabled", env: map[string]string{EnvEndpoint: "http://localhost:4318", EnvDisabled: "true"}},
+		{name: "invalid endpoint", env: map[string]string{EnvEndpoint: "localhost:4318"}, wantErr: true},
+		{name: "invalid headers", env: map[string]string{EnvEndpoint: "http://localhost:4318", EnvHeaders: "api-key"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{EnvEndpoint, EnvHeaders, EnvServiceName, EnvDisabled} {
+				t.Setenv(name, tt.env[name])
+			}
+			opts, err := OptionsFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OptionsFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(opts, tt.expected) {
+				t.Errorf("OptionsFromEnv() = %+v; want %+v", opts, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExporter(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		traces  otlpTracesRequest
+		metrics otlpMetricsRequest
+		headers http.Header
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		headers = r.Header
+		var err error
+		switch r.URL.Path {
+		case "/v1/traces":
+			err = json.NewDecoder(r.Body).Decode(&traces)
+		case "/v1/metrics":
+			err = json.NewDecoder(r.Body).Decode(&metrics)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+		if err != nil {
+			t.Errorf("invalid OTLP message sent to %s: %v", r.URL.Path, err)
+		}
+	}))
+	defer collector.Close()
+
+	e := New(&Options{Endpoint: collector.URL, Headers: map[string]string{"api-key": "secret"}, ServiceVersion: "1.2.3", Interval: time.Hour})
+	trace := e.Trace("dockershrink optimize", SpanKindInternal, nil)
+	h := trace.Handler()
+	h.Emit(events.AnalysisStarted{Operation: events.OperationOptimize, Goal: "size", Dockerfile: "Dockerfile"})
+	h.Emit(events.RuleApplied{Rule: "DS001", Changed: true})
+	h.Emit(events.LLMRequestFinished{Model: "gpt-4o", PromptTokens: 1000, CompletionTokens: 200, Retries: 1, Duration: 2 * time.Second})
+	h.Emit(events.ToolCallStarted{Tool: "read_files"})
+	h.Emit(events.ToolCallFinished{Tool: "read_files", Err: errors.New("no such file")})
+	h.Emit(events.LLMRequestFinished{Model: "gpt-4o", PromptTokens: 1500, CompletionTokens: 300, Duration: time.Second})
+	h.Emit(events.BuildStarted{Image: "optimized"})
+	h.Emit(events.BuildFinished{Image: "optimized", Size: 1 << 20})
+	h.Emit(events.AnalysisFinished{Operation: events.OperationOptimize})
+	trace.End(nil)
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if headers.Get("api-key") != "secret" {
+		t.Errorf("expected the headers to be sent, got %v", headers)
+	}
+
+	// every span is a child of the optimization, which is a child of the root span
+	spans := map[string]otlpSpan{}
+	for _, s := range traces.ResourceSpans[0].ScopeSpans[0].Spans {
+		spans[s.Name] = s
+	}
+	parents := map[string]string{
+		"dockershrink optimize":   "",
+		"dockershrink.optimize":   "dockershrink optimize",
+		"chat gpt-4o":             "dockershrink.optimize",
+		"execute_tool read_files": "dockershrink.optimize",
+		"docker build optimized":  "dockershrink.optimize",
+	}
+	if len(spans) != len(parents) {
+		t.Fatalf("expected %d spans, got %v", len(parents), spans)
+	}
+	for name, parent := range parents {
+		s, ok := spans[name]
+		if !ok {
+			t.Fatalf("span %q wasn't exported", name)
+		}
+		if s.TraceID != spans["dockershrink optimize"].TraceID {
+			t.Errorf("span %q belongs to another trace", name)
+		}
+		if parent != "" && s.ParentSpanID != spans[parent].SpanID {
+			t.Errorf("expected span %q to be a child of %q", name, parent)
+		}
+	}
+	if s := spans["execute_tool read_files"]; s.Status.Code != statusError || s.Status.Message != "no such file" {
+		t.Errorf("expected the failed tool call to have an error status, got %+v", s.Status)
+	}
+	if s := spans["dockershrink.optimize"]; len(s.Events) != 1 || s.Events[0].Name != "rule_applied" {
+		t.Errorf("expected the rule applied to be an event of the optimization span, got %+v", s.Events)
+	}
+
+	values := map[string]string{}
+	for _, m := range metrics.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		if m.Sum != nil {
+			for _, p := range m.Sum.DataPoints {
+				values[m.Name+"{"+attributesKey(p.Attributes)+"}"] = p.AsInt
+			}
+		}
+		if m.Histogram != nil {
+			for _, p := range m.Histogram.DataPoints {
+				values[m.Name+"{"+attributesKey(p.Attributes)+"}"] = p.Count
+			}
+		}
+	}
+	expected := map[string]string{
+		"dockershrink.operations{error=false,operation=optimize}": "1",
+		"dockershrink.operation.duration{operation=optimize}":     "1",
+		"dockershrink.llm.requests{error=false,model=gpt-4o}":     "2",
+		"dockershrink.llm.retries{model=gpt-4o}":                  "1",
+		"dockershrink.llm.tokens{model=gpt-4o,type=input}":        "2500",
+		"dockershrink.llm.tokens{model=gpt-4o,type=output}":       "500",
+		"dockershrink.llm.duration{model=gpt-4o}":                 "2",
+		"dockershrink.tool.calls{error=true,tool=read_files}":     "1",
+		"dockershrink.tool.duration{tool=read_files}":             "1",
+		"dockershrink.rules.applied{changed=true,rule=DS001}":     "1",
+		"dockershrink.builds{error=false,image=optimized}":        "1",
+		"dockershrink.build.duration{image=optimized}":            "1",
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("metrics = %v; want %v", values, expected)
+	}
+}
+
+func TestExporter_Nil(t *testing.T) {
+	var e *Exporter
+	trace := e.Trace("dockershrink analyze", SpanKindInternal, nil)
+	trace.Handler().Emit(events.AnalysisStarted{Operation: events.OperationAnalyze})
+	trace.End(nil)
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() of a nil exporter failed: %v", err)
+	}
+}
+
+// attributesKey returns the encoded attributes like attrsKey does
+func attributesKey(kvs []otlpKeyValue) string {
+	attrs := map[string]any{}
+	for _, kv := range kvs {
+		for _, v := range kv.Value {
+			attrs[kv.Key] = v
+		}
+	}
+	return attrsKey(attrs)
+}
@@ -0,0 +1,121 @@
+package tree
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/ownership"
+)
+
+// Patterns match slash-separated paths using the gitignore syntax, eg- "vendor/", "*.log" or "!keep.log".
+// Like in .gitignore, the last pattern that matches a path decides whether it's matched.
+type Patterns struct {
+	// base is the directory the patterns are relative to, "" for the root of the tree
+	base  string
+	rules []*patternRule
+}
+
+type patternRule struct {
+	rule *ownership.Rule
+	// dirRule matches the directories themselves, rule only matches the paths inside them if the
+	// pattern ends with a slash
+	dirRule *ownership.Rule
+	negated bool
+	// literal is the part of the pattern before its first wildcard, eg- "vendor/lib/" for "vendor/lib/*.go"
+	literal string
+}
+
+// NewPatterns compiles patterns relative to the directory base of the tree, "" for its root.
+// Empty lines and comments starting with "#" are skipped.
+func NewPatterns(base string, patterns []string) (*Patterns, error) {
+	ps := &Patterns{base: base}
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+		negated := strings.HasPrefix(p, "!")
+		p = strings.TrimPrefix(p, "!")
+		r, err := ownership.NewRule(p, nil)
+		if err != nil {
+			return nil, err
+		}
+		dirRule := r
+		if strings.HasSuffix(p, "/") {
+			if dirRule, err = ownership.NewRule(strings.TrimSuffix(p, "/"), nil); err != nil {
+				return nil, err
+			}
+		}
+		literal := strings.TrimPrefix(p, "/")
+		if i := strings.IndexAny(literal, "*?["); i >= 0 {
+			literal = literal[:i]
+		}
+		ps.rules = append(ps.rules, &patternRule{rule: r, dirRule: dirRule, negated: negated, literal: literal})
+	}
+	return ps, nil
+}
+
+// readGitignore compiles the .gitignore file of a directory of the tree, nil if there's none
+func readGitignore(absDir, base string) (*Patterns, error) {
+	f, err := os.Open(absDir + string(os.PathSeparator) + ".gitignore")
+	if err != nil {
+		return nil, nil
+	}
+	defer f.Close()
+	lines := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	ps, err := NewPatterns(base, lines)
+	if err != nil {
+		return nil, fmt.Errorf("invalid .gitignore in %s: %w", absDir, err)
+	}
+	return ps, nil
+}
+
+// Match returns whether the path is matched, and whether any pattern decided it. dir tells if the path is a
+// directory, so that patterns like "build/" only match directories.
+func (ps *Patterns) Match(p string, dir bool) (matched bool, decided bool) {
+	if ps == nil {
+		return false, false
+	}
+	rel := p
+	if ps.base != "" {
+		if !strings.HasPrefix(p, ps.base+"/") {
+			return false, false
+		}
+		rel = strings.TrimPrefix(p, ps.base+"/")
+	}
+	for _, r := range ps.rules {
+		rule := r.rule
+		if dir {
+			rule = r.dirRule
+		}
+		if rule.Matches(rel) {
+			matched, decided = !r.negated, true
+		}
+	}
+	return matched, decided
+}
+
+// MayMatchInside returns true if a pattern that names a path, eg- "vendor/lib/", could match a path inside
+// the directory. Patterns without a slash, eg- "*.md", match at any depth and aren't searched for in
+// directories left out of the tree.
+func (ps *Patterns) MayMatchInside(dir string) bool {
+	if ps == nil {
+		return false
+	}
+	rel := dir
+	if ps.base != "" {
+		rel = strings.TrimPrefix(dir, ps.base+"/")
+	}
+	for _, r := range ps.rules {
+		if !r.negated && strings.HasPrefix(r.literal, rel+"/") {
+			return true
+		}
+	}
+	return false
+}
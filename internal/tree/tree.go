@@ -1,27 +1,77 @@
 package tree
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
 )
 
+// Options control which files and directories a tree lists
+type Options struct {
+	// IgnoreDirs are the names of directories whose contents are left out, eg- "node_modules".
+	// The directories themselves are listed as truncated.
+	IgnoreDirs []string
+	// Gitignore leaves out the paths the .gitignore files of the directory and its subdirectories ignore
+	Gitignore bool
+	// Exclude are patterns in the gitignore syntax of paths left out of the tree, eg- "vendor/"
+	Exclude []string
+	// Include are patterns of paths listed even if IgnoreDirs, .gitignore or Exclude leave them out,
+	// eg- "vendor/acme/". Only patterns naming a path are searched for inside directories left out.
+	Include []string
+	// Ignored returns true for the slash-separated paths, relative to the directory, that are never listed
+	Ignored func(path string) bool
+	// MaxFileSize leaves out files larger than it, 0 for no limit
+	MaxFileSize int64
+	// SkipBinary leaves out files whose content isn't text
+	SkipBinary bool
+}
+
+// binarySniffSize is how much of a file is read to tell if it's binary, the same as git does
+const binarySniffSize = 8000
+
 // BuildTreeWithIgnore walks the given directory path, builds a
 // text-based tree representation, and returns it as a string.
 // It will skip any directories that match names in `ignoreDirs`.
 func BuildTreeWithIgnore(dirPath string, ignoreDirs []string) (string, error) {
+	return BuildTree(dirPath, Options{IgnoreDirs: ignoreDirs})
+}
+
+// BuildTree walks the given directory path and returns a text-based tree representation of the files
+// and directories the options don't leave out. Directories that hold files left out for being binary
+// or too large say how many of them aren't shown.
+// Symbolic links are listed along with their target, eg- "shared/ -> ../../packages/shared", and aren't
+// followed, since their targets are either listed where they are or can't be read from outside the
+// directory. Git submodules are marked as such, and directories mounted inside themselves aren't walked again.
+func BuildTree(dirPath string, opts Options) (string, error) {
 	// Resolve the absolute path
 	absPath, err := filepath.Abs(dirPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to resolve absolute path: %w", err)
 	}
 
+	b := &builder{opts: opts, root: absPath}
+	if real, err := filepath.EvalSymlinks(absPath); err == nil {
+		b.root = real
+	}
+	b.submodules = readGitmodules(absPath)
+	if b.exclude, err = NewPatterns("", opts.Exclude); err != nil {
+		return "", fmt.Errorf("invalid exclude pattern: %w", err)
+	}
+	if b.include, err = NewPatterns("", opts.Include); err != nil {
+		return "", fmt.Errorf("invalid include pattern: %w", err)
+	}
+
 	var sb strings.Builder
+	sb.WriteString(".\n")
 
 	// Kick off our recursive build from the top-level directory.
-	err = buildTree(absPath, ignoreDirs, "", true, &sb)
+	err = b.buildTree(absPath, "", nil, "", false, &sb)
 	if err != nil {
 		return "", err
 	}
@@ -29,33 +79,112 @@ func BuildTreeWithIgnore(dirPath string, ignoreDirs []string) (string, error) {
 	return sb.String(), nil
 }
 
+type builder struct {
+	opts             Options
+	exclude, include *Patterns
+	// root is the real path of the directory, with its symbolic links resolved
+	root string
+	// submodules are the paths of the git submodules listed in .gitmodules
+	submodules map[string]bool
+	// ancestors are the directories being walked, to tell if a directory is mounted inside itself
+	ancestors []os.FileInfo
+}
+
 // buildTree is a recursive helper that constructs the tree-like structure.
 //
-//	dirPath:    the path of the directory to explore
-//	ignoreDirs: list of directory names to skip
-//	prefix:     current "ASCII tree" prefix for nesting
-//	isRoot:     indicates if this is the top-level call
-//	sb:         pointer to a strings.Builder to accumulate the output
-func buildTree(dirPath string, ignoreDirs []string, prefix string, isRoot bool, sb *strings.Builder) error {
+//	dirPath:      the path of the directory to explore
+//	relPath:      the slash-separated path of the directory relative to the root, "" for the root
+//	gitignores:   the .gitignore files of the directory's parents
+//	prefix:       current "ASCII tree" prefix for nesting
+//	onlyIncluded: only list the paths of the Include patterns, since the directory itself is left out
+//	sb:           pointer to a strings.Builder to accumulate the output
+func (b *builder) buildTree(dirPath, relPath string, gitignores []*Patterns, prefix string, onlyIncluded bool, sb *strings.Builder) error {
 	entries, err := os.ReadDir(dirPath)
 	if err != nil {
 		return err
 	}
+	if info, err := os.Stat(dirPath); err == nil {
+		b.ancestors = append(b.ancestors, info)
+		defer func() { b.ancestors = b.ancestors[:len(b.ancestors)-1] }()
+	}
 
 	// Sort entries for consistent (alphabetical) output
 	sort.Slice(entries, func(i, j int) bool {
 		return entries[i].Name() < entries[j].Name()
 	})
 
-	// If this is the root, add "."
-	// alternatively, add root directory name: `filepath.Base(dirPath)`
-	if isRoot {
-		sb.WriteString(".\n")
+	if b.opts.Gitignore && !onlyIncluded {
+		gi, err := readGitignore(dirPath, relPath)
+		if err != nil {
+			return err
+		}
+		if gi != nil {
+			gitignores = append(gitignores[:len(gitignores):len(gitignores)], gi)
+		}
+	}
+
+	// decide what to list before writing anything, so that the last entry gets the right connector
+	type listed struct {
+		entry fs.DirEntry
+		// truncated lists a directory without its contents
+		truncated    bool
+		onlyIncluded bool
+		// label replaces the name of the entry, eg- for symbolic links
+		label string
+	}
+	visible := []listed{}
+	omitted := 0
+	for _, entry := range entries {
+		p := path.Join(relPath, entry.Name())
+		isDir := entry.IsDir()
+		if b.opts.Ignored != nil && b.opts.Ignored(p) {
+			continue
+		}
+		if entry.Name() == ".git" && !isDir {
+			// the .git file of a submodule or worktree only points to its repository
+			continue
+		}
+		if included, _ := b.include.Match(p, isDir); included {
+			visible = append(visible, listed{entry: entry})
+			continue
+		}
+		// a directory that's left out is still searched for the paths to include
+		leftOut := onlyIncluded || b.excluded(p, isDir, gitignores)
+		if isDir && contains(b.opts.IgnoreDirs, entry.Name()) {
+			if b.include.MayMatchInside(p) {
+				visible = append(visible, listed{entry: entry, onlyIncluded: true})
+			} else if !leftOut {
+				visible = append(visible, listed{entry: entry, truncated: true})
+			}
+			continue
+		}
+		if leftOut {
+			if isDir && b.include.MayMatchInside(p) {
+				visible = append(visible, listed{entry: entry, onlyIncluded: true})
+			}
+			continue
+		}
+		if entry.Type()&fs.ModeSymlink != 0 {
+			visible = append(visible, listed{entry: entry, label: b.linkLabel(filepath.Join(dirPath, entry.Name()), entry.Name())})
+			continue
+		}
+		if !isDir && b.skipFile(filepath.Join(dirPath, entry.Name()), entry) {
+			omitted++
+			continue
+		}
+		if isDir {
+			if label, walk := b.dirLabel(filepath.Join(dirPath, entry.Name()), p, entry.Name()); label != "" {
+				visible = append(visible, listed{entry: entry, label: label, truncated: !walk})
+				continue
+			}
+		}
+		visible = append(visible, listed{entry: entry})
 	}
 
 	// Iterate over directory entries
-	for i, entry := range entries {
-		isLast := (i == len(entries)-1)
+	for i, v := range visible {
+		entry := v.entry
+		isLast := (i == len(visible)-1) && omitted == 0
 		connector := "├── "
 		subPrefix := "│   "
 		if isLast {
@@ -63,18 +192,25 @@ func buildTree(dirPath string, ignoreDirs []string, prefix string, isRoot bool,
 			subPrefix = "    "
 		}
 
-		fullPath := filepath.Join(dirPath, entry.Name())
-
-		if entry.IsDir() {
+		if v.label != "" {
+			sb.WriteString(fmt.Sprintf("%s%s%s\n", prefix, connector, v.label))
+			if v.truncated || !entry.IsDir() {
+				continue
+			}
+			err = b.buildTree(filepath.Join(dirPath, entry.Name()), path.Join(relPath, entry.Name()), gitignores, prefix+subPrefix, v.onlyIncluded, sb)
+			if err != nil {
+				return err
+			}
+		} else if entry.IsDir() {
 			// Add the directory name
 			sb.WriteString(fmt.Sprintf("%s%s%s/\n", prefix, connector, entry.Name()))
 
-			if contains(ignoreDirs, entry.Name()) {
+			if v.truncated {
 				// If this is an ignored directory, skip exploring it and let the user know
 				sb.WriteString(fmt.Sprintf("%s%s(truncated)\n", prefix+subPrefix, ""))
 			} else {
 				// Recurse into this directory
-				err = buildTree(fullPath, ignoreDirs, prefix+subPrefix, false, sb)
+				err = b.buildTree(filepath.Join(dirPath, entry.Name()), path.Join(relPath, entry.Name()), gitignores, prefix+subPrefix, v.onlyIncluded, sb)
 				if err != nil {
 					return err
 				}
@@ -84,10 +220,118 @@ func buildTree(dirPath string, ignoreDirs []string, prefix string, isRoot bool,
 			sb.WriteString(fmt.Sprintf("%s%s%s\n", prefix, connector, entry.Name()))
 		}
 	}
+	if omitted > 0 {
+		sb.WriteString(fmt.Sprintf("%s└── (%d binary or large files not shown)\n", prefix, omitted))
+	}
 
 	return nil
 }
 
+// excluded returns true if the Exclude patterns or the .gitignore files leave out the path.
+// Like in git, the .gitignore files of subdirectories take precedence over the ones of their parents.
+func (b *builder) excluded(p string, isDir bool, gitignores []*Patterns) bool {
+	if matched, _ := b.exclude.Match(p, isDir); matched {
+		return true
+	}
+	for i := len(gitignores) - 1; i >= 0; i-- {
+		if matched, decided := gitignores[i].Match(p, isDir); decided {
+			return matched
+		}
+	}
+	return false
+}
+
+// linkLabel returns how a symbolic link is listed, eg- "shared/ -> ../../packages/shared" for a link to a
+// directory. Links that are broken or lead outside the directory of the tree say so.
+func (b *builder) linkLabel(linkPath, name string) string {
+	target, err := os.Readlink(linkPath)
+	if err != nil {
+		return name
+	}
+	info, err := os.Stat(linkPath)
+	if err != nil {
+		return fmt.Sprintf("%s -> %s (broken link)", name, target)
+	}
+	if info.IsDir() {
+		name += "/"
+	}
+	if real, err := filepath.EvalSymlinks(linkPath); err != nil || !within(b.root, real) {
+		return fmt.Sprintf("%s -> %s (outside the project)", name, target)
+	}
+	return fmt.Sprintf("%s -> %s", name, target)
+}
+
+// dirLabel returns how a directory is listed if it's a git submodule, eg- "lib/ (git submodule)", or
+// mounted inside itself, and whether its contents are listed. The label is "" for other directories.
+func (b *builder) dirLabel(dirPath, relPath, name string) (string, bool) {
+	if info, err := os.Stat(dirPath); err == nil {
+		for _, a := range b.ancestors {
+			if os.SameFile(a, info) {
+				return fmt.Sprintf("%s/ (the same directory as one of its parents, not shown again)", name), false
+			}
+		}
+	}
+	// checked out submodules have a .git file pointing to their repository
+	submodule := b.submodules[relPath]
+	if info, err := os.Lstat(filepath.Join(dirPath, ".git")); err == nil && !info.IsDir() {
+		submodule = true
+	}
+	if !submodule {
+		return "", true
+	}
+	if entries, err := os.ReadDir(dirPath); err == nil && len(entries) == 0 {
+		return fmt.Sprintf("%s/ (git submodule, not checked out)", name), false
+	}
+	return fmt.Sprintf("%s/ (git submodule)", name), true
+}
+
+// readGitmodules returns the paths of the submodules listed in the .gitmodules file of the directory
+func readGitmodules(dir string) map[string]bool {
+	content, err := os.ReadFile(filepath.Join(dir, ".gitmodules"))
+	if err != nil {
+		return nil
+	}
+	submodules := map[string]bool{}
+	for _, line := range strings.Split(string(content), "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if found && strings.TrimSpace(key) == "path" {
+			submodules[path.Clean(strings.TrimSpace(value))] = true
+		}
+	}
+	return submodules
+}
+
+// within returns true if the path is the directory or inside it
+func within(dir, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// skipFile returns true if a file is left out of the tree for being too large or binary
+func (b *builder) skipFile(filePath string, entry fs.DirEntry) bool {
+	if b.opts.MaxFileSize > 0 {
+		if info, err := entry.Info(); err == nil && info.Mode().IsRegular() && info.Size() > b.opts.MaxFileSize {
+			return true
+		}
+	}
+	if !b.opts.SkipBinary || !entry.Type().IsRegular() {
+		return false
+	}
+	return isBinary(filePath)
+}
+
+// isBinary returns true if the beginning of the file contains a NUL byte, which text files don't
+func isBinary(filePath string) bool {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	buf := make([]byte, binarySniffSize)
+	n, _ := io.ReadFull(f, buf)
+	return bytes.IndexByte(buf[:n], 0) >= 0
+}
+
 // contains checks if 'item' is in the string slice 'slice'.
 func contains(slice []string, item string) bool {
 	for _, v := range slice {
@@ -1,6 +1,11 @@
 package tree
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
 
 func TestContains(t *testing.T) {
 	tests := []struct {
@@ -50,3 +55,185 @@ func TestContains(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildTree(t *testing.T) {
+	files := map[string]string{
+		".gitignore":              "dist/\n*.log\n!keep.log\n",
+		"src/index.js":            "console.log('hi')\n",
+		"src/.gitignore":          "generated/\n",
+		"src/generated/types.js":  "",
+		"src/logo.png":            "\x89PNG\r\n\x1a\n\x00\x00",
+		"dist/index.js":           "",
+		"debug.log":               "",
+		"keep.log":                "",
+		"data/large.json":         strings.Repeat("x", 2048),
+		"vendor/acme/lib.js":      "",
+		"vendor/other/lib.js":     "",
+		"node_modules/a/index.js": "",
+	}
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		expected string
+	}{
+		{
+			name: "everything",
+			opts: Options{IgnoreDirs: []string{"node_modules"}},
+			expected: `.
+├── .gitignore
+├── data/
+│   └── large.json
+├── debug.log
+├── dist/
+│   └── index.js
+├── keep.log
+├── node_modules/
+│   (truncated)
+├── src/
+│   ├── .gitignore
+│   ├── generated/
+│   │   └── types.js
+│   ├── index.js
+│   └── logo.png
+└── vendor/
+    ├── acme/
+    │   └── lib.js
+    └── other/
+        └── lib.js
+`,
+		},
+		{
+			name: "gitignore, excludes and skipped files",
+			opts: Options{
+				IgnoreDirs:  []string{"node_modules"},
+				Gitignore:   true,
+				Exclude:     []string{"vendor/"},
+				Include:     []string{"vendor/acme/", "node_modules/a/"},
+				MaxFileSize: 1024,
+				SkipBinary:  true,
+			},
+			expected: `.
+├── .gitignore
+├── data/
+│   └── (1 binary or large files not shown)
+├── keep.log
+├── node_modules/
+│   └── a/
+│       └── index.js
+├── src/
+│   ├── .gitignore
+│   ├── index.js
+│   └── (1 binary or large files not shown)
+└── vendor/
+    └── acme/
+        └── lib.js
+`,
+		},
+		{
+			name: "ignored by the configuration",
+			opts: Options{Gitignore: true, IgnoreDirs: []string{"node_modules"}, Ignored: func(p string) bool { return strings.HasPrefix(p, "vendor") || p == "data" }},
+			expected: `.
+├── .gitignore
+├── keep.log
+├── node_modules/
+│   (truncated)
+└── src/
+    ├── .gitignore
+    ├── index.js
+    └── logo.png
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuildTree(dir, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestBuildTree_LinksAndSubmodules(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "repo")
+	files := map[string]string{
+		".gitmodules":                  "[submodule \"libs/ui\"]\n\tpath = libs/ui\n\turl = https://example.com/ui.git\n[submodule \"libs/empty\"]\n\tpath = libs/empty\n",
+		"libs/ui/.git":                 "gitdir: ../../.git/modules/libs/ui\n",
+		"libs/ui/index.js":             "",
+		"libs/vendored/.git":           "gitdir: ../../.git/modules/libs/vendored\n",
+		"libs/vendored/lib.js":         "",
+		"packages/app/index.js":        "",
+		"packages/shared/util.js":      "",
+		"../outside/secret.txt":        "",
+		"packages/app/node_modules/.x": "",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "libs", "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		"packages/app/shared": "../shared",
+		"packages/app/self":   "..",
+		"packages/app/out":    "../../../outside",
+		"packages/app/gone":   "missing.js",
+		"main.js":             "packages/app/index.js",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			t.Skipf("symbolic links aren't supported: %v", err)
+		}
+	}
+
+	got, err := BuildTree(dir, Options{IgnoreDirs: []string{"node_modules"}})
+	if err != nil {
+		t.Fatalf("BuildTree() failed: %v", err)
+	}
+	expected := `.
+├── .gitmodules
+├── libs/
+│   ├── empty/ (git submodule, not checked out)
+│   ├── ui/ (git submodule)
+│   │   └── index.js
+│   └── vendored/ (git submodule)
+│       └── lib.js
+├── main.js -> packages/app/index.js
+└── packages/
+    ├── app/
+    │   ├── gone -> missing.js (broken link)
+    │   ├── index.js
+    │   ├── node_modules/
+    │   │   (truncated)
+    │   ├── out/ -> ../../../outside (outside the project)
+    │   ├── self/ -> ..
+    │   └── shared/ -> ../shared
+    └── shared/
+        └── util.js
+`
+	if got != expected {
+		t.Errorf("BuildTree() =\n%s\nwant:\n%s", got, expected)
+	}
+}
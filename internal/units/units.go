@@ -0,0 +1,22 @@
+// Package units formats sizes for people, so that a size reads the same in every output of dockershrink:
+// the terminal, reports, badges and the findings of rules.
+package units
+
+import "fmt"
+
+// FormatBytes returns a human-readable size in binary units, eg- "12.3 MB" for 12.3 MiB
+func FormatBytes(b int64) string {
+	if b < 0 {
+		return "-" + FormatBytes(-b)
+	}
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,23 @@
+package units
+
+import "testing"
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		bytes    int64
+		expected string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KB"},
+		{1536, "1.5 KB"},
+		{12*1024*1024 + 300*1024, "12.3 MB"},
+		{3 << 30, "3.0 GB"},
+		{-2 << 20, "-2.0 MB"},
+	}
+	for _, tt := range tests {
+		if got := FormatBytes(tt.bytes); got != tt.expected {
+			t.Errorf("FormatBytes(%d) = %q; want %q", tt.bytes, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Package usage reports anonymous usage statistics to the maintainers of dockershrink, if the user opted in with
+// "dockershrink telemetry enable". They help prioritize the rules that matter most.
+//
+// A report is sent once per command and only holds aggregates that don't identify the user or their projects:
+// the command, the version of dockershrink, the OS, the IDs of the built-in rules that fired, the bytes saved
+// and the latency of the LLM. File paths, project names, Dockerfiles, findings and custom rules are never sent,
+// and reports carry no user or machine ID.
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/ai"
+	"github.com/duaraghav8/dockershrink/internal/config"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+	"github.com/duaraghav8/dockershrink/pkg/events"
+)
+
+// Environment variables that turn reporting off whatever the settings are, eg- on shared CI runners
+var disablingEnv = []string{"DO_NOT_TRACK", "DOCKERSHRINK_NO_TELEMETRY"}
+
+// EnvEndpoint overrides the endpoint reports are sent to, eg- to inspect them locally
+const EnvEndpoint = "DOCKERSHRINK_TELEMETRY_ENDPOINT"
+
+// SendTimeout caps how long sending a report may delay the exit of dockershrink
+const SendTimeout = 2 * time.Second
+
+// otherModel stands for the models whose name isn't known, eg- fine-tuned models that are named after their organization
+const otherModel = "other"
+
+// Settings is the user's choice, kept next to their configuration file
+type Settings struct {
+	Enabled bool `json:"enabled"`
+	// UpdatedAt is when the user last enabled or disabled reporting
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SettingsPath returns the path of the settings file, eg- ~/.config/dockershrink/telemetry.json
+func SettingsPath() (string, error) {
+	path, err := config.UserPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "telemetry.json"), nil
+}
+
+// LoadSettings reads the settings file. Reporting is disabled if the file doesn't exist.
+func LoadSettings(path string) (*Settings, error) {
+	s := &Settings{}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(content, s); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Save writes the settings file
+func (s *Settings) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(content, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// DisabledByEnv returns the environment variable turning reporting off, empty if none does
+func DisabledByEnv() string {
+	for _, name := range disablingEnv {
+		if v := os.Getenv(name); v != "" {
+			if disabled, err := strconv.ParseBool(v); err != nil || disabled {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// Report is what's sent for a command. Every field is an aggregate over the whole command.
+type Report struct {
+	Version string `json:"version"`
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+	// Command is the dockershrink command run, eg- "optimize"
+	Command         string  `json:"command"`
+	Success         bool    `json:"success"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	// Operations counts the Dockerfiles analyzed, optimized or generated, by operation
+	Operations map[string]int `json:"operations,omitempty"`
+	// Rules counts the findings and recommendations of the built-in rules, by rule ID
+	Rules map[string]int `json:"rules,omitempty"`
+	// OtherRules counts the other findings and recommendations, eg- of the rules of plugins and organization policies, whose names aren't sent
+	OtherRules int `json:"other_rules,omitempty"`
+	// ChangesApplied counts the changes made to Dockerfiles and .dockerignore files
+	ChangesApplied int `json:"changes_applied,omitempty"`
+	// BytesSaved sums the savings of the optimizations, measured by --verify-build or estimated
+	BytesSaved int64 `json:"bytes_saved,omitempty"`
+	// LLM holds the latency of the LLM, by model
+	LLM map[string]*LLMStats `json:"llm,omitempty"`
+
+	mu    sync.Mutex
+	start time.Time
+}
+
+// LLMStats aggregates the requests sent to a model
+type LLMStats struct {
+	Requests       int   `json:"requests"`
+	Failures       int   `json:"failures"`
+	Retries        int   `json:"retries"`
+	TotalLatencyMS int64 `json:"total_latency_ms"`
+	MaxLatencyMS   int64 `json:"max_latency_ms"`
+}
+
+// NewReport starts the report of a command
+func NewReport(command, version string) *Report {
+	return &Report{
+		Version:    version,
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		Command:    command,
+		Operations: map[string]int{},
+		Rules:      map[string]int{},
+		LLM:        map[string]*LLMStats{},
+		start:      time.Now(),
+	}
+}
+
+// Handler returns the handler aggregating the events of the command into the report, nil if r is nil.
+// It's safe to use from several goroutines.
+func (r *Report) Handler() events.Handler {
+	if r == nil {
+		return nil
+	}
+	return r.handle
+}
+
+func (r *Report) handle(e events.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch e := e.(type) {
+	case events.AnalysisStarted:
+		r.Operations[string(e.Operation)]++
+	case events.RuleApplied:
+		if e.Changed {
+			r.ChangesApplied++
+		}
+		if rule := rules.Lookup(e.Rule); rule != nil {
+			r.Rules[rule.ID]++
+		} else if !e.Changed {
+			r.OtherRules++
+		}
+	case events.LLMRequestFinished:
+		model := e.Model
+		if !ai.HasKnownPrice(model) {
+			model = otherModel
+		}
+		stats, ok := r.LLM[model]
+		if !ok {
+			stats = &LLMStats{}
+			r.LLM[model] = stats
+		}
+		latency := e.Duration.Milliseconds()
+		stats.Requests++
+		stats.Retries += e.Retries
+		stats.TotalLatencyMS += latency
+		stats.MaxLatencyMS = max(stats.MaxLatencyMS, latency)
+		if e.Err != nil {
+			stats.Failures++
+		}
+	}
+}
+
+// AddSavings adds the bytes an optimization saved, negative if the image grew
+func (r *Report) AddSavings(bytes int64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.BytesSaved += bytes
+}
+
+// Finish records the outcome and duration of the command
+func (r *Report) Finish(success bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Success = success
+	r.DurationSeconds = time.Since(r.start).Round(time.Millisecond).Seconds()
+}
+
+// Send posts the report as JSON to the endpoint
+func (r *Report) Send(ctx context.Context, client *http.Client, endpoint string) error {
+	r.mu.Lock()
+	body, err := json.Marshal(r)
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response from %s: %s", endpoint, resp.Status)
+	}
+	return nil
+}
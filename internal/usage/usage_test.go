@@ -0,0 +1,106 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/pkg/events"
+)
+
+func TestSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dockershrink", "telemetry.json")
+	s, err := LoadSettings(path)
+	if err != nil || s.Enabled {
+		t.Fatalf("expected reporting to be disabled without a settings file, got %+v, %v", s, err)
+	}
+	s.Enabled = true
+	if err := s.Save(path); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if s, err = LoadSettings(path); err != nil || !s.Enabled {
+		t.Errorf("expected reporting to be enabled after saving, got %+v, %v", s, err)
+	}
+}
+
+func TestDisabledByEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected string
+	}{
+		{name: "not set", env: map[string]string{}, expected: ""},
+		{name: "DO_NOT_TRACK", env: map[string]string{"DO_NOT_TRACK": "1"}, expected: "DO_NOT_TRACK"},
+		{name: "DO_NOT_TRACK=0", env: map[string]string{"DO_NOT_TRACK": "0"}, expected: ""},
+		{name: "DOCKERSHRINK_NO_TELEMETRY", env: map[string]string{"DOCKERSHRINK_NO_TELEMETRY": "yes"}, expected: "DOCKERSHRINK_NO_TELEMETRY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range disablingEnv {
+				t.Setenv(name, tt.env[name])
+			}
+			if got := DisabledByEnv(); got != tt.expected {
+				t.Errorf("DisabledByEnv() = %q; want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	var received map[string]any
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("invalid report: %v", err)
+		}
+	}))
+	defer collector.Close()
+
+	r := NewReport("optimize", "1.2.3")
+	h := r.Handler()
+	h.Emit(events.AnalysisStarted{Operation: events.OperationOptimize, Dockerfile: "services/secret-project/Dockerfile"})
+	h.Emit(events.RuleApplied{Rule: "DS001", Filepath: "services/secret-project/Dockerfile"})
+	h.Emit(events.RuleApplied{Rule: "ACME001", Title: "Use the Acme base image"})
+	h.Emit(events.RuleApplied{Rule: "create-dockerignore", Changed: true})
+	h.Emit(events.LLMRequestFinished{Model: "gpt-4o-2024-08-06", Retries: 1, Duration: 3 * time.Second})
+	h.Emit(events.LLMRequestFinished{Model: "gpt-4o-2024-08-06", Duration: time.Second, Err: errors.New("rate limited")})
+	h.Emit(events.LLMRequestFinished{Model: "ft:gpt-4o:acme::abc123", Duration: time.Second})
+	r.AddSavings(100 << 20)
+	r.Finish(true)
+	if err := r.Send(context.Background(), http.DefaultClient, collector.URL); err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(r.Rules, map[string]int{"DS001": 1}) || r.OtherRules != 1 || r.ChangesApplied != 1 {
+		t.Errorf("unexpected rules %v, other rules %d, changes %d", r.Rules, r.OtherRules, r.ChangesApplied)
+	}
+	expectedLLM := map[string]*LLMStats{
+		"gpt-4o-2024-08-06": {Requests: 2, Failures: 1, Retries: 1, TotalLatencyMS: 4000, MaxLatencyMS: 3000},
+		otherModel:          {Requests: 1, TotalLatencyMS: 1000, MaxLatencyMS: 1000},
+	}
+	if !reflect.DeepEqual(r.LLM, expectedLLM) {
+		t.Errorf("LLM = %v; want %v", r.LLM, expectedLLM)
+	}
+	// nothing identifying the project or the organization is sent
+	content, _ := json.Marshal(received)
+	for _, leaked := range []string{"secret-project", "ACME", "acme", "Acme"} {
+		if strings.Contains(string(content), leaked) {
+			t.Errorf("the report contains %q: %s", leaked, content)
+		}
+	}
+	if received["command"] != "optimize" || received["bytes_saved"] != float64(100<<20) || received["success"] != true {
+		t.Errorf("unexpected report %v", received)
+	}
+}
+
+func TestReport_Nil(t *testing.T) {
+	var r *Report
+	r.Handler().Emit(events.AnalysisStarted{Operation: events.OperationAnalyze})
+	r.AddSavings(1)
+}
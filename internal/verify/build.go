@@ -0,0 +1,155 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/duaraghav8/dockershrink/internal/docker"
+	"github.com/duaraghav8/dockershrink/pkg/events"
+)
+
+// Definition is the content of a Docker image definition
+type Definition struct {
+	Dockerfile   string
+	Dockerignore string
+	// BuildContexts are the named build contexts the image is built with, as NAME=VALUE
+	BuildContexts []string
+	// Secrets are the secrets the image is built with, as values of docker build's --secret flag
+	Secrets []string
+}
+
+// BuildReport compares the builds of the original and optimized image definitions
+type BuildReport struct {
+	Original  *docker.BuildResult
+	Optimized *docker.BuildResult
+
+	// OriginalSize and OptimizedSize are the image sizes in bytes, 0 if the build failed
+	OriginalSize  int64
+	OptimizedSize int64
+
+	// OriginalLayers and OptimizedLayers are nil if the build failed or its history couldn't be read
+	OriginalLayers  *SizeBreakdown
+	OptimizedLayers *SizeBreakdown
+}
+
+// OptimizedBuilds returns true if the optimized image definition built successfully
+func (r *BuildReport) OptimizedBuilds() bool {
+	return r.Optimized.Err == nil
+}
+
+// Savings returns the number of bytes the optimized image is smaller by.
+// It is negative if the optimized image is bigger and 0 if either build failed.
+func (r *BuildReport) Savings() int64 {
+	if r.OriginalSize == 0 || r.OptimizedSize == 0 {
+		return 0
+	}
+	return r.OriginalSize - r.OptimizedSize
+}
+
+// Cleanup deletes the images built for the report
+func (r *BuildReport) Cleanup(client *docker.Client) {
+	for _, b := range []*docker.BuildResult{r.Original, r.Optimized} {
+		if b != nil && b.ImageID != "" {
+			client.RemoveImage(context.Background(), b.ImageID)
+		}
+	}
+}
+
+// Builds builds the original and optimized image definitions against the same build context
+// and reports whether they succeeded along with the sizes of the resulting images.
+// The images are kept so that they can be tested further, call Cleanup to delete them.
+// The output of both builds is sent to the handler as it's produced.
+func Builds(ctx context.Context, client *docker.Client, contextDir string, original, optimized *Definition, h events.Handler) (*BuildReport, error) {
+	report := &BuildReport{}
+	var err error
+
+	report.Original, report.OriginalSize, err = buildImage(ctx, client, contextDir, original, h, "original")
+	if err != nil {
+		return nil, fmt.Errorf("failed to build original Dockerfile: %w", err)
+	}
+	report.Optimized, report.OptimizedSize, err = buildImage(ctx, client, contextDir, optimized, h, "optimized")
+	if err != nil {
+		report.Cleanup(client)
+		return nil, fmt.Errorf("failed to build optimized Dockerfile: %w", err)
+	}
+
+	// the layer breakdown is informational, so failing to read it doesn't fail verification
+	if report.Original.Err == nil {
+		report.OriginalLayers, _ = Breakdown(ctx, client, report.Original.ImageID, original.Dockerfile)
+	}
+	if report.Optimized.Err == nil {
+		report.OptimizedLayers, _ = Breakdown(ctx, client, report.Optimized.ImageID, optimized.Dockerfile)
+	}
+	return report, nil
+}
+
+// buildImage builds an image definition, reporting the start, output and outcome of the build as events
+func buildImage(ctx context.Context, client *docker.Client, contextDir string, def *Definition, h events.Handler, image string) (*docker.BuildResult, int64, error) {
+	h.Emit(events.BuildStarted{Image: image})
+	result, size, err := build(ctx, client, contextDir, def, buildProgress(h, image))
+	finished := events.BuildFinished{Image: image, Size: size, Err: err}
+	if err == nil && result.Err != nil {
+		finished.Err = result.Err
+	}
+	h.Emit(finished)
+	return result, size, err
+}
+
+// buildProgress returns a function that reports the build output of an image as events
+func buildProgress(h events.Handler, image string) func(string) {
+	if h == nil {
+		return nil
+	}
+	return func(line string) {
+		h.Emit(events.BuildProgress{Image: image, Line: line})
+	}
+}
+
+func build(ctx context.Context, client *docker.Client, contextDir string, def *Definition, progress func(string)) (*docker.BuildResult, int64, error) {
+	dir, err := writeDefinition(def)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	result, err := client.Build(ctx, &docker.BuildOptions{
+		ContextDir:    contextDir,
+		Dockerfile:    filepath.Join(dir, "Dockerfile"),
+		BuildContexts: def.BuildContexts,
+		Secrets:       def.Secrets,
+		Progress:      progress,
+	})
+	if err != nil || result.Err != nil {
+		return result, 0, err
+	}
+
+	size, err := client.ImageSize(ctx, result.ImageID)
+	if err != nil {
+		client.RemoveImage(context.Background(), result.ImageID)
+		return nil, 0, err
+	}
+	return result, size, nil
+}
+
+// writeDefinition writes the image definition to a new temporary directory.
+// The .dockerignore is written as Dockerfile.dockerignore, which BuildKit
+// prefers over the .dockerignore at the root of the build context.
+func writeDefinition(def *Definition) (string, error) {
+	dir, err := os.MkdirTemp("", "dockershrink-verify-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(def.Dockerfile), 0o644); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to write Dockerfile: %w", err)
+	}
+	if def.Dockerignore != "" {
+		if err := os.WriteFile(filepath.Join(dir, "Dockerfile.dockerignore"), []byte(def.Dockerignore), 0o644); err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("failed to write .dockerignore: %w", err)
+		}
+	}
+	return dir, nil
+}
@@ -0,0 +1,49 @@
+package verify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/docker"
+)
+
+func TestWriteDefinition(t *testing.T) {
+	dir, err := writeDefinition(&Definition{Dockerfile: "FROM node:20-alpine\n", Dockerignore: "node_modules\n"})
+	if err != nil {
+		t.Fatalf("writeDefinition() failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, expected := range map[string]string{
+		"Dockerfile":              "FROM node:20-alpine\n",
+		"Dockerfile.dockerignore": "node_modules\n",
+	} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("failed to read %s: %v", name, err)
+		} else if string(content) != expected {
+			t.Errorf("%s = %q; want %q", name, content, expected)
+		}
+	}
+}
+
+func TestBuildReportSavings(t *testing.T) {
+	cases := []struct {
+		name                    string
+		originalSize, optimized int64
+		expected                int64
+	}{
+		{"smaller", 1000, 400, 600},
+		{"bigger", 400, 1000, -600},
+		{"original failed", 0, 400, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &BuildReport{Optimized: &docker.BuildResult{}, OriginalSize: tc.originalSize, OptimizedSize: tc.optimized}
+			if got := r.Savings(); got != tc.expected {
+				t.Errorf("Savings() = %d; want %d", got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package verify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/compose"
+	"github.com/duaraghav8/dockershrink/internal/docker"
+)
+
+// composeDownTimeout is the max time allowed for tearing down the dependencies of a smoke test
+const composeDownTimeout = 2 * time.Minute
+
+// Dependencies are the compose services a smoke tested service depends on, running in their own compose project
+type Dependencies struct {
+	client  *docker.Client
+	file    *compose.File
+	project string
+	service *compose.Service
+}
+
+// StartDependencies starts the services that the given compose service depends on and waits until they
+// are ready: running, healthy or, for one-off services like migrations, completed successfully.
+// The services run in a separate compose project so that they don't interfere with the user's own.
+// Down must always be called afterwards, even if an error is returned.
+func StartDependencies(ctx context.Context, client *docker.Client, file *compose.File, service string) (*Dependencies, error) {
+	if err := file.Validate(service); err != nil {
+		return nil, err
+	}
+	d := &Dependencies{
+		client:  client,
+		file:    file,
+		project: composeProjectName(),
+		service: file.Service(service),
+	}
+
+	long, oneOff := []string{}, []string{}
+	for _, dep := range d.service.DependsOn {
+		if dep.Condition == compose.ConditionCompletedSuccessfully {
+			oneOff = append(oneOff, dep.Service)
+		} else {
+			long = append(long, dep.Service)
+		}
+	}
+
+	if len(long) > 0 {
+		// --wait waits for the services to be running and, if they define a healthcheck, healthy
+		args := append([]string{"up", "--detach", "--wait"}, long...)
+		if _, err := client.Output(ctx, d.args(args...)...); err != nil {
+			return d, fmt.Errorf("failed to start %s: %w", strings.Join(long, ", "), err)
+		}
+	}
+	for _, s := range oneOff {
+		if _, err := client.Output(ctx, d.args("run", "--rm", s)...); err != nil {
+			return d, fmt.Errorf("%s didn't complete successfully: %w", s, err)
+		}
+	}
+	return d, nil
+}
+
+// SmokeTestOptions returns a copy of opts that runs the container on the network of the
+// dependencies, reachable by its service name and with the service's environment.
+func (d *Dependencies) SmokeTestOptions(opts *SmokeTestOptions) *SmokeTestOptions {
+	o := *opts
+	o.Env = d.service.Environment
+	o.Network = d.project + "_" + d.service.Network()
+	o.NetworkAliases = []string{d.service.Name}
+	return &o
+}
+
+// Down stops and removes the dependencies along with their networks and volumes.
+// It's safe to call on nil.
+func (d *Dependencies) Down() error {
+	if d == nil {
+		return nil
+	}
+	// the parent context may already be cancelled, eg- after a timeout, but the services must still go
+	ctx, cancel := context.WithTimeout(context.Background(), composeDownTimeout)
+	defer cancel()
+	if _, err := d.client.Output(ctx, d.args("down", "--volumes", "--remove-orphans", "--timeout", "10")...); err != nil {
+		return fmt.Errorf("failed to tear down compose project %s: %w", d.project, err)
+	}
+	return nil
+}
+
+func (d *Dependencies) args(args ...string) []string {
+	return append([]string{"compose", "--file", d.file.Path, "--project-name", d.project}, args...)
+}
+
+func composeProjectName() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return "dockershrink-verify-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,53 @@
+package verify
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/compose"
+)
+
+func TestDependencies_SmokeTestOptions(t *testing.T) {
+	file, err := compose.Parse("compose.yaml", []byte(`
+services:
+  api:
+    build: .
+    depends_on: [db]
+    environment:
+      DATABASE_HOST: db
+  db:
+    image: postgres:16
+`))
+	if err != nil {
+		t.Fatalf("failed to parse compose file: %v", err)
+	}
+	d := &Dependencies{file: file, project: "dockershrink-verify-test", service: file.Service("api")}
+
+	opts := &SmokeTestOptions{Command: []string{"true"}}
+	got := d.SmokeTestOptions(opts)
+	if got.Network != "dockershrink-verify-test_default" {
+		t.Errorf("Network = %q; want the default network of the compose project", got.Network)
+	}
+	if !reflect.DeepEqual(got.NetworkAliases, []string{"api"}) {
+		t.Errorf("NetworkAliases = %v; want [api]", got.NetworkAliases)
+	}
+	if got.Env["DATABASE_HOST"] != "db" {
+		t.Errorf("Env = %v; want the environment of the service", got.Env)
+	}
+	if opts.Network != "" || !reflect.DeepEqual(got.Command, opts.Command) {
+		t.Errorf("SmokeTestOptions() must copy the original options without modifying them")
+	}
+
+	args := strings.Join(d.args("down"), " ")
+	if args != "compose --file compose.yaml --project-name dockershrink-verify-test down" {
+		t.Errorf("args() = %q", args)
+	}
+}
+
+func TestDependencies_DownNil(t *testing.T) {
+	var d *Dependencies
+	if err := d.Down(); err != nil {
+		t.Errorf("Down() on nil = %v; want nil", err)
+	}
+}
@@ -0,0 +1,120 @@
+package verify
+
+import (
+	"context"
+	"strings"
+
+	"github.com/duaraghav8/dockershrink/internal/docker"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+)
+
+// LayerSize is a layer of a built image along with the Dockerfile instruction that created it
+type LayerSize struct {
+	Size      int64
+	CreatedBy string
+	// Instruction is nil if the layer belongs to the base image
+	Instruction *dockerfile.Instruction
+}
+
+// SizeBreakdown splits the size of an image between its base image
+// and the layers created by the instructions of its Dockerfile.
+type SizeBreakdown struct {
+	BaseImage     string
+	BaseImageSize int64
+	// Layers are the layers created by the Dockerfile, oldest first
+	Layers []*LayerSize
+}
+
+// LayersSize returns the total size of the layers created by the Dockerfile
+func (b *SizeBreakdown) LayersSize() int64 {
+	var total int64
+	for _, l := range b.Layers {
+		total += l.Size
+	}
+	return total
+}
+
+// Breakdown maps the layers of a built image to the instructions of the Dockerfile it was built from
+func Breakdown(ctx context.Context, client *docker.Client, image, code string) (*SizeBreakdown, error) {
+	df, err := dockerfile.NewDockerfile(code)
+	if err != nil {
+		return nil, err
+	}
+	layers, err := client.History(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	return mapLayers(layers, df), nil
+}
+
+// mapLayers walks the image history and the instructions that make up the final image backwards,
+// assigning every layer to the closest instruction of the same kind.
+// Everything older than the first instruction belongs to the base image.
+func mapLayers(layers []*docker.Layer, df *dockerfile.Dockerfile) *SizeBreakdown {
+	base, instructions := finalImageInstructions(df)
+	b := &SizeBreakdown{BaseImage: base, Layers: []*LayerSize{}}
+
+	next := len(instructions) - 1
+	l := len(layers) - 1
+	for ; l >= 0; l-- {
+		cmd := layerCommand(layers[l].CreatedBy)
+		i := next
+		for i >= 0 && instructions[i].Cmd() != cmd {
+			i--
+		}
+		if i < 0 {
+			break
+		}
+		b.Layers = append([]*LayerSize{{
+			Size:        layers[l].Size,
+			CreatedBy:   layers[l].CreatedBy,
+			Instruction: instructions[i],
+		}}, b.Layers...)
+		next = i - 1
+	}
+	for ; l >= 0; l-- {
+		b.BaseImageSize += layers[l].Size
+	}
+	return b
+}
+
+// finalImageInstructions returns the base image of the final image and the instructions that built it.
+// If the final stage is based on other stages, their instructions are included.
+func finalImageInstructions(df *dockerfile.Dockerfile) (string, []*dockerfile.Instruction) {
+	stages := df.GetStages()
+	chain := []*dockerfile.Stage{stages[len(stages)-1]}
+	for {
+		first := chain[0]
+		parent := dockerfile.FindStage(stages[:first.Index()], first.BaseImage().Name())
+		if parent == nil {
+			break
+		}
+		chain = append([]*dockerfile.Stage{parent}, chain...)
+	}
+
+	instructions := []*dockerfile.Instruction{}
+	for _, s := range chain {
+		instructions = append(instructions, s.Instructions()...)
+	}
+	return chain[0].BaseImage().FullName(), instructions
+}
+
+// layerCommand returns the instruction that created a layer, based on its history entry.
+// Both BuildKit ("RUN /bin/sh -c npm ci # buildkit") and the legacy builder
+// ("/bin/sh -c #(nop) COPY dir:abc in /app") formats are recognized.
+func layerCommand(createdBy string) string {
+	s := strings.TrimSpace(createdBy)
+	s = strings.TrimSpace(strings.TrimSuffix(s, "# buildkit"))
+	s = strings.TrimPrefix(s, "/bin/sh -c #(nop)")
+	s = strings.TrimSpace(s)
+
+	// legacy builder records RUN as the command itself, prefixed with the build args, eg- "|1 ENV=prod /bin/sh -c ..."
+	if strings.HasPrefix(s, "/bin/sh -c") || strings.HasPrefix(s, "|") {
+		return dockerfile.CmdRun
+	}
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
@@ -0,0 +1,70 @@
+package verify
+
+import (
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/internal/docker"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+)
+
+func TestMapLayers(t *testing.T) {
+	df, err := dockerfile.NewDockerfile(`FROM node:20 AS build
+WORKDIR /app
+COPY . .
+RUN npm ci && npm run build
+
+FROM node:20-alpine AS base
+WORKDIR /app
+
+FROM base
+COPY --from=build /app/dist ./dist
+CMD ["node", "dist/index.js"]
+`)
+	if err != nil {
+		t.Fatalf("failed to parse Dockerfile: %v", err)
+	}
+	layers := []*docker.Layer{
+		{CreatedBy: "/bin/sh -c #(nop) ADD file:abc in / ", Size: 7000},
+		{CreatedBy: `/bin/sh -c #(nop)  CMD ["node"]`, Size: 0},
+		{CreatedBy: "WORKDIR /app", Size: 0},
+		{CreatedBy: "COPY /app/dist ./dist # buildkit", Size: 300},
+		{CreatedBy: `CMD ["node" "dist/index.js"]`, Size: 0},
+	}
+
+	b := mapLayers(layers, df)
+	if b.BaseImage != "node:20-alpine" {
+		t.Errorf("BaseImage = %q; want %q", b.BaseImage, "node:20-alpine")
+	}
+	if b.BaseImageSize != 7000 {
+		t.Errorf("BaseImageSize = %d; want 7000", b.BaseImageSize)
+	}
+	if b.LayersSize() != 300 {
+		t.Errorf("LayersSize() = %d; want 300", b.LayersSize())
+	}
+	expectedLines := []int{7, 10, 11}
+	if len(b.Layers) != len(expectedLines) {
+		t.Fatalf("got %d layers; want %d", len(b.Layers), len(expectedLines))
+	}
+	for i, line := range expectedLines {
+		if b.Layers[i].Instruction.StartLine() != line {
+			t.Errorf("layer %d maps to line %d; want %d", i, b.Layers[i].Instruction.StartLine(), line)
+		}
+	}
+}
+
+func TestLayerCommand(t *testing.T) {
+	tests := map[string]string{
+		"RUN /bin/sh -c npm ci # buildkit":       dockerfile.CmdRun,
+		"/bin/sh -c npm ci":                      dockerfile.CmdRun,
+		"|1 NODE_ENV=prod /bin/sh -c npm ci":     dockerfile.CmdRun,
+		"/bin/sh -c #(nop) COPY dir:abc in /app": dockerfile.CmdCopy,
+		"COPY . . # buildkit":                    dockerfile.CmdCopy,
+		"ENV NODE_ENV=production":                dockerfile.CmdEnv,
+		"":                                       "",
+	}
+	for createdBy, expected := range tests {
+		if got := layerCommand(createdBy); got != expected {
+			t.Errorf("layerCommand(%q) = %q; want %q", createdBy, got, expected)
+		}
+	}
+}
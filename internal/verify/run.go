@@ -0,0 +1,194 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/sandbox"
+)
+
+const (
+	// bootGracePeriod is how long a container without a health check must stay up to be considered booted
+	bootGracePeriod = 10 * time.Second
+	// commandTimeout is the max time the smoke test command may run for
+	commandTimeout    = time.Minute
+	statePollInterval = time.Second
+)
+
+// SmokeTestOptions control how an image is smoke tested
+type SmokeTestOptions struct {
+	// Command is run inside the container once the application has booted, eg- a request to its health endpoint.
+	// The smoke test only checks that the application boots if it's empty.
+	Command []string
+	// BootTimeout is how long the application may take to boot, or its health check to pass
+	BootTimeout time.Duration
+	Limits      sandbox.Limits
+
+	// Env, Network and NetworkAliases are used to run the container alongside its dependencies
+	Env            map[string]string
+	Network        string
+	NetworkAliases []string
+}
+
+// SmokeTestReport is the outcome of a smoke test
+type SmokeTestReport struct {
+	Passed bool
+	// Reason explains why the smoke test passed or failed
+	Reason string
+	// Logs is the output of the application
+	Logs string
+	// CommandResult is only set if a command was run
+	CommandResult *sandbox.Result
+}
+
+// SmokeTest starts the image in a sandbox and checks that the application boots without crashing.
+// If the image defines a HEALTHCHECK, the application must also become healthy.
+// The container is removed afterwards.
+func SmokeTest(ctx context.Context, sb *sandbox.Sandbox, image string, opts *SmokeTestOptions) (*SmokeTestReport, error) {
+	c, err := sb.Start(ctx, &sandbox.Spec{
+		Image:          image,
+		Env:            opts.Env,
+		Limits:         opts.Limits,
+		Network:        opts.Network,
+		NetworkAliases: opts.NetworkAliases,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer c.Remove()
+
+	report := &SmokeTestReport{}
+	booted, err := waitForBoot(ctx, c, opts, report)
+	report.Logs = c.Logs(context.Background())
+	if err != nil || !booted {
+		return report, err
+	}
+
+	if len(opts.Command) == 0 {
+		report.Passed = true
+		return report, nil
+	}
+	res, err := c.Exec(ctx, opts.Command, commandTimeout)
+	if err != nil {
+		return nil, err
+	}
+	report.CommandResult = res
+	report.Passed = res.Succeeded()
+	switch {
+	case res.TimedOut:
+		report.Reason = fmt.Sprintf("%q timed out after %s", strings.Join(opts.Command, " "), commandTimeout)
+	case res.ExitCode != 0:
+		report.Reason = fmt.Sprintf("%q exited with status %d", strings.Join(opts.Command, " "), res.ExitCode)
+	default:
+		report.Reason = fmt.Sprintf("application booted and %q succeeded", strings.Join(opts.Command, " "))
+	}
+	return report, nil
+}
+
+// waitForBoot waits until the application in the container has booted.
+// It returns false and sets the report's reason if the application crashed or never became healthy.
+func waitForBoot(ctx context.Context, c *sandbox.Container, opts *SmokeTestOptions, report *SmokeTestReport) (bool, error) {
+	start := time.Now()
+	timeout := max(opts.BootTimeout, bootGracePeriod)
+
+	for {
+		state, err := c.State(ctx)
+		if err != nil {
+			return false, err
+		}
+
+		switch {
+		case !state.Running && state.OOMKilled:
+			report.Reason = "application was killed because it ran out of memory"
+			return false, nil
+		case !state.Running && state.ExitCode != 0:
+			report.Reason = fmt.Sprintf("application exited with status %d", state.ExitCode)
+			return false, nil
+		case !state.Running:
+			// one-off programs exit successfully, but there's nothing left to run the command in
+			if len(opts.Command) > 0 {
+				report.Reason = "application exited before the command could be run"
+				return false, nil
+			}
+			report.Reason = "application ran to completion successfully"
+			return true, nil
+		case state.HealthStatus() == "healthy":
+			report.Reason = "application booted and its health check passed"
+			return true, nil
+		case state.HealthStatus() == "unhealthy":
+			report.Reason = "application's health check failed"
+			return false, nil
+		case state.HealthStatus() == "" && time.Since(start) >= bootGracePeriod:
+			report.Reason = fmt.Sprintf("application booted and kept running for %s", bootGracePeriod)
+			return true, nil
+		case time.Since(start) >= timeout:
+			report.Reason = fmt.Sprintf("application's health check didn't pass within %s", timeout)
+			return false, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(statePollInterval):
+		}
+	}
+}
+
+// SplitCommand splits a command line into its arguments, honoring single and double quotes
+// and backslash escapes the way a POSIX shell does. No other shell features are supported.
+func SplitCommand(s string) ([]string, error) {
+	args := []string{}
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case quote == '"':
+			switch {
+			case r == '"':
+				quote = 0
+			case r == '\\' && i+1 < len(runes) && strings.ContainsRune(`"\$`+"`", runes[i+1]):
+				i++
+				current.WriteRune(runes[i])
+			default:
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == '\\':
+			if i+1 < len(runes) {
+				i++
+				current.WriteRune(runes[i])
+				inArg = true
+			}
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote in command")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,33 @@
+package verify
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected []string
+	}{
+		{`wget -qO- http://localhost:3000/health`, []string{"wget", "-qO-", "http://localhost:3000/health"}},
+		{`node -e "require('sharp')"`, []string{"node", "-e", "require('sharp')"}},
+		{`sh -c 'echo $HOME && exit 0'`, []string{"sh", "-c", "echo $HOME && exit 0"}},
+		{`echo "a \"quoted\" word" b\ c ""`, []string{"echo", `a "quoted" word`, "b c", ""}},
+		{"  ", []string{}},
+	}
+	for _, tc := range cases {
+		got, err := SplitCommand(tc.input)
+		if err != nil {
+			t.Errorf("SplitCommand(%q) returned error: %v", tc.input, err)
+			continue
+		}
+		if !slices.Equal(got, tc.expected) {
+			t.Errorf("SplitCommand(%q) = %q; want %q", tc.input, got, tc.expected)
+		}
+	}
+
+	if _, err := SplitCommand(`echo "unterminated`); err == nil {
+		t.Error("expected an error for an unterminated quote")
+	}
+}
@@ -0,0 +1,93 @@
+// Package watch tells when the files of a project change, so that it can be analyzed again while it's being edited.
+// Files are watched through the notifications of the OS (inotify, kqueue, ReadDirectoryChangesW) using fsnotify.
+// The directories containing them are watched rather than the files themselves, because editors often save a file
+// by writing a new one and renaming it over the old one, and so that creating a file that didn't exist is reported.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultSettle is how long files must go unchanged before their changes are reported
+const DefaultSettle = 100 * time.Millisecond
+
+// Watcher tells when any of a set of files is created, modified or removed
+type Watcher struct {
+	// Settle is how long files must go unchanged before their changes are reported
+	Settle time.Duration
+
+	paths    map[string]bool
+	notifier *fsnotify.Watcher
+}
+
+// New returns a watcher of the given paths, whose changes are reported from now on.
+// Paths that don't exist yet are watched too, so that creating them is reported, but their directories must exist.
+// The watcher must be closed once done.
+func New(paths ...string) (*Watcher, error) {
+	notifier, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("error watching files: %w", err)
+	}
+	w := &Watcher{Settle: DefaultSettle, paths: map[string]bool{}, notifier: notifier}
+	dirs := map[string]bool{}
+	for _, p := range paths {
+		p = filepath.Clean(p)
+		w.paths[p] = true
+		dirs[filepath.Dir(p)] = true
+	}
+	for dir := range dirs {
+		if err := notifier.Add(dir); err != nil {
+			notifier.Close()
+			return nil, fmt.Errorf("error watching %s: %w", dir, err)
+		}
+	}
+	return w, nil
+}
+
+// Close stops watching the files
+func (w *Watcher) Close() error {
+	return w.notifier.Close()
+}
+
+// Wait blocks until some of the files change and returns their paths, sorted.
+// Editors often write a file in several steps, so it waits for the files to settle before returning,
+// and all the changes made meanwhile are returned together.
+func (w *Watcher) Wait(ctx context.Context) ([]string, error) {
+	changed := map[string]bool{}
+	// settled fires once no file changed for w.Settle, it's nil until a file changes
+	var settled <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case err, ok := <-w.notifier.Errors:
+			if !ok {
+				return nil, fmt.Errorf("watcher closed")
+			}
+			return nil, fmt.Errorf("error watching files: %w", err)
+		case e, ok := <-w.notifier.Events:
+			if !ok {
+				return nil, fmt.Errorf("watcher closed")
+			}
+			// a change of permissions or timestamps alone doesn't change what's analyzed
+			if !w.paths[filepath.Clean(e.Name)] || e.Op == fsnotify.Chmod {
+				continue
+			}
+			changed[filepath.Clean(e.Name)] = true
+			settled = time.After(w.Settle)
+		case <-settled:
+			paths := make([]string, 0, len(changed))
+			for p := range changed {
+				paths = append(paths, p)
+			}
+			sort.Strings(paths)
+			return paths, nil
+		}
+	}
+}
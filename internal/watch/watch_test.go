@@ -0,0 +1,92 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWatcher_Wait(t *testing.T) {
+	dir := t.TempDir()
+	dockerfile := filepath.Join(dir, "Dockerfile")
+	dockerignore := filepath.Join(dir, ".dockerignore")
+	packageJson := filepath.Join(dir, "package.json")
+	if err := os.WriteFile(dockerfile, []byte("FROM node:22\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(packageJson, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		change func() error
+		want   []string
+	}{
+		{
+			name:   "modified",
+			change: func() error { return os.WriteFile(dockerfile, []byte("FROM node:22-alpine\n"), 0o644) },
+			want:   []string{dockerfile},
+		},
+		{
+			name:   "created",
+			change: func() error { return os.WriteFile(dockerignore, []byte("node_modules\n"), 0o644) },
+			want:   []string{dockerignore},
+		},
+		{
+			name:   "removed",
+			change: func() error { return os.Remove(packageJson) },
+			want:   []string{packageJson},
+		},
+		{
+			name: "several files",
+			change: func() error {
+				if err := os.WriteFile(packageJson, []byte("{}"), 0o644); err != nil {
+					return err
+				}
+				return os.Remove(dockerignore)
+			},
+			want: []string{dockerignore, packageJson},
+		},
+	}
+
+	w, err := New(dockerfile, dockerignore, packageJson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Settle = 10 * time.Millisecond
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.change(); err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			got, err := w.Wait(ctx)
+			if err != nil {
+				t.Fatalf("Wait() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Wait() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatcher_WaitCancelled(t *testing.T) {
+	w, err := New(filepath.Join(t.TempDir(), "Dockerfile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Settle = 10 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err = w.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Wait() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
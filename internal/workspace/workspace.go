@@ -0,0 +1,245 @@
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ManagerNPM  = "npm"
+	ManagerYarn = "yarn"
+	ManagerPNPM = "pnpm"
+
+	OrchestratorTurbo = "turbo"
+	OrchestratorNx    = "nx"
+)
+
+// Package is a single package declared inside a monorepo workspace
+type Package struct {
+	// Name is the name declared in the package's package.json
+	Name string
+	// Dir is the package's directory relative to the workspace root
+	Dir string
+}
+
+// Workspace describes a nodejs monorepo (npm, yarn or pnpm workspaces),
+// optionally managed by a build orchestrator like turborepo or nx.
+type Workspace struct {
+	Manager      string
+	Orchestrator string
+	Patterns     []string
+	Packages     []*Package
+}
+
+// Detect inspects the root of the given filesystem and returns the workspace
+// configuration of the project.
+// If the project is not a monorepo, nil is returned without any error.
+func Detect(fsys fs.FS) (*Workspace, error) {
+	patterns, err := workspacePatterns(fsys)
+	if err != nil {
+		return nil, err
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	ws := &Workspace{
+		Manager:  detectManager(fsys),
+		Patterns: patterns,
+	}
+	if fileExists(fsys, "turbo.json") {
+		ws.Orchestrator = OrchestratorTurbo
+	} else if fileExists(fsys, "nx.json") {
+		ws.Orchestrator = OrchestratorNx
+	}
+
+	ws.Packages, err = resolvePackages(fsys, patterns)
+	if err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
+// FindPackage returns the workspace package with the given name or directory.
+// nil is returned if no such package exists.
+func (w *Workspace) FindPackage(nameOrDir string) *Package {
+	nameOrDir = strings.TrimSuffix(strings.TrimPrefix(nameOrDir, "./"), "/")
+	for _, p := range w.Packages {
+		if p.Name == nameOrDir || p.Dir == nameOrDir {
+			return p
+		}
+	}
+	return nil
+}
+
+// PruneCommand returns the command that produces a pruned copy of the monorepo
+// containing only the target package and the workspace packages it depends on.
+func (w *Workspace) PruneCommand(target string) string {
+	if target == "" {
+		target = "<package>"
+	}
+	switch {
+	case w.Orchestrator == OrchestratorTurbo:
+		return fmt.Sprintf("npx turbo prune %s --docker", target)
+	case w.Manager == ManagerPNPM:
+		return fmt.Sprintf("pnpm --filter %s deploy --prod /prod/%s", target, target)
+	case w.Orchestrator == OrchestratorNx:
+		return fmt.Sprintf("npx nx build %s --generatePackageJson", target)
+	case w.Manager == ManagerYarn:
+		return fmt.Sprintf("yarn workspaces focus %s --production", target)
+	default:
+		return fmt.Sprintf("npm install --omit=dev --workspace %s", target)
+	}
+}
+
+// Summary returns a human-readable description of the workspace suitable for LLM prompts
+func (w *Workspace) Summary() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Package manager: %s\n", w.Manager))
+	if w.Orchestrator != "" {
+		sb.WriteString(fmt.Sprintf("Build orchestrator: %s\n", w.Orchestrator))
+	}
+	sb.WriteString(fmt.Sprintf("Workspace patterns: %s\n", strings.Join(w.Patterns, ", ")))
+	sb.WriteString("Packages:\n")
+	for _, p := range w.Packages {
+		sb.WriteString(fmt.Sprintf("- %s (%s)\n", p.Name, p.Dir))
+	}
+	return sb.String()
+}
+
+// pruneIndicators are command fragments which show that a Dockerfile already
+// narrows down a monorepo to a single package before installing dependencies.
+var pruneIndicators = []string{
+	"turbo prune",
+	"pnpm deploy",
+	" deploy --prod",
+	"--generatePackageJson",
+	"workspaces focus",
+	"--workspace",
+	"--filter",
+}
+
+// IsPruned returns true if the given Dockerfile code contains instructions to prune the monorepo
+func IsPruned(dockerfileCode string) bool {
+	for _, indicator := range pruneIndicators {
+		if strings.Contains(dockerfileCode, indicator) {
+			return true
+		}
+	}
+	return false
+}
+
+// workspacePatterns returns the package globs declared in pnpm-workspace.yaml
+// or in the "workspaces" field of the root package.json.
+func workspacePatterns(fsys fs.FS) ([]string, error) {
+	if content, err := fs.ReadFile(fsys, "pnpm-workspace.yaml"); err == nil {
+		var conf struct {
+			Packages []string `yaml:"packages"`
+		}
+		if err := yaml.Unmarshal(content, &conf); err != nil {
+			return nil, fmt.Errorf("failed to parse pnpm-workspace.yaml: %w", err)
+		}
+		return conf.Packages, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	content, err := fs.ReadFile(fsys, "package.json")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var pkg struct {
+		Workspaces json.RawMessage `json:"workspaces"`
+	}
+	if err := json.Unmarshal(content, &pkg); err != nil {
+		return nil, fmt.Errorf("failed to parse package.json: %w", err)
+	}
+	if len(pkg.Workspaces) == 0 {
+		return nil, nil
+	}
+
+	// "workspaces" is either an array of globs or (yarn classic) an object with a "packages" array
+	var patterns []string
+	if err := json.Unmarshal(pkg.Workspaces, &patterns); err == nil {
+		return patterns, nil
+	}
+	var obj struct {
+		Packages []string `json:"packages"`
+	}
+	if err := json.Unmarshal(pkg.Workspaces, &obj); err != nil {
+		return nil, fmt.Errorf("unrecognized format of workspaces in package.json: %w", err)
+	}
+	return obj.Packages, nil
+}
+
+func detectManager(fsys fs.FS) string {
+	switch {
+	case fileExists(fsys, "pnpm-workspace.yaml"), fileExists(fsys, "pnpm-lock.yaml"):
+		return ManagerPNPM
+	case fileExists(fsys, "yarn.lock"):
+		return ManagerYarn
+	default:
+		return ManagerNPM
+	}
+}
+
+func resolvePackages(fsys fs.FS, patterns []string) ([]*Package, error) {
+	seen := map[string]struct{}{}
+	packages := []*Package{}
+
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") {
+			// exclusion patterns are only supported by pnpm and rarely used, skip them
+			continue
+		}
+		pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "./"), "/")
+		// "**" is not supported by fs.Glob, approximate it with a single level
+		pattern = strings.ReplaceAll(pattern, "**", "*")
+
+		dirs, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid workspace pattern %q: %w", pattern, err)
+		}
+		for _, dir := range dirs {
+			if _, ok := seen[dir]; ok {
+				continue
+			}
+			content, err := fs.ReadFile(fsys, path.Join(dir, "package.json"))
+			if err != nil {
+				// not a package, eg- a README inside packages/
+				continue
+			}
+			var pkg struct {
+				Name string `json:"name"`
+			}
+			if err := json.Unmarshal(content, &pkg); err != nil {
+				return nil, fmt.Errorf("failed to parse %s/package.json: %w", dir, err)
+			}
+			if pkg.Name == "" {
+				pkg.Name = path.Base(dir)
+			}
+			seen[dir] = struct{}{}
+			packages = append(packages, &Package{Name: pkg.Name, Dir: dir})
+		}
+	}
+
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Dir < packages[j].Dir
+	})
+	return packages, nil
+}
+
+func fileExists(fsys fs.FS, name string) bool {
+	_, err := fs.Stat(fsys, name)
+	return err == nil
+}
@@ -0,0 +1,110 @@
+package workspace
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestDetect_NotAMonorepo(t *testing.T) {
+	fsys := fstest.MapFS{
+		"package.json": {Data: []byte(`{"name": "app"}`)},
+	}
+	ws, err := Detect(fsys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ws != nil {
+		t.Fatalf("expected no workspace, got %+v", ws)
+	}
+}
+
+func TestDetect_NPMWorkspacesWithTurbo(t *testing.T) {
+	fsys := fstest.MapFS{
+		"package.json":              {Data: []byte(`{"name": "root", "workspaces": ["apps/*", "packages/*"]}`)},
+		"turbo.json":                {Data: []byte(`{}`)},
+		"apps/web/package.json":     {Data: []byte(`{"name": "@acme/web"}`)},
+		"apps/api/package.json":     {Data: []byte(`{"name": "@acme/api"}`)},
+		"packages/ui/package.json":  {Data: []byte(`{"name": "@acme/ui"}`)},
+		"packages/README.md":        {Data: []byte(`docs`)},
+		"packages/tsconfig/tsc.txt": {Data: []byte(``)},
+	}
+	ws, err := Detect(fsys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ws == nil {
+		t.Fatal("expected a workspace to be detected")
+	}
+	if ws.Manager != ManagerNPM {
+		t.Errorf("expected manager %q, got %q", ManagerNPM, ws.Manager)
+	}
+	if ws.Orchestrator != OrchestratorTurbo {
+		t.Errorf("expected orchestrator %q, got %q", OrchestratorTurbo, ws.Orchestrator)
+	}
+	if len(ws.Packages) != 3 {
+		t.Fatalf("expected 3 packages, got %d", len(ws.Packages))
+	}
+	if p := ws.FindPackage("@acme/api"); p == nil || p.Dir != "apps/api" {
+		t.Errorf("expected to find @acme/api in apps/api, got %+v", p)
+	}
+	if got := ws.PruneCommand("@acme/api"); got != "npx turbo prune @acme/api --docker" {
+		t.Errorf("unexpected prune command: %q", got)
+	}
+}
+
+func TestDetect_PNPMWorkspace(t *testing.T) {
+	fsys := fstest.MapFS{
+		"package.json":            {Data: []byte(`{"name": "root"}`)},
+		"pnpm-workspace.yaml":     {Data: []byte("packages:\n  - 'services/*'\n")},
+		"services/a/package.json": {Data: []byte(`{"name": "svc-a"}`)},
+	}
+	ws, err := Detect(fsys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ws.Manager != ManagerPNPM {
+		t.Errorf("expected manager %q, got %q", ManagerPNPM, ws.Manager)
+	}
+	if got := ws.PruneCommand("svc-a"); got != "pnpm --filter svc-a deploy --prod /prod/svc-a" {
+		t.Errorf("unexpected prune command: %q", got)
+	}
+}
+
+func TestDetect_YarnClassicWorkspacesObject(t *testing.T) {
+	fsys := fstest.MapFS{
+		"package.json":           {Data: []byte(`{"workspaces": {"packages": ["libs/*"]}}`)},
+		"yarn.lock":              {Data: []byte(``)},
+		"libs/core/package.json": {Data: []byte(`{}`)},
+	}
+	ws, err := Detect(fsys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ws.Manager != ManagerYarn {
+		t.Errorf("expected manager %q, got %q", ManagerYarn, ws.Manager)
+	}
+	if len(ws.Packages) != 1 || ws.Packages[0].Name != "core" {
+		t.Errorf("expected package named after its directory, got %+v", ws.Packages)
+	}
+}
+
+func TestIsPruned(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected bool
+	}{
+		{"turbo prune", "FROM node:22\nRUN npx turbo prune api --docker", true},
+		{"pnpm deploy", "FROM node:22\nRUN pnpm --filter api deploy --prod /prod/api", true},
+		{"npm workspace install", "FROM node:22\nRUN npm install --omit=dev --workspace api", true},
+		{"whole repo copied", "FROM node:22\nCOPY . .\nRUN npm install", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPruned(tt.code); got != tt.expected {
+				t.Errorf("IsPruned(%q) = %v; want %v", tt.code, got, tt.expected)
+			}
+		})
+	}
+}
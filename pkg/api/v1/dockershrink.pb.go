@@ -0,0 +1,1876 @@
+// The gRPC API of "dockershrink serve", for platform teams embedding dockershrink in their build systems.
+//
+// Every RPC uploads the project and streams the progress of the request as it runs, followed by its result.
+// Failed requests end with a status instead: INVALID_ARGUMENT for invalid options or uploads, FAILED_PRECONDITION
+// when the project can't be analyzed, eg- its Dockerfile doesn't parse, RESOURCE_EXHAUSTED when the project is
+// larger than the server accepts and UNAUTHENTICATED when the server's token isn't sent as "authorization: Bearer <token>".
+//
+// The Go client is generated in github.com/duaraghav8/dockershrink/pkg/api/v1, run "go generate ./pkg/api/..."
+// after changing this file. Clients in other languages are generated from it with protoc.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.35.1
+// 	protoc        v5.28.3
+// source: dockershrink/v1/dockershrink.proto
+
+package apiv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Project is the uploaded project, either as its files or as a tarball
+type Project struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// files maps the slash-separated paths of the project's files, relative to it, to their content
+	Files map[string]string `protobuf:"bytes,1,rep,name=files,proto3" json:"files,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// tarball is the project as a tarball, gzipped or not. It's used instead of files if it's set.
+	Tarball []byte `protobuf:"bytes,2,opt,name=tarball,proto3" json:"tarball,omitempty"`
+	// dockerfile and dockerignore are the paths of the files in the project, "Dockerfile" and ".dockerignore" by default
+	Dockerfile   string `protobuf:"bytes,3,opt,name=dockerfile,proto3" json:"dockerfile,omitempty"`
+	Dockerignore string `protobuf:"bytes,4,opt,name=dockerignore,proto3" json:"dockerignore,omitempty"`
+}
+
+func (x *Project) Reset() {
+	*x = Project{}
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Project) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Project) ProtoMessage() {}
+
+func (x *Project) ProtoReflect() protoreflect.Message {
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Project.ProtoReflect.Descriptor instead.
+func (*Project) Descriptor() ([]byte, []int) {
+	return file_dockershrink_v1_dockershrink_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Project) GetFiles() map[string]string {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *Project) GetTarball() []byte {
+	if x != nil {
+		return x.Tarball
+	}
+	return nil
+}
+
+func (x *Project) GetDockerfile() string {
+	if x != nil {
+		return x.Dockerfile
+	}
+	return ""
+}
+
+func (x *Project) GetDockerignore() string {
+	if x != nil {
+		return x.Dockerignore
+	}
+	return ""
+}
+
+type AnalyzeRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Project *Project `protobuf:"bytes,1,opt,name=project,proto3" json:"project,omitempty"`
+	// goal is size, build-speed, security or all, the default
+	Goal string `protobuf:"bytes,2,opt,name=goal,proto3" json:"goal,omitempty"`
+	// platforms the image is built for, eg- linux/arm64
+	Platforms []string `protobuf:"bytes,3,rep,name=platforms,proto3" json:"platforms,omitempty"`
+}
+
+func (x *AnalyzeRequest) Reset() {
+	*x = AnalyzeRequest{}
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyzeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeRequest) ProtoMessage() {}
+
+func (x *AnalyzeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeRequest.ProtoReflect.Descriptor instead.
+func (*AnalyzeRequest) Descriptor() ([]byte, []int) {
+	return file_dockershrink_v1_dockershrink_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *AnalyzeRequest) GetProject() *Project {
+	if x != nil {
+		return x.Project
+	}
+	return nil
+}
+
+func (x *AnalyzeRequest) GetGoal() string {
+	if x != nil {
+		return x.Goal
+	}
+	return ""
+}
+
+func (x *AnalyzeRequest) GetPlatforms() []string {
+	if x != nil {
+		return x.Platforms
+	}
+	return nil
+}
+
+type LintRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Project *Project `protobuf:"bytes,1,opt,name=project,proto3" json:"project,omitempty"`
+	// goal is size, build-speed, security or all, the default
+	Goal string `protobuf:"bytes,2,opt,name=goal,proto3" json:"goal,omitempty"`
+	// platforms the image is built for, eg- linux/arm64
+	Platforms []string `protobuf:"bytes,3,rep,name=platforms,proto3" json:"platforms,omitempty"`
+	// severities overrides the severity of rules, keyed by rule ID or name, eg- {"DS014": "off"}
+	Severities map[string]string `protobuf:"bytes,4,rep,name=severities,proto3" json:"severities,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (x *LintRequest) Reset() {
+	*x = LintRequest{}
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LintRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LintRequest) ProtoMessage() {}
+
+func (x *LintRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LintRequest.ProtoReflect.Descriptor instead.
+func (*LintRequest) Descriptor() ([]byte, []int) {
+	return file_dockershrink_v1_dockershrink_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *LintRequest) GetProject() *Project {
+	if x != nil {
+		return x.Project
+	}
+	return nil
+}
+
+func (x *LintRequest) GetGoal() string {
+	if x != nil {
+		return x.Goal
+	}
+	return ""
+}
+
+func (x *LintRequest) GetPlatforms() []string {
+	if x != nil {
+		return x.Platforms
+	}
+	return nil
+}
+
+func (x *LintRequest) GetSeverities() map[string]string {
+	if x != nil {
+		return x.Severities
+	}
+	return nil
+}
+
+type OptimizeRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Project *Project `protobuf:"bytes,1,opt,name=project,proto3" json:"project,omitempty"`
+	// goal is size, build-speed, security or all, the default
+	Goal string `protobuf:"bytes,2,opt,name=goal,proto3" json:"goal,omitempty"`
+	// platforms the image is built for, only base images published for all of them are recommended
+	Platforms []string `protobuf:"bytes,3,rep,name=platforms,proto3" json:"platforms,omitempty"`
+	// apply_risk only applies the changes of the optimization up to this risk level, all of them by default
+	ApplyRisk string `protobuf:"bytes,4,opt,name=apply_risk,json=applyRisk,proto3" json:"apply_risk,omitempty"`
+	// include_security_recommendations also hardens the container, like --include-security-recommendations
+	IncludeSecurityRecommendations bool `protobuf:"varint,5,opt,name=include_security_recommendations,json=includeSecurityRecommendations,proto3" json:"include_security_recommendations,omitempty"`
+	// explain also estimates the size impact of every action, like --explain
+	Explain bool `protobuf:"varint,6,opt,name=explain,proto3" json:"explain,omitempty"`
+}
+
+func (x *OptimizeRequest) Reset() {
+	*x = OptimizeRequest{}
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OptimizeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OptimizeRequest) ProtoMessage() {}
+
+func (x *OptimizeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OptimizeRequest.ProtoReflect.Descriptor instead.
+func (*OptimizeRequest) Descriptor() ([]byte, []int) {
+	return file_dockershrink_v1_dockershrink_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *OptimizeRequest) GetProject() *Project {
+	if x != nil {
+		return x.Project
+	}
+	return nil
+}
+
+func (x *OptimizeRequest) GetGoal() string {
+	if x != nil {
+		return x.Goal
+	}
+	return ""
+}
+
+func (x *OptimizeRequest) GetPlatforms() []string {
+	if x != nil {
+		return x.Platforms
+	}
+	return nil
+}
+
+func (x *OptimizeRequest) GetApplyRisk() string {
+	if x != nil {
+		return x.ApplyRisk
+	}
+	return ""
+}
+
+func (x *OptimizeRequest) GetIncludeSecurityRecommendations() bool {
+	if x != nil {
+		return x.IncludeSecurityRecommendations
+	}
+	return false
+}
+
+func (x *OptimizeRequest) GetExplain() bool {
+	if x != nil {
+		return x.Explain
+	}
+	return false
+}
+
+// Every message of a stream but the last has the progress of the request, the last one has its result
+type AnalyzeResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Event:
+	//	*AnalyzeResponse_Progress
+	//	*AnalyzeResponse_Result
+	Event isAnalyzeResponse_Event `protobuf_oneof:"event"`
+}
+
+func (x *AnalyzeResponse) Reset() {
+	*x = AnalyzeResponse{}
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyzeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeResponse) ProtoMessage() {}
+
+func (x *AnalyzeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeResponse.ProtoReflect.Descriptor instead.
+func (*AnalyzeResponse) Descriptor() ([]byte, []int) {
+	return file_dockershrink_v1_dockershrink_proto_rawDescGZIP(), []int{4}
+}
+
+func (m *AnalyzeResponse) GetEvent() isAnalyzeResponse_Event {
+	if m != nil {
+		return m.Event
+	}
+	return nil
+}
+
+func (x *AnalyzeResponse) GetProgress() *Progress {
+	if x, ok := x.GetEvent().(*AnalyzeResponse_Progress); ok {
+		return x.Progress
+	}
+	return nil
+}
+
+func (x *AnalyzeResponse) GetResult() *AnalyzeResult {
+	if x, ok := x.GetEvent().(*AnalyzeResponse_Result); ok {
+		return x.Result
+	}
+	return nil
+}
+
+type isAnalyzeResponse_Event interface {
+	isAnalyzeResponse_Event()
+}
+
+type AnalyzeResponse_Progress struct {
+	Progress *Progress `protobuf:"bytes,1,opt,name=progress,proto3,oneof"`
+}
+
+type AnalyzeResponse_Result struct {
+	Result *AnalyzeResult `protobuf:"bytes,2,opt,name=result,proto3,oneof"`
+}
+
+func (*AnalyzeResponse_Progress) isAnalyzeResponse_Event() {}
+
+func (*AnalyzeResponse_Result) isAnalyzeResponse_Event() {}
+
+type LintResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Event:
+	//	*LintResponse_Progress
+	//	*LintResponse_Result
+	Event isLintResponse_Event `protobuf_oneof:"event"`
+}
+
+func (x *LintResponse) Reset() {
+	*x = LintResponse{}
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LintResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LintResponse) ProtoMessage() {}
+
+func (x *LintResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LintResponse.ProtoReflect.Descriptor instead.
+func (*LintResponse) Descriptor() ([]byte, []int) {
+	return file_dockershrink_v1_dockershrink_proto_rawDescGZIP(), []int{5}
+}
+
+func (m *LintResponse) GetEvent() isLintResponse_Event {
+	if m != nil {
+		return m.Event
+	}
+	return nil
+}
+
+func (x *LintResponse) GetProgress() *Progress {
+	if x, ok := x.GetEvent().(*LintResponse_Progress); ok {
+		return x.Progress
+	}
+	return nil
+}
+
+func (x *LintResponse) GetResult() *LintResult {
+	if x, ok := x.GetEvent().(*LintResponse_Result); ok {
+		return x.Result
+	}
+	return nil
+}
+
+type isLintResponse_Event interface {
+	isLintResponse_Event()
+}
+
+type LintResponse_Progress struct {
+	Progress *Progress `protobuf:"bytes,1,opt,name=progress,proto3,oneof"`
+}
+
+type LintResponse_Result struct {
+	Result *LintResult `protobuf:"bytes,2,opt,name=result,proto3,oneof"`
+}
+
+func (*LintResponse_Progress) isLintResponse_Event() {}
+
+func (*LintResponse_Result) isLintResponse_Event() {}
+
+type OptimizeResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Event:
+	//	*OptimizeResponse_Progress
+	//	*OptimizeResponse_Result
+	Event isOptimizeResponse_Event `protobuf_oneof:"event"`
+}
+
+func (x *OptimizeResponse) Reset() {
+	*x = OptimizeResponse{}
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OptimizeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OptimizeResponse) ProtoMessage() {}
+
+func (x *OptimizeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OptimizeResponse.ProtoReflect.Descriptor instead.
+func (*OptimizeResponse) Descriptor() ([]byte, []int) {
+	return file_dockershrink_v1_dockershrink_proto_rawDescGZIP(), []int{6}
+}
+
+func (m *OptimizeResponse) GetEvent() isOptimizeResponse_Event {
+	if m != nil {
+		return m.Event
+	}
+	return nil
+}
+
+func (x *OptimizeResponse) GetProgress() *Progress {
+	if x, ok := x.GetEvent().(*OptimizeResponse_Progress); ok {
+		return x.Progress
+	}
+	return nil
+}
+
+func (x *OptimizeResponse) GetResult() *OptimizeResult {
+	if x, ok := x.GetEvent().(*OptimizeResponse_Result); ok {
+		return x.Result
+	}
+	return nil
+}
+
+type isOptimizeResponse_Event interface {
+	isOptimizeResponse_Event()
+}
+
+type OptimizeResponse_Progress struct {
+	Progress *Progress `protobuf:"bytes,1,opt,name=progress,proto3,oneof"`
+}
+
+type OptimizeResponse_Result struct {
+	Result *OptimizeResult `protobuf:"bytes,2,opt,name=result,proto3,oneof"`
+}
+
+func (*OptimizeResponse_Progress) isOptimizeResponse_Event() {}
+
+func (*OptimizeResponse_Result) isOptimizeResponse_Event() {}
+
+// Progress is a progress event of a request, see the events package for what each type means
+type Progress struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// type is analysis_started, analysis_finished, tool_call_started, tool_call_finished, rule_applied or llm_tokens_received
+	Type       string `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Operation  string `protobuf:"bytes,2,opt,name=operation,proto3" json:"operation,omitempty"`
+	Goal       string `protobuf:"bytes,3,opt,name=goal,proto3" json:"goal,omitempty"`
+	Dockerfile string `protobuf:"bytes,4,opt,name=dockerfile,proto3" json:"dockerfile,omitempty"`
+	// tool and arguments are set for the tool calls of the LLM
+	Tool      string `protobuf:"bytes,5,opt,name=tool,proto3" json:"tool,omitempty"`
+	Arguments string `protobuf:"bytes,6,opt,name=arguments,proto3" json:"arguments,omitempty"`
+	// rule, title, filepath, line and changed are set when a rule is applied
+	Rule     string `protobuf:"bytes,7,opt,name=rule,proto3" json:"rule,omitempty"`
+	Title    string `protobuf:"bytes,8,opt,name=title,proto3" json:"title,omitempty"`
+	Filepath string `protobuf:"bytes,9,opt,name=filepath,proto3" json:"filepath,omitempty"`
+	Line     int32  `protobuf:"varint,10,opt,name=line,proto3" json:"line,omitempty"`
+	Changed  bool   `protobuf:"varint,11,opt,name=changed,proto3" json:"changed,omitempty"`
+	// model and the token counts are set for every response of the LLM
+	Model            string `protobuf:"bytes,12,opt,name=model,proto3" json:"model,omitempty"`
+	PromptTokens     int64  `protobuf:"varint,13,opt,name=prompt_tokens,json=promptTokens,proto3" json:"prompt_tokens,omitempty"`
+	CompletionTokens int64  `protobuf:"varint,14,opt,name=completion_tokens,json=completionTokens,proto3" json:"completion_tokens,omitempty"`
+	Error            string `protobuf:"bytes,15,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (x *Progress) Reset() {
+	*x = Progress{}
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Progress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Progress) ProtoMessage() {}
+
+func (x *Progress) ProtoReflect() protoreflect.Message {
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Progress.ProtoReflect.Descriptor instead.
+func (*Progress) Descriptor() ([]byte, []int) {
+	return file_dockershrink_v1_dockershrink_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Progress) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Progress) GetOperation() string {
+	if x != nil {
+		return x.Operation
+	}
+	return ""
+}
+
+func (x *Progress) GetGoal() string {
+	if x != nil {
+		return x.Goal
+	}
+	return ""
+}
+
+func (x *Progress) GetDockerfile() string {
+	if x != nil {
+		return x.Dockerfile
+	}
+	return ""
+}
+
+func (x *Progress) GetTool() string {
+	if x != nil {
+		return x.Tool
+	}
+	return ""
+}
+
+func (x *Progress) GetArguments() string {
+	if x != nil {
+		return x.Arguments
+	}
+	return ""
+}
+
+func (x *Progress) GetRule() string {
+	if x != nil {
+		return x.Rule
+	}
+	return ""
+}
+
+func (x *Progress) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Progress) GetFilepath() string {
+	if x != nil {
+		return x.Filepath
+	}
+	return ""
+}
+
+func (x *Progress) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *Progress) GetChanged() bool {
+	if x != nil {
+		return x.Changed
+	}
+	return false
+}
+
+func (x *Progress) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *Progress) GetPromptTokens() int64 {
+	if x != nil {
+		return x.PromptTokens
+	}
+	return 0
+}
+
+func (x *Progress) GetCompletionTokens() int64 {
+	if x != nil {
+		return x.CompletionTokens
+	}
+	return 0
+}
+
+func (x *Progress) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type AnalyzeResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// score grades the image definition from 0 (worst) to 100 (no inefficiencies found)
+	Score          int32           `protobuf:"varint,1,opt,name=score,proto3" json:"score,omitempty"`
+	ScoreBreakdown *ScoreBreakdown `protobuf:"bytes,2,opt,name=score_breakdown,json=scoreBreakdown,proto3" json:"score_breakdown,omitempty"`
+	Findings       []*Finding      `protobuf:"bytes,3,rep,name=findings,proto3" json:"findings,omitempty"`
+	// instruction_sizes are the estimated sizes of the instructions of the final image, biggest first
+	InstructionSizes []*InstructionSize `protobuf:"bytes,4,rep,name=instruction_sizes,json=instructionSizes,proto3" json:"instruction_sizes,omitempty"`
+	// estimated_image_size is in bytes, 0 if it couldn't be estimated
+	EstimatedImageSize int64 `protobuf:"varint,5,opt,name=estimated_image_size,json=estimatedImageSize,proto3" json:"estimated_image_size,omitempty"`
+}
+
+func (x *AnalyzeResult) Reset() {
+	*x = AnalyzeResult{}
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyzeResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeResult) ProtoMessage() {}
+
+func (x *AnalyzeResult) ProtoReflect() protoreflect.Message {
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeResult.ProtoReflect.Descriptor instead.
+func (*AnalyzeResult) Descriptor() ([]byte, []int) {
+	return file_dockershrink_v1_dockershrink_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *AnalyzeResult) GetScore() int32 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *AnalyzeResult) GetScoreBreakdown() *ScoreBreakdown {
+	if x != nil {
+		return x.ScoreBreakdown
+	}
+	return nil
+}
+
+func (x *AnalyzeResult) GetFindings() []*Finding {
+	if x != nil {
+		return x.Findings
+	}
+	return nil
+}
+
+func (x *AnalyzeResult) GetInstructionSizes() []*InstructionSize {
+	if x != nil {
+		return x.InstructionSizes
+	}
+	return nil
+}
+
+func (x *AnalyzeResult) GetEstimatedImageSize() int64 {
+	if x != nil {
+		return x.EstimatedImageSize
+	}
+	return 0
+}
+
+type LintResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Findings []*Finding `protobuf:"bytes,1,rep,name=findings,proto3" json:"findings,omitempty"`
+	// failed tells whether any finding has a severity other than info, when "dockershrink lint" exits with status 3
+	Failed bool `protobuf:"varint,2,opt,name=failed,proto3" json:"failed,omitempty"`
+}
+
+func (x *LintResult) Reset() {
+	*x = LintResult{}
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LintResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LintResult) ProtoMessage() {}
+
+func (x *LintResult) ProtoReflect() protoreflect.Message {
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LintResult.ProtoReflect.Descriptor instead.
+func (*LintResult) Descriptor() ([]byte, []int) {
+	return file_dockershrink_v1_dockershrink_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *LintResult) GetFindings() []*Finding {
+	if x != nil {
+		return x.Findings
+	}
+	return nil
+}
+
+func (x *LintResult) GetFailed() bool {
+	if x != nil {
+		return x.Failed
+	}
+	return false
+}
+
+type OptimizeResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Dockerfile   string `protobuf:"bytes,1,opt,name=dockerfile,proto3" json:"dockerfile,omitempty"`
+	Dockerignore string `protobuf:"bytes,2,opt,name=dockerignore,proto3" json:"dockerignore,omitempty"`
+	// diff is the changes as a patch that can be applied to the project with "git apply", empty if nothing changed
+	Diff            string         `protobuf:"bytes,3,opt,name=diff,proto3" json:"diff,omitempty"`
+	ActionsTaken    []*Action      `protobuf:"bytes,4,rep,name=actions_taken,json=actionsTaken,proto3" json:"actions_taken,omitempty"`
+	Recommendations []*Action      `protobuf:"bytes,5,rep,name=recommendations,proto3" json:"recommendations,omitempty"`
+	BuildSecrets    []*BuildSecret `protobuf:"bytes,6,rep,name=build_secrets,json=buildSecrets,proto3" json:"build_secrets,omitempty"`
+	// the estimated sizes are in bytes, 0 if they couldn't be estimated
+	EstimatedImageSize          int64 `protobuf:"varint,7,opt,name=estimated_image_size,json=estimatedImageSize,proto3" json:"estimated_image_size,omitempty"`
+	EstimatedOptimizedImageSize int64 `protobuf:"varint,8,opt,name=estimated_optimized_image_size,json=estimatedOptimizedImageSize,proto3" json:"estimated_optimized_image_size,omitempty"`
+}
+
+func (x *OptimizeResult) Reset() {
+	*x = OptimizeResult{}
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OptimizeResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OptimizeResult) ProtoMessage() {}
+
+func (x *OptimizeResult) ProtoReflect() protoreflect.Message {
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OptimizeResult.ProtoReflect.Descriptor instead.
+func (*OptimizeResult) Descriptor() ([]byte, []int) {
+	return file_dockershrink_v1_dockershrink_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *OptimizeResult) GetDockerfile() string {
+	if x != nil {
+		return x.Dockerfile
+	}
+	return ""
+}
+
+func (x *OptimizeResult) GetDockerignore() string {
+	if x != nil {
+		return x.Dockerignore
+	}
+	return ""
+}
+
+func (x *OptimizeResult) GetDiff() string {
+	if x != nil {
+		return x.Diff
+	}
+	return ""
+}
+
+func (x *OptimizeResult) GetActionsTaken() []*Action {
+	if x != nil {
+		return x.ActionsTaken
+	}
+	return nil
+}
+
+func (x *OptimizeResult) GetRecommendations() []*Action {
+	if x != nil {
+		return x.Recommendations
+	}
+	return nil
+}
+
+func (x *OptimizeResult) GetBuildSecrets() []*BuildSecret {
+	if x != nil {
+		return x.BuildSecrets
+	}
+	return nil
+}
+
+func (x *OptimizeResult) GetEstimatedImageSize() int64 {
+	if x != nil {
+		return x.EstimatedImageSize
+	}
+	return 0
+}
+
+func (x *OptimizeResult) GetEstimatedOptimizedImageSize() int64 {
+	if x != nil {
+		return x.EstimatedOptimizedImageSize
+	}
+	return 0
+}
+
+// ScoreBreakdown grades the score and its aspects from A to F
+type ScoreBreakdown struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Grade    string       `protobuf:"bytes,1,opt,name=grade,proto3" json:"grade,omitempty"`
+	Size     *AspectScore `protobuf:"bytes,2,opt,name=size,proto3" json:"size,omitempty"`
+	Cache    *AspectScore `protobuf:"bytes,3,opt,name=cache,proto3" json:"cache,omitempty"`
+	Security *AspectScore `protobuf:"bytes,4,opt,name=security,proto3" json:"security,omitempty"`
+}
+
+func (x *ScoreBreakdown) Reset() {
+	*x = ScoreBreakdown{}
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScoreBreakdown) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScoreBreakdown) ProtoMessage() {}
+
+func (x *ScoreBreakdown) ProtoReflect() protoreflect.Message {
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScoreBreakdown.ProtoReflect.Descriptor instead.
+func (*ScoreBreakdown) Descriptor() ([]byte, []int) {
+	return file_dockershrink_v1_dockershrink_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ScoreBreakdown) GetGrade() string {
+	if x != nil {
+		return x.Grade
+	}
+	return ""
+}
+
+func (x *ScoreBreakdown) GetSize() *AspectScore {
+	if x != nil {
+		return x.Size
+	}
+	return nil
+}
+
+func (x *ScoreBreakdown) GetCache() *AspectScore {
+	if x != nil {
+		return x.Cache
+	}
+	return nil
+}
+
+func (x *ScoreBreakdown) GetSecurity() *AspectScore {
+	if x != nil {
+		return x.Security
+	}
+	return nil
+}
+
+type AspectScore struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Score    int32  `protobuf:"varint,1,opt,name=score,proto3" json:"score,omitempty"`
+	Grade    string `protobuf:"bytes,2,opt,name=grade,proto3" json:"grade,omitempty"`
+	Findings int32  `protobuf:"varint,3,opt,name=findings,proto3" json:"findings,omitempty"`
+}
+
+func (x *AspectScore) Reset() {
+	*x = AspectScore{}
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AspectScore) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AspectScore) ProtoMessage() {}
+
+func (x *AspectScore) ProtoReflect() protoreflect.Message {
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AspectScore.ProtoReflect.Descriptor instead.
+func (*AspectScore) Descriptor() ([]byte, []int) {
+	return file_dockershrink_v1_dockershrink_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *AspectScore) GetScore() int32 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *AspectScore) GetGrade() string {
+	if x != nil {
+		return x.Grade
+	}
+	return ""
+}
+
+func (x *AspectScore) GetFindings() int32 {
+	if x != nil {
+		return x.Findings
+	}
+	return 0
+}
+
+type Finding struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Rule string `protobuf:"bytes,1,opt,name=rule,proto3" json:"rule,omitempty"`
+	Code string `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	// severity is info, low, medium or high
+	Severity    string `protobuf:"bytes,3,opt,name=severity,proto3" json:"severity,omitempty"`
+	Filepath    string `protobuf:"bytes,4,opt,name=filepath,proto3" json:"filepath,omitempty"`
+	Line        int32  `protobuf:"varint,5,opt,name=line,proto3" json:"line,omitempty"`
+	Title       string `protobuf:"bytes,6,opt,name=title,proto3" json:"title,omitempty"`
+	Description string `protobuf:"bytes,7,opt,name=description,proto3" json:"description,omitempty"`
+	// estimated_size_impact is the estimated number of bytes that can be saved by fixing the finding, 0 if unknown
+	EstimatedSizeImpact int64 `protobuf:"varint,8,opt,name=estimated_size_impact,json=estimatedSizeImpact,proto3" json:"estimated_size_impact,omitempty"`
+}
+
+func (x *Finding) Reset() {
+	*x = Finding{}
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Finding) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Finding) ProtoMessage() {}
+
+func (x *Finding) ProtoReflect() protoreflect.Message {
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Finding.ProtoReflect.Descriptor instead.
+func (*Finding) Descriptor() ([]byte, []int) {
+	return file_dockershrink_v1_dockershrink_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Finding) GetRule() string {
+	if x != nil {
+		return x.Rule
+	}
+	return ""
+}
+
+func (x *Finding) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Finding) GetSeverity() string {
+	if x != nil {
+		return x.Severity
+	}
+	return ""
+}
+
+func (x *Finding) GetFilepath() string {
+	if x != nil {
+		return x.Filepath
+	}
+	return ""
+}
+
+func (x *Finding) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *Finding) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Finding) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Finding) GetEstimatedSizeImpact() int64 {
+	if x != nil {
+		return x.EstimatedSizeImpact
+	}
+	return 0
+}
+
+type InstructionSize struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Filepath      string `protobuf:"bytes,1,opt,name=filepath,proto3" json:"filepath,omitempty"`
+	Line          int32  `protobuf:"varint,2,opt,name=line,proto3" json:"line,omitempty"`
+	Instruction   string `protobuf:"bytes,3,opt,name=instruction,proto3" json:"instruction,omitempty"`
+	EstimatedSize int64  `protobuf:"varint,4,opt,name=estimated_size,json=estimatedSize,proto3" json:"estimated_size,omitempty"`
+	// basis is what the estimate is based on, eg- "build context"
+	Basis string `protobuf:"bytes,5,opt,name=basis,proto3" json:"basis,omitempty"`
+}
+
+func (x *InstructionSize) Reset() {
+	*x = InstructionSize{}
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InstructionSize) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InstructionSize) ProtoMessage() {}
+
+func (x *InstructionSize) ProtoReflect() protoreflect.Message {
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InstructionSize.ProtoReflect.Descriptor instead.
+func (*InstructionSize) Descriptor() ([]byte, []int) {
+	return file_dockershrink_v1_dockershrink_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *InstructionSize) GetFilepath() string {
+	if x != nil {
+		return x.Filepath
+	}
+	return ""
+}
+
+func (x *InstructionSize) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *InstructionSize) GetInstruction() string {
+	if x != nil {
+		return x.Instruction
+	}
+	return ""
+}
+
+func (x *InstructionSize) GetEstimatedSize() int64 {
+	if x != nil {
+		return x.EstimatedSize
+	}
+	return 0
+}
+
+func (x *InstructionSize) GetBasis() string {
+	if x != nil {
+		return x.Basis
+	}
+	return ""
+}
+
+type Action struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Rule        string `protobuf:"bytes,1,opt,name=rule,proto3" json:"rule,omitempty"`
+	Filepath    string `protobuf:"bytes,2,opt,name=filepath,proto3" json:"filepath,omitempty"`
+	Title       string `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"`
+	Description string `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+	Line        int32  `protobuf:"varint,5,opt,name=line,proto3" json:"line,omitempty"`
+	// risk is cosmetic, cache-impacting, size-impacting or behavior-changing
+	Risk        string       `protobuf:"bytes,6,opt,name=risk,proto3" json:"risk,omitempty"`
+	Explanation *Explanation `protobuf:"bytes,7,opt,name=explanation,proto3" json:"explanation,omitempty"`
+}
+
+func (x *Action) Reset() {
+	*x = Action{}
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Action) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Action) ProtoMessage() {}
+
+func (x *Action) ProtoReflect() protoreflect.Message {
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Action.ProtoReflect.Descriptor instead.
+func (*Action) Descriptor() ([]byte, []int) {
+	return file_dockershrink_v1_dockershrink_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *Action) GetRule() string {
+	if x != nil {
+		return x.Rule
+	}
+	return ""
+}
+
+func (x *Action) GetFilepath() string {
+	if x != nil {
+		return x.Filepath
+	}
+	return ""
+}
+
+func (x *Action) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Action) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Action) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *Action) GetRisk() string {
+	if x != nil {
+		return x.Risk
+	}
+	return ""
+}
+
+func (x *Action) GetExplanation() *Explanation {
+	if x != nil {
+		return x.Explanation
+	}
+	return nil
+}
+
+// Explanation tells why an action was taken and what it saves
+type Explanation struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Principle string   `protobuf:"bytes,1,opt,name=principle,proto3" json:"principle,omitempty"`
+	Rationale string   `protobuf:"bytes,2,opt,name=rationale,proto3" json:"rationale,omitempty"`
+	Docs      []string `protobuf:"bytes,3,rep,name=docs,proto3" json:"docs,omitempty"`
+	Links     []string `protobuf:"bytes,4,rep,name=links,proto3" json:"links,omitempty"`
+	// estimated_size_impact is the change in the size of the image in bytes, negative if the action shrinks it
+	EstimatedSizeImpact int64  `protobuf:"varint,5,opt,name=estimated_size_impact,json=estimatedSizeImpact,proto3" json:"estimated_size_impact,omitempty"`
+	BuildTimeImpact     string `protobuf:"bytes,6,opt,name=build_time_impact,json=buildTimeImpact,proto3" json:"build_time_impact,omitempty"`
+}
+
+func (x *Explanation) Reset() {
+	*x = Explanation{}
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Explanation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Explanation) ProtoMessage() {}
+
+func (x *Explanation) ProtoReflect() protoreflect.Message {
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Explanation.ProtoReflect.Descriptor instead.
+func (*Explanation) Descriptor() ([]byte, []int) {
+	return file_dockershrink_v1_dockershrink_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *Explanation) GetPrinciple() string {
+	if x != nil {
+		return x.Principle
+	}
+	return ""
+}
+
+func (x *Explanation) GetRationale() string {
+	if x != nil {
+		return x.Rationale
+	}
+	return ""
+}
+
+func (x *Explanation) GetDocs() []string {
+	if x != nil {
+		return x.Docs
+	}
+	return nil
+}
+
+func (x *Explanation) GetLinks() []string {
+	if x != nil {
+		return x.Links
+	}
+	return nil
+}
+
+func (x *Explanation) GetEstimatedSizeImpact() int64 {
+	if x != nil {
+		return x.EstimatedSizeImpact
+	}
+	return 0
+}
+
+func (x *Explanation) GetBuildTimeImpact() string {
+	if x != nil {
+		return x.BuildTimeImpact
+	}
+	return ""
+}
+
+// BuildSecret is a secret the optimized Dockerfile mounts, which must be passed to docker build with --secret
+type BuildSecret struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id  string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Env string `protobuf:"bytes,2,opt,name=env,proto3" json:"env,omitempty"`
+}
+
+func (x *BuildSecret) Reset() {
+	*x = BuildSecret{}
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BuildSecret) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BuildSecret) ProtoMessage() {}
+
+func (x *BuildSecret) ProtoReflect() protoreflect.Message {
+	mi := &file_dockershrink_v1_dockershrink_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BuildSecret.ProtoReflect.Descriptor instead.
+func (*BuildSecret) Descriptor() ([]byte, []int) {
+	return file_dockershrink_v1_dockershrink_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *BuildSecret) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *BuildSecret) GetEnv() string {
+	if x != nil {
+		return x.Env
+	}
+	return ""
+}
+
+var File_dockershrink_v1_dockershrink_proto protoreflect.FileDescriptor
+
+var file_dockershrink_v1_dockershrink_proto_rawDesc = []byte{
+	0x0a, 0x22, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2f, 0x76,
+	0x31, 0x2f, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0f, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72, 0x69,
+	0x6e, 0x6b, 0x2e, 0x76, 0x31, 0x22, 0xdc, 0x01, 0x0a, 0x07, 0x50, 0x72, 0x6f, 0x6a, 0x65, 0x63,
+	0x74, 0x12, 0x39, 0x0a, 0x05, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x23, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2e,
+	0x76, 0x31, 0x2e, 0x50, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x2e, 0x46, 0x69, 0x6c, 0x65, 0x73,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x05, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x12, 0x18, 0x0a, 0x07,
+	0x74, 0x61, 0x72, 0x62, 0x61, 0x6c, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x74,
+	0x61, 0x72, 0x62, 0x61, 0x6c, 0x6c, 0x12, 0x1e, 0x0a, 0x0a, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72,
+	0x66, 0x69, 0x6c, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x64, 0x6f, 0x63, 0x6b,
+	0x65, 0x72, 0x66, 0x69, 0x6c, 0x65, 0x12, 0x22, 0x0a, 0x0c, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72,
+	0x69, 0x67, 0x6e, 0x6f, 0x72, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x64, 0x6f,
+	0x63, 0x6b, 0x65, 0x72, 0x69, 0x67, 0x6e, 0x6f, 0x72, 0x65, 0x1a, 0x38, 0x0a, 0x0a, 0x46, 0x69,
+	0x6c, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x3a, 0x02, 0x38, 0x01, 0x22, 0x76, 0x0a, 0x0e, 0x41, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x32, 0x0a, 0x07, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63,
+	0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72,
+	0x73, 0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x72, 0x6f, 0x6a, 0x65, 0x63,
+	0x74, 0x52, 0x07, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x67, 0x6f,
+	0x61, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x67, 0x6f, 0x61, 0x6c, 0x12, 0x1c,
+	0x0a, 0x09, 0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x09, 0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x73, 0x22, 0x80, 0x02, 0x0a,
+	0x0b, 0x4c, 0x69, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x32, 0x0a, 0x07,
+	0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e,
+	0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2e, 0x76, 0x31, 0x2e,
+	0x50, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x52, 0x07, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74,
+	0x12, 0x12, 0x0a, 0x04, 0x67, 0x6f, 0x61, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x67, 0x6f, 0x61, 0x6c, 0x12, 0x1c, 0x0a, 0x09, 0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d,
+	0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x09, 0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72,
+	0x6d, 0x73, 0x12, 0x4c, 0x0a, 0x0a, 0x73, 0x65, 0x76, 0x65, 0x72, 0x69, 0x74, 0x69, 0x65, 0x73,
+	0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x2c, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73,
+	0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x6e, 0x74, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x2e, 0x53, 0x65, 0x76, 0x65, 0x72, 0x69, 0x74, 0x69, 0x65, 0x73, 0x45,
+	0x6e, 0x74, 0x72, 0x79, 0x52, 0x0a, 0x73, 0x65, 0x76, 0x65, 0x72, 0x69, 0x74, 0x69, 0x65, 0x73,
+	0x1a, 0x3d, 0x0a, 0x0f, 0x53, 0x65, 0x76, 0x65, 0x72, 0x69, 0x74, 0x69, 0x65, 0x73, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22,
+	0xfa, 0x01, 0x0a, 0x0f, 0x4f, 0x70, 0x74, 0x69, 0x6d, 0x69, 0x7a, 0x65, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x32, 0x0a, 0x07, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72,
+	0x69, 0x6e, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x52, 0x07,
+	0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x67, 0x6f, 0x61, 0x6c, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x67, 0x6f, 0x61, 0x6c, 0x12, 0x1c, 0x0a, 0x09, 0x70,
+	0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x09,
+	0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x73, 0x12, 0x1d, 0x0a, 0x0a, 0x61, 0x70, 0x70,
+	0x6c, 0x79, 0x5f, 0x72, 0x69, 0x73, 0x6b, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x61,
+	0x70, 0x70, 0x6c, 0x79, 0x52, 0x69, 0x73, 0x6b, 0x12, 0x48, 0x0a, 0x20, 0x69, 0x6e, 0x63, 0x6c,
+	0x75, 0x64, 0x65, 0x5f, 0x73, 0x65, 0x63, 0x75, 0x72, 0x69, 0x74, 0x79, 0x5f, 0x72, 0x65, 0x63,
+	0x6f, 0x6d, 0x6d, 0x65, 0x6e, 0x64, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x1e, 0x69, 0x6e, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x53, 0x65, 0x63, 0x75, 0x72,
+	0x69, 0x74, 0x79, 0x52, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x6e, 0x64, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x65, 0x78, 0x70, 0x6c, 0x61, 0x69, 0x6e, 0x18, 0x06, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x07, 0x65, 0x78, 0x70, 0x6c, 0x61, 0x69, 0x6e, 0x22, 0x8d, 0x01, 0x0a,
+	0x0f, 0x41, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x37, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x19, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72, 0x69, 0x6e,
+	0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x48, 0x00, 0x52,
+	0x08, 0x70, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x12, 0x38, 0x0a, 0x06, 0x72, 0x65, 0x73,
+	0x75, 0x6c, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x64, 0x6f, 0x63, 0x6b,
+	0x65, 0x72, 0x73, 0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x6e, 0x61, 0x6c,
+	0x79, 0x7a, 0x65, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x48, 0x00, 0x52, 0x06, 0x72, 0x65, 0x73,
+	0x75, 0x6c, 0x74, 0x42, 0x07, 0x0a, 0x05, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x22, 0x87, 0x01, 0x0a,
+	0x0c, 0x4c, 0x69, 0x6e, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x37, 0x0a,
+	0x08, 0x70, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x19, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2e, 0x76,
+	0x31, 0x2e, 0x50, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x48, 0x00, 0x52, 0x08, 0x70, 0x72,
+	0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x12, 0x35, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73,
+	0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x6e, 0x74, 0x52, 0x65, 0x73,
+	0x75, 0x6c, 0x74, 0x48, 0x00, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x42, 0x07, 0x0a,
+	0x05, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x22, 0x8f, 0x01, 0x0a, 0x10, 0x4f, 0x70, 0x74, 0x69, 0x6d,
+	0x69, 0x7a, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x37, 0x0a, 0x08, 0x70,
+	0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e,
+	0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2e, 0x76, 0x31, 0x2e,
+	0x50, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x48, 0x00, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x67,
+	0x72, 0x65, 0x73, 0x73, 0x12, 0x39, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72,
+	0x69, 0x6e, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x4f, 0x70, 0x74, 0x69, 0x6d, 0x69, 0x7a, 0x65, 0x52,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x48, 0x00, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x42,
+	0x07, 0x0a, 0x05, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x22, 0x94, 0x03, 0x0a, 0x08, 0x50, 0x72, 0x6f,
+	0x67, 0x72, 0x65, 0x73, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x6f, 0x70, 0x65,
+	0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6f, 0x70,
+	0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x67, 0x6f, 0x61, 0x6c, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x67, 0x6f, 0x61, 0x6c, 0x12, 0x1e, 0x0a, 0x0a, 0x64,
+	0x6f, 0x63, 0x6b, 0x65, 0x72, 0x66, 0x69, 0x6c, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0a, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x66, 0x69, 0x6c, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x74,
+	0x6f, 0x6f, 0x6c, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x6f, 0x6f, 0x6c, 0x12,
+	0x1c, 0x0a, 0x09, 0x61, 0x72, 0x67, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x09, 0x61, 0x72, 0x67, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x12, 0x0a,
+	0x04, 0x72, 0x75, 0x6c, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x75, 0x6c,
+	0x65, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x69, 0x6c, 0x65, 0x70,
+	0x61, 0x74, 0x68, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x66, 0x69, 0x6c, 0x65, 0x70,
+	0x61, 0x74, 0x68, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x0a, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x68, 0x61, 0x6e, 0x67,
+	0x65, 0x64, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65,
+	0x64, 0x12, 0x14, 0x0a, 0x05, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x05, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x12, 0x23, 0x0a, 0x0d, 0x70, 0x72, 0x6f, 0x6d, 0x70,
+	0x74, 0x5f, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x73, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0c,
+	0x70, 0x72, 0x6f, 0x6d, 0x70, 0x74, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x73, 0x12, 0x2b, 0x0a, 0x11,
+	0x63, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x74, 0x6f, 0x6b, 0x65, 0x6e,
+	0x73, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x03, 0x52, 0x10, 0x63, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74,
+	0x69, 0x6f, 0x6e, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72,
+	0x6f, 0x72, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x22,
+	0xa6, 0x02, 0x0a, 0x0d, 0x41, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x52, 0x65, 0x73, 0x75, 0x6c,
+	0x74, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x05, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x12, 0x48, 0x0a, 0x0f, 0x73, 0x63, 0x6f, 0x72, 0x65,
+	0x5f, 0x62, 0x72, 0x65, 0x61, 0x6b, 0x64, 0x6f, 0x77, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x1f, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2e,
+	0x76, 0x31, 0x2e, 0x53, 0x63, 0x6f, 0x72, 0x65, 0x42, 0x72, 0x65, 0x61, 0x6b, 0x64, 0x6f, 0x77,
+	0x6e, 0x52, 0x0e, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x42, 0x72, 0x65, 0x61, 0x6b, 0x64, 0x6f, 0x77,
+	0x6e, 0x12, 0x34, 0x0a, 0x08, 0x66, 0x69, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x73, 0x18, 0x03, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72, 0x69,
+	0x6e, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x46, 0x69, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x52, 0x08, 0x66,
+	0x69, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x73, 0x12, 0x4d, 0x0a, 0x11, 0x69, 0x6e, 0x73, 0x74, 0x72,
+	0x75, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x73, 0x18, 0x04, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x20, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72, 0x69, 0x6e,
+	0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x49, 0x6e, 0x73, 0x74, 0x72, 0x75, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+	0x53, 0x69, 0x7a, 0x65, 0x52, 0x10, 0x69, 0x6e, 0x73, 0x74, 0x72, 0x75, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x53, 0x69, 0x7a, 0x65, 0x73, 0x12, 0x30, 0x0a, 0x14, 0x65, 0x73, 0x74, 0x69, 0x6d, 0x61,
+	0x74, 0x65, 0x64, 0x5f, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x12, 0x65, 0x73, 0x74, 0x69, 0x6d, 0x61, 0x74, 0x65, 0x64, 0x49,
+	0x6d, 0x61, 0x67, 0x65, 0x53, 0x69, 0x7a, 0x65, 0x22, 0x5a, 0x0a, 0x0a, 0x4c, 0x69, 0x6e, 0x74,
+	0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x34, 0x0a, 0x08, 0x66, 0x69, 0x6e, 0x64, 0x69, 0x6e,
+	0x67, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65,
+	0x72, 0x73, 0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x46, 0x69, 0x6e, 0x64, 0x69,
+	0x6e, 0x67, 0x52, 0x08, 0x66, 0x69, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x73, 0x12, 0x16, 0x0a, 0x06,
+	0x66, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x66, 0x61,
+	0x69, 0x6c, 0x65, 0x64, 0x22, 0xa3, 0x03, 0x0a, 0x0e, 0x4f, 0x70, 0x74, 0x69, 0x6d, 0x69, 0x7a,
+	0x65, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x1e, 0x0a, 0x0a, 0x64, 0x6f, 0x63, 0x6b, 0x65,
+	0x72, 0x66, 0x69, 0x6c, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x64, 0x6f, 0x63,
+	0x6b, 0x65, 0x72, 0x66, 0x69, 0x6c, 0x65, 0x12, 0x22, 0x0a, 0x0c, 0x64, 0x6f, 0x63, 0x6b, 0x65,
+	0x72, 0x69, 0x67, 0x6e, 0x6f, 0x72, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x64,
+	0x6f, 0x63, 0x6b, 0x65, 0x72, 0x69, 0x67, 0x6e, 0x6f, 0x72, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x64,
+	0x69, 0x66, 0x66, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x64, 0x69, 0x66, 0x66, 0x12,
+	0x3c, 0x0a, 0x0d, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x5f, 0x74, 0x61, 0x6b, 0x65, 0x6e,
+	0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73,
+	0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52,
+	0x0c, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x54, 0x61, 0x6b, 0x65, 0x6e, 0x12, 0x41, 0x0a,
+	0x0f, 0x72, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x6e, 0x64, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73,
+	0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73,
+	0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52,
+	0x0f, 0x72, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x6e, 0x64, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73,
+	0x12, 0x41, 0x0a, 0x0d, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x5f, 0x73, 0x65, 0x63, 0x72, 0x65, 0x74,
+	0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72,
+	0x73, 0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x53,
+	0x65, 0x63, 0x72, 0x65, 0x74, 0x52, 0x0c, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x53, 0x65, 0x63, 0x72,
+	0x65, 0x74, 0x73, 0x12, 0x30, 0x0a, 0x14, 0x65, 0x73, 0x74, 0x69, 0x6d, 0x61, 0x74, 0x65, 0x64,
+	0x5f, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x12, 0x65, 0x73, 0x74, 0x69, 0x6d, 0x61, 0x74, 0x65, 0x64, 0x49, 0x6d, 0x61, 0x67,
+	0x65, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x43, 0x0a, 0x1e, 0x65, 0x73, 0x74, 0x69, 0x6d, 0x61, 0x74,
+	0x65, 0x64, 0x5f, 0x6f, 0x70, 0x74, 0x69, 0x6d, 0x69, 0x7a, 0x65, 0x64, 0x5f, 0x69, 0x6d, 0x61,
+	0x67, 0x65, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x08, 0x20, 0x01, 0x28, 0x03, 0x52, 0x1b, 0x65,
+	0x73, 0x74, 0x69, 0x6d, 0x61, 0x74, 0x65, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6d, 0x69, 0x7a, 0x65,
+	0x64, 0x49, 0x6d, 0x61, 0x67, 0x65, 0x53, 0x69, 0x7a, 0x65, 0x22, 0xc6, 0x01, 0x0a, 0x0e, 0x53,
+	0x63, 0x6f, 0x72, 0x65, 0x42, 0x72, 0x65, 0x61, 0x6b, 0x64, 0x6f, 0x77, 0x6e, 0x12, 0x14, 0x0a,
+	0x05, 0x67, 0x72, 0x61, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x67, 0x72,
+	0x61, 0x64, 0x65, 0x12, 0x30, 0x0a, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1c, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72, 0x69, 0x6e, 0x6b,
+	0x2e, 0x76, 0x31, 0x2e, 0x41, 0x73, 0x70, 0x65, 0x63, 0x74, 0x53, 0x63, 0x6f, 0x72, 0x65, 0x52,
+	0x04, 0x73, 0x69, 0x7a, 0x65, 0x12, 0x32, 0x0a, 0x05, 0x63, 0x61, 0x63, 0x68, 0x65, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72,
+	0x69, 0x6e, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x73, 0x70, 0x65, 0x63, 0x74, 0x53, 0x63, 0x6f,
+	0x72, 0x65, 0x52, 0x05, 0x63, 0x61, 0x63, 0x68, 0x65, 0x12, 0x38, 0x0a, 0x08, 0x73, 0x65, 0x63,
+	0x75, 0x72, 0x69, 0x74, 0x79, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x64, 0x6f,
+	0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x73,
+	0x70, 0x65, 0x63, 0x74, 0x53, 0x63, 0x6f, 0x72, 0x65, 0x52, 0x08, 0x73, 0x65, 0x63, 0x75, 0x72,
+	0x69, 0x74, 0x79, 0x22, 0x55, 0x0a, 0x0b, 0x41, 0x73, 0x70, 0x65, 0x63, 0x74, 0x53, 0x63, 0x6f,
+	0x72, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x05, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x67, 0x72, 0x61, 0x64,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x67, 0x72, 0x61, 0x64, 0x65, 0x12, 0x1a,
+	0x0a, 0x08, 0x66, 0x69, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x08, 0x66, 0x69, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x73, 0x22, 0xe9, 0x01, 0x0a, 0x07, 0x46,
+	0x69, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x75, 0x6c, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x75, 0x6c, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f,
+	0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x1a,
+	0x0a, 0x08, 0x73, 0x65, 0x76, 0x65, 0x72, 0x69, 0x74, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x08, 0x73, 0x65, 0x76, 0x65, 0x72, 0x69, 0x74, 0x79, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x69,
+	0x6c, 0x65, 0x70, 0x61, 0x74, 0x68, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x66, 0x69,
+	0x6c, 0x65, 0x70, 0x61, 0x74, 0x68, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x69,
+	0x74, 0x6c, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65,
+	0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18,
+	0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69,
+	0x6f, 0x6e, 0x12, 0x32, 0x0a, 0x15, 0x65, 0x73, 0x74, 0x69, 0x6d, 0x61, 0x74, 0x65, 0x64, 0x5f,
+	0x73, 0x69, 0x7a, 0x65, 0x5f, 0x69, 0x6d, 0x70, 0x61, 0x63, 0x74, 0x18, 0x08, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x13, 0x65, 0x73, 0x74, 0x69, 0x6d, 0x61, 0x74, 0x65, 0x64, 0x53, 0x69, 0x7a, 0x65,
+	0x49, 0x6d, 0x70, 0x61, 0x63, 0x74, 0x22, 0xa0, 0x01, 0x0a, 0x0f, 0x49, 0x6e, 0x73, 0x74, 0x72,
+	0x75, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x69,
+	0x6c, 0x65, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x66, 0x69,
+	0x6c, 0x65, 0x70, 0x61, 0x74, 0x68, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x69, 0x6e,
+	0x73, 0x74, 0x72, 0x75, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0b, 0x69, 0x6e, 0x73, 0x74, 0x72, 0x75, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x25, 0x0a, 0x0e,
+	0x65, 0x73, 0x74, 0x69, 0x6d, 0x61, 0x74, 0x65, 0x64, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x65, 0x73, 0x74, 0x69, 0x6d, 0x61, 0x74, 0x65, 0x64, 0x53,
+	0x69, 0x7a, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x62, 0x61, 0x73, 0x69, 0x73, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x62, 0x61, 0x73, 0x69, 0x73, 0x22, 0xd8, 0x01, 0x0a, 0x06, 0x41, 0x63,
+	0x74, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x75, 0x6c, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x72, 0x75, 0x6c, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x69, 0x6c, 0x65,
+	0x70, 0x61, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x66, 0x69, 0x6c, 0x65,
+	0x70, 0x61, 0x74, 0x68, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65,
+	0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04,
+	0x6c, 0x69, 0x6e, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x6c, 0x69, 0x6e, 0x65,
+	0x12, 0x12, 0x0a, 0x04, 0x72, 0x69, 0x73, 0x6b, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x72, 0x69, 0x73, 0x6b, 0x12, 0x3e, 0x0a, 0x0b, 0x65, 0x78, 0x70, 0x6c, 0x61, 0x6e, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x64, 0x6f, 0x63, 0x6b,
+	0x65, 0x72, 0x73, 0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x78, 0x70, 0x6c,
+	0x61, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0b, 0x65, 0x78, 0x70, 0x6c, 0x61, 0x6e, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x22, 0xd3, 0x01, 0x0a, 0x0b, 0x45, 0x78, 0x70, 0x6c, 0x61, 0x6e, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1c, 0x0a, 0x09, 0x70, 0x72, 0x69, 0x6e, 0x63, 0x69, 0x70, 0x6c,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x70, 0x72, 0x69, 0x6e, 0x63, 0x69, 0x70,
+	0x6c, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x61, 0x6c, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x61, 0x6c, 0x65,
+	0x12, 0x12, 0x0a, 0x04, 0x64, 0x6f, 0x63, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04,
+	0x64, 0x6f, 0x63, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6e, 0x6b, 0x73, 0x18, 0x04, 0x20,
+	0x03, 0x28, 0x09, 0x52, 0x05, 0x6c, 0x69, 0x6e, 0x6b, 0x73, 0x12, 0x32, 0x0a, 0x15, 0x65, 0x73,
+	0x74, 0x69, 0x6d, 0x61, 0x74, 0x65, 0x64, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x5f, 0x69, 0x6d, 0x70,
+	0x61, 0x63, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x13, 0x65, 0x73, 0x74, 0x69, 0x6d,
+	0x61, 0x74, 0x65, 0x64, 0x53, 0x69, 0x7a, 0x65, 0x49, 0x6d, 0x70, 0x61, 0x63, 0x74, 0x12, 0x2a,
+	0x0a, 0x11, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x69, 0x6d, 0x70,
+	0x61, 0x63, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0f, 0x62, 0x75, 0x69, 0x6c, 0x64,
+	0x54, 0x69, 0x6d, 0x65, 0x49, 0x6d, 0x70, 0x61, 0x63, 0x74, 0x22, 0x2f, 0x0a, 0x0b, 0x42, 0x75,
+	0x69, 0x6c, 0x64, 0x53, 0x65, 0x63, 0x72, 0x65, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x65, 0x6e, 0x76,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x65, 0x6e, 0x76, 0x32, 0xf8, 0x01, 0x0a, 0x0c,
+	0x44, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x53, 0x68, 0x72, 0x69, 0x6e, 0x6b, 0x12, 0x4e, 0x0a, 0x07,
+	0x41, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x12, 0x1f, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72,
+	0x73, 0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x6e, 0x61, 0x6c, 0x79, 0x7a,
+	0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x20, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65,
+	0x72, 0x73, 0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x6e, 0x61, 0x6c, 0x79,
+	0x7a, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01, 0x12, 0x45, 0x0a, 0x04,
+	0x4c, 0x69, 0x6e, 0x74, 0x12, 0x1c, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72,
+	0x69, 0x6e, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x1d, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72, 0x69, 0x6e,
+	0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x6e, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x30, 0x01, 0x12, 0x51, 0x0a, 0x08, 0x4f, 0x70, 0x74, 0x69, 0x6d, 0x69, 0x7a, 0x65, 0x12,
+	0x20, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2e, 0x76,
+	0x31, 0x2e, 0x4f, 0x70, 0x74, 0x69, 0x6d, 0x69, 0x7a, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x21, 0x2e, 0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72, 0x69, 0x6e, 0x6b,
+	0x2e, 0x76, 0x31, 0x2e, 0x4f, 0x70, 0x74, 0x69, 0x6d, 0x69, 0x7a, 0x65, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01, 0x42, 0x35, 0x5a, 0x33, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62,
+	0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x64, 0x75, 0x61, 0x72, 0x61, 0x67, 0x68, 0x61, 0x76, 0x38, 0x2f,
+	0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x73, 0x68, 0x72, 0x69, 0x6e, 0x6b, 0x2f, 0x70, 0x6b, 0x67,
+	0x2f, 0x61, 0x70, 0x69, 0x2f, 0x76, 0x31, 0x3b, 0x61, 0x70, 0x69, 0x76, 0x31, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_dockershrink_v1_dockershrink_proto_rawDescOnce sync.Once
+	file_dockershrink_v1_dockershrink_proto_rawDescData = file_dockershrink_v1_dockershrink_proto_rawDesc
+)
+
+func file_dockershrink_v1_dockershrink_proto_rawDescGZIP() []byte {
+	file_dockershrink_v1_dockershrink_proto_rawDescOnce.Do(func() {
+		file_dockershrink_v1_dockershrink_proto_rawDescData = protoimpl.X.CompressGZIP(file_dockershrink_v1_dockershrink_proto_rawDescData)
+	})
+	return file_dockershrink_v1_dockershrink_proto_rawDescData
+}
+
+var file_dockershrink_v1_dockershrink_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
+var file_dockershrink_v1_dockershrink_proto_goTypes = []any{
+	(*Project)(nil),          // 0: dockershrink.v1.Project
+	(*AnalyzeRequest)(nil),   // 1: dockershrink.v1.AnalyzeRequest
+	(*LintRequest)(nil),      // 2: dockershrink.v1.LintRequest
+	(*OptimizeRequest)(nil),  // 3: dockershrink.v1.OptimizeRequest
+	(*AnalyzeResponse)(nil),  // 4: dockershrink.v1.AnalyzeResponse
+	(*LintResponse)(nil),     // 5: dockershrink.v1.LintResponse
+	(*OptimizeResponse)(nil), // 6: dockershrink.v1.OptimizeResponse
+	(*Progress)(nil),         // 7: dockershrink.v1.Progress
+	(*AnalyzeResult)(nil),    // 8: dockershrink.v1.AnalyzeResult
+	(*LintResult)(nil),       // 9: dockershrink.v1.LintResult
+	(*OptimizeResult)(nil),   // 10: dockershrink.v1.OptimizeResult
+	(*ScoreBreakdown)(nil),   // 11: dockershrink.v1.ScoreBreakdown
+	(*AspectScore)(nil),      // 12: dockershrink.v1.AspectScore
+	(*Finding)(nil),          // 13: dockershrink.v1.Finding
+	(*InstructionSize)(nil),  // 14: dockershrink.v1.InstructionSize
+	(*Action)(nil),           // 15: dockershrink.v1.Action
+	(*Explanation)(nil),      // 16: dockershrink.v1.Explanation
+	(*BuildSecret)(nil),      // 17: dockershrink.v1.BuildSecret
+	nil,                      // 18: dockershrink.v1.Project.FilesEntry
+	nil,                      // 19: dockershrink.v1.LintRequest.SeveritiesEntry
+}
+var file_dockershrink_v1_dockershrink_proto_depIdxs = []int32{
+	18, // 0: dockershrink.v1.Project.files:type_name -> dockershrink.v1.Project.FilesEntry
+	0,  // 1: dockershrink.v1.AnalyzeRequest.project:type_name -> dockershrink.v1.Project
+	0,  // 2: dockershrink.v1.LintRequest.project:type_name -> dockershrink.v1.Project
+	19, // 3: dockershrink.v1.LintRequest.severities:type_name -> dockershrink.v1.LintRequest.SeveritiesEntry
+	0,  // 4: dockershrink.v1.OptimizeRequest.project:type_name -> dockershrink.v1.Project
+	7,  // 5: dockershrink.v1.AnalyzeResponse.progress:type_name -> dockershrink.v1.Progress
+	8,  // 6: dockershrink.v1.AnalyzeResponse.result:type_name -> dockershrink.v1.AnalyzeResult
+	7,  // 7: dockershrink.v1.LintResponse.progress:type_name -> dockershrink.v1.Progress
+	9,  // 8: dockershrink.v1.LintResponse.result:type_name -> dockershrink.v1.LintResult
+	7,  // 9: dockershrink.v1.OptimizeResponse.progress:type_name -> dockershrink.v1.Progress
+	10, // 10: dockershrink.v1.OptimizeResponse.result:type_name -> dockershrink.v1.OptimizeResult
+	11, // 11: dockershrink.v1.AnalyzeResult.score_breakdown:type_name -> dockershrink.v1.ScoreBreakdown
+	13, // 12: dockershrink.v1.AnalyzeResult.findings:type_name -> dockershrink.v1.Finding
+	14, // 13: dockershrink.v1.AnalyzeResult.instruction_sizes:type_name -> dockershrink.v1.InstructionSize
+	13, // 14: dockershrink.v1.LintResult.findings:type_name -> dockershrink.v1.Finding
+	15, // 15: dockershrink.v1.OptimizeResult.actions_taken:type_name -> dockershrink.v1.Action
+	15, // 16: dockershrink.v1.OptimizeResult.recommendations:type_name -> dockershrink.v1.Action
+	17, // 17: dockershrink.v1.OptimizeResult.build_secrets:type_name -> dockershrink.v1.BuildSecret
+	12, // 18: dockershrink.v1.ScoreBreakdown.size:type_name -> dockershrink.v1.AspectScore
+	12, // 19: dockershrink.v1.ScoreBreakdown.cache:type_name -> dockershrink.v1.AspectScore
+	12, // 20: dockershrink.v1.ScoreBreakdown.security:type_name -> dockershrink.v1.AspectScore
+	16, // 21: dockershrink.v1.Action.explanation:type_name -> dockershrink.v1.Explanation
+	1,  // 22: dockershrink.v1.DockerShrink.Analyze:input_type -> dockershrink.v1.AnalyzeRequest
+	2,  // 23: dockershrink.v1.DockerShrink.Lint:input_type -> dockershrink.v1.LintRequest
+	3,  // 24: dockershrink.v1.DockerShrink.Optimize:input_type -> dockershrink.v1.OptimizeRequest
+	4,  // 25: dockershrink.v1.DockerShrink.Analyze:output_type -> dockershrink.v1.AnalyzeResponse
+	5,  // 26: dockershrink.v1.DockerShrink.Lint:output_type -> dockershrink.v1.LintResponse
+	6,  // 27: dockershrink.v1.DockerShrink.Optimize:output_type -> dockershrink.v1.OptimizeResponse
+	25, // [25:28] is the sub-list for method output_type
+	22, // [22:25] is the sub-list for method input_type
+	22, // [22:22] is the sub-list for extension type_name
+	22, // [22:22] is the sub-list for extension extendee
+	0,  // [0:22] is the sub-list for field type_name
+}
+
+func init() { file_dockershrink_v1_dockershrink_proto_init() }
+func file_dockershrink_v1_dockershrink_proto_init() {
+	if File_dockershrink_v1_dockershrink_proto != nil {
+		return
+	}
+	file_dockershrink_v1_dockershrink_proto_msgTypes[4].OneofWrappers = []any{
+		(*AnalyzeResponse_Progress)(nil),
+		(*AnalyzeResponse_Result)(nil),
+	}
+	file_dockershrink_v1_dockershrink_proto_msgTypes[5].OneofWrappers = []any{
+		(*LintResponse_Progress)(nil),
+		(*LintResponse_Result)(nil),
+	}
+	file_dockershrink_v1_dockershrink_proto_msgTypes[6].OneofWrappers = []any{
+		(*OptimizeResponse_Progress)(nil),
+		(*OptimizeResponse_Result)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_dockershrink_v1_dockershrink_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   20,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_dockershrink_v1_dockershrink_proto_goTypes,
+		DependencyIndexes: file_dockershrink_v1_dockershrink_proto_depIdxs,
+		MessageInfos:      file_dockershrink_v1_dockershrink_proto_msgTypes,
+	}.Build()
+	File_dockershrink_v1_dockershrink_proto = out.File
+	file_dockershrink_v1_dockershrink_proto_rawDesc = nil
+	file_dockershrink_v1_dockershrink_proto_goTypes = nil
+	file_dockershrink_v1_dockershrink_proto_depIdxs = nil
+}
@@ -0,0 +1,222 @@
+// The gRPC API of "dockershrink serve", for platform teams embedding dockershrink in their build systems.
+//
+// Every RPC uploads the project and streams the progress of the request as it runs, followed by its result.
+// Failed requests end with a status instead: INVALID_ARGUMENT for invalid options or uploads, FAILED_PRECONDITION
+// when the project can't be analyzed, eg- its Dockerfile doesn't parse, RESOURCE_EXHAUSTED when the project is
+// larger than the server accepts and UNAUTHENTICATED when the server's token isn't sent as "authorization: Bearer <token>".
+//
+// The Go client is generated in github.com/duaraghav8/dockershrink/pkg/api/v1, run "go generate ./pkg/api/..."
+// after changing this file. Clients in other languages are generated from it with protoc.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.28.3
+// source: dockershrink/v1/dockershrink.proto
+
+package apiv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	DockerShrink_Analyze_FullMethodName  = "/dockershrink.v1.DockerShrink/Analyze"
+	DockerShrink_Lint_FullMethodName     = "/dockershrink.v1.DockerShrink/Lint"
+	DockerShrink_Optimize_FullMethodName = "/dockershrink.v1.DockerShrink/Optimize"
+)
+
+// DockerShrinkClient is the client API for DockerShrink service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DockerShrinkClient interface {
+	// Analyze scores the project and returns its findings, like "dockershrink analyze"
+	Analyze(ctx context.Context, in *AnalyzeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AnalyzeResponse], error)
+	// Lint checks the project against the rules, like "dockershrink lint"
+	Lint(ctx context.Context, in *LintRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LintResponse], error)
+	// Optimize returns the optimized Dockerfile and .dockerignore along with a patch, like "dockershrink optimize"
+	Optimize(ctx context.Context, in *OptimizeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[OptimizeResponse], error)
+}
+
+type dockerShrinkClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDockerShrinkClient(cc grpc.ClientConnInterface) DockerShrinkClient {
+	return &dockerShrinkClient{cc}
+}
+
+func (c *dockerShrinkClient) Analyze(ctx context.Context, in *AnalyzeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AnalyzeResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DockerShrink_ServiceDesc.Streams[0], DockerShrink_Analyze_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[AnalyzeRequest, AnalyzeResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DockerShrink_AnalyzeClient = grpc.ServerStreamingClient[AnalyzeResponse]
+
+func (c *dockerShrinkClient) Lint(ctx context.Context, in *LintRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LintResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DockerShrink_ServiceDesc.Streams[1], DockerShrink_Lint_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[LintRequest, LintResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DockerShrink_LintClient = grpc.ServerStreamingClient[LintResponse]
+
+func (c *dockerShrinkClient) Optimize(ctx context.Context, in *OptimizeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[OptimizeResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DockerShrink_ServiceDesc.Streams[2], DockerShrink_Optimize_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[OptimizeRequest, OptimizeResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DockerShrink_OptimizeClient = grpc.ServerStreamingClient[OptimizeResponse]
+
+// DockerShrinkServer is the server API for DockerShrink service.
+// All implementations must embed UnimplementedDockerShrinkServer
+// for forward compatibility.
+type DockerShrinkServer interface {
+	// Analyze scores the project and returns its findings, like "dockershrink analyze"
+	Analyze(*AnalyzeRequest, grpc.ServerStreamingServer[AnalyzeResponse]) error
+	// Lint checks the project against the rules, like "dockershrink lint"
+	Lint(*LintRequest, grpc.ServerStreamingServer[LintResponse]) error
+	// Optimize returns the optimized Dockerfile and .dockerignore along with a patch, like "dockershrink optimize"
+	Optimize(*OptimizeRequest, grpc.ServerStreamingServer[OptimizeResponse]) error
+	mustEmbedUnimplementedDockerShrinkServer()
+}
+
+// UnimplementedDockerShrinkServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDockerShrinkServer struct{}
+
+func (UnimplementedDockerShrinkServer) Analyze(*AnalyzeRequest, grpc.ServerStreamingServer[AnalyzeResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Analyze not implemented")
+}
+func (UnimplementedDockerShrinkServer) Lint(*LintRequest, grpc.ServerStreamingServer[LintResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Lint not implemented")
+}
+func (UnimplementedDockerShrinkServer) Optimize(*OptimizeRequest, grpc.ServerStreamingServer[OptimizeResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Optimize not implemented")
+}
+func (UnimplementedDockerShrinkServer) mustEmbedUnimplementedDockerShrinkServer() {}
+func (UnimplementedDockerShrinkServer) testEmbeddedByValue()                      {}
+
+// UnsafeDockerShrinkServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DockerShrinkServer will
+// result in compilation errors.
+type UnsafeDockerShrinkServer interface {
+	mustEmbedUnimplementedDockerShrinkServer()
+}
+
+func RegisterDockerShrinkServer(s grpc.ServiceRegistrar, srv DockerShrinkServer) {
+	// If the following call pancis, it indicates UnimplementedDockerShrinkServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&DockerShrink_ServiceDesc, srv)
+}
+
+func _DockerShrink_Analyze_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(AnalyzeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DockerShrinkServer).Analyze(m, &grpc.GenericServerStream[AnalyzeRequest, AnalyzeResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DockerShrink_AnalyzeServer = grpc.ServerStreamingServer[AnalyzeResponse]
+
+func _DockerShrink_Lint_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(LintRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DockerShrinkServer).Lint(m, &grpc.GenericServerStream[LintRequest, LintResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DockerShrink_LintServer = grpc.ServerStreamingServer[LintResponse]
+
+func _DockerShrink_Optimize_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(OptimizeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DockerShrinkServer).Optimize(m, &grpc.GenericServerStream[OptimizeRequest, OptimizeResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DockerShrink_OptimizeServer = grpc.ServerStreamingServer[OptimizeResponse]
+
+// DockerShrink_ServiceDesc is the grpc.ServiceDesc for DockerShrink service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DockerShrink_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dockershrink.v1.DockerShrink",
+	HandlerType: (*DockerShrinkServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Analyze",
+			Handler:       _DockerShrink_Analyze_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Lint",
+			Handler:       _DockerShrink_Lint_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Optimize",
+			Handler:       _DockerShrink_Optimize_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "dockershrink/v1/dockershrink.proto",
+}
@@ -0,0 +1,5 @@
+// Package apiv1 is the Go client and server code of dockershrink's gRPC API, generated from
+// proto/dockershrink/v1/dockershrink.proto. Connect to "dockershrink serve --grpc-listen" with NewDockerShrinkClient.
+package apiv1
+
+//go:generate protoc -I ../../../proto --go_out=../../.. --go_opt=module=github.com/duaraghav8/dockershrink --go-grpc_out=../../.. --go-grpc_opt=module=github.com/duaraghav8/dockershrink dockershrink/v1/dockershrink.proto
@@ -0,0 +1,460 @@
+// Package dockershrink embeds the dockershrink engine in other Go programs, eg- CI bots and IDE plugins.
+// It analyzes and optimizes the Docker image definition of a NodeJS project the same way the CLI does,
+// without printing anything or reading the CLI's flags and configuration files.
+//
+// Subscribe to Events to follow the progress of an operation.
+package dockershrink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/duaraghav8/dockershrink/internal/ai"
+	"github.com/duaraghav8/dockershrink/internal/baseimages"
+	"github.com/duaraghav8/dockershrink/internal/diff"
+	"github.com/duaraghav8/dockershrink/internal/dockerfile"
+	"github.com/duaraghav8/dockershrink/internal/dockerignore"
+	"github.com/duaraghav8/dockershrink/internal/log"
+	"github.com/duaraghav8/dockershrink/internal/models"
+	"github.com/duaraghav8/dockershrink/internal/packagejson"
+	"github.com/duaraghav8/dockershrink/internal/platform"
+	"github.com/duaraghav8/dockershrink/internal/project"
+	"github.com/duaraghav8/dockershrink/internal/restrictedfilesystem"
+	"github.com/duaraghav8/dockershrink/internal/rules"
+	"github.com/duaraghav8/dockershrink/internal/tree"
+	"github.com/duaraghav8/dockershrink/internal/workspace"
+	"github.com/duaraghav8/dockershrink/pkg/events"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+type (
+	// Finding is an inefficiency found in the image definition
+	Finding = models.Finding
+	// Action is a change made to the image definition, or recommended if it wasn't made
+	Action = models.OptimizationAction
+	// InstructionSize is what an instruction of the final stage adds to the size of the image
+	InstructionSize = models.InstructionSize
+	// Variant is the analysis of the image definition under a combination of build arguments
+	Variant = models.Variant
+	// ScoreBreakdown grades the image definition as a whole and on its size efficiency, cache friendliness and security posture
+	ScoreBreakdown = models.ScoreBreakdown
+	// AspectScore grades one aspect of the image definition from 0 to 100
+	AspectScore = models.AspectScore
+	// Explanation is why an action was taken, the practice behind it and what it saves
+	Explanation = models.Explanation
+	// Severity is how much a finding affects the image
+	Severity = models.Severity
+)
+
+const (
+	SeverityInfo   = models.SeverityInfo
+	SeverityLow    = models.SeverityLow
+	SeverityMedium = models.SeverityMedium
+	SeverityHigh   = models.SeverityHigh
+	// SeverityOff disables a rule when used as its severity override
+	SeverityOff = rules.SeverityOff
+)
+
+var (
+	// ErrInvalidInput is wrapped by the errors about invalid options, eg- an unknown goal
+	ErrInvalidInput = errors.New("invalid input")
+	// ErrInvalidProject is wrapped by the errors about projects that can't be loaded, eg- because the Dockerfile is missing or invalid
+	ErrInvalidProject = errors.New("invalid project")
+)
+
+// kindError is an error of a kind, like ErrInvalidInput, that keeps the message of the error
+type kindError struct {
+	err  error
+	kind error
+}
+
+func (e *kindError) Error() string   { return e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.err, e.kind} }
+
+// max time allowed for fetching live data about base images
+const baseImagesTimeout = 20 * time.Second
+
+// max number of characters of the directory tree sent to the LLM
+const dirTreeLimit = 4400
+
+// files larger than this are left out of the tree sent to the LLM
+const maxTreeFileSize = 1024 * 1024
+
+// directories left out of the tree sent to the LLM
+var dirsExcludedFromTree = []string{"node_modules", ".git", ".npm", ".yarn", ".cache", "vendor"}
+
+// Project locates the image definition of a project
+type Project struct {
+	// Dir is the root directory of the project
+	Dir string
+	// Dockerfile and Dockerignore are paths relative to Dir, "Dockerfile" and ".dockerignore" by default.
+	// The project may have no .dockerignore.
+	Dockerfile   string
+	Dockerignore string
+}
+
+// AnalyzeInput is what Analyze analyzes, and how
+type AnalyzeInput struct {
+	Project
+	// Goal is what the image is analyzed for: size, build-speed, security or all, the default
+	Goal string
+	// Platforms the image is built for, eg- linux/arm64. Base images are checked against all of them.
+	Platforms []string
+	// Severities overrides the severity of rules, keyed by rule ID or name. SeverityOff disables a rule.
+	Severities map[string]Severity
+	// Offline only uses cached or built-in data about base images, instead of fetching it
+	Offline bool
+	// Events receives the progress of the analysis, it's discarded if nil
+	Events events.Handler
+}
+
+// AnalyzeResult is the outcome of an analysis
+type AnalyzeResult struct {
+	// Score grades the image definition from 0 (worst) to 100 (no inefficiencies found)
+	Score int
+	// ScoreBreakdown grades the score and its aspects from A to F
+	ScoreBreakdown *ScoreBreakdown
+	Findings       []*Finding
+	// InstructionSizes are the estimated sizes of the instructions of the final image, biggest first
+	InstructionSizes []*InstructionSize
+	Variants         []*Variant
+	// EstimatedImageSize is in bytes, 0 if it couldn't be estimated
+	EstimatedImageSize int64
+}
+
+// OptimizeInput is what Optimize optimizes, and how
+type OptimizeInput struct {
+	Project
+	// Goal is what the image is optimized for: size, build-speed, security or all, the default
+	Goal string
+	// Platforms the image is built for. Only base images published for all of them are recommended.
+	Platforms []string
+	// MaxRisk is the riskiest change that's made: cosmetic, cache-impacting, size-impacting or behavior-changing.
+	// Riskier changes are returned as recommendations. Every change is made if it's empty.
+	MaxRisk string
+	// Hardening also hardens the container, eg- runs it as a non-root user. It needs the security goal.
+	Hardening bool
+	// Explain also estimates how much every action changes the size of the image on its own.
+	// Actions are always explained, this only adds their measured impact.
+	Explain bool
+	// LLM optimizes the Dockerfile with an LLM, only the rules are applied if it's nil
+	LLM *LLM
+	// Offline only uses cached or built-in data about base images, instead of fetching it
+	Offline bool
+	// Events receives the progress of the optimization, it's discarded if nil
+	Events events.Handler
+}
+
+// LLM is the OpenAI-compatible API used to optimize Dockerfiles
+type LLM struct {
+	APIKey string
+	// BaseURL is the URL of an OpenAI-compatible API, OpenAI's by default
+	BaseURL string
+	// Model defaults to the model the CLI uses
+	Model string
+	// MaxTokens and MaxCostUSD cap what an optimization can spend, they're unlimited if 0
+	MaxTokens  int64
+	MaxCostUSD float64
+	// MaxFileKB caps how much of a file the LLM reads is sent to it, and MaxReadKB how much of all the files it
+	// reads at once. Larger files are truncated. The defaults are used if they're 0.
+	MaxFileKB int
+	MaxReadKB int
+	// Exclude are patterns in the .gitignore syntax of project files the LLM doesn't see or read, on top of the
+	// ones .gitignore ignores, eg- "vendor/". Include are patterns of files it sees even if they're left out.
+	Exclude []string
+	Include []string
+}
+
+// OptimizeResult is the outcome of an optimization. The files of the project are never modified,
+// the optimized files are returned instead.
+type OptimizeResult struct {
+	Dockerfile   string
+	Dockerignore string
+	// Diff is the changes as a patch that can be applied to the project with "git apply", empty if nothing changed
+	Diff            string
+	ActionsTaken    []*Action
+	Recommendations []*Action
+	// BuildSecrets are the secrets the optimized Dockerfile mounts, which must be passed to docker build with --secret
+	BuildSecrets []BuildSecret
+	// EstimatedImageSize and EstimatedOptimizedImageSize are in bytes, 0 if they couldn't be estimated
+	EstimatedImageSize          int64
+	EstimatedOptimizedImageSize int64
+}
+
+// BuildSecret is a secret mounted by a RUN instruction
+type BuildSecret struct {
+	// ID identifies the secret in the mounts of RUN instructions
+	ID string
+	// Env is the environment variable the secret is read from when the image is built
+	Env string
+}
+
+// Analyze scores the image definition of a project and returns the inefficiencies it found, like "dockershrink analyze".
+func Analyze(ctx context.Context, in AnalyzeInput) (AnalyzeResult, error) {
+	if err := ctx.Err(); err != nil {
+		return AnalyzeResult{}, err
+	}
+	goal, targets, err := parseOptions(in.Goal, in.Platforms)
+	if err != nil {
+		return AnalyzeResult{}, &kindError{err, ErrInvalidInput}
+	}
+	severities, err := parseSeverities(in.Severities)
+	if err != nil {
+		return AnalyzeResult{}, &kindError{err, ErrInvalidInput}
+	}
+	proj, _, err := load(ctx, in.Project, in.Offline, nil)
+	if err != nil {
+		return AnalyzeResult{}, &kindError{err, ErrInvalidProject}
+	}
+	proj.SetEvents(in.Events)
+
+	analysis := proj.AnalyzeDockerImage(&project.AnalyzeOptions{Goal: goal, Platforms: targets, Severities: severities})
+	return AnalyzeResult{
+		Score:              analysis.Score,
+		ScoreBreakdown:     rules.Breakdown(analysis.Findings),
+		Findings:           analysis.Findings,
+		InstructionSizes:   analysis.Sizes,
+		Variants:           analysis.Variants,
+		EstimatedImageSize: analysis.EstimatedImageSize,
+	}, nil
+}
+
+// Optimize optimizes the image definition of a project, like "dockershrink optimize".
+// Cancelling ctx stops the requests in flight to the LLM and registries, and Optimize returns the error of ctx.
+func Optimize(ctx context.Context, in OptimizeInput) (OptimizeResult, error) {
+	if err := ctx.Err(); err != nil {
+		return OptimizeResult{}, err
+	}
+	goal, targets, err := parseOptions(in.Goal, in.Platforms)
+	if err != nil {
+		return OptimizeResult{}, &kindError{err, ErrInvalidInput}
+	}
+	var maxRisk models.Risk
+	if in.MaxRisk != "" {
+		if maxRisk, err = models.ParseRisk(in.MaxRisk); err != nil {
+			return OptimizeResult{}, &kindError{fmt.Errorf("invalid max risk: %w", err), ErrInvalidInput}
+		}
+	}
+	proj, original, err := load(ctx, in.Project, in.Offline, in.LLM)
+	if err != nil {
+		return OptimizeResult{}, &kindError{err, ErrInvalidProject}
+	}
+	proj.SetEvents(in.Events)
+
+	optimized, err := proj.OptimizeDockerImage(ctx, newAIService(in.LLM, in.Events), &project.OptimizeOptions{
+		Goal:      goal,
+		Platforms: targets,
+		MaxRisk:   maxRisk,
+		Hardening: in.Hardening,
+		Explain:   in.Explain,
+	})
+	if err != nil {
+		return OptimizeResult{}, fmt.Errorf("error optimizing Docker image: %w", err)
+	}
+
+	result := OptimizeResult{
+		Dockerfile:                  optimized.Dockerfile,
+		Dockerignore:                optimized.Dockerignore,
+		ActionsTaken:                optimized.ActionsTaken,
+		Recommendations:             optimized.Recommendations,
+		BuildSecrets:                []BuildSecret{},
+		EstimatedImageSize:          optimized.EstimatedSizeBefore,
+		EstimatedOptimizedImageSize: optimized.EstimatedSizeAfter,
+	}
+	if len(optimized.ActionsTaken) > 0 {
+		result.Diff = diff.GitPatch(original.Dockerfile, original.dockerfileContent, optimized.Dockerfile) +
+			diff.GitPatch(original.Dockerignore, original.dockerignoreContent, optimized.Dockerignore)
+		for _, s := range optimized.BuildSecrets {
+			result.BuildSecrets = append(result.BuildSecrets, BuildSecret{ID: s.ID, Env: s.Env})
+		}
+	}
+	return result, nil
+}
+
+func newAIService(llm *LLM, h events.Handler) *ai.AIService {
+	if llm == nil {
+		return nil
+	}
+	log.AddSecret(llm.APIKey)
+	opts := []option.RequestOption{option.WithAPIKey(llm.APIKey)}
+	if llm.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(llm.BaseURL))
+	}
+	// the logger only prints in debug mode, so nothing is printed
+	aiService := ai.NewAIService(log.NewLogger(false), openai.NewClient(opts...))
+	aiService.Events = h
+	if llm.Model != "" {
+		aiService.Model = openai.ChatModel(llm.Model)
+	}
+	aiService.FileLimits = ai.FileLimits{MaxFileBytes: llm.MaxFileKB * 1024, MaxTotalBytes: llm.MaxReadKB * 1024}
+	if llm.MaxTokens > 0 || llm.MaxCostUSD > 0 {
+		aiService.Budget = &ai.Budget{MaxTokens: llm.MaxTokens, MaxCostUSD: llm.MaxCostUSD}
+	}
+	return aiService
+}
+
+func parseOptions(goal string, platforms []string) (models.Goal, []platform.Platform, error) {
+	g := models.GoalAll
+	if goal != "" {
+		var err error
+		if g, err = models.ParseGoal(goal); err != nil {
+			return "", nil, err
+		}
+	}
+	targets := []platform.Platform{}
+	for _, p := range platforms {
+		target, err := platform.Parse(p)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid platform: %w", err)
+		}
+		targets = append(targets, target)
+	}
+	return g, targets, nil
+}
+
+// parseSeverities keys the severity overrides by rule ID
+func parseSeverities(overrides map[string]Severity) (map[string]models.Severity, error) {
+	severities := map[string]models.Severity{}
+	for ref, value := range overrides {
+		rule := rules.Lookup(ref)
+		if rule == nil {
+			return nil, fmt.Errorf("unknown rule %q", ref)
+		}
+		severity, err := rules.ParseSeverity(string(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ref, err)
+		}
+		severities[rule.ID] = severity
+	}
+	return severities, nil
+}
+
+// original is the image definition of a project before it was optimized
+type original struct {
+	Project
+	dockerfileContent   string
+	dockerignoreContent string
+}
+
+// load loads the project. The tree of its directories is only built if the project is optimized with an LLM.
+func load(ctx context.Context, p Project, offline bool, llm *LLM) (*project.Project, *original, error) {
+	if p.Dockerfile == "" {
+		p.Dockerfile = "Dockerfile"
+	}
+	if p.Dockerignore == "" {
+		p.Dockerignore = ".dockerignore"
+	}
+	dir, err := filepath.Abs(p.Dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error resolving project directory: %w", err)
+	}
+	o := &original{Project: p}
+
+	content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(p.Dockerfile)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading %s: %w", p.Dockerfile, err)
+	}
+	o.dockerfileContent = string(content)
+	d, err := parseDockerfile(o.dockerfileContent)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", p.Dockerfile, err)
+	}
+
+	dockerignorePath := p.Dockerignore
+	var di *dockerignore.Dockerignore
+	if content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(p.Dockerignore))); err == nil {
+		o.dockerignoreContent = string(content)
+		di = dockerignore.NewDockerignore(o.dockerignoreContent)
+	} else {
+		// signifies to the rest of the application that the project has no .dockerignore
+		dockerignorePath = ""
+	}
+
+	var pkg *packagejson.PackageJSON
+	for _, name := range []string{"package.json", "src/package.json"} {
+		if content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+			if pkg, err = packagejson.NewPackageJSON(string(content)); err != nil {
+				return nil, nil, fmt.Errorf("failed to parse %s: %w", name, err)
+			}
+			break
+		}
+	}
+
+	ws, err := workspace.Detect(os.DirFS(dir))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error detecting monorepo workspace: %w", err)
+	}
+
+	dirTree := ""
+	var ignored func(string) bool
+	if llm != nil {
+		opts := tree.Options{
+			IgnoreDirs:  dirsExcludedFromTree,
+			Gitignore:   true,
+			Exclude:     llm.Exclude,
+			Include:     llm.Include,
+			MaxFileSize: maxTreeFileSize,
+			SkipBinary:  true,
+		}
+		if dirTree, err = tree.BuildTree(dir, opts); err != nil {
+			return nil, nil, fmt.Errorf("error building directory tree: %w", err)
+		}
+		if len(dirTree) > dirTreeLimit {
+			dirTree = dirTree[:dirTreeLimit] + "\n... (truncated)"
+		}
+		// the LLM can't read the files it doesn't see in the tree either
+		exclude, _ := tree.NewPatterns("", llm.Exclude)
+		include, _ := tree.NewPatterns("", llm.Include)
+		ignored = func(p string) bool {
+			excluded, _ := exclude.Match(p, false)
+			included, _ := include.Match(p, false)
+			return excluded && !included
+		}
+	}
+
+	fs := restrictedfilesystem.NewRestrictedFilesystem(dir, dirTree, p.Dockerfile, dockerignorePath)
+	fs.SetIgnored(ignored)
+	proj := project.NewProject(d, di, pkg, fs, ws, "")
+	proj.SetBaseImages(loadBaseImages(ctx, d, offline))
+	return proj, o, nil
+}
+
+// loadBaseImages returns the data about the official images the Dockerfile is built from.
+// Failing to fetch live data isn't an error, cached or built-in data is used instead.
+func loadBaseImages(ctx context.Context, d *dockerfile.Dockerfile, offline bool) *baseimages.Matrix {
+	names := []string{}
+	for _, stage := range d.GetStages() {
+		names = append(names, stage.BaseImage().Name())
+	}
+	opts := &baseimages.LoadOptions{}
+	if cache, err := baseimages.DefaultCache(); err == nil {
+		opts.Cache = cache
+	}
+	if !offline {
+		opts.Fetcher = baseimages.NewFetcher()
+	}
+	ctx, cancel := context.WithTimeout(ctx, baseImagesTimeout)
+	defer cancel()
+	m, _ := baseimages.Load(ctx, names, opts)
+	return m
+}
+
+// parseDockerfile parses the Dockerfile, fixing the common problems that cause syntax errors if it can't
+func parseDockerfile(content string) (*dockerfile.Dockerfile, error) {
+	d, err := dockerfile.NewDockerfile(content)
+	var syntaxErrs dockerfile.SyntaxErrors
+	if err == nil || !errors.As(err, &syntaxErrs) {
+		return d, err
+	}
+	if recovered, fixes := dockerfile.Recover(content); len(fixes) > 0 {
+		if d, recoverErr := dockerfile.NewDockerfile(recovered); recoverErr == nil {
+			return d, nil
+		}
+	}
+	return nil, err
+}
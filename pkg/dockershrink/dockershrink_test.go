@@ -0,0 +1,109 @@
+package dockershrink
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/duaraghav8/dockershrink/pkg/events"
+)
+
+func writeProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestAnalyze(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"Dockerfile":   "FROM node:20\nWORKDIR /app\nCOPY . .\nRUN npm install\nCMD [\"node\", \"index.js\"]\n",
+		"package.json": `{"name": "app"}`,
+	})
+
+	var rules []string
+	result, err := Analyze(context.Background(), AnalyzeInput{
+		Project:    Project{Dir: dir},
+		Offline:    true,
+		Severities: map[string]Severity{"DS003": SeverityOff, "missing-dockerignore": SeverityInfo},
+		Events: func(e events.Event) {
+			if r, ok := e.(events.RuleApplied); ok {
+				rules = append(rules, r.Rule)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("Analyze() failed: %v", err)
+	}
+	if result.Score == 100 || len(result.Findings) == 0 || len(rules) != len(result.Findings) {
+		t.Errorf("expected findings to be returned and emitted, got score %d, %d findings and %d events", result.Score, len(result.Findings), len(rules))
+	}
+	for _, f := range result.Findings {
+		if f.Code == "DS003" {
+			t.Errorf("expected DS003 to be disabled")
+		}
+		if f.Code == "DS001" && f.Severity != SeverityInfo {
+			t.Errorf("expected DS001 to be overridden to info, got %s", f.Severity)
+		}
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	dockerfile := "FROM node:20\nWORKDIR /app\nCOPY . .\nRUN npm install\nCMD [\"node\", \"index.js\"]\n"
+	dir := writeProject(t, map[string]string{"Dockerfile": dockerfile, "package.json": `{"name": "app"}`})
+
+	result, err := Optimize(context.Background(), OptimizeInput{Project: Project{Dir: dir}, Offline: true})
+	if err != nil {
+		t.Fatalf("Optimize() failed: %v", err)
+	}
+	if !strings.Contains(result.Dockerignore, "node_modules") || !strings.Contains(result.Diff, "+++ b/.dockerignore") {
+		t.Errorf("expected a .dockerignore to be created, got %q with diff %q", result.Dockerignore, result.Diff)
+	}
+	// the project is never modified
+	if content, _ := os.ReadFile(filepath.Join(dir, "Dockerfile")); string(content) != dockerfile {
+		t.Errorf("expected the Dockerfile to be left untouched, got %q", content)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".dockerignore")); err == nil {
+		t.Errorf("expected no .dockerignore to be written to the project")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	valid := writeProject(t, map[string]string{"Dockerfile": "FROM node:20\n"})
+	tests := []struct {
+		name string
+		in   AnalyzeInput
+		want error
+	}{
+		{name: "unknown goal", in: AnalyzeInput{Project: Project{Dir: valid}, Goal: "speed"}, want: ErrInvalidInput},
+		{name: "invalid platform", in: AnalyzeInput{Project: Project{Dir: valid}, Platforms: []string{"windows/sparc/v9/x"}}, want: ErrInvalidInput},
+		{name: "unknown rule", in: AnalyzeInput{Project: Project{Dir: valid}, Severities: map[string]Severity{"DS999": SeverityLow}}, want: ErrInvalidInput},
+		{name: "missing Dockerfile", in: AnalyzeInput{Project: Project{Dir: valid, Dockerfile: "api.Dockerfile"}}, want: ErrInvalidProject},
+		{name: "invalid Dockerfile", in: AnalyzeInput{Project: Project{Dir: writeProject(t, map[string]string{"Dockerfile": "RUN npm ci\n"})}}, want: ErrInvalidProject},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.in.Offline = true
+			if _, err := Analyze(context.Background(), tt.in); !errors.Is(err, tt.want) {
+				t.Errorf("Analyze() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Optimize(ctx, OptimizeInput{Project: Project{Dir: valid}, Offline: true}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a cancelled context to stop the optimization, got %v", err)
+	}
+}
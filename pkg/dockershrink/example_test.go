@@ -0,0 +1,22 @@
+package dockershrink_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/duaraghav8/dockershrink/pkg/dockershrink"
+)
+
+func ExampleAnalyze() {
+	result, err := dockershrink.Analyze(context.Background(), dockershrink.AnalyzeInput{
+		Project: dockershrink.Project{Dir: "path/to/project"},
+		Goal:    "size",
+	})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Score: %d/100\n", result.Score)
+	for _, f := range result.Findings {
+		fmt.Printf("%s:%d %s %s\n", f.Filepath, f.Line, f.Code, f.Title)
+	}
+}
@@ -0,0 +1,135 @@
+// Package events defines the progress events emitted while dockershrink analyzes, optimizes
+// and verifies a project. Embedders like TUIs, servers and IDE plugins subscribe to them to
+// render progress instead of parsing log output.
+package events
+
+import "time"
+
+// Operation is the operation an event belongs to
+type Operation string
+
+const (
+	OperationAnalyze  Operation = "analyze"
+	OperationOptimize Operation = "optimize"
+	OperationGenerate Operation = "generate"
+)
+
+// Event is one of the event types declared in this package.
+// Use a type switch to handle the events an embedder is interested in.
+type Event interface {
+	event()
+}
+
+// AnalysisStarted is emitted when dockershrink starts analyzing, optimizing or generating a project's image definition
+type AnalysisStarted struct {
+	Operation Operation
+	// Goal is what the image is optimized for, eg- "size". Empty for OperationGenerate.
+	Goal string
+	// Dockerfile is the path to the Dockerfile, relative to the project's root
+	Dockerfile string
+}
+
+// AnalysisFinished is emitted when an operation completes
+type AnalysisFinished struct {
+	Operation Operation
+	// Err is set if the operation failed
+	Err error
+}
+
+// ToolCallStarted is emitted when the LLM calls a tool, eg- to read files from the project
+type ToolCallStarted struct {
+	Tool string
+	// Arguments is the JSON object the LLM passed to the tool
+	Arguments string
+}
+
+// ToolCallFinished is emitted when a tool called by the LLM returns
+type ToolCallFinished struct {
+	Tool string
+	// Err is set if the tool failed, in which case the LLM is told about the failure
+	Err error
+}
+
+// RuleApplied is emitted for every rule that changed the image definition, recommended a change or found an issue
+type RuleApplied struct {
+	Rule     string
+	Title    string
+	Filepath string
+	// Line is 1-based, 0 if the rule applies to the whole file
+	Line int
+	// Changed is true if the rule modified the image definition, false if it only reported a recommendation or issue
+	Changed bool
+}
+
+// LLMTokensReceived is emitted after every response from the LLM
+type LLMTokensReceived struct {
+	Model            string
+	PromptTokens     int64
+	CompletionTokens int64
+}
+
+// LLMRequestFinished is emitted after every request to the LLM, whether it succeeded or not
+type LLMRequestFinished struct {
+	Model            string
+	PromptTokens     int64
+	CompletionTokens int64
+	// Retries is the number of times the request was retried, eg- after being rate limited
+	Retries int
+	// Duration includes the retries
+	Duration time.Duration
+	// Err is set if the request failed
+	Err error
+}
+
+// BuildStarted is emitted when an image build starts
+type BuildStarted struct {
+	// Image identifies the build, eg- "original" or "optimized"
+	Image string
+}
+
+// BuildFinished is emitted when an image build completes
+type BuildFinished struct {
+	Image string
+	// Size of the image in bytes, 0 if the build failed
+	Size int64
+	// Err is set if the image failed to build
+	Err error
+}
+
+// BuildProgress is emitted for every line of output of an image build
+type BuildProgress struct {
+	// Image identifies the build, eg- "original" or "optimized"
+	Image string
+	Line  string
+}
+
+func (AnalysisStarted) event()    {}
+func (AnalysisFinished) event()   {}
+func (ToolCallStarted) event()    {}
+func (ToolCallFinished) event()   {}
+func (RuleApplied) event()        {}
+func (LLMTokensReceived) event()  {}
+func (LLMRequestFinished) event() {}
+func (BuildStarted) event()       {}
+func (BuildFinished) event()      {}
+func (BuildProgress) event()      {}
+
+// Handler receives events. It's called synchronously from the goroutine running the operation,
+// so it must return quickly. Handlers that render progress elsewhere should hand events off, eg- over a channel.
+type Handler func(Event)
+
+// Emit sends the event to the handler. A nil handler discards every event.
+func (h Handler) Emit(e Event) {
+	if h != nil {
+		h(e)
+	}
+}
+
+// Multi returns a handler that sends every event to all the given handlers, in order
+func Multi(handlers ...Handler) Handler {
+	return func(e Event) {
+		for _, h := range handlers {
+			h.Emit(e)
+		}
+	}
+}
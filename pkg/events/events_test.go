@@ -0,0 +1,30 @@
+package events
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHandler_Emit(t *testing.T) {
+	// a nil handler discards events
+	var h Handler
+	h.Emit(AnalysisStarted{Operation: OperationAnalyze})
+
+	var first, second []Event
+	h = Multi(
+		func(e Event) { first = append(first, e) },
+		nil,
+		func(e Event) { second = append(second, e) },
+	)
+	emitted := []Event{
+		AnalysisStarted{Operation: OperationOptimize, Goal: "size"},
+		RuleApplied{Rule: "create-dockerignore", Changed: true},
+		AnalysisFinished{Operation: OperationOptimize},
+	}
+	for _, e := range emitted {
+		h.Emit(e)
+	}
+	if !reflect.DeepEqual(first, emitted) || !reflect.DeepEqual(second, emitted) {
+		t.Errorf("expected every handler to receive %v, got %v and %v", emitted, first, second)
+	}
+}